@@ -0,0 +1,30 @@
+package goreason
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/bbiangul/go-reason/analytics"
+)
+
+// startMirror runs the analytics mirror in the background until Close.
+func (e *engine) startMirror(cfg AnalyticsConfig) {
+	interval := time.Duration(cfg.IntervalMinutes) * time.Minute
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	m := analytics.NewMirror(e.store, cfg.Dir, cfg.EvalRunsDir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.Run(ctx, interval)
+	}()
+	e.stopMirror = func() {
+		cancel()
+		<-done
+	}
+	slog.InfoContext(ctx, "analytics mirror started", "dir", cfg.Dir, "interval", interval)
+}
@@ -0,0 +1,129 @@
+package analytics
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// evalResultsTable is the file name (without extension) of exported eval
+// results.
+const evalResultsTable = "eval_results"
+
+// evalResultColumns are the columns of eval_results.parquet: one row per
+// test of every report in every run directory.
+var evalResultColumns = []Column{
+	{"run", String}, {"dataset", String}, {"difficulty", String}, {"question", String},
+	{"category", String}, {"answer", String}, {"passed", Int64}, {"confidence", Double},
+	{"faithfulness", Double}, {"relevance", Double}, {"accuracy", Double},
+	{"strict_accuracy", Double}, {"context_recall", Double}, {"citation_quality", Double},
+	{"claim_grounding", Double}, {"hallucination_score", Double}, {"error", String},
+	{"model", String}, {"prompt_tokens", Int64}, {"completion_tokens", Int64},
+	{"total_tokens", Int64}, {"cost_usd", Double}, {"elapsed_ms", Int64},
+	{"retrieval_ms", Int64}, {"reasoning_ms", Int64},
+}
+
+// evalReport is the subset of eval.Report the mirror exports. It is decoded
+// from the JSON report rather than imported, since the eval package depends
+// on the engine.
+type evalReport struct {
+	Dataset    string `json:"dataset"`
+	Difficulty string `json:"difficulty"`
+	Results    []struct {
+		Question           string  `json:"question"`
+		Category           string  `json:"category"`
+		Answer             string  `json:"answer"`
+		Passed             bool    `json:"passed"`
+		Confidence         float64 `json:"confidence"`
+		Faithfulness       float64 `json:"faithfulness"`
+		Relevance          float64 `json:"relevance"`
+		Accuracy           float64 `json:"accuracy"`
+		StrictAccuracy     float64 `json:"strict_accuracy"`
+		ContextRecall      float64 `json:"context_recall"`
+		CitationQuality    float64 `json:"citation_quality"`
+		ClaimGrounding     float64 `json:"claim_grounding"`
+		HallucinationScore float64 `json:"hallucination_score"`
+		Error              string  `json:"error"`
+		Model              string  `json:"model"`
+		PromptTokens       int64   `json:"prompt_tokens"`
+		CompletionTokens   int64   `json:"completion_tokens"`
+		TotalTokens        int64   `json:"total_tokens"`
+		CostUSD            float64 `json:"cost_usd"`
+		ElapsedMs          int64   `json:"elapsed_ms"`
+		RetrievalMs        int64   `json:"retrieval_ms"`
+		ReasoningMs        int64   `json:"reasoning_ms"`
+	} `json:"results"`
+}
+
+// exportEvalResults writes eval_results.parquet from the
+// <run>/eval-report.json files under evalRunsDir. Reports that cannot be
+// read, e.g. of a run still in progress, are logged and skipped.
+func (m *Mirror) exportEvalResults() (int64, error) {
+	paths, err := filepath.Glob(filepath.Join(m.evalRunsDir, "*", "eval-report.json"))
+	if err != nil {
+		return 0, err
+	}
+	sort.Strings(paths)
+
+	return m.writeFile(evalResultsTable, func(f *os.File) (int64, error) {
+		bw := bufio.NewWriter(f)
+		pw, err := NewWriter(bw, evalResultColumns)
+		if err != nil {
+			return 0, err
+		}
+		var total int64
+		for _, path := range paths {
+			rows, err := evalReportRows(path)
+			if err != nil {
+				slog.Warn("analytics: skipping eval report (non-fatal)", "path", path, "error", err)
+				continue
+			}
+			if err := pw.WriteRowGroup(rows); err != nil {
+				return 0, err
+			}
+			total += int64(len(rows))
+		}
+		if err := pw.Close(); err != nil {
+			return 0, err
+		}
+		return total, bw.Flush()
+	})
+}
+
+// evalReportRows reads one eval-report.json (a list of reports) into rows.
+func evalReportRows(path string) ([][]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var reports []evalReport
+	if err := json.Unmarshal(data, &reports); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", path, err)
+	}
+
+	run := filepath.Base(filepath.Dir(path))
+	var rows [][]interface{}
+	for _, rep := range reports {
+		for _, r := range rep.Results {
+			passed := int64(0)
+			if r.Passed {
+				passed = 1
+			}
+			rows = append(rows, []interface{}{
+				run, rep.Dataset, rep.Difficulty, r.Question,
+				r.Category, r.Answer, passed, r.Confidence,
+				r.Faithfulness, r.Relevance, r.Accuracy,
+				r.StrictAccuracy, r.ContextRecall, r.CitationQuality,
+				r.ClaimGrounding, r.HallucinationScore, r.Error,
+				r.Model, r.PromptTokens, r.CompletionTokens,
+				r.TotalTokens, r.CostUSD, r.ElapsedMs,
+				r.RetrievalMs, r.ReasoningMs,
+			})
+		}
+	}
+	return rows, nil
+}
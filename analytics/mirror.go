@@ -0,0 +1,249 @@
+// Package analytics mirrors the knowledge base's usage and quality data to
+// Parquet files, so analysts can query them with DuckDB or pandas without
+// opening the production SQLite database.
+package analytics
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bbiangul/go-reason/store"
+)
+
+// rowGroupSize is the number of rows per Parquet row group.
+const rowGroupSize = 10000
+
+// Table is a mirrored table: a query over the store and the Parquet
+// columns its result maps to, in order.
+type Table struct {
+	Name    string
+	Query   string
+	Columns []Column
+}
+
+// Tables lists what the mirror exports. Each is written to <name>.parquet.
+var Tables = []Table{
+	{
+		Name: "documents",
+		Query: `SELECT id, path, filename, format, parse_method, status, collection, language,
+			summary, created_at, updated_at FROM documents ORDER BY id`,
+		Columns: []Column{
+			{"id", Int64}, {"path", String}, {"filename", String}, {"format", String},
+			{"parse_method", String}, {"status", String}, {"collection", String}, {"language", String},
+			{"summary", String}, {"created_at", String}, {"updated_at", String},
+		},
+	},
+	{
+		Name: "chunks",
+		Query: `SELECT id, document_id, parent_chunk_id, chunk_type, heading, page_number,
+			position_in_doc, token_count, content FROM chunks ORDER BY id`,
+		Columns: []Column{
+			{"id", Int64}, {"document_id", Int64}, {"parent_chunk_id", Int64}, {"chunk_type", String},
+			{"heading", String}, {"page_number", Int64}, {"position_in_doc", Int64},
+			{"token_count", Int64}, {"content", String},
+		},
+	},
+	{
+		Name: "query_log",
+		Query: `SELECT id, query, answer, confidence, sources, retrieval_method, model_used, rounds,
+			prompt_tokens, completion_tokens, total_tokens, created_at, COALESCE(request_id, ''),
+			COALESCE(refusal_reason, '')
+			FROM query_log ORDER BY id`,
+		Columns: []Column{
+			{"id", Int64}, {"query", String}, {"answer", String}, {"confidence", Double},
+			{"sources", String}, {"retrieval_method", String}, {"model_used", String}, {"rounds", Int64},
+			{"prompt_tokens", Int64}, {"completion_tokens", Int64}, {"total_tokens", Int64},
+			{"created_at", String}, {"request_id", String}, {"refusal_reason", String},
+		},
+	},
+	{
+		Name: "audit_log",
+		Query: `SELECT id, operation, actor, params, outcome, error, document_id, duration_ms,
+			created_at FROM audit_log ORDER BY id`,
+		Columns: []Column{
+			{"id", Int64}, {"operation", String}, {"actor", String}, {"params", String},
+			{"outcome", String}, {"error", String}, {"document_id", Int64}, {"duration_ms", Int64},
+			{"created_at", String},
+		},
+	},
+}
+
+// ExportStats reports one mirror run.
+type ExportStats struct {
+	Rows    map[string]int64 // rows written per table
+	Elapsed time.Duration
+}
+
+// Mirror writes Tables from a store, and optionally eval results, to a
+// directory of Parquet files.
+type Mirror struct {
+	db          *sql.DB
+	dir         string
+	evalRunsDir string
+}
+
+// NewMirror creates a mirror of s into dir. When evalRunsDir is set, the
+// eval-report.json of each run directory under it is exported too (see
+// eval.go).
+func NewMirror(s *store.Store, dir, evalRunsDir string) *Mirror {
+	return &Mirror{db: s.DB(), dir: dir, evalRunsDir: evalRunsDir}
+}
+
+// Export writes every table. Each file is written to a temporary name and
+// renamed into place, so readers never see a partial file.
+func (m *Mirror) Export(ctx context.Context) (*ExportStats, error) {
+	start := time.Now()
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating mirror directory: %w", err)
+	}
+	stats := &ExportStats{Rows: make(map[string]int64, len(Tables))}
+	for _, t := range Tables {
+		n, err := m.exportTable(ctx, t)
+		if err != nil {
+			return nil, fmt.Errorf("exporting %s: %w", t.Name, err)
+		}
+		stats.Rows[t.Name] = n
+	}
+	if m.evalRunsDir != "" {
+		n, err := m.exportEvalResults()
+		if err != nil {
+			return nil, fmt.Errorf("exporting %s: %w", evalResultsTable, err)
+		}
+		stats.Rows[evalResultsTable] = n
+	}
+	stats.Elapsed = time.Since(start)
+	return stats, nil
+}
+
+// Run exports every interval until ctx is cancelled. Failed runs are
+// logged and retried at the next tick.
+func (m *Mirror) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if stats, err := m.Export(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Warn("analytics: mirror export failed (non-fatal)", "dir", m.dir, "error", err)
+		} else {
+			slog.Info("analytics: mirror exported", "dir", m.dir, "rows", stats.Rows,
+				"elapsed", stats.Elapsed.Round(time.Millisecond))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Mirror) exportTable(ctx context.Context, t Table) (int64, error) {
+	return m.writeFile(t.Name, func(f *os.File) (int64, error) {
+		return writeTable(ctx, m.db, t, f)
+	})
+}
+
+// writeFile writes <name>.parquet through a temporary file and a rename.
+func (m *Mirror) writeFile(name string, write func(*os.File) (int64, error)) (int64, error) {
+	path := filepath.Join(m.dir, name+".parquet")
+	tmp, err := os.CreateTemp(m.dir, name+".*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name()) // no-op after the rename
+
+	n, err := write(tmp)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return 0, err
+	}
+	return n, os.Rename(tmp.Name(), path)
+}
+
+// writeTable runs the table's query and writes the rows to f as Parquet.
+func writeTable(ctx context.Context, db *sql.DB, t Table, f *os.File) (int64, error) {
+	rows, err := db.QueryContext(ctx, t.Query)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	bw := bufio.NewWriter(f)
+	pw, err := NewWriter(bw, t.Columns)
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	batch := make([][]interface{}, 0, rowGroupSize)
+	for rows.Next() {
+		row, err := scanRow(rows, t.Columns)
+		if err != nil {
+			return 0, err
+		}
+		batch = append(batch, row)
+		if len(batch) == rowGroupSize {
+			if err := pw.WriteRowGroup(batch); err != nil {
+				return 0, err
+			}
+			total += int64(len(batch))
+			batch = batch[:0]
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if err := pw.WriteRowGroup(batch); err != nil {
+		return 0, err
+	}
+	total += int64(len(batch))
+	if err := pw.Close(); err != nil {
+		return 0, err
+	}
+	return total, bw.Flush()
+}
+
+// scanRow scans one result row into Parquet values, mapping SQL NULL to nil.
+func scanRow(rows *sql.Rows, cols []Column) ([]interface{}, error) {
+	dest := make([]interface{}, len(cols))
+	for i, c := range cols {
+		switch c.Type {
+		case Int64:
+			dest[i] = new(sql.NullInt64)
+		case Double:
+			dest[i] = new(sql.NullFloat64)
+		default:
+			dest[i] = new(sql.NullString)
+		}
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+	row := make([]interface{}, len(cols))
+	for i, d := range dest {
+		switch v := d.(type) {
+		case *sql.NullInt64:
+			if v.Valid {
+				row[i] = v.Int64
+			}
+		case *sql.NullFloat64:
+			if v.Valid {
+				row[i] = v.Float64
+			}
+		case *sql.NullString:
+			if v.Valid {
+				row[i] = v.String
+			}
+		}
+	}
+	return row, nil
+}
@@ -0,0 +1,78 @@
+//go:build cgo
+
+package analytics
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bbiangul/go-reason/store"
+)
+
+func TestMirrorExport(t *testing.T) {
+	dir := t.TempDir()
+	s, err := store.New(filepath.Join(dir, "kb.db"), 4)
+	if err != nil {
+		t.Fatalf("store.New: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	docID, err := s.UpsertDocument(ctx, store.Document{Path: "/a.txt", Filename: "a.txt", Format: "txt", ContentHash: "h", ParseMethod: "native", Status: "ready"})
+	if err != nil {
+		t.Fatalf("UpsertDocument: %v", err)
+	}
+	if _, err := s.InsertChunks(ctx, []store.Chunk{
+		{DocumentID: docID, Content: "one", ChunkType: "paragraph", ContentHash: "c1"},
+		{DocumentID: docID, Content: "two", ChunkType: "paragraph", ContentHash: "c2"},
+	}); err != nil {
+		t.Fatalf("InsertChunks: %v", err)
+	}
+	if err := s.LogQuery(ctx, store.QueryLog{Query: "q?", Answer: "a", Confidence: 0.8}); err != nil {
+		t.Fatalf("LogQuery: %v", err)
+	}
+
+	runs := filepath.Join(dir, "runs")
+	if err := os.MkdirAll(filepath.Join(runs, "run1"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	report := `[{"dataset": "d", "results": [{"question": "q1", "passed": true, "accuracy": 1}, {"question": "q2"}]}]`
+	if err := os.WriteFile(filepath.Join(runs, "run1", "eval-report.json"), []byte(report), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(runs, "broken"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(runs, "broken", "eval-report.json"), []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(dir, "mirror")
+	stats, err := NewMirror(s, out, runs).Export(ctx)
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	want := map[string]int64{"documents": 1, "chunks": 2, "query_log": 1, "audit_log": 0, "eval_results": 2}
+	for name, n := range want {
+		if stats.Rows[name] != n {
+			t.Errorf("%s rows = %d, want %d", name, stats.Rows[name], n)
+		}
+		b, err := os.ReadFile(filepath.Join(out, name+".parquet"))
+		if err != nil {
+			t.Fatalf("reading %s: %v", name, err)
+		}
+		footerLen := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+		meta := (&thriftReader{b: b[len(b)-8-footerLen : len(b)-8]}).readStruct()
+		if meta[3].(int64) != n {
+			t.Errorf("%s footer num_rows = %v, want %d", name, meta[3], n)
+		}
+	}
+
+	leftovers, _ := filepath.Glob(filepath.Join(out, "*.tmp"))
+	if len(leftovers) != 0 {
+		t.Errorf("temporary files left behind: %v", leftovers)
+	}
+}
@@ -0,0 +1,362 @@
+package analytics
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// This file is a minimal Apache Parquet writer: flat schemas of optional
+// INT64, DOUBLE, and UTF-8 string columns, one uncompressed PLAIN data page
+// per column chunk. That is all the mirror needs, and it keeps the module
+// free of an Arrow dependency. The output reads in DuckDB, pandas/pyarrow,
+// Polars, and Spark.
+
+// ColumnType is the physical type of a Column.
+type ColumnType int
+
+const (
+	Int64  ColumnType = iota // INT64
+	Double                   // DOUBLE
+	String                   // BYTE_ARRAY annotated UTF8
+)
+
+// Column describes one column of a Parquet file. All columns are optional
+// (nullable).
+type Column struct {
+	Name string
+	Type ColumnType
+}
+
+// Parquet physical types, encodings, and enums used below (parquet.thrift).
+const (
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	repetitionOptional = 1
+	convertedUTF8      = 0
+	pageTypeData       = 0
+	codecUncompressed  = 0
+)
+
+var parquetMagic = []byte("PAR1")
+
+// Writer writes rows to a Parquet file, one row group per WriteRowGroup
+// call. Close writes the footer; the file is unreadable without it.
+type Writer struct {
+	w         io.Writer
+	offset    int64
+	cols      []Column
+	rowGroups []rowGroupMeta
+	numRows   int64
+}
+
+type rowGroupMeta struct {
+	numRows   int64
+	totalSize int64
+	chunks    []columnChunkMeta
+}
+
+type columnChunkMeta struct {
+	offset    int64 // data page offset
+	size      int64 // page header + page body
+	numValues int64
+}
+
+// NewWriter starts a Parquet file with the given columns on w.
+func NewWriter(w io.Writer, cols []Column) (*Writer, error) {
+	if len(cols) == 0 {
+		return nil, errors.New("parquet: no columns")
+	}
+	pw := &Writer{w: w, cols: cols}
+	if err := pw.write(parquetMagic); err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+// WriteRowGroup writes rows as one row group. Each row holds one value per
+// column: int64, float64, string, or nil for null.
+func (pw *Writer) WriteRowGroup(rows [][]interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	rg := rowGroupMeta{numRows: int64(len(rows))}
+	for ci, col := range pw.cols {
+		body, err := encodeColumn(col, ci, rows)
+		if err != nil {
+			return err
+		}
+		header := pageHeader(len(rows), len(body))
+		chunk := columnChunkMeta{offset: pw.offset, size: int64(len(header) + len(body)), numValues: int64(len(rows))}
+		if err := pw.write(header); err != nil {
+			return err
+		}
+		if err := pw.write(body); err != nil {
+			return err
+		}
+		rg.chunks = append(rg.chunks, chunk)
+		rg.totalSize += chunk.size
+	}
+	pw.rowGroups = append(pw.rowGroups, rg)
+	pw.numRows += rg.numRows
+	return nil
+}
+
+// Close writes the file footer. It does not close the underlying writer.
+func (pw *Writer) Close() error {
+	footer := pw.fileMetadata()
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(len(footer)))
+	for _, b := range [][]byte{footer, size[:], parquetMagic} {
+		if err := pw.write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (pw *Writer) write(b []byte) error {
+	n, err := pw.w.Write(b)
+	pw.offset += int64(n)
+	if err != nil {
+		return fmt.Errorf("parquet: %w", err)
+	}
+	return nil
+}
+
+// encodeColumn builds the data page body of column ci: RLE definition
+// levels (length-prefixed) followed by the PLAIN non-null values.
+func encodeColumn(col Column, ci int, rows [][]interface{}) ([]byte, error) {
+	defined := make([]bool, len(rows))
+	var values []byte
+	for ri, row := range rows {
+		if ci >= len(row) {
+			return nil, fmt.Errorf("parquet: row %d has %d values, want %d", ri, len(row), ci+1)
+		}
+		v := row[ci]
+		if v == nil {
+			continue
+		}
+		defined[ri] = true
+		switch col.Type {
+		case Int64:
+			n, ok := v.(int64)
+			if !ok {
+				return nil, fmt.Errorf("parquet: column %s: %T is not int64", col.Name, v)
+			}
+			values = binary.LittleEndian.AppendUint64(values, uint64(n))
+		case Double:
+			f, ok := v.(float64)
+			if !ok {
+				return nil, fmt.Errorf("parquet: column %s: %T is not float64", col.Name, v)
+			}
+			values = binary.LittleEndian.AppendUint64(values, math.Float64bits(f))
+		case String:
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("parquet: column %s: %T is not string", col.Name, v)
+			}
+			values = binary.LittleEndian.AppendUint32(values, uint32(len(s)))
+			values = append(values, s...)
+		default:
+			return nil, fmt.Errorf("parquet: column %s: unknown type %d", col.Name, col.Type)
+		}
+	}
+
+	levels := encodeDefinitionLevels(defined)
+	body := binary.LittleEndian.AppendUint32(nil, uint32(len(levels)))
+	body = append(body, levels...)
+	return append(body, values...), nil
+}
+
+// encodeDefinitionLevels encodes 0/1 definition levels as RLE runs of the
+// RLE/bit-packing hybrid with bit width 1.
+func encodeDefinitionLevels(defined []bool) []byte {
+	var out []byte
+	for i := 0; i < len(defined); {
+		j := i
+		for j < len(defined) && defined[j] == defined[i] {
+			j++
+		}
+		out = binary.AppendUvarint(out, uint64(j-i)<<1)
+		if defined[i] {
+			out = append(out, 1)
+		} else {
+			out = append(out, 0)
+		}
+		i = j
+	}
+	return out
+}
+
+func physicalType(t ColumnType) int32 {
+	switch t {
+	case Int64:
+		return parquetInt64
+	case Double:
+		return parquetDouble
+	default:
+		return parquetByteArray
+	}
+}
+
+// pageHeader encodes the PageHeader of a v1 data page.
+func pageHeader(numValues, size int) []byte {
+	var t thriftWriter
+	t.i32Field(1, pageTypeData)
+	t.i32Field(2, int32(size))
+	t.i32Field(3, int32(size))
+	t.structField(5)
+	t.i32Field(1, int32(numValues))
+	t.i32Field(2, encodingPlain)
+	t.i32Field(3, encodingRLE)
+	t.i32Field(4, encodingRLE)
+	t.endStruct()
+	t.endStruct()
+	return t.buf
+}
+
+// fileMetadata encodes the FileMetaData footer.
+func (pw *Writer) fileMetadata() []byte {
+	var t thriftWriter
+	t.i32Field(1, 1) // version
+
+	t.listField(2, thriftStruct, len(pw.cols)+1)
+	t.beginStruct()
+	t.binaryField(4, "schema")
+	t.i32Field(5, int32(len(pw.cols)))
+	t.endStruct()
+	for _, col := range pw.cols {
+		t.beginStruct()
+		t.i32Field(1, physicalType(col.Type))
+		t.i32Field(3, repetitionOptional)
+		t.binaryField(4, col.Name)
+		if col.Type == String {
+			t.i32Field(6, convertedUTF8)
+		}
+		t.endStruct()
+	}
+
+	t.i64Field(3, pw.numRows)
+
+	t.listField(4, thriftStruct, len(pw.rowGroups))
+	for _, rg := range pw.rowGroups {
+		t.beginStruct()
+		t.listField(1, thriftStruct, len(rg.chunks))
+		for ci, c := range rg.chunks {
+			col := pw.cols[ci]
+			t.beginStruct()
+			t.i64Field(2, c.offset)
+			t.structField(3)
+			t.i32Field(1, physicalType(col.Type))
+			t.listField(2, thriftI32, 2)
+			t.varint(zigzag(encodingPlain))
+			t.varint(zigzag(encodingRLE))
+			t.listField(3, thriftBinary, 1)
+			t.binary(col.Name)
+			t.i32Field(4, codecUncompressed)
+			t.i64Field(5, c.numValues)
+			t.i64Field(6, c.size)
+			t.i64Field(7, c.size)
+			t.i64Field(9, c.offset)
+			t.endStruct() // ColumnMetaData
+			t.endStruct() // ColumnChunk
+		}
+		t.i64Field(2, rg.totalSize)
+		t.i64Field(3, rg.numRows)
+		t.endStruct()
+	}
+
+	t.binaryField(6, "go-reason analytics mirror")
+	t.endStruct()
+	return t.buf
+}
+
+// Thrift compact protocol type codes.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes the subset of the Thrift compact protocol Parquet
+// metadata needs. The top-level struct is implicit: end it with endStruct.
+type thriftWriter struct {
+	buf    []byte
+	lastID int16
+	stack  []int16
+}
+
+func zigzag(n int64) uint64 { return uint64((n << 1) ^ (n >> 63)) }
+
+func (t *thriftWriter) varint(v uint64) { t.buf = binary.AppendUvarint(t.buf, v) }
+
+func (t *thriftWriter) fieldHeader(id int16, typ byte) {
+	if delta := id - t.lastID; delta > 0 && delta <= 15 {
+		t.buf = append(t.buf, byte(delta)<<4|typ)
+	} else {
+		t.buf = append(t.buf, typ)
+		t.varint(zigzag(int64(id)))
+	}
+	t.lastID = id
+}
+
+func (t *thriftWriter) i32Field(id int16, v int32) {
+	t.fieldHeader(id, thriftI32)
+	t.varint(zigzag(int64(v)))
+}
+
+func (t *thriftWriter) i64Field(id int16, v int64) {
+	t.fieldHeader(id, thriftI64)
+	t.varint(zigzag(v))
+}
+
+func (t *thriftWriter) binaryField(id int16, s string) {
+	t.fieldHeader(id, thriftBinary)
+	t.binary(s)
+}
+
+func (t *thriftWriter) binary(s string) {
+	t.varint(uint64(len(s)))
+	t.buf = append(t.buf, s...)
+}
+
+func (t *thriftWriter) listField(id int16, elem byte, n int) {
+	t.fieldHeader(id, thriftList)
+	if n < 15 {
+		t.buf = append(t.buf, byte(n)<<4|elem)
+		return
+	}
+	t.buf = append(t.buf, 0xF0|elem)
+	t.varint(uint64(n))
+}
+
+// structField starts a struct-valued field; close it with endStruct.
+func (t *thriftWriter) structField(id int16) {
+	t.fieldHeader(id, thriftStruct)
+	t.beginStruct()
+}
+
+// beginStruct starts a struct, e.g. a list element.
+func (t *thriftWriter) beginStruct() {
+	t.stack = append(t.stack, t.lastID)
+	t.lastID = 0
+}
+
+// endStruct writes the stop byte and restores the enclosing field context.
+func (t *thriftWriter) endStruct() {
+	t.buf = append(t.buf, 0)
+	if n := len(t.stack); n > 0 {
+		t.lastID = t.stack[n-1]
+		t.stack = t.stack[:n-1]
+	}
+}
@@ -0,0 +1,166 @@
+package analytics
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// thriftReader decodes Thrift compact structs generically: field id ->
+// value, with nested structs as map[int16]interface{} and lists as
+// []interface{}. It covers the types the writer emits.
+type thriftReader struct {
+	b   []byte
+	pos int
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.b[r.pos:])
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) zigzag() int64 {
+	v := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) value(typ byte) interface{} {
+	switch typ {
+	case thriftI32, thriftI64:
+		return r.zigzag()
+	case thriftBinary:
+		n := int(r.uvarint())
+		s := string(r.b[r.pos : r.pos+n])
+		r.pos += n
+		return s
+	case thriftList:
+		h := r.b[r.pos]
+		r.pos++
+		n, elem := int(h>>4), h&0x0f
+		if n == 15 {
+			n = int(r.uvarint())
+		}
+		list := make([]interface{}, n)
+		for i := range list {
+			list[i] = r.value(elem)
+		}
+		return list
+	case thriftStruct:
+		return r.readStruct()
+	}
+	panic("unsupported thrift type")
+}
+
+func (r *thriftReader) readStruct() map[int16]interface{} {
+	fields := make(map[int16]interface{})
+	var last int16
+	for {
+		h := r.b[r.pos]
+		r.pos++
+		if h == 0 {
+			return fields
+		}
+		typ := h & 0x0f
+		id := last + int16(h>>4)
+		if h>>4 == 0 {
+			id = int16(r.zigzag())
+		}
+		fields[id] = r.value(typ)
+		last = id
+	}
+}
+
+func TestParquetWriter(t *testing.T) {
+	cols := []Column{{"id", Int64}, {"score", Double}, {"name", String}}
+	var buf bytes.Buffer
+	pw, err := NewWriter(&buf, cols)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	rows := [][]interface{}{
+		{int64(1), 0.5, "alpha"},
+		{int64(2), nil, nil},
+		{nil, 2.25, "gamma"},
+	}
+	if err := pw.WriteRowGroup(rows); err != nil {
+		t.Fatalf("WriteRowGroup: %v", err)
+	}
+	if err := pw.WriteRowGroup([][]interface{}{{int64(4), 1.0, "delta"}}); err != nil {
+		t.Fatalf("WriteRowGroup: %v", err)
+	}
+	if err := pw.WriteRowGroup([][]interface{}{{"bad", 1.0, "x"}}); err == nil {
+		t.Error("expected a type error")
+	}
+	if err := pw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	b := buf.Bytes()
+	if !bytes.HasPrefix(b, []byte("PAR1")) || !bytes.HasSuffix(b, []byte("PAR1")) {
+		t.Fatal("missing magic")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+	footer := &thriftReader{b: b[len(b)-8-footerLen : len(b)-8]}
+	meta := footer.readStruct()
+	if footer.pos != footerLen {
+		t.Fatalf("footer decoded %d of %d bytes", footer.pos, footerLen)
+	}
+
+	if meta[3].(int64) != 4 {
+		t.Errorf("num_rows = %v, want 4", meta[3])
+	}
+	schema := meta[2].([]interface{})
+	if len(schema) != 4 || schema[0].(map[int16]interface{})[5].(int64) != 3 {
+		t.Fatalf("unexpected schema: %v", schema)
+	}
+	for i, col := range cols {
+		el := schema[i+1].(map[int16]interface{})
+		if el[4] != col.Name || el[3].(int64) != repetitionOptional {
+			t.Errorf("schema[%d] = %v", i+1, el)
+		}
+	}
+	if _, ok := schema[3].(map[int16]interface{})[6]; !ok {
+		t.Error("string column must be annotated UTF8")
+	}
+
+	rowGroups := meta[4].([]interface{})
+	if len(rowGroups) != 2 {
+		t.Fatalf("row groups = %d, want 2", len(rowGroups))
+	}
+	chunks := rowGroups[0].(map[int16]interface{})[1].([]interface{})
+
+	// Decode the "name" column of the first row group.
+	cm := chunks[2].(map[int16]interface{})[3].(map[int16]interface{})
+	if cm[3].([]interface{})[0] != "name" || cm[5].(int64) != 3 {
+		t.Fatalf("unexpected column metadata: %v", cm)
+	}
+	page := &thriftReader{b: b[cm[9].(int64):]}
+	ph := page.readStruct()
+	body := page.b[page.pos : page.pos+int(ph[3].(int64))]
+	if int64(page.pos)+ph[3].(int64) != cm[7].(int64) {
+		t.Errorf("column chunk size %d does not match page", cm[7])
+	}
+	levelsLen := int(binary.LittleEndian.Uint32(body))
+	levels := body[4 : 4+levelsLen]
+	// Runs: 1 defined, 1 null, 1 defined.
+	if !bytes.Equal(levels, []byte{2, 1, 2, 0, 2, 1}) {
+		t.Errorf("definition levels = %v", levels)
+	}
+	values := body[4+levelsLen:]
+	want := []byte{5, 0, 0, 0, 'a', 'l', 'p', 'h', 'a', 5, 0, 0, 0, 'g', 'a', 'm', 'm', 'a'}
+	if !bytes.Equal(values, want) {
+		t.Errorf("values = %q", values)
+	}
+
+	// And the first value of the "score" column.
+	cm = chunks[1].(map[int16]interface{})[3].(map[int16]interface{})
+	page = &thriftReader{b: b[cm[9].(int64):]}
+	page.readStruct()
+	body = page.b[page.pos:]
+	levelsLen = int(binary.LittleEndian.Uint32(body))
+	if got := math.Float64frombits(binary.LittleEndian.Uint64(body[4+levelsLen:])); got != 0.5 {
+		t.Errorf("score[0] = %v, want 0.5", got)
+	}
+}
@@ -0,0 +1,84 @@
+package goreason
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bbiangul/go-reason/store"
+)
+
+// AnnotateChunk stores a curator annotation on a chunk, replacing the
+// chunk's annotation of the same kind, and returns its ID. Retrieval drops
+// chunks annotated store.AnnotationExclude and multiplies the score of
+// store.AnnotationBoost chunks by Boost; corrections and approved answers
+// (store.AnnotationCanonical) are shown to the model with the chunk. The
+// author defaults to the context's actor (WithActor).
+func (e *engine) AnnotateChunk(ctx context.Context, a store.ChunkAnnotation) (int64, error) {
+	if err := e.writable(); err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	id, docID, err := e.annotateChunk(ctx, a)
+	e.audit(ctx, AuditAnnotate, start, docID, map[string]string{
+		"chunk_id": strconv.FormatInt(a.ChunkID, 10), "kind": a.Kind,
+	}, err)
+	return id, err
+}
+
+func (e *engine) annotateChunk(ctx context.Context, a store.ChunkAnnotation) (int64, int64, error) {
+	a.Text = strings.TrimSpace(a.Text)
+	switch a.Kind {
+	case store.AnnotationCorrection, store.AnnotationCanonical:
+		if a.Text == "" {
+			return 0, 0, fmt.Errorf("%w: %s annotation needs text", ErrInvalidConfig, a.Kind)
+		}
+		a.Boost = 0
+	case store.AnnotationBoost:
+		if a.Boost <= 0 {
+			return 0, 0, fmt.Errorf("%w: boost must be positive", ErrInvalidConfig)
+		}
+	case store.AnnotationExclude:
+		a.Boost = 0
+	default:
+		return 0, 0, fmt.Errorf("%w: unknown annotation kind %q", ErrInvalidConfig, a.Kind)
+	}
+	c, err := e.store.GetChunk(ctx, a.ChunkID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, 0, fmt.Errorf("%w: %d", ErrChunkNotFound, a.ChunkID)
+		}
+		return 0, 0, err
+	}
+	if a.Author == "" {
+		a.Author = ActorFromContext(ctx)
+	}
+	id, err := e.store.UpsertChunkAnnotation(ctx, a)
+	return id, c.DocumentID, err
+}
+
+// ChunkAnnotations returns curator annotations, newest first, limited to
+// one chunk when chunkID is non-zero.
+func (e *engine) ChunkAnnotations(ctx context.Context, chunkID int64) ([]store.ChunkAnnotation, error) {
+	return e.store.ListChunkAnnotations(ctx, chunkID)
+}
+
+// DeleteChunkAnnotation removes a curator annotation.
+func (e *engine) DeleteChunkAnnotation(ctx context.Context, id int64) error {
+	if err := e.writable(); err != nil {
+		return err
+	}
+	start := time.Now()
+	err := e.store.DeleteChunkAnnotation(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = fmt.Errorf("%w: %d", ErrAnnotationNotFound, id)
+	}
+	e.audit(ctx, AuditDeleteAnnotation, start, 0, map[string]string{
+		"annotation_id": strconv.FormatInt(id, 10),
+	}, err)
+	return err
+}
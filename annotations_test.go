@@ -0,0 +1,87 @@
+package goreason
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bbiangul/go-reason/store"
+)
+
+func TestChunkAnnotationsSteerQuery(t *testing.T) {
+	eng, srv, path := faultEngine(t, nil)
+	ctx := WithActor(context.Background(), "curator")
+	docID, err := eng.Ingest(ctx, path)
+	if err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	chunks, err := eng.(*engine).store.GetChunksByDocument(ctx, docID)
+	if err != nil || len(chunks) == 0 {
+		t.Fatalf("GetChunksByDocument: %d chunks, %v", len(chunks), err)
+	}
+
+	var (
+		mu      sync.Mutex
+		prompts []string
+	)
+	chat := srv.ChatFunc
+	srv.ChatFunc = func(prompt string) string {
+		mu.Lock()
+		prompts = append(prompts, prompt)
+		mu.Unlock()
+		return chat(prompt)
+	}
+
+	for _, c := range chunks {
+		if _, err := eng.AnnotateChunk(ctx, store.ChunkAnnotation{ChunkID: c.ID, Kind: store.AnnotationCorrection, Text: "Since rev. C the valve opens at 12 bar."}); err != nil {
+			t.Fatalf("AnnotateChunk: %v", err)
+		}
+	}
+	if _, err := eng.Query(ctx, "When does the relief valve open?"); err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if !strings.Contains(strings.Join(prompts, "\n"), "[Curator correction: Since rev. C the valve opens at 12 bar.]") {
+		t.Error("correction not shown to the model")
+	}
+
+	list, err := eng.ChunkAnnotations(ctx, chunks[0].ID)
+	if err != nil || len(list) != 1 || list[0].Author != "curator" {
+		t.Fatalf("ChunkAnnotations = %+v, %v", list, err)
+	}
+	if err := eng.DeleteChunkAnnotation(ctx, list[0].ID); err != nil {
+		t.Fatalf("DeleteChunkAnnotation: %v", err)
+	}
+	if err := eng.DeleteChunkAnnotation(ctx, list[0].ID); !errors.Is(err, ErrAnnotationNotFound) {
+		t.Errorf("second delete = %v, want ErrAnnotationNotFound", err)
+	}
+
+	// Excluding every chunk leaves nothing to answer from.
+	for _, c := range chunks {
+		if _, err := eng.AnnotateChunk(ctx, store.ChunkAnnotation{ChunkID: c.ID, Kind: store.AnnotationExclude}); err != nil {
+			t.Fatalf("AnnotateChunk: %v", err)
+		}
+	}
+	if _, err := eng.Query(ctx, "When does the relief valve open?"); !errors.Is(err, ErrNoResults) {
+		t.Errorf("query over excluded chunks = %v, want ErrNoResults", err)
+	}
+
+	for _, bad := range []store.ChunkAnnotation{
+		{ChunkID: chunks[0].ID, Kind: "pin"},
+		{ChunkID: chunks[0].ID, Kind: store.AnnotationBoost},
+		{ChunkID: chunks[0].ID, Kind: store.AnnotationCanonical, Text: " "},
+	} {
+		if _, err := eng.AnnotateChunk(ctx, bad); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("annotation %+v: error = %v, want ErrInvalidConfig", bad, err)
+		}
+	}
+	if _, err := eng.AnnotateChunk(ctx, store.ChunkAnnotation{ChunkID: 9999, Kind: store.AnnotationExclude}); !errors.Is(err, ErrChunkNotFound) {
+		t.Errorf("unknown chunk error = %v, want ErrChunkNotFound", err)
+	}
+
+	audit, err := eng.AuditLog(ctx, AuditFilter{Operation: AuditAnnotate})
+	if err != nil || len(audit) == 0 || audit[0].Actor != "curator" {
+		t.Errorf("annotate audit entries = %+v, %v", audit, err)
+	}
+}
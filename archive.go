@@ -0,0 +1,321 @@
+package goreason
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bbiangul/go-reason/store"
+)
+
+// Archive formats Ingest extracts.
+const (
+	archiveZip   = "zip"
+	archiveTar   = "tar"
+	archiveTarGz = "tar.gz"
+)
+
+// parseMethodArchive is the parse method recorded for an archive document.
+// It has no chunks of its own; its members are documents linked to it.
+const parseMethodArchive = "archive"
+
+// Extraction limits, against archive bombs.
+const (
+	maxArchiveDepth   = 3       // levels of archives nested within an archive
+	maxArchiveMembers = 10000   // files across all levels
+	maxArchiveBytes   = 4 << 30 // extracted bytes across all levels
+)
+
+// archiveFormat returns the archive format of path, from its extension.
+func archiveFormat(path string) (string, bool) {
+	lower := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return archiveZip, true
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return archiveTarGz, true
+	case strings.HasSuffix(lower, ".tar"):
+		return archiveTar, true
+	}
+	return "", false
+}
+
+// archiveMemberPath is the document path of the archive member name, e.g.
+// "/docs/bundle.zip!/manuals/pump.pdf".
+func archiveMemberPath(archive, name string) string {
+	return archive + "!/" + name
+}
+
+// ingestArchive ingests each supported file of a .zip, .tar, or .tar.gz
+// archive as a document of its own, linked to a document recorded for the
+// archive. The archive is extracted to a temporary workspace, removed
+// afterwards; archives within it are extracted in turn. Re-ingesting
+// extracts the archive again: unchanged members are skipped by their
+// content hash, and members no longer in the archive are deleted.
+// Unsupported members are skipped, and a member that fails to ingest is
+// logged without failing the archive unless no member could be ingested.
+func (e *engine) ingestArchive(ctx context.Context, path, format string, options *ingestOptions) (int64, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return 0, fmt.Errorf("resolving path: %w", err)
+	}
+	hash, err := fileHash(absPath)
+	if err != nil {
+		return 0, fmt.Errorf("hashing file: %w", err)
+	}
+	if options.importance < 0 {
+		return 0, fmt.Errorf("%w: importance must be positive, got %v", ErrInvalidConfig, options.importance)
+	}
+
+	docPath, expiresAt := absPath, ""
+	if options.session != "" {
+		docPath = sessionPath(options.session, absPath)
+		expiresAt = store.SessionExpiry(time.Now().Add(e.sessionTTL()))
+	}
+	collection := options.collection
+	existing, err := e.store.GetDocumentByPath(ctx, docPath)
+	if err == nil && collection == "" {
+		collection = existing.Collection
+	}
+	var metadataJSON string
+	if options.metadata != nil {
+		data, _ := json.Marshal(options.metadata)
+		metadataJSON = string(data)
+	}
+	filename := filepath.Base(absPath)
+	parentID, err := e.store.UpsertDocument(ctx, store.Document{
+		Path:        docPath,
+		Filename:    filename,
+		Format:      format,
+		ContentHash: hash,
+		ParseMethod: parseMethodArchive,
+		Status:      "processing",
+		Metadata:    metadataJSON,
+		Collection:  collection,
+		Importance:  options.importance,
+		SessionID:   options.session,
+		ExpiresAt:   expiresAt,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("upserting document: %w", err)
+	}
+	// After an update UpsertDocument may return the connection's last
+	// inserted row rather than the document's.
+	if existing != nil {
+		parentID = existing.ID
+	}
+
+	start := time.Now()
+	workspace, err := os.MkdirTemp("", "goreason-archive-")
+	if err != nil {
+		e.store.UpdateDocumentStatus(ctx, parentID, "error")
+		return 0, fmt.Errorf("creating archive workspace: %w", err)
+	}
+	defer os.RemoveAll(workspace)
+
+	x := &archiveExtractor{seen: make(map[string]bool)}
+	if err := x.extract(absPath, format, "", workspace, 0); err != nil {
+		e.store.UpdateDocumentStatus(ctx, parentID, "error")
+		return 0, err
+	}
+	slog.InfoContext(ctx, "ingest: archive extracted", "file", filename, "files", len(x.members),
+		"bytes", x.bytes, "elapsed", time.Since(start).Round(time.Millisecond))
+
+	kept := make(map[string]bool, len(x.members))
+	ingested, failed := 0, 0
+	for _, m := range x.members {
+		if err := ctx.Err(); err != nil {
+			e.store.UpdateDocumentStatus(ctx, parentID, "error")
+			return 0, err
+		}
+		ext := strings.ToLower(strings.TrimPrefix(pathpkg.Ext(m.name), "."))
+		if _, err := e.parsers.ForMethod(ext, ""); err != nil {
+			slog.InfoContext(ctx, "ingest: skipping unsupported archive member", "file", filename, "member", m.name)
+			continue
+		}
+		memberPath := archiveMemberPath(docPath, m.name)
+		kept[memberPath] = true
+
+		memberOpts := *options
+		memberOpts.member = memberPath
+		memberOpts.parentID = parentID
+		memberOpts.collection = collection
+		// An update re-ingests only the members whose content changed.
+		memberOpts.forceReparse = options.forceReparse && !options.incremental
+		if _, err := e.ingest(ctx, m.path, &memberOpts); err != nil {
+			if ctx.Err() != nil {
+				e.store.UpdateDocumentStatus(ctx, parentID, "error")
+				return 0, err
+			}
+			failed++
+			slog.WarnContext(ctx, "ingest: archive member failed (non-fatal)",
+				"file", filename, "member", m.name, "error", err)
+			continue
+		}
+		ingested++
+	}
+
+	// Members removed from the archive since the last ingest.
+	children, err := e.store.ListChildDocuments(ctx, parentID)
+	if err != nil {
+		return 0, fmt.Errorf("listing archive members: %w", err)
+	}
+	for _, child := range children {
+		if kept[child.Path] {
+			continue
+		}
+		if err := e.store.DeleteDocument(ctx, child.ID); err != nil {
+			return 0, fmt.Errorf("deleting removed archive member: %w", err)
+		}
+		slog.InfoContext(ctx, "ingest: removed archive member deleted", "file", filename, "member", child.Path)
+	}
+
+	if ingested == 0 {
+		e.store.UpdateDocumentStatus(ctx, parentID, "error")
+		if failed > 0 {
+			return 0, fmt.Errorf("%w: no member of %s could be ingested", ErrParsingFailed, filename)
+		}
+		return 0, fmt.Errorf("%w: %s contains no supported files", ErrUnsupportedFormat, filename)
+	}
+	e.store.UpdateDocumentStatus(ctx, parentID, "ready")
+	slog.InfoContext(ctx, "ingest: archive complete", "file", filename, "doc_id", parentID,
+		"ingested", ingested, "failed", failed, "elapsed", time.Since(start).Round(time.Millisecond))
+	return parentID, nil
+}
+
+// archiveMember is a file extracted from an archive.
+type archiveMember struct {
+	name string // path within the archive; "!/" separates nested archives
+	path string // extracted file
+}
+
+// archiveExtractor extracts an archive and the archives nested in it,
+// within the extraction limits.
+type archiveExtractor struct {
+	members []archiveMember
+	seen    map[string]bool
+	bytes   int64
+}
+
+// extract extracts the archive at path into dir. prefix is prepended to
+// member names of a nested archive.
+func (x *archiveExtractor) extract(path, format, prefix, dir string, depth int) error {
+	if format == archiveZip {
+		r, err := zip.OpenReader(path)
+		if err != nil {
+			return fmt.Errorf("%w: %s%s: %v", ErrInvalidArchive, prefix, filepath.Base(path), err)
+		}
+		defer r.Close()
+		for _, f := range r.File {
+			if !f.Mode().IsRegular() {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return fmt.Errorf("%w: %s%s: %v", ErrInvalidArchive, prefix, f.Name, err)
+			}
+			err = x.add(f.Name, rc, prefix, dir, depth)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if format == archiveTarGz {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("%w: %s%s: %v", ErrInvalidArchive, prefix, filepath.Base(path), err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %s%s: %v", ErrInvalidArchive, prefix, filepath.Base(path), err)
+		}
+		// Links and devices are skipped: a link could point outside the
+		// workspace.
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := x.add(hdr.Name, tr, prefix, dir, depth); err != nil {
+			return err
+		}
+	}
+}
+
+// add writes the member name read from r under dir, or extracts it when
+// it is itself an archive.
+func (x *archiveExtractor) add(name string, r io.Reader, prefix, dir string, depth int) error {
+	name = pathpkg.Clean(strings.ReplaceAll(name, `\`, "/"))
+	if pathpkg.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") || filepath.VolumeName(name) != "" {
+		return fmt.Errorf("%w: member %q is outside the archive", ErrInvalidArchive, prefix+name)
+	}
+	// Skip hidden files and the resource forks macOS adds to archives.
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") || part == "__MACOSX" {
+			return nil
+		}
+	}
+	if x.seen[prefix+name] {
+		return nil
+	}
+	x.seen[prefix+name] = true
+	if len(x.members) >= maxArchiveMembers {
+		return fmt.Errorf("%w: more than %d files", ErrInvalidArchive, maxArchiveMembers)
+	}
+
+	dest := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return err
+	}
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(out, io.LimitReader(r, maxArchiveBytes-x.bytes+1))
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("%w: extracting %s: %v", ErrInvalidArchive, prefix+name, err)
+	}
+	x.bytes += n
+	if x.bytes > maxArchiveBytes {
+		return fmt.Errorf("%w: extracts to more than %d bytes", ErrInvalidArchive, int64(maxArchiveBytes))
+	}
+
+	if format, ok := archiveFormat(name); ok && depth < maxArchiveDepth {
+		nested, err := os.MkdirTemp(dir, "nested-")
+		if err != nil {
+			return err
+		}
+		return x.extract(dest, format, prefix+name+"!/", nested, depth+1)
+	}
+	x.members = append(x.members, archiveMember{name: prefix + name, path: dest})
+	return nil
+}
@@ -0,0 +1,186 @@
+package goreason
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// writeZip writes a zip archive of files (name to content) to path.
+func writeZip(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	if err := os.WriteFile(path, zipBytes(t, files), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func zipBytes(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(files[name]))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func memberPaths(t *testing.T, eng Engine, parentID int64) []string {
+	t.Helper()
+	docs, err := eng.ListDocuments(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, d := range docs {
+		if d.ParentID == parentID {
+			paths = append(paths, d.Path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestIngestZipArchive(t *testing.T) {
+	eng, _, pump := faultEngine(t, nil)
+	ctx := context.Background()
+	manual, err := os.ReadFile(pump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := filepath.Join(filepath.Dir(pump), "bundle.zip")
+	writeZip(t, archive, map[string]string{
+		"manuals/pump.txt":      string(manual),
+		"manuals/drawing.bin":   "\x00\x01",
+		"__MACOSX/._pump.txt":   "fork",
+		"extras/valves.zip":     string(zipBytes(t, map[string]string{"valve.txt": "The relief valve is spring loaded."})),
+		"manuals/../notes.txt":  "Notes on the pump.",
+		"manuals/.DS_Store.txt": "hidden",
+	})
+
+	id, err := eng.Ingest(ctx, archive)
+	if err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	parent, err := eng.Document(ctx, id)
+	if err != nil || parent.Format != "zip" || parent.ParseMethod != "archive" || parent.Status != "ready" {
+		t.Fatalf("archive document = %+v, %v", parent, err)
+	}
+	want := []string{
+		archive + "!/extras/valves.zip!/valve.txt",
+		archive + "!/manuals/pump.txt",
+		archive + "!/notes.txt",
+	}
+	got := memberPaths(t, eng, id)
+	if len(got) != len(want) {
+		t.Fatalf("members = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("member %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+	answer, err := eng.Query(ctx, "At what pressure does the relief valve open?")
+	if err != nil || len(answer.Sources) == 0 {
+		t.Fatalf("Query: %+v, %v", answer, err)
+	}
+
+	// Re-ingesting unchanged keeps the members; an update drops removed
+	// members and re-ingests changed ones.
+	if again, err := eng.Ingest(ctx, archive); err != nil || again != id {
+		t.Fatalf("re-Ingest = %d, %v", again, err)
+	}
+	writeZip(t, archive, map[string]string{"manuals/pump.txt": string(manual) + "Drain the pump before service.\n"})
+	results, err := eng.UpdateAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || !results[0].Changed || results[0].Error != nil {
+		t.Errorf("UpdateAll = %+v", results)
+	}
+	if got := memberPaths(t, eng, id); len(got) != 1 || got[0] != archive+"!/manuals/pump.txt" {
+		t.Errorf("members after update = %v", got)
+	}
+
+	if err := eng.Delete(ctx, id); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if docs, err := eng.ListDocuments(ctx); err != nil || len(docs) != 0 {
+		t.Errorf("documents after delete = %+v, %v", docs, err)
+	}
+}
+
+func TestIngestTarGzArchive(t *testing.T) {
+	eng, _, pump := faultEngine(t, nil)
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	body := "Check the relief valve every 500 hours."
+	tw.WriteHeader(&tar.Header{Name: "docs/", Typeflag: tar.TypeDir, Mode: 0o755})
+	tw.WriteHeader(&tar.Header{Name: "docs/service.txt", Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(body))})
+	tw.Write([]byte(body))
+	tw.WriteHeader(&tar.Header{Name: "docs/link.txt", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"})
+	tw.Close()
+	gz.Close()
+	archive := filepath.Join(filepath.Dir(pump), "service.tar.gz")
+	if err := os.WriteFile(archive, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := eng.Ingest(ctx, archive)
+	if err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	if got := memberPaths(t, eng, id); len(got) != 1 || got[0] != archive+"!/docs/service.txt" {
+		t.Errorf("members = %v", got)
+	}
+}
+
+func TestIngestArchiveRejected(t *testing.T) {
+	eng, _, pump := faultEngine(t, nil)
+	ctx := context.Background()
+	dir := filepath.Dir(pump)
+
+	escape := filepath.Join(dir, "escape.zip")
+	writeZip(t, escape, map[string]string{"../../outside.txt": "x"})
+	if _, err := eng.Ingest(ctx, escape); !errors.Is(err, ErrInvalidArchive) {
+		t.Errorf("Ingest(path traversal) = %v, want ErrInvalidArchive", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "..", "outside.txt")); !os.IsNotExist(err) {
+		t.Errorf("member written outside the workspace: %v", err)
+	}
+
+	unsupported := filepath.Join(dir, "images.zip")
+	writeZip(t, unsupported, map[string]string{"photo.bin": "x"})
+	if _, err := eng.Ingest(ctx, unsupported); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Ingest(no supported files) = %v, want ErrUnsupportedFormat", err)
+	}
+
+	corrupt := filepath.Join(dir, "corrupt.zip")
+	if err := os.WriteFile(corrupt, []byte("not a zip"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := eng.Ingest(ctx, corrupt); !errors.Is(err, ErrInvalidArchive) {
+		t.Errorf("Ingest(corrupt) = %v, want ErrInvalidArchive", err)
+	}
+}
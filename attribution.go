@@ -0,0 +1,71 @@
+package goreason
+
+import (
+	"math"
+	"sort"
+)
+
+// DocumentAttribution is one document's share of an answer drawn from
+// several documents.
+type DocumentAttribution struct {
+	DocumentID int64  `json:"document_id"`
+	Filename   string `json:"filename"`
+	// Percent is the document's part of the summed retrieval scores of
+	// the sources the answer cites, or of all its sources when it cites
+	// none by name, rounded to one decimal.
+	Percent float64 `json:"percent"`
+	Sources int     `json:"sources"`         // the answer's sources from the document
+	Cited   int     `json:"cited,omitempty"` // of those, the ones its text cites
+}
+
+// attributeDocuments splits answer's evidence between its documents by the
+// scores of the sources its text cites, largest share first. It returns
+// nil unless the evidence spans several documents, and for answers that
+// found nothing.
+func attributeDocuments(answer *Answer) []DocumentAttribution {
+	if answer.Refusal != "" || answer.Found != nil && !*answer.Found {
+		return nil
+	}
+	cited := make(map[int64]bool)
+	for _, id := range citedChunks(answer) {
+		cited[id] = true
+	}
+
+	var docs []DocumentAttribution
+	index := make(map[int64]int)
+	weight := make(map[int64]float64)
+	var total float64
+	for _, s := range answer.Sources {
+		i, ok := index[s.DocumentID]
+		if !ok {
+			i = len(docs)
+			index[s.DocumentID] = i
+			docs = append(docs, DocumentAttribution{DocumentID: s.DocumentID, Filename: s.Filename})
+		}
+		docs[i].Sources++
+		if cited[s.ChunkID] {
+			docs[i].Cited++
+		}
+		// Without citations every source counts.
+		if (len(cited) == 0 || cited[s.ChunkID]) && s.Score > 0 {
+			weight[s.DocumentID] += s.Score
+			total += s.Score
+		}
+	}
+	if total == 0 {
+		return nil
+	}
+
+	kept := docs[:0]
+	for _, d := range docs {
+		if w := weight[d.DocumentID]; w > 0 {
+			d.Percent = math.Round(w/total*1000) / 10
+			kept = append(kept, d)
+		}
+	}
+	if len(kept) < 2 {
+		return nil
+	}
+	sort.SliceStable(kept, func(i, j int) bool { return kept[i].Percent > kept[j].Percent })
+	return kept
+}
@@ -0,0 +1,44 @@
+package goreason
+
+import "testing"
+
+func TestAttributeDocuments(t *testing.T) {
+	answer := &Answer{
+		Text: "The valve opens at 10 bar (manual.pdf, p.3); an old memo said 12 bar (memo.docx).",
+		Sources: []Source{
+			{ChunkID: 1, DocumentID: 10, Filename: "manual.pdf", PageNumber: 3, Score: 0.8},
+			{ChunkID: 2, DocumentID: 20, Filename: "memo.docx", Score: 0.2},
+			{ChunkID: 3, DocumentID: 30, Filename: "unrelated.pdf", Score: 0.5},
+		},
+	}
+	got := attributeDocuments(answer)
+	if len(got) != 2 {
+		t.Fatalf("attribution = %+v, want the two cited documents", got)
+	}
+	if got[0].DocumentID != 10 || got[0].Percent != 80 || got[0].Cited != 1 || got[0].Sources != 1 {
+		t.Errorf("first = %+v, want manual.pdf at 80%%", got[0])
+	}
+	if got[1].DocumentID != 20 || got[1].Percent != 20 {
+		t.Errorf("second = %+v, want memo.docx at 20%%", got[1])
+	}
+
+	// Without citations every source counts.
+	answer.Text = "The valve opens at 10 bar."
+	got = attributeDocuments(answer)
+	if len(got) != 3 || got[0].Percent != 53.3 || got[1].Percent != 33.3 || got[2].Percent != 13.3 {
+		t.Errorf("uncited attribution = %+v", got)
+	}
+
+	// One document, or an answer that found nothing, has no attribution.
+	answer.Sources = answer.Sources[:1]
+	if got := attributeDocuments(answer); got != nil {
+		t.Errorf("single document attribution = %+v", got)
+	}
+	notFound := false
+	answer = &Answer{Found: &notFound, Sources: []Source{
+		{ChunkID: 1, DocumentID: 10, Score: 0.5}, {ChunkID: 2, DocumentID: 20, Score: 0.5},
+	}}
+	if got := attributeDocuments(answer); got != nil {
+		t.Errorf("not found attribution = %+v", got)
+	}
+}
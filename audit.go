@@ -0,0 +1,158 @@
+package goreason
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/bbiangul/go-reason/store"
+)
+
+// Audited operations.
+const (
+	AuditIngest    = "ingest"
+	AuditUpdate    = "update"
+	AuditUpdateAll = "update_all"
+	AuditDelete    = "delete"
+	AuditReembed   = "reembed"
+
+	AuditResumeIngest = "resume_ingest"
+	AuditReparse      = "reparse"
+
+	AuditRebuildCommunities = "rebuild_communities"
+	AuditRefreshCommunities = "refresh_communities"
+	AuditCompact            = "compact"
+	AuditImportGraph        = "import_graph"
+	AuditImportIndex        = "import_index"
+
+	AuditDetectContradictions = "detect_contradictions"
+
+	AuditAnnotate         = "annotate"
+	AuditDeleteAnnotation = "delete_annotation"
+
+	AuditSaveTemplate   = "save_template"
+	AuditDeleteTemplate = "delete_template"
+)
+
+// Audit outcomes.
+const (
+	AuditOutcomeOK    = "ok"
+	AuditOutcomeError = "error"
+)
+
+// AuditEntry records one mutation of the index: who ran it, when, with
+// which parameters, and how it ended.
+type AuditEntry struct {
+	ID         int64             `json:"id"`
+	Operation  string            `json:"operation"`
+	Actor      string            `json:"actor,omitempty"`
+	Params     map[string]string `json:"params,omitempty"`
+	Outcome    string            `json:"outcome"`
+	Error      string            `json:"error,omitempty"`
+	DocumentID int64             `json:"document_id,omitempty"`
+	DurationMs int64             `json:"duration_ms"`
+	CreatedAt  string            `json:"created_at"`
+}
+
+// AuditFilter narrows AuditLog. Zero fields match everything.
+type AuditFilter struct {
+	Operation  string    `json:"operation,omitempty"`
+	Actor      string    `json:"actor,omitempty"`
+	DocumentID int64     `json:"document_id,omitempty"`
+	Since      time.Time `json:"since,omitempty"`
+	Limit      int       `json:"limit,omitempty"` // default 100
+}
+
+type actorKey struct{}
+
+// WithActor returns a context that attributes the mutations run with it
+// to actor (a user name, API client, or job name) in the audit log.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor set by WithActor, or "".
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// audit records a finished operation. Failing to write the entry never
+// fails the operation itself.
+func (e *engine) audit(ctx context.Context, op string, start time.Time, docID int64, params map[string]string, opErr error) {
+	entry := store.AuditEntry{
+		Operation:  op,
+		Actor:      ActorFromContext(ctx),
+		Outcome:    AuditOutcomeOK,
+		DocumentID: docID,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if len(params) > 0 {
+		data, _ := json.Marshal(params)
+		entry.Params = string(data)
+	}
+	if opErr != nil {
+		entry.Outcome = AuditOutcomeError
+		entry.Error = opErr.Error()
+	}
+	// The operation's context may already be cancelled; the record of it
+	// should still be written.
+	if err := e.queryLog.InsertAuditEntry(context.WithoutCancel(ctx), entry); err != nil {
+		slog.WarnContext(ctx, "audit: recording operation failed (non-fatal)", "operation", op, "error", err)
+	}
+}
+
+// AuditLog returns recorded mutations matching f, newest first.
+func (e *engine) AuditLog(ctx context.Context, f AuditFilter) ([]AuditEntry, error) {
+	rows, err := e.queryLog.ListAuditEntries(ctx, store.AuditFilter{
+		Operation:  f.Operation,
+		Actor:      f.Actor,
+		DocumentID: f.DocumentID,
+		Since:      f.Since,
+		Limit:      f.Limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]AuditEntry, len(rows))
+	for i, r := range rows {
+		out[i] = AuditEntry{
+			ID:         r.ID,
+			Operation:  r.Operation,
+			Actor:      r.Actor,
+			Outcome:    r.Outcome,
+			Error:      r.Error,
+			DocumentID: r.DocumentID,
+			DurationMs: r.DurationMs,
+			CreatedAt:  r.CreatedAt,
+		}
+		if r.Params != "" {
+			_ = json.Unmarshal([]byte(r.Params), &out[i].Params)
+		}
+	}
+	return out, nil
+}
+
+// auditParams describes the ingest options for the audit log.
+func (o *ingestOptions) auditParams(path string) map[string]string {
+	params := map[string]string{"path": path}
+	if o.forceReparse {
+		params["force"] = "true"
+	}
+	if o.parseMethod != "" {
+		params["parse_method"] = o.parseMethod
+	}
+	if o.collection != "" {
+		params["collection"] = o.collection
+	}
+	if o.session != "" {
+		params["session"] = o.session
+	}
+	if o.importance != 0 {
+		params["importance"] = strconv.FormatFloat(o.importance, 'g', -1, 64)
+	}
+	o.selection.auditParams(params)
+	return params
+}
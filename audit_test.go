@@ -0,0 +1,57 @@
+package goreason
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/bbiangul/go-reason/store"
+)
+
+func TestAuditLog(t *testing.T) {
+	s, err := store.New(filepath.Join(t.TempDir(), "audit.db"), 4)
+	if err != nil {
+		t.Fatalf("store.New: %v", err)
+	}
+	defer s.Close()
+	e := &engine{store: s, queryLog: s}
+
+	ctx := WithActor(context.Background(), "alice")
+	docID, err := s.UpsertDocument(ctx, store.Document{Path: "/d.txt", Filename: "d.txt", Format: "txt", ContentHash: "h", Status: "ready"})
+	if err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	if err := e.Delete(ctx, docID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := e.Update(context.Background(), "/missing.txt"); !errors.Is(err, ErrDocumentNotFound) {
+		t.Fatalf("Update error = %v, want ErrDocumentNotFound", err)
+	}
+
+	entries, err := e.AuditLog(ctx, AuditFilter{})
+	if err != nil {
+		t.Fatalf("AuditLog: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %+v", entries)
+	}
+	update, del := entries[0], entries[1]
+	if update.Operation != AuditUpdate || update.Outcome != AuditOutcomeError || update.Error == "" {
+		t.Errorf("unexpected update entry: %+v", update)
+	}
+	if update.Params["path"] != "/missing.txt" || update.Params["changed"] != "false" {
+		t.Errorf("update params = %v", update.Params)
+	}
+	if del.Operation != AuditDelete || del.Actor != "alice" || del.Outcome != AuditOutcomeOK || del.DocumentID != docID {
+		t.Errorf("unexpected delete entry: %+v", del)
+	}
+
+	byActor, err := e.AuditLog(ctx, AuditFilter{Actor: "alice"})
+	if err != nil {
+		t.Fatalf("AuditLog by actor: %v", err)
+	}
+	if len(byActor) != 1 || byActor[0].Operation != AuditDelete {
+		t.Errorf("actor filter returned %+v", byActor)
+	}
+}
@@ -0,0 +1,121 @@
+package goreason
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/bbiangul/go-reason/store"
+)
+
+// ChangeReport describes how re-ingesting a changed document changed the
+// knowledge base, so operators can review answers that may now be stale.
+type ChangeReport struct {
+	// Sections are named by heading. A section is modified when it kept
+	// its heading but not its content.
+	SectionsAdded    []string `json:"sections_added,omitempty"`
+	SectionsRemoved  []string `json:"sections_removed,omitempty"`
+	SectionsModified []string `json:"sections_modified,omitempty"`
+
+	// Entities the document's chunks link to now and did not before, and
+	// the other way round. A removed entity may still be linked from
+	// other documents.
+	EntitiesAdded   []string `json:"entities_added,omitempty"`
+	EntitiesRemoved []string `json:"entities_removed,omitempty"`
+
+	// StaleAnswers are logged answers citing chunks the update removed.
+	StaleAnswers []store.StaleQuery `json:"stale_answers,omitempty"`
+}
+
+// untitledSection names the chunks before a document's first heading.
+const untitledSection = "(untitled)"
+
+// documentSnapshot is the state of a document a ChangeReport compares.
+type documentSnapshot struct {
+	chunks   map[int64]bool
+	sections map[string]string // heading -> content hashes of its chunks
+	order    []string          // headings in document order
+	entities []string
+}
+
+// snapshotDocument records a document's chunks, sections, and entities.
+func (e *engine) snapshotDocument(ctx context.Context, docID int64) (*documentSnapshot, error) {
+	chunks, err := e.store.ChunkOutline(ctx, docID)
+	if err != nil {
+		return nil, err
+	}
+	snap := &documentSnapshot{chunks: make(map[int64]bool, len(chunks)), sections: make(map[string]string)}
+	for _, c := range chunks {
+		snap.chunks[c.ID] = true
+		heading := strings.TrimSpace(c.Heading)
+		if heading == "" {
+			heading = untitledSection
+		}
+		if _, ok := snap.sections[heading]; !ok {
+			snap.order = append(snap.order, heading)
+		}
+		snap.sections[heading] += c.ContentHash + ","
+	}
+	snap.entities, err = e.store.DocumentEntityNames(ctx, docID)
+	if err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// diffDocument compares a re-ingested document with its snapshot from
+// before, and finds the logged answers citing the chunks it removed.
+func (e *engine) diffDocument(ctx context.Context, docID int64, before *documentSnapshot) (*ChangeReport, error) {
+	after, err := e.snapshotDocument(ctx, docID)
+	if err != nil {
+		return nil, err
+	}
+	report := &ChangeReport{}
+	for _, heading := range after.order {
+		content, ok := before.sections[heading]
+		switch {
+		case !ok:
+			report.SectionsAdded = append(report.SectionsAdded, heading)
+		case content != after.sections[heading]:
+			report.SectionsModified = append(report.SectionsModified, heading)
+		}
+	}
+	for _, heading := range before.order {
+		if _, ok := after.sections[heading]; !ok {
+			report.SectionsRemoved = append(report.SectionsRemoved, heading)
+		}
+	}
+	report.EntitiesAdded = missingNames(after.entities, before.entities)
+	report.EntitiesRemoved = missingNames(before.entities, after.entities)
+
+	stale, err := e.store.StaleQueries(ctx, docID)
+	if err != nil {
+		return report, err
+	}
+	// Answers already stale before the update were reported then.
+	for _, q := range stale {
+		for _, id := range q.MissingChunks {
+			if before.chunks[id] {
+				report.StaleAnswers = append(report.StaleAnswers, q)
+				break
+			}
+		}
+	}
+	return report, nil
+}
+
+// missingNames returns the names in a that are not in b, sorted.
+func missingNames(a, b []string) []string {
+	in := make(map[string]bool, len(b))
+	for _, name := range b {
+		in[name] = true
+	}
+	var out []string
+	for _, name := range a {
+		if !in[name] {
+			out = append(out, name)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"math"
+	"regexp"
 	"strings"
 
 	"github.com/bbiangul/go-reason/parser"
@@ -13,13 +14,29 @@ import (
 
 // Config controls the chunking behaviour.
 type Config struct {
-	MaxTokens int // Maximum estimated tokens per chunk.
-	Overlap   int // Token overlap between consecutive child chunks.
+	MaxTokens int        // Maximum estimated tokens per chunk.
+	Overlap   int        // Token overlap between consecutive child chunks.
+	Types     []TypeRule // Custom chunk types, checked in order.
+
+	// Tokenizers count tokens by language code (see DetectLanguage); the
+	// "" entry counts text of other languages. A language without one is
+	// estimated with a per-language words-to-tokens heuristic.
+	Tokenizers map[string]Tokenizer
+}
+
+// TypeRule registers a custom chunk type. A section whose parser type equals
+// Name keeps that type instead of being mapped to "section"/"paragraph".
+// When Match is set, generic chunks whose heading or content match it are
+// assigned Name; the first matching rule wins.
+type TypeRule struct {
+	Name  string
+	Match *regexp.Regexp
 }
 
 // Chunker converts parsed document sections into store-ready chunks.
 type Chunker struct {
-	cfg Config
+	cfg        Config
+	registered map[string]bool
 }
 
 // New returns a Chunker with the given configuration.
@@ -31,7 +48,11 @@ func New(cfg Config) *Chunker {
 	if cfg.Overlap == 0 {
 		cfg.Overlap = 128
 	}
-	return &Chunker{cfg: cfg}
+	registered := make(map[string]bool, len(cfg.Types))
+	for _, t := range cfg.Types {
+		registered[t.Name] = true
+	}
+	return &Chunker{cfg: cfg, registered: registered}
 }
 
 // Chunk converts parsed sections into store chunks with hierarchical
@@ -41,9 +62,10 @@ func New(cfg Config) *Chunker {
 // insert.
 func (c *Chunker) Chunk(sections []parser.Section) []store.Chunk {
 	var chunks []store.Chunk
+	var o outline
 	pos := 0
 	for _, sec := range sections {
-		c.processSection(sec, nil, &chunks, &pos, -1, nil)
+		c.processSection(sec, nil, &chunks, &pos, -1, nil, &o)
 	}
 	return chunks
 }
@@ -55,9 +77,10 @@ func (c *Chunker) Chunk(sections []parser.Section) []store.Chunk {
 func (c *Chunker) ChunkWithSectionMap(sections []parser.Section) ([]store.Chunk, []int) {
 	var chunks []store.Chunk
 	var sectionMap []int
+	var o outline
 	pos := 0
 	for i, sec := range sections {
-		c.processSection(sec, nil, &chunks, &pos, i, &sectionMap)
+		c.processSection(sec, nil, &chunks, &pos, i, &sectionMap, &o)
 	}
 	return chunks, sectionMap
 }
@@ -65,25 +88,30 @@ func (c *Chunker) ChunkWithSectionMap(sections []parser.Section) ([]store.Chunk,
 // processSection recursively converts a parser.Section (and its children)
 // into one parent chunk plus zero or more child chunks.
 // When sectionIdx >= 0 and sectionMap is non-nil, each chunk's originating
-// top-level section index is recorded.
-func (c *Chunker) processSection(sec parser.Section, parentPos *int64, chunks *[]store.Chunk, pos *int, sectionIdx int, sectionMap *[]int) {
+// top-level section index is recorded. The section's number and chapter,
+// tracked by o, are added to the chunks' metadata.
+func (c *Chunker) processSection(sec parser.Section, parentPos *int64, chunks *[]store.Chunk, pos *int, sectionIdx int, sectionMap *[]int, o *outline) {
 	// --- parent chunk ---
 	parentContent := buildParentContent(sec)
-	parentMeta := marshalMeta(sec.Metadata)
-	parentHash := contentHash(parentContent)
+	number, chapter := o.enter(sec.Heading)
+	parentMeta := marshalMeta(outlineMeta(sec.Metadata, number, chapter))
+	parentHash := ContentHash(parentContent)
 	parentIndex := int64(*pos)
+	tok := c.tokenizerFor(sec.Heading + "\n" + sec.Content)
 
 	parent := store.Chunk{
 		ID:            parentIndex, // temporary, replaced on DB insert
 		ParentChunkID: parentPos,
 		Content:       parentContent,
-		ChunkType:     chunkTypeFromSection(sec),
+		ChunkType:     c.chunkType(sec, chunkTypeFromSection(sec), parentContent),
 		Heading:       sec.Heading,
 		PageNumber:    sec.PageNumber,
 		PositionInDoc: *pos,
-		TokenCount:    estimateTokens(parentContent),
+		TokenCount:    tok.CountTokens(parentContent),
 		Metadata:      parentMeta,
 		ContentHash:   parentHash,
+		StartOffset:   sec.StartOffset,
+		EndOffset:     sec.EndOffset,
 	}
 	*chunks = append(*chunks, parent)
 	if sectionMap != nil {
@@ -94,19 +122,24 @@ func (c *Chunker) processSection(sec parser.Section, parentPos *int64, chunks *[
 	// --- child chunks from content ---
 	if sec.Content != "" {
 		fragments := c.splitContent(sec.Content)
+		from := 0
 		for _, frag := range fragments {
-			childHash := contentHash(frag)
+			childHash := ContentHash(frag)
+			var start, end int
+			start, end, from = fragmentSpan(sec, frag, from)
 			child := store.Chunk{
 				ID:            int64(*pos),
 				ParentChunkID: &parentIndex,
 				Content:       frag,
-				ChunkType:     childChunkType(sec),
+				ChunkType:     c.chunkType(sec, childChunkType(sec), frag),
 				Heading:       sec.Heading,
 				PageNumber:    sec.PageNumber,
 				PositionInDoc: *pos,
-				TokenCount:    estimateTokens(frag),
+				TokenCount:    tok.CountTokens(frag),
 				Metadata:      parentMeta,
 				ContentHash:   childHash,
+				StartOffset:   start,
+				EndOffset:     end,
 			}
 			*chunks = append(*chunks, child)
 			if sectionMap != nil {
@@ -118,16 +151,18 @@ func (c *Chunker) processSection(sec parser.Section, parentPos *int64, chunks *[
 
 	// --- recurse into child sections ---
 	for _, child := range sec.Children {
-		c.processSection(child, &parentIndex, chunks, pos, sectionIdx, sectionMap)
+		c.processSection(child, &parentIndex, chunks, pos, sectionIdx, sectionMap, o)
 	}
 }
 
 // splitContent breaks a long text into fragments that each fit within
 // MaxTokens, splitting at paragraph and then sentence boundaries.
 // Consecutive fragments share an overlap of c.cfg.Overlap tokens worth
-// of trailing text from the previous fragment.
+// of trailing text from the previous fragment. Tokens are counted with
+// the tokenizer for the text's language.
 func (c *Chunker) splitContent(text string) []string {
-	if estimateTokens(text) <= c.cfg.MaxTokens {
+	tok := c.tokenizerFor(text)
+	if tok.CountTokens(text) <= c.cfg.MaxTokens {
 		return []string{strings.TrimSpace(text)}
 	}
 
@@ -138,21 +173,21 @@ func (c *Chunker) splitContent(text string) []string {
 	overlapText := ""
 
 	for _, para := range paragraphs {
-		paraTokens := estimateTokens(para)
+		paraTokens := tok.CountTokens(para)
 
 		// If a single paragraph exceeds MaxTokens, split it by sentences.
 		if paraTokens > c.cfg.MaxTokens {
 			// Flush current buffer first.
 			if current.Len() > 0 {
 				fragments = append(fragments, strings.TrimSpace(current.String()))
-				overlapText = extractOverlap(current.String(), c.cfg.Overlap)
+				overlapText = extractOverlap(current.String(), c.cfg.Overlap, tok)
 				current.Reset()
 				currentTokens = 0
 			}
-			sentenceFragments := c.splitBySentences(para, overlapText)
+			sentenceFragments := c.splitBySentences(para, overlapText, tok)
 			fragments = append(fragments, sentenceFragments...)
 			if len(sentenceFragments) > 0 {
-				overlapText = extractOverlap(sentenceFragments[len(sentenceFragments)-1], c.cfg.Overlap)
+				overlapText = extractOverlap(sentenceFragments[len(sentenceFragments)-1], c.cfg.Overlap, tok)
 			}
 			continue
 		}
@@ -160,7 +195,7 @@ func (c *Chunker) splitContent(text string) []string {
 		// Would adding this paragraph exceed the limit?
 		if currentTokens+paraTokens > c.cfg.MaxTokens && current.Len() > 0 {
 			fragments = append(fragments, strings.TrimSpace(current.String()))
-			overlapText = extractOverlap(current.String(), c.cfg.Overlap)
+			overlapText = extractOverlap(current.String(), c.cfg.Overlap, tok)
 			current.Reset()
 			currentTokens = 0
 
@@ -168,7 +203,7 @@ func (c *Chunker) splitContent(text string) []string {
 			if overlapText != "" {
 				current.WriteString(overlapText)
 				current.WriteString("\n\n")
-				currentTokens = estimateTokens(overlapText)
+				currentTokens = tok.CountTokens(overlapText)
 			}
 		}
 
@@ -189,7 +224,7 @@ func (c *Chunker) splitContent(text string) []string {
 // splitBySentences breaks a paragraph into fragments at sentence
 // boundaries, respecting MaxTokens and prepending overlap from the
 // previous fragment.
-func (c *Chunker) splitBySentences(text string, initialOverlap string) []string {
+func (c *Chunker) splitBySentences(text string, initialOverlap string, tok Tokenizer) []string {
 	sentences := splitSentences(text)
 	var fragments []string
 	var current strings.Builder
@@ -198,21 +233,21 @@ func (c *Chunker) splitBySentences(text string, initialOverlap string) []string
 	if initialOverlap != "" {
 		current.WriteString(initialOverlap)
 		current.WriteString(" ")
-		currentTokens = estimateTokens(initialOverlap)
+		currentTokens = tok.CountTokens(initialOverlap)
 	}
 
 	for _, sent := range sentences {
-		sentTokens := estimateTokens(sent)
+		sentTokens := tok.CountTokens(sent)
 
 		if currentTokens+sentTokens > c.cfg.MaxTokens && current.Len() > 0 {
 			fragments = append(fragments, strings.TrimSpace(current.String()))
-			overlap := extractOverlap(current.String(), c.cfg.Overlap)
+			overlap := extractOverlap(current.String(), c.cfg.Overlap, tok)
 			current.Reset()
 			currentTokens = 0
 			if overlap != "" {
 				current.WriteString(overlap)
 				current.WriteString(" ")
-				currentTokens = estimateTokens(overlap)
+				currentTokens = tok.CountTokens(overlap)
 			}
 		}
 
@@ -241,6 +276,13 @@ func estimateTokens(text string) int {
 	return int(math.Ceil(float64(words) * 1.3))
 }
 
+// EstimateTokens approximates the token count of text with the same
+// heuristic, for counts that need no language-aware tokenizer, such as
+// the usage of a reply the provider did not report.
+func EstimateTokens(text string) int {
+	return estimateTokens(text)
+}
+
 // buildParentContent produces the parent chunk body: the heading
 // followed by an abbreviated version of the section content (first
 // 200 characters).
@@ -280,6 +322,25 @@ func chunkTypeFromSection(sec parser.Section) string {
 	}
 }
 
+// chunkType resolves the final chunk type from the built-in mapping and the
+// configured custom types. Only generic chunks ("section", "paragraph") are
+// reclassified by Match rules; parser-detected tables, definitions and
+// requirements keep their type.
+func (c *Chunker) chunkType(sec parser.Section, builtin, content string) string {
+	if c.registered[sec.Type] {
+		return sec.Type
+	}
+	if builtin != "section" && builtin != "paragraph" {
+		return builtin
+	}
+	for _, t := range c.cfg.Types {
+		if t.Match != nil && (t.Match.MatchString(sec.Heading) || t.Match.MatchString(content)) {
+			return t.Name
+		}
+	}
+	return builtin
+}
+
 // childChunkType returns the chunk type to assign to child fragments
 // of a section.
 func childChunkType(sec parser.Section) string {
@@ -339,9 +400,9 @@ func splitSentences(text string) []string {
 	return sentences
 }
 
-// extractOverlap returns the trailing portion of text whose estimated
-// token count is at most maxTokens.  It works at the word level.
-func extractOverlap(text string, maxTokens int) string {
+// extractOverlap returns the trailing portion of text whose token count,
+// by tok, is at most maxTokens.  It works at the word level.
+func extractOverlap(text string, maxTokens int, tok Tokenizer) string {
 	words := strings.Fields(text)
 	if len(words) == 0 {
 		return ""
@@ -351,18 +412,91 @@ func extractOverlap(text string, maxTokens int) string {
 	if maxWords > len(words) {
 		maxWords = len(words)
 	}
+	// Languages that take more tokens per word keep fewer words.
+	for maxWords > 0 {
+		n := tok.CountTokens(strings.Join(words[len(words)-maxWords:], " "))
+		if n <= maxTokens {
+			break
+		}
+		if shrunk := maxWords * maxTokens / n; shrunk < maxWords {
+			maxWords = shrunk
+		} else {
+			maxWords--
+		}
+	}
 	if maxWords == 0 {
 		return ""
 	}
 	return strings.Join(words[len(words)-maxWords:], " ")
 }
 
-// contentHash returns the SHA-256 hex digest of text.
-func contentHash(text string) string {
+// fragmentSpan maps a fragment of sec.Content back to a byte span in the
+// parser's extracted text. The fragment is located in Content as its word
+// sequence (whitespace may differ, since splitting re-joins paragraphs and
+// sentences), searching from `from` so repeated text resolves in order. The
+// position is exact when the section span covers Content verbatim and scaled
+// proportionally otherwise. It returns the span and the search position for
+// the next fragment; a section without a span yields an unknown (zero) span
+// and an unlocatable fragment falls back to the whole section.
+func fragmentSpan(sec parser.Section, frag string, from int) (start, end, next int) {
+	if sec.EndOffset <= sec.StartOffset || sec.Content == "" {
+		return 0, 0, from
+	}
+	words := strings.Fields(frag)
+	if len(words) == 0 {
+		return sec.StartOffset, sec.EndOffset, from
+	}
+	loc := wordsPattern(words).FindStringIndex(sec.Content[from:])
+	if loc == nil {
+		return sec.StartOffset, sec.EndOffset, from
+	}
+	lo, hi := from+loc[0], from+loc[1]
+
+	span := sec.EndOffset - sec.StartOffset
+	scale := func(pos int) int {
+		if span == len(sec.Content) {
+			return sec.StartOffset + pos
+		}
+		return sec.StartOffset + pos*span/len(sec.Content)
+	}
+	return scale(lo), scale(hi), lo + 1
+}
+
+// wordsPattern matches words in sequence separated by any whitespace.
+func wordsPattern(words []string) *regexp.Regexp {
+	quoted := make([]string, len(words))
+	for i, w := range words {
+		quoted[i] = regexp.QuoteMeta(w)
+	}
+	return regexp.MustCompile(strings.Join(quoted, `\s+`))
+}
+
+// ContentHash returns the SHA-256 hex digest of text, the content hash
+// recorded for chunks.
+func ContentHash(text string) string {
 	h := sha256.Sum256([]byte(text))
 	return hex.EncodeToString(h[:])
 }
 
+// outlineMeta returns the section metadata with its outline position
+// added. Keys the parser already set are kept.
+func outlineMeta(meta map[string]string, number, chapter string) map[string]string {
+	if number == "" && chapter == "" {
+		return meta
+	}
+	out := make(map[string]string, len(meta)+2)
+	if number != "" {
+		out[MetaSectionNumber] = number
+	}
+	if chapter != "" {
+		out[MetaChapter] = chapter
+	}
+	for k, v := range meta {
+		out[k] = v
+	}
+	return out
+}
+
 // marshalMeta serialises a metadata map to a JSON string.
 // Returns "{}" for nil or empty maps.
 func marshalMeta(m map[string]string) string {
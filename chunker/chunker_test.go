@@ -1,6 +1,9 @@
 package chunker
 
 import (
+	"encoding/json"
+	"fmt"
+	"regexp"
 	"strings"
 	"testing"
 
@@ -259,14 +262,43 @@ func TestChunkTypesFromSection(t *testing.T) {
 	}
 }
 
+func TestChunkCustomTypes(t *testing.T) {
+	c := New(Config{Types: []TypeRule{
+		{Name: "annex"},
+		{Name: "warning", Match: regexp.MustCompile(`(?i)\bwarning\b`)},
+	}})
+	chunks := c.Chunk([]parser.Section{
+		{Heading: "Annex A", Type: "annex", Content: "Extra material."},
+		{Heading: "Warning", Type: "section", Content: "Disconnect power first."},
+		{Heading: "Limits", Type: "requirement", Content: "Warning: the unit shall not exceed 40 C."},
+		{Heading: "Intro", Type: "section", Content: "General description."},
+	})
+
+	got := make(map[string][]string)
+	for _, ch := range chunks {
+		got[ch.Heading] = append(got[ch.Heading], ch.ChunkType)
+	}
+	want := map[string][]string{
+		"Annex A": {"annex", "annex"},
+		"Warning": {"warning", "warning"},
+		"Limits":  {"requirement", "requirement"}, // parser-detected types are not reclassified
+		"Intro":   {"section", "paragraph"},
+	}
+	for heading, types := range want {
+		if strings.Join(got[heading], ",") != strings.Join(types, ",") {
+			t.Errorf("%s: chunk types = %v, want %v", heading, got[heading], types)
+		}
+	}
+}
+
 // ---------------------------------------------------------------------------
 // Content hash tests
 // ---------------------------------------------------------------------------
 
 func TestContentHash(t *testing.T) {
-	hash1 := contentHash("hello world")
-	hash2 := contentHash("hello world")
-	hash3 := contentHash("different content")
+	hash1 := ContentHash("hello world")
+	hash2 := ContentHash("hello world")
+	hash3 := ContentHash("different content")
 
 	if hash1 != hash2 {
 		t.Error("identical content should produce identical hashes")
@@ -379,6 +411,92 @@ func TestSplitContentLong(t *testing.T) {
 	}
 }
 
+// ---------------------------------------------------------------------------
+// Language-aware token counting tests
+// ---------------------------------------------------------------------------
+
+// altavisionES is a paragraph of the Spanish ALTAVision AV-FM manual.
+const altavisionES = "Condiciones ambientales: Temperatura: 5° a 40° Celsius, humedad relativa de 10% a 90% sin condensación. " +
+	"Se proporcionan fusibles de 6.3Amp/250V para la protección de la Tarjeta de Control / Tracker. " +
+	"Requisitos de aire comprimido: 75 – 85 PSIG, limpio y seco, con un consumo máximo de 2 SCFM. " +
+	"La estructura del equipo está fabricada en acero inoxidable 304 y las guardas se abren para el mantenimiento de la cámara."
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{altavisionES, "es"},
+		{"The relief valve opens at 10 bar and closes when the pressure in the line falls below 8 bar.", "en"},
+		{"Die Maschine ist mit einem Drucksensor und einer Steuerung für den Betrieb ausgestattet.", "de"},
+		{"AV-FM 6.3A 250V 75-85 PSIG", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := DetectLanguage(tt.text); got != tt.want {
+			t.Errorf("DetectLanguage(%.40q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSplitContentSpanish(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; i < 6; i++ {
+		sb.WriteString(altavisionES)
+		sb.WriteString("\n\n")
+	}
+	text := sb.String()
+
+	// Sized as Spanish, the section splits into more, smaller fragments
+	// than the English estimate would make.
+	c := New(Config{MaxTokens: 120, Overlap: 16})
+	fragments := c.splitContent(text)
+	english := 0
+	for _, para := range splitParagraphs(text) {
+		english += estimateTokens(para)
+	}
+	if len(fragments) <= english/120 {
+		t.Errorf("fragments = %d, want more than %d", len(fragments), english/120)
+	}
+	es := heuristic("es")
+	for i, f := range fragments {
+		if n := es.CountTokens(f); n > 120 {
+			t.Errorf("fragment[%d] has %d tokens, want <= 120", i, n)
+		}
+	}
+}
+
+func TestChunkTokenizers(t *testing.T) {
+	// A tokenizer counting characters, standing in for the embedding
+	// model's tokenizer for Spanish.
+	chars := TokenizerFunc(func(text string) int { return len([]rune(text)) / 3 })
+	c := New(Config{MaxTokens: 100, Overlap: 10, Tokenizers: map[string]Tokenizer{"es": chars}})
+
+	chunks := c.Chunk([]parser.Section{{
+		Heading: "Especificaciones técnicas",
+		Content: altavisionES + "\n\n" + altavisionES,
+		Type:    "section",
+	}})
+	if len(chunks) < 3 {
+		t.Fatalf("chunks = %d, want a parent and several children", len(chunks))
+	}
+	for i, ch := range chunks[1:] {
+		if ch.TokenCount != chars(ch.Content) {
+			t.Errorf("chunk[%d].TokenCount = %d, want %d", i+1, ch.TokenCount, chars(ch.Content))
+		}
+		if ch.TokenCount > 100 {
+			t.Errorf("chunk[%d].TokenCount = %d, want <= 100", i+1, ch.TokenCount)
+		}
+	}
+
+	// English text keeps the word-based estimate.
+	en := New(Config{Tokenizers: map[string]Tokenizer{"es": chars}})
+	text := "The relief valve opens at 10 bar and closes when the pressure in the line falls below 8 bar."
+	if got := en.Chunk([]parser.Section{{Heading: "Relief valve", Content: text, Type: "section"}}); got[1].TokenCount != estimateTokens(text) {
+		t.Errorf("English TokenCount = %d, want %d", got[1].TokenCount, estimateTokens(text))
+	}
+}
+
 // ---------------------------------------------------------------------------
 // marshalMeta tests
 // ---------------------------------------------------------------------------
@@ -427,6 +545,44 @@ func TestChunkPositionInDoc(t *testing.T) {
 	}
 }
 
+func TestChunkSourceSpans(t *testing.T) {
+	c := New(Config{MaxTokens: 20, Overlap: 4})
+
+	var sb strings.Builder
+	for i := 0; i < 12; i++ {
+		fmt.Fprintf(&sb, "Paragraph %d talks about item %d in some detail.\n\n", i, i)
+	}
+	content := sb.String()
+	sections := []parser.Section{
+		{Heading: "Doc", Content: content, Type: "section", StartOffset: 0, EndOffset: len(content)},
+	}
+
+	chunks := c.Chunk(sections)
+	if chunks[0].StartOffset != 0 || chunks[0].EndOffset != len(content) {
+		t.Errorf("parent span = [%d,%d), want [0,%d)", chunks[0].StartOffset, chunks[0].EndOffset, len(content))
+	}
+	if len(chunks) < 3 {
+		t.Fatalf("expected multiple child chunks, got %d", len(chunks))
+	}
+	for _, ch := range chunks[1:] {
+		if ch.EndOffset <= ch.StartOffset {
+			t.Fatalf("chunk %d has no span", ch.PositionInDoc)
+		}
+		src := content[ch.StartOffset:ch.EndOffset]
+		if strings.Join(strings.Fields(src), " ") != strings.Join(strings.Fields(ch.Content), " ") {
+			t.Errorf("chunk %d span %q does not match content %q", ch.PositionInDoc, src, ch.Content)
+		}
+	}
+
+	// Sections without a span produce chunks without one.
+	chunks = c.Chunk([]parser.Section{{Heading: "X", Content: "Some text.", Type: "section"}})
+	for _, ch := range chunks {
+		if ch.EndOffset != 0 {
+			t.Errorf("expected unknown span, got [%d,%d)", ch.StartOffset, ch.EndOffset)
+		}
+	}
+}
+
 // ---------------------------------------------------------------------------
 // Empty input tests
 // ---------------------------------------------------------------------------
@@ -830,6 +986,63 @@ func TestNumberingLevel(t *testing.T) {
 	}
 }
 
+func TestSectionNumber(t *testing.T) {
+	tests := map[string]string{
+		"7.3.2 Relief valve":   "7.3.2",
+		"7.3.2. Relief valve":  "7.3.2",
+		"## 4.1 Scope":         "4.1",
+		"Section 4.1: Scope":   "4.1",
+		"Art. 12 Penalties":    "12",
+		"§ 3":                  "3",
+		"2) Installation":      "2",
+		"2024 annual overview": "",
+		"Introduction":         "",
+		"":                     "",
+	}
+	for heading, want := range tests {
+		if got := SectionNumber(heading); got != want {
+			t.Errorf("SectionNumber(%q) = %q, want %q", heading, got, want)
+		}
+	}
+}
+
+func TestChunkOutlineMetadata(t *testing.T) {
+	c := New(Config{})
+	sections := []parser.Section{
+		{Heading: "Chapter VII: Maintenance", Content: "Overview.", Level: 1},
+		{Heading: "7.3.2 Relief valve", Content: "Opens at 10 bar.", Level: 2, PageNumber: 135, Metadata: map[string]string{"clause": "x"}},
+		{Heading: "Notes", Content: "Keep records.", Level: 2},
+		{Heading: "8.1 Storage", Content: "Keep dry.", Level: 2},
+		{Heading: "9 Disposal", Content: "Recycle.", Level: 1},
+	}
+	chunks := c.Chunk(sections)
+
+	meta := make(map[string]map[string]string)
+	for _, ch := range chunks {
+		var m map[string]string
+		if err := json.Unmarshal([]byte(ch.Metadata), &m); err != nil {
+			t.Fatalf("chunk %q metadata: %v", ch.Heading, err)
+		}
+		meta[ch.Heading] = m
+	}
+	want := map[string][2]string{
+		"Chapter VII: Maintenance": {"", "Chapter VII: Maintenance"},
+		"7.3.2 Relief valve":       {"7.3.2", "Chapter VII: Maintenance"},
+		"Notes":                    {"", "Chapter VII: Maintenance"},
+		"8.1 Storage":              {"8.1", ""}, // past chapter 7
+		"9 Disposal":               {"9", "9 Disposal"},
+	}
+	for heading, w := range want {
+		m := meta[heading]
+		if m[MetaSectionNumber] != w[0] || m[MetaChapter] != w[1] {
+			t.Errorf("%q: section %q, chapter %q; want %q, %q", heading, m[MetaSectionNumber], m[MetaChapter], w[0], w[1])
+		}
+	}
+	if meta["7.3.2 Relief valve"]["clause"] != "x" {
+		t.Error("parser metadata lost")
+	}
+}
+
 // ---------------------------------------------------------------------------
 // Cross-reference detection tests
 // ---------------------------------------------------------------------------
@@ -2,6 +2,7 @@ package chunker
 
 import (
 	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -67,6 +68,86 @@ func NumberingLevel(numbering string) int {
 	return strings.Count(numbering, ".") + 1
 }
 
+// ---------------------------------------------------------------------------
+// Document outline
+// ---------------------------------------------------------------------------
+
+// Chunk metadata keys locating a chunk in a long document's outline.
+const (
+	MetaSectionNumber = "section_number" // e.g. "7.3.2"
+	MetaChapter       = "chapter"        // heading of the enclosing chapter
+)
+
+// sectionNumberPattern matches a heading's section number, optionally after
+// a keyword: "7.3.2 Relief valve", "Section 4.1", "Art. 12", "§ 3".
+var sectionNumberPattern = regexp.MustCompile(
+	`(?i)^(?:(?:section|sección|seccion|sec\.|article|artículo|articulo|art\.|clause|cláusula|clausula|§)\s*)?(\d{1,3}(?:\.\d{1,3})*)(?:[.):]|\s|$)`,
+)
+
+// chapterPattern matches chapter headings such as "Chapter 7", "Capítulo
+// III" or "Part 2".
+var chapterPattern = regexp.MustCompile(`(?i)^(?:chapter|capítulo|capitulo|part|parte)\s+(\d+|[ivxlc]+)\b`)
+
+// SectionNumber extracts the section number from a heading, e.g. "7.3.2"
+// from "7.3.2 Relief valve" or "4.1" from "Section 4.1: Scope". It returns
+// "" when the heading is not numbered.
+func SectionNumber(heading string) string {
+	heading = strings.TrimLeft(strings.TrimSpace(heading), "# ")
+	m := sectionNumberPattern.FindStringSubmatch(heading)
+	if len(m) < 2 {
+		return ""
+	}
+	return m[1]
+}
+
+// outline follows the chapters of a document as its sections are chunked
+// in document order.
+type outline struct {
+	chapter    string // heading of the current chapter
+	chapterNum string // its number in arabic digits, if known
+}
+
+// enter moves the outline to a section with the given heading and returns
+// the section's number and chapter.
+func (o *outline) enter(heading string) (number, chapter string) {
+	heading = strings.TrimLeft(strings.TrimSpace(heading), "# ")
+	if m := chapterPattern.FindStringSubmatch(heading); m != nil {
+		o.chapter, o.chapterNum = heading, arabicNumber(m[1])
+		return "", o.chapter
+	}
+	number = SectionNumber(heading)
+	switch {
+	case number == "":
+	case !strings.Contains(number, ".") && strings.HasPrefix(heading, number):
+		// A bare top-level number ("7 Maintenance") opens a chapter.
+		o.chapter, o.chapterNum = heading, number
+	case o.chapterNum != "" && strings.SplitN(number, ".", 2)[0] != o.chapterNum:
+		// Numbering moved past the chapter: it no longer encloses us.
+		o.chapter, o.chapterNum = "", ""
+	}
+	return number, o.chapter
+}
+
+// arabicNumber converts a chapter number to arabic digits ("VII" -> "7").
+// Numbers it cannot convert are returned as-is.
+func arabicNumber(s string) string {
+	values := map[byte]int{'i': 1, 'v': 5, 'x': 10, 'l': 50, 'c': 100}
+	lower := strings.ToLower(s)
+	total := 0
+	for i := 0; i < len(lower); i++ {
+		v, ok := values[lower[i]]
+		if !ok {
+			return s
+		}
+		if i+1 < len(lower) && values[lower[i+1]] > v {
+			total -= v
+		} else {
+			total += v
+		}
+	}
+	return strconv.Itoa(total)
+}
+
 // ---------------------------------------------------------------------------
 // Content type classification
 // ---------------------------------------------------------------------------
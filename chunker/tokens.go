@@ -0,0 +1,139 @@
+package chunker
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Tokenizer counts the tokens of text as a model does, such as the
+// embedding model's tokenizer (llm.LoadTokenizer), for sizing chunks.
+type Tokenizer interface {
+	CountTokens(text string) int
+}
+
+// TokenizerFunc adapts a function to Tokenizer.
+type TokenizerFunc func(text string) int
+
+// CountTokens calls f(text).
+func (f TokenizerFunc) CountTokens(text string) int { return f(text) }
+
+// tokensPerWord is the heuristic's tokens per word by language. Subword
+// tokenizers trained mostly on English split Spanish, Portuguese, and
+// especially German words, and accented letters, into more pieces, so
+// "words * 1.3" undercounts them and yields chunks past the embedding
+// model's window.
+var tokensPerWord = map[string]float64{
+	"en": 1.3,
+	"es": 1.9,
+	"pt": 1.9,
+	"fr": 1.8,
+	"it": 1.9,
+	"de": 2.0,
+}
+
+// accentedWordTokens is the heuristic's extra tokens for a word with
+// non-ASCII letters when the language is unknown.
+const accentedWordTokens = 0.6
+
+// heuristic returns the word-based estimate for lang. An unknown
+// language is counted as English plus accentedWordTokens per accented
+// word.
+func heuristic(lang string) Tokenizer {
+	if f, ok := tokensPerWord[lang]; ok {
+		return TokenizerFunc(func(text string) int {
+			return int(math.Ceil(float64(len(strings.Fields(text))) * f))
+		})
+	}
+	return TokenizerFunc(func(text string) int {
+		words, accented := 0, 0
+		for _, w := range strings.Fields(text) {
+			words++
+			if strings.IndexFunc(w, func(r rune) bool { return r > unicode.MaxASCII && unicode.IsLetter(r) }) >= 0 {
+				accented++
+			}
+		}
+		return int(math.Ceil(float64(words)*tokensPerWord["en"] + float64(accented)*accentedWordTokens))
+	})
+}
+
+// CountTokens counts the tokens of text with the tokenizer the chunker
+// sizes it with.
+func (c *Chunker) CountTokens(text string) int {
+	return c.tokenizerFor(text).CountTokens(text)
+}
+
+// tokenizerFor returns the tokenizer for text's language: the one
+// configured for it, else the configured fallback (""), else the
+// language's heuristic.
+func (c *Chunker) tokenizerFor(text string) Tokenizer {
+	lang := DetectLanguage(text)
+	if t, ok := c.cfg.Tokenizers[lang]; ok && lang != "" {
+		return t
+	}
+	if t, ok := c.cfg.Tokenizers[""]; ok {
+		return t
+	}
+	return heuristic(lang)
+}
+
+// stopWords are frequent function words that identify a language. Words
+// shared by several languages count for each.
+var stopWords = map[string][]string{
+	"en": {"the", "and", "of", "to", "is", "in", "that", "for", "with", "this", "are", "be", "on", "it", "as", "by", "from", "or"},
+	"es": {"el", "la", "los", "las", "de", "del", "que", "y", "en", "un", "una", "por", "para", "con", "es", "se", "al", "como", "su", "o"},
+	"pt": {"o", "os", "as", "do", "da", "dos", "das", "não", "em", "um", "uma", "com", "para", "é", "que", "no", "na", "ou"},
+	"fr": {"le", "les", "des", "du", "et", "est", "un", "une", "dans", "pour", "avec", "que", "sur", "au", "ce", "ou"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "mit", "den", "von", "zu", "ein", "eine", "für", "auf", "dem", "oder"},
+	"it": {"il", "lo", "gli", "della", "delle", "di", "che", "è", "per", "con", "una", "non", "sono", "nel", "e"},
+}
+
+// stopWordLangs maps each stop word to its languages.
+var stopWordLangs = func() map[string][]string {
+	m := make(map[string][]string)
+	for lang, words := range stopWords {
+		for _, w := range words {
+			m[w] = append(m[w], lang)
+		}
+	}
+	return m
+}()
+
+// Limits of language detection: the words read, and the stop words a
+// language needs to be named.
+const (
+	detectWords        = 300
+	detectMinStopWords = 3
+)
+
+// DetectLanguage returns the ISO 639-1 code of text's language, one of
+// en, es, pt, fr, de, and it, by counting their stop words in its first
+// words. It returns "" when the text has too few stop words to tell,
+// such as a parts table.
+func DetectLanguage(text string) string {
+	counts := make(map[string]int)
+	n := 0
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) {
+		for _, lang := range stopWordLangs[w] {
+			counts[lang]++
+		}
+		if n++; n == detectWords {
+			break
+		}
+	}
+	best, second := "", 0
+	for _, lang := range []string{"en", "es", "pt", "fr", "de", "it"} {
+		switch c := counts[lang]; {
+		case best == "" || c > counts[best]:
+			best, second = lang, counts[best]
+		case c > second:
+			second = c
+		}
+	}
+	if counts[best] < detectMinStopWords || counts[best] == second {
+		return ""
+	}
+	return best
+}
@@ -0,0 +1,131 @@
+package goreason
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/bbiangul/go-reason/chunker"
+	"github.com/bbiangul/go-reason/store"
+)
+
+// ChunkFilter is an application hook invoked on every chunk at ingest,
+// after chunking and before the chunk is stored and embedded. It returns
+// the chunks to store in its place: none to drop it (boilerplate headers,
+// footers, legal disclaimers), the chunk itself, possibly with edited
+// content or metadata, or several chunks to split it further.
+//
+// Filters run in order; each sees the output of the previous one. An error
+// fails the ingest. The engine renumbers positions afterwards and
+// re-estimates the token count of chunks whose content changed. Children
+// of a dropped chunk are kept without a parent.
+type ChunkFilter interface {
+	FilterChunk(ctx context.Context, doc ChunkDocument, chunk store.Chunk) ([]store.Chunk, error)
+}
+
+// ChunkFilterFunc adapts a function to ChunkFilter.
+type ChunkFilterFunc func(ctx context.Context, doc ChunkDocument, chunk store.Chunk) ([]store.Chunk, error)
+
+// FilterChunk calls f.
+func (f ChunkFilterFunc) FilterChunk(ctx context.Context, doc ChunkDocument, chunk store.Chunk) ([]store.Chunk, error) {
+	return f(ctx, doc, chunk)
+}
+
+// ChunkDocument describes the document being ingested to a ChunkFilter.
+type ChunkDocument struct {
+	ID         int64
+	Path       string
+	Filename   string
+	Format     string
+	Collection string
+	Metadata   map[string]string // from WithMetadata
+}
+
+// WithChunkFilter adds chunk filters for this ingest. They run after the
+// filters in Config.ChunkFilters.
+func WithChunkFilter(filters ...ChunkFilter) IngestOption {
+	return func(o *ingestOptions) { o.chunkFilters = append(o.chunkFilters, filters...) }
+}
+
+// ChunkMetadata decodes a chunk's metadata JSON object. It returns an empty
+// map when there is none.
+func ChunkMetadata(c store.Chunk) map[string]string {
+	m := make(map[string]string)
+	if c.Metadata != "" {
+		_ = json.Unmarshal([]byte(c.Metadata), &m)
+	}
+	return m
+}
+
+// SetChunkMetadata replaces a chunk's metadata, e.g. after enriching the
+// map returned by ChunkMetadata.
+func SetChunkMetadata(c *store.Chunk, m map[string]string) {
+	if len(m) == 0 {
+		c.Metadata = "{}"
+		return
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return
+	}
+	c.Metadata = string(b)
+}
+
+// applyChunkFilters runs filters over chunks and keeps sectionMap (chunk
+// index -> originating section, may be nil) parallel to the result. The
+// token count of edited chunks is recounted with chunkr.
+//
+// Chunks carry temporary position-based IDs that children reference as
+// ParentChunkID until InsertChunks maps them to database IDs. The first
+// chunk a filter returns keeps the original's temporary ID, so children
+// still find their parent; further split chunks get fresh ones.
+func applyChunkFilters(ctx context.Context, filters []ChunkFilter, doc ChunkDocument, chunks []store.Chunk, sectionMap []int, chunkr *chunker.Chunker) ([]store.Chunk, []int, error) {
+	if len(filters) == 0 {
+		return chunks, sectionMap, nil
+	}
+
+	var nextID int64
+	for _, c := range chunks {
+		if c.ID >= nextID {
+			nextID = c.ID + 1
+		}
+	}
+
+	var out []store.Chunk
+	var outMap []int
+	for i, orig := range chunks {
+		current := []store.Chunk{orig}
+		for _, f := range filters {
+			var next []store.Chunk
+			for _, c := range current {
+				res, err := f.FilterChunk(ctx, doc, c)
+				if err != nil {
+					return nil, nil, fmt.Errorf("chunk filter (chunk %d): %w", orig.PositionInDoc, err)
+				}
+				next = append(next, res...)
+			}
+			current = next
+		}
+
+		for j, c := range current {
+			if j == 0 {
+				c.ID = orig.ID
+			} else {
+				c.ID = nextID
+				nextID++
+			}
+			if c.Content != orig.Content {
+				c.TokenCount = chunkr.CountTokens(c.Content)
+			}
+			out = append(out, c)
+			if sectionMap != nil {
+				outMap = append(outMap, sectionMap[i])
+			}
+		}
+	}
+
+	for i := range out {
+		out[i].PositionInDoc = i
+	}
+	return out, outMap, nil
+}
@@ -0,0 +1,90 @@
+package goreason
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bbiangul/go-reason/chunker"
+	"github.com/bbiangul/go-reason/store"
+)
+
+func TestApplyChunkFilters(t *testing.T) {
+	parent := int64(0)
+	chunks := []store.Chunk{
+		{ID: 0, Content: "Section 1", PositionInDoc: 0},
+		{ID: 1, ParentChunkID: &parent, Content: "Confidential - do not distribute", PositionInDoc: 1},
+		{ID: 2, ParentChunkID: &parent, Content: "first half || second half", PositionInDoc: 2},
+		{ID: 3, ParentChunkID: &parent, Content: "plain text", PositionInDoc: 3},
+	}
+	sectionMap := []int{0, 0, 0, 1}
+
+	dropBoilerplate := ChunkFilterFunc(func(_ context.Context, _ ChunkDocument, c store.Chunk) ([]store.Chunk, error) {
+		if strings.HasPrefix(c.Content, "Confidential") {
+			return nil, nil
+		}
+		return []store.Chunk{c}, nil
+	})
+	split := ChunkFilterFunc(func(_ context.Context, _ ChunkDocument, c store.Chunk) ([]store.Chunk, error) {
+		parts := strings.Split(c.Content, " || ")
+		out := make([]store.Chunk, len(parts))
+		for i, p := range parts {
+			out[i] = c
+			out[i].Content = p
+		}
+		return out, nil
+	})
+	tag := ChunkFilterFunc(func(_ context.Context, doc ChunkDocument, c store.Chunk) ([]store.Chunk, error) {
+		m := ChunkMetadata(c)
+		m["source"] = doc.Filename
+		SetChunkMetadata(&c, m)
+		return []store.Chunk{c}, nil
+	})
+
+	doc := ChunkDocument{Filename: "contract.pdf"}
+	// Tokens counted as bytes, to tell the chunker's count from a default.
+	chunkr := chunker.New(chunker.Config{Tokenizers: map[string]chunker.Tokenizer{
+		"": chunker.TokenizerFunc(func(s string) int { return len(s) }),
+	}})
+	got, gotMap, err := applyChunkFilters(context.Background(), []ChunkFilter{dropBoilerplate, split, tag}, doc, chunks, sectionMap, chunkr)
+	if err != nil {
+		t.Fatalf("applyChunkFilters: %v", err)
+	}
+
+	wantContent := []string{"Section 1", "first half", "second half", "plain text"}
+	if len(got) != len(wantContent) {
+		t.Fatalf("got %d chunks, want %d", len(got), len(wantContent))
+	}
+	for i, c := range got {
+		if c.Content != wantContent[i] {
+			t.Errorf("chunk %d content = %q, want %q", i, c.Content, wantContent[i])
+		}
+		if c.PositionInDoc != i {
+			t.Errorf("chunk %d position = %d", i, c.PositionInDoc)
+		}
+		if ChunkMetadata(c)["source"] != "contract.pdf" {
+			t.Errorf("chunk %d metadata = %q", i, c.Metadata)
+		}
+	}
+	// Temporary IDs stay unique; the first piece of a split keeps the original.
+	if got[1].ID != 2 || got[2].ID != 4 || got[3].ID != 3 {
+		t.Errorf("IDs = %d, %d, %d; want 2, 4, 3", got[1].ID, got[2].ID, got[3].ID)
+	}
+	if got[2].ParentChunkID == nil || *got[2].ParentChunkID != 0 {
+		t.Error("split chunk lost its parent")
+	}
+	if got[1].TokenCount != len("first half") {
+		t.Errorf("token count = %d, want the chunker's count of the edited content", got[1].TokenCount)
+	}
+	if want := []int{0, 0, 0, 1}; len(gotMap) != 4 || gotMap[2] != want[2] || gotMap[3] != want[3] {
+		t.Errorf("section map = %v, want %v", gotMap, want)
+	}
+
+	failing := ChunkFilterFunc(func(context.Context, ChunkDocument, store.Chunk) ([]store.Chunk, error) {
+		return nil, errors.New("boom")
+	})
+	if _, _, err := applyChunkFilters(context.Background(), []ChunkFilter{failing}, doc, chunks, nil, chunkr); err == nil {
+		t.Error("expected filter error to fail")
+	}
+}
@@ -0,0 +1,312 @@
+package goreason
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/bbiangul/go-reason/chunker"
+	"github.com/bbiangul/go-reason/store"
+)
+
+// ChunkDetail is a stored chunk with its document and images, for
+// front-ends rendering cited evidence.
+type ChunkDetail struct {
+	ID               int64             `json:"id"`
+	DocumentID       int64             `json:"document_id"`
+	Filename         string            `json:"filename"`
+	Path             string            `json:"path,omitempty"`
+	ParentChunkID    *int64            `json:"parent_chunk_id,omitempty"`
+	Content          string            `json:"content"`
+	Heading          string            `json:"heading"`
+	ChunkType        string            `json:"chunk_type,omitempty"`
+	PageNumber       int               `json:"page_number"`
+	PositionInDoc    int               `json:"position_in_doc"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+	DocumentMetadata map[string]string `json:"document_metadata,omitempty"`
+	Span             *SourceSpan       `json:"span,omitempty"`
+	// Images lists the chunk's images without their bytes; fetch image n
+	// (its index here) with ChunkImage.
+	Images []SourceImage `json:"images"`
+}
+
+// Chunk returns a stored chunk by ID with its document and image list.
+func (e *engine) Chunk(ctx context.Context, chunkID int64) (*ChunkDetail, error) {
+	c, err := e.store.GetChunk(ctx, chunkID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %d", ErrChunkNotFound, chunkID)
+		}
+		return nil, err
+	}
+	doc, err := e.store.GetDocument(ctx, c.DocumentID)
+	if err != nil {
+		return nil, fmt.Errorf("loading document %d: %w", c.DocumentID, err)
+	}
+
+	out := &ChunkDetail{
+		ID:            c.ID,
+		DocumentID:    c.DocumentID,
+		Filename:      doc.Filename,
+		Path:          doc.Path,
+		ParentChunkID: c.ParentChunkID,
+		Content:       c.Content,
+		Heading:       c.Heading,
+		ChunkType:     c.ChunkType,
+		PageNumber:    c.PageNumber,
+		PositionInDoc: c.PositionInDoc,
+		Images:        []SourceImage{},
+	}
+	if c.Metadata != "" && c.Metadata != "{}" {
+		_ = json.Unmarshal([]byte(c.Metadata), &out.Metadata)
+	}
+	if doc.Metadata != "" {
+		_ = json.Unmarshal([]byte(doc.Metadata), &out.DocumentMetadata)
+	}
+	if c.EndOffset > 0 {
+		out.Span = &SourceSpan{StartOffset: c.StartOffset, EndOffset: c.EndOffset}
+	}
+
+	images, err := e.store.GetImagesByChunkIDs(ctx, []int64{chunkID}, false)
+	if err != nil {
+		return nil, fmt.Errorf("loading chunk images: %w", err)
+	}
+	for _, img := range images[chunkID] {
+		out.Images = append(out.Images, SourceImage{
+			ID:         img.ID,
+			Caption:    img.Caption,
+			MIMEType:   img.MIMEType,
+			Width:      img.Width,
+			Height:     img.Height,
+			PageNumber: img.PageNumber,
+		})
+	}
+	return out, nil
+}
+
+// ChunkImage returns image n (0-based, in ChunkDetail.Images order) of a
+// chunk, including its bytes.
+func (e *engine) ChunkImage(ctx context.Context, chunkID int64, n int) (*store.ChunkImage, error) {
+	if _, err := e.store.GetChunk(ctx, chunkID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %d", ErrChunkNotFound, chunkID)
+		}
+		return nil, err
+	}
+	images, err := e.store.GetImagesByChunkIDs(ctx, []int64{chunkID}, true)
+	if err != nil {
+		return nil, fmt.Errorf("loading chunk images: %w", err)
+	}
+	imgs := images[chunkID]
+	if n < 0 || n >= len(imgs) {
+		return nil, fmt.Errorf("%w: chunk %d has %d images, requested %d", ErrImageNotFound, chunkID, len(imgs), n)
+	}
+	img := imgs[n]
+	return &img, nil
+}
+
+// DocumentChunks is one page of a document's chunks in document order,
+// with the document's heading tree for a table of contents.
+type DocumentChunks struct {
+	DocumentID int64 `json:"document_id"`
+	Total      int   `json:"total"` // chunks in the document
+	Offset     int   `json:"offset"`
+	Limit      int   `json:"limit"`
+	// Chunks holds the page, Outline the whole document's headings.
+	Chunks  []DocumentChunk `json:"chunks"`
+	Outline []*HeadingNode  `json:"outline"`
+}
+
+// DocumentChunk is a chunk in a DocumentChunks page.
+type DocumentChunk struct {
+	ID            int64             `json:"id"`
+	ParentChunkID *int64            `json:"parent_chunk_id,omitempty"`
+	Content       string            `json:"content"`
+	Heading       string            `json:"heading"`
+	ChunkType     string            `json:"chunk_type,omitempty"`
+	PageNumber    int               `json:"page_number"`
+	PositionInDoc int               `json:"position_in_doc"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	Span          *SourceSpan       `json:"span,omitempty"`
+	Stats         ChunkStats        `json:"stats"`
+}
+
+// ChunkStats describes a chunk's size and use.
+type ChunkStats struct {
+	Tokens     int `json:"tokens"`
+	Characters int `json:"characters"`
+	Children   int `json:"children"` // chunks whose parent it is
+	Images     int `json:"images"`
+	Retrieved  int `json:"retrieved"` // times retrieved for a query
+	Cited      int `json:"cited"`     // times cited by an answer
+}
+
+// HeadingNode is a heading in a document's outline: the section chunk
+// that opens it and its subsections. Chunks and Tokens count the section
+// and everything under it.
+type HeadingNode struct {
+	ChunkID       int64          `json:"chunk_id"`
+	Heading       string         `json:"heading"`
+	SectionNumber string         `json:"section_number,omitempty"`
+	PageNumber    int            `json:"page_number"`
+	PositionInDoc int            `json:"position_in_doc"`
+	Chunks        int            `json:"chunks"`
+	Tokens        int            `json:"tokens"`
+	Children      []*HeadingNode `json:"children,omitempty"`
+}
+
+// defaultChunkPage is the DocumentChunks page size when limit is 0.
+const defaultChunkPage = 50
+
+// DocumentChunks returns limit chunks (50 when 0) of a document from
+// offset, ordered by position, with per-chunk stats and the document's
+// heading tree.
+func (e *engine) DocumentChunks(ctx context.Context, documentID int64, offset, limit int) (*DocumentChunks, error) {
+	if _, err := e.store.GetDocument(ctx, documentID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %d", ErrDocumentNotFound, documentID)
+		}
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = defaultChunkPage
+	}
+	offset = max(offset, 0)
+
+	all, err := e.store.ChunkOutline(ctx, documentID)
+	if err != nil {
+		return nil, fmt.Errorf("loading chunk outline: %w", err)
+	}
+	page, err := e.store.GetChunkPage(ctx, documentID, offset, limit)
+	if err != nil {
+		return nil, fmt.Errorf("loading chunks: %w", err)
+	}
+	out := &DocumentChunks{
+		DocumentID: documentID,
+		Total:      len(all),
+		Offset:     offset,
+		Limit:      limit,
+		Chunks:     make([]DocumentChunk, 0, len(page)),
+		Outline:    headingTree(all),
+	}
+	if len(page) == 0 {
+		return out, nil
+	}
+
+	children := make(map[int64]int)
+	for _, c := range all {
+		if c.ParentChunkID != nil {
+			children[*c.ParentChunkID]++
+		}
+	}
+	ids := make([]int64, len(page))
+	for i, c := range page {
+		ids[i] = c.ID
+	}
+	images, err := e.store.GetImagesByChunkIDs(ctx, ids, false)
+	if err != nil {
+		return nil, fmt.Errorf("loading chunk images: %w", err)
+	}
+	usage, err := e.store.ChunkUsages(ctx, ids, 0)
+	if err != nil {
+		return nil, fmt.Errorf("loading chunk usage: %w", err)
+	}
+	for _, c := range page {
+		dc := DocumentChunk{
+			ID:            c.ID,
+			ParentChunkID: c.ParentChunkID,
+			Content:       c.Content,
+			Heading:       c.Heading,
+			ChunkType:     c.ChunkType,
+			PageNumber:    c.PageNumber,
+			PositionInDoc: c.PositionInDoc,
+			Stats: ChunkStats{
+				Tokens:     c.TokenCount,
+				Characters: utf8.RuneCountInString(c.Content),
+				Children:   children[c.ID],
+				Images:     len(images[c.ID]),
+				Retrieved:  usage[c.ID].Retrieved,
+				Cited:      usage[c.ID].Cited,
+			},
+		}
+		if c.Metadata != "" && c.Metadata != "{}" {
+			_ = json.Unmarshal([]byte(c.Metadata), &dc.Metadata)
+		}
+		if c.EndOffset > 0 {
+			dc.Span = &SourceSpan{StartOffset: c.StartOffset, EndOffset: c.EndOffset}
+		}
+		out.Chunks = append(out.Chunks, dc)
+	}
+	return out, nil
+}
+
+// headingTree builds a document's outline from its chunks in document
+// order. The chunker stores each section as a chunk heading its content
+// chunks and subsections (through ParentChunkID), so a chunk opens a
+// heading when it has no parent or its heading differs from its parent's.
+// Sections without a heading are left out and their subsections lifted to
+// the nearest heading above.
+func headingTree(chunks []store.Chunk) []*HeadingNode {
+	byID := make(map[int64]store.Chunk, len(chunks))
+	for _, c := range chunks {
+		byID[c.ID] = c
+	}
+	nodes := make(map[int64]*HeadingNode)
+	var roots []*HeadingNode
+
+	// nodeFor returns the heading node a chunk belongs to, walking up
+	// through content chunks and untitled sections.
+	nodeFor := func(id *int64) *HeadingNode {
+		for id != nil {
+			if n, ok := nodes[*id]; ok {
+				return n
+			}
+			p, ok := byID[*id]
+			if !ok {
+				return nil
+			}
+			id = p.ParentChunkID
+		}
+		return nil
+	}
+	parents := make(map[*HeadingNode]*HeadingNode)
+
+	for _, c := range chunks {
+		var parent *store.Chunk
+		if c.ParentChunkID != nil {
+			if p, ok := byID[*c.ParentChunkID]; ok {
+				parent = &p
+			}
+		}
+		owner := nodeFor(c.ParentChunkID)
+		opens := c.Heading != "" && (parent == nil || parent.Heading != c.Heading)
+		if opens {
+			n := &HeadingNode{
+				ChunkID:       c.ID,
+				Heading:       c.Heading,
+				SectionNumber: chunker.SectionNumber(c.Heading),
+				PageNumber:    c.PageNumber,
+				PositionInDoc: c.PositionInDoc,
+			}
+			nodes[c.ID] = n
+			parents[n] = owner
+			if owner == nil {
+				roots = append(roots, n)
+			} else {
+				owner.Children = append(owner.Children, n)
+			}
+			owner = n
+		}
+		for n := owner; n != nil; n = parents[n] {
+			n.Chunks++
+			n.Tokens += c.TokenCount
+		}
+	}
+	if roots == nil {
+		roots = []*HeadingNode{}
+	}
+	return roots
+}
@@ -0,0 +1,135 @@
+package goreason
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/bbiangul/go-reason/store"
+)
+
+func TestChunkAndChunkImage(t *testing.T) {
+	s, err := store.New(filepath.Join(t.TempDir(), "chunks.db"), 4)
+	if err != nil {
+		t.Fatalf("store.New: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	docID, err := s.UpsertDocument(ctx, store.Document{Path: "/manual.pdf", Filename: "manual.pdf", Format: "pdf", ContentHash: "h", ParseMethod: "native", Status: "ready", Metadata: `{"owner":"ops"}`})
+	if err != nil {
+		t.Fatalf("UpsertDocument: %v", err)
+	}
+	ids, err := s.InsertChunks(ctx, []store.Chunk{
+		{DocumentID: docID, Content: "Wiring diagram for the pump.", ChunkType: "paragraph", Heading: "Wiring", PageNumber: 3, Metadata: `{"figure":"4"}`, EndOffset: 28},
+	})
+	if err != nil {
+		t.Fatalf("InsertChunks: %v", err)
+	}
+	png := []byte("\x89PNG fake")
+	if err := s.InsertChunkImages(ctx, []store.ChunkImage{
+		{ChunkID: ids[0], DocumentID: docID, Caption: "diagram", MIMEType: "image/png", Width: 10, Height: 5, PageNumber: 3, Data: png},
+	}); err != nil {
+		t.Fatalf("InsertChunkImages: %v", err)
+	}
+
+	e := &engine{store: s}
+	got, err := e.Chunk(ctx, ids[0])
+	if err != nil {
+		t.Fatalf("Chunk: %v", err)
+	}
+	if got.Filename != "manual.pdf" || got.Heading != "Wiring" || got.Metadata["figure"] != "4" || got.DocumentMetadata["owner"] != "ops" {
+		t.Errorf("Chunk = %+v", got)
+	}
+	if got.Span == nil || got.Span.EndOffset != 28 {
+		t.Errorf("Span = %+v", got.Span)
+	}
+	if len(got.Images) != 1 || got.Images[0].MIMEType != "image/png" || got.Images[0].Data != nil {
+		t.Errorf("Images = %+v, want one entry without data", got.Images)
+	}
+
+	img, err := e.ChunkImage(ctx, ids[0], 0)
+	if err != nil {
+		t.Fatalf("ChunkImage: %v", err)
+	}
+	if !bytes.Equal(img.Data, png) || img.MIMEType != "image/png" {
+		t.Errorf("ChunkImage = %q (%s)", img.Data, img.MIMEType)
+	}
+
+	if _, err := e.ChunkImage(ctx, ids[0], 1); !errors.Is(err, ErrImageNotFound) {
+		t.Errorf("ChunkImage(1) error = %v, want ErrImageNotFound", err)
+	}
+	if _, err := e.Chunk(ctx, 9999); !errors.Is(err, ErrChunkNotFound) {
+		t.Errorf("Chunk(missing) error = %v, want ErrChunkNotFound", err)
+	}
+}
+
+func TestDocumentChunks(t *testing.T) {
+	s, err := store.New(filepath.Join(t.TempDir(), "chunks.db"), 4)
+	if err != nil {
+		t.Fatalf("store.New: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	docID, err := s.UpsertDocument(ctx, store.Document{Path: "/manual.md", Filename: "manual.md", Format: "markdown", ContentHash: "h", ParseMethod: "native", Status: "ready"})
+	if err != nil {
+		t.Fatalf("UpsertDocument: %v", err)
+	}
+	// Temporary IDs as the chunker assigns them: 1 Pump > 2 content,
+	// 3 1.1 Valves > 4 content, then 5 Wiring.
+	parent := func(id int64) *int64 { return &id }
+	ids, err := s.InsertChunks(ctx, []store.Chunk{
+		{ID: 1, DocumentID: docID, Content: "Pump", ChunkType: "section", Heading: "Pump", PositionInDoc: 0, TokenCount: 1},
+		{ID: 2, DocumentID: docID, ParentChunkID: parent(1), Content: "The pump moves water.", ChunkType: "paragraph", Heading: "Pump", PositionInDoc: 1, TokenCount: 5},
+		{ID: 3, DocumentID: docID, ParentChunkID: parent(1), Content: "1.1 Valves", ChunkType: "section", Heading: "1.1 Valves", PositionInDoc: 2, TokenCount: 2},
+		{ID: 4, DocumentID: docID, ParentChunkID: parent(3), Content: "The relief valve opens at 10 bar.", ChunkType: "paragraph", Heading: "1.1 Valves", PositionInDoc: 3, TokenCount: 8},
+		{ID: 5, DocumentID: docID, Content: "Wiring", ChunkType: "section", Heading: "Wiring", PositionInDoc: 4, TokenCount: 1},
+	})
+	if err != nil {
+		t.Fatalf("InsertChunks: %v", err)
+	}
+	if err := s.RecordChunkUsage(ctx, []int64{ids[3]}, []int64{ids[3]}, 0); err != nil {
+		t.Fatalf("RecordChunkUsage: %v", err)
+	}
+
+	e := &engine{store: s}
+	page, err := e.DocumentChunks(ctx, docID, 1, 3)
+	if err != nil {
+		t.Fatalf("DocumentChunks: %v", err)
+	}
+	if page.Total != 5 || page.Offset != 1 || page.Limit != 3 || len(page.Chunks) != 3 {
+		t.Fatalf("page = total %d, offset %d, limit %d, %d chunks", page.Total, page.Offset, page.Limit, len(page.Chunks))
+	}
+	for i, c := range page.Chunks {
+		if c.PositionInDoc != i+1 {
+			t.Errorf("chunk %d at position %d, want %d", i, c.PositionInDoc, i+1)
+		}
+	}
+	if st := page.Chunks[1].Stats; st.Children != 1 || st.Tokens != 2 {
+		t.Errorf("section stats = %+v", st)
+	}
+	if st := page.Chunks[2].Stats; st.Retrieved != 1 || st.Cited != 1 || st.Characters != 33 {
+		t.Errorf("content stats = %+v", st)
+	}
+
+	if len(page.Outline) != 2 {
+		t.Fatalf("outline = %d roots, want 2", len(page.Outline))
+	}
+	pump, wiring := page.Outline[0], page.Outline[1]
+	if pump.Heading != "Pump" || pump.Chunks != 4 || pump.Tokens != 16 || len(pump.Children) != 1 || wiring.Heading != "Wiring" {
+		t.Errorf("outline roots = %+v, %+v", pump, wiring)
+	}
+	if v := pump.Children[0]; v.ChunkID != ids[2] || v.SectionNumber != "1.1" || v.Chunks != 2 || v.PositionInDoc != 2 {
+		t.Errorf("subsection = %+v", v)
+	}
+
+	if page, err := e.DocumentChunks(ctx, docID, 10, 0); err != nil || len(page.Chunks) != 0 || page.Limit != defaultChunkPage {
+		t.Errorf("past the end = %+v, %v", page, err)
+	}
+	if _, err := e.DocumentChunks(ctx, 9999, 0, 0); !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("DocumentChunks(missing) error = %v, want ErrDocumentNotFound", err)
+	}
+}
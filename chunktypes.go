@@ -0,0 +1,76 @@
+package goreason
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/bbiangul/go-reason/chunker"
+	"github.com/bbiangul/go-reason/llm"
+	"github.com/bbiangul/go-reason/reasoning"
+)
+
+// compileChunkTypes validates the configured chunk types and splits them
+// into the chunker rules, retrieval boosts, and reasoning treatments that
+// each layer consumes.
+func compileChunkTypes(types []ChunkTypeConfig) ([]chunker.TypeRule, map[string]float64, map[string]reasoning.ChunkTypeTreatment, error) {
+	if len(types) == 0 {
+		return nil, nil, nil, nil
+	}
+
+	rules := make([]chunker.TypeRule, 0, len(types))
+	boosts := make(map[string]float64)
+	treatments := make(map[string]reasoning.ChunkTypeTreatment)
+	seen := make(map[string]bool)
+	for _, t := range types {
+		if t.Name == "" {
+			return nil, nil, nil, fmt.Errorf("%w: chunk type name is required", ErrInvalidConfig)
+		}
+		if seen[t.Name] {
+			return nil, nil, nil, fmt.Errorf("%w: duplicate chunk type %q", ErrInvalidConfig, t.Name)
+		}
+		seen[t.Name] = true
+		if t.Boost < 0 {
+			return nil, nil, nil, fmt.Errorf("%w: chunk type %q has negative boost", ErrInvalidConfig, t.Name)
+		}
+		switch t.Render {
+		case reasoning.RenderPlain, reasoning.RenderMarkdownTable:
+		default:
+			return nil, nil, nil, fmt.Errorf("%w: chunk type %q has unknown render %q", ErrInvalidConfig, t.Name, t.Render)
+		}
+
+		rule := chunker.TypeRule{Name: t.Name}
+		if t.Match != "" {
+			re, err := regexp.Compile(t.Match)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("%w: chunk type %q match: %v", ErrInvalidConfig, t.Name, err)
+			}
+			rule.Match = re
+		}
+		rules = append(rules, rule)
+
+		if t.Boost > 0 && t.Boost != 1 {
+			boosts[t.Name] = t.Boost
+		}
+		if t.Render != "" || t.Instruction != "" {
+			treatments[t.Name] = reasoning.ChunkTypeTreatment{Render: t.Render, Instruction: t.Instruction}
+		}
+	}
+	return rules, boosts, treatments, nil
+}
+
+// loadChunkTokenizers loads the configured per-language tokenizers for
+// the chunker. A file that cannot be loaded is ErrInvalidConfig.
+func loadChunkTokenizers(paths map[string]string) (map[string]chunker.Tokenizer, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	tokenizers := make(map[string]chunker.Tokenizer, len(paths))
+	for lang, path := range paths {
+		tok, err := llm.LoadTokenizer(path)
+		if err != nil {
+			return nil, fmt.Errorf("%w: chunk_tokenizers[%q]: %v", ErrInvalidConfig, lang, err)
+		}
+		tokenizers[lang] = tok
+	}
+	return tokenizers, nil
+}
@@ -0,0 +1,41 @@
+package goreason
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCompileChunkTypes(t *testing.T) {
+	rules, boosts, treatments, err := compileChunkTypes([]ChunkTypeConfig{
+		{Name: "requirement", Boost: 1.3, Instruction: "Quote verbatim."},
+		{Name: "table", Render: "markdown_table"},
+		{Name: "warning", Match: `(?i)warning|caution`, Boost: 1},
+	})
+	if err != nil {
+		t.Fatalf("compileChunkTypes: %v", err)
+	}
+	if len(rules) != 3 || rules[2].Match == nil || !rules[2].Match.MatchString("CAUTION") {
+		t.Errorf("unexpected rules: %+v", rules)
+	}
+	if len(boosts) != 1 || boosts["requirement"] != 1.3 {
+		t.Errorf("boosts = %v, want only requirement", boosts)
+	}
+	if treatments["table"].Render != "markdown_table" || treatments["requirement"].Instruction == "" {
+		t.Errorf("treatments = %+v", treatments)
+	}
+	if _, ok := treatments["warning"]; ok {
+		t.Error("types without render or instruction need no treatment")
+	}
+
+	for _, bad := range [][]ChunkTypeConfig{
+		{{Name: ""}},
+		{{Name: "a"}, {Name: "a"}},
+		{{Name: "a", Match: "("}},
+		{{Name: "a", Render: "html"}},
+		{{Name: "a", Boost: -1}},
+	} {
+		if _, _, _, err := compileChunkTypes(bad); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("compileChunkTypes(%+v) error = %v, want ErrInvalidConfig", bad, err)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/bbiangul/go-reason/llm/llmtest"
+)
+
+// llmCassette routes the run's LLM calls through --cassette, or is nil.
+var llmCassette *cassetteSession
+
+// cassetteSession replays a run's LLM calls from a cassette file, or
+// records them into it on their way to the real providers (--record).
+type cassetteSession struct {
+	path     string
+	record   bool
+	cassette *llmtest.Cassette
+	servers  map[string]*llmtest.CassetteServer // by upstream base URL; one replay server under ""
+}
+
+// openCassette starts a cassette session. Recording extends an existing
+// cassette file, so several runs can share one.
+func openCassette(path string, record bool) *cassetteSession {
+	c, err := llmtest.LoadCassette(path)
+	switch {
+	case err == nil:
+	case record && os.IsNotExist(err):
+		c = &llmtest.Cassette{}
+	default:
+		log.Fatalf("loading cassette: %v", err)
+	}
+	s := &cassetteSession{path: path, record: record, cassette: c, servers: map[string]*llmtest.CassetteServer{}}
+	mode := "replaying"
+	if record {
+		mode = "recording"
+	}
+	fmt.Fprintf(os.Stderr, "Cassette: %s %s (%d recorded interactions)\n", mode, path, len(c.Interactions))
+	return s
+}
+
+// replaying reports whether LLM calls are answered from the cassette, so
+// no provider or API key is needed.
+func (s *cassetteSession) replaying() bool {
+	return s != nil && !s.record
+}
+
+// endpoint returns the provider type and base URL that route a provider at
+// baseURL through the cassette. Replay serves every provider as "custom".
+func (s *cassetteSession) endpoint(provider, baseURL string) (string, string) {
+	if s == nil {
+		return provider, baseURL
+	}
+	if !s.record {
+		srv, ok := s.servers[""]
+		if !ok {
+			srv = llmtest.NewReplayServer(s.cassette)
+			s.servers[""] = srv
+		}
+		return "custom", srv.URL
+	}
+	if baseURL == "" {
+		log.Fatalf("recording a cassette needs the base URL of provider %q", provider)
+	}
+	srv, ok := s.servers[baseURL]
+	if !ok {
+		srv = llmtest.NewRecordingServer(s.cassette, baseURL)
+		s.servers[baseURL] = srv
+	}
+	return provider, srv.URL
+}
+
+// close stops the servers and saves a recording. A replay that met
+// requests missing from the cassette exits non-zero, so CI notices that
+// the cassette needs re-recording.
+func (s *cassetteSession) close() {
+	if s == nil {
+		return
+	}
+	var misses []string
+	for _, srv := range s.servers {
+		srv.Close()
+		misses = append(misses, srv.Misses()...)
+	}
+	if s.record {
+		if err := s.cassette.Save(s.path); err != nil {
+			log.Fatalf("saving cassette: %v", err)
+		}
+		fmt.Fprintf(os.Stderr, "Cassette saved: %s (%d interactions)\n", s.path, len(s.cassette.Interactions))
+		return
+	}
+	if len(misses) > 0 {
+		fmt.Fprintf(os.Stderr, "Cassette %s has no recording for %d requests; re-record it with --record. First:\n%s\n",
+			s.path, len(misses), misses[0])
+		os.Exit(1)
+	}
+}
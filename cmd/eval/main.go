@@ -35,6 +35,20 @@
 //	  --full-context \
 //	  --fc-provider gemini --fc-model gemini-2.0-flash \
 //	  --difficulty all
+//
+// Offline replay from a recorded cassette (no API keys; record it first by
+// adding --record to a normal run with the same flags):
+//
+//	go run -tags sqlite_fts5 ./cmd/eval \
+//	  --pdf ./docs/ALTAVision.pdf --difficulty easy \
+//	  --cassette evals/cassettes/altavision-easy.json
+//
+// Re-judging the stored answers of a run with another judge (no retrieval
+// or generation):
+//
+//	go run -tags sqlite_fts5 ./cmd/eval \
+//	  --rejudge evals/runs/2025-01-02_15-04-05 \
+//	  --judge-provider gemini --judge-model gemini-2.0-flash
 package main
 
 import (
@@ -104,10 +118,38 @@ func main() {
 		judgeProvider = flag.String("judge-provider", "", "LLM provider for accuracy judge (enables LLM-as-judge; e.g., gemini)")
 		judgeModel    = flag.String("judge-model", "", "Judge LLM model name (e.g., gemini-2.0-flash-lite)")
 		judgeAPIKey   = flag.String("judge-api-key", "", "Judge provider API key (default: from env)")
+		pricingFile   = flag.String("pricing-file", "", "JSON file of model prices ({\"model\": {\"input_per_million\": 0.15, \"output_per_million\": 0.6}}) for cost estimates")
+		compareRuns   = flag.String("compare-runs", "", "Compare two run directories (runA,runB): diff their corpora and eval results, then exit")
+		answerLang    = flag.String("answer-language", "", "Answer language: ISO 639-1 code (en, es, ...) or auto to follow the question (default: model's choice)")
+		rejudge       = flag.String("rejudge", "", "Re-score the answers stored in this run directory with the --judge-* flags, without retrieval or generation, then exit")
+		cassettePath  = flag.String("cassette", "", "Answer every LLM call from this recorded cassette file: offline, no API keys (see --record)")
+		record        = flag.Bool("record", false, "With --cassette, call the real providers and record their responses into the cassette")
+		hardNegatives = flag.Int("hard-negatives", 5, "Hard negatives mined per missed fact of a failed test into hard-negatives.jsonl (0 disables)")
 	)
 	flag.Var(&benchmarkFiles, "benchmark-file", "Path to benchmark JSON file (repeatable, for LegalBench-RAG)")
 	flag.Parse()
 
+	if *compareRuns != "" {
+		dirs := strings.Split(*compareRuns, ",")
+		if len(dirs) != 2 {
+			log.Fatal("--compare-runs expects two run directories separated by a comma")
+		}
+		compareRunDirs(strings.TrimSpace(dirs[0]), strings.TrimSpace(dirs[1]))
+		return
+	}
+
+	if *cassettePath != "" {
+		llmCassette = openCassette(*cassettePath, *record)
+		defer llmCassette.close()
+	} else if *record {
+		log.Fatal("--record needs --cassette")
+	}
+
+	if *rejudge != "" {
+		rejudgeRun(*rejudge, *judgeProvider, *judgeModel, *judgeAPIKey, *pricingFile, *outputFile)
+		return
+	}
+
 	// Validate flags based on dataset type
 	switch strings.ToLower(*datasetType) {
 	case "altavision":
@@ -150,7 +192,7 @@ func main() {
 			apiKey = os.Getenv("GEMINI_API_KEY")
 		}
 	}
-	if apiKey == "" && *chatProvider != "ollama" && *chatProvider != "lmstudio" && !*fullContext {
+	if apiKey == "" && *chatProvider != "ollama" && *chatProvider != "lmstudio" && !*fullContext && !llmCassette.replaying() {
 		log.Fatalf("API key required for provider %q: set --openrouter-key or the appropriate env var", *chatProvider)
 	}
 
@@ -256,6 +298,10 @@ func main() {
 	if *maxTests > 0 {
 		meta["max_tests_per_benchmark"] = *maxTests
 	}
+	if *cassettePath != "" {
+		meta["cassette"] = filepath.Base(*cassettePath)
+		meta["cassette_record"] = *record
+	}
 	if *fullContext {
 		meta["full_context"] = true
 		meta["fc_provider"] = *fcProvider
@@ -271,16 +317,18 @@ func main() {
 		return
 	}
 
+	chatProv, chatURL := llmCassette.endpoint(*chatProvider, chatURL)
+	embedProv, embedURL := llmCassette.endpoint(*embedProvider, embedURL)
 	cfg := goreason.Config{
 		DBPath: db,
 		Chat: goreason.LLMConfig{
-			Provider: *chatProvider,
+			Provider: chatProv,
 			Model:    *chatModel,
 			BaseURL:  chatURL,
 			APIKey:   apiKey,
 		},
 		Embedding: goreason.LLMConfig{
-			Provider: *embedProvider,
+			Provider: embedProv,
 			Model:    *embedModel,
 			BaseURL:  embedURL,
 			APIKey:   embedKey,
@@ -376,6 +424,18 @@ func main() {
 		fmt.Fprintf(os.Stderr, "Ingested document ID %d in %s\n", docID, ingestElapsed.Round(time.Millisecond))
 	}
 
+	// Fingerprint the corpus so later runs can tell corpus drift apart from
+	// code/config changes (see --compare-runs).
+	if snap, err := eval.SnapshotCorpus(ctx, engine.Store()); err != nil {
+		slog.Warn("eval: corpus snapshot failed (non-fatal)", "error", err)
+	} else {
+		writeJSON(filepath.Join(runDir, "corpus.json"), snap)
+		meta["corpus_fingerprint"] = snap.Fingerprint
+		writeJSON(filepath.Join(runDir, "metadata.json"), meta)
+		fmt.Fprintf(os.Stderr, "Corpus fingerprint: %s (%d documents, %d chunks)\n",
+			snap.Fingerprint, len(snap.Documents), snap.Stats.Chunks)
+	}
+
 	// Select datasets based on type
 	var datasets []eval.Dataset
 	var groundTruth map[string][]eval.GroundTruthSpan
@@ -425,46 +485,7 @@ func main() {
 
 	// Setup LLM judge if configured
 	if *judgeProvider != "" {
-		judgeKey := *judgeAPIKey
-		if judgeKey == "" {
-			switch *judgeProvider {
-			case "gemini":
-				judgeKey = os.Getenv("GEMINI_API_KEY")
-			case "openai":
-				judgeKey = os.Getenv("OPENAI_API_KEY")
-			case "groq":
-				judgeKey = os.Getenv("GROQ_API_KEY")
-			case "openrouter":
-				judgeKey = os.Getenv("OPENROUTER_API_KEY")
-			}
-		}
-
-		var judgeBaseURL string
-		switch *judgeProvider {
-		case "openrouter":
-			judgeBaseURL = "https://openrouter.ai/api"
-		case "openai":
-			judgeBaseURL = "https://api.openai.com"
-		case "groq":
-			judgeBaseURL = "https://api.groq.com/openai"
-		case "gemini":
-			judgeBaseURL = "https://generativelanguage.googleapis.com/v1beta/openai"
-		case "ollama":
-			judgeBaseURL = "http://localhost:11434"
-		case "lmstudio":
-			judgeBaseURL = "http://localhost:1234"
-		}
-
-		judge, err := llm.NewProvider(llm.Config{
-			Provider: *judgeProvider,
-			Model:    *judgeModel,
-			BaseURL:  judgeBaseURL,
-			APIKey:   judgeKey,
-		})
-		if err != nil {
-			log.Fatalf("creating judge LLM provider: %v", err)
-		}
-		evaluator.SetJudge(judge, *judgeModel)
+		evaluator.SetJudge(newJudge(*judgeProvider, *judgeModel, *judgeAPIKey), *judgeModel)
 		fmt.Fprintf(os.Stderr, "LLM judge enabled: %s/%s\n", *judgeProvider, *judgeModel)
 
 		meta["judge_provider"] = *judgeProvider
@@ -472,12 +493,31 @@ func main() {
 		writeJSON(filepath.Join(runDir, "metadata.json"), meta)
 	}
 
+	if *pricingFile != "" {
+		evaluator.SetPricing(readPricing(*pricingFile))
+	}
+
+	if *answerLang != "" {
+		evaluator.SetAnswerLanguage(*answerLang)
+		meta["answer_language"] = *answerLang
+		writeJSON(filepath.Join(runDir, "metadata.json"), meta)
+	}
+
+	// Keep the raw answers so the judge can be re-run on them (--rejudge).
+	answersFile, err := os.Create(filepath.Join(runDir, "answers.jsonl"))
+	if err != nil {
+		log.Fatalf("creating answers file: %v", err)
+	}
+	defer answersFile.Close()
+	evaluator.RecordAnswers(answersFile)
+
 	queryOpts := []goreason.QueryOption{
 		goreason.WithMaxResults(*maxResults),
 		goreason.WithMaxRounds(*maxRounds),
 	}
 
 	var allReports []*eval.Report
+	var mined []eval.HardNegative
 	evalStart := time.Now()
 
 	for _, ds := range datasets {
@@ -488,6 +528,14 @@ func main() {
 		}
 		allReports = append(allReports, report)
 
+		if *hardNegatives > 0 {
+			negs, err := evaluator.MineHardNegatives(ctx, report, *hardNegatives)
+			if err != nil {
+				log.Printf("mining hard negatives from %s: %v", ds.Name, err)
+			}
+			mined = append(mined, negs...)
+		}
+
 		fmt.Println(eval.FormatReport(report))
 		fmt.Println()
 	}
@@ -499,12 +547,16 @@ func main() {
 	meta["ingestion_elapsed"] = ingestElapsed.Round(time.Millisecond).String()
 	meta["eval_elapsed"] = evalElapsed.Round(time.Millisecond).String()
 	meta["total_elapsed"] = totalElapsed.Round(time.Millisecond).String()
+	if *judgeProvider != "" {
+		meta["judge_usage"] = judgeMeter.Snapshot()
+	}
 	writeJSON(filepath.Join(runDir, "metadata.json"), meta)
 
 	// Write eval-report.json in run directory
 	reportPath := filepath.Join(runDir, "eval-report.json")
 	writeJSON(reportPath, allReports)
 	fmt.Fprintf(os.Stderr, "Eval report written to: %s\n", reportPath)
+	writeHTMLReport(filepath.Join(runDir, "eval-report.html"), allReports, meta)
 
 	// Write to --output if specified (backward compat)
 	if *outputFile != "" {
@@ -512,10 +564,34 @@ func main() {
 		fmt.Fprintf(os.Stderr, "JSON report also written to: %s\n", *outputFile)
 	}
 
-	// Print summary
+	if *hardNegatives > 0 {
+		writeHardNegatives(filepath.Join(runDir, "hard-negatives.jsonl"), mined)
+	}
+
+	printSummary(allReports)
+
+	fmt.Fprintf(os.Stderr, "\nRun directory: %s\n", runDir)
+}
+
+// writeHardNegatives exports the retrieval failures mined from the run as
+// training triples (see eval.HardNegative).
+func writeHardNegatives(path string, negatives []eval.HardNegative) {
+	f, err := os.Create(path)
+	if err != nil {
+		log.Fatalf("creating hard negatives file: %v", err)
+	}
+	defer f.Close()
+	if err := eval.WriteHardNegatives(f, negatives); err != nil {
+		log.Fatalf("writing hard negatives: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "Hard negatives (%d) written to: %s\n", len(negatives), path)
+}
+
+// printSummary prints the pass rate of each report and the total.
+func printSummary(reports []*eval.Report) {
 	fmt.Println("=== Summary ===")
 	totalPassed, totalTests := 0, 0
-	for _, r := range allReports {
+	for _, r := range reports {
 		totalPassed += r.Passed
 		totalTests += r.TotalTests
 		rate := 0.0
@@ -528,6 +604,157 @@ func main() {
 		fmt.Printf("  %-45s %d/%d (%.1f%%)\n", "TOTAL", totalPassed, totalTests,
 			float64(totalPassed)/float64(totalTests)*100)
 	}
+}
+
+// judgeMeter counts the judge's calls, labelled llm.OperationJudge by the
+// scorers; a run records them in its metadata as judge_usage.
+var judgeMeter = llm.NewMeter()
+
+// newJudge creates the metered LLM judge provider, resolving the API key
+// from the provider's env var and the base URL of known providers.
+func newJudge(provider, model, apiKey string) llm.Provider {
+	if apiKey == "" {
+		switch provider {
+		case "gemini":
+			apiKey = os.Getenv("GEMINI_API_KEY")
+		case "openai":
+			apiKey = os.Getenv("OPENAI_API_KEY")
+		case "groq":
+			apiKey = os.Getenv("GROQ_API_KEY")
+		case "openrouter":
+			apiKey = os.Getenv("OPENROUTER_API_KEY")
+		}
+	}
+
+	var baseURL string
+	switch provider {
+	case "openrouter":
+		baseURL = "https://openrouter.ai/api"
+	case "openai":
+		baseURL = "https://api.openai.com"
+	case "groq":
+		baseURL = "https://api.groq.com/openai"
+	case "gemini":
+		baseURL = "https://generativelanguage.googleapis.com/v1beta/openai"
+	case "ollama":
+		baseURL = "http://localhost:11434"
+	case "lmstudio":
+		baseURL = "http://localhost:1234"
+	}
+
+	name := provider
+	provider, baseURL = llmCassette.endpoint(provider, baseURL)
+	judge, err := llm.NewProvider(llm.Config{
+		Provider: provider,
+		Model:    model,
+		BaseURL:  baseURL,
+		APIKey:   apiKey,
+	})
+	if err != nil {
+		log.Fatalf("creating judge LLM provider: %v", err)
+	}
+	return llm.Metered(judge, name, model, judgeMeter)
+}
+
+// readPricing reads a --pricing-file model price table.
+func readPricing(path string) map[string]eval.ModelPrice {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("reading pricing file: %v", err)
+	}
+	var pricing map[string]eval.ModelPrice
+	if err := json.Unmarshal(data, &pricing); err != nil {
+		log.Fatalf("parsing pricing file: %v", err)
+	}
+	return pricing
+}
+
+// rejudgeRun re-scores the answers stored in srcDir with the given judge
+// and writes the reports to a new run directory. Retrieval and generation
+// are not repeated, so runs differ from srcDir only in judge-dependent
+// metrics. The source run's corpus snapshot and answers are copied along,
+// so the new run can be compared (--compare-runs) or rejudged again.
+func rejudgeRun(srcDir, judgeProvider, judgeModel, judgeAPIKey, pricingFile, outputFile string) {
+	answers, err := eval.ReadStoredAnswers(filepath.Join(srcDir, "answers.jsonl"))
+	if err != nil {
+		log.Fatalf("reading stored answers: %v (runs before answers were recorded cannot be rejudged)", err)
+	}
+	datasets, spans := eval.StoredDatasets(answers)
+
+	runDir := createRunDir()
+	fmt.Fprintf(os.Stderr, "Run directory: %s\n", runDir)
+	logFile := setupLogTee(runDir)
+	defer logFile.Close()
+
+	for _, name := range []string{"corpus.json", "answers.jsonl"} {
+		data, err := os.ReadFile(filepath.Join(srcDir, name))
+		if err != nil {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(runDir, name), data, 0644); err != nil {
+			log.Fatalf("copying %s: %v", name, err)
+		}
+	}
+
+	meta := readRunMeta(srcDir)
+	meta["rejudged_from"] = srcDir
+	meta["git_commit"] = gitCommit()
+	meta["timestamp"] = time.Now().UTC().Format(time.RFC3339)
+	delete(meta, "judge_provider")
+	delete(meta, "judge_model")
+	delete(meta, "judge_usage")
+
+	evaluator := eval.NewEvaluator(nil)
+	evaluator.ReplayAnswers(answers)
+	if len(spans) > 0 {
+		evaluator.SetGroundTruth(spans)
+	}
+	if lang, ok := meta["answer_language"].(string); ok && lang != "" {
+		evaluator.SetAnswerLanguage(lang)
+	}
+	if judgeProvider != "" {
+		evaluator.SetJudge(newJudge(judgeProvider, judgeModel, judgeAPIKey), judgeModel)
+		fmt.Fprintf(os.Stderr, "LLM judge enabled: %s/%s\n", judgeProvider, judgeModel)
+		meta["judge_provider"] = judgeProvider
+		meta["judge_model"] = judgeModel
+	}
+	if pricingFile != "" {
+		evaluator.SetPricing(readPricing(pricingFile))
+	}
+	writeJSON(filepath.Join(runDir, "metadata.json"), meta)
+
+	ctx := context.Background()
+	var allReports []*eval.Report
+	evalStart := time.Now()
+
+	for _, ds := range datasets {
+		fmt.Fprintf(os.Stderr, "\nRejudging %s (%d stored answers)...\n", ds.Name, len(ds.Tests))
+		report, err := evaluator.Run(ctx, ds)
+		if err != nil {
+			log.Fatalf("rejudging %s: %v", ds.Name, err)
+		}
+		allReports = append(allReports, report)
+		fmt.Println(eval.FormatReport(report))
+		fmt.Println()
+	}
+
+	meta["eval_elapsed"] = time.Since(evalStart).Round(time.Millisecond).String()
+	if judgeProvider != "" {
+		meta["judge_usage"] = judgeMeter.Snapshot()
+	}
+	writeJSON(filepath.Join(runDir, "metadata.json"), meta)
+
+	reportPath := filepath.Join(runDir, "eval-report.json")
+	writeJSON(reportPath, allReports)
+	fmt.Fprintf(os.Stderr, "Eval report written to: %s\n", reportPath)
+	writeHTMLReport(filepath.Join(runDir, "eval-report.html"), allReports, meta)
+
+	if outputFile != "" {
+		writeJSON(outputFile, allReports)
+		fmt.Fprintf(os.Stderr, "JSON report also written to: %s\n", outputFile)
+	}
+
+	printSummary(allReports)
 
 	fmt.Fprintf(os.Stderr, "\nRun directory: %s\n", runDir)
 }
@@ -556,6 +783,94 @@ func selectDatasets(all map[string]eval.Dataset, difficulty string) []eval.Datas
 	}
 }
 
+// compareRunDirs prints the corpus diff between two run directories and the
+// per-dataset accuracy deltas, flagging whether those deltas coincide with a
+// corpus change (and so cannot be attributed to code/config alone).
+func compareRunDirs(dirA, dirB string) {
+	snapA := readCorpusSnapshot(dirA)
+	snapB := readCorpusSnapshot(dirB)
+	diff := eval.DiffCorpus(snapA, snapB)
+	fmt.Print(eval.FormatCorpusDiff(snapA, snapB, diff))
+	fmt.Println()
+
+	commitA := readRunMeta(dirA)["git_commit"]
+	commitB := readRunMeta(dirB)["git_commit"]
+	fmt.Printf("Git commit: %v -> %v\n\n", commitA, commitB)
+
+	reportsA := readRunReports(dirA)
+	reportsB := readRunReports(dirB)
+	byName := make(map[string]*eval.Report, len(reportsA))
+	for _, r := range reportsA {
+		byName[r.Dataset] = r
+	}
+
+	fmt.Println("=== Eval Deltas ===")
+	changed := false
+	for _, rb := range reportsB {
+		ra, ok := byName[rb.Dataset]
+		if !ok {
+			fmt.Printf("  %-45s (only in %s)\n", rb.Dataset, dirB)
+			continue
+		}
+		dAcc := rb.Metrics.AvgAccuracy - ra.Metrics.AvgAccuracy
+		dPass := rb.Passed - ra.Passed
+		if dPass != 0 || dAcc > 0.005 || dAcc < -0.005 {
+			changed = true
+		}
+		fmt.Printf("  %-45s passed %d -> %d (%+d)  accuracy %.3f -> %.3f (%+.3f)\n",
+			rb.Dataset, ra.Passed, rb.Passed, dPass,
+			ra.Metrics.AvgAccuracy, rb.Metrics.AvgAccuracy, dAcc)
+	}
+	fmt.Println()
+
+	switch {
+	case !changed:
+		fmt.Println("Verdict: no material eval delta.")
+	case diff.Material && commitA == commitB:
+		fmt.Println("Verdict: eval delta is likely explained by corpus changes (same git commit).")
+	case diff.Material:
+		fmt.Println("Verdict: corpus AND code changed — eval delta cannot be attributed to code/config alone.")
+	default:
+		fmt.Println("Verdict: corpus unchanged — eval delta reflects code/config changes (or model nondeterminism).")
+	}
+}
+
+func readCorpusSnapshot(runDir string) *eval.CorpusSnapshot {
+	path := filepath.Join(runDir, "corpus.json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("reading %s: %v (runs before corpus snapshots were recorded cannot be compared)", path, err)
+	}
+	var snap eval.CorpusSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		log.Fatalf("parsing %s: %v", path, err)
+	}
+	return &snap
+}
+
+func readRunMeta(runDir string) map[string]interface{} {
+	meta := map[string]interface{}{}
+	data, err := os.ReadFile(filepath.Join(runDir, "metadata.json"))
+	if err != nil {
+		return meta
+	}
+	_ = json.Unmarshal(data, &meta)
+	return meta
+}
+
+func readRunReports(runDir string) []*eval.Report {
+	path := filepath.Join(runDir, "eval-report.json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("reading %s: %v", path, err)
+	}
+	var reports []*eval.Report
+	if err := json.Unmarshal(data, &reports); err != nil {
+		log.Fatalf("parsing %s: %v", path, err)
+	}
+	return reports
+}
+
 // createRunDir creates evals/runs/<timestamp>/ and returns its path.
 func createRunDir() string {
 	ts := time.Now().Format("2006-01-02_15-04-05")
@@ -599,6 +914,20 @@ func writeJSON(path string, v interface{}) {
 	}
 }
 
+// writeHTMLReport writes the shareable HTML version of the run's reports
+// (see eval.WriteHTMLReport).
+func writeHTMLReport(path string, reports []*eval.Report, meta map[string]interface{}) {
+	f, err := os.Create(path)
+	if err != nil {
+		log.Fatalf("creating %s: %v", path, err)
+	}
+	defer f.Close()
+	if err := eval.WriteHTMLReport(f, reports, meta); err != nil {
+		log.Fatalf("writing %s: %v", path, err)
+	}
+	fmt.Fprintf(os.Stderr, "HTML report written to: %s\n", path)
+}
+
 // limitDatasetTests truncates each dataset's test list to maxTests.
 func limitDatasetTests(datasets []eval.Dataset, maxTests int) []eval.Dataset {
 	result := make([]eval.Dataset, len(datasets))
@@ -628,7 +957,7 @@ func runFullContext(ctx context.Context, pdfPath, providerName, model, apiKey, d
 			apiKey = os.Getenv("OPENROUTER_API_KEY")
 		}
 	}
-	if apiKey == "" && providerName != "ollama" && providerName != "lmstudio" {
+	if apiKey == "" && providerName != "ollama" && providerName != "lmstudio" && !llmCassette.replaying() {
 		log.Fatalf("API key required for full-context provider %q", providerName)
 	}
 
@@ -649,6 +978,7 @@ func runFullContext(ctx context.Context, pdfPath, providerName, model, apiKey, d
 		baseURL = "http://localhost:1234"
 	}
 
+	providerName, baseURL = llmCassette.endpoint(providerName, baseURL)
 	provider, err := llm.NewProvider(llm.Config{
 		Provider: providerName,
 		Model:    model,
@@ -700,27 +1030,14 @@ func runFullContext(ctx context.Context, pdfPath, providerName, model, apiKey, d
 	reportPath := filepath.Join(runDir, "eval-report.json")
 	writeJSON(reportPath, allReports)
 	fmt.Fprintf(os.Stderr, "Eval report written to: %s\n", reportPath)
+	writeHTMLReport(filepath.Join(runDir, "eval-report.html"), allReports, meta)
 
 	if outputFile != "" {
 		writeJSON(outputFile, allReports)
 		fmt.Fprintf(os.Stderr, "JSON report also written to: %s\n", outputFile)
 	}
 
-	fmt.Println("=== Summary ===")
-	totalPassed, totalTests := 0, 0
-	for _, r := range allReports {
-		totalPassed += r.Passed
-		totalTests += r.TotalTests
-		rate := 0.0
-		if r.TotalTests > 0 {
-			rate = float64(r.Passed) / float64(r.TotalTests) * 100
-		}
-		fmt.Printf("  %-45s %d/%d (%.1f%%)\n", r.Dataset, r.Passed, r.TotalTests, rate)
-	}
-	if totalTests > 0 {
-		fmt.Printf("  %-45s %d/%d (%.1f%%)\n", "TOTAL", totalPassed, totalTests,
-			float64(totalPassed)/float64(totalTests)*100)
-	}
+	printSummary(allReports)
 
 	fmt.Fprintf(os.Stderr, "\nRun directory: %s\n", runDir)
 }
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bbiangul/go-reason"
+)
+
+// Demo mode defaults, for the zero fields of goreason.DemoConfig.
+const (
+	demoRequestsPerMinute = 10
+	demoTokensPerIP       = 50000
+	demoTokensPerDay      = 1000000
+	demoMaxQuestionChars  = 500
+	demoMaxRounds         = 2
+	demoWatermark         = "This answer comes from a public demo over a sample corpus. Verify it before relying on it."
+
+	// demoRoundTokens and demoRetrieveTokens are the tokens reserved for
+	// a reasoning round and for a retrieval before they run; what they
+	// actually spend is charged afterwards.
+	demoRoundTokens    = 4000
+	demoRetrieveTokens = 200
+)
+
+// demoRoutes are the routes a demo server serves: questions and reading
+// the corpus. The others answer 403.
+var demoRoutes = map[string]bool{
+	"POST /query":                 true,
+	"POST /retrieve":              true,
+	"GET /documents":              true,
+	"GET /documents/{id}":         true,
+	"GET /documents/{id}/summary": true,
+	"GET /documents/{id}/chunks":  true,
+	"GET /chunks/{id}":            true,
+	"GET /chunks/{id}/images/{n}": true,
+	"GET /glossary":               true,
+	"GET /entities/stats":         true,
+	"GET /health":                 true,
+}
+
+// demo enforces goreason.DemoConfig: the route allowlist, per-IP request
+// rates, and the daily token ceilings.
+type demo struct {
+	cfg goreason.DemoConfig
+
+	mu       sync.Mutex
+	clients  map[string]*demoClient
+	swept    time.Time // when idle clients were last removed
+	day      string    // UTC date the token counts are for
+	tokens   int       // spent by all clients on day
+	reserved int       // reserved by calls in flight
+}
+
+// demoClient is one client IP's request allowance and spent tokens.
+type demoClient struct {
+	allowance float64 // requests left, refilled at RequestsPerMinute
+	last      time.Time
+	tokens    int
+	reserved  int
+}
+
+// newDemo validates cfg and fills in its defaults.
+func newDemo(cfg goreason.DemoConfig) (*demo, error) {
+	for name, v := range map[string]int{
+		"requests_per_minute": cfg.RequestsPerMinute,
+		"tokens_per_ip":       cfg.TokensPerIP,
+		"tokens_per_day":      cfg.TokensPerDay,
+		"max_question_chars":  cfg.MaxQuestionChars,
+		"max_rounds":          cfg.MaxRounds,
+	} {
+		if v < 0 {
+			return nil, fmt.Errorf("demo: %s must not be negative", name)
+		}
+	}
+	if cfg.RequestsPerMinute == 0 {
+		cfg.RequestsPerMinute = demoRequestsPerMinute
+	}
+	if cfg.TokensPerIP == 0 {
+		cfg.TokensPerIP = demoTokensPerIP
+	}
+	if cfg.TokensPerDay == 0 {
+		cfg.TokensPerDay = demoTokensPerDay
+	}
+	if cfg.MaxQuestionChars == 0 {
+		cfg.MaxQuestionChars = demoMaxQuestionChars
+	}
+	if cfg.MaxRounds == 0 {
+		cfg.MaxRounds = demoMaxRounds
+	}
+	if cfg.Watermark == "" {
+		cfg.Watermark = demoWatermark
+	}
+	return &demo{cfg: cfg, clients: make(map[string]*demoClient)}, nil
+}
+
+// middleware refuses the routes outside demoRoutes and rate limits the
+// others per client IP, except the health check.
+func (d *demo) middleware(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Demo", "true")
+		// Unknown paths and methods fall through to the mux's 404 and 405.
+		if _, pattern := mux.Handler(r); pattern != "" && !demoRoutes[pattern] {
+			writeError(w, http.StatusForbidden, "disabled in the public demo")
+			return
+		}
+		if r.URL.Path != "/health" {
+			if wait := d.allow(d.clientIP(r), time.Now()); wait > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				writeError(w, http.StatusTooManyRequests, "demo rate limit reached, retry later")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientIP returns the address requests are limited by: with TrustProxy
+// the last X-Forwarded-For address, which the proxy appended, else the
+// connection's. The addresses before it come from the client and can be
+// forged.
+func (d *demo) clientIP(r *http.Request) string {
+	if d.cfg.TrustProxy {
+		if fwd := r.Header.Values("X-Forwarded-For"); len(fwd) > 0 {
+			last := fwd[len(fwd)-1]
+			if i := strings.LastIndexByte(last, ','); i >= 0 {
+				last = last[i+1:]
+			}
+			if ip := strings.TrimSpace(last); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// client returns ip's state, resetting the token counts at the start of
+// each UTC day. Once a minute it removes the clients that have been idle
+// for a minute, and so have their full allowance, unless they spent tokens
+// today or have calls in flight. d.mu must be held.
+func (d *demo) client(ip string, now time.Time) *demoClient {
+	if day := now.UTC().Format(time.DateOnly); day != d.day {
+		d.day, d.tokens = day, 0
+		for _, c := range d.clients {
+			c.tokens = 0
+		}
+	}
+	if now.Sub(d.swept) > time.Minute {
+		d.swept = now
+		for k, c := range d.clients {
+			if now.Sub(c.last) > time.Minute && c.tokens == 0 && c.reserved == 0 {
+				delete(d.clients, k)
+			}
+		}
+	}
+	c, ok := d.clients[ip]
+	if !ok {
+		c = &demoClient{allowance: float64(d.cfg.RequestsPerMinute), last: now}
+		d.clients[ip] = c
+	}
+	return c
+}
+
+// allow takes one request from ip's allowance. It returns how long to wait
+// when there is none left, else zero.
+func (d *demo) allow(ip string, now time.Time) time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	c := d.client(ip, now)
+	perSecond := float64(d.cfg.RequestsPerMinute) / 60
+	c.allowance = min(float64(d.cfg.RequestsPerMinute), c.allowance+now.Sub(c.last).Seconds()*perSecond)
+	c.last = now
+	if c.allowance < 1 {
+		return time.Duration((1 - c.allowance) / perSecond * float64(time.Second))
+	}
+	c.allowance--
+	return 0
+}
+
+// reserve sets estimate tokens of ip's and the demo's budgets aside for a
+// call about to be made, and reports whether they had tokens left today,
+// counting those reserved by calls in flight. A reservation is settled
+// once the call returns.
+func (d *demo) reserve(ip string, estimate int, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	c := d.client(ip, now)
+	if c.tokens+c.reserved >= d.cfg.TokensPerIP || d.tokens+d.reserved >= d.cfg.TokensPerDay {
+		return false
+	}
+	c.reserved += estimate
+	d.reserved += estimate
+	return true
+}
+
+// settle releases a reservation of ip's and charges the tokens the call
+// spent.
+func (d *demo) settle(ip string, reserved, spent int, now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	c := d.client(ip, now)
+	c.reserved -= reserved
+	d.reserved -= reserved
+	c.tokens += spent
+	d.tokens += spent
+}
+
+// question cuts q to MaxQuestionChars characters.
+func (d *demo) question(q string) string {
+	if r := []rune(q); len(r) > d.cfg.MaxQuestionChars {
+		return string(r[:d.cfg.MaxQuestionChars])
+	}
+	return q
+}
+
+// rounds caps the reasoning rounds a question asks for at MaxRounds,
+// which also applies when it asks for none.
+func (d *demo) rounds(requested int) int {
+	if requested <= 0 || requested > d.cfg.MaxRounds {
+		return d.cfg.MaxRounds
+	}
+	return requested
+}
+
+// watermark marks answer as a demo answer: in a "watermark" field of the
+// response, and at the end of its text unless the text is the JSON of
+// structured sections.
+func (d *demo) watermark(answer *goreason.Answer) interface{} {
+	if answer.Sections == nil {
+		answer.Text = strings.TrimRight(answer.Text, "\n") + "\n\n_" + d.cfg.Watermark + "_"
+	}
+	return struct {
+		*goreason.Answer
+		Watermark string `json:"watermark"`
+	}{answer, d.cfg.Watermark}
+}
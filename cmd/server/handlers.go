@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -10,17 +11,50 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/bbiangul/go-reason"
+	"github.com/bbiangul/go-reason/requestid"
+	"github.com/bbiangul/go-reason/store"
 )
 
 type handler struct {
 	engine goreason.Engine
+
+	// experiments split /query traffic across retrieval configurations.
+	experiments []goreason.ExperimentConfig
+
+	// logLevels are the levels /admin/loglevel reads and adjusts.
+	logLevels *logLevels
+
+	// demo limits questions in public demo mode; nil otherwise.
+	demo *demo
+}
+
+func newHandler(e goreason.Engine, experiments []goreason.ExperimentConfig) *handler {
+	return &handler{engine: e, experiments: experiments}
 }
 
-func newHandler(e goreason.Engine) *handler {
-	return &handler{engine: e}
+// assignExperiment returns the first experiment the request can be
+// enrolled in and its arm, or nil when there is none.
+func (h *handler) assignExperiment(r *http.Request) (*goreason.ExperimentConfig, *goreason.ExperimentArm) {
+	for i := range h.experiments {
+		x := &h.experiments[i]
+		var unit string
+		switch x.AssignBy {
+		case goreason.AssignByAPIKey:
+			if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+				unit = auth[7:]
+			}
+		case goreason.AssignByHeader:
+			unit = r.Header.Get(x.Header)
+		}
+		if arm := x.Assign(unit); arm != nil {
+			return x, arm
+		}
+	}
+	return nil, nil
 }
 
 // POST /ingest
@@ -43,22 +77,26 @@ func (h *handler) handleIngest(w http.ResponseWriter, r *http.Request) {
 			dst, err := os.Create(tmpPath)
 			if err != nil {
 				writeError(w, http.StatusInternalServerError, "failed to process file")
-				slog.Error("creating temp file", "error", err)
+				slog.ErrorContext(r.Context(), "creating temp file", "error", err)
 				return
 			}
 			if _, err := io.Copy(dst, file); err != nil {
 				dst.Close()
 				writeError(w, http.StatusInternalServerError, "failed to save file")
-				slog.Error("saving uploaded file", "error", err)
+				slog.ErrorContext(r.Context(), "saving uploaded file", "error", err)
 				return
 			}
 			dst.Close()
 			defer os.Remove(tmpPath)
 
-			docID, err := h.engine.Ingest(ctx, tmpPath)
+			var opts []goreason.IngestOption
+			if session := r.FormValue("session"); session != "" {
+				opts = append(opts, goreason.WithIngestSession(session))
+			}
+			docID, err := h.engine.Ingest(ctx, tmpPath, opts...)
 			if err != nil {
-				writeError(w, http.StatusInternalServerError, "ingestion failed")
-				slog.Error("ingest error", "error", err)
+				writeIngestError(w, err)
+				slog.ErrorContext(r.Context(), "ingest error", "error", err)
 				return
 			}
 
@@ -105,12 +143,42 @@ func (h *handler) handleIngest(w http.ResponseWriter, r *http.Request) {
 		if method, ok := req.Options["parse_method"]; ok {
 			opts = append(opts, goreason.WithParseMethod(method))
 		}
+		if collection, ok := req.Options["collection"]; ok {
+			opts = append(opts, goreason.WithIngestCollection(collection))
+		}
+		if session, ok := req.Options["session"]; ok {
+			opts = append(opts, goreason.WithIngestSession(session))
+		}
+		if importance, ok := req.Options["importance"]; ok {
+			weight, err := strconv.ParseFloat(importance, 64)
+			if err != nil || weight <= 0 {
+				writeError(w, http.StatusBadRequest, "importance must be a positive number")
+				return
+			}
+			opts = append(opts, goreason.WithImportance(weight))
+		}
+		if pages, ok := req.Options["pages"]; ok {
+			ranges, err := goreason.ParsePageRanges(pages)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			for _, r := range ranges {
+				opts = append(opts, goreason.WithPageRange(r.First, r.Last))
+			}
+		}
+		if sections, ok := req.Options["sections"]; ok {
+			opts = append(opts, goreason.WithSections(strings.Split(sections, "|")...))
+		}
+		if sections, ok := req.Options["exclude_sections"]; ok {
+			opts = append(opts, goreason.WithoutSections(strings.Split(sections, "|")...))
+		}
 	}
 
 	docID, err := h.engine.Ingest(ctx, absPath, opts...)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "ingestion failed")
-		slog.Error("ingest error", "path", absPath, "error", err)
+		writeIngestError(w, err)
+		slog.ErrorContext(r.Context(), "ingest error", "path", absPath, "error", err)
 		return
 	}
 
@@ -120,20 +188,125 @@ func (h *handler) handleIngest(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// queryParams are the query options accepted by /query and, per side, by
+// /query/compare.
+type queryParams struct {
+	MaxResults    int     `json:"max_results,omitempty"`
+	MaxRounds     int     `json:"max_rounds,omitempty"`
+	Strategy      string  `json:"strategy,omitempty"`
+	WeightVec     float64 `json:"weight_vector,omitempty"`
+	WeightFTS     float64 `json:"weight_fts,omitempty"`
+	WeightGraph   float64 `json:"weight_graph,omitempty"`
+	SkipGraph     bool    `json:"skip_graph,omitempty"`
+	JSONOutput    bool    `json:"json_output,omitempty"`
+	IncludeImages bool    `json:"include_images,omitempty"`
+	Suggest       bool    `json:"suggest_questions,omitempty"`
+	AnswerLang    string  `json:"answer_language,omitempty"`
+	Format        string  `json:"format,omitempty"`
+	DocOrder      string  `json:"document_order,omitempty"`
+	Collection    string  `json:"collection,omitempty"`
+	Space         string  `json:"embedding_space,omitempty"`
+	Session       string  `json:"session,omitempty"`
+
+	ValidationChecks []goreason.ValidationCheckConfig `json:"validation_checks,omitempty"`
+	NumericFilters   []goreason.NumericFilter         `json:"numeric_filters,omitempty"`
+}
+
+// options validates the parameters and converts them to query options.
+// Out-of-range limits fall back to the engine defaults.
+func (p queryParams) options() ([]goreason.QueryOption, error) {
+	// Bound parameters.
+	if p.MaxResults < 0 || p.MaxResults > 100 {
+		p.MaxResults = 0 // use default
+	}
+	if p.MaxRounds < 0 || p.MaxRounds > 10 {
+		p.MaxRounds = 0 // use default
+	}
+	switch p.Strategy {
+	case "", "multi_round", "single_shot", "react", "plan_execute":
+	default:
+		return nil, fmt.Errorf("unknown strategy: %s", p.Strategy)
+	}
+	switch p.DocOrder {
+	case "", "auto", "always":
+	default:
+		return nil, fmt.Errorf("unknown document_order: %s", p.DocOrder)
+	}
+	switch p.Space {
+	case "", "both", "primary", "secondary":
+	default:
+		return nil, fmt.Errorf("unknown embedding_space: %s", p.Space)
+	}
+	switch p.Format {
+	case "", "markdown", "plain":
+	case "json":
+		if p.JSONOutput {
+			return nil, fmt.Errorf("format json cannot be combined with json_output")
+		}
+	default:
+		return nil, fmt.Errorf("unknown format: %s", p.Format)
+	}
+
+	var opts []goreason.QueryOption
+	if p.MaxResults > 0 {
+		opts = append(opts, goreason.WithMaxResults(p.MaxResults))
+	}
+	if p.MaxRounds > 0 {
+		opts = append(opts, goreason.WithMaxRounds(p.MaxRounds))
+	}
+	if p.Strategy != "" {
+		opts = append(opts, goreason.WithStrategy(p.Strategy))
+	}
+	if p.WeightVec > 0 || p.WeightFTS > 0 || p.WeightGraph > 0 {
+		opts = append(opts, goreason.WithWeights(p.WeightVec, p.WeightFTS, p.WeightGraph))
+	}
+	if p.SkipGraph {
+		opts = append(opts, goreason.WithoutGraph())
+	}
+	if p.JSONOutput {
+		opts = append(opts, goreason.WithJSONOutput())
+	}
+	if p.IncludeImages {
+		opts = append(opts, goreason.WithIncludeImages())
+	}
+	if p.Suggest {
+		opts = append(opts, goreason.WithSuggestedQuestions())
+	}
+	if p.AnswerLang != "" {
+		opts = append(opts, goreason.WithAnswerLanguage(p.AnswerLang))
+	}
+	if p.Format != "" {
+		opts = append(opts, goreason.WithFormat(p.Format))
+	}
+	if p.DocOrder != "" {
+		opts = append(opts, goreason.WithDocumentOrder(p.DocOrder))
+	}
+	if p.Collection != "" {
+		opts = append(opts, goreason.WithCollection(p.Collection))
+	}
+	if p.Space != "" {
+		opts = append(opts, goreason.WithEmbeddingSpace(p.Space))
+	}
+	if p.Session != "" {
+		opts = append(opts, goreason.WithSession(p.Session))
+	}
+	if len(p.ValidationChecks) > 0 {
+		opts = append(opts, goreason.WithValidationChecks(p.ValidationChecks...))
+	}
+	if len(p.NumericFilters) > 0 {
+		opts = append(opts, goreason.WithNumericFilter(p.NumericFilters...))
+	}
+	return opts, nil
+}
+
 // POST /query
 func (h *handler) handleQuery(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Minute)
 	defer cancel()
 
 	var req struct {
-		Question      string  `json:"question"`
-		MaxResults    int     `json:"max_results,omitempty"`
-		MaxRounds     int     `json:"max_rounds,omitempty"`
-		WeightVec     float64 `json:"weight_vector,omitempty"`
-		WeightFTS     float64 `json:"weight_fts,omitempty"`
-		WeightGraph   float64 `json:"weight_graph,omitempty"`
-		JSONOutput    bool    `json:"json_output,omitempty"`
-		IncludeImages bool    `json:"include_images,omitempty"`
+		Question string `json:"question"`
+		queryParams
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -146,41 +319,329 @@ func (h *handler) handleQuery(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Bound parameters.
-	if req.MaxResults < 0 || req.MaxResults > 100 {
-		req.MaxResults = 0 // use default
+	if h.demo != nil {
+		req.MaxRounds = h.demo.rounds(req.MaxRounds)
+	}
+	opts, err := req.options()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
 	}
-	if req.MaxRounds < 0 || req.MaxRounds > 10 {
-		req.MaxRounds = 0 // use default
+	if x, arm := h.assignExperiment(r); arm != nil {
+		// The arm's settings are applied last so they win over the request's.
+		opts = append(opts, arm.Options()...)
+		opts = append(opts, goreason.WithExperiment(x.Name, arm.Name))
+		w.Header().Set("X-Experiment-Arm", x.Name+"/"+arm.Name)
+	}
+	var ip string
+	if h.demo != nil {
+		ip = h.demo.clientIP(r)
+		if !h.demo.reserve(ip, req.MaxRounds*demoRoundTokens, time.Now()) {
+			writeError(w, http.StatusTooManyRequests, "demo token limit reached for today")
+			return
+		}
+		req.Question = h.demo.question(req.Question)
 	}
 
-	var opts []goreason.QueryOption
-	if req.MaxResults > 0 {
-		opts = append(opts, goreason.WithMaxResults(req.MaxResults))
+	answer, err := h.engine.Query(ctx, req.Question, opts...)
+	if h.demo != nil {
+		var spent int
+		if answer != nil {
+			spent = answer.TotalTokens
+		}
+		h.demo.settle(ip, req.MaxRounds*demoRoundTokens, spent, time.Now())
 	}
-	if req.MaxRounds > 0 {
-		opts = append(opts, goreason.WithMaxRounds(req.MaxRounds))
+	h.writeAnswer(w, r, answer, err, "question", req.Question)
+}
+
+// writeAnswer writes the answer of POST /query or a template query, or
+// the error it failed with. Failures are logged with attrs, which name
+// what was asked.
+func (h *handler) writeAnswer(w http.ResponseWriter, r *http.Request, answer *goreason.Answer, err error, attrs ...any) {
+	if errors.Is(err, goreason.ErrInvalidConfig) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
 	}
-	if req.WeightVec > 0 || req.WeightFTS > 0 || req.WeightGraph > 0 {
-		opts = append(opts, goreason.WithWeights(req.WeightVec, req.WeightFTS, req.WeightGraph))
+	if errors.Is(err, goreason.ErrEmbeddingModelMismatch) {
+		writeError(w, http.StatusServiceUnavailable, "embedding model changed since ingestion; POST /reembed to rebuild vectors")
+		slog.ErrorContext(r.Context(), "query error", append(attrs, "error", err)...)
+		return
 	}
-	if req.JSONOutput {
-		opts = append(opts, goreason.WithJSONOutput())
+	if errors.Is(err, goreason.ErrQueryCancelled) {
+		// The partial trace of a query stopped by POST /queries/{id}/cancel.
+		writeJSON(w, http.StatusConflict, answer)
+		return
 	}
-	if req.IncludeImages {
-		opts = append(opts, goreason.WithIncludeImages())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "query failed")
+		slog.ErrorContext(r.Context(), "query error", append(attrs, "error", err)...)
+		return
 	}
 
-	answer, err := h.engine.Query(ctx, req.Question, opts...)
+	if h.demo != nil {
+		writeJSON(w, http.StatusOK, h.demo.watermark(answer))
+		return
+	}
+	writeJSON(w, http.StatusOK, answer)
+}
+
+// POST /retrieve
+//
+// Runs hybrid retrieval without reasoning and returns the fused chunks and
+// the search trace. The retrieval parameters of /query apply; the others
+// are ignored.
+func (h *handler) handleRetrieve(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Minute)
+	defer cancel()
+
+	var req struct {
+		Question string `json:"question"`
+		queryParams
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	if req.Question == "" {
+		writeError(w, http.StatusBadRequest, "question is required")
+		return
+	}
+
+	opts, err := req.options()
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "query failed")
-		slog.Error("query error", "question", req.Question, "error", err)
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var ip string
+	if h.demo != nil {
+		ip = h.demo.clientIP(r)
+		if !h.demo.reserve(ip, demoRetrieveTokens, time.Now()) {
+			writeError(w, http.StatusTooManyRequests, "demo token limit reached for today")
+			return
+		}
+		req.Question = h.demo.question(req.Question)
+	}
+
+	results, trace, err := h.engine.Retrieve(ctx, req.Question, opts...)
+	if h.demo != nil {
+		var spent int
+		if trace != nil {
+			spent = trace.TranslationPromptTokens + trace.TranslationCompletionTokens
+		}
+		h.demo.settle(ip, demoRetrieveTokens, spent, time.Now())
+	}
+	switch {
+	case errors.Is(err, goreason.ErrNoResults):
+		results = []store.RetrievalResult{}
+	case errors.Is(err, goreason.ErrInvalidConfig):
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, goreason.ErrEmbeddingModelMismatch):
+		writeError(w, http.StatusServiceUnavailable, "embedding model changed since ingestion; POST /reembed to rebuild vectors")
+		slog.ErrorContext(r.Context(), "retrieve error", "question", req.Question, "error", err)
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "retrieval failed")
+		slog.ErrorContext(r.Context(), "retrieve error", "question", req.Question, "error", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"results": results,
+		"trace":   trace,
+	})
+}
+
+// POST /query/compare
+//
+// Runs one question through two configurations in parallel and returns both
+// answers, their traces, and a diff. Debugging aid for retrieval tuning.
+func (h *handler) handleCompareQuery(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Minute)
+	defer cancel()
+
+	var req struct {
+		Question string      `json:"question"`
+		A        queryParams `json:"a"`
+		B        queryParams `json:"b"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	if req.Question == "" {
+		writeError(w, http.StatusBadRequest, "question is required")
 		return
 	}
 
+	optsA, err := req.A.options()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "a: "+err.Error())
+		return
+	}
+	optsB, err := req.B.options()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "b: "+err.Error())
+		return
+	}
+
+	cmp, err := h.engine.CompareQuery(ctx, req.Question, optsA, optsB)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "comparison failed")
+		slog.ErrorContext(r.Context(), "compare query error", "question", req.Question, "error", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, cmp)
+}
+
+// POST /extract
+func (h *handler) handleExtract(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Instruction string                 `json:"instruction"`
+		Schema      goreason.ExtractSchema `json:"schema"`
+		DocumentIDs []int64                `json:"document_ids,omitempty"`
+		MaxChunks   int                    `json:"max_chunks,omitempty"`
+		BatchSize   int                    `json:"batch_size,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	var opts []goreason.ExtractOption
+	if len(req.DocumentIDs) > 0 {
+		opts = append(opts, goreason.WithExtractDocuments(req.DocumentIDs...))
+	}
+	if req.MaxChunks > 0 {
+		opts = append(opts, goreason.WithExtractMaxChunks(req.MaxChunks))
+	}
+	if req.BatchSize > 0 {
+		opts = append(opts, goreason.WithExtractBatchSize(req.BatchSize))
+	}
+
+	result, err := h.engine.Extract(r.Context(), req.Instruction, req.Schema, opts...)
+	switch {
+	case errors.Is(err, goreason.ErrInvalidConfig):
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, goreason.ErrDocumentNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "extraction failed")
+		slog.ErrorContext(r.Context(), "extract error", "instruction", req.Instruction, "error", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// POST /feedback
+func (h *handler) handleFeedback(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		QueryID int64  `json:"query_id"`
+		Rating  *int   `json:"rating"`
+		Comment string `json:"comment,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	if req.QueryID <= 0 || req.Rating == nil {
+		writeError(w, http.StatusBadRequest, "query_id and rating are required")
+		return
+	}
+
+	err := h.engine.RecordFeedback(r.Context(), req.QueryID, *req.Rating, req.Comment)
+	switch {
+	case errors.Is(err, goreason.ErrInvalidConfig):
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, goreason.ErrQueryNotFound):
+		writeError(w, http.StatusNotFound, "query not found")
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "failed to record feedback")
+		slog.ErrorContext(r.Context(), "feedback error", "query_id", req.QueryID, "error", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"query_id": req.QueryID, "rating": *req.Rating})
+}
+
+// GET /queries/{id}/explanation
+// Query: format=text for the plain-text rendering.
+func (h *handler) handleExplainQuery(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid query id")
+		return
+	}
+
+	x, err := h.engine.ExplainQuery(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, goreason.ErrQueryNotFound) {
+			writeError(w, http.StatusNotFound, "query not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to explain query")
+		slog.ErrorContext(r.Context(), "explain query error", "query_id", id, "error", err)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(x.Text))
+		return
+	}
+	writeJSON(w, http.StatusOK, x)
+}
+
+// GET /queries/running
+//
+// Lists the queries in progress, oldest first, with the IDs POST
+// /queries/{id}/cancel takes.
+func (h *handler) handleRunningQueries(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.engine.RunningQueries())
+}
+
+// POST /queries/{id}/cancel
+//
+// Stops a query in progress, identified by its request ID, and returns
+// its partial answer once it has stopped.
+func (h *handler) handleCancelQuery(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	id := r.PathValue("id")
+	answer, err := h.engine.CancelQuery(ctx, id)
+	if err != nil {
+		if errors.Is(err, goreason.ErrQueryNotRunning) {
+			writeError(w, http.StatusNotFound, "query not running")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to cancel query")
+		slog.ErrorContext(r.Context(), "cancel query error", "id", id, "error", err)
+		return
+	}
 	writeJSON(w, http.StatusOK, answer)
 }
 
+// GET /experiments
+func (h *handler) handleExperiments(w http.ResponseWriter, r *http.Request) {
+	results, err := h.engine.ExperimentResults(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load experiments")
+		slog.ErrorContext(r.Context(), "experiments error", "error", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
 // POST /update
 func (h *handler) handleUpdate(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Minute)
@@ -202,7 +663,7 @@ func (h *handler) handleUpdate(w http.ResponseWriter, r *http.Request) {
 	changed, err := h.engine.Update(ctx, req.Path)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "update failed")
-		slog.Error("update error", "path", req.Path, "error", err)
+		slog.ErrorContext(r.Context(), "update error", "path", req.Path, "error", err)
 		return
 	}
 
@@ -220,7 +681,7 @@ func (h *handler) handleUpdateAll(w http.ResponseWriter, r *http.Request) {
 	results, err := h.engine.UpdateAll(ctx)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "update-all failed")
-		slog.Error("update-all error", "error", err)
+		slog.ErrorContext(r.Context(), "update-all error", "error", err)
 		return
 	}
 
@@ -229,51 +690,1003 @@ func (h *handler) handleUpdateAll(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// DELETE /documents/{id}
-func (h *handler) handleDeleteDocument(w http.ResponseWriter, r *http.Request) {
-	idStr := r.PathValue("id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid document id")
+// POST /reembed
+func (h *handler) handleReembed(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Minute)
+	defer cancel()
+
+	if err := h.engine.Reembed(ctx); err != nil {
+		writeError(w, http.StatusInternalServerError, "re-embedding failed")
+		slog.ErrorContext(r.Context(), "reembed error", "error", err)
 		return
 	}
 
-	if err := h.engine.Delete(r.Context(), id); err != nil {
-		writeError(w, http.StatusInternalServerError, "delete failed")
-		slog.Error("delete error", "document_id", id, "error", err)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "reembedded"})
+}
+
+// POST /admin/compact
+func (h *handler) handleCompact(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Minute)
+	defer cancel()
+
+	res, err := h.engine.Compact(ctx)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "compaction failed")
+		slog.ErrorContext(r.Context(), "compact error", "error", err)
 		return
 	}
 
-	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+	writeJSON(w, http.StatusOK, res)
 }
 
-// GET /documents
-func (h *handler) handleListDocuments(w http.ResponseWriter, r *http.Request) {
-	docs, err := h.engine.ListDocuments(r.Context())
+// POST /documents/{id}/resume
+func (h *handler) handleResumeIngest(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to list documents")
-		slog.Error("list documents error", "error", err)
+		writeError(w, http.StatusBadRequest, "invalid document id")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Minute)
+	defer cancel()
+
+	if err := h.engine.ResumeIngest(ctx, id); err != nil {
+		switch {
+		case errors.Is(err, goreason.ErrDocumentNotFound):
+			writeError(w, http.StatusNotFound, "document not found")
+		case errors.Is(err, goreason.ErrNoCheckpoint):
+			writeError(w, http.StatusConflict, "document has no unfinished ingest")
+		case errors.Is(err, goreason.ErrInvalidConfig):
+			// Ingested with a custom pipeline the server does not run.
+			writeError(w, http.StatusConflict, err.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, "resume failed")
+			slog.ErrorContext(r.Context(), "resume ingest error", "document_id", id, "error", err)
+		}
 		return
 	}
 
 	writeJSON(w, http.StatusOK, map[string]interface{}{
-		"documents": docs,
+		"document_id": id,
+		"status":      "ready",
 	})
 }
 
-// GET /health
-func (h *handler) handleHealth(w http.ResponseWriter, r *http.Request) {
-	writeJSON(w, http.StatusOK, map[string]string{
-		"status": "ok",
-	})
-}
+// POST /documents/{id}/reparse/compare
+// Body (optional): {"methods": ["native", "llamaparse", "vision"]}; every
+// available method when omitted.
+func (h *handler) handleReparseCompare(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid document id")
+		return
+	}
+	var req struct {
+		Methods []string `json:"methods,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
 
-func writeJSON(w http.ResponseWriter, status int, v interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(v)
-}
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Minute)
+	defer cancel()
 
-func writeError(w http.ResponseWriter, status int, msg string) {
-	writeJSON(w, status, map[string]string{"error": fmt.Sprintf("%s", msg)})
+	cmp, err := h.engine.ReparseCompare(ctx, id, req.Methods...)
+	if err != nil {
+		if errors.Is(err, goreason.ErrDocumentNotFound) {
+			writeError(w, http.StatusNotFound, "document not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "reparse comparison failed")
+		slog.ErrorContext(r.Context(), "reparse compare error", "document_id", id, "error", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, cmp)
+}
+
+// POST /documents/{id}/reparse
+// Body: {"method": "llamaparse"}
+func (h *handler) handleCommitReparse(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid document id")
+		return
+	}
+	var req struct {
+		Method string `json:"method"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	if req.Method == "" {
+		writeError(w, http.StatusBadRequest, "method is required")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Minute)
+	defer cancel()
+
+	if err := h.engine.CommitReparse(ctx, id, req.Method); err != nil {
+		if errors.Is(err, goreason.ErrDocumentNotFound) {
+			writeError(w, http.StatusNotFound, "document not found")
+			return
+		}
+		writeIngestError(w, err)
+		slog.ErrorContext(r.Context(), "commit reparse error", "document_id", id, "error", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"document_id":  id,
+		"parse_method": req.Method,
+		"status":       "ready",
+	})
+}
+
+// POST /admin/communities/rebuild
+// Body (optional): {"levels": 1|2, "skip_summaries": bool, "stream": bool}.
+// With "stream" the response is newline-delimited JSON: one progress object
+// per step, then {"result": ...} or {"error": ...}.
+func (h *handler) handleRebuildCommunities(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Levels        int  `json:"levels,omitempty"`
+		SkipSummaries bool `json:"skip_summaries,omitempty"`
+		Resummarize   bool `json:"resummarize,omitempty"`
+		Stream        bool `json:"stream,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	if req.Levels < 0 || req.Levels > 2 {
+		writeError(w, http.StatusBadRequest, "levels must be 1 or 2")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Minute)
+	defer cancel()
+
+	var opts []goreason.CommunityOption
+	if req.Levels > 0 {
+		opts = append(opts, goreason.WithCommunityLevels(req.Levels))
+	}
+	if req.SkipSummaries {
+		opts = append(opts, goreason.WithoutCommunitySummaries())
+	}
+	if req.Resummarize {
+		opts = append(opts, goreason.WithAllCommunitySummaries())
+	}
+
+	if !req.Stream {
+		res, err := h.engine.RebuildCommunities(ctx, opts...)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "community rebuild failed")
+			slog.ErrorContext(r.Context(), "community rebuild error", "error", err)
+			return
+		}
+		writeJSON(w, http.StatusOK, res)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	enc := json.NewEncoder(w)
+	emit := func(v interface{}) {
+		enc.Encode(v)
+		rc.Flush()
+	}
+	opts = append(opts, goreason.WithCommunityProgress(func(p goreason.CommunityProgress) { emit(p) }))
+
+	res, err := h.engine.RebuildCommunities(ctx, opts...)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "community rebuild error", "error", err)
+		emit(map[string]string{"error": "community rebuild failed"})
+		return
+	}
+	emit(map[string]interface{}{"result": res})
+}
+
+// POST /admin/communities/refresh
+func (h *handler) handleRefreshCommunities(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Minute)
+	defer cancel()
+
+	res, err := h.engine.RefreshCommunitySummaries(ctx)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "community refresh failed")
+		slog.ErrorContext(r.Context(), "community refresh error", "error", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// POST /admin/contradictions/detect
+func (h *handler) handleDetectContradictions(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Minute)
+	defer cancel()
+
+	res, err := h.engine.DetectContradictions(ctx)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "contradiction detection failed")
+		slog.ErrorContext(r.Context(), "contradiction detection error", "error", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// GET /contradictions
+func (h *handler) handleListContradictions(w http.ResponseWriter, r *http.Request) {
+	list, err := h.engine.Contradictions(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load contradictions")
+		slog.ErrorContext(r.Context(), "list contradictions error", "error", err)
+		return
+	}
+	if list == nil {
+		list = []store.Contradiction{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"contradictions": list,
+	})
+}
+
+// POST /admin/graph/import
+// Body: a goreason.GraphImport, optionally with its entities and
+// relationships as CSV text in "entities_csv" and "relationships_csv".
+func (h *handler) handleImportGraph(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		goreason.GraphImport
+		EntitiesCSV      string `json:"entities_csv"`
+		RelationshipsCSV string `json:"relationships_csv"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	g := req.GraphImport
+	if req.EntitiesCSV != "" || req.RelationshipsCSV != "" {
+		var ents, rels io.Reader
+		if req.EntitiesCSV != "" {
+			ents = strings.NewReader(req.EntitiesCSV)
+		}
+		if req.RelationshipsCSV != "" {
+			rels = strings.NewReader(req.RelationshipsCSV)
+		}
+		parsed, err := goreason.ReadGraphCSV(ents, rels)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		g.Entities = append(g.Entities, parsed.Entities...)
+		g.Relationships = append(g.Relationships, parsed.Relationships...)
+	}
+
+	res, err := h.engine.ImportGraph(r.Context(), g)
+	if err != nil {
+		if errors.Is(err, goreason.ErrInvalidGraphImport) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "graph import failed")
+		slog.ErrorContext(r.Context(), "graph import error", "source", g.Source, "error", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// POST /admin/index/import
+// Body: a goreason.IndexImport, or its source, embedding_model, and
+// collection with the format and server-side path of an export (and
+// metadata_path for FAISS).
+func (h *handler) handleImportIndex(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		goreason.IndexImport
+		Format       string `json:"format"`
+		Path         string `json:"path"`
+		MetadataPath string `json:"metadata_path"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	imp := req.IndexImport
+	if req.Path != "" {
+		data, err := os.Open(req.Path)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "path must be an existing file")
+			return
+		}
+		defer data.Close()
+		var metadata io.Reader
+		if req.MetadataPath != "" {
+			f, err := os.Open(req.MetadataPath)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "metadata_path must be an existing file")
+				return
+			}
+			defer f.Close()
+			metadata = f
+		}
+		chunks, err := goreason.ReadIndexExport(req.Format, data, metadata)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		imp.Chunks = append(imp.Chunks, chunks...)
+	}
+
+	res, err := h.engine.ImportIndex(r.Context(), imp)
+	if err != nil {
+		if errors.Is(err, goreason.ErrInvalidIndexImport) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "index import failed")
+		slog.ErrorContext(r.Context(), "index import error", "source", imp.Source, "error", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// DELETE /documents/{id}
+func (h *handler) handleDeleteDocument(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid document id")
+		return
+	}
+
+	if err := h.engine.Delete(r.Context(), id); err != nil {
+		writeError(w, http.StatusInternalServerError, "delete failed")
+		slog.ErrorContext(r.Context(), "delete error", "document_id", id, "error", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// DELETE /sessions/{id}
+func (h *handler) handleDeleteSession(w http.ResponseWriter, r *http.Request) {
+	session := r.PathValue("id")
+	n, err := h.engine.DeleteSession(r.Context(), session)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "delete failed")
+		slog.ErrorContext(r.Context(), "delete session error", "session", session, "error", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": "deleted", "documents": n})
+}
+
+// GET /documents
+func (h *handler) handleListDocuments(w http.ResponseWriter, r *http.Request) {
+	docs, err := h.engine.ListDocuments(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list documents")
+		slog.ErrorContext(r.Context(), "list documents error", "error", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"documents": docs,
+	})
+}
+
+// GET /documents/{id}
+func (h *handler) handleGetDocument(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid document id")
+		return
+	}
+
+	doc, err := h.engine.Document(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, goreason.ErrDocumentNotFound) {
+			writeError(w, http.StatusNotFound, "document not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load document")
+		slog.ErrorContext(r.Context(), "get document error", "document_id", id, "error", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, doc)
+}
+
+// GET /documents/{id}/summary
+func (h *handler) handleDocumentSummary(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid document id")
+		return
+	}
+
+	summary, err := h.engine.DocumentSummary(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, goreason.ErrDocumentNotFound) {
+			writeError(w, http.StatusNotFound, "document not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load summary")
+		slog.ErrorContext(r.Context(), "document summary error", "document_id", id, "error", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, summary)
+}
+
+// POST /documents/{id}/summarize
+// Body (optional): {"language": "Spanish", "key_points": 5, "focus": "..."}
+func (h *handler) handleSummarizeDocument(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid document id")
+		return
+	}
+	var req struct {
+		Language  string `json:"language,omitempty"`
+		KeyPoints int    `json:"key_points,omitempty"`
+		Focus     string `json:"focus,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	var opts []goreason.SummarizeOption
+	if req.Language != "" {
+		opts = append(opts, goreason.WithSummaryLanguage(req.Language))
+	}
+	if req.KeyPoints > 0 {
+		opts = append(opts, goreason.WithSummaryKeyPoints(req.KeyPoints))
+	}
+	if req.Focus != "" {
+		opts = append(opts, goreason.WithSummaryFocus(req.Focus))
+	}
+
+	result, err := h.engine.SummarizeDocument(r.Context(), id, opts...)
+	if err != nil {
+		if errors.Is(err, goreason.ErrDocumentNotFound) {
+			writeError(w, http.StatusNotFound, "document not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "summarization failed")
+		slog.ErrorContext(r.Context(), "summarize document error", "document_id", id, "error", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// GET /documents/{id}/chunks
+// Query parameters: offset (default 0), limit (default 50, at most 1000).
+func (h *handler) handleDocumentChunks(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid document id")
+		return
+	}
+	q := r.URL.Query()
+	var offset, limit int
+	if v := q.Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			writeError(w, http.StatusBadRequest, "offset must be a non-negative integer")
+			return
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > 1000 {
+			writeError(w, http.StatusBadRequest, "limit must be between 1 and 1000")
+			return
+		}
+	}
+
+	chunks, err := h.engine.DocumentChunks(r.Context(), id, offset, limit)
+	if err != nil {
+		if errors.Is(err, goreason.ErrDocumentNotFound) {
+			writeError(w, http.StatusNotFound, "document not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load chunks")
+		slog.ErrorContext(r.Context(), "document chunks error", "document_id", id, "error", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, chunks)
+}
+
+// GET /chunks/{id}
+func (h *handler) handleGetChunk(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid chunk id")
+		return
+	}
+
+	chunk, err := h.engine.Chunk(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, goreason.ErrChunkNotFound) {
+			writeError(w, http.StatusNotFound, "chunk not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load chunk")
+		slog.ErrorContext(r.Context(), "get chunk error", "chunk_id", id, "error", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, chunk)
+}
+
+// GET /chunks/{id}/images/{n}
+//
+// Streams the stored image bytes with their MIME type.
+func (h *handler) handleGetChunkImage(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid chunk id")
+		return
+	}
+	n, err := strconv.Atoi(r.PathValue("n"))
+	if err != nil || n < 0 {
+		writeError(w, http.StatusBadRequest, "invalid image index")
+		return
+	}
+
+	img, err := h.engine.ChunkImage(r.Context(), id, n)
+	if err != nil {
+		switch {
+		case errors.Is(err, goreason.ErrChunkNotFound):
+			writeError(w, http.StatusNotFound, "chunk not found")
+		case errors.Is(err, goreason.ErrImageNotFound):
+			writeError(w, http.StatusNotFound, "image not found")
+		default:
+			writeError(w, http.StatusInternalServerError, "failed to load image")
+			slog.ErrorContext(r.Context(), "get chunk image error", "chunk_id", id, "image", n, "error", err)
+		}
+		return
+	}
+
+	mimeType := img.MIMEType
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", mimeType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(img.Data)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(img.Data)
+}
+
+// POST /chunks/{id}/annotations
+func (h *handler) handleAnnotateChunk(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid chunk id")
+		return
+	}
+	var req struct {
+		Kind   string  `json:"kind"`
+		Text   string  `json:"text,omitempty"`
+		Boost  float64 `json:"boost,omitempty"`
+		Author string  `json:"author,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	annID, err := h.engine.AnnotateChunk(r.Context(), store.ChunkAnnotation{
+		ChunkID: id,
+		Kind:    req.Kind,
+		Text:    req.Text,
+		Boost:   req.Boost,
+		Author:  req.Author,
+	})
+	switch {
+	case errors.Is(err, goreason.ErrInvalidConfig):
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, goreason.ErrChunkNotFound):
+		writeError(w, http.StatusNotFound, "chunk not found")
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "failed to annotate chunk")
+		slog.ErrorContext(r.Context(), "annotate chunk error", "chunk_id", id, "error", err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"id": annID, "chunk_id": id, "kind": req.Kind})
+}
+
+// GET /annotations
+// Query parameters: chunk_id.
+func (h *handler) handleListAnnotations(w http.ResponseWriter, r *http.Request) {
+	var chunkID int64
+	if v := r.URL.Query().Get("chunk_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid chunk_id")
+			return
+		}
+		chunkID = id
+	}
+
+	list, err := h.engine.ChunkAnnotations(r.Context(), chunkID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list annotations")
+		slog.ErrorContext(r.Context(), "list annotations error", "error", err)
+		return
+	}
+	if list == nil {
+		list = []store.ChunkAnnotation{}
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// DELETE /annotations/{id}
+func (h *handler) handleDeleteAnnotation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid annotation id")
+		return
+	}
+
+	if err := h.engine.DeleteChunkAnnotation(r.Context(), id); err != nil {
+		if errors.Is(err, goreason.ErrAnnotationNotFound) {
+			writeError(w, http.StatusNotFound, "annotation not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to delete annotation")
+		slog.ErrorContext(r.Context(), "delete annotation error", "annotation_id", id, "error", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// POST /templates
+//
+// Stores a named question with {parameter} placeholders, replacing the
+// template of the same name.
+func (h *handler) handleSaveTemplate(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name        string `json:"name"`
+		Question    string `json:"question"`
+		Description string `json:"description,omitempty"`
+		Author      string `json:"author,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	err := h.engine.SaveQueryTemplate(r.Context(), goreason.QueryTemplate{
+		Name:        req.Name,
+		Question:    req.Question,
+		Description: req.Description,
+		Author:      req.Author,
+	})
+	switch {
+	case errors.Is(err, goreason.ErrInvalidConfig):
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "failed to save template")
+		slog.ErrorContext(r.Context(), "save template error", "name", req.Name, "error", err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"name":       req.Name,
+		"parameters": goreason.TemplateParameters(req.Question),
+	})
+}
+
+// GET /templates
+func (h *handler) handleListTemplates(w http.ResponseWriter, r *http.Request) {
+	list, err := h.engine.QueryTemplates(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list templates")
+		slog.ErrorContext(r.Context(), "list templates error", "error", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// DELETE /templates/{name}
+func (h *handler) handleDeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if err := h.engine.DeleteQueryTemplate(r.Context(), name); err != nil {
+		if errors.Is(err, goreason.ErrTemplateNotFound) {
+			writeError(w, http.StatusNotFound, "template not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to delete template")
+		slog.ErrorContext(r.Context(), "delete template error", "name", name, "error", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// POST /templates/{name}/query
+//
+// Fills the template's placeholders from "parameters" and answers the
+// question as POST /query does, with the same query parameters.
+func (h *handler) handleTemplateQuery(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Minute)
+	defer cancel()
+
+	name := r.PathValue("name")
+	var req struct {
+		Parameters map[string]string `json:"parameters"`
+		queryParams
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	opts, err := req.options()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if x, arm := h.assignExperiment(r); arm != nil {
+		opts = append(opts, arm.Options()...)
+		opts = append(opts, goreason.WithExperiment(x.Name, arm.Name))
+		w.Header().Set("X-Experiment-Arm", x.Name+"/"+arm.Name)
+	}
+
+	answer, err := h.engine.QueryTemplate(ctx, name, req.Parameters, opts...)
+	if errors.Is(err, goreason.ErrTemplateNotFound) {
+		writeError(w, http.StatusNotFound, "template not found")
+		return
+	}
+	h.writeAnswer(w, r, answer, err, "template", name)
+}
+
+// GET /audit
+// Query parameters: operation, actor, document_id, since (RFC 3339), limit.
+func (h *handler) handleAudit(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := goreason.AuditFilter{
+		Operation: q.Get("operation"),
+		Actor:     q.Get("actor"),
+	}
+	if v := q.Get("document_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid document_id")
+			return
+		}
+		f.DocumentID = id
+	}
+	if v := q.Get("since"); v != "" {
+		since, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid since: expected RFC 3339 timestamp")
+			return
+		}
+		f.Since = since
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > 1000 {
+			writeError(w, http.StatusBadRequest, "limit must be between 1 and 1000")
+			return
+		}
+		f.Limit = limit
+	}
+
+	entries, err := h.engine.AuditLog(r.Context(), f)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load audit log")
+		slog.ErrorContext(r.Context(), "audit log error", "error", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"entries": entries,
+	})
+}
+
+// GET /entities/stats
+// Query parameters: limit (default 50).
+func (h *handler) handleEntityStats(w http.ResponseWriter, r *http.Request) {
+	var limit int
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 1000 {
+			writeError(w, http.StatusBadRequest, "limit must be between 1 and 1000")
+			return
+		}
+		limit = n
+	}
+
+	stats, err := h.engine.EntityStats(r.Context(), limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load entity stats")
+		slog.ErrorContext(r.Context(), "entity stats error", "error", err)
+		return
+	}
+	if stats == nil {
+		stats = []store.EntityStat{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"entities": stats,
+	})
+}
+
+// GET /glossary
+func (h *handler) handleGlossary(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var docID int64
+	if v := q.Get("document_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid document_id")
+			return
+		}
+		docID = id
+	}
+
+	entries, err := h.engine.Glossary(r.Context(), docID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load glossary")
+		slog.ErrorContext(r.Context(), "glossary error", "error", err)
+		return
+	}
+
+	// Optional case-insensitive filter on abbreviation or definition.
+	if term := strings.ToLower(q.Get("q")); term != "" {
+		filtered := entries[:0]
+		for _, g := range entries {
+			if strings.Contains(strings.ToLower(g.Abbreviation), term) ||
+				strings.Contains(strings.ToLower(g.Definition), term) {
+				filtered = append(filtered, g)
+			}
+		}
+		entries = filtered
+	}
+	if entries == nil {
+		entries = []store.GlossaryEntry{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"entries": entries,
+	})
+}
+
+// GET /facts
+func (h *handler) handleKeyFacts(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := store.KeyFactFilter{
+		Schema: q.Get("schema"),
+		Field:  q.Get("field"),
+		Query:  q.Get("q"),
+		Limit:  100,
+	}
+	if v := q.Get("document_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid document_id")
+			return
+		}
+		filter.DocumentID = id
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 1000 {
+			writeError(w, http.StatusBadRequest, "limit must be between 1 and 1000")
+			return
+		}
+		filter.Limit = n
+	}
+
+	facts, err := h.engine.KeyFacts(r.Context(), filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load key facts")
+		slog.ErrorContext(r.Context(), "key facts error", "error", err)
+		return
+	}
+	if facts == nil {
+		facts = []store.KeyFact{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"facts": facts,
+	})
+}
+
+// GET /admin/loglevel
+func (h *handler) handleGetLogLevel(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.logLevels.snapshot())
+}
+
+// PUT /admin/loglevel
+//
+// Changes the levels until the next restart. A "level" replaces the
+// minimum level; each component in "components" gets the level given, or
+// loses its override when the level is "". Components left out keep
+// theirs.
+func (h *handler) handleSetLogLevel(w http.ResponseWriter, r *http.Request) {
+	var req logLevelsJSON
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	cur := h.logLevels.snapshot()
+	if req.Level != "" {
+		cur.Level = req.Level
+	}
+	for name, lv := range req.Components {
+		if lv == "" {
+			delete(cur.Components, strings.ToLower(name))
+		} else {
+			cur.Components[strings.ToLower(name)] = lv
+		}
+	}
+	if err := h.logLevels.set(cur.Level, cur.Components); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	slog.InfoContext(r.Context(), "log levels changed", "level", cur.Level, "components", cur.Components)
+	writeJSON(w, http.StatusOK, h.logLevels.snapshot())
+}
+
+// GET /health
+func (h *handler) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":            "ok",
+		"ingest_queue":      h.engine.IngestQueueStats(),
+		"query_cache":       h.engine.QueryCacheStats(),
+		"translation_cache": h.engine.TranslationCacheStats(),
+		"query_workers":     h.engine.QueryWorkerStats(),
+		"graph_extraction":  h.engine.GraphExtractionStats(),
+		"write_queue":       h.engine.WriteQueueStats(),
+		"replication":       h.engine.ReplicationStatus(),
+	})
+}
+
+// writeIngestError maps an Ingest error to a response: 429 when the
+// engine's queue is at capacity, 503 on embedding model drift, 500 otherwise.
+func writeIngestError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, goreason.ErrIngestQueueFull):
+		w.Header().Set("Retry-After", "30")
+		writeError(w, http.StatusTooManyRequests, "ingestion queue full, retry later")
+	case errors.Is(err, goreason.ErrEmbeddingModelMismatch):
+		writeError(w, http.StatusServiceUnavailable, "embedding model changed since ingestion; POST /reembed to rebuild vectors")
+	case errors.Is(err, goreason.ErrParseMethodUnavailable),
+		errors.Is(err, goreason.ErrInvalidConfig),
+		errors.Is(err, goreason.ErrEmptySelection),
+		errors.Is(err, goreason.ErrInvalidArchive):
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, "ingestion failed")
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	body := map[string]string{"error": fmt.Sprintf("%s", msg)}
+	if id := w.Header().Get(requestid.Header); id != "" {
+		body["request_id"] = id
+	}
+	writeJSON(w, status, body)
 }
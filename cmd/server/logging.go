@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bbiangul/go-reason"
+	"github.com/bbiangul/go-reason/requestid"
+)
+
+// modulePath prefixes the function names of the module's packages, from
+// which a record's component is derived.
+const modulePath = "github.com/bbiangul/go-reason"
+
+// setupLogging installs the default logger described by cfg (nil logs to
+// stdout at info) and returns its levels, for /admin/loglevel to adjust.
+// The returned function closes the log files.
+func setupLogging(cfg *goreason.LogConfig) (*logLevels, func(), error) {
+	if cfg == nil {
+		cfg = &goreason.LogConfig{}
+	}
+	levels := &logLevels{}
+	if err := levels.set(cfg.Level, cfg.Components); err != nil {
+		return nil, nil, err
+	}
+
+	outputs := cfg.Outputs
+	if len(outputs) == 0 {
+		outputs = []goreason.LogOutput{{Type: "stdout"}}
+	}
+	var writers []io.Writer
+	var files []*rotatingFile
+	closeFiles := func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}
+	for _, o := range outputs {
+		switch o.Type {
+		case "stdout":
+			writers = append(writers, os.Stdout)
+		case "stderr":
+			writers = append(writers, os.Stderr)
+		case "file":
+			f, err := openRotatingFile(o)
+			if err != nil {
+				closeFiles()
+				return nil, nil, err
+			}
+			files = append(files, f)
+			writers = append(writers, f)
+		default:
+			closeFiles()
+			return nil, nil, fmt.Errorf("log output type %q: want stdout, stderr, or file", o.Type)
+		}
+	}
+
+	// Structured JSON logging, filtered by component level and tagged with
+	// the request ID of the request that produced each line.
+	out := io.MultiWriter(writers...)
+	jh := slog.NewJSONHandler(out, &slog.HandlerOptions{Level: &levels.min})
+	slog.SetDefault(slog.New(requestid.NewHandler(&levelHandler{Handler: jh, levels: levels})))
+	return levels, closeFiles, nil
+}
+
+// logLevels holds the minimum log level and the per-component overrides.
+// Both can change while the server runs.
+type logLevels struct {
+	base       slog.LevelVar
+	components atomic.Pointer[map[string]slog.Level]
+	min        slog.LevelVar // lowest of base and overrides, the handler's level
+}
+
+// set replaces the levels. An empty level is "info".
+func (l *logLevels) set(level string, components map[string]string) error {
+	base, err := parseLevel(level)
+	if err != nil {
+		return err
+	}
+	comps := make(map[string]slog.Level, len(components))
+	lowest := base
+	for name, lv := range components {
+		c, err := parseLevel(lv)
+		if err != nil {
+			return fmt.Errorf("component %q: %w", name, err)
+		}
+		comps[strings.ToLower(name)] = c
+		lowest = min(lowest, c)
+	}
+	l.base.Set(base)
+	l.components.Store(&comps)
+	l.min.Set(lowest)
+	return nil
+}
+
+// snapshot returns the levels as names, the shape /admin/loglevel uses.
+func (l *logLevels) snapshot() logLevelsJSON {
+	out := logLevelsJSON{Level: strings.ToLower(l.base.Level().String()), Components: map[string]string{}}
+	for name, lv := range *l.components.Load() {
+		out.Components[name] = strings.ToLower(lv.String())
+	}
+	return out
+}
+
+// logLevelsJSON is the body of GET and PUT /admin/loglevel.
+type logLevelsJSON struct {
+	Level      string            `json:"level"`
+	Components map[string]string `json:"components"`
+}
+
+func parseLevel(s string) (slog.Level, error) {
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+	var lv slog.Level
+	if err := lv.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("log level %q: want debug, info, warn, or error", s)
+	}
+	return lv, nil
+}
+
+// levelHandler drops records below the level of the component that logged
+// them. The component is the package of the logging function, found from
+// the record's program counter. The wrapped handler's level is the lowest
+// of them, so records below every level are not built at all.
+type levelHandler struct {
+	slog.Handler
+	levels *logLevels
+}
+
+func (h *levelHandler) Handle(ctx context.Context, r slog.Record) error {
+	level := h.levels.base.Level()
+	if comps := *h.levels.components.Load(); len(comps) > 0 {
+		if lv, ok := comps[component(r.PC)]; ok {
+			level = lv
+		}
+	}
+	if r.Level < level {
+		return nil
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelHandler{Handler: h.Handler.WithAttrs(attrs), levels: h.levels}
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{Handler: h.Handler.WithGroup(name), levels: h.levels}
+}
+
+// components caches component by program counter.
+var components sync.Map
+
+// component names the package that logged at pc: "engine" for the root
+// package, "server" for this command, the package name for the others,
+// and "" outside the module.
+func component(pc uintptr) string {
+	if c, ok := components.Load(pc); ok {
+		return c.(string)
+	}
+	// When the slog call was inlined, pc's innermost function is slog's;
+	// the logging function is the first frame outside it.
+	var name string
+	frames := runtime.CallersFrames([]uintptr{pc})
+	for {
+		frame, more := frames.Next()
+		name = frame.Function
+		if !strings.HasPrefix(name, "log/slog.") || !more {
+			break
+		}
+	}
+	var c string
+	switch {
+	case strings.HasPrefix(name, "main."):
+		c = "server"
+	case strings.HasPrefix(name, modulePath+"."):
+		c = "engine"
+	case strings.HasPrefix(name, modulePath+"/"):
+		pkg := strings.TrimPrefix(name, modulePath+"/")
+		if i := strings.IndexByte(pkg, '.'); i >= 0 {
+			pkg = pkg[:i]
+		}
+		c = pkg[strings.LastIndexByte(pkg, '/')+1:]
+	}
+	components.Store(pc, c)
+	return c
+}
+
+// rotatingFile is a log file that is renamed aside with a timestamp when
+// a write would grow it past its size limit. Rotated files past the age
+// and count limits are deleted.
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+
+	mu    sync.Mutex
+	f     *os.File
+	size  int64
+	aside bool // f was renamed aside but no new file could be opened
+}
+
+// openLogFile opens a log file for appending; a var so tests can make the
+// reopen after a rotation fail.
+var openLogFile = func(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+}
+
+func openRotatingFile(o goreason.LogOutput) (*rotatingFile, error) {
+	if o.Path == "" {
+		return nil, fmt.Errorf("file log output needs a path")
+	}
+	maxMB := o.MaxSizeMB
+	if maxMB <= 0 {
+		maxMB = 100
+	}
+	r := &rotatingFile{
+		path:       o.Path,
+		maxSize:    int64(maxMB) << 20,
+		maxAge:     time.Duration(o.MaxAgeDays) * 24 * time.Hour,
+		maxBackups: o.MaxBackups,
+	}
+	if err := os.MkdirAll(filepath.Dir(o.Path), 0o755); err != nil {
+		return nil, fmt.Errorf("creating log directory: %w", err)
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := openLogFile(r.path)
+	if err != nil {
+		return fmt.Errorf("opening log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("opening log file: %w", err)
+	}
+	r.f, r.size = f, info.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		// When rotating fails the line goes to the current file, and the
+		// next write tries again.
+		_ = r.rotate()
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate moves the current file aside as <name>-<timestamp><ext>, starts a
+// new one, and prunes old rotated files. The current file stays open until
+// the new one is, so a failure leaves logging going to it.
+func (r *rotatingFile) rotate() error {
+	if !r.aside {
+		ext := filepath.Ext(r.path)
+		stamp := time.Now().UTC().Format("2006-01-02T15-04-05.000000000")
+		if err := os.Rename(r.path, strings.TrimSuffix(r.path, ext)+"-"+stamp+ext); err != nil {
+			return fmt.Errorf("rotating log file: %w", err)
+		}
+		r.aside = true
+	}
+	old := r.f
+	if err := r.open(); err != nil {
+		return err
+	}
+	r.aside = false
+	old.Close()
+	r.prune()
+	return nil
+}
+
+// prune deletes rotated files older than maxAge and, newest first, those
+// past maxBackups. Failures only leave files behind.
+func (r *rotatingFile) prune() {
+	if r.maxAge <= 0 && r.maxBackups <= 0 {
+		return
+	}
+	ext := filepath.Ext(r.path)
+	backups, _ := filepath.Glob(strings.TrimSuffix(r.path, ext) + "-*" + ext)
+	// Timestamps sort lexically, so the newest come last.
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+	for i, b := range backups {
+		old := false
+		if r.maxAge > 0 {
+			if info, err := os.Stat(b); err == nil && time.Since(info.ModTime()) > r.maxAge {
+				old = true
+			}
+		}
+		if old || r.maxBackups > 0 && i >= r.maxBackups {
+			os.Remove(b)
+		}
+	}
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Close()
+}
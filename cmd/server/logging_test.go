@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bbiangul/go-reason"
+)
+
+func TestLogLevels(t *testing.T) {
+	levels := &logLevels{}
+	if err := levels.set("warn", map[string]string{"Server": "debug", "retrieval": "error"}); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if err := levels.set("loud", nil); err == nil {
+		t.Error("set accepted an unknown level")
+	}
+	if got := levels.snapshot(); got.Level != "warn" || got.Components["server"] != "debug" || got.Components["retrieval"] != "error" {
+		t.Errorf("snapshot = %+v", got)
+	}
+
+	var buf bytes.Buffer
+	jh := slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: &levels.min})
+	logger := slog.New(&levelHandler{Handler: jh, levels: levels})
+	ctx := context.Background()
+
+	// This package is the "server" component, logged at debug.
+	logger.Debug("server debug")
+	if !strings.Contains(buf.String(), "server debug") {
+		t.Errorf("debug record of the server component dropped: %q", buf.String())
+	}
+
+	// Without the override the base level applies, and the handler's own
+	// level follows the change.
+	if err := levels.set("warn", nil); err != nil {
+		t.Fatal(err)
+	}
+	if logger.Enabled(ctx, slog.LevelInfo) {
+		t.Error("info enabled at warn")
+	}
+	buf.Reset()
+	logger.Info("server info")
+	logger.Warn("server warn")
+	if out := buf.String(); strings.Contains(out, "server info") || !strings.Contains(out, "server warn") {
+		t.Errorf("records at warn = %q", out)
+	}
+
+	if c := component(0); c != "" {
+		t.Errorf("component(0) = %q", c)
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "logs", "server.log")
+	r, err := openRotatingFile(goreason.LogOutput{Type: "file", Path: path, MaxBackups: 2})
+	if err != nil {
+		t.Fatalf("openRotatingFile: %v", err)
+	}
+	defer r.Close()
+	r.maxSize = 10
+
+	for _, line := range []string{"one\n", "two two\n", "three\n", "four four\n", "five\n"} {
+		if _, err := r.Write([]byte(line)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	backups, _ := filepath.Glob(filepath.Join(dir, "logs", "server-*.log"))
+	if len(backups) != 2 {
+		t.Errorf("%d rotated files, want 2 kept: %v", len(backups), backups)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "five\n" {
+		t.Errorf("current file = %q, want the last line", data)
+	}
+}
+
+func TestRotatingFileReopenFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.log")
+	r, err := openRotatingFile(goreason.LogOutput{Type: "file", Path: path})
+	if err != nil {
+		t.Fatalf("openRotatingFile: %v", err)
+	}
+	defer r.Close()
+	r.maxSize = 10
+
+	open := openLogFile
+	openLogFile = func(string) (*os.File, error) { return nil, errors.New("disk full") }
+	t.Cleanup(func() { openLogFile = open })
+
+	// The lines go to the file moved aside rather than being lost.
+	for _, line := range []string{"first line\n", "second line\n", "third line\n"} {
+		if _, err := r.Write([]byte(line)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	backups, _ := filepath.Glob(filepath.Join(filepath.Dir(path), "server-*.log"))
+	if len(backups) != 1 {
+		t.Fatalf("rotated files = %v, want 1", backups)
+	}
+	if data, _ := os.ReadFile(backups[0]); string(data) != "first line\nsecond line\nthird line\n" {
+		t.Errorf("rotated file = %q", data)
+	}
+
+	// Once a new file can be opened, rotation resumes without renaming
+	// again.
+	openLogFile = open
+	if _, err := r.Write([]byte("fourth line\n")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "fourth line\n" {
+		t.Errorf("new file = %q", data)
+	}
+	if again, _ := filepath.Glob(filepath.Join(filepath.Dir(path), "server-*.log")); len(again) != 1 {
+		t.Errorf("rotated files after recovery = %v", again)
+	}
+}
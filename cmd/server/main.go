@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"flag"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
@@ -12,17 +13,22 @@ import (
 	"time"
 
 	"github.com/bbiangul/go-reason"
+	"github.com/bbiangul/go-reason/requestid"
 )
 
 func main() {
 	configPath := flag.String("config", "", "Path to config file (JSON)")
 	addr := flag.String("addr", ":8080", "Listen address")
+	compact := flag.Bool("compact", false, "Compact the database, print the report, and exit")
+	validate := flag.Bool("validate", false, "Check the config, database, and providers, print a diagnostics report, and exit")
+	demoMode := flag.Bool("demo", false, "Serve a public demo: read-only, rate limited, ingestion disabled (the config's demo section, or its defaults)")
 	flag.Parse()
 
-	// Structured JSON logging.
-	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+	// Until the config's logging is set up: JSON on stdout, tagged with
+	// the request ID of the request that produced each line.
+	slog.SetDefault(slog.New(requestid.NewHandler(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 		Level: slog.LevelInfo,
-	})))
+	}))))
 
 	cfg := goreason.DefaultConfig()
 	if *configPath != "" {
@@ -67,6 +73,13 @@ func main() {
 	if v := os.Getenv("GOREASON_EMBED_PROVIDER"); v != "" {
 		cfg.Embedding.Provider = v
 	}
+	if v := os.Getenv("GOREASON_ENCRYPTION_KEY"); v != "" {
+		// Key for documents outside any collection, kept out of the config file.
+		if cfg.EncryptionKeys == nil {
+			cfg.EncryptionKeys = make(map[string]string)
+		}
+		cfg.EncryptionKeys[""] = v
+	}
 
 	// Fallback: check well-known provider env vars for API keys.
 	if cfg.Chat.APIKey == "" {
@@ -90,33 +103,131 @@ func main() {
 		}
 	}
 
+	if v := os.Getenv("GOREASON_LOG_LEVEL"); v != "" {
+		if cfg.Logging == nil {
+			cfg.Logging = &goreason.LogConfig{}
+		}
+		cfg.Logging.Level = v
+	}
+	logLevels, closeLogs, err := setupLogging(cfg.Logging)
+	if err != nil {
+		slog.Error("configuring logging", "error", err)
+		os.Exit(1)
+	}
+	defer closeLogs()
+
+	// A public demo serves a prepared corpus and never writes to it.
+	if *demoMode && cfg.Demo == nil {
+		cfg.Demo = &goreason.DemoConfig{}
+	}
+	var d *demo
+	if cfg.Demo != nil {
+		if d, err = newDemo(*cfg.Demo); err != nil {
+			slog.Error("configuring demo mode", "error", err)
+			os.Exit(1)
+		}
+		cfg.ReadOnly = true
+	}
+
 	apiKey := os.Getenv("GOREASON_API_KEY")
 	corsOrigins := os.Getenv("GOREASON_CORS_ORIGINS")
 
+	if *validate {
+		report := goreason.ValidateConfig(context.Background(), cfg)
+		fmt.Print(report.String())
+		if !report.OK {
+			os.Exit(1)
+		}
+		return
+	}
+
 	engine, err := goreason.New(cfg)
 	if err != nil {
-		slog.Error("creating engine", "error", err)
+		slog.Error("creating engine", "error", err, "hint", "run with -validate for a diagnostics report")
 		os.Exit(1)
 	}
 	defer engine.Close()
 
-	h := newHandler(engine)
+	if *compact {
+		res, err := engine.Compact(context.Background())
+		if err != nil {
+			slog.Error("compaction failed", "error", err)
+			engine.Close()
+			os.Exit(1)
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(res)
+		return
+	}
+
+	h := newHandler(engine, cfg.Experiments)
+	h.logLevels = logLevels
+	h.demo = d
 	mux := http.NewServeMux()
 
-	mux.HandleFunc("POST /ingest", h.handleIngest)
+	mux.HandleFunc("POST /ingest", writeGuard(engine, h.handleIngest))
 	mux.HandleFunc("POST /query", h.handleQuery)
-	mux.HandleFunc("POST /update", h.handleUpdate)
-	mux.HandleFunc("POST /update-all", h.handleUpdateAll)
-	mux.HandleFunc("DELETE /documents/{id}", h.handleDeleteDocument)
+	mux.HandleFunc("POST /retrieve", h.handleRetrieve)
+	mux.HandleFunc("POST /query/compare", h.handleCompareQuery)
+	mux.HandleFunc("POST /extract", h.handleExtract)
+	mux.HandleFunc("POST /feedback", writeGuard(engine, h.handleFeedback))
+	mux.HandleFunc("GET /queries/{id}/explanation", h.handleExplainQuery)
+	mux.HandleFunc("GET /queries/running", h.handleRunningQueries)
+	mux.HandleFunc("POST /queries/{id}/cancel", h.handleCancelQuery)
+	mux.HandleFunc("GET /experiments", h.handleExperiments)
+	mux.HandleFunc("POST /update", writeGuard(engine, h.handleUpdate))
+	mux.HandleFunc("POST /update-all", writeGuard(engine, h.handleUpdateAll))
+	mux.HandleFunc("POST /reembed", writeGuard(engine, h.handleReembed))
+	mux.HandleFunc("POST /admin/communities/rebuild", writeGuard(engine, h.handleRebuildCommunities))
+	mux.HandleFunc("POST /admin/communities/refresh", writeGuard(engine, h.handleRefreshCommunities))
+	mux.HandleFunc("POST /admin/graph/import", writeGuard(engine, h.handleImportGraph))
+	mux.HandleFunc("POST /admin/index/import", writeGuard(engine, h.handleImportIndex))
+	mux.HandleFunc("POST /admin/contradictions/detect", writeGuard(engine, h.handleDetectContradictions))
+	mux.HandleFunc("GET /contradictions", h.handleListContradictions)
+	mux.HandleFunc("POST /admin/compact", writeGuard(engine, h.handleCompact))
+	mux.HandleFunc("DELETE /documents/{id}", writeGuard(engine, h.handleDeleteDocument))
 	mux.HandleFunc("GET /documents", h.handleListDocuments)
+	mux.HandleFunc("DELETE /sessions/{id}", writeGuard(engine, h.handleDeleteSession))
+	mux.HandleFunc("GET /documents/{id}", h.handleGetDocument)
+	mux.HandleFunc("GET /documents/{id}/summary", h.handleDocumentSummary)
+	mux.HandleFunc("GET /documents/{id}/chunks", h.handleDocumentChunks)
+	mux.HandleFunc("POST /documents/{id}/summarize", h.handleSummarizeDocument)
+	mux.HandleFunc("POST /documents/{id}/resume", writeGuard(engine, h.handleResumeIngest))
+	mux.HandleFunc("POST /documents/{id}/reparse/compare", h.handleReparseCompare)
+	mux.HandleFunc("POST /documents/{id}/reparse", writeGuard(engine, h.handleCommitReparse))
+	mux.HandleFunc("GET /chunks/{id}", h.handleGetChunk)
+	mux.HandleFunc("GET /chunks/{id}/images/{n}", h.handleGetChunkImage)
+	mux.HandleFunc("POST /chunks/{id}/annotations", writeGuard(engine, h.handleAnnotateChunk))
+	mux.HandleFunc("GET /annotations", h.handleListAnnotations)
+	mux.HandleFunc("DELETE /annotations/{id}", writeGuard(engine, h.handleDeleteAnnotation))
+	mux.HandleFunc("POST /templates", writeGuard(engine, h.handleSaveTemplate))
+	mux.HandleFunc("GET /templates", h.handleListTemplates)
+	mux.HandleFunc("DELETE /templates/{name}", writeGuard(engine, h.handleDeleteTemplate))
+	mux.HandleFunc("POST /templates/{name}/query", h.handleTemplateQuery)
+	mux.HandleFunc("GET /audit", h.handleAudit)
+	mux.HandleFunc("GET /entities/stats", h.handleEntityStats)
+	mux.HandleFunc("GET /glossary", h.handleGlossary)
+	mux.HandleFunc("GET /facts", h.handleKeyFacts)
+	mux.HandleFunc("GET /admin/loglevel", h.handleGetLogLevel)
+	mux.HandleFunc("PUT /admin/loglevel", h.handleSetLogLevel)
 	mux.HandleFunc("GET /health", h.handleHealth)
+	mux.HandleFunc("GET /metrics", h.handleMetrics)
 
-	// Middleware chain: recovery -> cors -> auth -> logging -> mux
+	// Middleware chain: request ID -> recovery -> cors -> auth -> logging -> actor -> demo -> mux
 	var handler http.Handler = mux
+	if d != nil {
+		handler = d.middleware(mux, handler)
+		slog.Info("demo mode: read-only, rate limited",
+			"requests_per_minute", d.cfg.RequestsPerMinute, "tokens_per_ip", d.cfg.TokensPerIP,
+			"tokens_per_day", d.cfg.TokensPerDay)
+	}
+	handler = actorMiddleware(handler)
 	handler = logMiddleware(handler)
 	handler = authMiddleware(apiKey, handler)
 	handler = corsMiddleware(corsOrigins, handler)
 	handler = recoveryMiddleware(handler)
+	handler = requestIDMiddleware(handler)
 
 	srv := &http.Server{
 		Addr:         *addr,
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bbiangul/go-reason/llm"
+)
+
+// providerMetrics are the LLM provider series GET /metrics exposes, all
+// labelled with provider, model, operation, and phase.
+var providerMetrics = []struct {
+	name, help, kind string
+	value            func(llm.MeterStats) float64
+}{
+	{"goreason_llm_requests_total", "LLM provider calls.", "counter",
+		func(s llm.MeterStats) float64 { return float64(s.Calls) }},
+	{"goreason_llm_errors_total", "LLM provider calls that failed.", "counter",
+		func(s llm.MeterStats) float64 { return float64(s.Errors) }},
+	{"goreason_llm_prompt_tokens_total", "Prompt tokens reported by the provider.", "counter",
+		func(s llm.MeterStats) float64 { return float64(s.PromptTokens) }},
+	{"goreason_llm_completion_tokens_total", "Completion tokens reported by the provider.", "counter",
+		func(s llm.MeterStats) float64 { return float64(s.CompletionTokens) }},
+	{"goreason_llm_cached_tokens_total", "Prompt tokens served from the provider's prompt cache.", "counter",
+		func(s llm.MeterStats) float64 { return float64(s.CachedTokens) }},
+	{"goreason_llm_embedded_inputs_total", "Texts and images sent for embedding.", "counter",
+		func(s llm.MeterStats) float64 { return float64(s.Inputs) }},
+	{"goreason_llm_request_duration_seconds_total", "Time spent in LLM provider calls.", "counter",
+		func(s llm.MeterStats) float64 { return s.Seconds }},
+}
+
+// GET /metrics
+//
+// Reports LLM provider usage in the Prometheus text format, so dashboards
+// can break down cost, latency, and error rates by model and operation.
+func (h *handler) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	records := h.engine.ProviderUsage()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	for _, m := range providerMetrics {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+		for _, rec := range records {
+			fmt.Fprintf(bw, "%s{provider=%s,model=%s,operation=%s,phase=%s} %s\n", m.name,
+				labelValue(rec.Provider), labelValue(rec.Model), labelValue(rec.Operation), labelValue(rec.Phase),
+				strconv.FormatFloat(m.value(rec.MeterStats), 'g', -1, 64))
+		}
+	}
+	bw.Flush()
+}
+
+// labelValue quotes a Prometheus label value.
+func labelValue(v string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v) + `"`
+}
@@ -3,12 +3,31 @@ package main
 import (
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"runtime/debug"
 	"strings"
 	"time"
+
+	"github.com/bbiangul/go-reason"
+	"github.com/bbiangul/go-reason/requestid"
 )
 
+// requestIDMiddleware gives every request an ID: the caller's X-Request-ID
+// when it is usable, a new one otherwise. The ID is echoed in the response
+// header and error bodies, logged with every line the request produces,
+// stored with its query log entry, and forwarded to the LLM provider.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.New()
+		}
+		w.Header().Set(requestid.Header, id)
+		next.ServeHTTP(w, r.WithContext(goreason.WithRequestID(r.Context(), id)))
+	})
+}
+
 // logMiddleware logs each request with method, path, status, and duration.
 func logMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -17,7 +36,7 @@ func logMiddleware(next http.Handler) http.Handler {
 
 		next.ServeHTTP(rw, r)
 
-		slog.Info("request",
+		slog.InfoContext(r.Context(), "request",
 			"method", r.Method,
 			"path", r.URL.Path,
 			"status", rw.status,
@@ -27,6 +46,23 @@ func logMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// actorMiddleware attributes the request's mutations in the audit log to
+// the caller named in the X-Actor header, or to the client address when
+// the header is absent. The header is self-reported; put the server behind
+// an authenticating proxy that sets it when attribution must be trusted.
+func actorMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor := r.Header.Get("X-Actor")
+		if actor == "" {
+			actor = r.RemoteAddr
+			if host, _, err := net.SplitHostPort(actor); err == nil {
+				actor = host
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(goreason.WithActor(r.Context(), actor)))
+	})
+}
+
 // authMiddleware checks for a valid API key in the Authorization header.
 // If apiKey is empty, authentication is disabled (development mode).
 func authMiddleware(apiKey string, next http.Handler) http.Handler {
@@ -42,9 +78,7 @@ func authMiddleware(apiKey string, next http.Handler) http.Handler {
 
 		auth := r.Header.Get("Authorization")
 		if !strings.HasPrefix(auth, "Bearer ") || auth[7:] != apiKey {
-			writeJSON(w, http.StatusUnauthorized, map[string]string{
-				"error": "unauthorized",
-			})
+			writeError(w, http.StatusUnauthorized, "unauthorized")
 			return
 		}
 
@@ -52,19 +86,34 @@ func authMiddleware(apiKey string, next http.Handler) http.Handler {
 	})
 }
 
+// writeGuard rejects a write route's requests with 403 while the engine
+// is read-only (a replica), naming the LiteFS primary when it is known so
+// a proxy can forward the write there.
+func writeGuard(engine goreason.Engine, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if st := engine.ReplicationStatus(); st.Role == goreason.RoleReplica {
+			msg := "read-only replica; send writes to the primary"
+			if st.Primary != "" {
+				msg = fmt.Sprintf("read-only replica; send writes to the primary at %s", st.Primary)
+			}
+			writeError(w, http.StatusForbidden, msg)
+			return
+		}
+		next(w, r)
+	}
+}
+
 // recoveryMiddleware catches panics, logs the stack trace, and returns 500.
 func recoveryMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
-				slog.Error("panic recovered",
+				slog.ErrorContext(r.Context(), "panic recovered",
 					"error", fmt.Sprintf("%v", err),
 					"path", r.URL.Path,
 					"stack", string(debug.Stack()),
 				)
-				writeJSON(w, http.StatusInternalServerError, map[string]string{
-					"error": "internal server error",
-				})
+				writeError(w, http.StatusInternalServerError, "internal server error")
 			}
 		}()
 		next.ServeHTTP(w, r)
@@ -80,7 +129,8 @@ func corsMiddleware(origins string, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", origins)
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Actor, X-Request-ID")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
 		w.Header().Set("Access-Control-Max-Age", "86400")
 
 		if r.Method == http.MethodOptions {
@@ -101,3 +151,9 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.status = code
 	rw.ResponseWriter.WriteHeader(code)
 }
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to
+// flush streamed responses.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
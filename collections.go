@@ -0,0 +1,89 @@
+package goreason
+
+import (
+	"fmt"
+
+	"github.com/bbiangul/go-reason/chunker"
+)
+
+// WithCollection scopes the query to the documents of a collection and
+// applies its preset (weights, result window, system prompt). Options
+// given alongside it override the preset.
+func WithCollection(name string) QueryOption {
+	return func(o *queryOptions) { o.collection = name }
+}
+
+// WithIngestCollection assigns the document to a collection and chunks it
+// with the collection's settings. Re-ingesting without it keeps the
+// document's current collection.
+func WithIngestCollection(name string) IngestOption {
+	return func(o *ingestOptions) { o.collection = name }
+}
+
+// compileCollections validates the collection presets and builds a chunker
+// for each collection that overrides the chunk size or overlap.
+func compileCollections(cfg Config, typeRules []chunker.TypeRule, tokenizers map[string]chunker.Tokenizer) (map[string]*chunker.Chunker, error) {
+	chunkers := make(map[string]*chunker.Chunker)
+	for name, c := range cfg.Collections {
+		if name == "" {
+			return nil, fmt.Errorf("%w: collection name is required", ErrInvalidConfig)
+		}
+		if c.WeightVector < 0 || c.WeightFTS < 0 || c.WeightGraph < 0 {
+			return nil, fmt.Errorf("%w: collection %q has a negative weight", ErrInvalidConfig, name)
+		}
+		if c.MaxResults < 0 || c.MaxChunkTokens < 0 || c.ChunkOverlap < 0 {
+			return nil, fmt.Errorf("%w: collection %q has a negative size", ErrInvalidConfig, name)
+		}
+		if c.MaxChunkTokens == 0 && c.ChunkOverlap == 0 {
+			continue
+		}
+		maxTokens, overlap := cfg.MaxChunkTokens, cfg.ChunkOverlap
+		if c.MaxChunkTokens > 0 {
+			maxTokens = c.MaxChunkTokens
+		}
+		if c.ChunkOverlap > 0 {
+			overlap = c.ChunkOverlap
+		}
+		chunkers[name] = chunker.New(chunker.Config{
+			MaxTokens:  maxTokens,
+			Overlap:    overlap,
+			Types:      typeRules,
+			Tokenizers: tokenizers,
+		})
+	}
+	return chunkers, nil
+}
+
+// chunkerFor returns the chunker of a collection, or the default one.
+func (e *engine) chunkerFor(collection string) *chunker.Chunker {
+	if c, ok := e.collChunkers[collection]; ok {
+		return c
+	}
+	return e.chunkr
+}
+
+// defaultQueryOptions returns the query defaults, taken from the
+// collection's preset where it sets them.
+func (e *engine) defaultQueryOptions(collection string) *queryOptions {
+	o := &queryOptions{
+		maxResults:  20,
+		maxRounds:   e.cfg.MaxRounds,
+		weightVec:   e.cfg.WeightVector,
+		weightFTS:   e.cfg.WeightFTS,
+		weightGraph: e.cfg.WeightGraph,
+	}
+	preset, ok := e.cfg.Collections[collection]
+	if !ok {
+		return o
+	}
+	if preset.MaxResults > 0 {
+		o.maxResults = preset.MaxResults
+	}
+	if preset.WeightVector > 0 || preset.WeightFTS > 0 || preset.WeightGraph > 0 {
+		o.weightVec = preset.WeightVector
+		o.weightFTS = preset.WeightFTS
+		o.weightGraph = preset.WeightGraph
+	}
+	o.instructions = preset.SystemPrompt
+	return o
+}
@@ -0,0 +1,56 @@
+package goreason
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCompileCollections(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Collections = map[string]CollectionConfig{
+		"contracts": {MaxChunkTokens: 512},
+		"manuals":   {WeightFTS: 2},
+	}
+	chunkers, err := compileCollections(cfg, nil, nil)
+	if err != nil {
+		t.Fatalf("compileCollections: %v", err)
+	}
+	if _, ok := chunkers["contracts"]; !ok {
+		t.Error("expected a chunker for the collection overriding chunk size")
+	}
+	if _, ok := chunkers["manuals"]; ok {
+		t.Error("collections without chunk settings share the default chunker")
+	}
+
+	for _, bad := range []map[string]CollectionConfig{
+		{"": {}},
+		{"a": {WeightGraph: -1}},
+		{"a": {ChunkOverlap: -5}},
+	} {
+		cfg.Collections = bad
+		if _, err := compileCollections(cfg, nil, nil); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("compileCollections(%+v) error = %v, want ErrInvalidConfig", bad, err)
+		}
+	}
+}
+
+func TestCollectionQueryDefaults(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Collections = map[string]CollectionConfig{
+		"contracts": {WeightFTS: 2, MaxResults: 30, SystemPrompt: "Cite clause numbers."},
+	}
+	e := &engine{cfg: cfg}
+
+	base := e.defaultQueryOptions("")
+	if base.maxResults != 20 || base.weightFTS != cfg.WeightFTS || base.instructions != "" {
+		t.Errorf("unexpected defaults: %+v", base)
+	}
+	got := e.defaultQueryOptions("contracts")
+	if got.maxResults != 30 || got.weightFTS != 2 || got.instructions != "Cite clause numbers." {
+		t.Errorf("preset not applied: %+v", got)
+	}
+	if got := e.defaultQueryOptions("unknown"); !reflect.DeepEqual(got, base) {
+		t.Errorf("collection without preset must use defaults, got %+v", got)
+	}
+}
@@ -0,0 +1,251 @@
+package goreason
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/bbiangul/go-reason/graph"
+	"github.com/bbiangul/go-reason/store"
+)
+
+// Stages reported by CommunityProgress.
+const (
+	CommunityStageDetect    = "detect"
+	CommunityStageSummarize = "summarize"
+)
+
+// CommunityProgress reports the progress of a community rebuild. Done and
+// Total count summaries in the summarize stage.
+type CommunityProgress struct {
+	Stage string `json:"stage"`
+	Done  int    `json:"done"`
+	Total int    `json:"total"`
+}
+
+// CommunityRebuild is the result of RebuildCommunities. Reused counts
+// communities whose summary was still current, so they were not
+// summarised again; Stale counts those left without a current summary.
+type CommunityRebuild struct {
+	Levels      int   `json:"levels"`
+	Communities int   `json:"communities"`
+	Level0      int   `json:"level_0"`
+	Level1      int   `json:"level_1"`
+	Summarized  int   `json:"summarized"`
+	Reused      int   `json:"reused"`
+	Stale       int   `json:"stale"`
+	ElapsedMs   int64 `json:"elapsed_ms"`
+}
+
+// CommunityRefresh is the result of RefreshCommunitySummaries.
+type CommunityRefresh struct {
+	Stale      int   `json:"stale"` // stale communities found
+	Summarized int   `json:"summarized"`
+	ElapsedMs  int64 `json:"elapsed_ms"`
+}
+
+// CommunityOption configures RebuildCommunities.
+type CommunityOption func(*communityOptions)
+
+type communityOptions struct {
+	levels        int
+	skipSummaries bool
+	resummarize   bool
+	progress      func(CommunityProgress)
+}
+
+// WithCommunityLevels overrides Config.CommunityLevels for this rebuild:
+// 1 builds connected components only, 2 also splits large components.
+func WithCommunityLevels(n int) CommunityOption {
+	return func(o *communityOptions) { o.levels = n }
+}
+
+// WithoutCommunitySummaries skips the LLM summary of each community, for a
+// fast structural rebuild.
+func WithoutCommunitySummaries() CommunityOption {
+	return func(o *communityOptions) { o.skipSummaries = true }
+}
+
+// WithAllCommunitySummaries summarises every community, not only the
+// stale ones.
+func WithAllCommunitySummaries() CommunityOption {
+	return func(o *communityOptions) { o.resummarize = true }
+}
+
+// WithCommunityProgress calls fn as the rebuild advances. fn is called from
+// one goroutine at a time.
+func WithCommunityProgress(fn func(CommunityProgress)) CommunityOption {
+	return func(o *communityOptions) { o.progress = fn }
+}
+
+// newCommunityOptions applies opts over the configured defaults.
+func newCommunityOptions(cfg Config, opts []CommunityOption) *communityOptions {
+	o := &communityOptions{levels: cfg.CommunityLevels}
+	for _, fn := range opts {
+		fn(o)
+	}
+	if o.levels == 0 {
+		o.levels = 2
+	}
+	return o
+}
+
+// RebuildCommunities re-runs community detection over the whole entity
+// graph and summarises the communities that are new or whose members
+// changed.
+func (e *engine) RebuildCommunities(ctx context.Context, opts ...CommunityOption) (*CommunityRebuild, error) {
+	if err := e.writable(); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	o := newCommunityOptions(e.cfg, opts)
+	res, err := e.rebuildCommunities(ctx, o)
+	e.audit(ctx, AuditRebuildCommunities, start, 0, map[string]string{
+		"levels": strconv.Itoa(o.levels), "summaries": strconv.FormatBool(!o.skipSummaries),
+		"resummarize": strconv.FormatBool(o.resummarize),
+	}, err)
+	return res, err
+}
+
+// RefreshCommunitySummaries summarises the stale communities without
+// re-running detection: those never summarised, and those whose member
+// entities changed since their summary was written.
+func (e *engine) RefreshCommunitySummaries(ctx context.Context, opts ...CommunityOption) (*CommunityRefresh, error) {
+	if err := e.writable(); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	res, err := e.refreshCommunities(ctx, newCommunityOptions(e.cfg, opts))
+	var params map[string]string
+	if res != nil {
+		params = map[string]string{"stale": strconv.Itoa(res.Stale), "summarized": strconv.Itoa(res.Summarized)}
+	}
+	e.audit(ctx, AuditRefreshCommunities, start, 0, params, err)
+	return res, err
+}
+
+// refreshCommunities does the work of RefreshCommunitySummaries.
+func (e *engine) refreshCommunities(ctx context.Context, o *communityOptions) (*CommunityRefresh, error) {
+	e.communityMu.Lock()
+	defer e.communityMu.Unlock()
+
+	start := time.Now()
+	stale, err := e.store.StaleCommunities(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("loading stale communities: %w", err)
+	}
+	res := &CommunityRefresh{Stale: len(stale)}
+	res.Summarized = e.summarizeCommunities(ctx, stale, o)
+	res.ElapsedMs = time.Since(start).Milliseconds()
+	return res, nil
+}
+
+// summarizeCommunities summarises communities and returns how many
+// summaries were stored. A failed summarisation is logged, not returned.
+func (e *engine) summarizeCommunities(ctx context.Context, communities []store.Community, o *communityOptions) int {
+	if len(communities) == 0 {
+		return 0
+	}
+	report := func(p CommunityProgress) {
+		if o.progress != nil {
+			o.progress(p)
+		}
+	}
+	var summarized int
+	slog.InfoContext(ctx, "communities: summarizing", "count", len(communities))
+	report(CommunityProgress{Stage: CommunityStageSummarize, Total: len(communities)})
+	err := graph.SummarizeCommunitiesWithProgress(ctx, e.store, e.chatLLM, communities, func(done, total int) {
+		summarized = done
+		report(CommunityProgress{Stage: CommunityStageSummarize, Done: done, Total: total})
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "community summarization failed (non-fatal)", "error", err)
+	}
+	return summarized
+}
+
+// startCommunityRefresh summarises stale communities every interval until
+// Close (Config.CommunitySummaryIntervalMinutes).
+func (e *engine) startCommunityRefresh(interval time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				res, err := e.refreshCommunities(ctx, newCommunityOptions(e.cfg, nil))
+				if err != nil {
+					if ctx.Err() == nil {
+						slog.WarnContext(ctx, "communities: scheduled refresh failed (non-fatal)", "error", err)
+					}
+				} else if res.Stale > 0 {
+					slog.InfoContext(ctx, "communities: scheduled refresh complete",
+						"stale", res.Stale, "summarized", res.Summarized)
+				}
+			}
+		}
+	}()
+	e.stopCommunityRefresh = func() {
+		cancel()
+		<-done
+	}
+	slog.InfoContext(ctx, "communities: scheduled summary refresh started", "interval", interval)
+}
+
+// rebuildCommunities does the work of RebuildCommunities; ingest calls it
+// after each graph build. A failed summarisation is logged, not returned,
+// since the communities themselves are stored by then.
+func (e *engine) rebuildCommunities(ctx context.Context, o *communityOptions) (*CommunityRebuild, error) {
+	if o.levels < 1 || o.levels > 2 {
+		return nil, fmt.Errorf("%w: community levels must be 1 or 2", ErrInvalidConfig)
+	}
+	report := func(p CommunityProgress) {
+		if o.progress != nil {
+			o.progress(p)
+		}
+	}
+
+	e.communityMu.Lock()
+	defer e.communityMu.Unlock()
+
+	start := time.Now()
+	report(CommunityProgress{Stage: CommunityStageDetect})
+	communities, err := graph.DetectCommunitiesWithOptions(ctx, e.store, graph.CommunityOptions{Levels: o.levels})
+	if err != nil {
+		return nil, fmt.Errorf("detecting communities: %w", err)
+	}
+
+	res := &CommunityRebuild{Levels: o.levels, Communities: len(communities)}
+	var stale []store.Community
+	for _, c := range communities {
+		if c.Level == 0 {
+			res.Level0++
+		} else {
+			res.Level1++
+		}
+		if o.resummarize || c.Stale() {
+			stale = append(stale, c)
+		} else {
+			res.Reused++
+		}
+	}
+
+	if !o.skipSummaries {
+		res.Summarized = e.summarizeCommunities(ctx, stale, o)
+	}
+	for _, c := range stale {
+		if c.Stale() {
+			res.Stale++
+		}
+	}
+
+	res.ElapsedMs = time.Since(start).Milliseconds()
+	return res, nil
+}
@@ -0,0 +1,129 @@
+package goreason
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bbiangul/go-reason/store"
+)
+
+func TestRebuildCommunities(t *testing.T) {
+	s, err := store.New(filepath.Join(t.TempDir(), "communities.db"), 4)
+	if err != nil {
+		t.Fatalf("store.New: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	var ids []int64
+	for _, name := range []string{"iso 9001", "quality management", "iso 31000"} {
+		id, err := s.UpsertEntity(ctx, store.Entity{Name: name, EntityType: "concept"})
+		if err != nil {
+			t.Fatalf("UpsertEntity: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	if _, err := s.InsertRelationship(ctx, store.Relationship{SourceEntityID: ids[0], TargetEntityID: ids[1], RelationType: "defines", Weight: 1}); err != nil {
+		t.Fatalf("InsertRelationship: %v", err)
+	}
+
+	chat := &summaryChat{reply: "Quality standards."}
+	e := &engine{store: s, queryLog: s, chatLLM: chat}
+
+	var stages []CommunityProgress
+	res, err := e.RebuildCommunities(ctx, WithCommunityLevels(1), WithCommunityProgress(func(p CommunityProgress) {
+		stages = append(stages, p)
+	}))
+	if err != nil {
+		t.Fatalf("RebuildCommunities: %v", err)
+	}
+	if res.Levels != 1 || res.Communities != 2 || res.Level0 != 2 || res.Level1 != 0 || res.Summarized != 2 {
+		t.Errorf("unexpected result: %+v", res)
+	}
+	if len(stages) != 4 || stages[0].Stage != CommunityStageDetect || stages[3] != (CommunityProgress{Stage: CommunityStageSummarize, Done: 2, Total: 2}) {
+		t.Errorf("unexpected progress: %+v", stages)
+	}
+
+	chat.calls = 0
+	res, err = e.RebuildCommunities(ctx, WithoutCommunitySummaries())
+	if err != nil {
+		t.Fatalf("RebuildCommunities without summaries: %v", err)
+	}
+	if chat.calls != 0 || res.Summarized != 0 || res.Levels != 2 {
+		t.Errorf("summaries must be skipped: %d calls, %+v", chat.calls, res)
+	}
+
+	if _, err := e.RebuildCommunities(ctx, WithCommunityLevels(5)); err == nil {
+		t.Error("expected an error for levels=5")
+	}
+	entries, err := e.AuditLog(ctx, AuditFilter{Operation: AuditRebuildCommunities})
+	if err != nil {
+		t.Fatalf("AuditLog: %v", err)
+	}
+	if len(entries) != 3 || entries[0].Outcome != AuditOutcomeError || entries[2].Params["levels"] != "1" {
+		t.Errorf("unexpected audit entries: %+v", entries)
+	}
+}
+
+func TestCommunityStaleness(t *testing.T) {
+	eng, srv, path := faultEngine(t, nil)
+	ctx := context.Background()
+	var summaries atomic.Int32
+	chat := srv.ChatFunc
+	srv.ChatFunc = func(prompt string) string {
+		if strings.Contains(prompt, "Summarize the following group of related entities") {
+			summaries.Add(1)
+			return "Relief valve settings."
+		}
+		return chat(prompt)
+	}
+	if _, err := eng.Ingest(ctx, path); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	if n := summaries.Load(); n != 1 {
+		t.Fatalf("ingest summarised %d communities, want 1", n)
+	}
+
+	// Nothing changed: the summary is reused.
+	res, err := eng.RebuildCommunities(ctx)
+	if err != nil {
+		t.Fatalf("RebuildCommunities: %v", err)
+	}
+	if res.Reused != 1 || res.Summarized != 0 || summaries.Load() != 1 {
+		t.Errorf("rebuild = %+v after %d summaries", res, summaries.Load())
+	}
+
+	// A member entity changed: the community is stale until refreshed.
+	db := eng.(*engine).store.DB()
+	if _, err := db.ExecContext(ctx, "UPDATE entities SET description = 'Opens at 10 bar'"); err != nil {
+		t.Fatal(err)
+	}
+	res, err = eng.RebuildCommunities(ctx, WithoutCommunitySummaries())
+	if err != nil {
+		t.Fatalf("RebuildCommunities: %v", err)
+	}
+	if res.Reused != 0 || res.Stale != 1 {
+		t.Errorf("rebuild without summaries = %+v", res)
+	}
+	refresh, err := eng.RefreshCommunitySummaries(ctx)
+	if err != nil {
+		t.Fatalf("RefreshCommunitySummaries: %v", err)
+	}
+	if refresh.Stale != 1 || refresh.Summarized != 1 || summaries.Load() != 2 {
+		t.Errorf("refresh = %+v after %d summaries", refresh, summaries.Load())
+	}
+	if refresh, _ = eng.RefreshCommunitySummaries(ctx); refresh.Stale != 0 {
+		t.Errorf("second refresh found %d stale communities", refresh.Stale)
+	}
+
+	res, err = eng.RebuildCommunities(ctx, WithAllCommunitySummaries())
+	if err != nil {
+		t.Fatalf("RebuildCommunities: %v", err)
+	}
+	if res.Summarized != 1 || summaries.Load() != 3 {
+		t.Errorf("rebuild with all summaries = %+v", res)
+	}
+}
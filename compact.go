@@ -0,0 +1,72 @@
+package goreason
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/bbiangul/go-reason/store"
+)
+
+// Compaction is the result of Compact.
+type Compaction struct {
+	store.CompactStats
+	BytesReclaimed int64             `json:"bytes_reclaimed"`
+	Communities    *CommunityRebuild `json:"communities,omitempty"` // set when orphans were removed
+	ElapsedMs      int64             `json:"elapsed_ms"`
+}
+
+// Compact removes the entities and relationships deleted documents left
+// behind, cleans up orphaned vector rows, optimizes the FTS index, and
+// vacuums the database. Communities are rebuilt when entities or
+// relationships were removed, since the old ones may list them. It waits
+// for running ingests and holds back new ones while it runs.
+func (e *engine) Compact(ctx context.Context) (*Compaction, error) {
+	if err := e.writable(); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	res, err := e.compact(ctx)
+	var params map[string]string
+	if res != nil {
+		params = map[string]string{
+			"entities":        strconv.Itoa(res.Entities),
+			"relationships":   strconv.Itoa(res.Relationships),
+			"vectors":         strconv.Itoa(res.Vectors),
+			"bytes_reclaimed": strconv.FormatInt(res.BytesReclaimed, 10),
+		}
+	}
+	e.audit(ctx, AuditCompact, start, 0, params, err)
+	return res, err
+}
+
+// compact does the work of Compact.
+func (e *engine) compact(ctx context.Context) (*Compaction, error) {
+	// Orphans are whatever no chunk links to, so an ingest that has
+	// written entities but not yet linked them must not run alongside.
+	defer e.lockIngests()()
+
+	start := time.Now()
+	stats, err := e.store.Compact(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("compacting store: %w", err)
+	}
+	res := &Compaction{CompactStats: *stats, BytesReclaimed: stats.BytesBefore - stats.BytesAfter}
+
+	if stats.Entities > 0 || stats.Relationships > 0 {
+		communities, err := e.rebuildCommunities(ctx, newCommunityOptions(e.cfg, nil))
+		if err != nil {
+			return nil, fmt.Errorf("rebuilding communities: %w", err)
+		}
+		res.Communities = communities
+		e.refreshStopEntities(ctx)
+	}
+
+	res.ElapsedMs = time.Since(start).Milliseconds()
+	slog.InfoContext(ctx, "compaction complete",
+		"entities", res.Entities, "relationships", res.Relationships, "vectors", res.Vectors,
+		"bytes_reclaimed", res.BytesReclaimed, "elapsed_ms", res.ElapsedMs)
+	return res, nil
+}
@@ -0,0 +1,81 @@
+package goreason
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bbiangul/go-reason/llm/llmtest"
+)
+
+func TestCompactAfterDelete(t *testing.T) {
+	eng, _, path := faultEngine(t, &llmtest.Scenario{})
+	ctx := context.Background()
+	e := eng.(*engine)
+
+	docID, err := eng.Ingest(ctx, path)
+	if err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	if communities, _ := e.store.GetCommunities(ctx, 0); len(communities) == 0 {
+		t.Fatal("ingest built no communities")
+	}
+	if err := eng.Delete(ctx, docID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	res, err := eng.Compact(ctx)
+	if err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+	if res.Entities != 1 || res.Communities == nil {
+		t.Errorf("Compact = %+v, want the relief valve removed and communities rebuilt", res)
+	}
+	if res.BytesReclaimed != res.BytesBefore-res.BytesAfter {
+		t.Errorf("BytesReclaimed = %d, want %d", res.BytesReclaimed, res.BytesBefore-res.BytesAfter)
+	}
+	if communities, _ := e.store.GetCommunities(ctx, 0); len(communities) != 0 {
+		t.Errorf("%d communities left over an empty graph", len(communities))
+	}
+
+	entries, err := eng.AuditLog(ctx, AuditFilter{Operation: AuditCompact})
+	if err != nil || len(entries) != 1 || entries[0].Params["entities"] != "1" {
+		t.Errorf("audit = %+v, %v", entries, err)
+	}
+
+	// Nothing is left to remove, so communities are not rebuilt again.
+	res, err = eng.Compact(ctx)
+	if err != nil || res.Entities != 0 || res.Communities != nil {
+		t.Errorf("second Compact = %+v, %v", res, err)
+	}
+}
+
+func TestCompactWaitsForIngests(t *testing.T) {
+	eng, _, _ := faultEngine(t, &llmtest.Scenario{})
+	ctx := context.Background()
+	e := eng.(*engine)
+
+	release, err := e.acquireIngest(ctx)
+	if err != nil {
+		t.Fatalf("acquireIngest: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := eng.Compact(ctx)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("Compact returned during an ingest: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	release()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Compact: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Compact never ran after the ingest finished")
+	}
+}
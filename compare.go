@@ -0,0 +1,231 @@
+package goreason
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxDiffWords bounds the answer length the word diff aligns; the LCS table
+// is quadratic. Longer answers are compared on their first maxDiffWords
+// words.
+const maxDiffWords = 1500
+
+// QueryComparison is the result of CompareQuery: the answer of each
+// configuration and how they differ.
+type QueryComparison struct {
+	Question string          `json:"question"`
+	A        *ComparedAnswer `json:"a"`
+	B        *ComparedAnswer `json:"b"`
+	Diff     *AnswerDiff     `json:"diff,omitempty"` // nil unless both succeeded
+}
+
+// ComparedAnswer is one side of a comparison. Answer carries the
+// retrieval trace of that configuration.
+type ComparedAnswer struct {
+	Answer    *Answer `json:"answer,omitempty"`
+	Error     string  `json:"error,omitempty"`
+	ElapsedMs int64   `json:"elapsed_ms"`
+}
+
+// AnswerDiff summarises how answer B differs from answer A. Deltas are
+// B minus A.
+type AnswerDiff struct {
+	// TextDiff is a word diff of the answers: words only in A are marked
+	// [-like this-], words only in B {+like this+}.
+	TextDiff       string  `json:"text_diff"`
+	TextSimilarity float64 `json:"text_similarity"` // Jaccard similarity of the answers' words
+
+	SharedSources []int64      `json:"shared_sources"` // chunk IDs cited by both
+	OnlyA         []int64      `json:"only_a"`
+	OnlyB         []int64      `json:"only_b"`
+	SourceOverlap float64      `json:"source_overlap"` // Jaccard similarity of the source sets
+	RankChanges   []RankChange `json:"rank_changes,omitempty"`
+
+	ConfidenceDelta float64 `json:"confidence_delta"`
+	TokensDelta     int     `json:"tokens_delta"`
+	ElapsedDeltaMs  int64   `json:"elapsed_delta_ms"`
+}
+
+// RankChange records a shared source that moved between the two answers'
+// source lists (1-based ranks).
+type RankChange struct {
+	ChunkID int64 `json:"chunk_id"`
+	RankA   int   `json:"rank_a"`
+	RankB   int   `json:"rank_b"`
+}
+
+// CompareQuery runs question through two query configurations in parallel
+// and returns both answers with a diff, for interactive retrieval
+// experiments (weights A vs B, graph on vs off, strategies). A failing side
+// is reported on its ComparedAnswer; an error is returned only when both
+// fail.
+func (e *engine) CompareQuery(ctx context.Context, question string, a, b []QueryOption) (*QueryComparison, error) {
+	cmp := &QueryComparison{Question: question, A: &ComparedAnswer{}, B: &ComparedAnswer{}}
+	var errA, errB error
+
+	var wg sync.WaitGroup
+	run := func(side *ComparedAnswer, opts []QueryOption, errp *error) {
+		defer wg.Done()
+		start := time.Now()
+		side.Answer, *errp = e.Query(ctx, question, opts...)
+		side.ElapsedMs = time.Since(start).Milliseconds()
+		if *errp != nil {
+			side.Error = (*errp).Error()
+		}
+	}
+	wg.Add(2)
+	go run(cmp.A, a, &errA)
+	go run(cmp.B, b, &errB)
+	wg.Wait()
+
+	if errA != nil && errB != nil {
+		return nil, fmt.Errorf("both configurations failed: %w", errA)
+	}
+	if errA == nil && errB == nil {
+		cmp.Diff = diffAnswers(cmp.A, cmp.B)
+	}
+	return cmp, nil
+}
+
+// diffAnswers compares two successful answers.
+func diffAnswers(a, b *ComparedAnswer) *AnswerDiff {
+	wordsA, wordsB := strings.Fields(a.Answer.Text), strings.Fields(b.Answer.Text)
+	d := &AnswerDiff{
+		TextDiff:        wordDiff(wordsA, wordsB),
+		TextSimilarity:  jaccard(lowerWords(wordsA), lowerWords(wordsB)),
+		ConfidenceDelta: b.Answer.Confidence - a.Answer.Confidence,
+		TokensDelta:     b.Answer.TotalTokens - a.Answer.TotalTokens,
+		ElapsedDeltaMs:  b.ElapsedMs - a.ElapsedMs,
+		SharedSources:   []int64{},
+		OnlyA:           []int64{},
+		OnlyB:           []int64{},
+	}
+
+	rankB := make(map[int64]int, len(b.Answer.Sources))
+	for i, s := range b.Answer.Sources {
+		if _, ok := rankB[s.ChunkID]; !ok {
+			rankB[s.ChunkID] = i + 1
+		}
+	}
+	seenA := make(map[int64]bool, len(a.Answer.Sources))
+	for i, s := range a.Answer.Sources {
+		if seenA[s.ChunkID] {
+			continue
+		}
+		seenA[s.ChunkID] = true
+		rb, ok := rankB[s.ChunkID]
+		if !ok {
+			d.OnlyA = append(d.OnlyA, s.ChunkID)
+			continue
+		}
+		d.SharedSources = append(d.SharedSources, s.ChunkID)
+		if rb != i+1 {
+			d.RankChanges = append(d.RankChanges, RankChange{ChunkID: s.ChunkID, RankA: i + 1, RankB: rb})
+		}
+	}
+	for _, s := range b.Answer.Sources {
+		if !seenA[s.ChunkID] {
+			seenA[s.ChunkID] = true // dedup within B
+			d.OnlyB = append(d.OnlyB, s.ChunkID)
+		}
+	}
+	if union := len(d.SharedSources) + len(d.OnlyA) + len(d.OnlyB); union > 0 {
+		d.SourceOverlap = float64(len(d.SharedSources)) / float64(union)
+	} else {
+		d.SourceOverlap = 1
+	}
+	return d
+}
+
+// wordDiff aligns two word sequences by longest common subsequence and
+// renders the result with [-removed-] and {+added+} markers.
+func wordDiff(a, b []string) string {
+	if len(a) > maxDiffWords {
+		a = a[:maxDiffWords]
+	}
+	if len(b) > maxDiffWords {
+		b = b[:maxDiffWords]
+	}
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var out []string
+	var removed, added []string
+	flush := func() {
+		if len(removed) > 0 {
+			out = append(out, "[-"+strings.Join(removed, " ")+"-]")
+			removed = nil
+		}
+		if len(added) > 0 {
+			out = append(out, "{+"+strings.Join(added, " ")+"+}")
+			added = nil
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			flush()
+			out = append(out, a[i])
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			added = append(added, b[j])
+			j++
+		default:
+			removed = append(removed, a[i])
+			i++
+		}
+	}
+	flush()
+	return strings.Join(out, " ")
+}
+
+func lowerWords(words []string) []string {
+	out := make([]string, len(words))
+	for i, w := range words {
+		out[i] = strings.ToLower(strings.Trim(w, ".,;:!?()\"'"))
+	}
+	return out
+}
+
+// jaccard is the Jaccard similarity of two word sets; two empty sets are
+// identical.
+func jaccard(a, b []string) float64 {
+	setA := make(map[string]bool, len(a))
+	for _, w := range a {
+		setA[w] = true
+	}
+	setB := make(map[string]bool, len(b))
+	inter := 0
+	for _, w := range b {
+		if setB[w] {
+			continue
+		}
+		setB[w] = true
+		if setA[w] {
+			inter++
+		}
+	}
+	union := len(setA) + len(setB) - inter
+	if union == 0 {
+		return 1
+	}
+	return float64(inter) / float64(union)
+}
@@ -0,0 +1,69 @@
+package goreason
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWordDiff(t *testing.T) {
+	tests := []struct {
+		a, b, want string
+	}{
+		{"the motor is rated 5kW", "the motor is rated 5kW", "the motor is rated 5kW"},
+		{"the motor is rated 5kW", "the motor is rated 7kW", "the motor is rated [-5kW-] {+7kW+}"},
+		{"the pump runs hot", "the pump runs", "the pump runs [-hot-]"},
+		{"", "new answer", "{+new answer+}"},
+		{"rated at 230V AC supply", "rated 230V DC supply", "rated [-at-] 230V [-AC-] {+DC+} supply"},
+	}
+	for _, tt := range tests {
+		if got := wordDiff(strings.Fields(tt.a), strings.Fields(tt.b)); got != tt.want {
+			t.Errorf("wordDiff(%q, %q) = %q, want %q", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestDiffAnswers(t *testing.T) {
+	a := &ComparedAnswer{
+		Answer: &Answer{
+			Text:        "The motor is rated 5kW.",
+			Confidence:  0.6,
+			TotalTokens: 100,
+			Sources:     []Source{{ChunkID: 1}, {ChunkID: 2}, {ChunkID: 3}},
+		},
+		ElapsedMs: 200,
+	}
+	b := &ComparedAnswer{
+		Answer: &Answer{
+			Text:        "The motor is rated 5kW",
+			Confidence:  0.9,
+			TotalTokens: 150,
+			Sources:     []Source{{ChunkID: 2}, {ChunkID: 1}, {ChunkID: 4}},
+		},
+		ElapsedMs: 350,
+	}
+
+	d := diffAnswers(a, b)
+	if d.TextSimilarity != 1 {
+		t.Errorf("TextSimilarity = %v, want 1 (punctuation ignored)", d.TextSimilarity)
+	}
+	if !reflect.DeepEqual(d.SharedSources, []int64{1, 2}) {
+		t.Errorf("SharedSources = %v", d.SharedSources)
+	}
+	if !reflect.DeepEqual(d.OnlyA, []int64{3}) || !reflect.DeepEqual(d.OnlyB, []int64{4}) {
+		t.Errorf("OnlyA = %v, OnlyB = %v", d.OnlyA, d.OnlyB)
+	}
+	if d.SourceOverlap != 0.5 {
+		t.Errorf("SourceOverlap = %v, want 0.5", d.SourceOverlap)
+	}
+	wantRanks := []RankChange{{ChunkID: 1, RankA: 1, RankB: 2}, {ChunkID: 2, RankA: 2, RankB: 1}}
+	if !reflect.DeepEqual(d.RankChanges, wantRanks) {
+		t.Errorf("RankChanges = %v, want %v", d.RankChanges, wantRanks)
+	}
+	if d.ConfidenceDelta < 0.299 || d.ConfidenceDelta > 0.301 {
+		t.Errorf("ConfidenceDelta = %v, want 0.3", d.ConfidenceDelta)
+	}
+	if d.TokensDelta != 50 || d.ElapsedDeltaMs != 150 {
+		t.Errorf("TokensDelta = %d, ElapsedDeltaMs = %d", d.TokensDelta, d.ElapsedDeltaMs)
+	}
+}
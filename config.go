@@ -1,8 +1,11 @@
 package goreason
 
 import (
+	"context"
 	"os"
 	"path/filepath"
+
+	"github.com/bbiangul/go-reason/pipeline"
 )
 
 // Config holds all configuration for the GoReason engine.
@@ -26,23 +29,283 @@ type Config struct {
 	Embedding   LLMConfig `json:"embedding" yaml:"embedding"`
 	Vision      LLMConfig `json:"vision" yaml:"vision"`
 	Translation LLMConfig `json:"translation" yaml:"translation"` // optional: fast model for query translation (defaults to Chat)
+	Escalation  LLMConfig `json:"escalation" yaml:"escalation"`   // optional: stronger model that re-answers weak answers (see EscalationThreshold)
+	Sparse      LLMConfig `json:"sparse" yaml:"sparse"`           // optional: learned sparse embeddings (SPLADE/BM42) via "tei" or "custom"
+
+	// Multimodal image embeddings (optional). When ImageEmbedding.Provider is
+	// set ("jina" or "custom"), chunk images are embedded into vec_images and
+	// queries gain an image-similarity retrieval leg.
+	ImageEmbedding    LLMConfig `json:"image_embedding" yaml:"image_embedding"`
+	ImageEmbeddingDim int       `json:"image_embedding_dim" yaml:"image_embedding_dim"` // must match the multimodal model (default 1024)
+
+	// Secondary embedding space (optional). When SecondaryEmbedding.Provider
+	// is set, every chunk is also embedded with this model into
+	// vec_chunks_secondary and queries fuse both vector legs, e.g. a
+	// multilingual model next to an English one so English questions find
+	// Spanish manuals without translation. SecondaryEmbeddingDim is required.
+	SecondaryEmbedding    LLMConfig `json:"secondary_embedding" yaml:"secondary_embedding"`
+	SecondaryEmbeddingDim int       `json:"secondary_embedding_dim" yaml:"secondary_embedding_dim"`
 
 	// Retrieval weights for RRF
 	WeightVector float64 `json:"weight_vector" yaml:"weight_vector"`
 	WeightFTS    float64 `json:"weight_fts" yaml:"weight_fts"`
 	WeightGraph  float64 `json:"weight_graph" yaml:"weight_graph"`
+	WeightSparse float64 `json:"weight_sparse" yaml:"weight_sparse"` // only used when Sparse is configured
+	WeightImage  float64 `json:"weight_image" yaml:"weight_image"`   // only used when ImageEmbedding is configured
+
+	WeightSecondary float64 `json:"weight_secondary" yaml:"weight_secondary"` // only used when SecondaryEmbedding is configured
+
+	// WeightNumeric weights the numeric leg, which ranks chunks stating
+	// values in the ranges a question gives ("between 70 and 90 PSIG",
+	// "above 10 bar"), with units normalised so "5.5 bar" matches too.
+	// 0 stops reading ranges from questions; WithNumericFilter still
+	// applies.
+	WeightNumeric float64 `json:"weight_numeric" yaml:"weight_numeric"`
+
+	// BM25 weight of a chunk's heading relative to its content (1) in the
+	// FTS leg. A term in a heading ("Condiciones ambientales") is a far
+	// stronger relevance signal than the same term in body text.
+	// 0 or 1 weights both alike.
+	FTSHeadingWeight float64 `json:"fts_heading_weight" yaml:"fts_heading_weight"`
+
+	// Relationship hops followed by the graph leg; paths are scored by the
+	// product of their relationship weights. 0 or 1 = direct links only.
+	GraphMaxDepth int `json:"graph_max_depth" yaml:"graph_max_depth"`
+
+	// Minimum cosine similarity between the question and an entity's
+	// description embedding for the entity to seed the graph leg, next to
+	// the entities matched by name. Entity descriptions are embedded at
+	// ingest while this is above 0; 0 disables vector seeding.
+	GraphSeedSimilarity float64 `json:"graph_seed_similarity" yaml:"graph_seed_similarity"`
+
+	// Entities linked to more than this fraction of the documents are stop
+	// entities: too generic ("system", "equipment") to start or pass
+	// through graph search. Applied once the corpus has 5 documents;
+	// 0 disables the automatic list.
+	StopEntityThreshold float64 `json:"stop_entity_threshold" yaml:"stop_entity_threshold"`
+
+	// Entity names always treated as stop entities (StopEntities) or never
+	// (KeepEntities), whatever their document frequency.
+	StopEntities []string `json:"stop_entities,omitempty" yaml:"stop_entities,omitempty"`
+	KeepEntities []string `json:"keep_entities,omitempty" yaml:"keep_entities,omitempty"`
+
+	// Per-leg retrieval timeout in milliseconds. A leg (vector, FTS, graph,
+	// ...) that runs past it is left out and the legs that finished are
+	// fused, with the missing leg listed in the trace's timed_out_legs.
+	// 0 = no per-leg limit.
+	RetrievalLegTimeoutMs int `json:"retrieval_leg_timeout_ms" yaml:"retrieval_leg_timeout_ms"`
+
+	// Cross-language query translation timeout in milliseconds; past it
+	// the query is searched untranslated. 0 = no limit.
+	TranslationTimeoutMs int `json:"translation_timeout_ms" yaml:"translation_timeout_ms"`
+
+	// Retrieval collapses chunks with identical content (the same boilerplate
+	// in many documents) into one result that lists the other copies, so
+	// they do not take several context slots. KeepDuplicateChunks returns
+	// each copy as its own result.
+	KeepDuplicateChunks bool `json:"keep_duplicate_chunks" yaml:"keep_duplicate_chunks"`
+
+	// Retrieval only searches documents that have been "ready" once, so
+	// answers are not drawn from documents whose first ingest is running
+	// or failed; a document being re-ingested stays searchable.
+	// SearchUnreadyDocuments searches every document, for
+	// debugging an ingest.
+	SearchUnreadyDocuments bool `json:"search_unready_documents" yaml:"search_unready_documents"`
+
+	// Every answer counts its sources as retrieved and, when it is accepted
+	// (found, confidence 0.5 or more), the sources it cites as cited.
+	// UsageBoost multiplies the fused score of chunks by up to
+	// 1+UsageBoost by those counts, so commonly useful sections surface
+	// faster. 0 counts without boosting.
+	UsageBoost float64 `json:"usage_boost" yaml:"usage_boost"`
+
+	// Days after which a past retrieval or citation counts half toward
+	// UsageBoost. 0 never decays.
+	UsageHalfLifeDays float64 `json:"usage_half_life_days" yaml:"usage_half_life_days"`
+
+	// Query embeddings kept in an in-memory LRU so repeated questions skip
+	// the embedding call. 0 disables the cache.
+	QueryCacheSize int `json:"query_cache_size" yaml:"query_cache_size"`
+
+	// QueryWorkers runs queries on this many worker engines, each with its
+	// own read-only SQLite connection and retriever, fed from a
+	// work-stealing queue. Ingestion and all other writes stay on the
+	// engine's own connection pool. 0 runs queries on the calling goroutine.
+	QueryWorkers int `json:"query_workers" yaml:"query_workers"`
+
+	// ReadOnly opens an existing database without creating the schema or
+	// running migrations, for query servers on a replica. Writes fail with
+	// ErrReadOnly and queries are logged only to QueryLogFile.
+	ReadOnly bool `json:"read_only" yaml:"read_only"`
+
+	// Immutable opens a read-only database as an immutable file: SQLite
+	// takes no locks and creates no -wal or -shm files, so the database
+	// can be baked into a container image on a read-only filesystem. The
+	// file must not change while the engine runs, and writes still in its
+	// WAL are not seen: close the engine that wrote it, or Checkpoint it,
+	// before baking. Requires ReadOnly.
+	Immutable bool `json:"immutable" yaml:"immutable"`
+
+	// QueryLogFile receives the query log of a read-only engine, which
+	// cannot write it to the database: each query is appended to the file
+	// as a JSON line, or written to standard output with "-". Empty
+	// leaves read-only queries unlogged. Setting it without ReadOnly, on
+	// an engine that is not a LiteFS replica, is an error.
+	QueryLogFile string `json:"query_log_file,omitempty" yaml:"query_log_file,omitempty"`
 
 	// Chunking
 	MaxChunkTokens int `json:"max_chunk_tokens" yaml:"max_chunk_tokens"`
 	ChunkOverlap   int `json:"chunk_overlap" yaml:"chunk_overlap"`
 
+	// ChunkTokenizers maps language codes (en, es, pt, fr, de, it) to
+	// tokenizer.json files used to count chunk tokens for text detected in
+	// that language; "" applies to all other text. Languages without one
+	// use a per-language words-to-tokens estimate, which is larger for
+	// Spanish and the other Romance and Germanic languages than English.
+	ChunkTokenizers map[string]string `json:"chunk_tokenizers,omitempty" yaml:"chunk_tokenizers,omitempty"`
+
+	// Custom chunk types with per-type retrieval boosts and prompt
+	// treatment. Built-in types (table, definition, requirement, ...) can
+	// be listed here too, to boost them or change how they are rendered.
+	ChunkTypes []ChunkTypeConfig `json:"chunk_types,omitempty" yaml:"chunk_types,omitempty"`
+
+	// Named document collections with their own retrieval and prompt
+	// presets, selected per query with WithCollection and assigned at
+	// ingest with WithIngestCollection.
+	Collections map[string]CollectionConfig `json:"collections,omitempty" yaml:"collections,omitempty"`
+
+	// EncryptionKeys encrypts chunk content and images at rest with
+	// AES-GCM, one key per collection (tenant): the key under a
+	// collection's name covers its documents, the key under "" documents
+	// outside any collection. Keys are base64-encoded 16, 24 or 32 bytes.
+	// Content is decrypted only when read back for retrieval, and chunks
+	// whose key is not configured are left out of results. Collections
+	// without a key are stored in plaintext.
+	EncryptionKeys map[string]string `json:"encryption_keys,omitempty" yaml:"encryption_keys,omitempty"`
+
+	// ChunkFilters run on every chunk at ingest before it is stored, to
+	// drop boilerplate, enrich metadata, or split chunks further (see
+	// ChunkFilter). Go API only; not read from config files.
+	ChunkFilters []ChunkFilter `json:"-" yaml:"-"`
+
+	// Pipeline inserts custom stages into every ingest and skips built-in
+	// ones (see package pipeline). nil runs the built-in stages only. Go
+	// API only; not read from config files.
+	Pipeline *pipeline.Pipeline `json:"-" yaml:"-"`
+
+	// ScoreAdjusters run on every fused retrieval candidate with the
+	// query, to boost or demote it by domain heuristics such as a model
+	// name or clause number in its metadata (see ScoreAdjuster). Go API
+	// only; not read from config files.
+	ScoreAdjusters []ScoreAdjuster `json:"-" yaml:"-"`
+
+	// Ingestion backpressure. At most IngestConcurrency Ingest calls run at
+	// once (0 = unlimited); up to IngestQueueSize more wait for a slot
+	// (0 = wait without limit) and further calls fail with
+	// ErrIngestQueueFull.
+	IngestConcurrency int `json:"ingest_concurrency" yaml:"ingest_concurrency"`
+	IngestQueueSize   int `json:"ingest_queue_size" yaml:"ingest_queue_size"`
+
+	// Minutes a session document (WithIngestSession) is kept after its
+	// session's last ingest or query; expired ones are deleted. 0 means 60.
+	SessionTTLMinutes int `json:"session_ttl_minutes" yaml:"session_ttl_minutes"`
+
 	// Graph building
 	SkipGraph        bool `json:"skip_graph" yaml:"skip_graph"`                 // Skip knowledge graph extraction during ingest
 	GraphConcurrency int  `json:"graph_concurrency" yaml:"graph_concurrency"`   // Max parallel LLM calls for graph extraction (default 16)
 
+	// GraphChunkTypes limits graph extraction to chunks of these types,
+	// e.g. ["paragraph", "definition", "requirement"]; empty extracts all
+	// types. Chunks of GraphSkipChunkTypes are never extracted, e.g.
+	// ["table"] on corpora whose table fragments yield junk entities.
+	// Left-out chunks are still embedded and searchable, and are counted
+	// as skipped in the document's graph report.
+	GraphChunkTypes     []string `json:"graph_chunk_types,omitempty" yaml:"graph_chunk_types,omitempty"`
+	GraphSkipChunkTypes []string `json:"graph_skip_chunk_types,omitempty" yaml:"graph_skip_chunk_types,omitempty"`
+
+	// Community hierarchy levels: 1 = connected components only, 2 (default)
+	// = components split further by modularity. Used after each ingest and
+	// by RebuildCommunities.
+	CommunityLevels int `json:"community_levels,omitempty" yaml:"community_levels,omitempty"`
+
+	// Candidate contradictions DetectContradictions verifies with the chat
+	// model per run (default 200); the rest are reported as unchecked.
+	MaxContradictionChecks int `json:"max_contradiction_checks,omitempty" yaml:"max_contradiction_checks,omitempty"`
+
+	// Only communities that are new or whose member entities changed are
+	// summarised. With CommunitySummaryIntervalMinutes set, ingest updates
+	// the communities without summarising them and the stale ones are
+	// summarised every that many minutes instead; 0 summarises them after
+	// each ingest.
+	CommunitySummaryIntervalMinutes int `json:"community_summary_interval_minutes,omitempty" yaml:"community_summary_interval_minutes,omitempty"`
+
+	// Document summaries
+	SkipSummary bool `json:"skip_summary" yaml:"skip_summary"` // Skip LLM summary + keyword generation during ingest
+
+	// SkipGlossary turns off abbreviation glossary extraction at ingest and
+	// the glossary section of the reasoning prompt.
+	SkipGlossary bool `json:"skip_glossary" yaml:"skip_glossary"`
+
+	// KeyFacts are the schemas of the key-facts record extracted from each
+	// document at ingest with one LLM call: the model picks the schema
+	// that fits the document and fills in its fields. Empty disables
+	// extraction.
+	KeyFacts []KeyFactSchemaConfig `json:"key_facts,omitempty" yaml:"key_facts,omitempty"`
+
+	// InjectionPolicy controls the ingest-time scan for prompt injection:
+	// InjectionFlag strips chat control tokens and marks chunks with text
+	// addressed to the model, InjectionDrop leaves such chunks out of the
+	// index, and InjectionOff (default) stores text unchanged. With a scan,
+	// the reasoning prompt also quotes source text (see
+	// reasoning.Config.QuoteSources).
+	InjectionPolicy string `json:"injection_policy,omitempty" yaml:"injection_policy,omitempty"`
+
 	// Reasoning
 	MaxRounds           int     `json:"max_rounds" yaml:"max_rounds"`
 	ConfidenceThreshold float64 `json:"confidence_threshold" yaml:"confidence_threshold"`
+	ReasoningStrategy   string  `json:"reasoning_strategy" yaml:"reasoning_strategy"` // multi_round (default), single_shot, react, plan_execute
+
+	// EscalationThreshold is the confidence below which an answer from the
+	// Chat model is reasoned again with the Escalation model. Answers with
+	// validation issues are escalated too. 0 uses ConfidenceThreshold.
+	EscalationThreshold float64 `json:"escalation_threshold,omitempty" yaml:"escalation_threshold,omitempty"`
+
+	// ReasoningSearchBudget is how many follow-up searches the reasoner may
+	// request per query when the retrieved context lacks evidence, e.g. for
+	// the second hop of a multi-hop question: in any answer or refinement
+	// round the model can call a search tool and is asked again with the
+	// results added. Each search is a "search" step in the trace. Applies
+	// to multi_round and plan_execute (react searches on its own). 0 (the
+	// default) disables it.
+	ReasoningSearchBudget int `json:"reasoning_search_budget" yaml:"reasoning_search_budget"`
+
+	// LLMCallEstimateMs is how long one reasoning LLM call is assumed to
+	// take until calls have been timed. When a query's context has a
+	// deadline, reasoning fits its rounds to it: it skips refinement and
+	// follow-up retrieval, and falls back to a single prompt, rather than
+	// start a call that would be cancelled. Default 5000.
+	LLMCallEstimateMs int `json:"llm_call_estimate_ms,omitempty" yaml:"llm_call_estimate_ms,omitempty"`
+
+	// StreamValidation streams answer and refinement replies from the chat
+	// provider and stops one as soon as it states a quantity, such as
+	// "12 bar", whose number is not in the sources or the question. The
+	// round is then asked again once, saying why, instead of paying for
+	// the rest of a reply validation would reject. Each stop is a
+	// "stream_abort" step in the trace. Providers that do not stream
+	// (gemini, onnx) and reasoning models are not checked.
+	StreamValidation bool `json:"stream_validation,omitempty" yaml:"stream_validation,omitempty"`
+
+	// Extra criteria the validation round enforces on every answer, e.g.
+	// "cite page numbers" or "at most 150 words". Violations are reported
+	// in the validation step's issues and trigger a refinement round.
+	ValidationChecks []ValidationCheckConfig `json:"validation_checks,omitempty" yaml:"validation_checks,omitempty"`
+
+	// Context assembly (0 = unlimited). Keeps one document from monopolising
+	// the reasoning context in multi-document corpora.
+	ContextMaxChunks          int `json:"context_max_chunks" yaml:"context_max_chunks"`                       // total chunks sent to the reasoner
+	ContextMaxChunksPerDoc    int `json:"context_max_chunks_per_doc" yaml:"context_max_chunks_per_doc"`       // cap per document
+	ContextMaxTokensPerSource int `json:"context_max_tokens_per_source" yaml:"context_max_tokens_per_source"` // truncate long chunks
+	ContextMinDocuments       int `json:"context_min_documents" yaml:"context_min_documents"`                 // distinct documents to include when available
 
 	// Image captioning
 	CaptionImages bool `json:"caption_images" yaml:"caption_images"` // Opt-in: caption extracted images via vision LLM
@@ -50,16 +313,226 @@ type Config struct {
 	// External parsing
 	LlamaParse *LlamaParseConfig `json:"llamaparse,omitempty" yaml:"llamaparse,omitempty"`
 
+	// Analytics mirror (optional): periodic Parquet exports for analysts.
+	Analytics *AnalyticsConfig `json:"analytics,omitempty" yaml:"analytics,omitempty"`
+
+	// Out-of-scope refusal (optional): questions clearly outside the
+	// knowledge base's topics are refused before retrieval (see
+	// ScopeConfig).
+	Scope *ScopeConfig `json:"scope,omitempty" yaml:"scope,omitempty"`
+
+	// Replication (optional): coordinate WAL checkpoints with Litestream
+	// or detect LiteFS replicas (see ReplicationConfig).
+	Replication *ReplicationConfig `json:"replication,omitempty" yaml:"replication,omitempty"`
+
+	// Retrieval A/B experiments: the server splits query traffic across
+	// each experiment's arms and logs answers per arm (see
+	// ExperimentConfig).
+	Experiments []ExperimentConfig `json:"experiments,omitempty" yaml:"experiments,omitempty"`
+
+	// Server logging: outputs, rotation, and levels for cmd/server (see
+	// LogConfig). The library itself logs through slog.Default.
+	Logging *LogConfig `json:"logging,omitempty" yaml:"logging,omitempty"`
+
+	// Public demo mode for cmd/server: a read-only corpus, per-IP rate
+	// limits, token ceilings, and watermarked answers (see DemoConfig).
+	// The library ignores it.
+	Demo *DemoConfig `json:"demo,omitempty" yaml:"demo,omitempty"`
+
 	// Embedding dimensions (must match model)
 	EmbeddingDim int `json:"embedding_dim" yaml:"embedding_dim"`
+
+	// What to do when the configured embedding model differs from the one
+	// that produced the stored vectors: "error" (default) or "reembed".
+	EmbeddingDriftPolicy string `json:"embedding_drift_policy" yaml:"embedding_drift_policy"`
+
+	// Which part of a chunk too long for the embedding model is embedded:
+	// "head" (default), "tail", "head_tail", or "summarize" (LLM summary of
+	// the whole chunk; one chat call per oversized chunk). Contracts often
+	// put the operative language at the end of a long clause.
+	EmbedTruncation string `json:"embed_truncation,omitempty" yaml:"embed_truncation,omitempty"`
 }
 
 // LLMConfig configures a single LLM provider endpoint.
 type LLMConfig struct {
-	Provider string `json:"provider" yaml:"provider"` // ollama, lmstudio, openrouter, xai, gemini, custom
+	Provider string `json:"provider" yaml:"provider"` // ollama, lmstudio, openrouter, xai, gemini, custom, onnx (embeddings only)
 	Model    string `json:"model" yaml:"model"`
 	BaseURL  string `json:"base_url" yaml:"base_url"`
 	APIKey   string `json:"api_key" yaml:"api_key"`
+	// CacheControl marks cacheable prompt prefixes with cache_control
+	// breakpoints, for custom endpoints serving Anthropic models. OpenRouter
+	// always sends them; OpenAI and Gemini cache without them.
+	CacheControl bool `json:"cache_control,omitempty" yaml:"cache_control,omitempty"`
+	// ONNXLibrary is the ONNX Runtime shared library loaded by the onnx
+	// provider, whose Model is a local model directory. Empty loads the
+	// system's default.
+	ONNXLibrary string `json:"onnx_library,omitempty" yaml:"onnx_library,omitempty"`
+	// PromptStyle is "chat" or "reasoning", for chat models whose name does
+	// not tell. Reasoning models (o1, o3, DeepSeek R1, QwQ) get no system
+	// message, temperature, JSON mode, or step-by-step instructions. Empty
+	// detects the style from Model.
+	PromptStyle string `json:"prompt_style,omitempty" yaml:"prompt_style,omitempty"`
+}
+
+// ChunkTypeConfig registers a chunk type.
+type ChunkTypeConfig struct {
+	Name string `json:"name" yaml:"name"`
+
+	// Match is an optional regular expression. Generic chunks (section,
+	// paragraph) whose heading or content match are assigned this type at
+	// ingest. Sections the parser already labels with Name keep it.
+	Match string `json:"match,omitempty" yaml:"match,omitempty"`
+
+	// Boost multiplies the fused retrieval score of chunks of this type
+	// (0 or 1 = neutral).
+	Boost float64 `json:"boost,omitempty" yaml:"boost,omitempty"`
+
+	// Render controls how chunks of this type appear in the reasoning
+	// prompt: "" (as stored) or "markdown_table".
+	Render string `json:"render,omitempty" yaml:"render,omitempty"`
+
+	// Instruction is a note shown to the model once whenever chunks of
+	// this type are in the context, e.g. "quote requirements verbatim".
+	Instruction string `json:"instruction,omitempty" yaml:"instruction,omitempty"`
+}
+
+// KeyFactSchemaConfig is a kind of document and the fields of its
+// key-facts record. The built-in schemas "contract" (parties, dates,
+// amounts, ...) and "manual" (model numbers, voltages, standards, ...) are
+// used when listed by name without fields.
+type KeyFactSchemaConfig struct {
+	Name string `json:"name" yaml:"name"`
+
+	// Description tells the model which documents the schema is for,
+	// e.g. "supply agreements and purchase orders".
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+
+	Fields []KeyFactFieldConfig `json:"fields,omitempty" yaml:"fields,omitempty"`
+}
+
+// KeyFactFieldConfig is a field of a key-facts schema. A field may hold
+// several values, such as the parties of a contract.
+type KeyFactFieldConfig struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// ValidationCheckConfig is an answer criterion for the validation round.
+type ValidationCheckConfig struct {
+	// Check is one of: "cite_pages", "cite_articles" (article, clause, or
+	// section numbers), "max_words", "bullets", "must_match", or
+	// "must_not_match".
+	Check string `json:"check" yaml:"check"`
+
+	Limit   int    `json:"limit,omitempty" yaml:"limit,omitempty"`     // word limit for max_words
+	Pattern string `json:"pattern,omitempty" yaml:"pattern,omitempty"` // regular expression for must_match / must_not_match
+
+	// Message replaces the default issue text, and describes pattern
+	// checks to the model.
+	Message string `json:"message,omitempty" yaml:"message,omitempty"`
+}
+
+// CollectionConfig is the preset of a document collection. Zero values
+// fall back to the engine-wide settings.
+type CollectionConfig struct {
+	// Retrieval defaults for queries scoped to the collection. Weights
+	// passed with WithWeights still take precedence.
+	WeightVector float64 `json:"weight_vector,omitempty" yaml:"weight_vector,omitempty"`
+	WeightFTS    float64 `json:"weight_fts,omitempty" yaml:"weight_fts,omitempty"`
+	WeightGraph  float64 `json:"weight_graph,omitempty" yaml:"weight_graph,omitempty"`
+	MaxResults   int     `json:"max_results,omitempty" yaml:"max_results,omitempty"`
+
+	// Chunking for documents ingested into the collection.
+	MaxChunkTokens int `json:"max_chunk_tokens,omitempty" yaml:"max_chunk_tokens,omitempty"`
+	ChunkOverlap   int `json:"chunk_overlap,omitempty" yaml:"chunk_overlap,omitempty"`
+
+	// SystemPrompt is appended to the reasoning rules, e.g. "Cite clause
+	// numbers for every obligation."
+	SystemPrompt string `json:"system_prompt,omitempty" yaml:"system_prompt,omitempty"`
+}
+
+// AnalyticsConfig configures the analytics mirror, which rewrites
+// documents, chunks, query log, audit log, and eval results as Parquet files
+// in Dir on a schedule (see the analytics package).
+type AnalyticsConfig struct {
+	Dir             string `json:"dir" yaml:"dir"`
+	IntervalMinutes int    `json:"interval_minutes" yaml:"interval_minutes"`               // default 60
+	EvalRunsDir     string `json:"eval_runs_dir,omitempty" yaml:"eval_runs_dir,omitempty"` // cmd/eval run directories to include
+}
+
+// ScopeConfig describes what the knowledge base covers. Method "llm" (the
+// default) asks the chat model whether each question is about Topics;
+// "embedding" refuses a question whose embedding's cosine similarity to
+// the centroid of Topics and Examples is below MinSimilarity, without an
+// LLM call.
+type ScopeConfig struct {
+	Topics        string   `json:"topics" yaml:"topics"`                                     // e.g. "maintenance and safety of Acme pumps"
+	Examples      []string `json:"examples,omitempty" yaml:"examples,omitempty"`             // in-scope questions for the centroid
+	Method        string   `json:"method,omitempty" yaml:"method,omitempty"`                 // "llm" (default) or "embedding"
+	MinSimilarity float64  `json:"min_similarity,omitempty" yaml:"min_similarity,omitempty"` // default 0.3
+	Refusal       string   `json:"refusal,omitempty" yaml:"refusal,omitempty"`               // answer text; defaults to naming Topics
+}
+
+// ReplicationConfig describes how the database is replicated. Mode
+// "litestream" turns off SQLite's automatic checkpoints so Litestream
+// decides when the WAL is folded back, and optionally checkpoints PASSIVE
+// every CheckpointIntervalSeconds. Mode "litefs" opens the engine
+// read-only when Dir (the LiteFS mount, by default the database's
+// directory) holds a .primary file, which LiteFS writes on replicas only.
+// The hooks run around every checkpoint the engine makes; they are Go API
+// only.
+type ReplicationConfig struct {
+	Mode                      string `json:"mode" yaml:"mode"`                                                                   // "litestream" or "litefs"
+	CheckpointIntervalSeconds int    `json:"checkpoint_interval_seconds,omitempty" yaml:"checkpoint_interval_seconds,omitempty"` // 0 leaves checkpoints to Litestream
+	Dir                       string `json:"dir,omitempty" yaml:"dir,omitempty"`                                                 // LiteFS mount
+
+	// BeforeCheckpoint runs before each checkpoint; an error skips it.
+	BeforeCheckpoint func(ctx context.Context) error `json:"-" yaml:"-"`
+	// AfterCheckpoint runs after each successful checkpoint.
+	AfterCheckpoint func(ctx context.Context, cp Checkpoint) `json:"-" yaml:"-"`
+}
+
+// LogConfig configures cmd/server's logs. Level is the minimum level
+// ("debug", "info", "warn", "error"; default "info"), and Components
+// overrides it per component: "engine" (the root package), "retrieval",
+// "reasoning", "llm", "graph", "store", "parser", "server", and the other
+// package names. Records go to every output as JSON lines; with no outputs
+// they go to stdout.
+type LogConfig struct {
+	Level      string            `json:"level,omitempty" yaml:"level,omitempty"`
+	Components map[string]string `json:"components,omitempty" yaml:"components,omitempty"` // e.g. {"retrieval": "debug"}
+	Outputs    []LogOutput       `json:"outputs,omitempty" yaml:"outputs,omitempty"`
+}
+
+// DemoConfig makes cmd/server safe to expose as a public playground over
+// a prepared corpus. The database is opened read-only, every route that
+// writes, ingests, or runs administrative work is refused, and each client
+// IP gets RequestsPerMinute requests and TokensPerIP LLM tokens per day,
+// with TokensPerDay shared by all. Questions are cut to MaxQuestionChars
+// and answered in at most MaxRounds rounds, and answers carry Watermark.
+// Zero values take the defaults in parentheses.
+type DemoConfig struct {
+	RequestsPerMinute int    `json:"requests_per_minute,omitempty" yaml:"requests_per_minute,omitempty"` // (10)
+	TokensPerIP       int    `json:"tokens_per_ip,omitempty" yaml:"tokens_per_ip,omitempty"`             // per day (50000)
+	TokensPerDay      int    `json:"tokens_per_day,omitempty" yaml:"tokens_per_day,omitempty"`           // all clients (1000000)
+	MaxQuestionChars  int    `json:"max_question_chars,omitempty" yaml:"max_question_chars,omitempty"`   // (500)
+	MaxRounds         int    `json:"max_rounds,omitempty" yaml:"max_rounds,omitempty"`                   // (2)
+	Watermark         string `json:"watermark,omitempty" yaml:"watermark,omitempty"`
+	// TrustProxy takes the client IP from X-Forwarded-For, for a server
+	// behind a reverse proxy; otherwise the connection's address is used.
+	TrustProxy bool `json:"trust_proxy,omitempty" yaml:"trust_proxy,omitempty"`
+}
+
+// LogOutput is one destination of the server's logs: Type "stdout",
+// "stderr", or "file". A file is rotated when it would grow past
+// MaxSizeMB, keeping the rotated files for MaxAgeDays and at most
+// MaxBackups of them (0 keeps them all).
+type LogOutput struct {
+	Type       string `json:"type" yaml:"type"`
+	Path       string `json:"path,omitempty" yaml:"path,omitempty"`               // file outputs
+	MaxSizeMB  int    `json:"max_size_mb,omitempty" yaml:"max_size_mb,omitempty"` // default 100
+	MaxAgeDays int    `json:"max_age_days,omitempty" yaml:"max_age_days,omitempty"`
+	MaxBackups int    `json:"max_backups,omitempty" yaml:"max_backups,omitempty"`
 }
 
 // LlamaParseConfig configures the LlamaParse external parsing service.
@@ -89,14 +562,29 @@ func DefaultConfig() Config {
 			Model:    "llama3.2-vision",
 			BaseURL:  "http://localhost:11434",
 		},
-		WeightVector:        1.0,
-		WeightFTS:           1.0,
-		WeightGraph:         0.5,
-		MaxChunkTokens:      1024,
-		ChunkOverlap:        128,
-		MaxRounds:           3,
-		ConfidenceThreshold: 0.7,
-		EmbeddingDim:        768,
+		WeightVector:          1.0,
+		WeightFTS:             1.0,
+		WeightGraph:           0.5,
+		WeightSparse:          1.0,
+		WeightImage:           1.0,
+		WeightSecondary:       1.0,
+		WeightNumeric:         1.0,
+		FTSHeadingWeight:      3.0,
+		GraphMaxDepth:         2,
+		GraphSeedSimilarity:   0.6,
+		StopEntityThreshold:   0.5,
+		QueryCacheSize:        1024,
+		RetrievalLegTimeoutMs: 10000,
+		TranslationTimeoutMs:  5000,
+		UsageHalfLifeDays:     30,
+		MaxChunkTokens:        1024,
+		ChunkOverlap:          128,
+		IngestConcurrency:     2,
+		IngestQueueSize:       16,
+		SessionTTLMinutes:     60,
+		MaxRounds:             3,
+		ConfidenceThreshold:   0.7,
+		EmbeddingDim:          768,
 	}
 }
 
@@ -0,0 +1,132 @@
+package goreason
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bbiangul/go-reason/llm/llmtest"
+	"github.com/bbiangul/go-reason/store"
+)
+
+// linkOpensAt records that chunkID gives value as the relief valve's
+// opening pressure.
+func linkOpensAt(ctx context.Context, st *store.Store, chunkID int64, value string) {
+	var valve int64
+	if found, _ := st.GetEntitiesByNames(ctx, []string{"relief valve"}); len(found) > 0 {
+		valve = found[0].ID
+		st.LinkEntityChunk(ctx, valve, chunkID)
+	} else {
+		valve, _ = st.UpsertEntityAndLink(ctx, store.Entity{Name: "relief valve", EntityType: "concept"}, chunkID)
+	}
+	pressure, _ := st.UpsertEntityAndLink(ctx, store.Entity{Name: value, EntityType: "term"}, chunkID)
+	st.InsertRelationship(ctx, store.Relationship{SourceEntityID: valve, TargetEntityID: pressure, RelationType: "opens_at", Weight: 1, SourceChunkID: &chunkID})
+}
+
+func TestDetectContradictions(t *testing.T) {
+	eng, srv, pump := faultEngine(t, nil)
+	ctx := context.Background()
+	other := filepath.Join(filepath.Dir(pump), "pump-rev-b.txt")
+	if err := os.WriteFile(other, []byte("Pump maintenance, revision B.\n\nThe relief valve opens at 12 bar.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var docs []int64
+	for _, path := range []string{pump, other} {
+		id, err := eng.Ingest(ctx, path)
+		if err != nil {
+			t.Fatalf("Ingest(%s): %v", path, err)
+		}
+		docs = append(docs, id)
+	}
+
+	// Each document links the relief valve to its own set pressure.
+	st := eng.(*engine).store
+	for i, value := range []string{"10 bar", "12 bar"} {
+		chunks, err := st.GetChunksByDocument(ctx, docs[i])
+		if err != nil || len(chunks) == 0 {
+			t.Fatalf("chunks of %d: %v", docs[i], err)
+		}
+		linkOpensAt(ctx, st, chunks[0].ID, value)
+	}
+
+	var mu sync.Mutex
+	var prompts []string
+	answer := srv.ChatFunc
+	srv.ChatFunc = func(prompt string) string {
+		mu.Lock()
+		prompts = append(prompts, prompt)
+		mu.Unlock()
+		if strings.Contains(prompt, "Do the passages contradict each other") {
+			return `{"conflict": true, "attribute": "opening pressure", "value_a": "10 bar", "value_b": "12 bar", "explanation": "Revision B raised the set pressure."}`
+		}
+		return answer(prompt)
+	}
+
+	report, err := eng.DetectContradictions(ctx)
+	if err != nil {
+		t.Fatalf("DetectContradictions: %v", err)
+	}
+	if report.Candidates != 1 || report.Checked != 1 || len(report.Contradictions) != 1 {
+		t.Fatalf("report = %+v", report)
+	}
+	c := report.Contradictions[0]
+	if c.Entity != "relief valve" || c.Attribute != "opening pressure" || c.FilenameA != "pump.txt" || c.FilenameB != "pump-rev-b.txt" {
+		t.Errorf("contradiction = %+v", c)
+	}
+
+	// The answer's sources carry the conflict, and so does the prompt.
+	res, err := eng.Query(ctx, "At what pressure does the relief valve open?")
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	flagged := 0
+	for _, s := range res.Sources {
+		flagged += len(s.Conflicts)
+	}
+	if flagged == 0 {
+		t.Errorf("no source flagged: %+v", res.Sources)
+	}
+	mu.Lock()
+	last := prompts[len(prompts)-1]
+	mu.Unlock()
+	if !strings.Contains(last, `[Conflict: opening pressure of relief valve is "10 bar" here but "12 bar" in pump-rev-b.txt]`) {
+		t.Errorf("answer prompt does not note the conflict:\n%s", last)
+	}
+
+	entries, err := eng.AuditLog(ctx, AuditFilter{Operation: AuditDetectContradictions})
+	if err != nil || len(entries) != 1 || entries[0].Params["contradictions"] != "1" {
+		t.Errorf("audit = %+v, %v", entries, err)
+	}
+}
+
+func TestDetectContradictionsKeepsReportWhenChecksFail(t *testing.T) {
+	eng, _, pump := faultEngine(t, &llmtest.Scenario{Rules: []llmtest.Rule{
+		{Op: llmtest.OpChat, Match: "contradict each other", Fault: llmtest.FaultMalformedJSON, Body: "not json"},
+	}})
+	ctx := context.Background()
+	id, err := eng.Ingest(ctx, pump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := eng.(*engine).store
+	chunks, _ := st.GetChunksByDocument(ctx, id)
+	other, _ := st.UpsertDocument(ctx, store.Document{Path: "/other.txt", Filename: "other.txt", Format: "txt", ContentHash: "x", ParseMethod: "native", Status: "ready"})
+	otherIDs, _ := st.InsertChunks(ctx, []store.Chunk{{DocumentID: other, Content: "The relief valve opens at 12 bar.", ChunkType: "paragraph", TokenCount: 8}})
+	linkOpensAt(ctx, st, chunks[0].ID, "10 bar")
+	linkOpensAt(ctx, st, otherIDs[0], "12 bar")
+	previous := []store.Contradiction{{EntityID: 1, Attribute: "earlier", ChunkA: chunks[0].ID, ValueA: "a", ChunkB: otherIDs[0], ValueB: "b"}}
+	if err := st.ReplaceContradictions(ctx, previous); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := eng.DetectContradictions(ctx); !errors.Is(err, ErrLLMRequestFailed) {
+		t.Errorf("DetectContradictions = %v, want ErrLLMRequestFailed", err)
+	}
+	if list, _ := eng.Contradictions(ctx); len(list) != 1 || list[0].Attribute != "earlier" {
+		t.Errorf("contradictions = %+v, want the previous report kept", list)
+	}
+}
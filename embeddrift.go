@@ -0,0 +1,191 @@
+package goreason
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/bbiangul/go-reason/llm"
+	"github.com/bbiangul/go-reason/store"
+)
+
+// Embedding drift policies for Config.EmbeddingDriftPolicy.
+const (
+	EmbeddingDriftError   = "error"   // refuse queries and ingests until Reembed is called (default)
+	EmbeddingDriftReembed = "reembed" // re-embed the corpus with the configured model at startup
+)
+
+// configuredEmbeddingModel describes the model that would produce new chunk
+// vectors under cfg.
+func configuredEmbeddingModel(cfg Config) store.EmbeddingModel {
+	return store.EmbeddingModel{
+		Space:    store.ChunkVectorSpace,
+		Provider: cfg.Embedding.Provider,
+		Model:    cfg.Embedding.Model,
+		Dim:      cfg.EmbeddingDim,
+	}
+}
+
+// checkEmbeddingModel compares the configured embedding model with the one
+// recorded for the stored chunk vectors. An empty vector table adopts the
+// configured model, as do vectors that predate model tracking. A different
+// model name or dimension returns an error wrapping
+// ErrEmbeddingModelMismatch; the provider is recorded for reference only,
+// since the same model may be served by several providers.
+func checkEmbeddingModel(ctx context.Context, s *store.Store, want store.EmbeddingModel) error {
+	have, err := s.GetEmbeddingModel(ctx, want.Space)
+	if err != nil {
+		return fmt.Errorf("reading embedding model: %w", err)
+	}
+	if have != nil && have.Model == want.Model && have.Dim == want.Dim {
+		return nil
+	}
+
+	hasVectors, err := s.HasEmbeddings(ctx)
+	if err != nil {
+		return fmt.Errorf("checking stored embeddings: %w", err)
+	}
+	switch {
+	case !hasVectors:
+		if have != nil && have.Dim != want.Dim {
+			if err := s.ResetChunkVectors(ctx, want.Dim); err != nil {
+				return err
+			}
+		}
+	case have == nil:
+		slog.InfoContext(ctx, "recording embedding model for existing vectors",
+			"model", want.Model, "dim", want.Dim)
+	default:
+		return fmt.Errorf("%w: stored vectors were produced by %q (%d dims), configured model is %q (%d dims)",
+			ErrEmbeddingModelMismatch, have.Model, have.Dim, want.Model, want.Dim)
+	}
+	return s.SetEmbeddingModel(ctx, want)
+}
+
+// verifyEmbeddingModel is checkEmbeddingModel for read-only engines: it
+// reports a mismatch with the recorded model but records nothing.
+func verifyEmbeddingModel(ctx context.Context, s *store.Store, want store.EmbeddingModel) error {
+	have, err := s.GetEmbeddingModel(ctx, want.Space)
+	if err != nil {
+		return fmt.Errorf("reading embedding model: %w", err)
+	}
+	if have == nil || (have.Model == want.Model && have.Dim == want.Dim) {
+		return nil
+	}
+	return fmt.Errorf("%w: stored vectors were produced by %q (%d dims), configured model is %q (%d dims)",
+		ErrEmbeddingModelMismatch, have.Model, have.Dim, want.Model, want.Dim)
+}
+
+// resetQueryCaches drops the query embeddings cached by e's retriever and
+// its query workers'.
+func (e *engine) resetQueryCaches() {
+	e.retriever.ResetQueryCache()
+	if e.workers != nil {
+		for _, w := range e.workers.workers {
+			w.retriever.ResetQueryCache()
+		}
+	}
+}
+
+// embeddingDrift returns the pending drift error, if any.
+func (e *engine) embeddingDrift() error {
+	if e.primary != nil {
+		return e.primary.embeddingDrift()
+	}
+	e.driftMu.RLock()
+	defer e.driftMu.RUnlock()
+	return e.embedDrift
+}
+
+// Reembed re-embeds every chunk with the configured embedding model,
+// replaces the chunk vectors with the new ones once all are done, and
+// records that model as the producer of the stored vectors. It clears a
+// pending embedding drift. Entity description vectors are re-embedded too
+// when graph seeding is on. When a secondary embedding model is
+// configured, chunks missing a secondary vector are backfilled too. It
+// waits for running ingests and holds back new ones while it runs; if it
+// fails, the old vectors stay in place.
+func (e *engine) Reembed(ctx context.Context) error {
+	if err := e.writable(); err != nil {
+		return err
+	}
+	ctx = llm.WithPhase(ctx, llm.PhaseIngest)
+	start := time.Now()
+	want := configuredEmbeddingModel(e.cfg)
+	err := e.reembed(ctx, want)
+	e.audit(ctx, AuditReembed, start, 0, map[string]string{
+		"provider": want.Provider, "model": want.Model, "dim": strconv.Itoa(want.Dim),
+	}, err)
+	return err
+}
+
+// reembed does the work of Reembed.
+func (e *engine) reembed(ctx context.Context, want store.EmbeddingModel) (err error) {
+	defer e.lockIngests()()
+
+	// New vectors are staged and swapped in at the end, so a failure
+	// part way leaves the old ones searchable.
+	if err := e.store.StageChunkVectors(ctx, want.Dim); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			if derr := e.store.DiscardStagedChunkVectors(context.WithoutCancel(ctx)); derr != nil {
+				slog.WarnContext(ctx, "reembed: dropping staged vectors failed", "error", derr)
+			}
+		}
+	}()
+
+	docs, err := e.store.ListDocuments(ctx)
+	if err != nil {
+		return fmt.Errorf("listing documents: %w", err)
+	}
+	for _, doc := range docs {
+		chunks, err := e.store.GetChunksByDocument(ctx, doc.ID)
+		if err != nil {
+			return fmt.Errorf("loading chunks for document %d: %w", doc.ID, err)
+		}
+		if len(chunks) == 0 {
+			continue
+		}
+		ids := make([]int64, len(chunks))
+		for i, c := range chunks {
+			ids[i] = c.ID
+		}
+		if _, err := e.embedChunksInto(ctx, chunks, ids, e.store.InsertStagedEmbedding); err != nil {
+			return fmt.Errorf("re-embedding document %d: %w", doc.ID, err)
+		}
+		slog.InfoContext(ctx, "re-embedded document", "document_id", doc.ID, "chunks", len(chunks))
+	}
+
+	if err := e.store.SwapChunkVectors(ctx, want.Dim); err != nil {
+		return err
+	}
+
+	if err := e.store.SetEmbeddingModel(ctx, want); err != nil {
+		return fmt.Errorf("recording embedding model: %w", err)
+	}
+	e.driftMu.Lock()
+	e.embedDrift = nil
+	e.driftMu.Unlock()
+	e.resetQueryCaches()
+
+	if e.cfg.GraphSeedSimilarity > 0 {
+		n, err := e.embedEntities(ctx)
+		if err != nil {
+			return fmt.Errorf("re-embedding entities: %w", err)
+		}
+		slog.InfoContext(ctx, "re-embedded entities", "entities", n)
+	}
+
+	if e.secondaryLLM != nil {
+		n, err := e.backfillSecondary(ctx)
+		if err != nil {
+			return fmt.Errorf("backfilling secondary vectors: %w", err)
+		}
+		slog.InfoContext(ctx, "backfilled secondary vectors", "chunks", n)
+	}
+	return nil
+}
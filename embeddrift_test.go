@@ -0,0 +1,131 @@
+package goreason
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/bbiangul/go-reason/llm"
+	"github.com/bbiangul/go-reason/store"
+)
+
+func TestCheckEmbeddingModel(t *testing.T) {
+	ctx := context.Background()
+	s, err := store.New(filepath.Join(t.TempDir(), "drift.db"), 4)
+	if err != nil {
+		t.Fatalf("store.New: %v", err)
+	}
+	defer s.Close()
+
+	nomic := store.EmbeddingModel{Space: store.ChunkVectorSpace, Provider: "ollama", Model: "nomic-embed-text", Dim: 4}
+
+	// An empty corpus adopts the configured model.
+	if err := checkEmbeddingModel(ctx, s, nomic); err != nil {
+		t.Fatalf("first check: %v", err)
+	}
+	if m, _ := s.GetEmbeddingModel(ctx, store.ChunkVectorSpace); m == nil || m.Model != "nomic-embed-text" {
+		t.Fatalf("recorded model = %+v", m)
+	}
+
+	docID, err := s.UpsertDocument(ctx, store.Document{Path: "/d.txt", Filename: "d.txt", Format: "txt", ContentHash: "h", Status: "ready"})
+	if err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	ids, err := s.InsertChunks(ctx, []store.Chunk{{DocumentID: docID, Content: "x", ChunkType: "paragraph"}})
+	if err != nil {
+		t.Fatalf("insert chunks: %v", err)
+	}
+	if err := s.InsertEmbedding(ctx, ids[0], []float32{1, 0, 0, 0}); err != nil {
+		t.Fatalf("insert embedding: %v", err)
+	}
+
+	// Same model from another provider is not drift.
+	lmstudio := nomic
+	lmstudio.Provider = "lmstudio"
+	if err := checkEmbeddingModel(ctx, s, lmstudio); err != nil {
+		t.Errorf("provider change flagged as drift: %v", err)
+	}
+
+	other := nomic
+	other.Model = "text-embedding-3-small"
+	if err := checkEmbeddingModel(ctx, s, other); !errors.Is(err, ErrEmbeddingModelMismatch) {
+		t.Errorf("expected ErrEmbeddingModelMismatch, got %v", err)
+	}
+
+	// Once vectors are gone a new model (and dimension) is adopted.
+	if err := s.ResetChunkVectors(ctx, 4); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	other.Dim = 8
+	if err := checkEmbeddingModel(ctx, s, other); err != nil {
+		t.Fatalf("check after reset: %v", err)
+	}
+	if err := s.InsertEmbedding(ctx, ids[0], make([]float32, 8)); err != nil {
+		t.Errorf("vec_chunks not resized to new dimension: %v", err)
+	}
+}
+
+// failingEmbedder fails every embedding call.
+type failingEmbedder struct{ llm.Provider }
+
+func (failingEmbedder) Embed(context.Context, []string) ([][]float32, error) {
+	return nil, errors.New("embedding service down")
+}
+
+func TestReembedKeepsVectorsOnFailure(t *testing.T) {
+	eng, _, path := faultEngine(t, nil)
+	ctx := context.Background()
+	e := eng.(*engine)
+	if _, err := eng.Ingest(ctx, path); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	query := make([]float32, e.cfg.EmbeddingDim)
+	query[0] = 1
+	before, err := e.store.VectorSearch(ctx, query, 10)
+	if err != nil || len(before) == 0 {
+		t.Fatalf("VectorSearch = %d results, %v", len(before), err)
+	}
+
+	working := e.embedLLM
+	e.embedLLM = failingEmbedder{working}
+	if err := eng.Reembed(ctx); err == nil {
+		t.Fatal("Reembed succeeded without embeddings")
+	}
+	if got, err := e.store.VectorSearch(ctx, query, 10); err != nil || len(got) != len(before) {
+		t.Errorf("VectorSearch after a failed Reembed = %d results, %v; want %d", len(got), err, len(before))
+	}
+
+	e.embedLLM = working
+	if err := eng.Reembed(ctx); err != nil {
+		t.Fatalf("Reembed: %v", err)
+	}
+	if got, err := e.store.VectorSearch(ctx, query, 10); err != nil || len(got) != len(before) {
+		t.Errorf("VectorSearch after Reembed = %d results, %v; want %d", len(got), err, len(before))
+	}
+}
+
+func TestReembedResetsQueryCache(t *testing.T) {
+	eng, _, path := faultEngine(t, nil)
+	ctx := context.Background()
+	e := eng.(*engine)
+	e.cfg.QueryCacheSize = 8
+	e.retriever = e.newRetriever()
+	if _, err := eng.Ingest(ctx, path); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	if _, _, err := eng.Retrieve(ctx, "relief valve"); err != nil {
+		t.Fatalf("Retrieve: %v", err)
+	}
+	if s := eng.QueryCacheStats(); s.Size != 1 {
+		t.Fatalf("cache = %+v, want the query embedding", s)
+	}
+
+	// Query vectors cached before the corpus was re-embedded are dropped.
+	if err := eng.Reembed(ctx); err != nil {
+		t.Fatalf("Reembed: %v", err)
+	}
+	if s := eng.QueryCacheStats(); s.Size != 0 {
+		t.Errorf("cache after Reembed = %+v, want it empty", s)
+	}
+}
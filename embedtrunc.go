@@ -0,0 +1,124 @@
+package goreason
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/bbiangul/go-reason/chunker"
+	"github.com/bbiangul/go-reason/llm"
+)
+
+// Embedding truncation strategies for Config.EmbedTruncation. They decide
+// which part of a text longer than maxEmbedChars is embedded; the stored
+// chunk content is never shortened.
+const (
+	EmbedTruncateHead      = "head"      // keep the beginning (default)
+	EmbedTruncateTail      = "tail"      // keep the end
+	EmbedTruncateHeadTail  = "head_tail" // keep half from each end
+	EmbedTruncateSummarize = "summarize" // embed an LLM summary; falls back to head_tail
+)
+
+// embedSummaryPrompt asks for a summary short enough to embed. Operative
+// language is kept verbatim because that is what questions match against.
+const embedSummaryPrompt = `Summarize the following text in at most %d words for a search index.
+Keep obligations, conditions, exceptions, defined terms, numbers, and clause references verbatim.
+Reply with the summary only.
+
+TEXT:
+%s`
+
+// embedSummaryWords bounds the summary well inside maxEmbedChars.
+const embedSummaryWords = 1500
+
+// validEmbedTruncation reports whether s names a truncation strategy.
+func validEmbedTruncation(s string) bool {
+	switch s {
+	case "", EmbedTruncateHead, EmbedTruncateTail, EmbedTruncateHeadTail, EmbedTruncateSummarize:
+		return true
+	}
+	return false
+}
+
+// embedText prepares text of document docID for the embedding model using
+// the configured truncation strategy. Summaries that fail are logged and
+// replaced by head_tail truncation, so a chat outage never blocks
+// embedding. Summaries are stored by content hash, so a text the document
+// had before (re-ingests, Reembed, the other embedding models) is not
+// summarised again; a docID of 0 stores none.
+func (e *engine) embedText(ctx context.Context, docID int64, text string) string {
+	if len(text) <= maxEmbedChars {
+		return text
+	}
+	if e.cfg.EmbedTruncation != EmbedTruncateSummarize {
+		return truncateForEmbed(text, e.cfg.EmbedTruncation)
+	}
+
+	var hash string
+	if docID != 0 {
+		hash = chunker.ContentHash(text)
+		summary, err := e.store.EmbedSummary(ctx, docID, hash)
+		if err != nil {
+			slog.WarnContext(ctx, "loading embedding summary failed (non-fatal)", "doc_id", docID, "error", err)
+		} else if summary != "" {
+			return summary
+		}
+	}
+
+	resp, err := e.chatLLM.Chat(ctx, llm.ChatRequest{
+		Messages: []llm.Message{
+			{Role: "user", Content: fmt.Sprintf(embedSummaryPrompt, embedSummaryWords, text)},
+		},
+		Temperature: 0.0,
+	})
+	if err != nil || strings.TrimSpace(resp.Content) == "" {
+		slog.WarnContext(ctx, "embedding summary failed, truncating instead (non-fatal)", "chars", len(text), "error", err)
+		return truncateForEmbed(text, EmbedTruncateHeadTail)
+	}
+	summary := truncateForEmbed(strings.TrimSpace(resp.Content), EmbedTruncateHead)
+	if hash != "" {
+		if err := e.store.PutEmbedSummary(ctx, docID, hash, summary); err != nil {
+			slog.WarnContext(ctx, "storing embedding summary failed (non-fatal)", "doc_id", docID, "error", err)
+		}
+	}
+	return summary
+}
+
+// truncateForEmbed shortens text to maxEmbedChars on word boundaries,
+// keeping the part the strategy names. Unknown strategies keep the head.
+func truncateForEmbed(text, strategy string) string {
+	if len(text) <= maxEmbedChars {
+		return text
+	}
+	switch strategy {
+	case EmbedTruncateTail:
+		return tailChars(text, maxEmbedChars)
+	case EmbedTruncateHeadTail:
+		const sep = " ... "
+		half := (maxEmbedChars - len(sep)) / 2
+		return headChars(text, half) + sep + tailChars(text, half)
+	default:
+		return headChars(text, maxEmbedChars)
+	}
+}
+
+// headChars returns at most n bytes from the start of text, cut at the last
+// space before the limit to avoid splitting a word.
+func headChars(text string, n int) string {
+	cut := strings.LastIndex(text[:n], " ")
+	if cut <= 0 {
+		cut = n
+	}
+	return text[:cut]
+}
+
+// tailChars returns at most n bytes from the end of text, starting after the
+// first space past the limit.
+func tailChars(text string, n int) string {
+	start := len(text) - n
+	if i := strings.IndexByte(text[start:], ' '); i >= 0 && i < n-1 {
+		start += i + 1
+	}
+	return text[start:]
+}
@@ -0,0 +1,112 @@
+package goreason
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bbiangul/go-reason/llm"
+	"github.com/bbiangul/go-reason/store"
+)
+
+func TestTruncateForEmbed(t *testing.T) {
+	text := "HEAD " + strings.Repeat("filler ", maxEmbedChars/7+100) + "shall indemnify TAIL"
+
+	for _, tc := range []struct {
+		strategy   string
+		head, tail bool
+	}{
+		{"", true, false},
+		{EmbedTruncateHead, true, false},
+		{EmbedTruncateTail, false, true},
+		{EmbedTruncateHeadTail, true, true},
+	} {
+		got := truncateForEmbed(text, tc.strategy)
+		if len(got) > maxEmbedChars {
+			t.Errorf("%q: len = %d, want <= %d", tc.strategy, len(got), maxEmbedChars)
+		}
+		if strings.HasPrefix(got, "HEAD ") != tc.head {
+			t.Errorf("%q: keeps head = %v, want %v", tc.strategy, !tc.head, tc.head)
+		}
+		if strings.HasSuffix(got, "shall indemnify TAIL") != tc.tail {
+			t.Errorf("%q: keeps tail = %v, want %v", tc.strategy, !tc.tail, tc.tail)
+		}
+		if strings.HasPrefix(got, "iller") || strings.HasSuffix(got, "fille") {
+			t.Errorf("%q: cut inside a word", tc.strategy)
+		}
+	}
+
+	if got := truncateForEmbed("short", EmbedTruncateTail); got != "short" {
+		t.Errorf("short text changed: %q", got)
+	}
+}
+
+// summaryChat replies to every chat with a fixed summary or error.
+type summaryChat struct {
+	reply string
+	err   error
+	calls int
+}
+
+func (m *summaryChat) Chat(_ context.Context, _ llm.ChatRequest) (*llm.ChatResponse, error) {
+	m.calls++
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &llm.ChatResponse{Content: m.reply}, nil
+}
+
+func (m *summaryChat) Embed(_ context.Context, _ []string) ([][]float32, error) {
+	return nil, nil
+}
+
+func TestEmbedTextSummarize(t *testing.T) {
+	long := "HEAD " + strings.Repeat("filler ", maxEmbedChars/7+100) + "TAIL"
+	chat := &summaryChat{reply: " The supplier shall indemnify the buyer. "}
+	e := &engine{cfg: Config{EmbedTruncation: EmbedTruncateSummarize}, chatLLM: chat}
+
+	if got := e.embedText(context.Background(), 0, "short"); got != "short" || chat.calls != 0 {
+		t.Errorf("short text must be embedded as is without a chat call: %q, %d calls", got, chat.calls)
+	}
+	if got := e.embedText(context.Background(), 0, long); got != "The supplier shall indemnify the buyer." {
+		t.Errorf("summary = %q", got)
+	}
+
+	chat.err = errors.New("down")
+	got := e.embedText(context.Background(), 0, long)
+	if !strings.HasPrefix(got, "HEAD ") || !strings.HasSuffix(got, "TAIL") {
+		t.Error("failed summary must fall back to head_tail truncation")
+	}
+}
+
+func TestEmbedTextSummaryStored(t *testing.T) {
+	s, err := store.New(filepath.Join(t.TempDir(), "summaries.db"), 4)
+	if err != nil {
+		t.Fatalf("store.New: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+	docID, err := s.UpsertDocument(ctx, store.Document{Path: "/contract.pdf", Filename: "contract.pdf", Format: "pdf", ContentHash: "h", Status: "ready"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	long := "HEAD " + strings.Repeat("filler ", maxEmbedChars/7+100) + "TAIL"
+	chat := &summaryChat{reply: "The supplier shall indemnify the buyer."}
+	e := &engine{cfg: Config{EmbedTruncation: EmbedTruncateSummarize}, chatLLM: chat, store: s}
+
+	// Unchanged text is summarised once, changed text again.
+	for i := 0; i < 2; i++ {
+		if got := e.embedText(ctx, docID, long); got != chat.reply {
+			t.Errorf("summary %d = %q", i, got)
+		}
+	}
+	if chat.calls != 1 {
+		t.Errorf("%d chat calls for unchanged text, want 1", chat.calls)
+	}
+	e.embedText(ctx, docID, long+" changed")
+	if chat.calls != 2 {
+		t.Errorf("%d chat calls after the text changed, want 2", chat.calls)
+	}
+}
@@ -0,0 +1,47 @@
+package goreason
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/bbiangul/go-reason/store"
+)
+
+// contentCipher builds the store's content cipher from
+// Config.EncryptionKeys, or returns nil when no key is set.
+func contentCipher(keys map[string]string) (*store.ContentCipher, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	raw := make(map[string][]byte, len(keys))
+	for collection, key := range keys {
+		b, err := base64.StdEncoding.DecodeString(key)
+		if err != nil {
+			return nil, fmt.Errorf("%w: encryption key for collection %q is not base64: %v", ErrInvalidConfig, collection, err)
+		}
+		if n := len(b); n != 16 && n != 24 && n != 32 {
+			return nil, fmt.Errorf("%w: encryption key for collection %q must be 16, 24 or 32 bytes, got %d", ErrInvalidConfig, collection, n)
+		}
+		raw[collection] = b
+	}
+	c, err := store.NewContentCipher(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	return c, nil
+}
+
+// loggedSources returns sources as kept in the query log. With content
+// encryption on they lose their text, snippets, and images, so the log
+// holds no plaintext copy of encrypted chunks.
+func (e *engine) loggedSources(sources []Source) []Source {
+	if e.store.ContentCipher() == nil {
+		return sources
+	}
+	out := make([]Source, len(sources))
+	for i, s := range sources {
+		s.Content, s.Snippet, s.Images = "", "", nil
+		out[i] = s
+	}
+	return out
+}
@@ -0,0 +1,63 @@
+package goreason
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestContentCipherConfig(t *testing.T) {
+	if c, err := contentCipher(nil); c != nil || err != nil {
+		t.Errorf("no keys: cipher %v, err %v", c, err)
+	}
+	key := base64.StdEncoding.EncodeToString(make([]byte, 32))
+	if _, err := contentCipher(map[string]string{"acme": key, "": key}); err != nil {
+		t.Errorf("valid keys: %v", err)
+	}
+	for _, bad := range []string{"not base64!", base64.StdEncoding.EncodeToString(make([]byte, 20))} {
+		if _, err := contentCipher(map[string]string{"acme": bad}); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("key %q: error = %v, want ErrInvalidConfig", bad, err)
+		}
+	}
+}
+
+func TestEncryptedIngestAndQuery(t *testing.T) {
+	eng, _, path := faultEngine(t, nil, skipGraph, func(cfg *Config) {
+		cfg.EncryptionKeys = map[string]string{"": base64.StdEncoding.EncodeToString(make([]byte, 32))}
+	})
+	ctx := context.Background()
+
+	if err := os.WriteFile(path, []byte("The relief valve opens at 10 bar.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := eng.Ingest(ctx, path); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+
+	db := eng.(*engine).store.DB()
+	var stored string
+	if err := db.QueryRow("SELECT content FROM chunks LIMIT 1").Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(stored, "relief") {
+		t.Errorf("chunk content stored in plaintext: %q", stored)
+	}
+
+	answer, err := eng.Query(ctx, "At what pressure does the relief valve open?")
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if len(answer.Sources) == 0 || !strings.Contains(answer.Sources[0].Content, "10 bar") {
+		t.Fatalf("sources = %+v, want the decrypted chunk", answer.Sources)
+	}
+	var logged string
+	if err := db.QueryRow("SELECT sources FROM query_log WHERE id = ?", answer.QueryID).Scan(&logged); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(logged, "relief valve opens") {
+		t.Errorf("query log keeps source text: %s", logged)
+	}
+}
@@ -0,0 +1,53 @@
+package goreason
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bbiangul/go-reason/retrieval"
+	"github.com/bbiangul/go-reason/store"
+)
+
+func TestLinkedEntitiesTrace(t *testing.T) {
+	eng, _, path := faultEngine(t, nil)
+	ctx := context.Background()
+	if _, err := eng.Ingest(ctx, path); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	e := eng.(*engine)
+	r := e.newRetriever()
+	opts := retrieval.SearchOptions{MaxResults: 5, WeightVec: 1, WeightFTS: 1, WeightGraph: 1}
+
+	link := func(trace *retrieval.SearchTrace) *retrieval.EntityLink {
+		for i, l := range trace.LinkedEntities {
+			if l.Name == "relief valve" {
+				return &trace.LinkedEntities[i]
+			}
+		}
+		return nil
+	}
+
+	_, trace, err := r.Search(ctx, "What pressure opens the valve?", opts)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	l := link(trace)
+	if l == nil || l.Match != retrieval.LinkNameSubstr || l.Term != "valve" || l.Type != "concept" || l.Stopped {
+		t.Fatalf("LinkedEntities = %+v, want relief valve matched by name containing \"valve\"", trace.LinkedEntities)
+	}
+
+	// A stop entity is still listed, marked as not searched from.
+	if _, err := e.store.UpdateStopEntities(ctx, store.StopEntityRule{Stop: []string{"relief valve"}}); err != nil {
+		t.Fatal(err)
+	}
+	_, trace, err = r.Search(ctx, "What pressure opens the valve?", opts)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if l := link(trace); l == nil || !l.Stopped {
+		t.Errorf("LinkedEntities = %+v, want relief valve marked stopped", trace.LinkedEntities)
+	}
+	if trace.GraphResults != 0 {
+		t.Errorf("graph leg returned %d results from a stop entity", trace.GraphResults)
+	}
+}
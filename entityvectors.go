@@ -0,0 +1,70 @@
+package goreason
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/bbiangul/go-reason/store"
+)
+
+// entityEmbedText is the text embedded for an entity: its name and type
+// give short descriptions enough context to match a question.
+func entityEmbedText(ent store.Entity) string {
+	if ent.Description == "" {
+		return fmt.Sprintf("%s (%s)", ent.Name, ent.EntityType)
+	}
+	return fmt.Sprintf("%s (%s): %s", ent.Name, ent.EntityType, ent.Description)
+}
+
+// embedEntities embeds every entity that has no description embedding yet,
+// for Config.GraphSeedSimilarity. It runs after each graph build, so only
+// the entities a document introduced are embedded. Returns the number of
+// entities embedded.
+func (e *engine) embedEntities(ctx context.Context) (int, error) {
+	const pageSize = 32
+	var done, failed int
+	var after int64
+	for {
+		entities, err := e.store.EntitiesWithoutEmbedding(ctx, after, pageSize)
+		if err != nil {
+			return done, fmt.Errorf("listing entities without vectors: %w", err)
+		}
+		if len(entities) == 0 {
+			break
+		}
+		after = entities[len(entities)-1].ID
+
+		texts := make([]string, len(entities))
+		for i, ent := range entities {
+			texts[i] = entityEmbedText(ent)
+		}
+		embeddings, err := e.embedLLM.Embed(ctx, texts)
+		if err != nil {
+			if ctx.Err() != nil {
+				return done, ctx.Err()
+			}
+			slog.WarnContext(ctx, "entity embedding batch failed", "after_id", entities[0].ID, "error", err)
+			failed += len(entities)
+			continue
+		}
+		for i, emb := range embeddings {
+			if i >= len(entities) || len(emb) == 0 {
+				break
+			}
+			if err := e.store.InsertEntityEmbedding(ctx, entities[i].ID, emb); err != nil {
+				slog.WarnContext(ctx, "storing entity embedding failed", "entity_id", entities[i].ID, "error", err)
+				failed++
+				continue
+			}
+			done++
+		}
+	}
+	if failed > 0 && done == 0 {
+		return 0, fmt.Errorf("all %d entities failed embedding", failed)
+	}
+	if failed > 0 {
+		slog.WarnContext(ctx, "some entity embeddings failed", "failed", failed, "embedded", done)
+	}
+	return done, nil
+}
@@ -0,0 +1,55 @@
+package goreason
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bbiangul/go-reason/retrieval"
+)
+
+func TestGraphSeedsFromEntityVectors(t *testing.T) {
+	eng, _, path := faultEngine(t, nil)
+	ctx := context.Background()
+	if _, err := eng.Ingest(ctx, path); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	e := eng.(*engine)
+
+	// faultEngine leaves seeding off, so ingest embedded no entities.
+	n, err := e.embedEntities(ctx)
+	if err != nil || n != 1 {
+		t.Fatalf("embedEntities = %d, %v; want the one extracted entity", n, err)
+	}
+	if n, err := e.embedEntities(ctx); err != nil || n != 0 {
+		t.Fatalf("second embedEntities = %d, %v; want nothing left to embed", n, err)
+	}
+
+	// The test server embeds equal texts equally, so a question worded
+	// like the entity's embedding text is a perfect match.
+	e.cfg.GraphSeedSimilarity = 0.99
+	r := e.newRetriever()
+	question := "relief valve (concept): Valve"
+	results, trace, err := r.Search(ctx, question, retrieval.SearchOptions{
+		MaxResults: 5, WeightVec: 1, WeightFTS: 1, WeightGraph: 1,
+	})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(trace.GraphSeedEntities) != 1 || trace.GraphSeedEntities[0] != "relief valve" {
+		t.Errorf("GraphSeedEntities = %v, want [relief valve]", trace.GraphSeedEntities)
+	}
+	if trace.GraphResults == 0 || len(results) == 0 {
+		t.Errorf("seeded graph leg returned %d results (fused %d)", trace.GraphResults, len(results))
+	}
+
+	// An unrelated question is below the threshold.
+	_, trace, err = r.Search(ctx, "warranty terms", retrieval.SearchOptions{
+		MaxResults: 5, WeightVec: 1, WeightFTS: 1, WeightGraph: 1,
+	})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(trace.GraphSeedEntities) != 0 {
+		t.Errorf("GraphSeedEntities = %v for an unrelated question", trace.GraphSeedEntities)
+	}
+}
@@ -6,6 +6,32 @@ var (
 	// ErrDocumentNotFound is returned when a document ID does not exist.
 	ErrDocumentNotFound = errors.New("goreason: document not found")
 
+	// ErrChunkNotFound is returned when a chunk ID does not exist.
+	ErrChunkNotFound = errors.New("goreason: chunk not found")
+
+	// ErrQueryNotFound is returned when a query log ID does not exist.
+	ErrQueryNotFound = errors.New("goreason: query not found")
+
+	// ErrQueryNotRunning is returned when no query in progress has the
+	// given ID.
+	ErrQueryNotRunning = errors.New("goreason: query not running")
+
+	// ErrQueryCancelled is returned by a query stopped with CancelQuery,
+	// along with the answer's partial trace.
+	ErrQueryCancelled = errors.New("goreason: query cancelled")
+
+	// ErrAnnotationNotFound is returned when a chunk annotation ID does
+	// not exist.
+	ErrAnnotationNotFound = errors.New("goreason: annotation not found")
+
+	// ErrTemplateNotFound is returned when a query template name does not
+	// exist.
+	ErrTemplateNotFound = errors.New("goreason: query template not found")
+
+	// ErrImageNotFound is returned when a chunk has no image at the
+	// requested index.
+	ErrImageNotFound = errors.New("goreason: image not found")
+
 	// ErrDocumentExists is returned when trying to ingest a duplicate path.
 	ErrDocumentExists = errors.New("goreason: document already exists")
 
@@ -15,15 +41,42 @@ var (
 	// ErrParsingFailed is returned when document parsing fails.
 	ErrParsingFailed = errors.New("goreason: parsing failed")
 
+	// ErrParseMethodUnavailable is returned when a requested parse method
+	// is unknown, not configured, or does not support the document's format.
+	ErrParseMethodUnavailable = errors.New("goreason: parse method unavailable")
+
 	// ErrEmbeddingFailed is returned when embedding generation fails.
 	ErrEmbeddingFailed = errors.New("goreason: embedding generation failed")
 
+	// ErrEmptySelection is returned when an ingest's page range or section
+	// selection leaves nothing of the document.
+	ErrEmptySelection = errors.New("goreason: page or section selection matched no content")
+
+	// ErrNoCheckpoint is returned by ResumeIngest when a document has no
+	// unfinished ingest to resume.
+	ErrNoCheckpoint = errors.New("goreason: no ingest checkpoint for document")
+
 	// ErrLLMUnavailable is returned when the LLM provider is unreachable.
 	ErrLLMUnavailable = errors.New("goreason: LLM provider unavailable")
 
 	// ErrLLMRequestFailed is returned when an LLM request fails.
 	ErrLLMRequestFailed = errors.New("goreason: LLM request failed")
 
+	// ErrReadOnly is returned by writes to an engine opened read-only,
+	// such as a query server on a replica.
+	ErrReadOnly = errors.New("goreason: engine is read-only")
+
+	// ErrInvalidArchive is returned when an archive cannot be read or
+	// extracted safely.
+	ErrInvalidArchive = errors.New("goreason: invalid archive")
+
+	// ErrInvalidGraphImport is returned when a graph import is malformed.
+	ErrInvalidGraphImport = errors.New("goreason: invalid graph import")
+
+	// ErrInvalidIndexImport is returned when an exported index cannot be
+	// read or imported.
+	ErrInvalidIndexImport = errors.New("goreason: invalid index import")
+
 	// ErrStoreClosed is returned when operating on a closed store.
 	ErrStoreClosed = errors.New("goreason: store is closed")
 
@@ -40,6 +93,14 @@ var (
 	// but no vision provider is configured.
 	ErrVisionRequired = errors.New("goreason: vision provider required for this document")
 
+	// ErrEmbeddingModelMismatch is returned when the configured embedding model
+	// differs from the one that produced the stored chunk vectors.
+	ErrEmbeddingModelMismatch = errors.New("goreason: embedding model differs from stored vectors")
+
+	// ErrIngestQueueFull is returned when the ingestion queue is at capacity
+	// and the call was rejected instead of queued.
+	ErrIngestQueueFull = errors.New("goreason: ingestion queue full")
+
 	// ErrExternalParserRequired is returned when a legacy format needs an
 	// external parsing service that is not configured.
 	ErrExternalParserRequired = errors.New("goreason: external parser required for legacy format")
@@ -0,0 +1,102 @@
+package goreason
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/bbiangul/go-reason/reasoning"
+	"github.com/bbiangul/go-reason/store"
+)
+
+// Model tiers recorded in Answer.Tier when Config.Escalation is set.
+const (
+	ModelTierPrimary   = "primary"   // the Chat model's answer was kept
+	ModelTierEscalated = "escalated" // the Escalation model answered
+)
+
+// escalationThreshold is the confidence below which answers escalate.
+func (e *engine) escalationThreshold() float64 {
+	if e.cfg.EscalationThreshold > 0 {
+		return e.cfg.EscalationThreshold
+	}
+	return e.cfg.ConfidenceThreshold
+}
+
+// escalationReason says why answer should be reasoned again with the
+// stronger model, or returns "" when it is good enough.
+func (e *engine) escalationReason(answer *reasoning.Answer) string {
+	var reasons []string
+	if threshold := e.escalationThreshold(); answer.Confidence < threshold {
+		reasons = append(reasons, fmt.Sprintf("confidence %.2f below %.2f", answer.Confidence, threshold))
+	}
+	if n := len(answer.Issues); n > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d validation issues", n))
+	}
+	return strings.Join(reasons, ", ")
+}
+
+// escalate reasons again with the Escalation model when the Chat model's
+// answer is weak, and returns the answer to keep with the tier that
+// produced it and why it escalated. The stronger model's answer is kept
+// unless its confidence is lower; either way the tokens of both are
+// counted. Without an Escalation model answer is returned as is.
+func (e *engine) escalate(ctx context.Context, question string, results []store.RetrievalResult,
+	opts reasoning.Options, answer *reasoning.Answer) (*reasoning.Answer, string, string) {
+	if e.escalator == nil {
+		return answer, "", ""
+	}
+	reason := e.escalationReason(answer)
+	if reason == "" {
+		return answer, ModelTierPrimary, ""
+	}
+	if !e.escalator.HasTimeFor(ctx, 1) {
+		slog.InfoContext(ctx, "reasoning: skipping escalation, no time left before the deadline", "reason", reason)
+		answer.DeadlineLimited = true
+		return answer, ModelTierPrimary, ""
+	}
+
+	// The stronger model's rounds follow the escalation step, in the trace
+	// and as they are reported to OnStep.
+	round := reasoning.LastRound(answer.Reasoning) + 1
+	if onStep := opts.OnStep; onStep != nil {
+		opts.OnStep = func(s reasoning.Step) {
+			s.Round += round
+			onStep(s)
+		}
+	}
+
+	slog.InfoContext(ctx, "reasoning: escalating to the stronger model", "reason", reason, "model", e.cfg.Escalation.Model)
+	strong, err := e.escalator.Reason(ctx, question, results, opts)
+	if err != nil {
+		slog.WarnContext(ctx, "reasoning: escalation failed, keeping the first answer (non-fatal)", "error", err)
+		return answer, ModelTierPrimary, reason
+	}
+
+	kept, tier := strong, ModelTierEscalated
+	if strong.Confidence < answer.Confidence {
+		kept, tier = answer, ModelTierPrimary
+	}
+	merged := *kept
+	merged.PromptTokens = answer.PromptTokens + strong.PromptTokens
+	merged.CompletionTokens = answer.CompletionTokens + strong.CompletionTokens
+	merged.CachedTokens = answer.CachedTokens + strong.CachedTokens
+	merged.TotalTokens = merged.PromptTokens + merged.CompletionTokens
+	merged.Rounds = answer.Rounds + strong.Rounds
+	merged.Searches = answer.Searches + strong.Searches
+	merged.DeadlineLimited = answer.DeadlineLimited || strong.DeadlineLimited
+
+	// The trace keeps both attempts, split by an escalation step.
+	merged.Reasoning = append(append([]reasoning.Step(nil), answer.Reasoning...), reasoning.Step{
+		Round:  round,
+		Action: "escalation",
+		Input:  reason,
+		Output: fmt.Sprintf("re-answered with %s; kept the %s answer", strong.ModelUsed, tier),
+	})
+	for _, s := range strong.Reasoning {
+		s.Round += round
+		merged.Reasoning = append(merged.Reasoning, s)
+	}
+	return &merged, tier, reason
+}
@@ -0,0 +1,64 @@
+package goreason
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/bbiangul/go-reason/llm/llmtest"
+)
+
+func TestEscalation(t *testing.T) {
+	strong := llmtest.NewServer(nil)
+	defer strong.Close()
+	strong.ChatFunc = func(string) string { return "The relief valve opens at 10 bar (pump.txt)." }
+
+	eng, cheap, path := faultEngine(t, nil, skipGraph, func(cfg *Config) {
+		cfg.Escalation = LLMConfig{Provider: "custom", Model: "strong", BaseURL: strong.URL}
+		cfg.EscalationThreshold = 0.55
+	})
+	cheapAnswer := "It might possibly open at some pressure; the manual is unclear."
+	cheap.ChatFunc = func(string) string { return cheapAnswer }
+	ctx := context.Background()
+	if err := os.WriteFile(path, []byte("The relief valve opens at 10 bar.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := eng.Ingest(ctx, path); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+
+	// A hedged answer is below the threshold: the strong model answers.
+	answer, err := eng.Query(ctx, "At what pressure does the relief valve open?")
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if answer.Tier != ModelTierEscalated || answer.EscalationReason == "" || answer.ModelUsed != "strong" {
+		t.Errorf("answer tier %q (%q) from %q, want escalated to strong", answer.Tier, answer.EscalationReason, answer.ModelUsed)
+	}
+	if answer.Rounds != 2 || strong.Calls(llmtest.OpChat) != 1 {
+		t.Errorf("rounds = %d, strong model calls = %d; want one round from each model", answer.Rounds, strong.Calls(llmtest.OpChat))
+	}
+	// The stronger model's steps follow the escalation step's round.
+	var escalation int
+	for _, s := range answer.Reasoning {
+		switch {
+		case s.Action == "escalation":
+			escalation = s.Round
+		case escalation > 0 && s.Round <= escalation:
+			t.Errorf("step %q in round %d after the escalation in round %d", s.Action, s.Round, escalation)
+		}
+	}
+	if escalation != 2 {
+		t.Errorf("escalation step in round %d, want 2: %+v", escalation, answer.Reasoning)
+	}
+
+	// A confident answer stays with the cheap model.
+	cheapAnswer = "The relief valve opens at 10 bar (pump.txt)."
+	answer, err = eng.Query(ctx, "What opens at 10 bar?")
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if answer.Tier != ModelTierPrimary || answer.EscalationReason != "" || strong.Calls(llmtest.OpChat) != 1 {
+		t.Errorf("answer tier %q (%q) after %d strong calls, want the primary answer", answer.Tier, answer.EscalationReason, strong.Calls(llmtest.OpChat))
+	}
+}
@@ -0,0 +1,95 @@
+package eval
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/bbiangul/go-reason"
+)
+
+// StoredAnswer is the engine's raw answer to one test, with everything the
+// run computed from the engine: retrieval trace, query latency, and the
+// ground-truth diagnosis. Runs persist them (see RecordAnswers) so the
+// judge can be re-run later on the same answers (see ReplayAnswers)
+// without paying for retrieval and generation again.
+type StoredAnswer struct {
+	Dataset     string            `json:"dataset"`
+	Difficulty  string            `json:"difficulty,omitempty"`
+	Test        TestCase          `json:"test"`
+	Spans       []GroundTruthSpan `json:"ground_truth_spans,omitempty"`
+	QueryMs     int64             `json:"query_ms"`
+	Error       string            `json:"error,omitempty"`
+	Answer      *goreason.Answer  `json:"answer,omitempty"`
+	GroundTruth *GroundTruthCheck `json:"ground_truth,omitempty"`
+}
+
+// RecordAnswers makes Run write each test's StoredAnswer to w as one JSON
+// line. Write errors are logged and do not fail the run.
+func (e *Evaluator) RecordAnswers(w io.Writer) {
+	e.answerLog = json.NewEncoder(w)
+}
+
+// ReplayAnswers makes Run score the stored answers instead of querying the
+// engine: only the judge and the answer metrics are recomputed. Tests
+// without a stored answer fail with an error. The engine may be nil.
+func (e *Evaluator) ReplayAnswers(answers []StoredAnswer) {
+	e.replay = make(map[string]StoredAnswer, len(answers))
+	for _, a := range answers {
+		e.replay[answerKey(a.Dataset, a.Test.Question)] = a
+	}
+}
+
+func answerKey(dataset, question string) string {
+	return dataset + "\x00" + question
+}
+
+// ReadStoredAnswers reads a file written by RecordAnswers.
+func ReadStoredAnswers(path string) ([]StoredAnswer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var answers []StoredAnswer
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var a StoredAnswer
+		if err := json.Unmarshal(sc.Bytes(), &a); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		answers = append(answers, a)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return answers, nil
+}
+
+// StoredDatasets rebuilds the datasets of a run from its stored answers,
+// in the order they were run, with their ground-truth spans.
+func StoredDatasets(answers []StoredAnswer) ([]Dataset, map[string][]GroundTruthSpan) {
+	var datasets []Dataset
+	index := make(map[string]int)
+	spans := make(map[string][]GroundTruthSpan)
+	for _, a := range answers {
+		i, ok := index[a.Dataset]
+		if !ok {
+			i = len(datasets)
+			index[a.Dataset] = i
+			datasets = append(datasets, Dataset{Name: a.Dataset, Difficulty: a.Difficulty})
+		}
+		datasets[i].Tests = append(datasets[i].Tests, a.Test)
+		if len(a.Spans) > 0 {
+			spans[a.Test.Question] = a.Spans
+		}
+	}
+	return datasets, spans
+}
@@ -0,0 +1,133 @@
+package eval
+
+import (
+	"context"
+	"flag"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bbiangul/go-reason"
+	"github.com/bbiangul/go-reason/llm"
+	"github.com/bbiangul/go-reason/llm/llmtest"
+)
+
+// recordCassettes re-records the golden cassettes in testdata/cassettes
+// instead of replaying them:
+//
+//	go test -tags sqlite_fts5 ./eval -run Cassette -record-cassettes
+//
+// Recording goes to a scripted llmtest server, so the cassettes stay
+// deterministic.
+var recordCassettes = flag.Bool("record-cassettes", false, "re-record the golden LLM cassettes in testdata/cassettes")
+
+// pumpDataset is the dataset of the pump cassette.
+func pumpDataset() Dataset {
+	return Dataset{Name: "Pump", Difficulty: DifficultyEasy, Tests: []TestCase{
+		{Question: "At what pressure does the relief valve open?", ExpectedFacts: []string{"10 bar"}, Category: "single-fact"},
+		{Question: "How often is the relief valve inspected?", ExpectedFacts: []string{"500 operating hours"}, Category: "single-fact"},
+	}}
+}
+
+// scriptedPumpLLM answers the pump dataset's prompts.
+func scriptedPumpLLM(prompt string) string {
+	switch {
+	case strings.Contains(prompt, "evaluation judge"):
+		if strings.Contains(prompt, "500 hours") {
+			return `{"covered": [false], "rationale": "The answer says 500 hours, not 500 operating hours."}`
+		}
+		return `{"covered": [true]}`
+	case strings.Contains(prompt, "How often"):
+		return "Inspect it every 500 hours (pump.txt)."
+	}
+	return "The relief valve opens at 10 bar (pump.txt)."
+}
+
+// runCassetteEval ingests testdata/pump.txt and runs the pump dataset with
+// every LLM call sent to baseURL.
+func runCassetteEval(t *testing.T, baseURL string) *Report {
+	t.Helper()
+	ctx := context.Background()
+	llmCfg := goreason.LLMConfig{Provider: "custom", Model: "fake", BaseURL: baseURL}
+	engine, err := goreason.New(goreason.Config{
+		DBPath:       filepath.Join(t.TempDir(), "eval.db"),
+		Chat:         llmCfg,
+		Embedding:    llmCfg,
+		EmbeddingDim: 4,
+		MaxRounds:    1,
+		SkipGraph:    true,
+		SkipSummary:  true,
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer engine.Close()
+	if _, err := engine.Ingest(ctx, filepath.Join("testdata", "pump.txt")); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+
+	judge, err := llm.NewProvider(llm.Config{Provider: "custom", Model: "judge", BaseURL: baseURL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	evaluator := NewEvaluator(engine)
+	evaluator.SetJudge(judge, "judge")
+	report, err := evaluator.Run(ctx, pumpDataset())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	return report
+}
+
+func TestEvalCassette(t *testing.T) {
+	path := filepath.Join("testdata", "cassettes", "pump.json")
+	if *recordCassettes {
+		upstream := llmtest.NewServer(nil)
+		defer upstream.Close()
+		upstream.ChatFunc = scriptedPumpLLM
+		cassette := &llmtest.Cassette{}
+		rec := llmtest.NewRecordingServer(cassette, upstream.URL)
+		runCassetteEval(t, rec.URL)
+		rec.Close()
+		if err := cassette.Save(path); err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("recorded %d interactions to %s", len(cassette.Interactions), path)
+	}
+
+	cassette, err := llmtest.LoadCassette(path)
+	if err != nil {
+		t.Fatalf("LoadCassette: %v", err)
+	}
+	srv := llmtest.NewReplayServer(cassette)
+	defer srv.Close()
+
+	report := runCassetteEval(t, srv.URL)
+	if misses := srv.Misses(); len(misses) > 0 {
+		t.Fatalf("%d requests not in the cassette (re-record with -record-cassettes), first:\n%s", len(misses), misses[0])
+	}
+	if report.TotalTests != 2 || report.Passed != 1 {
+		t.Errorf("passed %d of %d, want 1 of 2", report.Passed, report.TotalTests)
+	}
+	for _, r := range report.Results {
+		if r.Error != "" {
+			t.Errorf("%q: %s", r.Question, r.Error)
+		}
+	}
+	if report.Results[0].Accuracy != 1 || report.Results[1].Accuracy != 0 {
+		t.Errorf("accuracy = %v, %v; want the judge's 1 and 0", report.Results[0].Accuracy, report.Results[1].Accuracy)
+	}
+	if r := report.Results[1]; len(r.FactsCovered) != 1 || r.FactsCovered[0] || !strings.Contains(r.JudgeRationale, "operating hours") {
+		t.Errorf("judge verdict = %v, %q", r.FactsCovered, r.JudgeRationale)
+	}
+	if out := FormatReport(report); !strings.Contains(out, "Pump") {
+		t.Errorf("report does not name the dataset:\n%s", out)
+	}
+
+	// A replay is a repeatable baseline: a second run scores the same.
+	again := runCassetteEval(t, srv.URL)
+	if again.Passed != report.Passed || again.Metrics.AvgAccuracy != report.Metrics.AvgAccuracy ||
+		again.Metrics.AvgFaithfulness != report.Metrics.AvgFaithfulness {
+		t.Errorf("replays differ: %+v vs %+v", again.Metrics, report.Metrics)
+	}
+}
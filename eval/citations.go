@@ -0,0 +1,266 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/bbiangul/go-reason"
+	"github.com/bbiangul/go-reason/llm"
+)
+
+const (
+	// maxCitedClaims bounds the claims one citation judge call checks.
+	maxCitedClaims = 10
+	// maxCitedPassages bounds the passages shown per claim, and
+	// maxPassageChars their length, to keep the judge prompt small.
+	maxCitedPassages = 3
+	maxPassageChars  = 1500
+)
+
+var (
+	// sectionRefPattern matches article, section, and clause references:
+	// "Article 17", "Art. 6(1)", "§ 4.2", "Sección 3".
+	sectionRefPattern = regexp.MustCompile(`(?i)(?:\barticle|\bart\.|\bsection|\bsec\.|§|\bclause|\bcl\.|\bart[íi]culo|\bsecci[óo]n)\s*(\d+(?:\.\d+)*)`)
+	// pageRefPattern matches page references: "page 12", "p. 12", "página 12".
+	pageRefPattern = regexp.MustCompile(`(?i)(?:\bpage|\bp\.|\bp[áa]gina)\s*(\d+)`)
+	// sourceRefPattern matches numbered source references: "[Source 2]".
+	sourceRefPattern = regexp.MustCompile(`\[Source\s*(\d+)\]`)
+)
+
+// citedClaim is a sentence of an answer that makes article, section,
+// clause, page, or [Source N] references, with the retrieved sources those
+// references resolve to.
+type citedClaim struct {
+	sentence string
+	refs     []string
+	sources  []goreason.Source
+}
+
+// extractCitedClaims finds the sentences of the answer that cite a
+// reference and resolves each reference against the answer's sources.
+// Filenames alone are too coarse to check and are not treated as
+// references.
+func extractCitedClaims(answer *goreason.Answer) []citedClaim {
+	var claims []citedClaim
+	for _, sentence := range splitClaims(answer.Text) {
+		var c citedClaim
+		seen := make(map[int64]bool)
+		add := func(ref string, sources []goreason.Source) {
+			c.refs = append(c.refs, ref)
+			for _, s := range sources {
+				if !seen[s.ChunkID] {
+					seen[s.ChunkID] = true
+					c.sources = append(c.sources, s)
+				}
+			}
+		}
+		for _, m := range sectionRefPattern.FindAllStringSubmatch(sentence, -1) {
+			add(m[0], sourcesForSection(m[1], answer.Sources))
+		}
+		for _, m := range pageRefPattern.FindAllStringSubmatch(sentence, -1) {
+			page, _ := strconv.Atoi(m[1])
+			var matched []goreason.Source
+			for _, s := range answer.Sources {
+				if page > 0 && s.PageNumber == page {
+					matched = append(matched, s)
+				}
+			}
+			add(m[0], matched)
+		}
+		for _, m := range sourceRefPattern.FindAllStringSubmatch(sentence, -1) {
+			n, _ := strconv.Atoi(m[1])
+			var matched []goreason.Source
+			if n >= 1 && n <= len(answer.Sources) {
+				matched = append(matched, answer.Sources[n-1])
+			}
+			add(m[0], matched)
+		}
+		if len(c.refs) > 0 {
+			c.sentence = sentence
+			claims = append(claims, c)
+		}
+	}
+	return claims
+}
+
+// sourcesForSection returns the sources that are article, section, or
+// clause num: by parsed section number, or by a heading or first line that
+// names it.
+func sourcesForSection(num string, sources []goreason.Source) []goreason.Source {
+	var out []goreason.Source
+	for _, s := range sources {
+		firstLine, _, _ := strings.Cut(strings.TrimSpace(s.Content), "\n")
+		if s.SectionNumber == num || headingNames(s.Heading, num) || headingNames(firstLine, num) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// headingNames reports whether a heading is section num: it starts with
+// the number ("17. Right to erasure", "4.2 Scope") or names it as an
+// article, section, or clause ("Article 17 – Right to erasure").
+func headingNames(heading, num string) bool {
+	heading = strings.TrimSpace(heading)
+	if rest, ok := strings.CutPrefix(heading, num); ok {
+		return rest == "" || !startsWithDigitOrSubsection(rest)
+	}
+	for _, m := range sectionRefPattern.FindAllStringSubmatchIndex(heading, -1) {
+		if heading[m[2]:m[3]] == num {
+			return true
+		}
+	}
+	return false
+}
+
+// startsWithDigitOrSubsection reports whether rest continues a section
+// number, as "7" does after "1" or ".2" after "4".
+func startsWithDigitOrSubsection(rest string) bool {
+	r, _ := utf8.DecodeRuneInString(rest)
+	if unicode.IsDigit(r) {
+		return true
+	}
+	return len(rest) > 1 && rest[0] == '.' && rest[1] >= '0' && rest[1] <= '9'
+}
+
+// splitClaims splits an answer into sentences at line breaks and at
+// sentence ends followed by a capital letter, so abbreviations such as
+// "Art. 17" stay whole (unlike splitSentences).
+func splitClaims(text string) []string {
+	var out []string
+	flush := func(s string) {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	for _, line := range strings.Split(text, "\n") {
+		start := 0
+		for i := 0; i < len(line); i++ {
+			if line[i] != '.' && line[i] != '!' && line[i] != '?' {
+				continue
+			}
+			j := i + 1
+			for j < len(line) && line[j] == ' ' {
+				j++
+			}
+			if j == i+1 || j >= len(line) {
+				continue
+			}
+			if r, _ := utf8.DecodeRuneInString(line[j:]); unicode.IsUpper(r) {
+				flush(line[start : i+1])
+				start = j
+			}
+		}
+		flush(line[start:])
+	}
+	return out
+}
+
+// computeCitationAccuracyLLM checks that the answer's references support
+// the claims that cite them: each sentence citing an article, section,
+// clause, page, or [Source N] is judged against the retrieved passages its
+// references resolve to. A reference that resolves to no retrieved passage
+// counts as unsupported without asking the judge. It returns the supported
+// fraction and the number of claims checked (0 when the answer cites
+// nothing checkable), with the judge call's token usage.
+func computeCitationAccuracyLLM(ctx context.Context, judge llm.Provider, model string, answer *goreason.Answer, answerLang string) (float64, int, TokenUsage, error) {
+	if answer == nil || answer.Text == "" {
+		return 0, 0, TokenUsage{}, nil
+	}
+	claims := extractCitedClaims(answer)
+	if len(claims) > maxCitedClaims {
+		claims = claims[:maxCitedClaims]
+	}
+	if len(claims) == 0 {
+		return 0, 0, TokenUsage{}, nil
+	}
+
+	// Only claims whose references resolve go to the judge.
+	var judged []int
+	var claimsBuilder strings.Builder
+	for i, c := range claims {
+		if len(c.sources) == 0 {
+			continue
+		}
+		judged = append(judged, i)
+		fmt.Fprintf(&claimsBuilder, "Claim %d: %s\nCited references: %s\nCited passages:\n", len(judged), c.sentence, strings.Join(c.refs, "; "))
+		for j, s := range c.sources {
+			if j == maxCitedPassages {
+				break
+			}
+			fmt.Fprintf(&claimsBuilder, "--- %s\n%s\n", sourceLabel(s), truncateStr(s.Content, maxPassageChars))
+		}
+		claimsBuilder.WriteByte('\n')
+	}
+	if len(judged) == 0 {
+		return 0, len(claims), TokenUsage{}, nil
+	}
+
+	prompt := fmt.Sprintf(`You are an evaluation judge checking the citations of a RAG system's answer. Each claim below cites articles, sections, clauses, or pages, and is followed by the passages those references point to.
+
+A claim is "supported" if the cited passages state the facts the claim attributes to them: the same obligation, right, condition, number, or date.
+A claim is NOT supported if the passages do not contain those facts, contradict them, or the facts come from a different article or page than the one cited.
+%s
+%s
+Respond with JSON: {"supported": [true, false, ...]} — one boolean per claim, in order.`, judgeLanguageRule(answerLang), claimsBuilder.String())
+
+	resp, err := judge.Chat(llm.WithOperation(ctx, llm.OperationJudge), llm.ChatRequest{
+		Model: model,
+		Messages: []llm.Message{
+			{Role: "user", Content: prompt},
+		},
+		Temperature:    0,
+		ResponseFormat: "json_object",
+	})
+	if err != nil {
+		return 0, 0, TokenUsage{}, fmt.Errorf("citation judge LLM call failed: %w", err)
+	}
+	usage := TokenUsage{
+		PromptTokens:     resp.PromptTokens,
+		CompletionTokens: resp.CompletionTokens,
+		TotalTokens:      resp.TotalTokens,
+		CachedTokens:     resp.CachedTokens,
+	}
+
+	var result struct {
+		Supported []bool `json:"supported"`
+	}
+	if err := json.Unmarshal([]byte(resp.Content), &result); err != nil {
+		return 0, 0, usage, fmt.Errorf("citation judge response parse error: %w (response: %s)", err, truncateStr(resp.Content, 200))
+	}
+	if len(result.Supported) != len(judged) {
+		slog.Warn("citation judge returned wrong number of booleans",
+			"expected", len(judged),
+			"got", len(result.Supported))
+		if len(result.Supported) > len(judged) {
+			result.Supported = result.Supported[:len(judged)]
+		}
+	}
+
+	supported := 0
+	for _, s := range result.Supported {
+		if s {
+			supported++
+		}
+	}
+	return float64(supported) / float64(len(claims)), len(claims), usage, nil
+}
+
+// sourceLabel names a cited passage for the judge: file, page, heading.
+func sourceLabel(s goreason.Source) string {
+	label := s.Filename
+	if s.PageNumber > 0 {
+		label += fmt.Sprintf(" p.%d", s.PageNumber)
+	}
+	if s.Heading != "" {
+		label += " | " + s.Heading
+	}
+	return label
+}
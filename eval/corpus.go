@@ -0,0 +1,258 @@
+package eval
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bbiangul/go-reason/store"
+)
+
+// CorpusDocument fingerprints one ingested document.
+type CorpusDocument struct {
+	Path        string `json:"path"`
+	Filename    string `json:"filename"`
+	ContentHash string `json:"content_hash"`
+	ParseMethod string `json:"parse_method"`
+	Status      string `json:"status"`
+	Chunks      int    `json:"chunks"`
+}
+
+// CorpusSnapshot fingerprints the indexed corpus an eval run was executed
+// against: which documents, their hashes and chunk counts, and graph stats.
+// Two runs with the same Fingerprint were evaluated over an identical index.
+type CorpusSnapshot struct {
+	Fingerprint string           `json:"fingerprint"`
+	CreatedAt   time.Time        `json:"created_at"`
+	Documents   []CorpusDocument `json:"documents"`
+	Stats       store.DBStats    `json:"stats"`
+}
+
+// SnapshotCorpus reads the document list, per-document chunk counts and
+// aggregate graph statistics from the store.
+func SnapshotCorpus(ctx context.Context, s *store.Store) (*CorpusSnapshot, error) {
+	docs, err := s.ListDocuments(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing documents: %w", err)
+	}
+
+	counts := make(map[int64]int)
+	rows, err := s.DB().QueryContext(ctx, `SELECT document_id, COUNT(*) FROM chunks GROUP BY document_id`)
+	if err != nil {
+		return nil, fmt.Errorf("counting chunks: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var docID int64
+		var n int
+		if err := rows.Scan(&docID, &n); err != nil {
+			return nil, fmt.Errorf("scanning chunk count: %w", err)
+		}
+		counts[docID] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("counting chunks: %w", err)
+	}
+
+	stats, err := s.DBStats(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("collecting db stats: %w", err)
+	}
+
+	snap := &CorpusSnapshot{
+		CreatedAt: time.Now().UTC(),
+		Stats:     *stats,
+	}
+	for _, d := range docs {
+		snap.Documents = append(snap.Documents, CorpusDocument{
+			Path:        d.Path,
+			Filename:    d.Filename,
+			ContentHash: d.ContentHash,
+			ParseMethod: d.ParseMethod,
+			Status:      d.Status,
+			Chunks:      counts[d.ID],
+		})
+	}
+	sort.Slice(snap.Documents, func(i, j int) bool {
+		return snap.Documents[i].Path < snap.Documents[j].Path
+	})
+	snap.Fingerprint = corpusFingerprint(snap)
+	return snap, nil
+}
+
+// corpusFingerprint hashes the document set and graph stats. Timestamps are
+// excluded so re-ingesting identical content yields the same fingerprint.
+func corpusFingerprint(s *CorpusSnapshot) string {
+	h := sha256.New()
+	for _, d := range s.Documents {
+		fmt.Fprintf(h, "%s\x00%s\x00%s\x00%d\n", d.Path, d.ContentHash, d.ParseMethod, d.Chunks)
+	}
+	st := s.Stats
+	fmt.Fprintf(h, "stats %d %d %d %d %d %d\n",
+		st.Documents, st.Chunks, st.Embeddings, st.Entities, st.Relationships, st.Communities)
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// CorpusDocumentChange describes a document present in both snapshots whose
+// content or chunking differs.
+type CorpusDocumentChange struct {
+	Path         string `json:"path"`
+	HashBefore   string `json:"hash_before"`
+	HashAfter    string `json:"hash_after"`
+	ChunksBefore int    `json:"chunks_before"`
+	ChunksAfter  int    `json:"chunks_after"`
+	ParseBefore  string `json:"parse_before,omitempty"`
+	ParseAfter   string `json:"parse_after,omitempty"`
+}
+
+// CorpusDiff is the difference between two corpus snapshots.
+type CorpusDiff struct {
+	Identical  bool                   `json:"identical"`
+	Material   bool                   `json:"material"` // see materialChange
+	Added      []string               `json:"added,omitempty"`
+	Removed    []string               `json:"removed,omitempty"`
+	Changed    []CorpusDocumentChange `json:"changed,omitempty"`
+	StatDeltas map[string]int         `json:"stat_deltas,omitempty"` // b - a, only non-zero entries
+}
+
+// graphStatTolerance is the relative change in entity/relationship/community
+// counts below which graph differences are treated as extraction noise
+// rather than a corpus change. LLM graph extraction is not deterministic,
+// so re-ingesting the same documents rarely reproduces identical counts.
+const graphStatTolerance = 0.02
+
+// materialChange reports whether the corpus changed enough to plausibly
+// explain a difference in eval results: documents were added, removed or
+// re-chunked, or graph statistics moved by more than graphStatTolerance.
+func materialChange(a, b *CorpusSnapshot, d CorpusDiff) bool {
+	if len(d.Added) > 0 || len(d.Removed) > 0 || len(d.Changed) > 0 {
+		return true
+	}
+	pairs := [][2]int{
+		{a.Stats.Chunks, b.Stats.Chunks},
+		{a.Stats.Embeddings, b.Stats.Embeddings},
+		{a.Stats.Entities, b.Stats.Entities},
+		{a.Stats.Relationships, b.Stats.Relationships},
+		{a.Stats.Communities, b.Stats.Communities},
+	}
+	for _, p := range pairs {
+		if p[0] == p[1] {
+			continue
+		}
+		base := math.Max(float64(p[0]), 1)
+		if math.Abs(float64(p[1]-p[0]))/base > graphStatTolerance {
+			return true
+		}
+	}
+	return false
+}
+
+// DiffCorpus compares two corpus snapshots, keyed by document path.
+func DiffCorpus(a, b *CorpusSnapshot) CorpusDiff {
+	var diff CorpusDiff
+	before := make(map[string]CorpusDocument, len(a.Documents))
+	for _, d := range a.Documents {
+		before[d.Path] = d
+	}
+	after := make(map[string]CorpusDocument, len(b.Documents))
+	for _, d := range b.Documents {
+		after[d.Path] = d
+		old, ok := before[d.Path]
+		if !ok {
+			diff.Added = append(diff.Added, d.Path)
+			continue
+		}
+		if old.ContentHash != d.ContentHash || old.Chunks != d.Chunks || old.ParseMethod != d.ParseMethod {
+			diff.Changed = append(diff.Changed, CorpusDocumentChange{
+				Path:         d.Path,
+				HashBefore:   old.ContentHash,
+				HashAfter:    d.ContentHash,
+				ChunksBefore: old.Chunks,
+				ChunksAfter:  d.Chunks,
+				ParseBefore:  old.ParseMethod,
+				ParseAfter:   d.ParseMethod,
+			})
+		}
+	}
+	for _, d := range a.Documents {
+		if _, ok := after[d.Path]; !ok {
+			diff.Removed = append(diff.Removed, d.Path)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Slice(diff.Changed, func(i, j int) bool { return diff.Changed[i].Path < diff.Changed[j].Path })
+
+	deltas := map[string]int{
+		"documents":     b.Stats.Documents - a.Stats.Documents,
+		"chunks":        b.Stats.Chunks - a.Stats.Chunks,
+		"embeddings":    b.Stats.Embeddings - a.Stats.Embeddings,
+		"entities":      b.Stats.Entities - a.Stats.Entities,
+		"relationships": b.Stats.Relationships - a.Stats.Relationships,
+		"communities":   b.Stats.Communities - a.Stats.Communities,
+	}
+	for k, v := range deltas {
+		if v != 0 {
+			if diff.StatDeltas == nil {
+				diff.StatDeltas = make(map[string]int)
+			}
+			diff.StatDeltas[k] = v
+		}
+	}
+
+	diff.Identical = len(diff.Added) == 0 && len(diff.Removed) == 0 &&
+		len(diff.Changed) == 0 && len(diff.StatDeltas) == 0
+	diff.Material = materialChange(a, b, diff)
+	return diff
+}
+
+// FormatCorpusDiff renders a corpus diff as human-readable text.
+func FormatCorpusDiff(a, b *CorpusSnapshot, d CorpusDiff) string {
+	var sb strings.Builder
+	sb.WriteString("=== Corpus Diff ===\n")
+	fmt.Fprintf(&sb, "Fingerprint: %s -> %s\n", a.Fingerprint, b.Fingerprint)
+	if d.Identical {
+		sb.WriteString("Corpus identical.\n")
+		return sb.String()
+	}
+
+	fmt.Fprintf(&sb, "Documents: %d -> %d (+%d added, -%d removed, %d changed)\n",
+		len(a.Documents), len(b.Documents), len(d.Added), len(d.Removed), len(d.Changed))
+	for _, p := range d.Added {
+		fmt.Fprintf(&sb, "  + %s\n", p)
+	}
+	for _, p := range d.Removed {
+		fmt.Fprintf(&sb, "  - %s\n", p)
+	}
+	for _, c := range d.Changed {
+		var parts []string
+		if c.HashBefore != c.HashAfter {
+			parts = append(parts, "content changed")
+		}
+		if c.ChunksBefore != c.ChunksAfter {
+			parts = append(parts, fmt.Sprintf("chunks %d -> %d", c.ChunksBefore, c.ChunksAfter))
+		}
+		if c.ParseBefore != c.ParseAfter {
+			parts = append(parts, fmt.Sprintf("parser %s -> %s", c.ParseBefore, c.ParseAfter))
+		}
+		fmt.Fprintf(&sb, "  ~ %s (%s)\n", c.Path, strings.Join(parts, ", "))
+	}
+
+	if len(d.StatDeltas) > 0 {
+		sb.WriteString("Stat deltas:\n")
+		keys := make([]string, 0, len(d.StatDeltas))
+		for k := range d.StatDeltas {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(&sb, "  %-14s %+d\n", k, d.StatDeltas[k])
+		}
+	}
+	return sb.String()
+}
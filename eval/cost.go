@@ -0,0 +1,230 @@
+package eval
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// ModelPrice is the per-million-token price of a model in US dollars.
+// CachedInputPerMillion prices prompt tokens served from the provider's
+// prompt cache; 0 bills them at InputPerMillion.
+type ModelPrice struct {
+	InputPerMillion       float64 `json:"input_per_million"`
+	OutputPerMillion      float64 `json:"output_per_million"`
+	CachedInputPerMillion float64 `json:"cached_input_per_million,omitempty"`
+}
+
+// DefaultPricing holds list prices for commonly evaluated models. Prices are
+// estimates for comparing runs, not billing; override with SetPricing.
+// Local models (Ollama, LM Studio) are absent and therefore cost nothing.
+var DefaultPricing = map[string]ModelPrice{
+	"gpt-4o":                  {InputPerMillion: 2.50, OutputPerMillion: 10.00, CachedInputPerMillion: 1.25},
+	"gpt-4o-mini":             {InputPerMillion: 0.15, OutputPerMillion: 0.60, CachedInputPerMillion: 0.075},
+	"gpt-4.1":                 {InputPerMillion: 2.00, OutputPerMillion: 8.00, CachedInputPerMillion: 0.50},
+	"gpt-4.1-mini":            {InputPerMillion: 0.40, OutputPerMillion: 1.60, CachedInputPerMillion: 0.10},
+	"gpt-4.1-nano":            {InputPerMillion: 0.10, OutputPerMillion: 0.40, CachedInputPerMillion: 0.025},
+	"gpt-oss-120b":            {InputPerMillion: 0.15, OutputPerMillion: 0.75},
+	"gpt-oss-20b":             {InputPerMillion: 0.10, OutputPerMillion: 0.50},
+	"llama-3.3-70b-versatile": {InputPerMillion: 0.59, OutputPerMillion: 0.79},
+	"llama-3.1-8b-instant":    {InputPerMillion: 0.05, OutputPerMillion: 0.08},
+	"gemini-2.0-flash":        {InputPerMillion: 0.10, OutputPerMillion: 0.40, CachedInputPerMillion: 0.025},
+	"gemini-2.0-flash-lite":   {InputPerMillion: 0.075, OutputPerMillion: 0.30},
+	"gemini-2.5-flash":        {InputPerMillion: 0.30, OutputPerMillion: 2.50, CachedInputPerMillion: 0.075},
+	"gemini-2.5-pro":          {InputPerMillion: 1.25, OutputPerMillion: 10.00, CachedInputPerMillion: 0.31},
+	"grok-3-mini":             {InputPerMillion: 0.30, OutputPerMillion: 0.50},
+}
+
+// lookupPrice finds the price for a model. Provider prefixes such as
+// "openai/" (OpenRouter, Groq) are ignored, and dated snapshots like
+// "gpt-4o-mini-2024-07-18" fall back to the longest matching base name.
+func lookupPrice(pricing map[string]ModelPrice, model string) (ModelPrice, bool) {
+	if model == "" {
+		return ModelPrice{}, false
+	}
+	name := strings.ToLower(model)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	if p, ok := pricing[name]; ok {
+		return p, true
+	}
+	best := ""
+	for k := range pricing {
+		if strings.HasPrefix(name, k+"-") && len(k) > len(best) {
+			best = k
+		}
+	}
+	if best == "" {
+		return ModelPrice{}, false
+	}
+	return pricing[best], true
+}
+
+// estimateCost returns the dollar cost of the given token usage, and whether
+// the model was found in the pricing table. Cached prompt tokens are billed
+// at the cached input price when the model has one.
+func estimateCost(pricing map[string]ModelPrice, model string, u TokenUsage) (float64, bool) {
+	if u.PromptTokens == 0 && u.CompletionTokens == 0 {
+		return 0, true
+	}
+	p, ok := lookupPrice(pricing, model)
+	if !ok {
+		return 0, false
+	}
+	cached := min(u.CachedTokens, u.PromptTokens)
+	cachedPrice := p.CachedInputPerMillion
+	if cachedPrice == 0 {
+		cachedPrice = p.InputPerMillion
+	}
+	return float64(u.PromptTokens-cached)/1e6*p.InputPerMillion +
+		float64(cached)/1e6*cachedPrice +
+		float64(u.CompletionTokens)/1e6*p.OutputPerMillion, true
+}
+
+// cacheHitRate is the fraction of prompt tokens served from prompt caches.
+func cacheHitRate(u TokenUsage) float64 {
+	if u.PromptTokens == 0 {
+		return 0
+	}
+	return float64(u.CachedTokens) / float64(u.PromptTokens)
+}
+
+// LatencyStats summarises a latency distribution in milliseconds.
+type LatencyStats struct {
+	P50Ms  int64 `json:"p50_ms"`
+	P95Ms  int64 `json:"p95_ms"`
+	MeanMs int64 `json:"mean_ms"`
+	MaxMs  int64 `json:"max_ms"`
+}
+
+// LatencyReport holds latency distributions for the whole test and for each
+// pipeline phase.
+type LatencyReport struct {
+	Total     LatencyStats `json:"total"`
+	Retrieval LatencyStats `json:"retrieval"`
+	Reasoning LatencyStats `json:"reasoning"`
+	Judge     LatencyStats `json:"judge"`
+}
+
+// PhaseTokens breaks token usage down by pipeline phase. Retrieval covers
+// LLM query translation; reasoning covers answer generation and refinement;
+// judge covers LLM-as-judge accuracy scoring.
+type PhaseTokens struct {
+	Retrieval TokenUsage `json:"retrieval"`
+	Reasoning TokenUsage `json:"reasoning"`
+	Judge     TokenUsage `json:"judge"`
+}
+
+func (u *TokenUsage) add(o TokenUsage) {
+	u.PromptTokens += o.PromptTokens
+	u.CompletionTokens += o.CompletionTokens
+	u.TotalTokens += o.TotalTokens
+	u.CachedTokens += o.CachedTokens
+}
+
+// percentile returns the nearest-rank percentile (0-100) of sorted values.
+func percentile(sorted []int64, p float64) int64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+func latencyStats(values []int64) LatencyStats {
+	if len(values) == 0 {
+		return LatencyStats{}
+	}
+	sorted := append([]int64(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var sum int64
+	for _, v := range sorted {
+		sum += v
+	}
+	return LatencyStats{
+		P50Ms:  percentile(sorted, 50),
+		P95Ms:  percentile(sorted, 95),
+		MeanMs: sum / int64(len(sorted)),
+		MaxMs:  sorted[len(sorted)-1],
+	}
+}
+
+// summarizeCostLatency fills the report's phase token totals, cost, prompt
+// cache hit rate, and latency percentiles from its per-test results. Errored tests count toward
+// cost (the tokens were spent) but not toward latency percentiles.
+func summarizeCostLatency(r *Report) {
+	var total, retrieval, reasoning, judge []int64
+	seen := make(map[string]bool)
+	r.PhaseTokens = PhaseTokens{}
+	r.CostUSD = 0
+	r.UnpricedModels = nil
+	for _, res := range r.Results {
+		r.PhaseTokens.Retrieval.add(res.PhaseTokens.Retrieval)
+		r.PhaseTokens.Reasoning.add(res.PhaseTokens.Reasoning)
+		r.PhaseTokens.Judge.add(res.PhaseTokens.Judge)
+		r.CostUSD += res.CostUSD
+		for _, m := range res.UnpricedModels {
+			if !seen[m] {
+				seen[m] = true
+				r.UnpricedModels = append(r.UnpricedModels, m)
+			}
+		}
+		if res.Error != "" {
+			continue
+		}
+		total = append(total, res.ElapsedMs)
+		retrieval = append(retrieval, res.RetrievalMs)
+		reasoning = append(reasoning, res.ReasoningMs)
+		judge = append(judge, res.JudgeMs)
+	}
+	sort.Strings(r.UnpricedModels)
+	var all TokenUsage
+	all.add(r.PhaseTokens.Retrieval)
+	all.add(r.PhaseTokens.Reasoning)
+	all.add(r.PhaseTokens.Judge)
+	r.TokenUsage.CachedTokens = all.CachedTokens
+	r.CacheHitRate = cacheHitRate(all)
+	r.Latency = LatencyReport{
+		Total:     latencyStats(total),
+		Retrieval: latencyStats(retrieval),
+		Reasoning: latencyStats(reasoning),
+		Judge:     latencyStats(judge),
+	}
+	if len(r.Results) > 0 {
+		r.AvgCostUSD = r.CostUSD / float64(len(r.Results))
+	}
+}
+
+// priceResult computes the per-test cost for each phase. Retrieval and
+// reasoning are billed at the answering model's price; judge calls at the
+// judge model's price.
+func priceResult(res *TestResult, pricing map[string]ModelPrice, answerModel, judgeModel string) {
+	res.CostUSD = 0
+	res.UnpricedModels = nil
+	charge := func(model string, u TokenUsage) {
+		cost, ok := estimateCost(pricing, model, u)
+		if !ok {
+			if model == "" {
+				model = "unknown"
+			}
+			for _, m := range res.UnpricedModels {
+				if m == model {
+					return
+				}
+			}
+			res.UnpricedModels = append(res.UnpricedModels, model)
+			return
+		}
+		res.CostUSD += cost
+	}
+	charge(answerModel, res.PhaseTokens.Retrieval)
+	charge(answerModel, res.PhaseTokens.Reasoning)
+	charge(judgeModel, res.PhaseTokens.Judge)
+}
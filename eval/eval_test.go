@@ -1,11 +1,18 @@
 package eval
 
 import (
+	"context"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/bbiangul/go-reason"
+	"github.com/bbiangul/go-reason/llm"
+	"github.com/bbiangul/go-reason/store"
 )
 
 func TestEasyDataset(t *testing.T) {
@@ -373,6 +380,209 @@ func TestFormatReportEmpty(t *testing.T) {
 	}
 }
 
+func TestLatencyStats(t *testing.T) {
+	values := []int64{500, 100, 300, 200, 400, 1000, 600, 700, 800, 900}
+	got := latencyStats(values)
+	want := LatencyStats{P50Ms: 500, P95Ms: 1000, MeanMs: 550, MaxMs: 1000}
+	if got != want {
+		t.Errorf("latencyStats = %+v, want %+v", got, want)
+	}
+	if values[0] != 500 {
+		t.Error("latencyStats must not reorder its input")
+	}
+	if (latencyStats(nil) != LatencyStats{}) {
+		t.Error("expected zero stats for no values")
+	}
+}
+
+func TestLookupPrice(t *testing.T) {
+	tests := []struct {
+		model string
+		want  bool
+	}{
+		{"gpt-4o-mini", true},
+		{"openai/gpt-oss-120b", true},
+		{"gpt-4o-mini-2024-07-18", true},
+		{"llama3.1:8b", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		_, ok := lookupPrice(DefaultPricing, tt.model)
+		if ok != tt.want {
+			t.Errorf("lookupPrice(%q) found=%v, want %v", tt.model, ok, tt.want)
+		}
+	}
+
+	// Dated snapshot resolves to the longest base name, not a shorter prefix.
+	p, _ := lookupPrice(DefaultPricing, "gpt-4o-mini-2024-07-18")
+	if p != DefaultPricing["gpt-4o-mini"] {
+		t.Errorf("expected gpt-4o-mini price, got %+v", p)
+	}
+}
+
+func TestCachedTokenPricing(t *testing.T) {
+	pricing := map[string]ModelPrice{
+		"cached":   {InputPerMillion: 1, OutputPerMillion: 2, CachedInputPerMillion: 0.25},
+		"uncached": {InputPerMillion: 1, OutputPerMillion: 2},
+	}
+	u := TokenUsage{PromptTokens: 1_000_000, CachedTokens: 800_000, TotalTokens: 1_000_000}
+	if cost, _ := estimateCost(pricing, "cached", u); math.Abs(cost-0.4) > 1e-9 {
+		t.Errorf("cost = %f, want 0.4 (200k at $1 + 800k at $0.25)", cost)
+	}
+	if cost, _ := estimateCost(pricing, "uncached", u); cost != 1.0 {
+		t.Errorf("cost = %f, want 1.0 without a cached price", cost)
+	}
+
+	res := TestResult{}
+	res.PhaseTokens.Reasoning = u
+	res.PhaseTokens.Judge = TokenUsage{PromptTokens: 1_000_000, TotalTokens: 1_000_000}
+	r := &Report{Results: []TestResult{res}}
+	summarizeCostLatency(r)
+	if r.CacheHitRate != 0.4 || r.TokenUsage.CachedTokens != 800_000 {
+		t.Errorf("hit rate = %v, cached = %d; want 0.4, 800000", r.CacheHitRate, r.TokenUsage.CachedTokens)
+	}
+	if out := FormatReport(r); !strings.Contains(out, "Cached:     800000 (40.0% of prompt tokens)") {
+		t.Errorf("report missing cache line:\n%s", out)
+	}
+}
+
+func TestComputeSpanMetrics(t *testing.T) {
+	gt := []GroundTruthSpan{
+		{FilePath: "cuad/acme.txt", ByteStart: 100, ByteEnd: 200},
+		{FilePath: "cuad/acme.txt", ByteStart: 500, ByteEnd: 600},
+	}
+	answer := &goreason.Answer{Sources: []goreason.Source{
+		// Covers the first span and 100 bytes around it.
+		{Path: "/corpus/cuad/acme.txt", Span: &goreason.SourceSpan{StartOffset: 50, EndOffset: 250}},
+		// Overlaps the previous source; counted once.
+		{Path: "/corpus/cuad/acme.txt", Span: &goreason.SourceSpan{StartOffset: 150, EndOffset: 250}},
+		// Another document.
+		{Path: "/corpus/cuad/other.txt", Content: strings.Repeat("x", 100), Span: &goreason.SourceSpan{StartOffset: 100, EndOffset: 200}},
+		// Half of the second span.
+		{Path: "/corpus/cuad/acme.txt", Span: &goreason.SourceSpan{StartOffset: 550, EndOffset: 600}},
+	}}
+
+	p, r := computeSpanMetricsAtK(answer, gt, 1)
+	if math.Abs(p-0.5) > 1e-9 || math.Abs(r-0.5) > 1e-9 {
+		t.Errorf("@1: precision %.3f recall %.3f, want 0.5 0.5", p, r)
+	}
+	// Retrieved 200 + 100 (other file) + 50 bytes; 150 of them in spans.
+	p, r = computeSpanMetricsAtK(answer, gt, 4)
+	if math.Abs(p-150.0/350) > 1e-9 || math.Abs(r-0.75) > 1e-9 {
+		t.Errorf("@4: precision %.3f recall %.3f, want %.3f 0.75", p, r, 150.0/350)
+	}
+
+	if p, r := computeSpanMetricsAtK(&goreason.Answer{}, gt, 4); p != 0 || r != 0 {
+		t.Errorf("no sources: %v %v", p, r)
+	}
+}
+
+func TestCharSpanToBytes(t *testing.T) {
+	content := "Cláusula 5: el Arrendatario pagará"
+	start, end := charSpanToBytes(content, 12, 26)
+	if got := content[start:end]; got != "el Arrendatari" {
+		t.Errorf("span = %q", got)
+	}
+	if start, end := charSpanToBytes("abc", 1, 3); start != 1 || end != 3 {
+		t.Errorf("ASCII span = %d:%d, want 1:3", start, end)
+	}
+}
+
+func TestSummarizeCostLatency(t *testing.T) {
+	pricing := map[string]ModelPrice{"chat": {InputPerMillion: 1, OutputPerMillion: 2}}
+	results := []TestResult{
+		{ElapsedMs: 100, RetrievalMs: 20, ReasoningMs: 80},
+		{ElapsedMs: 300, RetrievalMs: 40, ReasoningMs: 260},
+		{Error: "boom", ElapsedMs: 5},
+	}
+	results[0].PhaseTokens.Reasoning = TokenUsage{PromptTokens: 1_000_000, CompletionTokens: 500_000, TotalTokens: 1_500_000}
+	results[1].PhaseTokens.Judge = TokenUsage{PromptTokens: 10, TotalTokens: 10}
+	priceResult(&results[0], pricing, "chat", "")
+	priceResult(&results[1], pricing, "chat", "judge-x")
+
+	if results[0].CostUSD != 2.0 {
+		t.Errorf("cost = %f, want 2.0", results[0].CostUSD)
+	}
+	if len(results[1].UnpricedModels) != 1 || results[1].UnpricedModels[0] != "judge-x" {
+		t.Errorf("expected judge-x unpriced, got %v", results[1].UnpricedModels)
+	}
+
+	r := &Report{Results: results}
+	summarizeCostLatency(r)
+	if r.CostUSD != 2.0 || r.PhaseTokens.Reasoning.TotalTokens != 1_500_000 || r.PhaseTokens.Judge.TotalTokens != 10 {
+		t.Errorf("unexpected totals: cost=%f phases=%+v", r.CostUSD, r.PhaseTokens)
+	}
+	if r.Latency.Total.MaxMs != 300 || r.Latency.Total.P50Ms != 100 {
+		t.Errorf("errored tests must be excluded from latency, got %+v", r.Latency.Total)
+	}
+
+	out := FormatReport(r)
+	for _, check := range []string{"Estimated Cost", "$2.0000", "Unpriced:   judge-x", "Latency (ms)", "Retrieval"} {
+		if !strings.Contains(out, check) {
+			t.Errorf("report missing %q in output:\n%s", check, out)
+		}
+	}
+}
+
+func TestDiffCorpus(t *testing.T) {
+	a := &CorpusSnapshot{
+		Documents: []CorpusDocument{
+			{Path: "/c/a.txt", ContentHash: "h1", Chunks: 10},
+			{Path: "/c/b.txt", ContentHash: "h2", Chunks: 5},
+		},
+		Stats: store.DBStats{Documents: 2, Chunks: 15, Entities: 1000},
+	}
+	a.Fingerprint = corpusFingerprint(a)
+
+	same := *a
+	if d := DiffCorpus(a, &same); !d.Identical || d.Material {
+		t.Errorf("expected identical diff, got %+v", d)
+	}
+
+	// Small graph drift from re-extraction is not material.
+	noisy := *a
+	noisy.Stats.Entities = 1010
+	if d := DiffCorpus(a, &noisy); d.Identical || d.Material {
+		t.Errorf("expected non-material graph drift, got %+v", d)
+	}
+
+	b := &CorpusSnapshot{
+		Documents: []CorpusDocument{
+			{Path: "/c/a.txt", ContentHash: "h1", Chunks: 12},
+			{Path: "/c/c.txt", ContentHash: "h3", Chunks: 4},
+		},
+		Stats: store.DBStats{Documents: 2, Chunks: 16, Entities: 1000},
+	}
+	b.Fingerprint = corpusFingerprint(b)
+	if a.Fingerprint == b.Fingerprint {
+		t.Error("different corpora must have different fingerprints")
+	}
+
+	d := DiffCorpus(a, b)
+	if !d.Material {
+		t.Error("expected material diff")
+	}
+	if len(d.Added) != 1 || d.Added[0] != "/c/c.txt" {
+		t.Errorf("added = %v", d.Added)
+	}
+	if len(d.Removed) != 1 || d.Removed[0] != "/c/b.txt" {
+		t.Errorf("removed = %v", d.Removed)
+	}
+	if len(d.Changed) != 1 || d.Changed[0].ChunksAfter != 12 {
+		t.Errorf("changed = %+v", d.Changed)
+	}
+	if d.StatDeltas["chunks"] != 1 {
+		t.Errorf("chunk delta = %d, want 1", d.StatDeltas["chunks"])
+	}
+
+	out := FormatCorpusDiff(a, b, d)
+	for _, check := range []string{"+ /c/c.txt", "- /c/b.txt", "chunks 10 -> 12"} {
+		if !strings.Contains(out, check) {
+			t.Errorf("diff output missing %q:\n%s", check, out)
+		}
+	}
+}
+
 func TestPDFComplexityReport(t *testing.T) {
 	results := []PDFComplexityResult{
 		{
@@ -797,3 +1007,283 @@ func TestComputeCitationQuality(t *testing.T) {
 		})
 	}
 }
+
+// coverAllJudge is a judge that finds every expected fact covered.
+type coverAllJudge struct{ calls int }
+
+func (j *coverAllJudge) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	j.calls++
+	return &llm.ChatResponse{Content: `{"covered": [true, true]}`, PromptTokens: 100, CompletionTokens: 10, TotalTokens: 110}, nil
+}
+
+func (j *coverAllJudge) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return nil, nil
+}
+
+func TestReplayAnswers(t *testing.T) {
+	stored := []StoredAnswer{
+		{
+			Dataset: "Easy", Difficulty: DifficultyEasy,
+			Test:    TestCase{Question: "What is the relief pressure?", ExpectedFacts: []string{"10 bar", "relief valve"}},
+			Spans:   []GroundTruthSpan{{FilePath: "pump.txt", Start: 0, End: 20}},
+			QueryMs: 1500,
+			Answer: &goreason.Answer{
+				Text:         "The valve opens at ten bar.",
+				Sources:      []goreason.Source{{Filename: "pump.txt", Content: "The relief valve opens at 10 bar."}},
+				ModelUsed:    "gpt-4o-mini",
+				PromptTokens: 900, CompletionTokens: 50, TotalTokens: 950,
+			},
+		},
+		{
+			Dataset: "Easy", Difficulty: DifficultyEasy,
+			Test:  TestCase{Question: "Who maintains the pump?", ExpectedFacts: []string{"technician"}},
+			Error: "context deadline exceeded",
+		},
+		{
+			Dataset: "Hard", Difficulty: DifficultyHard,
+			Test: TestCase{Question: "Why does the valve chatter?", ExpectedFacts: []string{"oversized"}},
+		},
+	}
+
+	// Round-trip through the answers file.
+	path := filepath.Join(t.TempDir(), "answers.jsonl")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := json.NewEncoder(f)
+	for _, a := range stored {
+		if err := enc.Encode(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.Close()
+	answers, err := ReadStoredAnswers(path)
+	if err != nil {
+		t.Fatalf("ReadStoredAnswers: %v", err)
+	}
+
+	datasets, spans := StoredDatasets(answers)
+	if len(datasets) != 2 || datasets[0].Name != "Easy" || len(datasets[0].Tests) != 2 || datasets[1].Difficulty != DifficultyHard {
+		t.Fatalf("datasets = %+v", datasets)
+	}
+	if len(spans["What is the relief pressure?"]) != 1 {
+		t.Errorf("spans = %v", spans)
+	}
+
+	judge := &coverAllJudge{}
+	e := NewEvaluator(nil)
+	e.ReplayAnswers(answers)
+	e.SetGroundTruth(spans)
+	e.SetJudge(judge, "gemini-2.0-flash")
+
+	report, err := e.Run(context.Background(), datasets[0])
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if judge.calls != 1 {
+		t.Errorf("judge called %d times, want 1", judge.calls)
+	}
+	r := report.Results[0]
+	if r.Accuracy != 1 || r.StrictAccuracy >= 1 {
+		t.Errorf("accuracy = %f, strict = %f; want the judge's 1 over a lower strict score", r.Accuracy, r.StrictAccuracy)
+	}
+	if r.PromptTokens != 900 || r.PhaseTokens.Judge.TotalTokens != 110 || r.Model != "gpt-4o-mini" {
+		t.Errorf("tokens = %d, judge tokens = %d, model = %q", r.PromptTokens, r.PhaseTokens.Judge.TotalTokens, r.Model)
+	}
+	if r.ElapsedMs < 1500 {
+		t.Errorf("ElapsedMs = %d, want the stored query time included", r.ElapsedMs)
+	}
+	if r.RetrievalPrecision == nil {
+		t.Error("retrieval metrics not computed from stored spans")
+	}
+	if report.Results[1].Error != "context deadline exceeded" {
+		t.Errorf("error = %q, want the stored error", report.Results[1].Error)
+	}
+
+	// A test the run never answered is an error, not a query.
+	report, err = e.Run(context.Background(), Dataset{Name: "Easy", Tests: []TestCase{{Question: "New question?"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Results[0].Error != "no stored answer" {
+		t.Errorf("error = %q, want no stored answer", report.Results[0].Error)
+	}
+}
+
+func TestMineHardNegatives(t *testing.T) {
+	report := &Report{Dataset: "Pump", Results: []TestResult{
+		{
+			Question: "At what pressure does the relief valve open?",
+			Sources: []SourceTrace{
+				{ChunkID: 7, Content: "The safety valve is red.", Methods: []string{"vector"}, VecRank: 1},
+				{ChunkID: 8, Content: "Opens at 10 BAR, see 4.2."}, // also holds the fact
+				{ChunkID: 9, Content: "Relief valve overview."},
+				{ChunkID: 3, Content: "The relief valve opens at 10 bar."},
+				{ChunkID: 10, Content: "Ranked below the gold chunk."},
+			},
+			GroundTruth: &GroundTruthCheck{
+				FactsInDB:      []FactCheck{{Fact: "10 bar|ten bar", Found: true, ChunkID: 3}, {Fact: "green", Found: false}},
+				FactsRetrieved: []FactCheck{{Fact: "10 bar|ten bar", Found: true, ChunkID: 3, ChunkRank: 4}, {Fact: "green"}},
+				Diagnosis:      "MODEL_MISS",
+			},
+		},
+		{
+			Question: "How often is it inspected?",
+			Sources:  []SourceTrace{{ChunkID: 11, Content: "Inspection procedure."}, {ChunkID: 12, Content: "Lubrication."}},
+			GroundTruth: &GroundTruthCheck{
+				FactsInDB:      []FactCheck{{Fact: "500 hours", Found: true, ChunkID: 5}},
+				FactsRetrieved: []FactCheck{{Fact: "500 hours"}},
+				Diagnosis:      "RETRIEVAL_MISS",
+			},
+		},
+		{
+			Question: "Passed, so not mined",
+			Passed:   true,
+			Sources:  []SourceTrace{{ChunkID: 13, Content: "x"}},
+			GroundTruth: &GroundTruthCheck{
+				FactsInDB:      []FactCheck{{Fact: "y", Found: true, ChunkID: 14}},
+				FactsRetrieved: []FactCheck{{Fact: "y"}},
+			},
+		},
+	}}
+	loaded := map[int64]bool{}
+	getChunk := func(id int64) (*store.Chunk, error) {
+		loaded[id] = true
+		return &store.Chunk{ID: id, Heading: "4.2 Intervals", Content: "Inspect every 500 hours.", PageNumber: 12}, nil
+	}
+
+	got, err := mineHardNegatives(report, 5, getChunk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("mined %d, want 2: %+v", len(got), got)
+	}
+	ranked := got[0]
+	if ranked.Positive.ChunkID != 3 || ranked.Positive.Rank != 4 || ranked.Diagnosis != "MODEL_MISS" {
+		t.Errorf("positive = %+v, diagnosis %q", ranked.Positive, ranked.Diagnosis)
+	}
+	if len(ranked.Negatives) != 2 || ranked.Negatives[0].ChunkID != 7 || ranked.Negatives[1].ChunkID != 9 {
+		t.Errorf("negatives = %+v, want chunks 7 and 9 (above the gold, without the fact)", ranked.Negatives)
+	}
+	if ranked.Negatives[0].VecRank != 1 || len(ranked.Negatives[0].Methods) != 1 {
+		t.Errorf("leg ranks not kept: %+v", ranked.Negatives[0])
+	}
+	missed := got[1]
+	if missed.Positive.Rank != 0 || missed.Positive.Content != "Inspect every 500 hours." || len(missed.Negatives) != 2 {
+		t.Errorf("missed positive = %+v, negatives %d", missed.Positive, len(missed.Negatives))
+	}
+	if loaded[3] || !loaded[5] {
+		t.Errorf("loaded chunks %v, want only the unretrieved positive", loaded)
+	}
+
+	if got, _ := mineHardNegatives(report, 1, getChunk); len(got[0].Negatives) != 1 {
+		t.Errorf("maxNegatives not applied: %+v", got[0].Negatives)
+	}
+
+	var buf strings.Builder
+	if err := WriteHardNegatives(&buf, got); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	var first HardNegative
+	if len(lines) != 2 || json.Unmarshal([]byte(lines[0]), &first) != nil || first.Question != got[0].Question {
+		t.Errorf("exported lines = %q", lines)
+	}
+}
+
+// scriptedJudge answers every judge call with reply and keeps the prompts.
+type scriptedJudge struct {
+	reply   string
+	prompts []string
+}
+
+func (j *scriptedJudge) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	j.prompts = append(j.prompts, req.Messages[0].Content)
+	return &llm.ChatResponse{Content: j.reply, PromptTokens: 200, CompletionTokens: 10, TotalTokens: 210}, nil
+}
+
+func (j *scriptedJudge) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return nil, nil
+}
+
+func TestCitationAccuracy(t *testing.T) {
+	answer := &goreason.Answer{
+		Text: "The data subject may request erasure without undue delay (Art. 17). " +
+			"Processing is lawful with consent, see Article 6 on page 3.\n" +
+			"Fines reach 4% of turnover under Article 83. This holds in every member state.",
+		Sources: []goreason.Source{
+			{ChunkID: 1, Filename: "gdpr.pdf", Heading: "Article 17 Right to erasure", PageNumber: 5,
+				Content: "The data subject shall have the right to obtain erasure without undue delay."},
+			{ChunkID: 2, Filename: "gdpr.pdf", SectionNumber: "6", PageNumber: 3,
+				Content: "Processing shall be lawful only if the data subject has given consent."},
+			{ChunkID: 3, Filename: "gdpr.pdf", Heading: "Article 171 Transitional provisions", PageNumber: 9},
+		},
+	}
+
+	claims := extractCitedClaims(answer)
+	if len(claims) != 3 {
+		t.Fatalf("claims = %+v, want 3 cited sentences", claims)
+	}
+	if len(claims[0].sources) != 1 || claims[0].sources[0].ChunkID != 1 {
+		t.Errorf("Art. 17 resolved to %+v, want chunk 1 only", claims[0].sources)
+	}
+	if len(claims[1].refs) != 2 || len(claims[1].sources) != 1 || claims[1].sources[0].ChunkID != 2 {
+		t.Errorf("Article 6 on page 3 = %+v, want chunk 2", claims[1])
+	}
+	if len(claims[2].sources) != 0 {
+		t.Errorf("Article 83 resolved to %+v, but it was not retrieved", claims[2].sources)
+	}
+
+	// The two resolved claims go to the judge, which rejects the second;
+	// the unresolved Article 83 counts against the answer without a call.
+	judge := &scriptedJudge{reply: `{"supported": [true, false]}`}
+	acc, checked, usage, err := computeCitationAccuracyLLM(context.Background(), judge, "judge", answer, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checked != 3 || math.Abs(acc-1.0/3) > 1e-9 || usage.TotalTokens != 210 {
+		t.Errorf("accuracy = %v over %d claims (%d tokens), want 1/3 over 3", acc, checked, usage.TotalTokens)
+	}
+	if len(judge.prompts) != 1 || !strings.Contains(judge.prompts[0], "Claim 2: Processing is lawful") ||
+		strings.Contains(judge.prompts[0], "Article 83") {
+		t.Errorf("judge prompt = %v", judge.prompts)
+	}
+
+	// Answers without checkable references are not scored.
+	plain := &goreason.Answer{Text: "The relief valve opens at 10 bar (pump.txt)."}
+	if _, checked, _, _ := computeCitationAccuracyLLM(context.Background(), judge, "judge", plain, ""); checked != 0 || len(judge.prompts) != 1 {
+		t.Errorf("plain answer checked %d claims with %d judge calls", checked, len(judge.prompts))
+	}
+}
+
+func TestWriteHTMLReport(t *testing.T) {
+	report := &Report{
+		Dataset:    "Pump",
+		Difficulty: DifficultyEasy,
+		TotalTests: 2,
+		Passed:     1,
+		Failed:     1,
+		Results: []TestResult{
+			{Question: "At what pressure does the valve open?", ExpectedFacts: []string{"10 bar"}, Category: "single-fact",
+				Answer: "It opens at 10 bar.", Accuracy: 1, Passed: true, FactsCovered: []bool{true},
+				Sources: []SourceTrace{{ChunkID: 7, Heading: "Relief valve", Content: "Opens at 10 bar.", PageNumber: 3}}},
+			{Question: "How often is it inspected?", ExpectedFacts: []string{"500 operating hours"}, Category: "single-fact",
+				Answer: "Every <b>500</b> hours.", FactsCovered: []bool{false}, JudgeRationale: "Missing operating hours."},
+		},
+	}
+	var buf strings.Builder
+	if err := WriteHTMLReport(&buf, []*Report{report}, map[string]interface{}{"chat_model": "fake"}); err != nil {
+		t.Fatalf("WriteHTMLReport: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"chat_model", "50.0%", "single-fact (1/2)", "Relief valve", "page 3",
+		"Missing operating hours.", "Every &lt;b&gt;500&lt;/b&gt; hours.", "&#10007;",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report lacks %q", want)
+		}
+	}
+}
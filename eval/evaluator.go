@@ -2,6 +2,7 @@ package eval
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"sort"
@@ -19,11 +20,15 @@ type Evaluator struct {
 	groundTruth map[string][]GroundTruthSpan // query -> spans (for retrieval P@k/R@k)
 	judgeLLM    llm.Provider
 	judgeModel  string
+	pricing     map[string]ModelPrice
+	answerLang  string
+	answerLog   *json.Encoder           // see RecordAnswers
+	replay      map[string]StoredAnswer // see ReplayAnswers
 }
 
 // NewEvaluator creates a new evaluator.
 func NewEvaluator(engine goreason.Engine) *Evaluator {
-	return &Evaluator{engine: engine}
+	return &Evaluator{engine: engine, pricing: DefaultPricing}
 }
 
 // SetGroundTruth sets ground-truth spans for retrieval P@k/R@k computation.
@@ -39,6 +44,19 @@ func (e *Evaluator) SetJudge(provider llm.Provider, model string) {
 	e.judgeModel = model
 }
 
+// SetAnswerLanguage asks the engine to answer every test in lang (an ISO
+// 639-1 code or "auto", see goreason.WithAnswerLanguage) and tells the judge
+// which language to expect, so facts are matched across languages.
+func (e *Evaluator) SetAnswerLanguage(lang string) {
+	e.answerLang = lang
+}
+
+// SetPricing replaces the model pricing table used for cost estimates.
+// Keys are model names without provider prefix (e.g. "gpt-4o-mini").
+func (e *Evaluator) SetPricing(pricing map[string]ModelPrice) {
+	e.pricing = pricing
+}
+
 // Report holds the results of an evaluation run.
 type Report struct {
 	Dataset         string                      `json:"dataset"`
@@ -51,6 +69,14 @@ type Report struct {
 	Results         []TestResult                `json:"results"`
 	RunTime         time.Duration               `json:"run_time"`
 	TokenUsage      TokenUsage                  `json:"token_usage"`
+	PhaseTokens     PhaseTokens                 `json:"phase_tokens"`
+	Latency         LatencyReport               `json:"latency"`
+	CostUSD         float64                     `json:"cost_usd"`
+	AvgCostUSD      float64                     `json:"avg_cost_usd"`
+	UnpricedModels  []string                    `json:"unpriced_models,omitempty"`
+	// CacheHitRate is the fraction of prompt tokens, across all phases,
+	// that providers served from their prompt caches.
+	CacheHitRate float64 `json:"cache_hit_rate"`
 }
 
 // TokenUsage aggregates LLM token consumption across an evaluation run.
@@ -58,6 +84,7 @@ type TokenUsage struct {
 	PromptTokens     int `json:"prompt_tokens"`
 	CompletionTokens int `json:"completion_tokens"`
 	TotalTokens      int `json:"total_tokens"`
+	CachedTokens     int `json:"cached_tokens,omitempty"` // prompt tokens served from a prompt cache
 }
 
 // AggregateMetrics holds averaged metrics across all tests.
@@ -72,9 +99,16 @@ type AggregateMetrics struct {
 	AvgClaimGrounding      float64 `json:"avg_claim_grounding"`
 	AvgHallucinationScore  float64 `json:"avg_hallucination_score"`
 
+	// Citation accuracy, averaged over the CitationTests whose answers
+	// cited an article, section, clause, page, or source (judge runs only).
+	AvgCitationAccuracy float64 `json:"avg_citation_accuracy,omitempty"`
+	CitationTests       int     `json:"citation_tests,omitempty"`
+
 	// Retrieval metrics (populated when ground-truth spans are available)
 	AvgRetrievalPrecision map[int]float64 `json:"avg_retrieval_precision,omitempty"` // k -> P@k
 	AvgRetrievalRecall    map[int]float64 `json:"avg_retrieval_recall,omitempty"`    // k -> R@k
+	AvgSpanPrecision      map[int]float64 `json:"avg_span_precision,omitempty"`      // k -> character-level precision
+	AvgSpanRecall         map[int]float64 `json:"avg_span_recall,omitempty"`         // k -> character-level recall
 }
 
 // TestResult holds the result of a single test case with full diagnostics.
@@ -94,13 +128,34 @@ type TestResult struct {
 	ClaimGrounding     float64  `json:"claim_grounding"`
 	HallucinationScore float64  `json:"hallucination_score"`
 	Passed             bool     `json:"passed"`
+
+	// CitationAccuracy is the fraction of the answer's CitationsChecked
+	// claims whose cited articles or pages support them, as the judge
+	// found; see computeCitationAccuracyLLM.
+	CitationAccuracy float64 `json:"citation_accuracy,omitempty"`
+	CitationsChecked int     `json:"citations_checked,omitempty"`
+
+	// FactsCovered holds the judge's verdict on each of ExpectedFacts, and
+	// JudgeRationale its explanation of the facts not covered.
+	FactsCovered   []bool `json:"facts_covered,omitempty"`
+	JudgeRationale string `json:"judge_rationale,omitempty"`
+
 	Error            string   `json:"error,omitempty"`
 	PromptTokens     int      `json:"prompt_tokens"`
 	CompletionTokens int      `json:"completion_tokens"`
 	TotalTokens      int      `json:"total_tokens"`
 
+	// Cost breakdown
+	Model          string      `json:"model,omitempty"`
+	PhaseTokens    PhaseTokens `json:"phase_tokens"`
+	CostUSD        float64     `json:"cost_usd"`
+	UnpricedModels []string    `json:"unpriced_models,omitempty"`
+
 	// Timing
-	ElapsedMs int64 `json:"elapsed_ms"`
+	ElapsedMs   int64 `json:"elapsed_ms"`
+	RetrievalMs int64 `json:"retrieval_ms"`
+	ReasoningMs int64 `json:"reasoning_ms"`
+	JudgeMs     int64 `json:"judge_ms,omitempty"`
 
 	// Sources (the chunks the model actually saw)
 	Sources []SourceTrace `json:"sources,omitempty"`
@@ -117,6 +172,8 @@ type TestResult struct {
 	// Retrieval metrics (populated when ground-truth spans are available)
 	RetrievalPrecision map[int]float64 `json:"retrieval_precision,omitempty"` // k -> P@k
 	RetrievalRecall    map[int]float64 `json:"retrieval_recall,omitempty"`    // k -> R@k
+	SpanPrecision      map[int]float64 `json:"span_precision,omitempty"`      // k -> character-level precision
+	SpanRecall         map[int]float64 `json:"span_recall,omitempty"`         // k -> character-level recall
 }
 
 // SourceTrace records a single retrieved chunk with its retrieval metadata.
@@ -130,6 +187,9 @@ type SourceTrace struct {
 	VecRank    int      `json:"vec_rank,omitempty"`
 	FTSRank    int      `json:"fts_rank,omitempty"`
 	GraphRank  int      `json:"graph_rank,omitempty"`
+
+	// Span is the chunk's byte range in the source document.
+	Span *goreason.SourceSpan `json:"span,omitempty"`
 }
 
 // RetrievalTrace holds the full retrieval breakdown for a query.
@@ -144,6 +204,7 @@ type RetrievalTrace struct {
 	IdentifiersDetected bool     `json:"identifiers_detected"`
 	FTSQuery            string   `json:"fts_query"`
 	GraphEntities       []string `json:"graph_entities"`
+	SkippedLegs         []string `json:"skipped_legs,omitempty"`
 	ElapsedMs           int64    `json:"elapsed_ms"`
 }
 
@@ -194,10 +255,12 @@ func (e *Evaluator) Run(ctx context.Context, dataset Dataset, opts ...goreason.Q
 	// Retrieval metric accumulators
 	retPrecisionSums := make(map[int]float64)
 	retRecallSums := make(map[int]float64)
+	spanPrecisionSums := make(map[int]float64)
+	spanRecallSums := make(map[int]float64)
 	retMetricsCount := 0
 
 	for i, test := range dataset.Tests {
-		result := e.runTest(ctx, test, opts...)
+		result := e.runTest(ctx, dataset, test, opts...)
 		report.Results = append(report.Results, result)
 
 		status := "PASS"
@@ -250,6 +313,10 @@ func (e *Evaluator) Run(ctx context.Context, dataset Dataset, opts ...goreason.Q
 		report.Metrics.AvgConfidence += result.Confidence
 		report.Metrics.AvgClaimGrounding += result.ClaimGrounding
 		report.Metrics.AvgHallucinationScore += result.HallucinationScore
+		if result.CitationsChecked > 0 {
+			report.Metrics.AvgCitationAccuracy += result.CitationAccuracy
+			report.Metrics.CitationTests++
+		}
 
 		// Accumulate retrieval metrics
 		if result.RetrievalPrecision != nil {
@@ -257,6 +324,8 @@ func (e *Evaluator) Run(ctx context.Context, dataset Dataset, opts ...goreason.Q
 			for _, k := range RetrievalKValues {
 				retPrecisionSums[k] += result.RetrievalPrecision[k]
 				retRecallSums[k] += result.RetrievalRecall[k]
+				spanPrecisionSums[k] += result.SpanPrecision[k]
+				spanRecallSums[k] += result.SpanRecall[k]
 			}
 		}
 
@@ -273,6 +342,10 @@ func (e *Evaluator) Run(ctx context.Context, dataset Dataset, opts ...goreason.Q
 			sum.AvgConfidence += result.Confidence
 			sum.AvgClaimGrounding += result.ClaimGrounding
 			sum.AvgHallucinationScore += result.HallucinationScore
+			if result.CitationsChecked > 0 {
+				sum.AvgCitationAccuracy += result.CitationAccuracy
+				sum.CitationTests++
+			}
 			catSums[test.Category] = sum
 		}
 	}
@@ -289,15 +362,22 @@ func (e *Evaluator) Run(ctx context.Context, dataset Dataset, opts ...goreason.Q
 		report.Metrics.AvgClaimGrounding /= n
 		report.Metrics.AvgHallucinationScore /= n
 	}
+	if report.Metrics.CitationTests > 0 {
+		report.Metrics.AvgCitationAccuracy /= float64(report.Metrics.CitationTests)
+	}
 
 	// Compute retrieval metric averages
 	if retMetricsCount > 0 {
 		rn := float64(retMetricsCount)
 		report.Metrics.AvgRetrievalPrecision = make(map[int]float64)
 		report.Metrics.AvgRetrievalRecall = make(map[int]float64)
+		report.Metrics.AvgSpanPrecision = make(map[int]float64)
+		report.Metrics.AvgSpanRecall = make(map[int]float64)
 		for _, k := range RetrievalKValues {
 			report.Metrics.AvgRetrievalPrecision[k] = retPrecisionSums[k] / rn
 			report.Metrics.AvgRetrievalRecall[k] = retRecallSums[k] / rn
+			report.Metrics.AvgSpanPrecision[k] = spanPrecisionSums[k] / rn
+			report.Metrics.AvgSpanRecall[k] = spanRecallSums[k] / rn
 		}
 	}
 
@@ -305,6 +385,9 @@ func (e *Evaluator) Run(ctx context.Context, dataset Dataset, opts ...goreason.Q
 	for cat, count := range catCounts {
 		cn := float64(count)
 		sum := catSums[cat]
+		if sum.CitationTests > 0 {
+			sum.AvgCitationAccuracy /= float64(sum.CitationTests)
+		}
 		report.CategoryMetrics[cat] = AggregateMetrics{
 			AvgFaithfulness:       sum.AvgFaithfulness / cn,
 			AvgRelevance:          sum.AvgRelevance / cn,
@@ -315,14 +398,17 @@ func (e *Evaluator) Run(ctx context.Context, dataset Dataset, opts ...goreason.Q
 			AvgConfidence:         sum.AvgConfidence / cn,
 			AvgClaimGrounding:     sum.AvgClaimGrounding / cn,
 			AvgHallucinationScore: sum.AvgHallucinationScore / cn,
+			AvgCitationAccuracy:   sum.AvgCitationAccuracy,
+			CitationTests:         sum.CitationTests,
 		}
 	}
 
+	summarizeCostLatency(report)
 	report.RunTime = time.Since(start)
 	return report, nil
 }
 
-func (e *Evaluator) runTest(ctx context.Context, test TestCase, opts ...goreason.QueryOption) TestResult {
+func (e *Evaluator) runTest(ctx context.Context, dataset Dataset, test TestCase, opts ...goreason.QueryOption) TestResult {
 	testStart := time.Now()
 	result := TestResult{
 		Question:      test.Question,
@@ -331,10 +417,20 @@ func (e *Evaluator) runTest(ctx context.Context, test TestCase, opts ...goreason
 		Explanation:   test.Explanation,
 	}
 
-	answer, err := e.engine.Query(ctx, test.Question, opts...)
-	if err != nil {
-		result.Error = err.Error()
-		result.ElapsedMs = time.Since(testStart).Milliseconds()
+	var stored StoredAnswer
+	if e.replay != nil {
+		stored = e.replay[answerKey(dataset.Name, test.Question)]
+		if stored.Error == "" && stored.Answer == nil {
+			stored.Error = "no stored answer"
+		}
+	} else {
+		stored = e.queryTest(ctx, dataset, test, opts...)
+	}
+
+	answer, queryMs := stored.Answer, stored.QueryMs
+	if stored.Error != "" {
+		result.Error = stored.Error
+		result.ElapsedMs = queryMs
 		return result
 	}
 
@@ -343,6 +439,25 @@ func (e *Evaluator) runTest(ctx context.Context, test TestCase, opts ...goreason
 	result.PromptTokens = answer.PromptTokens
 	result.CompletionTokens = answer.CompletionTokens
 	result.TotalTokens = answer.TotalTokens
+	result.Model = answer.ModelUsed
+
+	// Split tokens and latency by phase. Reasoning latency is whatever part
+	// of the query was not spent in retrieval.
+	result.PhaseTokens.Reasoning = TokenUsage{
+		PromptTokens:     answer.PromptTokens,
+		CompletionTokens: answer.CompletionTokens,
+		TotalTokens:      answer.TotalTokens,
+		CachedTokens:     answer.CachedTokens,
+	}
+	if st := answer.RetrievalTrace; st != nil {
+		result.PhaseTokens.Retrieval = TokenUsage{
+			PromptTokens:     st.TranslationPromptTokens,
+			CompletionTokens: st.TranslationCompletionTokens,
+			TotalTokens:      st.TranslationPromptTokens + st.TranslationCompletionTokens,
+		}
+		result.RetrievalMs = st.ElapsedMs
+	}
+	result.ReasoningMs = max(queryMs-result.RetrievalMs, 0)
 
 	// Compute metrics
 	result.Faithfulness = computeFaithfulness(answer)
@@ -355,13 +470,31 @@ func (e *Evaluator) runTest(ctx context.Context, test TestCase, opts ...goreason
 
 	// If judge is configured, use LLM-based accuracy instead
 	if e.judgeLLM != nil {
-		llmAcc, err := computeAccuracyLLM(ctx, e.judgeLLM, e.judgeModel, answer, test.ExpectedFacts)
+		judgeStart := time.Now()
+		verdict, judgeUsage, err := computeAccuracyLLM(ctx, e.judgeLLM, e.judgeModel, answer, test.ExpectedFacts, e.answerLang)
+		result.JudgeMs = time.Since(judgeStart).Milliseconds()
+		result.PhaseTokens.Judge = judgeUsage
 		if err != nil {
 			slog.Warn("judge LLM failed, falling back to strict accuracy",
 				"error", err,
 				"question", truncate(test.Question, 60))
 		} else {
-			result.Accuracy = llmAcc
+			result.Accuracy = verdict.accuracy
+			result.FactsCovered = verdict.covered
+			result.JudgeRationale = verdict.rationale
+		}
+
+		// Citation rubric: do the cited articles and pages back the claims?
+		judgeStart = time.Now()
+		citeAcc, checked, citeUsage, err := computeCitationAccuracyLLM(ctx, e.judgeLLM, e.judgeModel, answer, e.answerLang)
+		result.JudgeMs += time.Since(judgeStart).Milliseconds()
+		result.PhaseTokens.Judge.add(citeUsage)
+		if err != nil {
+			slog.Warn("citation judge failed, citation accuracy not scored",
+				"error", err,
+				"question", truncate(test.Question, 60))
+		} else {
+			result.CitationAccuracy, result.CitationsChecked = citeAcc, checked
 		}
 	}
 
@@ -387,24 +520,63 @@ func (e *Evaluator) runTest(ctx context.Context, test TestCase, opts ...goreason
 	// Build reasoning steps from answer
 	result.ReasoningSteps = buildReasoningSteps(answer)
 
-	// Run ground truth diagnosis
-	result.GroundTruth = e.runGroundTruthCheck(ctx, test, answer)
+	result.GroundTruth = stored.GroundTruth
 
 	// Compute retrieval P@k/R@k if ground-truth spans are available
 	if spans, ok := e.groundTruth[test.Question]; ok && len(spans) > 0 {
 		result.RetrievalPrecision = make(map[int]float64)
 		result.RetrievalRecall = make(map[int]float64)
+		result.SpanPrecision = make(map[int]float64)
+		result.SpanRecall = make(map[int]float64)
 		for _, k := range RetrievalKValues {
 			result.RetrievalPrecision[k] = computeRetrievalPrecisionAtK(answer, spans, k)
 			result.RetrievalRecall[k] = computeRetrievalRecallAtK(answer, spans, k)
+			result.SpanPrecision[k], result.SpanRecall[k] = computeSpanMetricsAtK(answer, spans, k)
 		}
 	}
 
 	result.ElapsedMs = time.Since(testStart).Milliseconds()
+	if e.replay != nil {
+		result.ElapsedMs += queryMs
+	}
+	priceResult(&result, e.pricing, result.Model, e.judgeModel)
 
 	return result
 }
 
+// queryTest asks the engine one test question and runs the ground truth
+// diagnosis on its answer, recording the outcome when RecordAnswers is set.
+func (e *Evaluator) queryTest(ctx context.Context, dataset Dataset, test TestCase, opts ...goreason.QueryOption) StoredAnswer {
+	stored := StoredAnswer{
+		Dataset:    dataset.Name,
+		Difficulty: dataset.Difficulty,
+		Test:       test,
+		Spans:      e.groundTruth[test.Question],
+	}
+
+	if e.answerLang != "" {
+		opts = append(opts[:len(opts):len(opts)], goreason.WithAnswerLanguage(e.answerLang))
+	}
+
+	queryStart := time.Now()
+	answer, err := e.engine.Query(ctx, test.Question, opts...)
+	stored.QueryMs = time.Since(queryStart).Milliseconds()
+	if err != nil {
+		stored.Error = err.Error()
+	} else {
+		stored.Answer = answer
+		// Run ground truth diagnosis
+		stored.GroundTruth = e.runGroundTruthCheck(ctx, test, answer)
+	}
+
+	if e.answerLog != nil {
+		if err := e.answerLog.Encode(stored); err != nil {
+			slog.Warn("eval: recording answer failed (non-fatal)", "error", err, "question", truncate(test.Question, 60))
+		}
+	}
+	return stored
+}
+
 func buildSourceTraces(answer *goreason.Answer) []SourceTrace {
 	if answer == nil {
 		return nil
@@ -417,6 +589,7 @@ func buildSourceTraces(answer *goreason.Answer) []SourceTrace {
 			Content:    src.Content,
 			PageNumber: src.PageNumber,
 			Score:      src.Score,
+			Span:       src.Span,
 		}
 		// Attach per-result method info from the retrieval trace
 		if answer.RetrievalTrace != nil && answer.RetrievalTrace.PerResult != nil {
@@ -444,6 +617,7 @@ func buildRetrievalTrace(st *retrieval.SearchTrace) *RetrievalTrace {
 		IdentifiersDetected: st.IdentifiersDetected,
 		FTSQuery:            st.FTSQuery,
 		GraphEntities:       st.GraphEntities,
+		SkippedLegs:         st.SkippedLegs,
 		ElapsedMs:           st.ElapsedMs,
 	}
 }
@@ -634,6 +808,9 @@ func FormatReport(r *Report) string {
 	}
 	fmt.Fprintf(&b, "  Context Recall:       %.2f\n", r.Metrics.AvgContextRecall)
 	fmt.Fprintf(&b, "  Citation Quality:     %.2f\n", r.Metrics.AvgCitationQuality)
+	if r.Metrics.CitationTests > 0 {
+		fmt.Fprintf(&b, "  Citation Accuracy:    %.2f (%d tests with references)\n", r.Metrics.AvgCitationAccuracy, r.Metrics.CitationTests)
+	}
 	fmt.Fprintf(&b, "  Claim Grounding:      %.2f\n", r.Metrics.AvgClaimGrounding)
 	fmt.Fprintf(&b, "  Hallucination Score:  %.2f\n", r.Metrics.AvgHallucinationScore)
 	fmt.Fprintf(&b, "  Confidence:           %.2f\n\n", r.Metrics.AvgConfidence)
@@ -651,13 +828,45 @@ func FormatReport(r *Report) string {
 				fmt.Fprintf(&b, "  R@%-3d  %.1f%%\n", k, recall*100)
 			}
 		}
+		for _, k := range RetrievalKValues {
+			if p, ok := r.Metrics.AvgSpanPrecision[k]; ok {
+				fmt.Fprintf(&b, "  Span P@%-3d  %.1f%%   Span R@%-3d  %.1f%%\n", k, p*100, k, r.Metrics.AvgSpanRecall[k]*100)
+			}
+		}
 		fmt.Fprintln(&b)
 	}
 
 	fmt.Fprintf(&b, "Token Usage:\n")
 	fmt.Fprintf(&b, "  Prompt:     %d\n", r.TokenUsage.PromptTokens)
 	fmt.Fprintf(&b, "  Completion: %d\n", r.TokenUsage.CompletionTokens)
-	fmt.Fprintf(&b, "  Total:      %d\n\n", r.TokenUsage.TotalTokens)
+	fmt.Fprintf(&b, "  Total:      %d\n", r.TokenUsage.TotalTokens)
+	if r.TokenUsage.CachedTokens > 0 {
+		fmt.Fprintf(&b, "  Cached:     %d (%.1f%% of prompt tokens)\n", r.TokenUsage.CachedTokens, r.CacheHitRate*100)
+	}
+	fmt.Fprintf(&b, "  By phase:   retrieval=%d reasoning=%d judge=%d\n\n",
+		r.PhaseTokens.Retrieval.TotalTokens, r.PhaseTokens.Reasoning.TotalTokens, r.PhaseTokens.Judge.TotalTokens)
+
+	fmt.Fprintf(&b, "Estimated Cost:\n")
+	fmt.Fprintf(&b, "  Total:      $%.4f\n", r.CostUSD)
+	fmt.Fprintf(&b, "  Per test:   $%.4f\n", r.AvgCostUSD)
+	if len(r.UnpricedModels) > 0 {
+		fmt.Fprintf(&b, "  Unpriced:   %s\n", strings.Join(r.UnpricedModels, ", "))
+	}
+	fmt.Fprintln(&b)
+
+	fmt.Fprintf(&b, "Latency (ms):    p50     p95    mean     max\n")
+	for _, row := range []struct {
+		name string
+		s    LatencyStats
+	}{
+		{"Total", r.Latency.Total},
+		{"Retrieval", r.Latency.Retrieval},
+		{"Reasoning", r.Latency.Reasoning},
+		{"Judge", r.Latency.Judge},
+	} {
+		fmt.Fprintf(&b, "  %-11s %7d %7d %7d %7d\n", row.name, row.s.P50Ms, row.s.P95Ms, row.s.MeanMs, row.s.MaxMs)
+	}
+	fmt.Fprintln(&b)
 
 	// Per-category breakdown (sorted for deterministic output)
 	if len(r.CategoryMetrics) > 0 {
@@ -691,12 +900,16 @@ func FormatReport(r *Report) string {
 		if res.Error != "" {
 			fmt.Fprintf(&b, "  Error: %s\n", res.Error)
 		} else {
-			fmt.Fprintf(&b, "  Faith=%.2f Rel=%.2f Acc=%.2f CtxR=%.2f Cite=%.2f Grnd=%.2f Hall=%.2f Conf=%.2f  (%dms)\n",
+			fmt.Fprintf(&b, "  Faith=%.2f Rel=%.2f Acc=%.2f CtxR=%.2f Cite=%.2f Grnd=%.2f Hall=%.2f Conf=%.2f  (%dms, ret=%dms rsn=%dms, $%.4f)\n",
 				res.Faithfulness, res.Relevance, res.Accuracy, res.ContextRecall, res.CitationQuality,
-				res.ClaimGrounding, res.HallucinationScore, res.Confidence, res.ElapsedMs)
+				res.ClaimGrounding, res.HallucinationScore, res.Confidence, res.ElapsedMs,
+				res.RetrievalMs, res.ReasoningMs, res.CostUSD)
 			if res.StrictAccuracy != res.Accuracy {
 				fmt.Fprintf(&b, "  StrictAcc=%.2f\n", res.StrictAccuracy)
 			}
+			if res.CitationsChecked > 0 {
+				fmt.Fprintf(&b, "  CiteAcc=%.2f (%d cited claims)\n", res.CitationAccuracy, res.CitationsChecked)
+			}
 		}
 	}
 
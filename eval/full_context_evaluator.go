@@ -129,6 +129,7 @@ func (e *FullContextEvaluator) Run(ctx context.Context, dataset Dataset) (*Repor
 		}
 	}
 
+	summarizeCostLatency(report)
 	report.RunTime = time.Since(start)
 	return report, nil
 }
@@ -142,16 +143,17 @@ func (e *FullContextEvaluator) runTest(ctx context.Context, test TestCase) TestR
 		Explanation:   test.Explanation,
 	}
 
-	prompt := fmt.Sprintf(
-		"Based on the following document, answer this question thoroughly and accurately. "+
-			"Include specific article numbers and relevant details from the document.\n\n"+
-			"Question: %s\n\nDocument:\n%s",
-		test.Question, e.docText,
-	)
+	// The document comes first so every test repeats the same prefix,
+	// which providers with prompt caching serve from cache after the
+	// first call.
+	docPrefix := "Based on the following document, answer the question after it thoroughly and accurately. " +
+		"Include specific article numbers and relevant details from the document.\n\n" +
+		"Document:\n" + e.docText + "\n\n"
+	prompt := docPrefix + fmt.Sprintf("Question: %s", test.Question)
 
 	resp, err := e.provider.Chat(ctx, llm.ChatRequest{
 		Messages: []llm.Message{
-			{Role: "user", Content: prompt},
+			{Role: "user", Content: prompt, CachePrefix: len(docPrefix)},
 		},
 		Temperature: 0.1,
 	})
@@ -179,6 +181,15 @@ func (e *FullContextEvaluator) runTest(ctx context.Context, test TestCase) TestR
 	result.PromptTokens = answer.PromptTokens
 	result.CompletionTokens = answer.CompletionTokens
 	result.TotalTokens = answer.TotalTokens
+	result.Model = resp.Model
+	result.PhaseTokens.Reasoning = TokenUsage{
+		PromptTokens:     resp.PromptTokens,
+		CompletionTokens: resp.CompletionTokens,
+		TotalTokens:      resp.TotalTokens,
+		CachedTokens:     resp.CachedTokens,
+	}
+	result.ReasoningMs = time.Since(testStart).Milliseconds()
+	priceResult(&result, DefaultPricing, result.Model, "")
 
 	// Compute metrics using existing functions.
 	// With no Sources (full-context bypasses RAG):
@@ -0,0 +1,153 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/bbiangul/go-reason/store"
+)
+
+// HardNegative is a retrieval failure mined from an eval run: a question,
+// the chunk holding one of its expected facts (the positive), and the
+// chunks retrieval ranked above it that do not hold the fact. Exported as
+// JSON lines, they are training triples for fine-tuning an embedding model
+// and test cases for tuning the fusion weights.
+type HardNegative struct {
+	Dataset   string       `json:"dataset"`
+	Question  string       `json:"question"`
+	Fact      string       `json:"fact"`
+	Diagnosis string       `json:"diagnosis"` // the test's ground-truth diagnosis, e.g. RETRIEVAL_MISS
+	Positive  MinedChunk   `json:"positive"`
+	Negatives []MinedChunk `json:"negatives"`
+}
+
+// MinedChunk is a chunk of a HardNegative. Rank is its 1-based position in
+// the answer's sources, 0 for a positive that was not retrieved; the leg
+// ranks show which retrieval legs promoted it.
+type MinedChunk struct {
+	ChunkID    int64    `json:"chunk_id"`
+	Heading    string   `json:"heading,omitempty"`
+	Content    string   `json:"content"`
+	PageNumber int      `json:"page_number,omitempty"`
+	Rank       int      `json:"rank"`
+	Methods    []string `json:"methods,omitempty"`
+	VecRank    int      `json:"vec_rank,omitempty"`
+	FTSRank    int      `json:"fts_rank,omitempty"`
+	GraphRank  int      `json:"graph_rank,omitempty"`
+}
+
+// MineHardNegatives collects hard negatives from the failed tests of a
+// report, keeping at most maxNegatives per fact. A fact yields one when
+// the ground-truth check found the chunk holding it and retrieval either
+// missed that chunk or ranked other chunks above it. The positive's
+// content is read from the engine's store, so the report must come from
+// this evaluator's engine.
+func (e *Evaluator) MineHardNegatives(ctx context.Context, report *Report, maxNegatives int) ([]HardNegative, error) {
+	if e.engine == nil || e.engine.Store() == nil {
+		return nil, errors.New("mining hard negatives needs the engine's store")
+	}
+	s := e.engine.Store()
+	return mineHardNegatives(report, maxNegatives, func(id int64) (*store.Chunk, error) {
+		return s.GetChunk(ctx, id)
+	})
+}
+
+// mineHardNegatives implements MineHardNegatives, loading positives with
+// getChunk.
+func mineHardNegatives(report *Report, maxNegatives int, getChunk func(int64) (*store.Chunk, error)) ([]HardNegative, error) {
+	if maxNegatives <= 0 {
+		return nil, nil
+	}
+	var out []HardNegative
+	for _, r := range report.Results {
+		gt := r.GroundTruth
+		if r.Passed || r.Error != "" || gt == nil {
+			continue
+		}
+		for i, db := range gt.FactsInDB {
+			if !db.Found || i >= len(gt.FactsRetrieved) {
+				continue
+			}
+			goldRank := gt.FactsRetrieved[i].ChunkRank // 0: not retrieved
+			if goldRank == 1 {
+				continue
+			}
+			var negatives []MinedChunk
+			for j, src := range r.Sources {
+				if goldRank > 0 && j+1 >= goldRank || len(negatives) == maxNegatives {
+					break
+				}
+				if src.ChunkID == db.ChunkID || containsFact(src.Content, db.Fact) {
+					continue
+				}
+				negatives = append(negatives, minedSource(src, j+1))
+			}
+			if len(negatives) == 0 {
+				continue
+			}
+
+			var positive MinedChunk
+			if goldRank > 0 {
+				positive = minedSource(r.Sources[goldRank-1], goldRank)
+			} else {
+				gold, err := getChunk(db.ChunkID)
+				if err != nil {
+					return nil, fmt.Errorf("loading chunk %d for %q: %w", db.ChunkID, truncate(r.Question, 60), err)
+				}
+				positive = MinedChunk{ChunkID: gold.ID, Heading: gold.Heading, Content: gold.Content, PageNumber: gold.PageNumber}
+			}
+			out = append(out, HardNegative{
+				Dataset:   report.Dataset,
+				Question:  r.Question,
+				Fact:      db.Fact,
+				Diagnosis: gt.Diagnosis,
+				Positive:  positive,
+				Negatives: negatives,
+			})
+		}
+	}
+	return out, nil
+}
+
+func minedSource(src SourceTrace, rank int) MinedChunk {
+	return MinedChunk{
+		ChunkID:    src.ChunkID,
+		Heading:    src.Heading,
+		Content:    src.Content,
+		PageNumber: src.PageNumber,
+		Rank:       rank,
+		Methods:    src.Methods,
+		VecRank:    src.VecRank,
+		FTSRank:    src.FTSRank,
+		GraphRank:  src.GraphRank,
+	}
+}
+
+// containsFact reports whether text holds any of the fact's pipe-separated
+// alternatives, so chunks that also answer the question are not mined as
+// negatives.
+func containsFact(text, fact string) bool {
+	text = normalizeLLMText(strings.ToLower(text))
+	for _, alt := range strings.Split(fact, "|") {
+		alt = normalizeLLMText(strings.ToLower(strings.TrimSpace(alt)))
+		if alt != "" && strings.Contains(text, alt) {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteHardNegatives writes hard negatives to w, one JSON object per line.
+func WriteHardNegatives(w io.Writer, negatives []HardNegative) error {
+	enc := json.NewEncoder(w)
+	for _, n := range negatives {
+		if err := enc.Encode(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package eval
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"time"
+)
+
+// maxHTMLChunkChars caps each retrieved chunk shown in the HTML report.
+const maxHTMLChunkChars = 1500
+
+// htmlReport is the view of a run that htmlReportTemplate renders.
+type htmlReport struct {
+	Generated string
+	Meta      []htmlMeta
+	Datasets  []htmlDataset
+}
+
+type htmlMeta struct {
+	Key, Value string
+}
+
+type htmlDataset struct {
+	*Report
+	PassRate   float64
+	Categories []htmlCategory
+	Tests      []htmlTest
+}
+
+// htmlCategory is a category's row and bar in the per-category chart.
+type htmlCategory struct {
+	Name     string
+	Tests    int
+	Passed   int
+	PassRate float64
+	Metrics  AggregateMetrics
+}
+
+type htmlTest struct {
+	TestResult
+	Number int
+	Facts  []htmlFact
+}
+
+// htmlFact is an expected fact with the judge's verdict, when there was one.
+type htmlFact struct {
+	Fact    string
+	Judged  bool
+	Covered bool
+}
+
+// WriteHTMLReport writes a run's reports as one self-contained HTML page:
+// a summary table per dataset, per-category pass rate and accuracy charts,
+// and an expandable view of each test with its question, answer, expected
+// facts, retrieved chunks, and the judge's rationale. meta (the run's
+// metadata.json) is listed at the top; it may be nil.
+func WriteHTMLReport(w io.Writer, reports []*Report, meta map[string]interface{}) error {
+	view := htmlReport{Generated: time.Now().UTC().Format(time.RFC3339)}
+	keys := make([]string, 0, len(meta))
+	for k := range meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		view.Meta = append(view.Meta, htmlMeta{Key: k, Value: fmt.Sprint(meta[k])})
+	}
+	for _, r := range reports {
+		view.Datasets = append(view.Datasets, htmlDatasetView(r))
+	}
+	return htmlReportTemplate.Execute(w, view)
+}
+
+func htmlDatasetView(r *Report) htmlDataset {
+	d := htmlDataset{Report: r, PassRate: passRate(r.Passed, r.TotalTests)}
+	cats := make(map[string]*htmlCategory)
+	for i, res := range r.Results {
+		name := res.Category
+		if name == "" {
+			name = "uncategorized"
+		}
+		c, ok := cats[name]
+		if !ok {
+			c = &htmlCategory{Name: name, Metrics: r.CategoryMetrics[res.Category]}
+			cats[name] = c
+		}
+		c.Tests++
+		if res.Passed {
+			c.Passed++
+		}
+
+		t := htmlTest{TestResult: res, Number: i + 1}
+		for j, fact := range res.ExpectedFacts {
+			f := htmlFact{Fact: fact}
+			if j < len(res.FactsCovered) {
+				f.Judged, f.Covered = true, res.FactsCovered[j]
+			}
+			t.Facts = append(t.Facts, f)
+		}
+		for j := range t.Sources {
+			t.Sources[j].Content = truncate(t.Sources[j].Content, maxHTMLChunkChars)
+		}
+		d.Tests = append(d.Tests, t)
+	}
+	for _, c := range cats {
+		c.PassRate = passRate(c.Passed, c.Tests)
+		d.Categories = append(d.Categories, *c)
+	}
+	sort.Slice(d.Categories, func(i, j int) bool { return d.Categories[i].Name < d.Categories[j].Name })
+	return d
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"f2":  func(v float64) string { return fmt.Sprintf("%.2f", v) },
+	"pct": func(v float64) string { return fmt.Sprintf("%.1f", v) },
+	"bar": func(v float64) string { return fmt.Sprintf("%.1f", v*100) },
+	"usd": func(v float64) string { return fmt.Sprintf("$%.4f", v) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>GoReason evaluation report</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em auto; max-width: 1100px; color: #222; padding: 0 1em; }
+h1, h2, h3 { font-weight: 600; }
+table { border-collapse: collapse; margin: 0.5em 0 1.5em; }
+th, td { border-bottom: 1px solid #ddd; padding: 0.3em 0.8em; text-align: left; vertical-align: top; }
+th { background: #f5f5f5; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+.meta td { font-size: 0.9em; }
+.chart { margin: 0.5em 0 1.5em; }
+.chart .row { display: flex; align-items: center; margin: 0.25em 0; }
+.chart .label { width: 14em; font-size: 0.9em; }
+.chart .track { flex: 1; background: #eee; height: 0.9em; margin: 0.1em 0; }
+.chart .fill { height: 100%; }
+.chart .pass, .legend .pass { background: #3a7d44; }
+.chart .acc, .legend .acc { background: #4a6fa5; }
+.chart .value { width: 5em; text-align: right; font-size: 0.85em; }
+.legend span { display: inline-block; width: 0.8em; height: 0.8em; margin: 0 0.3em 0 1em; }
+details { border: 1px solid #ddd; border-radius: 4px; margin: 0.4em 0; padding: 0.4em 0.8em; }
+details[open] { background: #fcfcfc; }
+summary { cursor: pointer; }
+.status { font-weight: 600; display: inline-block; width: 3.5em; }
+.PASS { color: #3a7d44; }
+.FAIL { color: #b33; }
+.text { white-space: pre-wrap; background: #f7f7f7; padding: 0.6em; border-radius: 4px; }
+.scores { font-size: 0.85em; color: #555; }
+.chunk { border-left: 3px solid #ccc; margin: 0.5em 0; padding-left: 0.8em; }
+.chunk .head { font-size: 0.85em; color: #555; }
+.covered { color: #3a7d44; }
+.missed { color: #b33; }
+</style>
+</head>
+<body>
+<h1>GoReason evaluation report</h1>
+<p>Generated {{.Generated}}</p>
+{{with .Meta}}<table class="meta">
+{{range .}}<tr><th>{{.Key}}</th><td>{{.Value}}</td></tr>
+{{end}}</table>{{end}}
+
+<h2>Summary</h2>
+<table>
+<tr><th>Dataset</th><th>Tests</th><th>Passed</th><th>Pass rate</th><th>Accuracy</th><th>Faithfulness</th><th>Context recall</th><th>Citation quality</th><th>Cost</th><th>p50 ms</th><th>p95 ms</th></tr>
+{{range .Datasets}}<tr><td>{{.Dataset}}</td><td class="num">{{.TotalTests}}</td><td class="num">{{.Passed}}</td><td class="num">{{pct .PassRate}}%</td><td class="num">{{f2 .Metrics.AvgAccuracy}}</td><td class="num">{{f2 .Metrics.AvgFaithfulness}}</td><td class="num">{{f2 .Metrics.AvgContextRecall}}</td><td class="num">{{f2 .Metrics.AvgCitationQuality}}</td><td class="num">{{usd .CostUSD}}</td><td class="num">{{.Latency.Total.P50Ms}}</td><td class="num">{{.Latency.Total.P95Ms}}</td></tr>
+{{end}}</table>
+
+{{range .Datasets}}
+<h2>{{.Dataset}}{{with .Difficulty}} ({{.}}){{end}}</h2>
+<table>
+<tr><th>Metric</th><th>Average</th></tr>
+<tr><td>Accuracy</td><td class="num">{{f2 .Metrics.AvgAccuracy}}</td></tr>
+<tr><td>Strict accuracy</td><td class="num">{{f2 .Metrics.AvgStrictAccuracy}}</td></tr>
+<tr><td>Faithfulness</td><td class="num">{{f2 .Metrics.AvgFaithfulness}}</td></tr>
+<tr><td>Relevance</td><td class="num">{{f2 .Metrics.AvgRelevance}}</td></tr>
+<tr><td>Context recall</td><td class="num">{{f2 .Metrics.AvgContextRecall}}</td></tr>
+<tr><td>Citation quality</td><td class="num">{{f2 .Metrics.AvgCitationQuality}}</td></tr>
+{{if .Metrics.CitationTests}}<tr><td>Citation accuracy ({{.Metrics.CitationTests}} tests)</td><td class="num">{{f2 .Metrics.AvgCitationAccuracy}}</td></tr>
+{{end}}<tr><td>Claim grounding</td><td class="num">{{f2 .Metrics.AvgClaimGrounding}}</td></tr>
+<tr><td>Hallucination score</td><td class="num">{{f2 .Metrics.AvgHallucinationScore}}</td></tr>
+<tr><td>Confidence</td><td class="num">{{f2 .Metrics.AvgConfidence}}</td></tr>
+</table>
+
+{{with .Categories}}<h3>By category</h3>
+<div class="legend"><span class="pass"></span>pass rate<span class="acc"></span>accuracy</div>
+<div class="chart">
+{{range .}}<div class="row"><div class="label">{{.Name}} ({{.Passed}}/{{.Tests}})</div><div style="flex: 1">
+<div class="track"><div class="fill pass" style="width: {{pct .PassRate}}%"></div></div>
+<div class="track"><div class="fill acc" style="width: {{bar .Metrics.AvgAccuracy}}%"></div></div>
+</div><div class="value">{{pct .PassRate}}%<br>{{f2 .Metrics.AvgAccuracy}}</div></div>
+{{end}}</div>{{end}}
+
+<h3>Tests</h3>
+{{range .Tests}}<details>
+<summary>{{if .Passed}}<span class="status PASS">PASS</span>{{else}}<span class="status FAIL">FAIL</span>{{end}} {{.Number}}. {{.Question}}{{with .Category}} <small>[{{.}}]</small>{{end}}{{with .GroundTruth}}{{if ne .Diagnosis "PASS"}} <small>{{.Diagnosis}}</small>{{end}}{{end}}</summary>
+<p class="scores">Accuracy {{f2 .Accuracy}} · Faithfulness {{f2 .Faithfulness}} · Context recall {{f2 .ContextRecall}} · Citation quality {{f2 .CitationQuality}} · Confidence {{f2 .Confidence}} · {{.ElapsedMs}} ms · {{usd .CostUSD}}</p>
+{{with .Error}}<p class="FAIL">Error: {{.}}</p>{{end}}
+<h4>Answer</h4>
+<div class="text">{{.Answer}}</div>
+<h4>Expected facts</h4>
+<ul>{{range .Facts}}<li>{{if .Judged}}{{if .Covered}}<span class="covered">&#10003;</span>{{else}}<span class="missed">&#10007;</span>{{end}} {{end}}{{.Fact}}</li>{{end}}</ul>
+{{with .Explanation}}<p><strong>Reference:</strong> {{.}}</p>{{end}}
+{{with .JudgeRationale}}<h4>Judge rationale</h4>
+<p>{{.}}</p>{{end}}
+{{with .Sources}}<h4>Retrieved chunks</h4>
+{{range .}}<div class="chunk"><div class="head">#{{.ChunkID}}{{with .Heading}} · {{.}}{{end}}{{if .PageNumber}} · page {{.PageNumber}}{{end}} · score {{f2 .Score}}{{range .Methods}} · {{.}}{{end}}</div>
+<div class="text">{{.Content}}</div></div>
+{{end}}{{end}}
+</details>
+{{end}}
+{{end}}
+</body>
+</html>
+`))
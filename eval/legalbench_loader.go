@@ -277,9 +277,35 @@ func dedup(ss []string) []string {
 // GroundTruthSpan records a ground-truth snippet location for retrieval evaluation.
 type GroundTruthSpan struct {
 	FilePath string
-	Start    int
+	Start    int // character offsets, as in the benchmark file
 	End      int
 	Text     string
+
+	// ByteStart and ByteEnd are the span's byte offsets in the corpus file,
+	// comparable with goreason.Source.Span. They equal Start and End for
+	// ASCII files, or when the corpus file cannot be read.
+	ByteStart int
+	ByteEnd   int
+}
+
+// charSpanToBytes converts a character span of content to byte offsets.
+func charSpanToBytes(content string, start, end int) (int, int) {
+	byteStart, byteEnd := -1, len(content)
+	chars := 0
+	for i := range content {
+		if chars == start {
+			byteStart = i
+		}
+		if chars == end {
+			byteEnd = i
+			break
+		}
+		chars++
+	}
+	if byteStart < 0 {
+		byteStart = len(content)
+	}
+	return byteStart, byteEnd
 }
 
 // LoadLegalBenchGroundTruth loads the raw ground-truth spans from benchmark files.
@@ -287,6 +313,7 @@ type GroundTruthSpan struct {
 // against exact document spans).
 func LoadLegalBenchGroundTruth(cfg LegalBenchConfig) (map[string][]GroundTruthSpan, error) {
 	result := make(map[string][]GroundTruthSpan)
+	corpus := make(map[string]string) // file path -> content, "" if unreadable
 
 	for _, path := range cfg.BenchmarkFiles {
 		data, err := os.ReadFile(path)
@@ -325,11 +352,23 @@ func LoadLegalBenchGroundTruth(cfg LegalBenchConfig) (map[string][]GroundTruthSp
 						continue
 					}
 				}
+				content, ok := corpus[snippet.FilePath]
+				if !ok {
+					data, _ := os.ReadFile(filepath.Join(cfg.CorpusDir, snippet.FilePath))
+					content = string(data)
+					corpus[snippet.FilePath] = content
+				}
+				byteStart, byteEnd := snippet.Span[0], snippet.Span[1]
+				if content != "" {
+					byteStart, byteEnd = charSpanToBytes(content, byteStart, byteEnd)
+				}
 				spans = append(spans, GroundTruthSpan{
-					FilePath: snippet.FilePath,
-					Start:    snippet.Span[0],
-					End:      snippet.Span[1],
-					Text:     text,
+					FilePath:  snippet.FilePath,
+					Start:     snippet.Span[0],
+					End:       snippet.Span[1],
+					Text:      text,
+					ByteStart: byteStart,
+					ByteEnd:   byteEnd,
 				})
 			}
 			if len(spans) > 0 {
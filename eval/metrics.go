@@ -5,12 +5,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"path"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"unicode"
 
 	"github.com/bbiangul/go-reason"
 	"github.com/bbiangul/go-reason/llm"
+	"github.com/bbiangul/go-reason/reasoning"
 )
 
 // normalizeLLMText normalizes Unicode characters commonly inserted by LLMs
@@ -145,13 +149,29 @@ func computeAccuracy(answer *goreason.Answer, expectedFacts []string) float64 {
 	return float64(found) / float64(len(expectedFacts))
 }
 
+// judgeLanguageRule tells the judge that facts and answer may be in
+// different languages. Expected facts are usually written in the corpus
+// language, while the answer follows the question or the requested language.
+func judgeLanguageRule(answerLang string) string {
+	rule := "The answer and the expected facts may be in different languages (e.g. English and Spanish). A fact is covered if the answer conveys it in any language; judge meaning, not wording.\n"
+	switch answerLang {
+	case "":
+	case reasoning.AnswerLanguageAuto:
+		rule += "The answer was requested in the language of the question.\n"
+	default:
+		rule += fmt.Sprintf("The answer was requested in %s.\n", reasoning.LanguageName(answerLang))
+	}
+	return rule
+}
+
 // computeAccuracyLLM uses an LLM judge to semantically evaluate whether each
 // expected fact is covered by the answer. This handles paraphrasing that
 // verbatim substring matching misses. All facts are batched into a single
-// LLM call for efficiency.
-func computeAccuracyLLM(ctx context.Context, judge llm.Provider, model string, answer *goreason.Answer, expectedFacts []string) (float64, error) {
+// LLM call for efficiency. The judge call's token usage is returned so it can
+// be attributed to the judge phase.
+func computeAccuracyLLM(ctx context.Context, judge llm.Provider, model string, answer *goreason.Answer, expectedFacts []string, answerLang string) (accuracyVerdict, TokenUsage, error) {
 	if answer == nil || answer.Text == "" || len(expectedFacts) == 0 {
-		return 0, nil
+		return accuracyVerdict{}, TokenUsage{}, nil
 	}
 
 	// Build the numbered fact list for the prompt
@@ -179,15 +199,15 @@ func computeAccuracyLLM(ctx context.Context, judge llm.Provider, model string, a
 
 A fact is "covered" if the answer conveys the same core information, even if paraphrased, summarized, or worded differently.
 A fact is NOT covered if the answer contradicts it, omits it entirely, or gets key details (numbers, names, dates) wrong.
-
+%s
 Answer:
 %s
 
 Expected Facts:
 %s
-Respond with JSON: {"covered": [true, false, ...]} — one boolean per fact, in order.`, answer.Text, factsBuilder.String())
+Respond with JSON: {"covered": [true, false, ...], "rationale": "..."} — one boolean per fact, in order, and a one- or two-sentence rationale naming what the answer missed or got wrong (empty when every fact is covered).`, judgeLanguageRule(answerLang), answer.Text, factsBuilder.String())
 
-	resp, err := judge.Chat(ctx, llm.ChatRequest{
+	resp, err := judge.Chat(llm.WithOperation(ctx, llm.OperationJudge), llm.ChatRequest{
 		Model: model,
 		Messages: []llm.Message{
 			{Role: "user", Content: prompt},
@@ -196,15 +216,22 @@ Respond with JSON: {"covered": [true, false, ...]} — one boolean per fact, in
 		ResponseFormat: "json_object",
 	})
 	if err != nil {
-		return 0, fmt.Errorf("judge LLM call failed: %w", err)
+		return accuracyVerdict{}, TokenUsage{}, fmt.Errorf("judge LLM call failed: %w", err)
+	}
+	usage := TokenUsage{
+		PromptTokens:     resp.PromptTokens,
+		CompletionTokens: resp.CompletionTokens,
+		TotalTokens:      resp.TotalTokens,
+		CachedTokens:     resp.CachedTokens,
 	}
 
 	// Parse the JSON response
 	var result struct {
-		Covered []bool `json:"covered"`
+		Covered   []bool `json:"covered"`
+		Rationale string `json:"rationale"`
 	}
 	if err := json.Unmarshal([]byte(resp.Content), &result); err != nil {
-		return 0, fmt.Errorf("judge response parse error: %w (response: %s)", err, truncateStr(resp.Content, 200))
+		return accuracyVerdict{}, usage, fmt.Errorf("judge response parse error: %w (response: %s)", err, truncateStr(resp.Content, 200))
 	}
 
 	if len(result.Covered) != len(expectedFacts) {
@@ -223,7 +250,19 @@ Respond with JSON: {"covered": [true, false, ...]} — one boolean per fact, in
 			covered++
 		}
 	}
-	return float64(covered) / float64(len(expectedFacts)), nil
+	return accuracyVerdict{
+		accuracy:  float64(covered) / float64(len(expectedFacts)),
+		covered:   result.Covered,
+		rationale: strings.TrimSpace(result.Rationale),
+	}, usage, nil
+}
+
+// accuracyVerdict is the judge's reading of an answer: the covered share of
+// the expected facts, which were covered, and why any were not.
+type accuracyVerdict struct {
+	accuracy  float64
+	covered   []bool
+	rationale string
 }
 
 // truncateStr truncates a string to maxLen characters for logging.
@@ -558,6 +597,123 @@ func chunkMatchesGroundTruth(src goreason.Source, groundTruth []GroundTruthSpan)
 	return false
 }
 
+// computeSpanMetricsAtK scores the top-k sources against ground-truth spans
+// at character level, as LegalBench-RAG does: precision is the share of
+// retrieved text that lies inside a ground-truth span, and recall the share
+// of ground-truth text that was retrieved. Sources are matched to spans by
+// file and compared by their byte offsets (Source.Span); overlapping
+// sources are counted once. A source without offsets counts as retrieved
+// text outside every span.
+func computeSpanMetricsAtK(answer *goreason.Answer, groundTruth []GroundTruthSpan, k int) (precision, recall float64) {
+	if answer == nil || len(answer.Sources) == 0 || len(groundTruth) == 0 {
+		return 0, 0
+	}
+	topK := answer.Sources
+	if len(topK) > k {
+		topK = topK[:k]
+	}
+
+	gtByFile := make(map[string][]byteRange)
+	for _, gt := range groundTruth {
+		if gt.ByteEnd > gt.ByteStart {
+			gtByFile[gt.FilePath] = append(gtByFile[gt.FilePath], byteRange{gt.ByteStart, gt.ByteEnd})
+		}
+	}
+	retrieved := make(map[string][]byteRange)
+	unmatched := 0 // bytes of sources that are in no ground-truth file or lack offsets
+	for _, src := range topK {
+		file := ""
+		for f := range gtByFile {
+			if sourceInFile(src, f) {
+				file = f
+				break
+			}
+		}
+		if src.Span == nil || src.Span.EndOffset <= src.Span.StartOffset || file == "" {
+			unmatched += len(src.Content)
+			continue
+		}
+		retrieved[file] = append(retrieved[file], byteRange{src.Span.StartOffset, src.Span.EndOffset})
+	}
+
+	var retrievedLen, gtLen, overlap int
+	for file, ranges := range gtByFile {
+		gtRanges := mergeRanges(ranges)
+		gotRanges := mergeRanges(retrieved[file])
+		gtLen += rangesLen(gtRanges)
+		retrievedLen += rangesLen(gotRanges)
+		overlap += rangesOverlap(gtRanges, gotRanges)
+	}
+	retrievedLen += unmatched
+	if retrievedLen > 0 {
+		precision = float64(overlap) / float64(retrievedLen)
+	}
+	if gtLen > 0 {
+		recall = float64(overlap) / float64(gtLen)
+	}
+	return precision, recall
+}
+
+// sourceInFile reports whether src was ingested from the corpus file at
+// filePath (relative to the corpus directory).
+func sourceInFile(src goreason.Source, filePath string) bool {
+	p := filepath.ToSlash(src.Path)
+	filePath = filepath.ToSlash(filePath)
+	if p != "" {
+		return p == filePath || strings.HasSuffix(p, "/"+filePath)
+	}
+	return src.Filename == path.Base(filePath)
+}
+
+// byteRange is a half-open [start, end) byte range.
+type byteRange struct{ start, end int }
+
+// mergeRanges sorts ranges and merges overlapping ones.
+func mergeRanges(ranges []byteRange) []byteRange {
+	if len(ranges) == 0 {
+		return nil
+	}
+	sorted := append([]byteRange(nil), ranges...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].start < sorted[j].start })
+	merged := []byteRange{sorted[0]}
+	for _, r := range sorted[1:] {
+		last := &merged[len(merged)-1]
+		if r.start <= last.end {
+			if r.end > last.end {
+				last.end = r.end
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+func rangesLen(ranges []byteRange) int {
+	n := 0
+	for _, r := range ranges {
+		n += r.end - r.start
+	}
+	return n
+}
+
+// rangesOverlap returns the bytes shared by two merged range lists.
+func rangesOverlap(a, b []byteRange) int {
+	n := 0
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		lo, hi := max(a[i].start, b[j].start), min(a[i].end, b[j].end)
+		if hi > lo {
+			n += hi - lo
+		}
+		if a[i].end < b[j].end {
+			i++
+		} else {
+			j++
+		}
+	}
+	return n
+}
+
 // snippetOverlap computes the fraction of words in the ground-truth snippet
 // that appear in the chunk content.
 func snippetOverlap(chunkLower, snippetLower string) float64 {
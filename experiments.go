@@ -0,0 +1,220 @@
+package goreason
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/bbiangul/go-reason/store"
+)
+
+// Experiment assignment units.
+const (
+	// AssignByAPIKey buckets callers by the API key they authenticate with.
+	AssignByAPIKey = "api_key"
+	// AssignByHeader buckets callers by the value of a request header, such
+	// as a user or session ID.
+	AssignByHeader = "header"
+)
+
+// ExperimentConfig defines a retrieval A/B experiment: live traffic is
+// split across arms, each a retrieval configuration, and every answer is
+// logged with its arm so confidence and feedback can be compared per arm.
+type ExperimentConfig struct {
+	Name string `json:"name" yaml:"name"`
+
+	// AssignBy is the unit callers are bucketed by: AssignByAPIKey or
+	// AssignByHeader (the header named Header). A caller stays in the same
+	// arm for as long as the experiment's name and arms are unchanged.
+	// Requests without the unit are not enrolled.
+	AssignBy string `json:"assign_by" yaml:"assign_by"`
+	Header   string `json:"header,omitempty" yaml:"header,omitempty"`
+
+	Arms []ExperimentArm `json:"arms" yaml:"arms"`
+}
+
+// ExperimentArm is one retrieval configuration of an experiment. Zero
+// fields keep the engine (or request) setting, so a control arm can be
+// just a name and a weight.
+type ExperimentArm struct {
+	Name string `json:"name" yaml:"name"`
+	// Weight is the arm's share of traffic relative to the other arms
+	// (default 1).
+	Weight float64 `json:"weight,omitempty" yaml:"weight,omitempty"`
+
+	WeightVector   float64 `json:"weight_vector,omitempty" yaml:"weight_vector,omitempty"`
+	WeightFTS      float64 `json:"weight_fts,omitempty" yaml:"weight_fts,omitempty"`
+	WeightGraph    float64 `json:"weight_graph,omitempty" yaml:"weight_graph,omitempty"`
+	MaxResults     int     `json:"max_results,omitempty" yaml:"max_results,omitempty"`
+	SkipGraph      bool    `json:"skip_graph,omitempty" yaml:"skip_graph,omitempty"`
+	EmbeddingSpace string  `json:"embedding_space,omitempty" yaml:"embedding_space,omitempty"`
+}
+
+// ExperimentAssignment names the experiment arm that served a query.
+type ExperimentAssignment struct {
+	Experiment string `json:"experiment"`
+	Arm        string `json:"arm"`
+}
+
+// ExperimentResult reports an experiment's arms. Arms without traffic yet
+// are listed with zero counts.
+type ExperimentResult struct {
+	Name string           `json:"name"`
+	Arms []store.ArmStats `json:"arms"`
+}
+
+func (a ExperimentArm) weight() float64 {
+	if a.Weight == 0 {
+		return 1
+	}
+	return a.Weight
+}
+
+// validateExperiments checks experiment names, assignment units, and arms.
+func validateExperiments(exps []ExperimentConfig) error {
+	names := make(map[string]bool)
+	for _, x := range exps {
+		if x.Name == "" {
+			return fmt.Errorf("%w: experiment name is required", ErrInvalidConfig)
+		}
+		if names[x.Name] {
+			return fmt.Errorf("%w: duplicate experiment %q", ErrInvalidConfig, x.Name)
+		}
+		names[x.Name] = true
+		switch x.AssignBy {
+		case AssignByAPIKey:
+		case AssignByHeader:
+			if x.Header == "" {
+				return fmt.Errorf("%w: experiment %q assigns by header but names none", ErrInvalidConfig, x.Name)
+			}
+		default:
+			return fmt.Errorf("%w: experiment %q: unknown assign_by %q", ErrInvalidConfig, x.Name, x.AssignBy)
+		}
+		if len(x.Arms) < 2 {
+			return fmt.Errorf("%w: experiment %q needs at least two arms", ErrInvalidConfig, x.Name)
+		}
+		arms := make(map[string]bool)
+		for _, a := range x.Arms {
+			if a.Name == "" || arms[a.Name] {
+				return fmt.Errorf("%w: experiment %q: arm names must be unique and non-empty", ErrInvalidConfig, x.Name)
+			}
+			arms[a.Name] = true
+			if a.Weight < 0 || a.WeightVector < 0 || a.WeightFTS < 0 || a.WeightGraph < 0 || a.MaxResults < 0 {
+				return fmt.Errorf("%w: experiment %q arm %q: negative weight or limit", ErrInvalidConfig, x.Name, a.Name)
+			}
+			if !validEmbeddingSpace(a.EmbeddingSpace) {
+				return fmt.Errorf("%w: experiment %q arm %q: unknown embedding space %q", ErrInvalidConfig, x.Name, a.Name, a.EmbeddingSpace)
+			}
+		}
+	}
+	return nil
+}
+
+// Assign returns the arm for a caller identified by unit (an API key or
+// header value). The choice is a hash of the experiment name and unit, so
+// it is stable across requests and server restarts and needs no state.
+// It returns nil when unit is empty.
+func (x ExperimentConfig) Assign(unit string) *ExperimentArm {
+	if unit == "" || len(x.Arms) == 0 {
+		return nil
+	}
+	var total float64
+	for _, a := range x.Arms {
+		total += a.weight()
+	}
+	sum := sha256.Sum256([]byte(x.Name + "\x00" + unit))
+	point := float64(binary.BigEndian.Uint64(sum[:8])>>11) / (1 << 53) * total
+
+	for i := range x.Arms {
+		point -= x.Arms[i].weight()
+		if point < 0 {
+			return &x.Arms[i]
+		}
+	}
+	return &x.Arms[len(x.Arms)-1]
+}
+
+// Options converts the arm to query options, to be applied after the
+// request's own so the arm's settings win.
+func (a ExperimentArm) Options() []QueryOption {
+	var opts []QueryOption
+	if a.WeightVector > 0 || a.WeightFTS > 0 || a.WeightGraph > 0 {
+		// Legs the arm leaves unset keep their weight.
+		opts = append(opts, func(o *queryOptions) {
+			if a.WeightVector > 0 {
+				o.weightVec = a.WeightVector
+			}
+			if a.WeightFTS > 0 {
+				o.weightFTS = a.WeightFTS
+			}
+			if a.WeightGraph > 0 {
+				o.weightGraph = a.WeightGraph
+			}
+		})
+	}
+	if a.MaxResults > 0 {
+		opts = append(opts, WithMaxResults(a.MaxResults))
+	}
+	if a.SkipGraph {
+		opts = append(opts, WithoutGraph())
+	}
+	if a.EmbeddingSpace != "" {
+		opts = append(opts, WithEmbeddingSpace(a.EmbeddingSpace))
+	}
+	return opts
+}
+
+// WithExperiment records that the query is served by an experiment arm.
+// The arm is logged with the query and returned on Answer.Experiment; the
+// arm's retrieval settings are applied separately (ExperimentArm.Options).
+func WithExperiment(experiment, arm string) QueryOption {
+	return func(o *queryOptions) {
+		o.experiment = &ExperimentAssignment{Experiment: experiment, Arm: arm}
+	}
+}
+
+// RecordFeedback stores a caller's rating of an answer, identified by
+// Answer.QueryID: 1 helpful, -1 not helpful, 0 neutral. A later rating
+// replaces an earlier one.
+func (e *engine) RecordFeedback(ctx context.Context, queryID int64, rating int, comment string) error {
+	if err := e.writable(); err != nil {
+		return err
+	}
+	if rating < -1 || rating > 1 {
+		return fmt.Errorf("%w: rating must be -1, 0, or 1", ErrInvalidConfig)
+	}
+	err := e.queryLog.SetQueryFeedback(ctx, queryID, rating, comment)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %d", ErrQueryNotFound, queryID)
+	}
+	return err
+}
+
+// ExperimentResults reports per-arm query counts, confidence, latency, and
+// feedback for every configured experiment.
+func (e *engine) ExperimentResults(ctx context.Context) ([]ExperimentResult, error) {
+	out := make([]ExperimentResult, 0, len(e.cfg.Experiments))
+	for _, x := range e.cfg.Experiments {
+		stats, err := e.queryLog.ExperimentStats(ctx, x.Name)
+		if err != nil {
+			return nil, err
+		}
+		byArm := make(map[string]store.ArmStats, len(stats))
+		for _, s := range stats {
+			byArm[s.Arm] = s
+		}
+		res := ExperimentResult{Name: x.Name}
+		for _, a := range x.Arms {
+			s, ok := byArm[a.Name]
+			if !ok {
+				s = store.ArmStats{Arm: a.Name}
+			}
+			res.Arms = append(res.Arms, s)
+		}
+		out = append(out, res)
+	}
+	return out, nil
+}
@@ -0,0 +1,138 @@
+package goreason
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/bbiangul/go-reason/store"
+)
+
+func testExperiment() ExperimentConfig {
+	return ExperimentConfig{
+		Name:     "graph_weight",
+		AssignBy: AssignByHeader,
+		Header:   "X-User-ID",
+		Arms: []ExperimentArm{
+			{Name: "control"},
+			{Name: "more_graph", Weight: 3, WeightVector: 1, WeightFTS: 1, WeightGraph: 2},
+		},
+	}
+}
+
+func TestValidateExperiments(t *testing.T) {
+	if err := validateExperiments([]ExperimentConfig{testExperiment()}); err != nil {
+		t.Fatalf("valid experiment rejected: %v", err)
+	}
+	bad := []func(x *ExperimentConfig){
+		func(x *ExperimentConfig) { x.Name = "" },
+		func(x *ExperimentConfig) { x.AssignBy = "cookie" },
+		func(x *ExperimentConfig) { x.Header = "" },
+		func(x *ExperimentConfig) { x.Arms = x.Arms[:1] },
+		func(x *ExperimentConfig) { x.Arms[1].Name = "control" },
+		func(x *ExperimentConfig) { x.Arms[1].Weight = -1 },
+		func(x *ExperimentConfig) { x.Arms[1].EmbeddingSpace = "tertiary" },
+	}
+	for i, mutate := range bad {
+		x := testExperiment()
+		x.Arms = append([]ExperimentArm(nil), x.Arms...)
+		mutate(&x)
+		if err := validateExperiments([]ExperimentConfig{x}); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("case %d: error = %v, want ErrInvalidConfig", i, err)
+		}
+	}
+	x := testExperiment()
+	if err := validateExperiments([]ExperimentConfig{x, x}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("duplicate experiment accepted: %v", err)
+	}
+}
+
+func TestExperimentAssign(t *testing.T) {
+	x := testExperiment()
+	if x.Assign("") != nil {
+		t.Error("a request without a unit must not be enrolled")
+	}
+
+	counts := make(map[string]int)
+	for i := 0; i < 4000; i++ {
+		unit := fmt.Sprintf("user-%d", i)
+		arm := x.Assign(unit)
+		if again := x.Assign(unit); again.Name != arm.Name {
+			t.Fatalf("%s moved from %s to %s", unit, arm.Name, again.Name)
+		}
+		counts[arm.Name]++
+	}
+	// Weights 1:3 put about a quarter of the units in control.
+	if share := float64(counts["control"]) / 4000; share < 0.2 || share > 0.3 {
+		t.Errorf("control share = %.3f, want about 0.25 (%v)", share, counts)
+	}
+}
+
+func TestExperimentArmOptions(t *testing.T) {
+	o := &queryOptions{weightVec: 1, weightFTS: 1, weightGraph: 0.5, maxResults: 20}
+	arm := testExperiment().Arms[1]
+	arm.MaxResults = 5
+	arm.SkipGraph = true
+	for _, opt := range append(arm.Options(), WithExperiment("graph_weight", arm.Name)) {
+		opt(o)
+	}
+	if o.weightGraph != 2 || o.maxResults != 5 || !o.skipGraph {
+		t.Errorf("options = %+v", o)
+	}
+	if o.experiment == nil || o.experiment.Arm != "more_graph" {
+		t.Errorf("experiment = %+v", o.experiment)
+	}
+	if len(testExperiment().Arms[0].Options()) != 0 {
+		t.Error("an empty control arm should not change any setting")
+	}
+
+	// An arm setting one leg leaves the others at their defaults.
+	o = &queryOptions{weightVec: 1, weightFTS: 0.8, weightGraph: 0.5}
+	for _, opt := range (ExperimentArm{Name: "graph_only", WeightGraph: 2}).Options() {
+		opt(o)
+	}
+	if o.weightVec != 1 || o.weightFTS != 0.8 || o.weightGraph != 2 {
+		t.Errorf("weights = %v, %v, %v; want 1, 0.8, 2", o.weightVec, o.weightFTS, o.weightGraph)
+	}
+}
+
+func TestExperimentResultsAndFeedback(t *testing.T) {
+	s, err := store.New(filepath.Join(t.TempDir(), "exp.db"), 4)
+	if err != nil {
+		t.Fatalf("store.New: %v", err)
+	}
+	defer s.Close()
+	e := &engine{store: s, queryLog: s, cfg: Config{Experiments: []ExperimentConfig{testExperiment()}}}
+	ctx := context.Background()
+
+	id, err := s.InsertQueryLog(ctx, store.QueryLog{Query: "q", Confidence: 0.9, Experiment: "graph_weight", Arm: "more_graph"})
+	if err != nil {
+		t.Fatalf("InsertQueryLog: %v", err)
+	}
+	if err := e.RecordFeedback(ctx, id, 1, "spot on"); err != nil {
+		t.Fatalf("RecordFeedback: %v", err)
+	}
+	if err := e.RecordFeedback(ctx, id, 5, ""); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("out-of-range rating error = %v", err)
+	}
+	if err := e.RecordFeedback(ctx, id+1, 1, ""); !errors.Is(err, ErrQueryNotFound) {
+		t.Errorf("missing query error = %v, want ErrQueryNotFound", err)
+	}
+
+	results, err := e.ExperimentResults(ctx)
+	if err != nil {
+		t.Fatalf("ExperimentResults: %v", err)
+	}
+	if len(results) != 1 || len(results[0].Arms) != 2 {
+		t.Fatalf("results = %+v", results)
+	}
+	control, treated := results[0].Arms[0], results[0].Arms[1]
+	if control.Arm != "control" || control.Queries != 0 {
+		t.Errorf("control = %+v, want listed with no traffic", control)
+	}
+	if treated.Queries != 1 || treated.Feedback != 1 || treated.AvgRating != 1 {
+		t.Errorf("more_graph = %+v", treated)
+	}
+}
@@ -0,0 +1,362 @@
+package goreason
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bbiangul/go-reason/retrieval"
+)
+
+// queryTrace is what query_log.trace keeps of an answer for ExplainQuery.
+// Reasoning steps are stored without their prompts and responses.
+type queryTrace struct {
+	Strategy  string                 `json:"strategy,omitempty"`
+	Tier      string                 `json:"tier,omitempty"`
+	Threshold float64                `json:"confidence_threshold"`
+	Retrieval *retrieval.SearchTrace `json:"retrieval,omitempty"`
+	Reasoning []Step                 `json:"reasoning,omitempty"`
+}
+
+// newQueryTrace builds the persisted trace of answer.
+func (e *engine) newQueryTrace(answer *Answer) *queryTrace {
+	t := &queryTrace{
+		Strategy:  answer.Strategy,
+		Tier:      answer.Tier,
+		Threshold: e.cfg.ConfidenceThreshold,
+		Retrieval: answer.RetrievalTrace,
+	}
+	for _, s := range answer.Reasoning {
+		s.Prompt, s.Response = "", ""
+		t.Reasoning = append(t.Reasoning, s)
+	}
+	return t
+}
+
+// QueryExplanation describes, for the person who asked, how a logged
+// answer was produced. Text renders the same content as prose.
+type QueryExplanation struct {
+	QueryID    int64               `json:"query_id"`
+	Question   string              `json:"question"`
+	Answer     string              `json:"answer"`
+	AskedAt    string              `json:"asked_at"`
+	Refusal    string              `json:"refusal,omitempty"`
+	Documents  []ExplainedDocument `json:"documents"`
+	Rounds     int                 `json:"rounds"`
+	Steps      []string            `json:"steps,omitempty"`
+	Confidence float64             `json:"confidence"`
+	// ConfidenceRationale says why the confidence is what it is.
+	ConfidenceRationale string `json:"confidence_rationale"`
+	// Traced is false for queries logged before traces were recorded;
+	// their explanation lacks selection reasons and reasoning steps.
+	Traced bool   `json:"traced"`
+	Text   string `json:"text"`
+}
+
+// ExplainedDocument is a document consulted for an answer, with the
+// passages taken from it.
+type ExplainedDocument struct {
+	DocumentID int64             `json:"document_id"`
+	Filename   string            `json:"filename"`
+	Pages      []int             `json:"pages,omitempty"`
+	Passages   []ExplainedSource `json:"passages"`
+}
+
+// ExplainedSource is one passage given to the model and why retrieval
+// selected it.
+type ExplainedSource struct {
+	ChunkID    int64   `json:"chunk_id"`
+	Heading    string  `json:"heading,omitempty"`
+	PageNumber int     `json:"page_number,omitempty"`
+	Score      float64 `json:"score"`
+	// Ranks maps each retrieval method that found the passage to its
+	// 1-based rank in that method's results.
+	Ranks     map[string]int `json:"ranks,omitempty"`
+	GraphPath string         `json:"graph_path,omitempty"`
+	Reason    string         `json:"reason,omitempty"`
+}
+
+// methodDescriptions name retrieval legs for end users.
+var methodDescriptions = map[string]string{
+	"vector":           "similar meaning",
+	"vector_secondary": "similar meaning (second embedding model)",
+	"fts":              "matching keywords",
+	"sparse":           "matching key terms",
+	"graph":            "related entities in the knowledge graph",
+	"image":            "a matching image",
+}
+
+// stepDescriptions name reasoning actions for end users.
+var stepDescriptions = map[string]string{
+	"initial_answer": "drafted an answer from the passages",
+	"validation":     "checked the draft's citations and consistency",
+	"refinement":     "revised the answer to fix the issues found",
+	"answer":         "answered from the passages found",
+	"search":         "searched again for missing information",
+	"plan":           "split the question into sub-questions",
+	"execute":        "answered a sub-question",
+}
+
+// ExplainQuery explains how the logged answer queryID was produced: the
+// documents and pages consulted, why retrieval selected each passage, the
+// reasoning rounds, and what the confidence rests on. It reads only what
+// was logged with the query.
+func (e *engine) ExplainQuery(ctx context.Context, queryID int64) (*QueryExplanation, error) {
+	q, err := e.queryLog.GetQueryLog(ctx, queryID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %d", ErrQueryNotFound, queryID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var sources []Source
+	if raw, ok := q.Sources.(json.RawMessage); ok {
+		_ = json.Unmarshal(raw, &sources)
+	}
+	var trace *queryTrace
+	if raw, ok := q.Trace.(json.RawMessage); ok {
+		trace = &queryTrace{}
+		if err := json.Unmarshal(raw, trace); err != nil {
+			trace = nil
+		}
+	}
+
+	x := &QueryExplanation{
+		QueryID:    q.ID,
+		Question:   q.Query,
+		Answer:     q.Answer,
+		AskedAt:    q.CreatedAt,
+		Refusal:    q.RefusalReason,
+		Rounds:     q.Rounds,
+		Confidence: q.Confidence,
+		Traced:     trace != nil,
+		Documents:  explainSources(sources, trace),
+	}
+	if trace != nil {
+		for _, s := range trace.Reasoning {
+			x.Steps = append(x.Steps, describeStep(s))
+		}
+	}
+	x.ConfidenceRationale = confidenceRationale(q.Confidence, trace)
+	x.Text = x.render()
+	return x, nil
+}
+
+// explainSources groups sources by document in the order they were cited
+// and attaches retrieval's reasons from the trace.
+func explainSources(sources []Source, trace *queryTrace) []ExplainedDocument {
+	var perResult map[int64]retrieval.FusedResultInfo
+	if trace != nil && trace.Retrieval != nil {
+		perResult = trace.Retrieval.PerResult
+	}
+	docs := []ExplainedDocument{}
+	index := make(map[int64]int)
+	for _, s := range sources {
+		i, ok := index[s.DocumentID]
+		if !ok {
+			i = len(docs)
+			index[s.DocumentID] = i
+			docs = append(docs, ExplainedDocument{DocumentID: s.DocumentID, Filename: s.Filename})
+		}
+		d := &docs[i]
+		if s.PageNumber > 0 && !containsInt(d.Pages, s.PageNumber) {
+			d.Pages = append(d.Pages, s.PageNumber)
+		}
+		p := ExplainedSource{ChunkID: s.ChunkID, Heading: s.Heading, PageNumber: s.PageNumber, Score: s.Score}
+		if info, ok := perResult[s.ChunkID]; ok {
+			p.Ranks = legRanks(info)
+			p.GraphPath = info.GraphPath
+			p.Reason = selectionReason(p.Ranks, info.GraphPath)
+		}
+		d.Passages = append(d.Passages, p)
+	}
+	for i := range docs {
+		sort.Ints(docs[i].Pages)
+	}
+	return docs
+}
+
+func containsInt(xs []int, x int) bool {
+	for _, v := range xs {
+		if v == x {
+			return true
+		}
+	}
+	return false
+}
+
+// legRanks lists the ranks a fused result had in each leg that found it.
+func legRanks(info retrieval.FusedResultInfo) map[string]int {
+	ranks := make(map[string]int)
+	for method, rank := range map[string]int{
+		"vector": info.VecRank, "fts": info.FTSRank, "graph": info.GraphRank,
+		"sparse": info.SparseRank, "image": info.ImageRank, "vector_secondary": info.SecondaryRank,
+	} {
+		if rank > 0 {
+			ranks[method] = rank
+		}
+	}
+	if len(ranks) == 0 {
+		return nil
+	}
+	return ranks
+}
+
+// selectionReason says in words which searches found a passage, best
+// rank first.
+func selectionReason(ranks map[string]int, graphPath string) string {
+	if len(ranks) == 0 {
+		return ""
+	}
+	methods := make([]string, 0, len(ranks))
+	for m := range ranks {
+		methods = append(methods, m)
+	}
+	sort.Slice(methods, func(i, j int) bool {
+		if ranks[methods[i]] != ranks[methods[j]] {
+			return ranks[methods[i]] < ranks[methods[j]]
+		}
+		return methods[i] < methods[j]
+	})
+	parts := make([]string, len(methods))
+	for i, m := range methods {
+		desc := methodDescriptions[m]
+		if desc == "" {
+			desc = m
+		}
+		parts[i] = fmt.Sprintf("#%d for %s", ranks[m], desc)
+	}
+	reason := "Ranked " + joinWords(parts)
+	if graphPath != "" {
+		reason += " (via " + graphPath + ")"
+	}
+	if len(methods) > 1 {
+		reason += "; found by several searches, which raised its score"
+	}
+	return reason + "."
+}
+
+// joinWords joins "a", "b", and "c" as "a, b and c".
+func joinWords(parts []string) string {
+	if len(parts) <= 1 {
+		return strings.Join(parts, "")
+	}
+	return strings.Join(parts[:len(parts)-1], ", ") + " and " + parts[len(parts)-1]
+}
+
+func describeStep(s Step) string {
+	desc := stepDescriptions[s.Action]
+	if desc == "" {
+		desc = strings.ReplaceAll(s.Action, "_", " ")
+	}
+	text := fmt.Sprintf("Round %d: %s", s.Round, desc)
+	if s.ChunksUsed > 0 {
+		text += fmt.Sprintf(" (%d passages)", s.ChunksUsed)
+	}
+	if n := len(s.Issues); n > 0 {
+		text += fmt.Sprintf("; %d issue(s): %s", n, strings.Join(s.Issues, "; "))
+	}
+	return text + "."
+}
+
+// confidenceRationale explains the confidence score from the validation
+// round, when one ran.
+func confidenceRationale(confidence float64, trace *queryTrace) string {
+	level := "low"
+	switch {
+	case confidence >= 0.8:
+		level = "high"
+	case confidence >= 0.5:
+		level = "moderate"
+	}
+	text := fmt.Sprintf("Confidence is %s (%.2f).", level, confidence)
+	if trace == nil {
+		return text
+	}
+	var validation *Step
+	refined := false
+	for i, s := range trace.Reasoning {
+		switch s.Action {
+		case "validation":
+			validation = &trace.Reasoning[i]
+		case "refinement":
+			refined = true
+		}
+	}
+	switch {
+	case validation == nil:
+		text += " No validation round ran; it is estimated from how closely the answer follows the passages."
+	case len(validation.Issues) == 0:
+		text += " The validation round found the citations consistent with the passages."
+	default:
+		text += fmt.Sprintf(" The validation round found %d issue(s): %s.", len(validation.Issues), strings.Join(validation.Issues, "; "))
+	}
+	if refined {
+		text += fmt.Sprintf(" The answer was below the %.2f threshold or missed a requirement, so it was revised and checked again.", trace.Threshold)
+	} else if validation != nil && trace.Threshold > 0 && confidence < trace.Threshold {
+		text += fmt.Sprintf(" It is below the %.2f threshold.", trace.Threshold)
+	}
+	return text
+}
+
+// render writes the explanation as prose for end users.
+func (x *QueryExplanation) render() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Question: %s\n\n", x.Question)
+	if x.Refusal != "" {
+		fmt.Fprintf(&b, "The question was not answered from the documents: %s.\n", x.Refusal)
+		return b.String()
+	}
+
+	switch len(x.Documents) {
+	case 0:
+		b.WriteString("No document passages were used for this answer.\n")
+	case 1:
+		b.WriteString("The answer is based on 1 document:\n")
+	default:
+		fmt.Fprintf(&b, "The answer is based on %d documents:\n", len(x.Documents))
+	}
+	for _, d := range x.Documents {
+		fmt.Fprintf(&b, "- %s", d.Filename)
+		if len(d.Pages) > 0 {
+			pages := make([]string, len(d.Pages))
+			for i, p := range d.Pages {
+				pages[i] = fmt.Sprint(p)
+			}
+			label := "page"
+			if len(pages) > 1 {
+				label = "pages"
+			}
+			fmt.Fprintf(&b, " (%s %s)", label, joinWords(pages))
+		}
+		b.WriteString("\n")
+		for _, p := range d.Passages {
+			name := p.Heading
+			if name == "" {
+				name = fmt.Sprintf("passage %d", p.ChunkID)
+			}
+			fmt.Fprintf(&b, "  - %q", name)
+			if p.Reason != "" {
+				fmt.Fprintf(&b, ": %s", p.Reason)
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	if len(x.Steps) > 0 {
+		fmt.Fprintf(&b, "\nThe answer took %d reasoning round(s):\n", x.Rounds)
+		for _, s := range x.Steps {
+			fmt.Fprintf(&b, "- %s\n", s)
+		}
+	}
+	fmt.Fprintf(&b, "\n%s\n", x.ConfidenceRationale)
+	if !x.Traced {
+		b.WriteString("\nThis query was logged without a trace, so the reasons each passage was selected are not available.\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,88 @@
+package goreason
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bbiangul/go-reason/store"
+)
+
+func TestSelectionReason(t *testing.T) {
+	got := selectionReason(map[string]int{"fts": 1, "vector": 3}, "")
+	want := "Ranked #1 for matching keywords and #3 for similar meaning; found by several searches, which raised its score."
+	if got != want {
+		t.Errorf("selectionReason = %q, want %q", got, want)
+	}
+	got = selectionReason(map[string]int{"graph": 2}, "pump -[has_part]-> relief valve")
+	if got != "Ranked #2 for related entities in the knowledge graph (via pump -[has_part]-> relief valve)." {
+		t.Errorf("selectionReason = %q", got)
+	}
+}
+
+func TestExplainQuery(t *testing.T) {
+	eng, _, path := faultEngine(t, nil)
+	ctx := context.Background()
+	if _, err := eng.Ingest(ctx, path); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	answer, err := eng.Query(ctx, "At what pressure does the relief valve open?")
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+
+	x, err := eng.ExplainQuery(ctx, answer.QueryID)
+	if err != nil {
+		t.Fatalf("ExplainQuery: %v", err)
+	}
+	if !x.Traced || x.Question != "At what pressure does the relief valve open?" || x.Answer != answer.Text {
+		t.Errorf("explanation = %+v", x)
+	}
+	if len(x.Documents) != 1 || x.Documents[0].Filename != "pump.txt" || len(x.Documents[0].Passages) != len(answer.Sources) {
+		t.Fatalf("documents = %+v", x.Documents)
+	}
+	p := x.Documents[0].Passages[0]
+	if len(p.Ranks) == 0 || !strings.HasPrefix(p.Reason, "Ranked #") {
+		t.Errorf("passage = %+v", p)
+	}
+	if x.Rounds != answer.Rounds || len(x.Steps) != len(answer.Reasoning) {
+		t.Errorf("rounds = %d, steps = %v", x.Rounds, x.Steps)
+	}
+	if !strings.Contains(x.ConfidenceRationale, "The validation round found the citations consistent") {
+		t.Errorf("rationale = %q", x.ConfidenceRationale)
+	}
+	if !strings.Contains(x.Text, "pump.txt") || !strings.Contains(x.Text, p.Reason) {
+		t.Errorf("text = %q", x.Text)
+	}
+
+	if _, err := eng.ExplainQuery(ctx, answer.QueryID+100); !errors.Is(err, ErrQueryNotFound) {
+		t.Errorf("ExplainQuery(missing) = %v, want ErrQueryNotFound", err)
+	}
+}
+
+func TestExplainUntracedQuery(t *testing.T) {
+	eng, _, _ := faultEngine(t, nil)
+	ctx := context.Background()
+	id, err := eng.(*engine).store.InsertQueryLog(ctx, store.QueryLog{
+		Query:      "old question",
+		Answer:     "old answer",
+		Confidence: 0.9,
+		Sources:    []Source{{ChunkID: 7, DocumentID: 1, Filename: "manual.pdf", PageNumber: 4}},
+		Rounds:     2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	x, err := eng.ExplainQuery(ctx, id)
+	if err != nil {
+		t.Fatalf("ExplainQuery: %v", err)
+	}
+	if x.Traced || len(x.Documents) != 1 || len(x.Documents[0].Pages) != 1 || x.Documents[0].Pages[0] != 4 {
+		t.Errorf("explanation = %+v", x)
+	}
+	if x.ConfidenceRationale != "Confidence is high (0.90)." || !strings.Contains(x.Text, "without a trace") {
+		t.Errorf("rationale = %q, text = %q", x.ConfidenceRationale, x.Text)
+	}
+}
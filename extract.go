@@ -0,0 +1,417 @@
+package goreason
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bbiangul/go-reason/llm"
+	"github.com/bbiangul/go-reason/retrieval"
+	"github.com/bbiangul/go-reason/store"
+)
+
+const (
+	// defaultExtractMaxChunks caps the chunks retrieved for an extraction
+	// when no documents are named.
+	defaultExtractMaxChunks = 100
+	// defaultExtractBatchSize is the number of chunks sent per LLM call.
+	defaultExtractBatchSize = 8
+)
+
+// Extraction field types.
+const (
+	FieldString  = "string"
+	FieldNumber  = "number"
+	FieldInteger = "integer"
+	FieldBoolean = "boolean"
+)
+
+// ExtractField is one column of an extraction schema.
+type ExtractField struct {
+	Name        string `json:"name"`
+	Type        string `json:"type,omitempty"` // FieldString (default), FieldNumber, FieldInteger, FieldBoolean
+	Description string `json:"description,omitempty"`
+	// Required drops rows without a value for the field.
+	Required bool `json:"required,omitempty"`
+}
+
+// ExtractSchema describes the records Extract returns.
+type ExtractSchema struct {
+	Fields []ExtractField `json:"fields"`
+}
+
+// ExtractRow is one extracted record with the chunk it was read from.
+type ExtractRow struct {
+	Values     map[string]interface{} `json:"values"`
+	ChunkID    int64                  `json:"chunk_id"`
+	DocumentID int64                  `json:"document_id"`
+	Filename   string                 `json:"filename"`
+	Heading    string                 `json:"heading,omitempty"`
+	PageNumber int                    `json:"page_number,omitempty"`
+}
+
+// Extraction is the result of Extract.
+type Extraction struct {
+	Fields        []ExtractField `json:"fields"`
+	Rows          []ExtractRow   `json:"rows"`
+	ChunksScanned int            `json:"chunks_scanned"`
+	Batches       int            `json:"batches"`
+	FailedBatches int            `json:"failed_batches,omitempty"`
+
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// ExtractOption configures Extract.
+type ExtractOption func(*extractOptions)
+
+type extractOptions struct {
+	documentIDs []int64
+	maxChunks   int
+	batchSize   int
+}
+
+// WithExtractDocuments scans every chunk of the given documents, in
+// document order, instead of retrieving the chunks most relevant to the
+// instruction. Use it for exhaustive lists ("every part number in this
+// manual").
+func WithExtractDocuments(ids ...int64) ExtractOption {
+	return func(o *extractOptions) { o.documentIDs = ids }
+}
+
+// WithExtractMaxChunks caps the chunks retrieved when no documents are
+// named (default 100).
+func WithExtractMaxChunks(n int) ExtractOption {
+	return func(o *extractOptions) { o.maxChunks = n }
+}
+
+// WithExtractBatchSize sets the number of chunks per LLM call (default 8).
+func WithExtractBatchSize(n int) ExtractOption {
+	return func(o *extractOptions) { o.batchSize = n }
+}
+
+const extractPrompt = `You extract structured records from document excerpts.
+
+TASK: %s
+
+Each record has these fields:
+%s
+Rules:
+- Extract only records that are explicitly stated in the excerpts. Do not infer or invent values.
+- Use null for a field the excerpt does not state.
+- "source" is the number of the excerpt the record was read from.
+- Return {"rows": []} when no excerpt contains a matching record.
+
+Return a JSON object: {"rows": [{"source": 1, "values": {"field": value, ...}}]}
+Do NOT include any text outside the JSON object.
+
+EXCERPTS:
+%s`
+
+// extractReply is the JSON shape returned by an extraction call.
+type extractReply struct {
+	Rows []struct {
+		Source int                    `json:"source"`
+		Values map[string]interface{} `json:"values"`
+	} `json:"rows"`
+}
+
+// validateExtractSchema checks field names and types.
+func validateExtractSchema(schema ExtractSchema) error {
+	if len(schema.Fields) == 0 {
+		return fmt.Errorf("%w: extraction schema has no fields", ErrInvalidConfig)
+	}
+	seen := make(map[string]bool)
+	for _, f := range schema.Fields {
+		if f.Name == "" || seen[f.Name] {
+			return fmt.Errorf("%w: extraction field names must be unique and non-empty", ErrInvalidConfig)
+		}
+		seen[f.Name] = true
+		switch f.Type {
+		case "", FieldString, FieldNumber, FieldInteger, FieldBoolean:
+		default:
+			return fmt.Errorf("%w: extraction field %q: unknown type %q", ErrInvalidConfig, f.Name, f.Type)
+		}
+	}
+	return nil
+}
+
+// Extract reads records matching schema out of the corpus: the chunks most
+// relevant to instruction, or every chunk of the documents named with
+// WithExtractDocuments. Chunks are sent to the LLM in batches; a failed
+// batch is logged and skipped. Duplicate records are returned once.
+func (e *engine) Extract(ctx context.Context, instruction string, schema ExtractSchema, opts ...ExtractOption) (*Extraction, error) {
+	ctx = llm.WithPhase(ctx, llm.PhaseQuery)
+	if strings.TrimSpace(instruction) == "" {
+		return nil, fmt.Errorf("%w: extraction instruction is required", ErrInvalidConfig)
+	}
+	if err := validateExtractSchema(schema); err != nil {
+		return nil, err
+	}
+	options := &extractOptions{maxChunks: defaultExtractMaxChunks, batchSize: defaultExtractBatchSize}
+	for _, o := range opts {
+		o(options)
+	}
+	if options.batchSize <= 0 {
+		options.batchSize = defaultExtractBatchSize
+	}
+
+	chunks, err := e.extractChunks(ctx, instruction, options)
+	if err != nil {
+		return nil, err
+	}
+	out := &Extraction{Fields: schema.Fields, Rows: []ExtractRow{}, ChunksScanned: len(chunks)}
+	if len(chunks) == 0 {
+		return out, nil
+	}
+
+	fields := describeExtractFields(schema.Fields)
+	seen := make(map[string]bool)
+	for start := 0; start < len(chunks); start += options.batchSize {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		end := start + options.batchSize
+		if end > len(chunks) {
+			end = len(chunks)
+		}
+		batch := chunks[start:end]
+		out.Batches++
+
+		resp, err := e.chatLLM.Chat(ctx, llm.ChatRequest{
+			Messages: []llm.Message{
+				{Role: "user", Content: fmt.Sprintf(extractPrompt, instruction, fields, extractExcerpts(batch))},
+			},
+			Temperature:    0.0,
+			ResponseFormat: "json_object",
+		})
+		if err != nil {
+			out.FailedBatches++
+			slog.WarnContext(ctx, "extract: batch failed (non-fatal)", "batch", out.Batches, "error", err)
+			continue
+		}
+		out.PromptTokens += resp.PromptTokens
+		out.CompletionTokens += resp.CompletionTokens
+		out.TotalTokens += resp.TotalTokens
+
+		rows, err := parseExtractRows(resp.Content, schema.Fields, batch)
+		if err != nil {
+			out.FailedBatches++
+			slog.WarnContext(ctx, "extract: unparseable batch reply (non-fatal)", "batch", out.Batches, "error", err)
+			continue
+		}
+		for _, row := range rows {
+			key, _ := json.Marshal(row.Values)
+			if seen[string(key)] {
+				continue
+			}
+			seen[string(key)] = true
+			out.Rows = append(out.Rows, row)
+		}
+	}
+	if out.FailedBatches == out.Batches {
+		return nil, fmt.Errorf("extraction failed: all %d batches failed", out.Batches)
+	}
+	return out, nil
+}
+
+// extractChunks returns the chunks to scan, ordered by document and
+// position so each batch reads as continuous text.
+func (e *engine) extractChunks(ctx context.Context, instruction string, o *extractOptions) ([]store.RetrievalResult, error) {
+	if len(o.documentIDs) == 0 {
+		defaults := e.defaultQueryOptions("")
+		results, _, err := e.retriever.Search(ctx, instruction, retrieval.SearchOptions{
+			MaxResults:  o.maxChunks,
+			WeightVec:   defaults.weightVec,
+			WeightFTS:   defaults.weightFTS,
+			WeightGraph: defaults.weightGraph,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("retrieval: %w", err)
+		}
+		sort.SliceStable(results, func(i, j int) bool {
+			if results[i].DocumentID != results[j].DocumentID {
+				return results[i].DocumentID < results[j].DocumentID
+			}
+			return results[i].PositionInDoc < results[j].PositionInDoc
+		})
+		return results, nil
+	}
+
+	var out []store.RetrievalResult
+	for _, id := range o.documentIDs {
+		doc, err := e.store.GetDocument(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %d", ErrDocumentNotFound, id)
+		}
+		chunks, err := e.store.GetChunksByDocument(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("loading chunks of document %d: %w", id, err)
+		}
+		// Parent chunks repeat their children's text; scan the children.
+		parents := make(map[int64]bool)
+		for _, c := range chunks {
+			if c.ParentChunkID != nil {
+				parents[*c.ParentChunkID] = true
+			}
+		}
+		for _, c := range chunks {
+			if parents[c.ID] {
+				continue
+			}
+			out = append(out, store.RetrievalResult{
+				ChunkID:       c.ID,
+				DocumentID:    id,
+				Content:       c.Content,
+				Heading:       c.Heading,
+				ChunkType:     c.ChunkType,
+				PageNumber:    c.PageNumber,
+				PositionInDoc: c.PositionInDoc,
+				StartOffset:   c.StartOffset,
+				EndOffset:     c.EndOffset,
+				Filename:      doc.Filename,
+				Path:          doc.Path,
+			})
+		}
+	}
+	return out, nil
+}
+
+// describeExtractFields lists the schema for the prompt, one field per line.
+func describeExtractFields(fields []ExtractField) string {
+	var b strings.Builder
+	for _, f := range fields {
+		typ := f.Type
+		if typ == "" {
+			typ = FieldString
+		}
+		fmt.Fprintf(&b, "- %q (%s", f.Name, typ)
+		if f.Required {
+			b.WriteString(", required")
+		}
+		b.WriteString(")")
+		if f.Description != "" {
+			b.WriteString(": " + f.Description)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// extractExcerpts numbers the batch's chunks from 1 with their location.
+func extractExcerpts(batch []store.RetrievalResult) string {
+	var b strings.Builder
+	for i, c := range batch {
+		fmt.Fprintf(&b, "[%d] %s", i+1, c.Filename)
+		if c.PageNumber > 0 {
+			fmt.Fprintf(&b, ", page %d", c.PageNumber)
+		}
+		if c.Heading != "" {
+			fmt.Fprintf(&b, ", %s", c.Heading)
+		}
+		b.WriteString("\n")
+		b.WriteString(c.Content)
+		b.WriteString("\n\n")
+	}
+	return b.String()
+}
+
+// parseExtractRows decodes an extraction reply, tolerating surrounding
+// prose, and converts each row to the schema: values are coerced to the
+// field type, unknown fields are dropped, and rows missing a required
+// field, without any value, or citing an excerpt outside the batch are
+// skipped.
+func parseExtractRows(raw string, fields []ExtractField, batch []store.RetrievalResult) ([]ExtractRow, error) {
+	raw = llm.JSONObject(raw)
+	var reply extractReply
+	if err := json.Unmarshal([]byte(raw), &reply); err != nil {
+		return nil, fmt.Errorf("unmarshalling extraction result: %w", err)
+	}
+
+	var rows []ExtractRow
+	for _, r := range reply.Rows {
+		if r.Source < 1 || r.Source > len(batch) {
+			continue
+		}
+		values := make(map[string]interface{}, len(fields))
+		ok, empty := true, true
+		for _, f := range fields {
+			v, has := coerceExtractValue(r.Values[f.Name], f.Type)
+			if !has {
+				if f.Required {
+					ok = false
+				}
+				values[f.Name] = nil
+				continue
+			}
+			values[f.Name] = v
+			empty = false
+		}
+		if !ok || empty {
+			continue
+		}
+		src := batch[r.Source-1]
+		rows = append(rows, ExtractRow{
+			Values:     values,
+			ChunkID:    src.ChunkID,
+			DocumentID: src.DocumentID,
+			Filename:   src.Filename,
+			Heading:    src.Heading,
+			PageNumber: src.PageNumber,
+		})
+	}
+	return rows, nil
+}
+
+// coerceExtractValue converts a decoded JSON value to the field type. It
+// reports false for null, empty, or unconvertible values.
+func coerceExtractValue(v interface{}, typ string) (interface{}, bool) {
+	if v == nil {
+		return nil, false
+	}
+	switch typ {
+	case FieldNumber, FieldInteger:
+		var f float64
+		switch x := v.(type) {
+		case float64:
+			f = x
+		case string:
+			n, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(x), ",", ""), 64)
+			if err != nil {
+				return nil, false
+			}
+			f = n
+		default:
+			return nil, false
+		}
+		if typ == FieldInteger {
+			return int64(f), true
+		}
+		return f, true
+	case FieldBoolean:
+		switch x := v.(type) {
+		case bool:
+			return x, true
+		case string:
+			b, err := strconv.ParseBool(strings.ToLower(strings.TrimSpace(x)))
+			return b, err == nil
+		}
+		return nil, false
+	default:
+		var s string
+		switch x := v.(type) {
+		case string:
+			s = strings.TrimSpace(x)
+		case float64:
+			s = strconv.FormatFloat(x, 'f', -1, 64)
+		default:
+			s = strings.TrimSpace(fmt.Sprint(x))
+		}
+		return s, s != ""
+	}
+}
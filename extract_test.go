@@ -0,0 +1,103 @@
+package goreason
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bbiangul/go-reason/store"
+)
+
+var partSchema = ExtractSchema{Fields: []ExtractField{
+	{Name: "part_number", Required: true},
+	{Name: "description"},
+	{Name: "quantity", Type: FieldInteger},
+}}
+
+func TestValidateExtractSchema(t *testing.T) {
+	if err := validateExtractSchema(partSchema); err != nil {
+		t.Fatalf("valid schema rejected: %v", err)
+	}
+	for _, bad := range []ExtractSchema{
+		{},
+		{Fields: []ExtractField{{Name: ""}}},
+		{Fields: []ExtractField{{Name: "a"}, {Name: "a"}}},
+		{Fields: []ExtractField{{Name: "a", Type: "date"}}},
+	} {
+		if err := validateExtractSchema(bad); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("schema %+v: error = %v, want ErrInvalidConfig", bad, err)
+		}
+	}
+}
+
+func TestParseExtractRows(t *testing.T) {
+	batch := []store.RetrievalResult{
+		{ChunkID: 10, DocumentID: 1, Filename: "pump.pdf", PageNumber: 4},
+		{ChunkID: 11, DocumentID: 1, Filename: "pump.pdf", PageNumber: 5},
+	}
+	raw := "Here are the rows:\n" + `{"rows": [
+		{"source": 1, "values": {"part_number": "PV-10", "description": "Relief valve", "quantity": "2", "colour": "red"}},
+		{"source": 2, "values": {"part_number": 4711, "quantity": 1.0}},
+		{"source": 2, "values": {"description": "Seal kit"}},
+		{"source": 3, "values": {"part_number": "X-1"}}
+	]}`
+	rows, err := parseExtractRows(raw, partSchema.Fields, batch)
+	if err != nil {
+		t.Fatalf("parseExtractRows: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("rows = %+v, want 2 (missing part number and bad source dropped)", rows)
+	}
+	first := rows[0]
+	if first.Values["part_number"] != "PV-10" || first.Values["quantity"] != int64(2) || first.PageNumber != 4 || first.ChunkID != 10 {
+		t.Errorf("first row = %+v", first)
+	}
+	if _, ok := first.Values["colour"]; ok {
+		t.Error("field outside the schema was kept")
+	}
+	if second := rows[1]; second.Values["part_number"] != "4711" || second.Values["description"] != nil || second.ChunkID != 11 {
+		t.Errorf("second row = %+v", second)
+	}
+
+	if _, err := parseExtractRows("no json", partSchema.Fields, batch); err == nil {
+		t.Error("expected error for non-JSON reply")
+	}
+}
+
+func TestExtractBatchesDocument(t *testing.T) {
+	eng, srv, path := faultEngine(t, nil)
+	ctx := context.Background()
+	docID, err := eng.Ingest(ctx, path)
+	if err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+
+	calls := 0
+	srv.ChatFunc = func(prompt string) string {
+		if !strings.Contains(prompt, "You extract structured records") {
+			return "{}"
+		}
+		calls++
+		// Every batch reports the same valve; it is returned once.
+		return `{"rows": [{"source": 1, "values": {"part_number": "relief valve", "description": "opens at 10 bar"}}]}`
+	}
+
+	res, err := eng.Extract(ctx, "List every part.", partSchema, WithExtractDocuments(docID), WithExtractBatchSize(1))
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	if res.ChunksScanned == 0 || res.Batches != res.ChunksScanned || calls != res.Batches {
+		t.Errorf("scanned %d chunks in %d batches with %d calls", res.ChunksScanned, res.Batches, calls)
+	}
+	if len(res.Rows) != 1 || res.Rows[0].DocumentID != docID || res.Rows[0].Filename != "pump.txt" {
+		t.Errorf("rows = %+v", res.Rows)
+	}
+
+	if _, err := eng.Extract(ctx, "List every part.", partSchema, WithExtractDocuments(docID+1)); !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("unknown document error = %v, want ErrDocumentNotFound", err)
+	}
+	if _, err := eng.Extract(ctx, " ", partSchema); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("empty instruction error = %v, want ErrInvalidConfig", err)
+	}
+}
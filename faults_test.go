@@ -0,0 +1,212 @@
+package goreason
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bbiangul/go-reason/llm/llmtest"
+	"github.com/bbiangul/go-reason/retrieval"
+)
+
+// loadScenario reads a scenario from testdata/faults.
+func loadScenario(t *testing.T, name string) *llmtest.Scenario {
+	t.Helper()
+	sc, err := llmtest.LoadScenario(filepath.Join("testdata", "faults", name))
+	if err != nil {
+		t.Fatalf("LoadScenario: %v", err)
+	}
+	return sc
+}
+
+// faultConfig returns the config of an engine storing its database in dir
+// whose chat and embedding providers talk to srv.
+func faultConfig(dir string, srv *llmtest.Server) Config {
+	llmCfg := LLMConfig{Provider: "custom", Model: "fake", BaseURL: srv.URL}
+	return Config{
+		DBPath:       filepath.Join(dir, "faults.db"),
+		Chat:         llmCfg,
+		Embedding:    llmCfg,
+		EmbeddingDim: 4,
+		MaxRounds:    1,
+		SkipSummary:  true,
+	}
+}
+
+// faultEngine returns an engine whose chat and embedding providers talk to
+// a fault-injecting server running sc, and the path of a document to
+// ingest. Each override edits the config before the engine is opened.
+func faultEngine(t testing.TB, sc *llmtest.Scenario, overrides ...func(*Config)) (Engine, *llmtest.Server, string) {
+	t.Helper()
+	srv := llmtest.NewServer(sc)
+	t.Cleanup(srv.Close)
+	srv.ChatFunc = func(prompt string) string {
+		switch {
+		case strings.Contains(prompt, "Convert the following answer into a JSON object"):
+			return `{"found": true, "response": "The relief valve opens at 10 bar."}`
+		case strings.Contains(prompt, "relationship extraction engine"):
+			return `{"relationships": []}`
+		case strings.Contains(prompt, "entity extraction engine"):
+			return `{"language": "English", "entities": [{"name": "relief valve", "type": "concept", "description": "Valve", "name_en": "relief valve"}]}`
+		}
+		return "The relief valve opens at 10 bar (pump.txt)."
+	}
+
+	dir := t.TempDir()
+	cfg := faultConfig(dir, srv)
+	for _, override := range overrides {
+		override(&cfg)
+	}
+	eng, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { eng.Close() })
+
+	path := filepath.Join(dir, "pump.txt")
+	text := "Pump maintenance.\n\n" +
+		"The relief valve on the discharge line of the pump opens at 10 bar and closes again once the " +
+		"pressure drops below 8 bar. Inspect the relief valve every 500 operating hours and replace the " +
+		"seat seal whenever the valve weeps below its set pressure.\n"
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return eng, srv, path
+}
+
+// skipGraph is a faultEngine override for tests that need no graph.
+func skipGraph(cfg *Config) { cfg.SkipGraph = true }
+
+func TestIngestSurvivesLLMFaults(t *testing.T) {
+	eng, srv, path := faultEngine(t, loadScenario(t, "ingest.json"))
+	ctx := context.Background()
+
+	if _, err := eng.Ingest(ctx, path); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	docs, err := eng.ListDocuments(ctx)
+	if err != nil || len(docs) != 1 || docs[0].Status != "ready" {
+		t.Fatalf("documents = %+v, %v; want one ready", docs, err)
+	}
+
+	// The failed embedding batch fell back to one request per chunk.
+	if srv.Faults(llmtest.FaultServerError) != 1 || srv.Calls(llmtest.OpEmbed) < 2 {
+		t.Errorf("embed calls = %d, faults = %d", srv.Calls(llmtest.OpEmbed), srv.Faults(llmtest.FaultServerError))
+	}
+	// The malformed extraction reply was retried, not dropped.
+	stats := eng.GraphExtractionStats()
+	if stats.Retried != 1 || stats.ParseFailures != 0 {
+		t.Errorf("extraction stats = %+v, want one retry and no failures", stats)
+	}
+}
+
+func TestQueryRecoversMalformedJSONOutput(t *testing.T) {
+	eng, srv, path := faultEngine(t, loadScenario(t, "query.json"))
+	ctx := context.Background()
+	if _, err := eng.Ingest(ctx, path); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+
+	ans, err := eng.Query(ctx, "At what pressure does the relief valve open?", WithJSONOutput())
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if srv.Faults(llmtest.FaultMalformedJSON) != 1 {
+		t.Fatalf("malformed JSON faults = %d, want 1", srv.Faults(llmtest.FaultMalformedJSON))
+	}
+	if ans.Found == nil || !*ans.Found || !strings.Contains(ans.Text, "10 bar") {
+		t.Errorf("answer = %q (found %v), want the repaired JSON output", ans.Text, ans.Found)
+	}
+}
+
+func TestQueryAnswerFormat(t *testing.T) {
+	eng, srv, path := faultEngine(t, &llmtest.Scenario{})
+	ctx := context.Background()
+	if _, err := eng.Ingest(ctx, path); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	chat := srv.ChatFunc
+	srv.ChatFunc = func(prompt string) string {
+		if strings.Contains(prompt, "Convert the answer below into a JSON object") {
+			return `{"summary": "The relief valve opens at 10 bar.", "details": "", "caveats": [], "citations": ["pump.txt"]}`
+		}
+		return chat(prompt)
+	}
+
+	ans, err := eng.Query(ctx, "At what pressure does the relief valve open?", WithFormat("json"))
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if ans.Sections == nil || ans.Sections.Summary != "The relief valve opens at 10 bar." || !strings.HasPrefix(ans.Text, `{"summary"`) {
+		t.Errorf("answer = %q, sections %+v", ans.Text, ans.Sections)
+	}
+
+	for _, opts := range [][]QueryOption{
+		{WithFormat("yaml")},
+		{WithFormat("json"), WithJSONOutput()},
+	} {
+		if _, err := eng.Query(ctx, "At what pressure does the relief valve open?", opts...); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("Query error = %v, want ErrInvalidConfig", err)
+		}
+	}
+}
+
+func TestQueryTimesOut(t *testing.T) {
+	// Calls mentioning the question never get an answer, so ingest works
+	// and the query ends at the caller's deadline.
+	const question = "At what pressure does the relief valve open?"
+	eng, _, path := faultEngine(t, &llmtest.Scenario{Rules: []llmtest.Rule{
+		{Op: llmtest.OpChat, Match: question, Fault: llmtest.FaultTimeout},
+	}})
+	ctx := context.Background()
+	if _, err := eng.Ingest(ctx, path); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := eng.Query(ctx, question)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Query error = %v, want deadline exceeded", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Error("query was not abandoned at the deadline")
+	}
+}
+
+func TestSearchFusesLegsFinishedBeforeTimeout(t *testing.T) {
+	// The question's embedding never arrives, so the vector leg hangs
+	// while FTS answers at once.
+	const question = "relief valve seat seal"
+	eng, _, path := faultEngine(t, &llmtest.Scenario{Rules: []llmtest.Rule{
+		{Op: llmtest.OpEmbed, Match: question, Fault: llmtest.FaultTimeout},
+	}})
+	ctx := context.Background()
+	if _, err := eng.Ingest(ctx, path); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+
+	e := eng.(*engine)
+	e.cfg.RetrievalLegTimeoutMs = 200
+	start := time.Now()
+	results, trace, err := e.newRetriever().Search(ctx, question, retrieval.SearchOptions{
+		MaxResults: 5, WeightVec: 1, WeightFTS: 1, WeightGraph: 1,
+	})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Error("search waited for the hung leg")
+	}
+	if len(trace.TimedOutLegs) != 1 || trace.TimedOutLegs[0] != "vector" {
+		t.Errorf("TimedOutLegs = %v, want [vector]", trace.TimedOutLegs)
+	}
+	if trace.FTSResults == 0 || len(results) == 0 {
+		t.Errorf("got %d results (%d from FTS), want the FTS leg fused", len(results), trace.FTSResults)
+	}
+}
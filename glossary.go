@@ -0,0 +1,236 @@
+package goreason
+
+import (
+	"context"
+	"log/slog"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/bbiangul/go-reason/reasoning"
+	"github.com/bbiangul/go-reason/store"
+)
+
+// maxGlossaryTerms caps the glossary entries added to the reasoning prompt.
+const maxGlossaryTerms = 20
+
+var (
+	// "Total Harmonic Distortion (THD)": the long form precedes the
+	// parenthesised abbreviation.
+	glossaryParenAbbrev = regexp.MustCompile(`\(\s*([\p{L}\p{N}][\p{L}\p{N}&/.\-]{1,9})\s*\)`)
+	// "THD (Total Harmonic Distortion)".
+	glossaryAbbrevParen = regexp.MustCompile(`([\p{L}\p{N}][\p{L}\p{N}&/\-]{1,9})\s*\(([^()\n]{3,120})\)`)
+	// Glossary lists: "THD — Total Harmonic Distortion", "EPP: equipo de
+	// protección personal", "kVA = kilovolt-ampere", "THD<tab>...".
+	glossaryLine = regexp.MustCompile(`(?m)^[ \t]*(?:[-*•][ \t]+)?([\p{L}\p{N}][\p{L}\p{N}&/.\-]{1,9})[ \t]*(?:—|–|-|:|=|\t)[ \t]*(\p{L}[^\n]{2,120})$`)
+	// glossaryToken finds abbreviation candidates in free text.
+	glossaryToken = regexp.MustCompile(`[\p{L}\p{N}][\p{L}\p{N}&/\-]*`)
+)
+
+// extractGlossary finds abbreviations defined in the chunks. A candidate is
+// kept only when the abbreviation's letters can be traced, in order, through
+// the words of its definition (the Schwartz-Hearst test), so a parenthesised
+// remark or a "Note: ..." line is not mistaken for a definition.
+func extractGlossary(docID int64, chunks []store.Chunk, chunkIDs []int64) []store.GlossaryEntry {
+	var out []store.GlossaryEntry
+	seen := make(map[string]bool)
+	add := func(chunkID int64, abbrev, def string) {
+		def = strings.TrimSpace(strings.Trim(def, " \t,;:.-–—"))
+		if !isAbbreviation(abbrev) || !validDefinition(abbrev, def) {
+			return
+		}
+		key := abbrev + "\x00" + strings.ToLower(def)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		out = append(out, store.GlossaryEntry{DocumentID: docID, ChunkID: chunkID, Abbreviation: abbrev, Definition: def})
+	}
+
+	for i, c := range chunks {
+		text := c.Content
+		for _, m := range glossaryParenAbbrev.FindAllStringSubmatchIndex(text, -1) {
+			abbrev := text[m[2]:m[3]]
+			window := precedingWords(text[:m[0]], longFormWords(abbrev))
+			if def := longFormFor(abbrev, window); def != "" {
+				add(chunkIDs[i], abbrev, def)
+			}
+		}
+		for _, m := range glossaryAbbrevParen.FindAllStringSubmatch(text, -1) {
+			long := strings.TrimSpace(m[2])
+			if def := longFormFor(m[1], long); def == long {
+				add(chunkIDs[i], m[1], def)
+			}
+		}
+		for _, m := range glossaryLine.FindAllStringSubmatch(text, -1) {
+			long := strings.TrimSpace(m[2])
+			if cut := strings.IndexAny(long, ".;("); cut > 0 {
+				long = strings.TrimSpace(long[:cut])
+			}
+			if def := longFormFor(m[1], long); def == long {
+				add(chunkIDs[i], m[1], def)
+			}
+		}
+	}
+	return out
+}
+
+// isAbbreviation reports whether s looks like an abbreviation: 2-10
+// characters with at least two capitals ("THD", "PoE", "kVA"), or a short
+// token with one ("Hz").
+func isAbbreviation(s string) bool {
+	runes := []rune(s)
+	if len(runes) < 2 || len(runes) > 10 {
+		return false
+	}
+	var upper, letters int
+	for _, r := range runes {
+		if unicode.IsLetter(r) {
+			letters++
+		}
+		if unicode.IsUpper(r) {
+			upper++
+		}
+	}
+	return letters > 0 && (upper >= 2 || upper == 1 && len(runes) <= 3)
+}
+
+// validDefinition rejects definitions that are too short, too long, or
+// merely repeat the abbreviation.
+func validDefinition(abbrev, def string) bool {
+	n := len([]rune(def))
+	if n <= len([]rune(abbrev)) || n > 100 {
+		return false
+	}
+	return !strings.EqualFold(def, abbrev) && !strings.Contains(def, abbrev)
+}
+
+// longFormWords is how many words before "(ABBR)" may belong to its long
+// form: min(|A|+5, 2|A|), as in Schwartz and Hearst.
+func longFormWords(abbrev string) int {
+	n := len([]rune(abbrev))
+	if n+5 < 2*n {
+		return n + 5
+	}
+	return 2 * n
+}
+
+// precedingWords returns the last n words of text within its final
+// sentence or clause.
+func precedingWords(text string, n int) string {
+	if i := strings.LastIndexAny(text, ".;:!?\n()"); i >= 0 {
+		text = text[i+1:]
+	}
+	words := strings.Fields(text)
+	if len(words) > n {
+		words = words[len(words)-n:]
+	}
+	return strings.Join(words, " ")
+}
+
+// longFormFor returns the shortest suffix of long that accounts for every
+// letter and digit of abbrev in order, with the first one starting a word
+// (Schwartz and Hearst, 2003). It returns "" when there is none.
+func longFormFor(abbrev, long string) string {
+	short := []rune(strings.ToLower(abbrev))
+	lf := []rune(long)
+	lower := []rune(strings.ToLower(long))
+	if len(lower) != len(lf) {
+		return ""
+	}
+
+	s, l := len(short)-1, len(lower)-1
+	for s >= 0 {
+		c := short[s]
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			s--
+			continue
+		}
+		for l >= 0 && (lower[l] != c || s == 0 && l > 0 && (unicode.IsLetter(lower[l-1]) || unicode.IsDigit(lower[l-1]))) {
+			l--
+		}
+		if l < 0 {
+			return ""
+		}
+		l--
+		s--
+	}
+	start := l + 1
+	for start > 0 && !unicode.IsSpace(lf[start-1]) {
+		start--
+	}
+	return strings.TrimSpace(string(lf[start:]))
+}
+
+// glossaryFor returns the glossary entries for abbreviations used in the
+// question or the retrieved chunks. An abbreviation defined differently in
+// several documents takes the definitions of the retrieved documents, so
+// "PE" reads as "protective earth" in a wiring manual and "polyethylene" in
+// a materials spec.
+func (e *engine) glossaryFor(ctx context.Context, question string, results []store.RetrievalResult) []reasoning.GlossaryTerm {
+	if e.cfg.SkipGlossary {
+		return nil
+	}
+	var order []string
+	seen := make(map[string]bool)
+	collect := func(text string) {
+		for _, tok := range glossaryToken.FindAllString(text, -1) {
+			if !seen[tok] && isAbbreviation(tok) && len(order) < 500 {
+				seen[tok] = true
+				order = append(order, tok)
+			}
+		}
+	}
+	collect(question)
+	retrievedDocs := make(map[int64]bool)
+	for _, r := range results {
+		collect(r.Content)
+		retrievedDocs[r.DocumentID] = true
+	}
+	if len(order) == 0 {
+		return nil
+	}
+
+	entries, err := e.store.GlossaryByAbbreviations(ctx, order)
+	if err != nil {
+		slog.WarnContext(ctx, "glossary lookup failed (non-fatal)", "error", err)
+		return nil
+	}
+	byAbbrev := make(map[string][]store.GlossaryEntry)
+	for _, g := range entries {
+		byAbbrev[g.Abbreviation] = append(byAbbrev[g.Abbreviation], g)
+	}
+
+	var terms []reasoning.GlossaryTerm
+	for _, abbrev := range order {
+		defs := byAbbrev[abbrev]
+		var local []store.GlossaryEntry
+		for _, g := range defs {
+			if retrievedDocs[g.DocumentID] {
+				local = append(local, g)
+			}
+		}
+		if len(local) > 0 {
+			defs = local
+		}
+		shown := make(map[string]bool)
+		for _, g := range defs {
+			key := strings.ToLower(g.Definition)
+			if shown[key] {
+				continue
+			}
+			shown[key] = true
+			terms = append(terms, reasoning.GlossaryTerm{Abbreviation: g.Abbreviation, Definition: g.Definition, Source: g.Filename})
+			if len(terms) == maxGlossaryTerms {
+				return terms
+			}
+		}
+	}
+	return terms
+}
+
+// Glossary returns the abbreviations extracted from one document, or from
+// all documents when documentID is 0.
+func (e *engine) Glossary(ctx context.Context, documentID int64) ([]store.GlossaryEntry, error) {
+	return e.store.ListGlossary(ctx, documentID)
+}
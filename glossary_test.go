@@ -0,0 +1,94 @@
+package goreason
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/bbiangul/go-reason/store"
+)
+
+func TestExtractGlossary(t *testing.T) {
+	chunks := []store.Chunk{
+		{Content: "Measure the Total Harmonic Distortion (THD) at the inverter output. Keep it below 5% (see note)."},
+		{Content: "Abreviaturas\n\nEPP — equipo de protección personal\nkVA = kilovolt-ampere\nNote: check the seals\n"},
+		{Content: "Power is delivered by PoE (Power over Ethernet) to each camera."},
+		{Content: "The Total Harmonic Distortion (THD) limit applies to every phase."},
+	}
+	got := extractGlossary(7, chunks, []int64{10, 11, 12, 13})
+
+	want := map[string]string{
+		"THD": "Total Harmonic Distortion",
+		"EPP": "equipo de protección personal",
+		"kVA": "kilovolt-ampere",
+		"PoE": "Power over Ethernet",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d entries, want %d: %+v", len(got), len(want), got)
+	}
+	for _, g := range got {
+		if want[g.Abbreviation] != g.Definition {
+			t.Errorf("%s = %q, want %q", g.Abbreviation, g.Definition, want[g.Abbreviation])
+		}
+		if g.DocumentID != 7 || g.ChunkID == 0 {
+			t.Errorf("%s: document %d chunk %d", g.Abbreviation, g.DocumentID, g.ChunkID)
+		}
+	}
+}
+
+func TestLongFormFor(t *testing.T) {
+	tests := []struct {
+		abbrev, long, want string
+	}{
+		{"THD", "we measure the Total Harmonic Distortion", "Total Harmonic Distortion"},
+		{"EPP", "equipo de protección personal", "equipo de protección personal"},
+		{"HVAC", "the heating, ventilation and air conditioning", "heating, ventilation and air conditioning"},
+		{"THD", "the inverter output", ""},
+		{"Hz", "Hertz", "Hertz"},
+	}
+	for _, tt := range tests {
+		if got := longFormFor(tt.abbrev, tt.long); got != tt.want {
+			t.Errorf("longFormFor(%q, %q) = %q, want %q", tt.abbrev, tt.long, got, tt.want)
+		}
+	}
+}
+
+func TestGlossaryFor(t *testing.T) {
+	s, err := store.New(filepath.Join(t.TempDir(), "glossary.db"), 4)
+	if err != nil {
+		t.Fatalf("store.New: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	wiring, _ := s.UpsertDocument(ctx, store.Document{Path: "/wiring.pdf", Filename: "wiring.pdf", Format: "pdf", ContentHash: "a", ParseMethod: "native", Status: "ready"})
+	materials, _ := s.UpsertDocument(ctx, store.Document{Path: "/materials.pdf", Filename: "materials.pdf", Format: "pdf", ContentHash: "b", ParseMethod: "native", Status: "ready"})
+	if err := s.InsertGlossary(ctx, []store.GlossaryEntry{
+		{DocumentID: wiring, Abbreviation: "PE", Definition: "protective earth"},
+		{DocumentID: materials, Abbreviation: "PE", Definition: "polyethylene"},
+		{DocumentID: materials, Abbreviation: "THD", Definition: "Total Harmonic Distortion"},
+	}); err != nil {
+		t.Fatalf("InsertGlossary: %v", err)
+	}
+
+	e := &engine{store: s}
+	results := []store.RetrievalResult{{DocumentID: wiring, Content: "Connect PE to the chassis."}}
+	terms := e.glossaryFor(ctx, "What is the THD limit and where does PE go?", results)
+
+	// THD is only defined in the materials spec; PE takes the retrieved
+	// wiring manual's meaning.
+	if len(terms) != 2 {
+		t.Fatalf("terms = %+v, want 2", terms)
+	}
+	if terms[0].Abbreviation != "THD" || terms[0].Source != "materials.pdf" {
+		t.Errorf("terms[0] = %+v", terms[0])
+	}
+	if terms[1].Abbreviation != "PE" || terms[1].Definition != "protective earth" {
+		t.Errorf("terms[1] = %+v, want PE = protective earth", terms[1])
+	}
+
+	e.cfg.SkipGlossary = true
+	if terms := e.glossaryFor(ctx, "THD?", results); terms != nil {
+		t.Errorf("SkipGlossary: got %+v", terms)
+	}
+}
@@ -7,6 +7,8 @@ require (
 	github.com/ledongthuc/pdf v0.0.0-20250511090121-5959a4027728
 	github.com/mattn/go-sqlite3 v1.14.33
 	github.com/xuri/excelize/v2 v2.10.0
+	github.com/yalue/onnxruntime_go v1.36.0
+	golang.org/x/text v0.30.0
 )
 
 require (
@@ -17,5 +19,4 @@ require (
 	github.com/xuri/nfp v0.0.2-0.20250530014748-2ddeb826f9a9 // indirect
 	golang.org/x/crypto v0.43.0 // indirect
 	golang.org/x/net v0.46.0 // indirect
-	golang.org/x/text v0.30.0 // indirect
 )
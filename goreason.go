@@ -3,22 +3,29 @@ package goreason
 import (
 	"context"
 	"crypto/sha256"
+	"database/sql"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"math"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/bbiangul/go-reason/chunker"
 	"github.com/bbiangul/go-reason/graph"
 	"github.com/bbiangul/go-reason/llm"
 	"github.com/bbiangul/go-reason/parser"
+	"github.com/bbiangul/go-reason/pipeline"
 	"github.com/bbiangul/go-reason/reasoning"
 	"github.com/bbiangul/go-reason/retrieval"
 	"github.com/bbiangul/go-reason/store"
@@ -27,24 +34,208 @@ import (
 // Engine is the main entry point for the Graph RAG engine.
 type Engine interface {
 	// Ingest parses, chunks, embeds, and builds graph for a document.
-	// Returns document ID. Skips if content hash unchanged.
+	// Returns document ID. Skips if content hash unchanged. A .zip, .tar,
+	// or .tar.gz archive is extracted and each supported file in it is
+	// ingested as a document linked to the archive's (see Document.ParentID).
 	Ingest(ctx context.Context, path string, opts ...IngestOption) (int64, error)
 
 	// Query runs a question through hybrid retrieval + multi-round reasoning.
 	Query(ctx context.Context, question string, opts ...QueryOption) (*Answer, error)
 
+	// Retrieve runs a question through hybrid retrieval only, returning
+	// the fused results and search trace without reasoning over them.
+	Retrieve(ctx context.Context, question string, opts ...QueryOption) ([]store.RetrievalResult, *retrieval.SearchTrace, error)
+
+	// CompareQuery runs a question through two query configurations in
+	// parallel and diffs the answers and their sources.
+	CompareQuery(ctx context.Context, question string, a, b []QueryOption) (*QueryComparison, error)
+
+	// SummarizeDocument summarizes a document by map-reduce over its
+	// chunks, section by section: short and long summaries and key points.
+	SummarizeDocument(ctx context.Context, documentID int64, opts ...SummarizeOption) (*Summarization, error)
+
+	// Extract reads records matching a schema out of the corpus, batching
+	// the relevant chunks (or every chunk of named documents) through the
+	// LLM.
+	Extract(ctx context.Context, instruction string, schema ExtractSchema, opts ...ExtractOption) (*Extraction, error)
+
 	// Update re-checks a document by hash. Re-ingests if changed.
 	Update(ctx context.Context, path string) (bool, error)
 
 	// UpdateAll checks all ingested documents for changes.
 	UpdateAll(ctx context.Context) ([]UpdateResult, error)
 
+	// ResumeIngest continues a document's failed or interrupted ingest
+	// from its last checkpoint instead of starting over. An ingest run
+	// with a custom pipeline needs it again (WithPipeline).
+	ResumeIngest(ctx context.Context, documentID int64, opts ...IngestOption) error
+
+	// ReparseCompare parses a document's file with each of methods (every
+	// available method when none are given) and compares the results
+	// without changing the index.
+	ReparseCompare(ctx context.Context, documentID int64, methods ...string) (*ParserComparison, error)
+
+	// CommitReparse re-ingests a document with the parse method picked
+	// from a ReparseCompare, reusing that parse when the file is unchanged.
+	CommitReparse(ctx context.Context, documentID int64, method string) error
+
 	// Delete removes a document and all associated data.
 	Delete(ctx context.Context, documentID int64) error
 
-	// ListDocuments returns all ingested documents.
+	// DeleteSession removes the documents ingested with WithIngestSession
+	// for a session before they expire, and returns how many there were.
+	DeleteSession(ctx context.Context, session string) (int, error)
+
+	// ListDocuments returns all ingested documents except session ones.
 	ListDocuments(ctx context.Context) ([]Document, error)
 
+	// Document returns one ingested document with its quality report,
+	// and the progress of its ingest while that is unfinished.
+	Document(ctx context.Context, documentID int64) (*Document, error)
+
+	// DocumentSummary returns the LLM-generated summary and keywords for a document.
+	DocumentSummary(ctx context.Context, documentID int64) (*DocumentSummary, error)
+
+	// Chunk returns a stored chunk with its document and image list.
+	Chunk(ctx context.Context, chunkID int64) (*ChunkDetail, error)
+
+	// DocumentChunks pages through a document's chunks in document order
+	// with per-chunk stats and the document's heading tree.
+	DocumentChunks(ctx context.Context, documentID int64, offset, limit int) (*DocumentChunks, error)
+
+	// ChunkImage returns image n of a chunk, including its bytes.
+	ChunkImage(ctx context.Context, chunkID int64, n int) (*store.ChunkImage, error)
+
+	// Glossary returns the abbreviations defined in a document, or in all
+	// documents when documentID is 0.
+	Glossary(ctx context.Context, documentID int64) ([]store.GlossaryEntry, error)
+
+	// KeyFacts returns the key facts extracted from documents at ingest
+	// (see Config.KeyFacts) that match filter.
+	KeyFacts(ctx context.Context, filter store.KeyFactFilter) ([]store.KeyFact, error)
+
+	// AnnotateChunk stores a curator annotation (correction, exclusion,
+	// boost, or approved answer) on a chunk and returns its ID.
+	AnnotateChunk(ctx context.Context, a store.ChunkAnnotation) (int64, error)
+
+	// ChunkAnnotations returns curator annotations, limited to one chunk
+	// when chunkID is non-zero.
+	ChunkAnnotations(ctx context.Context, chunkID int64) ([]store.ChunkAnnotation, error)
+
+	// DeleteChunkAnnotation removes a curator annotation.
+	DeleteChunkAnnotation(ctx context.Context, id int64) error
+
+	// SaveQueryTemplate stores a named question with {parameter}
+	// placeholders, replacing the template of the same name.
+	SaveQueryTemplate(ctx context.Context, t QueryTemplate) error
+
+	// QueryTemplates returns the stored query templates in name order.
+	QueryTemplates(ctx context.Context) ([]QueryTemplate, error)
+
+	// DeleteQueryTemplate removes a query template.
+	DeleteQueryTemplate(ctx context.Context, name string) error
+
+	// QueryTemplate fills the named template's placeholders from params
+	// and runs the question through Query.
+	QueryTemplate(ctx context.Context, name string, params map[string]string, opts ...QueryOption) (*Answer, error)
+
+	// RunningQueries lists the queries in progress, oldest first.
+	RunningQueries() []RunningQuery
+
+	// CancelQuery stops the query in progress with the given
+	// RunningQuery.ID and waits for it to return. Its Query call fails
+	// with ErrQueryCancelled; both get the partial answer (Cancelled set),
+	// or the whole answer when the query finished first.
+	CancelQuery(ctx context.Context, id string) (*Answer, error)
+
+	// RecordFeedback stores a rating (-1, 0, or 1) of the answer with the
+	// given Answer.QueryID.
+	RecordFeedback(ctx context.Context, queryID int64, rating int, comment string) error
+
+	// ExplainQuery explains how the answer with the given Answer.QueryID
+	// was produced, from what the query log recorded.
+	ExplainQuery(ctx context.Context, queryID int64) (*QueryExplanation, error)
+
+	// ExperimentResults reports per-arm statistics of the configured
+	// retrieval experiments.
+	ExperimentResults(ctx context.Context) ([]ExperimentResult, error)
+
+	// IngestQueueStats reports running and queued Ingest calls.
+	IngestQueueStats() IngestQueueStats
+
+	// QueryCacheStats reports hits and misses of the query embedding cache.
+	QueryCacheStats() retrieval.EmbeddingCacheStats
+
+	// TranslationCacheStats reports hits, misses, and saved tokens of the
+	// cross-language query translation cache.
+	TranslationCacheStats() retrieval.TranslationCacheStats
+
+	// QueryWorkerStats reports the load of the query worker pool
+	// (Config.QueryWorkers).
+	QueryWorkerStats() QueryWorkerStats
+
+	// EntityStats returns the entities linked to the most documents (50 by
+	// default), marking the stop entities kept out of graph search.
+	EntityStats(ctx context.Context, limit int) ([]store.EntityStat, error)
+
+	// ProviderUsage reports LLM provider calls, errors, tokens, and time
+	// by provider, model, operation (chat, embed, vision, judge), and phase
+	// (ingest, query, other).
+	ProviderUsage() []llm.MeterRecord
+
+	// GraphExtractionStats reports how graph extraction replies were parsed:
+	// repairs, retries, parse failures, and items dropped by validation.
+	GraphExtractionStats() graph.ExtractionStats
+
+	// WriteQueueStats reports contention on the store's single writer:
+	// writes run, time spent queued, and SQLite busy retries and errors.
+	WriteQueueStats() store.WriteQueueStats
+
+	// ImportGraph merges externally curated entities and relationships
+	// into the knowledge graph.
+	ImportGraph(ctx context.Context, g GraphImport) (*GraphImportResult, error)
+
+	// ImportIndex loads a corpus exported from another vector store
+	// (see ReadIndexExport) without parsing it again.
+	ImportIndex(ctx context.Context, imp IndexImport) (*IndexImportResult, error)
+
+	// DetectContradictions finds facts that documents state differently
+	// and records them, so retrieval can flag the chunks involved.
+	DetectContradictions(ctx context.Context) (*ContradictionReport, error)
+
+	// Contradictions returns the contradictions recorded by the last
+	// DetectContradictions.
+	Contradictions(ctx context.Context) ([]store.Contradiction, error)
+
+	// RebuildCommunities re-runs community detection and summarisation over
+	// the whole entity graph, e.g. after bulk ingests with SkipGraph.
+	RebuildCommunities(ctx context.Context, opts ...CommunityOption) (*CommunityRebuild, error)
+
+	// RefreshCommunitySummaries summarises only the communities whose
+	// members changed since their last summary, without re-detection.
+	RefreshCommunitySummaries(ctx context.Context, opts ...CommunityOption) (*CommunityRefresh, error)
+
+	// Compact removes entities and relationships left behind by deleted
+	// documents, rebuilds communities if any were removed, and reclaims
+	// the space of deleted data.
+	Compact(ctx context.Context) (*Compaction, error)
+
+	// Checkpoint copies the SQLite WAL back into the database, coordinated
+	// with the replication tool (see ReplicationConfig).
+	Checkpoint(ctx context.Context) (*Checkpoint, error)
+
+	// ReplicationStatus reports whether the engine is a primary or a
+	// read-only replica, with its replication position and last checkpoint.
+	ReplicationStatus() ReplicationStatus
+
+	// AuditLog returns recorded ingests, updates, deletes, and re-embeds,
+	// newest first.
+	AuditLog(ctx context.Context, f AuditFilter) ([]AuditEntry, error)
+
+	// Reembed re-embeds all chunks with the configured embedding model,
+	// resolving an ErrEmbeddingModelMismatch.
+	Reembed(ctx context.Context) error
+
 	// Store returns the underlying store for diagnostic access (e.g. eval ground-truth checks).
 	Store() *store.Store
 
@@ -62,9 +253,47 @@ type Answer struct {
 	RetrievalTrace   *retrieval.SearchTrace `json:"retrieval_trace,omitempty"`
 	ModelUsed        string                 `json:"model_used"`
 	Rounds           int                    `json:"rounds"`
+	Strategy         string                 `json:"strategy"`
 	PromptTokens     int                    `json:"prompt_tokens"`
 	CompletionTokens int                    `json:"completion_tokens"`
 	TotalTokens      int                    `json:"total_tokens"`
+	// CachedTokens is the part of PromptTokens the chat provider served
+	// from its prompt cache.
+	CachedTokens int `json:"cached_tokens,omitempty"`
+	// Sections is the structured answer, populated only with
+	// WithFormat("json"); Text then holds its JSON encoding.
+	Sections *reasoning.AnswerSections `json:"sections,omitempty"`
+	// Follow-up questions, populated only with WithSuggestedQuestions.
+	SuggestedQuestions []string `json:"suggested_questions,omitempty"`
+	// QueryID identifies the answer in the query log, for RecordFeedback.
+	QueryID int64 `json:"query_id,omitempty"`
+	// Experiment is the experiment arm that served the query, if any.
+	Experiment *ExperimentAssignment `json:"experiment,omitempty"`
+	// Refusal is why the question was refused as outside Config.Scope;
+	// Text then holds the refusal message and there are no sources.
+	Refusal string `json:"refusal,omitempty"`
+	// DeadlineLimited is set when reasoning rounds or follow-up retrieval
+	// were skipped to answer before the context's deadline.
+	DeadlineLimited bool `json:"deadline_limited,omitempty"`
+	// Tier is the model tier that produced the answer, ModelTierPrimary or
+	// ModelTierEscalated, when Config.Escalation is set. EscalationReason
+	// is why the Escalation model was asked, if it was.
+	Tier             string `json:"tier,omitempty"`
+	EscalationReason string `json:"escalation_reason,omitempty"`
+	// Searches counts the follow-up searches the reasoner requested for
+	// missing evidence (Config.ReasoningSearchBudget). Each is a "search"
+	// step in Reasoning.
+	Searches int `json:"searches,omitempty"`
+	// StreamRestarts counts the replies Config.StreamValidation stopped
+	// and asked for again. Each is a "stream_abort" step in Reasoning.
+	StreamRestarts int `json:"stream_restarts,omitempty"`
+	// Attribution is each document's share of the evidence when the
+	// answer draws on several documents.
+	Attribution []DocumentAttribution `json:"attribution,omitempty"`
+	// Cancelled is set on the partial answer of a query stopped with
+	// CancelQuery: it has no Text, only the RetrievalTrace and the
+	// Reasoning steps taken before the query stopped.
+	Cancelled bool `json:"cancelled,omitempty"`
 }
 
 // Source represents a retrieved source chunk backing an answer.
@@ -78,11 +307,38 @@ type Source struct {
 	ChunkType        string            `json:"chunk_type,omitempty"`
 	PageNumber       int               `json:"page_number"`
 	PositionInDoc    int               `json:"position_in_doc,omitempty"`
+	SectionNumber    string            `json:"section_number,omitempty"` // e.g. "7.3.2", from the heading
+	Chapter          string            `json:"chapter,omitempty"`        // heading of the enclosing chapter
 	Score            float64           `json:"score"`
 	ChunkMetadata    map[string]string `json:"chunk_metadata,omitempty"`
 	DocumentMetadata map[string]string `json:"document_metadata,omitempty"`
 	Snippet          string            `json:"snippet,omitempty"`
 	Images           []SourceImage     `json:"images,omitempty"`
+	Span             *SourceSpan       `json:"span,omitempty"`
+	Conflicts        []string          `json:"conflicts,omitempty"` // contradictions with other documents
+
+	// Duplicates are other chunks with the same content, such as
+	// boilerplate repeated across documents, collapsed into this source.
+	Duplicates []DuplicateSource `json:"duplicates,omitempty"`
+}
+
+// DuplicateSource locates a copy of a source's content in another chunk.
+type DuplicateSource struct {
+	ChunkID    int64  `json:"chunk_id"`
+	DocumentID int64  `json:"document_id"`
+	Filename   string `json:"filename"`
+	Path       string `json:"path,omitempty"`
+	PageNumber int    `json:"page_number,omitempty"`
+}
+
+// SourceSpan locates a source chunk in the original document as a byte
+// range of the parser's extracted text: the file itself for plain text, the
+// text of PageNumber for PDFs and slides, and the body text (one line per
+// paragraph) for DOCX. Viewers can use it to scroll to and highlight the
+// cited passage.
+type SourceSpan struct {
+	StartOffset int `json:"start_offset"`
+	EndOffset   int `json:"end_offset"`
 }
 
 // SourceImage represents an image associated with a source chunk.
@@ -121,6 +377,15 @@ type Document struct {
 	ParseMethod string            `json:"parse_method"`
 	Status      string            `json:"status"`
 	Metadata    map[string]string `json:"metadata,omitempty"`
+	Summary     string            `json:"summary,omitempty"`
+	Keywords    []string          `json:"keywords,omitempty"`
+	Collection  string            `json:"collection,omitempty"`
+	Importance  float64           `json:"importance"`
+	Quality     *QualityReport    `json:"quality,omitempty"`
+	Ingest      *IngestProgress   `json:"ingest,omitempty"`    // set while an ingest is unfinished
+	ParentID    int64             `json:"parent_id,omitempty"` // archive the document was extracted from
+	SessionID   string            `json:"session_id,omitempty"`
+	ExpiresAt   string            `json:"expires_at,omitempty"` // session documents only
 	CreatedAt   string            `json:"created_at"`
 	UpdatedAt   string            `json:"updated_at"`
 }
@@ -130,7 +395,9 @@ type UpdateResult struct {
 	DocumentID int64  `json:"document_id"`
 	Path       string `json:"path"`
 	Changed    bool   `json:"changed"`
-	Error      error  `json:"error,omitempty"`
+	// Changes reports what the re-ingest of a changed document changed.
+	Changes *ChangeReport `json:"changes,omitempty"`
+	Error   error         `json:"error,omitempty"`
 }
 
 // IngestOption configures ingestion behavior.
@@ -138,8 +405,18 @@ type IngestOption func(*ingestOptions)
 
 type ingestOptions struct {
 	forceReparse bool
+	incremental  bool // keep unchanged chunks (Update)
 	parseMethod  string
+	parsed       *parser.ParseResult // parse to store instead of parsing (CommitReparse)
 	metadata     map[string]string
+	collection   string
+	chunkFilters []ChunkFilter
+	pipeline     *pipeline.Pipeline // WithPipeline
+	selection    ingestSelection    // pages and sections to keep
+	importance   float64            // 0 keeps the document's current importance
+	member       string             // document path when the file is an extracted archive member
+	parentID     int64              // the archive document of a member
+	session      string             // WithIngestSession
 }
 
 // WithForceReparse forces re-parsing even if the hash hasn't changed.
@@ -147,7 +424,8 @@ func WithForceReparse() IngestOption {
 	return func(o *ingestOptions) { o.forceReparse = true }
 }
 
-// WithParseMethod overrides the automatic parse method selection.
+// WithParseMethod overrides the automatic parse method selection:
+// "native", "llamaparse", or "vision" (see ReparseCompare).
 func WithParseMethod(method string) IngestOption {
 	return func(o *ingestOptions) { o.parseMethod = method }
 }
@@ -157,17 +435,40 @@ func WithMetadata(metadata map[string]string) IngestOption {
 	return func(o *ingestOptions) { o.metadata = metadata }
 }
 
+// WithImportance sets how authoritative the document is: its chunks' fused
+// retrieval scores are multiplied by importance, so with 2.0 for the
+// official manual and 0.5 for meeting notes the manual wins when the two
+// disagree. The default is 1; importance must be positive. Re-ingesting
+// without it keeps the document's current importance.
+func WithImportance(importance float64) IngestOption {
+	return func(o *ingestOptions) { o.importance = importance }
+}
+
 // QueryOption configures query behavior.
 type QueryOption func(*queryOptions)
 
 type queryOptions struct {
 	maxResults    int
 	maxRounds     int
+	strategy      string
 	weightVec     float64
 	weightFTS     float64
 	weightGraph   float64
 	jsonOutput    bool
 	includeImages bool
+	suggest       bool
+	answerLang    string
+	format        string
+	docOrder      string
+	skipGraph     bool
+	space         string
+	checks        []ValidationCheckConfig
+	collection    string
+	instructions  string // from the collection preset
+	experiment    *ExperimentAssignment
+	session       string
+
+	numericFilters []NumericFilter
 }
 
 // WithMaxResults sets the maximum number of chunks to retrieve.
@@ -180,6 +481,12 @@ func WithMaxRounds(n int) QueryOption {
 	return func(o *queryOptions) { o.maxRounds = n }
 }
 
+// WithStrategy overrides the reasoning strategy for this query: "multi_round",
+// "single_shot", "react", or "plan_execute".
+func WithStrategy(strategy string) QueryOption {
+	return func(o *queryOptions) { o.strategy = strategy }
+}
+
 // WithJSONOutput enables structured JSON output mode. When enabled, the
 // answer is post-processed into {"found": true/false, "response": "..."}.
 // The Found field on Answer is set accordingly, and Text holds the response.
@@ -194,6 +501,52 @@ func WithIncludeImages() QueryOption {
 	return func(o *queryOptions) { o.includeImages = true }
 }
 
+// WithSuggestedQuestions generates 3-5 follow-up questions grounded in the
+// retrieved sources and their entity neighbourhood, returned on
+// Answer.SuggestedQuestions. Costs one extra LLM call.
+func WithSuggestedQuestions() QueryOption {
+	return func(o *queryOptions) { o.suggest = true }
+}
+
+// WithAnswerLanguage fixes the language of the answer: an ISO 639-1 code
+// such as "en" or "es", or "auto" to answer in the language of the question.
+// Useful when the corpus and the question are in different languages, where
+// the model otherwise tends to mix the two.
+func WithAnswerLanguage(lang string) QueryOption {
+	return func(o *queryOptions) { o.answerLang = lang }
+}
+
+// WithFormat sets the layout of Answer.Text: "markdown" (headings, lists,
+// and emphasis), "plain" (prose without markup), or "json" (an object with
+// summary, details, caveats, and citations, also parsed into
+// Answer.Sections). The format is requested in the reasoning prompt and
+// checked before the answer is returned; non-compliant answers are
+// repaired. "json" cannot be combined with WithJSONOutput.
+func WithFormat(format string) QueryOption {
+	return func(o *queryOptions) { o.format = format }
+}
+
+// WithDocumentOrder re-orders the retrieved chunks by their position in the
+// source documents before reasoning, keeping each section's chunks together,
+// so step sequences are read in order. mode is "auto" (procedural questions
+// such as "what are the steps to..." only) or "always".
+func WithDocumentOrder(mode string) QueryOption {
+	return func(o *queryOptions) { o.docOrder = mode }
+}
+
+// WithoutGraph turns the graph retrieval leg off for this query, e.g. to
+// measure what the knowledge graph contributes.
+func WithoutGraph() QueryOption {
+	return func(o *queryOptions) { o.skipGraph = true }
+}
+
+// WithEmbeddingSpace selects the dense vector legs when a secondary
+// embedding model is configured: "primary", "secondary", or "both" (the
+// default, fusing the two).
+func WithEmbeddingSpace(space string) QueryOption {
+	return func(o *queryOptions) { o.space = space }
+}
+
 // WithWeights overrides the retrieval weights for this query.
 func WithWeights(vec, fts, graph float64) QueryOption {
 	return func(o *queryOptions) {
@@ -210,11 +563,97 @@ type engine struct {
 	chatLLM   llm.Provider
 	embedLLM  llm.Provider
 	visionLLM llm.Provider
+	sparseLLM llm.SparseEmbedder
+	imageLLM  llm.MultimodalEmbedder
 	parsers   *parser.Registry
 	chunkr    *chunker.Chunker
 	graphB    *graph.Builder
 	retriever *retrieval.Engine
 	reasoner  *reasoning.Engine
+	ingestQ   *ingestQueue
+	// ingestGate is held shared by running ingests and exclusively by
+	// the maintenance that rewrites what they write, such as Compact.
+	ingestGate sync.RWMutex
+
+	// graphProgress holds the graph build report so far of each document
+	// whose graph stage is running (document ID -> graph.BuildReport).
+	graphProgress sync.Map
+
+	// escalator reasons with Config.Escalation; nil without one.
+	escalator *reasoning.Engine
+
+	// collChunkers holds the chunkers of collections that override the
+	// chunk size; other collections use chunkr.
+	collChunkers map[string]*chunker.Chunker
+
+	// checks are the compiled Config.ValidationChecks.
+	checks []reasoning.ValidationCheck
+
+	// typeBoosts are the retrieval boosts compiled from Config.ChunkTypes.
+	typeBoosts map[string]float64
+
+	// secondaryLLM embeds chunks into the secondary vector space; nil when
+	// Config.SecondaryEmbedding is unset.
+	secondaryLLM llm.Provider
+
+	// communityMu serialises community rebuilds, which replace the whole
+	// communities table.
+	communityMu sync.Mutex
+
+	// embedDrift is set when the stored vectors came from a different
+	// embedding model; Query and Ingest return it until Reembed succeeds.
+	driftMu    sync.RWMutex
+	embedDrift error
+
+	// reparses keeps the parses of recent ReparseCompare calls for
+	// CommitReparse.
+	reparses reparseCache
+
+	// stopMirror stops the analytics mirror and waits for it; nil when
+	// Config.Analytics is unset.
+	stopMirror func()
+
+	// stopCommunityRefresh stops the scheduled community summary refresh;
+	// nil when Config.CommunitySummaryIntervalMinutes is 0.
+	stopCommunityRefresh func()
+
+	// stopSessionExpiry stops the removal of expired session documents;
+	// nil on a read-only engine.
+	stopSessionExpiry func()
+
+	// stopSecondaryBackfill stops the startup backfill of secondary
+	// vectors; nil without a secondary model or on a read-only engine.
+	stopSecondaryBackfill func()
+
+	// scope refuses out-of-scope questions; nil when Config.Scope is unset.
+	scope *scopeGuard
+
+	// workers runs queries when Config.QueryWorkers is set; nil otherwise.
+	// primary is set on the worker engines themselves and points back to
+	// the engine that owns the writable store.
+	workers *queryPool
+	primary *engine
+
+	// querySink receives the query log while the engine is read-only
+	// (Config.QueryLogFile); nil without one.
+	querySink *queryLogSink
+
+	// queryLog holds the query log, feedback, chunk usage, and audit
+	// trail. It is the writable store, which worker engines share with
+	// their primary.
+	queryLog store.QueryLogStore
+
+	// readOnly is set when the store was opened with store.OpenReader
+	// (Config.ReadOnly or a LiteFS replica); repl is nil without
+	// Config.Replication.
+	readOnly bool
+	repl     *replicator
+
+	// meter counts the calls of every LLM provider (ProviderUsage).
+	meter *llm.Meter
+
+	// running tracks the queries in progress, for CancelQuery.
+	running runningQueries
 }
 
 // New creates a new GoReason engine with the given configuration.
@@ -227,34 +666,106 @@ func New(cfg Config) (Engine, error) {
 		cfg.EmbeddingDim = 768
 	}
 
-	// Open store
-	s, err := store.New(dbPath, cfg.EmbeddingDim)
+	typeRules, typeBoosts, typeTreatments, err := compileChunkTypes(cfg.ChunkTypes)
+	if err != nil {
+		return nil, err
+	}
+	checks, err := compileValidationChecks(cfg.ValidationChecks)
+	if err != nil {
+		return nil, err
+	}
+	tokenizers, err := loadChunkTokenizers(cfg.ChunkTokenizers)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateConfig(cfg); err != nil {
+		return nil, err
+	}
+	cipher, err := contentCipher(cfg.EncryptionKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	// Open store. A LiteFS replica cannot write, so it opens read-only
+	// like an explicitly read-only engine.
+	repl := newReplicator(cfg.Replication, dbPath)
+	if host, ok := repl.liteFSPrimary(); ok && !cfg.ReadOnly {
+		slog.Info("replication: LiteFS replica, opening read-only", "primary", host)
+		cfg.ReadOnly = true
+	}
+	if cfg.QueryLogFile != "" && !cfg.ReadOnly {
+		return nil, fmt.Errorf("%w: query_log_file requires read_only (a writable engine logs queries to the database)", ErrInvalidConfig)
+	}
+	var s *store.Store
+	if cfg.ReadOnly {
+		if _, err := os.Stat(dbPath); err != nil {
+			return nil, fmt.Errorf("%w: read_only needs an existing database: %v", ErrInvalidConfig, err)
+		}
+		// An immutable database ignores its WAL, so refuse one that still
+		// has writes there rather than serve stale data.
+		if fi, err := os.Stat(dbPath + "-wal"); cfg.Immutable && err == nil && fi.Size() > 0 {
+			return nil, fmt.Errorf("%w: immutable database %s has writes in its WAL; checkpoint it or close its writer first", ErrInvalidConfig, dbPath)
+		}
+		s, err = store.OpenReaderWithOptions(dbPath, cfg.EmbeddingDim, store.ReaderOptions{Immutable: cfg.Immutable})
+	} else {
+		s, err = store.NewWithOptions(dbPath, cfg.EmbeddingDim, store.Options{
+			ManualCheckpoint: cfg.Replication != nil && cfg.Replication.Mode == ReplicationLitestream,
+		})
+	}
 	if err != nil {
 		return nil, fmt.Errorf("opening store: %w", err)
 	}
+	s.SetContentCipher(cipher)
+	s.SetSearchReadyOnly(!cfg.SearchUnreadyDocuments)
+	if cipher != nil && !cfg.ReadOnly {
+		if n, err := s.IndexSealedChunks(context.Background()); err != nil {
+			s.Close()
+			return nil, fmt.Errorf("indexing encrypted chunks: %w", err)
+		} else if n > 0 {
+			slog.Info("encryption: indexed encrypted chunks for keyword search", "chunks", n)
+		}
+	}
+	if !cfg.ReadOnly {
+		n, err := s.BackfillChunkQuantities(context.Background(), func(c store.Chunk) []store.ChunkQuantity {
+			return extractQuantities(c.DocumentID, []store.Chunk{c}, []int64{c.ID})
+		})
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("extracting numeric values: %w", err)
+		}
+		if n > 0 {
+			slog.Info("numeric: extracted values of chunks ingested before range queries", "chunks", n)
+		}
+	}
 
-	// Create LLM providers
+	// Create LLM providers. Each is metered for ProviderUsage.
+	meter := llm.NewMeter()
 	chatLLM, err := llm.NewProvider(llm.Config{
-		Provider: cfg.Chat.Provider,
-		Model:    cfg.Chat.Model,
-		BaseURL:  cfg.Chat.BaseURL,
-		APIKey:   cfg.Chat.APIKey,
+		Provider:     cfg.Chat.Provider,
+		Model:        cfg.Chat.Model,
+		BaseURL:      cfg.Chat.BaseURL,
+		APIKey:       cfg.Chat.APIKey,
+		CacheControl: cfg.Chat.CacheControl,
+		PromptStyle:  cfg.Chat.PromptStyle,
 	})
 	if err != nil {
 		s.Close()
 		return nil, fmt.Errorf("creating chat provider: %w", err)
 	}
+	chatLLM = llm.Metered(chatLLM, cfg.Chat.Provider, cfg.Chat.Model, meter)
 
 	embedLLM, err := llm.NewProvider(llm.Config{
-		Provider: cfg.Embedding.Provider,
-		Model:    cfg.Embedding.Model,
-		BaseURL:  cfg.Embedding.BaseURL,
-		APIKey:   cfg.Embedding.APIKey,
+		Provider:    cfg.Embedding.Provider,
+		Model:       cfg.Embedding.Model,
+		BaseURL:     cfg.Embedding.BaseURL,
+		APIKey:      cfg.Embedding.APIKey,
+		ONNXLibrary: cfg.Embedding.ONNXLibrary,
 	})
 	if err != nil {
 		s.Close()
 		return nil, fmt.Errorf("creating embedding provider: %w", err)
 	}
+	embedLLM = llm.Metered(embedLLM, cfg.Embedding.Provider, cfg.Embedding.Model, meter)
 
 	var visionLLM llm.Provider
 	if cfg.Vision.Provider != "" {
@@ -268,6 +779,74 @@ func New(cfg Config) (Engine, error) {
 			s.Close()
 			return nil, fmt.Errorf("creating vision provider: %w", err)
 		}
+		visionLLM = llm.Metered(visionLLM, cfg.Vision.Provider, cfg.Vision.Model, meter)
+	}
+
+	var sparseLLM llm.SparseEmbedder
+	if cfg.Sparse.Provider != "" {
+		sparseLLM, err = llm.NewSparseEmbedder(llm.Config{
+			Provider: cfg.Sparse.Provider,
+			Model:    cfg.Sparse.Model,
+			BaseURL:  cfg.Sparse.BaseURL,
+			APIKey:   cfg.Sparse.APIKey,
+		})
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("creating sparse embedding provider: %w", err)
+		}
+		sparseLLM = llm.MeteredSparse(sparseLLM, cfg.Sparse.Provider, cfg.Sparse.Model, meter)
+	}
+
+	var imageLLM llm.MultimodalEmbedder
+	if cfg.ImageEmbedding.Provider != "" {
+		imageLLM, err = llm.NewMultimodalEmbedder(llm.Config{
+			Provider: cfg.ImageEmbedding.Provider,
+			Model:    cfg.ImageEmbedding.Model,
+			BaseURL:  cfg.ImageEmbedding.BaseURL,
+			APIKey:   cfg.ImageEmbedding.APIKey,
+		})
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("creating image embedding provider: %w", err)
+		}
+		imageLLM = llm.MeteredMultimodal(imageLLM, cfg.ImageEmbedding.Provider, cfg.ImageEmbedding.Model, meter)
+		dim := cfg.ImageEmbeddingDim
+		if dim == 0 {
+			dim = 1024
+		}
+		// A read-only engine uses the vec_images table the primary made.
+		if !cfg.ReadOnly {
+			if err := s.EnableImageVectors(context.Background(), dim); err != nil {
+				s.Close()
+				return nil, err
+			}
+		}
+	}
+
+	var secondaryLLM llm.Provider
+	if cfg.SecondaryEmbedding.Provider != "" {
+		secondaryLLM, err = llm.NewProvider(llm.Config{
+			Provider:    cfg.SecondaryEmbedding.Provider,
+			Model:       cfg.SecondaryEmbedding.Model,
+			BaseURL:     cfg.SecondaryEmbedding.BaseURL,
+			APIKey:      cfg.SecondaryEmbedding.APIKey,
+			ONNXLibrary: cfg.SecondaryEmbedding.ONNXLibrary,
+		})
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("creating secondary embedding provider: %w", err)
+		}
+		secondaryLLM = llm.Metered(secondaryLLM, cfg.SecondaryEmbedding.Provider, cfg.SecondaryEmbedding.Model, meter)
+		if cfg.WeightSecondary == 0 {
+			cfg.WeightSecondary = 1.0
+		}
+		// Likewise the primary checks and records the secondary model.
+		if !cfg.ReadOnly {
+			if err := openSecondaryVectors(context.Background(), s, cfg); err != nil {
+				s.Close()
+				return nil, err
+			}
+		}
 	}
 
 	// Create parser registry
@@ -278,54 +857,265 @@ func New(cfg Config) (Engine, error) {
 			BaseURL: cfg.LlamaParse.BaseURL,
 		})
 	}
+	if vp, ok := visionLLM.(llm.VisionProvider); ok {
+		reg.SetVision(vp)
+	}
 
 	// Create chunker
 	chunkr := chunker.New(chunker.Config{
-		MaxTokens: cfg.MaxChunkTokens,
-		Overlap:   cfg.ChunkOverlap,
+		MaxTokens:  cfg.MaxChunkTokens,
+		Overlap:    cfg.ChunkOverlap,
+		Types:      typeRules,
+		Tokenizers: tokenizers,
 	})
+	collChunkers, err := compileCollections(cfg, typeRules, tokenizers)
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
 
 	// Create graph builder
 	graphB := graph.NewBuilder(s, chatLLM, embedLLM, cfg.GraphConcurrency)
-
-	// Create retrieval engine (chatLLM enables cross-language query translation)
-	retriever := retrieval.New(s, embedLLM, chatLLM, retrieval.Config{
-		WeightVector: cfg.WeightVector,
-		WeightFTS:    cfg.WeightFTS,
-		WeightGraph:  cfg.WeightGraph,
-	})
+	graphB.SetChunkTypes(cfg.GraphChunkTypes, cfg.GraphSkipChunkTypes)
 
 	// Create reasoning engine
-	reasoner := reasoning.New(chatLLM, reasoning.Config{
+	reasonCfg := reasoning.Config{
 		MaxRounds:           cfg.MaxRounds,
 		ConfidenceThreshold: cfg.ConfidenceThreshold,
-	})
+		Strategy:            cfg.ReasoningStrategy,
+		CallEstimate:        time.Duration(cfg.LLMCallEstimateMs) * time.Millisecond,
+		PromptStyle:         chatPromptStyle(cfg.Chat),
+		Context: reasoning.ContextPolicy{
+			MaxChunks:          cfg.ContextMaxChunks,
+			MaxChunksPerDoc:    cfg.ContextMaxChunksPerDoc,
+			MaxTokensPerSource: cfg.ContextMaxTokensPerSource,
+			MinDocuments:       cfg.ContextMinDocuments,
+		},
+		ChunkTypes:   typeTreatments,
+		QuoteSources: cfg.InjectionPolicy == InjectionFlag || cfg.InjectionPolicy == InjectionDrop,
+	}
+	if cfg.StreamValidation {
+		reasonCfg.StreamChecks = []reasoning.StreamCheck{reasoning.CheckUnsupportedNumbers}
+	}
+	reasoner := reasoning.New(chatLLM, reasonCfg)
+
+	// The escalation model reasons the same way with its own prompt style.
+	var escalator *reasoning.Engine
+	if cfg.Escalation.Provider != "" {
+		escalationLLM, err := llm.NewProvider(llm.Config{
+			Provider:     cfg.Escalation.Provider,
+			Model:        cfg.Escalation.Model,
+			BaseURL:      cfg.Escalation.BaseURL,
+			APIKey:       cfg.Escalation.APIKey,
+			CacheControl: cfg.Escalation.CacheControl,
+			PromptStyle:  cfg.Escalation.PromptStyle,
+		})
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("creating escalation provider: %w", err)
+		}
+		escalationLLM = llm.Metered(escalationLLM, cfg.Escalation.Provider, cfg.Escalation.Model, meter)
+		reasonCfg.PromptStyle = chatPromptStyle(cfg.Escalation)
+		escalator = reasoning.New(escalationLLM, reasonCfg)
+	}
+
+	querySink, err := openQueryLogSink(cfg.QueryLogFile)
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
 
-	return &engine{
+	e := &engine{
 		cfg:       cfg,
 		store:     s,
 		chatLLM:   chatLLM,
 		embedLLM:  embedLLM,
 		visionLLM: visionLLM,
+		sparseLLM: sparseLLM,
+		imageLLM:  imageLLM,
 		parsers:   reg,
 		chunkr:    chunkr,
 		graphB:    graphB,
-		retriever: retriever,
 		reasoner:  reasoner,
-	}, nil
+		escalator: escalator,
+		ingestQ:   newIngestQueue(cfg.IngestConcurrency, cfg.IngestQueueSize),
+
+		collChunkers: collChunkers,
+		checks:       checks,
+		typeBoosts:   typeBoosts,
+		secondaryLLM: secondaryLLM,
+		scope:        newScopeGuard(cfg.Scope, chatLLM, embedLLM),
+		querySink:    querySink,
+		queryLog:     s,
+		readOnly:     cfg.ReadOnly,
+		repl:         repl,
+		meter:        meter,
+	}
+	e.retriever = e.newRetriever()
+
+	// Refuse to mix vectors from different embedding models.
+	ctx := context.Background()
+	check := checkEmbeddingModel
+	if cfg.ReadOnly {
+		check = verifyEmbeddingModel
+	}
+	if err := check(ctx, s, configuredEmbeddingModel(cfg)); err != nil {
+		if !errors.Is(err, ErrEmbeddingModelMismatch) {
+			querySink.close()
+			s.Close()
+			return nil, err
+		}
+		if cfg.EmbeddingDriftPolicy != EmbeddingDriftReembed || cfg.ReadOnly {
+			slog.ErrorContext(ctx, "embedding model drift: queries and ingests will fail until Reembed is called", "error", err)
+			e.embedDrift = err
+		} else {
+			slog.WarnContext(ctx, "embedding model drift: re-embedding corpus", "error", err)
+			if err := e.Reembed(ctx); err != nil {
+				querySink.close()
+				s.Close()
+				return nil, fmt.Errorf("re-embedding after model change: %w", err)
+			}
+		}
+	}
+
+	// Apply the configured stop entity lists to the existing graph. A
+	// read-only engine uses the primary's.
+	if !cfg.ReadOnly {
+		e.refreshStopEntities(ctx)
+	}
+
+	if cfg.QueryWorkers > 0 {
+		e.workers, err = e.startQueryWorkers(dbPath, cfg.QueryWorkers)
+		if err != nil {
+			querySink.close()
+			s.Close()
+			return nil, err
+		}
+	}
+
+	if cfg.Analytics != nil && cfg.Analytics.Dir != "" {
+		e.startMirror(*cfg.Analytics)
+	}
+
+	if cfg.CommunitySummaryIntervalMinutes > 0 && !cfg.ReadOnly {
+		e.startCommunityRefresh(time.Duration(cfg.CommunitySummaryIntervalMinutes) * time.Minute)
+	}
+
+	if !cfg.ReadOnly {
+		e.startSessionExpiry(time.Minute)
+	}
+
+	if secondaryLLM != nil && !cfg.ReadOnly {
+		e.startSecondaryBackfill()
+	}
+
+	if rc := cfg.Replication; rc != nil && rc.CheckpointIntervalSeconds > 0 && !cfg.ReadOnly {
+		e.startCheckpoints(time.Duration(rc.CheckpointIntervalSeconds) * time.Second)
+	}
+
+	return e, nil
+}
+
+// chatPromptStyle returns the prompt style of the chat model c: its
+// configured PromptStyle, or the one named by its model.
+func chatPromptStyle(c LLMConfig) string {
+	if c.PromptStyle != "" {
+		return c.PromptStyle
+	}
+	return llm.PromptStyleFor(c.Model)
+}
+
+// newRetriever creates a retrieval engine over e.store (chatLLM enables
+// cross-language query translation).
+func (e *engine) newRetriever() *retrieval.Engine {
+	r := retrieval.New(e.store, e.embedLLM, e.chatLLM, retrieval.Config{
+		WeightVector:    e.cfg.WeightVector,
+		WeightFTS:       e.cfg.WeightFTS,
+		WeightGraph:     e.cfg.WeightGraph,
+		WeightSparse:    e.cfg.WeightSparse,
+		WeightImage:     e.cfg.WeightImage,
+		WeightSecondary: e.cfg.WeightSecondary,
+		WeightNumeric:   e.cfg.WeightNumeric,
+		TypeBoosts:      e.typeBoosts,
+		GraphMaxDepth:   e.cfg.GraphMaxDepth,
+		QueryCacheSize:  e.cfg.QueryCacheSize,
+		EmbeddingModel:  e.cfg.Embedding.Model,
+
+		FTSHeadingWeight:     e.cfg.FTSHeadingWeight,
+		EntitySeedSimilarity: e.cfg.GraphSeedSimilarity,
+		LegTimeout:           time.Duration(e.cfg.RetrievalLegTimeoutMs) * time.Millisecond,
+		TranslationTimeout:   time.Duration(e.cfg.TranslationTimeoutMs) * time.Millisecond,
+		KeepDuplicates:       e.cfg.KeepDuplicateChunks,
+		UsageBoost:           e.cfg.UsageBoost,
+		UsageHalfLife:        e.usageHalfLife(),
+		ScoreAdjusters:       e.cfg.ScoreAdjusters,
+
+		SecondaryEmbeddingModel: e.cfg.SecondaryEmbedding.Model,
+	})
+	if e.sparseLLM != nil {
+		r.SetSparseEmbedder(e.sparseLLM)
+	}
+	if e.imageLLM != nil {
+		r.SetImageEmbedder(e.imageLLM)
+	}
+	if e.secondaryLLM != nil {
+		r.SetSecondaryEmbedder(e.secondaryLLM)
+	}
+	return r
 }
 
 // Ingest processes a document through the full pipeline.
 func (e *engine) Ingest(ctx context.Context, path string, opts ...IngestOption) (int64, error) {
+	if err := e.writable(); err != nil {
+		return 0, err
+	}
 	options := &ingestOptions{}
 	for _, o := range opts {
 		o(options)
 	}
 
+	start := time.Now()
+	docID, err := e.ingest(ctx, path, options)
+	e.audit(ctx, AuditIngest, start, docID, options.auditParams(path), err)
+	return docID, err
+}
+
+// ingest runs the pipeline behind Ingest, Update, and UpdateAll.
+func (e *engine) ingest(ctx context.Context, path string, options *ingestOptions) (int64, error) {
+	ctx = llm.WithPhase(ctx, llm.PhaseIngest)
+	if format, ok := archiveFormat(path); ok {
+		return e.ingestArchive(ctx, path, format, options)
+	}
+
+	if err := e.embeddingDrift(); err != nil {
+		return 0, err
+	}
+
+	release, err := e.acquireIngest(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
 	absPath, err := filepath.Abs(path)
 	if err != nil {
 		return 0, fmt.Errorf("resolving path: %w", err)
 	}
+	// An archive member is recorded under its path within the archive.
+	docPath := absPath
+	if options.member != "" {
+		docPath = options.member
+	}
+	// A session document is recorded apart from the same file ingested
+	// into the shared corpus or by another session; an archive member's
+	// path has its archive's prefix already.
+	var expiresAt string
+	if options.session != "" {
+		if options.member == "" {
+			docPath = sessionPath(options.session, docPath)
+		}
+		expiresAt = store.SessionExpiry(time.Now().Add(e.sessionTTL()))
+	}
 
 	// Compute file hash
 	hash, err := fileHash(absPath)
@@ -333,103 +1123,273 @@ func (e *engine) Ingest(ctx context.Context, path string, opts ...IngestOption)
 		return 0, fmt.Errorf("hashing file: %w", err)
 	}
 
+	// An explicit page or section selection replaces the recorded one;
+	// without one, the selection recorded in the metadata (passed back by
+	// Update and CommitReparse) applies.
+	selection := options.selection
+	if selection.empty() {
+		if selection, err = selectionFromMetadata(options.metadata); err != nil {
+			return 0, err
+		}
+	} else if err := selection.validate(); err != nil {
+		return 0, err
+	}
+	metadata := selection.record(options.metadata)
+	if options.importance < 0 || math.IsNaN(options.importance) || math.IsInf(options.importance, 0) {
+		return 0, fmt.Errorf("%w: importance must be positive, got %v", ErrInvalidConfig, options.importance)
+	}
+
 	// Check if document already exists with same hash
-	if !options.forceReparse {
-		existing, err := e.store.GetDocumentByPath(ctx, absPath)
-		if err == nil && existing.ContentHash == hash {
+	collection := options.collection
+	existing, err := e.store.GetDocumentByPath(ctx, docPath)
+	if err == nil {
+		// A new selection needs the document parsed again.
+		reselect := !options.selection.empty() && !sameSelection(existing.Metadata, metadata)
+		if !options.forceReparse && !reselect && existing.ContentHash == hash {
+			if collection != "" && collection != existing.Collection {
+				if err := e.store.SetDocumentCollection(ctx, existing.ID, collection); err != nil {
+					return 0, fmt.Errorf("setting collection: %w", err)
+				}
+			}
+			if options.importance > 0 && options.importance != existing.Importance {
+				if err := e.store.SetDocumentImportance(ctx, existing.ID, options.importance); err != nil {
+					return 0, fmt.Errorf("setting importance: %w", err)
+				}
+			}
 			return existing.ID, nil // no change
 		}
+		if collection == "" {
+			collection = existing.Collection
+		}
 	}
 
 	// Determine format
-	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(absPath), "."))
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(docPath), "."))
 	format := ext
 
 	// Serialize metadata if present
 	var metadataJSON string
-	if options.metadata != nil {
-		data, _ := json.Marshal(options.metadata)
+	if metadata != nil {
+		data, _ := json.Marshal(metadata)
 		metadataJSON = string(data)
 	}
 
 	// Set status to processing
-	filename := filepath.Base(absPath)
+	filename := filepath.Base(docPath)
 	docID, err := e.store.UpsertDocument(ctx, store.Document{
-		Path:        absPath,
+		Path:        docPath,
 		Filename:    filename,
 		Format:      format,
 		ContentHash: hash,
 		ParseMethod: "pending",
 		Status:      "processing",
 		Metadata:    metadataJSON,
+		Collection:  collection,
+		Importance:  options.importance,
+		ParentID:    options.parentID,
+		SessionID:   options.session,
+		ExpiresAt:   expiresAt,
 	})
 	if err != nil {
 		return 0, fmt.Errorf("upserting document: %w", err)
 	}
+	// After an update UpsertDocument may return the connection's last
+	// inserted row rather than the document's.
+	if existing != nil {
+		docID = existing.ID
+	}
 
-	// Parse
-	parseMethod := options.parseMethod
-	if parseMethod == "" {
-		parseMethod = "native"
+	// The pipeline's stages share the document as it takes shape. A custom
+	// stage may run before Parse and supply the parse result itself.
+	p := options.pipeline
+	if p == nil {
+		p = e.cfg.Pipeline
+	}
+	doc := &pipeline.Document{
+		ID:         docID,
+		Path:       docPath,
+		Filename:   filename,
+		Format:     format,
+		Collection: collection,
+		Metadata:   metadata,
+		Parsed:     options.parsed,
+	}
+	fail := func(err error) (int64, error) {
+		e.store.UpdateDocumentStatus(ctx, docID, "error")
+		return 0, err
+	}
+	if err := runStages(ctx, p.First(), doc); err != nil {
+		return fail(err)
 	}
 
-	slog.Info("ingest: parsing document", "file", filename, "format", format, "doc_id", docID)
+	// Parse
 	parseStart := time.Now()
+	if !p.Skips(pipeline.Parse) {
+		if doc.Parsed == nil {
+			slog.InfoContext(ctx, "ingest: parsing document", "file", filename, "format", format, "doc_id", docID)
+			pr, err := e.parsers.ForMethod(format, options.parseMethod)
+			if err != nil {
+				if options.parseMethod != "" {
+					return fail(fmt.Errorf("%w: %v", ErrParseMethodUnavailable, err))
+				}
+				return fail(fmt.Errorf("%w: %s", ErrUnsupportedFormat, format))
+			}
+			doc.Parsed, err = pr.Parse(ctx, absPath)
+			if err != nil {
+				return fail(fmt.Errorf("%w: %v", ErrParsingFailed, err))
+			}
+			slog.InfoContext(ctx, "ingest: parsing complete",
+				"file", filename, "method", doc.Parsed.Method,
+				"sections", len(doc.Parsed.Sections), "elapsed", time.Since(parseStart).Round(time.Millisecond))
+		}
 
-	p, err := e.parsers.Get(format)
-	if err != nil {
-		e.store.UpdateDocumentStatus(ctx, docID, "error")
-		return 0, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+		if !selection.empty() {
+			before := len(doc.Parsed.Sections)
+			doc.Parsed = selection.apply(doc.Parsed)
+			if len(doc.Parsed.Sections) == 0 {
+				return fail(fmt.Errorf("%w: %s", ErrEmptySelection, filename))
+			}
+			slog.InfoContext(ctx, "ingest: selection applied",
+				"file", filename, "sections_before", before, "sections", len(doc.Parsed.Sections))
+		}
 	}
-
-	parsed, err := p.Parse(ctx, absPath)
-	if err != nil {
-		e.store.UpdateDocumentStatus(ctx, docID, "error")
-		return 0, fmt.Errorf("%w: %v", ErrParsingFailed, err)
+	if err := runStages(ctx, p.After(pipeline.Parse), doc); err != nil {
+		return fail(err)
 	}
-	parseMethod = parsed.Method
-
-	slog.Info("ingest: parsing complete",
-		"file", filename, "method", parseMethod,
-		"sections", len(parsed.Sections), "elapsed", time.Since(parseStart).Round(time.Millisecond))
+	if doc.Parsed == nil {
+		return fail(fmt.Errorf("%w: no pipeline stage parsed %s", ErrParsingFailed, filename))
+	}
+	parsed := doc.Parsed
 
 	// Update parse method
-	e.store.UpdateDocumentParseMethod(ctx, docID, parseMethod)
-
-	// Caption images (opt-in) — inject [Image: caption] or [image] into section content
-	var collectedImages []captionedImage
-	if len(parsed.Images) > 0 {
-		parsed.Sections, collectedImages = e.captionImages(ctx, parsed.Sections, parsed.Images)
-	}
+	e.store.UpdateDocumentParseMethod(ctx, docID, parsed.Method)
 
 	// Chunk
-	chunkStart := time.Now()
-	var chunks []store.Chunk
+	var scan *injectionScan
+	var collectedImages []captionedImage
 	var sectionMap []int // maps chunk index -> originating section index
-	if len(collectedImages) > 0 {
-		chunks, sectionMap = e.chunkr.ChunkWithSectionMap(parsed.Sections)
-	} else {
-		chunks = e.chunkr.Chunk(parsed.Sections)
-	}
-	slog.Info("ingest: chunking complete",
-		"file", filename, "chunks", len(chunks),
-		"max_tokens", e.cfg.MaxChunkTokens, "overlap", e.cfg.ChunkOverlap,
-		"elapsed", time.Since(chunkStart).Round(time.Millisecond))
+	if !p.Skips(pipeline.Chunk) {
+		// Caption images (opt-in) — inject [Image: caption] or [image] into section content
+		if len(parsed.Images) > 0 {
+			parsed.Sections, collectedImages = e.captionImages(ctx, parsed.Sections, parsed.Images)
+		}
 
-	// Delete old chunks/embeddings/entities for this document (re-ingest)
-	if err := e.store.DeleteDocumentData(ctx, docID); err != nil {
-		return 0, fmt.Errorf("cleaning old data: %w", err)
+		chunkStart := time.Now()
+		var chunks []store.Chunk
+		chunkr := e.chunkerFor(collection)
+		if len(collectedImages) > 0 {
+			chunks, sectionMap = chunkr.ChunkWithSectionMap(parsed.Sections)
+		} else {
+			chunks = chunkr.Chunk(parsed.Sections)
+		}
+		slog.InfoContext(ctx, "ingest: chunking complete",
+			"file", filename, "chunks", len(chunks), "collection", collection,
+			"elapsed", time.Since(chunkStart).Round(time.Millisecond))
+
+		// Application chunk filters: engine-wide first, then per-ingest. The
+		// prompt injection scan runs last, over the text that is stored.
+		filters := append(append([]ChunkFilter(nil), e.cfg.ChunkFilters...), options.chunkFilters...)
+		scan = newInjectionScan(e.cfg.InjectionPolicy)
+		if scan != nil {
+			filters = append(filters, scan)
+		}
+		if len(filters) > 0 {
+			before := len(chunks)
+			var err error
+			chunks, sectionMap, err = applyChunkFilters(ctx, filters, ChunkDocument{
+				ID:         docID,
+				Path:       docPath,
+				Filename:   filename,
+				Format:     format,
+				Collection: collection,
+				Metadata:   metadata,
+			}, chunks, sectionMap, chunkr)
+			if err != nil {
+				return fail(err)
+			}
+			slog.InfoContext(ctx, "ingest: chunk filters applied", "file", filename, "before", before, "after", len(chunks))
+		}
+		doc.Chunks = chunks
 	}
+	if stages := p.After(pipeline.Chunk); len(stages) > 0 {
+		// Images are linked to the chunks of their section, which stages
+		// may drop, reorder, or add to; the chunker's temporary chunk IDs
+		// identify the chunks that remain.
+		sectionOf := make(map[int64]int, len(sectionMap))
+		for i, secIdx := range sectionMap {
+			sectionOf[doc.Chunks[i].ID] = secIdx
+		}
+		if err := runStages(ctx, stages, doc); err != nil {
+			return fail(err)
+		}
+		for i := range doc.Chunks {
+			doc.Chunks[i].PositionInDoc = i
+		}
+		if sectionMap != nil {
+			sectionMap = make([]int, len(doc.Chunks))
+			for i, c := range doc.Chunks {
+				secIdx, ok := sectionOf[c.ID]
+				if !ok {
+					secIdx = -1 // added by a stage
+				}
+				sectionMap[i] = secIdx
+			}
+		}
+	}
+	chunks := doc.Chunks
 
-	// Store chunks and generate embeddings
 	for i := range chunks {
 		chunks[i].DocumentID = docID
 	}
 
-	chunkIDs, err := e.store.InsertChunks(ctx, chunks)
-	if err != nil {
-		e.store.UpdateDocumentStatus(ctx, docID, "error")
-		return 0, fmt.Errorf("inserting chunks: %w", err)
+	// Store chunks. A full re-ingest replaces every chunk; an incremental
+	// one keeps chunks whose content is unchanged, with their IDs,
+	// embeddings, and graph links, and only the new chunks (newChunks)
+	// are embedded and extracted below.
+	var chunkIDs, newIDs []int64
+	newChunks := chunks
+	if options.incremental {
+		sync, err := e.store.SyncChunks(ctx, docID, chunks)
+		if err != nil {
+			e.store.UpdateDocumentStatus(ctx, docID, "error")
+			return 0, fmt.Errorf("syncing chunks: %w", err)
+		}
+		chunkIDs = sync.IDs
+		newChunks, newIDs = make([]store.Chunk, 0, len(sync.Added)), make([]int64, 0, len(sync.Added))
+		for _, i := range sync.Added {
+			newChunks = append(newChunks, chunks[i])
+			newIDs = append(newIDs, chunkIDs[i])
+		}
+		slog.InfoContext(ctx, "ingest: chunks synced",
+			"file", filename, "kept", sync.Kept, "added", len(sync.Added), "removed", sync.Removed)
+	} else {
+		// Delete old chunks/embeddings/entities for this document (re-ingest)
+		if err := e.store.DeleteDocumentData(ctx, docID); err != nil {
+			return 0, fmt.Errorf("cleaning old data: %w", err)
+		}
+		chunkIDs, err = e.store.InsertChunks(ctx, chunks)
+		if err != nil {
+			e.store.UpdateDocumentStatus(ctx, docID, "error")
+			return 0, fmt.Errorf("inserting chunks: %w", err)
+		}
+		newIDs = chunkIDs
+	}
+	doc.ChunkIDs = chunkIDs
+
+	// Abbreviation glossary (definitions such as "Total Harmonic Distortion (THD)").
+	// A session document's definitions would reach other sessions' answers.
+	if !e.cfg.SkipGlossary && options.session == "" {
+		glossary := extractGlossary(docID, chunks, chunkIDs)
+		if err := e.store.InsertGlossary(ctx, glossary); err != nil {
+			slog.WarnContext(ctx, "ingest: storing glossary failed (non-fatal)", "doc_id", docID, "error", err)
+		} else if len(glossary) > 0 {
+			slog.InfoContext(ctx, "ingest: glossary extracted", "file", filename, "entries", len(glossary))
+		}
+	}
+
+	// Numeric values with units, for range queries. Kept chunks keep theirs.
+	if err := e.store.InsertChunkQuantities(ctx, extractQuantities(docID, newChunks, newIDs)); err != nil {
+		slog.WarnContext(ctx, "ingest: storing numeric values failed (non-fatal)", "doc_id", docID, "error", err)
 	}
 
 	// Store extracted images linked to their chunks
@@ -461,92 +1421,410 @@ func (e *engine) Ingest(ctx context.Context, path string, opts ...IngestOption)
 		}
 		if len(storeImages) > 0 {
 			if err := e.store.InsertChunkImages(ctx, storeImages); err != nil {
-				slog.Warn("ingest: storing chunk images failed (non-fatal)", "error", err)
+				slog.WarnContext(ctx, "ingest: storing chunk images failed (non-fatal)", "error", err)
 			} else {
-				slog.Info("ingest: stored chunk images", "count", len(storeImages))
+				slog.InfoContext(ctx, "ingest: stored chunk images", "count", len(storeImages))
 			}
 		}
 	}
 
-	// Generate embeddings concurrently
-	slog.Info("ingest: generating embeddings", "file", filename, "chunks", len(chunks))
-	embedStart := time.Now()
-	if err := e.embedChunks(ctx, chunks, chunkIDs); err != nil {
+	// Embed, summarise, and extract the graph. The stages checkpoint their
+	// progress, so an ingest that fails part-way can be continued with
+	// ResumeIngest instead of restarted.
+	run := &ingestRun{
+		docID:    docID,
+		filename: filename,
+		chunks:   newChunks,
+		ids:      newIDs,
+		sections: parsed.Sections,
+		session:  options.session != "",
+		pipeline: p,
+		doc:      doc,
+	}
+	if err := e.runIngestStages(ctx, run, store.CheckpointEmbedding, 0); err != nil {
 		e.store.UpdateDocumentStatus(ctx, docID, "error")
-		return 0, fmt.Errorf("%w: %v", ErrEmbeddingFailed, err)
+		return 0, err
 	}
-	slog.Info("ingest: embeddings complete",
-		"file", filename, "chunks", len(chunks),
+
+	e.recordQuality(ctx, docID, filename, parsed, scan.result(), run.graph)
+
+	totalElapsed := time.Since(parseStart)
+	slog.InfoContext(ctx, "ingest: document ready",
+		"file", filename, "doc_id", docID,
+		"total_elapsed", totalElapsed.Round(time.Millisecond))
+	e.store.UpdateDocumentStatus(ctx, docID, "ready")
+	return docID, nil
+}
+
+// ingestRun is what the ingest stages after chunk storage work on. Ingest
+// builds it from the parsed document, ResumeIngest from the stored chunks.
+type ingestRun struct {
+	docID    int64
+	filename string
+	chunks   []store.Chunk // chunks this ingest added, in ID order
+	ids      []int64
+	sections []parser.Section // document summary input
+	session  bool             // a session document: no summary or graph
+	embedded bool             // dense vectors already stored (ImportIndex)
+	// graph accumulates the graph build report of the run's batches,
+	// including those of an interrupted ingest it resumes; nil until the
+	// graph stage runs.
+	graph *graph.BuildReport
+	// pipeline skips stages and runs custom ones after them, over doc.
+	// A resumed ingest runs the pipeline it was started with, over the
+	// stored document and chunks.
+	pipeline *pipeline.Pipeline
+	doc      *pipeline.Document
+}
+
+// checkpointBatch is how many chunks an ingest stage processes between
+// checkpoints (a variable so tests can checkpoint small documents).
+var checkpointBatch = 256
+
+// runIngestStages runs the embedding stage (dense, sparse, secondary, and
+// image embeddings, and the document summary) and the graph stage, starting
+// at stage after chunk lastChunkID, and removes the document's checkpoint
+// once both are done. The custom stages of run.pipeline run after each,
+// checkpointed as stages of their own, so a resumed ingest runs those that
+// failed again.
+func (e *engine) runIngestStages(ctx context.Context, run *ingestRun, stage string, lastChunkID int64) error {
+	cp := store.IngestCheckpoint{DocumentID: run.docID, Stage: stage, LastChunkID: lastChunkID, Pipeline: run.pipeline.String()}
+	if len(run.ids) > 0 {
+		cp.FirstChunkID = run.ids[0]
+	}
+	e.saveCheckpoint(ctx, cp)
+	next := func(stage string) {
+		cp.Stage, cp.LastChunkID = stage, 0
+		e.saveCheckpoint(ctx, cp)
+	}
+
+	if cp.Stage == store.CheckpointEmbedding {
+		if !run.pipeline.Skips(pipeline.Embed) {
+			if err := e.embeddingStage(ctx, run, &cp); err != nil {
+				return err
+			}
+		}
+		next(store.CheckpointAfterEmbedding)
+	}
+	if cp.Stage == store.CheckpointAfterEmbedding {
+		if err := runStages(ctx, run.pipeline.After(pipeline.Embed), run.doc); err != nil {
+			return err
+		}
+		next(store.CheckpointGraph)
+	}
+	if cp.Stage == store.CheckpointGraph {
+		if !run.pipeline.Skips(pipeline.Graph) {
+			if err := e.graphStage(ctx, run, &cp); err != nil {
+				return err
+			}
+		}
+		next(store.CheckpointAfterGraph)
+	}
+	if err := runStages(ctx, run.pipeline.After(pipeline.Graph), run.doc); err != nil {
+		return err
+	}
+
+	if err := e.store.DeleteIngestCheckpoint(ctx, run.docID); err != nil {
+		slog.WarnContext(ctx, "ingest: clearing checkpoint failed (non-fatal)", "doc_id", run.docID, "error", err)
+	}
+	return nil
+}
+
+// saveCheckpoint records a stage's progress. Failing to save only costs
+// repeated work on resume, so it is logged rather than returned.
+func (e *engine) saveCheckpoint(ctx context.Context, cp store.IngestCheckpoint) {
+	if cp.FirstChunkID == 0 {
+		return // nothing to resume
+	}
+	if err := e.store.SetIngestCheckpoint(ctx, cp); err != nil {
+		slog.WarnContext(ctx, "ingest: saving checkpoint failed (non-fatal)",
+			"doc_id", cp.DocumentID, "stage", cp.Stage, "error", err)
+	}
+}
+
+// pendingFrom returns the index of the first chunk after lastChunkID.
+func pendingFrom(ids []int64, lastChunkID int64) int {
+	return sort.Search(len(ids), func(i int) bool { return ids[i] > lastChunkID })
+}
+
+// embeddingStage embeds the run's chunks from the checkpoint on, in
+// checkpointed batches, then adds the optional embeddings and the summary.
+func (e *engine) embeddingStage(ctx context.Context, run *ingestRun, cp *store.IngestCheckpoint) error {
+	from := pendingFrom(run.ids, cp.LastChunkID)
+	if run.embedded {
+		from = len(run.chunks)
+	}
+	slog.InfoContext(ctx, "ingest: generating embeddings", "file", run.filename, "chunks", len(run.chunks)-from)
+	embedStart := time.Now()
+	// embedChunks tolerates failed texts, so a resumed ingest must start
+	// again from the first batch some of whose chunks failed.
+	partial := false
+	for i := from; i < len(run.chunks); i += checkpointBatch {
+		end := min(i+checkpointBatch, len(run.chunks))
+		failed, err := e.embedChunks(ctx, run.chunks[i:end], run.ids[i:end])
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrEmbeddingFailed, err)
+		}
+		// A batch cut short by cancellation must not be checkpointed as
+		// done either.
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("%w: %v", ErrEmbeddingFailed, err)
+		}
+		partial = partial || failed > 0
+		if !partial {
+			cp.LastChunkID = run.ids[end-1]
+			e.saveCheckpoint(ctx, *cp)
+		}
+	}
+	slog.InfoContext(ctx, "ingest: embeddings complete",
+		"file", run.filename, "chunks", len(run.chunks),
 		"elapsed", time.Since(embedStart).Round(time.Millisecond))
 
-	// Build knowledge graph (optional — can be skipped for faster ingestion).
-	if !e.cfg.SkipGraph {
-		slog.Info("ingest: building knowledge graph", "file", filename, "chunks", len(chunks),
-			"concurrency", e.cfg.GraphConcurrency)
-		graphStart := time.Now()
-		if err := e.graphB.Build(ctx, docID, chunks, chunkIDs); err != nil {
-			slog.Warn("graph build had errors (non-fatal)", "doc_id", docID, "error", err)
+	// Sparse embeddings (optional — only when a sparse provider is configured).
+	if e.sparseLLM != nil {
+		sparseStart := time.Now()
+		if err := e.embedChunksSparse(ctx, run.chunks, run.ids); err != nil {
+			slog.WarnContext(ctx, "ingest: sparse embeddings failed (non-fatal)", "doc_id", run.docID, "error", err)
+		} else {
+			slog.InfoContext(ctx, "ingest: sparse embeddings complete",
+				"file", run.filename, "chunks", len(run.chunks),
+				"elapsed", time.Since(sparseStart).Round(time.Millisecond))
+		}
+	}
+
+	// Secondary embeddings (optional — only when a second embedding model is configured).
+	if e.secondaryLLM != nil {
+		secondaryStart := time.Now()
+		if err := e.embedChunksSecondary(ctx, run.chunks, run.ids); err != nil {
+			slog.WarnContext(ctx, "ingest: secondary embeddings failed (non-fatal)", "doc_id", run.docID, "error", err)
+		} else {
+			slog.InfoContext(ctx, "ingest: secondary embeddings complete",
+				"file", run.filename, "chunks", len(run.chunks),
+				"elapsed", time.Since(secondaryStart).Round(time.Millisecond))
+		}
+	}
+
+	// Image embeddings (optional — only when a multimodal provider is configured).
+	if e.imageLLM != nil {
+		imageStart := time.Now()
+		if n, err := e.embedImages(ctx, run.docID); err != nil {
+			slog.WarnContext(ctx, "ingest: image embeddings failed (non-fatal)", "doc_id", run.docID, "error", err)
+		} else if n > 0 {
+			slog.InfoContext(ctx, "ingest: image embeddings complete",
+				"file", run.filename, "images", n,
+				"elapsed", time.Since(imageStart).Round(time.Millisecond))
+		}
+	}
+
+	// Document summary + keywords (optional — used by ListDocuments and
+	// query-time document selection).
+	if !e.cfg.SkipSummary && !run.session {
+		summaryStart := time.Now()
+		if err := e.summarizeDocument(ctx, run.docID, run.filename, run.sections); err != nil {
+			slog.WarnContext(ctx, "ingest: document summary failed (non-fatal)", "doc_id", run.docID, "error", err)
+		} else {
+			slog.InfoContext(ctx, "ingest: document summary complete",
+				"file", run.filename, "elapsed", time.Since(summaryStart).Round(time.Millisecond))
+		}
+	}
+
+	// Key facts record (optional — only when key_facts schemas are configured).
+	if len(e.cfg.KeyFacts) > 0 && !run.session {
+		factsStart := time.Now()
+		if n, err := e.extractKeyFacts(ctx, run.docID, run.filename, run.sections); err != nil {
+			slog.WarnContext(ctx, "ingest: key facts extraction failed (non-fatal)", "doc_id", run.docID, "error", err)
+		} else {
+			slog.InfoContext(ctx, "ingest: key facts extracted",
+				"file", run.filename, "facts", n, "elapsed", time.Since(factsStart).Round(time.Millisecond))
 		}
-		slog.Info("ingest: graph build complete",
-			"file", filename, "elapsed", time.Since(graphStart).Round(time.Millisecond))
+	}
+	return nil
+}
 
-		// Run community detection on the updated graph.
-		slog.Info("ingest: detecting communities", "file", filename)
-		communities, err := graph.DetectCommunities(ctx, e.store)
+// graphStage extracts the knowledge graph from the run's chunks from the
+// checkpoint on, in checkpointed batches, then embeds new entities and
+// re-runs community detection.
+func (e *engine) graphStage(ctx context.Context, run *ingestRun, cp *store.IngestCheckpoint) error {
+	if e.cfg.SkipGraph {
+		slog.InfoContext(ctx, "ingest: graph building skipped (skip_graph=true)", "doc_id", run.docID)
+		return nil
+	}
+	// A session document's entities would outlive it in the shared graph.
+	if run.session {
+		return nil
+	}
+
+	from := pendingFrom(run.ids, cp.LastChunkID)
+	slog.InfoContext(ctx, "ingest: building knowledge graph", "file", run.filename, "chunks", len(run.chunks)-from,
+		"concurrency", e.cfg.GraphConcurrency)
+	graphStart := time.Now()
+	if run.graph == nil {
+		run.graph = &graph.BuildReport{}
+	}
+	defer e.graphProgress.Delete(run.docID)
+	// As in the embedding stage, the checkpoint stays before the first
+	// batch with failed extractions.
+	partial := false
+	for i := from; i < len(run.chunks); i += checkpointBatch {
+		end := min(i+checkpointBatch, len(run.chunks))
+		report, err := e.graphB.Build(ctx, run.docID, run.chunks[i:end], run.ids[i:end], func(r graph.BuildReport) {
+			r.Add(run.graph)
+			e.graphProgress.Store(run.docID, r)
+		})
 		if err != nil {
-			slog.Warn("community detection failed (non-fatal)", "error", err)
-		} else if len(communities) > 0 {
-			slog.Info("ingest: summarizing communities", "count", len(communities))
-			if err := graph.SummarizeCommunities(ctx, e.store, e.chatLLM, communities); err != nil {
-				slog.Warn("community summarization failed (non-fatal)", "error", err)
-			}
+			slog.WarnContext(ctx, "graph build had errors (non-fatal)", "doc_id", run.docID, "error", err)
+		}
+		run.graph.Add(report)
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("building graph: %w", err)
+		}
+		partial = partial || err != nil || report.Failed > 0
+		if partial {
+			continue
+		}
+		cp.LastChunkID = run.ids[end-1]
+		if data, err := json.Marshal(run.graph); err == nil {
+			cp.GraphReport = string(data)
+		}
+		e.saveCheckpoint(ctx, *cp)
+	}
+	slog.InfoContext(ctx, "ingest: graph build complete",
+		"file", run.filename, "chunks", run.graph.Processed, "failed", run.graph.Failed,
+		"entities", run.graph.Entities, "relationships", run.graph.Relationships,
+		"elapsed", time.Since(graphStart).Round(time.Millisecond))
+
+	// Embed new entity descriptions for query-time graph seeding.
+	if e.cfg.GraphSeedSimilarity > 0 {
+		if n, err := e.embedEntities(ctx); err != nil {
+			slog.WarnContext(ctx, "ingest: entity embeddings failed (non-fatal)", "doc_id", run.docID, "error", err)
+		} else if n > 0 {
+			slog.InfoContext(ctx, "ingest: entity embeddings complete", "file", run.filename, "entities", n)
 		}
-	} else {
-		slog.Info("ingest: graph building skipped (skip_graph=true)", "doc_id", docID)
 	}
 
-	totalElapsed := time.Since(parseStart)
-	slog.Info("ingest: document ready",
-		"file", filename, "doc_id", docID,
-		"total_elapsed", totalElapsed.Round(time.Millisecond))
-	e.store.UpdateDocumentStatus(ctx, docID, "ready")
-	return docID, nil
+	e.refreshStopEntities(ctx)
+
+	// Run community detection on the updated graph. Scheduled refreshes
+	// summarise the stale communities later.
+	slog.InfoContext(ctx, "ingest: detecting communities", "file", run.filename)
+	o := newCommunityOptions(e.cfg, nil)
+	o.skipSummaries = e.cfg.CommunitySummaryIntervalMinutes > 0
+	if _, err := e.rebuildCommunities(ctx, o); err != nil {
+		slog.WarnContext(ctx, "community rebuild failed (non-fatal)", "error", err)
+	}
+	return nil
 }
 
-// Query runs hybrid retrieval and multi-round reasoning.
+// Query runs hybrid retrieval and multi-round reasoning, on a query
+// worker when Config.QueryWorkers is set.
 func (e *engine) Query(ctx context.Context, question string, opts ...QueryOption) (*Answer, error) {
-	options := &queryOptions{
-		maxResults:  20,
-		maxRounds:   e.cfg.MaxRounds,
-		weightVec:   e.cfg.WeightVector,
-		weightFTS:   e.cfg.WeightFTS,
-		weightGraph: e.cfg.WeightGraph,
+	ctx, rq := e.running.start(ctx, question)
+	var answer *Answer
+	var err error
+	if e.workers == nil {
+		answer, err = e.query(ctx, question, opts...)
+	} else {
+		answer, err = e.workers.do(ctx, func(w *engine) (*Answer, error) {
+			return w.query(ctx, question, opts...)
+		})
 	}
-	for _, o := range opts {
-		o(options)
+	if err != nil && queryCancelled(ctx) != nil {
+		answer, err = rq.partial(), ErrQueryCancelled
 	}
+	e.running.finish(rq, answer)
+	return answer, err
+}
 
-	// Hybrid retrieval
-	results, searchTrace, err := e.retriever.Search(ctx, question, retrieval.SearchOptions{
-		MaxResults:  options.maxResults,
-		WeightVec:   options.weightVec,
-		WeightFTS:   options.weightFTS,
-		WeightGraph: options.weightGraph,
-	})
+// query runs Query on e's own store and retriever.
+func (e *engine) query(ctx context.Context, question string, opts ...QueryOption) (*Answer, error) {
+	ctx = llm.WithPhase(ctx, llm.PhaseQuery)
+	start := time.Now()
+	options := e.queryOptions(opts)
+	rq := runningQueryFrom(ctx)
+
+	queryChecks, err := compileValidationChecks(options.checks)
 	if err != nil {
-		return nil, fmt.Errorf("retrieval: %w", err)
+		return nil, err
+	}
+	if !validEmbeddingSpace(options.space) {
+		return nil, fmt.Errorf("%w: unknown embedding space %q", ErrInvalidConfig, options.space)
+	}
+	if !reasoning.ValidFormat(options.format) {
+		return nil, fmt.Errorf("%w: unknown answer format %q", ErrInvalidConfig, options.format)
 	}
-	if len(results) == 0 {
-		return nil, ErrNoResults
+	if options.format == reasoning.FormatJSON && options.jsonOutput {
+		return nil, fmt.Errorf("%w: the json answer format cannot be combined with JSON output", ErrInvalidConfig)
+	}
+	numericFilters, err := numericRanges(options.numericFilters)
+	if err != nil {
+		return nil, err
 	}
 
-	// Multi-round reasoning
-	rAnswer, err := e.reasoner.Reason(ctx, question, results, reasoning.Options{
-		MaxRounds: options.maxRounds,
-	})
+	if err := e.embeddingDrift(); err != nil {
+		return nil, err
+	}
+
+	// Out-of-scope questions are refused before spending retrieval and
+	// reasoning tokens.
+	refusal, scopePT, scopeCT := e.scope.check(ctx, question)
+	if refusal != "" {
+		return e.refuse(ctx, question, refusal, scopePT, scopeCT, start, options), nil
+	}
+
+	scope, err := e.documentScope(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+
+	// Hybrid retrieval
+	results, searchTrace, err := e.search(ctx, question, options, scope)
+	rq.setTrace(searchTrace)
+	if err != nil {
+		return nil, err
+	}
+
+	// Multi-round reasoning. Strategies that retrieve as they go (ReAct,
+	// plan-and-execute) issue sub-queries through the same hybrid retriever.
+	reasonOpts := reasoning.Options{
+		MaxRounds:      options.maxRounds,
+		Strategy:       options.strategy,
+		AnswerLanguage: options.answerLang,
+		Format:         options.format,
+		Instructions:   options.instructions,
+		DocumentOrder:  options.docOrder,
+		Checks:         append(append([]reasoning.ValidationCheck(nil), e.checks...), queryChecks...),
+		Glossary:       e.glossaryFor(ctx, question, results),
+		SearchBudget:   e.cfg.ReasoningSearchBudget,
+		Retrieve: func(ctx context.Context, query string) ([]store.RetrievalResult, error) {
+			res, _, err := e.retriever.Search(ctx, query, retrieval.SearchOptions{
+				MaxResults:  10,
+				WeightVec:   options.weightVec,
+				WeightFTS:   options.weightFTS,
+				WeightGraph: options.weightGraph,
+				DocumentIDs: scope.include,
+				SkipGraph:   options.skipGraph,
+
+				ExcludeDocumentIDs: scope.exclude,
+				EmbeddingSpace:     options.space,
+				NumericFilters:     numericFilters,
+			})
+			return res, err
+		},
+	}
+	if rq != nil {
+		reasonOpts.OnStep = rq.addStep
+	}
+	rAnswer, err := e.reasoner.Reason(ctx, question, results, reasonOpts)
 	if err != nil {
 		return nil, fmt.Errorf("reasoning: %w", err)
 	}
+	// Reasoning keeps the answer of an earlier round when a later one
+	// fails; a cancelled query stops here instead.
+	if err := queryCancelled(ctx); err != nil {
+		return nil, err
+	}
+	// The search budget is per query: later passes get what is left.
+	reasonOpts.SearchBudget -= rAnswer.Searches
 
 	// Follow-up retrieval for synthesis queries with a full initial window.
 	// When the first retrieval filled the entire result window, there are
@@ -558,11 +1836,20 @@ func (e *engine) Query(ctx context.Context, question string, opts ...QueryOption
 	// Gate: compare against FusedResults (the actual window size after
 	// synthesis widening) rather than the caller's original maxResults,
 	// so we only fire when the widened window was truly filled.
-	if searchTrace != nil && searchTrace.SynthesisMode && searchTrace.FusedResults >= searchTrace.MaxRequested {
+	//
+	// The follow-up is skipped when its search and second answer would not
+	// finish before the deadline.
+	followUp := searchTrace != nil && searchTrace.SynthesisMode && searchTrace.FusedResults >= searchTrace.MaxRequested
+	if followUp && !e.reasoner.HasTimeFor(ctx, 2) {
+		slog.InfoContext(ctx, "retrieval: skipping synthesis follow-up, no time left before the deadline")
+		followUp = false
+		rAnswer.DeadlineLimited = true
+	}
+	if followUp {
 		// The widened window was filled — there are likely more chunks.
 		missing := extractMissingTerms(rAnswer.Text, results)
 		if len(missing) > 0 {
-			slog.Debug("retrieval: synthesis follow-up",
+			slog.DebugContext(ctx, "retrieval: synthesis follow-up",
 				"missing_terms", missing, "count", len(missing))
 
 			// Replace hyphens with spaces so FTS tokenisation matches the
@@ -579,31 +1866,42 @@ func (e *engine) Query(ctx context.Context, question string, opts ...QueryOption
 				WeightFTS:   2.0,
 				WeightVec:   0.5,
 				WeightGraph: 1.0,
+				DocumentIDs: scope.include,
+				SkipGraph:   options.skipGraph,
+
+				ExcludeDocumentIDs: scope.exclude,
+				EmbeddingSpace:     options.space,
+				NumericFilters:     numericFilters,
 			})
 
 			// Record follow-up in the original trace for diagnostics.
 			searchTrace.FollowUpTerms = missing
 			if followTrace != nil {
 				searchTrace.FollowUpResults = followTrace.FusedResults
+				searchTrace.TranslationPromptTokens += followTrace.TranslationPromptTokens
+				searchTrace.TranslationCompletionTokens += followTrace.TranslationCompletionTokens
 			}
 
 			if ferr == nil && len(extraResults) > 0 {
 				merged := mergeResults(results, extraResults)
-				slog.Debug("retrieval: synthesis follow-up merged",
+				slog.DebugContext(ctx, "retrieval: synthesis follow-up merged",
 					"extra", len(extraResults), "total", len(merged))
 
 				// Accumulate token counts from the first reasoning call
 				// so the final answer reflects total usage.
 				firstPromptTokens := rAnswer.PromptTokens
 				firstCompletionTokens := rAnswer.CompletionTokens
+				firstCachedTokens := rAnswer.CachedTokens
 
 				// Re-run reasoning with expanded context
-				rAnswer2, rerr := e.reasoner.Reason(ctx, question, merged, reasoning.Options{
-					MaxRounds: options.maxRounds,
-				})
+				rAnswer2, rerr := e.reasoner.Reason(ctx, question, merged, reasonOpts)
 				if rerr == nil {
+					reasonOpts.SearchBudget -= rAnswer2.Searches
+					rAnswer2.Searches += rAnswer.Searches
+					rAnswer2.StreamRestarts += rAnswer.StreamRestarts
 					rAnswer2.PromptTokens += firstPromptTokens
 					rAnswer2.CompletionTokens += firstCompletionTokens
+					rAnswer2.CachedTokens += firstCachedTokens
 					rAnswer2.TotalTokens = rAnswer2.PromptTokens + rAnswer2.CompletionTokens
 					rAnswer2.Rounds += rAnswer.Rounds
 					rAnswer = rAnswer2
@@ -613,6 +1911,13 @@ func (e *engine) Query(ctx context.Context, question string, opts ...QueryOption
 		}
 	}
 
+	// A weak answer from the chat model is reasoned again with the
+	// escalation model, when one is configured.
+	rAnswer, tier, escalationReason := e.escalate(ctx, question, results, reasonOpts, rAnswer)
+	if err := queryCancelled(ctx); err != nil {
+		return nil, err
+	}
+
 	// Convert reasoning.Answer -> goreason.Answer
 	answer := &Answer{
 		Text:             rAnswer.Text,
@@ -620,9 +1925,17 @@ func (e *engine) Query(ctx context.Context, question string, opts ...QueryOption
 		RetrievalTrace:   searchTrace,
 		ModelUsed:        rAnswer.ModelUsed,
 		Rounds:           rAnswer.Rounds,
+		Strategy:         rAnswer.Strategy,
 		PromptTokens:     rAnswer.PromptTokens,
 		CompletionTokens: rAnswer.CompletionTokens,
 		TotalTokens:      rAnswer.TotalTokens,
+		CachedTokens:     rAnswer.CachedTokens,
+		Sections:         rAnswer.Sections,
+		DeadlineLimited:  rAnswer.DeadlineLimited,
+		Tier:             tier,
+		EscalationReason: escalationReason,
+		Searches:         rAnswer.Searches,
+		StreamRestarts:   rAnswer.StreamRestarts,
 	}
 	for _, s := range rAnswer.Sources {
 		src := Source{
@@ -635,7 +1948,16 @@ func (e *engine) Query(ctx context.Context, question string, opts ...QueryOption
 			ChunkType:     s.ChunkType,
 			PageNumber:    s.PageNumber,
 			PositionInDoc: s.PositionInDoc,
+			SectionNumber: s.SectionNumber,
+			Chapter:       s.Chapter,
 			Score:         s.Score,
+			Conflicts:     s.Conflicts,
+		}
+		for _, d := range s.Duplicates {
+			src.Duplicates = append(src.Duplicates, DuplicateSource(d))
+		}
+		if s.EndOffset > 0 {
+			src.Span = &SourceSpan{StartOffset: s.StartOffset, EndOffset: s.EndOffset}
 		}
 		if s.ChunkMeta != "" && s.ChunkMeta != "{}" {
 			_ = json.Unmarshal([]byte(s.ChunkMeta), &src.ChunkMetadata)
@@ -662,7 +1984,7 @@ func (e *engine) Query(ctx context.Context, question string, opts ...QueryOption
 		}
 		imageMap, err := e.store.GetImagesByChunkIDs(ctx, chunkIDs, options.includeImages)
 		if err != nil {
-			slog.Warn("query: loading chunk images failed (non-fatal)", "error", err)
+			slog.WarnContext(ctx, "query: loading chunk images failed (non-fatal)", "error", err)
 		} else if len(imageMap) > 0 {
 			for i := range answer.Sources {
 				if imgs, ok := imageMap[answer.Sources[i].ChunkID]; ok {
@@ -682,21 +2004,7 @@ func (e *engine) Query(ctx context.Context, question string, opts ...QueryOption
 		}
 	}
 
-	for _, s := range rAnswer.Reasoning {
-		answer.Reasoning = append(answer.Reasoning, Step{
-			Round:      s.Round,
-			Action:     s.Action,
-			Input:      s.Input,
-			Output:     s.Output,
-			Prompt:     s.Prompt,
-			Response:   s.Response,
-			Validation: s.Validation,
-			ChunksUsed: s.ChunksUsed,
-			Tokens:     s.Tokens,
-			ElapsedMs:  s.ElapsedMs,
-			Issues:     s.Issues,
-		})
-	}
+	answer.Reasoning = toSteps(rAnswer.Reasoning)
 
 	// Structured JSON output (opt-in)
 	if options.jsonOutput {
@@ -708,103 +2016,296 @@ func (e *engine) Query(ctx context.Context, question string, opts ...QueryOption
 		answer.TotalTokens = answer.PromptTokens + answer.CompletionTokens
 	}
 
-	// Log query
-	e.store.LogQuery(ctx, store.QueryLog{
+	// Follow-up question suggestions (opt-in)
+	if options.suggest {
+		questions, pt, ct, err := e.suggestQuestions(ctx, question, answer)
+		if err != nil {
+			slog.WarnContext(ctx, "query: suggesting follow-up questions failed (non-fatal)", "error", err)
+		}
+		answer.SuggestedQuestions = questions
+		answer.PromptTokens += pt
+		answer.CompletionTokens += ct
+		answer.TotalTokens = answer.PromptTokens + answer.CompletionTokens
+	}
+
+	answer.PromptTokens += scopePT
+	answer.CompletionTokens += scopeCT
+	answer.TotalTokens = answer.PromptTokens + answer.CompletionTokens
+	answer.Attribution = attributeDocuments(answer)
+
+	// Log query. A query cancelled since is not logged.
+	if err := queryCancelled(ctx); err != nil {
+		return nil, err
+	}
+	answer.Experiment = options.experiment
+	logEntry := store.QueryLog{
 		Query:            question,
 		Answer:           answer.Text,
 		Confidence:       answer.Confidence,
-		Sources:          answer.Sources,
+		Sources:          e.loggedSources(answer.Sources),
 		RetrievalMethod:  "hybrid",
 		ModelUsed:        answer.ModelUsed,
 		Rounds:           answer.Rounds,
 		PromptTokens:     answer.PromptTokens,
 		CompletionTokens: answer.CompletionTokens,
 		TotalTokens:      answer.TotalTokens,
-	})
+		ElapsedMs:        time.Since(start).Milliseconds(),
+		RequestID:        RequestIDFromContext(ctx),
+		Trace:            e.newQueryTrace(answer),
+	}
+	if x := options.experiment; x != nil {
+		logEntry.Experiment, logEntry.Arm = x.Experiment, x.Arm
+	}
+	answer.QueryID = e.logQuery(ctx, logEntry)
+	e.recordUsage(ctx, answer)
 
 	return answer, nil
 }
 
 // Update checks if a document has changed and re-ingests if needed.
 func (e *engine) Update(ctx context.Context, path string) (bool, error) {
+	if err := e.writable(); err != nil {
+		return false, err
+	}
+	start := time.Now()
+	docID, changed, _, err := e.update(ctx, path, false)
+	e.audit(ctx, AuditUpdate, start, docID, map[string]string{
+		"path": path, "changed": strconv.FormatBool(changed),
+	}, err)
+	return changed, err
+}
+
+// update re-ingests a document whose content hash changed. It returns
+// the document ID when the document is known, and with report a
+// ChangeReport when it changed.
+func (e *engine) update(ctx context.Context, path string, report bool) (int64, bool, *ChangeReport, error) {
 	absPath, err := filepath.Abs(path)
 	if err != nil {
-		return false, fmt.Errorf("resolving path: %w", err)
+		return 0, false, nil, fmt.Errorf("resolving path: %w", err)
 	}
 
 	doc, err := e.store.GetDocumentByPath(ctx, absPath)
 	if err != nil {
-		return false, fmt.Errorf("%w: %s", ErrDocumentNotFound, absPath)
+		return 0, false, nil, fmt.Errorf("%w: %s", ErrDocumentNotFound, absPath)
+	}
+	// An archive member is updated with its archive.
+	if doc.ParentID != 0 {
+		parent, err := e.store.GetDocument(ctx, doc.ParentID)
+		if err != nil {
+			return doc.ID, false, nil, fmt.Errorf("%w: archive of %s", ErrDocumentNotFound, doc.Path)
+		}
+		return e.update(ctx, parent.Path, report)
 	}
 
 	hash, err := fileHash(absPath)
 	if err != nil {
-		return false, fmt.Errorf("hashing file: %w", err)
+		return doc.ID, false, nil, fmt.Errorf("hashing file: %w", err)
 	}
 
 	if hash == doc.ContentHash {
-		return false, nil
+		return doc.ID, false, nil, nil
 	}
 
-	_, err = e.Ingest(ctx, absPath, WithForceReparse())
+	var before *documentSnapshot
+	if report {
+		if before, err = e.snapshotDocument(ctx, doc.ID); err != nil {
+			slog.WarnContext(ctx, "update: recording document state failed, no change report (non-fatal)",
+				"doc_id", doc.ID, "error", err)
+		}
+	}
+
+	// The document keeps its metadata, and with it its page and section
+	// selection.
+	options := &ingestOptions{forceReparse: true, incremental: true}
+	if doc.Metadata != "" {
+		_ = json.Unmarshal([]byte(doc.Metadata), &options.metadata)
+	}
+	_, err = e.ingest(ctx, absPath, options)
 	if err != nil {
-		return false, err
+		return doc.ID, false, nil, err
+	}
+	if before == nil {
+		return doc.ID, true, nil, nil
+	}
+	changes, err := e.diffDocument(ctx, doc.ID, before)
+	if err != nil {
+		slog.WarnContext(ctx, "update: building change report failed (non-fatal)", "doc_id", doc.ID, "error", err)
 	}
-	return true, nil
+	return doc.ID, true, changes, nil
 }
 
 // UpdateAll checks all documents for changes.
 func (e *engine) UpdateAll(ctx context.Context) ([]UpdateResult, error) {
+	if err := e.writable(); err != nil {
+		return nil, err
+	}
+	start := time.Now()
 	docs, err := e.store.ListDocuments(ctx)
 	if err != nil {
+		e.audit(ctx, AuditUpdateAll, start, 0, nil, err)
 		return nil, err
 	}
 
 	results := make([]UpdateResult, 0, len(docs))
+	changed, failed := 0, 0
 	for _, doc := range docs {
-		changed, err := e.Update(ctx, doc.Path)
+		if doc.ParentID != 0 {
+			continue // updated with its archive
+		}
+		if doc.SessionID != "" {
+			continue // expires instead
+		}
+		if doc.ParseMethod == parseMethodImport {
+			continue // no file to check
+		}
+		_, ok, changes, err := e.update(ctx, doc.Path, true)
 		results = append(results, UpdateResult{
 			DocumentID: doc.ID,
 			Path:       doc.Path,
-			Changed:    changed,
+			Changed:    ok,
+			Changes:    changes,
 			Error:      err,
 		})
+		if ok {
+			changed++
+		}
+		if err != nil {
+			failed++
+		}
 	}
+	e.audit(ctx, AuditUpdateAll, start, 0, map[string]string{
+		"documents": strconv.Itoa(len(docs)),
+		"changed":   strconv.Itoa(changed),
+		"failed":    strconv.Itoa(failed),
+	}, nil)
 	return results, nil
 }
 
 // Delete removes a document and all its associated data.
 func (e *engine) Delete(ctx context.Context, documentID int64) error {
-	return e.store.DeleteDocument(ctx, documentID)
+	if err := e.writable(); err != nil {
+		return err
+	}
+	start := time.Now()
+	err := e.store.DeleteDocument(ctx, documentID)
+	e.audit(ctx, AuditDelete, start, documentID, nil, err)
+	if err == nil {
+		e.refreshStopEntities(ctx)
+	}
+	return err
 }
 
-// ListDocuments returns all ingested documents.
+// ListDocuments returns all ingested documents except session ones.
 func (e *engine) ListDocuments(ctx context.Context) ([]Document, error) {
 	docs, err := e.store.ListDocuments(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	result := make([]Document, len(docs))
-	for i, d := range docs {
-		result[i] = Document{
-			ID:          d.ID,
-			Path:        d.Path,
-			Filename:    d.Filename,
-			Format:      d.Format,
-			ContentHash: d.ContentHash,
-			ParseMethod: d.ParseMethod,
-			Status:      d.Status,
-			CreatedAt:   d.CreatedAt,
-			UpdatedAt:   d.UpdatedAt,
-		}
-		if d.Metadata != "" {
-			_ = json.Unmarshal([]byte(d.Metadata), &result[i].Metadata)
+	result := make([]Document, 0, len(docs))
+	for i := range docs {
+		if docs[i].SessionID != "" {
+			continue
 		}
+		result = append(result, toDocument(&docs[i]))
 	}
 	return result, nil
 }
 
+// Document returns one ingested document with its quality report, and
+// the progress of its ingest while that is unfinished.
+func (e *engine) Document(ctx context.Context, documentID int64) (*Document, error) {
+	d, err := e.store.GetDocument(ctx, documentID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %d", ErrDocumentNotFound, documentID)
+		}
+		return nil, err
+	}
+	doc := toDocument(d)
+	doc.Ingest = e.ingestProgress(ctx, documentID)
+	return &doc, nil
+}
+
+// toDocument converts a stored document, decoding its JSON columns.
+func toDocument(d *store.Document) Document {
+	doc := Document{
+		ID:          d.ID,
+		Path:        d.Path,
+		Filename:    d.Filename,
+		Format:      d.Format,
+		ContentHash: d.ContentHash,
+		ParseMethod: d.ParseMethod,
+		Status:      d.Status,
+		Summary:     d.Summary,
+		Collection:  d.Collection,
+		Importance:  d.Importance,
+		ParentID:    d.ParentID,
+		SessionID:   d.SessionID,
+		ExpiresAt:   d.ExpiresAt,
+		CreatedAt:   d.CreatedAt,
+		UpdatedAt:   d.UpdatedAt,
+	}
+	if d.Metadata != "" {
+		_ = json.Unmarshal([]byte(d.Metadata), &doc.Metadata)
+	}
+	if d.Keywords != "" {
+		_ = json.Unmarshal([]byte(d.Keywords), &doc.Keywords)
+	}
+	if d.Quality != "" {
+		var q QualityReport
+		if json.Unmarshal([]byte(d.Quality), &q) == nil {
+			doc.Quality = &q
+		}
+	}
+	return doc
+}
+
+// IngestQueueStats reports running and queued Ingest calls.
+func (e *engine) IngestQueueStats() IngestQueueStats {
+	return e.ingestQ.stats()
+}
+
+// QueryCacheStats reports hits and misses of the query embedding cache.
+// With query workers the counts are summed over every worker's cache.
+func (e *engine) QueryCacheStats() retrieval.EmbeddingCacheStats {
+	stats := e.retriever.QueryCacheStats()
+	if e.workers != nil {
+		for _, w := range e.workers.workers {
+			ws := w.retriever.QueryCacheStats()
+			stats.Hits += ws.Hits
+			stats.Misses += ws.Misses
+			stats.Size += ws.Size
+			stats.Capacity += ws.Capacity
+		}
+	}
+	return stats
+}
+
+// TranslationCacheStats reports hits, misses, and saved tokens of the
+// query translation cache, which query workers share.
+func (e *engine) TranslationCacheStats() retrieval.TranslationCacheStats {
+	return e.retriever.TranslationCacheStats()
+}
+
+// ProviderUsage reports the calls, errors, tokens, and time of each LLM
+// provider and model by operation and phase. Query workers share the
+// primary's providers, so their calls are included.
+func (e *engine) ProviderUsage() []llm.MeterRecord {
+	return e.meter.Snapshot()
+}
+
+// GraphExtractionStats reports how graph extraction replies were parsed.
+func (e *engine) GraphExtractionStats() graph.ExtractionStats {
+	return e.graphB.ExtractionStats()
+}
+
+// WriteQueueStats reports contention on the store's writer. Query workers
+// write through the primary's store, so theirs is the one reported.
+func (e *engine) WriteQueueStats() store.WriteQueueStats {
+	return e.writeStore().WriteQueueStats()
+}
+
 // Store returns the underlying store for diagnostic access.
 func (e *engine) Store() *store.Store {
 	return e.store
@@ -812,6 +2313,31 @@ func (e *engine) Store() *store.Store {
 
 // Close shuts down the engine.
 func (e *engine) Close() error {
+	if e.workers != nil {
+		e.workers.close()
+	}
+	if e.stopMirror != nil {
+		e.stopMirror()
+	}
+	if e.repl != nil && e.repl.stop != nil {
+		e.repl.stop()
+	}
+	if e.stopCommunityRefresh != nil {
+		e.stopCommunityRefresh()
+	}
+	if e.stopSessionExpiry != nil {
+		e.stopSessionExpiry()
+	}
+	if e.stopSecondaryBackfill != nil {
+		e.stopSecondaryBackfill()
+	}
+	// In-process embedders (the onnx provider) hold native resources.
+	for _, p := range []llm.Provider{e.embedLLM, e.secondaryLLM} {
+		if c, ok := p.(io.Closer); ok {
+			c.Close()
+		}
+	}
+	e.querySink.close()
 	return e.store.Close()
 }
 
@@ -821,23 +2347,16 @@ func (e *engine) Close() error {
 // languages where token/char ratios differ from English.
 const maxEmbedChars = 24000
 
-// truncateForEmbed truncates text to maxEmbedChars on a word boundary.
-func truncateForEmbed(text string) string {
-	if len(text) <= maxEmbedChars {
-		return text
-	}
-	// Cut at the last space before the limit to avoid splitting a word.
-	cut := strings.LastIndex(text[:maxEmbedChars], " ")
-	if cut <= 0 {
-		cut = maxEmbedChars
-	}
-	return text[:cut]
-}
-
 // embedChunks generates embeddings for chunks in batches.
 // Individual batch failures trigger per-text fallback so a single oversized
-// text does not cause the entire batch to be lost.
-func (e *engine) embedChunks(ctx context.Context, chunks []store.Chunk, chunkIDs []int64) error {
+// text does not cause the entire batch to be lost. It returns how many
+// chunks got no embedding, failing only when none did.
+func (e *engine) embedChunks(ctx context.Context, chunks []store.Chunk, chunkIDs []int64) (int, error) {
+	return e.embedChunksInto(ctx, chunks, chunkIDs, e.store.InsertEmbedding)
+}
+
+// embedChunksInto is embedChunks storing each vector with insert.
+func (e *engine) embedChunksInto(ctx context.Context, chunks []store.Chunk, chunkIDs []int64, insert func(context.Context, int64, []float32) error) (int, error) {
 	const batchSize = 32
 	var failed int
 
@@ -853,19 +2372,19 @@ func (e *engine) embedChunks(ctx context.Context, chunks []store.Chunk, chunkIDs
 			if chunks[j].Heading != "" {
 				prefix = chunks[j].Heading + ": "
 			}
-			texts[j-i] = truncateForEmbed(prefix + chunks[j].Content)
+			texts[j-i] = e.embedText(ctx, chunks[j].DocumentID, prefix+chunks[j].Content)
 		}
 
 		embeddings, err := e.embedLLM.Embed(ctx, texts)
 		if err != nil {
 			// Batch failed — fall back to embedding each text individually
 			// so one oversized text doesn't lose the entire batch.
-			slog.Warn("embedding batch failed, falling back to individual",
+			slog.WarnContext(ctx, "embedding batch failed, falling back to individual",
 				"batch_start", i, "batch_end", end, "error", err)
 			for j, text := range texts {
 				single, serr := e.embedLLM.Embed(ctx, []string{text})
 				if serr != nil {
-					slog.Warn("embedding single text failed",
+					slog.WarnContext(ctx, "embedding single text failed",
 						"chunk_id", chunkIDs[i+j], "error", serr)
 					failed++
 					continue
@@ -874,8 +2393,8 @@ func (e *engine) embedChunks(ctx context.Context, chunks []store.Chunk, chunkIDs
 					failed++
 					continue
 				}
-				if serr := e.store.InsertEmbedding(ctx, chunkIDs[i+j], single[0]); serr != nil {
-					slog.Warn("storing embedding failed",
+				if serr := insert(ctx, chunkIDs[i+j], single[0]); serr != nil {
+					slog.WarnContext(ctx, "storing embedding failed",
 						"chunk_id", chunkIDs[i+j], "error", serr)
 					failed++
 				}
@@ -884,8 +2403,8 @@ func (e *engine) embedChunks(ctx context.Context, chunks []store.Chunk, chunkIDs
 		}
 
 		for j, emb := range embeddings {
-			if err := e.store.InsertEmbedding(ctx, chunkIDs[i+j], emb); err != nil {
-				slog.Warn("storing embedding failed",
+			if err := insert(ctx, chunkIDs[i+j], emb); err != nil {
+				slog.WarnContext(ctx, "storing embedding failed",
 					"chunk_id", chunkIDs[i+j], "error", err)
 				failed++
 			}
@@ -893,14 +2412,111 @@ func (e *engine) embedChunks(ctx context.Context, chunks []store.Chunk, chunkIDs
 	}
 
 	if failed == len(chunks) {
-		return fmt.Errorf("all %d chunks failed embedding", len(chunks))
+		return failed, fmt.Errorf("all %d chunks failed embedding", len(chunks))
 	}
 	if failed > 0 {
-		slog.Warn("some embeddings failed", "failed", failed, "total", len(chunks))
+		slog.WarnContext(ctx, "some embeddings failed", "failed", failed, "total", len(chunks))
+	}
+	return failed, nil
+}
+
+// embedChunksSparse generates learned sparse embeddings for chunks in batches.
+// Failures are counted per batch; the sparse leg is an optional recall boost,
+// so callers treat the returned error as non-fatal.
+func (e *engine) embedChunksSparse(ctx context.Context, chunks []store.Chunk, chunkIDs []int64) error {
+	const batchSize = 32
+	var failed int
+
+	for i := 0; i < len(chunks); i += batchSize {
+		end := i + batchSize
+		if end > len(chunks) {
+			end = len(chunks)
+		}
+
+		texts := make([]string, end-i)
+		for j := i; j < end; j++ {
+			prefix := ""
+			if chunks[j].Heading != "" {
+				prefix = chunks[j].Heading + ": "
+			}
+			texts[j-i] = e.embedText(ctx, chunks[j].DocumentID, prefix+chunks[j].Content)
+		}
+
+		vectors, err := e.sparseLLM.EmbedSparse(ctx, texts)
+		if err != nil {
+			slog.WarnContext(ctx, "sparse embedding batch failed",
+				"batch_start", i, "batch_end", end, "error", err)
+			failed += end - i
+			continue
+		}
+
+		for j, v := range vectors {
+			if err := e.store.InsertSparseEmbedding(ctx, chunkIDs[i+j], v.Indices, v.Values); err != nil {
+				slog.WarnContext(ctx, "storing sparse embedding failed",
+					"chunk_id", chunkIDs[i+j], "error", err)
+				failed++
+			}
+		}
+	}
+
+	if failed == len(chunks) && len(chunks) > 0 {
+		return fmt.Errorf("all %d chunks failed sparse embedding", len(chunks))
+	}
+	if failed > 0 {
+		slog.WarnContext(ctx, "some sparse embeddings failed", "failed", failed, "total", len(chunks))
 	}
 	return nil
 }
 
+// embedImages generates multimodal embeddings for a document's stored chunk
+// images that do not have one yet. Returns the number of images embedded.
+func (e *engine) embedImages(ctx context.Context, docID int64) (int, error) {
+	const batchSize = 8
+	images, err := e.store.ImagesWithoutEmbedding(ctx, docID)
+	if err != nil {
+		return 0, fmt.Errorf("listing images: %w", err)
+	}
+
+	var embedded, failed int
+	for i := 0; i < len(images); i += batchSize {
+		end := i + batchSize
+		if end > len(images) {
+			end = len(images)
+		}
+
+		inputs := make([]llm.ImageInput, end-i)
+		for j := i; j < end; j++ {
+			inputs[j-i] = llm.ImageInput{Data: images[j].Data, MIMEType: images[j].MIMEType}
+		}
+
+		vectors, err := e.imageLLM.EmbedImages(ctx, inputs)
+		if err != nil {
+			slog.WarnContext(ctx, "image embedding batch failed",
+				"batch_start", i, "batch_end", end, "error", err)
+			failed += end - i
+			continue
+		}
+
+		for j, v := range vectors {
+			if err := e.store.InsertImageEmbedding(ctx, images[i+j].ID, v); err != nil {
+				slog.WarnContext(ctx, "storing image embedding failed",
+					"image_id", images[i+j].ID, "error", err)
+				failed++
+				continue
+			}
+			embedded++
+		}
+	}
+
+	if failed == len(images) && len(images) > 0 {
+		return 0, fmt.Errorf("all %d images failed embedding", len(images))
+	}
+	if failed > 0 {
+		slog.WarnContext(ctx, "some image embeddings failed", "failed", failed, "total", len(images))
+	}
+	return embedded, nil
+}
+
 // captionedImage holds a parsed image with its caption and originating section.
 type captionedImage struct {
 	image        parser.ExtractedImage
@@ -924,7 +2540,7 @@ func (e *engine) captionImages(ctx context.Context, sections []parser.Section, i
 		if ok {
 			visionLLM = vp
 		} else {
-			slog.Warn("ingest: caption_images enabled but vision LLM does not support ChatWithImages, falling back to [image] markers")
+			slog.WarnContext(ctx, "ingest: caption_images enabled but vision LLM does not support ChatWithImages, falling back to [image] markers")
 		}
 	}
 
@@ -992,7 +2608,7 @@ func (e *engine) captionImages(ctx context.Context, sections []parser.Section, i
 				MaxTokens: 256,
 			})
 			if err != nil {
-				slog.Warn("ingest: image captioning failed, using [image]",
+				slog.WarnContext(ctx, "ingest: image captioning failed, using [image]",
 					"page", key.page, "error", err)
 				caption = ""
 			} else {
@@ -1031,7 +2647,7 @@ func (e *engine) captionImages(ctx context.Context, sections []parser.Section, i
 		}
 	}
 
-	slog.Info("ingest: image processing complete",
+	slog.InfoContext(ctx, "ingest: image processing complete",
 		"total_images", len(images),
 		"pages_captioned", pagesCaptioned,
 		"captioning_enabled", visionLLM != nil,
@@ -1191,7 +2807,7 @@ func (e *engine) formatAnswerAsJSON(ctx context.Context, answerText string) (*js
 	if err == nil {
 		return result, pt, ct, nil
 	}
-	slog.Debug("json format attempt 1 failed, retrying", "error", err)
+	slog.DebugContext(ctx, "json format attempt 1 failed, retrying", "error", err)
 
 	// Attempt 2: stricter prompt
 	result2, pt2, ct2, err := e.tryJSONFormat(ctx, fmt.Sprintf(jsonRetryPrompt, answerText))
@@ -1200,7 +2816,7 @@ func (e *engine) formatAnswerAsJSON(ctx context.Context, answerText string) (*js
 	if err == nil {
 		return result2, pt, ct, nil
 	}
-	slog.Debug("json format attempt 2 failed, falling back to keywords", "error", err)
+	slog.DebugContext(ctx, "json format attempt 2 failed, falling back to keywords", "error", err)
 
 	// Attempt 3: keyword fallback (always succeeds)
 	return keywordFallback(answerText), pt, ct, nil
@@ -1,6 +1,14 @@
 package goreason
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bbiangul/go-reason/llm"
+	"github.com/bbiangul/go-reason/llm/llmtest"
+)
 
 func TestKeywordFallback(t *testing.T) {
 	tests := []struct {
@@ -82,3 +90,105 @@ func TestKeywordFallback(t *testing.T) {
 		})
 	}
 }
+
+func TestIngestImportance(t *testing.T) {
+	eng, _, path := faultEngine(t, nil)
+	ctx := context.Background()
+
+	if _, err := eng.Ingest(ctx, path, WithImportance(-1)); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("Ingest with negative importance = %v, want ErrInvalidConfig", err)
+	}
+	docID, err := eng.Ingest(ctx, path)
+	if err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	importance := func() float64 {
+		doc, err := eng.Document(ctx, docID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return doc.Importance
+	}
+	if got := importance(); got != 1 {
+		t.Errorf("default importance = %v, want 1", got)
+	}
+
+	// Re-ingesting the unchanged file only sets the importance, and
+	// re-ingesting without the option keeps it.
+	if _, err := eng.Ingest(ctx, path, WithImportance(2)); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	if _, err := eng.Ingest(ctx, path, WithForceReparse()); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	if got := importance(); got != 2 {
+		t.Errorf("importance = %v, want 2", got)
+	}
+	if weights, _ := eng.(*engine).store.DocumentImportance(ctx); weights[docID] != 2 {
+		t.Errorf("DocumentImportance = %v", weights)
+	}
+}
+
+func TestQueryReasoningSearch(t *testing.T) {
+	eng, srv, path := faultEngine(t, nil)
+	eng.(*engine).cfg.ReasoningSearchBudget = 2
+	var offers int
+	srv.ToolFunc = func(prompt string) []llmtest.ToolCall {
+		if offers++; offers == 1 {
+			return []llmtest.ToolCall{{Name: "search", Arguments: `{"query": "seat seal replacement"}`}}
+		}
+		return nil
+	}
+	ctx := context.Background()
+	if _, err := eng.Ingest(ctx, path); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+
+	answer, err := eng.Query(ctx, "When does the relief valve open?")
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if answer.Searches != 1 || len(answer.Reasoning) == 0 || answer.Reasoning[0].Action != "search" ||
+		answer.Reasoning[0].Input != "seat seal replacement" {
+		t.Fatalf("searches = %d, reasoning = %+v", answer.Searches, answer.Reasoning)
+	}
+	if !strings.Contains(answer.Text, "10 bar") {
+		t.Errorf("answer = %q", answer.Text)
+	}
+	// The round is asked again, offering the one search left.
+	if offers != 2 {
+		t.Errorf("search offered %d times, want 2", offers)
+	}
+}
+
+func TestProviderUsage(t *testing.T) {
+	eng, _, path := faultEngine(t, nil)
+	ctx := context.Background()
+	if _, err := eng.Ingest(ctx, path); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	if _, err := eng.Query(ctx, "At what pressure does the relief valve open?"); err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+
+	got := make(map[llm.MeterKey]llm.MeterStats)
+	for _, r := range eng.ProviderUsage() {
+		got[r.MeterKey] = r.MeterStats
+	}
+	key := func(op, phase string) llm.MeterKey {
+		return llm.MeterKey{Provider: "custom", Model: "fake", Operation: op, Phase: phase}
+	}
+	for _, k := range []llm.MeterKey{
+		key(llm.OperationEmbed, llm.PhaseIngest),
+		key(llm.OperationChat, llm.PhaseIngest),
+		key(llm.OperationEmbed, llm.PhaseQuery),
+		key(llm.OperationChat, llm.PhaseQuery),
+	} {
+		if s := got[k]; s.Calls == 0 || s.Errors != 0 {
+			t.Errorf("%+v = %+v, want calls without errors", k, s)
+		}
+	}
+	if s := got[key(llm.OperationEmbed, llm.PhaseIngest)]; s.Inputs == 0 {
+		t.Errorf("ingest embedded no inputs: %+v", s)
+	}
+}
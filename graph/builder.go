@@ -184,16 +184,29 @@ func preExtractIdentifiers(text string) []string {
 	return identifiers
 }
 
+// Store is the storage the builder writes the graph to. *store.Store
+// implements it.
+type Store interface {
+	store.DocumentStore
+	store.GraphStore
+}
+
 // Builder constructs the knowledge graph from document chunks.
 type Builder struct {
-	store       *store.Store
+	store       Store
 	chat        llm.Provider
 	embed       llm.Provider
 	concurrency int
+	counters    extractionCounters
+
+	// onlyTypes and skipTypes select the chunk types extracted (see
+	// SetChunkTypes); nil extracts all.
+	onlyTypes map[string]bool
+	skipTypes map[string]bool
 }
 
 // NewBuilder creates a new graph builder.
-func NewBuilder(s *store.Store, chat, embed llm.Provider, concurrency int) *Builder {
+func NewBuilder(s Store, chat, embed llm.Provider, concurrency int) *Builder {
 	if concurrency <= 0 {
 		concurrency = defaultConcurrency
 	}
@@ -205,20 +218,56 @@ func NewBuilder(s *store.Store, chat, embed llm.Provider, concurrency int) *Buil
 	}
 }
 
-// Build extracts entities and relationships from chunks and stores them.
-// chunks and chunkIDs correspond by index.
-func (b *Builder) Build(ctx context.Context, docID int64, chunks []store.Chunk, chunkIDs []int64) error {
+// SetChunkTypes limits extraction to chunks whose type is in only (all
+// types when empty) and not in skip. Other chunks are counted as skipped.
+func (b *Builder) SetChunkTypes(only, skip []string) {
+	b.onlyTypes, b.skipTypes = typeSet(only), typeSet(skip)
+}
+
+// extractsType reports whether chunks of the given type are extracted.
+func (b *Builder) extractsType(chunkType string) bool {
+	if b.skipTypes[chunkType] {
+		return false
+	}
+	return b.onlyTypes == nil || b.onlyTypes[chunkType]
+}
+
+func typeSet(types []string) map[string]bool {
+	if len(types) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(types))
+	for _, t := range types {
+		set[t] = true
+	}
+	return set
+}
+
+// Build extracts entities and relationships from chunks and stores them,
+// and reports how much of the graph it built. chunks and chunkIDs
+// correspond by index. progress, when set, receives the report so far
+// after each chunk. A chunk whose extraction fails is left out of the
+// graph and counted in the report; Build fails only when every eligible
+// chunk failed, still returning the report.
+func (b *Builder) Build(ctx context.Context, docID int64, chunks []store.Chunk, chunkIDs []int64, progress func(BuildReport)) (*BuildReport, error) {
 	if len(chunks) != len(chunkIDs) {
-		return fmt.Errorf("graph.Build: chunks and chunkIDs length mismatch (%d vs %d)", len(chunks), len(chunkIDs))
+		return nil, fmt.Errorf("graph.Build: chunks and chunkIDs length mismatch (%d vs %d)", len(chunks), len(chunkIDs))
 	}
 
-	// Filter out trivial chunks (headers, TOC entries, etc.)
+	// Filter out chunk types left out of the graph and trivial chunks
+	// (headers, TOC entries, etc.)
 	type indexedChunk struct {
 		chunk   store.Chunk
 		chunkID int64
 	}
 	var eligible []indexedChunk
+	var skippedTypes int
 	for i := range chunks {
+		if !b.extractsType(chunks[i].ChunkType) {
+			slog.Debug("graph: skipping chunk type", "chunk_id", chunkIDs[i], "chunk_type", chunks[i].ChunkType)
+			skippedTypes++
+			continue
+		}
 		if estimateTokens(chunks[i].Content) < minChunkTokens {
 			slog.Debug("graph: skipping trivial chunk", "chunk_id", chunkIDs[i],
 				"tokens", estimateTokens(chunks[i].Content))
@@ -227,8 +276,9 @@ func (b *Builder) Build(ctx context.Context, docID int64, chunks []store.Chunk,
 		eligible = append(eligible, indexedChunk{chunks[i], chunkIDs[i]})
 	}
 
+	report := &BuildReport{Chunks: len(chunks), Skipped: len(chunks) - len(eligible), SkippedTypes: skippedTypes}
 	if len(eligible) == 0 {
-		return nil
+		return report, nil
 	}
 
 	slog.Info("graph: processing chunks", "total", len(chunks), "eligible", len(eligible),
@@ -238,14 +288,28 @@ func (b *Builder) Build(ctx context.Context, docID int64, chunks []store.Chunk,
 		mu         sync.Mutex
 		wg         sync.WaitGroup
 		sem        = make(chan struct{}, b.concurrency)
-		errs       []string
-		completed  int
+		run        = new(buildCounters)
 		langVotes  = make(map[string]int)
 		buildStart = time.Now()
 	)
 
 	total := len(eligible)
 
+	// fail and done record a chunk's outcome; mu must be held.
+	fail := func(chunkID int64, err error) {
+		report.Failed++
+		report.addError(fmt.Sprintf("chunk %d: %v", chunkID, err))
+	}
+	done := func() {
+		if progress != nil {
+			r := *report
+			run.fill(&r)
+			r.Errors = append([]string(nil), report.Errors...)
+			r.ElapsedMs = time.Since(buildStart).Milliseconds()
+			progress(r)
+		}
+	}
+
 	for _, ic := range eligible {
 		wg.Add(1)
 		go func(chunk store.Chunk, chunkID int64) {
@@ -257,7 +321,8 @@ func (b *Builder) Build(ctx context.Context, docID int64, chunks []store.Chunk,
 				defer func() { <-sem }()
 			case <-ctx.Done():
 				mu.Lock()
-				errs = append(errs, fmt.Sprintf("chunk %d: %v", chunkID, ctx.Err()))
+				fail(chunkID, ctx.Err())
+				done()
 				mu.Unlock()
 				return
 			}
@@ -267,27 +332,30 @@ func (b *Builder) Build(ctx context.Context, docID int64, chunks []store.Chunk,
 			defer cancel()
 
 			chunkStart := time.Now()
-			lang, err := b.processChunk(chunkCtx, chunk, chunkID)
+			out, err := b.processChunk(chunkCtx, chunk, chunkID, run)
 			if err != nil {
 				slog.Warn("graph: chunk failed",
 					"chunk_id", chunkID, "error", err,
 					"elapsed", time.Since(chunkStart).Round(time.Millisecond))
 				mu.Lock()
-				errs = append(errs, fmt.Sprintf("chunk %d: %v", chunkID, err))
-				completed++
+				fail(chunkID, err)
+				done()
 				mu.Unlock()
 			} else {
 				mu.Lock()
-				completed++
-				if lang != "" {
-					langVotes[lang]++
+				report.Processed++
+				report.Entities += out.entities
+				report.Relationships += out.relationships
+				if out.language != "" {
+					langVotes[out.language]++
 				}
-				n := completed
+				n := report.Processed + report.Failed
+				done()
 				mu.Unlock()
 				slog.Info("graph: chunk processed",
 					"progress", fmt.Sprintf("%d/%d", n, total),
 					"chunk_id", chunkID,
-					"language", lang,
+					"language", out.language,
 					"elapsed", time.Since(chunkStart).Round(time.Millisecond),
 					"total_elapsed", time.Since(buildStart).Round(time.Millisecond))
 			}
@@ -295,14 +363,22 @@ func (b *Builder) Build(ctx context.Context, docID int64, chunks []store.Chunk,
 	}
 
 	wg.Wait()
+	run.fill(report)
+	report.ElapsedMs = time.Since(buildStart).Milliseconds()
 
-	if len(errs) == len(eligible) && len(eligible) > 0 {
-		return fmt.Errorf("graph.Build: all %d eligible chunks failed; first error: %s", len(eligible), errs[0])
+	if report.Failed == len(eligible) {
+		return report, fmt.Errorf("graph.Build: all %d eligible chunks failed; first error: %s", len(eligible), report.Errors[0])
 	}
-	if len(errs) > 0 {
+	if report.Failed > 0 {
 		slog.Warn("graph: build completed with failures",
-			"succeeded", len(eligible)-len(errs), "failed", len(errs), "total", len(eligible))
+			"succeeded", report.Processed, "failed", report.Failed, "total", len(eligible),
+			"llm_failures", report.LLMFailures)
 	}
+	stats := b.ExtractionStats()
+	slog.Info("graph: extraction parse stats",
+		"replies", stats.Replies, "repaired", stats.Repaired, "retried", stats.Retried,
+		"parse_failures", stats.ParseFailures, "dropped_items", stats.DroppedItems,
+		"failure_rate", fmt.Sprintf("%.3f", stats.ParseFailureRate()))
 
 	// Determine consensus language via majority vote and store on document.
 	if len(langVotes) > 0 {
@@ -325,7 +401,7 @@ func (b *Builder) Build(ctx context.Context, docID int64, chunks []store.Chunk,
 		}
 	}
 
-	return nil
+	return report, nil
 }
 
 // codeBlockRe strips markdown code fences from LLM output.
@@ -357,22 +433,23 @@ func extractJSON(raw string) (string, error) {
 }
 
 // entityResult is the JSON shape returned by the entity extraction LLM call.
+// Items are decoded one by one by validateEntities.
 type entityResult struct {
 	Language string            `json:"language"`
-	Entities []ExtractedEntity `json:"entities"`
+	Entities []json.RawMessage `json:"entities"`
 }
 
 // relationshipResult is the JSON shape returned by the relationship extraction
-// LLM call.
+// LLM call. Items are decoded one by one by validateRelationships.
 type relationshipResult struct {
-	Relationships []ExtractedRelationship `json:"relationships"`
+	Relationships []json.RawMessage `json:"relationships"`
 }
 
 // extractEntities calls the LLM with a focused entity-only prompt.
 // Pre-extracted identifiers are included as hints so the model does not miss
 // structured data like part numbers, standards, and measurements.
 // Returns the extracted entities and the detected language of the chunk.
-func (b *Builder) extractEntities(ctx context.Context, chunk store.Chunk) ([]ExtractedEntity, string, error) {
+func (b *Builder) extractEntities(ctx context.Context, chunk store.Chunk, run *buildCounters) ([]ExtractedEntity, string, error) {
 	identifiers := preExtractIdentifiers(chunk.Content)
 
 	var hintsSection string
@@ -385,33 +462,19 @@ func (b *Builder) extractEntities(ctx context.Context, chunk store.Chunk) ([]Ext
 
 	prompt := fmt.Sprintf(entityExtractionPrompt, hintsSection, chunk.Content)
 
-	resp, err := b.chat.Chat(ctx, llm.ChatRequest{
-		Messages: []llm.Message{
-			{Role: "user", Content: prompt},
-		},
-		Temperature:    0.0,
-		ResponseFormat: "json_object",
-	})
-	if err != nil {
-		return nil, "", fmt.Errorf("entity extraction llm chat: %w", err)
-	}
-
-	jsonStr, err := extractJSON(resp.Content)
-	if err != nil {
-		return nil, "", fmt.Errorf("parsing entity extraction result: %w", err)
-	}
-
 	var result entityResult
-	if err := json.Unmarshal([]byte(jsonStr), &result); err != nil {
-		return nil, "", fmt.Errorf("unmarshalling entity extraction result: %w", err)
+	if err := b.chatJSONCounted(ctx, run, prompt, &result); err != nil {
+		return nil, "", fmt.Errorf("entity extraction: %w", err)
 	}
 
-	return result.Entities, result.Language, nil
+	entities, dropped := validateEntities(result.Entities)
+	b.counters.droppedItems.Add(int64(dropped))
+	return entities, result.Language, nil
 }
 
 // extractRelationships calls the LLM with the known entities and asks it to
 // find only relationships (verbs) between them.
-func (b *Builder) extractRelationships(ctx context.Context, chunk store.Chunk, entities []ExtractedEntity) ([]ExtractedRelationship, error) {
+func (b *Builder) extractRelationships(ctx context.Context, chunk store.Chunk, entities []ExtractedEntity, run *buildCounters) ([]ExtractedRelationship, error) {
 	if len(entities) < 2 {
 		// Need at least two entities to form a relationship.
 		return nil, nil
@@ -429,42 +492,37 @@ func (b *Builder) extractRelationships(ctx context.Context, chunk store.Chunk, e
 	entitiesJSON, _ := json.Marshal(entityNames)
 	prompt := fmt.Sprintf(relationshipExtractionPrompt, string(entitiesJSON), chunk.Content)
 
-	resp, err := b.chat.Chat(ctx, llm.ChatRequest{
-		Messages: []llm.Message{
-			{Role: "user", Content: prompt},
-		},
-		Temperature:    0.0,
-		ResponseFormat: "json_object",
-	})
-	if err != nil {
-		return nil, fmt.Errorf("relationship extraction llm chat: %w", err)
-	}
-
-	jsonStr, err := extractJSON(resp.Content)
-	if err != nil {
-		return nil, fmt.Errorf("parsing relationship extraction result: %w", err)
-	}
-
 	var result relationshipResult
-	if err := json.Unmarshal([]byte(jsonStr), &result); err != nil {
-		return nil, fmt.Errorf("unmarshalling relationship extraction result: %w", err)
+	if err := b.chatJSONCounted(ctx, run, prompt, &result); err != nil {
+		return nil, fmt.Errorf("relationship extraction: %w", err)
 	}
 
-	return result.Relationships, nil
+	relationships, dropped := validateRelationships(result.Relationships)
+	b.counters.droppedItems.Add(int64(dropped))
+	return relationships, nil
+}
+
+// chunkOutcome is what processChunk stored for a chunk.
+type chunkOutcome struct {
+	language      string // detected language of the chunk
+	entities      int
+	relationships int
 }
 
 // processChunk orchestrates the multi-step extraction pipeline for a single
 // chunk: first extracts entities, then extracts relationships given those
-// entities, and finally persists the results. Returns the detected language.
-func (b *Builder) processChunk(ctx context.Context, chunk store.Chunk, chunkID int64) (string, error) {
+// entities, and finally persists the results. Its LLM calls are counted
+// in run.
+func (b *Builder) processChunk(ctx context.Context, chunk store.Chunk, chunkID int64, run *buildCounters) (chunkOutcome, error) {
 	// Step 1: Extract entities (atomic LLM call).
-	entities, language, err := b.extractEntities(ctx, chunk)
+	entities, language, err := b.extractEntities(ctx, chunk, run)
 	if err != nil {
-		return "", fmt.Errorf("step 1 (entities): %w", err)
+		return chunkOutcome{}, fmt.Errorf("step 1 (entities): %w", err)
 	}
+	out := chunkOutcome{language: language}
 
 	// Step 2: Extract relationships using the found entities (atomic LLM call).
-	relationships, err := b.extractRelationships(ctx, chunk, entities)
+	relationships, err := b.extractRelationships(ctx, chunk, entities, run)
 	if err != nil {
 		// Non-fatal: we still have entities to persist.
 		slog.Warn("graph: relationship extraction failed, persisting entities only",
@@ -482,6 +540,18 @@ func (b *Builder) processChunk(ctx context.Context, chunk store.Chunk, chunkID i
 	// reference the correct rows.
 	entityIDMap := make(map[string]int64, len(result.Entities))
 
+	// Entities the graph already holds, keyed by name and type.
+	names := make([]string, 0, len(result.Entities))
+	for _, e := range result.Entities {
+		names = append(names, strings.TrimSpace(strings.ToLower(e.Name)))
+	}
+	known := make(map[[2]string]int64)
+	if existing, err := b.store.GetEntitiesByNames(ctx, names); err == nil {
+		for _, e := range existing {
+			known[[2]string{e.Name, e.EntityType}] = e.ID
+		}
+	}
+
 	for _, e := range result.Entities {
 		name := strings.TrimSpace(strings.ToLower(e.Name))
 		if name == "" {
@@ -494,19 +564,34 @@ func (b *Builder) processChunk(ctx context.Context, chunk store.Chunk, chunkID i
 
 		nameEN := strings.TrimSpace(strings.ToLower(e.NameEN))
 
-		// Upsert + link in a single transaction to avoid FK race conditions.
-		id, err := b.store.UpsertEntityAndLink(ctx, store.Entity{
+		entity := store.Entity{
 			Name:        name,
 			EntityType:  eType,
 			Description: e.Description,
 			NameEN:      nameEN,
-		}, chunkID)
+		}
+		key := [2]string{name, eType}
+		id, ok := known[key]
+		var err error
+		if ok {
+			// After an update UpsertEntityAndLink may return the
+			// connection's last inserted row rather than the entity's, so
+			// an existing entity is updated and linked by its own ID.
+			if _, err = b.store.UpsertEntity(ctx, entity); err == nil {
+				err = b.store.LinkEntityChunk(ctx, id, chunkID)
+			}
+		} else {
+			// Upsert + link in a single transaction to avoid FK race conditions.
+			id, err = b.store.UpsertEntityAndLink(ctx, entity, chunkID)
+		}
 		if err != nil {
 			slog.Warn("graph: entity upsert+link failed, skipping",
 				"entity", name, "chunk", chunkID, "error", err)
 			continue
 		}
+		known[key] = id
 		entityIDMap[name] = id
+		out.entities++
 	}
 
 	for _, r := range result.Relationships {
@@ -553,7 +638,8 @@ func (b *Builder) processChunk(ctx context.Context, chunk store.Chunk, chunkID i
 				"source", srcName, "target", tgtName, "error", err)
 			continue
 		}
+		out.relationships++
 	}
 
-	return language, nil
+	return out, nil
 }
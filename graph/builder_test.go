@@ -5,9 +5,14 @@ package graph
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/bbiangul/go-reason/llm"
 	"github.com/bbiangul/go-reason/store"
 )
 
@@ -225,6 +230,244 @@ func TestExtractionResultParsing(t *testing.T) {
 	}
 }
 
+func TestRepairJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  int // entities that must survive
+	}{
+		{"trailing commas", `{"entities": [{"name": "a", "type": "term",}, {"name": "b"},],}`, 2},
+		{"typographic quotes", `{“entities”: [{“name”: “a”}]}`, 1},
+		{"raw newline in string", "{\"entities\": [{\"name\": \"a\", \"description\": \"line one\nline two\"}]}", 1},
+		{"truncated mid-item", `{"language": "English", "entities": [{"name": "a"}, {"name": "b"}, {"name": "c", "desc`, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result entityResult
+			repaired, err := decodeLLMJSON(tt.input, &result)
+			if err != nil {
+				t.Fatalf("decodeLLMJSON: %v (repaired: %s)", err, repairJSON(tt.input))
+			}
+			if !repaired {
+				t.Error("expected the input to need repair")
+			}
+			if len(result.Entities) != tt.want {
+				t.Errorf("entities = %d, want %d", len(result.Entities), tt.want)
+			}
+		})
+	}
+
+	var result entityResult
+	if repaired, err := decodeLLMJSON("```json\n{\"entities\": []}\n```", &result); err != nil || repaired {
+		t.Errorf("fenced valid JSON: repaired=%v err=%v", repaired, err)
+	}
+}
+
+func TestValidateExtractionItems(t *testing.T) {
+	raw := func(items ...string) []json.RawMessage {
+		out := make([]json.RawMessage, len(items))
+		for i, s := range items {
+			out[i] = json.RawMessage(s)
+		}
+		return out
+	}
+
+	entities, dropped := validateEntities(raw(
+		`{"name": " ISO 9001 ", "type": "Standard"}`,
+		`{"name": "", "type": "term"}`,
+		`{"name": "widget", "type": "gadget"}`,
+		`"just a string"`,
+	))
+	if dropped != 2 || len(entities) != 2 {
+		t.Fatalf("entities = %+v, dropped = %d", entities, dropped)
+	}
+	if entities[0].Name != "iso 9001" || entities[0].Type != EntityStandard || entities[1].Type != EntityConcept {
+		t.Errorf("entities not normalised: %+v", entities)
+	}
+
+	rels, dropped := validateRelationships(raw(
+		`{"source": "a", "target": "b", "relation_type": "complies_with", "weight": 3}`,
+		`{"source": "a", "target": "a", "relation_type": "defines"}`,
+		`{"source": "a", "target": "b", "weight": "high"}`,
+	))
+	if dropped != 2 || len(rels) != 1 {
+		t.Fatalf("relationships = %+v, dropped = %d", rels, dropped)
+	}
+	if rels[0].RelationType != RelReferences || rels[0].Weight != 1 {
+		t.Errorf("relationship not normalised: %+v", rels[0])
+	}
+}
+
+// scriptedChat returns canned replies in order.
+type scriptedChat struct {
+	replies []string
+	calls   [][]llm.Message
+}
+
+func (p *scriptedChat) Chat(_ context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	p.calls = append(p.calls, req.Messages)
+	return &llm.ChatResponse{Content: p.replies[len(p.calls)-1]}, nil
+}
+
+func (p *scriptedChat) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	return make([][]float32, len(texts)), nil
+}
+
+func TestChatJSONRetry(t *testing.T) {
+	p := &scriptedChat{replies: []string{"I cannot comply.", `{"relationships": []}`}}
+	b := NewBuilder(nil, p, p, 1)
+
+	var result relationshipResult
+	if err := b.chatJSON(context.Background(), "extract", &result); err != nil {
+		t.Fatalf("chatJSON: %v", err)
+	}
+	if len(p.calls) != 2 || len(p.calls[1]) != 3 || p.calls[1][1].Content != "I cannot comply." {
+		t.Errorf("retry must replay the bad reply and ask for a correction, got %+v", p.calls)
+	}
+
+	p = &scriptedChat{replies: []string{"no", "still no"}}
+	b = NewBuilder(nil, p, p, 1)
+	if err := b.chatJSON(context.Background(), "extract", &result); err == nil {
+		t.Fatal("expected an error after the retry fails")
+	}
+	stats := b.ExtractionStats()
+	if stats.Replies != 2 || stats.Retried != 1 || stats.ParseFailures != 1 || stats.ParseFailureRate() != 0.5 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+// promptChat answers each prompt with reply(prompt); it is safe for
+// concurrent use.
+type promptChat struct {
+	reply func(prompt string) string
+}
+
+func (p promptChat) Chat(_ context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	return &llm.ChatResponse{Content: p.reply(req.Messages[0].Content)}, nil
+}
+
+func (p promptChat) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	return make([][]float32, len(texts)), nil
+}
+
+func TestBuildReport(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	docID, err := s.UpsertDocument(ctx, store.Document{
+		Path: "/tmp/pump.pdf", Filename: "pump.pdf", Format: "pdf", ContentHash: "h", ParseMethod: "native", Status: "processing",
+	})
+	if err != nil {
+		t.Fatalf("upserting document: %v", err)
+	}
+	long := func(s string) string { return strings.Repeat(s+" ", 8) }
+	chunks := []store.Chunk{
+		{DocumentID: docID, Content: long("The relief valve protects the pump against overpressure."), ChunkType: "text"},
+		{DocumentID: docID, Content: long("The gearbox is filled with synthetic oil every year."), ChunkType: "text"},
+		{DocumentID: docID, Content: "Contents", ChunkType: "text"},
+	}
+	ids, err := s.InsertChunks(ctx, chunks)
+	if err != nil {
+		t.Fatalf("inserting chunks: %v", err)
+	}
+
+	// The gearbox chunk gets unparseable replies, also after the retry.
+	p := promptChat{reply: func(prompt string) string {
+		switch {
+		case strings.Contains(prompt, "gearbox"):
+			return "I cannot help with that."
+		case strings.Contains(prompt, "KNOWN ENTITIES"):
+			return `{"relationships": [{"source": "relief valve", "target": "pump", "relation_type": "part_of"}]}`
+		default:
+			return `{"language": "en", "entities": [{"name": "relief valve", "type": "component"}, {"name": "pump", "type": "component"}]}`
+		}
+	}}
+	b := NewBuilder(s, p, p, 2)
+	var updates []BuildReport
+	report, err := b.Build(ctx, docID, chunks, ids, func(r BuildReport) { updates = append(updates, r) })
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	want := BuildReport{Chunks: 3, Skipped: 1, Processed: 1, Failed: 1, Entities: 2, Relationships: 1,
+		LLMCalls: 4, LLMFailures: 1, RetriedCalls: 1}
+	got := *report
+	got.Errors, got.ElapsedMs = nil, 0
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("report = %+v, want %+v", got, want)
+	}
+	if len(report.Errors) != 1 || !strings.Contains(report.Errors[0], fmt.Sprintf("chunk %d:", ids[1])) {
+		t.Errorf("errors = %q", report.Errors)
+	}
+	if len(updates) != 2 || updates[1].Pending() != 0 || updates[0].Pending() != 1 {
+		t.Errorf("progress updates = %+v", updates)
+	}
+
+	// When every chunk fails the report still comes back with the error.
+	report, err = b.Build(ctx, docID, chunks[1:2], ids[1:2], nil)
+	if err == nil || report == nil || report.Failed != 1 {
+		t.Errorf("Build of failing chunks = %+v, %v", report, err)
+	}
+
+	var total BuildReport
+	total.Add(&want)
+	total.Add(report)
+	if total.Chunks != 4 || total.Failed != 2 || total.LLMFailures != 2 || len(total.Errors) != 1 {
+		t.Errorf("accumulated report = %+v", total)
+	}
+}
+
+func TestBuildChunkTypes(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	docID, err := s.UpsertDocument(ctx, store.Document{
+		Path: "/tmp/pump.pdf", Filename: "pump.pdf", Format: "pdf", ContentHash: "h", ParseMethod: "native", Status: "processing",
+	})
+	if err != nil {
+		t.Fatalf("upserting document: %v", err)
+	}
+	long := func(s string) string { return strings.Repeat(s+" ", 8) }
+	chunks := []store.Chunk{
+		{DocumentID: docID, Content: long("The relief valve protects the pump against overpressure."), ChunkType: "paragraph"},
+		{DocumentID: docID, Content: long("| valve | 10 bar | 8 bar |"), ChunkType: "table"},
+		{DocumentID: docID, Content: long("Overpressure means a pressure above the rated pressure."), ChunkType: "definition"},
+	}
+	ids, err := s.InsertChunks(ctx, chunks)
+	if err != nil {
+		t.Fatalf("inserting chunks: %v", err)
+	}
+
+	var mu sync.Mutex
+	var prompts []string
+	p := promptChat{reply: func(prompt string) string {
+		mu.Lock()
+		prompts = append(prompts, prompt)
+		mu.Unlock()
+		return `{"language": "en", "entities": [{"name": "relief valve", "type": "component"}]}`
+	}}
+	tests := []struct {
+		only, skip []string
+		processed  int
+	}{
+		{nil, []string{"table"}, 2},
+		{[]string{"paragraph"}, nil, 1},
+		{nil, nil, 3},
+	}
+	for _, tt := range tests {
+		prompts = nil
+		b := NewBuilder(s, p, p, 2)
+		b.SetChunkTypes(tt.only, tt.skip)
+		report, err := b.Build(ctx, docID, chunks, ids, nil)
+		if err != nil {
+			t.Fatalf("Build(%v, %v): %v", tt.only, tt.skip, err)
+		}
+		if report.Processed != tt.processed || report.SkippedTypes != 3-tt.processed || report.Skipped != 3-tt.processed {
+			t.Errorf("Build(%v, %v) = %+v", tt.only, tt.skip, report)
+		}
+		if tt.processed < 3 && strings.Contains(strings.Join(prompts, "\n"), "| valve |") {
+			t.Errorf("Build(%v, %v) extracted the table", tt.only, tt.skip)
+		}
+	}
+}
+
 func TestCommunityDetection(t *testing.T) {
 	s := newTestStore(t)
 	ctx := context.Background()
@@ -279,6 +522,73 @@ func TestCommunityDetection(t *testing.T) {
 	}
 }
 
+func TestCommunityDetectionLevels(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	seedEntitiesAndRelationships(t, s)
+
+	communities, err := DetectCommunitiesWithOptions(ctx, s, CommunityOptions{Levels: 1})
+	if err != nil {
+		t.Fatalf("DetectCommunitiesWithOptions: %v", err)
+	}
+	if len(communities) != 1 || communities[0].Level != 0 {
+		t.Errorf("levels=1 must build one level-0 component only, got %+v", communities)
+	}
+	if l1, _ := s.GetCommunities(ctx, 1); len(l1) != 0 {
+		t.Errorf("expected no stored level-1 communities, got %d", len(l1))
+	}
+
+	if _, err := DetectCommunitiesWithOptions(ctx, s, CommunityOptions{Levels: 3}); err == nil {
+		t.Error("expected an error for levels=3")
+	}
+}
+
+func TestCommunitySummaryCarryOver(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	entityIDs, _ := seedEntitiesAndRelationships(t, s)
+
+	communities, err := DetectCommunitiesWithOptions(ctx, s, CommunityOptions{Levels: 1})
+	if err != nil {
+		t.Fatalf("DetectCommunitiesWithOptions: %v", err)
+	}
+	if !communities[0].Stale() {
+		t.Fatal("a new community must be stale")
+	}
+	if err := s.SetCommunitySummary(ctx, communities[0].ID, "ISO standards."); err != nil {
+		t.Fatal(err)
+	}
+
+	// Same members: the summary is kept and current.
+	communities, err = DetectCommunitiesWithOptions(ctx, s, CommunityOptions{Levels: 1})
+	if err != nil {
+		t.Fatalf("DetectCommunitiesWithOptions: %v", err)
+	}
+	if c := communities[0]; c.Summary != "ISO standards." || c.Stale() {
+		t.Errorf("unchanged community = %+v, want its current summary", c)
+	}
+
+	// A member's description changed: the summary is kept but stale.
+	if _, err := s.DB().ExecContext(ctx, "UPDATE entities SET description = 'Quality standard, 2015 edition' WHERE id = ?",
+		entityIDs["iso 9001"]); err != nil {
+		t.Fatal(err)
+	}
+	communities, err = DetectCommunitiesWithOptions(ctx, s, CommunityOptions{Levels: 1})
+	if err != nil {
+		t.Fatalf("DetectCommunitiesWithOptions: %v", err)
+	}
+	if c := communities[0]; c.Summary != "ISO standards." || !c.Stale() {
+		t.Errorf("changed community = %+v, want a stale summary", c)
+	}
+	stale, err := s.StaleCommunities(ctx)
+	if err != nil {
+		t.Fatalf("StaleCommunities: %v", err)
+	}
+	if len(stale) != 1 || stale[0].ID != communities[0].ID {
+		t.Errorf("StaleCommunities = %+v", stale)
+	}
+}
+
 func TestCommunityDetectionEmptyGraph(t *testing.T) {
 	s := newTestStore(t)
 	ctx := context.Background()
@@ -2,9 +2,12 @@ package graph
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"sort"
 	"strings"
 	"sync"
 
@@ -26,11 +29,32 @@ type edge struct {
 	weight float64
 }
 
+// CommunityOptions configures community detection.
+type CommunityOptions struct {
+	// Levels is the number of hierarchy levels to build: 1 keeps connected
+	// components only, 2 also splits large components by modularity.
+	// 0 means 2.
+	Levels int
+}
+
 // DetectCommunities runs community detection on the entity graph.
 // Level-0 communities are connected components. Components larger than
 // minComponentSplit are further split using greedy modularity optimisation and
 // stored as level-1 communities.
-func DetectCommunities(ctx context.Context, s *store.Store) ([]store.Community, error) {
+func DetectCommunities(ctx context.Context, s store.GraphStore) ([]store.Community, error) {
+	return DetectCommunitiesWithOptions(ctx, s, CommunityOptions{})
+}
+
+// DetectCommunitiesWithOptions is DetectCommunities with a configurable
+// number of levels. Existing communities are replaced; a new community with
+// the same level and members as an old one keeps its summary, which stays
+// current unless a member entity has changed since (see Community.Stale).
+func DetectCommunitiesWithOptions(ctx context.Context, s store.GraphStore, opts CommunityOptions) ([]store.Community, error) {
+	if opts.Levels < 0 || opts.Levels > 2 {
+		return nil, fmt.Errorf("community levels must be 1 or 2, got %d", opts.Levels)
+	}
+	splitLevels := opts.Levels != 1
+
 	entities, err := s.AllEntities(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("loading entities: %w", err)
@@ -41,6 +65,10 @@ func DetectCommunities(ctx context.Context, s *store.Store) ([]store.Community,
 	}
 
 	if len(entities) == 0 {
+		// Communities of a graph that has since been deleted are stale.
+		if err := s.ClearCommunities(ctx); err != nil {
+			return nil, fmt.Errorf("clearing communities: %w", err)
+		}
 		return nil, nil
 	}
 
@@ -95,6 +123,31 @@ func DetectCommunities(ctx context.Context, s *store.Store) ([]store.Community,
 	slog.Info("community: BFS found components",
 		"components", len(components), "largest", largestComp(components))
 
+	// Keep the summaries of communities that come out the same.
+	previous := make(map[string]store.Community)
+	for level := 0; level <= 1; level++ {
+		old, err := s.GetCommunities(ctx, level)
+		if err != nil {
+			return nil, fmt.Errorf("loading communities: %w", err)
+		}
+		for _, c := range old {
+			previous[fmt.Sprintf("%d:%s", c.Level, c.EntityIDs)] = c
+		}
+	}
+	newCommunity := func(level int, comp []int) store.Community {
+		ids := componentEntityIDs(comp, entities)
+		idsJSON, _ := json.Marshal(ids)
+		c := store.Community{
+			Level:     level,
+			EntityIDs: string(idsJSON),
+			Signature: communitySignature(comp, entities),
+		}
+		if old, ok := previous[fmt.Sprintf("%d:%s", level, c.EntityIDs)]; ok {
+			c.Summary, c.SummarySignature = old.Summary, old.SummarySignature
+		}
+		return c
+	}
+
 	// Clear old community data before inserting new results.
 	if err := s.ClearCommunities(ctx); err != nil {
 		return nil, fmt.Errorf("clearing communities: %w", err)
@@ -103,13 +156,7 @@ func DetectCommunities(ctx context.Context, s *store.Store) ([]store.Community,
 	var communities []store.Community
 
 	for _, comp := range components {
-		ids := componentEntityIDs(comp, entities)
-		idsJSON, _ := json.Marshal(ids)
-
-		c := store.Community{
-			Level:     0,
-			EntityIDs: string(idsJSON),
-		}
+		c := newCommunity(0, comp)
 		id, err := s.InsertCommunity(ctx, c)
 		if err != nil {
 			return nil, fmt.Errorf("inserting level-0 community: %w", err)
@@ -119,16 +166,10 @@ func DetectCommunities(ctx context.Context, s *store.Store) ([]store.Community,
 
 		// --- Level 1: modularity-based splitting for large components ---
 		// Skip if too large (O(n²) algorithm would be too slow).
-		if len(comp) >= minComponentSplit && len(comp) <= maxModularityNodes && totalWeight > 0 {
+		if splitLevels && len(comp) >= minComponentSplit && len(comp) <= maxModularityNodes && totalWeight > 0 {
 			subcommunities := modularitySplit(comp, adj, totalWeight)
 			for _, sub := range subcommunities {
-				subIDs := componentEntityIDs(sub, entities)
-				subJSON, _ := json.Marshal(subIDs)
-
-				sc := store.Community{
-					Level:     1,
-					EntityIDs: string(subJSON),
-				}
+				sc := newCommunity(1, sub)
 				sid, err := s.InsertCommunity(ctx, sc)
 				if err != nil {
 					return nil, fmt.Errorf("inserting level-1 community: %w", err)
@@ -154,14 +195,32 @@ func largestComp(comps [][]int) int {
 }
 
 // componentEntityIDs maps component node indices back to entity IDs.
+// componentEntityIDs returns the sorted entity IDs of a component, so the
+// same members always give the same entity_ids.
 func componentEntityIDs(comp []int, entities []store.Entity) []int64 {
 	ids := make([]int64, len(comp))
 	for i, idx := range comp {
 		ids[i] = entities[idx].ID
 	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
 	return ids
 }
 
+// communitySignature fingerprints what a community summary is written
+// from: each member's name, type, and description.
+func communitySignature(comp []int, entities []store.Entity) string {
+	members := make([]store.Entity, len(comp))
+	for i, idx := range comp {
+		members[i] = entities[idx]
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].ID < members[j].ID })
+	h := sha256.New()
+	for _, e := range members {
+		fmt.Fprintf(h, "%d\x00%s\x00%s\x00%s\n", e.ID, e.Name, e.EntityType, e.Description)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 // modularitySplit applies a greedy modularity optimisation (simplified Louvain)
 // to split a connected component into two or more sub-communities. If the
 // split does not improve modularity the original component is returned as-is.
@@ -279,7 +338,14 @@ func modularitySplit(comp []int, adj [][]edge, totalWeight float64) [][]int {
 // for each community based on its member entities. Summaries are generated
 // concurrently (up to 8 at a time) and individual failures are logged but
 // do not abort the entire operation.
-func SummarizeCommunities(ctx context.Context, s *store.Store, chat llm.Provider, communities []store.Community) error {
+func SummarizeCommunities(ctx context.Context, s store.GraphStore, chat llm.Provider, communities []store.Community) error {
+	return SummarizeCommunitiesWithProgress(ctx, s, chat, communities, nil)
+}
+
+// SummarizeCommunitiesWithProgress is SummarizeCommunities reporting each
+// stored summary to progress (which may be nil) with the number stored so
+// far. progress is called from one goroutine at a time.
+func SummarizeCommunitiesWithProgress(ctx context.Context, s store.GraphStore, chat llm.Provider, communities []store.Community, progress func(done, total int)) error {
 	// Load all entities once; filter per community.
 	allEntities, err := s.AllEntities(ctx)
 	if err != nil {
@@ -296,7 +362,7 @@ func SummarizeCommunities(ctx context.Context, s *store.Store, chat llm.Provider
 	sem := make(chan struct{}, concurrency)
 	var wg sync.WaitGroup
 	var mu sync.Mutex
-	var failed int
+	var failed, summarized int
 
 	for i := range communities {
 		c := &communities[i]
@@ -359,11 +425,7 @@ func SummarizeCommunities(ctx context.Context, s *store.Store, chat llm.Provider
 
 			summary := strings.TrimSpace(resp.Content)
 
-			db := s.DB()
-			if _, err := db.ExecContext(ctx,
-				"UPDATE communities SET summary = ? WHERE id = ?",
-				summary, c.ID,
-			); err != nil {
+			if err := s.SetCommunitySummary(ctx, c.ID, summary); err != nil {
 				slog.Warn("community: failed to store summary",
 					"community_id", c.ID, "error", err)
 				mu.Lock()
@@ -373,7 +435,11 @@ func SummarizeCommunities(ctx context.Context, s *store.Store, chat llm.Provider
 			}
 
 			mu.Lock()
-			c.Summary = summary
+			c.Summary, c.SummarySignature = summary, c.Signature
+			summarized++
+			if progress != nil {
+				progress(summarized, len(communities))
+			}
 			done := len(communities) - failed - countPending(&wg)
 			mu.Unlock()
 
@@ -0,0 +1,272 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/bbiangul/go-reason/llm"
+)
+
+// retryJSONPrompt asks the model to fix a reply that could not be parsed.
+const retryJSONPrompt = `Your previous reply could not be parsed as JSON (%v).
+Reply again with only the corrected JSON object, no markdown fences and no text outside it.`
+
+// maxEntityNameLen rejects "entities" that are really sentences or whole
+// passages copied from the chunk.
+const maxEntityNameLen = 200
+
+// ExtractionStats counts how graph extraction replies were parsed since the
+// builder was created. ParseFailures are replies that stayed unusable after
+// repair and one retry; their chunks are missing from the graph.
+type ExtractionStats struct {
+	Replies       int64 `json:"replies"`
+	Repaired      int64 `json:"repaired"`
+	Retried       int64 `json:"retried"`
+	ParseFailures int64 `json:"parse_failures"`
+	DroppedItems  int64 `json:"dropped_items"` // entities or relationships failing validation
+}
+
+// ParseFailureRate is the share of replies that could not be parsed.
+func (s ExtractionStats) ParseFailureRate() float64 {
+	if s.Replies == 0 {
+		return 0
+	}
+	return float64(s.ParseFailures) / float64(s.Replies)
+}
+
+// extractionCounters is the concurrency-safe form of ExtractionStats.
+type extractionCounters struct {
+	replies, repaired, retried, parseFailures, droppedItems atomic.Int64
+}
+
+func (c *extractionCounters) snapshot() ExtractionStats {
+	return ExtractionStats{
+		Replies:       c.replies.Load(),
+		Repaired:      c.repaired.Load(),
+		Retried:       c.retried.Load(),
+		ParseFailures: c.parseFailures.Load(),
+		DroppedItems:  c.droppedItems.Load(),
+	}
+}
+
+// ExtractionStats reports parse and validation outcomes of the extraction
+// replies seen so far.
+func (b *Builder) ExtractionStats() ExtractionStats {
+	return b.counters.snapshot()
+}
+
+// chatJSON sends prompt and decodes the JSON reply into dst. Replies that
+// fail to parse are repaired; if that is not enough the model is asked once
+// to correct its reply.
+func (b *Builder) chatJSON(ctx context.Context, prompt string, dst interface{}) error {
+	return b.chatJSONCounted(ctx, nil, prompt, dst)
+}
+
+// chatJSONCounted is chatJSON that also counts its calls in run, the
+// counters of the Build it serves.
+func (b *Builder) chatJSONCounted(ctx context.Context, run *buildCounters, prompt string, dst interface{}) error {
+	msgs := []llm.Message{{Role: "user", Content: prompt}}
+	for attempt := 0; ; attempt++ {
+		run.call()
+		resp, err := b.chat.Chat(ctx, llm.ChatRequest{
+			Messages:       msgs,
+			Temperature:    0.0,
+			ResponseFormat: "json_object",
+		})
+		if err != nil {
+			run.failure()
+			return fmt.Errorf("llm chat: %w", err)
+		}
+		b.counters.replies.Add(1)
+
+		repaired, err := decodeLLMJSON(resp.Content, dst)
+		if err == nil {
+			if repaired {
+				b.counters.repaired.Add(1)
+			}
+			return nil
+		}
+		if attempt > 0 || ctx.Err() != nil {
+			b.counters.parseFailures.Add(1)
+			run.failure()
+			return err
+		}
+		b.counters.retried.Add(1)
+		run.retry()
+		msgs = append(msgs,
+			llm.Message{Role: "assistant", Content: resp.Content},
+			llm.Message{Role: "user", Content: fmt.Sprintf(retryJSONPrompt, err)},
+		)
+	}
+}
+
+// decodeLLMJSON extracts the JSON object from raw and decodes it into dst,
+// repairing common LLM defects when the object does not parse as is.
+// repaired reports whether a repair was needed.
+func decodeLLMJSON(raw string, dst interface{}) (repaired bool, err error) {
+	jsonStr, err := extractJSON(raw)
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal([]byte(jsonStr), dst); err == nil {
+		return false, nil
+	}
+	if err := json.Unmarshal([]byte(repairJSON(jsonStr)), dst); err != nil {
+		return true, fmt.Errorf("invalid JSON after repair: %w", err)
+	}
+	return true, nil
+}
+
+// repairJSON fixes the defects LLMs commonly put in JSON: typographic
+// quotes, trailing commas, raw newlines inside strings, and output cut off
+// mid-object. A truncated reply is cut back to its last complete value and
+// its open brackets are closed, so the complete items survive.
+func repairJSON(s string) string {
+	s = strings.NewReplacer("“", `"`, "”", `"`).Replace(s)
+
+	var out []byte
+	var stack []byte // expected closing brackets
+	inString, escaped := false, false
+	safeLen, safeStack := 0, []byte(nil)
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			case c == '\n':
+				out = append(out, '\\', 'n')
+				continue
+			case c == '\r' || c == '\t':
+				out = append(out, ' ')
+				continue
+			}
+			out = append(out, c)
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+		case '{':
+			stack = append(stack, '}')
+		case '[':
+			stack = append(stack, ']')
+		case '}', ']':
+			out = trimTrailingComma(out)
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			out = append(out, c)
+			safeLen, safeStack = len(out), append([]byte(nil), stack...)
+			continue
+		}
+		out = append(out, c)
+	}
+
+	if len(stack) == 0 {
+		return string(out)
+	}
+	// Truncated: keep everything up to the last closed value.
+	if safeLen == 0 {
+		return string(out)
+	}
+	out = trimTrailingComma(out[:safeLen])
+	for i := len(safeStack) - 1; i >= 0; i-- {
+		out = append(trimTrailingComma(out), safeStack[i])
+	}
+	return string(out)
+}
+
+// trimTrailingComma drops a comma (and the whitespace after it) at the end
+// of b.
+func trimTrailingComma(b []byte) []byte {
+	end := len(b)
+	for end > 0 && strings.IndexByte(" \t\r\n", b[end-1]) >= 0 {
+		end--
+	}
+	if end > 0 && b[end-1] == ',' {
+		return b[:end-1]
+	}
+	return b
+}
+
+// knownEntityTypes and knownRelationTypes are the types the extraction
+// prompts allow.
+var (
+	knownEntityTypes = map[string]bool{
+		EntityPerson: true, EntityOrg: true, EntityStandard: true, EntityClause: true,
+		EntityConcept: true, EntityTerm: true, EntityRegulation: true,
+	}
+	knownRelationTypes = map[string]bool{
+		RelReferences: true, RelDefines: true, RelAmends: true,
+		RelRequires: true, RelContradicts: true, RelSupersedes: true,
+	}
+)
+
+// validateEntities decodes and normalises each entity on its own, so one
+// malformed item does not discard the rest. Names are required; unknown
+// types become concepts. It returns the accepted entities and the number
+// dropped.
+func validateEntities(items []json.RawMessage) ([]ExtractedEntity, int) {
+	var out []ExtractedEntity
+	dropped := 0
+	for _, item := range items {
+		var e ExtractedEntity
+		if err := json.Unmarshal(item, &e); err != nil {
+			dropped++
+			continue
+		}
+		e.Name = strings.TrimSpace(strings.ToLower(e.Name))
+		if e.Name == "" || len(e.Name) > maxEntityNameLen {
+			dropped++
+			continue
+		}
+		e.Type = strings.TrimSpace(strings.ToLower(e.Type))
+		if !knownEntityTypes[e.Type] {
+			e.Type = EntityConcept
+		}
+		e.NameEN = strings.TrimSpace(strings.ToLower(e.NameEN))
+		out = append(out, e)
+	}
+	return out, dropped
+}
+
+// validateRelationships decodes and normalises each relationship on its
+// own. Both endpoints are required and must differ; unknown relation types
+// become references and weights are clamped to (0, 1], with a missing
+// weight meaning 1.
+func validateRelationships(items []json.RawMessage) ([]ExtractedRelationship, int) {
+	var out []ExtractedRelationship
+	dropped := 0
+	for _, item := range items {
+		var r ExtractedRelationship
+		if err := json.Unmarshal(item, &r); err != nil {
+			dropped++
+			continue
+		}
+		r.Source = strings.TrimSpace(strings.ToLower(r.Source))
+		r.Target = strings.TrimSpace(strings.ToLower(r.Target))
+		if r.Source == "" || r.Target == "" || r.Source == r.Target {
+			dropped++
+			continue
+		}
+		r.RelationType = strings.TrimSpace(strings.ToLower(r.RelationType))
+		if !knownRelationTypes[r.RelationType] {
+			r.RelationType = RelReferences
+		}
+		if r.Weight <= 0 || r.Weight > 1 {
+			r.Weight = 1.0
+		}
+		out = append(out, r)
+	}
+	return out, dropped
+}
@@ -0,0 +1,93 @@
+package graph
+
+import "sync/atomic"
+
+// maxReportErrors bounds the failures a BuildReport keeps.
+const maxReportErrors = 5
+
+// BuildReport summarises a graph build: how much of it succeeded, and the
+// LLM failures behind the chunks missing from the graph.
+type BuildReport struct {
+	Chunks        int `json:"chunks"`        // chunks given to Build
+	Skipped       int `json:"skipped"`       // trivial chunks and chunk types not extracted
+	SkippedTypes  int `json:"skipped_types"` // of Skipped, chunks of types not extracted (SetChunkTypes)
+	Processed     int `json:"processed"`     // chunks extracted into the graph
+	Failed        int `json:"failed"`        // chunks whose extraction failed
+	Entities      int `json:"entities"`      // entity mentions stored
+	Relationships int `json:"relationships"` // relationships stored
+
+	LLMCalls int64 `json:"llm_calls"`
+	// LLMFailures are calls that failed or whose reply stayed unusable
+	// after repair and one retry.
+	LLMFailures  int64 `json:"llm_failures"`
+	RetriedCalls int64 `json:"retried_calls"` // replies asked again after failing to parse
+
+	// Errors are the first chunk failures, "chunk <id>: <error>".
+	Errors    []string `json:"errors,omitempty"`
+	ElapsedMs int64    `json:"elapsed_ms"`
+}
+
+// Pending returns how many of the chunks are still being extracted.
+func (r *BuildReport) Pending() int {
+	return r.Chunks - r.Skipped - r.Processed - r.Failed
+}
+
+// Add accumulates o, the report of another batch of the same document.
+func (r *BuildReport) Add(o *BuildReport) {
+	if o == nil {
+		return
+	}
+	r.Chunks += o.Chunks
+	r.Skipped += o.Skipped
+	r.SkippedTypes += o.SkippedTypes
+	r.Processed += o.Processed
+	r.Failed += o.Failed
+	r.Entities += o.Entities
+	r.Relationships += o.Relationships
+	r.LLMCalls += o.LLMCalls
+	r.LLMFailures += o.LLMFailures
+	r.RetriedCalls += o.RetriedCalls
+	r.ElapsedMs += o.ElapsedMs
+	for _, e := range o.Errors {
+		r.addError(e)
+	}
+}
+
+// addError keeps e unless the report already holds maxReportErrors.
+func (r *BuildReport) addError(e string) {
+	if len(r.Errors) < maxReportErrors {
+		r.Errors = append(r.Errors, e)
+	}
+}
+
+// buildCounters counts the LLM calls of one Build, alongside the
+// builder's lifetime extractionCounters. A nil *buildCounters counts
+// nothing.
+type buildCounters struct {
+	calls, failures, retried atomic.Int64
+}
+
+func (c *buildCounters) call() {
+	if c != nil {
+		c.calls.Add(1)
+	}
+}
+
+func (c *buildCounters) failure() {
+	if c != nil {
+		c.failures.Add(1)
+	}
+}
+
+func (c *buildCounters) retry() {
+	if c != nil {
+		c.retried.Add(1)
+	}
+}
+
+// fill copies the counts into r.
+func (c *buildCounters) fill(r *BuildReport) {
+	r.LLMCalls = c.calls.Load()
+	r.LLMFailures = c.failures.Load()
+	r.RetriedCalls = c.retried.Load()
+}
@@ -19,7 +19,7 @@ type TraversalResult struct {
 // queryEntities are entity names (case-insensitive lookup). The traversal
 // walks outgoing and incoming relationships up to maxDepth hops, collecting
 // all entity IDs and their associated chunk IDs.
-func Traverse(ctx context.Context, s *store.Store, queryEntities []string, maxDepth int) (*TraversalResult, error) {
+func Traverse(ctx context.Context, s store.GraphStore, queryEntities []string, maxDepth int) (*TraversalResult, error) {
 	if len(queryEntities) == 0 || maxDepth < 0 {
 		return &TraversalResult{}, nil
 	}
@@ -76,7 +76,7 @@ func Traverse(ctx context.Context, s *store.Store, queryEntities []string, maxDe
 	}
 
 	// Resolve chunk IDs linked to the discovered entities via entity_chunks.
-	chunkIDs, err := chunkIDsForEntities(ctx, s, entityIDs)
+	chunkIDs, err := s.ChunkIDsForEntities(ctx, entityIDs)
 	if err != nil {
 		return nil, fmt.Errorf("graph.Traverse: resolving chunks: %w", err)
 	}
@@ -86,61 +86,3 @@ func Traverse(ctx context.Context, s *store.Store, queryEntities []string, maxDe
 		ChunkIDs:  chunkIDs,
 	}, nil
 }
-
-// chunkIDsForEntities queries the entity_chunks table to find all chunk IDs
-// linked to the given entity IDs. It queries in batches to avoid overly large
-// IN clauses.
-func chunkIDsForEntities(ctx context.Context, s *store.Store, entityIDs []int64) ([]int64, error) {
-	if len(entityIDs) == 0 {
-		return nil, nil
-	}
-
-	db := s.DB()
-
-	const batchSize = 200
-	seen := make(map[int64]bool)
-	var result []int64
-
-	for start := 0; start < len(entityIDs); start += batchSize {
-		end := start + batchSize
-		if end > len(entityIDs) {
-			end = len(entityIDs)
-		}
-		batch := entityIDs[start:end]
-
-		placeholders := "?"
-		for i := 1; i < len(batch); i++ {
-			placeholders += ", ?"
-		}
-
-		query := "SELECT DISTINCT chunk_id FROM entity_chunks WHERE entity_id IN (" + placeholders + ")"
-		args := make([]interface{}, len(batch))
-		for i, id := range batch {
-			args[i] = id
-		}
-
-		rows, err := db.QueryContext(ctx, query, args...)
-		if err != nil {
-			return nil, fmt.Errorf("querying entity_chunks: %w", err)
-		}
-
-		for rows.Next() {
-			var cid int64
-			if err := rows.Scan(&cid); err != nil {
-				rows.Close()
-				return nil, err
-			}
-			if !seen[cid] {
-				seen[cid] = true
-				result = append(result, cid)
-			}
-		}
-		if err := rows.Err(); err != nil {
-			rows.Close()
-			return nil, err
-		}
-		rows.Close()
-	}
-
-	return result, nil
-}
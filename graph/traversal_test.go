@@ -0,0 +1,70 @@
+package graph
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/bbiangul/go-reason/store"
+)
+
+// memGraph is an in-memory GraphStore with just what Traverse reads; the
+// embedded interface panics on anything else.
+type memGraph struct {
+	store.GraphStore
+	entities []store.Entity
+	rels     []store.Relationship
+	chunks   map[int64][]int64 // entity ID -> chunk IDs
+}
+
+func (g *memGraph) GetEntitiesByNames(ctx context.Context, names []string) ([]store.Entity, error) {
+	var out []store.Entity
+	for _, e := range g.entities {
+		for _, n := range names {
+			if e.Name == n {
+				out = append(out, e)
+			}
+		}
+	}
+	return out, nil
+}
+
+func (g *memGraph) AllRelationships(ctx context.Context) ([]store.Relationship, error) {
+	return g.rels, nil
+}
+
+func (g *memGraph) ChunkIDsForEntities(ctx context.Context, entityIDs []int64) ([]int64, error) {
+	var out []int64
+	for _, id := range entityIDs {
+		out = append(out, g.chunks[id]...)
+	}
+	return out, nil
+}
+
+func TestTraverseWithoutSQLite(t *testing.T) {
+	g := &memGraph{
+		entities: []store.Entity{{ID: 1, Name: "pump"}, {ID: 2, Name: "relief valve"}, {ID: 3, Name: "seal"}},
+		rels: []store.Relationship{
+			{SourceEntityID: 1, TargetEntityID: 2},
+			{SourceEntityID: 2, TargetEntityID: 3},
+		},
+		chunks: map[int64][]int64{1: {10}, 2: {20}, 3: {30}},
+	}
+
+	res, err := Traverse(context.Background(), g, []string{"pump"}, 1)
+	if err != nil {
+		t.Fatalf("Traverse: %v", err)
+	}
+	sort.Slice(res.ChunkIDs, func(i, j int) bool { return res.ChunkIDs[i] < res.ChunkIDs[j] })
+	if len(res.EntityIDs) != 2 || len(res.ChunkIDs) != 2 || res.ChunkIDs[0] != 10 || res.ChunkIDs[1] != 20 {
+		t.Errorf("depth 1: entities %v, chunks %v", res.EntityIDs, res.ChunkIDs)
+	}
+
+	res, err = Traverse(context.Background(), g, []string{"pump"}, 2)
+	if err != nil {
+		t.Fatalf("Traverse: %v", err)
+	}
+	if len(res.ChunkIDs) != 3 {
+		t.Errorf("depth 2: chunks %v, want all three", res.ChunkIDs)
+	}
+}
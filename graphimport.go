@@ -0,0 +1,258 @@
+package goreason
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bbiangul/go-reason/graph"
+	"github.com/bbiangul/go-reason/store"
+)
+
+// Conflict policies for GraphImport.OnConflict.
+const (
+	ConflictKeepExisting   = store.ConflictKeepExisting
+	ConflictPreferImported = store.ConflictPreferImported
+	ConflictMerge          = store.ConflictMerge
+)
+
+// GraphImport is externally curated knowledge, such as a product ontology,
+// to merge into the entity graph so retrieval can follow links the
+// documents never state.
+type GraphImport struct {
+	// Source names the import (e.g. "product-ontology.csv"). It is recorded
+	// on the imported rows and in the audit log.
+	Source string `json:"source"`
+	// OnConflict says how an imported entity that matches one already in
+	// the graph is merged: ConflictKeepExisting (default) keeps the stored
+	// description and only fills gaps, ConflictPreferImported overwrites
+	// it, ConflictMerge appends the imported description. It applies the
+	// same way to relationships imported before.
+	OnConflict    string                 `json:"on_conflict,omitempty"`
+	Entities      []ImportedEntity       `json:"entities,omitempty"`
+	Relationships []ImportedRelationship `json:"relationships,omitempty"`
+}
+
+// ImportedEntity is a curated entity. Type defaults to "concept".
+type ImportedEntity struct {
+	Name        string `json:"name"`
+	Type        string `json:"type,omitempty"`
+	Description string `json:"description,omitempty"`
+	NameEN      string `json:"name_en,omitempty"`
+}
+
+// ImportedRelationship is a curated relationship between two entities
+// named in the import or already in the graph. Weight defaults to 1.
+type ImportedRelationship struct {
+	Source      string  `json:"source"`
+	Target      string  `json:"target"`
+	Type        string  `json:"type"`
+	Description string  `json:"description,omitempty"`
+	Weight      float64 `json:"weight,omitempty"`
+}
+
+// GraphImportResult is the result of ImportGraph. Merged counts imported
+// entities (and relationships) that matched existing ones; Unresolved
+// lists relationships skipped because an endpoint is in neither the
+// import nor the graph.
+type GraphImportResult struct {
+	store.GraphImportStats
+	ElapsedMs int64 `json:"elapsed_ms"`
+}
+
+// ImportGraph merges curated entities and relationships into the
+// knowledge graph. Names and types are normalised the way extraction
+// normalises them, so imported entities join the extracted ones they name.
+// Imported rows survive Compact and re-extraction keeps their descriptions.
+// Community membership changes take effect at the next RebuildCommunities.
+func (e *engine) ImportGraph(ctx context.Context, g GraphImport) (*GraphImportResult, error) {
+	if err := e.writable(); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	res, err := e.importGraph(ctx, g)
+	params := map[string]string{
+		"source":        g.Source,
+		"on_conflict":   g.OnConflict,
+		"entities":      strconv.Itoa(len(g.Entities)),
+		"relationships": strconv.Itoa(len(g.Relationships)),
+	}
+	e.audit(ctx, AuditImportGraph, start, 0, params, err)
+	return res, err
+}
+
+func (e *engine) importGraph(ctx context.Context, g GraphImport) (*GraphImportResult, error) {
+	start := time.Now()
+	sg, err := normalizeGraphImport(g)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := e.store.ImportGraph(ctx, *sg)
+	if err != nil {
+		return nil, fmt.Errorf("importing graph: %w", err)
+	}
+	slog.InfoContext(ctx, "graph import complete", "source", g.Source,
+		"entities_created", stats.EntitiesCreated, "entities_merged", stats.EntitiesMerged,
+		"relationships_created", stats.RelationshipsCreated, "unresolved", len(stats.Unresolved))
+
+	// Embed new and changed entity descriptions for query-time graph seeding.
+	if e.cfg.GraphSeedSimilarity > 0 {
+		if _, err := e.embedEntities(ctx); err != nil {
+			slog.WarnContext(ctx, "graph import: entity embeddings failed (non-fatal)", "error", err)
+		}
+	}
+	return &GraphImportResult{GraphImportStats: *stats, ElapsedMs: time.Since(start).Milliseconds()}, nil
+}
+
+// normalizeGraphImport validates g and converts it for the store.
+func normalizeGraphImport(g GraphImport) (*store.GraphImport, error) {
+	if strings.TrimSpace(g.Source) == "" {
+		return nil, fmt.Errorf("%w: source is required", ErrInvalidGraphImport)
+	}
+	switch g.OnConflict {
+	case "", ConflictKeepExisting, ConflictPreferImported, ConflictMerge:
+	default:
+		return nil, fmt.Errorf("%w: unknown conflict policy %q", ErrInvalidGraphImport, g.OnConflict)
+	}
+	sg := &store.GraphImport{Source: strings.TrimSpace(g.Source), OnConflict: g.OnConflict}
+
+	for i, ent := range g.Entities {
+		name := normalizeGraphName(ent.Name)
+		if name == "" {
+			return nil, fmt.Errorf("%w: entity %d has no name", ErrInvalidGraphImport, i+1)
+		}
+		eType := normalizeGraphName(ent.Type)
+		if eType == "" {
+			eType = graph.EntityConcept
+		}
+		sg.Entities = append(sg.Entities, store.Entity{
+			Name:        name,
+			EntityType:  eType,
+			Description: strings.TrimSpace(ent.Description),
+			NameEN:      normalizeGraphName(ent.NameEN),
+		})
+	}
+	for i, r := range g.Relationships {
+		src, tgt, rType := normalizeGraphName(r.Source), normalizeGraphName(r.Target), normalizeGraphName(r.Type)
+		if src == "" || tgt == "" || rType == "" {
+			return nil, fmt.Errorf("%w: relationship %d needs a source, target, and type", ErrInvalidGraphImport, i+1)
+		}
+		if r.Weight < 0 {
+			return nil, fmt.Errorf("%w: relationship %d has a negative weight", ErrInvalidGraphImport, i+1)
+		}
+		weight := r.Weight
+		if weight == 0 {
+			weight = 1.0
+		}
+		sg.Relationships = append(sg.Relationships, store.ImportedRelationship{
+			Source:       src,
+			Target:       tgt,
+			RelationType: rType,
+			Description:  strings.TrimSpace(r.Description),
+			Weight:       weight,
+		})
+	}
+	return sg, nil
+}
+
+// normalizeGraphName lowercases and trims a name as graph extraction does.
+func normalizeGraphName(s string) string {
+	return strings.TrimSpace(strings.ToLower(s))
+}
+
+// ReadGraphCSV reads a graph import from CSV files with a header row.
+// Entity columns: name, type, description, name_en. Relationship columns:
+// source, target, type (or relation_type), description, weight. Only name,
+// and source, target, and type, are required; either reader may be nil.
+// The caller sets Source and OnConflict on the result.
+func ReadGraphCSV(entities, relationships io.Reader) (*GraphImport, error) {
+	g := &GraphImport{}
+	if entities != nil {
+		rows, err := readCSVRecords(entities, "entities", "name")
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			g.Entities = append(g.Entities, ImportedEntity{
+				Name:        row["name"],
+				Type:        row["type"],
+				Description: row["description"],
+				NameEN:      row["name_en"],
+			})
+		}
+	}
+	if relationships != nil {
+		rows, err := readCSVRecords(relationships, "relationships", "source", "target")
+		if err != nil {
+			return nil, err
+		}
+		for i, row := range rows {
+			r := ImportedRelationship{
+				Source:      row["source"],
+				Target:      row["target"],
+				Type:        row["type"],
+				Description: row["description"],
+			}
+			if r.Type == "" {
+				r.Type = row["relation_type"]
+			}
+			if w := row["weight"]; w != "" {
+				if r.Weight, err = strconv.ParseFloat(w, 64); err != nil {
+					return nil, fmt.Errorf("%w: relationships row %d: invalid weight %q", ErrInvalidGraphImport, i+2, w)
+				}
+			}
+			g.Relationships = append(g.Relationships, r)
+		}
+	}
+	return g, nil
+}
+
+// readCSVRecords reads CSV rows as maps keyed by the lowercased header.
+func readCSVRecords(r io.Reader, what string, required ...string) ([]map[string]string, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s header: %v", ErrInvalidGraphImport, what, err)
+	}
+	for i, h := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+	}
+	for _, col := range required {
+		found := false
+		for _, h := range header {
+			found = found || h == col
+		}
+		if !found {
+			return nil, fmt.Errorf("%w: %s CSV has no %q column", ErrInvalidGraphImport, what, col)
+		}
+	}
+
+	var rows []map[string]string
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidGraphImport, what, err)
+		}
+		row := make(map[string]string, len(header))
+		for i, v := range rec {
+			if i < len(header) {
+				row[header[i]] = strings.TrimSpace(v)
+			}
+		}
+		rows = append(rows, row)
+	}
+}
@@ -0,0 +1,79 @@
+package goreason
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReadGraphCSV(t *testing.T) {
+	ents := "\ufeffName,Type,Description\nP-100,product,\"Centrifugal pump, 10 bar\"\nCentrifugal pumps,category,\n"
+	rels := "source,target,relation_type,weight\nP-100,Centrifugal pumps,is_a,0.5\n"
+	g, err := ReadGraphCSV(strings.NewReader(ents), strings.NewReader(rels))
+	if err != nil {
+		t.Fatalf("ReadGraphCSV: %v", err)
+	}
+	if len(g.Entities) != 2 || g.Entities[0].Description != "Centrifugal pump, 10 bar" || g.Entities[1].Type != "category" {
+		t.Errorf("entities = %+v", g.Entities)
+	}
+	if len(g.Relationships) != 1 || g.Relationships[0].Type != "is_a" || g.Relationships[0].Weight != 0.5 {
+		t.Errorf("relationships = %+v", g.Relationships)
+	}
+
+	if _, err := ReadGraphCSV(strings.NewReader("title\nx\n"), nil); !errors.Is(err, ErrInvalidGraphImport) {
+		t.Errorf("missing name column: %v", err)
+	}
+	if _, err := ReadGraphCSV(nil, strings.NewReader("source,target,type,weight\na,b,c,heavy\n")); !errors.Is(err, ErrInvalidGraphImport) {
+		t.Errorf("invalid weight: %v", err)
+	}
+}
+
+func TestImportGraph(t *testing.T) {
+	eng, _, path := faultEngine(t, nil)
+	ctx := context.Background()
+	if _, err := eng.Ingest(ctx, path); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+
+	res, err := eng.ImportGraph(ctx, GraphImport{
+		Source: "safety-ontology",
+		Entities: []ImportedEntity{
+			{Name: "Relief Valve", Description: "Opens to relieve overpressure"},
+			{Name: "Overpressure Protection", Type: "Function"},
+		},
+		Relationships: []ImportedRelationship{
+			{Source: "relief valve", Target: "overpressure protection", Type: "Provides"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ImportGraph: %v", err)
+	}
+	// The relief valve was extracted from pump.txt.
+	if res.EntitiesMerged != 1 || res.EntitiesCreated != 1 || res.RelationshipsCreated != 1 || len(res.Unresolved) != 0 {
+		t.Errorf("result = %+v", res)
+	}
+	var relType string
+	if err := eng.(*engine).store.DB().QueryRowContext(ctx, `
+		SELECT r.relation_type FROM relationships r JOIN entities e ON e.id = r.target_entity_id
+		WHERE e.name = 'overpressure protection' AND e.entity_type = 'function'`).Scan(&relType); err != nil || relType != "provides" {
+		t.Errorf("imported relationship = %q, %v", relType, err)
+	}
+
+	entries, err := eng.AuditLog(ctx, AuditFilter{Operation: AuditImportGraph})
+	if err != nil || len(entries) != 1 || entries[0].Params["source"] != "safety-ontology" {
+		t.Errorf("audit = %+v, %v", entries, err)
+	}
+
+	invalid := []GraphImport{
+		{Entities: []ImportedEntity{{Name: "x"}}},
+		{Source: "s", OnConflict: "overwrite"},
+		{Source: "s", Entities: []ImportedEntity{{Name: " "}}},
+		{Source: "s", Relationships: []ImportedRelationship{{Source: "a", Target: "b"}}},
+	}
+	for _, g := range invalid {
+		if _, err := eng.ImportGraph(ctx, g); !errors.Is(err, ErrInvalidGraphImport) {
+			t.Errorf("ImportGraph(%+v) = %v, want ErrInvalidGraphImport", g, err)
+		}
+	}
+}
@@ -0,0 +1,639 @@
+package goreason
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bbiangul/go-reason/chunker"
+	"github.com/bbiangul/go-reason/parser"
+	"github.com/bbiangul/go-reason/store"
+)
+
+// Export formats read by ReadIndexExport.
+const (
+	IndexFormatLangChain = "langchain" // LangChain Document or LlamaIndex node dump
+	IndexFormatChroma    = "chroma"    // Chroma collection.get() output
+	IndexFormatFAISS     = "faiss"     // flat FAISS index with a metadata JSON file
+)
+
+// parseMethodImport is the parse method recorded for imported documents.
+const parseMethodImport = "import"
+
+// IndexImport is a corpus exported from another vector store, to load
+// without parsing and, when its vectors fit, without embedding again.
+type IndexImport struct {
+	// Source names the import (e.g. "chroma-prod"). Documents are recorded
+	// under import://<source>/<document>, so importing the same source
+	// again replaces them.
+	Source string `json:"source"`
+	// EmbeddingModel is the model that made the chunks' embeddings. They
+	// are stored as they are only when it is the configured embedding
+	// model and they have Config.EmbeddingDim dimensions; otherwise the
+	// chunks are embedded again.
+	EmbeddingModel string          `json:"embedding_model,omitempty"`
+	Collection     string          `json:"collection,omitempty"`
+	Chunks         []ImportedChunk `json:"chunks"`
+}
+
+// ImportedChunk is one chunk of an exported index. The metadata's source
+// (or file_path, path, filename) groups chunks into documents; page_number,
+// page_label, or LangChain's 0-based page gives the page; heading, section,
+// title, or LangChain's "Header 1".."Header 6" the heading.
+type ImportedChunk struct {
+	ID        string            `json:"id,omitempty"`
+	Text      string            `json:"text"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	Embedding []float32         `json:"embedding,omitempty"`
+}
+
+// IndexImportResult is the result of ImportIndex. Unchanged counts
+// documents already imported with the same content.
+type IndexImportResult struct {
+	Documents     int     `json:"documents"`
+	Unchanged     int     `json:"unchanged"`
+	Chunks        int     `json:"chunks"`
+	VectorsReused int     `json:"vectors_reused"`
+	Reembedded    int     `json:"reembedded"`
+	DocumentIDs   []int64 `json:"document_ids"`
+	ElapsedMs     int64   `json:"elapsed_ms"`
+}
+
+// ImportIndex loads an exported index as documents and chunks, keeping
+// its chunking. Chunk filters are not applied. The imported documents
+// then go through the rest of ingest (optional embeddings, summary, and
+// graph) as configured, and are skipped by UpdateAll, having no file.
+func (e *engine) ImportIndex(ctx context.Context, imp IndexImport) (*IndexImportResult, error) {
+	if err := e.writable(); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	res, err := e.importIndex(ctx, imp)
+	params := map[string]string{
+		"source": imp.Source,
+		"chunks": strconv.Itoa(len(imp.Chunks)),
+	}
+	if imp.EmbeddingModel != "" {
+		params["embedding_model"] = imp.EmbeddingModel
+	}
+	if imp.Collection != "" {
+		params["collection"] = imp.Collection
+	}
+	e.audit(ctx, AuditImportIndex, start, 0, params, err)
+	return res, err
+}
+
+func (e *engine) importIndex(ctx context.Context, imp IndexImport) (*IndexImportResult, error) {
+	start := time.Now()
+	source := strings.TrimSpace(imp.Source)
+	if source == "" {
+		return nil, fmt.Errorf("%w: source is required", ErrInvalidIndexImport)
+	}
+	if len(imp.Chunks) == 0 {
+		return nil, fmt.Errorf("%w: no chunks", ErrInvalidIndexImport)
+	}
+	if err := e.embeddingDrift(); err != nil {
+		return nil, err
+	}
+
+	docs, order := groupImportedChunks(imp.Chunks, source)
+	res := &IndexImportResult{}
+	for _, name := range order {
+		docID, reused, err := e.importDocument(ctx, imp, source, name, docs[name])
+		if err != nil {
+			return res, fmt.Errorf("importing %s: %w", name, err)
+		}
+		res.DocumentIDs = append(res.DocumentIDs, docID)
+		switch {
+		case reused < 0:
+			res.Unchanged++
+			continue
+		case reused > 0:
+			res.VectorsReused += reused
+		default:
+			res.Reembedded += len(docs[name])
+		}
+		res.Documents++
+		res.Chunks += len(docs[name])
+	}
+	res.ElapsedMs = time.Since(start).Milliseconds()
+	slog.InfoContext(ctx, "index import complete", "source", source,
+		"documents", res.Documents, "unchanged", res.Unchanged, "chunks", res.Chunks,
+		"vectors_reused", res.VectorsReused, "reembedded", res.Reembedded)
+	return res, nil
+}
+
+// importDocument stores one imported document and runs the ingest stages
+// on it. reused is the number of imported vectors stored, or -1 when the
+// document was already imported with the same content.
+func (e *engine) importDocument(ctx context.Context, imp IndexImport, source, name string, chunks []ImportedChunk) (int64, int, error) {
+	release, err := e.acquireIngest(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer release()
+
+	h := sha256.New()
+	for _, c := range chunks {
+		h.Write([]byte(c.Text))
+		h.Write([]byte{0})
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+	docPath := "import://" + source + "/" + name
+	collection := imp.Collection
+	existing, err := e.store.GetDocumentByPath(ctx, docPath)
+	if err == nil {
+		if existing.ContentHash == hash && existing.Status == "ready" {
+			return existing.ID, -1, nil
+		}
+		if collection == "" {
+			collection = existing.Collection
+		}
+	}
+
+	filename := path.Base(name)
+	metadata, _ := json.Marshal(map[string]string{"import_source": source})
+	docID, err := e.store.UpsertDocument(ctx, store.Document{
+		Path:        docPath,
+		Filename:    filename,
+		Format:      strings.ToLower(strings.TrimPrefix(path.Ext(filename), ".")),
+		ContentHash: hash,
+		ParseMethod: parseMethodImport,
+		Status:      "processing",
+		Metadata:    string(metadata),
+		Collection:  collection,
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("upserting document: %w", err)
+	}
+	// After an update UpsertDocument may return the connection's last
+	// inserted row rather than the document's.
+	if existing != nil {
+		docID = existing.ID
+	}
+	if err := e.store.DeleteDocumentData(ctx, docID); err != nil {
+		return 0, 0, fmt.Errorf("cleaning old data: %w", err)
+	}
+
+	chunkr := e.chunkerFor(collection)
+	stored := make([]store.Chunk, len(chunks))
+	sections := make([]parser.Section, len(chunks))
+	for i, c := range chunks {
+		heading, page := importedHeading(c.Metadata), importedPage(c.Metadata)
+		var meta string
+		if len(c.Metadata) > 0 {
+			data, _ := json.Marshal(c.Metadata)
+			meta = string(data)
+		}
+		stored[i] = store.Chunk{
+			DocumentID:    docID,
+			Content:       c.Text,
+			ChunkType:     "section",
+			Heading:       heading,
+			PageNumber:    page,
+			PositionInDoc: i,
+			TokenCount:    chunkr.CountTokens(c.Text),
+			Metadata:      meta,
+			ContentHash:   chunker.ContentHash(c.Text),
+		}
+		sections[i] = parser.Section{Heading: heading, Content: c.Text, PageNumber: page}
+	}
+	ids, err := e.store.InsertChunks(ctx, stored)
+	if err != nil {
+		e.store.UpdateDocumentStatus(ctx, docID, "error")
+		return 0, 0, fmt.Errorf("inserting chunks: %w", err)
+	}
+	if !e.cfg.SkipGlossary {
+		if err := e.store.InsertGlossary(ctx, extractGlossary(docID, stored, ids)); err != nil {
+			slog.WarnContext(ctx, "import: storing glossary failed (non-fatal)", "doc_id", docID, "error", err)
+		}
+	}
+	if err := e.store.InsertChunkQuantities(ctx, extractQuantities(docID, stored, ids)); err != nil {
+		slog.WarnContext(ctx, "import: storing numeric values failed (non-fatal)", "doc_id", docID, "error", err)
+	}
+
+	reused := 0
+	if e.importedVectorsFit(imp.EmbeddingModel, chunks) {
+		for i, c := range chunks {
+			if err := e.store.InsertEmbedding(ctx, ids[i], c.Embedding); err != nil {
+				e.store.UpdateDocumentStatus(ctx, docID, "error")
+				return 0, 0, fmt.Errorf("storing embedding: %w", err)
+			}
+		}
+		reused = len(chunks)
+	}
+
+	run := &ingestRun{
+		docID:    docID,
+		filename: filename,
+		chunks:   stored,
+		ids:      ids,
+		sections: sections,
+		embedded: reused > 0,
+	}
+	if err := e.runIngestStages(ctx, run, store.CheckpointEmbedding, 0); err != nil {
+		e.store.UpdateDocumentStatus(ctx, docID, "error")
+		return 0, 0, err
+	}
+	e.store.UpdateDocumentStatus(ctx, docID, "ready")
+	return docID, reused, nil
+}
+
+// importedVectorsFit reports whether the chunks' embeddings can be stored
+// as they are: all present, from the configured model, of its dimension.
+func (e *engine) importedVectorsFit(model string, chunks []ImportedChunk) bool {
+	if model == "" || model != e.cfg.Embedding.Model {
+		return false
+	}
+	for _, c := range chunks {
+		if len(c.Embedding) != e.cfg.EmbeddingDim {
+			return false
+		}
+	}
+	return true
+}
+
+// groupImportedChunks groups chunks by the document their metadata names,
+// in order of first appearance, and orders each document's chunks by page
+// and LangChain's start_index where given.
+func groupImportedChunks(chunks []ImportedChunk, source string) (map[string][]ImportedChunk, []string) {
+	docs := make(map[string][]ImportedChunk)
+	var order []string
+	for _, c := range chunks {
+		if strings.TrimSpace(c.Text) == "" {
+			continue
+		}
+		name := source
+		for _, key := range []string{"source", "file_path", "path", "filename", "file_name"} {
+			if v := strings.TrimSpace(c.Metadata[key]); v != "" {
+				name = v
+				break
+			}
+		}
+		if _, ok := docs[name]; !ok {
+			order = append(order, name)
+		}
+		docs[name] = append(docs[name], c)
+	}
+	for _, cs := range docs {
+		sort.SliceStable(cs, func(i, j int) bool {
+			pi, pj := importedPage(cs[i].Metadata), importedPage(cs[j].Metadata)
+			if pi != pj {
+				return pi < pj
+			}
+			si, _ := strconv.Atoi(cs[i].Metadata["start_index"])
+			sj, _ := strconv.Atoi(cs[j].Metadata["start_index"])
+			return si < sj
+		})
+	}
+	return docs, order
+}
+
+// importedPage returns the 1-based page named by chunk metadata, or 0.
+func importedPage(meta map[string]string) int {
+	for _, key := range []string{"page_number", "page_label"} {
+		if n, err := strconv.Atoi(meta[key]); err == nil && n > 0 {
+			return n
+		}
+	}
+	if n, err := strconv.Atoi(meta["page"]); err == nil && n >= 0 {
+		return n + 1 // LangChain's PDF loaders count pages from 0
+	}
+	return 0
+}
+
+// importedHeading returns the heading named by chunk metadata.
+func importedHeading(meta map[string]string) string {
+	for _, key := range []string{"heading", "section", "title"} {
+		if v := strings.TrimSpace(meta[key]); v != "" {
+			return v
+		}
+	}
+	var headers []string
+	for level := 1; level <= 6; level++ {
+		if v := strings.TrimSpace(meta["Header "+strconv.Itoa(level)]); v != "" {
+			headers = append(headers, v)
+		}
+	}
+	return strings.Join(headers, " > ")
+}
+
+// ReadIndexExport reads the chunks of an exported index. data is the
+// export itself: for IndexFormatLangChain a JSON array or JSON lines of
+// LangChain Documents (page_content, metadata, optional id and embedding,
+// also in LangChain's serialized {"kwargs": ...} form) or LlamaIndex nodes
+// (text, metadata, id_, embedding); for IndexFormatChroma the JSON of a
+// collection.get(include=["documents", "metadatas", "embeddings"]); for
+// IndexFormatFAISS a flat index written by faiss.write_index, optionally
+// wrapped in an IndexIDMap. metadata is only read for FAISS: a JSON array
+// of documents in vector order, or an object with index_to_docstore_id and
+// docstore maps as in a LangChain FAISS store.
+func ReadIndexExport(format string, data, metadata io.Reader) ([]ImportedChunk, error) {
+	switch format {
+	case IndexFormatLangChain:
+		return readLangChainDocuments(data)
+	case IndexFormatChroma:
+		return readChromaExport(data)
+	case IndexFormatFAISS:
+		if metadata == nil {
+			return nil, fmt.Errorf("%w: a FAISS index needs its metadata JSON", ErrInvalidIndexImport)
+		}
+		return readFAISSExport(data, metadata)
+	}
+	return nil, fmt.Errorf("%w: unknown format %q (want %s, %s, or %s)", ErrInvalidIndexImport,
+		format, IndexFormatLangChain, IndexFormatChroma, IndexFormatFAISS)
+}
+
+// exportedDocument is a LangChain Document or LlamaIndex node as dumped
+// to JSON.
+type exportedDocument struct {
+	PageContent *string                `json:"page_content"`
+	Text        *string                `json:"text"`
+	Metadata    map[string]interface{} `json:"metadata"`
+	ID          interface{}            `json:"id"`
+	NodeID      string                 `json:"id_"`
+	Embedding   []float32              `json:"embedding"`
+	Kwargs      *exportedDocument      `json:"kwargs"`
+}
+
+// chunk converts the document; ok is false when it has no text.
+func (d *exportedDocument) chunk() (ImportedChunk, bool) {
+	if d.Kwargs != nil {
+		return d.Kwargs.chunk()
+	}
+	c := ImportedChunk{Embedding: d.Embedding, Metadata: importedMetadata(d.Metadata)}
+	switch {
+	case d.PageContent != nil:
+		c.Text = *d.PageContent
+	case d.Text != nil:
+		c.Text = *d.Text
+	default:
+		return c, false
+	}
+	switch id := d.ID.(type) {
+	case string:
+		c.ID = id
+	case []interface{}, nil:
+		// LangChain's serialized form uses id for the class path.
+	default:
+		c.ID = fmt.Sprint(id)
+	}
+	if c.ID == "" {
+		c.ID = d.NodeID
+	}
+	return c, true
+}
+
+// importedMetadata flattens JSON metadata to strings; values that are not
+// strings keep their JSON text.
+func importedMetadata(meta map[string]interface{}) map[string]string {
+	if len(meta) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(meta))
+	for k, v := range meta {
+		switch v := v.(type) {
+		case nil:
+		case string:
+			out[k] = v
+		default:
+			data, _ := json.Marshal(v)
+			out[k] = string(data)
+		}
+	}
+	return out
+}
+
+func readLangChainDocuments(r io.Reader) ([]ImportedChunk, error) {
+	br := bufio.NewReader(r)
+	first, err := firstNonSpace(br)
+	if err != nil {
+		return nil, err
+	}
+	var docs []exportedDocument
+	if first == '[' {
+		if err := json.NewDecoder(br).Decode(&docs); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidIndexImport, err)
+		}
+	} else {
+		dec := json.NewDecoder(br)
+		for {
+			var d exportedDocument
+			if err := dec.Decode(&d); err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, fmt.Errorf("%w: document %d: %v", ErrInvalidIndexImport, len(docs)+1, err)
+			}
+			docs = append(docs, d)
+		}
+	}
+	var out []ImportedChunk
+	for i := range docs {
+		c, ok := docs[i].chunk()
+		if !ok {
+			return nil, fmt.Errorf("%w: document %d has no page_content or text", ErrInvalidIndexImport, i+1)
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// firstNonSpace peeks at the first byte of r that is not white space.
+func firstNonSpace(r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.ReadByte()
+		if err == io.EOF {
+			return 0, fmt.Errorf("%w: empty export", ErrInvalidIndexImport)
+		}
+		if err != nil {
+			return 0, err
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n', 0xef, 0xbb, 0xbf: // white space or a byte order mark
+			continue
+		}
+		return b, r.UnreadByte()
+	}
+}
+
+func readChromaExport(r io.Reader) ([]ImportedChunk, error) {
+	var export struct {
+		IDs        []string                 `json:"ids"`
+		Documents  []*string                `json:"documents"`
+		Metadatas  []map[string]interface{} `json:"metadatas"`
+		Embeddings [][]float32              `json:"embeddings"`
+	}
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIndexImport, err)
+	}
+	if len(export.Documents) == 0 {
+		return nil, fmt.Errorf("%w: export has no documents (include \"documents\" in collection.get)", ErrInvalidIndexImport)
+	}
+	var out []ImportedChunk
+	for i, text := range export.Documents {
+		if text == nil {
+			continue
+		}
+		c := ImportedChunk{Text: *text}
+		if i < len(export.IDs) {
+			c.ID = export.IDs[i]
+		}
+		if i < len(export.Metadatas) {
+			c.Metadata = importedMetadata(export.Metadatas[i])
+		}
+		if i < len(export.Embeddings) {
+			c.Embedding = export.Embeddings[i]
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+func readFAISSExport(index, metadata io.Reader) ([]ImportedChunk, error) {
+	vectors, ids, err := readFAISSIndex(bufio.NewReader(index))
+	if err != nil {
+		return nil, err
+	}
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(metadata).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("%w: FAISS metadata: %v", ErrInvalidIndexImport, err)
+	}
+	var docs []exportedDocument
+	if err := json.Unmarshal(raw, &docs); err != nil {
+		var ls struct {
+			IndexToDocstoreID map[string]string           `json:"index_to_docstore_id"`
+			Docstore          map[string]exportedDocument `json:"docstore"`
+		}
+		if err := json.Unmarshal(raw, &ls); err != nil || ls.IndexToDocstoreID == nil {
+			return nil, fmt.Errorf("%w: FAISS metadata must be an array of documents or have index_to_docstore_id and docstore", ErrInvalidIndexImport)
+		}
+		docs = make([]exportedDocument, len(vectors))
+		for i := range vectors {
+			id, ok := ls.IndexToDocstoreID[strconv.Itoa(i)]
+			d, found := ls.Docstore[id]
+			if !ok || !found {
+				return nil, fmt.Errorf("%w: FAISS vector %d has no document", ErrInvalidIndexImport, i)
+			}
+			if d.ID == nil && d.NodeID == "" {
+				d.ID = id
+			}
+			docs[i] = d
+		}
+	}
+	if len(docs) != len(vectors) {
+		return nil, fmt.Errorf("%w: FAISS index has %d vectors but the metadata %d documents", ErrInvalidIndexImport, len(vectors), len(docs))
+	}
+
+	out := make([]ImportedChunk, len(docs))
+	for i := range docs {
+		c, ok := docs[i].chunk()
+		if !ok {
+			return nil, fmt.Errorf("%w: FAISS document %d has no page_content or text", ErrInvalidIndexImport, i+1)
+		}
+		c.Embedding = vectors[i]
+		if c.ID == "" && ids != nil {
+			c.ID = strconv.FormatInt(ids[i], 10)
+		}
+		out[i] = c
+	}
+	return out, nil
+}
+
+// readFAISSIndex reads the vectors of a flat FAISS index (IndexFlatL2 or
+// IndexFlatIP) and, for an IndexIDMap around one, their IDs.
+func readFAISSIndex(r io.Reader) ([][]float32, []int64, error) {
+	var fourcc [4]byte
+	if _, err := io.ReadFull(r, fourcc[:]); err != nil {
+		return nil, nil, fmt.Errorf("%w: FAISS index: %v", ErrInvalidIndexImport, err)
+	}
+	switch string(fourcc[:]) {
+	case "IxFI", "IxF2", "IxFl":
+		vectors, err := readFAISSFlat(r)
+		return vectors, nil, err
+	case "IxMp":
+		if _, err := readFAISSHeader(r); err != nil {
+			return nil, nil, err
+		}
+		vectors, _, err := readFAISSIndex(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		var n uint64
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil || n != uint64(len(vectors)) {
+			return nil, nil, fmt.Errorf("%w: FAISS ID map does not match its index", ErrInvalidIndexImport)
+		}
+		ids := make([]int64, n)
+		if err := binary.Read(r, binary.LittleEndian, ids); err != nil {
+			return nil, nil, fmt.Errorf("%w: FAISS ID map: %v", ErrInvalidIndexImport, err)
+		}
+		return vectors, ids, nil
+	}
+	return nil, nil, fmt.Errorf("%w: FAISS index type %q is not supported; export a flat index (IndexFlatL2/IP)", ErrInvalidIndexImport, fourcc[:])
+}
+
+// maxFAISSDim bounds the dimension read from a FAISS header, well above
+// that of any embedding model.
+const maxFAISSDim = 1 << 16
+
+// readFAISSHeader reads the header every FAISS index starts with, after
+// its type, and returns the dimension.
+func readFAISSHeader(r io.Reader) (int, error) {
+	var h struct {
+		Dim        int32
+		NTotal     int64
+		Dummy      [2]int64
+		IsTrained  bool
+		MetricType int32
+	}
+	if err := binary.Read(r, binary.LittleEndian, &h); err != nil {
+		return 0, fmt.Errorf("%w: FAISS header: %v", ErrInvalidIndexImport, err)
+	}
+	if h.MetricType > 1 {
+		var arg float32
+		if err := binary.Read(r, binary.LittleEndian, &arg); err != nil {
+			return 0, fmt.Errorf("%w: FAISS header: %v", ErrInvalidIndexImport, err)
+		}
+	}
+	if h.Dim <= 0 || h.Dim > maxFAISSDim {
+		return 0, fmt.Errorf("%w: FAISS index has dimension %d", ErrInvalidIndexImport, h.Dim)
+	}
+	return int(h.Dim), nil
+}
+
+func readFAISSFlat(r io.Reader) ([][]float32, error) {
+	dim, err := readFAISSHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	var n uint64
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return nil, fmt.Errorf("%w: FAISS vectors: %v", ErrInvalidIndexImport, err)
+	}
+	if n%uint64(dim) != 0 || n > 1<<34 {
+		return nil, fmt.Errorf("%w: FAISS index holds %d floats, not a multiple of dimension %d", ErrInvalidIndexImport, n, dim)
+	}
+	// The count comes from the file, so vectors are read one at a time:
+	// a truncated or forged index fails on read instead of allocating
+	// what its header claims.
+	var vectors [][]float32
+	for i := uint64(0); i < n/uint64(dim); i++ {
+		v := make([]float32, dim)
+		if err := binary.Read(r, binary.LittleEndian, v); err != nil {
+			return nil, fmt.Errorf("%w: FAISS vector %d of %d: %v", ErrInvalidIndexImport, i, n/uint64(dim), err)
+		}
+		vectors = append(vectors, v)
+	}
+	return vectors, nil
+}
@@ -0,0 +1,157 @@
+package goreason
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bbiangul/go-reason/llm/llmtest"
+)
+
+func TestReadIndexExport(t *testing.T) {
+	// A JSON lines dump, one document in LangChain's serialized form.
+	langchain := `{"page_content": "The relief valve opens at 10 bar.", "metadata": {"source": "pump.pdf", "page": 0}, "id": "a"}
+{"lc": 1, "type": "constructor", "id": ["langchain", "schema", "document", "Document"], "kwargs": {"page_content": "Inspect it yearly.", "metadata": {"source": "pump.pdf", "Header 1": "Service", "Header 2": "Valves"}}}
+`
+	chunks, err := ReadIndexExport(IndexFormatLangChain, strings.NewReader(langchain), nil)
+	if err != nil {
+		t.Fatalf("langchain: %v", err)
+	}
+	if len(chunks) != 2 || chunks[0].ID != "a" || chunks[1].ID != "" || chunks[1].Text != "Inspect it yearly." {
+		t.Fatalf("langchain chunks = %+v", chunks)
+	}
+	if importedPage(chunks[0].Metadata) != 1 || importedHeading(chunks[1].Metadata) != "Service > Valves" {
+		t.Errorf("page %d, heading %q", importedPage(chunks[0].Metadata), importedHeading(chunks[1].Metadata))
+	}
+
+	// A LlamaIndex node array.
+	nodes := `[{"id_": "n1", "text": "Torque to 40 Nm.", "metadata": {"file_name": "bolts.md"}, "embedding": [1, 0, 0, 0]}]`
+	if chunks, err = ReadIndexExport(IndexFormatLangChain, strings.NewReader(nodes), nil); err != nil || len(chunks) != 1 ||
+		chunks[0].ID != "n1" || len(chunks[0].Embedding) != 4 {
+		t.Errorf("llamaindex chunks = %+v, %v", chunks, err)
+	}
+
+	chroma := `{"ids": ["c1", "c2"], "documents": ["Torque to 40 Nm.", null],
+		"metadatas": [{"source": "bolts.md", "page_number": 3}, null], "embeddings": [[0, 1, 0, 0], [0, 0, 1, 0]]}`
+	chunks, err = ReadIndexExport(IndexFormatChroma, strings.NewReader(chroma), nil)
+	if err != nil {
+		t.Fatalf("chroma: %v", err)
+	}
+	if len(chunks) != 1 || chunks[0].ID != "c1" || chunks[0].Metadata["page_number"] != "3" || chunks[0].Embedding[1] != 1 {
+		t.Errorf("chroma chunks = %+v", chunks)
+	}
+
+	index := faissFlatIndex(t, 4, [][]float32{{1, 0, 0, 0}, {0, 1, 0, 0}})
+	docstore := `{"index_to_docstore_id": {"0": "u1", "1": "u2"},
+		"docstore": {"u1": {"page_content": "First."}, "u2": {"page_content": "Second."}}}`
+	chunks, err = ReadIndexExport(IndexFormatFAISS, bytes.NewReader(index), strings.NewReader(docstore))
+	if err != nil {
+		t.Fatalf("faiss: %v", err)
+	}
+	if len(chunks) != 2 || chunks[1].ID != "u2" || chunks[1].Text != "Second." || chunks[1].Embedding[1] != 1 {
+		t.Errorf("faiss chunks = %+v", chunks)
+	}
+	if _, err := ReadIndexExport(IndexFormatFAISS, bytes.NewReader(index), strings.NewReader(`[{"page_content": "Only one."}]`)); !errors.Is(err, ErrInvalidIndexImport) {
+		t.Errorf("faiss with too few documents: err = %v", err)
+	}
+	// A header claiming far more vectors than the file holds fails on
+	// read rather than allocating them.
+	forged := append([]byte(nil), index...)
+	binary.LittleEndian.PutUint64(forged[len(forged)-8*4-8:], 1<<33)
+	if _, err := ReadIndexExport(IndexFormatFAISS, bytes.NewReader(forged), strings.NewReader(docstore)); !errors.Is(err, ErrInvalidIndexImport) {
+		t.Errorf("faiss with a forged vector count: err = %v", err)
+	}
+	if _, err := ReadIndexExport("pinecone", strings.NewReader("{}"), nil); !errors.Is(err, ErrInvalidIndexImport) {
+		t.Errorf("unknown format: err = %v", err)
+	}
+}
+
+// faissFlatIndex encodes vectors as faiss.write_index writes an IndexFlatL2.
+func faissFlatIndex(t *testing.T, dim int32, vectors [][]float32) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	buf.WriteString("IxF2")
+	flat := make([]float32, 0, len(vectors)*int(dim))
+	for _, v := range vectors {
+		flat = append(flat, v...)
+	}
+	for _, v := range []interface{}{dim, int64(len(vectors)), int64(1 << 20), int64(1 << 20), true, int32(1), uint64(len(flat)), flat} {
+		if err := binary.Write(&buf, binary.LittleEndian, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestImportIndex(t *testing.T) {
+	eng, srv, _ := faultEngine(t, nil)
+	ctx := context.Background()
+
+	imp := IndexImport{
+		Source:         "chroma-prod",
+		EmbeddingModel: "fake",
+		Chunks: []ImportedChunk{
+			{Text: "Inspect the relief valve yearly.", Metadata: map[string]string{"source": "pump.pdf", "page": "1"}, Embedding: []float32{0, 1, 0, 0}},
+			{Text: "The relief valve opens at 10 bar.", Metadata: map[string]string{"source": "pump.pdf", "page": "0"}, Embedding: []float32{1, 0, 0, 0}},
+			{Text: "Torque the bolts to 40 Nm.", Metadata: map[string]string{"source": "bolts.md"}, Embedding: []float32{0, 0, 1, 0}},
+		},
+	}
+	embeds := srv.Calls(llmtest.OpEmbed)
+	res, err := eng.ImportIndex(ctx, imp)
+	if err != nil {
+		t.Fatalf("ImportIndex: %v", err)
+	}
+	if res.Documents != 2 || res.Chunks != 3 || res.VectorsReused != 3 || res.Reembedded != 0 || len(res.DocumentIDs) != 2 {
+		t.Errorf("result = %+v", res)
+	}
+	if n := srv.Calls(llmtest.OpEmbed) - embeds; n != 0 {
+		t.Errorf("%d embedding calls, want the imported vectors reused", n)
+	}
+
+	doc, err := eng.Document(ctx, res.DocumentIDs[0])
+	if err != nil {
+		t.Fatalf("Document: %v", err)
+	}
+	if doc.Path != "import://chroma-prod/pump.pdf" || doc.Status != "ready" || doc.ParseMethod != parseMethodImport {
+		t.Errorf("document = %+v", doc)
+	}
+	// Chunks keep their export's boundaries, ordered by page.
+	chunks, err := eng.(*engine).store.GetChunksByDocument(ctx, doc.ID)
+	if err != nil || len(chunks) != 2 || chunks[0].PageNumber != 1 || !strings.Contains(chunks[0].Content, "10 bar") {
+		t.Fatalf("chunks = %+v, %v", chunks, err)
+	}
+	answer, err := eng.Query(ctx, "At what pressure does the relief valve open?")
+	if err != nil || len(answer.Sources) == 0 {
+		t.Fatalf("Query over the import: %+v, %v", answer, err)
+	}
+
+	// Importing again changes nothing.
+	if res, err = eng.ImportIndex(ctx, imp); err != nil || res.Unchanged != 2 || res.Documents != 0 {
+		t.Errorf("re-import = %+v, %v", res, err)
+	}
+
+	// Vectors from another model are not stored; the chunks are embedded.
+	embeds = srv.Calls(llmtest.OpEmbed)
+	res, err = eng.ImportIndex(ctx, IndexImport{
+		Source:         "faiss-legacy",
+		EmbeddingModel: "text-embedding-ada-002",
+		Chunks:         []ImportedChunk{{Text: "Drain the tank monthly.", Embedding: []float32{1, 1, 1, 1}}},
+	})
+	if err != nil {
+		t.Fatalf("ImportIndex: %v", err)
+	}
+	if res.Reembedded != 1 || res.VectorsReused != 0 || srv.Calls(llmtest.OpEmbed) == embeds {
+		t.Errorf("other model's import = %+v", res)
+	}
+
+	if _, err := eng.ImportIndex(ctx, IndexImport{Chunks: imp.Chunks}); !errors.Is(err, ErrInvalidIndexImport) {
+		t.Errorf("import without source: err = %v", err)
+	}
+	entries, err := eng.AuditLog(ctx, AuditFilter{Operation: AuditImportIndex})
+	if err != nil || len(entries) != 4 {
+		t.Errorf("audit = %d entries, %v", len(entries), err)
+	}
+}
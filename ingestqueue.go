@@ -0,0 +1,104 @@
+package goreason
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// ingestQueue bounds concurrent Ingest calls. Up to cap(slots) ingests run
+// at once; further callers wait for a slot, and once maxWaiting callers are
+// already waiting new ones are rejected with ErrIngestQueueFull. This keeps
+// bursts of uploads from all hitting the embedding provider and the single
+// SQLite writer at the same time.
+//
+// A nil *ingestQueue imposes no limit.
+type ingestQueue struct {
+	slots      chan struct{}
+	maxWaiting int64 // 0 = unbounded
+	waiting    atomic.Int64
+}
+
+// newIngestQueue returns nil (no limit) when concurrency <= 0.
+func newIngestQueue(concurrency, maxWaiting int) *ingestQueue {
+	if concurrency <= 0 {
+		return nil
+	}
+	if maxWaiting < 0 {
+		maxWaiting = 0
+	}
+	return &ingestQueue{
+		slots:      make(chan struct{}, concurrency),
+		maxWaiting: int64(maxWaiting),
+	}
+}
+
+// acquire takes a slot, waiting if necessary. The returned release func
+// must be called when the ingest finishes.
+func (q *ingestQueue) acquire(ctx context.Context) (func(), error) {
+	if q == nil {
+		return func() {}, nil
+	}
+	release := func() { <-q.slots }
+
+	select {
+	case q.slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	if n := q.waiting.Add(1); q.maxWaiting > 0 && n > q.maxWaiting {
+		q.waiting.Add(-1)
+		return nil, ErrIngestQueueFull
+	}
+	defer q.waiting.Add(-1)
+
+	select {
+	case q.slots <- struct{}{}:
+		return release, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// acquireIngest takes an ingest slot and holds off exclusive maintenance
+// (see lockIngests) until the returned func is called.
+func (e *engine) acquireIngest(ctx context.Context) (func(), error) {
+	release, err := e.ingestQ.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	e.ingestGate.RLock()
+	return func() {
+		e.ingestGate.RUnlock()
+		release()
+	}, nil
+}
+
+// lockIngests waits for running ingests to finish and holds back new ones
+// until the returned func is called.
+func (e *engine) lockIngests() func() {
+	e.ingestGate.Lock()
+	return e.ingestGate.Unlock
+}
+
+// stats reports running and waiting ingests.
+func (q *ingestQueue) stats() IngestQueueStats {
+	if q == nil {
+		return IngestQueueStats{}
+	}
+	return IngestQueueStats{
+		Running:     len(q.slots),
+		Waiting:     int(q.waiting.Load()),
+		Concurrency: cap(q.slots),
+		MaxWaiting:  int(q.maxWaiting),
+	}
+}
+
+// IngestQueueStats is a snapshot of the ingestion queue. All fields are
+// zero when ingestion is unbounded.
+type IngestQueueStats struct {
+	Running     int `json:"running"`
+	Waiting     int `json:"waiting"`
+	Concurrency int `json:"concurrency"`
+	MaxWaiting  int `json:"max_waiting"`
+}
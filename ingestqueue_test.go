@@ -0,0 +1,76 @@
+package goreason
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestIngestQueueLimitsConcurrency(t *testing.T) {
+	q := newIngestQueue(1, 1)
+	ctx := context.Background()
+
+	release, err := q.acquire(ctx)
+	if err != nil {
+		t.Fatalf("first acquire: %v", err)
+	}
+
+	acquired := make(chan func(), 1)
+	go func() {
+		r, err := q.acquire(ctx)
+		if err != nil {
+			t.Errorf("queued acquire: %v", err)
+		}
+		acquired <- r
+	}()
+
+	// Wait until the second caller is queued.
+	for q.stats().Waiting != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := q.acquire(ctx); !errors.Is(err, ErrIngestQueueFull) {
+		t.Errorf("expected ErrIngestQueueFull, got %v", err)
+	}
+
+	release()
+	select {
+	case r := <-acquired:
+		if s := q.stats(); s.Running != 1 || s.Waiting != 0 {
+			t.Errorf("stats = %+v", s)
+		}
+		r()
+	case <-time.After(time.Second):
+		t.Fatal("queued ingest never acquired a slot")
+	}
+}
+
+func TestIngestQueueContextCancel(t *testing.T) {
+	q := newIngestQueue(1, 0)
+	release, _ := q.acquire(context.Background())
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := q.acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+	if w := q.stats().Waiting; w != 0 {
+		t.Errorf("waiting = %d after cancel", w)
+	}
+}
+
+func TestIngestQueueUnlimited(t *testing.T) {
+	q := newIngestQueue(0, 0)
+	if q != nil {
+		t.Fatal("expected nil queue for zero concurrency")
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := q.acquire(context.Background()); err != nil {
+			t.Fatalf("acquire: %v", err)
+		}
+	}
+	if s := q.stats(); s != (IngestQueueStats{}) {
+		t.Errorf("stats = %+v", s)
+	}
+}
@@ -0,0 +1,163 @@
+package goreason
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bbiangul/go-reason/store"
+)
+
+// Injection policies (Config.InjectionPolicy).
+const (
+	// InjectionFlag strips chat control tokens from chunks and marks chunks
+	// with instruction-like text, which the reasoning prompt then labels.
+	InjectionFlag = "flag"
+	// InjectionDrop also leaves flagged chunks out of the index.
+	InjectionDrop = "drop"
+	// InjectionOff stores chunk text unchanged. This is the default.
+	InjectionOff = "off"
+)
+
+// injectionMetaKey is the chunk metadata key listing a flagged chunk's
+// signals, comma-separated.
+const injectionMetaKey = "injection_suspect"
+
+// controlTokenPattern matches chat-template control tokens (<|im_start|>,
+// <|eot_id|>, [INST], <<SYS>>) that have no place in document text but can
+// make a model treat what follows as a new turn.
+var controlTokenPattern = regexp.MustCompile(`<\|[A-Za-z0-9_]{1,32}\|>|\[/?INST\]|<</?SYS>>`)
+
+// injectionSignals are phrasings that address the model rather than the
+// reader. They flag chunks; the text is kept, since manuals and policies
+// legitimately quote such phrases.
+var injectionSignals = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{"override", regexp.MustCompile(`(?i)\b(?:ignore|disregard|forget|override)\s+(?:all\s+|any\s+|the\s+|your\s+)*(?:previous|prior|above|earlier|preceding|system)\s+(?:instructions|prompts?|rules|directions|context)\b`)},
+	{"override", regexp.MustCompile(`(?i)\b(?:ignora|olvida|omite)\s+(?:todas\s+)?(?:las\s+)?instrucciones\s+(?:anteriores|previas)\b`)},
+	{"role_change", regexp.MustCompile(`(?i)\b(?:you\s+are\s+now\s+(?:a|an|the|in)\b|from\s+now\s+on,?\s+you\b|pretend\s+(?:to\s+be|you\s+are)\b)`)},
+	{"prompt_exfiltration", regexp.MustCompile(`(?i)\b(?:reveal|print|repeat|show|output|disclose)\s+(?:your|the)\s+(?:system\s+prompt|hidden\s+instructions|initial\s+instructions)\b`)},
+	{"fake_turn", regexp.MustCompile(`(?im)^\s*#*\s*(?:system|assistant)\s+(?:prompt|message|instructions?)\s*:`)},
+}
+
+// InjectionReport counts what the ingest-time injection scan found in a
+// document (QualityReport.Injection).
+type InjectionReport struct {
+	FlaggedChunks int `json:"flagged_chunks"`
+	// DroppedChunks are flagged chunks left out under InjectionDrop.
+	DroppedChunks int `json:"dropped_chunks,omitempty"`
+	// ControlTokens is the number of chat control tokens removed.
+	ControlTokens int `json:"control_tokens_removed"`
+	// Signals counts flagged chunks per signal: "control_token",
+	// "override", "role_change", "prompt_exfiltration", "fake_turn".
+	Signals map[string]int `json:"signals,omitempty"`
+}
+
+// validInjectionPolicy reports whether p is a known injection policy.
+func validInjectionPolicy(p string) bool {
+	switch p {
+	case "", InjectionFlag, InjectionDrop, InjectionOff:
+		return true
+	}
+	return false
+}
+
+// injectionScan is the ChunkFilter behind Config.InjectionPolicy. It runs
+// after the application's filters, so it sees the text that is stored.
+type injectionScan struct {
+	drop   bool
+	report InjectionReport
+}
+
+// newInjectionScan returns nil when the policy is empty or InjectionOff.
+func newInjectionScan(policy string) *injectionScan {
+	if policy == "" || policy == InjectionOff {
+		return nil
+	}
+	return &injectionScan{drop: policy == InjectionDrop}
+}
+
+// FilterChunk strips control tokens and flags instruction-like text.
+func (s *injectionScan) FilterChunk(_ context.Context, _ ChunkDocument, c store.Chunk) ([]store.Chunk, error) {
+	content, signals := scanInjection(c.Content)
+	if len(signals) == 0 {
+		return []store.Chunk{c}, nil
+	}
+	if n := len(controlTokenPattern.FindAllStringIndex(c.Content, -1)); n > 0 {
+		s.report.ControlTokens += n
+		c.Content = content
+	}
+	s.report.FlaggedChunks++
+	if s.report.Signals == nil {
+		s.report.Signals = make(map[string]int)
+	}
+	for _, sig := range signals {
+		s.report.Signals[sig]++
+	}
+	if s.drop {
+		s.report.DroppedChunks++
+		return nil, nil
+	}
+	meta := ChunkMetadata(c)
+	meta[injectionMetaKey] = strings.Join(signals, ",")
+	SetChunkMetadata(&c, meta)
+	return []store.Chunk{c}, nil
+}
+
+// result returns the scan's report, or nil when scanning is off.
+func (s *injectionScan) result() *InjectionReport {
+	if s == nil {
+		return nil
+	}
+	r := s.report
+	return &r
+}
+
+// scanInjection removes control tokens from content and returns the
+// sorted names of the signals found.
+func scanInjection(content string) (string, []string) {
+	found := make(map[string]bool)
+	if controlTokenPattern.MatchString(content) {
+		found["control_token"] = true
+		content = controlTokenPattern.ReplaceAllString(content, "")
+	}
+	for _, sig := range injectionSignals {
+		if !found[sig.name] && sig.pattern.MatchString(content) {
+			found[sig.name] = true
+		}
+	}
+	if len(found) == 0 {
+		return content, nil
+	}
+	names := make([]string, 0, len(found))
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return content, names
+}
+
+// warning describes the report for the quality warnings, or "".
+func (r *InjectionReport) warning() string {
+	if r == nil || r.FlaggedChunks == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(r.Signals))
+	for name := range r.Signals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s: %d", name, r.Signals[name])
+	}
+	w := fmt.Sprintf("%d chunks contain text addressed to the model (%s)", r.FlaggedChunks, strings.Join(parts, ", "))
+	if r.DroppedChunks > 0 {
+		w += fmt.Sprintf("; %d were left out of the index", r.DroppedChunks)
+	}
+	return w
+}
@@ -0,0 +1,215 @@
+package goreason
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/bbiangul/go-reason/llm"
+	"github.com/bbiangul/go-reason/parser"
+	"github.com/bbiangul/go-reason/store"
+)
+
+// keyFactsInputChars caps the document text sent to the LLM for key-facts
+// extraction. Contracts name their parties, dates, and amounts up front,
+// manuals their models and ratings; the budget is larger than the
+// summary's for the schedules and specification tables that follow.
+const keyFactsInputChars = 24000
+
+// Limits on what one extraction stores per field.
+const (
+	maxKeyFactValues   = 20
+	maxKeyFactValueLen = 300
+)
+
+// builtinKeyFactSchemas are used for schemas listed by name without fields.
+var builtinKeyFactSchemas = map[string]KeyFactSchemaConfig{
+	"contract": {
+		Name:        "contract",
+		Description: "contracts, agreements, purchase orders, and amendments",
+		Fields: []KeyFactFieldConfig{
+			{Name: "parties", Description: "the contracting parties"},
+			{Name: "effective_date", Description: "when the agreement takes effect"},
+			{Name: "expiration_date", Description: "when it ends or must be renewed"},
+			{Name: "amounts", Description: "prices, fees, penalties, and liability caps, with currency"},
+			{Name: "governing_law", Description: "the governing law or jurisdiction"},
+		},
+	},
+	"manual": {
+		Name:        "manual",
+		Description: "product manuals, datasheets, and installation or service guides",
+		Fields: []KeyFactFieldConfig{
+			{Name: "manufacturer", Description: "the manufacturer or vendor"},
+			{Name: "model_numbers", Description: "product models and part numbers covered"},
+			{Name: "voltages", Description: "rated supply and operating voltages"},
+			{Name: "standards", Description: "standards, certifications, and regulations referenced"},
+			{Name: "revision", Description: "the document revision or publication date"},
+		},
+	},
+}
+
+// resolveKeyFactSchemas returns the configured schemas with the built-in
+// fields filled in for schemas listed by name only.
+func resolveKeyFactSchemas(schemas []KeyFactSchemaConfig) []KeyFactSchemaConfig {
+	out := make([]KeyFactSchemaConfig, len(schemas))
+	for i, s := range schemas {
+		if b, ok := builtinKeyFactSchemas[s.Name]; ok && len(s.Fields) == 0 {
+			if s.Description != "" {
+				b.Description = s.Description
+			}
+			s = b
+		}
+		out[i] = s
+	}
+	return out
+}
+
+func validateKeyFacts(schemas []KeyFactSchemaConfig) error {
+	names := make(map[string]bool)
+	for _, s := range schemas {
+		if s.Name == "" {
+			return fmt.Errorf("%w: key_facts schema name is required", ErrInvalidConfig)
+		}
+		if names[s.Name] {
+			return fmt.Errorf("%w: duplicate key_facts schema %q", ErrInvalidConfig, s.Name)
+		}
+		names[s.Name] = true
+		if _, ok := builtinKeyFactSchemas[s.Name]; !ok && len(s.Fields) == 0 {
+			return fmt.Errorf("%w: key_facts schema %q has no fields", ErrInvalidConfig, s.Name)
+		}
+		fields := make(map[string]bool)
+		for _, f := range s.Fields {
+			if f.Name == "" || fields[f.Name] {
+				return fmt.Errorf("%w: key_facts schema %q: field names must be unique and non-empty", ErrInvalidConfig, s.Name)
+			}
+			fields[f.Name] = true
+		}
+	}
+	return nil
+}
+
+const keyFactsPrompt = `You are filling in a key-facts record for a document index.
+Pick the schema below that describes the document and return a JSON object with exactly these keys:
+  "schema" : string (the schema name, or "" if no schema fits the document)
+  "facts"  : object mapping each field of that schema to an array of strings (the values the document states, copied as written; [] when it states none)
+
+Only record values stated in the document. Do NOT include any text outside the JSON object.
+
+SCHEMAS:
+%s
+DOCUMENT: %s
+
+%s`
+
+// keyFactsResult is the JSON shape returned by the key-facts LLM call.
+// Values are arrays of strings, but a single string is accepted too.
+type keyFactsResult struct {
+	Schema string                     `json:"schema"`
+	Facts  map[string]json.RawMessage `json:"facts"`
+}
+
+// extractKeyFacts asks the chat LLM for the document's key-facts record and
+// replaces the stored one. It returns how many values were stored.
+func (e *engine) extractKeyFacts(ctx context.Context, docID int64, filename string, sections []parser.Section) (int, error) {
+	text := sectionText(sections, keyFactsInputChars)
+	if text == "" {
+		return 0, nil
+	}
+	schemas := resolveKeyFactSchemas(e.cfg.KeyFacts)
+
+	var b strings.Builder
+	for _, s := range schemas {
+		fmt.Fprintf(&b, "- %s", s.Name)
+		if s.Description != "" {
+			fmt.Fprintf(&b, ": %s", s.Description)
+		}
+		b.WriteString("\n")
+		for _, f := range s.Fields {
+			fmt.Fprintf(&b, "    %s", f.Name)
+			if f.Description != "" {
+				fmt.Fprintf(&b, ": %s", f.Description)
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	resp, err := e.chatLLM.Chat(ctx, llm.ChatRequest{
+		Messages: []llm.Message{
+			{Role: "user", Content: fmt.Sprintf(keyFactsPrompt, b.String(), filename, text)},
+		},
+		Temperature:    0.0,
+		ResponseFormat: "json_object",
+	})
+	if err != nil {
+		return 0, fmt.Errorf("key facts llm chat: %w", err)
+	}
+
+	facts, err := parseKeyFacts(resp.Content, schemas)
+	if err != nil {
+		return 0, err
+	}
+	if err := e.store.ReplaceKeyFacts(ctx, docID, facts); err != nil {
+		return 0, fmt.Errorf("storing key facts: %w", err)
+	}
+	return len(facts), nil
+}
+
+// parseKeyFacts decodes the LLM response, tolerating surrounding prose,
+// and keeps the values of the chosen schema's fields, trimmed,
+// deduplicated, and capped. An unknown or empty schema yields no facts.
+func parseKeyFacts(raw string, schemas []KeyFactSchemaConfig) ([]store.KeyFact, error) {
+	var result keyFactsResult
+	if err := json.Unmarshal([]byte(llm.JSONObject(raw)), &result); err != nil {
+		return nil, fmt.Errorf("unmarshalling key facts result: %w", err)
+	}
+	var schema *KeyFactSchemaConfig
+	for i := range schemas {
+		if strings.EqualFold(schemas[i].Name, strings.TrimSpace(result.Schema)) {
+			schema = &schemas[i]
+			break
+		}
+	}
+	if schema == nil {
+		return nil, nil
+	}
+
+	var facts []store.KeyFact
+	for _, f := range schema.Fields {
+		msg, ok := result.Facts[f.Name]
+		if !ok {
+			continue
+		}
+		var values []string
+		if err := json.Unmarshal(msg, &values); err != nil {
+			var one string
+			if json.Unmarshal(msg, &one) != nil {
+				continue
+			}
+			values = []string{one}
+		}
+		seen := make(map[string]bool)
+		pos := 0
+		for _, v := range values {
+			v = strings.TrimSpace(v)
+			if len(v) > maxKeyFactValueLen {
+				v = strings.ToValidUTF8(v[:maxKeyFactValueLen], "")
+			}
+			if v == "" || seen[strings.ToLower(v)] {
+				continue
+			}
+			seen[strings.ToLower(v)] = true
+			facts = append(facts, store.KeyFact{Schema: schema.Name, Field: f.Name, Value: v, Position: pos})
+			pos++
+			if pos == maxKeyFactValues {
+				break
+			}
+		}
+	}
+	return facts, nil
+}
+
+// KeyFacts returns the stored key facts matching filter.
+func (e *engine) KeyFacts(ctx context.Context, filter store.KeyFactFilter) ([]store.KeyFact, error) {
+	return e.store.ListKeyFacts(ctx, filter)
+}
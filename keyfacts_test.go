@@ -0,0 +1,104 @@
+package goreason
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bbiangul/go-reason/store"
+)
+
+func TestParseKeyFacts(t *testing.T) {
+	schemas := resolveKeyFactSchemas([]KeyFactSchemaConfig{{Name: "contract"}, {Name: "manual"}})
+	if len(schemas[1].Fields) == 0 {
+		t.Fatal("built-in manual schema has no fields")
+	}
+
+	raw := "Here you go:\n" + `{"schema": "Manual", "facts": {
+		"model_numbers": ["XR-200", " XR-200 ", "xr-200", "XR-300"],
+		"voltages": "230 V",
+		"standards": [],
+		"warranty": ["2 years"]
+	}}`
+	facts, err := parseKeyFacts(raw, schemas)
+	if err != nil {
+		t.Fatalf("parseKeyFacts: %v", err)
+	}
+	var got []string
+	for _, f := range facts {
+		if f.Schema != "manual" {
+			t.Errorf("fact %+v: schema %q, want manual", f, f.Schema)
+		}
+		got = append(got, f.Field+"="+f.Value)
+	}
+	want := "model_numbers=XR-200,model_numbers=XR-300,voltages=230 V"
+	if strings.Join(got, ",") != want {
+		t.Errorf("facts = %s, want %s", strings.Join(got, ","), want)
+	}
+	if facts[1].Position != 1 {
+		t.Errorf("second model position = %d, want 1", facts[1].Position)
+	}
+
+	if facts, err := parseKeyFacts(`{"schema": "", "facts": {}}`, schemas); err != nil || facts != nil {
+		t.Errorf("no schema = %+v, %v", facts, err)
+	}
+	if _, err := parseKeyFacts("not json", schemas); err == nil {
+		t.Error("expected an error for a non-JSON response")
+	}
+}
+
+func TestValidateKeyFacts(t *testing.T) {
+	for _, bad := range [][]KeyFactSchemaConfig{
+		{{Name: ""}},
+		{{Name: "contract"}, {Name: "contract"}},
+		{{Name: "report"}},
+		{{Name: "report", Fields: []KeyFactFieldConfig{{Name: "date"}, {Name: "date"}}}},
+	} {
+		if err := validateKeyFacts(bad); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("validateKeyFacts(%+v) = %v, want ErrInvalidConfig", bad, err)
+		}
+	}
+	if err := validateKeyFacts([]KeyFactSchemaConfig{{Name: "manual"}, {Name: "report", Fields: []KeyFactFieldConfig{{Name: "date"}}}}); err != nil {
+		t.Errorf("valid schemas: %v", err)
+	}
+}
+
+func TestKeyFactsIngest(t *testing.T) {
+	eng, srv, path := faultEngine(t, nil)
+	e := eng.(*engine)
+	e.cfg.KeyFacts = []KeyFactSchemaConfig{{Name: "manual"}}
+	chat := srv.ChatFunc
+	srv.ChatFunc = func(prompt string) string {
+		if strings.Contains(prompt, "key-facts record") {
+			return `{"schema": "manual", "facts": {"manufacturer": ["Acme Pumps"], "model_numbers": ["XR-200"]}}`
+		}
+		return chat(prompt)
+	}
+	ctx := context.Background()
+	docID, err := eng.Ingest(ctx, path)
+	if err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+
+	facts, err := eng.KeyFacts(ctx, store.KeyFactFilter{Query: "acme"})
+	if err != nil {
+		t.Fatalf("KeyFacts: %v", err)
+	}
+	if len(facts) != 1 || facts[0].DocumentID != docID || facts[0].Field != "manufacturer" || facts[0].Filename == "" {
+		t.Fatalf("facts = %+v", facts)
+	}
+	if facts, _ := eng.KeyFacts(ctx, store.KeyFactFilter{DocumentID: docID, Field: "model_numbers"}); len(facts) != 1 || facts[0].Value != "XR-200" {
+		t.Errorf("model facts = %+v", facts)
+	}
+	if facts, _ := eng.KeyFacts(ctx, store.KeyFactFilter{Query: "%"}); len(facts) != 0 {
+		t.Errorf("a literal %% matched %+v", facts)
+	}
+
+	if err := eng.Delete(ctx, docID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if facts, _ := eng.KeyFacts(ctx, store.KeyFactFilter{}); len(facts) != 0 {
+		t.Errorf("facts left after delete: %+v", facts)
+	}
+}
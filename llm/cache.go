@@ -0,0 +1,300 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Prompt caching.
+//
+// A message's CachePrefix marks where the part of a conversation that later
+// requests repeat ends: a large document, or the retrieved context shared by
+// the answer and refinement rounds. How that is used depends on the
+// provider:
+//
+//   - OpenAI, and Gemini's implicit caching, cache long repeated prefixes
+//     on their own; the mark changes nothing on the wire.
+//   - Anthropic models (through OpenRouter, or a custom endpoint with
+//     Config.CacheControl) cache only up to explicit cache_control
+//     breakpoints, which the mark becomes.
+//   - Gemini stores the prefix as cached content (explicit caching) and
+//     later requests reference it instead of resending it.
+//
+// Providers report cache hits in ChatResponse.CachedTokens.
+
+// hasCachePrefix reports whether any message marks a cacheable prefix.
+func hasCachePrefix(msgs []Message) bool {
+	for _, m := range msgs {
+		if m.CachePrefix > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// cachePrefixLen clamps m.CachePrefix to its content.
+func cachePrefixLen(m Message) int {
+	if m.CachePrefix > len(m.Content) {
+		return len(m.Content)
+	}
+	return m.CachePrefix
+}
+
+type cacheControl struct {
+	Type string `json:"type"`
+}
+
+type cachedTextPart struct {
+	Type         string        `json:"type"`
+	Text         string        `json:"text"`
+	CacheControl *cacheControl `json:"cache_control,omitempty"`
+}
+
+// cacheControlMessage is an openAIMessage whose content is split into text
+// parts, the first ending at a cache_control breakpoint.
+type cacheControlMessage struct {
+	Role       string           `json:"role"`
+	Content    []cachedTextPart `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+// cacheControlMessages converts messages to the OpenAI wire format, turning
+// each CachePrefix into an ephemeral cache_control breakpoint.
+func cacheControlMessages(msgs []Message) []interface{} {
+	plain := openAIMessages(msgs)
+	out := make([]interface{}, len(msgs))
+	for i, m := range msgs {
+		n := cachePrefixLen(m)
+		if n <= 0 {
+			out[i] = plain[i]
+			continue
+		}
+		parts := []cachedTextPart{{Type: "text", Text: m.Content[:n], CacheControl: &cacheControl{Type: "ephemeral"}}}
+		if rest := m.Content[n:]; rest != "" {
+			parts = append(parts, cachedTextPart{Type: "text", Text: rest})
+		}
+		out[i] = cacheControlMessage{Role: plain[i].Role, Content: parts, ToolCalls: plain[i].ToolCalls, ToolCallID: plain[i].ToolCallID}
+	}
+	return out
+}
+
+// splitAtCachePrefix splits msgs at the last CachePrefix mark into the
+// cacheable prefix and the rest of the conversation. ok is false when no
+// message is marked.
+func splitAtCachePrefix(msgs []Message) (prefix, rest []Message, ok bool) {
+	for i := len(msgs) - 1; i >= 0; i-- {
+		n := cachePrefixLen(msgs[i])
+		if n <= 0 {
+			continue
+		}
+		head, tail := msgs[i], msgs[i]
+		head.Content, head.CachePrefix = head.Content[:n], 0
+		tail.Content, tail.CachePrefix = tail.Content[n:], 0
+		prefix = append(append([]Message(nil), msgs[:i]...), head)
+		if tail.Content != "" {
+			rest = append(rest, tail)
+		}
+		rest = append(rest, msgs[i+1:]...)
+		return prefix, rest, true
+	}
+	return nil, msgs, false
+}
+
+// --- Gemini explicit caching ---
+
+// geminiCacheTTL is how long Gemini keeps cached content. Entries are
+// reused until a minute before they expire.
+const geminiCacheTTL = 10 * time.Minute
+
+// geminiMinCacheTokens returns the smallest prefix Gemini will cache for a
+// model. Smaller prefixes are sent as usual (implicit caching still
+// applies).
+func geminiMinCacheTokens(model string) int {
+	if strings.Contains(model, "pro") {
+		return 4096
+	}
+	return 1024
+}
+
+// geminiCache creates and remembers Gemini cached contents, keyed by model
+// and prefix.
+type geminiCache struct {
+	client  *http.Client
+	baseURL string // native API root, e.g. .../v1beta
+	apiKey  string
+
+	mu      sync.Mutex
+	entries map[string]geminiCacheEntry
+}
+
+type geminiCacheEntry struct {
+	name    string // "cachedContents/..."
+	expires time.Time
+}
+
+func newGeminiCache(client *http.Client, openAIBaseURL, apiKey string) *geminiCache {
+	return &geminiCache{
+		client:  client,
+		baseURL: strings.TrimSuffix(strings.TrimSuffix(openAIBaseURL, "/"), "/openai"),
+		apiKey:  apiKey,
+		entries: make(map[string]geminiCacheEntry),
+	}
+}
+
+func geminiCacheKey(model string, prefix []Message) string {
+	h := sha256.New()
+	h.Write([]byte(model))
+	for _, m := range prefix {
+		fmt.Fprintf(h, "\x00%s\x00%d\x00%s", m.Role, len(m.Content), m.Content)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// lookup returns the name of the live cached content for prefix, creating
+// it when there is none.
+func (g *geminiCache) lookup(ctx context.Context, model string, prefix []Message) (string, error) {
+	key := geminiCacheKey(model, prefix)
+	g.mu.Lock()
+	e, ok := g.entries[key]
+	g.mu.Unlock()
+	if ok && time.Until(e.expires) > time.Minute {
+		return e.name, nil
+	}
+
+	name, err := g.create(ctx, model, prefix)
+	if err != nil {
+		return "", err
+	}
+	g.put(key, name, time.Now())
+	return name, nil
+}
+
+// put remembers the cached content created at now under key, dropping
+// the entries Gemini has let expire by then.
+func (g *geminiCache) put(key, name string, now time.Time) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for k, e := range g.entries {
+		if !now.Before(e.expires) {
+			delete(g.entries, k)
+		}
+	}
+	g.entries[key] = geminiCacheEntry{name: name, expires: now.Add(geminiCacheTTL)}
+}
+
+// forget drops the entry for prefix, e.g. after Gemini rejected it.
+func (g *geminiCache) forget(model string, prefix []Message) {
+	g.mu.Lock()
+	delete(g.entries, geminiCacheKey(model, prefix))
+	g.mu.Unlock()
+}
+
+// create stores prefix as Gemini cached content and returns its name.
+func (g *geminiCache) create(ctx context.Context, model string, prefix []Message) (string, error) {
+	system, contents, err := geminiContents(prefix)
+	if err != nil {
+		return "", err
+	}
+	if len(contents) == 0 {
+		return "", fmt.Errorf("gemini cache: prefix has no contents")
+	}
+	body := map[string]interface{}{
+		"model":    "models/" + strings.TrimPrefix(model, "models/"),
+		"contents": contents,
+		"ttl":      fmt.Sprintf("%ds", int(geminiCacheTTL.Seconds())),
+	}
+	if system != "" {
+		body["systemInstruction"] = map[string]interface{}{
+			"parts": []map[string]interface{}{{"text": system}},
+		}
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", g.baseURL+"/cachedContents", bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if g.apiKey != "" {
+		req.Header.Set("x-goog-api-key", g.apiKey)
+	}
+	setRequestID(req)
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("gemini cache: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("gemini cache: reading response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("gemini cache: API error %d: %s", resp.StatusCode, string(respBody))
+	}
+	var out struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(respBody, &out); err != nil || out.Name == "" {
+		return "", fmt.Errorf("gemini cache: unexpected response: %s", string(respBody))
+	}
+	return out.Name, nil
+}
+
+// chatCached sends req through Gemini's OpenAI endpoint, replacing its
+// marked prefix with cached content when the prefix is large enough to
+// cache. Any caching failure falls back to the plain request.
+func (g *geminiCache) chatCached(ctx context.Context, c *openAICompatClient, req ChatRequest) (*ChatResponse, error) {
+	prefix, rest, ok := splitAtCachePrefix(req.Messages)
+	if !ok || len(rest) == 0 {
+		return c.chat(ctx, req)
+	}
+	model := req.Model
+	if model == "" {
+		model = c.cfg.Model
+	}
+	var chars int
+	for _, m := range prefix {
+		chars += len(m.Content)
+	}
+	// Cached content replaces the system instruction, so a system message
+	// after the mark cannot be sent alongside it.
+	for _, m := range rest {
+		if m.Role == "system" {
+			return c.chat(ctx, req)
+		}
+	}
+	if chars/4 < geminiMinCacheTokens(model) {
+		return c.chat(ctx, req)
+	}
+
+	name, err := g.lookup(ctx, model, prefix)
+	if err != nil {
+		slog.WarnContext(ctx, "llm: gemini context cache unavailable, sending full prompt", "error", err)
+		return c.chat(ctx, req)
+	}
+	cachedReq := req
+	cachedReq.Messages = rest
+	extra := map[string]interface{}{"google": map[string]interface{}{"cached_content": name}}
+	resp, err := c.chatExtra(ctx, cachedReq, extra)
+	if err != nil && ctx.Err() == nil {
+		// The cache may have expired or been evicted early.
+		slog.WarnContext(ctx, "llm: gemini cached request failed, sending full prompt", "cache", name, "error", err)
+		g.forget(model, prefix)
+		return c.chat(ctx, req)
+	}
+	return resp, err
+}
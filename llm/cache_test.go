@@ -0,0 +1,197 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSplitAtCachePrefix(t *testing.T) {
+	msgs := []Message{
+		{Role: "system", Content: "sys"},
+		{Role: "user", Content: "Context:\nabc\n\nQuestion: q", CachePrefix: len("Context:\nabc\n\n")},
+	}
+	prefix, rest, ok := splitAtCachePrefix(msgs)
+	if !ok || len(prefix) != 2 || prefix[1].Content != "Context:\nabc\n\n" {
+		t.Fatalf("prefix = %+v, ok = %v", prefix, ok)
+	}
+	if len(rest) != 1 || rest[0].Role != "user" || rest[0].Content != "Question: q" || rest[0].CachePrefix != 0 {
+		t.Errorf("rest = %+v", rest)
+	}
+	if msgs[1].CachePrefix == 0 {
+		t.Error("input messages were modified")
+	}
+
+	if _, rest, ok := splitAtCachePrefix(msgs[:1]); ok || len(rest) != 1 {
+		t.Errorf("unmarked messages: ok = %v, rest = %+v", ok, rest)
+	}
+}
+
+func TestCacheControlMessages(t *testing.T) {
+	out := cacheControlMessages([]Message{
+		{Role: "system", Content: "sys"},
+		{Role: "user", Content: "DOC\nQuestion", CachePrefix: 4},
+	})
+	data, _ := json.Marshal(out)
+	want := `[{"role":"system","content":"sys"},{"role":"user","content":[` +
+		`{"type":"text","text":"DOC\n","cache_control":{"type":"ephemeral"}},` +
+		`{"type":"text","text":"Question"}]}]`
+	if string(data) != want {
+		t.Errorf("got  %s\nwant %s", data, want)
+	}
+}
+
+// cacheServer records chat request bodies and answers with a usage block
+// reporting cached tokens.
+type cacheServer struct {
+	mu       sync.Mutex
+	chats    []map[string]interface{}
+	caches   []map[string]interface{}
+	failChat bool
+}
+
+func (s *cacheServer) handler(t *testing.T) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1beta/cachedContents", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		s.mu.Lock()
+		s.caches = append(s.caches, body)
+		s.mu.Unlock()
+		if r.Header.Get("x-goog-api-key") != "key" {
+			t.Errorf("cachedContents without API key header")
+		}
+		w.Write([]byte(`{"name": "cachedContents/abc123"}`))
+	})
+	chat := func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		s.mu.Lock()
+		s.chats = append(s.chats, body)
+		fail := s.failChat && body["extra_body"] != nil
+		s.mu.Unlock()
+		if fail {
+			http.Error(w, `{"error":{"message":"cached content not found"}}`, http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"model": "m", "choices": [{"message": {"content": "ok"}, "finish_reason": "stop"}],
+			"usage": {"prompt_tokens": 100, "completion_tokens": 5, "total_tokens": 105, "prompt_tokens_details": {"cached_tokens": 80}}}`))
+	}
+	mux.HandleFunc("/v1beta/openai/chat/completions", chat)
+	mux.HandleFunc("/v1/chat/completions", chat)
+	return mux
+}
+
+func TestOpenRouterSendsCacheControl(t *testing.T) {
+	s := &cacheServer{}
+	srv := httptest.NewServer(s.handler(t))
+	defer srv.Close()
+
+	p := NewOpenRouter(Config{Model: "anthropic/claude", BaseURL: srv.URL})
+	resp, err := p.Chat(context.Background(), ChatRequest{Messages: []Message{
+		{Role: "user", Content: "DOC\nQ", CachePrefix: 4},
+	}})
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if resp.CachedTokens != 80 {
+		t.Errorf("cached tokens = %d, want 80", resp.CachedTokens)
+	}
+	data, _ := json.Marshal(s.chats[0]["messages"])
+	if !strings.Contains(string(data), `"cache_control":{"type":"ephemeral"}`) {
+		t.Errorf("messages = %s, want a cache_control breakpoint", data)
+	}
+
+	// Plain OpenAI-compatible endpoints get string content.
+	p = NewOpenAICompat(Config{Model: "m", BaseURL: srv.URL})
+	if _, err := p.Chat(context.Background(), ChatRequest{Messages: []Message{
+		{Role: "user", Content: "DOC\nQ", CachePrefix: 4},
+	}}); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	data, _ = json.Marshal(s.chats[1]["messages"])
+	if string(data) != `[{"content":"DOC\nQ","role":"user"}]` {
+		t.Errorf("messages = %s", data)
+	}
+}
+
+func TestGeminiCachedContent(t *testing.T) {
+	s := &cacheServer{}
+	srv := httptest.NewServer(s.handler(t))
+	defer srv.Close()
+
+	p := NewGemini(Config{Model: "gemini-2.5-flash", BaseURL: srv.URL + "/v1beta/openai", APIKey: "key"})
+	doc := "Context:\n" + strings.Repeat("The relief valve opens at 10 bar. ", 200) + "\n\n"
+	req := ChatRequest{Messages: []Message{
+		{Role: "system", Content: "Answer from the context."},
+		{Role: "user", Content: doc + "Question: when does it open?", CachePrefix: len(doc)},
+	}}
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if _, err := p.Chat(ctx, req); err != nil {
+			t.Fatalf("Chat %d: %v", i, err)
+		}
+	}
+
+	// One cache for both requests, holding the system prompt and the context.
+	if len(s.caches) != 1 {
+		t.Fatalf("created %d caches, want 1", len(s.caches))
+	}
+	if s.caches[0]["model"] != "models/gemini-2.5-flash" || s.caches[0]["systemInstruction"] == nil {
+		t.Errorf("cache request = %v", s.caches[0])
+	}
+	for _, chat := range s.chats {
+		data, _ := json.Marshal(chat)
+		if !strings.Contains(string(data), `"cached_content":"cachedContents/abc123"`) {
+			t.Errorf("request does not reference the cache: %s", data)
+		}
+		if strings.Contains(string(data), "relief valve") || strings.Contains(string(data), "Answer from the context") {
+			t.Errorf("cached prefix was resent: %.200s", data)
+		}
+	}
+
+	// A prefix below the model's minimum is sent as usual.
+	small := ChatRequest{Messages: []Message{{Role: "user", Content: "short doc. Q", CachePrefix: 10}}}
+	if _, err := p.Chat(ctx, small); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if len(s.caches) != 1 || s.chats[2]["extra_body"] != nil {
+		t.Error("small prefix should not be cached")
+	}
+}
+
+func TestGeminiCacheFallsBack(t *testing.T) {
+	s := &cacheServer{failChat: true}
+	srv := httptest.NewServer(s.handler(t))
+	defer srv.Close()
+
+	p := NewGemini(Config{Model: "gemini-2.5-flash", BaseURL: srv.URL + "/v1beta/openai", APIKey: "key"})
+	doc := strings.Repeat("x ", 3000)
+	resp, err := p.Chat(context.Background(), ChatRequest{Messages: []Message{
+		{Role: "user", Content: doc + "Q", CachePrefix: len(doc)},
+	}})
+	if err != nil || resp.Content != "ok" {
+		t.Fatalf("Chat = %v, %v; want the uncached retry to succeed", resp, err)
+	}
+	if len(s.chats) != 2 || s.chats[1]["extra_body"] != nil {
+		t.Errorf("expected a cached attempt then a full prompt, got %d requests", len(s.chats))
+	}
+}
+
+func TestGeminiCacheEvictsExpired(t *testing.T) {
+	g := newGeminiCache(nil, "", "")
+	start := time.Now()
+	g.put("a", "cachedContents/a", start)
+	g.put("b", "cachedContents/b", start.Add(geminiCacheTTL/2))
+
+	// Once a has expired, the next entry drops it but keeps b.
+	g.put("c", "cachedContents/c", start.Add(geminiCacheTTL))
+	if _, ok := g.entries["a"]; ok || len(g.entries) != 2 {
+		t.Errorf("entries = %v, want b and c", g.entries)
+	}
+}
@@ -0,0 +1,145 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bbiangul/go-reason/llm/llmtest"
+)
+
+// fastRetries shortens the retry delays for the duration of a test.
+func fastRetries(t *testing.T) {
+	t.Helper()
+	retries, base, rateLimit := maxRetries, baseRetryDelay, minRateLimitDelay
+	maxRetries, baseRetryDelay, minRateLimitDelay = 2, time.Millisecond, time.Millisecond
+	t.Cleanup(func() {
+		maxRetries, baseRetryDelay, minRateLimitDelay = retries, base, rateLimit
+	})
+}
+
+func faultProvider(t *testing.T, rules ...llmtest.Rule) (Provider, *llmtest.Server) {
+	t.Helper()
+	srv := llmtest.NewServer(&llmtest.Scenario{Rules: rules})
+	t.Cleanup(srv.Close)
+	srv.ChatFunc = func(string) string { return "ok" }
+	return NewOpenAICompat(Config{Provider: "custom", Model: "m", BaseURL: srv.URL}), srv
+}
+
+func TestRetriesTransientFaults(t *testing.T) {
+	fastRetries(t)
+	for _, fault := range []string{llmtest.FaultRateLimit, llmtest.FaultTruncated} {
+		t.Run(fault, func(t *testing.T) {
+			p, srv := faultProvider(t, llmtest.Rule{Fault: fault, Times: 2})
+
+			resp, err := p.Chat(context.Background(), ChatRequest{Messages: []Message{{Role: "user", Content: "hi"}}})
+			if err != nil {
+				t.Fatalf("Chat: %v", err)
+			}
+			if resp.Content != "ok" {
+				t.Errorf("content = %q", resp.Content)
+			}
+			if got := srv.Calls(llmtest.OpChat); got != 3 {
+				t.Errorf("calls = %d, want 3 (two faults, then success)", got)
+			}
+		})
+	}
+}
+
+func TestRetriesServerErrors(t *testing.T) {
+	fastRetries(t)
+	p, srv := faultProvider(t, llmtest.Rule{Op: llmtest.OpEmbed, Fault: llmtest.FaultServerError, Status: 503, Times: 1})
+	vecs, err := p.Embed(context.Background(), []string{"a", "b"})
+	if err != nil || len(vecs) != 2 || len(vecs[1]) != 4 {
+		t.Fatalf("Embed = %v, %v", vecs, err)
+	}
+	if srv.Faults(llmtest.FaultServerError) != 1 {
+		t.Errorf("faults = %d, want 1", srv.Faults(llmtest.FaultServerError))
+	}
+
+	// 500 is not retryable.
+	p, srv = faultProvider(t, llmtest.Rule{Fault: llmtest.FaultServerError})
+	if _, err := p.Embed(context.Background(), []string{"a"}); err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("expected a 500 error, got %v", err)
+	}
+	if srv.Calls(llmtest.OpEmbed) != 1 {
+		t.Errorf("calls = %d, want 1", srv.Calls(llmtest.OpEmbed))
+	}
+}
+
+func TestGivesUpAfterMaxRetries(t *testing.T) {
+	fastRetries(t)
+	p, srv := faultProvider(t, llmtest.Rule{Fault: llmtest.FaultRateLimit})
+	_, err := p.Chat(context.Background(), ChatRequest{Messages: []Message{{Role: "user", Content: "hi"}}})
+	if err == nil || !strings.Contains(err.Error(), "max retries exceeded") {
+		t.Fatalf("expected max retries error, got %v", err)
+	}
+	if got := srv.Calls(llmtest.OpChat); got != maxRetries+1 {
+		t.Errorf("calls = %d, want %d", got, maxRetries+1)
+	}
+}
+
+func TestTimeoutHonoursContext(t *testing.T) {
+	fastRetries(t)
+	p, _ := faultProvider(t, llmtest.Rule{Fault: llmtest.FaultTimeout})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := p.Chat(ctx, ChatRequest{Messages: []Message{{Role: "user", Content: "hi"}}})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Error("request was not abandoned at the deadline")
+	}
+}
+
+func TestMalformedJSON(t *testing.T) {
+	fastRetries(t)
+	p, _ := faultProvider(t, llmtest.Rule{Fault: llmtest.FaultMalformedJSON})
+
+	// A broken reply from the model reaches the caller, which owns repair.
+	resp, err := p.Chat(context.Background(), ChatRequest{Messages: []Message{{Role: "user", Content: "hi"}}})
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if !strings.HasPrefix(resp.Content, `{"entities"`) {
+		t.Errorf("content = %q", resp.Content)
+	}
+
+	// A broken envelope is a decoding error.
+	if _, err := p.Embed(context.Background(), []string{"a"}); err == nil || !strings.Contains(err.Error(), "decoding embedding response") {
+		t.Errorf("expected decoding error, got %v", err)
+	}
+}
+
+func TestSkipAndMatch(t *testing.T) {
+	fastRetries(t)
+	p, srv := faultProvider(t, llmtest.Rule{Op: llmtest.OpEmbed, Match: "poison", Skip: 1, Fault: llmtest.FaultServerError, Status: 400})
+	ctx := context.Background()
+	if _, err := p.Embed(ctx, []string{"poison"}); err != nil {
+		t.Fatalf("first matching request should pass: %v", err)
+	}
+	if _, err := p.Embed(ctx, []string{"clean"}); err != nil {
+		t.Fatalf("non-matching request failed: %v", err)
+	}
+	if _, err := p.Embed(ctx, []string{"clean", "poison"}); err == nil {
+		t.Error("expected second matching request to fail")
+	}
+	if srv.Faults(llmtest.FaultServerError) != 1 {
+		t.Errorf("faults = %d, want 1", srv.Faults(llmtest.FaultServerError))
+	}
+}
+
+func TestParseScenario(t *testing.T) {
+	sc, err := llmtest.ParseScenario([]byte(`{"rules": [{"op": "embed", "fault": "rate_limit", "times": 1}]}`))
+	if err != nil || len(sc.Rules) != 1 || sc.Rules[0].Times != 1 {
+		t.Fatalf("ParseScenario = %+v, %v", sc, err)
+	}
+	if _, err := llmtest.ParseScenario([]byte(`{"rules": [{"fault": "explode"}]}`)); err == nil {
+		t.Error("expected unknown fault to fail")
+	}
+}
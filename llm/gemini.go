@@ -17,8 +17,12 @@ import "context"
 //	gemini-embedding-001   (3072 dim, free tier available)
 //
 // API key: set via config or GEMINI_API_KEY env var.
+//
+// Chat requests with a CachePrefix reuse Gemini cached content for the
+// prefix (see cache.go).
 type geminiProvider struct {
-	base openAICompatClient
+	base  openAICompatClient
+	cache *geminiCache
 }
 
 // NewGemini creates a provider for Google Gemini.
@@ -26,11 +30,12 @@ func NewGemini(cfg Config) Provider {
 	if cfg.BaseURL == "" {
 		cfg.BaseURL = "https://generativelanguage.googleapis.com/v1beta/openai"
 	}
-	return &geminiProvider{base: newOpenAICompatClientPrefix(cfg, "")}
+	base := newOpenAICompatClientPrefix(cfg, "")
+	return &geminiProvider{base: base, cache: newGeminiCache(base.client, cfg.BaseURL, cfg.APIKey)}
 }
 
 func (p *geminiProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
-	return p.base.chat(ctx, req)
+	return p.cache.chatCached(ctx, &p.base, req)
 }
 
 func (p *geminiProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
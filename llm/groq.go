@@ -27,6 +27,10 @@ func (p *groqProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse
 	return p.base.chat(ctx, req)
 }
 
+func (p *groqProvider) ChatStream(ctx context.Context, req ChatRequest, onDelta func(string) error) (*ChatResponse, error) {
+	return p.base.chatStream(ctx, req, onDelta)
+}
+
 func (p *groqProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
 	return p.base.embed(ctx, texts)
 }
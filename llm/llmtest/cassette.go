@@ -0,0 +1,251 @@
+package llmtest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Cassette is a recording of LLM requests and the responses they got, for
+// replaying a run (an eval, a test) without the provider: no API keys, no
+// cost, and the same answers every time.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is one recorded request and its response body. Key
+// identifies the request (see requestKey); Request is kept for reading
+// and diffing the cassette.
+type Interaction struct {
+	Op       string          `json:"op"`
+	Key      string          `json:"key"`
+	Request  json.RawMessage `json:"request"`
+	Response json.RawMessage `json:"response"`
+}
+
+// LoadCassette reads a cassette file.
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("parsing cassette %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// Save writes the cassette to path, ordered by key so re-recording an
+// unchanged run leaves the file unchanged.
+func (c *Cassette) Save(path string) error {
+	sort.Slice(c.Interactions, func(i, j int) bool { return c.Interactions[i].Key < c.Interactions[j].Key })
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// CassetteServer replays a cassette as an OpenAI-compatible server, or
+// records one by forwarding requests to a real provider. Point a "custom"
+// provider at URL to replay; to record, point the provider's own type at
+// URL, which stands in for its base URL.
+type CassetteServer struct {
+	// URL is the base URL for llm.Config.BaseURL.
+	URL string
+
+	cassette *Cassette
+	upstream string // recording: the provider's base URL; "" replays
+	client   *http.Client
+	srv      *httptest.Server
+
+	mu     sync.Mutex
+	byKey  map[string]int // index into cassette.Interactions
+	misses []string       // replay: requests without a recording
+}
+
+// NewReplayServer starts a server answering requests from c. A request the
+// cassette has no recording for gets a 404, and is listed by Misses.
+func NewReplayServer(c *Cassette) *CassetteServer {
+	return newCassetteServer(c, "")
+}
+
+// NewRecordingServer starts a server that forwards requests to upstream, a
+// provider base URL such as "https://api.openai.com", and records the
+// successful responses into c. Request headers, including the API key,
+// are forwarded and never recorded.
+func NewRecordingServer(c *Cassette, upstream string) *CassetteServer {
+	return newCassetteServer(c, strings.TrimRight(upstream, "/"))
+}
+
+func newCassetteServer(c *Cassette, upstream string) *CassetteServer {
+	s := &CassetteServer{cassette: c, upstream: upstream, client: &http.Client{}, byKey: map[string]int{}}
+	for i, in := range c.Interactions {
+		s.byKey[in.Key] = i
+	}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.handle))
+	s.URL = s.srv.URL
+	return s
+}
+
+// Close shuts the server down.
+func (s *CassetteServer) Close() {
+	s.srv.Close()
+}
+
+// Misses returns the requests, in the cassette's request form, that
+// replay had no recording for.
+func (s *CassetteServer) Misses() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.misses...)
+}
+
+// cassetteOp returns the operation of a request path, or "" for paths
+// that are not recorded (such as Gemini's context cache).
+func cassetteOp(path string) string {
+	switch {
+	case strings.HasSuffix(path, "/chat/completions"):
+		return OpChat
+	case strings.HasSuffix(path, "/embeddings"), strings.HasSuffix(path, "/api/embed"):
+		return OpEmbed
+	}
+	return ""
+}
+
+// requestKey identifies a request by its operation and body. The body is
+// re-encoded with sorted keys so field order does not matter.
+func requestKey(op string, body []byte) (string, json.RawMessage, error) {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return "", nil, err
+	}
+	canonical, err := json.Marshal(v)
+	if err != nil {
+		return "", nil, err
+	}
+	sum := sha256.Sum256(append([]byte(op+"\n"), canonical...))
+	return hex.EncodeToString(sum[:16]), canonical, nil
+}
+
+func (s *CassetteServer) handle(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	op := cassetteOp(r.URL.Path)
+	if op == "" {
+		if s.upstream == "" {
+			http.Error(w, `{"error":{"message":"not recorded"}}`, http.StatusNotFound)
+			return
+		}
+		s.forward(w, r, body)
+		return
+	}
+	key, canonical, err := requestKey(op, body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	i, ok := s.byKey[key]
+	var resp json.RawMessage
+	if ok {
+		resp = s.cassette.Interactions[i].Response
+	} else if s.upstream == "" {
+		s.misses = append(s.misses, string(canonical))
+	}
+	s.mu.Unlock()
+	if ok {
+		writeBody(w, resp, nil)
+		return
+	}
+	if s.upstream == "" {
+		http.Error(w, fmt.Sprintf(`{"error":{"message":"cassette has no recording for this %s request (key %s)"}}`, op, key), http.StatusNotFound)
+		return
+	}
+
+	status, resp := s.forward(w, r, body)
+	if status != http.StatusOK {
+		return
+	}
+	if op == OpEmbed {
+		resp = openAIEmbeddings(resp)
+	}
+	s.mu.Lock()
+	if _, ok := s.byKey[key]; !ok {
+		s.byKey[key] = len(s.cassette.Interactions)
+		s.cassette.Interactions = append(s.cassette.Interactions, Interaction{Op: op, Key: key, Request: canonical, Response: resp})
+	}
+	s.mu.Unlock()
+}
+
+// forward sends the request to the upstream provider and copies its
+// response to w, returning the status and body.
+func (s *CassetteServer) forward(w http.ResponseWriter, r *http.Request, body []byte) (int, []byte) {
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, s.upstream+r.URL.RequestURI(), bytes.NewReader(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return http.StatusBadGateway, nil
+	}
+	req.Header = r.Header.Clone()
+	resp, err := s.client.Do(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return http.StatusBadGateway, nil
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return http.StatusBadGateway, nil
+	}
+	for k, v := range resp.Header {
+		if k != "Content-Length" {
+			w.Header()[k] = v
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	w.Write(data)
+	return resp.StatusCode, data
+}
+
+// openAIEmbeddings converts an Ollama /api/embed response to the OpenAI
+// embeddings shape, so the recording replays to any provider type.
+func openAIEmbeddings(body []byte) []byte {
+	var native struct {
+		Model      string      `json:"model"`
+		Embeddings [][]float64 `json:"embeddings"`
+	}
+	if json.Unmarshal(body, &native) != nil || native.Embeddings == nil {
+		return body
+	}
+	type item struct {
+		Embedding []float64 `json:"embedding"`
+		Index     int       `json:"index"`
+	}
+	out := struct {
+		Data  []item `json:"data"`
+		Model string `json:"model"`
+	}{Model: native.Model}
+	for i, e := range native.Embeddings {
+		out.Data = append(out.Data, item{Embedding: e, Index: i})
+	}
+	converted, err := json.Marshal(out)
+	if err != nil {
+		return body
+	}
+	return converted
+}
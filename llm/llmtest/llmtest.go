@@ -0,0 +1,449 @@
+// Package llmtest provides a fault-injecting, OpenAI-compatible LLM server
+// for tests. Point a "custom" provider at Server.URL and the real client
+// code (retries, rate-limit handling, response decoding) runs against
+// scripted timeouts, 429s, server errors, malformed JSON, and truncated
+// responses described by a Scenario.
+package llmtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Operations a Rule can target.
+const (
+	OpChat  = "chat"  // /v1/chat/completions
+	OpEmbed = "embed" // /v1/embeddings
+)
+
+// Faults a Rule can inject.
+const (
+	// FaultTimeout holds the request until the client gives up, or for
+	// DelayMs and then answers 504.
+	FaultTimeout = "timeout"
+	// FaultRateLimit answers 429, with Retry-After when RetryAfter is set.
+	FaultRateLimit = "rate_limit"
+	// FaultServerError answers Status (default 500).
+	FaultServerError = "server_error"
+	// FaultMalformedJSON answers 200 with broken JSON: for chat the
+	// envelope is valid and the message content is not (Body, or a
+	// truncated object), for embeddings the envelope itself is broken.
+	FaultMalformedJSON = "malformed_json"
+	// FaultTruncated sends the first half of the normal response and drops
+	// the connection, as a stream cut off mid-body.
+	FaultTruncated = "truncated"
+)
+
+// Scenario is an ordered list of fault rules. For each request the first
+// rule that fires decides the fault; requests no rule fires for get a
+// normal response.
+type Scenario struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Rule injects Fault into requests of Op whose text contains Match.
+type Rule struct {
+	Op    string `json:"op"`              // OpChat, OpEmbed, or "" for both
+	Match string `json:"match,omitempty"` // substring of the prompt or an input text; "" matches all
+	Fault string `json:"fault"`
+
+	Skip  int `json:"skip,omitempty"`  // let this many matching requests through first
+	Times int `json:"times,omitempty"` // fire this many times; 0 = every time
+
+	Status     int    `json:"status,omitempty"`      // FaultServerError status
+	RetryAfter int    `json:"retry_after,omitempty"` // FaultRateLimit Retry-After seconds
+	DelayMs    int    `json:"delay_ms,omitempty"`    // FaultTimeout hold time
+	Body       string `json:"body,omitempty"`        // FaultMalformedJSON content
+}
+
+// LoadScenario reads a JSON scenario file.
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseScenario(data)
+}
+
+// ParseScenario decodes and checks a JSON scenario.
+func ParseScenario(data []byte) (*Scenario, error) {
+	var sc Scenario
+	if err := json.Unmarshal(data, &sc); err != nil {
+		return nil, fmt.Errorf("parsing scenario: %w", err)
+	}
+	for i, r := range sc.Rules {
+		switch r.Op {
+		case "", OpChat, OpEmbed:
+		default:
+			return nil, fmt.Errorf("rule %d: unknown op %q", i, r.Op)
+		}
+		switch r.Fault {
+		case FaultTimeout, FaultRateLimit, FaultServerError, FaultMalformedJSON, FaultTruncated:
+		default:
+			return nil, fmt.Errorf("rule %d: unknown fault %q", i, r.Fault)
+		}
+	}
+	return &sc, nil
+}
+
+// ToolCall is a tool call a ToolFunc replies with.
+type ToolCall struct {
+	Name      string
+	Arguments string // JSON object
+}
+
+// Server is the fault-injecting LLM server.
+type Server struct {
+	// URL is the base URL for llm.Config.BaseURL.
+	URL string
+
+	// ChatFunc produces the reply to a chat request from its messages'
+	// text, joined by newlines. Nil replies "{}".
+	ChatFunc func(prompt string) string
+	// ToolFunc, when set, answers the chat requests that offer tools:
+	// the calls it returns are the reply, and none falls through to
+	// ChatFunc.
+	ToolFunc func(prompt string) []ToolCall
+	// Dim is the dimension of the deterministic embeddings (default 4).
+	Dim int
+
+	srv *httptest.Server
+
+	mu      sync.Mutex
+	rules   []Rule
+	matched []int // per rule, matching requests seen
+	fired   []int // per rule, faults injected
+	calls   map[string]int
+	faults  map[string]int
+}
+
+// NewServer starts a server running sc; nil injects no faults. Close it
+// when done.
+func NewServer(sc *Scenario) *Server {
+	s := &Server{calls: map[string]int{}, faults: map[string]int{}}
+	if sc != nil {
+		s.rules = sc.Rules
+		s.matched = make([]int, len(sc.Rules))
+		s.fired = make([]int, len(sc.Rules))
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/chat/completions", s.handleChat)
+	mux.HandleFunc("/v1/embeddings", s.handleEmbed)
+	s.srv = httptest.NewServer(mux)
+	s.URL = s.srv.URL
+	return s
+}
+
+// Close shuts the server down, releasing held requests.
+func (s *Server) Close() {
+	s.srv.CloseClientConnections()
+	s.srv.Close()
+}
+
+// Calls returns the number of requests received for op.
+func (s *Server) Calls(op string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[op]
+}
+
+// Faults returns the number of times fault was injected.
+func (s *Server) Faults(fault string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.faults[fault]
+}
+
+// pick records a request and returns the rule to apply, or nil.
+func (s *Server) pick(op string, texts []string) *Rule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls[op]++
+	for i := range s.rules {
+		r := &s.rules[i]
+		if r.Op != "" && r.Op != op || !matchesAny(texts, r.Match) {
+			continue
+		}
+		s.matched[i]++
+		if s.matched[i] <= r.Skip || r.Times > 0 && s.fired[i] >= r.Times {
+			continue
+		}
+		s.fired[i]++
+		s.faults[r.Fault]++
+		return r
+	}
+	return nil
+}
+
+func matchesAny(texts []string, sub string) bool {
+	if sub == "" {
+		return true
+	}
+	for _, t := range texts {
+		if strings.Contains(t, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Model    string `json:"model"`
+		Messages []struct {
+			Content json.RawMessage `json:"content"`
+		} `json:"messages"`
+		Stream bool              `json:"stream"`
+		Tools  []json.RawMessage `json:"tools"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	parts := make([]string, len(req.Messages))
+	for i, m := range req.Messages {
+		parts[i] = messageText(m.Content)
+	}
+	prompt := strings.Join(parts, "\n")
+
+	rule := s.pick(OpChat, []string{prompt})
+	if rule != nil && rule.Fault == FaultMalformedJSON {
+		content := rule.Body
+		if content == "" {
+			content = `{"entities": [{"name": "`
+		}
+		writeBody(w, chatResponse(req.Model, content), nil)
+		return
+	}
+	if rule != nil && s.fault(w, r, rule) {
+		return
+	}
+
+	if s.ToolFunc != nil && len(req.Tools) > 0 {
+		if calls := s.ToolFunc(prompt); len(calls) > 0 {
+			writeBody(w, toolCallResponse(req.Model, calls), rule)
+			return
+		}
+	}
+	reply := "{}"
+	if s.ChatFunc != nil {
+		reply = s.ChatFunc(prompt)
+	}
+	if req.Stream && rule == nil {
+		streamReply(w, r, req.Model, reply)
+		return
+	}
+	writeBody(w, chatResponse(req.Model, reply), rule)
+}
+
+// streamReply sends reply as server-sent events, one word per event,
+// until the client hangs up.
+func streamReply(w http.ResponseWriter, r *http.Request, model, reply string) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	flusher, _ := w.(http.Flusher)
+	send := func(v interface{}) bool {
+		data, _ := json.Marshal(v)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return r.Context().Err() == nil
+	}
+	for _, word := range strings.SplitAfter(reply, " ") {
+		if !send(map[string]interface{}{
+			"model":   model,
+			"choices": []map[string]interface{}{{"delta": map[string]string{"content": word}}},
+		}) {
+			return
+		}
+	}
+	send(map[string]interface{}{
+		"model":   model,
+		"choices": []map[string]interface{}{{"delta": map[string]string{}, "finish_reason": "stop"}},
+	})
+	send(map[string]interface{}{
+		"model":   model,
+		"choices": []interface{}{},
+		"usage":   map[string]int{"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15},
+	})
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+func (s *Server) handleEmbed(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Model string   `json:"model"`
+		Input []string `json:"input"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rule := s.pick(OpEmbed, req.Input)
+	if rule != nil && rule.Fault == FaultMalformedJSON {
+		body := rule.Body
+		if body == "" {
+			body = `{"data": [{"embedding": [0.1, `
+		}
+		writeBody(w, []byte(body), nil)
+		return
+	}
+	if rule != nil && s.fault(w, r, rule) {
+		return
+	}
+
+	type item struct {
+		Embedding []float32 `json:"embedding"`
+		Index     int       `json:"index"`
+	}
+	resp := struct {
+		Data  []item `json:"data"`
+		Model string `json:"model"`
+	}{Model: req.Model}
+	for i, text := range req.Input {
+		resp.Data = append(resp.Data, item{Embedding: s.embedding(text), Index: i})
+	}
+	body, _ := json.Marshal(resp)
+	writeBody(w, body, rule)
+}
+
+// fault writes the error faults and reports whether the response is done.
+// Truncation is applied by writeBody.
+func (s *Server) fault(w http.ResponseWriter, r *http.Request, rule *Rule) bool {
+	switch rule.Fault {
+	case FaultTimeout:
+		var after <-chan time.Time
+		if rule.DelayMs > 0 {
+			after = time.After(time.Duration(rule.DelayMs) * time.Millisecond)
+		}
+		select {
+		case <-r.Context().Done():
+		case <-after:
+			http.Error(w, "upstream timeout", http.StatusGatewayTimeout)
+		}
+		return true
+	case FaultRateLimit:
+		if rule.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(rule.RetryAfter))
+		}
+		http.Error(w, `{"error":{"message":"rate limit exceeded"}}`, http.StatusTooManyRequests)
+		return true
+	case FaultServerError:
+		status := rule.Status
+		if status == 0 {
+			status = http.StatusInternalServerError
+		}
+		http.Error(w, `{"error":{"message":"injected server error"}}`, status)
+		return true
+	}
+	return false
+}
+
+// writeBody writes a 200 JSON response, cut in half when rule truncates.
+func writeBody(w http.ResponseWriter, body []byte, rule *Rule) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	if rule != nil && rule.Fault == FaultTruncated {
+		// Writing less than the declared length makes the server close the
+		// connection, so the client sees an unexpected EOF.
+		body = body[:len(body)/2]
+	}
+	w.Write(body)
+}
+
+func chatResponse(model, content string) []byte {
+	resp := map[string]interface{}{
+		"model": model,
+		"choices": []map[string]interface{}{{
+			"message":       map[string]string{"role": "assistant", "content": content},
+			"finish_reason": "stop",
+		}},
+		"usage": map[string]int{
+			"prompt_tokens":     10,
+			"completion_tokens": 5,
+			"total_tokens":      15,
+		},
+	}
+	body, _ := json.Marshal(resp)
+	return body
+}
+
+func toolCallResponse(model string, calls []ToolCall) []byte {
+	wire := make([]map[string]interface{}, len(calls))
+	for i, c := range calls {
+		wire[i] = map[string]interface{}{
+			"id":       fmt.Sprintf("call_%d", i+1),
+			"type":     "function",
+			"function": map[string]string{"name": c.Name, "arguments": c.Arguments},
+		}
+	}
+	resp := map[string]interface{}{
+		"model": model,
+		"choices": []map[string]interface{}{{
+			"message":       map[string]interface{}{"role": "assistant", "content": "", "tool_calls": wire},
+			"finish_reason": "tool_calls",
+		}},
+		"usage": map[string]int{
+			"prompt_tokens":     10,
+			"completion_tokens": 5,
+			"total_tokens":      15,
+		},
+	}
+	body, _ := json.Marshal(resp)
+	return body
+}
+
+// messageText returns the text of a message content, which is a string or
+// an array of typed parts.
+func messageText(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if json.Unmarshal(raw, &parts) != nil {
+		return ""
+	}
+	var texts []string
+	for _, p := range parts {
+		if p.Type == "text" {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// embedding derives a unit vector from text, so equal texts get equal
+// vectors and searches are repeatable.
+func (s *Server) embedding(text string) []float32 {
+	dim := s.Dim
+	if dim <= 0 {
+		dim = 4
+	}
+	v := make([]float32, dim)
+	var norm float64
+	for i := range v {
+		h := fnv.New32a()
+		fmt.Fprintf(h, "%d:%s", i, text)
+		x := float64(h.Sum32())/math.MaxUint32*2 - 1
+		v[i] = float32(x)
+		norm += x * x
+	}
+	norm = math.Sqrt(norm)
+	for i := range v {
+		v[i] = float32(float64(v[i]) / norm)
+	}
+	return v
+}
@@ -20,6 +20,10 @@ func (p *lmStudioProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResp
 	return p.base.chat(ctx, req)
 }
 
+func (p *lmStudioProvider) ChatStream(ctx context.Context, req ChatRequest, onDelta func(string) error) (*ChatResponse, error) {
+	return p.base.chatStream(ctx, req, onDelta)
+}
+
 func (p *lmStudioProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
 	return p.base.embed(ctx, texts)
 }
@@ -0,0 +1,288 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Operations a Meter labels provider calls with. Chat calls are
+// OperationChat unless their context says otherwise (WithOperation).
+const (
+	OperationChat   = "chat"
+	OperationEmbed  = "embed"
+	OperationVision = "vision"
+	OperationJudge  = "judge"
+)
+
+// Phases a Meter labels provider calls with (see WithPhase). Calls made
+// outside an ingest or a query, such as community summaries, are
+// PhaseOther.
+const (
+	PhaseIngest = "ingest"
+	PhaseQuery  = "query"
+	PhaseOther  = "other"
+)
+
+type meterContextKey int
+
+const (
+	phaseKey meterContextKey = iota
+	operationKey
+)
+
+// WithPhase marks the provider calls made with ctx as part of phase, such
+// as PhaseIngest or PhaseQuery.
+func WithPhase(ctx context.Context, phase string) context.Context {
+	return context.WithValue(ctx, phaseKey, phase)
+}
+
+// WithOperation labels the chat calls made with ctx as operation instead
+// of OperationChat, e.g. OperationJudge for LLM-as-judge scoring.
+func WithOperation(ctx context.Context, operation string) context.Context {
+	return context.WithValue(ctx, operationKey, operation)
+}
+
+// MeterKey identifies one series of metered calls.
+type MeterKey struct {
+	Provider  string `json:"provider"`
+	Model     string `json:"model"`
+	Operation string `json:"operation"`
+	Phase     string `json:"phase"`
+}
+
+// MeterStats accumulates the calls of a series.
+type MeterStats struct {
+	Calls            int64   `json:"calls"`
+	Errors           int64   `json:"errors"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	CachedTokens     int64   `json:"cached_tokens"`
+	Inputs           int64   `json:"inputs"`  // texts and images embedded
+	Seconds          float64 `json:"seconds"` // total call duration
+}
+
+// MeterRecord is one series of a Meter snapshot.
+type MeterRecord struct {
+	MeterKey
+	MeterStats
+}
+
+// Meter counts provider calls, errors, tokens, and time by provider,
+// model, operation, and phase, for usage and cost dashboards. Providers
+// record into it when wrapped with Metered. It is safe for concurrent use.
+type Meter struct {
+	mu     sync.Mutex
+	series map[MeterKey]*MeterStats
+}
+
+// NewMeter returns an empty Meter.
+func NewMeter() *Meter {
+	return &Meter{series: make(map[MeterKey]*MeterStats)}
+}
+
+// Snapshot returns every series recorded so far, ordered by key.
+func (m *Meter) Snapshot() []MeterRecord {
+	m.mu.Lock()
+	out := make([]MeterRecord, 0, len(m.series))
+	for k, s := range m.series {
+		out = append(out, MeterRecord{MeterKey: k, MeterStats: *s})
+	}
+	m.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i].MeterKey, out[j].MeterKey
+		if a.Provider != b.Provider {
+			return a.Provider < b.Provider
+		}
+		if a.Model != b.Model {
+			return a.Model < b.Model
+		}
+		if a.Operation != b.Operation {
+			return a.Operation < b.Operation
+		}
+		return a.Phase < b.Phase
+	})
+	return out
+}
+
+func (m *Meter) record(key MeterKey, resp *ChatResponse, inputs int, d time.Duration, failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.series[key]
+	if s == nil {
+		s = &MeterStats{}
+		m.series[key] = s
+	}
+	s.Calls++
+	if failed {
+		s.Errors++
+	}
+	if resp != nil {
+		s.PromptTokens += int64(resp.PromptTokens)
+		s.CompletionTokens += int64(resp.CompletionTokens)
+		s.CachedTokens += int64(resp.CachedTokens)
+	}
+	s.Inputs += int64(inputs)
+	s.Seconds += d.Seconds()
+}
+
+// meter records the calls of one provider.
+type meter struct {
+	m               *Meter
+	provider, model string
+}
+
+func (mt meter) key(ctx context.Context, operation string) MeterKey {
+	if operation == OperationChat {
+		if op, _ := ctx.Value(operationKey).(string); op != "" {
+			operation = op
+		}
+	}
+	phase, _ := ctx.Value(phaseKey).(string)
+	if phase == "" {
+		phase = PhaseOther
+	}
+	return MeterKey{Provider: mt.provider, Model: mt.model, Operation: operation, Phase: phase}
+}
+
+func (mt meter) chat(ctx context.Context, operation string, start time.Time, resp *ChatResponse, err error) {
+	mt.m.record(mt.key(ctx, operation), resp, 0, time.Since(start), err != nil)
+}
+
+func (mt meter) embed(ctx context.Context, inputs int, start time.Time, err error) {
+	mt.m.record(mt.key(ctx, OperationEmbed), nil, inputs, time.Since(start), err != nil)
+}
+
+// Metered wraps p so that its calls are recorded in m under the given
+// provider and model names. The wrapper is a VisionProvider or a
+// StreamingProvider when p is, and closes p when p is an io.Closer.
+func Metered(p Provider, provider, model string, m *Meter) Provider {
+	mp := &meteredProvider{p: p, meter: meter{m: m, provider: provider, model: model}}
+	_, vision := p.(VisionProvider)
+	_, stream := p.(StreamingProvider)
+	switch {
+	case vision && stream:
+		return meteredVisionStream{mp}
+	case vision:
+		return meteredVision{mp}
+	case stream:
+		return meteredStream{mp}
+	}
+	return mp
+}
+
+type meteredProvider struct {
+	p     Provider
+	meter meter
+}
+
+func (mp *meteredProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	start := time.Now()
+	resp, err := mp.p.Chat(ctx, req)
+	mp.meter.chat(ctx, OperationChat, start, resp, err)
+	return resp, err
+}
+
+func (mp *meteredProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	start := time.Now()
+	out, err := mp.p.Embed(ctx, texts)
+	mp.meter.embed(ctx, len(texts), start, err)
+	return out, err
+}
+
+func (mp *meteredProvider) Close() error {
+	if c, ok := mp.p.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (mp *meteredProvider) chatWithImages(ctx context.Context, req VisionChatRequest) (*ChatResponse, error) {
+	start := time.Now()
+	resp, err := mp.p.(VisionProvider).ChatWithImages(ctx, req)
+	mp.meter.chat(ctx, OperationVision, start, resp, err)
+	return resp, err
+}
+
+// chatStream records a stream stopped by onDelta as a call, not an error.
+func (mp *meteredProvider) chatStream(ctx context.Context, req ChatRequest, onDelta func(string) error) (*ChatResponse, error) {
+	start := time.Now()
+	var stopped error
+	resp, err := mp.p.(StreamingProvider).ChatStream(ctx, req, func(delta string) error {
+		stopped = onDelta(delta)
+		return stopped
+	})
+	if stopped != nil && errors.Is(err, stopped) {
+		mp.meter.chat(ctx, OperationChat, start, resp, nil)
+	} else {
+		mp.meter.chat(ctx, OperationChat, start, resp, err)
+	}
+	return resp, err
+}
+
+type meteredVision struct{ *meteredProvider }
+
+func (mv meteredVision) ChatWithImages(ctx context.Context, req VisionChatRequest) (*ChatResponse, error) {
+	return mv.chatWithImages(ctx, req)
+}
+
+type meteredStream struct{ *meteredProvider }
+
+func (ms meteredStream) ChatStream(ctx context.Context, req ChatRequest, onDelta func(string) error) (*ChatResponse, error) {
+	return ms.chatStream(ctx, req, onDelta)
+}
+
+type meteredVisionStream struct{ *meteredProvider }
+
+func (mvs meteredVisionStream) ChatWithImages(ctx context.Context, req VisionChatRequest) (*ChatResponse, error) {
+	return mvs.chatWithImages(ctx, req)
+}
+
+func (mvs meteredVisionStream) ChatStream(ctx context.Context, req ChatRequest, onDelta func(string) error) (*ChatResponse, error) {
+	return mvs.chatStream(ctx, req, onDelta)
+}
+
+// MeteredSparse wraps a sparse embedder like Metered.
+func MeteredSparse(p SparseEmbedder, provider, model string, m *Meter) SparseEmbedder {
+	return meteredSparse{p: p, meter: meter{m: m, provider: provider, model: model}}
+}
+
+type meteredSparse struct {
+	p     SparseEmbedder
+	meter meter
+}
+
+func (ms meteredSparse) EmbedSparse(ctx context.Context, texts []string) ([]SparseVector, error) {
+	start := time.Now()
+	out, err := ms.p.EmbedSparse(ctx, texts)
+	ms.meter.embed(ctx, len(texts), start, err)
+	return out, err
+}
+
+// MeteredMultimodal wraps a multimodal embedder like Metered. Images and
+// texts are both OperationEmbed inputs.
+func MeteredMultimodal(p MultimodalEmbedder, provider, model string, m *Meter) MultimodalEmbedder {
+	return meteredMultimodal{p: p, meter: meter{m: m, provider: provider, model: model}}
+}
+
+type meteredMultimodal struct {
+	p     MultimodalEmbedder
+	meter meter
+}
+
+func (mm meteredMultimodal) EmbedImages(ctx context.Context, images []ImageInput) ([][]float32, error) {
+	start := time.Now()
+	out, err := mm.p.EmbedImages(ctx, images)
+	mm.meter.embed(ctx, len(images), start, err)
+	return out, err
+}
+
+func (mm meteredMultimodal) EmbedTexts(ctx context.Context, texts []string) ([][]float32, error) {
+	start := time.Now()
+	out, err := mm.p.EmbedTexts(ctx, texts)
+	mm.meter.embed(ctx, len(texts), start, err)
+	return out, err
+}
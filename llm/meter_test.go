@@ -0,0 +1,72 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bbiangul/go-reason/llm/llmtest"
+)
+
+func TestMetered(t *testing.T) {
+	fastRetries(t)
+	p, _ := faultProvider(t, llmtest.Rule{Fault: llmtest.FaultServerError, Status: 400, Match: "broken"})
+	m := NewMeter()
+	mp := Metered(p, "custom", "m", m)
+	if _, ok := mp.(StreamingProvider); !ok {
+		t.Fatal("metered provider does not stream")
+	}
+	_, vision := p.(VisionProvider)
+	if _, ok := mp.(VisionProvider); ok != vision {
+		t.Errorf("metered provider is a VisionProvider: %v, wrapped one: %v", ok, vision)
+	}
+
+	ctx := context.Background()
+	query := WithPhase(ctx, PhaseQuery)
+	chat := func(ctx context.Context, prompt string) error {
+		_, err := mp.Chat(ctx, ChatRequest{Messages: []Message{{Role: "user", Content: prompt}}})
+		return err
+	}
+	if err := chat(query, "hi"); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if err := chat(query, "broken"); err == nil {
+		t.Fatal("Chat of a broken prompt succeeded")
+	}
+	if err := chat(WithOperation(query, OperationJudge), "hi"); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if _, err := mp.Embed(WithPhase(ctx, PhaseIngest), []string{"a", "b", "c"}); err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	// A stream stopped by its caller is a call, not an error.
+	stop := errors.New("enough")
+	_, err := mp.(StreamingProvider).ChatStream(ctx, ChatRequest{Messages: []Message{{Role: "user", Content: "hi"}}},
+		func(string) error { return stop })
+	if !errors.Is(err, stop) {
+		t.Fatalf("ChatStream = %v, want the onDelta error", err)
+	}
+
+	got := make(map[MeterKey]MeterStats)
+	for _, r := range m.Snapshot() {
+		got[r.MeterKey] = r.MeterStats
+	}
+	key := func(op, phase string) MeterKey {
+		return MeterKey{Provider: "custom", Model: "m", Operation: op, Phase: phase}
+	}
+	if len(got) != 4 {
+		t.Errorf("got %d series, want 4: %+v", len(got), got)
+	}
+	if s := got[key(OperationChat, PhaseQuery)]; s.Calls != 2 || s.Errors != 1 || s.PromptTokens == 0 || s.Seconds <= 0 {
+		t.Errorf("query chat = %+v, want 2 calls with 1 error", s)
+	}
+	if s := got[key(OperationJudge, PhaseQuery)]; s.Calls != 1 || s.Errors != 0 {
+		t.Errorf("query judge = %+v, want 1 call", s)
+	}
+	if s := got[key(OperationEmbed, PhaseIngest)]; s.Calls != 1 || s.Inputs != 3 {
+		t.Errorf("ingest embed = %+v, want 1 call of 3 inputs", s)
+	}
+	if s := got[key(OperationChat, PhaseOther)]; s.Calls != 1 || s.Errors != 0 {
+		t.Errorf("stopped stream = %+v, want 1 call without errors", s)
+	}
+}
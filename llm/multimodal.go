@@ -0,0 +1,216 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // decoders for fitImage
+	"image/jpeg"
+	"image/png"
+)
+
+// Image limits applied before an image is sent to a multimodal provider.
+const (
+	// defaultMaxImageSide is the longest side, in pixels, the providers
+	// accept; larger images are scaled down to it. CLIP-style models work
+	// at a few hundred pixels, so nothing they see is lost.
+	defaultMaxImageSide = 2048
+	// maxDecodePixels caps the images decoded for scaling, so a small file
+	// declaring huge dimensions is rejected rather than allocated.
+	maxDecodePixels = 50_000_000
+)
+
+// ImageInput is a raw image to embed.
+type ImageInput struct {
+	Data     []byte
+	MIMEType string
+}
+
+// MultimodalEmbedder embeds images and text into a shared vector space
+// (CLIP-style), so a text query can be compared directly against image
+// embeddings.
+type MultimodalEmbedder interface {
+	// EmbedImages generates one embedding per image.
+	EmbedImages(ctx context.Context, images []ImageInput) ([][]float32, error)
+
+	// EmbedTexts generates embeddings for texts in the same space as images.
+	EmbedTexts(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// NewMultimodalEmbedder creates a multimodal embedding provider from
+// configuration.
+//
+// Supported providers:
+//
+//	jina    — Jina AI embeddings API (jina-clip-v2 and similar)
+//	custom  — any server exposing the same /v1/embeddings request shape
+//	          with {"image": ...} / {"text": ...} input objects
+func NewMultimodalEmbedder(cfg Config) (MultimodalEmbedder, error) {
+	switch cfg.Provider {
+	case "jina":
+		if cfg.BaseURL == "" {
+			cfg.BaseURL = "https://api.jina.ai"
+		}
+		if cfg.Model == "" {
+			cfg.Model = "jina-clip-v2"
+		}
+		return &multimodalProvider{base: newOpenAICompatClient(cfg), maxSide: defaultMaxImageSide}, nil
+	case "custom":
+		if cfg.BaseURL == "" {
+			return nil, fmt.Errorf("multimodal embedding provider %q requires base_url", cfg.Provider)
+		}
+		return &multimodalProvider{base: newOpenAICompatClient(cfg), maxSide: defaultMaxImageSide}, nil
+	case "":
+		return nil, fmt.Errorf("multimodal embedding provider not specified")
+	default:
+		return nil, fmt.Errorf("unknown multimodal embedding provider: %s", cfg.Provider)
+	}
+}
+
+// multimodalProvider calls an OpenAI-style /embeddings endpoint whose input
+// items are objects tagged with their modality: {"image": "data:..."} or
+// {"text": "..."}. The response uses the standard {"data": [...]} shape.
+type multimodalProvider struct {
+	base    openAICompatClient
+	maxSide int // longest image side the provider accepts
+}
+
+type multimodalInput struct {
+	Image string `json:"image,omitempty"`
+	Text  string `json:"text,omitempty"`
+}
+
+type multimodalEmbeddingRequest struct {
+	Model string            `json:"model"`
+	Input []multimodalInput `json:"input"`
+}
+
+func (p *multimodalProvider) EmbedImages(ctx context.Context, images []ImageInput) ([][]float32, error) {
+	inputs := make([]multimodalInput, len(images))
+	for i, img := range images {
+		img, err := fitImage(img, p.maxSide)
+		if err != nil {
+			return nil, fmt.Errorf("image %d: %w", i, err)
+		}
+		mime := img.MIMEType
+		if mime == "" {
+			mime = "image/png"
+		}
+		inputs[i] = multimodalInput{
+			Image: "data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(img.Data),
+		}
+	}
+	return p.embed(ctx, inputs)
+}
+
+func (p *multimodalProvider) EmbedTexts(ctx context.Context, texts []string) ([][]float32, error) {
+	inputs := make([]multimodalInput, len(texts))
+	for i, t := range texts {
+		inputs[i] = multimodalInput{Text: t}
+	}
+	return p.embed(ctx, inputs)
+}
+
+func (p *multimodalProvider) embed(ctx context.Context, inputs []multimodalInput) ([][]float32, error) {
+	respBody, err := p.base.doPost(ctx, p.base.pathPrefix+"/embeddings", multimodalEmbeddingRequest{
+		Model: p.base.cfg.Model,
+		Input: inputs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var resp embeddingResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return nil, fmt.Errorf("decoding multimodal embedding response: %w", err)
+	}
+
+	embeddings := make([][]float32, len(inputs))
+	for _, d := range resp.Data {
+		if d.Index < len(embeddings) {
+			embeddings[d.Index] = d.Embedding
+		}
+	}
+	for i, e := range embeddings {
+		if e == nil {
+			return nil, fmt.Errorf("multimodal embedding response missing vector %d", i)
+		}
+	}
+	return embeddings, nil
+}
+
+// fitImage scales img down so neither side exceeds maxSide pixels. Images
+// within the limit, and images in a format this package cannot decode, are
+// returned unchanged for the provider to judge. An image too large to
+// decode safely is rejected.
+func fitImage(img ImageInput, maxSide int) (ImageInput, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(img.Data))
+	if err != nil || maxSide <= 0 || (cfg.Width <= maxSide && cfg.Height <= maxSide) {
+		return img, nil
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > maxDecodePixels {
+		return ImageInput{}, fmt.Errorf("image of %dx%d pixels is too large to scale down", cfg.Width, cfg.Height)
+	}
+	src, _, err := image.Decode(bytes.NewReader(img.Data))
+	if err != nil {
+		return ImageInput{}, fmt.Errorf("decoding %s image: %w", format, err)
+	}
+
+	w, h := cfg.Width, cfg.Height
+	if w >= h {
+		w, h = maxSide, max(1, h*maxSide/w)
+	} else {
+		w, h = max(1, w*maxSide/h), maxSide
+	}
+	dst := scaleDown(src, w, h)
+
+	var buf bytes.Buffer
+	out := ImageInput{MIMEType: "image/png"}
+	if format == "jpeg" {
+		out.MIMEType = "image/jpeg"
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 90})
+	} else {
+		err = png.Encode(&buf, dst)
+	}
+	if err != nil {
+		return ImageInput{}, fmt.Errorf("encoding scaled image: %w", err)
+	}
+	out.Data = buf.Bytes()
+	return out, nil
+}
+
+// scaleDown resizes src to w×h by averaging the source pixels that fall
+// into each destination pixel.
+func scaleDown(src image.Image, w, h int) *image.NRGBA {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0, y1 := b.Min.Y+y*sh/h, b.Min.Y+(y+1)*sh/h
+		for x := 0; x < w; x++ {
+			x0, x1 := b.Min.X+x*sw/w, b.Min.X+(x+1)*sw/w
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := color.NRGBA64Model.Convert(src.At(sx, sy)).(color.NRGBA64)
+					r += uint64(c.R)
+					g += uint64(c.G)
+					bl += uint64(c.B)
+					a += uint64(c.A)
+					n++
+				}
+			}
+			if n == 0 {
+				continue
+			}
+			dst.SetNRGBA(x, y, color.NRGBA{
+				R: uint8(r / n >> 8), G: uint8(g / n >> 8), B: uint8(bl / n >> 8), A: uint8(a / n >> 8),
+			})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,111 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewMultimodalEmbedder(t *testing.T) {
+	if _, err := NewMultimodalEmbedder(Config{}); err == nil {
+		t.Error("expected error for empty provider")
+	}
+	if _, err := NewMultimodalEmbedder(Config{Provider: "custom"}); err == nil {
+		t.Error("expected error for custom provider without base_url")
+	}
+	if _, err := NewMultimodalEmbedder(Config{Provider: "jina"}); err != nil {
+		t.Errorf("NewMultimodalEmbedder(jina): %v", err)
+	}
+}
+
+func TestMultimodalEmbed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/embeddings" {
+			t.Errorf("path = %q, want /v1/embeddings", r.URL.Path)
+		}
+		var req multimodalEmbeddingRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("decoding request: %v", err)
+		}
+		if req.Model != "clip" {
+			t.Errorf("model = %q, want clip", req.Model)
+		}
+		for _, in := range req.Input {
+			if in.Image != "" && !strings.HasPrefix(in.Image, "data:image/jpeg;base64,") {
+				t.Errorf("image input = %q, want data URI", in.Image)
+			}
+		}
+		// Out-of-order indices must be placed correctly.
+		w.Write([]byte(`{"data":[{"index":1,"embedding":[0,1]},{"index":0,"embedding":[1,0]}]}`))
+	}))
+	defer srv.Close()
+
+	mm, err := NewMultimodalEmbedder(Config{Provider: "custom", BaseURL: srv.URL, Model: "clip"})
+	if err != nil {
+		t.Fatalf("NewMultimodalEmbedder: %v", err)
+	}
+
+	vecs, err := mm.EmbedImages(context.Background(), []ImageInput{
+		{Data: []byte{1, 2}, MIMEType: "image/jpeg"},
+		{Data: []byte{3}, MIMEType: "image/jpeg"},
+	})
+	if err != nil {
+		t.Fatalf("EmbedImages: %v", err)
+	}
+	if len(vecs) != 2 || vecs[0][0] != 1 || vecs[1][1] != 1 {
+		t.Errorf("unexpected vectors: %v", vecs)
+	}
+
+	if _, err := mm.EmbedTexts(context.Background(), []string{"wiring diagram"}); err != nil {
+		t.Fatalf("EmbedTexts: %v", err)
+	}
+}
+
+func TestFitImage(t *testing.T) {
+	encode := func(w, h int) []byte {
+		img := image.NewNRGBA(image.Rect(0, 0, w, h))
+		for i := range img.Pix {
+			img.Pix[i] = 0xff
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	small := ImageInput{Data: encode(40, 20), MIMEType: "image/png"}
+	if got, err := fitImage(small, 64); err != nil || !bytes.Equal(got.Data, small.Data) {
+		t.Errorf("image within the limit changed: %v", err)
+	}
+	if got, err := fitImage(ImageInput{Data: []byte{1, 2}}, 64); err != nil || len(got.Data) != 2 {
+		t.Errorf("undecodable image = %v, %v; want it passed through", got, err)
+	}
+
+	got, err := fitImage(ImageInput{Data: encode(300, 100), MIMEType: "image/png"}, 64)
+	if err != nil {
+		t.Fatalf("fitImage: %v", err)
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(got.Data))
+	if err != nil || cfg.Width != 64 || cfg.Height != 21 || got.MIMEType != "image/png" {
+		t.Errorf("scaled image = %dx%d %s (%v), want 64x21 png", cfg.Width, cfg.Height, got.MIMEType, err)
+	}
+
+	// A header declaring far more pixels than the file holds is rejected
+	// without decoding.
+	bomb := encode(1, 1)
+	binary.BigEndian.PutUint32(bomb[16:], 100000)
+	binary.BigEndian.PutUint32(bomb[20:], 100000)
+	binary.BigEndian.PutUint32(bomb[29:], crc32.ChecksumIEEE(bomb[12:29]))
+	if _, err := fitImage(ImageInput{Data: bomb}, 64); err == nil {
+		t.Error("oversized image header was accepted")
+	}
+}
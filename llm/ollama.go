@@ -29,6 +29,10 @@ func (p *ollamaProvider) Chat(ctx context.Context, req ChatRequest) (*ChatRespon
 	return p.base.chat(ctx, req)
 }
 
+func (p *ollamaProvider) ChatStream(ctx context.Context, req ChatRequest, onDelta func(string) error) (*ChatResponse, error) {
+	return p.base.chatStream(ctx, req, onDelta)
+}
+
 func (p *ollamaProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
 	// Use Ollama's native /api/embed endpoint for batched embeddings
 	body := ollamaEmbedRequest{
@@ -47,6 +51,7 @@ func (p *ollamaProvider) Embed(ctx context.Context, texts []string) ([][]float32
 		return nil, err
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
+	setRequestID(httpReq)
 
 	resp, err := p.base.client.Do(httpReq)
 	if err != nil {
@@ -0,0 +1,243 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// onnxProvider runs a sentence embedding model in process with ONNX
+// Runtime, so offline deployments need no embedding server. It produces
+// embeddings only.
+type onnxProvider struct {
+	tok     *hfTokenizer
+	sess    onnxSession
+	cls     bool // CLS pooling (bge); mean pooling otherwise (e5, MiniLM)
+	modelID string
+	// prompts are prepended to the texts embedded: query to those embedded
+	// while answering a query (PhaseQuery), passage to the rest.
+	prompts onnxPrompts
+}
+
+// onnxPrompts are the instruction prefixes of an embedding model, such as
+// e5's "query: " and "passage: ".
+type onnxPrompts struct {
+	query, passage string
+}
+
+// onnxSession runs an encoder model over a padded batch of token ids.
+// Its implementation needs ONNX Runtime and is built with the onnx tag
+// (see onnx_runtime.go).
+type onnxSession interface {
+	// run returns the last hidden state, flattened as [batch][seq][dim].
+	run(ids, mask, typeIDs []int64, batch, seq int) (hidden []float32, dim int, err error)
+	// maxBatch is the most texts run accepts at once.
+	maxBatch() int
+	close() error
+}
+
+// NewONNX creates a provider for a local ONNX embedding model. cfg.Model
+// is the model directory, laid out like the HuggingFace repositories of
+// bge-small or multilingual-e5: model.onnx (or onnx/model.onnx),
+// tokenizer.json, and optionally the sentence-transformers pooling config
+// 1_Pooling/config.json and prompts config_sentence_transformers.json.
+// Models named e5 without a prompts config get e5's "query: " and
+// "passage: " prefixes. cfg.ONNXLibrary is the path of the ONNX Runtime
+// shared library; when empty the system's default is loaded.
+func NewONNX(cfg Config) (Provider, error) {
+	if cfg.Model == "" {
+		return nil, fmt.Errorf("onnx provider needs the model directory as model")
+	}
+	modelPath := filepath.Join(cfg.Model, "model.onnx")
+	if _, err := os.Stat(modelPath); err != nil {
+		modelPath = filepath.Join(cfg.Model, "onnx", "model.onnx")
+		if _, err := os.Stat(modelPath); err != nil {
+			return nil, fmt.Errorf("no model.onnx in %s", cfg.Model)
+		}
+	}
+	tok, err := loadTokenizer(filepath.Join(cfg.Model, "tokenizer.json"))
+	if err != nil {
+		return nil, err
+	}
+	cls, err := readPoolingMode(filepath.Join(cfg.Model, "1_Pooling", "config.json"))
+	if err != nil {
+		return nil, err
+	}
+	prompts, err := readPrompts(cfg.Model)
+	if err != nil {
+		return nil, err
+	}
+	sess, err := openONNXSession(modelPath, cfg.ONNXLibrary)
+	if err != nil {
+		return nil, err
+	}
+	return &onnxProvider{tok: tok, sess: sess, cls: cls, modelID: filepath.Base(cfg.Model), prompts: prompts}, nil
+}
+
+// readPrompts reads the query and passage prompts of the model in dir
+// from its sentence-transformers config. Without one, e5 models (named
+// so) get "query: " and "passage: ", which they were trained with; other
+// models get none.
+func readPrompts(dir string) (onnxPrompts, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "config_sentence_transformers.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		if strings.Contains(strings.ToLower(filepath.Base(dir)), "e5") {
+			return onnxPrompts{query: "query: ", passage: "passage: "}, nil
+		}
+		return onnxPrompts{}, nil
+	}
+	if err != nil {
+		return onnxPrompts{}, fmt.Errorf("reading sentence-transformers config: %w", err)
+	}
+	var cfg struct {
+		Prompts map[string]string `json:"prompts"`
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return onnxPrompts{}, fmt.Errorf("decoding sentence-transformers config: %w", err)
+	}
+	p := onnxPrompts{query: cfg.Prompts["query"], passage: cfg.Prompts["passage"]}
+	if p.passage == "" {
+		p.passage = cfg.Prompts["document"]
+	}
+	return p, nil
+}
+
+// readPoolingMode reports whether a sentence-transformers pooling config
+// selects CLS pooling. Without a config, mean pooling is used.
+func readPoolingMode(path string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("reading pooling config: %w", err)
+	}
+	var cfg struct {
+		CLS  bool `json:"pooling_mode_cls_token"`
+		Mean bool `json:"pooling_mode_mean_tokens"`
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return false, fmt.Errorf("decoding pooling config: %w", err)
+	}
+	return cfg.CLS && !cfg.Mean, nil
+}
+
+func (p *onnxProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	return nil, fmt.Errorf("onnx provider supports embeddings only")
+}
+
+// Embed runs the texts through the model in batches of at most the
+// session's maxBatch, each padded to its longest text, and returns
+// L2-normalized pooled embeddings. The model's query prompt is prepended
+// to texts embedded for a query (see WithPhase), its passage prompt to
+// the rest.
+func (p *onnxProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	prompt := p.prompts.passage
+	if phase, _ := ctx.Value(phaseKey).(string); phase == PhaseQuery {
+		prompt = p.prompts.query
+	}
+	size := p.sess.maxBatch()
+	if size <= 0 {
+		size = len(texts)
+	}
+
+	out := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += size {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		vecs, err := p.embedBatch(texts[start:min(start+size, len(texts))], prompt)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, vecs...)
+	}
+	return out, nil
+}
+
+// embedBatch runs texts through the model in one pass.
+func (p *onnxProvider) embedBatch(texts []string, prompt string) ([][]float32, error) {
+	encoded := make([][]int64, len(texts))
+	seq := 0
+	for i, text := range texts {
+		encoded[i] = p.tok.encode(prompt + text)
+		seq = max(seq, len(encoded[i]))
+	}
+	batch := len(texts)
+	ids := make([]int64, batch*seq)
+	mask := make([]int64, batch*seq)
+	for i, enc := range encoded {
+		for j := 0; j < seq; j++ {
+			if j < len(enc) {
+				ids[i*seq+j] = enc[j]
+				mask[i*seq+j] = 1
+			} else {
+				ids[i*seq+j] = p.tok.padID
+			}
+		}
+	}
+
+	hidden, dim, err := p.sess.run(ids, mask, make([]int64, batch*seq), batch, seq)
+	if err != nil {
+		return nil, fmt.Errorf("running onnx model %s: %w", p.modelID, err)
+	}
+	if len(hidden) != batch*seq*dim {
+		return nil, fmt.Errorf("onnx model %s returned %d values for a %dx%dx%d output", p.modelID, len(hidden), batch, seq, dim)
+	}
+
+	out := make([][]float32, batch)
+	for i := range out {
+		out[i] = poolHidden(hidden[i*seq*dim:(i+1)*seq*dim], mask[i*seq:(i+1)*seq], dim, p.cls)
+	}
+	return out, nil
+}
+
+// Close releases the ONNX Runtime session.
+func (p *onnxProvider) Close() error {
+	return p.sess.close()
+}
+
+// poolHidden pools one text's hidden states ([seq][dim]) into a unit
+// vector: the first (CLS) token's state, or the mean over unmasked tokens.
+func poolHidden(hidden []float32, mask []int64, dim int, cls bool) []float32 {
+	v := make([]float32, dim)
+	if cls {
+		copy(v, hidden[:dim])
+	} else {
+		var n float32
+		for j, m := range mask {
+			if m == 0 {
+				continue
+			}
+			for k := 0; k < dim; k++ {
+				v[k] += hidden[j*dim+k]
+			}
+			n++
+		}
+		if n > 0 {
+			for k := range v {
+				v[k] /= n
+			}
+		}
+	}
+
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum > 0 {
+		norm := float32(math.Sqrt(sum))
+		for k := range v {
+			v[k] /= norm
+		}
+	}
+	return v
+}
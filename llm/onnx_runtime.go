@@ -0,0 +1,128 @@
+//go:build onnx
+
+package llm
+
+import (
+	"fmt"
+	"sync"
+
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// ortEnv initializes the ONNX Runtime environment once per process; the
+// shared library path of the first provider wins.
+var ortEnv struct {
+	once sync.Once
+	err  error
+}
+
+// ortDefaultBatch caps the batch of models with a dynamic batch
+// dimension, bounding the memory of one run.
+const ortDefaultBatch = 32
+
+// ortSession is an onnxSession backed by ONNX Runtime.
+type ortSession struct {
+	sess       *ort.DynamicAdvancedSession
+	inputNames []string
+	dim        int64
+	batch      int
+}
+
+// openONNXSession loads the model at modelPath. It feeds the inputs the
+// model declares among input_ids, attention_mask, and token_type_ids, and
+// reads last_hidden_state (or the first output).
+func openONNXSession(modelPath, library string) (onnxSession, error) {
+	ortEnv.once.Do(func() {
+		if library != "" {
+			ort.SetSharedLibraryPath(library)
+		}
+		ortEnv.err = ort.InitializeEnvironment()
+	})
+	if ortEnv.err != nil {
+		return nil, fmt.Errorf("initializing onnx runtime: %w", ortEnv.err)
+	}
+
+	inputs, outputs, err := ort.GetInputOutputInfo(modelPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading onnx model: %w", err)
+	}
+	var inputNames []string
+	hasIDs, hasMask := false, false
+	batch := ortDefaultBatch
+	for _, in := range inputs {
+		// A fixed batch dimension is the most the model takes at once.
+		if len(in.Dimensions) > 0 && in.Dimensions[0] > 0 {
+			batch = min(batch, int(in.Dimensions[0]))
+		}
+		switch in.Name {
+		case "input_ids":
+			hasIDs = true
+		case "attention_mask":
+			hasMask = true
+		case "token_type_ids":
+		default:
+			return nil, fmt.Errorf("onnx model has unsupported input %q", in.Name)
+		}
+		inputNames = append(inputNames, in.Name)
+	}
+	if !hasIDs || !hasMask {
+		return nil, fmt.Errorf("onnx model needs input_ids and attention_mask inputs")
+	}
+	if len(outputs) == 0 {
+		return nil, fmt.Errorf("onnx model has no outputs")
+	}
+	out := outputs[0]
+	for _, o := range outputs {
+		if o.Name == "last_hidden_state" {
+			out = o
+		}
+	}
+	if len(out.Dimensions) != 3 || out.Dimensions[2] <= 0 {
+		return nil, fmt.Errorf("onnx output %q has shape %v, want [batch, seq, dim]", out.Name, out.Dimensions)
+	}
+
+	sess, err := ort.NewDynamicAdvancedSession(modelPath, inputNames, []string{out.Name}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating onnx session: %w", err)
+	}
+	return &ortSession{sess: sess, inputNames: inputNames, dim: out.Dimensions[2], batch: batch}, nil
+}
+
+func (s *ortSession) run(ids, mask, typeIDs []int64, batch, seq int) ([]float32, int, error) {
+	shape := ort.NewShape(int64(batch), int64(seq))
+	inputs := make([]ort.Value, len(s.inputNames))
+	for i, name := range s.inputNames {
+		data := ids
+		switch name {
+		case "attention_mask":
+			data = mask
+		case "token_type_ids":
+			data = typeIDs
+		}
+		t, err := ort.NewTensor(shape, data)
+		if err != nil {
+			return nil, 0, err
+		}
+		defer t.Destroy()
+		inputs[i] = t
+	}
+	out, err := ort.NewEmptyTensor[float32](ort.NewShape(int64(batch), int64(seq), s.dim))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer out.Destroy()
+
+	if err := s.sess.Run(inputs, []ort.Value{out}); err != nil {
+		return nil, 0, err
+	}
+	// The tensor's memory is freed with it.
+	return append([]float32(nil), out.GetData()...), int(s.dim), nil
+}
+
+func (s *ortSession) maxBatch() int {
+	return s.batch
+}
+
+func (s *ortSession) close() error {
+	return s.sess.Destroy()
+}
@@ -0,0 +1,11 @@
+//go:build !onnx
+
+package llm
+
+import "fmt"
+
+// openONNXSession fails in builds without the onnx tag, which leave out
+// the ONNX Runtime bindings and their shared library dependency.
+func openONNXSession(modelPath, library string) (onnxSession, error) {
+	return nil, fmt.Errorf("onnx provider unavailable: build with -tags onnx")
+}
@@ -0,0 +1,219 @@
+package llm
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const wordPieceTokenizer = `{
+  "normalizer": {"type": "BertNormalizer", "lowercase": true, "strip_accents": null},
+  "pre_tokenizer": {"type": "BertPreTokenizer"},
+  "post_processor": {"type": "BertProcessing", "sep": ["[SEP]", 3], "cls": ["[CLS]", 2]},
+  "model": {
+    "type": "WordPiece", "unk_token": "[UNK]", "continuing_subword_prefix": "##",
+    "vocab": {"[PAD]": 0, "[UNK]": 1, "[CLS]": 2, "[SEP]": 3, "hello": 4, ",": 5, "world": 6, "!": 7, "un": 8, "##able": 9}
+  }
+}`
+
+const unigramTokenizer = `{
+  "truncation": {"max_length": 6},
+  "added_tokens": [{"id": 1, "content": "<pad>"}],
+  "normalizer": {"type": "Sequence", "normalizers": [{"type": "Precompiled"}]},
+  "pre_tokenizer": {"type": "Sequence", "pretokenizers": [{"type": "WhitespaceSplit"}, {"type": "Metaspace"}]},
+  "post_processor": {
+    "type": "TemplateProcessing",
+    "single": [{"SpecialToken": {"id": "<s>"}}, {"Sequence": {"id": "A"}}, {"SpecialToken": {"id": "</s>"}}],
+    "special_tokens": {"<s>": {"ids": [0]}, "</s>": {"ids": [2]}}
+  },
+  "model": {
+    "type": "Unigram", "unk_id": 3,
+    "vocab": [["<s>", 0], ["<pad>", 0], ["</s>", 0], ["<unk>", 0],
+      ["▁", -2], ["▁hel", -3], ["lo", -2], ["▁hello", -4], ["l", -5]]
+  }
+}`
+
+func TestWordPieceTokenizer(t *testing.T) {
+	tok, err := parseTokenizer([]byte(wordPieceTokenizer))
+	if err != nil {
+		t.Fatalf("parseTokenizer: %v", err)
+	}
+	// Accents are stripped, punctuation split off, unknown words kept as
+	// one [UNK].
+	got := tok.encode("Héllo, WORLD! unable xyz")
+	want := []int64{2, 4, 5, 6, 7, 8, 9, 1, 3}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("encode = %v, want %v", got, want)
+	}
+	if tok.padID != 0 {
+		t.Errorf("padID = %d, want 0", tok.padID)
+	}
+}
+
+func TestUnigramTokenizer(t *testing.T) {
+	tok, err := parseTokenizer([]byte(unigramTokenizer))
+	if err != nil {
+		t.Fatalf("parseTokenizer: %v", err)
+	}
+	// "▁hello" beats "▁hel"+"lo"; the uncovered "z" is unknown.
+	got := tok.encode("hello hellz")
+	want := []int64{0, 7, 5, 8, 3, 2}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("encode = %v, want %v", got, want)
+	}
+	// Truncated to max_length, keeping the closing token.
+	got = tok.encode("hello hello hello hello hello")
+	want = []int64{0, 7, 7, 7, 7, 2}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("encode(long) = %v, want %v", got, want)
+	}
+	if tok.padID != 1 {
+		t.Errorf("padID = %d, want 1", tok.padID)
+	}
+
+	if _, err := parseTokenizer([]byte(`{"model": {"type": "BPE"}}`)); err == nil {
+		t.Error("expected error for an unsupported model")
+	}
+}
+
+func TestTokenizerCountTokens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokenizer.json")
+	if err := os.WriteFile(path, []byte(unigramTokenizer), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tok, err := LoadTokenizer(path)
+	if err != nil {
+		t.Fatalf("LoadTokenizer: %v", err)
+	}
+	// "hellz" is three pieces; no special tokens, and no truncation to
+	// max_length 6.
+	if n := tok.CountTokens("hello hellz hello hello hello hello"); n != 8 {
+		t.Errorf("CountTokens = %d, want 8", n)
+	}
+	if _, err := LoadTokenizer(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+// fakeSession returns hidden states where every component of token j of
+// text i is i*10+j, so pooling results are easy to predict.
+type fakeSession struct {
+	dim     int
+	batch   int // maxBatch; 0 takes any batch
+	seen    []int64
+	batches []int
+}
+
+func (s *fakeSession) run(ids, mask, typeIDs []int64, batch, seq int) ([]float32, int, error) {
+	s.seen = ids
+	s.batches = append(s.batches, batch)
+	hidden := make([]float32, batch*seq*s.dim)
+	for i := 0; i < batch; i++ {
+		for j := 0; j < seq; j++ {
+			for k := 0; k < s.dim; k++ {
+				hidden[(i*seq+j)*s.dim+k] = float32(i*10 + j)
+			}
+		}
+	}
+	return hidden, s.dim, nil
+}
+
+func (s *fakeSession) maxBatch() int { return s.batch }
+
+func (s *fakeSession) close() error { return nil }
+
+func TestONNXEmbedPooling(t *testing.T) {
+	tok, err := parseTokenizer([]byte(wordPieceTokenizer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess := &fakeSession{dim: 2}
+	p := &onnxProvider{tok: tok, sess: sess, modelID: "test"}
+
+	vecs, err := p.Embed(context.Background(), []string{"hello world", "hello"})
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	// The shorter text is padded with [PAD].
+	if want := []int64{2, 4, 6, 3, 2, 4, 3, 0}; !reflect.DeepEqual(sess.seen, want) {
+		t.Errorf("ids = %v, want %v", sess.seen, want)
+	}
+	if len(vecs) != 2 || len(vecs[0]) != 2 {
+		t.Fatalf("got %d vectors", len(vecs))
+	}
+	for i, v := range vecs {
+		if n := math.Hypot(float64(v[0]), float64(v[1])); math.Abs(n-1) > 1e-6 {
+			t.Errorf("vector %d has norm %f, want 1", i, n)
+		}
+	}
+
+	// Mean pooling skips the padding.
+	mean := poolHidden([]float32{1, 1, 3, 3, 100, 100}, []int64{1, 1, 0}, 2, false)
+	cls := poolHidden([]float32{1, 0, 3, 3, 100, 100}, []int64{1, 1, 0}, 2, true)
+	if math.Abs(float64(mean[0]-mean[1])) > 1e-6 || math.Abs(float64(cls[0])-1) > 1e-6 || cls[1] != 0 {
+		t.Errorf("mean = %v, cls = %v", mean, cls)
+	}
+}
+
+func TestONNXEmbedBatchesAndPrompts(t *testing.T) {
+	tok, err := parseTokenizer([]byte(wordPieceTokenizer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess := &fakeSession{dim: 2, batch: 2}
+	p := &onnxProvider{tok: tok, sess: sess, modelID: "test", prompts: onnxPrompts{query: "hello ", passage: "world "}}
+
+	vecs, err := p.Embed(context.Background(), []string{"hello", "world", "hello", "world", "hello"})
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if len(vecs) != 5 || !reflect.DeepEqual(sess.batches, []int{2, 2, 1}) {
+		t.Errorf("got %d vectors in batches %v, want 5 in batches of at most 2", len(vecs), sess.batches)
+	}
+	// Passages get the passage prompt, queries the query prompt.
+	if want := []int64{2, 6, 4, 3}; !reflect.DeepEqual(sess.seen, want) {
+		t.Errorf("passage ids = %v, want %v", sess.seen, want)
+	}
+	if _, err := p.Embed(WithPhase(context.Background(), PhaseQuery), []string{"world"}); err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if want := []int64{2, 4, 6, 3}; !reflect.DeepEqual(sess.seen, want) {
+		t.Errorf("query ids = %v, want %v", sess.seen, want)
+	}
+
+	dir := filepath.Join(t.TempDir(), "multilingual-e5-small")
+	os.MkdirAll(dir, 0o755)
+	if got, err := readPrompts(dir); err != nil || got != (onnxPrompts{query: "query: ", passage: "passage: "}) {
+		t.Errorf("readPrompts(e5) = %+v, %v", got, err)
+	}
+	os.WriteFile(filepath.Join(dir, "config_sentence_transformers.json"),
+		[]byte(`{"prompts": {"query": "Query: ", "document": "Doc: "}}`), 0o644)
+	if got, err := readPrompts(dir); err != nil || got != (onnxPrompts{query: "Query: ", passage: "Doc: "}) {
+		t.Errorf("readPrompts(config) = %+v, %v", got, err)
+	}
+	if got, err := readPrompts(t.TempDir()); err != nil || got != (onnxPrompts{}) {
+		t.Errorf("readPrompts(bge) = %+v, %v", got, err)
+	}
+}
+
+func TestNewONNXErrors(t *testing.T) {
+	if _, err := NewProvider(Config{Provider: "onnx"}); err == nil {
+		t.Error("expected error without a model directory")
+	}
+	dir := t.TempDir()
+	if _, err := NewProvider(Config{Provider: "onnx", Model: dir}); err == nil {
+		t.Error("expected error for a directory without model.onnx")
+	}
+
+	os.WriteFile(filepath.Join(dir, "model.onnx"), []byte("onnx"), 0o644)
+	os.WriteFile(filepath.Join(dir, "tokenizer.json"), []byte(wordPieceTokenizer), 0o644)
+	os.MkdirAll(filepath.Join(dir, "1_Pooling"), 0o755)
+	os.WriteFile(filepath.Join(dir, "1_Pooling", "config.json"), []byte(`{"pooling_mode_cls_token": true}`), 0o644)
+	cls, err := readPoolingMode(filepath.Join(dir, "1_Pooling", "config.json"))
+	if err != nil || !cls {
+		t.Errorf("readPoolingMode = %v, %v; want CLS pooling", cls, err)
+	}
+}
@@ -32,6 +32,10 @@ func (p *openAIProvider) Chat(ctx context.Context, req ChatRequest) (*ChatRespon
 	return p.base.chat(ctx, req)
 }
 
+func (p *openAIProvider) ChatStream(ctx context.Context, req ChatRequest, onDelta func(string) error) (*ChatResponse, error) {
+	return p.base.chatStream(ctx, req, onDelta)
+}
+
 func (p *openAIProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
 	return p.base.embed(ctx, texts)
 }
@@ -10,13 +10,26 @@ import (
 	"net/http"
 	"strconv"
 	"time"
+
+	"github.com/bbiangul/go-reason/requestid"
 )
 
+// setRequestID forwards the context's request ID, so gateways and proxies
+// in front of the provider can log it next to their own records.
+func setRequestID(req *http.Request) {
+	if id := requestid.From(req.Context()); id != "" {
+		req.Header.Set(requestid.Header, id)
+	}
+}
+
 // openAICompatClient is the shared base for all OpenAI-compatible providers.
 type openAICompatClient struct {
 	cfg        Config
 	client     *http.Client
 	pathPrefix string // API path prefix, defaults to "/v1"
+	// cacheControl sends cache_control breakpoints on messages with a
+	// CachePrefix.
+	cacheControl bool
 }
 
 func newOpenAICompatClient(cfg Config) openAICompatClient {
@@ -29,8 +42,9 @@ func newOpenAICompatClientPrefix(cfg Config, prefix string) openAICompatClient {
 	// reasonable enough to avoid multi-minute hangs on stalled connections.
 	timeout := 120 * time.Second
 	return openAICompatClient{
-		cfg:        cfg,
-		pathPrefix: prefix,
+		cfg:          cfg,
+		pathPrefix:   prefix,
+		cacheControl: cfg.CacheControl,
 		client: &http.Client{
 			Timeout: timeout,
 		},
@@ -50,6 +64,10 @@ func (p *openAICompatProvider) Chat(ctx context.Context, req ChatRequest) (*Chat
 	return p.base.chat(ctx, req)
 }
 
+func (p *openAICompatProvider) ChatStream(ctx context.Context, req ChatRequest, onDelta func(string) error) (*ChatResponse, error) {
+	return p.base.chatStream(ctx, req, onDelta)
+}
+
 func (p *openAICompatProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
 	return p.base.embed(ctx, texts)
 }
@@ -61,11 +79,16 @@ func (p *openAICompatProvider) ChatWithImages(ctx context.Context, req VisionCha
 // --- shared implementation ---
 
 type chatCompletionRequest struct {
-	Model          string          `json:"model"`
-	Messages       json.RawMessage `json:"messages"`
-	Temperature    float64         `json:"temperature,omitempty"`
-	MaxTokens      int             `json:"max_tokens,omitempty"`
-	ResponseFormat *responseFormat  `json:"response_format,omitempty"`
+	Model          string                   `json:"model"`
+	Messages       json.RawMessage          `json:"messages"`
+	Temperature    float64                  `json:"temperature,omitempty"`
+	MaxTokens      int                      `json:"max_tokens,omitempty"`
+	ResponseFormat *responseFormat          `json:"response_format,omitempty"`
+	Tools          []map[string]interface{} `json:"tools,omitempty"`
+	ToolChoice     interface{}              `json:"tool_choice,omitempty"`
+	// ExtraBody carries provider extensions, such as Gemini's
+	// {"google": {"cached_content": ...}}.
+	ExtraBody map[string]interface{} `json:"extra_body,omitempty"`
 }
 
 type responseFormat struct {
@@ -75,18 +98,54 @@ type responseFormat struct {
 type chatCompletionResponse struct {
 	Choices []struct {
 		Message struct {
-			Content string `json:"content"`
+			Content   string           `json:"content"`
+			ToolCalls []openAIToolCall `json:"tool_calls"`
 		} `json:"message"`
 		FinishReason string `json:"finish_reason"`
 	} `json:"choices"`
 	Model string `json:"model"`
 	Usage struct {
-		PromptTokens     int `json:"prompt_tokens"`
-		CompletionTokens int `json:"completion_tokens"`
-		TotalTokens      int `json:"total_tokens"`
+		PromptTokens        int `json:"prompt_tokens"`
+		CompletionTokens    int `json:"completion_tokens"`
+		TotalTokens         int `json:"total_tokens"`
+		PromptTokensDetails struct {
+			CachedTokens int `json:"cached_tokens"`
+		} `json:"prompt_tokens_details"`
 	} `json:"usage"`
 }
 
+// toChatResponse converts the first choice to a ChatResponse, decoding
+// tool call arguments from their JSON-string encoding.
+func (r *chatCompletionResponse) toChatResponse() (*ChatResponse, error) {
+	if len(r.Choices) == 0 {
+		return nil, fmt.Errorf("no choices in response")
+	}
+	choice := r.Choices[0]
+	out := &ChatResponse{
+		Content:          choice.Message.Content,
+		Model:            r.Model,
+		FinishReason:     choice.FinishReason,
+		PromptTokens:     r.Usage.PromptTokens,
+		CompletionTokens: r.Usage.CompletionTokens,
+		TotalTokens:      r.Usage.TotalTokens,
+		CachedTokens:     r.Usage.PromptTokensDetails.CachedTokens,
+	}
+	for _, tc := range choice.Message.ToolCalls {
+		args := rawArgs(json.RawMessage(tc.Function.Arguments))
+		if !json.Valid(args) {
+			// Some models emit malformed arguments; keep them as a JSON
+			// string so the caller can report the error back to the model.
+			args, _ = json.Marshal(tc.Function.Arguments)
+		}
+		out.ToolCalls = append(out.ToolCalls, ToolCall{
+			ID:        tc.ID,
+			Name:      tc.Function.Name,
+			Arguments: args,
+		})
+	}
+	return out, nil
+}
+
 type embeddingRequest struct {
 	Model string   `json:"model"`
 	Input []string `json:"input"`
@@ -100,48 +159,66 @@ type embeddingResponse struct {
 }
 
 func (c *openAICompatClient) chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
-	msgs, err := json.Marshal(req.Messages)
+	return c.chatExtra(ctx, req, nil)
+}
+
+// chatExtra is chat with provider extensions added to the request body.
+func (c *openAICompatClient) chatExtra(ctx context.Context, req ChatRequest, extra map[string]interface{}) (*ChatResponse, error) {
+	body, style, err := c.chatBody(req, extra)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := c.doPost(ctx, c.pathPrefix+"/chat/completions", body)
 	if err != nil {
 		return nil, err
 	}
 
+	var resp chatCompletionResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return nil, fmt.Errorf("decoding chat response: %w", err)
+	}
+	out, err := resp.toChatResponse()
+	if err == nil && style == PromptStyleReasoning {
+		out.Content = StripThinking(out.Content)
+	}
+	return out, err
+}
+
+// chatBody builds the wire request for req, adapted to the model's prompt
+// style, which it also returns.
+func (c *openAICompatClient) chatBody(req ChatRequest, extra map[string]interface{}) (chatCompletionRequest, string, error) {
 	model := req.Model
 	if model == "" {
 		model = c.cfg.Model
 	}
+	style := c.cfg.promptStyle(model)
+	req = AdaptRequest(style, req)
+
+	var wire interface{} = openAIMessages(req.Messages)
+	if c.cacheControl && hasCachePrefix(req.Messages) {
+		wire = cacheControlMessages(req.Messages)
+	}
+	msgs, err := json.Marshal(wire)
+	if err != nil {
+		return chatCompletionRequest{}, "", err
+	}
 
 	body := chatCompletionRequest{
 		Model:       model,
 		Messages:    msgs,
 		Temperature: req.Temperature,
 		MaxTokens:   req.MaxTokens,
+		ExtraBody:   extra,
 	}
 	if req.ResponseFormat == "json_object" {
 		body.ResponseFormat = &responseFormat{Type: "json_object"}
 	}
-
-	respBody, err := c.doPost(ctx, c.pathPrefix+"/chat/completions", body)
-	if err != nil {
-		return nil, err
-	}
-
-	var resp chatCompletionResponse
-	if err := json.Unmarshal(respBody, &resp); err != nil {
-		return nil, fmt.Errorf("decoding chat response: %w", err)
+	if len(req.Tools) > 0 {
+		body.Tools = openAITools(req.Tools)
+		body.ToolChoice = openAIToolChoice(req.ToolChoice)
 	}
-
-	if len(resp.Choices) == 0 {
-		return nil, fmt.Errorf("no choices in response")
-	}
-
-	return &ChatResponse{
-		Content:          resp.Choices[0].Message.Content,
-		Model:            resp.Model,
-		FinishReason:     resp.Choices[0].FinishReason,
-		PromptTokens:     resp.Usage.PromptTokens,
-		CompletionTokens: resp.Usage.CompletionTokens,
-		TotalTokens:      resp.Usage.TotalTokens,
-	}, nil
+	return body, style, nil
 }
 
 func (c *openAICompatClient) embed(ctx context.Context, texts []string) ([][]float32, error) {
@@ -209,13 +286,15 @@ func (c *openAICompatClient) chatWithImages(ctx context.Context, req VisionChatR
 		PromptTokens:     resp.Usage.PromptTokens,
 		CompletionTokens: resp.Usage.CompletionTokens,
 		TotalTokens:      resp.Usage.TotalTokens,
+		CachedTokens:     resp.Usage.PromptTokensDetails.CachedTokens,
 	}, nil
 }
 
-const (
-	maxRetries         = 6
-	baseRetryDelay     = 2 * time.Second
-	minRateLimitDelay  = 5 * time.Second // minimum delay for 429 errors
+// Retry policy of doPost. Variables so tests can shorten the delays.
+var (
+	maxRetries        = 6
+	baseRetryDelay    = 2 * time.Second
+	minRateLimitDelay = 5 * time.Second // minimum delay for 429 errors
 )
 
 // retryableStatusCode returns true for HTTP status codes that warrant a retry.
@@ -238,7 +317,7 @@ func (c *openAICompatClient) doPost(ctx context.Context, path string, body inter
 	for attempt := 0; attempt <= maxRetries; attempt++ {
 		if attempt > 0 {
 			delay := baseRetryDelay * time.Duration(1<<(attempt-1)) // 1s, 2s, 4s
-			slog.Warn("llm: retrying request",
+			slog.WarnContext(ctx, "llm: retrying request",
 				"url", url,
 				"attempt", attempt,
 				"delay", delay,
@@ -260,6 +339,7 @@ func (c *openAICompatClient) doPost(ctx context.Context, path string, body inter
 		if c.cfg.APIKey != "" {
 			req.Header.Set("Authorization", "Bearer "+c.cfg.APIKey)
 		}
+		setRequestID(req)
 
 		resp, err := c.client.Do(req)
 		if err != nil {
@@ -300,7 +380,7 @@ func (c *openAICompatClient) doPost(ctx context.Context, path string, body inter
 					}
 				}
 			}
-			slog.Warn("llm: rate limited, waiting before retry",
+			slog.WarnContext(ctx, "llm: rate limited, waiting before retry",
 				"url", url,
 				"attempt", attempt+1,
 				"delay", rateLimitDelay,
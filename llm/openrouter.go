@@ -13,6 +13,9 @@ func NewOpenRouter(cfg Config) Provider {
 	if cfg.BaseURL == "" {
 		cfg.BaseURL = "https://openrouter.ai/api"
 	}
+	// OpenRouter passes cache_control through to the models that need it
+	// (Anthropic, Gemini) and ignores it for the rest.
+	cfg.CacheControl = true
 	return &openRouterProvider{base: newOpenAICompatClient(cfg)}
 }
 
@@ -20,6 +23,10 @@ func (p *openRouterProvider) Chat(ctx context.Context, req ChatRequest) (*ChatRe
 	return p.base.chat(ctx, req)
 }
 
+func (p *openRouterProvider) ChatStream(ctx context.Context, req ChatRequest, onDelta func(string) error) (*ChatResponse, error) {
+	return p.base.chatStream(ctx, req, onDelta)
+}
+
 func (p *openRouterProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
 	return p.base.embed(ctx, texts)
 }
@@ -0,0 +1,121 @@
+package llm
+
+import (
+	"strings"
+)
+
+// Prompt styles of chat models, selected from the model name unless
+// Config.PromptStyle sets one.
+const (
+	// PromptStyleChat is the shape instruction-tuned chat models expect: a
+	// system message, sampling settings, and explicit step-by-step requests.
+	PromptStyleChat = "chat"
+	// PromptStyleReasoning suits models that reason before answering, such
+	// as OpenAI's o-series and DeepSeek R1. They have no system role (or
+	// handle it poorly), reject or ignore temperature and JSON mode, and do
+	// worse when told how to think.
+	PromptStyleReasoning = "reasoning"
+)
+
+// PromptStyleFor returns the prompt style of model from its name, e.g.
+// PromptStyleReasoning for "o3-mini", "deepseek/deepseek-r1", or
+// "deepseek-r1:14b" and PromptStyleChat for "gpt-4o" or "llama3.1:8b".
+func PromptStyleFor(model string) string {
+	name := strings.ToLower(model)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	if i := strings.Index(name, ":"); i >= 0 {
+		name = name[:i] // Ollama tag, e.g. ":14b"
+	}
+	for _, series := range []string{"o1", "o3", "o4"} {
+		if name == series || strings.HasPrefix(name, series+"-") {
+			return PromptStyleReasoning
+		}
+	}
+	switch {
+	case strings.HasPrefix(name, "r1"), strings.Contains(name, "-r1"),
+		strings.Contains(name, "deepseek-reasoner"), strings.HasPrefix(name, "qwq"):
+		return PromptStyleReasoning
+	}
+	return PromptStyleChat
+}
+
+// promptStyle is the prompt style of requests to model: cfg.PromptStyle
+// when set, otherwise the one named by the model.
+func (cfg Config) promptStyle(model string) string {
+	if cfg.PromptStyle != "" {
+		return cfg.PromptStyle
+	}
+	return PromptStyleFor(model)
+}
+
+// AdaptRequest reshapes req for a model of the given prompt style. Chat
+// requests are returned unchanged. For reasoning models, system messages
+// are folded into the user message that follows them, and temperature and
+// JSON mode are dropped; callers of JSON mode already tolerate prose
+// around the object.
+func AdaptRequest(style string, req ChatRequest) ChatRequest {
+	if style != PromptStyleReasoning {
+		return req
+	}
+	req.Temperature = 0
+	req.ResponseFormat = ""
+
+	msgs := make([]Message, 0, len(req.Messages))
+	var system []string
+	for _, m := range req.Messages {
+		if m.Role == "system" {
+			system = append(system, m.Content)
+			continue
+		}
+		if m.Role == "user" && len(system) > 0 {
+			prefix := strings.Join(system, "\n\n") + "\n\n"
+			m.Content = prefix + m.Content
+			if m.CachePrefix > 0 {
+				m.CachePrefix += len(prefix)
+			}
+			system = nil
+		}
+		msgs = append(msgs, m)
+	}
+	if len(system) > 0 {
+		// Trailing instructions with no user message after them.
+		msgs = append(msgs, Message{Role: "user", Content: strings.Join(system, "\n\n")})
+	}
+	req.Messages = msgs
+	return req
+}
+
+// StripThinking removes the <think>...</think> blocks some reasoning
+// models (DeepSeek R1, QwQ, Qwen3) put before their answer.
+func StripThinking(s string) string {
+	if !strings.Contains(s, "<think>") {
+		return s
+	}
+	for {
+		start := strings.Index(s, "<think>")
+		if start == -1 {
+			break
+		}
+		end := strings.Index(s, "</think>")
+		if end == -1 {
+			// Unclosed tag — strip from <think> onward
+			s = s[:start]
+			break
+		}
+		s = s[:start] + s[end+len("</think>"):]
+	}
+	return strings.TrimSpace(s)
+}
+
+// JSONObject returns the JSON object in a model's reply: the text from its
+// first "{" to its last "}", dropping prose or code fences around it. A
+// reply without one is returned trimmed.
+func JSONObject(reply string) string {
+	reply = strings.TrimSpace(reply)
+	if start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}"); start >= 0 && end > start {
+		return reply[start : end+1]
+	}
+	return reply
+}
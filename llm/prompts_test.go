@@ -0,0 +1,123 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPromptStyleFor(t *testing.T) {
+	tests := map[string]string{
+		"o1":                                PromptStyleReasoning,
+		"o3-mini":                           PromptStyleReasoning,
+		"openai/o4-mini-high":               PromptStyleReasoning,
+		"deepseek/deepseek-r1":              PromptStyleReasoning,
+		"deepseek-r1:14b":                   PromptStyleReasoning,
+		"deepseek-r1-distill-llama-70b":     PromptStyleReasoning,
+		"deepseek-reasoner":                 PromptStyleReasoning,
+		"qwq-32b":                           PromptStyleReasoning,
+		"gpt-4o":                            PromptStyleChat,
+		"llama3.1:8b":                       PromptStyleChat,
+		"omni-model":                        PromptStyleChat,
+		"anthropic/claude-sonnet-4":         PromptStyleChat,
+		"meta-llama/llama-3.3-70b-instruct": PromptStyleChat,
+	}
+	for model, want := range tests {
+		if got := PromptStyleFor(model); got != want {
+			t.Errorf("PromptStyleFor(%q) = %q, want %q", model, got, want)
+		}
+	}
+}
+
+func TestAdaptRequest(t *testing.T) {
+	req := ChatRequest{
+		Messages: []Message{
+			{Role: "system", Content: "Be precise."},
+			{Role: "user", Content: "Context: x\n\nQuestion: y", CachePrefix: 10},
+		},
+		Temperature:    0.2,
+		ResponseFormat: "json_object",
+	}
+	if got := AdaptRequest(PromptStyleChat, req); len(got.Messages) != 2 || got.Temperature != 0.2 {
+		t.Errorf("chat request changed: %+v", got)
+	}
+
+	got := AdaptRequest(PromptStyleReasoning, req)
+	if len(got.Messages) != 1 || got.Messages[0].Role != "user" {
+		t.Fatalf("messages = %+v, want one user message", got.Messages)
+	}
+	m := got.Messages[0]
+	if m.Content != "Be precise.\n\nContext: x\n\nQuestion: y" {
+		t.Errorf("content = %q", m.Content)
+	}
+	if m.Content[:m.CachePrefix] != "Be precise.\n\nContext: x" {
+		t.Errorf("cache prefix = %q", m.Content[:m.CachePrefix])
+	}
+	if got.Temperature != 0 || got.ResponseFormat != "" {
+		t.Errorf("temperature = %v, response format = %q", got.Temperature, got.ResponseFormat)
+	}
+	if req.Messages[0].Role != "system" {
+		t.Error("AdaptRequest modified the caller's messages")
+	}
+}
+
+func TestChatReasoningModel(t *testing.T) {
+	var sent map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &sent)
+		w.Write([]byte(`{"model": "deepseek-r1", "choices": [{"message": {"content": "<think>The valve opens at 10 bar.</think>\n10 bar"}, "finish_reason": "stop"}]}`))
+	}))
+	defer srv.Close()
+
+	p := NewOpenAICompat(Config{Provider: "custom", Model: "deepseek-r1", BaseURL: srv.URL})
+	resp, err := p.Chat(context.Background(), ChatRequest{
+		Messages:       []Message{{Role: "system", Content: "Be precise."}, {Role: "user", Content: "Pressure?"}},
+		Temperature:    0.3,
+		ResponseFormat: "json_object",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "10 bar" {
+		t.Errorf("content = %q, want the thinking stripped", resp.Content)
+	}
+	msgs, _ := sent["messages"].([]interface{})
+	if len(msgs) != 1 || msgs[0].(map[string]interface{})["role"] != "user" {
+		t.Errorf("messages sent = %v", sent["messages"])
+	}
+	if _, ok := sent["temperature"]; ok {
+		t.Errorf("temperature sent: %v", sent["temperature"])
+	}
+	if _, ok := sent["response_format"]; ok {
+		t.Errorf("response_format sent: %v", sent["response_format"])
+	}
+
+	// An explicit style overrides the name.
+	p = NewOpenAICompat(Config{Provider: "custom", Model: "deepseek-r1", BaseURL: srv.URL, PromptStyle: PromptStyleChat})
+	if _, err := p.Chat(context.Background(), ChatRequest{
+		Messages: []Message{{Role: "system", Content: "Be precise."}, {Role: "user", Content: "Pressure?"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if msgs, _ := sent["messages"].([]interface{}); len(msgs) != 2 {
+		t.Errorf("messages sent with chat style = %v", sent["messages"])
+	}
+}
+
+func TestJSONObject(t *testing.T) {
+	tests := []struct{ reply, want string }{
+		{`{"a": 1}`, `{"a": 1}`},
+		{"Here it is:\n```json\n{\"a\": {\"b\": 2}}\n```\nDone.", `{"a": {"b": 2}}`},
+		{"  no object here ", "no object here"},
+		{"} backwards {", "} backwards {"},
+	}
+	for _, tt := range tests {
+		if got := JSONObject(tt.reply); got != tt.want {
+			t.Errorf("JSONObject(%q) = %q, want %q", tt.reply, got, tt.want)
+		}
+	}
+}
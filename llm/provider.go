@@ -29,6 +29,12 @@ type ChatRequest struct {
 	MaxTokens   int       `json:"max_tokens,omitempty"`
 	// ResponseFormat can be set to "json_object" for JSON mode.
 	ResponseFormat string `json:"response_format,omitempty"`
+	// Tools the model may call, in provider-agnostic form. Each provider
+	// translates them to its own wire format (see tools.go).
+	Tools []Tool `json:"tools,omitempty"`
+	// ToolChoice is "auto" (default), "none", "required", or a tool name
+	// to force that tool.
+	ToolChoice string `json:"tool_choice,omitempty"`
 }
 
 // VisionChatRequest is a chat request with image content.
@@ -39,10 +45,19 @@ type VisionChatRequest struct {
 	MaxTokens   int             `json:"max_tokens,omitempty"`
 }
 
-// Message represents a chat message.
+// Message represents a chat message. In a tool loop, assistant messages
+// carry the ToolCalls the model made and each "tool" message answers one
+// of them via ToolCallID.
 type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+	// CachePrefix, when positive, marks the conversation up to
+	// Content[:CachePrefix] as a prefix worth caching because later
+	// requests repeat it (see cache.go). Providers without explicit
+	// prompt caching ignore it.
+	CachePrefix int `json:"cache_prefix,omitempty"`
 }
 
 // VisionMessage represents a chat message that may contain images.
@@ -71,14 +86,30 @@ type ChatResponse struct {
 	PromptTokens     int    `json:"prompt_tokens"`
 	CompletionTokens int    `json:"completion_tokens"`
 	TotalTokens      int    `json:"total_tokens"`
+	// CachedTokens is the part of PromptTokens the provider served from
+	// its prompt cache.
+	CachedTokens int `json:"cached_tokens,omitempty"`
+	// ToolCalls requested by the model. When non-empty, the caller should
+	// run the tools and send the results back as "tool" messages.
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
 }
 
 // Config configures an LLM provider.
 type Config struct {
-	Provider string `json:"provider"` // ollama, lmstudio, openrouter, openai, groq, xai, gemini, custom
+	Provider string `json:"provider"` // ollama, lmstudio, openrouter, openai, groq, xai, gemini, custom, onnx
 	Model    string `json:"model"`
 	BaseURL  string `json:"base_url"`
 	APIKey   string `json:"api_key"`
+	// CacheControl sends cache_control breakpoints (Anthropic-style prompt
+	// caching) on messages with a CachePrefix. Always on for openrouter;
+	// set it for a custom endpoint that proxies Anthropic models.
+	CacheControl bool `json:"cache_control,omitempty"`
+	// ONNXLibrary is the path of the ONNX Runtime shared library, for the
+	// onnx provider (see NewONNX).
+	ONNXLibrary string `json:"onnx_library,omitempty"`
+	// PromptStyle is PromptStyleChat or PromptStyleReasoning, overriding the
+	// style detected from the model name (see prompts.go).
+	PromptStyle string `json:"prompt_style,omitempty"`
 }
 
 // NewProvider creates an LLM provider from configuration.
@@ -100,6 +131,8 @@ func NewProvider(cfg Config) (Provider, error) {
 		return NewGemini(cfg), nil
 	case "custom":
 		return NewOpenAICompat(cfg), nil
+	case "onnx":
+		return NewONNX(cfg)
 	case "":
 		return nil, fmt.Errorf("llm provider not specified")
 	default:
@@ -0,0 +1,88 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// SparseVector is a learned sparse embedding (SPLADE, BM42, etc.): a small
+// set of vocabulary indices with non-zero weights. Indices and Values
+// correspond by position.
+type SparseVector struct {
+	Indices []int     `json:"indices"`
+	Values  []float32 `json:"values"`
+}
+
+// SparseEmbedder generates learned sparse embeddings for a batch of texts.
+type SparseEmbedder interface {
+	EmbedSparse(ctx context.Context, texts []string) ([]SparseVector, error)
+}
+
+// NewSparseEmbedder creates a sparse embedding provider from configuration.
+//
+// Supported providers:
+//
+//	tei     — HuggingFace text-embeddings-inference /embed_sparse endpoint
+//	custom  — any server exposing the same /embed_sparse request/response shape
+func NewSparseEmbedder(cfg Config) (SparseEmbedder, error) {
+	switch cfg.Provider {
+	case "tei", "custom":
+		if cfg.BaseURL == "" {
+			cfg.BaseURL = "http://localhost:8080"
+		}
+		return &teiSparseProvider{base: newOpenAICompatClientPrefix(cfg, "")}, nil
+	case "":
+		return nil, fmt.Errorf("sparse embedding provider not specified")
+	default:
+		return nil, fmt.Errorf("unknown sparse embedding provider: %s", cfg.Provider)
+	}
+}
+
+// teiSparseProvider calls a text-embeddings-inference style /embed_sparse
+// endpoint. The request body is {"inputs": [...]} and the response is one
+// array of {"index", "value"} pairs per input text.
+type teiSparseProvider struct {
+	base openAICompatClient
+}
+
+type teiSparseRequest struct {
+	Inputs []string `json:"inputs"`
+}
+
+type teiSparseValue struct {
+	Index int     `json:"index"`
+	Value float32 `json:"value"`
+}
+
+func (p *teiSparseProvider) EmbedSparse(ctx context.Context, texts []string) ([]SparseVector, error) {
+	respBody, err := p.base.doPost(ctx, "/embed_sparse", teiSparseRequest{Inputs: texts})
+	if err != nil {
+		return nil, err
+	}
+
+	var resp [][]teiSparseValue
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return nil, fmt.Errorf("decoding sparse embedding response: %w", err)
+	}
+	if len(resp) != len(texts) {
+		return nil, fmt.Errorf("sparse embedding response has %d vectors for %d inputs", len(resp), len(texts))
+	}
+
+	vectors := make([]SparseVector, len(resp))
+	for i, vals := range resp {
+		v := SparseVector{
+			Indices: make([]int, 0, len(vals)),
+			Values:  make([]float32, 0, len(vals)),
+		}
+		for _, sv := range vals {
+			if sv.Value == 0 {
+				continue
+			}
+			v.Indices = append(v.Indices, sv.Index)
+			v.Values = append(v.Values, sv.Value)
+		}
+		vectors[i] = v
+	}
+	return vectors, nil
+}
@@ -0,0 +1,60 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewSparseEmbedder(t *testing.T) {
+	if _, err := NewSparseEmbedder(Config{}); err == nil {
+		t.Error("expected error for empty provider")
+	}
+	if _, err := NewSparseEmbedder(Config{Provider: "nope"}); err == nil {
+		t.Error("expected error for unknown provider")
+	}
+	for _, name := range []string{"tei", "custom"} {
+		if _, err := NewSparseEmbedder(Config{Provider: name}); err != nil {
+			t.Errorf("NewSparseEmbedder(%q): %v", name, err)
+		}
+	}
+}
+
+func TestTEISparseEmbed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/embed_sparse" {
+			t.Errorf("path = %q, want /embed_sparse", r.URL.Path)
+		}
+		var req teiSparseRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("decoding request: %v", err)
+		}
+		if len(req.Inputs) != 2 {
+			t.Errorf("inputs = %d, want 2", len(req.Inputs))
+		}
+		w.Write([]byte(`[[{"index":5,"value":1.5},{"index":9,"value":0}],[{"index":7,"value":0.25}]]`))
+	}))
+	defer srv.Close()
+
+	sp, err := NewSparseEmbedder(Config{Provider: "tei", BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("NewSparseEmbedder: %v", err)
+	}
+
+	vecs, err := sp.EmbedSparse(context.Background(), []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("EmbedSparse: %v", err)
+	}
+	if len(vecs) != 2 {
+		t.Fatalf("got %d vectors, want 2", len(vecs))
+	}
+	// Zero-weight terms are dropped.
+	if len(vecs[0].Indices) != 1 || vecs[0].Indices[0] != 5 || vecs[0].Values[0] != 1.5 {
+		t.Errorf("vector 0 = %+v, want {[5] [1.5]}", vecs[0])
+	}
+	if len(vecs[1].Indices) != 1 || vecs[1].Indices[0] != 7 {
+		t.Errorf("vector 1 = %+v, want {[7] [0.25]}", vecs[1])
+	}
+}
@@ -0,0 +1,190 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// StreamingProvider extends Provider with streamed chat completions, so
+// callers can inspect a reply while it is generated and stop it early.
+type StreamingProvider interface {
+	Provider
+	// ChatStream sends a chat request and calls onDelta with each piece of
+	// the reply's content as it arrives. When onDelta returns an error the
+	// request is cancelled and ChatStream returns that error. Otherwise it
+	// returns the complete reply, as Chat does. Requests with tools, and
+	// endpoints that do not stream, get one call with the whole content.
+	ChatStream(ctx context.Context, req ChatRequest, onDelta func(delta string) error) (*ChatResponse, error)
+}
+
+type chatStreamRequest struct {
+	chatCompletionRequest
+	Stream        bool           `json:"stream"`
+	StreamOptions *streamOptions `json:"stream_options,omitempty"`
+}
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// chatCompletionChunk is one server-sent event of a streamed completion.
+// With stream_options.include_usage the last one carries the usage and no
+// choices.
+type chatCompletionChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Model string `json:"model"`
+	Usage *struct {
+		PromptTokens        int `json:"prompt_tokens"`
+		CompletionTokens    int `json:"completion_tokens"`
+		TotalTokens         int `json:"total_tokens"`
+		PromptTokensDetails struct {
+			CachedTokens int `json:"cached_tokens"`
+		} `json:"prompt_tokens_details"`
+	} `json:"usage"`
+}
+
+// chatStream is chat streamed through onDelta (see StreamingProvider).
+// The request is not retried once streaming started; when it cannot
+// start, such as on a 429 or an endpoint rejecting stream options, it
+// falls back to chat and its retries.
+func (c *openAICompatClient) chatStream(ctx context.Context, req ChatRequest, onDelta func(string) error) (*ChatResponse, error) {
+	if len(req.Tools) > 0 {
+		return c.chatWhole(ctx, req, onDelta)
+	}
+	body, style, err := c.chatBody(req, nil)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(chatStreamRequest{
+		chatCompletionRequest: body,
+		Stream:                true,
+		StreamOptions:         &streamOptions{IncludeUsage: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.cfg.BaseURL+c.pathPrefix+"/chat/completions", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream")
+	if c.cfg.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.cfg.APIKey)
+	}
+	setRequestID(httpReq)
+
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return c.chatWhole(ctx, req, onDelta)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return c.chatWhole(ctx, req, onDelta)
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		// The endpoint ignored "stream" and sent the whole reply.
+		respBody, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("reading response body: %w", err)
+		}
+		var whole chatCompletionResponse
+		if err := json.Unmarshal(respBody, &whole); err != nil {
+			return nil, fmt.Errorf("decoding chat response: %w", err)
+		}
+		out, err := whole.toChatResponse()
+		if err != nil {
+			return nil, err
+		}
+		return streamed(out, style, onDelta)
+	}
+
+	out := &ChatResponse{}
+	var content strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		payload, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "data:")
+		if !ok {
+			continue
+		}
+		payload = strings.TrimSpace(payload)
+		if payload == "[DONE]" {
+			break
+		}
+		var chunk chatCompletionChunk
+		if err := json.Unmarshal([]byte(payload), &chunk); err != nil {
+			return nil, fmt.Errorf("decoding chat stream: %w", err)
+		}
+		if chunk.Model != "" {
+			out.Model = chunk.Model
+		}
+		if u := chunk.Usage; u != nil {
+			out.PromptTokens = u.PromptTokens
+			out.CompletionTokens = u.CompletionTokens
+			out.TotalTokens = u.TotalTokens
+			out.CachedTokens = u.PromptTokensDetails.CachedTokens
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		choice := chunk.Choices[0]
+		if choice.FinishReason != "" {
+			out.FinishReason = choice.FinishReason
+		}
+		if delta := choice.Delta.Content; delta != "" {
+			content.WriteString(delta)
+			if err := onDelta(delta); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading chat stream: %w", err)
+	}
+	out.Content = content.String()
+	if style == PromptStyleReasoning {
+		out.Content = StripThinking(out.Content)
+	}
+	return out, nil
+}
+
+// chatWhole is the chat fallback of chatStream.
+func (c *openAICompatClient) chatWhole(ctx context.Context, req ChatRequest, onDelta func(string) error) (*ChatResponse, error) {
+	out, err := c.chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return streamed(out, "", onDelta)
+}
+
+// streamed hands a whole reply to onDelta, stripping the thinking of
+// reasoning models afterwards as a stream would.
+func streamed(out *ChatResponse, style string, onDelta func(string) error) (*ChatResponse, error) {
+	if out.Content != "" {
+		if err := onDelta(out.Content); err != nil {
+			return nil, err
+		}
+	}
+	if style == PromptStyleReasoning {
+		out.Content = StripThinking(out.Content)
+	}
+	return out, nil
+}
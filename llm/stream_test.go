@@ -0,0 +1,61 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bbiangul/go-reason/llm/llmtest"
+)
+
+func TestChatStream(t *testing.T) {
+	fastRetries(t)
+	p, srv := faultProvider(t, llmtest.Rule{Fault: llmtest.FaultRateLimit, Match: "retry", Times: 1})
+	srv.ChatFunc = func(string) string { return "The relief valve opens at 10 bar." }
+	sp, ok := p.(StreamingProvider)
+	if !ok {
+		t.Fatal("custom provider does not stream")
+	}
+	req := ChatRequest{Messages: []Message{{Role: "user", Content: "When does it open?"}}}
+
+	var deltas []string
+	resp, err := sp.ChatStream(context.Background(), req, func(d string) error {
+		deltas = append(deltas, d)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ChatStream: %v", err)
+	}
+	if resp.Content != "The relief valve opens at 10 bar." || len(deltas) < 2 || strings.Join(deltas, "") != resp.Content {
+		t.Errorf("content %q from deltas %q", resp.Content, deltas)
+	}
+	if resp.TotalTokens != 15 || resp.FinishReason != "stop" {
+		t.Errorf("usage %d, finish %q", resp.TotalTokens, resp.FinishReason)
+	}
+
+	// An error from onDelta stops the stream.
+	stop := errors.New("drifting")
+	var seen string
+	_, err = sp.ChatStream(context.Background(), req, func(d string) error {
+		seen += d
+		if strings.Contains(seen, "valve") {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) || strings.Contains(seen, "bar") {
+		t.Errorf("ChatStream = %v after %q, want the onDelta error before the end", err, seen)
+	}
+
+	// A stream that cannot start falls back to Chat and its retries.
+	req.Messages[0].Content = "retry please"
+	deltas = nil
+	resp, err = sp.ChatStream(context.Background(), req, func(d string) error {
+		deltas = append(deltas, d)
+		return nil
+	})
+	if err != nil || len(deltas) != 1 || deltas[0] != resp.Content {
+		t.Errorf("fallback = %+v, %v with deltas %q", resp, err, deltas)
+	}
+}
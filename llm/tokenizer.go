@@ -0,0 +1,497 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// hfTokenizer implements the subset of the HuggingFace tokenizers format
+// (tokenizer.json) that sentence embedding models use: the WordPiece models
+// of BERT-style encoders (bge, MiniLM) and the Unigram models of
+// XLM-R-style encoders (multilingual-e5).
+type hfTokenizer struct {
+	lowercase    bool
+	stripAccents bool
+	nfkc         bool // NFKC, standing in for a Precompiled normalizer
+	metaspace    bool // Metaspace pre-tokenizer; BERT splitting otherwise
+
+	// WordPiece model.
+	vocab         map[string]int64
+	subwordPrefix string
+	maxWordChars  int
+
+	// Unigram model.
+	pieces      map[string]unigramPiece
+	maxPieceLen int // in runes
+	unkScore    float64
+
+	unkID  int64
+	prefix []int64 // special tokens before the text, e.g. [CLS] or <s>
+	suffix []int64 // special tokens after it, e.g. [SEP] or </s>
+	padID  int64
+	maxLen int // including the special tokens
+}
+
+type unigramPiece struct {
+	id    int64
+	score float64
+}
+
+// defaultMaxTokens is the input length of BERT and XLM-R encoders, used
+// when tokenizer.json sets no truncation.
+const defaultMaxTokens = 512
+
+type tokenizerFile struct {
+	Truncation *struct {
+		MaxLength int `json:"max_length"`
+	} `json:"truncation"`
+	Padding *struct {
+		PadID int64 `json:"pad_id"`
+	} `json:"padding"`
+	AddedTokens []struct {
+		ID      int64  `json:"id"`
+		Content string `json:"content"`
+	} `json:"added_tokens"`
+	Normalizer    *tokenizerNormalizer    `json:"normalizer"`
+	PreTokenizer  *tokenizerPreTokenizer  `json:"pre_tokenizer"`
+	PostProcessor *tokenizerPostProcessor `json:"post_processor"`
+	Model         struct {
+		Type                    string          `json:"type"`
+		UnkToken                string          `json:"unk_token"`
+		UnkID                   *int64          `json:"unk_id"`
+		ContinuingSubwordPrefix string          `json:"continuing_subword_prefix"`
+		MaxInputCharsPerWord    int             `json:"max_input_chars_per_word"`
+		Vocab                   json.RawMessage `json:"vocab"`
+	} `json:"model"`
+}
+
+type tokenizerNormalizer struct {
+	Type         string                `json:"type"`
+	Lowercase    bool                  `json:"lowercase"`
+	StripAccents *bool                 `json:"strip_accents"`
+	Normalizers  []tokenizerNormalizer `json:"normalizers"`
+}
+
+type tokenizerPreTokenizer struct {
+	Type          string                  `json:"type"`
+	PreTokenizers []tokenizerPreTokenizer `json:"pretokenizers"`
+}
+
+type tokenizerPostProcessor struct {
+	Type string `json:"type"`
+	// BertProcessing and RobertaProcessing: ["[CLS]", 101].
+	CLS []any `json:"cls"`
+	SEP []any `json:"sep"`
+	// TemplateProcessing.
+	Single []struct {
+		SpecialToken *struct {
+			ID string `json:"id"`
+		} `json:"SpecialToken"`
+		Sequence *struct {
+			ID string `json:"id"`
+		} `json:"Sequence"`
+	} `json:"single"`
+	SpecialTokens map[string]struct {
+		IDs []int64 `json:"ids"`
+	} `json:"special_tokens"`
+	// Sequence.
+	Processors []tokenizerPostProcessor `json:"processors"`
+}
+
+// Tokenizer counts tokens as a HuggingFace tokenizer.json does, e.g. the
+// one shipped with the embedding model, so chunks can be sized to its
+// input window. Only WordPiece and Unigram models are supported.
+type Tokenizer struct {
+	t *hfTokenizer
+}
+
+// LoadTokenizer reads a tokenizer.json file.
+func LoadTokenizer(path string) (*Tokenizer, error) {
+	t, err := loadTokenizer(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Tokenizer{t: t}, nil
+}
+
+// CountTokens returns the number of tokens in text, without the model's
+// special tokens and without truncating to its input length.
+func (t *Tokenizer) CountTokens(text string) int {
+	return t.t.count(text)
+}
+
+// loadTokenizer reads a tokenizer.json file.
+func loadTokenizer(path string) (*hfTokenizer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading tokenizer: %w", err)
+	}
+	return parseTokenizer(data)
+}
+
+// parseTokenizer builds a tokenizer from the contents of a tokenizer.json.
+func parseTokenizer(data []byte) (*hfTokenizer, error) {
+	var f tokenizerFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("decoding tokenizer: %w", err)
+	}
+
+	t := &hfTokenizer{maxLen: defaultMaxTokens}
+	if f.Truncation != nil && f.Truncation.MaxLength > 0 {
+		t.maxLen = f.Truncation.MaxLength
+	}
+	if f.Normalizer != nil {
+		t.applyNormalizer(*f.Normalizer)
+	}
+	if f.PreTokenizer != nil {
+		t.applyPreTokenizer(*f.PreTokenizer)
+	}
+
+	switch f.Model.Type {
+	case "WordPiece":
+		if err := json.Unmarshal(f.Model.Vocab, &t.vocab); err != nil {
+			return nil, fmt.Errorf("decoding wordpiece vocab: %w", err)
+		}
+		t.subwordPrefix = f.Model.ContinuingSubwordPrefix
+		if t.subwordPrefix == "" {
+			t.subwordPrefix = "##"
+		}
+		t.maxWordChars = f.Model.MaxInputCharsPerWord
+		if t.maxWordChars == 0 {
+			t.maxWordChars = 100
+		}
+		unk, ok := t.vocab[f.Model.UnkToken]
+		if !ok {
+			return nil, fmt.Errorf("unknown token %q not in vocab", f.Model.UnkToken)
+		}
+		t.unkID = unk
+		if f.Normalizer == nil {
+			t.lowercase, t.stripAccents = true, true
+		}
+	case "Unigram":
+		var vocab [][2]any
+		if err := json.Unmarshal(f.Model.Vocab, &vocab); err != nil {
+			return nil, fmt.Errorf("decoding unigram vocab: %w", err)
+		}
+		if f.Model.UnkID == nil {
+			return nil, fmt.Errorf("unigram tokenizer has no unk_id")
+		}
+		t.unkID = *f.Model.UnkID
+		t.pieces = make(map[string]unigramPiece, len(vocab))
+		minScore := 0.0
+		for id, entry := range vocab {
+			piece, _ := entry[0].(string)
+			score, _ := entry[1].(float64)
+			t.pieces[piece] = unigramPiece{id: int64(id), score: score}
+			if n := len([]rune(piece)); n > t.maxPieceLen {
+				t.maxPieceLen = n
+			}
+			minScore = min(minScore, score)
+		}
+		// The penalty HuggingFace applies to unknown characters.
+		t.unkScore = minScore - 10
+	default:
+		return nil, fmt.Errorf("unsupported tokenizer model %q", f.Model.Type)
+	}
+
+	if f.PostProcessor != nil {
+		if err := t.applyPostProcessor(*f.PostProcessor); err != nil {
+			return nil, err
+		}
+	}
+	if f.Padding != nil {
+		t.padID = f.Padding.PadID
+	} else {
+		for _, at := range f.AddedTokens {
+			if at.Content == "[PAD]" || at.Content == "<pad>" {
+				t.padID = at.ID
+			}
+		}
+	}
+	if len(t.prefix)+len(t.suffix) >= t.maxLen {
+		return nil, fmt.Errorf("tokenizer max length %d leaves no room for text", t.maxLen)
+	}
+	return t, nil
+}
+
+func (t *hfTokenizer) applyNormalizer(n tokenizerNormalizer) {
+	switch n.Type {
+	case "BertNormalizer":
+		t.lowercase = n.Lowercase
+		// strip_accents defaults to following lowercase.
+		t.stripAccents = n.Lowercase
+		if n.StripAccents != nil {
+			t.stripAccents = *n.StripAccents
+		}
+	case "Lowercase":
+		t.lowercase = true
+	case "StripAccents":
+		t.stripAccents = true
+	case "NFKC", "Precompiled":
+		t.nfkc = true
+	case "Sequence":
+		for _, sub := range n.Normalizers {
+			t.applyNormalizer(sub)
+		}
+	}
+}
+
+func (t *hfTokenizer) applyPreTokenizer(p tokenizerPreTokenizer) {
+	switch p.Type {
+	case "Metaspace":
+		t.metaspace = true
+	case "Sequence":
+		for _, sub := range p.PreTokenizers {
+			t.applyPreTokenizer(sub)
+		}
+	}
+}
+
+func (t *hfTokenizer) applyPostProcessor(p tokenizerPostProcessor) error {
+	switch p.Type {
+	case "BertProcessing", "RobertaProcessing":
+		cls, err := specialTokenID(p.CLS)
+		if err != nil {
+			return err
+		}
+		sep, err := specialTokenID(p.SEP)
+		if err != nil {
+			return err
+		}
+		t.prefix, t.suffix = []int64{cls}, []int64{sep}
+	case "TemplateProcessing":
+		t.prefix, t.suffix = nil, nil
+		seen := false
+		for _, item := range p.Single {
+			switch {
+			case item.Sequence != nil:
+				seen = true
+			case item.SpecialToken != nil:
+				ids := p.SpecialTokens[item.SpecialToken.ID].IDs
+				if len(ids) == 0 {
+					return fmt.Errorf("template special token %q has no id", item.SpecialToken.ID)
+				}
+				if seen {
+					t.suffix = append(t.suffix, ids...)
+				} else {
+					t.prefix = append(t.prefix, ids...)
+				}
+			}
+		}
+	case "Sequence":
+		for _, sub := range p.Processors {
+			if err := t.applyPostProcessor(sub); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// specialTokenID returns the id of a ["token", id] pair.
+func specialTokenID(pair []any) (int64, error) {
+	if len(pair) != 2 {
+		return 0, fmt.Errorf("malformed special token %v", pair)
+	}
+	id, ok := pair[1].(float64)
+	if !ok {
+		return 0, fmt.Errorf("malformed special token %v", pair)
+	}
+	return int64(id), nil
+}
+
+// encode returns the token ids of text with the model's special tokens,
+// truncated to the model's input length.
+func (t *hfTokenizer) encode(text string) []int64 {
+	room := t.maxLen - len(t.prefix) - len(t.suffix)
+	ids := append([]int64(nil), t.prefix...)
+	n := 0
+	for _, word := range t.preTokenize(t.normalize(text)) {
+		var toks []int64
+		if t.pieces != nil {
+			toks = t.unigram(word)
+		} else {
+			toks = t.wordPiece(word)
+		}
+		if n+len(toks) > room {
+			toks = toks[:room-n]
+		}
+		ids = append(ids, toks...)
+		n += len(toks)
+		if n == room {
+			break
+		}
+	}
+	return append(ids, t.suffix...)
+}
+
+// count returns the number of tokens in text, without special tokens or
+// truncation.
+func (t *hfTokenizer) count(text string) int {
+	n := 0
+	for _, word := range t.preTokenize(t.normalize(text)) {
+		if t.pieces != nil {
+			n += len(t.unigram(word))
+		} else {
+			n += len(t.wordPiece(word))
+		}
+	}
+	return n
+}
+
+// normalize cleans control characters and applies the configured case,
+// accent, and Unicode normalization.
+func (t *hfTokenizer) normalize(text string) string {
+	if t.nfkc {
+		text = norm.NFKC.String(text)
+	}
+	if t.stripAccents {
+		text = norm.NFD.String(text)
+	}
+	var b strings.Builder
+	b.Grow(len(text))
+	for _, r := range text {
+		switch {
+		case r == 0 || r == unicode.ReplacementChar:
+			continue
+		case unicode.IsSpace(r):
+			b.WriteRune(' ')
+		case unicode.IsControl(r):
+			continue
+		case t.stripAccents && unicode.Is(unicode.Mn, r):
+			continue
+		case t.lowercase:
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// preTokenize splits normalized text into words: on whitespace with a
+// leading "▁" for Metaspace models, and on whitespace, punctuation, and
+// CJK characters for BERT models.
+func (t *hfTokenizer) preTokenize(text string) []string {
+	if t.metaspace {
+		words := strings.Fields(text)
+		for i, w := range words {
+			words[i] = "▁" + w
+		}
+		return words
+	}
+
+	var words []string
+	start := -1
+	for i, r := range text {
+		split := r == ' ' || isBertPunct(r) || unicode.Is(unicode.Han, r)
+		if !split {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			words = append(words, text[start:i])
+			start = -1
+		}
+		if r != ' ' {
+			words = append(words, string(r))
+		}
+	}
+	if start >= 0 {
+		words = append(words, text[start:])
+	}
+	return words
+}
+
+// isBertPunct matches BERT's punctuation: all non-alphanumeric ASCII
+// symbols plus Unicode punctuation.
+func isBertPunct(r rune) bool {
+	if (r >= 33 && r <= 47) || (r >= 58 && r <= 64) || (r >= 91 && r <= 96) || (r >= 123 && r <= 126) {
+		return true
+	}
+	return unicode.IsPunct(r)
+}
+
+// wordPiece splits a word greedily into the longest vocabulary pieces. A
+// word that cannot be split becomes a single unknown token.
+func (t *hfTokenizer) wordPiece(word string) []int64 {
+	runes := []rune(word)
+	if len(runes) > t.maxWordChars {
+		return []int64{t.unkID}
+	}
+	var ids []int64
+	for start := 0; start < len(runes); {
+		end := len(runes)
+		var id int64 = -1
+		for ; end > start; end-- {
+			piece := string(runes[start:end])
+			if start > 0 {
+				piece = t.subwordPrefix + piece
+			}
+			if v, ok := t.vocab[piece]; ok {
+				id = v
+				break
+			}
+		}
+		if id < 0 {
+			return []int64{t.unkID}
+		}
+		ids = append(ids, id)
+		start = end
+	}
+	return ids
+}
+
+// unigram segments a word into the pieces with the highest total score
+// (Viterbi). Characters no piece covers become unknown tokens.
+func (t *hfTokenizer) unigram(word string) []int64 {
+	runes := []rune(word)
+	n := len(runes)
+	type node struct {
+		score float64
+		start int
+		id    int64
+		ok    bool
+	}
+	best := make([]node, n+1)
+	best[0].ok = true
+	for i := 0; i < n; i++ {
+		if !best[i].ok {
+			continue
+		}
+		covered := false
+		for j := i + 1; j <= n && j-i <= t.maxPieceLen; j++ {
+			p, ok := t.pieces[string(runes[i:j])]
+			if !ok {
+				continue
+			}
+			if j == i+1 {
+				covered = true
+			}
+			if s := best[i].score + p.score; !best[j].ok || s > best[j].score {
+				best[j] = node{score: s, start: i, id: p.id, ok: true}
+			}
+		}
+		if !covered {
+			if s := best[i].score + t.unkScore; !best[i+1].ok || s > best[i+1].score {
+				best[i+1] = node{score: s, start: i, id: t.unkID, ok: true}
+			}
+		}
+	}
+
+	var ids []int64
+	for i := n; i > 0; i = best[i].start {
+		ids = append(ids, best[i].id)
+	}
+	for l, r := 0, len(ids)-1; l < r; l, r = l+1, r-1 {
+		ids[l], ids[r] = ids[r], ids[l]
+	}
+	return ids
+}
@@ -0,0 +1,464 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Tool describes a function the model may call. Parameters is a JSON Schema
+// object describing the arguments; it is passed through to every provider
+// unchanged.
+type Tool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// ToolCall is a model's request to invoke a tool. Arguments holds the raw
+// JSON object of arguments.
+type ToolCall struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// ToolFormat identifies a provider wire format for tool calling.
+type ToolFormat string
+
+const (
+	// ToolFormatOpenAI is the chat completions "tools"/"tool_calls" format,
+	// used by every OpenAI-compatible provider in this package (including
+	// Gemini's OpenAI endpoint).
+	ToolFormatOpenAI ToolFormat = "openai"
+	// ToolFormatAnthropic is the Messages API "tools"/"tool_use" format.
+	ToolFormatAnthropic ToolFormat = "anthropic"
+	// ToolFormatGemini is the native generateContent
+	// "functionDeclarations"/"functionCall" format.
+	ToolFormatGemini ToolFormat = "gemini"
+)
+
+// EncodeChatRequest translates a provider-agnostic chat request, including
+// tools and any tool-call history in its messages, into the request body
+// for the given wire format. The model field is omitted for Gemini, which
+// takes the model in the URL.
+func EncodeChatRequest(format ToolFormat, req ChatRequest) (map[string]interface{}, error) {
+	switch format {
+	case ToolFormatOpenAI:
+		body := map[string]interface{}{
+			"model":    req.Model,
+			"messages": openAIMessages(req.Messages),
+		}
+		if req.Temperature != 0 {
+			body["temperature"] = req.Temperature
+		}
+		if req.MaxTokens > 0 {
+			body["max_tokens"] = req.MaxTokens
+		}
+		if len(req.Tools) > 0 {
+			body["tools"] = openAITools(req.Tools)
+			if tc := openAIToolChoice(req.ToolChoice); tc != nil {
+				body["tool_choice"] = tc
+			}
+		}
+		return body, nil
+
+	case ToolFormatAnthropic:
+		system, msgs, err := anthropicMessages(req.Messages)
+		if err != nil {
+			return nil, err
+		}
+		maxTokens := req.MaxTokens
+		if maxTokens <= 0 {
+			maxTokens = 4096 // required by the Messages API
+		}
+		body := map[string]interface{}{
+			"model":      req.Model,
+			"messages":   msgs,
+			"max_tokens": maxTokens,
+		}
+		if system != "" {
+			body["system"] = system
+		}
+		if req.Temperature != 0 {
+			body["temperature"] = req.Temperature
+		}
+		if len(req.Tools) > 0 {
+			tools := make([]map[string]interface{}, len(req.Tools))
+			for i, t := range req.Tools {
+				tools[i] = map[string]interface{}{
+					"name":         t.Name,
+					"description":  t.Description,
+					"input_schema": toolSchema(t),
+				}
+			}
+			body["tools"] = tools
+			switch req.ToolChoice {
+			case "", "auto":
+			case "none":
+				body["tool_choice"] = map[string]interface{}{"type": "none"}
+			case "required":
+				body["tool_choice"] = map[string]interface{}{"type": "any"}
+			default:
+				body["tool_choice"] = map[string]interface{}{"type": "tool", "name": req.ToolChoice}
+			}
+		}
+		return body, nil
+
+	case ToolFormatGemini:
+		system, contents, err := geminiContents(req.Messages)
+		if err != nil {
+			return nil, err
+		}
+		body := map[string]interface{}{"contents": contents}
+		if system != "" {
+			body["systemInstruction"] = map[string]interface{}{
+				"parts": []map[string]interface{}{{"text": system}},
+			}
+		}
+		gen := map[string]interface{}{}
+		if req.Temperature != 0 {
+			gen["temperature"] = req.Temperature
+		}
+		if req.MaxTokens > 0 {
+			gen["maxOutputTokens"] = req.MaxTokens
+		}
+		if len(gen) > 0 {
+			body["generationConfig"] = gen
+		}
+		if len(req.Tools) > 0 {
+			decls := make([]map[string]interface{}, len(req.Tools))
+			for i, t := range req.Tools {
+				decls[i] = map[string]interface{}{
+					"name":        t.Name,
+					"description": t.Description,
+					"parameters":  toolSchema(t),
+				}
+			}
+			body["tools"] = []map[string]interface{}{{"functionDeclarations": decls}}
+			cfg := map[string]interface{}{}
+			switch req.ToolChoice {
+			case "", "auto":
+				cfg["mode"] = "AUTO"
+			case "none":
+				cfg["mode"] = "NONE"
+			case "required":
+				cfg["mode"] = "ANY"
+			default:
+				cfg["mode"] = "ANY"
+				cfg["allowedFunctionNames"] = []string{req.ToolChoice}
+			}
+			body["toolConfig"] = map[string]interface{}{"functionCallingConfig": cfg}
+		}
+		return body, nil
+
+	default:
+		return nil, fmt.Errorf("unknown tool format: %s", format)
+	}
+}
+
+// DecodeChatResponse parses a raw response body in the given wire format
+// into a ChatResponse, collecting any tool calls the model made.
+func DecodeChatResponse(format ToolFormat, body []byte) (*ChatResponse, error) {
+	switch format {
+	case ToolFormatOpenAI:
+		var resp chatCompletionResponse
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return nil, fmt.Errorf("decoding chat response: %w", err)
+		}
+		return resp.toChatResponse()
+
+	case ToolFormatAnthropic:
+		var resp struct {
+			Model      string `json:"model"`
+			StopReason string `json:"stop_reason"`
+			Content    []struct {
+				Type  string          `json:"type"`
+				Text  string          `json:"text"`
+				ID    string          `json:"id"`
+				Name  string          `json:"name"`
+				Input json.RawMessage `json:"input"`
+			} `json:"content"`
+			Usage struct {
+				InputTokens  int `json:"input_tokens"`
+				OutputTokens int `json:"output_tokens"`
+			} `json:"usage"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return nil, fmt.Errorf("decoding anthropic response: %w", err)
+		}
+		out := &ChatResponse{
+			Model:            resp.Model,
+			FinishReason:     resp.StopReason,
+			PromptTokens:     resp.Usage.InputTokens,
+			CompletionTokens: resp.Usage.OutputTokens,
+			TotalTokens:      resp.Usage.InputTokens + resp.Usage.OutputTokens,
+		}
+		var text strings.Builder
+		for _, block := range resp.Content {
+			switch block.Type {
+			case "text":
+				text.WriteString(block.Text)
+			case "tool_use":
+				out.ToolCalls = append(out.ToolCalls, ToolCall{
+					ID:        block.ID,
+					Name:      block.Name,
+					Arguments: rawArgs(block.Input),
+				})
+			}
+		}
+		out.Content = text.String()
+		return out, nil
+
+	case ToolFormatGemini:
+		var resp struct {
+			ModelVersion string `json:"modelVersion"`
+			Candidates   []struct {
+				FinishReason string `json:"finishReason"`
+				Content      struct {
+					Parts []struct {
+						Text         string `json:"text"`
+						FunctionCall *struct {
+							Name string          `json:"name"`
+							Args json.RawMessage `json:"args"`
+						} `json:"functionCall"`
+					} `json:"parts"`
+				} `json:"content"`
+			} `json:"candidates"`
+			UsageMetadata struct {
+				PromptTokenCount     int `json:"promptTokenCount"`
+				CandidatesTokenCount int `json:"candidatesTokenCount"`
+				TotalTokenCount      int `json:"totalTokenCount"`
+			} `json:"usageMetadata"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return nil, fmt.Errorf("decoding gemini response: %w", err)
+		}
+		if len(resp.Candidates) == 0 {
+			return nil, fmt.Errorf("no candidates in response")
+		}
+		cand := resp.Candidates[0]
+		out := &ChatResponse{
+			Model:            resp.ModelVersion,
+			FinishReason:     cand.FinishReason,
+			PromptTokens:     resp.UsageMetadata.PromptTokenCount,
+			CompletionTokens: resp.UsageMetadata.CandidatesTokenCount,
+			TotalTokens:      resp.UsageMetadata.TotalTokenCount,
+		}
+		var text strings.Builder
+		for _, part := range cand.Content.Parts {
+			if part.FunctionCall != nil {
+				// Gemini has no call IDs; the function name is what a
+				// functionResponse part is matched against.
+				out.ToolCalls = append(out.ToolCalls, ToolCall{
+					ID:        part.FunctionCall.Name,
+					Name:      part.FunctionCall.Name,
+					Arguments: rawArgs(part.FunctionCall.Args),
+				})
+				continue
+			}
+			text.WriteString(part.Text)
+		}
+		out.Content = text.String()
+		return out, nil
+
+	default:
+		return nil, fmt.Errorf("unknown tool format: %s", format)
+	}
+}
+
+// --- OpenAI ---
+
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    string           `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"` // JSON-encoded string, not an object
+	} `json:"function"`
+}
+
+func openAIMessages(msgs []Message) []openAIMessage {
+	out := make([]openAIMessage, len(msgs))
+	for i, m := range msgs {
+		out[i] = openAIMessage{Role: m.Role, Content: m.Content, ToolCallID: m.ToolCallID}
+		for _, tc := range m.ToolCalls {
+			var call openAIToolCall
+			call.ID = tc.ID
+			call.Type = "function"
+			call.Function.Name = tc.Name
+			call.Function.Arguments = string(rawArgs(tc.Arguments))
+			out[i].ToolCalls = append(out[i].ToolCalls, call)
+		}
+	}
+	return out
+}
+
+func openAITools(tools []Tool) []map[string]interface{} {
+	out := make([]map[string]interface{}, len(tools))
+	for i, t := range tools {
+		out[i] = map[string]interface{}{
+			"type": "function",
+			"function": map[string]interface{}{
+				"name":        t.Name,
+				"description": t.Description,
+				"parameters":  toolSchema(t),
+			},
+		}
+	}
+	return out
+}
+
+func openAIToolChoice(choice string) interface{} {
+	switch choice {
+	case "":
+		return nil
+	case "auto", "none", "required":
+		return choice
+	default:
+		return map[string]interface{}{
+			"type":     "function",
+			"function": map[string]string{"name": choice},
+		}
+	}
+}
+
+// --- Anthropic ---
+
+// anthropicMessages converts messages to the Messages API shape. System
+// messages are lifted into the top-level system prompt; assistant tool calls
+// become tool_use blocks; consecutive "tool" messages are merged into one
+// user turn of tool_result blocks, as the API requires alternating roles.
+func anthropicMessages(msgs []Message) (string, []map[string]interface{}, error) {
+	var system []string
+	var out []map[string]interface{}
+	for _, m := range msgs {
+		switch m.Role {
+		case "system":
+			system = append(system, m.Content)
+		case "tool":
+			if m.ToolCallID == "" {
+				return "", nil, fmt.Errorf("tool message without tool_call_id")
+			}
+			block := map[string]interface{}{
+				"type":        "tool_result",
+				"tool_use_id": m.ToolCallID,
+				"content":     m.Content,
+			}
+			if n := len(out); n > 0 && out[n-1]["role"] == "user" {
+				if blocks, ok := out[n-1]["content"].([]map[string]interface{}); ok {
+					out[n-1]["content"] = append(blocks, block)
+					continue
+				}
+			}
+			out = append(out, map[string]interface{}{
+				"role":    "user",
+				"content": []map[string]interface{}{block},
+			})
+		case "assistant":
+			if len(m.ToolCalls) == 0 {
+				out = append(out, map[string]interface{}{"role": "assistant", "content": m.Content})
+				continue
+			}
+			var blocks []map[string]interface{}
+			if m.Content != "" {
+				blocks = append(blocks, map[string]interface{}{"type": "text", "text": m.Content})
+			}
+			for _, tc := range m.ToolCalls {
+				blocks = append(blocks, map[string]interface{}{
+					"type":  "tool_use",
+					"id":    tc.ID,
+					"name":  tc.Name,
+					"input": rawArgs(tc.Arguments),
+				})
+			}
+			out = append(out, map[string]interface{}{"role": "assistant", "content": blocks})
+		default:
+			out = append(out, map[string]interface{}{"role": "user", "content": m.Content})
+		}
+	}
+	return strings.Join(system, "\n\n"), out, nil
+}
+
+// --- Gemini ---
+
+// geminiContents converts messages to generateContent "contents". Tool
+// results become functionResponse parts keyed by function name, which is
+// how Gemini pairs them with the preceding functionCall.
+func geminiContents(msgs []Message) (string, []map[string]interface{}, error) {
+	var system []string
+	var out []map[string]interface{}
+	for _, m := range msgs {
+		switch m.Role {
+		case "system":
+			system = append(system, m.Content)
+		case "tool":
+			if m.ToolCallID == "" {
+				return "", nil, fmt.Errorf("tool message without tool_call_id")
+			}
+			var resp interface{}
+			if err := json.Unmarshal([]byte(m.Content), &resp); err != nil {
+				resp = map[string]interface{}{"result": m.Content}
+			} else if _, ok := resp.(map[string]interface{}); !ok {
+				resp = map[string]interface{}{"result": resp}
+			}
+			out = append(out, map[string]interface{}{
+				"role": "user",
+				"parts": []map[string]interface{}{{
+					"functionResponse": map[string]interface{}{
+						"name":     m.ToolCallID,
+						"response": resp,
+					},
+				}},
+			})
+		case "assistant":
+			var parts []map[string]interface{}
+			if m.Content != "" {
+				parts = append(parts, map[string]interface{}{"text": m.Content})
+			}
+			for _, tc := range m.ToolCalls {
+				parts = append(parts, map[string]interface{}{
+					"functionCall": map[string]interface{}{
+						"name": tc.Name,
+						"args": rawArgs(tc.Arguments),
+					},
+				})
+			}
+			out = append(out, map[string]interface{}{"role": "model", "parts": parts})
+		default:
+			out = append(out, map[string]interface{}{
+				"role":  "user",
+				"parts": []map[string]interface{}{{"text": m.Content}},
+			})
+		}
+	}
+	return strings.Join(system, "\n\n"), out, nil
+}
+
+// --- helpers ---
+
+// toolSchema returns the tool's parameter schema, defaulting to an empty
+// object schema since every provider requires one.
+func toolSchema(t Tool) map[string]interface{} {
+	if t.Parameters != nil {
+		return t.Parameters
+	}
+	return map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+}
+
+// rawArgs normalises tool arguments to a JSON object, treating empty or
+// null arguments as {}.
+func rawArgs(args json.RawMessage) json.RawMessage {
+	trimmed := strings.TrimSpace(string(args))
+	if trimmed == "" || trimmed == "null" {
+		return json.RawMessage("{}")
+	}
+	return args
+}
@@ -0,0 +1,213 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var searchTool = Tool{
+	Name:        "search",
+	Description: "Search the knowledge base",
+	Parameters: map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"query": map[string]interface{}{"type": "string"},
+		},
+		"required": []string{"query"},
+	},
+}
+
+// toolLoopMessages is a conversation that has already made one tool call.
+var toolLoopMessages = []Message{
+	{Role: "system", Content: "Be precise."},
+	{Role: "user", Content: "What is the torque?"},
+	{Role: "assistant", ToolCalls: []ToolCall{
+		{ID: "call_1", Name: "search", Arguments: json.RawMessage(`{"query":"torque"}`)},
+	}},
+	{Role: "tool", ToolCallID: "call_1", Content: `{"hits":1}`},
+}
+
+// roundTrip marshals v and unmarshals it into a generic value so tests can
+// inspect the wire shape without caring about Go types.
+func roundTrip(t *testing.T, v interface{}) map[string]interface{} {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	return out
+}
+
+func TestEncodeChatRequestOpenAI(t *testing.T) {
+	body, err := EncodeChatRequest(ToolFormatOpenAI, ChatRequest{
+		Model: "gpt", Messages: toolLoopMessages, Tools: []Tool{searchTool}, ToolChoice: "search",
+	})
+	if err != nil {
+		t.Fatalf("EncodeChatRequest: %v", err)
+	}
+	wire := roundTrip(t, body)
+
+	tool := wire["tools"].([]interface{})[0].(map[string]interface{})
+	if tool["type"] != "function" || tool["function"].(map[string]interface{})["name"] != "search" {
+		t.Errorf("unexpected tool encoding: %v", tool)
+	}
+	choice := wire["tool_choice"].(map[string]interface{})
+	if choice["function"].(map[string]interface{})["name"] != "search" {
+		t.Errorf("unexpected tool_choice: %v", choice)
+	}
+
+	msgs := wire["messages"].([]interface{})
+	call := msgs[2].(map[string]interface{})["tool_calls"].([]interface{})[0].(map[string]interface{})
+	args := call["function"].(map[string]interface{})["arguments"]
+	if args != `{"query":"torque"}` {
+		t.Errorf("arguments must be a JSON string, got %#v", args)
+	}
+	if msgs[3].(map[string]interface{})["tool_call_id"] != "call_1" {
+		t.Errorf("tool result missing tool_call_id: %v", msgs[3])
+	}
+}
+
+func TestEncodeChatRequestAnthropic(t *testing.T) {
+	msgs := append(append([]Message{}, toolLoopMessages...), Message{Role: "tool", ToolCallID: "call_2", Content: "second"})
+	body, err := EncodeChatRequest(ToolFormatAnthropic, ChatRequest{
+		Model: "claude", Messages: msgs, Tools: []Tool{searchTool}, ToolChoice: "required",
+	})
+	if err != nil {
+		t.Fatalf("EncodeChatRequest: %v", err)
+	}
+	wire := roundTrip(t, body)
+
+	if wire["system"] != "Be precise." {
+		t.Errorf("system = %v", wire["system"])
+	}
+	if wire["max_tokens"] == nil {
+		t.Error("max_tokens is required by the Messages API")
+	}
+	tool := wire["tools"].([]interface{})[0].(map[string]interface{})
+	if tool["input_schema"] == nil {
+		t.Errorf("expected input_schema, got %v", tool)
+	}
+	if wire["tool_choice"].(map[string]interface{})["type"] != "any" {
+		t.Errorf("tool_choice = %v", wire["tool_choice"])
+	}
+
+	turns := wire["messages"].([]interface{})
+	if len(turns) != 3 {
+		t.Fatalf("expected user, assistant, user turns, got %d", len(turns))
+	}
+	use := turns[1].(map[string]interface{})["content"].([]interface{})[0].(map[string]interface{})
+	if use["type"] != "tool_use" || use["input"].(map[string]interface{})["query"] != "torque" {
+		t.Errorf("unexpected tool_use block: %v", use)
+	}
+	results := turns[2].(map[string]interface{})["content"].([]interface{})
+	if len(results) != 2 {
+		t.Errorf("consecutive tool results must share one user turn, got %d blocks", len(results))
+	}
+
+	if _, err := EncodeChatRequest(ToolFormatAnthropic, ChatRequest{
+		Messages: []Message{{Role: "tool", Content: "x"}},
+	}); err == nil {
+		t.Error("expected error for tool message without tool_call_id")
+	}
+}
+
+func TestEncodeChatRequestGemini(t *testing.T) {
+	body, err := EncodeChatRequest(ToolFormatGemini, ChatRequest{
+		Messages: toolLoopMessages, Tools: []Tool{searchTool}, MaxTokens: 100,
+	})
+	if err != nil {
+		t.Fatalf("EncodeChatRequest: %v", err)
+	}
+	wire := roundTrip(t, body)
+
+	decls := wire["tools"].([]interface{})[0].(map[string]interface{})["functionDeclarations"].([]interface{})
+	if decls[0].(map[string]interface{})["name"] != "search" {
+		t.Errorf("unexpected declarations: %v", decls)
+	}
+	mode := wire["toolConfig"].(map[string]interface{})["functionCallingConfig"].(map[string]interface{})["mode"]
+	if mode != "AUTO" {
+		t.Errorf("mode = %v, want AUTO", mode)
+	}
+
+	contents := wire["contents"].([]interface{})
+	if contents[1].(map[string]interface{})["role"] != "model" {
+		t.Errorf("assistant turn must use role model: %v", contents[1])
+	}
+	part := contents[2].(map[string]interface{})["parts"].([]interface{})[0].(map[string]interface{})
+	fr := part["functionResponse"].(map[string]interface{})
+	if fr["response"].(map[string]interface{})["hits"] != float64(1) {
+		t.Errorf("unexpected functionResponse: %v", fr)
+	}
+}
+
+func TestDecodeChatResponse(t *testing.T) {
+	tests := []struct {
+		name   string
+		format ToolFormat
+		body   string
+	}{
+		{"openai", ToolFormatOpenAI, `{"model":"m","choices":[{"finish_reason":"tool_calls","message":{"content":"","tool_calls":[
+			{"id":"c1","type":"function","function":{"name":"search","arguments":"{\"query\":\"torque\"}"}}]}}]}`},
+		{"anthropic", ToolFormatAnthropic, `{"model":"m","stop_reason":"tool_use","content":[
+			{"type":"text","text":"Looking."},{"type":"tool_use","id":"c1","name":"search","input":{"query":"torque"}}]}`},
+		{"gemini", ToolFormatGemini, `{"candidates":[{"finishReason":"STOP","content":{"parts":[
+			{"functionCall":{"name":"search","args":{"query":"torque"}}}]}}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := DecodeChatResponse(tt.format, []byte(tt.body))
+			if err != nil {
+				t.Fatalf("DecodeChatResponse: %v", err)
+			}
+			if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Name != "search" {
+				t.Fatalf("tool calls = %+v", resp.ToolCalls)
+			}
+			var args struct{ Query string }
+			if err := json.Unmarshal(resp.ToolCalls[0].Arguments, &args); err != nil || args.Query != "torque" {
+				t.Errorf("arguments = %s (%v)", resp.ToolCalls[0].Arguments, err)
+			}
+		})
+	}
+
+	if _, err := DecodeChatResponse("nope", []byte(`{}`)); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestChatWithTools(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("decoding request: %v", err)
+		}
+		if _, ok := req["tools"]; !ok {
+			t.Error("request missing tools")
+		}
+		if req["tool_choice"] != "auto" {
+			t.Errorf("tool_choice = %v", req["tool_choice"])
+		}
+		w.Write([]byte(`{"choices":[{"finish_reason":"tool_calls","message":{"tool_calls":[
+			{"id":"c1","type":"function","function":{"name":"search","arguments":""}}]}}]}`))
+	}))
+	defer srv.Close()
+
+	p := NewOpenAICompat(Config{BaseURL: srv.URL, Model: "m"})
+	resp, err := p.Chat(context.Background(), ChatRequest{
+		Messages:   []Message{{Role: "user", Content: "hi"}},
+		Tools:      []Tool{searchTool},
+		ToolChoice: "auto",
+	})
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if len(resp.ToolCalls) != 1 || string(resp.ToolCalls[0].Arguments) != "{}" {
+		t.Errorf("empty arguments must normalise to {}, got %+v", resp.ToolCalls)
+	}
+}
@@ -20,6 +20,10 @@ func (p *xaiProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse,
 	return p.base.chat(ctx, req)
 }
 
+func (p *xaiProvider) ChatStream(ctx context.Context, req ChatRequest, onDelta func(string) error) (*ChatResponse, error) {
+	return p.base.chatStream(ctx, req, onDelta)
+}
+
 func (p *xaiProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
 	return p.base.embed(ctx, texts)
 }
@@ -0,0 +1,58 @@
+package goreason
+
+import (
+	"fmt"
+
+	"github.com/bbiangul/go-reason/retrieval"
+	"github.com/bbiangul/go-reason/store"
+)
+
+// NumericFilter restricts a query to chunks stating a value between Min
+// and Max (inclusive) in Unit's dimension, e.g. {Min: 70, Max: 90, Unit:
+// "psig"} matches "75 PSIG" and "5.5 bar". A nil bound is open.
+type NumericFilter struct {
+	Min  *float64 `json:"min,omitempty"`
+	Max  *float64 `json:"max,omitempty"`
+	Unit string   `json:"unit"`
+}
+
+// WithNumericFilter restricts retrieval to chunks with a value in every
+// filter's range. Without it, ranges stated in the question ("between 70
+// and 90 PSIG") only rank matching chunks higher, weighted by
+// Config.WeightNumeric.
+func WithNumericFilter(filters ...NumericFilter) QueryOption {
+	return func(o *queryOptions) { o.numericFilters = append(o.numericFilters, filters...) }
+}
+
+// numericRanges converts filters to ranges in base units. An unknown unit
+// or an empty or inverted range is ErrInvalidConfig.
+func numericRanges(filters []NumericFilter) ([]store.NumericRange, error) {
+	var out []store.NumericRange
+	for _, f := range filters {
+		r, err := retrieval.NormalizeRange(f.Min, f.Max, f.Unit)
+		if err != nil {
+			return nil, fmt.Errorf("%w: numeric filter: %v", ErrInvalidConfig, err)
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+// extractQuantities returns the numeric values with units stated in
+// chunks, for the numeric retrieval leg.
+func extractQuantities(docID int64, chunks []store.Chunk, chunkIDs []int64) []store.ChunkQuantity {
+	var out []store.ChunkQuantity
+	for i, c := range chunks {
+		for _, q := range retrieval.ExtractQuantities(c.Content) {
+			out = append(out, store.ChunkQuantity{
+				ChunkID:    chunkIDs[i],
+				DocumentID: docID,
+				Dimension:  q.Dimension,
+				Value:      q.Value,
+				Unit:       q.Unit,
+				Raw:        q.Raw,
+			})
+		}
+	}
+	return out
+}
@@ -0,0 +1,48 @@
+package goreason
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestNumericFilters(t *testing.T) {
+	eng, _, path := faultEngine(t, nil)
+	e := eng.(*engine)
+	ctx := context.Background()
+	docID, err := eng.Ingest(ctx, path)
+	if err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+
+	// "opens at 10 bar ... below 8 bar" is indexed as two pressures; "500
+	// operating hours" has no unit the index knows.
+	qs, err := e.store.ChunkQuantities(ctx, docID)
+	if err != nil {
+		t.Fatalf("ChunkQuantities: %v", err)
+	}
+	if len(qs) < 2 || qs[0].Dimension != "pressure" || qs[0].Value != 1000 || qs[1].Value != 800 {
+		t.Fatalf("quantities = %+v", qs)
+	}
+
+	// 140-150 psi is 965-1034 kPa, so 10 bar matches.
+	lo, hi := 140.0, 150.0
+	results, trace, err := eng.Retrieve(ctx, "relief valve set pressure",
+		WithNumericFilter(NumericFilter{Min: &lo, Max: &hi, Unit: "psi"}))
+	if err != nil {
+		t.Fatalf("Retrieve: %v", err)
+	}
+	if len(results) == 0 || trace.NumericResults == 0 || len(trace.NumericRanges) != 1 {
+		t.Errorf("results = %d, trace = %+v", len(results), trace)
+	}
+
+	above := 20.0
+	if _, _, err := eng.Retrieve(ctx, "relief valve set pressure",
+		WithNumericFilter(NumericFilter{Min: &above, Unit: "bar"})); !errors.Is(err, ErrNoResults) {
+		t.Errorf("out-of-range filter: err = %v, want ErrNoResults", err)
+	}
+	if _, _, err := eng.Retrieve(ctx, "relief valve",
+		WithNumericFilter(NumericFilter{Min: &above, Unit: "furlongs"})); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("unknown unit: err = %v, want ErrInvalidConfig", err)
+	}
+}
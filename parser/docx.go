@@ -360,11 +360,17 @@ func parseDocxXML(data []byte) ([]Section, error) {
 	var currentHeading string
 	currentLevel := 0
 
+	// Offsets index the body text: each non-empty paragraph followed by a
+	// newline.
+	offset, contentStart, contentEnd := 0, -1, 0
+
 	for _, para := range doc.Body.Paras {
 		text := extractParaText(para)
 		if text == "" {
 			continue
 		}
+		paraStart := offset
+		offset += len(text) + 1
 
 		style := ""
 		if para.PPr != nil && para.PPr.PStyle != nil {
@@ -378,12 +384,15 @@ func parseDocxXML(data []byte) ([]Section, error) {
 			// Save previous section
 			if currentContent.Len() > 0 || currentHeading != "" {
 				sections = append(sections, Section{
-					Heading: currentHeading,
-					Content: strings.TrimSpace(currentContent.String()),
-					Level:   currentLevel,
-					Type:    classifySectionType(currentHeading, currentContent.String()),
+					Heading:     currentHeading,
+					Content:     strings.TrimSpace(currentContent.String()),
+					Level:       currentLevel,
+					Type:        classifySectionType(currentHeading, currentContent.String()),
+					StartOffset: max(contentStart, 0),
+					EndOffset:   contentEnd,
 				})
 				currentContent.Reset()
+				contentStart, contentEnd = -1, 0
 			}
 			currentHeading = text
 			currentLevel = headingStyleLevel(style)
@@ -392,6 +401,10 @@ func parseDocxXML(data []byte) ([]Section, error) {
 				currentContent.WriteString("\n")
 			}
 			currentContent.WriteString(text)
+			if contentStart < 0 {
+				contentStart = paraStart
+			}
+			contentEnd = paraStart + len(text)
 		}
 	}
 
@@ -422,10 +435,12 @@ func parseDocxXML(data []byte) ([]Section, error) {
 	// Final section
 	if currentContent.Len() > 0 {
 		sections = append(sections, Section{
-			Heading: currentHeading,
-			Content: strings.TrimSpace(currentContent.String()),
-			Level:   currentLevel,
-			Type:    classifySectionType(currentHeading, currentContent.String()),
+			Heading:     currentHeading,
+			Content:     strings.TrimSpace(currentContent.String()),
+			Level:       currentLevel,
+			Type:        classifySectionType(currentHeading, currentContent.String()),
+			StartOffset: contentStart,
+			EndOffset:   contentEnd,
 		})
 	}
 
@@ -29,6 +29,13 @@ type Section struct {
 	Type       string // "section", "table", "definition", "requirement", "paragraph"
 	Children   []Section
 	Metadata   map[string]string
+
+	// StartOffset and EndOffset are the byte span of Content in the
+	// parser's extracted text: the file itself for plain text, the page's
+	// text for PDFs and slides (see PageNumber), and the body text (one
+	// line per paragraph) for DOCX. EndOffset == 0 means the span is unknown.
+	StartOffset int
+	EndOffset   int
 }
 
 // Parser can parse a specific document format.
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"fmt"
 	"testing"
 )
 
@@ -85,6 +86,34 @@ func TestRegistryCustomParser(t *testing.T) {
 	}
 }
 
+func TestRegistryForMethod(t *testing.T) {
+	reg := NewRegistry()
+	if got := reg.Methods("pdf"); len(got) != 1 || got[0] != "native" {
+		t.Fatalf("Methods(pdf) = %v, want [native]", got)
+	}
+	for _, method := range []string{"llamaparse", "vision", "ocr", "bogus"} {
+		if _, err := reg.ForMethod("pdf", method); err == nil {
+			t.Errorf("ForMethod(pdf, %q) expected error when not configured", method)
+		}
+	}
+
+	// LlamaParse takes over PDFs by default, but "native" still selects
+	// the built-in parser.
+	reg.SetLlamaParse(LlamaParseConfig{APIKey: "key"})
+	if p, _ := reg.Get("pdf"); fmt.Sprintf("%T", p) != "*parser.LlamaParseParser" {
+		t.Fatalf("Get(pdf) = %T after SetLlamaParse", p)
+	}
+	if p, err := reg.ForMethod("pdf", "native"); err != nil || fmt.Sprintf("%T", p) != "*parser.PDFParser" {
+		t.Errorf("ForMethod(pdf, native) = %T, %v", p, err)
+	}
+	if _, err := reg.ForMethod("txt", "llamaparse"); err == nil {
+		t.Error("ForMethod(txt, llamaparse) expected error for an unsupported format")
+	}
+	if got := reg.Methods("pdf"); len(got) != 2 || got[1] != "llamaparse" {
+		t.Errorf("Methods(pdf) = %v, want [native llamaparse]", got)
+	}
+}
+
 // ---------------------------------------------------------------------------
 // splitPageIntoSections tests
 // ---------------------------------------------------------------------------
@@ -133,6 +162,21 @@ The scope of this document covers requirements.
 	}
 }
 
+func TestSplitPageIntoSectionsOffsets(t *testing.T) {
+	text := "INTRODUCTION\n  Intro text here.\nMore intro.\n\n1.1 Scope\nScope text."
+
+	sections := splitPageIntoSections(text, 1)
+	if len(sections) != 2 {
+		t.Fatalf("expected 2 sections, got %d", len(sections))
+	}
+	for i, want := range []string{"Intro text here.\nMore intro.", "Scope text."} {
+		got := text[sections[i].StartOffset:sections[i].EndOffset]
+		if got != want {
+			t.Errorf("section[%d] span = %q, want %q", i, got, want)
+		}
+	}
+}
+
 func TestSplitPageIntoSectionsEmptyText(t *testing.T) {
 	sections := splitPageIntoSections("", 1)
 	if len(sections) != 0 {
@@ -384,7 +384,13 @@ func splitPageIntoSections(text string, pageNum int) []Section {
 	var currentHeading string
 	currentLevel := 0
 
+	// Byte span of the current section's content lines within text.
+	offset, contentStart, contentEnd := 0, -1, 0
+
 	for _, line := range lines {
+		lineStart := offset
+		offset += len(line) + 1
+
 		trimmed := strings.TrimSpace(line)
 		if trimmed == "" {
 			if currentContent.Len() > 0 {
@@ -398,13 +404,16 @@ func splitPageIntoSections(text string, pageNum int) []Section {
 			// Save previous section
 			if currentContent.Len() > 0 || currentHeading != "" {
 				sections = append(sections, Section{
-					Heading:    currentHeading,
-					Content:    strings.TrimSpace(currentContent.String()),
-					Level:      currentLevel,
-					PageNumber: pageNum,
-					Type:       classifySectionType(currentHeading, currentContent.String()),
+					Heading:     currentHeading,
+					Content:     strings.TrimSpace(currentContent.String()),
+					Level:       currentLevel,
+					PageNumber:  pageNum,
+					Type:        classifySectionType(currentHeading, currentContent.String()),
+					StartOffset: max(contentStart, 0),
+					EndOffset:   contentEnd,
 				})
 				currentContent.Reset()
+				contentStart, contentEnd = -1, 0
 			}
 			currentHeading = trimmed
 			currentLevel = detectHeadingLevel(trimmed)
@@ -413,6 +422,12 @@ func splitPageIntoSections(text string, pageNum int) []Section {
 				currentContent.WriteString("\n")
 			}
 			currentContent.WriteString(trimmed)
+
+			lead := strings.Index(line, trimmed)
+			if contentStart < 0 {
+				contentStart = lineStart + lead
+			}
+			contentEnd = lineStart + lead + len(trimmed)
 		}
 	}
 
@@ -420,11 +435,13 @@ func splitPageIntoSections(text string, pageNum int) []Section {
 	// are not silently dropped (they provide context for the next page's content).
 	if currentContent.Len() > 0 || currentHeading != "" {
 		sections = append(sections, Section{
-			Heading:    currentHeading,
-			Content:    strings.TrimSpace(currentContent.String()),
-			Level:      currentLevel,
-			PageNumber: pageNum,
-			Type:       classifySectionType(currentHeading, currentContent.String()),
+			Heading:     currentHeading,
+			Content:     strings.TrimSpace(currentContent.String()),
+			Level:       currentLevel,
+			PageNumber:  pageNum,
+			Type:        classifySectionType(currentHeading, currentContent.String()),
+			StartOffset: max(contentStart, 0),
+			EndOffset:   contentEnd,
 		})
 	}
 
@@ -451,6 +468,7 @@ func splitPageIntoSections(text string, pageNum int) []Section {
 			Content:    text,
 			PageNumber: pageNum,
 			Type:       "paragraph",
+			EndOffset:  len(text),
 		})
 	}
 
@@ -75,6 +75,7 @@ func (p *PPTXParser) Parse(ctx context.Context, path string) (*ParseResult, erro
 			Type:       "section",
 			Level:      1,
 			PageNumber: num,
+			EndOffset:  len(text),
 		})
 
 		// Extract images from this slide
@@ -1,6 +1,10 @@
 package parser
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/bbiangul/go-reason/llm"
+)
 
 type LlamaParseConfig struct {
 	APIKey  string
@@ -9,11 +13,13 @@ type LlamaParseConfig struct {
 
 type Registry struct {
 	parsers    map[string]Parser
+	native     map[string]Parser // built-in parsers, whatever is registered over them
 	llamaParse *LlamaParseConfig
+	vision     llm.VisionProvider
 }
 
 func NewRegistry() *Registry {
-	r := &Registry{parsers: make(map[string]Parser)}
+	r := &Registry{parsers: make(map[string]Parser), native: make(map[string]Parser)}
 	// Register built-in parsers
 	pdf := &PDFParser{}
 	docx := &DOCXParser{}
@@ -24,6 +30,7 @@ func NewRegistry() *Registry {
 	for _, p := range []Parser{pdf, docx, xlsx, pptx, txt} {
 		for _, f := range p.SupportedFormats() {
 			r.parsers[f] = p
+			r.native[f] = p
 		}
 	}
 	return r
@@ -38,6 +45,11 @@ func (r *Registry) SetLlamaParse(cfg LlamaParseConfig) {
 	}
 }
 
+// SetVision makes the "vision" parse method available for PDFs.
+func (r *Registry) SetVision(provider llm.VisionProvider) {
+	r.vision = provider
+}
+
 func (r *Registry) Get(format string) (Parser, error) {
 	p, ok := r.parsers[format]
 	if !ok {
@@ -46,6 +58,53 @@ func (r *Registry) Get(format string) (Parser, error) {
 	return p, nil
 }
 
+// ForMethod returns the parser that parses format with method: "native"
+// (the built-in parser), "llamaparse", or "vision" (a vision LLM
+// transcribes the PDF; "ocr" is accepted for it). An empty method returns
+// the default parser, as Get does.
+func (r *Registry) ForMethod(format, method string) (Parser, error) {
+	switch method {
+	case "":
+		return r.Get(format)
+	case "native":
+		if p, ok := r.native[format]; ok {
+			return p, nil
+		}
+		return nil, fmt.Errorf("no native parser for format: %s", format)
+	case "llamaparse":
+		if r.llamaParse == nil {
+			return nil, fmt.Errorf("llamaparse is not configured")
+		}
+		lp := NewLlamaParseParser(*r.llamaParse)
+		for _, f := range lp.SupportedFormats() {
+			if f == format {
+				return lp, nil
+			}
+		}
+		return nil, fmt.Errorf("llamaparse does not support format: %s", format)
+	case "vision", "ocr":
+		if r.vision == nil {
+			return nil, fmt.Errorf("no vision provider is configured")
+		}
+		if format != "pdf" {
+			return nil, fmt.Errorf("vision parsing supports only pdf, not %s", format)
+		}
+		return NewPDFVisionParser(r.vision), nil
+	}
+	return nil, fmt.Errorf("unknown parse method: %q", method)
+}
+
+// Methods returns the parse methods ForMethod accepts for format.
+func (r *Registry) Methods(format string) []string {
+	var methods []string
+	for _, m := range []string{"native", "llamaparse", "vision"} {
+		if _, err := r.ForMethod(format, m); err == nil {
+			methods = append(methods, m)
+		}
+	}
+	return methods
+}
+
 func (r *Registry) Register(format string, p Parser) {
 	r.parsers[format] = p
 }
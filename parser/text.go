@@ -28,10 +28,11 @@ func (p *TextParser) Parse(ctx context.Context, path string) (*ParseResult, erro
 	return &ParseResult{
 		Sections: []Section{
 			{
-				Heading: filepath.Base(path),
-				Content: content,
-				Level:   1,
-				Type:    "paragraph",
+				Heading:   filepath.Base(path),
+				Content:   content,
+				Level:     1,
+				Type:      "paragraph",
+				EndOffset: len(content),
 			},
 		},
 		Method: "native",
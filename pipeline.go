@@ -0,0 +1,33 @@
+package goreason
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/bbiangul/go-reason/pipeline"
+)
+
+// WithPipeline runs this ingest with p in place of Config.Pipeline: its
+// custom stages run between the built-in ones, and the skipped built-in
+// stages do not run. ResumeIngest continues an interrupted ingest with
+// the pipeline given to it, which must be the one the ingest ran with.
+func WithPipeline(p *pipeline.Pipeline) IngestOption {
+	return func(o *ingestOptions) { o.pipeline = p }
+}
+
+// runStages runs custom pipeline stages over doc, in order. The first
+// failure stops the ingest.
+func runStages(ctx context.Context, stages []pipeline.Stage, doc *pipeline.Document) error {
+	for _, s := range stages {
+		start := time.Now()
+		if err := s.Run(ctx, doc); err != nil {
+			return fmt.Errorf("pipeline stage %q: %w", s.Name(), err)
+		}
+		slog.InfoContext(ctx, "ingest: pipeline stage complete",
+			"file", doc.Filename, "stage", s.Name(), "chunks", len(doc.Chunks),
+			"elapsed", time.Since(start).Round(time.Millisecond))
+	}
+	return nil
+}
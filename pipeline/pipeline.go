@@ -0,0 +1,273 @@
+// Package pipeline describes the stages of document ingestion, so
+// applications can run their own stages between the engine's: scrubbing
+// personal data from the parsed text, removing duplicate chunks, enriching
+// chunk metadata, or replacing a built-in stage altogether.
+//
+// The built-in stages run in a fixed order: Parse, Chunk, Embed, Graph.
+// A Builder inserts custom stages before or after them and skips the ones
+// not wanted:
+//
+//	p, err := pipeline.New().
+//		After(pipeline.Parse, pipeline.Func("pii", scrub)).
+//		After(pipeline.Chunk, pipeline.Func("dedup", dedup)).
+//		Skip(pipeline.Graph).
+//		Build()
+//
+// and the engine runs it with goreason.WithPipeline or Config.Pipeline.
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bbiangul/go-reason/parser"
+	"github.com/bbiangul/go-reason/store"
+)
+
+// ErrInvalidPipeline is returned by Build for a pipeline that cannot run.
+var ErrInvalidPipeline = errors.New("invalid pipeline")
+
+// Document is the document being ingested, as the stages see it. Each
+// stage reads what the earlier ones produced and may change it.
+type Document struct {
+	ID         int64
+	Path       string
+	Filename   string
+	Format     string
+	Collection string
+	Metadata   map[string]string // from WithMetadata
+
+	// Parsed is the parse result. Parse sets it; a stage before Parse
+	// may set it instead, and Parse then keeps it. With Parse skipped, a
+	// custom stage must set it.
+	Parsed *parser.ParseResult
+
+	// Chunks are the document's chunks, set by Chunk. Stages before Embed
+	// may edit, drop, or add chunks; the engine renumbers their positions.
+	// A chunk keeps its images while it keeps its ID.
+	// The chunks are stored once those stages have run, so later stages
+	// see them read-only.
+	Chunks []store.Chunk
+
+	// ChunkIDs are the stored IDs of Chunks, set from Embed on.
+	ChunkIDs []int64
+}
+
+// Stage is one step of the pipeline: a built-in stage the engine runs, or
+// a custom one made by Func.
+type Stage struct {
+	name string
+	run  func(ctx context.Context, doc *Document) error
+}
+
+// The built-in stages, in the order they run.
+var (
+	// Parse extracts the document's sections with the parser for its
+	// format, and applies the page and section selection.
+	Parse = Stage{name: "parse"}
+	// Chunk captions images, splits the sections into chunks, and runs
+	// the chunk filters.
+	Chunk = Stage{name: "chunk"}
+	// Embed embeds the chunks (dense, and sparse, secondary, and image
+	// embeddings when configured) and writes the summary and key facts.
+	Embed = Stage{name: "embed"}
+	// Graph extracts entities and relationships into the knowledge graph.
+	Graph = Stage{name: "graph"}
+)
+
+var builtins = []Stage{Parse, Chunk, Embed, Graph}
+
+// Func returns a custom stage that calls run with the document. An error
+// fails the ingest.
+func Func(name string, run func(ctx context.Context, doc *Document) error) Stage {
+	return Stage{name: name, run: run}
+}
+
+// Name returns the stage's name.
+func (s Stage) Name() string { return s.name }
+
+// Builtin reports whether s is one of the engine's stages.
+func (s Stage) Builtin() bool { return s.run == nil }
+
+// Run runs a custom stage. It does nothing for a built-in one, which the
+// engine runs itself.
+func (s Stage) Run(ctx context.Context, doc *Document) error {
+	if s.run == nil {
+		return nil
+	}
+	return s.run(ctx, doc)
+}
+
+// Pipeline is a built pipeline. A nil *Pipeline is the default one: the
+// built-in stages alone.
+type Pipeline struct {
+	skip  map[string]bool
+	after map[string][]Stage // custom stages after a built-in one; "" before Parse
+}
+
+// Skips reports whether the built-in stage is skipped.
+func (p *Pipeline) Skips(builtin Stage) bool {
+	return p != nil && p.skip[builtin.name]
+}
+
+// After returns the custom stages that run right after the built-in
+// stage, or where it would run when skipped.
+func (p *Pipeline) After(builtin Stage) []Stage {
+	if p == nil {
+		return nil
+	}
+	return p.after[builtin.name]
+}
+
+// First returns the custom stages that run before Parse.
+func (p *Pipeline) First() []Stage {
+	if p == nil {
+		return nil
+	}
+	return p.after[""]
+}
+
+// Stages returns the stages in the order they run, without the skipped
+// ones.
+func (p *Pipeline) Stages() []Stage {
+	stages := append([]Stage(nil), p.First()...)
+	for _, b := range builtins {
+		if !p.Skips(b) {
+			stages = append(stages, b)
+		}
+		stages = append(stages, p.After(b)...)
+	}
+	return stages
+}
+
+// String lists the stage names, e.g. "parse > pii > chunk > embed".
+func (p *Pipeline) String() string {
+	var names []string
+	for _, s := range p.Stages() {
+		names = append(names, s.name)
+	}
+	return strings.Join(names, " > ")
+}
+
+// Builder assembles a Pipeline. Its methods record the first error, which
+// Build returns.
+type Builder struct {
+	p   Pipeline
+	err error
+}
+
+// New returns a builder for the default pipeline.
+func New() *Builder {
+	return &Builder{p: Pipeline{skip: make(map[string]bool), after: make(map[string][]Stage)}}
+}
+
+// After inserts stages after the built-in stage, following the ones
+// inserted there before.
+func (b *Builder) After(builtin Stage, stages ...Stage) *Builder {
+	i := b.builtinIndex(builtin)
+	if i < 0 {
+		return b
+	}
+	b.insert(builtin.name, stages)
+	return b
+}
+
+// Before inserts stages before the built-in stage, following the ones
+// inserted there before.
+func (b *Builder) Before(builtin Stage, stages ...Stage) *Builder {
+	i := b.builtinIndex(builtin)
+	if i < 0 {
+		return b
+	}
+	slot := ""
+	if i > 0 {
+		slot = builtins[i-1].name
+	}
+	b.insert(slot, stages)
+	return b
+}
+
+// Skip leaves out built-in stages. Custom stages inserted around a
+// skipped stage still run.
+func (b *Builder) Skip(builtin ...Stage) *Builder {
+	for _, s := range builtin {
+		if b.builtinIndex(s) >= 0 {
+			b.p.skip[s.name] = true
+		}
+	}
+	return b
+}
+
+// Build returns the pipeline, or the first error of the builder's calls
+// wrapped in ErrInvalidPipeline.
+func (b *Builder) Build() (*Pipeline, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	// Later calls on the builder must not change the pipeline.
+	p := &Pipeline{skip: make(map[string]bool), after: make(map[string][]Stage)}
+	for name := range b.p.skip {
+		p.skip[name] = true
+	}
+	for slot, stages := range b.p.after {
+		p.after[slot] = append([]Stage(nil), stages...)
+	}
+	return p, nil
+}
+
+func (b *Builder) builtinIndex(s Stage) int {
+	for i, bs := range builtins {
+		if s.name == bs.name && s.Builtin() {
+			return i
+		}
+	}
+	b.fail("%q is not a built-in stage", s.name)
+	return -1
+}
+
+func (b *Builder) insert(slot string, stages []Stage) {
+	for _, s := range stages {
+		switch {
+		case s.Builtin() && isBuiltin(s.name):
+			b.fail("built-in stage %q cannot be inserted; skip the ones not wanted", s.name)
+			return
+		case s.Builtin():
+			b.fail("custom stage %q has no function", s.name)
+			return
+		case strings.TrimSpace(s.name) == "":
+			b.fail("a custom stage needs a name")
+			return
+		case b.named(s.name):
+			b.fail("stage name %q is already used", s.name)
+			return
+		}
+		b.p.after[slot] = append(b.p.after[slot], s)
+	}
+}
+
+// named reports whether a stage of the builder's pipeline is called name.
+func (b *Builder) named(name string) bool {
+	for _, s := range b.p.Stages() {
+		if s.name == name {
+			return true
+		}
+	}
+	return isBuiltin(name)
+}
+
+func isBuiltin(name string) bool {
+	for _, s := range builtins {
+		if s.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *Builder) fail(format string, args ...any) {
+	if b.err == nil {
+		b.err = fmt.Errorf("%w: %s", ErrInvalidPipeline, fmt.Sprintf(format, args...))
+	}
+}
@@ -0,0 +1,70 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func noop(context.Context, *Document) error { return nil }
+
+func TestBuilder(t *testing.T) {
+	var nilPipeline *Pipeline
+	if got := nilPipeline.String(); got != "parse > chunk > embed > graph" {
+		t.Errorf("default pipeline = %q", got)
+	}
+
+	b := New().
+		Before(Parse, Func("fetch", noop)).
+		After(Parse, Func("pii", noop)).
+		Before(Embed, Func("dedup", noop)).
+		After(Chunk, Func("enrich", noop)).
+		Skip(Graph)
+	p, err := b.Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if got, want := p.String(), "fetch > parse > pii > chunk > dedup > enrich > embed"; got != want {
+		t.Errorf("pipeline = %q, want %q", got, want)
+	}
+	if !p.Skips(Graph) || p.Skips(Embed) {
+		t.Errorf("Skips(graph, embed) = %v, %v", p.Skips(Graph), p.Skips(Embed))
+	}
+
+	// The built pipeline does not change with the builder.
+	b.After(Graph, Func("notify", noop))
+	if len(p.After(Graph)) != 0 {
+		t.Error("builder call after Build changed the pipeline")
+	}
+
+	for name, b := range map[string]*Builder{
+		"duplicate name":   New().After(Parse, Func("x", noop)).After(Chunk, Func("x", noop)),
+		"built-in name":    New().After(Parse, Func("chunk", noop)),
+		"empty name":       New().After(Parse, Func(" ", noop)),
+		"no function":      New().After(Parse, Func("x", nil)),
+		"insert built-in":  New().After(Parse, Embed),
+		"custom as anchor": New().After(Func("x", noop), Func("y", noop)),
+		"skip custom":      New().Skip(Func("x", noop)),
+	} {
+		if _, err := b.Build(); !errors.Is(err, ErrInvalidPipeline) {
+			t.Errorf("%s: Build error = %v, want ErrInvalidPipeline", name, err)
+		}
+	}
+}
+
+func TestStageRun(t *testing.T) {
+	doc := &Document{Filename: "a.txt"}
+	s := Func("name", func(_ context.Context, d *Document) error {
+		d.Filename = "b.txt"
+		return nil
+	})
+	if err := s.Run(context.Background(), doc); err != nil || doc.Filename != "b.txt" {
+		t.Errorf("Run = %v, filename %q", err, doc.Filename)
+	}
+	if s.Builtin() || !Parse.Builtin() {
+		t.Error("Builtin misreports")
+	}
+	if err := Parse.Run(context.Background(), doc); err != nil {
+		t.Errorf("built-in Run = %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package goreason
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bbiangul/go-reason/parser"
+	"github.com/bbiangul/go-reason/pipeline"
+	"github.com/bbiangul/go-reason/store"
+)
+
+func TestIngestPipeline(t *testing.T) {
+	eng, srv, path := faultEngine(t, nil)
+	ctx := context.Background()
+	e := eng.(*engine)
+
+	var extractions int
+	chat := srv.ChatFunc
+	srv.ChatFunc = func(prompt string) string {
+		if strings.Contains(prompt, "entity extraction engine") {
+			extractions++
+		}
+		return chat(prompt)
+	}
+
+	var order []string
+	var storedIDs []int64
+	scrub := pipeline.Func("scrub", func(_ context.Context, doc *pipeline.Document) error {
+		order = append(order, "scrub")
+		for i := range doc.Parsed.Sections {
+			doc.Parsed.Sections[i].Content = strings.ReplaceAll(doc.Parsed.Sections[i].Content, "10 bar", "[redacted]")
+		}
+		return nil
+	})
+	extra := pipeline.Func("extra", func(_ context.Context, doc *pipeline.Document) error {
+		order = append(order, "extra")
+		doc.Chunks = append(doc.Chunks, store.Chunk{Content: "Appended by a pipeline stage.", ChunkType: "paragraph"})
+		return nil
+	})
+	done := pipeline.Func("done", func(_ context.Context, doc *pipeline.Document) error {
+		order = append(order, "done")
+		storedIDs = doc.ChunkIDs
+		return nil
+	})
+	p, err := pipeline.New().
+		After(pipeline.Parse, scrub).
+		After(pipeline.Chunk, extra).
+		After(pipeline.Embed, done).
+		Skip(pipeline.Graph).
+		Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	docID, err := eng.Ingest(ctx, path, WithPipeline(p))
+	if err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	if strings.Join(order, ",") != "scrub,extra,done" {
+		t.Errorf("stages ran %v", order)
+	}
+	chunks, err := e.store.GetChunksByDocument(ctx, docID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(storedIDs) != len(chunks) || len(chunks) < 2 {
+		t.Fatalf("stage after embed saw %d chunk IDs, stored %d", len(storedIDs), len(chunks))
+	}
+	last := chunks[len(chunks)-1]
+	if last.Content != "Appended by a pipeline stage." || last.PositionInDoc != len(chunks)-1 {
+		t.Errorf("last chunk = %q at %d", last.Content, last.PositionInDoc)
+	}
+	for _, c := range chunks {
+		if strings.Contains(c.Content, "10 bar") {
+			t.Errorf("chunk %d kept scrubbed text: %q", c.ID, c.Content)
+		}
+	}
+	if extractions != 0 {
+		t.Errorf("skipped graph stage ran %d extractions", extractions)
+	}
+
+	// A stage before Parse can replace the parser; a failing stage fails
+	// the ingest.
+	p, err = pipeline.New().
+		Before(pipeline.Parse, pipeline.Func("parse-own", func(_ context.Context, doc *pipeline.Document) error {
+			doc.Parsed = &parser.ParseResult{Method: "custom", Sections: []parser.Section{{Heading: "Own", Content: "Parsed by the application."}}}
+			return nil
+		})).
+		Skip(pipeline.Parse).
+		Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if docID, err = eng.Ingest(ctx, path, WithPipeline(p), WithForceReparse()); err != nil {
+		t.Fatalf("Ingest with own parser: %v", err)
+	}
+	if d, err := e.store.GetDocument(ctx, docID); err != nil || d.ParseMethod != "custom" {
+		t.Errorf("document = %+v, %v; want parse method custom", d, err)
+	}
+
+	boom := errors.New("boom")
+	p, _ = pipeline.New().After(pipeline.Chunk, pipeline.Func("fail", func(context.Context, *pipeline.Document) error {
+		return boom
+	})).Build()
+	if _, err := eng.Ingest(ctx, path, WithPipeline(p), WithForceReparse()); !errors.Is(err, boom) {
+		t.Errorf("Ingest with failing stage = %v, want boom", err)
+	}
+
+	p, _ = pipeline.New().Skip(pipeline.Parse).Build()
+	if _, err := eng.Ingest(ctx, path, WithPipeline(p), WithForceReparse()); !errors.Is(err, ErrParsingFailed) {
+		t.Errorf("Ingest with no parse = %v, want ErrParsingFailed", err)
+	}
+}
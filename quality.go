@@ -0,0 +1,198 @@
+package goreason
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bbiangul/go-reason/graph"
+	"github.com/bbiangul/go-reason/parser"
+	"github.com/bbiangul/go-reason/store"
+)
+
+// Thresholds behind the quality report's warnings.
+const (
+	qualityEmptySectionRatio = 0.3 // warn when more sections than this are empty
+	qualityMinMeanTokens     = 20  // warn when chunks average fewer tokens
+	qualityMinEntityDensity  = 0.1 // warn when chunks average fewer entities
+	qualityMinOCRConfidence  = 0.8
+)
+
+// QualityReport summarises how well a document parsed, so a bad parse is
+// caught at ingest rather than when queries over it start failing.
+type QualityReport struct {
+	ParseMethod   string `json:"parse_method"`
+	Sections      int    `json:"sections"`
+	EmptySections int    `json:"empty_sections"`
+	Chunks        int    `json:"chunks"`
+	// ChunkTokens is the distribution of chunk token counts.
+	ChunkTokens            TokenDistribution `json:"chunk_tokens"`
+	Images                 int               `json:"images"`
+	Entities               int               `json:"entities"`
+	EntityDensity          float64           `json:"entity_density"` // entities per chunk
+	ChunksWithoutEmbedding int               `json:"chunks_without_embedding"`
+	// OCRConfidence is the parser's mean OCR confidence in [0, 1], when the
+	// parser reports one (metadata key "ocr_confidence").
+	OCRConfidence *float64 `json:"ocr_confidence,omitempty"`
+	// Injection reports the prompt injection scan; nil when it is off.
+	Injection *InjectionReport `json:"injection,omitempty"`
+	// Graph reports the knowledge graph build; nil when it did not run.
+	Graph    *graph.BuildReport `json:"graph,omitempty"`
+	Warnings []string           `json:"warnings,omitempty"`
+}
+
+// TokenDistribution summarises chunk token counts.
+type TokenDistribution struct {
+	Min  int     `json:"min"`
+	P50  int     `json:"p50"`
+	P90  int     `json:"p90"`
+	Max  int     `json:"max"`
+	Mean float64 `json:"mean"`
+}
+
+// countSections returns the number of sections in the tree and how many
+// have neither content nor children.
+func countSections(secs []parser.Section) (total, empty int) {
+	for _, sec := range secs {
+		total++
+		if strings.TrimSpace(sec.Content) == "" && len(sec.Children) == 0 {
+			empty++
+		}
+		t, e := countSections(sec.Children)
+		total += t
+		empty += e
+	}
+	return total, empty
+}
+
+// tokenDistribution computes the distribution of counts.
+func tokenDistribution(counts []int) TokenDistribution {
+	if len(counts) == 0 {
+		return TokenDistribution{}
+	}
+	sorted := append([]int(nil), counts...)
+	sort.Ints(sorted)
+	sum := 0
+	for _, n := range sorted {
+		sum += n
+	}
+	at := func(p float64) int {
+		return sorted[int(p*float64(len(sorted)-1)+0.5)]
+	}
+	return TokenDistribution{
+		Min:  sorted[0],
+		P50:  at(0.5),
+		P90:  at(0.9),
+		Max:  sorted[len(sorted)-1],
+		Mean: float64(sum) / float64(len(sorted)),
+	}
+}
+
+// warnings flags the report's signs of a bad parse.
+func (q *QualityReport) warnings(skipGraph bool) []string {
+	var w []string
+	if q.Chunks == 0 {
+		return append(w, "no chunks were produced; the document may be empty or unreadable")
+	}
+	if q.Sections > 0 && float64(q.EmptySections)/float64(q.Sections) > qualityEmptySectionRatio {
+		w = append(w, fmt.Sprintf("%d of %d sections are empty", q.EmptySections, q.Sections))
+	}
+	if q.ChunkTokens.Mean < qualityMinMeanTokens {
+		w = append(w, fmt.Sprintf("chunks average %.1f tokens; the text may be fragmented", q.ChunkTokens.Mean))
+	}
+	if q.ChunksWithoutEmbedding > 0 {
+		w = append(w, fmt.Sprintf("%d chunks have no embedding and are invisible to vector search", q.ChunksWithoutEmbedding))
+	}
+	if !skipGraph && q.EntityDensity < qualityMinEntityDensity {
+		w = append(w, fmt.Sprintf("graph entity density is %.2f per chunk", q.EntityDensity))
+	}
+	if g := q.Graph; g != nil && g.Failed > 0 {
+		w = append(w, fmt.Sprintf("graph extraction failed for %d of %d chunks (%d LLM failures)",
+			g.Failed, g.Chunks-g.Skipped, g.LLMFailures))
+	}
+	if q.OCRConfidence != nil && *q.OCRConfidence < qualityMinOCRConfidence {
+		w = append(w, fmt.Sprintf("OCR confidence is %.2f", *q.OCRConfidence))
+	}
+	if iw := q.Injection.warning(); iw != "" {
+		w = append(w, iw)
+	}
+	return w
+}
+
+// qualityReport builds the quality report of a stored document from its
+// parse result and what ingest stored.
+func (e *engine) qualityReport(ctx context.Context, docID int64, parsed *parser.ParseResult, injection *InjectionReport, graphReport *graph.BuildReport) (*QualityReport, error) {
+	stats, err := e.store.DocumentStats(ctx, docID)
+	if err != nil {
+		return nil, err
+	}
+	q := &QualityReport{
+		ParseMethod:            parsed.Method,
+		Chunks:                 stats.Chunks,
+		ChunkTokens:            tokenDistribution(stats.TokenCounts),
+		Images:                 stats.Images,
+		Entities:               stats.Entities,
+		ChunksWithoutEmbedding: stats.ChunksWithoutEmbedding,
+		Injection:              injection,
+		Graph:                  graphReport,
+	}
+	q.Sections, q.EmptySections = countSections(parsed.Sections)
+	if q.Chunks > 0 {
+		q.EntityDensity = float64(q.Entities) / float64(q.Chunks)
+	}
+	if v, err := strconv.ParseFloat(parsed.Metadata["ocr_confidence"], 64); err == nil {
+		q.OCRConfidence = &v
+	}
+	q.Warnings = q.warnings(e.cfg.SkipGraph)
+	return q, nil
+}
+
+// recordQuality computes and stores a document's quality report. Failures
+// are logged, not returned: the report is diagnostic.
+func (e *engine) recordQuality(ctx context.Context, docID int64, filename string, parsed *parser.ParseResult, injection *InjectionReport, graphReport *graph.BuildReport) {
+	q, err := e.qualityReport(ctx, docID, parsed, injection, graphReport)
+	if err != nil {
+		slog.WarnContext(ctx, "quality report failed (non-fatal)", "doc_id", docID, "error", err)
+		return
+	}
+	e.storeQuality(ctx, docID, filename, q)
+}
+
+// recordResumedQuality recomputes the quality report of a document whose
+// ingest ResumeIngest finished. The parsed document is not kept, so the
+// section counts, OCR confidence, and injection scan come from the report
+// the interrupted ingest stored, if any.
+func (e *engine) recordResumedQuality(ctx context.Context, doc *store.Document, graphReport *graph.BuildReport) {
+	q, err := e.qualityReport(ctx, doc.ID, &parser.ParseResult{Method: doc.ParseMethod}, nil, graphReport)
+	if err != nil {
+		slog.WarnContext(ctx, "quality report failed (non-fatal)", "doc_id", doc.ID, "error", err)
+		return
+	}
+	var prev QualityReport
+	if doc.Quality != "" && json.Unmarshal([]byte(doc.Quality), &prev) == nil {
+		q.Sections, q.EmptySections = prev.Sections, prev.EmptySections
+		q.OCRConfidence, q.Injection = prev.OCRConfidence, prev.Injection
+		q.Warnings = q.warnings(e.cfg.SkipGraph)
+	}
+	e.storeQuality(ctx, doc.ID, doc.Filename, q)
+}
+
+// storeQuality stores a document's quality report and logs its warnings.
+func (e *engine) storeQuality(ctx context.Context, docID int64, filename string, q *QualityReport) {
+	data, err := json.Marshal(q)
+	if err != nil {
+		slog.WarnContext(ctx, "quality report failed (non-fatal)", "doc_id", docID, "error", err)
+		return
+	}
+	if err := e.store.UpdateDocumentQuality(ctx, docID, string(data)); err != nil {
+		slog.WarnContext(ctx, "storing quality report failed (non-fatal)", "doc_id", docID, "error", err)
+		return
+	}
+	if len(q.Warnings) > 0 {
+		slog.WarnContext(ctx, "ingest: document quality warnings", "file", filename, "doc_id", docID, "warnings", q.Warnings)
+	}
+}
@@ -0,0 +1,99 @@
+package goreason
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bbiangul/go-reason/graph"
+	"github.com/bbiangul/go-reason/parser"
+)
+
+func TestCountSections(t *testing.T) {
+	secs := []parser.Section{
+		{Heading: "1 Scope", Content: "Covers pumps."},
+		{Heading: "2 Safety", Children: []parser.Section{
+			{Heading: "2.1 Valves", Content: "Relief valves."},
+			{Heading: "2.2 Seals"},
+		}},
+		{Heading: "3 Annex", Content: "  \n"},
+	}
+	total, empty := countSections(secs)
+	if total != 5 || empty != 2 {
+		t.Errorf("countSections = %d, %d; want 5, 2", total, empty)
+	}
+}
+
+func TestTokenDistribution(t *testing.T) {
+	d := tokenDistribution([]int{50, 10, 40, 30, 20, 60, 70, 80, 90, 100})
+	if d.Min != 10 || d.Max != 100 || d.P50 != 60 || d.P90 != 90 || d.Mean != 55 {
+		t.Errorf("distribution = %+v", d)
+	}
+	if d := tokenDistribution(nil); d != (TokenDistribution{}) {
+		t.Errorf("empty distribution = %+v", d)
+	}
+}
+
+func TestQualityWarnings(t *testing.T) {
+	low := 0.5
+	q := &QualityReport{
+		Sections: 10, EmptySections: 6, Chunks: 4,
+		ChunkTokens:            TokenDistribution{Mean: 8},
+		ChunksWithoutEmbedding: 1,
+		OCRConfidence:          &low,
+	}
+	w := strings.Join(q.warnings(false), "\n")
+	for _, want := range []string{"6 of 10 sections are empty", "average 8.0 tokens", "1 chunks have no embedding", "entity density", "OCR confidence is 0.50"} {
+		if !strings.Contains(w, want) {
+			t.Errorf("warnings missing %q:\n%s", want, w)
+		}
+	}
+	if w := q.warnings(true); strings.Contains(strings.Join(w, "\n"), "entity density") {
+		t.Error("entity density should not be checked when the graph is skipped")
+	}
+	q.Graph = &graph.BuildReport{Chunks: 4, Skipped: 1, Processed: 1, Failed: 2, LLMFailures: 3}
+	if w := strings.Join(q.warnings(false), "\n"); !strings.Contains(w, "graph extraction failed for 2 of 3 chunks (3 LLM failures)") {
+		t.Errorf("warnings missing the graph failures:\n%s", w)
+	}
+	if w := (&QualityReport{}).warnings(false); len(w) != 1 || !strings.Contains(w[0], "no chunks") {
+		t.Errorf("warnings for an empty document = %v", w)
+	}
+}
+
+func TestIngestRecordsQualityReport(t *testing.T) {
+	eng, _, path := faultEngine(t, nil)
+	ctx := context.Background()
+
+	id, err := eng.Ingest(ctx, path)
+	if err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	doc, err := eng.Document(ctx, id)
+	if err != nil {
+		t.Fatalf("Document: %v", err)
+	}
+	q := doc.Quality
+	if q == nil {
+		t.Fatal("no quality report stored")
+	}
+	if q.Chunks == 0 || q.ChunkTokens.Max == 0 || q.Sections == 0 {
+		t.Errorf("report = %+v, want chunks and sections counted", q)
+	}
+	if q.ChunksWithoutEmbedding != 0 {
+		t.Errorf("chunks without embedding = %d, want 0", q.ChunksWithoutEmbedding)
+	}
+	if q.Entities != 1 || q.EntityDensity <= 0 {
+		t.Errorf("entities = %d, density = %v; want the extracted entity", q.Entities, q.EntityDensity)
+	}
+	if g := q.Graph; g == nil || g.Processed == 0 || g.Failed != 0 || g.Entities == 0 || g.LLMCalls == 0 {
+		t.Errorf("graph report = %+v, want the extraction counted", g)
+	}
+	if doc.Ingest != nil {
+		t.Errorf("ingest progress = %+v after a finished ingest", doc.Ingest)
+	}
+
+	if _, err := eng.Document(ctx, id+100); !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("missing document error = %v, want ErrDocumentNotFound", err)
+	}
+}
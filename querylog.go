@@ -0,0 +1,71 @@
+package goreason
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bbiangul/go-reason/store"
+)
+
+// queryLogSink writes the query log of a read-only engine as JSON lines
+// (Config.QueryLogFile), in the shape of the query_log table. A nil sink
+// drops entries.
+type queryLogSink struct {
+	mu sync.Mutex
+	w  io.Writer
+	c  io.Closer // nil for standard output
+}
+
+// queryLogLine is one line of the query log file.
+type queryLogLine struct {
+	store.QueryLog
+	CreatedAt string `json:"created_at"`
+}
+
+// openQueryLogSink opens path for appending, or standard output for "-".
+// An empty path returns nil.
+func openQueryLogSink(path string) (*queryLogSink, error) {
+	switch path {
+	case "":
+		return nil, nil
+	case "-":
+		return &queryLogSink{w: os.Stdout}, nil
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("%w: query_log_file: %v", ErrInvalidConfig, err)
+	}
+	return &queryLogSink{w: f, c: f}, nil
+}
+
+// write appends entry. Failures are logged, as a lost log line must not
+// fail the query.
+func (s *queryLogSink) write(ctx context.Context, entry store.QueryLog) {
+	if s == nil {
+		return
+	}
+	data, err := json.Marshal(queryLogLine{QueryLog: entry, CreatedAt: time.Now().UTC().Format(time.RFC3339)})
+	if err != nil {
+		slog.WarnContext(ctx, "query: logging failed (non-fatal)", "error", err)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.w.Write(append(data, '\n')); err != nil {
+		slog.WarnContext(ctx, "query: logging failed (non-fatal)", "error", err)
+	}
+}
+
+// close closes the log file.
+func (s *queryLogSink) close() error {
+	if s == nil || s.c == nil {
+		return nil
+	}
+	return s.c.Close()
+}
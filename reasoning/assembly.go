@@ -0,0 +1,133 @@
+package reasoning
+
+import (
+	"sort"
+	"unicode"
+
+	"github.com/bbiangul/go-reason/chunker"
+	"github.com/bbiangul/go-reason/store"
+)
+
+// ContextPolicy constrains how retrieved chunks are assembled into the
+// reasoning context. The zero value disables assembly: chunks are passed
+// through in retrieval order.
+type ContextPolicy struct {
+	MaxChunks          int // total chunks in the context (0 = all retrieved)
+	MaxChunksPerDoc    int // cap per document so one file cannot monopolise the context
+	MaxTokensPerSource int // truncate each chunk to roughly this many tokens
+	MinDocuments       int // reserve slots for at least this many distinct documents, when available
+}
+
+func (p ContextPolicy) enabled() bool {
+	return p.MaxChunks > 0 || p.MaxChunksPerDoc > 0 || p.MaxTokensPerSource > 0 || p.MinDocuments > 0
+}
+
+// AssembleContext selects and orders chunks for the reasoning context.
+//
+// Selection walks chunks in retrieval (rank) order. The best chunk of each
+// of the first MinDocuments documents is taken first, then remaining slots
+// are filled in rank order subject to MaxChunksPerDoc. The result is grouped
+// by document — documents ordered by their best-ranked chunk — and ordered
+// by position within each document, so the model reads each source in its
+// natural sequence.
+func AssembleContext(chunks []store.RetrievalResult, p ContextPolicy) []store.RetrievalResult {
+	if !p.enabled() || len(chunks) == 0 {
+		return chunks
+	}
+
+	budget := len(chunks)
+	if p.MaxChunks > 0 && p.MaxChunks < budget {
+		budget = p.MaxChunks
+	}
+
+	selected := make([]bool, len(chunks))
+	perDoc := make(map[int64]int)
+	docRank := make(map[int64]int) // document -> rank of its best chunk
+	for i, c := range chunks {
+		if _, ok := docRank[c.DocumentID]; !ok {
+			docRank[c.DocumentID] = i
+		}
+	}
+	n := 0
+	take := func(i int) {
+		selected[i] = true
+		perDoc[chunks[i].DocumentID]++
+		n++
+	}
+
+	// Reserve one slot for each of the top MinDocuments documents.
+	if p.MinDocuments > 0 {
+		seen := make(map[int64]bool)
+		for i, c := range chunks {
+			if n >= budget || len(seen) >= p.MinDocuments {
+				break
+			}
+			if seen[c.DocumentID] {
+				continue
+			}
+			seen[c.DocumentID] = true
+			take(i)
+		}
+	}
+
+	// Fill the remaining budget in rank order.
+	for i, c := range chunks {
+		if n >= budget {
+			break
+		}
+		if selected[i] {
+			continue
+		}
+		if p.MaxChunksPerDoc > 0 && perDoc[c.DocumentID] >= p.MaxChunksPerDoc {
+			continue
+		}
+		take(i)
+	}
+
+	out := make([]store.RetrievalResult, 0, n)
+	for i, c := range chunks {
+		if selected[i] {
+			if p.MaxTokensPerSource > 0 {
+				c.Content = truncateTokens(c.Content, p.MaxTokensPerSource)
+			}
+			out = append(out, c)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		di, dj := docRank[out[i].DocumentID], docRank[out[j].DocumentID]
+		if di != dj {
+			return di < dj
+		}
+		return out[i].PositionInDoc < out[j].PositionInDoc
+	})
+	return out
+}
+
+// truncateTokens shortens text to at most maxTokens tokens by the
+// chunker's estimate, keeping at least one word. Whitespace inside the kept
+// prefix is preserved so tables and lists keep their layout.
+func truncateTokens(text string, maxTokens int) string {
+	if chunker.EstimateTokens(text) <= maxTokens {
+		return text
+	}
+	// ends holds the offset just past each word.
+	var ends []int
+	inWord := false
+	for i, r := range text {
+		if unicode.IsSpace(r) {
+			if inWord {
+				ends = append(ends, i)
+			}
+			inWord = false
+			continue
+		}
+		inWord = true
+	}
+	if inWord {
+		ends = append(ends, len(text))
+	}
+	n := sort.Search(len(ends), func(i int) bool {
+		return chunker.EstimateTokens(text[:ends[i]]) > maxTokens
+	})
+	return text[:ends[max(n, 1)-1]] + " ..."
+}
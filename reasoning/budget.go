@@ -0,0 +1,124 @@
+package reasoning
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/bbiangul/go-reason/llm"
+)
+
+// defaultCallEstimate is how long one LLM call is assumed to take until a
+// call has been timed (Config.CallEstimate).
+const defaultCallEstimate = 5 * time.Second
+
+// callTimer keeps a moving average of chat call durations, so the round
+// budget follows the provider's actual latency.
+type callTimer struct {
+	mu  sync.Mutex
+	avg time.Duration
+}
+
+func (t *callTimer) observe(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.avg == 0 {
+		t.avg = d
+		return
+	}
+	t.avg = (t.avg*7 + d*3) / 10
+}
+
+func (t *callTimer) average() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.avg
+}
+
+// timedChat times the successful chat calls of a provider.
+type timedChat struct {
+	llm.Provider
+	timer *callTimer
+}
+
+func (c timedChat) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	start := time.Now()
+	resp, err := c.Provider.Chat(ctx, req)
+	if err == nil {
+		c.timer.observe(time.Since(start))
+	}
+	return resp, err
+}
+
+// CallsLeft returns how many LLM calls are expected to finish before ctx's
+// deadline, from the average duration of past calls, or -1 when ctx has no
+// deadline.
+func (e *Engine) CallsLeft(ctx context.Context) int {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return -1
+	}
+	per := e.timer.average()
+	if per == 0 {
+		per = e.cfg.CallEstimate
+	}
+	left := time.Until(deadline)
+	if left <= 0 {
+		return 0
+	}
+	return int(left / per)
+}
+
+// HasTimeFor reports whether n more LLM calls are expected to finish
+// before ctx's deadline.
+func (e *Engine) HasTimeFor(ctx context.Context, n int) bool {
+	left := e.CallsLeft(ctx)
+	return left < 0 || left >= n
+}
+
+// budgetRounds fits the strategy and round limit to the calls expected to
+// finish before the deadline. Rather than start a round that would be
+// cancelled, it drops the refinement round and falls back from the
+// multi-call strategies to a single consolidated prompt. It reports
+// whether anything was cut. The first answer is always attempted.
+func budgetRounds(strategy string, maxRounds, calls int) (string, int, bool) {
+	switch strategy {
+	case StrategyReAct:
+		// At least one search and the final answer.
+		if calls < 2 {
+			return StrategySingleShot, 1, true
+		}
+		if maxRounds > calls-1 {
+			return strategy, calls - 1, true
+		}
+	case StrategyPlanExecute:
+		// The plan and the synthesis; refinement needs a third call.
+		if calls < 2 {
+			return StrategySingleShot, 1, true
+		}
+		if calls < 3 && maxRounds >= 3 {
+			return strategy, 2, true
+		}
+	case StrategyMultiRound:
+		// Validation makes no LLM call; refinement does.
+		if calls < 2 && maxRounds >= 3 {
+			return strategy, 2, true
+		}
+	}
+	return strategy, maxRounds, false
+}
+
+// applyDeadline is budgetRounds for ctx's deadline.
+func (e *Engine) applyDeadline(ctx context.Context, strategy string, maxRounds int) (string, int, bool) {
+	calls := e.CallsLeft(ctx)
+	if calls < 0 {
+		return strategy, maxRounds, false
+	}
+	s, r, cut := budgetRounds(strategy, maxRounds, calls)
+	if cut {
+		slog.InfoContext(ctx, "reasoning: rounds cut to fit the deadline",
+			"calls_left", calls, "strategy", s, "max_rounds", r)
+	}
+	return s, r, cut
+}
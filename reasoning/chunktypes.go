@@ -0,0 +1,140 @@
+package reasoning
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Render modes for ChunkTypeTreatment.Render.
+const (
+	RenderPlain         = ""               // content as stored
+	RenderMarkdownTable = "markdown_table" // pipe/tab-delimited rows rendered as a markdown table
+)
+
+// ChunkTypeTreatment controls how chunks of one type are presented to the
+// model.
+type ChunkTypeTreatment struct {
+	Render      string // one of the Render* constants
+	Instruction string // note shown once above the context when this type is present
+}
+
+// renderChunk applies the chunk type's render mode to its content.
+func renderChunk(content string, t ChunkTypeTreatment) string {
+	switch t.Render {
+	case RenderMarkdownTable:
+		return renderMarkdownTable(content)
+	default:
+		return content
+	}
+}
+
+// typeInstructions lists the instructions for chunk types present in the
+// context, sorted by type name for a stable prompt.
+func typeInstructions(present map[string]bool, types map[string]ChunkTypeTreatment) string {
+	var names []string
+	for name := range present {
+		if types[name].Instruction != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteString("Notes on source types:\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "- [%s] %s\n", name, types[name].Instruction)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// renderMarkdownTable rewrites runs of pipe- or tab-delimited lines as
+// markdown tables: the first row becomes the header, a separator row is
+// inserted, and short rows are padded to the widest row. Other lines are
+// kept as they are. Content with no run of at least two rows is returned
+// unchanged.
+func renderMarkdownTable(content string) string {
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	var out []string
+	var block [][]string
+	converted := false
+
+	flush := func() {
+		if len(block) < 2 {
+			for _, row := range block {
+				out = append(out, "| "+strings.Join(row, " | ")+" |")
+			}
+			block = nil
+			return
+		}
+		width := 0
+		for _, row := range block {
+			if len(row) > width {
+				width = len(row)
+			}
+		}
+		for i, row := range block {
+			for len(row) < width {
+				row = append(row, "")
+			}
+			out = append(out, "| "+strings.Join(row, " | ")+" |")
+			if i == 0 {
+				out = append(out, "|"+strings.Repeat(" --- |", width))
+			}
+		}
+		block = nil
+		converted = true
+	}
+
+	for _, line := range lines {
+		cells, ok := tableCells(line)
+		if !ok {
+			flush()
+			out = append(out, line)
+			continue
+		}
+		if isSeparatorRow(cells) {
+			continue // re-emitted after the header
+		}
+		block = append(block, cells)
+	}
+	flush()
+
+	if !converted {
+		return content
+	}
+	return strings.Join(out, "\n")
+}
+
+// tableCells splits a pipe- or tab-delimited line into trimmed cells.
+func tableCells(line string) ([]string, bool) {
+	trimmed := strings.TrimSpace(line)
+	var raw []string
+	switch {
+	case strings.Count(trimmed, "|") >= 2:
+		raw = strings.Split(strings.Trim(trimmed, "|"), "|")
+	case strings.Contains(trimmed, "\t"):
+		raw = strings.Split(trimmed, "\t")
+	default:
+		return nil, false
+	}
+	cells := make([]string, len(raw))
+	for i, c := range raw {
+		cells[i] = strings.TrimSpace(c)
+	}
+	return cells, true
+}
+
+// isSeparatorRow reports whether cells form a markdown header separator
+// such as "--- | :---:".
+func isSeparatorRow(cells []string) bool {
+	for _, c := range cells {
+		if strings.Trim(c, "-: ") != "" || !strings.Contains(c, "-") {
+			return false
+		}
+	}
+	return len(cells) > 0
+}
@@ -0,0 +1,143 @@
+package reasoning
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Validation check kinds.
+const (
+	CheckCitePages    = "cite_pages"    // the answer must cite page numbers
+	CheckCiteArticles = "cite_articles" // the answer must cite article, clause, or section numbers
+	CheckMaxWords     = "max_words"     // the answer must not exceed Limit words
+	CheckBullets      = "bullets"       // the answer must be a bulleted or numbered list
+	CheckMustMatch    = "must_match"    // the answer must match Pattern
+	CheckMustNotMatch = "must_not_match"
+)
+
+// ValidationCheck is a caller-supplied criterion the validation round
+// enforces on top of the built-in citation and consistency checks. A
+// violation is reported in the validation Step's Issues and triggers a
+// refinement round when rounds remain.
+type ValidationCheck struct {
+	Kind    string         // one of the Check* constants
+	Limit   int            // word limit for CheckMaxWords
+	Pattern *regexp.Regexp // for CheckMustMatch and CheckMustNotMatch
+	Message string         // issue reported on violation; a default per kind when empty
+}
+
+var (
+	pageCitePattern    = regexp.MustCompile(`(?i)\b(pages?|pp?\.|p[áa]gina|pág\.)\s*\d+`)
+	articleCitePattern = regexp.MustCompile(`(?i)(\b(articles?|art\.|clauses?|sections?|sec\.|art[íi]culos?|cl[áa]usulas?|secci[óo]n)\s*\d+|§\s*\d+)`)
+	bulletLinePattern  = regexp.MustCompile(`^\s*([-*•]|\d+[.)])\s+`)
+)
+
+// ValidCheckKind reports whether kind is a known check.
+func ValidCheckKind(kind string) bool {
+	switch kind {
+	case CheckCitePages, CheckCiteArticles, CheckMaxWords, CheckBullets, CheckMustMatch, CheckMustNotMatch:
+		return true
+	}
+	return false
+}
+
+// violation returns the issue for answer, or "" when it passes.
+func (c ValidationCheck) violation(answer string) string {
+	var failed bool
+	var msg string
+	switch c.Kind {
+	case CheckCitePages:
+		failed = !pageCitePattern.MatchString(answer)
+		msg = "Answer does not cite page numbers"
+	case CheckCiteArticles:
+		failed = !articleCitePattern.MatchString(answer)
+		msg = "Answer does not cite article, clause, or section numbers"
+	case CheckMaxWords:
+		n := len(strings.Fields(answer))
+		failed = c.Limit > 0 && n > c.Limit
+		msg = fmt.Sprintf("Answer has %d words, more than the limit of %d", n, c.Limit)
+	case CheckBullets:
+		failed = !isBulleted(answer)
+		msg = "Answer is not in bullet form"
+	case CheckMustMatch:
+		failed = c.Pattern != nil && !c.Pattern.MatchString(answer)
+		msg = "Answer does not match the required pattern " + patternString(c.Pattern)
+	case CheckMustNotMatch:
+		failed = c.Pattern != nil && c.Pattern.MatchString(answer)
+		msg = "Answer matches the forbidden pattern " + patternString(c.Pattern)
+	}
+	if !failed {
+		return ""
+	}
+	if c.Message != "" {
+		return c.Message
+	}
+	return msg
+}
+
+// requirement describes the check as an instruction for the prompt.
+func (c ValidationCheck) requirement() string {
+	if c.Message != "" && (c.Kind == CheckMustMatch || c.Kind == CheckMustNotMatch) {
+		// A pattern means little to the model; the message says what it is for.
+		return c.Message
+	}
+	switch c.Kind {
+	case CheckCitePages:
+		return "Cite the page number of every source you use (e.g. \"page 12\")."
+	case CheckCiteArticles:
+		return "Cite the article, clause, or section number of every provision you rely on."
+	case CheckMaxWords:
+		return fmt.Sprintf("Answer in at most %d words.", c.Limit)
+	case CheckBullets:
+		return "Format the answer as a bulleted list."
+	}
+	return ""
+}
+
+// checkInstructions lists the requirements of checks for the system prompt,
+// so the first answer already aims to satisfy them.
+func checkInstructions(checks []ValidationCheck) string {
+	var lines []string
+	for _, c := range checks {
+		if r := c.requirement(); r != "" {
+			lines = append(lines, "- "+r)
+		}
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return "\n\nAnswer requirements:\n" + strings.Join(lines, "\n")
+}
+
+// validateCriteria runs the caller's checks.
+func validateCriteria(answer string, checks []ValidationCheck, result *validationResult) {
+	for _, c := range checks {
+		if issue := c.violation(answer); issue != "" {
+			result.criteriaIssues = append(result.criteriaIssues, issue)
+		}
+	}
+}
+
+// isBulleted reports whether most non-empty lines of answer, and at least
+// two, are list items. A lead-in sentence before the list is allowed.
+func isBulleted(answer string) bool {
+	var lines, items int
+	for _, line := range strings.Split(answer, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		lines++
+		if bulletLinePattern.MatchString(line) {
+			items++
+		}
+	}
+	return items >= 2 && items*2 >= lines
+}
+
+func patternString(p *regexp.Regexp) string {
+	if p == nil {
+		return ""
+	}
+	return p.String()
+}
@@ -0,0 +1,168 @@
+package reasoning
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/bbiangul/go-reason/chunker"
+	"github.com/bbiangul/go-reason/llm"
+	"github.com/bbiangul/go-reason/store"
+)
+
+// evidenceSearch is the follow-up retrieval the answer and refinement
+// rounds of the multi-round pipeline may request: instead of answering,
+// the model calls the search tool (see searchTool), the searches run, and
+// the round is asked again over the widened context. Budget bounds the
+// searches of one reasoning operation (Options.SearchBudget).
+type evidenceSearch struct {
+	retrieve RetrieveFunc
+	budget   int
+	done     int
+}
+
+// newEvidenceSearch returns nil when the reasoner cannot search.
+func newEvidenceSearch(retrieve RetrieveFunc, budget int) *evidenceSearch {
+	if retrieve == nil || budget <= 0 {
+		return nil
+	}
+	return &evidenceSearch{retrieve: retrieve, budget: budget}
+}
+
+// left returns how many searches the budget still allows.
+func (s *evidenceSearch) left() int {
+	if s == nil {
+		return 0
+	}
+	return s.budget - s.done
+}
+
+// used returns how many searches ran.
+func (s *evidenceSearch) used() int {
+	if s == nil {
+		return 0
+	}
+	return s.done
+}
+
+// searchInstructions offer the search tool in an answer or refinement
+// prompt.
+func searchInstructions(left int) string {
+	return fmt.Sprintf(`
+
+If the context does not contain information the question needs, you may call the search tool before answering; its results are added to the context. You can run %d more search(es). Otherwise answer as instructed.`, left)
+}
+
+// roundResult is the outcome of answerRound: the reply that answered,
+// the prompt that produced it, and the chunks it saw.
+type roundResult struct {
+	resp   *llm.ChatResponse
+	prompt string
+	chunks []store.RetrievalResult
+}
+
+// answerRound sends the prompt build makes from the rendered context.
+// When searches are left and the model asks for them instead of
+// answering, it runs them, records a "search" step for each, adds their
+// new chunks to the context, and sends the rebuilt prompt again. The
+// usage of every call is added to u.
+//
+// Replies are streamed through Config.StreamChecks; the first one a check
+// stops is recorded as a "stream_abort" step and asked for again with the
+// issue noted in the prompt.
+func (e *Engine) answerRound(ctx context.Context, system string, round int, chunks []store.RetrievalResult, search *evidenceSearch, u *usage, steps *[]Step, build func(contextStr string) string) (*roundResult, error) {
+	restarted := false
+	for {
+		start := time.Now()
+		contextStr := buildContext(chunks, e.cfg.ChunkTypes, e.cfg.QuoteSources)
+		prompt := build(contextStr)
+		// A search is only worth offering if the answer after it can
+		// still finish before the deadline.
+		offer := search.left() > 0 && e.HasTimeFor(ctx, 2)
+		if offer {
+			prompt += searchInstructions(search.left())
+		}
+
+		request := func(prompt string) llm.ChatRequest {
+			req := llm.ChatRequest{
+				Messages: []llm.Message{
+					{Role: "system", Content: system},
+					{Role: "user", Content: prompt, CachePrefix: len(contextBlock(contextStr))},
+				},
+				Temperature: 0,
+			}
+			if offer {
+				req.Tools = []llm.Tool{searchTool}
+			}
+			return req
+		}
+		resp, err := e.chatChecked(ctx, request(prompt), prompt, !restarted)
+		var abort *streamAbort
+		if errors.As(err, &abort) {
+			promptTokens, completionTokens := chunker.EstimateTokens(system+prompt), chunker.EstimateTokens(abort.partial)
+			u.prompt += promptTokens
+			u.completion += completionTokens
+			u.total += promptTokens + completionTokens
+			addStep(ctx, steps, Step{
+				Round:      round,
+				Action:     "stream_abort",
+				Prompt:     prompt,
+				Response:   abort.partial,
+				ChunksUsed: len(chunks),
+				Tokens:     promptTokens + completionTokens,
+				ElapsedMs:  time.Since(start).Milliseconds(),
+				Issues:     []string{abort.issue},
+			})
+			slog.InfoContext(ctx, "reasoning: reply stopped while streaming, asking again", "round", round, "issue", abort.issue)
+			restarted = true
+			prompt += restartNote(abort.issue)
+			resp, err = e.chat.Chat(ctx, request(prompt))
+		}
+		if err != nil {
+			return nil, err
+		}
+		u.add(resp)
+
+		var queries []string
+		for _, call := range resp.ToolCalls {
+			if q := searchQuery(call); q != "" {
+				queries = append(queries, q)
+			}
+		}
+		if !offer || len(queries) == 0 {
+			return &roundResult{resp: resp, prompt: prompt, chunks: chunks}, nil
+		}
+		// Merge into a copy; chunks may share the caller's array.
+		chunks = append([]store.RetrievalResult(nil), chunks...)
+		for i, q := range queries {
+			if search.left() == 0 {
+				break
+			}
+			search.done++
+			output := "retrieval failed"
+			found, rerr := search.retrieve(ctx, q)
+			if rerr != nil {
+				slog.WarnContext(ctx, "reasoning: evidence search failed (non-fatal)", "query", q, "error", rerr)
+			} else {
+				var added int
+				chunks, added = mergeChunks(chunks, found, maxNewChunksPerSearch)
+				output = fmt.Sprintf("%d new sources added to the context", added)
+			}
+			step := Step{
+				Round:      round,
+				Action:     "search",
+				Input:      q,
+				Output:     output,
+				ChunksUsed: len(chunks),
+				ElapsedMs:  time.Since(start).Milliseconds(),
+			}
+			if i == 0 {
+				step.Prompt, step.Response, step.Tokens = prompt, resp.Content, resp.TotalTokens
+			}
+			addStep(ctx, steps, step)
+			slog.InfoContext(ctx, "reasoning: evidence search", "round", round, "query", q, "result", output)
+		}
+	}
+}
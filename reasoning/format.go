@@ -0,0 +1,180 @@
+package reasoning
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"github.com/bbiangul/go-reason/llm"
+)
+
+// Answer formats (Options.Format). Empty leaves the layout to the model.
+const (
+	FormatMarkdown = "markdown" // headings, lists, and emphasis in Markdown
+	FormatPlain    = "plain"    // prose without any markup
+	FormatJSON     = "json"     // an AnswerSections object
+)
+
+// ValidFormat reports whether format is empty or one of the Format*
+// constants.
+func ValidFormat(format string) bool {
+	switch format {
+	case "", FormatMarkdown, FormatPlain, FormatJSON:
+		return true
+	}
+	return false
+}
+
+// AnswerSections is the answer in FormatJSON: a short summary, the
+// supporting details, caveats such as missing or conflicting information,
+// and the sources cited.
+type AnswerSections struct {
+	Summary   string   `json:"summary"`
+	Details   string   `json:"details"`
+	Caveats   []string `json:"caveats"`
+	Citations []string `json:"citations"`
+}
+
+// formatInstructions returns the system prompt rule for format.
+func formatInstructions(format string) string {
+	switch format {
+	case FormatMarkdown:
+		return "\n\nOutput format: Markdown. Start with a one-sentence answer, then use `##` headings for sections, `-` bullet lists for enumerations, and **bold** for key values. Put citations in parentheses after the facts they support. Do not wrap the answer in a code block."
+	case FormatPlain:
+		return "\n\nOutput format: plain prose. Write complete sentences in paragraphs separated by blank lines. Do not use Markdown or any markup: no headings, no bullet or numbered lists, no bold or italics, no tables, no code blocks. Put citations in parentheses after the facts they support."
+	case FormatJSON:
+		return "\n\nOutput format: a single JSON object and nothing else, no code fences:\n" +
+			`{"summary": "<the answer in one or two sentences>", "details": "<the supporting facts, with citations in parentheses>", "caveats": ["<missing, uncertain, or conflicting information>"], "citations": ["<filename, section or page>"]}` +
+			"\nUse empty lists when there are no caveats or citations. When the documents do not contain the answer, say so in summary."
+	}
+	return ""
+}
+
+var (
+	fencePattern     = regexp.MustCompile("(?s)^```[a-zA-Z]*\\s*\\n(.*?)\\n?```$")
+	mdHeadingPattern = regexp.MustCompile(`(?m)^#{1,6}[ \t]+`)
+	mdBulletPattern  = regexp.MustCompile(`(?m)^[ \t]*[-*+][ \t]+`)
+	mdEmphPattern    = regexp.MustCompile(`(\*\*|__)(.+?)(\*\*|__)`)
+	mdItalicPattern  = regexp.MustCompile(`(^|\s)[*_]([^*_\s][^*_]*?)[*_]`)
+	mdLinkPattern    = regexp.MustCompile(`\[([^\]]+)\]\([^)]+\)`)
+	mdCodePattern    = regexp.MustCompile("`([^`]*)`")
+	mdRulePattern    = regexp.MustCompile(`(?m)^[ \t]*(-{3,}|\*{3,}|_{3,})[ \t]*$\n?`)
+	mdTableSep       = regexp.MustCompile(`(?m)^[ \t]*\|?[ \t:|-]+\|[ \t:|-]*$\n?`)
+)
+
+// stripFence removes a code fence wrapping the whole text.
+func stripFence(text string) string {
+	text = strings.TrimSpace(text)
+	if m := fencePattern.FindStringSubmatch(text); m != nil {
+		return strings.TrimSpace(m[1])
+	}
+	return text
+}
+
+// stripMarkdown turns Markdown into plain text, keeping the words of
+// headings, list items, links, and table cells.
+func stripMarkdown(text string) string {
+	text = stripFence(text)
+	text = mdRulePattern.ReplaceAllString(text, "")
+	text = mdTableSep.ReplaceAllString(text, "")
+	text = mdHeadingPattern.ReplaceAllString(text, "")
+	text = mdBulletPattern.ReplaceAllString(text, "")
+	text = mdLinkPattern.ReplaceAllString(text, "$1")
+	text = mdEmphPattern.ReplaceAllString(text, "$2")
+	text = mdItalicPattern.ReplaceAllString(text, "$1$2")
+	text = mdCodePattern.ReplaceAllString(text, "$1")
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if t := strings.TrimSpace(line); strings.HasPrefix(t, "|") && strings.HasSuffix(t, "|") {
+			cells := strings.Split(strings.Trim(t, "|"), "|")
+			for j := range cells {
+				cells[j] = strings.TrimSpace(cells[j])
+			}
+			lines[i] = strings.Join(cells, ", ")
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// ParseAnswerSections parses a FormatJSON answer, tolerating a code fence
+// or text around the object. The summary is required.
+func ParseAnswerSections(text string) (*AnswerSections, error) {
+	text = llm.JSONObject(text)
+	if !strings.HasPrefix(text, "{") {
+		return nil, fmt.Errorf("no JSON object in answer")
+	}
+	var s AnswerSections
+	if err := json.Unmarshal([]byte(text), &s); err != nil {
+		return nil, fmt.Errorf("decoding answer sections: %w", err)
+	}
+	if strings.TrimSpace(s.Summary) == "" {
+		return nil, fmt.Errorf("answer sections have no summary")
+	}
+	if s.Caveats == nil {
+		s.Caveats = []string{}
+	}
+	if s.Citations == nil {
+		s.Citations = []string{}
+	}
+	return &s, nil
+}
+
+const sectionsRepairPrompt = `Convert the answer below into a JSON object with exactly these fields, keeping its facts and citations unchanged:
+{"summary": "<the answer in one or two sentences>", "details": "<the supporting facts>", "caveats": ["<missing, uncertain, or conflicting information>"], "citations": ["<filename, section or page>"]}
+
+Answer:
+%s`
+
+// applyFormat checks the final answer against format and repairs it when
+// the model did not comply: Markdown fences are removed, plain answers are
+// stripped of markup, and a JSON answer that does not parse is converted
+// by one more LLM call, or else wrapped whole as the summary. Text holds
+// the JSON encoding of Sections for FormatJSON.
+func (e *Engine) applyFormat(ctx context.Context, answer *Answer, format string) {
+	switch format {
+	case FormatMarkdown:
+		answer.Text = stripFence(answer.Text)
+	case FormatPlain:
+		answer.Text = stripMarkdown(answer.Text)
+	case FormatJSON:
+		sections, err := ParseAnswerSections(answer.Text)
+		if err != nil {
+			slog.DebugContext(ctx, "reasoning: answer is not valid JSON sections, repairing", "error", err)
+			sections = e.repairSections(ctx, answer)
+		}
+		data, _ := json.Marshal(sections)
+		answer.Text = string(data)
+		answer.Sections = sections
+	}
+}
+
+// repairSections asks the model to convert answer to AnswerSections,
+// adding the call's tokens to answer. It falls back to the whole answer as
+// the summary, also when the call would not finish before the deadline.
+func (e *Engine) repairSections(ctx context.Context, answer *Answer) *AnswerSections {
+	if !e.HasTimeFor(ctx, 1) {
+		slog.InfoContext(ctx, "reasoning: no time left to repair JSON sections, using the answer as summary")
+		answer.DeadlineLimited = true
+		return &AnswerSections{Summary: strings.TrimSpace(answer.Text), Caveats: []string{}, Citations: []string{}}
+	}
+	resp, err := e.chat.Chat(ctx, llm.ChatRequest{
+		Messages:       []llm.Message{{Role: "user", Content: fmt.Sprintf(sectionsRepairPrompt, answer.Text)}},
+		Temperature:    0,
+		ResponseFormat: "json_object",
+	})
+	if err == nil {
+		answer.PromptTokens += resp.PromptTokens
+		answer.CompletionTokens += resp.CompletionTokens
+		answer.TotalTokens = answer.PromptTokens + answer.CompletionTokens
+		var sections *AnswerSections
+		if sections, err = ParseAnswerSections(resp.Content); err == nil {
+			return sections
+		}
+	}
+	slog.WarnContext(ctx, "reasoning: repairing JSON sections failed, using the answer as summary (non-fatal)", "error", err)
+	return &AnswerSections{Summary: strings.TrimSpace(answer.Text), Caveats: []string{}, Citations: []string{}}
+}
@@ -0,0 +1,31 @@
+package reasoning
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GlossaryTerm is an abbreviation and its definition as given in a source
+// document.
+type GlossaryTerm struct {
+	Abbreviation string
+	Definition   string
+	Source       string // filename of the defining document
+}
+
+// glossaryInstructions lists the terms for the system prompt, so the model
+// reads abbreviations the way the documents define them.
+func glossaryInstructions(terms []GlossaryTerm) string {
+	if len(terms) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n\nGlossary (abbreviations as defined in the documents):")
+	for _, t := range terms {
+		fmt.Fprintf(&b, "\n- %s: %s", t.Abbreviation, t.Definition)
+		if t.Source != "" {
+			fmt.Fprintf(&b, " (%s)", t.Source)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,48 @@
+package reasoning
+
+import "fmt"
+
+// AnswerLanguageAuto answers in the language of the question, whatever the
+// language of the sources.
+const AnswerLanguageAuto = "auto"
+
+// languageNames maps ISO 639-1 codes to the names used in prompts.
+var languageNames = map[string]string{
+	"en": "English",
+	"es": "Spanish",
+	"pt": "Portuguese",
+	"fr": "French",
+	"de": "German",
+	"it": "Italian",
+	"nl": "Dutch",
+	"ca": "Catalan",
+}
+
+// LanguageName returns the English name of an ISO 639-1 code, or the code
+// itself when it is not known.
+func LanguageName(code string) string {
+	if name, ok := languageNames[code]; ok {
+		return name
+	}
+	return code
+}
+
+// answerSystemPrompt returns the system prompt with a rule fixing the answer
+// language. An empty lang leaves the model free to choose, which with a
+// corpus in one language and a question in another often yields mixed-
+// language answers. With quote the source text rule precedes it.
+func answerSystemPrompt(lang string, quote bool) string {
+	const keep = " Translate the facts, but keep identifiers, part numbers, units and clause references exactly as written in the sources."
+	prompt, rule := systemPrompt, 7
+	if quote {
+		prompt, rule = systemPrompt+sourceTextRule, 8
+	}
+	switch lang {
+	case "":
+		return prompt
+	case AnswerLanguageAuto:
+		return prompt + fmt.Sprintf("\n%d. Answer in the same language as the question, even when the sources are in another language.", rule) + keep
+	default:
+		return prompt + fmt.Sprintf("\n%d. Answer in %s, even when the question or the sources are in another language.", rule, LanguageName(lang)) + keep
+	}
+}
@@ -0,0 +1,90 @@
+package reasoning
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/bbiangul/go-reason/store"
+)
+
+// Document order modes for Options.DocumentOrder.
+const (
+	DocumentOrderOff    = ""       // chunks keep their retrieval (rank) order
+	DocumentOrderAuto   = "auto"   // document order for procedural questions only
+	DocumentOrderAlways = "always" // document order for every question
+)
+
+// proceduralPatterns mark questions asking for a sequence of steps. The
+// corpus is multilingual, so the common Spanish and Portuguese forms are
+// included.
+var proceduralPatterns = []string{
+	"steps to", "steps for", "what steps", "step by step", "step-by-step",
+	"procedure", "how do i", "how do you", "how to ", "in what order",
+	"sequence of", "process for", "process to",
+	"pasos", "procedimiento", "cómo se", "como se",
+	"passos", "procedimento", "como fazer",
+}
+
+// IsProceduralQuestion reports whether the question asks for steps or a
+// procedure, whose answer depends on the order the sources are read in.
+func IsProceduralQuestion(question string) bool {
+	lower := strings.ToLower(question)
+	for _, p := range proceduralPatterns {
+		if strings.Contains(lower, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// useDocumentOrder reports whether mode calls for document order on this
+// question.
+func useDocumentOrder(mode, question string) bool {
+	switch mode {
+	case DocumentOrderAlways:
+		return true
+	case DocumentOrderAuto:
+		return IsProceduralQuestion(question)
+	default:
+		return false
+	}
+}
+
+// orderByPosition re-orders chunks the way they appear in their documents,
+// so step sequences are not presented out of order. Documents keep the
+// order of their best-ranked chunk. Within a document, chunks of the same
+// section (heading) are kept together as one run, placed where the section
+// first appears, and ordered by position inside it.
+func orderByPosition(chunks []store.RetrievalResult) []store.RetrievalResult {
+	type sectionKey struct {
+		doc     int64
+		heading string
+	}
+	docRank := make(map[int64]int)
+	sectionStart := make(map[sectionKey]int)
+	for i, c := range chunks {
+		if _, ok := docRank[c.DocumentID]; !ok {
+			docRank[c.DocumentID] = i
+		}
+		k := sectionKey{c.DocumentID, c.Heading}
+		if pos, ok := sectionStart[k]; !ok || c.PositionInDoc < pos {
+			sectionStart[k] = c.PositionInDoc
+		}
+	}
+
+	out := make([]store.RetrievalResult, len(chunks))
+	copy(out, chunks)
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if da, db := docRank[a.DocumentID], docRank[b.DocumentID]; da != db {
+			return da < db
+		}
+		sa := sectionStart[sectionKey{a.DocumentID, a.Heading}]
+		sb := sectionStart[sectionKey{b.DocumentID, b.Heading}]
+		if sa != sb {
+			return sa < sb
+		}
+		return a.PositionInDoc < b.PositionInDoc
+	})
+	return out
+}
@@ -0,0 +1,398 @@
+package reasoning
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/bbiangul/go-reason/llm"
+	"github.com/bbiangul/go-reason/store"
+)
+
+// Reasoning strategies selectable via Config.Strategy or Options.Strategy.
+const (
+	// StrategyMultiRound answers, validates, and refines when confidence is
+	// below the threshold. This is the default.
+	StrategyMultiRound = "multi_round"
+	// StrategySingleShot answers in one LLM call with no validation round.
+	// Cheapest option, suited to simple lookup questions.
+	StrategySingleShot = "single_shot"
+	// StrategyReAct interleaves reasoning with retrieval: the model may issue
+	// search actions for missing evidence before committing to an answer.
+	StrategyReAct = "react"
+	// StrategyPlanExecute decomposes the question into sub-questions,
+	// retrieves evidence for each, then synthesises a single answer.
+	StrategyPlanExecute = "plan_execute"
+)
+
+// maxPlanSteps caps the number of sub-questions in a plan-and-execute plan.
+const maxPlanSteps = 4
+
+// maxNewChunksPerSearch caps how many new chunks a single ReAct search or
+// plan step adds to the evidence set, so the context does not balloon.
+const maxNewChunksPerSearch = 6
+
+// usage accumulates token counts across LLM calls.
+type usage struct {
+	prompt, completion, total, cached int
+}
+
+func (u *usage) add(resp *llm.ChatResponse) {
+	u.prompt += resp.PromptTokens
+	u.completion += resp.CompletionTokens
+	u.total += resp.TotalTokens
+	u.cached += resp.CachedTokens
+}
+
+// mergeChunks appends chunks from extra that are not already in existing,
+// up to limit new chunks. Returns the merged slice and the number added.
+func mergeChunks(existing, extra []store.RetrievalResult, limit int) ([]store.RetrievalResult, int) {
+	seen := make(map[int64]bool, len(existing))
+	for _, c := range existing {
+		seen[c.ChunkID] = true
+	}
+	added := 0
+	for _, c := range extra {
+		if added >= limit {
+			break
+		}
+		if seen[c.ChunkID] {
+			continue
+		}
+		seen[c.ChunkID] = true
+		existing = append(existing, c)
+		added++
+	}
+	return existing, added
+}
+
+// --- ReAct ---
+
+// searchTool is the tool through which the model searches the documents
+// for evidence missing from its context.
+var searchTool = llm.Tool{
+	Name:        "search",
+	Description: "Search the documents for information the context is missing. The results are added to the context.",
+	Parameters: map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"query": map[string]interface{}{
+				"type":        "string",
+				"description": "A short search query for the missing information",
+			},
+		},
+		"required": []string{"query"},
+	},
+}
+
+// searchQuery returns the query of a search tool call, or "" when call is
+// not one or has no query.
+func searchQuery(call llm.ToolCall) string {
+	if call.Name != searchTool.Name {
+		return ""
+	}
+	var args struct {
+		Query string `json:"query"`
+	}
+	if err := json.Unmarshal(call.Arguments, &args); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(args.Query)
+}
+
+const reactInstructions = `Work step by step: think about what the context already says and what is missing. While information the question needs is missing, call the search tool with a short query for it; its results are added to the context. Once the context is sufficient, reply with the final answer, citing sources.`
+
+// reactReasoningInstructions replace reactInstructions for reasoning
+// models, which think before replying on their own and do worse when told
+// to write their reasoning out.
+const reactReasoningInstructions = `While information the question needs is missing from the context, call the search tool with a short query for it; its results are added to the context. Once the context is sufficient, reply with the final answer, citing sources.`
+
+// reactFinalNote ends the conversation of the last ReAct round.
+const reactFinalNote = "No more searches are allowed. Reply with the final answer now."
+
+// reasonReAct runs a ReAct loop: the model alternates between thinking and
+// calling the search tool until it answers or the step budget (maxRounds
+// searching rounds) runs out. The context is rebuilt with the evidence
+// found so far each round; the tool calls and their results stay in the
+// conversation.
+func (e *Engine) reasonReAct(ctx context.Context, system, question string, chunks []store.RetrievalResult, maxRounds int, retrieve RetrieveFunc, checks []ValidationCheck) (*Answer, error) {
+	evidence := append([]store.RetrievalResult(nil), chunks...)
+	var steps []Step
+	var history []llm.Message
+	var u usage
+	var modelUsed, answer string
+	var limited bool
+	rounds := 0
+
+	for round := 1; round <= maxRounds+1 && answer == ""; round++ {
+		rounds = round
+		final := round > maxRounds
+		// A search is only worth its call if the answer after it can
+		// still finish before the deadline.
+		if !final && !e.HasTimeFor(ctx, 2) {
+			final, limited = true, true
+		}
+		prompt := buildReActPrompt(question, buildContext(evidence, e.cfg.ChunkTypes, e.cfg.QuoteSources), e.reasoningModel())
+		messages := append([]llm.Message{
+			{Role: "system", Content: system},
+			{Role: "user", Content: prompt},
+		}, history...)
+		req := llm.ChatRequest{Messages: messages, Tools: []llm.Tool{searchTool}, Temperature: 0}
+		if final {
+			req.Messages = append(req.Messages, llm.Message{Role: "user", Content: reactFinalNote})
+			req.ToolChoice = "none"
+		}
+
+		start := time.Now()
+		resp, err := e.chat.Chat(ctx, req)
+		if err != nil {
+			if round == 1 {
+				return nil, fmt.Errorf("react step 1: %w", err)
+			}
+			break
+		}
+		modelUsed = resp.Model
+		u.add(resp)
+
+		text := strings.TrimSpace(resp.Content)
+		if final || (len(resp.ToolCalls) == 0 && text != "") {
+			answer = text
+			addStep(ctx, &steps, Step{
+				Round:      round,
+				Action:     "answer",
+				Output:     answer,
+				Prompt:     prompt,
+				Response:   resp.Content,
+				ChunksUsed: len(evidence),
+				Tokens:     resp.TotalTokens,
+				ElapsedMs:  time.Since(start).Milliseconds(),
+			})
+			break
+		}
+
+		if len(resp.ToolCalls) == 0 {
+			// An empty reply: ask again for a search or the answer.
+			history = append(history,
+				llm.Message{Role: "assistant", Content: resp.Content},
+				llm.Message{Role: "user", Content: "Call the search tool or reply with the final answer."})
+			addStep(ctx, &steps, Step{
+				Round:      round,
+				Action:     "search",
+				Output:     "no search or answer in the reply",
+				Prompt:     prompt,
+				Response:   resp.Content,
+				ChunksUsed: len(evidence),
+				Tokens:     resp.TotalTokens,
+				ElapsedMs:  time.Since(start).Milliseconds(),
+			})
+			continue
+		}
+
+		history = append(history, llm.Message{Role: "assistant", Content: resp.Content, ToolCalls: resp.ToolCalls})
+		for i, call := range resp.ToolCalls {
+			query := searchQuery(call)
+			observation := "unknown tool or missing query; call search with a query"
+			if query != "" {
+				observation = "retrieval failed"
+				found, rerr := retrieve(ctx, query)
+				if rerr != nil {
+					slog.WarnContext(ctx, "reasoning: react search failed (non-fatal)", "query", query, "error", rerr)
+				} else {
+					var added int
+					evidence, added = mergeChunks(evidence, found, maxNewChunksPerSearch)
+					observation = fmt.Sprintf("%d new sources added to the context", added)
+				}
+			}
+			history = append(history, llm.Message{Role: "tool", Content: observation, ToolCallID: call.ID})
+
+			step := Step{
+				Round:      round,
+				Action:     "search",
+				Input:      query,
+				Output:     observation,
+				ChunksUsed: len(evidence),
+				ElapsedMs:  time.Since(start).Milliseconds(),
+			}
+			if i == 0 {
+				step.Prompt, step.Response, step.Tokens = prompt, resp.Content, resp.TotalTokens
+			}
+			addStep(ctx, &steps, step)
+		}
+	}
+
+	if answer == "" {
+		return nil, fmt.Errorf("react: no answer produced after %d steps", len(steps))
+	}
+
+	validation := validate(answer, evidence, checks...)
+	validationIssues := validation.issues()
+	addStep(ctx, &steps, Step{
+		Round:      rounds,
+		Action:     "validation",
+		Input:      answer,
+		Output:     validation.summary(),
+		Validation: validation.summary(),
+		Issues:     validationIssues,
+	})
+	return &Answer{
+		Text:             answer,
+		Confidence:       validation.confidence(),
+		Issues:           validationIssues,
+		Sources:          toSources(evidence),
+		Reasoning:        steps,
+		ModelUsed:        modelUsed,
+		Rounds:           rounds,
+		PromptTokens:     u.prompt,
+		CompletionTokens: u.completion,
+		TotalTokens:      u.total,
+		CachedTokens:     u.cached,
+		DeadlineLimited:  limited,
+	}, nil
+}
+
+func buildReActPrompt(question, context string, reasoningModel bool) string {
+	instructions := reactInstructions
+	if reasoningModel {
+		instructions = reactReasoningInstructions
+	}
+	return fmt.Sprintf("Context:\n%s\nQuestion: %s\n\n%s\n", context, question, instructions)
+}
+
+// --- Plan and execute ---
+
+const planPrompt = `Break the question below into at most %d self-contained sub-questions that together cover everything needed to answer it. Simple questions need only one.
+
+Return a JSON object: {"steps": ["sub-question 1", "sub-question 2"]}
+Do NOT include any text outside the JSON object.
+
+Question: %s`
+
+// reasonPlanExecute plans sub-questions, retrieves evidence for each, and
+// synthesises a final answer over the combined evidence.
+func (e *Engine) reasonPlanExecute(ctx context.Context, system, question string, chunks []store.RetrievalResult, maxRounds int, retrieve RetrieveFunc, checks []ValidationCheck, search *evidenceSearch) (*Answer, error) {
+	evidence := append([]store.RetrievalResult(nil), chunks...)
+	var steps []Step
+	var u usage
+
+	// Plan
+	start := time.Now()
+	prompt := fmt.Sprintf(planPrompt, maxPlanSteps, question)
+	resp, err := e.chat.Chat(ctx, llm.ChatRequest{
+		Messages:       []llm.Message{{Role: "user", Content: prompt}},
+		Temperature:    0,
+		ResponseFormat: "json_object",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("plan generation: %w", err)
+	}
+	u.add(resp)
+	plan := parsePlan(resp.Content)
+	if len(plan) == 0 {
+		plan = []string{question}
+	}
+	addStep(ctx, &steps, Step{
+		Round:     1,
+		Action:    "plan",
+		Input:     question,
+		Output:    strings.Join(plan, "\n"),
+		Prompt:    prompt,
+		Response:  resp.Content,
+		Tokens:    resp.TotalTokens,
+		ElapsedMs: time.Since(start).Milliseconds(),
+	})
+
+	// Execute: retrieve evidence per sub-question.
+	for _, sub := range plan {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("plan execution: %w", err)
+		}
+		start := time.Now()
+		found, rerr := retrieve(ctx, sub)
+		output := ""
+		if rerr != nil {
+			slog.WarnContext(ctx, "reasoning: plan step retrieval failed (non-fatal)", "step", sub, "error", rerr)
+			output = "retrieval failed"
+		} else {
+			var added int
+			evidence, added = mergeChunks(evidence, found, maxNewChunksPerSearch)
+			output = fmt.Sprintf("%d new sources added to the context", added)
+		}
+		addStep(ctx, &steps, Step{
+			Round:      len(steps) + 1,
+			Action:     "execute",
+			Input:      sub,
+			Output:     output,
+			ChunksUsed: len(evidence),
+			ElapsedMs:  time.Since(start).Milliseconds(),
+		})
+	}
+
+	// Synthesise the final answer over the combined evidence, then let the
+	// multi-round pipeline validate and refine it.
+	// Its rounds follow the plan and execute steps, in the trace and as
+	// they are reported to OnStep.
+	offset := LastRound(steps)
+	synthCtx := ctx
+	if onStep, _ := ctx.Value(stepHookKey{}).(func(Step)); onStep != nil {
+		synthCtx = context.WithValue(ctx, stepHookKey{}, func(s Step) {
+			s.Round += offset
+			onStep(s)
+		})
+	}
+	synth, err := e.reasonMultiRound(synthCtx, system, buildPlannedQuestion(question, plan), evidence, maxRounds, checks, search)
+	if err != nil {
+		return nil, fmt.Errorf("plan synthesis: %w", err)
+	}
+	for _, s := range synth.Reasoning {
+		s.Round += offset
+		steps = append(steps, s)
+	}
+
+	synth.Reasoning = steps
+	synth.Rounds = LastRound(steps)
+	synth.PromptTokens += u.prompt
+	synth.CompletionTokens += u.completion
+	synth.TotalTokens += u.total
+	synth.CachedTokens += u.cached
+	return synth, nil
+}
+
+// parsePlan decodes the plan JSON, tolerating surrounding prose, and caps the
+// number of steps at maxPlanSteps.
+func parsePlan(raw string) []string {
+	raw = llm.JSONObject(raw)
+	var result struct {
+		Steps []string `json:"steps"`
+	}
+	if err := json.Unmarshal([]byte(raw), &result); err != nil {
+		return nil
+	}
+	var plan []string
+	for _, s := range result.Steps {
+		if s = strings.TrimSpace(s); s != "" {
+			plan = append(plan, s)
+		}
+		if len(plan) == maxPlanSteps {
+			break
+		}
+	}
+	return plan
+}
+
+// buildPlannedQuestion restates the question with its plan so the synthesis
+// call addresses every sub-question.
+func buildPlannedQuestion(question string, plan []string) string {
+	if len(plan) <= 1 {
+		return question
+	}
+	var b strings.Builder
+	b.WriteString(question)
+	b.WriteString("\n\nAddress each of these points:\n")
+	for i, p := range plan {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, p)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
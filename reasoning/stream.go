@@ -0,0 +1,107 @@
+package reasoning
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/bbiangul/go-reason/llm"
+)
+
+// StreamCheck sets up a check of the reply to prompt while it streams in.
+// The returned function is called with the reply so far whenever a word or
+// sentence ends, and returns why the rest of the reply is not worth
+// generating, or "" to let it continue. A stopped reply is asked for again
+// once, without checks (see Config.StreamChecks).
+type StreamCheck func(prompt string) func(partial string) string
+
+// quantityPattern matches a number with a unit, such as "12 bar" or
+// "4,5 mm". The character after the unit is required, so a quantity at the
+// end of a partial reply ("10 m" of "10 mm") is left until it is complete.
+// One-letter units (m, s, h, l, ...) are left out: after a number they are
+// as often a list marker or an abbreviation as a unit.
+var quantityPattern = regexp.MustCompile(`\b(\d+(?:[.,]\d+)?)\s?(%|°\s?[CF]|[kMG]?Pa|bar|psi|mm|cm|km|kg|kV|mV|mA|kWh|kW|MW|kHz|Hz|rpm|Nm|mL|ml|hours?|minutes?|min|seconds?|days?)[^\p{L}\d]`)
+
+// numberPattern matches the numbers of a prompt.
+var numberPattern = regexp.MustCompile(`\d+(?:[.,]\d+)?`)
+
+// CheckUnsupportedNumbers stops a reply stating a quantity whose number is
+// neither in the sources nor in the question, the usual sign of a model
+// drifting from the context into recalled or made-up values.
+func CheckUnsupportedNumbers(prompt string) func(partial string) string {
+	known := make(map[string]bool)
+	for _, n := range numberPattern.FindAllString(prompt, -1) {
+		known[normalizeNumber(n)] = true
+	}
+	return func(partial string) string {
+		for _, m := range quantityPattern.FindAllStringSubmatch(partial, -1) {
+			if !known[normalizeNumber(m[1])] {
+				quantity := strings.TrimRightFunc(m[0], func(r rune) bool { return !unicode.IsLetter(r) && r != '%' })
+				return fmt.Sprintf("%q is not in the sources", quantity)
+			}
+		}
+		return ""
+	}
+}
+
+// normalizeNumber makes "4,50" and "4.5" the same number.
+func normalizeNumber(n string) string {
+	n = strings.ReplaceAll(n, ",", ".")
+	if strings.Contains(n, ".") {
+		n = strings.TrimSuffix(strings.TrimRight(n, "0"), ".")
+	}
+	return n
+}
+
+// streamAbort is the error chatChecked returns when a check stopped the
+// reply.
+type streamAbort struct {
+	issue   string
+	partial string // the reply up to where it was stopped
+}
+
+func (a *streamAbort) Error() string { return "reply stopped: " + a.issue }
+
+// chatChecked sends req, streaming the reply through the configured
+// checks when checks is set and the provider streams. Reasoning models
+// are not checked: their thinking is streamed too. A stopped reply is
+// returned as a *streamAbort.
+func (e *Engine) chatChecked(ctx context.Context, req llm.ChatRequest, prompt string, checks bool) (*llm.ChatResponse, error) {
+	if !checks || e.stream == nil || len(e.cfg.StreamChecks) == 0 || e.reasoningModel() {
+		return e.chat.Chat(ctx, req)
+	}
+	running := make([]func(string) string, len(e.cfg.StreamChecks))
+	for i, check := range e.cfg.StreamChecks {
+		running[i] = check(prompt)
+	}
+
+	start := time.Now()
+	var partial strings.Builder
+	resp, err := e.stream.ChatStream(ctx, req, func(delta string) error {
+		partial.WriteString(delta)
+		if !strings.ContainsFunc(delta, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+			return nil
+		}
+		for _, check := range running {
+			if issue := check(partial.String()); issue != "" {
+				return &streamAbort{issue: issue, partial: partial.String()}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	e.timer.observe(time.Since(start))
+	return resp, nil
+}
+
+// restartNote is appended to the prompt of a reply asked for again after
+// a check stopped it.
+func restartNote(issue string) string {
+	return "\n\nA previous reply to this prompt was stopped: " + issue +
+		". Answer again, stating only values that appear in the sources."
+}
@@ -14,6 +14,7 @@ type validationResult struct {
 	consistencyIssues []string
 	completenessValid bool
 	completenessIssues []string
+	criteriaIssues   []string // violated caller-supplied checks (criteria.go)
 }
 
 func (v *validationResult) summary() string {
@@ -28,6 +29,9 @@ func (v *validationResult) summary() string {
 	if !v.completenessValid {
 		parts = append(parts, "Completeness issues: "+strings.Join(v.completenessIssues, "; "))
 	}
+	if len(v.criteriaIssues) > 0 {
+		parts = append(parts, "Requirement violations: "+strings.Join(v.criteriaIssues, "; "))
+	}
 
 	if len(parts) == 0 {
 		return "All validations passed."
@@ -35,6 +39,15 @@ func (v *validationResult) summary() string {
 	return strings.Join(parts, "\n")
 }
 
+// issues lists every problem found, in summary order.
+func (v *validationResult) issues() []string {
+	var issues []string
+	issues = append(issues, v.citationIssues...)
+	issues = append(issues, v.consistencyIssues...)
+	issues = append(issues, v.completenessIssues...)
+	return append(issues, v.criteriaIssues...)
+}
+
 func (v *validationResult) confidence() float64 {
 	score := 1.0
 
@@ -47,6 +60,7 @@ func (v *validationResult) confidence() float64 {
 	if !v.completenessValid {
 		score -= 0.1 * float64(len(v.completenessIssues))
 	}
+	score -= 0.1 * float64(len(v.criteriaIssues))
 
 	if score < 0 {
 		score = 0
@@ -54,8 +68,8 @@ func (v *validationResult) confidence() float64 {
 	return score
 }
 
-// validate runs all validators on an answer.
-func validate(answer string, chunks []store.RetrievalResult) *validationResult {
+// validate runs all validators on an answer, plus the caller's checks.
+func validate(answer string, chunks []store.RetrievalResult, checks ...ValidationCheck) *validationResult {
 	result := &validationResult{
 		citationValid:     true,
 		consistencyValid:  true,
@@ -64,6 +78,7 @@ func validate(answer string, chunks []store.RetrievalResult) *validationResult {
 
 	validateCitations(answer, chunks, result)
 	validateConsistency(answer, chunks, result)
+	validateCriteria(answer, checks, result)
 
 	return result
 }
@@ -0,0 +1,283 @@
+package goreason
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bbiangul/go-reason/parser"
+	"github.com/bbiangul/go-reason/store"
+)
+
+// Bounds on the parses kept between ReparseCompare and CommitReparse.
+const (
+	reparseCacheDocs = 16
+	reparseCacheTTL  = time.Hour
+)
+
+// ParserComparison is the result of ReparseCompare: one document's file
+// parsed with several methods.
+type ParserComparison struct {
+	DocumentID int64  `json:"document_id"`
+	Filename   string `json:"filename"`
+	// CurrentMethod is the parse method of the indexed version.
+	CurrentMethod string         `json:"current_method"`
+	Results       []ParserResult `json:"results"`
+}
+
+// ParserResult summarises one method's parse of a document.
+type ParserResult struct {
+	Method        string `json:"method"`
+	Sections      int    `json:"sections"`
+	EmptySections int    `json:"empty_sections"`
+	Tables        int    `json:"tables"`      // sections detected as tables
+	TextLength    int    `json:"text_length"` // characters of heading and section text
+	Pages         int    `json:"pages"`       // distinct pages the sections come from
+	Images        int    `json:"images"`
+	DurationMs    int64  `json:"duration_ms"`
+	// Error is set when the method is unavailable or failed.
+	Error string `json:"error,omitempty"`
+}
+
+// summariseParse fills r from a parse result.
+func (r *ParserResult) summariseParse(parsed *parser.ParseResult) {
+	r.Sections, r.EmptySections = countSections(parsed.Sections)
+	r.Images = len(parsed.Images)
+	pages := make(map[int]bool)
+	var walk func([]parser.Section)
+	walk = func(secs []parser.Section) {
+		for _, sec := range secs {
+			if sec.Type == "table" {
+				r.Tables++
+			}
+			r.TextLength += utf8.RuneCountInString(sec.Heading) + utf8.RuneCountInString(sec.Content)
+			if sec.PageNumber > 0 {
+				pages[sec.PageNumber] = true
+			}
+			walk(sec.Children)
+		}
+	}
+	walk(parsed.Sections)
+	r.Pages = len(pages)
+}
+
+// reparseCache holds, per document, the parses of its latest
+// ReparseCompare and the hash of the file they were parsed from.
+type reparseCache struct {
+	mu      sync.Mutex
+	entries map[int64]*reparseEntry
+}
+
+type reparseEntry struct {
+	hash    string
+	created time.Time
+	parsed  map[string]*parser.ParseResult
+}
+
+// put replaces a document's entry, dropping expired entries and, past
+// reparseCacheDocs, the oldest one.
+func (c *reparseCache) put(docID int64, entry *reparseEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[int64]*reparseEntry)
+	}
+	c.entries[docID] = entry
+	var oldest int64
+	for id, en := range c.entries {
+		if time.Since(en.created) > reparseCacheTTL {
+			delete(c.entries, id)
+			continue
+		}
+		if oldest == 0 || en.created.Before(c.entries[oldest].created) {
+			oldest = id
+		}
+	}
+	if len(c.entries) > reparseCacheDocs {
+		delete(c.entries, oldest)
+	}
+}
+
+// take removes and returns a document's parse with method, if it was
+// parsed from a file with hash and has not expired.
+func (c *reparseCache) take(docID int64, method, hash string) *parser.ParseResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[docID]
+	if !ok {
+		return nil
+	}
+	delete(c.entries, docID)
+	if entry.hash != hash || time.Since(entry.created) > reparseCacheTTL {
+		return nil
+	}
+	return entry.parsed[method]
+}
+
+// ReparseCompare parses a document's file with each method in turn, so a
+// parser can be chosen per document without re-ingesting it with each.
+// A method that is unavailable or fails is reported in its result's
+// Error. The parses are kept for CommitReparse.
+func (e *engine) ReparseCompare(ctx context.Context, documentID int64, methods ...string) (*ParserComparison, error) {
+	doc, err := e.store.GetDocument(ctx, documentID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %d", ErrDocumentNotFound, documentID)
+		}
+		return nil, err
+	}
+	src, cleanup, err := e.reparseSource(ctx, doc, &ingestOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	hash, err := fileHash(src)
+	if err != nil {
+		return nil, fmt.Errorf("hashing file: %w", err)
+	}
+	if len(methods) == 0 {
+		methods = e.parsers.Methods(doc.Format)
+	}
+
+	cmp := &ParserComparison{
+		DocumentID:    doc.ID,
+		Filename:      doc.Filename,
+		CurrentMethod: doc.ParseMethod,
+	}
+	entry := &reparseEntry{hash: hash, created: time.Now(), parsed: make(map[string]*parser.ParseResult)}
+	for _, method := range methods {
+		res := ParserResult{Method: method}
+		p, err := e.parsers.ForMethod(doc.Format, method)
+		if err != nil {
+			res.Error = err.Error()
+			cmp.Results = append(cmp.Results, res)
+			continue
+		}
+		start := time.Now()
+		parsed, err := p.Parse(ctx, src)
+		res.DurationMs = time.Since(start).Milliseconds()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			res.Error = err.Error()
+			cmp.Results = append(cmp.Results, res)
+			continue
+		}
+		res.summariseParse(parsed)
+		entry.parsed[method] = parsed
+		cmp.Results = append(cmp.Results, res)
+	}
+	e.reparses.put(doc.ID, entry)
+	return cmp, nil
+}
+
+// CommitReparse re-ingests a document with method. The parse from the
+// document's latest ReparseCompare is stored when the file has not changed
+// since; otherwise the file is parsed again. The document keeps its
+// collection and metadata.
+func (e *engine) CommitReparse(ctx context.Context, documentID int64, method string) error {
+	if err := e.writable(); err != nil {
+		return err
+	}
+	start := time.Now()
+	cached, err := e.commitReparse(ctx, documentID, method)
+	e.audit(ctx, AuditReparse, start, documentID, map[string]string{
+		"parse_method": method, "cached": strconv.FormatBool(cached),
+	}, err)
+	return err
+}
+
+// commitReparse does the work of CommitReparse and reports whether the
+// cached parse was used.
+func (e *engine) commitReparse(ctx context.Context, documentID int64, method string) (bool, error) {
+	doc, err := e.store.GetDocument(ctx, documentID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, fmt.Errorf("%w: %d", ErrDocumentNotFound, documentID)
+		}
+		return false, err
+	}
+	if _, err := e.parsers.ForMethod(doc.Format, method); err != nil {
+		return false, fmt.Errorf("%w: %v", ErrParseMethodUnavailable, err)
+	}
+	options := &ingestOptions{
+		forceReparse: true,
+		parseMethod:  method,
+		collection:   doc.Collection,
+	}
+	src, cleanup, err := e.reparseSource(ctx, doc, options)
+	if err != nil {
+		return false, err
+	}
+	defer cleanup()
+	hash, err := fileHash(src)
+	if err != nil {
+		return false, fmt.Errorf("hashing file: %w", err)
+	}
+	options.parsed = e.reparses.take(documentID, method, hash)
+	if doc.Metadata != "" {
+		_ = json.Unmarshal([]byte(doc.Metadata), &options.metadata)
+	}
+	_, err = e.ingest(ctx, src, options)
+	return options.parsed != nil, err
+}
+
+// reparseSource returns the file doc was ingested from, which is not its
+// path for a session document or an archive member, and sets the options
+// that record a re-ingest of that file as doc again. An archive member is
+// extracted from its archive into a temporary workspace that cleanup
+// removes.
+func (e *engine) reparseSource(ctx context.Context, doc *store.Document, options *ingestOptions) (string, func(), error) {
+	options.session = doc.SessionID
+	if doc.ParentID == 0 {
+		return unsessionPath(doc), func() {}, nil
+	}
+
+	parent, err := e.store.GetDocument(ctx, doc.ParentID)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: archive of %s", ErrDocumentNotFound, doc.Path)
+	}
+	archive := unsessionPath(parent)
+	format, ok := archiveFormat(archive)
+	if !ok {
+		return "", nil, fmt.Errorf("%w: %s", ErrInvalidArchive, parent.Filename)
+	}
+	workspace, err := os.MkdirTemp("", "goreason-archive-")
+	if err != nil {
+		return "", nil, fmt.Errorf("creating archive workspace: %w", err)
+	}
+	cleanup := func() { os.RemoveAll(workspace) }
+	x := &archiveExtractor{seen: make(map[string]bool)}
+	if err := x.extract(archive, format, "", workspace, 0); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	name := strings.TrimPrefix(doc.Path, archiveMemberPath(parent.Path, ""))
+	for _, m := range x.members {
+		if m.name == name {
+			options.member = doc.Path
+			options.parentID = parent.ID
+			return m.path, cleanup, nil
+		}
+	}
+	cleanup()
+	return "", nil, fmt.Errorf("%w: %s is no longer in its archive", ErrDocumentNotFound, doc.Path)
+}
+
+// unsessionPath returns the file path of doc, without the session prefix
+// of a session document.
+func unsessionPath(doc *store.Document) string {
+	if doc.SessionID == "" {
+		return doc.Path
+	}
+	return strings.TrimPrefix(doc.Path, sessionPath(doc.SessionID, ""))
+}
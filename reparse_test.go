@@ -0,0 +1,133 @@
+package goreason
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReparseCompareAndCommit(t *testing.T) {
+	eng, _, path := faultEngine(t, nil)
+	ctx := context.Background()
+	docID, err := eng.Ingest(ctx, path, WithMetadata(map[string]string{"owner": "maintenance"}))
+	if err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	e := eng.(*engine)
+
+	cmp, err := eng.ReparseCompare(ctx, docID)
+	if err != nil {
+		t.Fatalf("ReparseCompare: %v", err)
+	}
+	if cmp.CurrentMethod != "native" || len(cmp.Results) != 1 {
+		t.Fatalf("comparison = %+v, want the native method only", cmp)
+	}
+	if r := cmp.Results[0]; r.Method != "native" || r.Error != "" || r.Sections == 0 || r.TextLength == 0 {
+		t.Errorf("native result = %+v", r)
+	}
+
+	cmp, err = eng.ReparseCompare(ctx, docID, "native", "vision", "bogus")
+	if err != nil {
+		t.Fatalf("ReparseCompare: %v", err)
+	}
+	if len(cmp.Results) != 3 || cmp.Results[1].Error == "" || cmp.Results[2].Error == "" {
+		t.Errorf("results = %+v, want errors for vision and bogus", cmp.Results)
+	}
+
+	if err := eng.CommitReparse(ctx, docID, "vision"); !errors.Is(err, ErrParseMethodUnavailable) {
+		t.Fatalf("CommitReparse(vision) = %v, want ErrParseMethodUnavailable", err)
+	}
+	if err := eng.CommitReparse(ctx, docID, "native"); err != nil {
+		t.Fatalf("CommitReparse: %v", err)
+	}
+	doc, err := e.store.GetDocument(ctx, docID)
+	if err != nil || doc.Status != "ready" || doc.ParseMethod != "native" {
+		t.Fatalf("document after commit = %+v, %v", doc, err)
+	}
+	if doc.Metadata != `{"owner":"maintenance"}` {
+		t.Errorf("metadata after commit = %q", doc.Metadata)
+	}
+	entries, err := eng.AuditLog(ctx, AuditFilter{Operation: AuditReparse})
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("reparse audit entries = %d, %v; want 2", len(entries), err)
+	}
+	if entries[0].Params["cached"] != "true" {
+		t.Errorf("commit params = %v, want the compared parse reused", entries[0].Params)
+	}
+
+	// A file changed since the comparison is parsed again.
+	if _, err := eng.ReparseCompare(ctx, docID, "native"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("Pump maintenance.\n\nThe relief valve opens at 12 bar.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := eng.CommitReparse(ctx, docID, "native"); err != nil {
+		t.Fatalf("CommitReparse after change: %v", err)
+	}
+	entries, _ = eng.AuditLog(ctx, AuditFilter{Operation: AuditReparse, Limit: 1})
+	if len(entries) != 1 || entries[0].Params["cached"] != "false" {
+		t.Errorf("commit after change = %+v, want a fresh parse", entries)
+	}
+
+	if _, err := eng.ReparseCompare(ctx, docID+100); !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("ReparseCompare(unknown) = %v, want ErrDocumentNotFound", err)
+	}
+}
+
+func TestReparseSessionAndArchiveMember(t *testing.T) {
+	eng, _, path := faultEngine(t, nil)
+	ctx := context.Background()
+	e := eng.(*engine)
+	manual, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := filepath.Join(filepath.Dir(path), "bundle.zip")
+	writeZip(t, archive, map[string]string{"manuals/pump.txt": string(manual)})
+
+	sessionID, err := eng.Ingest(ctx, path, WithIngestSession("s1"))
+	if err != nil {
+		t.Fatalf("Ingest session: %v", err)
+	}
+	parentID, err := eng.Ingest(ctx, archive)
+	if err != nil {
+		t.Fatalf("Ingest archive: %v", err)
+	}
+	members := memberPaths(t, eng, parentID)
+	if len(members) != 1 {
+		t.Fatalf("members = %v", members)
+	}
+	member, err := e.store.GetDocumentByPath(ctx, members[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, docID := range []int64{sessionID, member.ID} {
+		before, err := e.store.GetDocument(ctx, docID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cmp, err := eng.ReparseCompare(ctx, docID, "native")
+		if err != nil {
+			t.Fatalf("ReparseCompare(%s): %v", before.Path, err)
+		}
+		if r := cmp.Results[0]; r.Error != "" || r.Sections == 0 {
+			t.Errorf("%s: native result = %+v", before.Path, r)
+		}
+		if err := eng.CommitReparse(ctx, docID, "native"); err != nil {
+			t.Fatalf("CommitReparse(%s): %v", before.Path, err)
+		}
+		after, err := e.store.GetDocument(ctx, docID)
+		if err != nil || after.Status != "ready" || after.Path != before.Path ||
+			after.ParentID != before.ParentID || after.SessionID != before.SessionID {
+			t.Errorf("document after commit = %+v, %v; want %+v kept", after, err, before)
+		}
+		entries, _ := eng.AuditLog(ctx, AuditFilter{Operation: AuditReparse, Limit: 1})
+		if len(entries) != 1 || entries[0].Params["cached"] != "true" {
+			t.Errorf("%s: commit = %+v, want the compared parse reused", before.Path, entries)
+		}
+	}
+}
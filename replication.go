@@ -0,0 +1,243 @@
+package goreason
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bbiangul/go-reason/store"
+)
+
+// Replication modes (ReplicationConfig.Mode).
+const (
+	ReplicationLitestream = "litestream"
+	ReplicationLiteFS     = "litefs"
+)
+
+// Replication roles (ReplicationStatus.Role).
+const (
+	RoleStandalone = "standalone"
+	RolePrimary    = "primary"
+	RoleReplica    = "replica"
+)
+
+// Checkpoint is the result of Engine.Checkpoint.
+type Checkpoint struct {
+	store.WALCheckpoint
+	At         time.Time `json:"at"`
+	DurationMs int64     `json:"duration_ms"`
+}
+
+// ReplicationStatus describes the engine's place in a replicated
+// deployment, for health checks and load balancers.
+type ReplicationStatus struct {
+	Mode     string `json:"mode,omitempty"`
+	ReadOnly bool   `json:"read_only"`
+	Role     string `json:"role"`
+	// Primary is the LiteFS primary's hostname, on replicas.
+	Primary string `json:"primary,omitempty"`
+	// Position is the LiteFS replication position ("TXID/checksum").
+	Position string `json:"position,omitempty"`
+	// WALBytes is the size of the WAL file not yet truncated.
+	WALBytes       int64       `json:"wal_bytes"`
+	LastCheckpoint *Checkpoint `json:"last_checkpoint,omitempty"`
+	LastError      string      `json:"last_error,omitempty"`
+}
+
+// validateReplication checks Config.Replication.
+func validateReplication(rc *ReplicationConfig) error {
+	if rc == nil {
+		return nil
+	}
+	switch rc.Mode {
+	case ReplicationLitestream, ReplicationLiteFS:
+	default:
+		return fmt.Errorf("%w: unknown replication mode %q", ErrInvalidConfig, rc.Mode)
+	}
+	if rc.CheckpointIntervalSeconds < 0 {
+		return fmt.Errorf("%w: replication.checkpoint_interval_seconds must not be negative", ErrInvalidConfig)
+	}
+	return nil
+}
+
+// replicator tracks the engine's checkpoints and reads the replication
+// tool's state. A nil *replicator means replication is not configured.
+type replicator struct {
+	cfg    ReplicationConfig
+	dbPath string
+
+	mu      sync.Mutex
+	last    *Checkpoint
+	lastErr string
+
+	// stop ends the periodic checkpoints; nil when there are none.
+	stop func()
+}
+
+// newReplicator returns nil when rc is nil.
+func newReplicator(rc *ReplicationConfig, dbPath string) *replicator {
+	if rc == nil {
+		return nil
+	}
+	return &replicator{cfg: *rc, dbPath: dbPath}
+}
+
+// liteFSPrimary returns the primary's hostname and true when this node is
+// a LiteFS replica. LiteFS keeps a .primary file in the mount on replicas
+// only.
+func (r *replicator) liteFSPrimary() (string, bool) {
+	if r == nil || r.cfg.Mode != ReplicationLiteFS {
+		return "", false
+	}
+	dir := r.cfg.Dir
+	if dir == "" {
+		dir = filepath.Dir(r.dbPath)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, ".primary"))
+	if err != nil {
+		return "", false
+	}
+	return strings.TrimSpace(string(data)), true
+}
+
+// liteFSPosition reads the database's LiteFS position file, or "".
+func (r *replicator) liteFSPosition() string {
+	if r == nil || r.cfg.Mode != ReplicationLiteFS {
+		return ""
+	}
+	data, err := os.ReadFile(r.dbPath + "-pos")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// checkpointMode is PASSIVE under Litestream, which must copy frames
+// before they leave the WAL, and TRUNCATE otherwise.
+func (r *replicator) checkpointMode() string {
+	if r != nil && r.cfg.Mode == ReplicationLitestream {
+		return store.CheckpointPassive
+	}
+	return store.CheckpointTruncate
+}
+
+func (r *replicator) record(cp *Checkpoint, err error) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.lastErr = err.Error()
+		return
+	}
+	r.last, r.lastErr = cp, ""
+}
+
+// writable returns ErrReadOnly when the engine cannot write: it was opened
+// read-only, or it is a LiteFS node that has since lost the primary lease.
+func (e *engine) writable() error {
+	if e.primary != nil {
+		return e.primary.writable()
+	}
+	if e.readOnly {
+		return ErrReadOnly
+	}
+	if host, ok := e.repl.liteFSPrimary(); ok {
+		return fmt.Errorf("%w: LiteFS primary is %s", ErrReadOnly, host)
+	}
+	return nil
+}
+
+// Checkpoint copies the WAL back into the database: PASSIVE under
+// Litestream, TRUNCATE otherwise. ReplicationConfig's hooks run around it.
+func (e *engine) Checkpoint(ctx context.Context) (*Checkpoint, error) {
+	if err := e.writable(); err != nil {
+		return nil, err
+	}
+	cp, err := e.checkpoint(ctx)
+	e.repl.record(cp, err)
+	return cp, err
+}
+
+func (e *engine) checkpoint(ctx context.Context) (*Checkpoint, error) {
+	var hooks ReplicationConfig
+	if e.repl != nil {
+		hooks = e.repl.cfg
+	}
+	if hooks.BeforeCheckpoint != nil {
+		if err := hooks.BeforeCheckpoint(ctx); err != nil {
+			return nil, fmt.Errorf("before checkpoint: %w", err)
+		}
+	}
+	start := time.Now()
+	wal, err := e.store.Checkpoint(ctx, e.repl.checkpointMode())
+	if err != nil {
+		return nil, err
+	}
+	cp := &Checkpoint{WALCheckpoint: *wal, At: start, DurationMs: time.Since(start).Milliseconds()}
+	if hooks.AfterCheckpoint != nil {
+		hooks.AfterCheckpoint(ctx, *cp)
+	}
+	return cp, nil
+}
+
+// ReplicationStatus reports the engine's role, its LiteFS position, and
+// its last checkpoint.
+func (e *engine) ReplicationStatus() ReplicationStatus {
+	st := ReplicationStatus{
+		ReadOnly: e.readOnly,
+		Role:     RoleStandalone,
+		WALBytes: e.store.WALSize(),
+	}
+	if e.repl == nil {
+		if e.readOnly {
+			st.Role = RoleReplica
+		}
+		return st
+	}
+	st.Mode = e.repl.cfg.Mode
+	st.Role = RolePrimary
+	if e.readOnly {
+		st.Role = RoleReplica
+	}
+	if host, ok := e.repl.liteFSPrimary(); ok {
+		st.Role, st.Primary = RoleReplica, host
+	}
+	st.Position = e.repl.liteFSPosition()
+	e.repl.mu.Lock()
+	st.LastCheckpoint, st.LastError = e.repl.last, e.repl.lastErr
+	e.repl.mu.Unlock()
+	return st
+}
+
+// startCheckpoints checkpoints every interval until Close.
+func (e *engine) startCheckpoints(interval time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				if _, err := e.Checkpoint(ctx); err != nil && ctx.Err() == nil {
+					slog.WarnContext(ctx, "replication: checkpoint failed (non-fatal)", "error", err)
+				}
+			}
+		}
+	}()
+	e.repl.stop = func() {
+		cancel()
+		<-done
+	}
+	slog.InfoContext(ctx, "replication: periodic checkpoints started", "interval", interval)
+}
@@ -0,0 +1,196 @@
+package goreason
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bbiangul/go-reason/store"
+)
+
+func TestReadOnlyEngine(t *testing.T) {
+	eng, _, path := faultEngine(t, nil)
+	ctx := context.Background()
+	if _, err := eng.Ingest(ctx, path); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+
+	cfg := eng.(*engine).cfg
+	cfg.ReadOnly = true
+	replica, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New read-only: %v", err)
+	}
+	defer replica.Close()
+
+	answer, err := replica.Query(ctx, "At what pressure does the relief valve open?")
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if len(answer.Sources) == 0 || answer.QueryID != 0 {
+		t.Errorf("replica answer = %+v, want sources and no query log", answer)
+	}
+	if _, err := replica.Ingest(ctx, path); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Ingest = %v, want ErrReadOnly", err)
+	}
+	if err := replica.Delete(ctx, 1); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Delete = %v, want ErrReadOnly", err)
+	}
+	if st := replica.ReplicationStatus(); !st.ReadOnly || st.Role != RoleReplica {
+		t.Errorf("status = %+v", st)
+	}
+
+	cfg.DBPath = filepath.Join(t.TempDir(), "missing.db")
+	if _, err := New(cfg); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("New read-only without a database = %v, want ErrInvalidConfig", err)
+	}
+}
+
+func TestImmutableEngine(t *testing.T) {
+	eng, _, path := faultEngine(t, nil)
+	ctx := context.Background()
+	if _, err := eng.Ingest(ctx, path); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	cfg := eng.(*engine).cfg
+	cfg.ReadOnly, cfg.Immutable = true, true
+	cfg.QueryLogFile = filepath.Join(t.TempDir(), "queries.jsonl")
+
+	// The writer's WAL is not checkpointed yet.
+	if _, err := New(cfg); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("New with a pending WAL = %v, want ErrInvalidConfig", err)
+	}
+	if _, err := eng.Checkpoint(ctx); err != nil {
+		t.Fatalf("Checkpoint: %v", err)
+	}
+	replica, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New immutable: %v", err)
+	}
+	answer, err := replica.Query(ctx, "At what pressure does the relief valve open?")
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if len(answer.Sources) == 0 || answer.QueryID != 0 {
+		t.Errorf("replica answer = %+v, want sources and no query log ID", answer)
+	}
+	replica.Close()
+
+	data, err := os.ReadFile(cfg.QueryLogFile)
+	if err != nil {
+		t.Fatalf("reading query log: %v", err)
+	}
+	var line struct {
+		Query     string `json:"query"`
+		Answer    string `json:"answer"`
+		CreatedAt string `json:"created_at"`
+	}
+	if err := json.Unmarshal(data, &line); err != nil {
+		t.Fatalf("query log %q: %v", data, err)
+	}
+	if line.Query != "At what pressure does the relief valve open?" || line.Answer != answer.Text || line.CreatedAt == "" {
+		t.Errorf("query log line = %+v", line)
+	}
+
+	cfg.ReadOnly = false
+	if _, err := New(cfg); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("New immutable without read_only = %v, want ErrInvalidConfig", err)
+	}
+	cfg.Immutable = false
+	if _, err := New(cfg); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("New with a query log file without read_only = %v, want ErrInvalidConfig", err)
+	}
+}
+
+// BenchmarkImmutableStartup measures how long an immutable read-only
+// engine takes to open, which serverless images want under 100ms:
+//
+//	go test -tags sqlite_fts5 -run '^$' -bench ImmutableStartup .
+func BenchmarkImmutableStartup(b *testing.B) {
+	eng, _, path := faultEngine(b, nil)
+	ctx := context.Background()
+	if _, err := eng.Ingest(ctx, path); err != nil {
+		b.Fatalf("Ingest: %v", err)
+	}
+	if _, err := eng.Checkpoint(ctx); err != nil {
+		b.Fatalf("Checkpoint: %v", err)
+	}
+	cfg := eng.(*engine).cfg
+	cfg.ReadOnly, cfg.Immutable = true, true
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		replica, err := New(cfg)
+		if err != nil {
+			b.Fatalf("New immutable: %v", err)
+		}
+		b.StopTimer()
+		replica.Close()
+		b.StartTimer()
+	}
+}
+
+func TestLiteFSReplica(t *testing.T) {
+	eng, _, _ := faultEngine(t, nil)
+	cfg := eng.(*engine).cfg
+	dir := filepath.Dir(cfg.DBPath)
+	os.WriteFile(filepath.Join(dir, ".primary"), []byte("node-1\n"), 0o644)
+	os.WriteFile(cfg.DBPath+"-pos", []byte("0000000000000007/a1b2c3d4e5f60718\n"), 0o644)
+
+	// The primary lost its lease: writes are refused from now on.
+	e := eng.(*engine)
+	e.repl = newReplicator(&ReplicationConfig{Mode: ReplicationLiteFS}, cfg.DBPath)
+	if _, err := eng.Compact(context.Background()); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Compact on a demoted primary = %v, want ErrReadOnly", err)
+	}
+
+	cfg.Replication = &ReplicationConfig{Mode: ReplicationLiteFS}
+	replica, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer replica.Close()
+	st := replica.ReplicationStatus()
+	if !st.ReadOnly || st.Role != RoleReplica || st.Primary != "node-1" || st.Position != "0000000000000007/a1b2c3d4e5f60718" {
+		t.Errorf("status = %+v", st)
+	}
+}
+
+func TestCheckpointHooks(t *testing.T) {
+	eng, _, path := faultEngine(t, nil)
+	ctx := context.Background()
+	e := eng.(*engine)
+
+	var before, after int
+	var hookErr error
+	e.repl = newReplicator(&ReplicationConfig{
+		Mode:             ReplicationLitestream,
+		BeforeCheckpoint: func(ctx context.Context) error { before++; return hookErr },
+		AfterCheckpoint:  func(ctx context.Context, cp Checkpoint) { after++ },
+	}, e.cfg.DBPath)
+	if _, err := eng.Ingest(ctx, path); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+
+	cp, err := eng.Checkpoint(ctx)
+	if err != nil {
+		t.Fatalf("Checkpoint: %v", err)
+	}
+	if cp.Mode != store.CheckpointPassive || before != 1 || after != 1 {
+		t.Errorf("checkpoint = %+v, hooks ran %d/%d times", cp, before, after)
+	}
+	if st := eng.ReplicationStatus(); st.Role != RolePrimary || st.Mode != ReplicationLitestream || st.LastCheckpoint == nil {
+		t.Errorf("status = %+v", st)
+	}
+
+	hookErr = errors.New("replicator busy")
+	if _, err := eng.Checkpoint(ctx); err == nil || after != 1 {
+		t.Errorf("Checkpoint with failing hook = %v, after ran %d times", err, after)
+	}
+	if st := eng.ReplicationStatus(); st.LastError == "" || st.LastCheckpoint == nil {
+		t.Errorf("status after failure = %+v", st)
+	}
+}
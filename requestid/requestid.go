@@ -0,0 +1,86 @@
+// Package requestid carries a request ID through a context, so the log
+// lines, LLM calls, and query log entries of one request can be
+// correlated across the engine, retrieval, reasoning, and LLM packages.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+)
+
+// Header is the HTTP header a request ID is accepted from, returned in,
+// and forwarded to LLM providers with.
+const Header = "X-Request-ID"
+
+// LogKey is the attribute key request IDs are logged under.
+const LogKey = "request_id"
+
+// maxLen bounds accepted request IDs, so a client cannot bloat every log
+// line of its request.
+const maxLen = 128
+
+type key struct{}
+
+// With returns a context carrying id.
+func With(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, key{}, id)
+}
+
+// From returns the request ID carried by ctx, or "".
+func From(ctx context.Context) string {
+	id, _ := ctx.Value(key{}).(string)
+	return id
+}
+
+// New returns a random 16-byte hex request ID.
+func New() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// Valid reports whether a caller-supplied ID can be used as is: non-empty,
+// at most 128 characters, and printable ASCII without spaces, so it is
+// safe in headers and log lines.
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// Handler is a slog.Handler that adds the context's request ID to every
+// record logged with a context (slog.InfoContext and friends).
+type Handler struct {
+	slog.Handler
+}
+
+// NewHandler wraps h so records carry the request ID of their context.
+func NewHandler(h slog.Handler) *Handler {
+	return &Handler{Handler: h}
+}
+
+// Handle adds the request_id attribute when the context has one.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	if id := From(ctx); id != "" {
+		r.AddAttrs(slog.String(LogKey, id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs keeps the wrapper around the derived handler.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Handler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup keeps the wrapper around the derived handler.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{Handler: h.Handler.WithGroup(name)}
+}
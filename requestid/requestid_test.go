@@ -0,0 +1,44 @@
+package requestid
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestValid(t *testing.T) {
+	for id, want := range map[string]bool{
+		"":                         false,
+		"a1b2-c3d4":                true,
+		New():                      true,
+		"has space":                false,
+		"line\nbreak":              false,
+		strings.Repeat("x", 129):   false,
+		"req_2024-01-01T00:00:00Z": true,
+	} {
+		if got := Valid(id); got != want {
+			t.Errorf("Valid(%q) = %v, want %v", id, got, want)
+		}
+	}
+}
+
+func TestHandlerAddsRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(NewHandler(slog.NewTextHandler(&buf, nil))).With("component", "test")
+
+	log.InfoContext(With(context.Background(), "abc123"), "tagged")
+	log.Info("untagged")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d log lines, want 2", len(lines))
+	}
+	if !strings.Contains(lines[0], "request_id=abc123") || !strings.Contains(lines[0], "component=test") {
+		t.Errorf("tagged line = %q", lines[0])
+	}
+	if strings.Contains(lines[1], "request_id") {
+		t.Errorf("untagged line = %q", lines[1])
+	}
+}
@@ -0,0 +1,20 @@
+package goreason
+
+import (
+	"context"
+
+	"github.com/bbiangul/go-reason/requestid"
+)
+
+// WithRequestID returns a context whose log lines, LLM calls, and query
+// log entry carry id, so one request can be followed across components.
+// Log records show it only when the slog handler is wrapped with
+// requestid.NewHandler.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return requestid.With(ctx, id)
+}
+
+// RequestIDFromContext returns the ID set by WithRequestID, or "".
+func RequestIDFromContext(ctx context.Context) string {
+	return requestid.From(ctx)
+}
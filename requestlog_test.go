@@ -0,0 +1,27 @@
+package goreason
+
+import (
+	"context"
+	"testing"
+)
+
+func TestQueryLogRecordsRequestID(t *testing.T) {
+	eng, _, path := faultEngine(t, nil)
+	ctx := WithRequestID(context.Background(), "req-42")
+	if _, err := eng.Ingest(ctx, path); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	answer, err := eng.Query(ctx, "At what pressure does the relief valve open?")
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+
+	var got string
+	if err := eng.(*engine).store.DB().QueryRowContext(ctx,
+		"SELECT request_id FROM query_log WHERE id = ?", answer.QueryID).Scan(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got != "req-42" {
+		t.Errorf("query_log.request_id = %q, want req-42", got)
+	}
+}
@@ -0,0 +1,163 @@
+package goreason
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/bbiangul/go-reason/graph"
+	"github.com/bbiangul/go-reason/llm"
+	"github.com/bbiangul/go-reason/parser"
+	"github.com/bbiangul/go-reason/pipeline"
+)
+
+// ResumeIngest continues an ingest that failed or was interrupted after
+// the document's chunks were stored. It picks up at the checkpointed stage
+// and chunk: chunks already embedded, or already sent to graph extraction,
+// are not processed again. The quality report is recomputed from the
+// stored chunks, with the graph build report of both runs; the parsed
+// document is not kept, so its section counts are those of the report the
+// interrupted ingest stored, if any.
+//
+// An ingest run with a custom pipeline resumes with the same pipeline:
+// WithPipeline, or Config.Pipeline without it, must have the stages the
+// interrupted ingest had, in the same places. Its custom stages see the
+// stored document and chunks, without the parse result. The other ingest
+// options are ignored.
+func (e *engine) ResumeIngest(ctx context.Context, documentID int64, opts ...IngestOption) error {
+	if err := e.writable(); err != nil {
+		return err
+	}
+	options := &ingestOptions{}
+	for _, o := range opts {
+		o(options)
+	}
+	start := time.Now()
+	err := e.resumeIngest(llm.WithPhase(ctx, llm.PhaseIngest), documentID, options)
+	e.audit(ctx, AuditResumeIngest, start, documentID, nil, err)
+	return err
+}
+
+// resumeIngest does the work of ResumeIngest.
+func (e *engine) resumeIngest(ctx context.Context, documentID int64, options *ingestOptions) error {
+	if err := e.embeddingDrift(); err != nil {
+		return err
+	}
+
+	release, err := e.acquireIngest(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	doc, err := e.store.GetDocument(ctx, documentID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: %d", ErrDocumentNotFound, documentID)
+		}
+		return err
+	}
+	cp, err := e.store.GetIngestCheckpoint(ctx, documentID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: %d", ErrNoCheckpoint, documentID)
+		}
+		return fmt.Errorf("loading checkpoint: %w", err)
+	}
+	p := options.pipeline
+	if p == nil {
+		p = e.cfg.Pipeline
+	}
+	// Checkpoints from before pipelines were recorded resume with any.
+	if cp.Pipeline != "" && cp.Pipeline != p.String() {
+		return fmt.Errorf("%w: document %d was ingested with pipeline %q; resume it with the same pipeline, not %q",
+			ErrInvalidConfig, documentID, cp.Pipeline, p.String())
+	}
+
+	chunks, err := e.store.GetChunksByDocument(ctx, documentID)
+	if err != nil {
+		return fmt.Errorf("loading chunks: %w", err)
+	}
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].ID < chunks[j].ID })
+	run := &ingestRun{docID: documentID, filename: doc.Filename, session: doc.SessionID != "", pipeline: p}
+	if cp.GraphReport != "" {
+		run.graph = new(graph.BuildReport)
+		if err := json.Unmarshal([]byte(cp.GraphReport), run.graph); err != nil {
+			run.graph = nil
+		}
+	}
+	for _, c := range chunks {
+		if c.ID < cp.FirstChunkID {
+			continue // kept from an earlier ingest
+		}
+		run.chunks = append(run.chunks, c)
+		run.ids = append(run.ids, c.ID)
+		run.sections = append(run.sections, parser.Section{
+			Heading:    c.Heading,
+			Content:    c.Content,
+			PageNumber: c.PageNumber,
+			Type:       c.ChunkType,
+		})
+	}
+
+	var metadata map[string]string
+	if doc.Metadata != "" {
+		json.Unmarshal([]byte(doc.Metadata), &metadata)
+	}
+	run.doc = &pipeline.Document{
+		ID:         documentID,
+		Path:       doc.Path,
+		Filename:   doc.Filename,
+		Format:     doc.Format,
+		Collection: doc.Collection,
+		Metadata:   metadata,
+		Chunks:     run.chunks,
+		ChunkIDs:   run.ids,
+	}
+
+	slog.InfoContext(ctx, "ingest: resuming", "file", doc.Filename, "doc_id", documentID,
+		"stage", cp.Stage, "after_chunk", cp.LastChunkID, "chunks", len(run.chunks))
+	e.store.UpdateDocumentStatus(ctx, documentID, "processing")
+	if err := e.runIngestStages(ctx, run, cp.Stage, cp.LastChunkID); err != nil {
+		e.store.UpdateDocumentStatus(ctx, documentID, "error")
+		return err
+	}
+	e.recordResumedQuality(ctx, doc, run.graph)
+	return e.store.UpdateDocumentStatus(ctx, documentID, "ready")
+}
+
+// IngestProgress reports an unfinished ingest of a document: one still
+// running, or one that failed and can be continued with ResumeIngest.
+type IngestProgress struct {
+	Stage       string `json:"stage"`         // "embedding", "after_embedding", "graph", or "after_graph"
+	LastChunkID int64  `json:"last_chunk_id"` // last chunk the stage checkpointed
+	// Graph is the graph build report so far, updated after every chunk
+	// while the graph stage runs.
+	Graph     *graph.BuildReport `json:"graph,omitempty"`
+	UpdatedAt string             `json:"updated_at"`
+}
+
+// ingestProgress returns the progress of a document's unfinished ingest,
+// or nil when it has none.
+func (e *engine) ingestProgress(ctx context.Context, documentID int64) *IngestProgress {
+	cp, err := e.store.GetIngestCheckpoint(ctx, documentID)
+	if err != nil {
+		return nil
+	}
+	p := &IngestProgress{Stage: cp.Stage, LastChunkID: cp.LastChunkID, UpdatedAt: cp.UpdatedAt}
+	if live, ok := e.graphProgress.Load(documentID); ok {
+		r := live.(graph.BuildReport)
+		p.Graph = &r
+	} else if cp.GraphReport != "" {
+		var r graph.BuildReport
+		if json.Unmarshal([]byte(cp.GraphReport), &r) == nil {
+			p.Graph = &r
+		}
+	}
+	return p
+}
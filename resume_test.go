@@ -0,0 +1,211 @@
+package goreason
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bbiangul/go-reason/llm/llmtest"
+	"github.com/bbiangul/go-reason/pipeline"
+	"github.com/bbiangul/go-reason/store"
+)
+
+func TestResumeIngestAfterEmbeddingFailure(t *testing.T) {
+	// The first batch call and its per-text fallbacks all fail, so the
+	// ingest stops in the embedding stage.
+	eng, srv, path := faultEngine(t, &llmtest.Scenario{Rules: []llmtest.Rule{
+		{Op: llmtest.OpEmbed, Fault: llmtest.FaultServerError, Times: 3},
+	}})
+	ctx := context.Background()
+	e := eng.(*engine)
+
+	if _, err := eng.Ingest(ctx, path); !errors.Is(err, ErrEmbeddingFailed) {
+		t.Fatalf("Ingest error = %v, want ErrEmbeddingFailed", err)
+	}
+	docs, err := e.store.ListDocuments(ctx)
+	if err != nil || len(docs) != 1 {
+		t.Fatalf("ListDocuments = %d, %v", len(docs), err)
+	}
+	docID := docs[0].ID
+	cp, err := e.store.GetIngestCheckpoint(ctx, docID)
+	if err != nil {
+		t.Fatalf("GetIngestCheckpoint: %v", err)
+	}
+	if cp.Stage != store.CheckpointEmbedding || cp.LastChunkID != 0 || cp.FirstChunkID == 0 {
+		t.Fatalf("checkpoint = %+v, want the embedding stage with no chunk done", cp)
+	}
+	if doc, err := eng.Document(ctx, docID); err != nil || doc.Ingest == nil || doc.Ingest.Stage != store.CheckpointEmbedding {
+		t.Fatalf("Document = %+v, %v; want the unfinished embedding stage", doc, err)
+	}
+
+	var extractions atomic.Int32
+	chat := srv.ChatFunc
+	srv.ChatFunc = func(prompt string) string {
+		if strings.Contains(prompt, "entity extraction engine") {
+			extractions.Add(1)
+		}
+		return chat(prompt)
+	}
+
+	if err := eng.ResumeIngest(ctx, docID); err != nil {
+		t.Fatalf("ResumeIngest: %v", err)
+	}
+	doc, err := e.store.GetDocument(ctx, docID)
+	if err != nil || doc.Status != "ready" {
+		t.Fatalf("document after resume = %+v, %v; want ready", doc, err)
+	}
+	if ok, _ := e.store.HasEmbeddings(ctx); !ok {
+		t.Error("resumed ingest stored no embeddings")
+	}
+	if extractions.Load() == 0 {
+		t.Error("resumed ingest skipped graph extraction")
+	}
+	if _, err := e.store.GetIngestCheckpoint(ctx, docID); err == nil {
+		t.Error("checkpoint left behind after a finished ingest")
+	}
+	if d, err := eng.Document(ctx, docID); err != nil || d.Ingest != nil || d.Quality == nil || d.Quality.Graph == nil || d.Quality.Graph.Processed == 0 {
+		t.Errorf("Document after resume = %+v, %v; want a quality report with the graph build", d, err)
+	}
+	if err := eng.ResumeIngest(ctx, docID); !errors.Is(err, ErrNoCheckpoint) {
+		t.Errorf("second ResumeIngest = %v, want ErrNoCheckpoint", err)
+	}
+
+	// A checkpoint past the last chunk of the graph stage leaves nothing
+	// to extract again.
+	chunks, err := e.store.GetChunksByDocument(ctx, docID)
+	if err != nil || len(chunks) == 0 {
+		t.Fatalf("GetChunksByDocument = %d, %v", len(chunks), err)
+	}
+	last := chunks[len(chunks)-1].ID
+	if err := e.store.SetIngestCheckpoint(ctx, store.IngestCheckpoint{
+		DocumentID: docID, Stage: store.CheckpointGraph, FirstChunkID: chunks[0].ID, LastChunkID: last,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	before := extractions.Load()
+	if err := eng.ResumeIngest(ctx, docID); err != nil {
+		t.Fatalf("ResumeIngest from graph checkpoint: %v", err)
+	}
+	if extractions.Load() != before {
+		t.Error("chunks before the graph checkpoint were extracted again")
+	}
+
+	if err := eng.ResumeIngest(ctx, docID+100); !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("ResumeIngest(unknown) = %v, want ErrDocumentNotFound", err)
+	}
+}
+
+func TestResumeIngestWithPipeline(t *testing.T) {
+	eng, srv, path := faultEngine(t, nil)
+	ctx := context.Background()
+	e := eng.(*engine)
+
+	var extractions atomic.Int32
+	chat := srv.ChatFunc
+	srv.ChatFunc = func(prompt string) string {
+		if strings.Contains(prompt, "entity extraction engine") {
+			extractions.Add(1)
+		}
+		return chat(prompt)
+	}
+
+	// The stage after Embed fails the first time.
+	boom := errors.New("boom")
+	var runs int
+	var seen []int64
+	p, err := pipeline.New().
+		After(pipeline.Embed, pipeline.Func("flaky", func(_ context.Context, doc *pipeline.Document) error {
+			runs++
+			seen = doc.ChunkIDs
+			if runs == 1 {
+				return boom
+			}
+			return nil
+		})).
+		Skip(pipeline.Graph).
+		Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if _, err := eng.Ingest(ctx, path, WithPipeline(p)); !errors.Is(err, boom) {
+		t.Fatalf("Ingest = %v, want boom", err)
+	}
+	docs, _ := e.store.ListDocuments(ctx)
+	docID := docs[0].ID
+	cp, err := e.store.GetIngestCheckpoint(ctx, docID)
+	if err != nil || cp.Stage != store.CheckpointAfterEmbedding || cp.Pipeline != p.String() {
+		t.Fatalf("checkpoint = %+v, %v; want the stages after embedding of %q", cp, err, p)
+	}
+
+	if err := eng.ResumeIngest(ctx, docID); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("ResumeIngest without the pipeline = %v, want ErrInvalidConfig", err)
+	}
+	if err := eng.ResumeIngest(ctx, docID, WithPipeline(p)); err != nil {
+		t.Fatalf("ResumeIngest: %v", err)
+	}
+	if runs != 2 || len(seen) == 0 {
+		t.Errorf("flaky stage ran %d times, last with %d chunk IDs; want a second run over the stored chunks", runs, len(seen))
+	}
+	if n := extractions.Load(); n != 0 {
+		t.Errorf("skipped graph stage ran %d extractions on resume", n)
+	}
+	if doc, err := e.store.GetDocument(ctx, docID); err != nil || doc.Status != "ready" {
+		t.Errorf("document after resume = %+v, %v; want ready", doc, err)
+	}
+	if _, err := e.store.GetIngestCheckpoint(ctx, docID); err == nil {
+		t.Error("checkpoint left after the resumed ingest finished")
+	}
+}
+
+func TestIngestCheckpointStopsAtPartialBatch(t *testing.T) {
+	batch := checkpointBatch
+	checkpointBatch = 1
+	t.Cleanup(func() { checkpointBatch = batch })
+
+	// Extraction fails for the first chunk, the document chunk that starts
+	// with the file name, and hangs for the second.
+	eng, srv, dir := faultEngine(t, &llmtest.Scenario{Rules: []llmtest.Rule{
+		{Op: llmtest.OpChat, Match: "manual.txt\n\nALPHA", Fault: llmtest.FaultMalformedJSON},
+		{Op: llmtest.OpChat, Match: "entity extraction engine", Fault: llmtest.FaultTimeout},
+	}})
+	path := filepath.Join(filepath.Dir(dir), "manual.txt")
+	text := "ALPHA\n\nThe relief valve on the discharge line of the alpha pump opens at 10 bar and closes again " +
+		"once the pressure drops below 8 bar. Inspect the relief valve every 500 operating hours.\n"
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	e := eng.(*engine)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := eng.Ingest(ctx, path)
+		done <- err
+	}()
+	for deadline := time.Now().Add(5 * time.Second); srv.Faults(llmtest.FaultTimeout) == 0; time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("the second chunk's extraction never started")
+		}
+	}
+	cancel()
+	if err := <-done; err == nil {
+		t.Fatal("Ingest succeeded after being cancelled")
+	}
+
+	docs, err := e.store.ListDocuments(context.Background())
+	if err != nil || len(docs) != 1 {
+		t.Fatalf("ListDocuments = %d, %v", len(docs), err)
+	}
+	cp, err := e.store.GetIngestCheckpoint(context.Background(), docs[0].ID)
+	if err != nil {
+		t.Fatalf("GetIngestCheckpoint: %v", err)
+	}
+	if cp.Stage != store.CheckpointGraph || cp.LastChunkID != 0 {
+		t.Errorf("checkpoint = %+v, want the graph stage before the failed chunk", cp)
+	}
+}
@@ -0,0 +1,65 @@
+package retrieval
+
+import (
+	"context"
+	"sort"
+
+	"github.com/bbiangul/go-reason/store"
+)
+
+// ScoreAdjuster is an application hook for domain ranking heuristics. It
+// is invoked on every fused candidate of a search with the query and
+// returns a factor the candidate's score is multiplied by: above 1 to
+// boost it (its metadata names the model or clause the query asks about),
+// below 1 to demote it, 1 to leave it. Factors of 0 or less are ignored.
+//
+// Adjusters run in order after chunk type boosts and before the fused
+// candidates are cut to MaxResults, so they can lift one that fusion
+// alone ranked just outside the window. Curator annotations, usage, and
+// document importance are applied after them. They are called
+// concurrently by concurrent searches and should be cheap: they see each
+// candidate of every query.
+type ScoreAdjuster interface {
+	AdjustScore(ctx context.Context, query string, candidate store.RetrievalResult) float64
+}
+
+// ScoreAdjusterFunc adapts a function to ScoreAdjuster.
+type ScoreAdjusterFunc func(ctx context.Context, query string, candidate store.RetrievalResult) float64
+
+// AdjustScore calls f.
+func (f ScoreAdjusterFunc) AdjustScore(ctx context.Context, query string, candidate store.RetrievalResult) float64 {
+	return f(ctx, query, candidate)
+}
+
+// applyScoreAdjusters multiplies each result's score by the factors of
+// adjusters and re-sorts. It records each result's combined factor in
+// info and returns how many results changed.
+func applyScoreAdjusters(ctx context.Context, adjusters []ScoreAdjuster, query string, results []store.RetrievalResult, info map[int64]FusedResultInfo) int {
+	if len(adjusters) == 0 {
+		return 0
+	}
+	adjusted := 0
+	for i := range results {
+		factor := 1.0
+		for _, a := range adjusters {
+			if f := a.AdjustScore(ctx, query, results[i]); f > 0 {
+				factor *= f
+			}
+		}
+		if factor == 1 {
+			continue
+		}
+		results[i].Score *= factor
+		adjusted++
+		if fi, ok := info[results[i].ChunkID]; ok {
+			fi.ScoreAdjustment = factor
+			info[results[i].ChunkID] = fi
+		}
+	}
+	if adjusted > 0 {
+		sort.SliceStable(results, func(i, j int) bool {
+			return results[i].Score > results[j].Score
+		})
+	}
+	return adjusted
+}
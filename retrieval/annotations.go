@@ -0,0 +1,131 @@
+package retrieval
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+
+	"github.com/bbiangul/go-reason/store"
+)
+
+// annotations are the curator annotations of a search's candidate chunks,
+// keyed by chunk ID.
+type annotations map[int64][]store.ChunkAnnotation
+
+// loadAnnotations reads the annotations of every candidate in legs. A
+// failed read is logged and the search proceeds without them.
+func (e *Engine) loadAnnotations(ctx context.Context, legs []rrfLeg) annotations {
+	notes, err := e.store.AnnotationsForChunks(ctx, candidateIDs(legs))
+	if err != nil {
+		slog.WarnContext(ctx, "retrieval: loading chunk annotations failed", "error", err)
+		return nil
+	}
+	return notes
+}
+
+// candidateIDs returns the distinct chunk IDs of legs.
+func candidateIDs(legs []rrfLeg) []int64 {
+	seen := make(map[int64]bool)
+	var ids []int64
+	for _, l := range legs {
+		for _, r := range l.results {
+			if !seen[r.ChunkID] {
+				seen[r.ChunkID] = true
+				ids = append(ids, r.ChunkID)
+			}
+		}
+	}
+	return ids
+}
+
+// dropExcluded removes chunks annotated AnnotationExclude from every leg
+// and returns how many distinct chunks were removed.
+func (a annotations) dropExcluded(legs []rrfLeg) int {
+	excluded := make(map[int64]bool)
+	for id, list := range a {
+		for _, n := range list {
+			if n.Kind == store.AnnotationExclude {
+				excluded[id] = true
+			}
+		}
+	}
+	if len(excluded) == 0 {
+		return 0
+	}
+	dropped := make(map[int64]bool)
+	for i := range legs {
+		kept := legs[i].results[:0:0]
+		for _, r := range legs[i].results {
+			if excluded[r.ChunkID] {
+				dropped[r.ChunkID] = true
+				continue
+			}
+			kept = append(kept, r)
+		}
+		legs[i].results = kept
+	}
+	return len(dropped)
+}
+
+// boost multiplies the scores of chunks annotated AnnotationBoost and
+// re-sorts when a score changed.
+func (a annotations) boost(results []store.RetrievalResult) {
+	changed := false
+	for i := range results {
+		for _, n := range a[results[i].ChunkID] {
+			if n.Kind == store.AnnotationBoost && n.Boost > 0 && n.Boost != 1 {
+				results[i].Score *= n.Boost
+				changed = true
+			}
+		}
+	}
+	if changed {
+		sort.SliceStable(results, func(i, j int) bool {
+			return results[i].Score > results[j].Score
+		})
+	}
+}
+
+// attach sets the corrections and approved answers of results' chunks for
+// the reasoning prompt.
+func (a annotations) attach(results []store.RetrievalResult) {
+	for i := range results {
+		for _, n := range a[results[i].ChunkID] {
+			switch n.Kind {
+			case store.AnnotationCorrection:
+				results[i].Correction = n.Text
+			case store.AnnotationCanonical:
+				results[i].CanonicalAnswer = n.Text
+			}
+		}
+	}
+}
+
+// flagConflicts attaches the recorded contradictions of results' chunks
+// and returns how many results were flagged. A failed read is logged and
+// the results are left unflagged.
+func (e *Engine) flagConflicts(ctx context.Context, results []store.RetrievalResult) int {
+	if len(results) == 0 {
+		return 0
+	}
+	ids := make([]int64, len(results))
+	for i, r := range results {
+		ids[i] = r.ChunkID
+	}
+	found, err := e.store.ContradictionsForChunks(ctx, ids)
+	if err != nil {
+		slog.WarnContext(ctx, "retrieval: loading contradictions failed", "error", err)
+		return 0
+	}
+	flagged := 0
+	for i := range results {
+		list := found[results[i].ChunkID]
+		for _, c := range list {
+			results[i].Conflicts = append(results[i].Conflicts, c.Note(results[i].ChunkID))
+		}
+		if len(list) > 0 {
+			flagged++
+		}
+	}
+	return flagged
+}
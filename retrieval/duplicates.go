@@ -0,0 +1,18 @@
+package retrieval
+
+import (
+	"context"
+	"log/slog"
+)
+
+// loadContentHashes reads the content hash of every candidate in legs, for
+// collapsing duplicates in fusion. A failed read is logged and nothing is
+// collapsed.
+func (e *Engine) loadContentHashes(ctx context.Context, legs []rrfLeg) map[int64]string {
+	hashes, err := e.store.ChunkContentHashes(ctx, candidateIDs(legs))
+	if err != nil {
+		slog.WarnContext(ctx, "retrieval: loading chunk content hashes failed", "error", err)
+		return nil
+	}
+	return hashes
+}
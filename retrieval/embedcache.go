@@ -0,0 +1,124 @@
+package retrieval
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+)
+
+// EmbeddingCacheStats reports query embedding cache effectiveness.
+type EmbeddingCacheStats struct {
+	Hits     int64 `json:"hits"`
+	Misses   int64 `json:"misses"`
+	Size     int   `json:"size"`
+	Capacity int   `json:"capacity"`
+}
+
+// embeddingCache is an LRU of query embeddings keyed by embedding model and
+// normalized query text, so repeated or trivially re-worded questions
+// within a session skip the embedding call. A nil *embeddingCache caches
+// nothing.
+type embeddingCache struct {
+	mu       sync.Mutex
+	capacity int
+	model    string
+	order    *list.List // front = most recently used
+	items    map[string]*list.Element
+	hits     int64
+	misses   int64
+}
+
+type embeddingCacheEntry struct {
+	key       string
+	embedding []float32
+}
+
+// newEmbeddingCache returns a cache of model's query embeddings, or nil
+// (caching disabled) when capacity <= 0.
+func newEmbeddingCache(capacity int, model string) *embeddingCache {
+	if capacity <= 0 {
+		return nil
+	}
+	return &embeddingCache{
+		capacity: capacity,
+		model:    model,
+		order:    list.New(),
+		items:    make(map[string]*list.Element, capacity),
+	}
+}
+
+// normalizeQueryKey lowercases, collapses whitespace, and strips trailing
+// punctuation so "What is X?" and "what is  x" share an entry.
+func normalizeQueryKey(query string) string {
+	key := strings.Join(strings.Fields(strings.ToLower(query)), " ")
+	return strings.TrimRight(key, "?.!¿¡ ")
+}
+
+// key returns the entry key of query.
+func (c *embeddingCache) key(query string) string {
+	return c.model + "\x00" + normalizeQueryKey(query)
+}
+
+// get returns the cached embedding for query and records a hit or miss.
+func (c *embeddingCache) get(query string) ([]float32, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[c.key(query)]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	c.order.MoveToFront(el)
+	return el.Value.(*embeddingCacheEntry).embedding, true
+}
+
+// put stores an embedding, evicting the least recently used entry when full.
+func (c *embeddingCache) put(query string, embedding []float32) {
+	if c == nil {
+		return
+	}
+	key := c.key(query)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		el.Value.(*embeddingCacheEntry).embedding = embedding
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(&embeddingCacheEntry{key: key, embedding: embedding})
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*embeddingCacheEntry).key)
+	}
+}
+
+// reset drops every entry, keeping the hit and miss counts.
+func (c *embeddingCache) reset() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	clear(c.items)
+}
+
+// stats returns a snapshot of the cache counters.
+func (c *embeddingCache) stats() EmbeddingCacheStats {
+	if c == nil {
+		return EmbeddingCacheStats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return EmbeddingCacheStats{
+		Hits:     c.hits,
+		Misses:   c.misses,
+		Size:     c.order.Len(),
+		Capacity: c.capacity,
+	}
+}
@@ -0,0 +1,325 @@
+package retrieval
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bbiangul/go-reason/store"
+)
+
+// unitDef converts a unit to its dimension's base unit:
+// base = value*factor + offset.
+type unitDef struct {
+	dimension string
+	base      string
+	factor    float64
+	offset    float64
+}
+
+// Dimensions and the base units values are indexed in.
+var unitsByDimension = map[string]string{
+	"pressure":    "kPa",
+	"temperature": "°C",
+	"voltage":     "V",
+	"current":     "A",
+	"power":       "W",
+	"length":      "mm",
+	"mass":        "kg",
+	"frequency":   "Hz",
+	"torque":      "N·m",
+	"flow":        "L/min",
+	"speed":       "rpm",
+	"sound":       "dB",
+}
+
+func unit(dimension string, factor, offset float64) unitDef {
+	return unitDef{dimension: dimension, base: unitsByDimension[dimension], factor: factor, offset: offset}
+}
+
+// caseUnits are matched case-insensitively; exactUnits only as written,
+// where case tells units apart (mW and MW) or a lone letter would match
+// ordinary words.
+var (
+	caseUnits = map[string]unitDef{
+		"pa": unit("pressure", 0.001, 0), "kpa": unit("pressure", 1, 0), "mpa": unit("pressure", 1000, 0),
+		"bar": unit("pressure", 100, 0), "mbar": unit("pressure", 0.1, 0), "barg": unit("pressure", 100, 0),
+		"psi": unit("pressure", 6.894757, 0), "psig": unit("pressure", 6.894757, 0), "psia": unit("pressure", 6.894757, 0),
+		"atm": unit("pressure", 101.325, 0),
+
+		"°c": unit("temperature", 1, 0), "ºc": unit("temperature", 1, 0), "deg c": unit("temperature", 1, 0),
+		"°f": unit("temperature", 5.0/9, -32*5.0/9), "ºf": unit("temperature", 5.0/9, -32*5.0/9), "deg f": unit("temperature", 5.0/9, -32*5.0/9),
+
+		"v": unit("voltage", 1, 0), "vac": unit("voltage", 1, 0), "vdc": unit("voltage", 1, 0),
+		"kv": unit("voltage", 1000, 0), "volts": unit("voltage", 1, 0),
+
+		"amps": unit("current", 1, 0), "ma": unit("current", 0.001, 0),
+
+		"hp": unit("power", 745.7, 0),
+
+		"mm": unit("length", 1, 0), "cm": unit("length", 10, 0), "km": unit("length", 1e6, 0),
+		"inch": unit("length", 25.4, 0), "inches": unit("length", 25.4, 0), "ft": unit("length", 304.8, 0),
+
+		"g": unit("mass", 0.001, 0), "kg": unit("mass", 1, 0), "lb": unit("mass", 0.45359237, 0), "lbs": unit("mass", 0.45359237, 0),
+
+		"hz": unit("frequency", 1, 0), "khz": unit("frequency", 1e3, 0), "mhz": unit("frequency", 1e6, 0), "ghz": unit("frequency", 1e9, 0),
+
+		"nm": unit("torque", 1, 0), "n·m": unit("torque", 1, 0), "n.m": unit("torque", 1, 0), "n-m": unit("torque", 1, 0),
+		"ft-lb": unit("torque", 1.355818, 0), "ft·lb": unit("torque", 1.355818, 0), "lbf·ft": unit("torque", 1.355818, 0),
+		"lb-ft": unit("torque", 1.355818, 0), "in-lb": unit("torque", 0.1129848, 0),
+
+		"l/min": unit("flow", 1, 0), "lpm": unit("flow", 1, 0), "gpm": unit("flow", 3.785412, 0),
+		"m3/h": unit("flow", 1000.0/60, 0), "m³/h": unit("flow", 1000.0/60, 0),
+
+		"rpm": unit("speed", 1, 0), "db": unit("sound", 1, 0), "dba": unit("sound", 1, 0), "db(a)": unit("sound", 1, 0),
+	}
+	exactUnits = map[string]unitDef{
+		"A": unit("current", 1, 0), "kA": unit("current", 1000, 0),
+		"mV": unit("voltage", 0.001, 0), "MV": unit("voltage", 1e6, 0),
+		"W": unit("power", 1, 0), "kW": unit("power", 1000, 0), "KW": unit("power", 1000, 0),
+		"mW": unit("power", 0.001, 0), "MW": unit("power", 1e6, 0),
+		"m": unit("length", 1000, 0),
+	}
+)
+
+// lookupUnit returns the definition of a unit as written.
+func lookupUnit(u string) (unitDef, bool) {
+	if d, ok := exactUnits[u]; ok {
+		return d, true
+	}
+	d, ok := caseUnits[strings.ToLower(u)]
+	return d, ok
+}
+
+// numberPattern matches a number with optional thousands separators or a
+// decimal comma: 75, -4.5, 1,000, 2,5.
+const numberPattern = `-?\d+(?:[.,]\d+)*`
+
+var (
+	unitPattern = func() string {
+		var all []string
+		for u := range caseUnits {
+			all = append(all, regexp.QuoteMeta(u))
+		}
+		for u := range exactUnits {
+			all = append(all, regexp.QuoteMeta(u))
+		}
+		// Longest first, so "psig" is not matched as "psi".
+		sort.Slice(all, func(i, j int) bool {
+			if len(all[i]) != len(all[j]) {
+				return len(all[i]) > len(all[j])
+			}
+			return all[i] < all[j]
+		})
+		return `(?i:` + strings.Join(all, "|") + `)`
+	}()
+
+	// reQuantity finds "75 psig" and ranges such as "70-90 PSIG" or
+	// "10 to 40 °C", where the unit follows the second number.
+	reQuantity = regexp.MustCompile(`(?:^|[^\pL\pN.,])(` + numberPattern + `)(?:\s*(?:-|–|to|a|\.\.)\s*(` + numberPattern + `))?\s?(` + unitPattern + `)(?:$|[^\pL\pN/])`)
+)
+
+// parseNumber parses a number written with "." or "," as the decimal or
+// thousands separator. The last separator is the decimal one, unless three
+// digits follow it and it is a "," or appears more than once (1,000 and
+// 1.000.000); "2,5" is two and a half.
+func parseNumber(s string) (float64, bool) {
+	if i := strings.LastIndexAny(s, ".,"); i >= 0 {
+		sep, intPart, frac := s[i], s[:i], s[i+1:]
+		strip := strings.NewReplacer(",", "", ".", "")
+		if len(frac) == 3 && (sep == ',' || strings.IndexByte(intPart, sep) >= 0) {
+			s = strip.Replace(s)
+		} else {
+			s = strip.Replace(intPart) + "." + frac
+		}
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	return v, err == nil && !math.IsInf(v, 0) && !math.IsNaN(v)
+}
+
+// Quantity is a numeric value with a unit found in text, converted to its
+// dimension's base unit.
+type Quantity struct {
+	Dimension string
+	Value     float64 // in Unit
+	Unit      string  // the dimension's base unit
+	Raw       string  // as written
+}
+
+// ExtractQuantities finds the numeric values with units in text, such as
+// "75 PSIG", "230 VAC", or both ends of "10–40 °C", normalised to base
+// units (kPa, °C, V, mm, ...) so values written in different units
+// compare.
+func ExtractQuantities(text string) []Quantity {
+	var out []Quantity
+	seen := make(map[string]bool)
+	add := func(num, u, raw string) {
+		v, ok := parseNumber(num)
+		if !ok {
+			return
+		}
+		d, ok := lookupUnit(u)
+		if !ok {
+			return
+		}
+		q := Quantity{Dimension: d.dimension, Value: round6(v*d.factor + d.offset), Unit: d.base, Raw: raw}
+		key := fmt.Sprintf("%s|%g", q.Dimension, q.Value)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		out = append(out, q)
+	}
+	for _, m := range reQuantity.FindAllStringSubmatchIndex(text, -1) {
+		raw, u := text[m[2]:m[7]], text[m[6]:m[7]]
+		add(text[m[2]:m[3]], u, raw)
+		if m[4] >= 0 {
+			add(text[m[4]:m[5]], u, raw)
+		}
+	}
+	return out
+}
+
+// round6 rounds conversions to six decimal places, so 100 psi in
+// the text and in a question land on the same value.
+func round6(v float64) float64 {
+	return math.Round(v*1e6) / 1e6
+}
+
+// NormalizeRange converts a range given in unitName to a store.NumericRange in
+// the dimension's base unit. A nil bound leaves that side open.
+func NormalizeRange(min, max *float64, unitName string) (store.NumericRange, error) {
+	d, ok := lookupUnit(strings.TrimSpace(unitName))
+	if !ok {
+		return store.NumericRange{}, fmt.Errorf("unknown unit %q", unitName)
+	}
+	if min == nil && max == nil {
+		return store.NumericRange{}, fmt.Errorf("range in %s has no bounds", unitName)
+	}
+	r := store.NumericRange{Dimension: d.dimension, Unit: d.base, Min: math.Inf(-1), Max: math.Inf(1)}
+	if min != nil {
+		r.Min = round6(*min*d.factor + d.offset)
+	}
+	if max != nil {
+		r.Max = round6(*max*d.factor + d.offset)
+	}
+	if r.Min > r.Max {
+		return store.NumericRange{}, fmt.Errorf("range in %s: minimum above maximum", unitName)
+	}
+	return r, nil
+}
+
+var (
+	num   = `(` + numberPattern + `)`
+	unitG = `\s?(` + unitPattern + `)`
+
+	// "between 70 and 90 PSIG", "between 70 psig and 90 psig".
+	reBetween = regexp.MustCompile(`(?i)(?:^|[^\pL\pN])(?:between|entre)\s+` + num + `(?:\s?(?:` + unitPattern + `))?\s*(?:and|y|-|–)\s*` + num + unitG + `(?:$|[^\pL\pN/])`)
+	// "from 70 to 90 psig", "70-90 psig", "70 to 90 psig".
+	reSpan = regexp.MustCompile(`(?i)(?:^|[^\pL\pN.,])(?:(?:from|de|desde)\s+)?` + num + `(?:\s?(?:` + unitPattern + `))?\s*(?:to|-|–|a|hasta|\.\.)\s*` + num + unitG + `(?:$|[^\pL\pN/])`)
+	// "above 90 psi", "at least 10 bar", ">= 5 kW".
+	reAbove = regexp.MustCompile(`(?i)(?:^|[^\pL\pN])(at least|no less than|minimum of|min\.?|>=|≥|more than|greater than|higher than|over|above|exceeding|>|más de|mayor(?:es)? (?:a|que)|superior(?:es)? a)\s*` + num + unitG + `(?:$|[^\pL\pN/])`)
+	// "below 90 psi", "at most 10 bar", "<= 5 kW", "up to 40 °C".
+	reBelow = regexp.MustCompile(`(?i)(?:^|[^\pL\pN])(at most|no more than|maximum of|max\.?|up to|<=|≤|less than|lower than|under|below|<|menos de|menor(?:es)? (?:a|que)|inferior(?:es)? a|hasta)\s*` + num + unitG + `(?:$|[^\pL\pN/])`)
+)
+
+// ParseNumericConstraints extracts numeric constraints from a question:
+// ranges ("between 70 and 90 PSIG", "70-90 psig"), bounds ("above 10
+// bar", "at most 40 °C"), and exact values ("rated 230 V"), normalised to
+// base units. Values without a known unit are ignored.
+func ParseNumericConstraints(question string) []store.NumericRange {
+	var out []store.NumericRange
+	used := make([]bool, len(question))
+	claim := func(loc []int) bool {
+		for i := loc[0]; i < loc[1]; i++ {
+			if used[i] {
+				return false
+			}
+		}
+		for i := loc[0]; i < loc[1]; i++ {
+			used[i] = true
+		}
+		return true
+	}
+	add := func(min, max *float64, u string) {
+		r, err := NormalizeRange(min, max, u)
+		if err == nil {
+			out = append(out, r)
+		}
+	}
+
+	for _, re := range []*regexp.Regexp{reBetween, reSpan} {
+		for _, m := range re.FindAllStringSubmatchIndex(question, -1) {
+			lo, ok1 := parseNumber(question[m[2]:m[3]])
+			hi, ok2 := parseNumber(question[m[4]:m[5]])
+			if !ok1 || !ok2 || !claim(m[:2]) {
+				continue
+			}
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			add(&lo, &hi, question[m[6]:m[7]])
+		}
+	}
+	for _, m := range reAbove.FindAllStringSubmatchIndex(question, -1) {
+		v, ok := parseNumber(question[m[4]:m[5]])
+		if !ok || !claim(m[:2]) {
+			continue
+		}
+		add(&v, nil, question[m[6]:m[7]])
+	}
+	for _, m := range reBelow.FindAllStringSubmatchIndex(question, -1) {
+		v, ok := parseNumber(question[m[4]:m[5]])
+		if !ok || !claim(m[:2]) {
+			continue
+		}
+		add(nil, &v, question[m[6]:m[7]])
+	}
+	for _, m := range reQuantity.FindAllStringSubmatchIndex(question, -1) {
+		if !claim(m[:2]) {
+			continue
+		}
+		v, ok := parseNumber(question[m[2]:m[3]])
+		if !ok {
+			continue
+		}
+		add(&v, &v, question[m[6]:m[7]])
+	}
+	return out
+}
+
+// applyNumericFilters removes from every leg the chunks without a value in
+// each of ranges and returns how many distinct chunks were removed.
+func (e *Engine) applyNumericFilters(ctx context.Context, legs []rrfLeg, ranges []store.NumericRange) (int, error) {
+	seen := make(map[int64]bool)
+	var ids []int64
+	for _, leg := range legs {
+		for _, r := range leg.results {
+			if !seen[r.ChunkID] {
+				seen[r.ChunkID] = true
+				ids = append(ids, r.ChunkID)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	inRange, err := e.store.ChunksInRanges(ctx, ids, ranges)
+	if err != nil {
+		return 0, err
+	}
+	for i := range legs {
+		kept := legs[i].results[:0:0]
+		for _, r := range legs[i].results {
+			if inRange[r.ChunkID] {
+				kept = append(kept, r)
+			}
+		}
+		legs[i].results = kept
+	}
+	return len(ids) - len(inRange), nil
+}
@@ -2,10 +2,13 @@ package retrieval
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/bbiangul/go-reason/llm"
@@ -49,25 +52,155 @@ type Config struct {
 	WeightVector float64
 	WeightFTS    float64
 	WeightGraph  float64
+	WeightSparse float64
+	WeightImage  float64
+
+	// WeightSecondary weights the secondary embedding space's vector leg
+	// (see SetSecondaryEmbedder).
+	WeightSecondary float64
+
+	// WeightNumeric weights the numeric leg: chunks with values in the
+	// ranges the query states ("between 70 and 90 PSIG", "above 10 bar"),
+	// found in the chunk_quantities index. 0 turns off reading ranges from
+	// the query; explicit SearchOptions.NumericFilters still apply.
+	WeightNumeric float64
+
+	// FTSHeadingWeight is the BM25 weight of chunk headings relative to
+	// chunk content in the FTS leg. 0 or 1 weights them alike.
+	FTSHeadingWeight float64
+
+	// TypeBoosts multiplies the fused score of chunks by chunk_type
+	// (e.g. {"requirement": 1.3}). Types not listed are left unchanged.
+	TypeBoosts map[string]float64
+
+	// QueryCacheSize is the number of query embeddings kept in an
+	// in-memory LRU. 0 disables the cache.
+	QueryCacheSize int
+
+	// EmbeddingModel and SecondaryEmbeddingModel name the models whose
+	// query embeddings are cached, so an entry is never reused for the
+	// vectors of another model.
+	EmbeddingModel          string
+	SecondaryEmbeddingModel string
+
+	// GraphMaxDepth bounds the relationship hops the graph leg follows from
+	// the query's entities. Values above 1 enable multi-hop search with
+	// path-weight decay; 0 or 1 keeps the direct-link search.
+	GraphMaxDepth int
+
+	// EntitySeedSimilarity is the minimum cosine similarity between the
+	// query and an entity's description embedding for the entity to seed
+	// the graph leg alongside the entities matched by name. This lets
+	// paraphrased questions reach the graph when no query term matches an
+	// entity name. 0 disables vector seeding.
+	EntitySeedSimilarity float64
+
+	// LegTimeout bounds each retrieval leg (and the query embedding the
+	// vector legs share). A leg that runs past it is left out of fusion
+	// and reported in SearchTrace.TimedOutLegs, so one slow leg does not
+	// fail the whole search. 0 means no per-leg limit.
+	LegTimeout time.Duration
+
+	// TranslationTimeout bounds cross-language query translation; past it
+	// the search goes on with the untranslated terms. 0 means no limit.
+	TranslationTimeout time.Duration
+
+	// KeepDuplicates returns chunks with the same content as separate
+	// results. By default they are collapsed into the best ranked copy,
+	// which lists the others in Duplicates.
+	KeepDuplicates bool
+
+	// UsageBoost multiplies the fused score of chunks by up to
+	// 1+UsageBoost as earlier answers retrieved and, above all, cited
+	// them (see store.ChunkUsage), so commonly useful sections surface
+	// faster. 0 disables the boost.
+	UsageBoost float64
+
+	// UsageHalfLife halves the weight of a past use every period, so
+	// chunks that stop being useful fade back. 0 never decays.
+	UsageHalfLife time.Duration
+
+	// ScoreAdjusters nudge fused scores with domain heuristics (see
+	// ScoreAdjuster).
+	ScoreAdjusters []ScoreAdjuster
 }
 
+// graphMaxPathEntities caps how many entities a multi-hop expansion visits,
+// keeping hub entities from flooding the graph leg.
+const graphMaxPathEntities = 200
+
+// entitySeedK is how many nearest entities vector seeding considers before
+// applying Config.EntitySeedSimilarity.
+const entitySeedK = 10
+
 // SearchOptions configures a single search operation.
 type SearchOptions struct {
-	MaxResults  int
-	WeightVec   float64
-	WeightFTS   float64
-	WeightGraph float64
+	MaxResults   int
+	WeightVec    float64
+	WeightFTS    float64
+	WeightGraph  float64
+	WeightSparse float64
+	WeightImage  float64
+
+	// DocumentIDs restricts results to these documents (e.g. one
+	// collection). Empty searches the whole corpus.
+	DocumentIDs []int64
+
+	// ExcludeDocumentIDs drops results from these documents (e.g. another
+	// session's temporary uploads), on top of DocumentIDs.
+	ExcludeDocumentIDs []int64
+
+	// SkipGraph turns the graph leg off, e.g. to compare answers with and
+	// without it. The other legs keep their weights.
+	SkipGraph bool
+
+	// WeightSecondary overrides Config.WeightSecondary.
+	WeightSecondary float64
+
+	// EmbeddingSpace selects the dense vector legs when a secondary space
+	// is configured: one of the EmbeddingSpace* constants. Empty means
+	// EmbeddingSpaceBoth.
+	EmbeddingSpace string
+
+	// NumericFilters restricts results to chunks with a value in every
+	// range (see NormalizeRange). The ranges also drive the numeric leg
+	// in place of those read from the query.
+	NumericFilters []store.NumericRange
 }
 
+// Embedding spaces for SearchOptions.EmbeddingSpace.
+const (
+	EmbeddingSpaceBoth      = "both"      // fuse the primary and secondary vector legs
+	EmbeddingSpacePrimary   = "primary"   // primary embedding model only
+	EmbeddingSpaceSecondary = "secondary" // secondary embedding model only
+)
+
+// scopedOverfetch widens each leg's window when results are restricted to
+// a subset of documents, since the legs rank the whole corpus and results
+// from other documents are only dropped afterwards. A leg left with too
+// few results is searched again over a window this much wider, up to
+// scopedMaxWindow.
+const (
+	scopedOverfetch = 5
+	scopedMaxWindow = 1000
+)
+
 // SearchTrace records the full breakdown of a hybrid search operation.
 type SearchTrace struct {
 	VecResults          int                `json:"vec_results"`
 	FTSResults          int                `json:"fts_results"`
 	GraphResults        int                `json:"graph_results"`
+	SparseResults       int                `json:"sparse_results,omitempty"`
+	ImageResults        int                `json:"image_results,omitempty"`
+	SecondaryResults    int                `json:"secondary_results,omitempty"`
+	NumericResults      int                `json:"numeric_results,omitempty"`
 	FusedResults        int                `json:"fused_results"`
 	VecWeight           float64            `json:"vec_weight"`
 	FTSWeight           float64            `json:"fts_weight"`
 	GraphWeight         float64            `json:"graph_weight"`
+	SparseWeight        float64            `json:"sparse_weight,omitempty"`
+	ImageWeight         float64            `json:"image_weight,omitempty"`
+	SecondaryWeight     float64            `json:"secondary_weight,omitempty"`
 	IdentifiersDetected bool               `json:"identifiers_detected"`
 	SynthesisMode       bool               `json:"synthesis_mode"`
 	MaxRequested        int                `json:"max_requested"`
@@ -75,29 +208,179 @@ type SearchTrace struct {
 	FollowUpResults     int                `json:"follow_up_results,omitempty"`
 	FTSQuery            string             `json:"fts_query"`
 	GraphEntities       []string           `json:"graph_entities"`
+
+	// Entities seeded into the graph leg by description similarity
+	// (Config.EntitySeedSimilarity).
+	GraphSeedEntities []string `json:"graph_seed_entities,omitempty"`
+
+	// Entities the graph leg linked the question to, and how: the
+	// matches it started from, then the related entities it reached.
+	LinkedEntities []EntityLink `json:"linked_entities,omitempty"`
+
+	SelectedDocuments   []int64            `json:"selected_documents,omitempty"`
+	ScopedDocuments     int                `json:"scoped_documents,omitempty"`
+
+	// Candidates dropped because a curator marked them "do not retrieve".
+	CuratorExcluded int `json:"curator_excluded,omitempty"`
+
+	// Fused results whose score was multiplied by their document's
+	// importance (a value other than 1, set at ingest).
+	ImportanceWeighted int `json:"importance_weighted,omitempty"`
+
+	// Fused results flagged with a recorded contradiction with another
+	// document (see store.Contradiction).
+	Conflicted int `json:"conflicted,omitempty"`
+
+	// Fused candidates dropped as copies of a better ranked result with
+	// the same content (see Config.KeepDuplicates).
+	DuplicatesCollapsed int `json:"duplicates_collapsed,omitempty"`
+
+	// Fused results whose score was raised by their past retrievals and
+	// citations (see Config.UsageBoost).
+	UsageBoosted int `json:"usage_boosted,omitempty"`
+
+	// Fused results whose score Config.ScoreAdjusters changed.
+	ScoreAdjusted int `json:"score_adjusted,omitempty"`
+
+	// Numeric ranges the numeric leg searched, read from the query or
+	// given as NumericFilters, and how many candidates the filters
+	// dropped for lacking a value in range.
+	NumericRanges   []string `json:"numeric_ranges,omitempty"`
+	NumericFiltered int      `json:"numeric_filtered,omitempty"`
+
+	// Legs that were short-circuited because the index cannot serve them
+	// (e.g. "graph" when no entities were extracted). Their weight is
+	// redistributed over the remaining legs; see Vec/FTS/...Weight.
+	SkippedLegs []string `json:"skipped_legs,omitempty"`
+
+	// Legs that ran past Config.LegTimeout and were fused without, and
+	// "translation" when query translation ran past
+	// Config.TranslationTimeout. The results are partial.
+	TimedOutLegs []string `json:"timed_out_legs,omitempty"`
+
+	// LLM tokens spent on cross-language query translation (zero on cache hit).
+	TranslationPromptTokens     int `json:"translation_prompt_tokens,omitempty"`
+	TranslationCompletionTokens int `json:"translation_completion_tokens,omitempty"`
 	ElapsedMs           int64              `json:"elapsed_ms"`
 	PerResult           map[int64]FusedResultInfo `json:"per_result,omitempty"`
 }
 
+// How an entity was linked to the question, for EntityLink.Match.
+const (
+	LinkName       = "name"          // a query term is the entity's name
+	LinkNameSubstr = "name_contains" // the entity's name contains a query term
+	LinkNameEN     = "name_en"       // the English canonical name contains a query term
+	LinkEmbedding  = "embedding"     // description similarity (Config.EntitySeedSimilarity)
+	LinkRelated    = "related"       // reached over relationships from a match
+)
+
+// maxRelatedLinks caps the related entities SearchTrace.LinkedEntities
+// lists; a multi-hop expansion may reach hundreds.
+const maxRelatedLinks = 20
+
+// EntityLink is an entity the graph leg linked the question to. A wrong
+// link, such as "tracker" matching an unrelated part, shows here before
+// it shows in the answer.
+type EntityLink struct {
+	ID     int64  `json:"id"`
+	Name   string `json:"name"`
+	NameEN string `json:"name_en,omitempty"`
+	Type   string `json:"type,omitempty"`
+	Match  string `json:"match"`          // one of the Link* constants
+	Term   string `json:"term,omitempty"` // the query term matched by name
+	// Path is how a related entity was reached, e.g.
+	// "av-fm -[references]-> en 1366-2".
+	Path string `json:"path,omitempty"`
+	// Stopped marks a stop entity, matched but not searched from.
+	Stopped bool `json:"stopped,omitempty"`
+}
+
+// Store is the storage retrieval searches. *store.Store implements it.
+type Store interface {
+	store.DocumentStore
+	store.ChunkStore
+	store.VectorIndex
+	store.GraphStore
+	store.TranslationStore
+}
+
 // Engine performs hybrid retrieval combining vector, FTS, and graph search.
 type Engine struct {
-	store      *store.Store
+	store      Store
 	embedder   llm.Provider
+	sparse     llm.SparseEmbedder
+	image      llm.MultimodalEmbedder
 	translator *Translator
+	queryCache *embeddingCache
 	cfg        Config
+
+	secondary      llm.Provider
+	secondaryCache *embeddingCache
 }
 
 // New creates a new retrieval engine. chatLLM is used for cross-language
 // query translation; pass nil to disable translation.
-func New(s *store.Store, embedder llm.Provider, chatLLM llm.Provider, cfg Config) *Engine {
+func New(s Store, embedder llm.Provider, chatLLM llm.Provider, cfg Config) *Engine {
 	return &Engine{
 		store:      s,
 		embedder:   embedder,
 		translator: NewTranslator(chatLLM, s),
+		queryCache: newEmbeddingCache(cfg.QueryCacheSize, cfg.EmbeddingModel),
 		cfg:        cfg,
 	}
 }
 
+// QueryCacheStats reports hits and misses of the query embedding cache.
+func (e *Engine) QueryCacheStats() EmbeddingCacheStats {
+	return e.queryCache.stats()
+}
+
+// ResetQueryCache drops the cached query embeddings of both embedding
+// spaces, e.g. once the corpus was re-embedded.
+func (e *Engine) ResetQueryCache() {
+	e.queryCache.reset()
+	e.secondaryCache.reset()
+}
+
+// TranslationCacheStats reports hits, misses, and token savings of the
+// query translation cache.
+func (e *Engine) TranslationCacheStats() TranslationCacheStats {
+	return e.translator.CacheStats()
+}
+
+// Translator returns the engine's cross-language query translator.
+func (e *Engine) Translator() *Translator {
+	return e.translator
+}
+
+// SetTranslator replaces the engine's query translator, letting several
+// engines share one translation cache.
+func (e *Engine) SetTranslator(t *Translator) {
+	e.translator = t
+}
+
+// SetSparseEmbedder enables the learned sparse retrieval leg. When unset
+// (the default), Search runs only the vector, FTS, and graph legs.
+func (e *Engine) SetSparseEmbedder(sp llm.SparseEmbedder) {
+	e.sparse = sp
+}
+
+// SetImageEmbedder enables the image-similarity leg: the query is embedded
+// with the multimodal model and matched against vec_images, so chunks are
+// found by what their images show rather than only by caption text.
+func (e *Engine) SetImageEmbedder(mm llm.MultimodalEmbedder) {
+	e.image = mm
+}
+
+// SetSecondaryEmbedder enables the secondary vector leg: the query is also
+// embedded with a second model and matched against vec_chunks_secondary,
+// e.g. a multilingual model next to an English one for questions in one
+// language over manuals in another.
+func (e *Engine) SetSecondaryEmbedder(p llm.Provider) {
+	e.secondary = p
+	e.secondaryCache = newEmbeddingCache(e.cfg.QueryCacheSize, e.cfg.SecondaryEmbeddingModel)
+}
+
 // Search performs hybrid retrieval using RRF to fuse results from
 // vector search, FTS5, and graph-based retrieval.
 // Returns fused results and a SearchTrace with the full breakdown.
@@ -114,11 +397,76 @@ func (e *Engine) Search(ctx context.Context, query string, opts SearchOptions) (
 	if opts.WeightGraph == 0 {
 		opts.WeightGraph = e.cfg.WeightGraph
 	}
+	if opts.WeightSparse == 0 {
+		opts.WeightSparse = e.cfg.WeightSparse
+	}
+	if e.sparse == nil {
+		opts.WeightSparse = 0
+	}
+	if opts.WeightImage == 0 {
+		opts.WeightImage = e.cfg.WeightImage
+	}
+	if e.image == nil {
+		opts.WeightImage = 0
+	}
+	if opts.WeightSecondary == 0 {
+		opts.WeightSecondary = e.cfg.WeightSecondary
+	}
+	skipPrimary := false
+	switch {
+	case e.secondary == nil:
+		opts.WeightSecondary = 0
+	case opts.EmbeddingSpace == EmbeddingSpacePrimary:
+		opts.WeightSecondary = 0
+	case opts.EmbeddingSpace == EmbeddingSpaceSecondary:
+		// The secondary leg takes over the primary's weight.
+		opts.WeightSecondary = opts.WeightVec
+		opts.WeightVec = 0
+		skipPrimary = true
+	}
+
+	// Graceful degradation: a corpus ingested with SkipGraph has no
+	// entities, so the graph leg can only burn time on entity lookups.
+	// Skip it and hand its weight to the other legs.
+	skipGraph := opts.SkipGraph
+	if skipGraph {
+		opts.WeightGraph = 0
+	} else if opts.WeightGraph > 0 {
+		hasGraph, err := e.store.HasGraph(ctx)
+		if err != nil {
+			slog.WarnContext(ctx, "retrieval: checking graph state failed", "error", err)
+		} else if !hasGraph {
+			skipGraph = true
+			opts = redistributeGraphWeight(opts)
+			slog.DebugContext(ctx, "retrieval: graph is empty, skipping graph leg",
+				"weights", fmt.Sprintf("vec=%.2f fts=%.2f sparse=%.2f image=%.2f secondary=%.2f",
+					opts.WeightVec, opts.WeightFTS, opts.WeightSparse, opts.WeightImage, opts.WeightSecondary))
+		}
+	}
+
+	// Numeric ranges: explicit filters, or those the query states.
+	numericRanges := opts.NumericFilters
+	weightNumeric := e.cfg.WeightNumeric
+	if len(numericRanges) == 0 && weightNumeric > 0 {
+		numericRanges = ParseNumericConstraints(query)
+	} else if len(numericRanges) > 0 && weightNumeric <= 0 {
+		weightNumeric = 1
+	}
 
 	trace := &SearchTrace{
-		VecWeight:   opts.WeightVec,
-		FTSWeight:   opts.WeightFTS,
-		GraphWeight: opts.WeightGraph,
+		VecWeight:    opts.WeightVec,
+		FTSWeight:    opts.WeightFTS,
+		GraphWeight:  opts.WeightGraph,
+		SparseWeight: opts.WeightSparse,
+		ImageWeight:  opts.WeightImage,
+
+		SecondaryWeight: opts.WeightSecondary,
+	}
+	if skipGraph {
+		trace.SkippedLegs = append(trace.SkippedLegs, "graph")
+	}
+	for _, r := range numericRanges {
+		trace.NumericRanges = append(trace.NumericRanges, r.String())
 	}
 
 	// Identifier-aware query routing: when the query contains structured
@@ -126,15 +474,17 @@ func (e *Engine) Search(ctx context.Context, query string, opts SearchOptions) (
 	// boost FTS weight by 2x and reduce vector weight by 0.5x so that
 	// exact-match retrieval is preferred over semantic similarity.
 	if detectIdentifiers(query) {
-		slog.Debug("retrieval: identifiers detected in query, boosting FTS weight",
+		slog.DebugContext(ctx, "retrieval: identifiers detected in query, boosting FTS weight",
 			"query", query,
 			"original_fts", opts.WeightFTS,
 			"original_vec", opts.WeightVec)
 		opts.WeightFTS *= 2.0
 		opts.WeightVec *= 0.5
+		opts.WeightSecondary *= 0.5
 		trace.IdentifiersDetected = true
 		trace.VecWeight = opts.WeightVec
 		trace.FTSWeight = opts.WeightFTS
+		trace.SecondaryWeight = opts.WeightSecondary
 	}
 
 	// Synthesis query detection: widen retrieval window for exhaustive queries
@@ -144,12 +494,51 @@ func (e *Engine) Search(ctx context.Context, query string, opts SearchOptions) (
 			opts.MaxResults = 40
 		}
 		trace.SynthesisMode = true
-		slog.Debug("retrieval: synthesis mode activated, widened retrieval window",
+		slog.DebugContext(ctx, "retrieval: synthesis mode activated, widened retrieval window",
 			"query", query, "max_results", opts.MaxResults)
 	}
 
+	// Document scoping: fetch a wider window per leg and drop results
+	// outside the scope before fusion.
+	legK := opts.MaxResults
+	var scope map[int64]bool
+	if len(opts.DocumentIDs) > 0 {
+		scope = make(map[int64]bool, len(opts.DocumentIDs))
+		for _, id := range opts.DocumentIDs {
+			scope[id] = true
+		}
+		legK *= scopedOverfetch
+		trace.ScopedDocuments = len(scope)
+	}
+	var excluded map[int64]bool
+	if len(opts.ExcludeDocumentIDs) > 0 {
+		excluded = make(map[int64]bool, len(opts.ExcludeDocumentIDs))
+		for _, id := range opts.ExcludeDocumentIDs {
+			excluded[id] = true
+		}
+		if scope == nil {
+			legK *= scopedOverfetch
+		}
+	}
+	var keep func([]store.RetrievalResult) []store.RetrievalResult
+	if scope != nil || excluded != nil {
+		keep = func(results []store.RetrievalResult) []store.RetrievalResult {
+			if scope != nil {
+				results = filterDocuments(results, scope)
+			}
+			if excluded != nil {
+				results = excludeDocuments(results, excluded)
+			}
+			return results
+		}
+	}
+	// legSearch runs a leg's search over the documents in scope.
+	legSearch := func(ctx context.Context, search func(ctx context.Context, k int) ([]store.RetrievalResult, error)) ([]store.RetrievalResult, error) {
+		return scopedSearch(ctx, legK, opts.MaxResults, keep, search)
+	}
+
 	// Run all three retrieval methods concurrently
-	slog.Debug("retrieval: starting hybrid search",
+	slog.DebugContext(ctx, "retrieval: starting hybrid search",
 		"query_len", len(query), "max_results", opts.MaxResults,
 		"weights", fmt.Sprintf("vec=%.1f fts=%.1f graph=%.1f", opts.WeightVec, opts.WeightFTS, opts.WeightGraph))
 	searchStart := time.Now()
@@ -157,65 +546,273 @@ func (e *Engine) Search(ctx context.Context, query string, opts SearchOptions) (
 	// Cross-language expansion: translate significant query terms to
 	// the document language so FTS and graph search can match content
 	// written in a different language than the query.
-	translated := e.translator.TranslateTerms(ctx, extractSignificantTerms(query))
+	translated, translateUsage, translateTimedOut := e.translate(ctx, extractSignificantTerms(query))
+	if translateTimedOut {
+		slog.WarnContext(ctx, "retrieval: query translation timed out, searching untranslated terms",
+			"timeout", e.cfg.TranslationTimeout)
+		trace.TimedOutLegs = append(trace.TimedOutLegs, "translation")
+	}
+	trace.TranslationPromptTokens = translateUsage.PromptTokens
+	trace.TranslationCompletionTokens = translateUsage.CompletionTokens
 
 	// Capture FTS query for trace
 	ftsQuery := sanitizeFTSQuery(query, translated)
 	trace.FTSQuery = ftsQuery
 
 	// Capture graph entities for trace
-	graphEntities := extractQueryEntities(query, translated)
+	var graphEntities []string
+	if !skipGraph {
+		graphEntities = extractQueryEntities(query, translated)
+	}
 	trace.GraphEntities = graphEntities
 
-	type result struct {
-		results []store.RetrievalResult
-		err     error
-	}
+	// The vector leg and entity seeding share one query embedding, bounded
+	// like a leg.
+	embedCtx, cancelEmbed := e.legContext(ctx)
+	defer cancelEmbed()
+	embedQuery := sync.OnceValues(func() ([]float32, error) {
+		return e.queryEmbedding(embedCtx, query)
+	})
 
-	vecCh := make(chan result, 1)
-	ftsCh := make(chan result, 1)
-	graphCh := make(chan result, 1)
+	// Each leg runs concurrently under its own deadline.
+	vecLeg := e.startLeg(ctx, func(ctx context.Context) ([]store.RetrievalResult, error) {
+		if skipPrimary {
+			return nil, nil
+		}
+		emb, err := embedQuery()
+		if err != nil {
+			return nil, err
+		}
+		return legSearch(ctx, func(ctx context.Context, k int) ([]store.RetrievalResult, error) {
+			return e.store.VectorSearch(ctx, emb, k)
+		})
+	})
 
-	// Vector search
-	go func() {
-		r, err := e.vectorSearch(ctx, query, opts.MaxResults)
-		vecCh <- result{r, err}
-	}()
+	ftsLeg := e.startLeg(ctx, func(ctx context.Context) ([]store.RetrievalResult, error) {
+		return legSearch(ctx, func(ctx context.Context, k int) ([]store.RetrievalResult, error) {
+			return e.store.FTSSearchWeighted(ctx, ftsQuery, k, e.cfg.FTSHeadingWeight)
+		})
+	})
 
-	// FTS search
-	go func() {
-		r, err := e.store.FTSSearch(ctx, ftsQuery, opts.MaxResults)
-		ftsCh <- result{r, err}
-	}()
+	// Graph search. The seeds and links are handed over on channels, since
+	// a timed out leg may still be running when the results are collected.
+	seedCh := make(chan []store.Entity, 1)
+	linkCh := make(chan []EntityLink, 1)
+	graphLeg := e.startLeg(ctx, func(ctx context.Context) ([]store.RetrievalResult, error) {
+		if skipGraph {
+			return nil, nil
+		}
+		var seeds []store.Entity
+		if e.cfg.EntitySeedSimilarity > 0 {
+			emb, err := embedQuery()
+			if err == nil {
+				seeds, err = e.vectorSeedEntities(ctx, emb)
+			}
+			if err != nil {
+				slog.WarnContext(ctx, "retrieval: entity vector seeding failed", "error", err)
+			}
+		}
+		seedCh <- seeds
+		var links []EntityLink
+		results, err := legSearch(ctx, func(ctx context.Context, k int) (results []store.RetrievalResult, err error) {
+			results, links, err = e.graphSearchWithEntities(ctx, graphEntities, seeds, k, synthesisMode)
+			return results, err
+		})
+		linkCh <- links
+		return results, err
+	})
 
-	// Graph search
-	go func() {
-		r, err := e.graphSearchWithEntities(ctx, graphEntities, opts.MaxResults, synthesisMode)
-		graphCh <- result{r, err}
-	}()
+	// Sparse search (only when a sparse embedder is configured)
+	sparseLeg := e.startLeg(ctx, func(ctx context.Context) ([]store.RetrievalResult, error) {
+		if opts.WeightSparse <= 0 {
+			return nil, nil
+		}
+		return legSearch(ctx, func(ctx context.Context, k int) ([]store.RetrievalResult, error) {
+			return e.sparseSearch(ctx, query, k)
+		})
+	})
+
+	// Image similarity search (only when a multimodal embedder is configured)
+	imageLeg := e.startLeg(ctx, func(ctx context.Context) ([]store.RetrievalResult, error) {
+		if opts.WeightImage <= 0 {
+			return nil, nil
+		}
+		return legSearch(ctx, func(ctx context.Context, k int) ([]store.RetrievalResult, error) {
+			return e.imageSearch(ctx, query, k)
+		})
+	})
 
-	vecRes := <-vecCh
-	ftsRes := <-ftsCh
-	graphRes := <-graphCh
+	// Secondary embedding space (only when a secondary embedder is configured)
+	secondaryLeg := e.startLeg(ctx, func(ctx context.Context) ([]store.RetrievalResult, error) {
+		if opts.WeightSecondary <= 0 {
+			return nil, nil
+		}
+		return legSearch(ctx, func(ctx context.Context, k int) ([]store.RetrievalResult, error) {
+			return e.secondarySearch(ctx, query, k)
+		})
+	})
+
+	// Numeric ranges (only when the query states some or filters are given)
+	numericLeg := e.startLeg(ctx, func(ctx context.Context) ([]store.RetrievalResult, error) {
+		if len(numericRanges) == 0 {
+			return nil, nil
+		}
+		return legSearch(ctx, func(ctx context.Context, k int) ([]store.RetrievalResult, error) {
+			return e.store.NumericSearch(ctx, numericRanges, k)
+		})
+	})
+
+	await := func(name string, l *pendingLeg) legResult {
+		r, timedOut := l.wait(ctx)
+		if timedOut {
+			slog.WarnContext(ctx, "retrieval: leg timed out, fusing the other legs",
+				"leg", name, "timeout", e.cfg.LegTimeout)
+			trace.TimedOutLegs = append(trace.TimedOutLegs, name)
+		}
+		return r
+	}
+	vecRes := await("vector", vecLeg)
+	ftsRes := await("fts", ftsLeg)
+	graphRes := await("graph", graphLeg)
+	select {
+	case seeds := <-seedCh:
+		for _, ent := range seeds {
+			trace.GraphSeedEntities = append(trace.GraphSeedEntities, ent.Name)
+		}
+	default:
+	}
+	select {
+	case trace.LinkedEntities = <-linkCh:
+	default:
+	}
+	sparseRes := await("sparse", sparseLeg)
+	imageRes := await("image", imageLeg)
+	secondaryRes := await("vector_secondary", secondaryLeg)
+	numericRes := await("numeric", numericLeg)
+	// A cancelled search stops here rather than fusing and reranking the
+	// legs that finished before it.
+	if err := ctx.Err(); err != nil {
+		trace.ElapsedMs = time.Since(searchStart).Milliseconds()
+		return nil, trace, err
+	}
 
 	if vecRes.err != nil {
-		slog.Warn("retrieval: vector search failed", "error", vecRes.err)
+		slog.WarnContext(ctx, "retrieval: vector search failed", "error", vecRes.err)
+	}
+	if sparseRes.err != nil {
+		slog.WarnContext(ctx, "retrieval: sparse search failed", "error", sparseRes.err)
+	}
+	if imageRes.err != nil {
+		slog.WarnContext(ctx, "retrieval: image search failed", "error", imageRes.err)
+	}
+	if secondaryRes.err != nil {
+		slog.WarnContext(ctx, "retrieval: secondary vector search failed", "error", secondaryRes.err)
+	}
+	if numericRes.err != nil {
+		slog.WarnContext(ctx, "retrieval: numeric search failed", "error", numericRes.err)
 	}
 	trace.VecResults = len(vecRes.results)
 	trace.FTSResults = len(ftsRes.results)
 	trace.GraphResults = len(graphRes.results)
+	trace.SparseResults = len(sparseRes.results)
+	trace.ImageResults = len(imageRes.results)
+	trace.SecondaryResults = len(secondaryRes.results)
+	trace.NumericResults = len(numericRes.results)
 
-	slog.Debug("retrieval: searches complete",
+	slog.DebugContext(ctx, "retrieval: searches complete",
 		"vec_results", len(vecRes.results), "fts_results", len(ftsRes.results),
-		"graph_results", len(graphRes.results),
+		"graph_results", len(graphRes.results), "sparse_results", len(sparseRes.results),
+		"image_results", len(imageRes.results), "secondary_results", len(secondaryRes.results),
 		"elapsed", time.Since(searchStart).Round(time.Millisecond))
 
-	// Fuse results with RRF
-	fused, infoMap := fuseRRF(
-		vecRes.results, ftsRes.results, graphRes.results,
-		opts.WeightVec, opts.WeightFTS, opts.WeightGraph,
-		opts.MaxResults,
-	)
+	legs := []rrfLeg{
+		{method: "vector", results: vecRes.results, weight: opts.WeightVec},
+		{method: "fts", results: ftsRes.results, weight: opts.WeightFTS},
+		{method: "graph", results: graphRes.results, weight: opts.WeightGraph},
+		{method: "sparse", results: sparseRes.results, weight: opts.WeightSparse},
+		{method: "image", results: imageRes.results, weight: opts.WeightImage},
+		{method: "vector_secondary", results: secondaryRes.results, weight: opts.WeightSecondary},
+		{method: "numeric", results: numericRes.results, weight: weightNumeric},
+	}
+
+	// Explicit numeric filters drop candidates without a value in range.
+	if len(opts.NumericFilters) > 0 {
+		n, err := e.applyNumericFilters(ctx, legs, opts.NumericFilters)
+		if err != nil {
+			return nil, trace, fmt.Errorf("numeric filters: %w", err)
+		}
+		trace.NumericFiltered = n
+	}
+
+	// Curator annotations: chunks marked "do not retrieve" leave every leg
+	// before fusion, so the window is still filled from the remaining ones.
+	notes := e.loadAnnotations(ctx, legs)
+	trace.CuratorExcluded = notes.dropExcluded(legs)
+
+	// Fuse results with RRF. Copies of the same content collapse into one
+	// result before the window is cut, leaving room for distinct evidence.
+	var hashes map[int64]string
+	if !e.cfg.KeepDuplicates {
+		hashes = e.loadContentHashes(ctx, legs)
+	}
+	fused, infoMap := fuseDistinct(legs, 0, hashes)
+
+	// Chunk type boosts: favour configured types (definitions,
+	// requirements, ...) over equally ranked generic chunks.
+	applyTypeBoost(fused, e.cfg.TypeBoosts)
+
+	// Application heuristics, e.g. boosting chunks whose metadata names
+	// the model the query asks about.
+	trace.ScoreAdjusted = applyScoreAdjusters(ctx, e.cfg.ScoreAdjusters, query, fused, infoMap)
+
+	// Document importance: authoritative sources outrank conflicting
+	// chunks from less authoritative ones.
+	if len(fused) > 0 {
+		importance, err := e.store.DocumentImportance(ctx)
+		if err != nil {
+			slog.WarnContext(ctx, "retrieval: loading document importance failed", "error", err)
+		} else {
+			trace.ImportanceWeighted = applyImportance(fused, importance)
+		}
+	}
+
+	// Usage: chunks earlier answers kept citing edge ahead.
+	if e.cfg.UsageBoost > 0 && len(fused) > 0 {
+		trace.UsageBoosted = e.applyUsageBoost(ctx, fused)
+	}
+
+	// Curator boosts.
+	notes.boost(fused)
+
+	// Document selection: nudge results from documents whose summary or
+	// keywords match the query ahead of equally ranked chunks elsewhere.
+	if len(fused) > 0 {
+		docs, err := e.store.DocumentSummaries(ctx)
+		if err != nil {
+			slog.WarnContext(ctx, "retrieval: loading document summaries failed", "error", err)
+		} else if boosts := selectDocuments(append(extractSignificantTerms(query), translated...), docs); len(boosts) > 0 {
+			applyDocumentBoost(fused, boosts)
+			for id := range boosts {
+				trace.SelectedDocuments = append(trace.SelectedDocuments, id)
+			}
+			sort.Slice(trace.SelectedDocuments, func(i, j int) bool {
+				return trace.SelectedDocuments[i] < trace.SelectedDocuments[j]
+			})
+		}
+	}
+
+	// Cut the window only now, so the boosts above can lift a candidate
+	// fusion alone ranked just outside it.
+	fused = truncateFused(fused, infoMap, opts.MaxResults)
+	for _, r := range fused {
+		trace.DuplicatesCollapsed += len(r.Duplicates)
+	}
+
+	// Curator corrections and approved answers.
+	notes.attach(fused)
+
+	// Facts other documents state differently, so the answer can say so.
+	trace.Conflicted = e.flagConflicts(ctx, fused)
 
 	trace.FusedResults = len(fused)
 	trace.MaxRequested = opts.MaxResults
@@ -233,13 +830,126 @@ func (e *Engine) Search(ctx context.Context, query string, opts SearchOptions) (
 		if graphRes.err != nil {
 			return nil, trace, fmt.Errorf("graph search: %w", graphRes.err)
 		}
+		if sparseRes.err != nil {
+			return nil, trace, fmt.Errorf("sparse search: %w", sparseRes.err)
+		}
+		if imageRes.err != nil {
+			return nil, trace, fmt.Errorf("image search: %w", imageRes.err)
+		}
+		if secondaryRes.err != nil {
+			return nil, trace, fmt.Errorf("secondary vector search: %w", secondaryRes.err)
+		}
+		if numericRes.err != nil {
+			return nil, trace, fmt.Errorf("numeric search: %w", numericRes.err)
+		}
 	}
 
 	return fused, trace, nil
 }
 
-// vectorSearch generates an embedding for the query and searches vec_chunks.
-func (e *Engine) vectorSearch(ctx context.Context, query string, k int) ([]store.RetrievalResult, error) {
+// legResult is what a retrieval leg returns.
+type legResult struct {
+	results []store.RetrievalResult
+	err     error
+}
+
+// pendingLeg is a retrieval leg running in its own goroutine under its
+// own deadline.
+type pendingLeg struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	ch     chan legResult
+}
+
+// legContext bounds a leg by Config.LegTimeout.
+func (e *Engine) legContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if e.cfg.LegTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, e.cfg.LegTimeout)
+}
+
+// startLeg runs a leg concurrently.
+func (e *Engine) startLeg(ctx context.Context, run func(ctx context.Context) ([]store.RetrievalResult, error)) *pendingLeg {
+	l := &pendingLeg{ch: make(chan legResult, 1)}
+	l.ctx, l.cancel = e.legContext(ctx)
+	go func() {
+		r, err := run(l.ctx)
+		l.ch <- legResult{r, err}
+	}()
+	return l
+}
+
+// wait returns the leg's results, or stops waiting once the leg's deadline
+// passes even if the leg has not returned. timedOut reports that the leg's
+// own timeout, not the end of parent, cut it short.
+func (l *pendingLeg) wait(parent context.Context) (res legResult, timedOut bool) {
+	defer l.cancel()
+	// Legs are awaited in turn, so a later leg's deadline may have passed
+	// while it sat finished: take its results first.
+	select {
+	case res = <-l.ch:
+	default:
+		select {
+		case res = <-l.ch:
+		case <-l.ctx.Done():
+			res = legResult{err: l.ctx.Err()}
+		}
+	}
+	timedOut = res.err != nil && parent.Err() == nil && errors.Is(l.ctx.Err(), context.DeadlineExceeded)
+	return res, timedOut
+}
+
+// translate runs cross-language query translation under
+// Config.TranslationTimeout, reporting whether it timed out.
+func (e *Engine) translate(ctx context.Context, terms []string) ([]string, Usage, bool) {
+	if e.cfg.TranslationTimeout <= 0 {
+		translated, usage := e.translator.TranslateTerms(ctx, terms)
+		return translated, usage, false
+	}
+	tctx, cancel := context.WithTimeout(ctx, e.cfg.TranslationTimeout)
+	defer cancel()
+	type translation struct {
+		terms []string
+		usage Usage
+	}
+	ch := make(chan translation, 1)
+	go func() {
+		translated, usage := e.translator.TranslateTerms(tctx, terms)
+		ch <- translation{translated, usage}
+	}()
+	select {
+	case t := <-ch:
+		return t.terms, t.usage, ctx.Err() == nil && errors.Is(tctx.Err(), context.DeadlineExceeded)
+	case <-tctx.Done():
+		return nil, Usage{}, ctx.Err() == nil
+	}
+}
+
+// redistributeGraphWeight zeroes the graph weight and scales the remaining
+// active legs so the total weight is unchanged. Fused scores therefore stay
+// on the same scale as with a populated graph, which keeps score-based
+// thresholds downstream meaningful.
+func redistributeGraphWeight(opts SearchOptions) SearchOptions {
+	rest := opts.WeightVec + opts.WeightFTS + opts.WeightSparse + opts.WeightImage + opts.WeightSecondary
+	if rest > 0 {
+		scale := (rest + opts.WeightGraph) / rest
+		opts.WeightVec *= scale
+		opts.WeightFTS *= scale
+		opts.WeightSparse *= scale
+		opts.WeightImage *= scale
+		opts.WeightSecondary *= scale
+	}
+	opts.WeightGraph = 0
+	return opts
+}
+
+// queryEmbedding embeds the query with the primary model, or reuses a
+// cached embedding.
+func (e *Engine) queryEmbedding(ctx context.Context, query string) ([]float32, error) {
+	if cached, ok := e.queryCache.get(query); ok {
+		return cached, nil
+	}
 	embeddings, err := e.embedder.Embed(ctx, []string{query})
 	if err != nil {
 		return nil, err
@@ -247,19 +957,81 @@ func (e *Engine) vectorSearch(ctx context.Context, query string, k int) ([]store
 	if len(embeddings) == 0 || len(embeddings[0]) == 0 {
 		return nil, fmt.Errorf("empty embedding returned")
 	}
-	return e.store.VectorSearch(ctx, embeddings[0], k)
+	e.queryCache.put(query, embeddings[0])
+	return embeddings[0], nil
+}
+
+// vectorSeedEntities returns the entities whose description embeddings are
+// at least Config.EntitySeedSimilarity similar to the query embedding.
+func (e *Engine) vectorSeedEntities(ctx context.Context, embedding []float32) ([]store.Entity, error) {
+	matches, err := e.store.EntityVectorSearch(ctx, embedding, entitySeedK)
+	if err != nil {
+		return nil, err
+	}
+	var seeds []store.Entity
+	for _, m := range matches {
+		if m.Similarity < e.cfg.EntitySeedSimilarity {
+			break
+		}
+		seeds = append(seeds, m.Entity)
+	}
+	return seeds, nil
+}
+
+// secondarySearch embeds the query with the secondary model (or reuses a
+// cached embedding) and searches vec_chunks_secondary.
+func (e *Engine) secondarySearch(ctx context.Context, query string, k int) ([]store.RetrievalResult, error) {
+	if cached, ok := e.secondaryCache.get(query); ok {
+		return e.store.SecondaryVectorSearch(ctx, cached, k)
+	}
+	embeddings, err := e.secondary.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	if len(embeddings) == 0 || len(embeddings[0]) == 0 {
+		return nil, fmt.Errorf("empty secondary embedding returned")
+	}
+	e.secondaryCache.put(query, embeddings[0])
+	return e.store.SecondaryVectorSearch(ctx, embeddings[0], k)
+}
+
+// sparseSearch generates a learned sparse embedding for the query and
+// scores chunks by term-weight dot product against sparse_chunks.
+func (e *Engine) sparseSearch(ctx context.Context, query string, k int) ([]store.RetrievalResult, error) {
+	vectors, err := e.sparse.EmbedSparse(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) == 0 || len(vectors[0].Indices) == 0 {
+		return nil, nil
+	}
+	return e.store.SparseSearch(ctx, vectors[0].Indices, vectors[0].Values, k)
+}
+
+// imageSearch embeds the query into the multimodal space and returns the
+// chunks whose images are nearest to it.
+func (e *Engine) imageSearch(ctx context.Context, query string, k int) ([]store.RetrievalResult, error) {
+	embeddings, err := e.image.EmbedTexts(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	if len(embeddings) == 0 || len(embeddings[0]) == 0 {
+		return nil, fmt.Errorf("empty image query embedding returned")
+	}
+	return e.store.ImageVectorSearch(ctx, embeddings[0], k)
 }
 
 // ftsSearch performs FTS5 full-text search.
 func (e *Engine) ftsSearch(ctx context.Context, query string, translated []string, limit int) ([]store.RetrievalResult, error) {
 	ftsQuery := sanitizeFTSQuery(query, translated)
-	return e.store.FTSSearch(ctx, ftsQuery, limit)
+	return e.store.FTSSearchWeighted(ctx, ftsQuery, limit, e.cfg.FTSHeadingWeight)
 }
 
 // graphSearch extracts entities from the query and traverses the graph.
 func (e *Engine) graphSearch(ctx context.Context, query string, translated []string, limit int) ([]store.RetrievalResult, error) {
 	entities := extractQueryEntities(query, translated)
-	return e.graphSearchWithEntities(ctx, entities, limit, false)
+	results, _, err := e.graphSearchWithEntities(ctx, entities, nil, limit, false)
+	return results, err
 }
 
 // graphSearchWithEntities traverses the graph using pre-extracted entity names.
@@ -272,9 +1044,14 @@ func (e *Engine) graphSearch(ctx context.Context, query string, translated []str
 // When synthesisMode is true, performs an additional 1-hop relationship
 // expansion to discover entities connected to the initial matches but not
 // directly matched by name. This helps synthesis queries find scattered facts.
-func (e *Engine) graphSearchWithEntities(ctx context.Context, entities []string, limit int, synthesisMode bool) ([]store.RetrievalResult, error) {
-	if len(entities) == 0 {
-		return nil, nil
+//
+// seeds are entities already selected by description similarity to the
+// query; they join the name matches as starting points. The returned links
+// record every entity matched, and the related entities reached, for
+// SearchTrace.LinkedEntities.
+func (e *Engine) graphSearchWithEntities(ctx context.Context, entities []string, seeds []store.Entity, limit int, synthesisMode bool) ([]store.RetrievalResult, []EntityLink, error) {
+	if len(entities) == 0 && len(seeds) == 0 {
+		return nil, nil, nil
 	}
 
 	// Normalize to lowercase to match storage format (graph builder lowercases all entity names)
@@ -285,62 +1062,92 @@ func (e *Engine) graphSearchWithEntities(ctx context.Context, entities []string,
 	// Try exact match first
 	found, err := e.store.GetEntitiesByNames(ctx, entities)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Also do substring match to find multi-word entities containing query terms
 	fuzzyFound, err := e.store.SearchEntitiesByTerms(ctx, entities, 50)
 	if err != nil {
-		slog.Warn("retrieval: fuzzy entity search failed", "error", err)
+		slog.WarnContext(ctx, "retrieval: fuzzy entity search failed", "error", err)
 	}
 
 	// Also search by English canonical name for cross-language entity matching
 	enFound, err := e.store.SearchEntitiesByNameEN(ctx, entities, 50)
 	if err != nil {
-		slog.Warn("retrieval: name_en entity search failed", "error", err)
+		slog.WarnContext(ctx, "retrieval: name_en entity search failed", "error", err)
 	}
 
 	// Merge results (deduplicate by ID)
 	seen := make(map[int64]bool)
 	var allEntities []store.Entity
-	for _, e := range found {
-		if !seen[e.ID] {
-			seen[e.ID] = true
-			allEntities = append(allEntities, e)
+	var links []EntityLink
+	add := func(ents []store.Entity, match string) {
+		for _, ent := range ents {
+			if !seen[ent.ID] {
+				seen[ent.ID] = true
+				allEntities = append(allEntities, ent)
+				links = append(links, newEntityLink(ent, match, entities))
+			}
 		}
 	}
-	for _, e := range fuzzyFound {
-		if !seen[e.ID] {
-			seen[e.ID] = true
-			allEntities = append(allEntities, e)
+	add(found, LinkName)
+	add(fuzzyFound, LinkNameSubstr)
+	add(enFound, LinkNameEN)
+	add(seeds, LinkEmbedding)
+
+	// Stop entities ("system", "document") match most questions and link
+	// to much of the corpus, so they would flood the leg.
+	var stopped int
+	if stop, err := e.store.StopEntityIDs(ctx); err != nil {
+		slog.WarnContext(ctx, "retrieval: loading stop entities failed", "error", err)
+	} else if len(stop) > 0 {
+		kept := allEntities[:0]
+		for _, ent := range allEntities {
+			if !stop[ent.ID] {
+				kept = append(kept, ent)
+			}
 		}
-	}
-	for _, e := range enFound {
-		if !seen[e.ID] {
-			seen[e.ID] = true
-			allEntities = append(allEntities, e)
+		stopped = len(allEntities) - len(kept)
+		allEntities = kept
+		for i := range links {
+			links[i].Stopped = stop[links[i].ID]
 		}
 	}
 
 	if len(allEntities) == 0 {
-		return nil, nil
+		return nil, links, nil
 	}
 
-	slog.Debug("retrieval: graph entity lookup",
+	slog.DebugContext(ctx, "retrieval: graph entity lookup",
 		"exact_matches", len(found), "fuzzy_matches", len(fuzzyFound),
-		"name_en_matches", len(enFound), "total_unique", len(allEntities))
+		"name_en_matches", len(enFound), "vector_seeds", len(seeds),
+		"stop_entities", stopped, "total_unique", len(allEntities))
 
 	entityIDs := make([]int64, len(allEntities))
 	for i, e := range allEntities {
 		entityIDs[i] = e.ID
 	}
 
+	// Multi-hop search: score chunks by the best relationship path from
+	// the matched entities. Subsumes the synthesis-mode 1-hop expansion.
+	if e.cfg.GraphMaxDepth > 1 {
+		paths, err := e.store.ExpandEntityPaths(ctx, allEntities, e.cfg.GraphMaxDepth, graphMaxPathEntities)
+		if err != nil {
+			return nil, links, err
+		}
+		slog.DebugContext(ctx, "retrieval: multi-hop expansion",
+			"seeds", len(allEntities), "reached", len(paths), "max_depth", e.cfg.GraphMaxDepth)
+		links = append(links, relatedPathLinks(paths)...)
+		results, err := e.store.MultiHopGraphSearch(ctx, paths, limit)
+		return results, links, err
+	}
+
 	// 1-hop relationship expansion for synthesis queries: discover entities
 	// connected to the seed set (e.g., "seguridad y normativa" → "ip54").
 	if synthesisMode {
 		neighborEntities, err := e.store.GetRelatedEntities(ctx, entityIDs, 100)
 		if err != nil {
-			slog.Warn("retrieval: 1-hop entity expansion failed", "error", err)
+			slog.WarnContext(ctx, "retrieval: 1-hop entity expansion failed", "error", err)
 		} else if len(neighborEntities) > 0 {
 			added := 0
 			for _, ne := range neighborEntities {
@@ -348,13 +1155,63 @@ func (e *Engine) graphSearchWithEntities(ctx context.Context, entities []string,
 					seen[ne.ID] = true
 					allEntities = append(allEntities, ne)
 					entityIDs = append(entityIDs, ne.ID)
+					if added < maxRelatedLinks {
+						links = append(links, EntityLink{ID: ne.ID, Name: ne.Name, NameEN: ne.NameEN, Type: ne.EntityType, Match: LinkRelated})
+					}
 					added++
 				}
 			}
-			slog.Debug("retrieval: 1-hop expansion",
+			slog.DebugContext(ctx, "retrieval: 1-hop expansion",
 				"returned", len(neighborEntities), "new", added, "total_unique", len(allEntities))
 		}
 	}
 
-	return e.store.GraphSearch(ctx, entityIDs, limit)
+	results, err := e.store.GraphSearch(ctx, entityIDs, limit)
+	return results, links, err
+}
+
+// newEntityLink describes ent, linked to the question by match. Term is
+// the first of the lowercased query terms its name (or English name, for
+// LinkNameEN) equals or contains.
+func newEntityLink(ent store.Entity, match string, terms []string) EntityLink {
+	link := EntityLink{ID: ent.ID, Name: ent.Name, NameEN: ent.NameEN, Type: ent.EntityType, Match: match}
+	name := strings.ToLower(ent.Name)
+	switch match {
+	case LinkEmbedding:
+		return link
+	case LinkNameEN:
+		name = strings.ToLower(ent.NameEN)
+	}
+	for _, t := range terms {
+		if name == t || (match != LinkName && strings.Contains(name, t)) {
+			link.Term = t
+			break
+		}
+	}
+	return link
+}
+
+// relatedPathLinks returns the best scoring entities a multi-hop
+// expansion reached beyond its seeds, at most maxRelatedLinks.
+func relatedPathLinks(paths map[int64]store.EntityPath) []EntityLink {
+	var reached []store.EntityPath
+	for _, p := range paths {
+		if p.Hops > 0 {
+			reached = append(reached, p)
+		}
+	}
+	sort.Slice(reached, func(i, j int) bool {
+		if reached[i].Score != reached[j].Score {
+			return reached[i].Score > reached[j].Score
+		}
+		return reached[i].EntityID < reached[j].EntityID
+	})
+	if len(reached) > maxRelatedLinks {
+		reached = reached[:maxRelatedLinks]
+	}
+	links := make([]EntityLink, len(reached))
+	for i, p := range reached {
+		links[i] = EntityLink{ID: p.EntityID, Name: p.Name, Match: LinkRelated, Path: p.Path}
+	}
+	return links
 }
@@ -1,8 +1,15 @@
 package retrieval
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
 	"testing"
 
+	"github.com/bbiangul/go-reason/llm"
 	"github.com/bbiangul/go-reason/store"
 )
 
@@ -114,6 +121,356 @@ func TestFuseRRFWeightZero(t *testing.T) {
 	}
 }
 
+func TestFuseLegsSparse(t *testing.T) {
+	vec := []store.RetrievalResult{{ChunkID: 1}, {ChunkID: 2}}
+	sparse := []store.RetrievalResult{{ChunkID: 3}, {ChunkID: 2}}
+
+	results, infoMap := fuseLegs([]rrfLeg{
+		{method: "vector", results: vec, weight: 1.0},
+		{method: "sparse", results: sparse, weight: 1.0},
+	}, 10)
+
+	if len(results) != 3 {
+		t.Fatalf("expected 3 fused results, got %d", len(results))
+	}
+	// Chunk 2 appears in both legs and must rank first.
+	if results[0].ChunkID != 2 {
+		t.Errorf("expected chunk 2 first, got %d", results[0].ChunkID)
+	}
+	if info := infoMap[3]; info.SparseRank != 1 || info.VecRank != 0 {
+		t.Errorf("chunk 3: got %+v, want sparse_rank=1 vec_rank=0", info)
+	}
+	if info := infoMap[2]; info.SparseRank != 2 || info.VecRank != 2 {
+		t.Errorf("chunk 2: got %+v, want sparse_rank=2 vec_rank=2", info)
+	}
+}
+
+func TestFuseLegsImage(t *testing.T) {
+	fts := []store.RetrievalResult{{ChunkID: 1}}
+	image := []store.RetrievalResult{{ChunkID: 4}}
+
+	results, infoMap := fuseLegs([]rrfLeg{
+		{method: "fts", results: fts, weight: 1.0},
+		{method: "image", results: image, weight: 1.5},
+	}, 10)
+
+	if len(results) != 2 || results[0].ChunkID != 4 {
+		t.Fatalf("expected image hit to rank first with higher weight, got %+v", results)
+	}
+	if info := infoMap[4]; info.ImageRank != 1 || len(info.Methods) != 1 || info.Methods[0] != "image" {
+		t.Errorf("chunk 4: got %+v, want image_rank=1 methods=[image]", info)
+	}
+}
+
+func TestFuseLegsSecondary(t *testing.T) {
+	vec := []store.RetrievalResult{{ChunkID: 1}, {ChunkID: 2}}
+	secondary := []store.RetrievalResult{{ChunkID: 2}, {ChunkID: 3}}
+
+	results, infoMap := fuseLegs([]rrfLeg{
+		{method: "vector", results: vec, weight: 1.0},
+		{method: "vector_secondary", results: secondary, weight: 1.0},
+	}, 10)
+
+	// Chunk 2 is found by both models and outranks the single-space hits.
+	if len(results) != 3 || results[0].ChunkID != 2 {
+		t.Fatalf("expected chunk 2 first of 3 results, got %+v", results)
+	}
+	if info := infoMap[2]; info.VecRank != 2 || info.SecondaryRank != 1 {
+		t.Errorf("chunk 2: got %+v, want vec_rank=2 secondary_rank=1", info)
+	}
+}
+
+func TestFuseDistinct(t *testing.T) {
+	vec := []store.RetrievalResult{
+		{ChunkID: 1, DocumentID: 10, Filename: "a.pdf"},
+		{ChunkID: 2, DocumentID: 20, Filename: "b.pdf", PageNumber: 3},
+		{ChunkID: 3, DocumentID: 30, Filename: "c.pdf"},
+		{ChunkID: 4, DocumentID: 40, Filename: "d.pdf"},
+	}
+	fts := []store.RetrievalResult{{ChunkID: 5, DocumentID: 50, Filename: "e.pdf"}}
+	hashes := map[int64]string{1: "boilerplate", 2: "boilerplate", 3: "boilerplate", 4: "distinct", 5: ""}
+
+	results, infoMap := fuseDistinct([]rrfLeg{
+		{method: "vector", results: vec, weight: 1.0},
+		{method: "fts", results: fts, weight: 1.0},
+	}, 3, hashes)
+
+	// The copies collapse into chunk 1, leaving room for chunks 4 and 5.
+	if len(results) != 3 || results[0].ChunkID != 1 {
+		t.Fatalf("expected chunk 1 first of 3 results, got %+v", results)
+	}
+	if got := []int64{results[1].ChunkID, results[2].ChunkID}; got[0] != 5 || got[1] != 4 {
+		t.Errorf("remaining results = %v, want [5 4]", got)
+	}
+	dups := results[0].Duplicates
+	if len(dups) != 2 || dups[0].ChunkID != 2 || dups[0].Filename != "b.pdf" || dups[0].PageNumber != 3 || dups[1].DocumentID != 30 {
+		t.Errorf("duplicates = %+v, want chunks 2 (b.pdf p.3) and 3", dups)
+	}
+	if _, ok := infoMap[2]; ok {
+		t.Error("collapsed chunk 2 has fusion info")
+	}
+
+	// Without hashes every copy is its own result.
+	results, _ = fuseDistinct([]rrfLeg{{method: "vector", results: vec, weight: 1.0}}, 10, nil)
+	if len(results) != 4 || len(results[0].Duplicates) != 0 {
+		t.Errorf("expected 4 uncollapsed results, got %+v", results)
+	}
+}
+
+func TestRedistributeGraphWeight(t *testing.T) {
+	opts := redistributeGraphWeight(SearchOptions{WeightVec: 1.0, WeightFTS: 1.0, WeightGraph: 0.5})
+	if opts.WeightGraph != 0 {
+		t.Errorf("graph weight = %f, want 0", opts.WeightGraph)
+	}
+	if opts.WeightVec != 1.25 || opts.WeightFTS != 1.25 {
+		t.Errorf("weights = vec %f fts %f, want 1.25 each", opts.WeightVec, opts.WeightFTS)
+	}
+	if opts.WeightSparse != 0 || opts.WeightImage != 0 || opts.WeightSecondary != 0 {
+		t.Error("inactive legs must stay disabled")
+	}
+
+	// Graph-only configuration: nothing to redistribute to.
+	opts = redistributeGraphWeight(SearchOptions{WeightGraph: 1.0})
+	if opts.WeightGraph != 0 || opts.WeightVec != 0 {
+		t.Errorf("unexpected weights %+v", opts)
+	}
+}
+
+func TestSelectDocuments(t *testing.T) {
+	docs := []store.DocumentSummary{
+		{DocumentID: 1, Summary: "Installation manual for fire dampers.", Keywords: `["av-fm","damper"]`},
+		{DocumentID: 2, Summary: "Employment contract terms.", Keywords: `["salary","termination"]`},
+	}
+
+	boosts := selectDocuments([]string{"damper", "installation"}, docs)
+	if len(boosts) != 1 {
+		t.Fatalf("expected 1 selected document, got %v", boosts)
+	}
+	if b := boosts[1]; b <= 1 || b > 1+maxDocumentBoost {
+		t.Errorf("boost for doc 1 = %f, want in (1, %f]", b, 1+maxDocumentBoost)
+	}
+
+	// No term matches anything: no selection.
+	if got := selectDocuments([]string{"voltage"}, docs); len(got) != 0 {
+		t.Errorf("expected no selection, got %v", got)
+	}
+}
+
+func TestApplyDocumentBoost(t *testing.T) {
+	results := []store.RetrievalResult{
+		{ChunkID: 1, DocumentID: 10, Score: 0.030},
+		{ChunkID: 2, DocumentID: 20, Score: 0.029},
+	}
+	applyDocumentBoost(results, map[int64]float64{20: 1.1})
+	if results[0].ChunkID != 2 {
+		t.Errorf("expected boosted chunk 2 first, got %d", results[0].ChunkID)
+	}
+	if len(results) != 2 {
+		t.Errorf("boost must not drop results, got %d", len(results))
+	}
+}
+
+func TestApplyImportance(t *testing.T) {
+	results := []store.RetrievalResult{
+		{ChunkID: 1, DocumentID: 10, Score: 1.0}, // meeting notes
+		{ChunkID: 2, DocumentID: 20, Score: 0.8}, // official manual
+		{ChunkID: 3, DocumentID: 30, Score: 0.7},
+	}
+	n := applyImportance(results, map[int64]float64{10: 0.5, 20: 2})
+	if n != 2 || results[0].ChunkID != 2 || results[0].Score != 1.6 {
+		t.Errorf("expected the manual first, got %+v (%d weighted)", results, n)
+	}
+	if results[1].ChunkID != 3 || results[2].ChunkID != 1 || results[2].Score != 0.5 {
+		t.Errorf("expected the notes last, got %+v", results)
+	}
+}
+
+func TestApplyTypeBoost(t *testing.T) {
+	results := []store.RetrievalResult{
+		{ChunkID: 1, ChunkType: "paragraph", Score: 1.0},
+		{ChunkID: 2, ChunkType: "requirement", Score: 0.9},
+		{ChunkID: 3, ChunkType: "table", Score: 0.8},
+	}
+	applyTypeBoost(results, map[string]float64{"requirement": 1.5, "table": 1})
+	if results[0].ChunkID != 2 || results[0].Score != 1.35 {
+		t.Errorf("expected boosted requirement first, got %+v", results[0])
+	}
+	if results[2].ChunkID != 3 || results[2].Score != 0.8 {
+		t.Errorf("boost 1 must be a no-op, got %+v", results[2])
+	}
+}
+
+func TestApplyScoreAdjusters(t *testing.T) {
+	results := []store.RetrievalResult{
+		{ChunkID: 1, Score: 1.0, ChunkMeta: `{"model":"XR-100"}`},
+		{ChunkID: 2, Score: 0.9, ChunkMeta: `{"model":"XR-200"}`},
+		{ChunkID: 3, Score: 0.8},
+	}
+	info := map[int64]FusedResultInfo{1: {}, 2: {}, 3: {}}
+	var queries []string
+	model := ScoreAdjusterFunc(func(_ context.Context, query string, c store.RetrievalResult) float64 {
+		queries = append(queries, query)
+		if strings.Contains(c.ChunkMeta, "XR-200") {
+			return 2
+		}
+		return 1
+	})
+	ignored := ScoreAdjusterFunc(func(context.Context, string, store.RetrievalResult) float64 { return 0 })
+
+	n := applyScoreAdjusters(context.Background(), []ScoreAdjuster{model, ignored}, "XR-200 torque", results, info)
+	if n != 1 || results[0].ChunkID != 2 || results[0].Score != 1.8 || results[2].Score != 0.8 {
+		t.Errorf("adjusted %d, results = %+v", n, results)
+	}
+	if info[2].ScoreAdjustment != 2 || info[1].ScoreAdjustment != 0 {
+		t.Errorf("info = %+v", info)
+	}
+	if len(queries) != 3 || queries[0] != "XR-200 torque" {
+		t.Errorf("adjuster saw queries %q", queries)
+	}
+	if n := applyScoreAdjusters(context.Background(), nil, "q", results, info); n != 0 {
+		t.Errorf("no adjusters adjusted %d", n)
+	}
+}
+
+func TestScoreAdjustersBeforeTruncation(t *testing.T) {
+	vec := []store.RetrievalResult{
+		{ChunkID: 1}, {ChunkID: 2}, {ChunkID: 3, ChunkMeta: `{"model":"XR-200"}`},
+	}
+	fused, info := fuseDistinct([]rrfLeg{{method: "vector", results: vec, weight: 1.0}}, 0, nil)
+	model := ScoreAdjusterFunc(func(_ context.Context, _ string, c store.RetrievalResult) float64 {
+		if c.ChunkMeta != "" {
+			return 2
+		}
+		return 1
+	})
+	applyScoreAdjusters(context.Background(), []ScoreAdjuster{model}, "XR-200", fused, info)
+	fused = truncateFused(fused, info, 2)
+
+	// Fusion alone ranks chunk 3 outside a window of 2; the boost lifts it in.
+	if len(fused) != 2 || fused[0].ChunkID != 3 || fused[1].ChunkID != 1 {
+		t.Errorf("results = %+v, want chunks [3 1]", fused)
+	}
+	if _, ok := info[2]; ok || len(info) != 2 {
+		t.Errorf("info = %+v, want chunks 1 and 3 only", info)
+	}
+}
+
+func TestImportanceBeforeTruncation(t *testing.T) {
+	vec := []store.RetrievalResult{
+		{ChunkID: 1, DocumentID: 10}, {ChunkID: 2, DocumentID: 10}, {ChunkID: 3, DocumentID: 20},
+	}
+	fused, info := fuseDistinct([]rrfLeg{{method: "vector", results: vec, weight: 1.0}}, 0, nil)
+	if n := applyImportance(fused, map[int64]float64{20: 2}); n != 1 {
+		t.Errorf("applyImportance = %d, want 1", n)
+	}
+	fused = truncateFused(fused, info, 2)
+
+	// Fusion alone ranks chunk 3 outside a window of 2; its document's
+	// importance lifts it in.
+	if len(fused) != 2 || fused[0].ChunkID != 3 || fused[1].ChunkID != 1 {
+		t.Errorf("results = %+v, want chunks [3 1]", fused)
+	}
+}
+
+func TestDocumentBoostBeforeTruncation(t *testing.T) {
+	vec := []store.RetrievalResult{
+		{ChunkID: 1, DocumentID: 10}, {ChunkID: 2, DocumentID: 10}, {ChunkID: 3, DocumentID: 20},
+	}
+	fused, info := fuseDistinct([]rrfLeg{{method: "vector", results: vec, weight: 1.0}}, 0, nil)
+	applyDocumentBoost(fused, map[int64]float64{20: 2})
+	fused = truncateFused(fused, info, 2)
+
+	// Fusion alone ranks chunk 3 outside a window of 2; its selected
+	// document lifts it in.
+	if len(fused) != 2 || fused[0].ChunkID != 3 || fused[1].ChunkID != 1 {
+		t.Errorf("results = %+v, want chunks [3 1]", fused)
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	notes := annotations{
+		2: {{ChunkID: 2, Kind: store.AnnotationExclude}},
+		3: {{ChunkID: 3, Kind: store.AnnotationBoost, Boost: 2}, {ChunkID: 3, Kind: store.AnnotationCorrection, Text: "45 Nm"}},
+	}
+	legs := []rrfLeg{
+		{method: "vector", results: []store.RetrievalResult{{ChunkID: 1}, {ChunkID: 2}, {ChunkID: 3}}, weight: 1},
+		{method: "fts", results: []store.RetrievalResult{{ChunkID: 2}}, weight: 1},
+	}
+	if n := notes.dropExcluded(legs); n != 1 {
+		t.Errorf("dropExcluded = %d, want 1", n)
+	}
+	if len(legs[0].results) != 2 || len(legs[1].results) != 0 {
+		t.Fatalf("legs after exclusion = %+v", legs)
+	}
+
+	results := []store.RetrievalResult{{ChunkID: 1, Score: 1.0}, {ChunkID: 3, Score: 0.8}}
+	notes.boost(results)
+	notes.attach(results)
+	if results[0].ChunkID != 3 || results[0].Score != 1.6 || results[0].Correction != "45 Nm" {
+		t.Errorf("expected boosted, corrected chunk 3 first, got %+v", results[0])
+	}
+}
+
+func TestAnnotationBoostBeforeTruncation(t *testing.T) {
+	vec := []store.RetrievalResult{{ChunkID: 1}, {ChunkID: 2}, {ChunkID: 3}}
+	fused, info := fuseDistinct([]rrfLeg{{method: "vector", results: vec, weight: 1.0}}, 0, nil)
+	notes := annotations{3: {{ChunkID: 3, Kind: store.AnnotationBoost, Boost: 2}}}
+	notes.boost(fused)
+	fused = truncateFused(fused, info, 2)
+
+	// Fusion alone ranks chunk 3 outside a window of 2; the curator's
+	// boost lifts it in.
+	if len(fused) != 2 || fused[0].ChunkID != 3 || fused[1].ChunkID != 1 {
+		t.Errorf("results = %+v, want chunks [3 1]", fused)
+	}
+}
+
+func TestFilterDocuments(t *testing.T) {
+	results := []store.RetrievalResult{
+		{ChunkID: 1, DocumentID: 10},
+		{ChunkID: 2, DocumentID: 20},
+		{ChunkID: 3, DocumentID: 10},
+	}
+	got := filterDocuments(results, map[int64]bool{10: true})
+	if len(got) != 2 || got[0].ChunkID != 1 || got[1].ChunkID != 3 {
+		t.Errorf("filterDocuments = %+v, want chunks 1 and 3 in order", got)
+	}
+}
+
+func TestScopedSearch(t *testing.T) {
+	// A corpus of 300 chunks ranked by ID, whose last 50 are in scope.
+	var windows []int
+	search := func(_ context.Context, k int) ([]store.RetrievalResult, error) {
+		windows = append(windows, k)
+		var results []store.RetrievalResult
+		for id := int64(1); id <= 300 && len(results) < k; id++ {
+			doc := int64(10)
+			if id > 250 {
+				doc = 20
+			}
+			results = append(results, store.RetrievalResult{ChunkID: id, DocumentID: doc})
+		}
+		return results, nil
+	}
+	keep := func(r []store.RetrievalResult) []store.RetrievalResult {
+		return filterDocuments(r, map[int64]bool{20: true})
+	}
+	ctx := context.Background()
+
+	// Widened while full windows leave too few, up to scopedMaxWindow.
+	got, err := scopedSearch(ctx, 10, 5, keep, search)
+	if err != nil || len(got) != 50 || got[0].ChunkID != 251 {
+		t.Errorf("scopedSearch = %d results, %v; want chunks 251-300", len(got), err)
+	}
+	if want := []int{10, 50, 250, scopedMaxWindow}; fmt.Sprint(windows) != fmt.Sprint(want) {
+		t.Errorf("windows = %v, want %v", windows, want)
+	}
+	windows = nil
+	if got, _ := scopedSearch(ctx, 10, 5, nil, search); len(got) != 10 || len(windows) != 1 {
+		t.Errorf("unscoped search = %d results over windows %v", len(got), windows)
+	}
+}
+
 func TestSanitizeFTSQuery(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -300,3 +657,245 @@ func searchStr(s, sub string) bool {
 func containsStr(haystack, needle string) bool {
 	return len(haystack) >= len(needle) && searchStr(haystack, needle)
 }
+
+func TestEmbeddingCache(t *testing.T) {
+	c := newEmbeddingCache(2, "m")
+	c.put("What is the torque?", []float32{1})
+	if _, ok := c.get("what is the   TORQUE"); !ok {
+		t.Error("expected normalized query to hit")
+	}
+	c.put("second", []float32{2})
+	c.get("what is the torque") // refresh; "second" is now least recent
+	c.put("third", []float32{3})
+	if _, ok := c.get("second"); ok {
+		t.Error("expected least recently used entry to be evicted")
+	}
+	if got, ok := c.get("third"); !ok || got[0] != 3 {
+		t.Errorf("third = %v, %v", got, ok)
+	}
+	if s := c.stats(); s.Hits != 3 || s.Misses != 1 || s.Size != 2 || s.Capacity != 2 {
+		t.Errorf("stats = %+v", s)
+	}
+
+	disabled := newEmbeddingCache(0, "m")
+	disabled.put("q", []float32{1})
+	if _, ok := disabled.get("q"); ok {
+		t.Error("disabled cache must not hit")
+	}
+	disabled.reset()
+
+	// Another model's cache does not share entries; reset drops them all.
+	other := newEmbeddingCache(2, "other")
+	other.put("third", []float32{4})
+	if got, ok := c.get("third"); !ok || got[0] != 3 {
+		t.Errorf("third after another model's put = %v, %v", got, ok)
+	}
+	if c.key("third") == other.key("third") {
+		t.Error("caches of two models share a key")
+	}
+	c.reset()
+	if _, ok := c.get("third"); ok {
+		t.Error("expected no entries after reset")
+	}
+	if s := c.stats(); s.Size != 0 || s.Hits != 4 {
+		t.Errorf("stats after reset = %+v", s)
+	}
+}
+
+// translationChat answers translation prompts with a fixed reply, or fails
+// when err is set.
+type translationChat struct {
+	calls int
+	err   error
+}
+
+func (c *translationChat) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	c.calls++
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &llm.ChatResponse{
+		Content:          `{"pump": {"Spanish": ["bomba"], "Portuguese": ["bomba"]}, "valve": {"Spanish": ["válvula"], "Portuguese": ["válvula"]}}`,
+		PromptTokens:     100,
+		CompletionTokens: 40,
+	}, nil
+}
+
+func (c *translationChat) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return nil, errors.New("not implemented")
+}
+
+// memTranslations is an in-memory TranslatorStore; the embedded interface
+// panics on anything the translator does not read.
+type memTranslations struct {
+	store.DocumentStore
+	rows map[string]store.QueryTranslation
+}
+
+func (m *memTranslations) GetCorpusLanguages(ctx context.Context) ([]string, error) {
+	return []string{"English", "Spanish", "Portuguese"}, nil
+}
+
+func (m *memTranslations) PutQueryTranslation(ctx context.Context, t store.QueryTranslation) error {
+	m.rows[t.QuestionHash+"/"+t.Language] = t
+	return nil
+}
+
+func (m *memTranslations) GetQueryTranslations(ctx context.Context, hash string, langs []string) (map[string]store.QueryTranslation, error) {
+	out := make(map[string]store.QueryTranslation)
+	for _, l := range langs {
+		if t, ok := m.rows[hash+"/"+l]; ok {
+			out[l] = t
+		}
+	}
+	return out, nil
+}
+
+func TestTranslationCache(t *testing.T) {
+	ctx := context.Background()
+	st := &memTranslations{rows: make(map[string]store.QueryTranslation)}
+	chat := &translationChat{}
+	tr := NewTranslator(chat, st)
+
+	terms, usage := tr.TranslateTerms(ctx, []string{"pump", "valve"})
+	sort.Strings(terms)
+	if len(terms) != 4 || terms[0] != "bomba" || usage.PromptTokens != 100 {
+		t.Fatalf("first translation = %v, %+v", terms, usage)
+	}
+	if len(st.rows) != 2 {
+		t.Errorf("persisted %d translations, want one per language", len(st.rows))
+	}
+
+	// Case and word order do not change the question key.
+	terms, usage = tr.TranslateTerms(ctx, []string{"Valve", "pump"})
+	if len(terms) != 4 || usage != (Usage{}) || chat.calls != 1 {
+		t.Errorf("cached translation = %v, %+v after %d calls", terms, usage, chat.calls)
+	}
+	s := tr.CacheStats()
+	if s.Hits != 1 || s.Misses != 1 || s.HitRate != 0.5 || s.SavedPromptTokens != 100 || s.SavedCompletionTokens != 40 {
+		t.Errorf("stats = %+v", s)
+	}
+
+	// A new translator (a restarted engine) reads the question from the store.
+	restarted := NewTranslator(chat, st)
+	if terms, _ := restarted.TranslateTerms(ctx, []string{"pump", "valve"}); len(terms) != 4 || chat.calls != 1 {
+		t.Errorf("after restart: %v after %d calls", terms, chat.calls)
+	}
+	if s := restarted.CacheStats(); s.Hits != 1 || s.DiskHits != 1 {
+		t.Errorf("restarted stats = %+v", s)
+	}
+
+	// Failed translations are not persisted.
+	failing := NewTranslator(&translationChat{err: errors.New("down")}, st)
+	failing.TranslateTerms(ctx, []string{"seal"})
+	if len(st.rows) != 2 {
+		t.Errorf("failed translation was persisted: %d rows", len(st.rows))
+	}
+}
+
+func TestTranslationCacheEvicts(t *testing.T) {
+	limit := maxCachedQuestions
+	maxCachedQuestions = 1
+	t.Cleanup(func() { maxCachedQuestions = limit })
+	ctx := context.Background()
+	st := &memTranslations{rows: make(map[string]store.QueryTranslation)}
+	chat := &translationChat{}
+	tr := NewTranslator(chat, st)
+
+	tr.TranslateTerms(ctx, []string{"pump", "valve"})
+	tr.TranslateTerms(ctx, []string{"pump"})
+	if len(tr.questions) != 1 || tr.order.Len() != 1 {
+		t.Fatalf("%d questions in memory, want 1", len(tr.questions))
+	}
+
+	// The evicted question is read back from the store, not translated again.
+	if terms, _ := tr.TranslateTerms(ctx, []string{"pump", "valve"}); len(terms) != 4 || chat.calls != 1 {
+		t.Errorf("evicted question: %v after %d calls", terms, chat.calls)
+	}
+	if s := tr.CacheStats(); s.DiskHits != 1 {
+		t.Errorf("stats = %+v, want the evicted question read from disk", s)
+	}
+}
+
+func TestBoostByUsage(t *testing.T) {
+	results := []store.RetrievalResult{
+		{ChunkID: 1, Score: 0.030},
+		{ChunkID: 2, Score: 0.029},
+		{ChunkID: 3, Score: 0.028},
+	}
+	usage := map[int64]store.ChunkUsage{
+		2: {ChunkID: 2, CitedScore: 3},     // half the boost: 0.029 * 1.25
+		3: {ChunkID: 3, RetrievedScore: 1}, // retrieved only: a small nudge
+	}
+	if n := boostByUsage(results, usage, 0.5); n != 2 {
+		t.Errorf("boosted %d results, want 2", n)
+	}
+	if results[0].ChunkID != 2 || math.Abs(results[0].Score-0.03625) > 1e-9 {
+		t.Errorf("top = %+v, want the cited chunk 2 with score 0.03625", results[0])
+	}
+	if results[1].ChunkID != 1 || results[2].ChunkID != 3 || results[2].Score <= 0.028 {
+		t.Errorf("order = %+v", results)
+	}
+}
+
+func TestExtractQuantities(t *testing.T) {
+	got := ExtractQuantities("Set the relief valve to 75 PSIG (5.2 bar) at 10–40 °C. Max load 1,000 psi, 2,5 kW.")
+	want := map[string][]float64{
+		"pressure":    {517.107, 520, 6894.757},
+		"temperature": {10, 40},
+		"power":       {2500},
+	}
+	byDim := make(map[string][]float64)
+	for _, q := range got {
+		byDim[q.Dimension] = append(byDim[q.Dimension], q.Value)
+	}
+	for dim, values := range want {
+		if len(byDim[dim]) != len(values) {
+			t.Errorf("%s: got %v, want %v", dim, byDim[dim], values)
+			continue
+		}
+		for i, v := range values {
+			if math.Abs(byDim[dim][i]-v) > 0.01 {
+				t.Errorf("%s[%d] = %g, want %g", dim, i, byDim[dim][i], v)
+			}
+		}
+	}
+	if len(got) == 0 || got[0].Raw != "75 PSIG" || got[0].Unit != "kPa" {
+		t.Errorf("first quantity = %+v", got)
+	}
+
+	if q := ExtractQuantities("See section 4.2 and table 3."); len(q) != 0 {
+		t.Errorf("numbers without units were extracted: %+v", q)
+	}
+}
+
+func TestParseNumericConstraints(t *testing.T) {
+	tests := []struct {
+		question string
+		want     string
+	}{
+		{"Which valves operate between 70 and 90 PSIG?", "pressure 482.63299–620.52813 kPa"},
+		{"relief valves rated 70-90 psig", "pressure 482.63299–620.52813 kPa"},
+		{"pumps above 10 bar", "pressure ≥ 1000 kPa"},
+		{"sensors for at most 104 °F", "temperature ≤ 40 °C"},
+		{"motores de más de 5 kW", "power ≥ 5000 W"},
+		{"what runs at 230 V?", "voltage = 230 V"},
+		{"how do I reset the alarm?", ""},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, r := range ParseNumericConstraints(tt.question) {
+			got = append(got, r.String())
+		}
+		if strings.Join(got, "; ") != tt.want {
+			t.Errorf("ParseNumericConstraints(%q) = %v, want %q", tt.question, got, tt.want)
+		}
+	}
+
+	if _, err := NormalizeRange(nil, nil, "psi"); err == nil {
+		t.Error("expected an error for a range without bounds")
+	}
+	if _, err := NormalizeRange(nil, new(float64), "furlongs"); err == nil {
+		t.Error("expected an error for an unknown unit")
+	}
+}
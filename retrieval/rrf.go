@@ -10,10 +10,27 @@ const rrfK = 60 // RRF constant (standard value from literature)
 
 // FusedResultInfo holds per-result method contribution metadata.
 type FusedResultInfo struct {
-	Methods   []string `json:"methods"`
-	VecRank   int      `json:"vec_rank,omitempty"`   // 1-based, 0 = not present
-	FTSRank   int      `json:"fts_rank,omitempty"`   // 1-based, 0 = not present
-	GraphRank int      `json:"graph_rank,omitempty"` // 1-based, 0 = not present
+	Methods    []string `json:"methods"`
+	VecRank    int      `json:"vec_rank,omitempty"`    // 1-based, 0 = not present
+	FTSRank    int      `json:"fts_rank,omitempty"`    // 1-based, 0 = not present
+	GraphRank  int      `json:"graph_rank,omitempty"`  // 1-based, 0 = not present
+	SparseRank int      `json:"sparse_rank,omitempty"` // 1-based, 0 = not present
+	ImageRank  int      `json:"image_rank,omitempty"`  // 1-based, 0 = not present
+	GraphPath  string   `json:"graph_path,omitempty"`  // relationship path that reached the chunk (multi-hop graph search)
+
+	SecondaryRank int `json:"secondary_rank,omitempty"` // 1-based, 0 = not present
+	NumericRank   int `json:"numeric_rank,omitempty"`   // 1-based, 0 = not present
+
+	// ScoreAdjustment is the factor Config.ScoreAdjusters multiplied the
+	// fused score by, when not 1.
+	ScoreAdjustment float64 `json:"score_adjustment,omitempty"`
+}
+
+// rrfLeg is one ranked result list contributing to the fusion.
+type rrfLeg struct {
+	method  string // "vector", "fts", "graph", "sparse", "image", "vector_secondary", "numeric"
+	results []store.RetrievalResult
+	weight  float64
 }
 
 // fuseRRF implements Reciprocal Rank Fusion to combine results from
@@ -25,6 +42,23 @@ func fuseRRF(
 	weightVec, weightFTS, weightGraph float64,
 	maxResults int,
 ) ([]store.RetrievalResult, map[int64]FusedResultInfo) {
+	return fuseLegs([]rrfLeg{
+		{method: "vector", results: vecResults, weight: weightVec},
+		{method: "fts", results: ftsResults, weight: weightFTS},
+		{method: "graph", results: graphResults, weight: weightGraph},
+	}, maxResults)
+}
+
+// fuseLegs is the general form of fuseRRF over any number of legs.
+func fuseLegs(legs []rrfLeg, maxResults int) ([]store.RetrievalResult, map[int64]FusedResultInfo) {
+	return fuseDistinct(legs, maxResults, nil)
+}
+
+// fuseDistinct is fuseLegs collapsing chunks with the same content hash:
+// the best ranked copy is kept and lists the others in Duplicates, and the
+// others leave the ranking, so maxResults counts distinct content. Chunks
+// without a hash are never collapsed.
+func fuseDistinct(legs []rrfLeg, maxResults int, hashes map[int64]string) ([]store.RetrievalResult, map[int64]FusedResultInfo) {
 	// Map from chunk_id -> fused score and result data
 	type fusedEntry struct {
 		result store.RetrievalResult
@@ -34,40 +68,34 @@ func fuseRRF(
 
 	fused := make(map[int64]*fusedEntry)
 
-	// Add vector results with their RRF scores
-	for rank, r := range vecResults {
-		entry, ok := fused[r.ChunkID]
-		if !ok {
-			entry = &fusedEntry{result: r}
-			fused[r.ChunkID] = entry
-		}
-		entry.score += weightVec / float64(rrfK+rank+1)
-		entry.info.Methods = append(entry.info.Methods, "vector")
-		entry.info.VecRank = rank + 1
-	}
-
-	// Add FTS results
-	for rank, r := range ftsResults {
-		entry, ok := fused[r.ChunkID]
-		if !ok {
-			entry = &fusedEntry{result: r}
-			fused[r.ChunkID] = entry
+	for _, leg := range legs {
+		for rank, r := range leg.results {
+			entry, ok := fused[r.ChunkID]
+			if !ok {
+				entry = &fusedEntry{result: r}
+				fused[r.ChunkID] = entry
+			}
+			entry.score += leg.weight / float64(rrfK+rank+1)
+			entry.info.Methods = append(entry.info.Methods, leg.method)
+			switch leg.method {
+			case "vector":
+				entry.info.VecRank = rank + 1
+			case "fts":
+				entry.info.FTSRank = rank + 1
+			case "graph":
+				entry.info.GraphRank = rank + 1
+				entry.info.GraphPath = r.GraphPath
+				entry.result.GraphPath = r.GraphPath
+			case "sparse":
+				entry.info.SparseRank = rank + 1
+			case "image":
+				entry.info.ImageRank = rank + 1
+			case "vector_secondary":
+				entry.info.SecondaryRank = rank + 1
+			case "numeric":
+				entry.info.NumericRank = rank + 1
+			}
 		}
-		entry.score += weightFTS / float64(rrfK+rank+1)
-		entry.info.Methods = append(entry.info.Methods, "fts")
-		entry.info.FTSRank = rank + 1
-	}
-
-	// Add graph results
-	for rank, r := range graphResults {
-		entry, ok := fused[r.ChunkID]
-		if !ok {
-			entry = &fusedEntry{result: r}
-			fused[r.ChunkID] = entry
-		}
-		entry.score += weightGraph / float64(rrfK+rank+1)
-		entry.info.Methods = append(entry.info.Methods, "graph")
-		entry.info.GraphRank = rank + 1
 	}
 
 	// Sort by fused score
@@ -76,10 +104,36 @@ func fuseRRF(
 		entries = append(entries, e)
 	}
 
+	// Ties go to the lower chunk ID, so the order (and the prompt built
+	// from it) does not depend on map iteration.
 	sort.Slice(entries, func(i, j int) bool {
-		return entries[i].score > entries[j].score
+		if entries[i].score != entries[j].score {
+			return entries[i].score > entries[j].score
+		}
+		return entries[i].result.ChunkID < entries[j].result.ChunkID
 	})
 
+	if len(hashes) > 0 {
+		kept := entries[:0]
+		byHash := make(map[string]*fusedEntry)
+		for _, e := range entries {
+			h := hashes[e.result.ChunkID]
+			if first, ok := byHash[h]; ok && h != "" {
+				first.result.Duplicates = append(first.result.Duplicates, store.DuplicateSource{
+					ChunkID:    e.result.ChunkID,
+					DocumentID: e.result.DocumentID,
+					Filename:   e.result.Filename,
+					Path:       e.result.Path,
+					PageNumber: e.result.PageNumber,
+				})
+				continue
+			}
+			byHash[h] = e
+			kept = append(kept, e)
+		}
+		entries = kept
+	}
+
 	// Limit results
 	if maxResults > 0 && len(entries) > maxResults {
 		entries = entries[:maxResults]
@@ -95,3 +149,15 @@ func fuseRRF(
 
 	return results, infoMap
 }
+
+// truncateFused cuts results to the first maxResults, dropping the info
+// of the results cut. A maxResults of 0 keeps them all.
+func truncateFused(results []store.RetrievalResult, info map[int64]FusedResultInfo, maxResults int) []store.RetrievalResult {
+	if maxResults <= 0 || len(results) <= maxResults {
+		return results
+	}
+	for _, r := range results[maxResults:] {
+		delete(info, r.ChunkID)
+	}
+	return results[:maxResults]
+}
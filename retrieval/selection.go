@@ -0,0 +1,173 @@
+package retrieval
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/bbiangul/go-reason/store"
+)
+
+// maxDocumentBoost is the largest multiplicative boost applied to results
+// from a document whose summary/keywords match every query term.
+const maxDocumentBoost = 0.25
+
+// selectDocuments scores each document by how many query terms appear in its
+// keyword list or summary. Keyword hits count double since keywords are the
+// LLM's own distillation of what the document is about. Returns a boost
+// factor per selected document (1 < factor <= 1+maxDocumentBoost).
+//
+// When every summarised document matches equally the summaries carry no
+// signal and nothing is boosted.
+func selectDocuments(terms []string, docs []store.DocumentSummary) map[int64]float64 {
+	if len(terms) == 0 || len(docs) == 0 {
+		return nil
+	}
+
+	scores := make(map[int64]int, len(docs))
+	best := 0
+	for _, d := range docs {
+		var keywords []string
+		_ = json.Unmarshal([]byte(d.Keywords), &keywords)
+		kw := strings.ToLower(strings.Join(keywords, " | "))
+		summary := strings.ToLower(d.Summary)
+
+		score := 0
+		for _, t := range terms {
+			t = strings.ToLower(t)
+			if strings.Contains(kw, t) {
+				score += 2
+			} else if strings.Contains(summary, t) {
+				score++
+			}
+		}
+		scores[d.DocumentID] = score
+		if score > best {
+			best = score
+		}
+	}
+
+	if best == 0 {
+		return nil
+	}
+	allEqual := true
+	for _, sc := range scores {
+		if sc != best {
+			allEqual = false
+			break
+		}
+	}
+	if allEqual && len(docs) > 1 {
+		return nil
+	}
+
+	maxScore := float64(2 * len(terms))
+	boosts := make(map[int64]float64)
+	for id, sc := range scores {
+		if sc == 0 {
+			continue
+		}
+		boosts[id] = 1 + maxDocumentBoost*float64(sc)/maxScore
+	}
+	return boosts
+}
+
+// applyDocumentBoost multiplies each result's fused score by its document's
+// boost factor and re-sorts. Results from unselected documents keep their
+// score, so selection reorders but never drops evidence.
+func applyDocumentBoost(results []store.RetrievalResult, boosts map[int64]float64) {
+	if len(boosts) == 0 {
+		return
+	}
+	for i := range results {
+		if b, ok := boosts[results[i].DocumentID]; ok {
+			results[i].Score *= b
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+}
+
+// applyImportance multiplies each result's score by its document's
+// importance and re-sorts, returning how many results were weighted.
+func applyImportance(results []store.RetrievalResult, importance map[int64]float64) int {
+	weighted := 0
+	for i := range results {
+		if w, ok := importance[results[i].DocumentID]; ok && w > 0 && w != 1 {
+			results[i].Score *= w
+			weighted++
+		}
+	}
+	if weighted > 0 {
+		sort.SliceStable(results, func(i, j int) bool {
+			return results[i].Score > results[j].Score
+		})
+	}
+	return weighted
+}
+
+// applyTypeBoost multiplies each result's score by the boost configured for
+// its chunk type and re-sorts. Boosts of 0 or 1 are no-ops.
+func applyTypeBoost(results []store.RetrievalResult, boosts map[string]float64) {
+	if len(boosts) == 0 {
+		return
+	}
+	changed := false
+	for i := range results {
+		if b, ok := boosts[results[i].ChunkType]; ok && b > 0 && b != 1 {
+			results[i].Score *= b
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+}
+
+// filterDocuments keeps the results whose document is in scope, preserving
+// their order.
+func filterDocuments(results []store.RetrievalResult, scope map[int64]bool) []store.RetrievalResult {
+	kept := results[:0]
+	for _, r := range results {
+		if scope[r.DocumentID] {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
+
+// scopedSearch runs a leg's search over a window of k and keeps the
+// results keep leaves (nil keeps all). The legs rank the whole corpus, so
+// when a full window leaves fewer than want results, the search is run
+// again over a window scopedOverfetch times wider, up to scopedMaxWindow.
+func scopedSearch(ctx context.Context, k, want int, keep func([]store.RetrievalResult) []store.RetrievalResult,
+	search func(ctx context.Context, k int) ([]store.RetrievalResult, error)) ([]store.RetrievalResult, error) {
+	for {
+		results, err := search(ctx, k)
+		if err != nil || keep == nil {
+			return results, err
+		}
+		full := len(results) >= k
+		results = keep(results)
+		if len(results) >= want || !full || k >= scopedMaxWindow {
+			return results, nil
+		}
+		k = min(k*scopedOverfetch, scopedMaxWindow)
+	}
+}
+
+// excludeDocuments drops the results from documents in excluded, in place.
+func excludeDocuments(results []store.RetrievalResult, excluded map[int64]bool) []store.RetrievalResult {
+	kept := results[:0]
+	for _, r := range results {
+		if !excluded[r.DocumentID] {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
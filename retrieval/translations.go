@@ -1,10 +1,14 @@
 package retrieval
 
 import (
+	"container/list"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"sort"
 	"strings"
 	"sync"
 
@@ -15,26 +19,75 @@ import (
 // Translator provides cross-language query expansion by reading corpus
 // languages from the database and translating query terms to all non-English
 // document languages via an LLM at runtime. Results are cached in memory so
-// each unique term is only translated once per engine lifetime.
+// each unique term is only translated once per engine lifetime, and each
+// question's translations are also cached per language in the database so
+// they survive restarts. The most recently used maxCachedQuestions
+// questions are kept in memory; older ones are read back from the database.
 type Translator struct {
 	chatLLM llm.Provider
-	store   *store.Store
+	store   TranslatorStore
+
+	mu        sync.RWMutex
+	langs     []string                       // cached corpus languages
+	cache     map[string]map[string][]string // lowercase English term → language → translated forms
+	questions map[string]*list.Element       // question key → *cachedQuestion
+	order     *list.List                     // questions, front = most recently used
+	stats     TranslationCacheStats
+}
+
+// maxCachedQuestions caps the questions whose translations a Translator
+// keeps in memory. A var so tests can lower it.
+var maxCachedQuestions = 4096
+
+// cachedQuestion is a question's translations by language.
+type cachedQuestion struct {
+	key          string
+	translations map[string]store.QueryTranslation
+}
 
-	mu    sync.RWMutex
-	langs []string            // cached corpus languages
-	cache map[string][]string // lowercase English term → translated forms
+// TranslatorStore is the storage a Translator reads corpus languages from
+// and persists query translations to. *store.Store implements it.
+type TranslatorStore interface {
+	store.DocumentStore
+	store.TranslationStore
+}
+
+// TranslationCacheStats reports query translation cache effectiveness. A
+// hit is a question whose translations into every corpus language were
+// cached; the saved tokens are what translating it cost the first time.
+type TranslationCacheStats struct {
+	Hits                  int64   `json:"hits"`
+	DiskHits              int64   `json:"disk_hits"` // hits read from the database rather than memory
+	Misses                int64   `json:"misses"`
+	HitRate               float64 `json:"hit_rate"`
+	SavedPromptTokens     int64   `json:"saved_prompt_tokens"`
+	SavedCompletionTokens int64   `json:"saved_completion_tokens"`
 }
 
 // NewTranslator creates a Translator. If chatLLM is nil translation is a
-// no-op (all methods return nil).
-func NewTranslator(chatLLM llm.Provider, s *store.Store) *Translator {
+// no-op (all methods return nil). s may be nil, which disables the
+// persistent cache.
+func NewTranslator(chatLLM llm.Provider, s TranslatorStore) *Translator {
 	return &Translator{
-		chatLLM: chatLLM,
-		store:   s,
-		cache:   make(map[string][]string),
+		chatLLM:   chatLLM,
+		store:     s,
+		cache:     make(map[string]map[string][]string),
+		questions: make(map[string]*list.Element),
+		order:     list.New(),
 	}
 }
 
+// CacheStats returns a snapshot of the translation cache counters.
+func (t *Translator) CacheStats() TranslationCacheStats {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	stats := t.stats
+	if stats.Hits+stats.Misses > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(stats.Hits+stats.Misses)
+	}
+	return stats
+}
+
 // Languages returns the corpus languages, reading from the DB on first call
 // and caching thereafter. Returns nil if no languages are detected.
 func (t *Translator) Languages() []string {
@@ -71,13 +124,20 @@ func (t *Translator) Languages() []string {
 	return t.langs
 }
 
+// Usage records LLM token consumption for a translation call.
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+}
+
 // TranslateTerms translates English query terms to all non-English corpus
 // languages. Returns additional terms (translated forms) to append to search
-// queries. Returns nil if all docs are English, no languages are detected,
-// or chatLLM is nil.
-func (t *Translator) TranslateTerms(ctx context.Context, terms []string) []string {
+// queries, and the tokens spent translating uncached terms (zero when every
+// term was cached). Returns nil if all docs are English, no languages are
+// detected, or chatLLM is nil.
+func (t *Translator) TranslateTerms(ctx context.Context, terms []string) ([]string, Usage) {
 	if t.chatLLM == nil || len(terms) == 0 {
-		return nil
+		return nil, Usage{}
 	}
 
 	langs := t.Languages()
@@ -90,13 +150,11 @@ func (t *Translator) TranslateTerms(ctx context.Context, terms []string) []strin
 		}
 	}
 	if len(targetLangs) == 0 {
-		return nil
+		return nil, Usage{}
 	}
 
-	// Deduplicate and check cache
-	t.mu.RLock()
-	var uncached []string
-	var result []string
+	// Deduplicate
+	var unique []string
 	seen := make(map[string]bool)
 	for _, term := range terms {
 		lower := strings.ToLower(term)
@@ -104,33 +162,190 @@ func (t *Translator) TranslateTerms(ctx context.Context, terms []string) []strin
 			continue
 		}
 		seen[lower] = true
-		if cached, ok := t.cache[lower]; ok {
-			result = append(result, cached...)
+		unique = append(unique, lower)
+	}
+	if len(unique) == 0 {
+		return nil, Usage{}
+	}
+
+	key := questionKey(unique)
+	if cached, ok := t.lookupQuestion(ctx, key, targetLangs); ok {
+		var result []string
+		for _, lang := range targetLangs {
+			result = append(result, cached[lang].Terms...)
+		}
+		return result, Usage{}
+	}
+
+	// Check the term cache
+	t.mu.RLock()
+	var uncached []string
+	for _, term := range unique {
+		if _, ok := t.cache[term]; !ok {
+			uncached = append(uncached, term)
+		}
+	}
+	t.mu.RUnlock()
+
+	// Batch translate via LLM to all target languages
+	var usage Usage
+	if len(uncached) > 0 {
+		usage = t.llmTranslateMulti(ctx, uncached, targetLangs)
+	}
+
+	// Assemble the translations per language. The question is cached only
+	// when every term was translated; a failed call is retried by a later
+	// engine rather than remembered on disk.
+	t.mu.RLock()
+	perLang := make(map[string][]string, len(targetLangs))
+	complete := true
+	for _, term := range unique {
+		forms, ok := t.cache[term]
+		if forms == nil {
+			complete = false
+		}
+		if !ok {
+			continue
+		}
+		for lang, f := range forms {
+			perLang[lang] = append(perLang[lang], f...)
+		}
+	}
+	t.mu.RUnlock()
+
+	var result []string
+	for _, lang := range targetLangs {
+		result = append(result, perLang[lang]...)
+	}
+	if _, unattributed := perLang[""]; unattributed {
+		result = append(result, perLang[""]...)
+		complete = false
+	}
+	if complete {
+		t.storeQuestion(ctx, key, targetLangs, perLang, usage)
+	}
+	return result, usage
+}
+
+// questionKey hashes a question's deduplicated, lowercased terms, so
+// questions that differ only in stop words, case, or word order share an
+// entry.
+func questionKey(terms []string) string {
+	sorted := append([]string(nil), terms...)
+	sort.Strings(sorted)
+	sum := sha256.Sum256([]byte(strings.Join(sorted, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// lookupQuestion returns the cached translations of a question into every
+// target language, reading those not in memory from the store, and records
+// a hit or miss.
+func (t *Translator) lookupQuestion(ctx context.Context, key string, targetLangs []string) (map[string]store.QueryTranslation, bool) {
+	t.mu.RLock()
+	cached := make(map[string]store.QueryTranslation, len(targetLangs))
+	var missing []string
+	var inMemory map[string]store.QueryTranslation
+	if el, ok := t.questions[key]; ok {
+		inMemory = el.Value.(*cachedQuestion).translations
+	}
+	for _, lang := range targetLangs {
+		if tr, ok := inMemory[lang]; ok {
+			cached[lang] = tr
 		} else {
-			uncached = append(uncached, lower)
+			missing = append(missing, lang)
 		}
 	}
 	t.mu.RUnlock()
 
-	if len(uncached) == 0 {
-		return result
+	fromDisk := false
+	if len(missing) > 0 && t.store != nil {
+		stored, err := t.store.GetQueryTranslations(ctx, key, missing)
+		if err != nil {
+			slog.WarnContext(ctx, "translator: reading cached translations failed (non-fatal)", "error", err)
+		}
+		for lang, tr := range stored {
+			cached[lang] = tr
+		}
+		fromDisk = len(stored) > 0
 	}
 
-	// Batch translate via LLM to all target languages
-	translated := t.llmTranslateMulti(ctx, uncached, targetLangs)
-	for _, term := range uncached {
-		if forms, ok := translated[term]; ok {
-			result = append(result, forms...)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(cached) < len(targetLangs) {
+		t.stats.Misses++
+		return nil, false
+	}
+	if fromDisk {
+		t.stats.DiskHits++
+	}
+	t.rememberQuestion(key, cached)
+	t.stats.Hits++
+	for _, tr := range cached {
+		t.stats.SavedPromptTokens += int64(tr.PromptTokens)
+		t.stats.SavedCompletionTokens += int64(tr.CompletionTokens)
+	}
+	return cached, true
+}
+
+// storeQuestion caches a question's translations in memory and in the
+// store. Each language is charged an equal share of usage.
+func (t *Translator) storeQuestion(ctx context.Context, key string, targetLangs []string, perLang map[string][]string, usage Usage) {
+	n := len(targetLangs)
+	translations := make(map[string]store.QueryTranslation, n)
+	for _, lang := range targetLangs {
+		translations[lang] = store.QueryTranslation{
+			QuestionHash:     key,
+			Language:         lang,
+			Terms:            perLang[lang],
+			PromptTokens:     usage.PromptTokens / n,
+			CompletionTokens: usage.CompletionTokens / n,
 		}
 	}
 
-	return result
+	t.mu.Lock()
+	t.rememberQuestion(key, translations)
+	t.mu.Unlock()
+
+	if t.store == nil {
+		return
+	}
+	for _, lang := range targetLangs {
+		if err := t.store.PutQueryTranslation(ctx, translations[lang]); err != nil {
+			slog.WarnContext(ctx, "translator: caching translation failed (non-fatal)",
+				"language", lang, "error", err)
+			return
+		}
+	}
+}
+
+// rememberQuestion adds translations to the in-memory question cache and
+// marks the question most recently used, evicting the least recently used
+// question when the cache is full. The caller holds t.mu.
+func (t *Translator) rememberQuestion(key string, translations map[string]store.QueryTranslation) {
+	if el, ok := t.questions[key]; ok {
+		m := el.Value.(*cachedQuestion).translations
+		for lang, tr := range translations {
+			m[lang] = tr
+		}
+		t.order.MoveToFront(el)
+		return
+	}
+	m := make(map[string]store.QueryTranslation, len(translations))
+	for lang, tr := range translations {
+		m[lang] = tr
+	}
+	t.questions[key] = t.order.PushFront(&cachedQuestion{key: key, translations: m})
+	if t.order.Len() > maxCachedQuestions {
+		oldest := t.order.Back()
+		t.order.Remove(oldest)
+		delete(t.questions, oldest.Value.(*cachedQuestion).key)
+	}
 }
 
 // llmTranslateMulti sends a batch of terms to the LLM for translation into
-// multiple target languages and caches the results. Each term maps to an
-// array of all translated forms across all target languages.
-func (t *Translator) llmTranslateMulti(ctx context.Context, terms []string, targetLangs []string) map[string][]string {
+// multiple target languages and caches each term's translated forms by
+// language. The returned Usage reports the tokens spent on the LLM call.
+func (t *Translator) llmTranslateMulti(ctx context.Context, terms []string, targetLangs []string) Usage {
 	langList := strings.Join(targetLangs, ", ")
 	prompt := fmt.Sprintf(
 		`Translate these English technical terms to %s. For each term provide the singular and plural forms in each target language.
@@ -155,13 +370,18 @@ Terms: %s`, langList, strings.Join(terms, ", "))
 		MaxTokens:   2048,
 	})
 	if err != nil {
-		slog.Warn("translator: LLM translation failed", "error", err, "terms", len(terms))
-		t.cacheEmpty(terms)
-		return nil
+		slog.WarnContext(ctx, "translator: LLM translation failed", "error", err, "terms", len(terms))
+		// A cancelled or timed out call says nothing about the terms, so
+		// a later query tries them again.
+		if ctx.Err() == nil {
+			t.cacheEmpty(terms)
+		}
+		return Usage{}
 	}
+	usage := Usage{PromptTokens: resp.PromptTokens, CompletionTokens: resp.CompletionTokens}
 
 	// Parse JSON — strip thinking blocks and markdown fences
-	content := stripThinking(strings.TrimSpace(resp.Content))
+	content := llm.StripThinking(strings.TrimSpace(resp.Content))
 	if idx := strings.Index(content, "{"); idx >= 0 {
 		content = content[idx:]
 	}
@@ -172,58 +392,56 @@ Terms: %s`, langList, strings.Join(terms, ", "))
 	// Try multi-language format first: {"term": {"Lang": [...]}}
 	var multiParsed map[string]map[string][]string
 	if err := json.Unmarshal([]byte(content), &multiParsed); err == nil {
-		// Flatten to term → all forms
-		flat := make(map[string][]string, len(multiParsed))
-		for term, langMap := range multiParsed {
-			var allForms []string
-			for _, forms := range langMap {
-				allForms = append(allForms, forms...)
-			}
-			flat[term] = allForms
-		}
-
-		// Cache results
-		t.mu.Lock()
-		for _, term := range terms {
-			if forms, ok := flat[term]; ok && len(forms) > 0 {
-				t.cache[term] = forms
-			} else {
-				t.cache[term] = nil
-			}
-		}
-		t.mu.Unlock()
-
-		slog.Debug("translator: translated terms (multi-lang)",
-			"requested", len(terms), "returned", len(flat), "langs", langList)
-		return flat
+		t.cacheTranslations(terms, multiParsed)
+		slog.DebugContext(ctx, "translator: translated terms (multi-lang)",
+			"requested", len(terms), "returned", len(multiParsed), "langs", langList)
+		return usage
 	}
 
 	// Fallback: try simple format {"term": [...]} (single target language)
 	var simpleParsed map[string][]string
 	if err := json.Unmarshal([]byte(content), &simpleParsed); err != nil {
-		slog.Warn("translator: failed to parse translation JSON",
+		slog.WarnContext(ctx, "translator: failed to parse translation JSON",
 			"error", err, "content_len", len(content))
 		t.cacheEmpty(terms)
-		return nil
+		return usage
+	}
+
+	// Without languages in the reply the forms can only be attributed when
+	// there is a single target language.
+	lang := ""
+	if len(targetLangs) == 1 {
+		lang = targetLangs[0]
+	}
+	byLang := make(map[string]map[string][]string, len(simpleParsed))
+	for term, forms := range simpleParsed {
+		byLang[term] = map[string][]string{lang: forms}
 	}
+	t.cacheTranslations(terms, byLang)
 
-	// Cache results
+	slog.DebugContext(ctx, "translator: translated terms (simple)",
+		"requested", len(terms), "returned", len(simpleParsed), "langs", langList)
+	return usage
+}
+
+// cacheTranslations records the translated forms of each term by language;
+// terms missing from translated are cached as having none.
+func (t *Translator) cacheTranslations(terms []string, translated map[string]map[string][]string) {
 	t.mu.Lock()
 	for _, term := range terms {
-		if forms, ok := simpleParsed[term]; ok && len(forms) > 0 {
-			t.cache[term] = forms
-		} else {
-			t.cache[term] = nil
+		forms := make(map[string][]string)
+		for lang, f := range translated[term] {
+			if len(f) > 0 {
+				forms[lang] = f
+			}
 		}
+		t.cache[term] = forms
 	}
 	t.mu.Unlock()
-
-	slog.Debug("translator: translated terms (simple)",
-		"requested", len(terms), "returned", len(simpleParsed), "langs", langList)
-	return simpleParsed
 }
 
-// cacheEmpty records nil for each term so we don't retry failed translations.
+// cacheEmpty records nil for each term so we don't retry failed
+// translations during this engine's lifetime.
 func (t *Translator) cacheEmpty(terms []string) {
 	t.mu.Lock()
 	for _, term := range terms {
@@ -231,22 +449,3 @@ func (t *Translator) cacheEmpty(terms []string) {
 	}
 	t.mu.Unlock()
 }
-
-// stripThinking removes <think>...</think> blocks from LLM output.
-// Some models (e.g. Qwen3) wrap reasoning in these tags.
-func stripThinking(s string) string {
-	for {
-		start := strings.Index(s, "<think>")
-		if start == -1 {
-			break
-		}
-		end := strings.Index(s, "</think>")
-		if end == -1 {
-			// Unclosed tag — strip from <think> onward
-			s = s[:start]
-			break
-		}
-		s = s[:start] + s[end+len("</think>"):]
-	}
-	return strings.TrimSpace(s)
-}
@@ -0,0 +1,58 @@
+package retrieval
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+
+	"github.com/bbiangul/go-reason/store"
+)
+
+const (
+	// usageRetrievalCredit is what a retrieval adds to a chunk's popularity
+	// relative to a citation in an accepted answer.
+	usageRetrievalCredit = 0.1
+	// usageSaturation is the popularity at which a chunk gets half of
+	// Config.UsageBoost; the boost approaches the full value from there.
+	usageSaturation = 3.0
+)
+
+// applyUsageBoost multiplies the fused score of chunks that earlier answers
+// retrieved and cited by up to 1+Config.UsageBoost and re-sorts them. It
+// returns how many results were boosted. A failed read is logged and the
+// results are left as they are.
+func (e *Engine) applyUsageBoost(ctx context.Context, results []store.RetrievalResult) int {
+	ids := make([]int64, len(results))
+	for i, r := range results {
+		ids[i] = r.ChunkID
+	}
+	usage, err := e.store.ChunkUsages(ctx, ids, e.cfg.UsageHalfLife)
+	if err != nil {
+		slog.WarnContext(ctx, "retrieval: loading chunk usage failed", "error", err)
+		return 0
+	}
+	return boostByUsage(results, usage, e.cfg.UsageBoost)
+}
+
+// boostByUsage implements applyUsageBoost over loaded usage.
+func boostByUsage(results []store.RetrievalResult, usage map[int64]store.ChunkUsage, boost float64) int {
+	boosted := 0
+	for i := range results {
+		u, ok := usage[results[i].ChunkID]
+		if !ok {
+			continue
+		}
+		p := u.CitedScore + usageRetrievalCredit*u.RetrievedScore
+		if p <= 0 {
+			continue
+		}
+		results[i].Score *= 1 + boost*p/(p+usageSaturation)
+		boosted++
+	}
+	if boosted > 0 {
+		sort.SliceStable(results, func(i, j int) bool {
+			return results[i].Score > results[j].Score
+		})
+	}
+	return boosted
+}
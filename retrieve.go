@@ -0,0 +1,118 @@
+package goreason
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bbiangul/go-reason/llm"
+	"github.com/bbiangul/go-reason/retrieval"
+	"github.com/bbiangul/go-reason/store"
+)
+
+// Retrieve runs Query's hybrid retrieval without reasoning, on a query
+// worker when Config.QueryWorkers is set. Of the query options, those
+// that shape retrieval apply: WithMaxResults, the weights, WithCollection,
+// WithSkipGraph, WithEmbeddingSpace, and WithNumericFilter. No LLM
+// answers and nothing is logged, so applications with their own
+// generation step can use the engine as a retriever.
+func (e *engine) Retrieve(ctx context.Context, question string, opts ...QueryOption) ([]store.RetrievalResult, *retrieval.SearchTrace, error) {
+	if e.workers == nil {
+		return e.retrieve(ctx, question, opts...)
+	}
+	var results []store.RetrievalResult
+	var trace *retrieval.SearchTrace
+	_, err := e.workers.do(ctx, func(w *engine) (*Answer, error) {
+		var err error
+		results, trace, err = w.retrieve(ctx, question, opts...)
+		return nil, err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return results, trace, nil
+}
+
+// retrieve runs Retrieve on e's own store and retriever.
+func (e *engine) retrieve(ctx context.Context, question string, opts ...QueryOption) ([]store.RetrievalResult, *retrieval.SearchTrace, error) {
+	ctx = llm.WithPhase(ctx, llm.PhaseQuery)
+	options := e.queryOptions(opts)
+	if !validEmbeddingSpace(options.space) {
+		return nil, nil, fmt.Errorf("%w: unknown embedding space %q", ErrInvalidConfig, options.space)
+	}
+	if err := e.embeddingDrift(); err != nil {
+		return nil, nil, err
+	}
+	scope, err := e.documentScope(ctx, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	return e.search(ctx, question, options, scope)
+}
+
+// queryOptions applies opts over the query defaults, and over the preset
+// of the collection they select.
+func (e *engine) queryOptions(opts []QueryOption) *queryOptions {
+	options := e.defaultQueryOptions("")
+	for _, o := range opts {
+		o(options)
+	}
+	if options.collection != "" {
+		// Re-apply the caller's options over the collection's preset.
+		options = e.defaultQueryOptions(options.collection)
+		for _, o := range opts {
+			o(options)
+		}
+	}
+	return options
+}
+
+// documentScope returns the documents the query searches: those of the
+// selected collection (none selected searches the whole corpus), plus the
+// query's session documents, less other sessions' documents. An empty
+// collection is ErrNoResults.
+func (e *engine) documentScope(ctx context.Context, options *queryOptions) (searchScope, error) {
+	var scope searchScope
+	if options.collection != "" {
+		ids, err := e.store.DocumentIDsInCollection(ctx, options.collection)
+		if err != nil {
+			return scope, fmt.Errorf("loading collection %q: %w", options.collection, err)
+		}
+		if len(ids) == 0 {
+			return scope, ErrNoResults
+		}
+		scope.include = ids
+	}
+	if err := e.sessionScope(ctx, options, &scope); err != nil {
+		return scope, err
+	}
+	return scope, nil
+}
+
+// search runs hybrid retrieval for a question over the scope's documents.
+// It returns ErrNoResults when nothing matches, and the trace of a search
+// that failed, such as a cancelled one, along with its error.
+func (e *engine) search(ctx context.Context, question string, options *queryOptions, scope searchScope) ([]store.RetrievalResult, *retrieval.SearchTrace, error) {
+	ranges, err := numericRanges(options.numericFilters)
+	if err != nil {
+		return nil, nil, err
+	}
+	results, trace, err := e.retriever.Search(ctx, question, retrieval.SearchOptions{
+		MaxResults:  options.maxResults,
+		WeightVec:   options.weightVec,
+		WeightFTS:   options.weightFTS,
+		WeightGraph: options.weightGraph,
+		DocumentIDs: scope.include,
+		SkipGraph:   options.skipGraph,
+
+		ExcludeDocumentIDs: scope.exclude,
+		EmbeddingSpace:     options.space,
+		NumericFilters:     ranges,
+	})
+	if err != nil {
+		return nil, trace, fmt.Errorf("retrieval: %w", err)
+	}
+	if len(results) == 0 {
+		return nil, nil, ErrNoResults
+	}
+	return results, trace, nil
+}
@@ -0,0 +1,50 @@
+package goreason
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRetrieve(t *testing.T) {
+	for _, workers := range []int{0, 1} {
+		eng, srv, path := faultEngine(t, nil, skipGraph, func(cfg *Config) { cfg.QueryWorkers = workers })
+		var chats atomic.Int32
+		srv.ChatFunc = func(prompt string) string {
+			chats.Add(1)
+			return "The relief valve opens at 10 bar (pump.txt)."
+		}
+		ctx := context.Background()
+		if err := os.WriteFile(path, []byte("The relief valve opens at 10 bar.\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := eng.Ingest(ctx, path); err != nil {
+			t.Fatalf("Ingest: %v", err)
+		}
+
+		before := chats.Load()
+		results, trace, err := eng.Retrieve(ctx, "At what pressure does the relief valve open?", WithMaxResults(5))
+		if err != nil {
+			t.Fatalf("workers=%d: Retrieve: %v", workers, err)
+		}
+		if len(results) == 0 || !strings.Contains(results[0].Content, "10 bar") || results[0].Filename != "pump.txt" {
+			t.Errorf("workers=%d: results = %+v", workers, results)
+		}
+		if trace == nil || trace.FusedResults != len(results) || trace.MaxRequested != 5 {
+			t.Errorf("workers=%d: trace = %+v", workers, trace)
+		}
+		if n := chats.Load() - before; n != 0 {
+			t.Errorf("workers=%d: Retrieve made %d chat calls, want none", workers, n)
+		}
+
+		if _, _, err := eng.Retrieve(ctx, "relief valve", WithCollection("empty")); !errors.Is(err, ErrNoResults) {
+			t.Errorf("workers=%d: empty collection: err = %v, want ErrNoResults", workers, err)
+		}
+		if _, _, err := eng.Retrieve(ctx, "relief valve", WithEmbeddingSpace("nope")); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("workers=%d: unknown space: err = %v, want ErrInvalidConfig", workers, err)
+		}
+	}
+}
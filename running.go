@@ -0,0 +1,198 @@
+package goreason
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bbiangul/go-reason/reasoning"
+	"github.com/bbiangul/go-reason/requestid"
+	"github.com/bbiangul/go-reason/retrieval"
+)
+
+// RunningQuery describes a query in progress.
+type RunningQuery struct {
+	// ID is the query's request ID (X-Request-ID on the server), or a
+	// random one when it has none or another running query has it.
+	ID        string    `json:"id"`
+	Question  string    `json:"question"`
+	StartedAt time.Time `json:"started_at"`
+	ElapsedMs int64     `json:"elapsed_ms"`
+	// Steps counts the reasoning steps taken so far.
+	Steps int `json:"steps"`
+}
+
+// runningQueries tracks the queries in progress by ID.
+type runningQueries struct {
+	mu      sync.Mutex
+	queries map[string]*runningQuery
+}
+
+// runningQuery is a query in progress and the trace it has so far.
+type runningQuery struct {
+	id       string
+	question string
+	started  time.Time
+	cancel   context.CancelCauseFunc
+	done     chan struct{}
+	answer   *Answer // what Query returned, set before done is closed
+
+	mu    sync.Mutex
+	trace *retrieval.SearchTrace
+	steps []reasoning.Step
+}
+
+type runningQueryKey struct{}
+
+// start registers a query and returns the context it runs with, which
+// CancelQuery cancels with ErrQueryCancelled.
+func (r *runningQueries) start(ctx context.Context, question string) (context.Context, *runningQuery) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	rq := &runningQuery{question: question, started: time.Now(), cancel: cancel, done: make(chan struct{})}
+
+	r.mu.Lock()
+	if r.queries == nil {
+		r.queries = make(map[string]*runningQuery)
+	}
+	rq.id = requestid.From(ctx)
+	if _, taken := r.queries[rq.id]; taken || rq.id == "" {
+		rq.id = requestid.New()
+	}
+	r.queries[rq.id] = rq
+	r.mu.Unlock()
+	return context.WithValue(ctx, runningQueryKey{}, rq), rq
+}
+
+// finish unregisters rq, handing answer to the CancelQuery calls waiting
+// for it.
+func (r *runningQueries) finish(rq *runningQuery, answer *Answer) {
+	r.mu.Lock()
+	delete(r.queries, rq.id)
+	r.mu.Unlock()
+	rq.answer = answer
+	close(rq.done)
+	rq.cancel(nil)
+}
+
+// runningQueryFrom returns the query ctx belongs to, or nil for queries
+// not started through Query, such as CompareQuery's.
+func runningQueryFrom(ctx context.Context) *runningQuery {
+	rq, _ := ctx.Value(runningQueryKey{}).(*runningQuery)
+	return rq
+}
+
+// setTrace records the query's retrieval trace. It is copied, as the
+// synthesis follow-up keeps adding to the original.
+func (rq *runningQuery) setTrace(trace *retrieval.SearchTrace) {
+	if rq == nil || trace == nil {
+		return
+	}
+	t := *trace
+	rq.mu.Lock()
+	rq.trace = &t
+	rq.mu.Unlock()
+}
+
+// addStep records a reasoning step (reasoning.Options.OnStep).
+func (rq *runningQuery) addStep(s reasoning.Step) {
+	rq.mu.Lock()
+	rq.steps = append(rq.steps, s)
+	rq.mu.Unlock()
+}
+
+// partial returns the answer of a cancelled query: its retrieval trace
+// and the reasoning steps of every pass, in the order they were taken.
+// Rounds is the last round a step was taken in.
+func (rq *runningQuery) partial() *Answer {
+	rq.mu.Lock()
+	defer rq.mu.Unlock()
+	rounds := 0
+	for _, s := range rq.steps {
+		rounds = max(rounds, s.Round)
+	}
+	return &Answer{
+		RetrievalTrace: rq.trace,
+		Reasoning:      toSteps(rq.steps),
+		Rounds:         rounds,
+		Cancelled:      true,
+	}
+}
+
+// queryCancelled returns ErrQueryCancelled once CancelQuery stopped the
+// query ctx belongs to.
+func queryCancelled(ctx context.Context) error {
+	if err := context.Cause(ctx); errors.Is(err, ErrQueryCancelled) {
+		return err
+	}
+	return nil
+}
+
+// toSteps converts reasoning steps to the trace of an Answer.
+func toSteps(steps []reasoning.Step) []Step {
+	var out []Step
+	for _, s := range steps {
+		out = append(out, Step{
+			Round:      s.Round,
+			Action:     s.Action,
+			Input:      s.Input,
+			Output:     s.Output,
+			Prompt:     s.Prompt,
+			Response:   s.Response,
+			Validation: s.Validation,
+			ChunksUsed: s.ChunksUsed,
+			Tokens:     s.Tokens,
+			ElapsedMs:  s.ElapsedMs,
+			Issues:     s.Issues,
+		})
+	}
+	return out
+}
+
+// RunningQueries lists the queries in progress, oldest first.
+func (e *engine) RunningQueries() []RunningQuery {
+	e.running.mu.Lock()
+	queries := make([]*runningQuery, 0, len(e.running.queries))
+	for _, rq := range e.running.queries {
+		queries = append(queries, rq)
+	}
+	e.running.mu.Unlock()
+	sort.Slice(queries, func(i, j int) bool { return queries[i].started.Before(queries[j].started) })
+
+	out := make([]RunningQuery, len(queries))
+	for i, rq := range queries {
+		rq.mu.Lock()
+		steps := len(rq.steps)
+		rq.mu.Unlock()
+		out[i] = RunningQuery{
+			ID:        rq.id,
+			Question:  rq.question,
+			StartedAt: rq.started,
+			ElapsedMs: time.Since(rq.started).Milliseconds(),
+			Steps:     steps,
+		}
+	}
+	return out
+}
+
+// CancelQuery stops a query in progress and waits for it to return. The
+// retrieval and LLM calls in flight are abandoned; the query is not
+// logged.
+func (e *engine) CancelQuery(ctx context.Context, id string) (*Answer, error) {
+	e.running.mu.Lock()
+	rq := e.running.queries[id]
+	e.running.mu.Unlock()
+	if rq == nil {
+		return nil, ErrQueryNotRunning
+	}
+	slog.InfoContext(ctx, "query: cancelling", "id", id, "elapsed", time.Since(rq.started).Round(time.Millisecond))
+	rq.cancel(ErrQueryCancelled)
+	select {
+	case <-rq.done:
+		return rq.answer, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,74 @@
+package goreason
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bbiangul/go-reason/llm/llmtest"
+	"github.com/bbiangul/go-reason/reasoning"
+)
+
+func TestCancelQuery(t *testing.T) {
+	const question = "At what pressure does the relief valve open?"
+	// The answer call hangs until the query gives up on it.
+	eng, srv, path := faultEngine(t, &llmtest.Scenario{Rules: []llmtest.Rule{
+		{Op: llmtest.OpChat, Match: question, Fault: llmtest.FaultTimeout},
+	}})
+	ctx := context.Background()
+	if _, err := eng.Ingest(ctx, path); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+
+	type result struct {
+		answer *Answer
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		answer, err := eng.Query(WithRequestID(ctx, "runaway"), question)
+		done <- result{answer, err}
+	}()
+	for deadline := time.Now().Add(5 * time.Second); srv.Faults(llmtest.FaultTimeout) == 0; time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("the answer call never started")
+		}
+	}
+
+	running := eng.RunningQueries()
+	if len(running) != 1 || running[0].ID != "runaway" || running[0].Question != question {
+		t.Fatalf("RunningQueries = %+v, want the runaway query", running)
+	}
+	if _, err := eng.CancelQuery(ctx, "other"); !errors.Is(err, ErrQueryNotRunning) {
+		t.Errorf("CancelQuery(other) = %v, want ErrQueryNotRunning", err)
+	}
+
+	answer, err := eng.CancelQuery(ctx, "runaway")
+	if err != nil {
+		t.Fatalf("CancelQuery: %v", err)
+	}
+	if !answer.Cancelled || answer.Text != "" || answer.RetrievalTrace == nil || answer.RetrievalTrace.FusedResults == 0 {
+		t.Errorf("partial answer = %+v, want the retrieval trace of a cancelled query", answer)
+	}
+	r := <-done
+	if !errors.Is(r.err, ErrQueryCancelled) || r.answer != answer {
+		t.Errorf("Query = %+v, %v, want the partial answer and ErrQueryCancelled", r.answer, r.err)
+	}
+	if running := eng.RunningQueries(); len(running) != 0 {
+		t.Errorf("RunningQueries after cancel = %+v", running)
+	}
+	if _, err := eng.CancelQuery(ctx, "runaway"); !errors.Is(err, ErrQueryNotRunning) {
+		t.Errorf("second CancelQuery = %v, want ErrQueryNotRunning", err)
+	}
+}
+
+func TestPartialRounds(t *testing.T) {
+	rq := &runningQuery{}
+	for _, round := range []int{1, 1, 2, 2, 2} {
+		rq.addStep(reasoning.Step{Round: round})
+	}
+	if a := rq.partial(); a.Rounds != 2 || len(a.Reasoning) != 5 {
+		t.Errorf("partial = %d rounds, %d steps, want 2 rounds of 5 steps", a.Rounds, len(a.Reasoning))
+	}
+}
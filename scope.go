@@ -0,0 +1,245 @@
+package goreason
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bbiangul/go-reason/llm"
+	"github.com/bbiangul/go-reason/store"
+)
+
+// Scope check methods (ScopeConfig.Method).
+const (
+	ScopeLLM       = "llm"
+	ScopeEmbedding = "embedding"
+)
+
+// defaultScopeSimilarity is ScopeConfig.MinSimilarity when unset.
+const defaultScopeSimilarity = 0.3
+
+const scopePrompt = `You screen questions for a knowledge base that covers: %s
+
+Decide whether the question below could be answered from such a knowledge base. Reply IN unless the question is clearly unrelated to these topics (small talk, weather, news, general knowledge about other subjects). When in doubt, reply IN.
+
+Reply with exactly one line: IN, or OUT: <short reason>.
+
+QUESTION: %s`
+
+// validateScope checks Config.Scope.
+func validateScope(sc *ScopeConfig) error {
+	if sc == nil {
+		return nil
+	}
+	switch sc.Method {
+	case "", ScopeLLM:
+		if strings.TrimSpace(sc.Topics) == "" {
+			return fmt.Errorf("%w: scope.topics is required", ErrInvalidConfig)
+		}
+	case ScopeEmbedding:
+		if strings.TrimSpace(sc.Topics) == "" && len(sc.Examples) == 0 {
+			return fmt.Errorf("%w: scope needs topics or examples for the embedding method", ErrInvalidConfig)
+		}
+	default:
+		return fmt.Errorf("%w: unknown scope method %q", ErrInvalidConfig, sc.Method)
+	}
+	if sc.MinSimilarity < 0 || sc.MinSimilarity > 1 {
+		return fmt.Errorf("%w: scope.min_similarity must be between 0 and 1", ErrInvalidConfig)
+	}
+	return nil
+}
+
+// scopeGuard refuses out-of-scope questions before retrieval. A nil
+// *scopeGuard lets every question through.
+type scopeGuard struct {
+	cfg   ScopeConfig
+	chat  llm.Provider
+	embed llm.Provider
+
+	// centroid is the normalized mean embedding of Topics and Examples,
+	// computed on first use.
+	mu       sync.Mutex
+	centroid []float32
+}
+
+// newScopeGuard returns nil when sc is nil.
+func newScopeGuard(sc *ScopeConfig, chat, embed llm.Provider) *scopeGuard {
+	if sc == nil {
+		return nil
+	}
+	return &scopeGuard{cfg: *sc, chat: chat, embed: embed}
+}
+
+// check returns why question is out of scope, or "" when it is in scope,
+// with the chat tokens spent deciding. A failed check lets the question
+// through: only clearly out-of-scope questions are refused.
+func (g *scopeGuard) check(ctx context.Context, question string) (string, int, int) {
+	if g == nil {
+		return "", 0, 0
+	}
+	var reason string
+	var pt, ct int
+	var err error
+	if g.cfg.Method == ScopeEmbedding {
+		reason, err = g.checkEmbedding(ctx, question)
+	} else {
+		reason, pt, ct, err = g.checkLLM(ctx, question)
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "query: scope check failed, answering (non-fatal)", "error", err)
+		return "", pt, ct
+	}
+	return reason, pt, ct
+}
+
+func (g *scopeGuard) checkLLM(ctx context.Context, question string) (string, int, int, error) {
+	resp, err := g.chat.Chat(ctx, llm.ChatRequest{
+		Messages: []llm.Message{
+			{Role: "user", Content: fmt.Sprintf(scopePrompt, g.cfg.Topics, question)},
+		},
+		Temperature: 0,
+		MaxTokens:   64,
+	})
+	if err != nil {
+		return "", 0, 0, err
+	}
+	return parseScopeReply(resp.Content), resp.PromptTokens, resp.CompletionTokens, nil
+}
+
+// parseScopeReply returns the reason of an "OUT: <reason>" reply, or ""
+// for anything else.
+func parseScopeReply(reply string) string {
+	reply = strings.TrimSpace(reply)
+	if len(reply) < 3 || !strings.EqualFold(reply[:3], "OUT") {
+		return ""
+	}
+	reason := strings.TrimSpace(strings.TrimLeft(reply[3:], " :-–"))
+	if i := strings.IndexByte(reason, '\n'); i >= 0 {
+		reason = strings.TrimSpace(reason[:i])
+	}
+	if reason == "" {
+		reason = "outside the knowledge base's topics"
+	}
+	return reason
+}
+
+func (g *scopeGuard) checkEmbedding(ctx context.Context, question string) (string, error) {
+	centroid, err := g.loadCentroid(ctx)
+	if err != nil {
+		return "", err
+	}
+	vecs, err := g.embed.Embed(ctx, []string{question})
+	if err != nil {
+		return "", fmt.Errorf("embedding question: %w", err)
+	}
+	if len(vecs) != 1 || len(vecs[0]) != len(centroid) {
+		return "", fmt.Errorf("embedding question: unexpected embedding shape")
+	}
+	minSim := g.cfg.MinSimilarity
+	if minSim == 0 {
+		minSim = defaultScopeSimilarity
+	}
+	if sim := cosineSimilarity(vecs[0], centroid); sim < minSim {
+		return fmt.Sprintf("similarity %.2f to the knowledge base's topics is below %.2f", sim, minSim), nil
+	}
+	return "", nil
+}
+
+// loadCentroid embeds Topics and Examples once and averages them.
+func (g *scopeGuard) loadCentroid(ctx context.Context) ([]float32, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.centroid != nil {
+		return g.centroid, nil
+	}
+
+	var texts []string
+	if strings.TrimSpace(g.cfg.Topics) != "" {
+		texts = append(texts, g.cfg.Topics)
+	}
+	texts = append(texts, g.cfg.Examples...)
+	vecs, err := g.embed.Embed(ctx, texts)
+	if err != nil {
+		return nil, fmt.Errorf("embedding scope: %w", err)
+	}
+	if len(vecs) == 0 || len(vecs[0]) == 0 {
+		return nil, fmt.Errorf("embedding scope: no embeddings returned")
+	}
+	centroid := make([]float32, len(vecs[0]))
+	for _, v := range vecs {
+		if len(v) != len(centroid) {
+			return nil, fmt.Errorf("embedding scope: mismatched embedding dimensions")
+		}
+		// Normalize each vector so long texts do not dominate the mean.
+		norm := math.Sqrt(dot(v, v))
+		if norm == 0 {
+			continue
+		}
+		for i, x := range v {
+			centroid[i] += float32(float64(x) / norm)
+		}
+	}
+	g.centroid = centroid
+	return centroid, nil
+}
+
+func dot(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
+
+func cosineSimilarity(a, b []float32) float64 {
+	na, nb := math.Sqrt(dot(a, a)), math.Sqrt(dot(b, b))
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot(a, b) / (na * nb)
+}
+
+// refusalText is the answer given to an out-of-scope question.
+func (g *scopeGuard) refusalText() string {
+	if g.cfg.Refusal != "" {
+		return g.cfg.Refusal
+	}
+	if topics := strings.TrimSpace(g.cfg.Topics); topics != "" {
+		return fmt.Sprintf("Sorry, I can only answer questions about %s.", topics)
+	}
+	return "Sorry, that question is outside what I can answer from these documents."
+}
+
+// refuse answers an out-of-scope question with the refusal text and logs
+// it with its reason.
+func (e *engine) refuse(ctx context.Context, question, reason string, pt, ct int, start time.Time, options *queryOptions) *Answer {
+	slog.InfoContext(ctx, "query: refused out-of-scope question", "reason", reason)
+	answer := &Answer{
+		Text:             e.scope.refusalText(),
+		Refusal:          reason,
+		PromptTokens:     pt,
+		CompletionTokens: ct,
+		TotalTokens:      pt + ct,
+		Experiment:       options.experiment,
+	}
+	logEntry := store.QueryLog{
+		Query:            question,
+		Answer:           answer.Text,
+		RetrievalMethod:  "refused",
+		PromptTokens:     pt,
+		CompletionTokens: ct,
+		TotalTokens:      pt + ct,
+		ElapsedMs:        time.Since(start).Milliseconds(),
+		RequestID:        RequestIDFromContext(ctx),
+		RefusalReason:    reason,
+	}
+	if x := options.experiment; x != nil {
+		logEntry.Experiment, logEntry.Arm = x.Experiment, x.Arm
+	}
+	answer.QueryID = e.logQuery(ctx, logEntry)
+	return answer
+}
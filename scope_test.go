@@ -0,0 +1,139 @@
+package goreason
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bbiangul/go-reason/llm"
+)
+
+func TestValidateScope(t *testing.T) {
+	valid := []*ScopeConfig{
+		nil,
+		{Topics: "pumps"},
+		{Method: ScopeEmbedding, Examples: []string{"How do I bleed the pump?"}, MinSimilarity: 0.5},
+	}
+	for _, sc := range valid {
+		if err := validateScope(sc); err != nil {
+			t.Errorf("validateScope(%+v) = %v", sc, err)
+		}
+	}
+	invalid := []*ScopeConfig{
+		{},
+		{Method: ScopeEmbedding},
+		{Topics: "pumps", Method: "regex"},
+		{Topics: "pumps", MinSimilarity: 2},
+	}
+	for _, sc := range invalid {
+		if err := validateScope(sc); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("validateScope(%+v) = %v, want ErrInvalidConfig", sc, err)
+		}
+	}
+}
+
+func TestParseScopeReply(t *testing.T) {
+	tests := map[string]string{
+		"IN":                            "",
+		"in scope, it's about pumps":    "",
+		"OUT: asks about the weather":   "asks about the weather",
+		"out - small talk\nmore detail": "small talk",
+		"OUT":                           "outside the knowledge base's topics",
+	}
+	for reply, want := range tests {
+		if got := parseScopeReply(reply); got != want {
+			t.Errorf("parseScopeReply(%q) = %q, want %q", reply, got, want)
+		}
+	}
+}
+
+func TestQueryRefusesOutOfScope(t *testing.T) {
+	eng, srv, path := faultEngine(t, nil)
+	ctx := context.Background()
+	if _, err := eng.Ingest(ctx, path); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	e := eng.(*engine)
+	e.scope = newScopeGuard(&ScopeConfig{Topics: "pump maintenance"}, e.chatLLM, e.embedLLM)
+	answerChat := srv.ChatFunc
+	srv.ChatFunc = func(prompt string) string {
+		if strings.Contains(prompt, "You screen questions") {
+			if strings.Contains(prompt, "Lisbon") {
+				return "OUT: asks about the weather"
+			}
+			return "IN"
+		}
+		return answerChat(prompt)
+	}
+
+	answer, err := eng.Query(ctx, "What's the weather in Lisbon?")
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if answer.Refusal != "asks about the weather" || len(answer.Sources) != 0 || answer.RetrievalTrace != nil ||
+		answer.Text != "Sorry, I can only answer questions about pump maintenance." {
+		t.Errorf("refusal = %+v", answer)
+	}
+	var reason, method string
+	if err := e.store.DB().QueryRowContext(ctx,
+		"SELECT refusal_reason, retrieval_method FROM query_log WHERE id = ?", answer.QueryID).Scan(&reason, &method); err != nil {
+		t.Fatal(err)
+	}
+	if reason != "asks about the weather" || method != "refused" {
+		t.Errorf("query_log = %q, %q", reason, method)
+	}
+
+	answer, err = eng.Query(ctx, "At what pressure does the relief valve open?")
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if answer.Refusal != "" || len(answer.Sources) == 0 {
+		t.Errorf("in-scope question was refused: %+v", answer)
+	}
+}
+
+// fixedEmbedder embeds known texts to fixed vectors.
+type fixedEmbedder struct {
+	vectors map[string][]float32
+}
+
+func (f fixedEmbedder) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f fixedEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, t := range texts {
+		v, ok := f.vectors[t]
+		if !ok {
+			return nil, errors.New("unknown text " + t)
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+func TestScopeEmbedding(t *testing.T) {
+	embed := fixedEmbedder{vectors: map[string][]float32{
+		"pump maintenance":         {1, 0, 0},
+		"How do I bleed the pump?": {0.8, 0.2, 0},
+		"Relief valve pressure?":   {0.9, 0.1, 0.1},
+		"What's the weather?":      {0, 0, 1},
+	}}
+	g := newScopeGuard(&ScopeConfig{
+		Topics: "pump maintenance", Examples: []string{"How do I bleed the pump?"}, Method: ScopeEmbedding,
+	}, nil, embed)
+	ctx := context.Background()
+
+	if reason, _, _ := g.check(ctx, "Relief valve pressure?"); reason != "" {
+		t.Errorf("in-scope question refused: %s", reason)
+	}
+	if reason, _, _ := g.check(ctx, "What's the weather?"); !strings.Contains(reason, "below 0.30") {
+		t.Errorf("out-of-scope reason = %q", reason)
+	}
+	// A failed embedding lets the question through.
+	if reason, _, _ := g.check(ctx, "unknown"); reason != "" {
+		t.Errorf("failed check refused: %s", reason)
+	}
+}
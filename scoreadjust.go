@@ -0,0 +1,107 @@
+package goreason
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/bbiangul/go-reason/retrieval"
+	"github.com/bbiangul/go-reason/store"
+)
+
+// ScoreAdjuster is an application hook that nudges the fused score of
+// retrieval candidates by domain heuristics. Register adjusters in
+// Config.ScoreAdjusters; see retrieval.ScoreAdjuster.
+type ScoreAdjuster = retrieval.ScoreAdjuster
+
+// ScoreAdjusterFunc adapts a function to ScoreAdjuster.
+type ScoreAdjusterFunc = retrieval.ScoreAdjusterFunc
+
+// ResultMetadata decodes a retrieval result's chunk metadata JSON object.
+// It returns an empty map when there is none.
+func ResultMetadata(r store.RetrievalResult) map[string]string {
+	m := make(map[string]string)
+	if r.ChunkMeta != "" {
+		_ = json.Unmarshal([]byte(r.ChunkMeta), &m)
+	}
+	return m
+}
+
+// MetadataMatchAdjuster returns a ScoreAdjuster that multiplies by factor
+// the score of candidates whose chunk metadata value under one of keys
+// appears in the query as a whole word, case-insensitively: with keys
+// "model" and "clause", a question about the "XR-200" boosts chunks
+// tagged {"model": "XR-200"} over the same text for other models.
+func MetadataMatchAdjuster(factor float64, keys ...string) ScoreAdjuster {
+	var (
+		mu   sync.Mutex
+		last *queryWords
+	)
+	return ScoreAdjusterFunc(func(_ context.Context, query string, c store.RetrievalResult) float64 {
+		if c.ChunkMeta == "" {
+			return 1
+		}
+		// The adjuster sees every candidate of a query in turn, so the
+		// query is prepared once and reused until the next one arrives.
+		mu.Lock()
+		if last == nil || last.query != query {
+			last = newQueryWords(query)
+		}
+		words := last
+		mu.Unlock()
+
+		meta := ResultMetadata(c)
+		for _, k := range keys {
+			if v := strings.TrimSpace(meta[k]); v != "" && words.contains(v) {
+				return factor
+			}
+		}
+		return 1
+	})
+}
+
+// queryWords matches phrases against a query as whole words.
+type queryWords struct {
+	query string
+	lower string
+}
+
+func newQueryWords(query string) *queryWords {
+	return &queryWords{query: query, lower: strings.ToLower(query)}
+}
+
+// contains reports whether phrase occurs in the query, ignoring case,
+// without letters or digits directly before or after it.
+func (q *queryWords) contains(phrase string) bool {
+	return containsWord(q.lower, strings.ToLower(phrase))
+}
+
+// containsWord reports whether phrase occurs in s without letters or
+// digits directly before or after it. Both are compared as given.
+func containsWord(s, phrase string) bool {
+	if phrase == "" {
+		return false
+	}
+	for from := 0; from < len(s); {
+		i := strings.Index(s[from:], phrase)
+		if i < 0 {
+			return false
+		}
+		start, end := from+i, from+i+len(phrase)
+		before, _ := utf8.DecodeLastRuneInString(s[:start])
+		after, _ := utf8.DecodeRuneInString(s[end:])
+		if (start == 0 || !isWordRune(before)) && (end == len(s) || !isWordRune(after)) {
+			return true
+		}
+		_, size := utf8.DecodeRuneInString(s[start:])
+		from = start + size
+	}
+	return false
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
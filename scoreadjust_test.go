@@ -0,0 +1,30 @@
+package goreason
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bbiangul/go-reason/store"
+)
+
+func TestMetadataMatchAdjuster(t *testing.T) {
+	adj := MetadataMatchAdjuster(1.5, "model", "clause")
+	ctx := context.Background()
+	cases := []struct {
+		query string
+		meta  string
+		want  float64
+	}{
+		{"What is the torque for the xr-200?", `{"model":"XR-200"}`, 1.5},
+		{"What is the torque for the XR-2000?", `{"model":"XR-200"}`, 1},
+		{"Is the XR-2000 torque the same as the XR-200's?", `{"model":"XR-200"}`, 1.5},
+		{"What does clause 4.2 require?", `{"clause":"4.2","model":"XR-100"}`, 1.5},
+		{"What does clause 4.2 require?", `{"section":"4.2"}`, 1},
+		{"What does clause 4.2 require?", "", 1},
+	}
+	for _, c := range cases {
+		if got := adj.AdjustScore(ctx, c.query, store.RetrievalResult{ChunkMeta: c.meta}); got != c.want {
+			t.Errorf("AdjustScore(%q, %s) = %v, want %v", c.query, c.meta, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package goreason
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/bbiangul/go-reason/llm"
+	"github.com/bbiangul/go-reason/retrieval"
+	"github.com/bbiangul/go-reason/store"
+)
+
+// validEmbeddingSpace reports whether space is accepted by
+// WithEmbeddingSpace.
+func validEmbeddingSpace(space string) bool {
+	switch space {
+	case "", retrieval.EmbeddingSpaceBoth, retrieval.EmbeddingSpacePrimary, retrieval.EmbeddingSpaceSecondary:
+		return true
+	}
+	return false
+}
+
+// configuredSecondaryModel describes the model that produces secondary
+// chunk vectors under cfg.
+func configuredSecondaryModel(cfg Config) store.EmbeddingModel {
+	return store.EmbeddingModel{
+		Space:    store.SecondaryVectorSpace,
+		Provider: cfg.SecondaryEmbedding.Provider,
+		Model:    cfg.SecondaryEmbedding.Model,
+		Dim:      cfg.SecondaryEmbeddingDim,
+	}
+}
+
+// openSecondaryVectors enables the secondary vector space and records the
+// configured model for it. Unlike the primary space, a model change does
+// not block queries: the secondary space is a recall boost, so its stale
+// vectors are dropped and Reembed backfills them.
+func openSecondaryVectors(ctx context.Context, s *store.Store, cfg Config) error {
+	want := configuredSecondaryModel(cfg)
+	have, err := s.GetEmbeddingModel(ctx, want.Space)
+	if err != nil {
+		return fmt.Errorf("reading secondary embedding model: %w", err)
+	}
+	same := have != nil && have.Model == want.Model && have.Dim == want.Dim
+	if have != nil && !same {
+		slog.WarnContext(ctx, "secondary embedding model changed; dropping secondary vectors to backfill them",
+			"stored_model", have.Model, "stored_dim", have.Dim,
+			"model", want.Model, "dim", want.Dim)
+		if err := s.ResetSecondaryVectors(ctx, want.Dim); err != nil {
+			return err
+		}
+	} else if err := s.EnableSecondaryVectors(ctx, want.Dim); err != nil {
+		return err
+	}
+	if same {
+		return nil
+	}
+	return s.SetEmbeddingModel(ctx, want)
+}
+
+// embedChunksSecondary embeds chunks with the secondary model in batches.
+// Like the sparse leg it is optional, so callers treat the returned error
+// as non-fatal.
+func (e *engine) embedChunksSecondary(ctx context.Context, chunks []store.Chunk, chunkIDs []int64) error {
+	const batchSize = 32
+	var failed int
+
+	for i := 0; i < len(chunks); i += batchSize {
+		end := i + batchSize
+		if end > len(chunks) {
+			end = len(chunks)
+		}
+
+		texts := make([]string, end-i)
+		for j := i; j < end; j++ {
+			prefix := ""
+			if chunks[j].Heading != "" {
+				prefix = chunks[j].Heading + ": "
+			}
+			texts[j-i] = e.embedText(ctx, chunks[j].DocumentID, prefix+chunks[j].Content)
+		}
+
+		embeddings, err := e.secondaryLLM.Embed(ctx, texts)
+		if err != nil {
+			slog.WarnContext(ctx, "secondary embedding batch failed",
+				"batch_start", i, "batch_end", end, "error", err)
+			failed += end - i
+			continue
+		}
+
+		for j, emb := range embeddings {
+			if err := e.store.InsertSecondaryEmbedding(ctx, chunkIDs[i+j], emb); err != nil {
+				slog.WarnContext(ctx, "storing secondary embedding failed",
+					"chunk_id", chunkIDs[i+j], "error", err)
+				failed++
+			}
+		}
+	}
+
+	if failed == len(chunks) && len(chunks) > 0 {
+		return fmt.Errorf("all %d chunks failed secondary embedding", len(chunks))
+	}
+	if failed > 0 {
+		slog.WarnContext(ctx, "some secondary embeddings failed", "failed", failed, "total", len(chunks))
+	}
+	return nil
+}
+
+// backfillSecondary embeds every chunk that has no secondary vector yet,
+// e.g. after the secondary model was added to an existing corpus or
+// changed. Returns the number of chunks embedded.
+func (e *engine) backfillSecondary(ctx context.Context) (int, error) {
+	const pageSize = 256
+	var done int
+	var after int64
+	for {
+		chunks, err := e.store.ChunksWithoutSecondaryEmbedding(ctx, after, pageSize)
+		if err != nil {
+			return done, fmt.Errorf("listing chunks without secondary vectors: %w", err)
+		}
+		if len(chunks) == 0 {
+			return done, nil
+		}
+		ids := make([]int64, len(chunks))
+		for i, c := range chunks {
+			ids[i] = c.ID
+		}
+		if err := e.embedChunksSecondary(ctx, chunks, ids); err != nil {
+			return done, err
+		}
+		done += len(chunks)
+		after = ids[len(ids)-1]
+	}
+}
+
+// startSecondaryBackfill runs backfillSecondary in the background, so a
+// corpus ingested before the secondary model was configured, or whose
+// secondary vectors were dropped when it changed, gets them without a
+// Reembed. Chunks without a secondary vector are still found by the other
+// legs meanwhile.
+func (e *engine) startSecondaryBackfill() {
+	ctx, cancel := context.WithCancel(llm.WithPhase(context.Background(), llm.PhaseIngest))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		n, err := e.backfillSecondary(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.WarnContext(ctx, "secondary: backfill failed (non-fatal, Reembed retries it)", "chunks", n, "error", err)
+			}
+		} else if n > 0 {
+			slog.InfoContext(ctx, "secondary: backfilled secondary vectors", "chunks", n)
+		}
+	}()
+	e.stopSecondaryBackfill = func() {
+		cancel()
+		<-done
+	}
+}
@@ -0,0 +1,114 @@
+package goreason
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bbiangul/go-reason/llm/llmtest"
+	"github.com/bbiangul/go-reason/store"
+)
+
+func TestOpenSecondaryVectors(t *testing.T) {
+	s, err := store.New(filepath.Join(t.TempDir(), "secondary.db"), 4)
+	if err != nil {
+		t.Fatalf("store.New: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	cfg := Config{SecondaryEmbedding: LLMConfig{Provider: "ollama", Model: "bge-m3"}, SecondaryEmbeddingDim: 2}
+	if err := openSecondaryVectors(ctx, s, cfg); err != nil {
+		t.Fatalf("openSecondaryVectors: %v", err)
+	}
+	docID, _ := s.UpsertDocument(ctx, store.Document{Path: "/a.pdf", Filename: "a.pdf", Format: "pdf", ContentHash: "h", ParseMethod: "native", Status: "ready"})
+	ids, _ := s.InsertChunks(ctx, []store.Chunk{{DocumentID: docID, Content: "x", ChunkType: "p"}})
+	if err := s.InsertSecondaryEmbedding(ctx, ids[0], []float32{1, 0}); err != nil {
+		t.Fatalf("InsertSecondaryEmbedding: %v", err)
+	}
+
+	// Same model: vectors are kept.
+	if err := openSecondaryVectors(ctx, s, cfg); err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if pending, _ := s.ChunksWithoutSecondaryEmbedding(ctx, 0, 10); len(pending) != 0 {
+		t.Fatalf("vectors dropped on reopen with the same model")
+	}
+
+	// A new model drops the stale vectors instead of failing.
+	cfg.SecondaryEmbedding.Model = "multilingual-e5"
+	cfg.SecondaryEmbeddingDim = 3
+	if err := openSecondaryVectors(ctx, s, cfg); err != nil {
+		t.Fatalf("reopen with new model: %v", err)
+	}
+	if pending, _ := s.ChunksWithoutSecondaryEmbedding(ctx, 0, 10); len(pending) != 1 {
+		t.Errorf("expected the chunk to need a new secondary vector, got %d pending", len(pending))
+	}
+	m, err := s.GetEmbeddingModel(ctx, store.SecondaryVectorSpace)
+	if err != nil || m == nil || m.Model != "multilingual-e5" || m.Dim != 3 {
+		t.Errorf("recorded model = %+v (%v)", m, err)
+	}
+	if err := s.InsertSecondaryEmbedding(ctx, ids[0], []float32{1, 0, 0}); err != nil {
+		t.Errorf("insert with new dimension: %v", err)
+	}
+
+	if validEmbeddingSpace("english") || !validEmbeddingSpace("") || !validEmbeddingSpace("secondary") {
+		t.Error("validEmbeddingSpace")
+	}
+}
+
+func TestSecondaryBackfillAtStartup(t *testing.T) {
+	srv := llmtest.NewServer(&llmtest.Scenario{})
+	defer srv.Close()
+	srv.ChatFunc = func(prompt string) string {
+		if strings.Contains(prompt, "entity extraction engine") {
+			return `{"language": "English", "entities": []}`
+		}
+		return `{"relationships": []}`
+	}
+
+	dir := t.TempDir()
+	cfg := faultConfig(dir, srv)
+	path := filepath.Join(dir, "pump.txt")
+	if err := os.WriteFile(path, []byte("Pump maintenance.\n\nThe relief valve opens at 10 bar.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// Ingest before the secondary model is configured.
+	eng, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if _, err := eng.Ingest(ctx, path); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	eng.Close()
+
+	cfg.SecondaryEmbedding = LLMConfig{Provider: "custom", Model: "fake-multilingual", BaseURL: srv.URL}
+	cfg.SecondaryEmbeddingDim = 4
+	eng, err = New(cfg)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer eng.Close()
+	s := eng.(*engine).store
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		pending, err := s.ChunksWithoutSecondaryEmbedding(ctx, 0, 10)
+		if err != nil {
+			t.Fatalf("ChunksWithoutSecondaryEmbedding: %v", err)
+		}
+		if len(pending) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d chunks still without a secondary vector after opening", len(pending))
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
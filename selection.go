@@ -0,0 +1,370 @@
+package goreason
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/bbiangul/go-reason/parser"
+)
+
+// PageRange is an inclusive range of 1-based page numbers.
+type PageRange struct {
+	First int `json:"first"`
+	Last  int `json:"last"`
+}
+
+func (r PageRange) String() string {
+	if r.First == r.Last {
+		return strconv.Itoa(r.First)
+	}
+	return fmt.Sprintf("%d-%d", r.First, r.Last)
+}
+
+// ParsePageRanges parses a comma-separated list of pages and page ranges
+// such as "5-8,12".
+func ParsePageRanges(s string) ([]PageRange, error) {
+	var ranges []PageRange
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		first, last, isRange := strings.Cut(part, "-")
+		a, err := strconv.Atoi(strings.TrimSpace(first))
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid page range %q", ErrInvalidConfig, part)
+		}
+		b := a
+		if isRange {
+			if b, err = strconv.Atoi(strings.TrimSpace(last)); err != nil {
+				return nil, fmt.Errorf("%w: invalid page range %q", ErrInvalidConfig, part)
+			}
+		}
+		r := PageRange{First: a, Last: b}
+		if err := r.validate(); err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+func (r PageRange) validate() error {
+	if r.First < 1 || r.Last < r.First {
+		return fmt.Errorf("%w: invalid page range %d-%d", ErrInvalidConfig, r.First, r.Last)
+	}
+	return nil
+}
+
+// WithPageRange ingests only the content on pages first to last
+// (inclusive, 1-based). Repeat it to select several ranges. Formats without
+// page numbers have no content on any page.
+//
+// The selection is recorded in the document's metadata, and Update and
+// CommitReparse apply it again. Re-ingesting with Ingest replaces it.
+func WithPageRange(first, last int) IngestOption {
+	return func(o *ingestOptions) {
+		o.selection.Pages = append(o.selection.Pages, PageRange{First: first, Last: last})
+	}
+}
+
+// WithSections ingests only the sections whose heading matches one of
+// headings, with their subsections. A heading matches when it equals the
+// given text or starts with it followed by a space or punctuation, ignoring
+// case: "5" matches "5 Installation" and "5.2 Mounting" but not "50 Parts".
+// Recorded and re-applied like WithPageRange.
+func WithSections(headings ...string) IngestOption {
+	return func(o *ingestOptions) { o.selection.Sections = append(o.selection.Sections, headings...) }
+}
+
+// WithoutSections leaves out the sections whose heading matches one of
+// headings, with their subsections (e.g. "Appendix"). It applies after
+// WithSections and WithPageRange, and is recorded and re-applied like them.
+func WithoutSections(headings ...string) IngestOption {
+	return func(o *ingestOptions) {
+		o.selection.ExcludeSections = append(o.selection.ExcludeSections, headings...)
+	}
+}
+
+// Document metadata keys recording an ingest selection.
+const (
+	metaSelectedPages    = "selected_pages"
+	metaSelectedSections = "selected_sections"
+	metaExcludedSections = "excluded_sections"
+)
+
+// ingestSelection is the part of a document an ingest keeps.
+type ingestSelection struct {
+	Pages           []PageRange
+	Sections        []string
+	ExcludeSections []string
+}
+
+func (s ingestSelection) empty() bool {
+	return len(s.Pages) == 0 && len(s.Sections) == 0 && len(s.ExcludeSections) == 0
+}
+
+func (s ingestSelection) validate() error {
+	for _, r := range s.Pages {
+		if err := r.validate(); err != nil {
+			return err
+		}
+	}
+	for _, h := range append(append([]string(nil), s.Sections...), s.ExcludeSections...) {
+		if strings.TrimSpace(h) == "" {
+			return fmt.Errorf("%w: empty section heading", ErrInvalidConfig)
+		}
+	}
+	return nil
+}
+
+// record returns a copy of metadata with the selection recorded in it,
+// replacing any earlier one.
+func (s ingestSelection) record(metadata map[string]string) map[string]string {
+	if s.empty() && metadata[metaSelectedPages] == "" && metadata[metaSelectedSections] == "" && metadata[metaExcludedSections] == "" {
+		return metadata
+	}
+	out := make(map[string]string, len(metadata)+3)
+	for k, v := range metadata {
+		out[k] = v
+	}
+	delete(out, metaSelectedPages)
+	delete(out, metaSelectedSections)
+	delete(out, metaExcludedSections)
+
+	if len(s.Pages) > 0 {
+		parts := make([]string, len(s.Pages))
+		for i, r := range s.Pages {
+			parts[i] = r.String()
+		}
+		out[metaSelectedPages] = strings.Join(parts, ",")
+	}
+	if len(s.Sections) > 0 {
+		data, _ := json.Marshal(s.Sections)
+		out[metaSelectedSections] = string(data)
+	}
+	if len(s.ExcludeSections) > 0 {
+		data, _ := json.Marshal(s.ExcludeSections)
+		out[metaExcludedSections] = string(data)
+	}
+	return out
+}
+
+// sameSelection reports whether the document metadata JSON stored records
+// the same selection as metadata.
+func sameSelection(stored string, metadata map[string]string) bool {
+	var m map[string]string
+	if stored != "" {
+		_ = json.Unmarshal([]byte(stored), &m)
+	}
+	for _, k := range []string{metaSelectedPages, metaSelectedSections, metaExcludedSections} {
+		if m[k] != metadata[k] {
+			return false
+		}
+	}
+	return true
+}
+
+// selectionFromMetadata reads the selection recorded by record.
+func selectionFromMetadata(metadata map[string]string) (ingestSelection, error) {
+	var s ingestSelection
+	if v := metadata[metaSelectedPages]; v != "" {
+		pages, err := ParsePageRanges(v)
+		if err != nil {
+			return s, err
+		}
+		s.Pages = pages
+	}
+	if v := metadata[metaSelectedSections]; v != "" {
+		if err := json.Unmarshal([]byte(v), &s.Sections); err != nil {
+			return s, fmt.Errorf("decoding %s: %w", metaSelectedSections, err)
+		}
+	}
+	if v := metadata[metaExcludedSections]; v != "" {
+		if err := json.Unmarshal([]byte(v), &s.ExcludeSections); err != nil {
+			return s, fmt.Errorf("decoding %s: %w", metaExcludedSections, err)
+		}
+	}
+	return s, nil
+}
+
+// apply returns a copy of parsed holding only the selected sections and
+// the images that belong to them.
+func (s ingestSelection) apply(parsed *parser.ParseResult) *parser.ParseResult {
+	if s.empty() {
+		return parsed
+	}
+	// Section images refer to top-level sections by index, so the index
+	// each kept section came from is tracked alongside it.
+	sections := parsed.Sections
+	from := make([]int, len(sections))
+	for i := range from {
+		from[i] = i
+	}
+	remap := func(kept []int) {
+		for i, k := range kept {
+			kept[i] = from[k]
+		}
+		from = kept
+	}
+
+	if len(s.Sections) > 0 {
+		var kept []int
+		sections, kept = selectSections(sections, s.Sections, true)
+		remap(kept)
+	}
+	if len(s.Pages) > 0 {
+		var out []parser.Section
+		var kept []int
+		for i, sec := range sections {
+			if sec, ok := s.keepPages(sec); ok {
+				out = append(out, sec)
+				kept = append(kept, i)
+			}
+		}
+		sections = out
+		remap(kept)
+	}
+	if len(s.ExcludeSections) > 0 {
+		var kept []int
+		sections, kept = selectSections(sections, s.ExcludeSections, false)
+		remap(kept)
+	}
+
+	result := *parsed
+	result.Sections = sections
+	newIndex := make(map[int]int, len(from))
+	pages := make(map[int]bool)
+	for i, sec := range sections {
+		newIndex[from[i]] = i
+		collectPages(sec, pages)
+	}
+	result.Images = nil
+	for _, img := range parsed.Images {
+		if img.PageNumber > 0 {
+			if pages[img.PageNumber] {
+				result.Images = append(result.Images, img)
+			}
+		} else if i, ok := newIndex[img.SectionIndex]; ok {
+			img.SectionIndex = i
+			result.Images = append(result.Images, img)
+		}
+	}
+	return &result
+}
+
+// keepPages trims sec and its children to the selected pages. A section
+// off the selected pages is kept, without its own content, when some of
+// its children are on them.
+func (s ingestSelection) keepPages(sec parser.Section) (parser.Section, bool) {
+	var children []parser.Section
+	for _, c := range sec.Children {
+		if c, ok := s.keepPages(c); ok {
+			children = append(children, c)
+		}
+	}
+	sec.Children = children
+	if s.onPages(sec.PageNumber) {
+		return sec, true
+	}
+	if len(children) > 0 {
+		sec.Content = ""
+		return sec, true
+	}
+	return sec, false
+}
+
+func (s ingestSelection) onPages(page int) bool {
+	for _, r := range s.Pages {
+		if page >= r.First && page <= r.Last {
+			return true
+		}
+	}
+	return false
+}
+
+// markSections reports which sections lie under a heading matching one of
+// headings: the matching section and the sections after it with a deeper
+// heading level (the parsers emit most documents as a flat list).
+func markSections(sections []parser.Section, headings []string) []bool {
+	marks := make([]bool, len(sections))
+	inside, level := false, 0
+	for i, sec := range sections {
+		if inside && (level == 0 || sec.Level == 0 || sec.Level <= level) {
+			inside = false
+		}
+		if !inside && headingMatches(sec.Heading, headings) {
+			inside, level = true, sec.Level
+		}
+		marks[i] = inside
+	}
+	return marks
+}
+
+// selectSections keeps the sections under a heading matching one of
+// headings (include) or drops them (exclude), descending into children,
+// and returns the indexes in sections of the ones kept. With include, a
+// matching section keeps all of its children and an ancestor of a deeper
+// match is kept without its own content.
+func selectSections(sections []parser.Section, headings []string, include bool) ([]parser.Section, []int) {
+	marks := markSections(sections, headings)
+	var out []parser.Section
+	var kept []int
+	for i, sec := range sections {
+		switch {
+		case marks[i] && include:
+		case marks[i]:
+			continue // excluded with its subtree
+		case include:
+			children, _ := selectSections(sec.Children, headings, true)
+			if len(children) == 0 {
+				continue
+			}
+			sec.Content, sec.Children = "", children
+		default:
+			sec.Children, _ = selectSections(sec.Children, headings, false)
+		}
+		out = append(out, sec)
+		kept = append(kept, i)
+	}
+	return out, kept
+}
+
+// headingMatches reports whether heading equals one of headings or starts
+// with it followed by a space or punctuation, ignoring case.
+func headingMatches(heading string, headings []string) bool {
+	h := strings.ToLower(strings.TrimSpace(heading))
+	for _, want := range headings {
+		w := strings.ToLower(strings.TrimSpace(want))
+		if w == "" || !strings.HasPrefix(h, w) {
+			continue
+		}
+		rest := h[len(w):]
+		if rest == "" {
+			return true
+		}
+		if r := []rune(rest)[0]; !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func collectPages(sec parser.Section, pages map[int]bool) {
+	if sec.PageNumber > 0 {
+		pages[sec.PageNumber] = true
+	}
+	for _, c := range sec.Children {
+		collectPages(c, pages)
+	}
+}
+
+// auditParams adds the selection to ingest audit parameters.
+func (s ingestSelection) auditParams(params map[string]string) {
+	for k, v := range s.record(nil) {
+		params[k] = v
+	}
+}
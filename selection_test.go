@@ -0,0 +1,135 @@
+package goreason
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/bbiangul/go-reason/parser"
+)
+
+func TestParsePageRanges(t *testing.T) {
+	got, err := ParsePageRanges(" 5-8, 12 ,")
+	if err != nil {
+		t.Fatalf("ParsePageRanges: %v", err)
+	}
+	if want := []PageRange{{5, 8}, {12, 12}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for _, bad := range []string{"8-5", "0-3", "x", "3-"} {
+		if _, err := ParsePageRanges(bad); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("ParsePageRanges(%q) = %v, want ErrInvalidConfig", bad, err)
+		}
+	}
+}
+
+func TestSelectionApply(t *testing.T) {
+	manual := &parser.ParseResult{
+		Sections: []parser.Section{
+			{Heading: "4 Transport", Level: 1, PageNumber: 3, Content: "transport"},
+			{Heading: "5 Installation", Level: 1, PageNumber: 5, Content: "install"},
+			{Heading: "5.1 Mounting", Level: 2, PageNumber: 6, Content: "mount"},
+			{Heading: "50 Parts list", Level: 1, PageNumber: 9, Content: "parts"},
+			{Heading: "Appendix A", Level: 1, PageNumber: 12, Content: "appendix"},
+			{Heading: "A.1 Tables", Level: 2, PageNumber: 13, Content: "tables"},
+		},
+		Images: []parser.ExtractedImage{{PageNumber: 3}, {PageNumber: 6}, {SectionIndex: 4}},
+	}
+	headings := func(r *parser.ParseResult) []string {
+		var hs []string
+		for _, s := range r.Sections {
+			hs = append(hs, s.Heading)
+		}
+		return hs
+	}
+
+	got := ingestSelection{Sections: []string{"5"}}.apply(manual)
+	if want := []string{"5 Installation", "5.1 Mounting"}; !reflect.DeepEqual(headings(got), want) {
+		t.Errorf("sections = %v, want %v", headings(got), want)
+	}
+	if len(got.Images) != 1 || got.Images[0].PageNumber != 6 {
+		t.Errorf("images = %+v, want the page 6 image", got.Images)
+	}
+
+	got = ingestSelection{ExcludeSections: []string{"appendix"}}.apply(manual)
+	if len(got.Sections) != 4 || len(got.Images) != 2 {
+		t.Errorf("without appendix: %v, %d images", headings(got), len(got.Images))
+	}
+
+	got = ingestSelection{Pages: []PageRange{{5, 9}}, ExcludeSections: []string{"50"}}.apply(manual)
+	if want := []string{"5 Installation", "5.1 Mounting"}; !reflect.DeepEqual(headings(got), want) {
+		t.Errorf("pages 5-9 without 50 = %v, want %v", headings(got), want)
+	}
+
+	// Section images follow their section to its new index.
+	got = ingestSelection{Sections: []string{"Appendix A"}}.apply(manual)
+	if len(got.Images) != 1 || got.Images[0].SectionIndex != 0 {
+		t.Errorf("images = %+v, want the appendix image at index 0", got.Images)
+	}
+
+	// A nested match keeps its ancestor without the ancestor's own text.
+	nested := &parser.ParseResult{Sections: []parser.Section{{
+		Heading: "Chapter 2", Level: 1, Content: "intro",
+		Children: []parser.Section{
+			{Heading: "2.1 Safety", Level: 2, Content: "safety"},
+			{Heading: "2.2 Wiring", Level: 2, Content: "wiring"},
+		},
+	}}}
+	got = ingestSelection{Sections: []string{"2.2"}}.apply(nested)
+	if len(got.Sections) != 1 || got.Sections[0].Content != "" || len(got.Sections[0].Children) != 1 ||
+		got.Sections[0].Children[0].Heading != "2.2 Wiring" {
+		t.Errorf("nested selection = %+v", got.Sections)
+	}
+	if len(nested.Sections[0].Children) != 2 {
+		t.Error("apply modified the parse it was given")
+	}
+}
+
+func TestIngestSelection(t *testing.T) {
+	eng, _, path := faultEngine(t, nil)
+	ctx := context.Background()
+	s := eng.(*engine).store
+
+	// Text files have no pages, so a page range leaves nothing.
+	if _, err := eng.Ingest(ctx, path, WithPageRange(1, 3)); !errors.Is(err, ErrEmptySelection) {
+		t.Fatalf("Ingest with page range = %v, want ErrEmptySelection", err)
+	}
+	if _, err := eng.Ingest(ctx, path, WithPageRange(3, 1)); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("Ingest with reversed range = %v, want ErrInvalidConfig", err)
+	}
+
+	docID, err := eng.Ingest(ctx, path, WithSections("pump.txt"), WithMetadata(map[string]string{"owner": "ops"}))
+	if err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	meta := func() map[string]string {
+		doc, err := s.GetDocument(ctx, docID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var m map[string]string
+		json.Unmarshal([]byte(doc.Metadata), &m)
+		return m
+	}
+	if m := meta(); m[metaSelectedSections] != `["pump.txt"]` || m["owner"] != "ops" {
+		t.Errorf("metadata = %v", m)
+	}
+
+	// Update keeps the metadata and applies the recorded selection.
+	data, _ := os.ReadFile(path)
+	os.WriteFile(path, append(data, []byte("\nCheck the seal.\n")...), 0o644)
+	if changed, err := eng.Update(ctx, path); err != nil || !changed {
+		t.Fatalf("Update = %v, %v", changed, err)
+	}
+	if m := meta(); m[metaSelectedSections] != `["pump.txt"]` || m["owner"] != "ops" {
+		t.Errorf("metadata after Update = %v", m)
+	}
+
+	// A new selection re-ingests the unchanged file.
+	if _, err := eng.Ingest(ctx, path, WithoutSections("pump")); !errors.Is(err, ErrEmptySelection) {
+		t.Errorf("Ingest excluding everything = %v, want ErrEmptySelection", err)
+	}
+}
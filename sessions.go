@@ -0,0 +1,141 @@
+package goreason
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// WithIngestSession ingests the document for one session only, e.g. a file
+// a user uploads to ask about in a conversation. It is searched only by
+// queries with WithSession(session), is left out of ListDocuments,
+// UpdateAll, the knowledge graph, and document summaries, and is deleted
+// Config.SessionTTLMinutes after the session's last ingest or query.
+func WithIngestSession(session string) IngestOption {
+	return func(o *ingestOptions) { o.session = session }
+}
+
+// WithSession searches the documents ingested with WithIngestSession for
+// session alongside the corpus (or the WithCollection scope), and keeps
+// them from expiring. Other sessions' documents are never searched.
+func WithSession(session string) QueryOption {
+	return func(o *queryOptions) { o.session = session }
+}
+
+// sessionPath is the document path of a file ingested for a session.
+func sessionPath(session, path string) string {
+	return "session://" + session + "/" + path
+}
+
+// sessionTTL is how long session documents outlive their last use.
+func (e *engine) sessionTTL() time.Duration {
+	if e.cfg.SessionTTLMinutes <= 0 {
+		return time.Hour
+	}
+	return time.Duration(e.cfg.SessionTTLMinutes) * time.Minute
+}
+
+// searchScope is the documents a query searches: only include when it is
+// non-empty, and never exclude.
+type searchScope struct {
+	include []int64
+	exclude []int64
+}
+
+// sessionScope narrows scope to the query's session: the session's own
+// documents join an include list, and every other session's documents are
+// excluded. Using a session extends its documents' lifetime.
+func (e *engine) sessionScope(ctx context.Context, options *queryOptions, scope *searchScope) error {
+	sessions, err := e.store.SessionDocuments(ctx)
+	if err != nil {
+		return fmt.Errorf("loading session documents: %w", err)
+	}
+	for id, session := range sessions {
+		switch {
+		case session != options.session:
+			scope.exclude = append(scope.exclude, id)
+		case scope.include != nil:
+			scope.include = append(scope.include, id)
+		}
+	}
+	if options.session != "" && e.writable() == nil {
+		expiresAt := time.Now().Add(e.sessionTTL())
+		if err := e.writeStore().ExtendSession(ctx, options.session, expiresAt); err != nil {
+			slog.WarnContext(ctx, "session: extending expiry failed (non-fatal)", "session", options.session, "error", err)
+		}
+	}
+	return nil
+}
+
+// DeleteSession removes a session's documents.
+func (e *engine) DeleteSession(ctx context.Context, session string) (int, error) {
+	if err := e.writable(); err != nil {
+		return 0, err
+	}
+	if session == "" {
+		return 0, fmt.Errorf("%w: session is required", ErrInvalidConfig)
+	}
+	sessions, err := e.store.SessionDocuments(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("loading session documents: %w", err)
+	}
+	var ids []int64
+	for id, s := range sessions {
+		if s == session {
+			ids = append(ids, id)
+		}
+	}
+	return len(ids), e.deleteDocuments(ctx, ids)
+}
+
+// expireSessions deletes the session documents past their expiry.
+func (e *engine) expireSessions(ctx context.Context) (int, error) {
+	ids, err := e.store.ExpiredSessionDocuments(ctx, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("loading expired session documents: %w", err)
+	}
+	return len(ids), e.deleteDocuments(ctx, ids)
+}
+
+// deleteDocuments deletes documents through Delete, so each is audited.
+// An archive member already deleted with its archive is a no-op.
+func (e *engine) deleteDocuments(ctx context.Context, ids []int64) error {
+	for _, id := range ids {
+		if err := e.Delete(ctx, id); err != nil {
+			return fmt.Errorf("deleting document %d: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// startSessionExpiry deletes expired session documents every interval
+// until Close.
+func (e *engine) startSessionExpiry(interval time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				n, err := e.expireSessions(ctx)
+				if err != nil {
+					if ctx.Err() == nil {
+						slog.WarnContext(ctx, "session: expiring documents failed (non-fatal)", "error", err)
+					}
+				} else if n > 0 {
+					slog.InfoContext(ctx, "session: expired documents deleted", "documents", n)
+				}
+			}
+		}
+	}()
+	e.stopSessionExpiry = func() {
+		cancel()
+		<-done
+	}
+}
@@ -0,0 +1,111 @@
+package goreason
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSessionDocuments(t *testing.T) {
+	eng, _, pump := faultEngine(t, nil, skipGraph)
+	ctx := context.Background()
+
+	write := func(name, text string) string {
+		path := filepath.Join(filepath.Dir(pump), name)
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	manual, err := eng.Ingest(ctx, write("manual.txt", "The pump is serviced yearly.\n"))
+	if err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	upload, err := eng.Ingest(ctx, write("upload.txt", "The relief valve opens at 10 bar.\n"), WithIngestSession("s1"))
+	if err != nil {
+		t.Fatalf("Ingest with session: %v", err)
+	}
+
+	documents := func(opts ...QueryOption) map[int64]bool {
+		t.Helper()
+		results, _, err := eng.Retrieve(ctx, "relief valve pump", opts...)
+		if err != nil {
+			t.Fatalf("Retrieve: %v", err)
+		}
+		docs := make(map[int64]bool)
+		for _, r := range results {
+			docs[r.DocumentID] = true
+		}
+		return docs
+	}
+	if docs := documents(); docs[upload] || !docs[manual] {
+		t.Errorf("without a session, searched %v; want only the manual %d", docs, manual)
+	}
+	if docs := documents(WithSession("s2")); docs[upload] {
+		t.Errorf("another session searched the upload: %v", docs)
+	}
+	if docs := documents(WithSession("s1")); !docs[upload] || !docs[manual] {
+		t.Errorf("session s1 searched %v; want the upload %d and the manual %d", docs, upload, manual)
+	}
+
+	list, err := eng.ListDocuments(ctx)
+	if err != nil {
+		t.Fatalf("ListDocuments: %v", err)
+	}
+	if len(list) != 1 || list[0].ID != manual {
+		t.Errorf("ListDocuments = %+v, want only the manual", list)
+	}
+	doc, err := eng.Document(ctx, upload)
+	if err != nil {
+		t.Fatalf("Document: %v", err)
+	}
+	if doc.SessionID != "s1" || doc.ExpiresAt == "" {
+		t.Errorf("upload has session %q expiring %q", doc.SessionID, doc.ExpiresAt)
+	}
+
+	// Expiry removes the upload, and only after its TTL.
+	e := eng.(*engine)
+	if n, err := e.expireSessions(ctx); err != nil || n != 0 {
+		t.Fatalf("expireSessions before expiry = %d, %v", n, err)
+	}
+	if _, err := e.store.DB().ExecContext(ctx,
+		"UPDATE documents SET expires_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now', '-1 minute') WHERE id = ?", upload); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := e.expireSessions(ctx); err != nil || n != 1 {
+		t.Fatalf("expireSessions = %d, %v; want the upload deleted", n, err)
+	}
+	if _, err := eng.Document(ctx, upload); err == nil {
+		t.Error("expired upload still stored")
+	}
+
+	// DeleteSession removes a session's documents before they expire.
+	if _, err := eng.Ingest(ctx, write("notes.txt", "Notes.\n"), WithIngestSession("s3")); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := eng.DeleteSession(ctx, "s3"); err != nil || n != 1 {
+		t.Errorf("DeleteSession = %d, %v; want 1 document", n, err)
+	}
+}
+
+func TestSessionGlossary(t *testing.T) {
+	eng, _, path := faultEngine(t, nil, skipGraph)
+	ctx := context.Background()
+
+	if err := os.WriteFile(path, []byte("The Pressure Relief Valve (PRV) opens at 10 bar.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := eng.Ingest(ctx, path, WithIngestSession("s1")); err != nil {
+		t.Fatalf("Ingest with session: %v", err)
+	}
+
+	// Neither the shared glossary nor another session's answers see the
+	// upload's definition.
+	if entries, err := eng.Glossary(ctx, 0); err != nil || len(entries) != 0 {
+		t.Errorf("Glossary = %+v, %v; want no entries", entries, err)
+	}
+	if terms := eng.(*engine).glossaryFor(ctx, "When does the PRV open?", nil); len(terms) != 0 {
+		t.Errorf("session s2 sees glossary terms %+v", terms)
+	}
+}
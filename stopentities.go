@@ -0,0 +1,41 @@
+package goreason
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/bbiangul/go-reason/store"
+)
+
+// stopEntityMinDocuments is the corpus size below which
+// Config.StopEntityThreshold is not applied: in a handful of documents
+// every entity spans a large fraction of the corpus.
+const stopEntityMinDocuments = 5
+
+// refreshStopEntities recomputes which entities are stop entities, after
+// the graph or the corpus changed. A stale list only makes graph search
+// noisier, so failures are logged rather than returned.
+func (e *engine) refreshStopEntities(ctx context.Context) {
+	n, err := e.store.UpdateStopEntities(ctx, store.StopEntityRule{
+		Threshold:    e.cfg.StopEntityThreshold,
+		MinDocuments: stopEntityMinDocuments,
+		Stop:         e.cfg.StopEntities,
+		Keep:         e.cfg.KeepEntities,
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "updating stop entities failed (non-fatal)", "error", err)
+		return
+	}
+	if n > 0 {
+		slog.DebugContext(ctx, "stop entities updated", "count", n)
+	}
+}
+
+// EntityStats returns the entities linked to the most documents, with
+// whether each is a stop entity.
+func (e *engine) EntityStats(ctx context.Context, limit int) ([]store.EntityStat, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	return e.store.EntityStats(ctx, limit)
+}
@@ -0,0 +1,69 @@
+package store
+
+import "context"
+
+// StaleQuery is a logged answer citing chunks that no longer exist, such as
+// chunks a re-ingest replaced.
+type StaleQuery struct {
+	QueryID       int64   `json:"query_id"`
+	Query         string  `json:"query"`
+	CreatedAt     string  `json:"created_at"`
+	MissingChunks []int64 `json:"missing_chunks"`
+}
+
+// DocumentEntityNames returns the names of the entities linked to a
+// document's chunks, in name order.
+func (s *Store) DocumentEntityNames(ctx context.Context, docID int64) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT DISTINCT e.name
+		FROM entities e
+		JOIN entity_chunks ec ON ec.entity_id = e.id
+		JOIN chunks c ON c.id = ec.chunk_id
+		WHERE c.document_id = ?
+		ORDER BY e.name`, docID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// StaleQueries returns the logged answers whose sources include chunks of
+// the document that have since been deleted, oldest first.
+func (s *Store) StaleQueries(ctx context.Context, docID int64) ([]StaleQuery, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT q.id, q.query, q.created_at, l.chunk_id
+		FROM query_sources l
+		JOIN query_log q ON q.id = l.query_id
+		WHERE l.document_id = ? AND l.live_chunk_id IS NULL
+		ORDER BY q.id, l.chunk_id`, docID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stale []StaleQuery
+	for rows.Next() {
+		var q StaleQuery
+		var id int64
+		if err := rows.Scan(&q.QueryID, &q.Query, &q.CreatedAt, &id); err != nil {
+			return nil, err
+		}
+		if n := len(stale); n > 0 && stale[n-1].QueryID == q.QueryID {
+			stale[n-1].MissingChunks = append(stale[n-1].MissingChunks, id)
+			continue
+		}
+		q.MissingChunks = []int64{id}
+		stale = append(stale, q)
+	}
+	return stale, rows.Err()
+}
@@ -0,0 +1,184 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// ContradictionCandidate is a pair of relationships from two documents
+// that give the same entity different targets for the same relation type,
+// where each document gives only one: e.g. "tracker -[has_ip_address]->
+// 10.0.0.5" in one manual and "-> 10.0.0.9" in another. Candidates are
+// verified before they are recorded as contradictions.
+type ContradictionCandidate struct {
+	EntityID     int64
+	Entity       string
+	RelationType string
+	ChunkA       int64
+	TargetA      string
+	ChunkB       int64
+	TargetB      string
+}
+
+// Contradiction is a fact two documents state differently. Its chunks are
+// flagged at retrieval so the answer can note the conflict.
+type Contradiction struct {
+	ID          int64  `json:"id"`
+	EntityID    int64  `json:"entity_id"`
+	Entity      string `json:"entity"`
+	Attribute   string `json:"attribute"`
+	ChunkA      int64  `json:"chunk_a"`
+	DocumentA   int64  `json:"document_a"`
+	FilenameA   string `json:"filename_a"`
+	ValueA      string `json:"value_a"`
+	ChunkB      int64  `json:"chunk_b"`
+	DocumentB   int64  `json:"document_b"`
+	FilenameB   string `json:"filename_b"`
+	ValueB      string `json:"value_b"`
+	Explanation string `json:"explanation,omitempty"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// Note describes the contradiction from the side of chunk chunkID, e.g.
+// `ip address of tracker is "10.0.0.5" here but "10.0.0.9" in b.pdf`.
+func (c Contradiction) Note(chunkID int64) string {
+	here, there, other := c.ValueA, c.ValueB, c.FilenameB
+	if chunkID == c.ChunkB {
+		here, there, other = c.ValueB, c.ValueA, c.FilenameA
+	}
+	return fmt.Sprintf("%s of %s is %q here but %q in %s", c.Attribute, c.Entity, here, there, other)
+}
+
+// ContradictionCandidates returns the candidate contradictions of the
+// graph, ordered by entity and relation type.
+func (s *Store) ContradictionCandidates(ctx context.Context) ([]ContradictionCandidate, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		WITH facts AS (
+			SELECT r.source_entity_id AS entity_id, r.relation_type, r.target_entity_id AS target_id,
+				c.document_id, MIN(c.id) AS chunk_id
+			FROM relationships r JOIN chunks c ON c.id = r.source_chunk_id
+			WHERE r.source_entity_id != r.target_entity_id
+			GROUP BY r.source_entity_id, r.relation_type, r.target_entity_id, c.document_id
+		),
+		single AS (
+			SELECT entity_id, relation_type, document_id, MIN(target_id) AS target_id, MIN(chunk_id) AS chunk_id
+			FROM facts
+			GROUP BY entity_id, relation_type, document_id
+			HAVING COUNT(*) = 1
+		)
+		SELECT a.entity_id, e.name, a.relation_type, a.chunk_id, ta.name, b.chunk_id, tb.name
+		FROM single a
+		JOIN single b ON b.entity_id = a.entity_id AND b.relation_type = a.relation_type
+			AND b.document_id > a.document_id AND b.target_id != a.target_id
+		JOIN entities e ON e.id = a.entity_id
+		JOIN entities ta ON ta.id = a.target_id
+		JOIN entities tb ON tb.id = b.target_id
+		ORDER BY a.entity_id, a.relation_type, a.document_id, b.document_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []ContradictionCandidate
+	for rows.Next() {
+		var c ContradictionCandidate
+		if err := rows.Scan(&c.EntityID, &c.Entity, &c.RelationType, &c.ChunkA, &c.TargetA, &c.ChunkB, &c.TargetB); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// ReplaceContradictions replaces the recorded contradictions with list.
+func (s *Store) ReplaceContradictions(ctx context.Context, list []Contradiction) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM contradictions"); err != nil {
+			return err
+		}
+		for _, c := range list {
+			if _, err := tx.ExecContext(ctx, `
+				INSERT INTO contradictions (entity_id, attribute, chunk_a, value_a, chunk_b, value_b, explanation)
+				VALUES (?, ?, ?, ?, ?, ?, ?)
+			`, c.EntityID, c.Attribute, c.ChunkA, c.ValueA, c.ChunkB, c.ValueB, c.Explanation); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// contradictionColumns selects a Contradiction with its entity name and
+// the documents of its chunks.
+const contradictionColumns = `SELECT x.id, x.entity_id, e.name, x.attribute,
+		x.chunk_a, ca.document_id, da.filename, x.value_a,
+		x.chunk_b, cb.document_id, db.filename, x.value_b,
+		COALESCE(x.explanation, ''), x.created_at
+	FROM contradictions x
+	JOIN entities e ON e.id = x.entity_id
+	JOIN chunks ca ON ca.id = x.chunk_a
+	JOIN documents da ON da.id = ca.document_id
+	JOIN chunks cb ON cb.id = x.chunk_b
+	JOIN documents db ON db.id = cb.document_id`
+
+// ListContradictions returns the recorded contradictions, by entity.
+func (s *Store) ListContradictions(ctx context.Context) ([]Contradiction, error) {
+	return s.queryContradictions(ctx, contradictionColumns+" ORDER BY e.name, x.attribute, x.id")
+}
+
+// ContradictionsForChunks returns the recorded contradictions involving
+// the given chunks, keyed by chunk ID; a contradiction is listed under
+// both of its chunks when both are given.
+func (s *Store) ContradictionsForChunks(ctx context.Context, chunkIDs []int64) (map[int64][]Contradiction, error) {
+	if len(chunkIDs) == 0 {
+		return nil, nil
+	}
+	in := "(?" + strings.Repeat(",?", len(chunkIDs)-1) + ")"
+	args := make([]interface{}, 0, 2*len(chunkIDs))
+	for _, id := range chunkIDs {
+		args = append(args, id)
+	}
+	args = append(args, args...)
+	list, err := s.queryContradictions(ctx,
+		contradictionColumns+" WHERE x.chunk_a IN "+in+" OR x.chunk_b IN "+in+" ORDER BY x.id", args...)
+	if err != nil {
+		return nil, err
+	}
+	wanted := make(map[int64]bool, len(chunkIDs))
+	for _, id := range chunkIDs {
+		wanted[id] = true
+	}
+	out := make(map[int64][]Contradiction)
+	for _, c := range list {
+		if wanted[c.ChunkA] {
+			out[c.ChunkA] = append(out[c.ChunkA], c)
+		}
+		if wanted[c.ChunkB] {
+			out[c.ChunkB] = append(out[c.ChunkB], c)
+		}
+	}
+	return out, nil
+}
+
+func (s *Store) queryContradictions(ctx context.Context, query string, args ...interface{}) ([]Contradiction, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Contradiction
+	for rows.Next() {
+		var c Contradiction
+		if err := rows.Scan(&c.ID, &c.EntityID, &c.Entity, &c.Attribute,
+			&c.ChunkA, &c.DocumentA, &c.FilenameA, &c.ValueA,
+			&c.ChunkB, &c.DocumentB, &c.FilenameB, &c.ValueB,
+			&c.Explanation, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
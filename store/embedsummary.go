@@ -0,0 +1,45 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// EmbedSummary returns the summary stored for the text with content hash
+// hash of document docID, or "" when there is none or it is encrypted
+// with a key that is not configured.
+func (s *Store) EmbedSummary(ctx context.Context, docID int64, hash string) (string, error) {
+	var summary string
+	err := s.db.QueryRowContext(ctx,
+		"SELECT summary FROM embed_summaries WHERE document_id = ? AND content_hash = ?",
+		docID, hash).Scan(&summary)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	summary, err = s.openText(summary)
+	if errors.Is(err, ErrNoContentKey) {
+		return "", nil
+	}
+	return summary, err
+}
+
+// PutEmbedSummary stores the summary a text of document docID with content
+// hash hash is embedded by, encrypted like the document's chunks. The
+// summaries are kept across re-ingests and re-embeds of the document, so
+// unchanged text is not summarised again, and deleted with it.
+func (s *Store) PutEmbedSummary(ctx context.Context, docID int64, hash, summary string) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		sealed, err := s.contentSealer(ctx, tx).text(docID, summary)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			"INSERT OR REPLACE INTO embed_summaries (document_id, content_hash, summary) VALUES (?, ?, ?)",
+			docID, hash, sealed)
+		return err
+	})
+}
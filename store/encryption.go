@@ -0,0 +1,349 @@
+package store
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNoContentKey is returned when encrypted chunk content or image data
+// is read without the key that encrypted it.
+var ErrNoContentKey = errors.New("no key for encrypted content")
+
+// encryptedPrefix starts chunk content and image data encrypted by a
+// ContentCipher: "enc1:<key id>:" then, for content, the base64 of nonce
+// and ciphertext, and for image data the raw bytes.
+const encryptedPrefix = "enc1:"
+
+// ContentCipher encrypts chunk content and image data at rest with
+// AES-GCM, using the key of the collection the chunk's document is in.
+// Each ciphertext names its key by a fingerprint, so documents keep
+// decrypting after they move to another collection as long as the key is
+// still configured.
+type ContentCipher struct {
+	byCollection map[string]*contentKey
+	byID         map[string]*contentKey
+}
+
+type contentKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// NewContentCipher returns a cipher with one AES key (16, 24 or 32 bytes)
+// per collection; the key under "" encrypts documents outside any
+// collection. Documents of collections without a key are stored in
+// plaintext.
+func NewContentCipher(keys map[string][]byte) (*ContentCipher, error) {
+	c := &ContentCipher{byCollection: make(map[string]*contentKey), byID: make(map[string]*contentKey)}
+	for collection, key := range keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("key for collection %q: %w", collection, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("key for collection %q: %w", collection, err)
+		}
+		sum := sha256.Sum256(key)
+		k := &contentKey{id: hex.EncodeToString(sum[:4]), aead: aead}
+		c.byCollection[collection] = k
+		c.byID[k.id] = k
+	}
+	return c, nil
+}
+
+// seal encrypts data with the key of collection, returning the prefixed
+// ciphertext bytes (before any base64), or nil when the collection has
+// no key.
+func (c *ContentCipher) seal(collection string, data []byte) []byte {
+	if c == nil {
+		return nil
+	}
+	k, ok := c.byCollection[collection]
+	if !ok {
+		return nil
+	}
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		panic(err) // crypto/rand does not fail on supported platforms
+	}
+	return k.aead.Seal(nonce, nonce, data, nil)
+}
+
+// open decrypts nonce-prefixed ciphertext made by key id.
+func (c *ContentCipher) open(id string, sealed []byte) ([]byte, error) {
+	var k *contentKey
+	if c != nil {
+		k = c.byID[id]
+	}
+	if k == nil {
+		return nil, fmt.Errorf("%w (key %s)", ErrNoContentKey, id)
+	}
+	n := k.aead.NonceSize()
+	if len(sealed) < n {
+		return nil, fmt.Errorf("decrypting content: ciphertext too short")
+	}
+	plain, err := k.aead.Open(nil, sealed[:n], sealed[n:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting content: %w", err)
+	}
+	return plain, nil
+}
+
+// sealText encrypts chunk content for a document of collection.
+func (c *ContentCipher) sealText(collection, text string) string {
+	sealed := c.seal(collection, []byte(text))
+	if sealed == nil {
+		return text
+	}
+	return encryptedPrefix + c.byCollection[collection].id + ":" + base64.StdEncoding.EncodeToString(sealed)
+}
+
+// sealBytes encrypts image data for a document of collection.
+func (c *ContentCipher) sealBytes(collection string, data []byte) []byte {
+	sealed := c.seal(collection, data)
+	if sealed == nil {
+		return data
+	}
+	return append([]byte(encryptedPrefix+c.byCollection[collection].id+":"), sealed...)
+}
+
+// splitEncrypted returns the key ID and payload of encrypted data, or ok
+// false for plaintext.
+func splitEncrypted(s string) (id, payload string, ok bool) {
+	if !strings.HasPrefix(s, encryptedPrefix) {
+		return "", "", false
+	}
+	id, payload, ok = strings.Cut(s[len(encryptedPrefix):], ":")
+	if !ok || len(id) != 8 {
+		return "", "", false
+	}
+	return id, payload, true
+}
+
+// SetContentCipher makes the store encrypt the content and images of
+// chunks it inserts from now on with c, and decrypt them on read. A nil
+// cipher stores plaintext; encrypted chunks then fail to read with
+// ErrNoContentKey, or are left out of search results.
+func (s *Store) SetContentCipher(c *ContentCipher) {
+	s.cipher = c
+}
+
+// ContentCipher returns the store's content cipher, or nil.
+func (s *Store) ContentCipher() *ContentCipher {
+	return s.cipher
+}
+
+// openText decrypts chunk content read from the database. Plaintext is
+// returned unchanged.
+func (s *Store) openText(text string) (string, error) {
+	id, payload, ok := splitEncrypted(text)
+	if !ok {
+		return text, nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", fmt.Errorf("decoding encrypted content: %w", err)
+	}
+	plain, err := s.cipher.open(id, sealed)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// openBytes decrypts image data read from the database.
+func (s *Store) openBytes(data []byte) ([]byte, error) {
+	if len(data) < len(encryptedPrefix)+9 || string(data[:len(encryptedPrefix)]) != encryptedPrefix {
+		return data, nil
+	}
+	id, _, ok := splitEncrypted(string(data[:len(encryptedPrefix)+9]))
+	if !ok {
+		return data, nil
+	}
+	return s.cipher.open(id, data[len(encryptedPrefix)+9:])
+}
+
+// openImage decrypts an image's caption and data as read from the
+// database into img.
+func (s *Store) openImage(img *ChunkImage, caption string) error {
+	var err error
+	if img.Caption, err = s.openText(caption); err != nil {
+		return fmt.Errorf("image %d: %w", img.ID, err)
+	}
+	if img.Data, err = s.openBytes(img.Data); err != nil {
+		return fmt.Errorf("image %d: %w", img.ID, err)
+	}
+	return nil
+}
+
+// openResults decrypts the content of search results in place, dropping
+// results whose key is not configured: another collection's chunks are
+// not served as ciphertext.
+func (s *Store) openResults(results []RetrievalResult) ([]RetrievalResult, error) {
+	out := results[:0]
+	for _, r := range results {
+		content, err := s.openText(r.Content)
+		if errors.Is(err, ErrNoContentKey) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("chunk %d: %w", r.ChunkID, err)
+		}
+		r.Content = content
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+// indexSealed adds chunk id to chunks_fts_sealed when its content was
+// stored encrypted, so keyword search still finds it: chunks_fts indexes
+// the chunks table, which only holds the ciphertext. The index terms are
+// not encrypted.
+func indexSealed(ctx context.Context, tx *sql.Tx, id int64, stored, content, heading string) error {
+	if stored == content {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx,
+		"INSERT INTO chunks_fts_sealed (rowid, content, heading) VALUES (?, ?, ?)", id, content, heading)
+	return err
+}
+
+// reindexSealed updates the chunks_fts_sealed row of a kept chunk whose
+// heading may have changed. Chunks stored in plaintext are left alone.
+func reindexSealed(ctx context.Context, tx *sql.Tx, id int64, content, heading string) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM chunks_fts_sealed WHERE rowid = ?", id); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO chunks_fts_sealed (rowid, content, heading)
+		SELECT id, ?, ? FROM chunks WHERE id = ? AND content LIKE 'enc1:%'`, content, heading, id)
+	return err
+}
+
+// IndexSealedChunks adds the encrypted chunks missing from the keyword
+// index to it, decrypting them with the store's cipher, and returns how
+// many it added. Chunks encrypted before the index existed need this
+// once; chunks whose key is missing are skipped.
+func (s *Store) IndexSealedChunks(ctx context.Context) (int, error) {
+	if s.cipher == nil {
+		return 0, nil
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, content, heading FROM chunks
+		WHERE content LIKE 'enc1:%' AND id NOT IN (SELECT rowid FROM chunks_fts_sealed)`)
+	if err != nil {
+		return 0, err
+	}
+	type sealedChunk struct {
+		id               int64
+		content, heading string
+	}
+	var pending []sealedChunk
+	for rows.Next() {
+		var c sealedChunk
+		var heading sql.NullString
+		if err := rows.Scan(&c.id, &c.content, &heading); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		c.heading = heading.String
+		pending = append(pending, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		for _, c := range pending {
+			content, err := s.openText(c.content)
+			if errors.Is(err, ErrNoContentKey) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("chunk %d: %w", c.id, err)
+			}
+			if err := indexSealed(ctx, tx, c.id, c.content, content, c.heading); err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// rowQuerier is a *sql.DB or *sql.Tx.
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// contentSealer returns a sealer for one insert through q, or nil when the
+// store has no cipher.
+func (s *Store) contentSealer(ctx context.Context, q rowQuerier) *sealer {
+	if s.cipher == nil {
+		return nil
+	}
+	return &sealer{ctx: ctx, q: q, c: s.cipher, collections: make(map[int64]string)}
+}
+
+// sealer encrypts the chunks and images of one insert with the keys of
+// their documents' collections, looking each document's collection up once.
+type sealer struct {
+	ctx         context.Context
+	q           rowQuerier
+	c           *ContentCipher
+	collections map[int64]string
+}
+
+func (sl *sealer) collection(docID int64) (string, error) {
+	if col, ok := sl.collections[docID]; ok {
+		return col, nil
+	}
+	var col sql.NullString
+	err := sl.q.QueryRowContext(sl.ctx, "SELECT collection FROM documents WHERE id = ?", docID).Scan(&col)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("looking up collection of document %d: %w", docID, err)
+	}
+	sl.collections[docID] = col.String
+	return col.String, nil
+}
+
+// text encrypts chunk content of document docID. A nil sealer returns it
+// unchanged.
+func (sl *sealer) text(docID int64, content string) (string, error) {
+	if sl == nil || content == "" {
+		return content, nil
+	}
+	col, err := sl.collection(docID)
+	if err != nil {
+		return "", err
+	}
+	return sl.c.sealText(col, content), nil
+}
+
+// bytes encrypts image data of document docID.
+func (sl *sealer) bytes(docID int64, data []byte) ([]byte, error) {
+	if sl == nil {
+		return data, nil
+	}
+	col, err := sl.collection(docID)
+	if err != nil {
+		return nil, err
+	}
+	return sl.c.sealBytes(col, data), nil
+}
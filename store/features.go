@@ -0,0 +1,38 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// SQLiteFeatures reports the SQLite extensions of this build.
+type SQLiteFeatures struct {
+	SQLiteVersion string `json:"sqlite_version"`
+	VecVersion    string `json:"vec_version,omitempty"` // "" without sqlite-vec
+	FTS5          bool   `json:"fts5"`                  // needs the sqlite_fts5 build tag
+}
+
+// Features probes an in-memory database for the extensions the store
+// needs: sqlite-vec for the vector tables and FTS5 for keyword search.
+// Without them New fails while creating the schema.
+func Features(ctx context.Context) (SQLiteFeatures, error) {
+	var f SQLiteFeatures
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		return f, fmt.Errorf("opening database: %w", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1) // every statement on the same in-memory database
+
+	if err := db.QueryRowContext(ctx, "SELECT sqlite_version()").Scan(&f.SQLiteVersion); err != nil {
+		return f, fmt.Errorf("opening database: %w", err)
+	}
+	if err := db.QueryRowContext(ctx, "SELECT vec_version()").Scan(&f.VecVersion); err != nil {
+		f.VecVersion = ""
+	}
+	if _, err := db.ExecContext(ctx, "CREATE VIRTUAL TABLE fts_probe USING fts5(content)"); err == nil {
+		f.FTS5 = true
+	}
+	return f, nil
+}
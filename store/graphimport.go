@@ -0,0 +1,279 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Conflict policies for ImportGraph: how an imported entity or
+// relationship that is already in the graph is merged with it.
+const (
+	// ConflictKeepExisting keeps the stored description and weight and
+	// only fills in fields the stored row lacks.
+	ConflictKeepExisting = "keep_existing"
+	// ConflictPreferImported replaces the stored description and weight
+	// with the imported ones.
+	ConflictPreferImported = "prefer_imported"
+	// ConflictMerge appends a differing imported description to the stored
+	// one and keeps the larger weight.
+	ConflictMerge = "merge"
+)
+
+// GraphImport is a batch of externally curated entities and relationships.
+// Entities are matched to the graph by name and type, then by name (or
+// English name) alone when exactly one entity has it; an entity's type
+// never changes. Relationship endpoints are resolved the same way, among
+// the batch's entities first. Imported rows are marked with Source, which
+// keeps them through Compact even though no chunk mentions them.
+type GraphImport struct {
+	Source        string
+	OnConflict    string // default ConflictKeepExisting
+	Entities      []Entity
+	Relationships []ImportedRelationship
+}
+
+// ImportedRelationship is a relationship between entities given by name.
+type ImportedRelationship struct {
+	Source       string
+	Target       string
+	RelationType string
+	Description  string
+	Weight       float64
+}
+
+// GraphImportStats counts what ImportGraph created and merged.
+// Relationships are merged only with earlier imported ones: extracted
+// relationships are per chunk, and a curated copy outlives the chunk.
+type GraphImportStats struct {
+	EntitiesCreated      int      `json:"entities_created"`
+	EntitiesMerged       int      `json:"entities_merged"`
+	RelationshipsCreated int      `json:"relationships_created"`
+	RelationshipsMerged  int      `json:"relationships_merged"`
+	Unresolved           []string `json:"unresolved,omitempty"` // relationships with an unknown endpoint
+}
+
+// ImportGraph merges g into the knowledge graph in one transaction.
+// Entities whose description changes lose their description vector, so
+// the next entity embedding pass re-embeds them.
+func (s *Store) ImportGraph(ctx context.Context, g GraphImport) (*GraphImportStats, error) {
+	policy := g.OnConflict
+	if policy == "" {
+		policy = ConflictKeepExisting
+	}
+	stats := &GraphImportStats{}
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		ids := make(map[string]int64, len(g.Entities))
+		for _, e := range g.Entities {
+			id, created, err := importEntity(ctx, tx, e, g.Source, policy)
+			if err != nil {
+				return fmt.Errorf("importing entity %q: %w", e.Name, err)
+			}
+			if created {
+				stats.EntitiesCreated++
+			} else {
+				stats.EntitiesMerged++
+			}
+			ids[e.Name] = id
+		}
+
+		for _, r := range g.Relationships {
+			src, err := resolveImportEndpoint(ctx, tx, ids, r.Source)
+			if err != nil {
+				return err
+			}
+			tgt, err := resolveImportEndpoint(ctx, tx, ids, r.Target)
+			if err != nil {
+				return err
+			}
+			if src == 0 || tgt == 0 {
+				stats.Unresolved = append(stats.Unresolved,
+					fmt.Sprintf("%s -[%s]-> %s", r.Source, r.RelationType, r.Target))
+				continue
+			}
+			created, err := importRelationship(ctx, tx, src, tgt, r, g.Source, policy)
+			if err != nil {
+				return fmt.Errorf("importing relationship %s -[%s]-> %s: %w", r.Source, r.RelationType, r.Target, err)
+			}
+			if created {
+				stats.RelationshipsCreated++
+			} else {
+				stats.RelationshipsMerged++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// matchEntity finds the stored entity an imported one refers to, or
+// returns 0.
+func matchEntity(ctx context.Context, tx *sql.Tx, name, entityType string) (int64, error) {
+	var id int64
+	err := tx.QueryRowContext(ctx,
+		"SELECT id FROM entities WHERE name = ? AND entity_type = ?", name, entityType).Scan(&id)
+	if err == nil || !errors.Is(err, sql.ErrNoRows) {
+		return id, err
+	}
+	return matchEntityName(ctx, tx, name)
+}
+
+// matchEntityName returns the one entity named name (or with English name
+// name), or 0 when there is none or the name is ambiguous.
+func matchEntityName(ctx context.Context, tx *sql.Tx, name string) (int64, error) {
+	rows, err := tx.QueryContext(ctx,
+		"SELECT id FROM entities WHERE name = ? OR name_en = ? LIMIT 2", name, name)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil || len(ids) != 1 {
+		return 0, err
+	}
+	return ids[0], nil
+}
+
+// importEntity inserts e or merges it into its match, and reports whether
+// it was inserted.
+func importEntity(ctx context.Context, tx *sql.Tx, e Entity, source, policy string) (int64, bool, error) {
+	id, err := matchEntity(ctx, tx, e.Name, e.EntityType)
+	if err != nil {
+		return 0, false, err
+	}
+	if id == 0 {
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO entities (name, entity_type, description, name_en, import_source)
+			VALUES (?, ?, ?, NULLIF(?, ''), ?)
+		`, e.Name, e.EntityType, e.Description, e.NameEN, source)
+		if err != nil {
+			return 0, false, err
+		}
+		id, err = res.LastInsertId()
+		return id, true, err
+	}
+
+	var desc, nameEN string
+	if err := tx.QueryRowContext(ctx,
+		"SELECT COALESCE(description, ''), COALESCE(name_en, '') FROM entities WHERE id = ?", id).Scan(&desc, &nameEN); err != nil {
+		return 0, false, err
+	}
+	newDesc, newNameEN := desc, nameEN
+	switch policy {
+	case ConflictPreferImported:
+		if e.Description != "" {
+			newDesc = e.Description
+		}
+		if e.NameEN != "" {
+			newNameEN = e.NameEN
+		}
+	case ConflictMerge:
+		newDesc = mergeDescriptions(desc, e.Description)
+		if newNameEN == "" {
+			newNameEN = e.NameEN
+		}
+	default:
+		if newDesc == "" {
+			newDesc = e.Description
+		}
+		if newNameEN == "" {
+			newNameEN = e.NameEN
+		}
+	}
+
+	sourceExpr := "COALESCE(import_source, ?)"
+	if policy == ConflictPreferImported {
+		sourceExpr = "?"
+	}
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE entities SET description = ?, name_en = NULLIF(?, ''), import_source = "+sourceExpr+" WHERE id = ?",
+		newDesc, newNameEN, source, id); err != nil {
+		return 0, false, err
+	}
+	if newDesc != desc {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM vec_entities WHERE entity_id = ?", id); err != nil {
+			return 0, false, err
+		}
+	}
+	return id, false, nil
+}
+
+// mergeDescriptions appends added to existing unless one already
+// contains the other.
+func mergeDescriptions(existing, added string) string {
+	switch {
+	case added == "" || strings.Contains(existing, added):
+		return existing
+	case existing == "" || strings.Contains(added, existing):
+		return added
+	}
+	return existing + "\n" + added
+}
+
+// resolveImportEndpoint returns the ID of the relationship endpoint name:
+// an entity of the batch, else the one graph entity with that name, else 0.
+func resolveImportEndpoint(ctx context.Context, tx *sql.Tx, batch map[string]int64, name string) (int64, error) {
+	if id, ok := batch[name]; ok {
+		return id, nil
+	}
+	id, err := matchEntityName(ctx, tx, name)
+	if err != nil {
+		return 0, fmt.Errorf("resolving entity %q: %w", name, err)
+	}
+	return id, nil
+}
+
+// importRelationship inserts r or merges it into an earlier imported
+// relationship with the same endpoints and type, and reports whether it
+// was inserted.
+func importRelationship(ctx context.Context, tx *sql.Tx, src, tgt int64, r ImportedRelationship, source, policy string) (bool, error) {
+	var id int64
+	var desc string
+	var weight float64
+	err := tx.QueryRowContext(ctx, `
+		SELECT id, COALESCE(description, ''), COALESCE(weight, 1.0) FROM relationships
+		WHERE source_entity_id = ? AND target_entity_id = ? AND relation_type = ? AND import_source IS NOT NULL
+		ORDER BY id LIMIT 1`, src, tgt, r.RelationType).Scan(&id, &desc, &weight)
+	if errors.Is(err, sql.ErrNoRows) {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO relationships (source_entity_id, target_entity_id, relation_type, weight, description, import_source)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, src, tgt, r.RelationType, r.Weight, r.Description, source)
+		return err == nil, err
+	}
+	if err != nil {
+		return false, err
+	}
+
+	switch policy {
+	case ConflictPreferImported:
+		if r.Description != "" {
+			desc = r.Description
+		}
+		weight = r.Weight
+	case ConflictMerge:
+		desc = mergeDescriptions(desc, r.Description)
+		if r.Weight > weight {
+			weight = r.Weight
+		}
+	default:
+		if desc == "" {
+			desc = r.Description
+		}
+	}
+	_, err = tx.ExecContext(ctx,
+		"UPDATE relationships SET description = ?, weight = ? WHERE id = ?", desc, weight, id)
+	return false, err
+}
@@ -0,0 +1,112 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// The interfaces below split Store into the parts the retrieval, graph,
+// and query layers depend on, so those layers can run on other backends
+// (a KV store for metadata, a graph database for entities) or on in-memory
+// fakes in unit tests. Store implements all of them. Schema management,
+// migrations, and maintenance (Compact, DBStats) stay on Store.
+
+// DocumentStore holds document records and their corpus-level metadata.
+type DocumentStore interface {
+	UpsertDocument(ctx context.Context, doc Document) (int64, error)
+	GetDocument(ctx context.Context, id int64) (*Document, error)
+	GetDocumentByPath(ctx context.Context, path string) (*Document, error)
+	ListDocuments(ctx context.Context) ([]Document, error)
+	ListChildDocuments(ctx context.Context, parentID int64) ([]Document, error)
+	UpdateDocumentStatus(ctx context.Context, id int64, status string) error
+	UpdateDocumentLanguage(ctx context.Context, docID int64, language string) error
+	DeleteDocument(ctx context.Context, id int64) error
+	DocumentSummaries(ctx context.Context) ([]DocumentSummary, error)
+	DocumentIDsInCollection(ctx context.Context, collection string) ([]int64, error)
+	GetCorpusLanguages(ctx context.Context) ([]string, error)
+	DocumentImportance(ctx context.Context) (map[int64]float64, error)
+}
+
+// ChunkStore holds chunks, their annotations and usage, and the full-text
+// and numeric indexes over them.
+type ChunkStore interface {
+	InsertChunks(ctx context.Context, chunks []Chunk) ([]int64, error)
+	GetChunk(ctx context.Context, id int64) (*Chunk, error)
+	GetChunksByDocument(ctx context.Context, docID int64) ([]Chunk, error)
+	FTSSearch(ctx context.Context, query string, limit int) ([]RetrievalResult, error)
+	FTSSearchWeighted(ctx context.Context, query string, limit int, headingWeight float64) ([]RetrievalResult, error)
+	AnnotationsForChunks(ctx context.Context, chunkIDs []int64) (map[int64][]ChunkAnnotation, error)
+	ContradictionsForChunks(ctx context.Context, chunkIDs []int64) (map[int64][]Contradiction, error)
+	ChunkContentHashes(ctx context.Context, chunkIDs []int64) (map[int64]string, error)
+	ChunkUsages(ctx context.Context, chunkIDs []int64, halfLife time.Duration) (map[int64]ChunkUsage, error)
+	NumericSearch(ctx context.Context, ranges []NumericRange, limit int) ([]RetrievalResult, error)
+	ChunksInRanges(ctx context.Context, chunkIDs []int64, ranges []NumericRange) (map[int64]bool, error)
+}
+
+// VectorIndex holds the dense, sparse, secondary, image, and entity
+// embeddings and answers nearest-neighbour searches over them.
+type VectorIndex interface {
+	InsertEmbedding(ctx context.Context, chunkID int64, embedding []float32) error
+	VectorSearch(ctx context.Context, queryEmbedding []float32, k int) ([]RetrievalResult, error)
+	SecondaryVectorSearch(ctx context.Context, queryEmbedding []float32, k int) ([]RetrievalResult, error)
+	ImageVectorSearch(ctx context.Context, queryEmbedding []float32, k int) ([]RetrievalResult, error)
+	SparseSearch(ctx context.Context, indices []int, weights []float32, k int) ([]RetrievalResult, error)
+	EntityVectorSearch(ctx context.Context, queryEmbedding []float32, k int) ([]EntityMatch, error)
+}
+
+// GraphStore holds the knowledge graph: entities, their links to chunks,
+// relationships, and communities.
+type GraphStore interface {
+	UpsertEntity(ctx context.Context, e Entity) (int64, error)
+	UpsertEntityAndLink(ctx context.Context, e Entity, chunkID int64) (int64, error)
+	LinkEntityChunk(ctx context.Context, entityID, chunkID int64) error
+	InsertRelationship(ctx context.Context, r Relationship) (int64, error)
+	GetEntitiesByNames(ctx context.Context, names []string) ([]Entity, error)
+	SearchEntitiesByTerms(ctx context.Context, terms []string, limit int) ([]Entity, error)
+	SearchEntitiesByNameEN(ctx context.Context, terms []string, limit int) ([]Entity, error)
+	GetRelatedEntities(ctx context.Context, entityIDs []int64, limit int) ([]Entity, error)
+	AllEntities(ctx context.Context) ([]Entity, error)
+	AllRelationships(ctx context.Context) ([]Relationship, error)
+	ChunkIDsForEntities(ctx context.Context, entityIDs []int64) ([]int64, error)
+	GraphSearch(ctx context.Context, entityIDs []int64, limit int) ([]RetrievalResult, error)
+	ExpandEntityPaths(ctx context.Context, seeds []Entity, maxDepth, maxEntities int) (map[int64]EntityPath, error)
+	MultiHopGraphSearch(ctx context.Context, paths map[int64]EntityPath, limit int) ([]RetrievalResult, error)
+	HasGraph(ctx context.Context) (bool, error)
+	StopEntityIDs(ctx context.Context) (map[int64]bool, error)
+	InsertCommunity(ctx context.Context, c Community) (int64, error)
+	GetCommunities(ctx context.Context, level int) ([]Community, error)
+	StaleCommunities(ctx context.Context) ([]Community, error)
+	SetCommunitySummary(ctx context.Context, id int64, summary string) error
+	ClearCommunities(ctx context.Context) error
+	ImportGraph(ctx context.Context, g GraphImport) (*GraphImportStats, error)
+	ContradictionCandidates(ctx context.Context) ([]ContradictionCandidate, error)
+	ReplaceContradictions(ctx context.Context, list []Contradiction) error
+	ListContradictions(ctx context.Context) ([]Contradiction, error)
+}
+
+// TranslationStore caches cross-language query translations.
+type TranslationStore interface {
+	PutQueryTranslation(ctx context.Context, t QueryTranslation) error
+	GetQueryTranslations(ctx context.Context, questionHash string, languages []string) (map[string]QueryTranslation, error)
+}
+
+// QueryLogStore records queries, their feedback, the chunk usage they
+// produce, and the audit trail.
+type QueryLogStore interface {
+	InsertQueryLog(ctx context.Context, q QueryLog) (int64, error)
+	GetQueryLog(ctx context.Context, id int64) (*QueryLogEntry, error)
+	SetQueryFeedback(ctx context.Context, queryID int64, rating int, comment string) error
+	RecordChunkUsage(ctx context.Context, retrieved, cited []int64, halfLife time.Duration) error
+	ExperimentStats(ctx context.Context, experiment string) ([]ArmStats, error)
+	InsertAuditEntry(ctx context.Context, a AuditEntry) error
+	ListAuditEntries(ctx context.Context, f AuditFilter) ([]AuditEntry, error)
+}
+
+var (
+	_ DocumentStore    = (*Store)(nil)
+	_ ChunkStore       = (*Store)(nil)
+	_ VectorIndex      = (*Store)(nil)
+	_ GraphStore       = (*Store)(nil)
+	_ TranslationStore = (*Store)(nil)
+	_ QueryLogStore    = (*Store)(nil)
+)
@@ -0,0 +1,133 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// KeyFact is one value of a document's key-facts record: a field of the
+// schema the document was matched to, such as a contract's "parties" or a
+// manual's "rated_voltage". Fields with several values have one KeyFact
+// per value, ordered by Position.
+type KeyFact struct {
+	ID         int64  `json:"id"`
+	DocumentID int64  `json:"document_id"`
+	Schema     string `json:"schema"`
+	Field      string `json:"field"`
+	Value      string `json:"value"`
+	Position   int    `json:"position"`
+	Filename   string `json:"filename,omitempty"`
+}
+
+// KeyFactFilter selects key facts. Zero fields match everything.
+type KeyFactFilter struct {
+	DocumentID int64
+	Schema     string
+	Field      string
+	Query      string // case-insensitive substring of the value
+	Limit      int    // 0 = no limit
+}
+
+// ReplaceKeyFacts replaces a document's key facts. Their values are
+// encrypted like chunk content when the store has a content cipher.
+func (s *Store) ReplaceKeyFacts(ctx context.Context, docID int64, facts []KeyFact) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM key_facts WHERE document_id = ?", docID); err != nil {
+			return err
+		}
+		if len(facts) == 0 {
+			return nil
+		}
+		stmt, err := tx.PrepareContext(ctx, `
+			INSERT INTO key_facts (document_id, schema_name, field, value, position)
+			VALUES (?, ?, ?, ?, ?)`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		seal := s.contentSealer(ctx, tx)
+		for _, f := range facts {
+			value, err := seal.text(docID, f.Value)
+			if err != nil {
+				return err
+			}
+			if _, err := stmt.ExecContext(ctx, docID, f.Schema, f.Field, value, f.Position); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ListKeyFacts returns the key facts matching f, ordered by document,
+// field, and position. Encrypted values whose key is not configured are
+// left out.
+func (s *Store) ListKeyFacts(ctx context.Context, f KeyFactFilter) ([]KeyFact, error) {
+	query := `SELECT k.id, k.document_id, k.schema_name, k.field, k.value, k.position, d.filename
+		FROM key_facts k JOIN documents d ON d.id = k.document_id`
+	var where []string
+	var args []interface{}
+	if f.DocumentID != 0 {
+		where = append(where, "k.document_id = ?")
+		args = append(args, f.DocumentID)
+	}
+	if f.Schema != "" {
+		where = append(where, "k.schema_name = ?")
+		args = append(args, f.Schema)
+	}
+	if f.Field != "" {
+		where = append(where, "k.field = ?")
+		args = append(args, f.Field)
+	}
+	// Encrypted values are matched after they are decrypted, and the
+	// limit is then applied here too.
+	if f.Query != "" {
+		where = append(where, `(k.value LIKE ? ESCAPE '\' OR k.value LIKE 'enc1:%')`)
+		args = append(args, "%"+likeEscaper.Replace(f.Query)+"%")
+	}
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY k.document_id, k.field, k.position"
+	if f.Limit > 0 && f.Query == "" {
+		query += " LIMIT ?"
+		args = append(args, f.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []KeyFact
+	needle := strings.ToLower(f.Query)
+	for rows.Next() {
+		var k KeyFact
+		if err := rows.Scan(&k.ID, &k.DocumentID, &k.Schema, &k.Field, &k.Value, &k.Position, &k.Filename); err != nil {
+			return nil, err
+		}
+		value, err := s.openText(k.Value)
+		if errors.Is(err, ErrNoContentKey) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("key fact %d: %w", k.ID, err)
+		}
+		if value != k.Value && !strings.Contains(strings.ToLower(value), needle) {
+			continue
+		}
+		k.Value = value
+		out = append(out, k)
+		if f.Limit > 0 && len(out) == f.Limit {
+			break
+		}
+	}
+	return out, rows.Err()
+}
+
+// likeEscaper escapes LIKE wildcards for patterns using ESCAPE '\'.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
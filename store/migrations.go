@@ -85,6 +85,594 @@ var migrations = []migration{
 			return nil
 		},
 	},
+	{
+		version:     5,
+		description: "add sparse_chunks table for learned sparse embeddings",
+		apply: func(tx *sql.Tx) error {
+			stmts := []string{
+				`CREATE TABLE IF NOT EXISTS sparse_chunks (
+					chunk_id INTEGER NOT NULL REFERENCES chunks(id) ON DELETE CASCADE,
+					term_id INTEGER NOT NULL,
+					weight REAL NOT NULL,
+					PRIMARY KEY (chunk_id, term_id)
+				)`,
+				"CREATE INDEX IF NOT EXISTS idx_sparse_chunks_term ON sparse_chunks(term_id)",
+			}
+			for _, stmt := range stmts {
+				if _, err := tx.Exec(stmt); err != nil {
+					slog.Debug("migration 5: statement may already be applied", "sql", stmt, "error", err)
+				}
+			}
+			return nil
+		},
+	},
+	{
+		version:     6,
+		description: "add document summary and keywords",
+		apply: func(tx *sql.Tx) error {
+			stmts := []string{
+				"ALTER TABLE documents ADD COLUMN summary TEXT",
+				"ALTER TABLE documents ADD COLUMN keywords JSON",
+			}
+			for _, stmt := range stmts {
+				if _, err := tx.Exec(stmt); err != nil {
+					slog.Debug("migration 6: statement may already be applied", "sql", stmt, "error", err)
+				}
+			}
+			return nil
+		},
+	},
+	{
+		version:     7,
+		description: "add source byte offsets to chunks",
+		apply: func(tx *sql.Tx) error {
+			stmts := []string{
+				"ALTER TABLE chunks ADD COLUMN start_offset INTEGER DEFAULT 0",
+				"ALTER TABLE chunks ADD COLUMN end_offset INTEGER DEFAULT 0",
+			}
+			for _, stmt := range stmts {
+				if _, err := tx.Exec(stmt); err != nil {
+					slog.Debug("migration 7: statement may already be applied", "sql", stmt, "error", err)
+				}
+			}
+			return nil
+		},
+	},
+	{
+		version:     8,
+		description: "add embedding_models table for embedding drift detection",
+		apply: func(tx *sql.Tx) error {
+			_, err := tx.Exec(`CREATE TABLE IF NOT EXISTS embedding_models (
+				space TEXT PRIMARY KEY,
+				provider TEXT,
+				model TEXT NOT NULL,
+				dim INTEGER NOT NULL,
+				recorded_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)`)
+			return err
+		},
+	},
+	{
+		version:     9,
+		description: "add documents.collection for per-collection retrieval presets",
+		apply: func(tx *sql.Tx) error {
+			stmts := []string{
+				"ALTER TABLE documents ADD COLUMN collection TEXT DEFAULT ''",
+				"CREATE INDEX IF NOT EXISTS idx_documents_collection ON documents(collection)",
+			}
+			for _, stmt := range stmts {
+				if _, err := tx.Exec(stmt); err != nil {
+					slog.Debug("migration 9: statement may already be applied", "sql", stmt, "error", err)
+				}
+			}
+			return nil
+		},
+	},
+	{
+		version:     10,
+		description: "add audit_log table for mutation history",
+		apply: func(tx *sql.Tx) error {
+			stmts := []string{
+				`CREATE TABLE IF NOT EXISTS audit_log (
+					id INTEGER PRIMARY KEY,
+					operation TEXT NOT NULL,
+					actor TEXT,
+					params JSON,
+					outcome TEXT NOT NULL,
+					error TEXT,
+					document_id INTEGER,
+					duration_ms INTEGER DEFAULT 0,
+					created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+				)`,
+				"CREATE INDEX IF NOT EXISTS idx_audit_log_created ON audit_log(created_at)",
+			}
+			for _, stmt := range stmts {
+				if _, err := tx.Exec(stmt); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		version:     11,
+		description: "add glossary table for abbreviations defined in documents",
+		apply: func(tx *sql.Tx) error {
+			stmts := []string{
+				`CREATE TABLE IF NOT EXISTS glossary (
+					id INTEGER PRIMARY KEY,
+					document_id INTEGER NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+					chunk_id INTEGER REFERENCES chunks(id) ON DELETE SET NULL,
+					abbreviation TEXT NOT NULL,
+					definition TEXT NOT NULL,
+					UNIQUE(document_id, abbreviation, definition)
+				)`,
+				"CREATE INDEX IF NOT EXISTS idx_glossary_abbreviation ON glossary(abbreviation)",
+			}
+			for _, stmt := range stmts {
+				if _, err := tx.Exec(stmt); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		version:     12,
+		description: "add documents.quality for ingest-time quality reports",
+		apply: func(tx *sql.Tx) error {
+			stmt := "ALTER TABLE documents ADD COLUMN quality JSON"
+			if _, err := tx.Exec(stmt); err != nil {
+				slog.Debug("migration 12: statement may already be applied", "sql", stmt, "error", err)
+			}
+			return nil
+		},
+	},
+	{
+		version:     13,
+		description: "add experiment arms, latency, and feedback to query_log",
+		apply: func(tx *sql.Tx) error {
+			stmts := []string{
+				"ALTER TABLE query_log ADD COLUMN experiment TEXT",
+				"ALTER TABLE query_log ADD COLUMN arm TEXT",
+				"ALTER TABLE query_log ADD COLUMN elapsed_ms INTEGER DEFAULT 0",
+				"ALTER TABLE query_log ADD COLUMN feedback_rating INTEGER",
+				"ALTER TABLE query_log ADD COLUMN feedback_comment TEXT",
+				"ALTER TABLE query_log ADD COLUMN feedback_at DATETIME",
+			}
+			for _, stmt := range stmts {
+				if _, err := tx.Exec(stmt); err != nil {
+					slog.Debug("migration 13: statement may already be applied", "sql", stmt, "error", err)
+				}
+			}
+			_, err := tx.Exec("CREATE INDEX IF NOT EXISTS idx_query_log_experiment ON query_log(experiment, arm)")
+			return err
+		},
+	},
+	{
+		version:     14,
+		description: "re-index FTS only when chunk content or heading changes",
+		apply: func(tx *sql.Tx) error {
+			// Partial updates rewrite the position and metadata of unchanged
+			// chunks; their FTS rows must not be replaced. The original
+			// trigger's re-insert also named the chunks_fts command column
+			// without a value, so any chunk update failed.
+			stmts := []string{
+				"DROP TRIGGER IF EXISTS chunks_au",
+				`CREATE TRIGGER chunks_au AFTER UPDATE OF content, heading ON chunks
+				WHEN old.content IS NOT new.content OR old.heading IS NOT new.heading BEGIN
+					INSERT INTO chunks_fts(chunks_fts, rowid, content, heading) VALUES ('delete', old.id, old.content, old.heading);
+					INSERT INTO chunks_fts(rowid, content, heading) VALUES (new.id, new.content, new.heading);
+				END`,
+			}
+			for _, stmt := range stmts {
+				if _, err := tx.Exec(stmt); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		version:     15,
+		description: "add chunk_annotations table for human curation",
+		apply: func(tx *sql.Tx) error {
+			stmts := []string{
+				`CREATE TABLE IF NOT EXISTS chunk_annotations (
+					id INTEGER PRIMARY KEY,
+					chunk_id INTEGER NOT NULL REFERENCES chunks(id) ON DELETE CASCADE,
+					kind TEXT NOT NULL,
+					text TEXT,
+					boost REAL DEFAULT 0,
+					author TEXT,
+					created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+					UNIQUE(chunk_id, kind)
+				)`,
+			}
+			for _, stmt := range stmts {
+				if _, err := tx.Exec(stmt); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		version:     16,
+		description: "add ingest_checkpoints table for resumable ingestion",
+		apply: func(tx *sql.Tx) error {
+			_, err := tx.Exec(`CREATE TABLE IF NOT EXISTS ingest_checkpoints (
+				document_id INTEGER PRIMARY KEY REFERENCES documents(id) ON DELETE CASCADE,
+				stage TEXT NOT NULL,
+				first_chunk_id INTEGER NOT NULL,
+				last_chunk_id INTEGER NOT NULL DEFAULT 0,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)`)
+			return err
+		},
+	},
+	{
+		version:     17,
+		description: "add query_log.request_id for correlating queries with logs",
+		apply: func(tx *sql.Tx) error {
+			if _, err := tx.Exec("ALTER TABLE query_log ADD COLUMN request_id TEXT"); err != nil {
+				return err
+			}
+			_, err := tx.Exec("CREATE INDEX IF NOT EXISTS idx_query_log_request ON query_log(request_id)")
+			return err
+		},
+	},
+	{
+		version:     18,
+		description: "add entities.stop for generic entities kept out of graph search",
+		apply: func(tx *sql.Tx) error {
+			if _, err := tx.Exec("ALTER TABLE entities ADD COLUMN stop INTEGER NOT NULL DEFAULT 0"); err != nil {
+				return err
+			}
+			_, err := tx.Exec("CREATE INDEX IF NOT EXISTS idx_entities_stop ON entities(stop) WHERE stop = 1")
+			return err
+		},
+	},
+	{
+		version:     19,
+		description: "add query_translations table caching cross-language query translations",
+		apply: func(tx *sql.Tx) error {
+			_, err := tx.Exec(`CREATE TABLE IF NOT EXISTS query_translations (
+				question_hash TEXT NOT NULL,
+				language TEXT NOT NULL,
+				terms JSON NOT NULL,
+				prompt_tokens INTEGER NOT NULL DEFAULT 0,
+				completion_tokens INTEGER NOT NULL DEFAULT 0,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (question_hash, language)
+			)`)
+			return err
+		},
+	},
+	{
+		version:     20,
+		description: "add documents.importance for weighting authoritative sources",
+		apply: func(tx *sql.Tx) error {
+			_, err := tx.Exec("ALTER TABLE documents ADD COLUMN importance REAL NOT NULL DEFAULT 1")
+			return err
+		},
+	},
+	{
+		version:     21,
+		description: "add query_log.refusal_reason for out-of-scope refusals",
+		apply: func(tx *sql.Tx) error {
+			_, err := tx.Exec("ALTER TABLE query_log ADD COLUMN refusal_reason TEXT")
+			return err
+		},
+	},
+	{
+		version:     22,
+		description: "add communities.signature and summary_signature for staleness tracking",
+		apply: func(tx *sql.Tx) error {
+			if _, err := tx.Exec("ALTER TABLE communities ADD COLUMN signature TEXT NOT NULL DEFAULT ''"); err != nil {
+				return err
+			}
+			_, err := tx.Exec("ALTER TABLE communities ADD COLUMN summary_signature TEXT NOT NULL DEFAULT ''")
+			return err
+		},
+	},
+	{
+		version:     23,
+		description: "add query_log.trace for answer explanations",
+		apply: func(tx *sql.Tx) error {
+			_, err := tx.Exec("ALTER TABLE query_log ADD COLUMN trace JSON")
+			return err
+		},
+	},
+	{
+		version:     24,
+		description: "add entities.import_source and relationships.import_source for curated graph data",
+		apply: func(tx *sql.Tx) error {
+			if _, err := tx.Exec("ALTER TABLE entities ADD COLUMN import_source TEXT"); err != nil {
+				return err
+			}
+			_, err := tx.Exec("ALTER TABLE relationships ADD COLUMN import_source TEXT")
+			return err
+		},
+	},
+	{
+		version:     25,
+		description: "add documents.parent_id linking archive members to their archive",
+		apply: func(tx *sql.Tx) error {
+			if _, err := tx.Exec("ALTER TABLE documents ADD COLUMN parent_id INTEGER REFERENCES documents(id)"); err != nil {
+				return err
+			}
+			_, err := tx.Exec("CREATE INDEX IF NOT EXISTS idx_documents_parent ON documents(parent_id)")
+			return err
+		},
+	},
+	{
+		version:     26,
+		description: "add contradictions table for conflicting facts across documents",
+		apply: func(tx *sql.Tx) error {
+			stmts := []string{
+				`CREATE TABLE IF NOT EXISTS contradictions (
+					id INTEGER PRIMARY KEY,
+					entity_id INTEGER NOT NULL REFERENCES entities(id) ON DELETE CASCADE,
+					attribute TEXT NOT NULL,
+					chunk_a INTEGER NOT NULL REFERENCES chunks(id) ON DELETE CASCADE,
+					value_a TEXT NOT NULL,
+					chunk_b INTEGER NOT NULL REFERENCES chunks(id) ON DELETE CASCADE,
+					value_b TEXT NOT NULL,
+					explanation TEXT,
+					created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+				)`,
+				"CREATE INDEX IF NOT EXISTS idx_contradictions_chunk_a ON contradictions(chunk_a)",
+				"CREATE INDEX IF NOT EXISTS idx_contradictions_chunk_b ON contradictions(chunk_b)",
+			}
+			for _, stmt := range stmts {
+				if _, err := tx.Exec(stmt); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		version:     27,
+		description: "add chunk_usage table counting retrievals and citations",
+		apply: func(tx *sql.Tx) error {
+			_, err := tx.Exec(`CREATE TABLE IF NOT EXISTS chunk_usage (
+				chunk_id INTEGER PRIMARY KEY REFERENCES chunks(id) ON DELETE CASCADE,
+				retrieved INTEGER NOT NULL DEFAULT 0,
+				cited INTEGER NOT NULL DEFAULT 0,
+				retrieved_score REAL NOT NULL DEFAULT 0,
+				cited_score REAL NOT NULL DEFAULT 0,
+				decayed_at INTEGER NOT NULL -- unix seconds the scores were last decayed to
+			)`)
+			return err
+		},
+	},
+	{
+		version:     28,
+		description: "add documents.session_id and expires_at for session-scoped temporary documents",
+		apply: func(tx *sql.Tx) error {
+			stmts := []string{
+				"ALTER TABLE documents ADD COLUMN session_id TEXT DEFAULT ''",
+				"ALTER TABLE documents ADD COLUMN expires_at DATETIME",
+				"CREATE INDEX IF NOT EXISTS idx_documents_session ON documents(session_id) WHERE session_id != ''",
+			}
+			for _, stmt := range stmts {
+				if _, err := tx.Exec(stmt); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		version:     29,
+		description: "add key_facts table for per-document key-facts records",
+		apply: func(tx *sql.Tx) error {
+			stmts := []string{
+				`CREATE TABLE IF NOT EXISTS key_facts (
+					id INTEGER PRIMARY KEY,
+					document_id INTEGER NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+					schema_name TEXT NOT NULL,
+					field TEXT NOT NULL,
+					value TEXT NOT NULL,
+					position INTEGER NOT NULL DEFAULT 0
+				)`,
+				"CREATE INDEX IF NOT EXISTS idx_key_facts_document ON key_facts(document_id)",
+				"CREATE INDEX IF NOT EXISTS idx_key_facts_field ON key_facts(field, value)",
+			}
+			for _, stmt := range stmts {
+				if _, err := tx.Exec(stmt); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		version:     30,
+		description: "add chunk_quantities table for numeric range retrieval",
+		apply: func(tx *sql.Tx) error {
+			stmts := []string{
+				`CREATE TABLE IF NOT EXISTS chunk_quantities (
+					id INTEGER PRIMARY KEY,
+					chunk_id INTEGER NOT NULL REFERENCES chunks(id) ON DELETE CASCADE,
+					document_id INTEGER NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+					dimension TEXT NOT NULL,
+					value REAL NOT NULL,
+					unit TEXT NOT NULL,
+					raw TEXT NOT NULL
+				)`,
+				"CREATE INDEX IF NOT EXISTS idx_chunk_quantities_value ON chunk_quantities(dimension, value)",
+				"CREATE INDEX IF NOT EXISTS idx_chunk_quantities_chunk ON chunk_quantities(chunk_id)",
+				"CREATE INDEX IF NOT EXISTS idx_chunk_quantities_document ON chunk_quantities(document_id)",
+			}
+			for _, stmt := range stmts {
+				if _, err := tx.Exec(stmt); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		version:     31,
+		description: "add ingest_checkpoints.graph_report for graph build progress",
+		apply: func(tx *sql.Tx) error {
+			_, err := tx.Exec("ALTER TABLE ingest_checkpoints ADD COLUMN graph_report TEXT")
+			return err
+		},
+	},
+	{
+		version:     32,
+		description: "add query_templates table for named parameterized questions",
+		apply: func(tx *sql.Tx) error {
+			_, err := tx.Exec(`CREATE TABLE IF NOT EXISTS query_templates (
+				name TEXT PRIMARY KEY,
+				question TEXT NOT NULL,
+				description TEXT,
+				author TEXT,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)`)
+			return err
+		},
+	},
+	{
+		version:     33,
+		description: "add chunks_fts_sealed keyword index for encrypted chunks",
+		apply: func(tx *sql.Tx) error {
+			// chunks_fts reads its content from chunks, which for encrypted
+			// chunks is ciphertext. Their plaintext terms go to a
+			// contentless table written by the store instead (see
+			// indexSealed), and chunks_fts skips them.
+			stmts := []string{
+				`CREATE VIRTUAL TABLE IF NOT EXISTS chunks_fts_sealed USING fts5(
+					content, heading, content='', contentless_delete=1, tokenize='porter unicode61'
+				)`,
+				`INSERT INTO chunks_fts(chunks_fts, rowid, content, heading)
+				SELECT 'delete', id, content, heading FROM chunks WHERE content LIKE 'enc1:%'`,
+				"DROP TRIGGER IF EXISTS chunks_ai",
+				"DROP TRIGGER IF EXISTS chunks_ad",
+				"DROP TRIGGER IF EXISTS chunks_au",
+				`CREATE TRIGGER chunks_ai AFTER INSERT ON chunks WHEN new.content NOT LIKE 'enc1:%' BEGIN
+INSERT INTO chunks_fts(rowid, content, heading) VALUES (new.id, new.content, new.heading);
+END`,
+				`CREATE TRIGGER chunks_ad AFTER DELETE ON chunks BEGIN
+INSERT INTO chunks_fts(chunks_fts, rowid, content, heading)
+SELECT 'delete', old.id, old.content, old.heading WHERE old.content NOT LIKE 'enc1:%';
+DELETE FROM chunks_fts_sealed WHERE rowid = old.id;
+END`,
+				`CREATE TRIGGER chunks_au AFTER UPDATE OF content, heading ON chunks
+WHEN old.content IS NOT new.content OR old.heading IS NOT new.heading BEGIN
+INSERT INTO chunks_fts(chunks_fts, rowid, content, heading)
+SELECT 'delete', old.id, old.content, old.heading WHERE old.content NOT LIKE 'enc1:%';
+INSERT INTO chunks_fts(rowid, content, heading)
+SELECT new.id, new.content, new.heading WHERE new.content NOT LIKE 'enc1:%';
+END`,
+			}
+			for _, stmt := range stmts {
+				if _, err := tx.Exec(stmt); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		version:     34,
+		description: "add documents.has_ready_version for searching documents being re-ingested",
+		apply: func(tx *sql.Tx) error {
+			if _, err := tx.Exec("ALTER TABLE documents ADD COLUMN has_ready_version INTEGER NOT NULL DEFAULT 0"); err != nil {
+				return err
+			}
+			_, err := tx.Exec("UPDATE documents SET has_ready_version = 1 WHERE status = 'ready'")
+			return err
+		},
+	},
+	{
+		version:     35,
+		description: "add ingest_checkpoints.pipeline for resuming custom pipelines",
+		apply: func(tx *sql.Tx) error {
+			_, err := tx.Exec("ALTER TABLE ingest_checkpoints ADD COLUMN pipeline TEXT")
+			return err
+		},
+	},
+	{
+		version:     36,
+		description: "add chunks.quantities_pending to backfill values of older chunks",
+		apply: func(tx *sql.Tx) error {
+			// Chunks written before migration 30 have no values; the engine
+			// extracts them at startup. Chunks without any are marked too:
+			// scanning them again finds nothing and is cheap.
+			stmts := []string{
+				"ALTER TABLE chunks ADD COLUMN quantities_pending INTEGER NOT NULL DEFAULT 0",
+				"UPDATE chunks SET quantities_pending = 1 WHERE id NOT IN (SELECT chunk_id FROM chunk_quantities)",
+			}
+			for _, stmt := range stmts {
+				if _, err := tx.Exec(stmt); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		version:     37,
+		description: "add query_sources linking logged answers to the chunks they cite",
+		apply: func(tx *sql.Tx) error {
+			// live_chunk_id is cleared when the chunk is deleted, so a
+			// replacement that happens to reuse its ID does not hide it.
+			stmts := []string{
+				`CREATE TABLE query_sources (
+					query_id INTEGER NOT NULL REFERENCES query_log(id) ON DELETE CASCADE,
+					chunk_id INTEGER NOT NULL,
+					document_id INTEGER NOT NULL,
+					live_chunk_id INTEGER REFERENCES chunks(id) ON DELETE SET NULL,
+					PRIMARY KEY (query_id, chunk_id)
+				)`,
+				"CREATE INDEX idx_query_sources_document ON query_sources(document_id) WHERE live_chunk_id IS NULL",
+				"CREATE INDEX idx_query_sources_chunk ON query_sources(live_chunk_id)",
+				`INSERT OR IGNORE INTO query_sources (query_id, chunk_id, document_id, live_chunk_id)
+				SELECT q.id, json_extract(q.sources, j.fullkey || '.chunk_id'), json_extract(q.sources, j.fullkey || '.document_id'), c.id
+				FROM query_log q,
+					json_each(CASE WHEN json_valid(q.sources) AND json_type(q.sources) = 'array' THEN q.sources ELSE '[]' END) j
+				LEFT JOIN chunks c ON c.id = json_extract(q.sources, j.fullkey || '.chunk_id')
+				WHERE j.type = 'object'
+				  AND json_extract(q.sources, j.fullkey || '.chunk_id') IS NOT NULL
+				  AND json_extract(q.sources, j.fullkey || '.document_id') IS NOT NULL`,
+			}
+			for _, stmt := range stmts {
+				if _, err := tx.Exec(stmt); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		version:     38,
+		description: "add embed_summaries table caching the summaries oversized chunks are embedded by",
+		apply: func(tx *sql.Tx) error {
+			_, err := tx.Exec(`CREATE TABLE embed_summaries (
+				document_id INTEGER NOT NULL,
+				content_hash TEXT NOT NULL,
+				summary TEXT NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (document_id, content_hash)
+			)`)
+			return err
+		},
+	},
+	{
+		version:     39,
+		description: "convert documents.expires_at to RFC 3339, the form session expiries are compared in",
+		apply: func(tx *sql.Tx) error {
+			_, err := tx.Exec(`UPDATE documents SET expires_at = strftime('%Y-%m-%dT%H:%M:%SZ', expires_at)
+				WHERE expires_at IS NOT NULL AND expires_at NOT LIKE '%T%'`)
+			return err
+		},
+	},
 }
 
 // Migrate runs all pending schema migrations.
@@ -0,0 +1,280 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// ChunkQuantity is a numeric value with a unit found in a chunk, in its
+// dimension's base unit (kPa for pressure, °C for temperature, ...), so
+// values written in different units compare.
+type ChunkQuantity struct {
+	ChunkID    int64   `json:"chunk_id"`
+	DocumentID int64   `json:"document_id"`
+	Dimension  string  `json:"dimension"`
+	Value      float64 `json:"value"`
+	Unit       string  `json:"unit"`
+	Raw        string  `json:"raw"` // as written, e.g. "75 PSIG"
+}
+
+// NumericRange constrains a dimension's values, in its base unit. Min and
+// Max are inclusive; an open side is -Inf or +Inf. Min == Max matches one
+// value, allowing for rounding in unit conversion.
+type NumericRange struct {
+	Dimension string
+	Unit      string
+	Min, Max  float64
+}
+
+// String describes the range, e.g. "pressure 482.6–620.5 kPa" or
+// "temperature ≤ 40 °C".
+func (r NumericRange) String() string {
+	switch {
+	case math.IsInf(r.Min, -1):
+		return fmt.Sprintf("%s ≤ %g %s", r.Dimension, r.Max, r.Unit)
+	case math.IsInf(r.Max, 1):
+		return fmt.Sprintf("%s ≥ %g %s", r.Dimension, r.Min, r.Unit)
+	case r.Min == r.Max:
+		return fmt.Sprintf("%s = %g %s", r.Dimension, r.Min, r.Unit)
+	}
+	return fmt.Sprintf("%s %g–%g %s", r.Dimension, r.Min, r.Max, r.Unit)
+}
+
+// condition returns the SQL condition on chunk_quantities q for the range
+// and its arguments.
+func (r NumericRange) condition() (string, []interface{}) {
+	cond := "q.dimension = ?"
+	args := []interface{}{r.Dimension}
+	if !math.IsInf(r.Min, -1) {
+		cond += " AND q.value >= ?"
+		args = append(args, r.Min-rangeTolerance(r.Min))
+	}
+	if !math.IsInf(r.Max, 1) {
+		cond += " AND q.value <= ?"
+		args = append(args, r.Max+rangeTolerance(r.Max))
+	}
+	return cond, args
+}
+
+// rangeTolerance absorbs rounding in unit conversions at a bound.
+func rangeTolerance(v float64) float64 {
+	return 1e-6 * math.Max(1, math.Abs(v))
+}
+
+// InsertChunkQuantities stores the numeric values found in chunks. Raw
+// is encrypted like chunk content.
+func (s *Store) InsertChunkQuantities(ctx context.Context, qs []ChunkQuantity) error {
+	if len(qs) == 0 {
+		return nil
+	}
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		return insertChunkQuantities(ctx, tx, s.contentSealer(ctx, tx), qs)
+	})
+}
+
+func insertChunkQuantities(ctx context.Context, tx *sql.Tx, seal *sealer, qs []ChunkQuantity) error {
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO chunk_quantities (chunk_id, document_id, dimension, value, unit, raw)
+		VALUES (?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, q := range qs {
+		raw, err := seal.text(q.DocumentID, q.Raw)
+		if err != nil {
+			return err
+		}
+		if _, err := stmt.ExecContext(ctx, q.ChunkID, q.DocumentID, q.Dimension, q.Value, q.Unit, raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BackfillChunkQuantities stores the values extract finds in the chunks
+// written before values were extracted at ingest, and returns how many
+// chunks it scanned. Encrypted chunks whose key is missing are left for a
+// later run.
+func (s *Store) BackfillChunkQuantities(ctx context.Context, extract func(Chunk) []ChunkQuantity) (int, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, document_id, content FROM chunks WHERE quantities_pending = 1`)
+	if err != nil {
+		return 0, err
+	}
+	var pending []Chunk
+	for rows.Next() {
+		var c Chunk
+		if err := rows.Scan(&c.ID, &c.DocumentID, &c.Content); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		pending = append(pending, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(pending) == 0 {
+		return 0, nil
+	}
+
+	n := 0
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		seal := s.contentSealer(ctx, tx)
+		for _, c := range pending {
+			content, err := s.openText(c.Content)
+			if errors.Is(err, ErrNoContentKey) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("chunk %d: %w", c.ID, err)
+			}
+			c.Content = content
+			if _, err := tx.ExecContext(ctx, "DELETE FROM chunk_quantities WHERE chunk_id = ?", c.ID); err != nil {
+				return err
+			}
+			if err := insertChunkQuantities(ctx, tx, seal, extract(c)); err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, "UPDATE chunks SET quantities_pending = 0 WHERE id = ?", c.ID); err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// rangesFilter returns a condition on chunks c requiring a value in every
+// range, and its arguments.
+func rangesFilter(ranges []NumericRange) (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	for _, r := range ranges {
+		cond, a := r.condition()
+		conds = append(conds, "c.id IN (SELECT q.chunk_id FROM chunk_quantities q WHERE "+cond+")")
+		args = append(args, a...)
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// NumericSearch returns up to limit chunks with a value in every range,
+// those with the most matching values first. Score is that count.
+func (s *Store) NumericSearch(ctx context.Context, ranges []NumericRange, limit int) ([]RetrievalResult, error) {
+	if len(ranges) == 0 {
+		return nil, nil
+	}
+	filter, args := rangesFilter(ranges)
+	var matches []string
+	var countArgs []interface{}
+	for _, r := range ranges {
+		cond, a := r.condition()
+		matches = append(matches, "("+cond+")")
+		countArgs = append(countArgs, a...)
+	}
+	query := `
+		SELECT c.id, (SELECT COUNT(*) FROM chunk_quantities q WHERE q.chunk_id = c.id AND (` + strings.Join(matches, " OR ") + `)),
+			c.content, c.heading, c.chunk_type, c.page_number, c.position_in_doc,
+			COALESCE(c.start_offset, 0), COALESCE(c.end_offset, 0),
+			c.metadata, c.document_id,
+			d.filename, d.path, d.metadata
+		FROM chunks c
+		` + s.documentsJoin() + `
+		WHERE ` + filter + `
+		ORDER BY 2 DESC, c.id
+		LIMIT ?`
+	args = append(append(countArgs, args...), limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []RetrievalResult
+	for rows.Next() {
+		var r RetrievalResult
+		var chunkMeta, docMeta sql.NullString
+		if err := rows.Scan(&r.ChunkID, &r.Score,
+			&r.Content, &r.Heading, &r.ChunkType, &r.PageNumber, &r.PositionInDoc,
+			&r.StartOffset, &r.EndOffset,
+			&chunkMeta, &r.DocumentID,
+			&r.Filename, &r.Path, &docMeta); err != nil {
+			return nil, err
+		}
+		r.ChunkMeta = chunkMeta.String
+		r.DocMeta = docMeta.String
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return s.openResults(results)
+}
+
+// ChunksInRanges returns which of chunkIDs have a value in every range.
+func (s *Store) ChunksInRanges(ctx context.Context, chunkIDs []int64, ranges []NumericRange) (map[int64]bool, error) {
+	out := make(map[int64]bool)
+	if len(chunkIDs) == 0 {
+		return out, nil
+	}
+	filter, args := rangesFilter(ranges)
+	if filter == "" {
+		filter = "1"
+	}
+	query := "SELECT c.id FROM chunks c WHERE c.id IN (?" + repeatPlaceholders(len(chunkIDs)-1) + ") AND " + filter
+	idArgs := make([]interface{}, 0, len(chunkIDs)+len(args))
+	for _, id := range chunkIDs {
+		idArgs = append(idArgs, id)
+	}
+	rows, err := s.db.QueryContext(ctx, query, append(idArgs, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		out[id] = true
+	}
+	return out, rows.Err()
+}
+
+// ChunkQuantities returns the values found in a document's chunks, in
+// document order.
+func (s *Store) ChunkQuantities(ctx context.Context, docID int64) ([]ChunkQuantity, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT q.chunk_id, q.document_id, q.dimension, q.value, q.unit, q.raw
+		FROM chunk_quantities q JOIN chunks c ON c.id = q.chunk_id
+		WHERE q.document_id = ?
+		ORDER BY c.position_in_doc, q.id`, docID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ChunkQuantity
+	for rows.Next() {
+		var q ChunkQuantity
+		if err := rows.Scan(&q.ChunkID, &q.DocumentID, &q.Dimension, &q.Value, &q.Unit, &q.Raw); err != nil {
+			return nil, err
+		}
+		raw, err := s.openText(q.Raw)
+		if err != nil {
+			return nil, fmt.Errorf("chunk %d: %w", q.ChunkID, err)
+		}
+		q.Raw = raw
+		out = append(out, q)
+	}
+	return out, rows.Err()
+}
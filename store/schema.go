@@ -3,7 +3,7 @@ package store
 import "fmt"
 
 // schemaSQL returns the DDL for all tables. embeddingDim controls the
-// vec0 virtual table dimension.
+// dimension of the vec_chunks and vec_entities virtual tables.
 func schemaSQL(embeddingDim int) string {
 	return fmt.Sprintf(`
 -- Document registry with hash-based change detection
@@ -16,6 +16,8 @@ CREATE TABLE IF NOT EXISTS documents (
     parse_method TEXT NOT NULL,
     status TEXT DEFAULT 'pending',
     metadata JSON,
+    summary TEXT,
+    keywords JSON,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 );
@@ -32,14 +34,31 @@ CREATE TABLE IF NOT EXISTS chunks (
     position_in_doc INTEGER,
     token_count INTEGER,
     metadata JSON,
-    content_hash TEXT NOT NULL
+    content_hash TEXT NOT NULL,
+    start_offset INTEGER DEFAULT 0,
+    end_offset INTEGER DEFAULT 0
 );
 
 -- Vector embeddings via sqlite-vec
 CREATE VIRTUAL TABLE IF NOT EXISTS vec_chunks USING vec0(
     chunk_id INTEGER PRIMARY KEY,
-    embedding float[%d]
+    embedding float[%[1]d]
+);
+
+-- Entity description embeddings, for mapping queries to seed entities
+CREATE VIRTUAL TABLE IF NOT EXISTS vec_entities USING vec0(
+    entity_id INTEGER PRIMARY KEY,
+    embedding float[%[1]d] distance_metric=cosine
+);
+
+-- Learned sparse embeddings (SPLADE/BM42): one row per non-zero term
+CREATE TABLE IF NOT EXISTS sparse_chunks (
+    chunk_id INTEGER NOT NULL REFERENCES chunks(id) ON DELETE CASCADE,
+    term_id INTEGER NOT NULL,
+    weight REAL NOT NULL,
+    PRIMARY KEY (chunk_id, term_id)
 );
+CREATE INDEX IF NOT EXISTS idx_sparse_chunks_term ON sparse_chunks(term_id);
 
 -- Full-text search via FTS5
 CREATE VIRTUAL TABLE IF NOT EXISTS chunks_fts USING fts5(
@@ -57,9 +76,10 @@ END;
 CREATE TRIGGER IF NOT EXISTS chunks_ad AFTER DELETE ON chunks BEGIN
     INSERT INTO chunks_fts(chunks_fts, rowid, content, heading) VALUES ('delete', old.id, old.content, old.heading);
 END;
-CREATE TRIGGER IF NOT EXISTS chunks_au AFTER UPDATE ON chunks BEGIN
+CREATE TRIGGER IF NOT EXISTS chunks_au AFTER UPDATE OF content, heading ON chunks
+WHEN old.content IS NOT new.content OR old.heading IS NOT new.heading BEGIN
     INSERT INTO chunks_fts(chunks_fts, rowid, content, heading) VALUES ('delete', old.id, old.content, old.heading);
-    INSERT INTO chunks_fts(chunks_fts, rowid, content, heading) VALUES (new.id, new.content, new.heading);
+    INSERT INTO chunks_fts(rowid, content, heading) VALUES (new.id, new.content, new.heading);
 END;
 
 -- Knowledge graph: entities
@@ -116,6 +136,19 @@ CREATE TABLE IF NOT EXISTS query_log (
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Mutation audit log (ingest, update, delete, re-embed, maintenance)
+CREATE TABLE IF NOT EXISTS audit_log (
+    id INTEGER PRIMARY KEY,
+    operation TEXT NOT NULL,
+    actor TEXT,
+    params JSON,
+    outcome TEXT NOT NULL,
+    error TEXT,
+    document_id INTEGER,
+    duration_ms INTEGER DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
 -- Chunk-associated images (extracted during parsing)
 CREATE TABLE IF NOT EXISTS chunk_images (
     id INTEGER PRIMARY KEY,
@@ -131,6 +164,15 @@ CREATE TABLE IF NOT EXISTS chunk_images (
 CREATE INDEX IF NOT EXISTS idx_chunk_images_chunk ON chunk_images(chunk_id);
 CREATE INDEX IF NOT EXISTS idx_chunk_images_document ON chunk_images(document_id);
 
+-- Embedding model that produced each vector table's contents
+CREATE TABLE IF NOT EXISTS embedding_models (
+    space TEXT PRIMARY KEY,
+    provider TEXT,
+    model TEXT NOT NULL,
+    dim INTEGER NOT NULL,
+    recorded_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
 -- Indexes
 CREATE INDEX IF NOT EXISTS idx_chunks_document ON chunks(document_id);
 CREATE INDEX IF NOT EXISTS idx_chunks_parent ON chunks(parent_chunk_id);
@@ -141,5 +183,6 @@ CREATE INDEX IF NOT EXISTS idx_relationships_target ON relationships(target_enti
 CREATE INDEX IF NOT EXISTS idx_relationships_type ON relationships(relation_type);
 CREATE INDEX IF NOT EXISTS idx_entity_chunks_chunk ON entity_chunks(chunk_id);
 CREATE INDEX IF NOT EXISTS idx_documents_hash ON documents(content_hash);
+CREATE INDEX IF NOT EXISTS idx_audit_log_created ON audit_log(created_at);
 `, embeddingDim)
 }
@@ -0,0 +1,64 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// SessionExpiry formats t as a Document.ExpiresAt: RFC 3339 in UTC, the
+// form expires_at is read back in, so stored expiries compare as strings.
+func SessionExpiry(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// SessionDocuments returns the session of every session document, keyed by
+// document ID.
+func (s *Store) SessionDocuments(ctx context.Context) (map[int64]string, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT id, session_id FROM documents WHERE session_id != ''")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var session string
+		if err := rows.Scan(&id, &session); err != nil {
+			return nil, err
+		}
+		out[id] = session
+	}
+	return out, rows.Err()
+}
+
+// ExtendSession moves the expiry of the session's documents to expiresAt,
+// never earlier than it already is.
+func (s *Store) ExtendSession(ctx context.Context, session string, expiresAt time.Time) error {
+	_, err := s.exec(ctx,
+		"UPDATE documents SET expires_at = ? WHERE session_id = ? AND (expires_at IS NULL OR expires_at < ?)",
+		SessionExpiry(expiresAt), session, SessionExpiry(expiresAt))
+	return err
+}
+
+// ExpiredSessionDocuments returns the session documents whose expiry is
+// not after now.
+func (s *Store) ExpiredSessionDocuments(ctx context.Context, now time.Time) ([]int64, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT id FROM documents WHERE session_id != '' AND expires_at <= ? ORDER BY id", SessionExpiry(now))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
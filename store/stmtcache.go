@@ -0,0 +1,86 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// maxCachedStmts bounds the statement cache. GraphSearch's SQL varies with
+// the number of entity IDs, so the set of statements is open-ended; beyond
+// the cap queries run unprepared as before.
+const maxCachedStmts = 64
+
+// stmtCache holds prepared statements for the hot retrieval and embedding
+// paths, keyed by SQL text. A *sql.Stmt prepares itself lazily on each
+// pooled connection it runs on and reuses that preparation afterwards, so
+// the cache amounts to one prepared statement per connection per query.
+type stmtCache struct {
+	mu       sync.Mutex
+	stmts    map[string]*sql.Stmt
+	disabled bool // benchmarks compare against unprepared queries
+}
+
+// prepared returns the cached statement for query, preparing it on first
+// use. It returns nil when the cache is full or preparation fails; callers
+// then run the query directly, which reports any error in the SQL.
+func (s *Store) prepared(ctx context.Context, query string) *sql.Stmt {
+	c := &s.stmts
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.disabled {
+		return nil
+	}
+	if stmt, ok := c.stmts[query]; ok {
+		return stmt
+	}
+	if len(c.stmts) >= maxCachedStmts {
+		return nil
+	}
+	stmt, err := s.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil
+	}
+	if c.stmts == nil {
+		c.stmts = make(map[string]*sql.Stmt)
+	}
+	c.stmts[query] = stmt
+	return stmt
+}
+
+// cachedQuery is QueryContext through the statement cache.
+func (s *Store) cachedQuery(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if stmt := s.prepared(ctx, query); stmt != nil {
+		return stmt.QueryContext(ctx, args...)
+	}
+	return s.db.QueryContext(ctx, query, args...)
+}
+
+// cachedExec is exec through the statement cache.
+func (s *Store) cachedExec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	var res sql.Result
+	err := s.write(ctx, func(ctx context.Context) error {
+		return s.retryBusy(ctx, func() error {
+			var err error
+			if stmt := s.prepared(ctx, query); stmt != nil {
+				res, err = stmt.ExecContext(ctx, args...)
+			} else {
+				res, err = s.db.ExecContext(ctx, query, args...)
+			}
+			return err
+		})
+	})
+	return res, err
+}
+
+// resetStmts closes and forgets all cached statements, e.g. after a table
+// they reference is recreated.
+func (s *Store) resetStmts() {
+	c := &s.stmts
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, stmt := range c.stmts {
+		stmt.Close()
+	}
+	c.stmts = nil
+}
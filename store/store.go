@@ -7,11 +7,15 @@ import (
 	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
+	"net/url"
 	"os"
 	"strings"
 	"path/filepath"
+	"sort"
+	"sync"
 	"time"
 
 	sqlite_vec "github.com/asg017/sqlite-vec-go-bindings/cgo"
@@ -24,16 +28,56 @@ func init() {
 
 // Document represents a row in the documents table.
 type Document struct {
-	ID          int64  `json:"id"`
-	Path        string `json:"path"`
-	Filename    string `json:"filename"`
-	Format      string `json:"format"`
-	ContentHash string `json:"content_hash"`
-	ParseMethod string `json:"parse_method"`
-	Status      string `json:"status"`
-	Metadata    string `json:"metadata,omitempty"`
-	CreatedAt   string `json:"created_at"`
-	UpdatedAt   string `json:"updated_at"`
+	ID          int64   `json:"id"`
+	Path        string  `json:"path"`
+	Filename    string  `json:"filename"`
+	Format      string  `json:"format"`
+	ContentHash string  `json:"content_hash"`
+	ParseMethod string  `json:"parse_method"`
+	Status      string  `json:"status"`
+	Metadata    string  `json:"metadata,omitempty"`
+	Summary     string  `json:"summary,omitempty"`
+	Keywords    string  `json:"keywords,omitempty"` // JSON array
+	Collection  string  `json:"collection,omitempty"`
+	Quality     string  `json:"quality,omitempty"`    // JSON quality report from the last ingest
+	Importance  float64 `json:"importance"`           // fusion score multiplier; 0 on upsert keeps the current value
+	ParentID    int64   `json:"parent_id,omitempty"`  // the archive the document was extracted from
+	SessionID   string  `json:"session_id,omitempty"` // the session a temporary document belongs to
+	ExpiresAt   string  `json:"expires_at,omitempty"` // when a session document is deleted, UTC
+	CreatedAt   string  `json:"created_at"`
+	UpdatedAt   string  `json:"updated_at"`
+}
+
+// documentColumns is the column list shared by every documents SELECT that
+// scans into a Document via scanDocument.
+const documentColumns = `id, path, filename, format, content_hash, parse_method, status, metadata,
+	summary, keywords, collection, quality, importance, parent_id, session_id, expires_at, created_at, updated_at`
+
+// rowScanner is satisfied by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanDocument scans a row selected with documentColumns.
+func scanDocument(row rowScanner) (*Document, error) {
+	doc := &Document{}
+	var metadata, summary, keywords, collection, quality, sessionID, expiresAt sql.NullString
+	var parentID sql.NullInt64
+	if err := row.Scan(&doc.ID, &doc.Path, &doc.Filename, &doc.Format,
+		&doc.ContentHash, &doc.ParseMethod, &doc.Status,
+		&metadata, &summary, &keywords, &collection, &quality, &doc.Importance, &parentID,
+		&sessionID, &expiresAt, &doc.CreatedAt, &doc.UpdatedAt); err != nil {
+		return nil, err
+	}
+	doc.ParentID = parentID.Int64
+	doc.SessionID = sessionID.String
+	doc.ExpiresAt = expiresAt.String
+	doc.Metadata = metadata.String
+	doc.Summary = summary.String
+	doc.Keywords = keywords.String
+	doc.Collection = collection.String
+	doc.Quality = quality.String
+	return doc, nil
 }
 
 // Chunk represents a row in the chunks table.
@@ -49,6 +93,8 @@ type Chunk struct {
 	TokenCount    int    `json:"token_count"`
 	Metadata      string `json:"metadata,omitempty"`
 	ContentHash   string `json:"content_hash"`
+	StartOffset   int    `json:"start_offset,omitempty"` // byte span in the parser's extracted text
+	EndOffset     int    `json:"end_offset,omitempty"`   // 0 = unknown
 }
 
 // ChunkImage represents an image associated with a chunk.
@@ -93,6 +139,17 @@ type Community struct {
 	Level     int    `json:"level"`
 	Summary   string `json:"summary"`
 	EntityIDs string `json:"entity_ids"` // JSON array
+
+	// Signature fingerprints the member entities as of detection;
+	// SummarySignature is the Signature the summary was written for.
+	Signature        string `json:"signature"`
+	SummarySignature string `json:"summary_signature"`
+}
+
+// Stale reports whether the community has no summary or its members have
+// changed since the summary was written.
+func (c Community) Stale() bool {
+	return c.Summary == "" || c.SummarySignature != c.Signature
 }
 
 // QueryLog represents a row in the query_log table.
@@ -107,6 +164,29 @@ type QueryLog struct {
 	PromptTokens     int         `json:"prompt_tokens"`
 	CompletionTokens int         `json:"completion_tokens"`
 	TotalTokens      int         `json:"total_tokens"`
+	ElapsedMs        int64       `json:"elapsed_ms,omitempty"`
+	// Experiment and Arm name the retrieval experiment arm that served the
+	// query, if any.
+	Experiment string `json:"experiment,omitempty"`
+	Arm        string `json:"arm,omitempty"`
+	// RequestID is the ID of the request that asked the query, for
+	// finding its log lines.
+	RequestID string `json:"request_id,omitempty"`
+	// RefusalReason is why the question was refused as out of scope
+	// without retrieval; empty for answered queries.
+	RefusalReason string `json:"refusal_reason,omitempty"`
+	// Trace records how the answer was produced (retrieval and reasoning),
+	// stored as JSON; nil stores nothing.
+	Trace interface{} `json:"trace,omitempty"`
+}
+
+// QueryLogEntry is a logged query as read back by GetQueryLog. Sources and
+// Trace hold the stored JSON as json.RawMessage (Trace is nil when none
+// was recorded).
+type QueryLogEntry struct {
+	ID int64 `json:"id"`
+	QueryLog
+	CreatedAt string `json:"created_at"`
 }
 
 // RetrievalResult holds a chunk with its retrieval score and document info.
@@ -118,22 +198,73 @@ type RetrievalResult struct {
 	ChunkType     string  `json:"chunk_type"`
 	PageNumber    int     `json:"page_number"`
 	PositionInDoc int     `json:"position_in_doc"`
+	StartOffset   int     `json:"start_offset,omitempty"` // byte span in the parser's extracted text
+	EndOffset     int     `json:"end_offset,omitempty"`
 	Filename      string  `json:"filename"`
 	Path          string  `json:"path"`
 	Score         float64 `json:"score"`
 	ChunkMeta     string  `json:"chunk_metadata,omitempty"`
 	DocMeta       string  `json:"doc_metadata,omitempty"`
+	GraphPath     string  `json:"graph_path,omitempty"` // set by MultiHopGraphSearch
+
+	// Curator annotations, set by retrieval (see ChunkAnnotation).
+	Correction      string `json:"correction,omitempty"`
+	CanonicalAnswer string `json:"canonical_answer,omitempty"`
+
+	// Conflicts describe recorded contradictions with other documents
+	// (see Contradiction.Note), set by retrieval.
+	Conflicts []string `json:"conflicts,omitempty"`
+
+	// Duplicates are other chunks with the same content (the same
+	// content_hash), collapsed into this result by retrieval.
+	Duplicates []DuplicateSource `json:"duplicates,omitempty"`
+}
+
+// DuplicateSource locates a chunk whose content duplicates a retrieval
+// result's, such as boilerplate repeated across documents.
+type DuplicateSource struct {
+	ChunkID    int64  `json:"chunk_id"`
+	DocumentID int64  `json:"document_id"`
+	Filename   string `json:"filename"`
+	Path       string `json:"path,omitempty"`
+	PageNumber int    `json:"page_number,omitempty"`
 }
 
 // Store wraps the SQLite database for all goreason persistence.
 type Store struct {
 	db           *sql.DB
 	embeddingDim int
+	imageVectors bool // vec_images exists (see EnableImageVectors)
+	secondaryVec bool // vec_chunks_secondary exists (see EnableSecondaryVectors)
+	stmts        stmtCache
+	writer       *writer // nil for OpenReader
+
+	path             string
+	readOnly         bool           // opened by OpenReader
+	manualCheckpoint bool           // see Options.ManualCheckpoint
+	cipher           *ContentCipher // see SetContentCipher
+	searchReady      bool           // see SetSearchReadyOnly
+
+	summaries summaryCache // see DocumentSummaries
+}
+
+// Options tunes how NewWithOptions opens the database.
+type Options struct {
+	// ManualCheckpoint turns off SQLite's automatic WAL checkpoints so a
+	// replicator such as Litestream decides when the WAL is copied back
+	// into the database. Compact then checkpoints PASSIVE instead of
+	// TRUNCATE so it never waits on the replicator's read lock.
+	ManualCheckpoint bool
 }
 
 // New opens (or creates) a SQLite database at the given path and
 // initialises the schema including sqlite-vec and FTS5 virtual tables.
 func New(dbPath string, embeddingDim int) (*Store, error) {
+	return NewWithOptions(dbPath, embeddingDim, Options{})
+}
+
+// NewWithOptions is New with opts applied.
+func NewWithOptions(dbPath string, embeddingDim int, opts Options) (*Store, error) {
 	// Ensure parent directory exists
 	dir := filepath.Dir(dbPath)
 	if dir != "." && dir != "" {
@@ -142,7 +273,13 @@ func New(dbPath string, embeddingDim int) (*Store, error) {
 		}
 	}
 
-	db, err := sql.Open("sqlite3", dbPath+"?_journal_mode=WAL&_foreign_keys=on&_busy_timeout=30000")
+	driver := "sqlite3"
+	if opts.ManualCheckpoint {
+		driver = manualCheckpointDriver
+	}
+	// Transactions take the write lock when they begin, where the busy
+	// timeout applies, rather than failing busy when they first write.
+	db, err := sql.Open(driver, dbPath+"?_journal_mode=WAL&_foreign_keys=on&_busy_timeout=30000&_txlock=immediate")
 	if err != nil {
 		return nil, fmt.Errorf("opening database: %w", err)
 	}
@@ -163,7 +300,7 @@ func New(dbPath string, embeddingDim int) (*Store, error) {
 	db.SetMaxIdleConns(2)
 	db.SetConnMaxLifetime(30 * time.Minute)
 
-	s := &Store{db: db, embeddingDim: embeddingDim}
+	s := &Store{db: db, embeddingDim: embeddingDim, path: dbPath, manualCheckpoint: opts.ManualCheckpoint}
 
 	// Run pending migrations.
 	if err := s.Migrate(context.Background()); err != nil {
@@ -171,11 +308,76 @@ func New(dbPath string, embeddingDim int) (*Store, error) {
 		return nil, fmt.Errorf("running migrations: %w", err)
 	}
 
+	s.detectVectorTables()
+	s.writer = startWriter()
+	return s, nil
+}
+
+// ReaderOptions tunes OpenReaderWithOptions.
+type ReaderOptions struct {
+	// Immutable opens the database file with SQLite's immutable=1: no
+	// locks, no WAL, and no -shm file, so it can be read from a read-only
+	// filesystem such as a container image. The file must not change
+	// while it is open, and frames still in a WAL file are not seen.
+	Immutable bool
+}
+
+// OpenReader opens a read-only connection to an existing database created
+// by New. It does not create the schema or run migrations; any write
+// through it fails. Query workers each hold one so they can search
+// concurrently without competing for the writer's connection pool.
+func OpenReader(dbPath string, embeddingDim int) (*Store, error) {
+	return OpenReaderWithOptions(dbPath, embeddingDim, ReaderOptions{})
+}
+
+// OpenReaderWithOptions is OpenReader with options.
+func OpenReaderWithOptions(dbPath string, embeddingDim int, opts ReaderOptions) (*Store, error) {
+	dsn := dbPath + "?_journal_mode=WAL&_foreign_keys=on&_busy_timeout=30000&_query_only=true"
+	if opts.Immutable {
+		// A URI filename: escape the path so "?", "#", and "%" in it are
+		// not read as the query string, fragment, or an escape.
+		dsn = "file:" + (&url.URL{Path: dbPath}).EscapedPath() + "?mode=ro&immutable=1&_foreign_keys=on&_query_only=true"
+	}
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("opening database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("pinging database: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+	db.SetMaxIdleConns(1)
+	db.SetConnMaxLifetime(30 * time.Minute)
+
+	s := &Store{db: db, embeddingDim: embeddingDim, path: dbPath, readOnly: true}
+	s.detectVectorTables()
 	return s, nil
 }
 
+// detectVectorTables records which optional vector tables exist. Image
+// vectors are optional and their dimension depends on the multimodal
+// model, so the table is not part of schemaSQL. Detect it here so deletes
+// clean it up even when image embedding is disabled.
+func (s *Store) detectVectorTables() {
+	var n int
+	if err := s.db.QueryRow(
+		"SELECT COUNT(*) FROM sqlite_master WHERE name = 'vec_images'").Scan(&n); err == nil {
+		s.imageVectors = n > 0
+	}
+	// Likewise for the secondary chunk embedding space.
+	if err := s.db.QueryRow(
+		"SELECT COUNT(*) FROM sqlite_master WHERE name = 'vec_chunks_secondary'").Scan(&n); err == nil {
+		s.secondaryVec = n > 0
+	}
+}
+
 // Close closes the underlying database connection.
 func (s *Store) Close() error {
+	if s.writer != nil {
+		s.writer.close()
+	}
+	s.resetStmts()
 	return s.db.Close()
 }
 
@@ -192,24 +394,44 @@ func (s *Store) EmbeddingDim() int {
 // --- Document operations ---
 
 // UpsertDocument inserts or updates a document record. Returns the document ID.
+// An empty Collection keeps the collection of an existing record.
 func (s *Store) UpsertDocument(ctx context.Context, doc Document) (int64, error) {
-	res, err := s.db.ExecContext(ctx, `
-		INSERT INTO documents (path, filename, format, content_hash, parse_method, status, metadata)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
+	defer s.summariesChanged()
+	var id int64
+	// An importance of 0 inserts the default and keeps an existing one.
+	var importance sql.NullFloat64
+	if doc.Importance > 0 {
+		importance = sql.NullFloat64{Float64: doc.Importance, Valid: true}
+	}
+	err := s.write(ctx, func(ctx context.Context) error {
+		return s.retryBusy(ctx, func() error {
+			res, err := s.db.ExecContext(ctx, `
+		INSERT INTO documents (path, filename, format, content_hash, parse_method, status, metadata, collection, importance, parent_id,
+			session_id, expires_at, has_ready_version)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, COALESCE(?, 1.0), NULLIF(?, 0), ?, NULLIF(?, ''), ? = 'ready')
 		ON CONFLICT(path) DO UPDATE SET
 			filename = excluded.filename,
 			format = excluded.format,
 			content_hash = excluded.content_hash,
 			parse_method = excluded.parse_method,
 			status = excluded.status,
+			has_ready_version = documents.has_ready_version OR excluded.has_ready_version,
 			metadata = excluded.metadata,
+			collection = COALESCE(NULLIF(excluded.collection, ''), documents.collection),
+			importance = COALESCE(?, documents.importance),
+			parent_id = excluded.parent_id,
+			session_id = excluded.session_id,
+			expires_at = excluded.expires_at,
 			updated_at = CURRENT_TIMESTAMP
-	`, doc.Path, doc.Filename, doc.Format, doc.ContentHash, doc.ParseMethod, doc.Status, doc.Metadata)
-	if err != nil {
-		return 0, err
-	}
-
-	id, err := res.LastInsertId()
+	`, doc.Path, doc.Filename, doc.Format, doc.ContentHash, doc.ParseMethod, doc.Status, doc.Metadata, doc.Collection,
+				importance, doc.ParentID, doc.SessionID, doc.ExpiresAt, doc.Status, importance)
+			if err != nil {
+				return err
+			}
+			id, err = res.LastInsertId()
+			return err
+		})
+	})
 	if err != nil {
 		return 0, err
 	}
@@ -226,44 +448,41 @@ func (s *Store) UpsertDocument(ctx context.Context, doc Document) (int64, error)
 
 // GetDocumentByPath retrieves a document by its file path.
 func (s *Store) GetDocumentByPath(ctx context.Context, path string) (*Document, error) {
-	doc := &Document{}
-	var metadata sql.NullString
-	err := s.db.QueryRowContext(ctx, `
-		SELECT id, path, filename, format, content_hash, parse_method, status, metadata, created_at, updated_at
-		FROM documents WHERE path = ?
-	`, path).Scan(&doc.ID, &doc.Path, &doc.Filename, &doc.Format,
-		&doc.ContentHash, &doc.ParseMethod, &doc.Status,
-		&metadata, &doc.CreatedAt, &doc.UpdatedAt)
-	if err != nil {
-		return nil, err
-	}
-	doc.Metadata = metadata.String
-	return doc, nil
+	return scanDocument(s.db.QueryRowContext(ctx,
+		"SELECT "+documentColumns+" FROM documents WHERE path = ?", path))
 }
 
 // GetDocument retrieves a document by ID.
 func (s *Store) GetDocument(ctx context.Context, id int64) (*Document, error) {
-	doc := &Document{}
-	var metadata sql.NullString
-	err := s.db.QueryRowContext(ctx, `
-		SELECT id, path, filename, format, content_hash, parse_method, status, metadata, created_at, updated_at
-		FROM documents WHERE id = ?
-	`, id).Scan(&doc.ID, &doc.Path, &doc.Filename, &doc.Format,
-		&doc.ContentHash, &doc.ParseMethod, &doc.Status,
-		&metadata, &doc.CreatedAt, &doc.UpdatedAt)
+	return scanDocument(s.db.QueryRowContext(ctx,
+		"SELECT "+documentColumns+" FROM documents WHERE id = ?", id))
+}
+
+// ListDocuments returns all documents ordered by creation time.
+func (s *Store) ListDocuments(ctx context.Context) ([]Document, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+documentColumns+" FROM documents ORDER BY created_at DESC")
 	if err != nil {
 		return nil, err
 	}
-	doc.Metadata = metadata.String
-	return doc, nil
+	defer rows.Close()
+
+	var docs []Document
+	for rows.Next() {
+		d, err := scanDocument(rows)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, *d)
+	}
+	return docs, rows.Err()
 }
 
-// ListDocuments returns all documents ordered by creation time.
-func (s *Store) ListDocuments(ctx context.Context) ([]Document, error) {
-	rows, err := s.db.QueryContext(ctx, `
-		SELECT id, path, filename, format, content_hash, parse_method, status, metadata, created_at, updated_at
-		FROM documents ORDER BY created_at DESC
-	`)
+// ListChildDocuments returns the documents extracted from the archive
+// document parentID.
+func (s *Store) ListChildDocuments(ctx context.Context, parentID int64) ([]Document, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+documentColumns+" FROM documents WHERE parent_id = ? ORDER BY path", parentID)
 	if err != nil {
 		return nil, err
 	}
@@ -271,37 +490,248 @@ func (s *Store) ListDocuments(ctx context.Context) ([]Document, error) {
 
 	var docs []Document
 	for rows.Next() {
-		var d Document
-		var metadata sql.NullString
-		if err := rows.Scan(&d.ID, &d.Path, &d.Filename, &d.Format,
-			&d.ContentHash, &d.ParseMethod, &d.Status,
-			&metadata, &d.CreatedAt, &d.UpdatedAt); err != nil {
+		d, err := scanDocument(rows)
+		if err != nil {
 			return nil, err
 		}
-		d.Metadata = metadata.String
-		docs = append(docs, d)
+		docs = append(docs, *d)
 	}
 	return docs, rows.Err()
 }
 
+// UpdateDocumentSummary stores the LLM-generated summary and keyword list
+// (a JSON array) for a document.
+func (s *Store) UpdateDocumentSummary(ctx context.Context, id int64, summary, keywords string) error {
+	defer s.summariesChanged()
+	_, err := s.exec(ctx,
+		"UPDATE documents SET summary = ?, keywords = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		summary, keywords, id)
+	return err
+}
+
+// UpdateDocumentQuality stores a document's JSON quality report.
+func (s *Store) UpdateDocumentQuality(ctx context.Context, id int64, quality string) error {
+	_, err := s.exec(ctx,
+		"UPDATE documents SET quality = ? WHERE id = ?", quality, id)
+	return err
+}
+
+// DocumentStats are the stored counts behind a document's quality report.
+type DocumentStats struct {
+	Chunks                 int
+	TokenCounts            []int // per chunk, in document order
+	ChunksWithoutEmbedding int
+	Images                 int
+	Entities               int // distinct entities linked to the document's chunks
+}
+
+// DocumentStats counts a document's chunks, chunk tokens, missing chunk
+// vectors, images, and linked entities.
+func (s *Store) DocumentStats(ctx context.Context, docID int64) (*DocumentStats, error) {
+	st := &DocumentStats{}
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT token_count FROM chunks WHERE document_id = ? ORDER BY position_in_doc", docID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var n int
+		if err := rows.Scan(&n); err != nil {
+			return nil, err
+		}
+		st.TokenCounts = append(st.TokenCounts, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	st.Chunks = len(st.TokenCounts)
+
+	err = s.db.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM chunks WHERE document_id = ?1
+				AND id NOT IN (SELECT chunk_id FROM vec_chunks)),
+			(SELECT COUNT(*) FROM chunk_images WHERE document_id = ?1),
+			(SELECT COUNT(DISTINCT ec.entity_id) FROM entity_chunks ec
+				JOIN chunks c ON c.id = ec.chunk_id WHERE c.document_id = ?1)`,
+		docID).Scan(&st.ChunksWithoutEmbedding, &st.Images, &st.Entities)
+	if err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// DocumentSummary is the summary/keyword projection of a document row used
+// for query-time document selection.
+type DocumentSummary struct {
+	DocumentID int64  `json:"document_id"`
+	Filename   string `json:"filename"`
+	Summary    string `json:"summary"`
+	Keywords   string `json:"keywords"` // JSON array
+}
+
+// summaryCacheTTL bounds how long DocumentSummaries serves its cached
+// list. Writes through the store clear it at once; the TTL catches writes
+// through other connections, such as the primary a reader follows.
+const summaryCacheTTL = 10 * time.Second
+
+// summaryCache holds the last DocumentSummaries result, which retrieval
+// reads on every query.
+type summaryCache struct {
+	mu     sync.Mutex
+	docs   []DocumentSummary
+	loaded time.Time // zero when docs is not valid
+	gen    uint64    // bumped by summariesChanged
+}
+
+// summariesChanged drops the cached document summaries after a write to
+// the documents they are read from.
+func (s *Store) summariesChanged() {
+	s.summaries.mu.Lock()
+	s.summaries.gen++
+	s.summaries.docs, s.summaries.loaded = nil, time.Time{}
+	s.summaries.mu.Unlock()
+}
+
+// DocumentSummaries returns the summary and keywords of every document that
+// has at least one of them set, under SetSearchReadyOnly only of the
+// documents with a ready version. The result is cached (see
+// summaryCacheTTL) and must not be modified.
+func (s *Store) DocumentSummaries(ctx context.Context) ([]DocumentSummary, error) {
+	s.summaries.mu.Lock()
+	if !s.summaries.loaded.IsZero() && time.Since(s.summaries.loaded) < summaryCacheTTL {
+		docs := s.summaries.docs
+		s.summaries.mu.Unlock()
+		return docs, nil
+	}
+	gen := s.summaries.gen
+	s.summaries.mu.Unlock()
+
+	ready := ""
+	if s.searchReady {
+		ready = "AND has_ready_version = 1"
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, filename, COALESCE(summary, ''), COALESCE(keywords, '')
+		FROM documents
+		WHERE (COALESCE(summary, '') != '' OR COALESCE(keywords, '') != '') `+ready)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []DocumentSummary
+	for rows.Next() {
+		var d DocumentSummary
+		if err := rows.Scan(&d.DocumentID, &d.Filename, &d.Summary, &d.Keywords); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// A write during the load may not be in it; leave the cache empty.
+	s.summaries.mu.Lock()
+	if s.summaries.gen == gen {
+		s.summaries.docs, s.summaries.loaded = out, time.Now()
+	}
+	s.summaries.mu.Unlock()
+	return out, nil
+}
+
+// SetDocumentImportance sets the multiplier applied to the fused retrieval
+// scores of a document's chunks.
+func (s *Store) SetDocumentImportance(ctx context.Context, id int64, importance float64) error {
+	_, err := s.exec(ctx,
+		"UPDATE documents SET importance = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		importance, id)
+	return err
+}
+
+// DocumentImportance returns the importance of every document whose
+// importance is not the default 1.
+func (s *Store) DocumentImportance(ctx context.Context) (map[int64]float64, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, importance FROM documents WHERE importance != 1")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[int64]float64)
+	for rows.Next() {
+		var id int64
+		var w float64
+		if err := rows.Scan(&id, &w); err != nil {
+			return nil, err
+		}
+		out[id] = w
+	}
+	return out, rows.Err()
+}
+
+// SetDocumentCollection moves a document to a collection ("" = none).
+func (s *Store) SetDocumentCollection(ctx context.Context, id int64, collection string) error {
+	_, err := s.exec(ctx,
+		"UPDATE documents SET collection = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		collection, id)
+	return err
+}
+
+// DocumentIDsInCollection returns the IDs of the documents in a collection.
+func (s *Store) DocumentIDsInCollection(ctx context.Context, collection string) ([]int64, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT id FROM documents WHERE collection = ? ORDER BY id", collection)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
 // UpdateDocumentStatus updates just the status field.
 func (s *Store) UpdateDocumentStatus(ctx context.Context, id int64, status string) error {
-	_, err := s.db.ExecContext(ctx,
-		"UPDATE documents SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
-		status, id)
+	defer s.summariesChanged()
+	_, err := s.exec(ctx, `
+		UPDATE documents SET status = ?, has_ready_version = has_ready_version OR ? = 'ready',
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`,
+		status, status, id)
 	return err
 }
 
 // UpdateDocumentParseMethod updates just the parse_method field.
 func (s *Store) UpdateDocumentParseMethod(ctx context.Context, id int64, method string) error {
-	_, err := s.db.ExecContext(ctx,
+	_, err := s.exec(ctx,
 		"UPDATE documents SET parse_method = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
 		method, id)
 	return err
 }
 
-// DeleteDocument removes a document and cascades to all related data.
+// DeleteDocument removes a document and cascades to all related data,
+// and to the documents extracted from it when it is an archive.
 func (s *Store) DeleteDocument(ctx context.Context, id int64) error {
+	defer s.summariesChanged()
+	children, err := s.ListChildDocuments(ctx, id)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if err := s.DeleteDocument(ctx, child.ID); err != nil {
+			return fmt.Errorf("deleting archive member %d: %w", child.ID, err)
+		}
+	}
+
 	return s.inTx(ctx, func(tx *sql.Tx) error {
 		// Delete entity_chunks for entities related to this doc's chunks
 		if _, err := tx.ExecContext(ctx, `
@@ -327,7 +757,33 @@ func (s *Store) DeleteDocument(ctx context.Context, id int64) error {
 			return err
 		}
 
+		// Delete sparse embeddings
+		if _, err := tx.ExecContext(ctx, `
+			DELETE FROM sparse_chunks WHERE chunk_id IN (
+				SELECT id FROM chunks WHERE document_id = ?
+			)`, id); err != nil {
+			return err
+		}
+
+		if s.secondaryVec {
+			if _, err := tx.ExecContext(ctx, `
+				DELETE FROM vec_chunks_secondary WHERE chunk_id IN (
+					SELECT id FROM chunks WHERE document_id = ?
+				)`, id); err != nil {
+				return err
+			}
+		}
+
 		// Delete chunk images
+		if s.imageVectors {
+			if _, err := tx.ExecContext(ctx, `
+				DELETE FROM vec_images WHERE image_id IN (
+					SELECT id FROM chunk_images WHERE document_id = ?
+				)`, id); err != nil {
+				return err
+			}
+		}
+
 		if _, err := tx.ExecContext(ctx,
 			"DELETE FROM chunk_images WHERE document_id = ?", id); err != nil {
 			return err
@@ -339,6 +795,11 @@ func (s *Store) DeleteDocument(ctx context.Context, id int64) error {
 			return err
 		}
 
+		if _, err := tx.ExecContext(ctx,
+			"DELETE FROM embed_summaries WHERE document_id = ?", id); err != nil {
+			return err
+		}
+
 		// Delete the document
 		if _, err := tx.ExecContext(ctx,
 			"DELETE FROM documents WHERE id = ?", id); err != nil {
@@ -374,45 +835,233 @@ func (s *Store) DeleteDocumentData(ctx context.Context, docID int64) error {
 			return err
 		}
 
+		if _, err := tx.ExecContext(ctx, `
+			DELETE FROM sparse_chunks WHERE chunk_id IN (
+				SELECT id FROM chunks WHERE document_id = ?
+			)`, docID); err != nil {
+			return err
+		}
+
+		if s.secondaryVec {
+			if _, err := tx.ExecContext(ctx, `
+				DELETE FROM vec_chunks_secondary WHERE chunk_id IN (
+					SELECT id FROM chunks WHERE document_id = ?
+				)`, docID); err != nil {
+				return err
+			}
+		}
+
+		if s.imageVectors {
+			if _, err := tx.ExecContext(ctx, `
+				DELETE FROM vec_images WHERE image_id IN (
+					SELECT id FROM chunk_images WHERE document_id = ?
+				)`, docID); err != nil {
+				return err
+			}
+		}
+
 		if _, err := tx.ExecContext(ctx,
 			"DELETE FROM chunk_images WHERE document_id = ?", docID); err != nil {
 			return err
 		}
 
 		if _, err := tx.ExecContext(ctx,
-			"DELETE FROM chunks WHERE document_id = ?", docID); err != nil {
+			"DELETE FROM glossary WHERE document_id = ?", docID); err != nil {
 			return err
 		}
 
-		return nil
-	})
-}
+		if _, err := tx.ExecContext(ctx,
+			"DELETE FROM key_facts WHERE document_id = ?", docID); err != nil {
+			return err
+		}
 
-// --- Chunk operations ---
+		if _, err := tx.ExecContext(ctx, `
+			DELETE FROM chunk_annotations WHERE chunk_id IN (
+				SELECT id FROM chunks WHERE document_id = ?
+			)`, docID); err != nil {
+			return err
+		}
 
-// InsertChunks inserts a batch of chunks and returns their IDs.
-// The chunker assigns temporary position-based IDs; this method remaps
-// ParentChunkID values to the real database IDs as chunks are inserted.
-func (s *Store) InsertChunks(ctx context.Context, chunks []Chunk) ([]int64, error) {
-	ids := make([]int64, len(chunks))
+		if _, err := tx.ExecContext(ctx, `
+			DELETE FROM chunk_usage WHERE chunk_id IN (
+				SELECT id FROM chunks WHERE document_id = ?
+			)`, docID); err != nil {
+			return err
+		}
 
-	// Map from temporary position-based ID to real DB ID.
-	idMap := make(map[int64]int64, len(chunks))
+		if _, err := tx.ExecContext(ctx,
+			"DELETE FROM chunk_quantities WHERE document_id = ?", docID); err != nil {
+			return err
+		}
 
-	err := s.inTx(ctx, func(tx *sql.Tx) error {
-		stmt, err := tx.PrepareContext(ctx, `
-			INSERT INTO chunks (document_id, parent_chunk_id, content, chunk_type, heading,
-				page_number, position_in_doc, token_count, metadata, content_hash)
-			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-		`)
-		if err != nil {
+		if _, err := tx.ExecContext(ctx,
+			"DELETE FROM chunks WHERE document_id = ?", docID); err != nil {
 			return err
 		}
-		defer stmt.Close()
+
+		return nil
+	})
+}
+
+// CompactStats reports what Compact removed and the space it reclaimed.
+type CompactStats struct {
+	Entities      int   `json:"entities"`      // entities no chunk mentions any more
+	Relationships int   `json:"relationships"` // relationships from deleted chunks or entities
+	Vectors       int   `json:"vectors"`       // vector and sparse rows of deleted chunks, images, or entities
+	BytesBefore   int64 `json:"bytes_before"`
+	BytesAfter    int64 `json:"bytes_after"`
+}
+
+// Compact removes the data deletes leave behind: relationships whose
+// source chunk is gone, entities no chunk links to (with their
+// relationships and description vectors), and vector rows whose chunk or
+// image no longer exists. It then optimizes the FTS index and vacuums the
+// database. Sizes are measured from page counts, before and after.
+func (s *Store) Compact(ctx context.Context) (*CompactStats, error) {
+	stats := &CompactStats{}
+	var err error
+	if stats.BytesBefore, err = s.databaseSize(ctx); err != nil {
+		return nil, err
+	}
+
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		exec := func(query string) (int, error) {
+			res, err := tx.ExecContext(ctx, query)
+			if err != nil {
+				return 0, err
+			}
+			n, _ := res.RowsAffected()
+			return int(n), nil
+		}
+
+		n, err := exec(`DELETE FROM relationships
+			WHERE source_chunk_id IS NOT NULL
+			  AND source_chunk_id NOT IN (SELECT id FROM chunks)`)
+		if err != nil {
+			return fmt.Errorf("relationships of deleted chunks: %w", err)
+		}
+		stats.Relationships += n
+
+		// Relationships of orphaned entities are removed explicitly so they
+		// are counted; the foreign key cascade would remove them silently.
+		// Imported entities are not orphans: no chunk is expected to link
+		// to them.
+		n, err = exec(`DELETE FROM relationships
+			WHERE source_entity_id NOT IN (SELECT entity_id FROM entity_chunks
+				UNION SELECT id FROM entities WHERE import_source IS NOT NULL)
+			   OR target_entity_id NOT IN (SELECT entity_id FROM entity_chunks
+				UNION SELECT id FROM entities WHERE import_source IS NOT NULL)`)
+		if err != nil {
+			return fmt.Errorf("relationships of orphaned entities: %w", err)
+		}
+		stats.Relationships += n
+
+		if stats.Entities, err = exec(`DELETE FROM entities
+			WHERE id NOT IN (SELECT entity_id FROM entity_chunks)
+			  AND import_source IS NULL`); err != nil {
+			return fmt.Errorf("orphaned entities: %w", err)
+		}
+
+		orphans := []string{
+			`DELETE FROM vec_entities WHERE entity_id NOT IN (SELECT id FROM entities)`,
+			`DELETE FROM vec_chunks WHERE chunk_id NOT IN (SELECT id FROM chunks)`,
+			`DELETE FROM sparse_chunks WHERE chunk_id NOT IN (SELECT id FROM chunks)`,
+		}
+		if s.secondaryVec {
+			orphans = append(orphans,
+				`DELETE FROM vec_chunks_secondary WHERE chunk_id NOT IN (SELECT id FROM chunks)`)
+		}
+		if s.imageVectors {
+			orphans = append(orphans,
+				`DELETE FROM vec_images WHERE image_id NOT IN (SELECT id FROM chunk_images)`)
+		}
+		for _, q := range orphans {
+			n, err := exec(q)
+			if err != nil {
+				return fmt.Errorf("orphaned vectors: %w", err)
+			}
+			stats.Vectors += n
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.exec(ctx, `INSERT INTO chunks_fts(chunks_fts) VALUES('optimize')`); err != nil {
+		return nil, fmt.Errorf("optimizing fts index: %w", err)
+	}
+	var sealed bool
+	if err := s.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM chunks_fts_sealed)").Scan(&sealed); err != nil {
+		return nil, fmt.Errorf("checking encrypted fts index: %w", err)
+	}
+	if sealed {
+		if _, err := s.exec(ctx, `INSERT INTO chunks_fts_sealed(chunks_fts_sealed) VALUES('optimize')`); err != nil {
+			return nil, fmt.Errorf("optimizing encrypted fts index: %w", err)
+		}
+	}
+	if _, err := s.exec(ctx, "VACUUM"); err != nil {
+		return nil, fmt.Errorf("vacuum: %w", err)
+	}
+	// VACUUM writes the whole database through the WAL; truncate it so the
+	// reclaimed space shows on disk too. When a replicator owns checkpoints
+	// only fold back what it has already copied.
+	mode := CheckpointTruncate
+	if s.manualCheckpoint {
+		mode = CheckpointPassive
+	}
+	if _, err := s.Checkpoint(ctx, mode); err != nil {
+		return nil, err
+	}
+
+	if stats.BytesAfter, err = s.databaseSize(ctx); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// databaseSize returns the size of the main database in bytes.
+func (s *Store) databaseSize(ctx context.Context) (int64, error) {
+	var pages, pageSize int64
+	if err := s.db.QueryRowContext(ctx, "PRAGMA page_count").Scan(&pages); err != nil {
+		return 0, fmt.Errorf("reading page count: %w", err)
+	}
+	if err := s.db.QueryRowContext(ctx, "PRAGMA page_size").Scan(&pageSize); err != nil {
+		return 0, fmt.Errorf("reading page size: %w", err)
+	}
+	return pages * pageSize, nil
+}
+
+// --- Chunk operations ---
+
+// InsertChunks inserts a batch of chunks and returns their IDs.
+// The chunker assigns temporary position-based IDs; this method remaps
+// ParentChunkID values to the real database IDs as chunks are inserted.
+func (s *Store) InsertChunks(ctx context.Context, chunks []Chunk) ([]int64, error) {
+	ids := make([]int64, len(chunks))
+
+	// Map from temporary position-based ID to real DB ID.
+	idMap := make(map[int64]int64, len(chunks))
+
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, `
+			INSERT INTO chunks (document_id, parent_chunk_id, content, chunk_type, heading,
+				page_number, position_in_doc, token_count, metadata, content_hash,
+				start_offset, end_offset)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		seal := s.contentSealer(ctx, tx)
 
 		for i, c := range chunks {
-			hash := sha256.Sum256([]byte(c.Content))
-			contentHash := hex.EncodeToString(hash[:])
+			contentHash := chunkHash(c.Content)
+			content, err := seal.text(c.DocumentID, c.Content)
+			if err != nil {
+				return err
+			}
 
 			// Remap parent_chunk_id from temporary to real DB ID.
 			var parentID *int64
@@ -423,9 +1072,9 @@ func (s *Store) InsertChunks(ctx context.Context, chunks []Chunk) ([]int64, erro
 			}
 
 			res, err := stmt.ExecContext(ctx,
-				c.DocumentID, parentID, c.Content, c.ChunkType,
+				c.DocumentID, parentID, content, c.ChunkType,
 				c.Heading, c.PageNumber, c.PositionInDoc, c.TokenCount,
-				c.Metadata, contentHash)
+				c.Metadata, contentHash, c.StartOffset, c.EndOffset)
 			if err != nil {
 				return err
 			}
@@ -433,6 +1082,9 @@ func (s *Store) InsertChunks(ctx context.Context, chunks []Chunk) ([]int64, erro
 			if err != nil {
 				return err
 			}
+			if err := indexSealed(ctx, tx, ids[i], content, c.Content, c.Heading); err != nil {
+				return err
+			}
 			idMap[c.ID] = ids[i]
 		}
 		return nil
@@ -441,54 +1093,1310 @@ func (s *Store) InsertChunks(ctx context.Context, chunks []Chunk) ([]int64, erro
 	return ids, err
 }
 
-// GetChunksByDocument returns all chunks for a given document.
-func (s *Store) GetChunksByDocument(ctx context.Context, docID int64) ([]Chunk, error) {
-	rows, err := s.db.QueryContext(ctx, `
-		SELECT id, document_id, parent_chunk_id, content, chunk_type, heading,
-			page_number, position_in_doc, token_count, metadata, content_hash
-		FROM chunks WHERE document_id = ? ORDER BY position_in_doc
-	`, docID)
+// ChunkSync reports how SyncChunks reconciled a document's chunks.
+type ChunkSync struct {
+	// IDs holds the database ID of each input chunk, in input order.
+	IDs []int64
+	// Added lists the indexes of input chunks that were inserted; they
+	// need embeddings and graph extraction.
+	Added []int
+	// Kept and Removed count existing chunks that were kept or deleted.
+	Kept    int
+	Removed int
+}
+
+// SyncChunks replaces a document's chunks with chunks, keeping existing
+// chunks whose content hash is unchanged. Kept chunks retain their IDs,
+// embeddings, FTS rows, and entity links, so citations of them in the
+// query log stay resolvable; only their position, heading, and other
+// metadata are updated. Chunks no longer present are deleted with their
+// vectors, FTS rows, and graph links, and new chunks are inserted.
+// Document-level derived data (glossary and images) is cleared for the
+// caller to rebuild.
+func (s *Store) SyncChunks(ctx context.Context, docID int64, chunks []Chunk) (*ChunkSync, error) {
+	res := &ChunkSync{IDs: make([]int64, len(chunks))}
+
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx,
+			"SELECT id, content_hash FROM chunks WHERE document_id = ? ORDER BY position_in_doc, id", docID)
+		if err != nil {
+			return err
+		}
+		existing := make(map[string][]int64)
+		for rows.Next() {
+			var id int64
+			var hash string
+			if err := rows.Scan(&id, &hash); err != nil {
+				rows.Close()
+				return err
+			}
+			existing[hash] = append(existing[hash], id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		insert, err := tx.PrepareContext(ctx, `
+			INSERT INTO chunks (document_id, parent_chunk_id, content, chunk_type, heading,
+				page_number, position_in_doc, token_count, metadata, content_hash,
+				start_offset, end_offset)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`)
+		if err != nil {
+			return err
+		}
+		defer insert.Close()
+		update, err := tx.PrepareContext(ctx, `
+			UPDATE chunks SET parent_chunk_id = ?, chunk_type = ?, heading = ?, page_number = ?,
+				position_in_doc = ?, token_count = ?, metadata = ?, start_offset = ?, end_offset = ?
+			WHERE id = ?
+		`)
+		if err != nil {
+			return err
+		}
+		defer update.Close()
+		seal := s.contentSealer(ctx, tx)
+
+		idMap := make(map[int64]int64, len(chunks))
+		for i, c := range chunks {
+			contentHash := chunkHash(c.Content)
+			var parentID *int64
+			if c.ParentChunkID != nil {
+				if realID, ok := idMap[*c.ParentChunkID]; ok {
+					parentID = &realID
+				}
+			}
+
+			if ids := existing[contentHash]; len(ids) > 0 {
+				id := ids[0]
+				existing[contentHash] = ids[1:]
+				if _, err := update.ExecContext(ctx,
+					parentID, c.ChunkType, c.Heading, c.PageNumber, c.PositionInDoc,
+					c.TokenCount, c.Metadata, c.StartOffset, c.EndOffset, id); err != nil {
+					return err
+				}
+				if seal != nil {
+					if err := reindexSealed(ctx, tx, id, c.Content, c.Heading); err != nil {
+						return err
+					}
+				}
+				res.IDs[i] = id
+				res.Kept++
+			} else {
+				content, err := seal.text(docID, c.Content)
+				if err != nil {
+					return err
+				}
+				r, err := insert.ExecContext(ctx,
+					docID, parentID, content, c.ChunkType,
+					c.Heading, c.PageNumber, c.PositionInDoc, c.TokenCount,
+					c.Metadata, contentHash, c.StartOffset, c.EndOffset)
+				if err != nil {
+					return err
+				}
+				if res.IDs[i], err = r.LastInsertId(); err != nil {
+					return err
+				}
+				if err := indexSealed(ctx, tx, res.IDs[i], content, c.Content, c.Heading); err != nil {
+					return err
+				}
+				res.Added = append(res.Added, i)
+			}
+			idMap[c.ID] = res.IDs[i]
+		}
+
+		// Images, the glossary, and key facts are rebuilt from the new parse.
+		if s.imageVectors {
+			if _, err := tx.ExecContext(ctx, `
+				DELETE FROM vec_images WHERE image_id IN (
+					SELECT id FROM chunk_images WHERE document_id = ?
+				)`, docID); err != nil {
+				return err
+			}
+		}
+		for _, q := range []string{
+			"DELETE FROM chunk_images WHERE document_id = ?",
+			"DELETE FROM glossary WHERE document_id = ?",
+			"DELETE FROM key_facts WHERE document_id = ?",
+		} {
+			if _, err := tx.ExecContext(ctx, q, docID); err != nil {
+				return err
+			}
+		}
+
+		removes := []string{
+			"DELETE FROM entity_chunks WHERE chunk_id = ?",
+			"UPDATE chunks SET parent_chunk_id = NULL WHERE parent_chunk_id = ?",
+			"DELETE FROM relationships WHERE source_chunk_id = ?",
+			"DELETE FROM vec_chunks WHERE chunk_id = ?",
+			"DELETE FROM sparse_chunks WHERE chunk_id = ?",
+			"DELETE FROM chunk_annotations WHERE chunk_id = ?",
+			"DELETE FROM chunk_quantities WHERE chunk_id = ?",
+		}
+		if s.secondaryVec {
+			removes = append(removes, "DELETE FROM vec_chunks_secondary WHERE chunk_id = ?")
+		}
+		removes = append(removes, "DELETE FROM chunks WHERE id = ?")
+		for _, ids := range existing {
+			for _, id := range ids {
+				for _, q := range removes {
+					if _, err := tx.ExecContext(ctx, q, id); err != nil {
+						return err
+					}
+				}
+				res.Removed++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func chunkHash(content string) string {
+	hash := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(hash[:])
+}
+
+// GetChunksByDocument returns all chunks for a given document.
+func (s *Store) GetChunksByDocument(ctx context.Context, docID int64) ([]Chunk, error) {
+	return s.queryDocumentChunks(ctx, "content", "", docID)
+}
+
+// GetChunkPage returns up to limit chunks of a document in document order,
+// skipping the first offset.
+func (s *Store) GetChunkPage(ctx context.Context, docID int64, offset, limit int) ([]Chunk, error) {
+	return s.queryDocumentChunks(ctx, "content", " LIMIT ? OFFSET ?", docID, limit, offset)
+}
+
+// ChunkOutline returns every chunk of a document in document order with
+// an empty Content, for walking its structure without loading the text.
+func (s *Store) ChunkOutline(ctx context.Context, docID int64) ([]Chunk, error) {
+	return s.queryDocumentChunks(ctx, "''", "", docID)
+}
+
+// queryDocumentChunks selects a document's chunks in document order, with
+// content as the content column and tail appended to the query.
+func (s *Store) queryDocumentChunks(ctx context.Context, content, tail string, args ...interface{}) ([]Chunk, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, document_id, parent_chunk_id, `+content+`, chunk_type, heading,
+			page_number, position_in_doc, token_count, metadata, content_hash,
+			COALESCE(start_offset, 0), COALESCE(end_offset, 0)
+		FROM chunks WHERE document_id = ? ORDER BY position_in_doc`+tail, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chunks []Chunk
+	for rows.Next() {
+		var c Chunk
+		var metadata sql.NullString
+		if err := rows.Scan(&c.ID, &c.DocumentID, &c.ParentChunkID, &c.Content,
+			&c.ChunkType, &c.Heading, &c.PageNumber, &c.PositionInDoc,
+			&c.TokenCount, &metadata, &c.ContentHash,
+			&c.StartOffset, &c.EndOffset); err != nil {
+			return nil, err
+		}
+		if c.Content, err = s.openText(c.Content); err != nil {
+			return nil, fmt.Errorf("chunk %d: %w", c.ID, err)
+		}
+		c.Metadata = metadata.String
+		chunks = append(chunks, c)
+	}
+	return chunks, rows.Err()
+}
+
+// GetChunk returns a chunk by ID, or sql.ErrNoRows.
+func (s *Store) GetChunk(ctx context.Context, id int64) (*Chunk, error) {
+	var c Chunk
+	var metadata sql.NullString
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, document_id, parent_chunk_id, content, chunk_type, heading,
+			page_number, position_in_doc, token_count, metadata, content_hash,
+			COALESCE(start_offset, 0), COALESCE(end_offset, 0)
+		FROM chunks WHERE id = ?
+	`, id).Scan(&c.ID, &c.DocumentID, &c.ParentChunkID, &c.Content,
+		&c.ChunkType, &c.Heading, &c.PageNumber, &c.PositionInDoc,
+		&c.TokenCount, &metadata, &c.ContentHash,
+		&c.StartOffset, &c.EndOffset)
+	if err != nil {
+		return nil, err
+	}
+	if c.Content, err = s.openText(c.Content); err != nil {
+		return nil, fmt.Errorf("chunk %d: %w", c.ID, err)
+	}
+	c.Metadata = metadata.String
+	return &c, nil
+}
+
+// --- Chunk image operations ---
+
+// InsertChunkImages batch-inserts images associated with chunks.
+func (s *Store) InsertChunkImages(ctx context.Context, images []ChunkImage) error {
+	if len(images) == 0 {
+		return nil
+	}
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, `
+			INSERT INTO chunk_images (chunk_id, document_id, caption, mime_type, width, height, page_number, data)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		seal := s.contentSealer(ctx, tx)
+
+		for _, img := range images {
+			caption, err := seal.text(img.DocumentID, img.Caption)
+			if err != nil {
+				return err
+			}
+			data, err := seal.bytes(img.DocumentID, img.Data)
+			if err != nil {
+				return err
+			}
+			if _, err := stmt.ExecContext(ctx,
+				img.ChunkID, img.DocumentID, caption, img.MIMEType,
+				img.Width, img.Height, img.PageNumber, data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetImagesByChunkIDs returns images grouped by chunk ID.
+// When includeData is false, the Data field is left empty to avoid loading BLOBs.
+func (s *Store) GetImagesByChunkIDs(ctx context.Context, chunkIDs []int64, includeData bool) (map[int64][]ChunkImage, error) {
+	if len(chunkIDs) == 0 {
+		return nil, nil
+	}
+
+	dataCol := "NULL"
+	if includeData {
+		dataCol = "data"
+	}
+
+	query := fmt.Sprintf(`SELECT id, chunk_id, document_id, caption, mime_type, width, height, page_number, %s
+		FROM chunk_images WHERE chunk_id IN (?%s) ORDER BY id`,
+		dataCol, repeatPlaceholders(len(chunkIDs)-1))
+
+	args := make([]interface{}, len(chunkIDs))
+	for i, id := range chunkIDs {
+		args[i] = id
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[int64][]ChunkImage)
+	for rows.Next() {
+		var img ChunkImage
+		var caption sql.NullString
+		if err := rows.Scan(&img.ID, &img.ChunkID, &img.DocumentID, &caption,
+			&img.MIMEType, &img.Width, &img.Height, &img.PageNumber, &img.Data); err != nil {
+			return nil, err
+		}
+		if err := s.openImage(&img, caption.String); err != nil {
+			return nil, err
+		}
+		result[img.ChunkID] = append(result[img.ChunkID], img)
+	}
+	return result, rows.Err()
+}
+
+// --- Image embedding operations ---
+
+// EnableImageVectors creates the vec_images table for multimodal image
+// embeddings of the given dimension. Safe to call on every start.
+func (s *Store) EnableImageVectors(ctx context.Context, dim int) error {
+	if dim <= 0 {
+		return fmt.Errorf("image embedding dimension must be positive, got %d", dim)
+	}
+	if _, err := s.exec(ctx, fmt.Sprintf(`
+		CREATE VIRTUAL TABLE IF NOT EXISTS vec_images USING vec0(
+			image_id INTEGER PRIMARY KEY,
+			embedding float[%d]
+		)`, dim)); err != nil {
+		return fmt.Errorf("creating vec_images: %w", err)
+	}
+	s.imageVectors = true
+	return nil
+}
+
+// ImagesWithoutEmbedding returns a document's images (with data) that have
+// no row in vec_images yet.
+func (s *Store) ImagesWithoutEmbedding(ctx context.Context, documentID int64) ([]ChunkImage, error) {
+	if !s.imageVectors {
+		return nil, fmt.Errorf("image vectors not enabled")
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, chunk_id, document_id, caption, mime_type, width, height, page_number, data
+		FROM chunk_images
+		WHERE document_id = ? AND id NOT IN (SELECT image_id FROM vec_images)
+		ORDER BY id`, documentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var images []ChunkImage
+	for rows.Next() {
+		var img ChunkImage
+		var caption sql.NullString
+		if err := rows.Scan(&img.ID, &img.ChunkID, &img.DocumentID, &caption,
+			&img.MIMEType, &img.Width, &img.Height, &img.PageNumber, &img.Data); err != nil {
+			return nil, err
+		}
+		if err := s.openImage(&img, caption.String); err != nil {
+			return nil, err
+		}
+		images = append(images, img)
+	}
+	return images, rows.Err()
+}
+
+// InsertImageEmbedding stores a multimodal embedding for an image.
+func (s *Store) InsertImageEmbedding(ctx context.Context, imageID int64, embedding []float32) error {
+	_, err := s.exec(ctx,
+		"INSERT OR REPLACE INTO vec_images (image_id, embedding) VALUES (?, ?)",
+		imageID, serializeFloat32(embedding))
+	return err
+}
+
+// ImageVectorSearch performs a KNN search over image embeddings and returns
+// the chunks owning the top-k images. A chunk with several matching images
+// is scored by its closest one.
+func (s *Store) ImageVectorSearch(ctx context.Context, queryEmbedding []float32, k int) ([]RetrievalResult, error) {
+	if !s.imageVectors {
+		return nil, nil
+	}
+	window, err := s.knnWindow(ctx, "chunk_images", k)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		WITH knn AS (
+			SELECT image_id, distance FROM vec_images
+			WHERE embedding MATCH ? AND k = ?
+		)
+		SELECT ci.chunk_id, MIN(knn.distance) AS distance,
+			c.content, c.heading, c.chunk_type, c.page_number, c.position_in_doc,
+			COALESCE(c.start_offset, 0), COALESCE(c.end_offset, 0),
+			c.metadata, c.document_id,
+			d.filename, d.path, d.metadata
+		FROM knn
+		JOIN chunk_images ci ON ci.id = knn.image_id
+		JOIN chunks c ON c.id = ci.chunk_id
+		`+s.documentsJoin()+`
+		GROUP BY ci.chunk_id
+		ORDER BY distance
+		LIMIT ?
+	`, serializeFloat32(queryEmbedding), window, k)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []RetrievalResult
+	for rows.Next() {
+		var r RetrievalResult
+		var distance float64
+		var chunkMeta, docMeta sql.NullString
+		if err := rows.Scan(&r.ChunkID, &distance,
+			&r.Content, &r.Heading, &r.ChunkType, &r.PageNumber, &r.PositionInDoc,
+			&r.StartOffset, &r.EndOffset,
+			&chunkMeta, &r.DocumentID,
+			&r.Filename, &r.Path, &docMeta); err != nil {
+			return nil, err
+		}
+		r.Score = 1.0 - distance
+		r.ChunkMeta = chunkMeta.String
+		r.DocMeta = docMeta.String
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return s.openResults(results)
+}
+
+// --- Embedding operations ---
+
+// InsertEmbedding stores a vector embedding for a chunk.
+func (s *Store) InsertEmbedding(ctx context.Context, chunkID int64, embedding []float32) error {
+	_, err := s.cachedExec(ctx,
+		"INSERT OR REPLACE INTO vec_chunks (chunk_id, embedding) VALUES (?, ?)",
+		chunkID, serializeFloat32(embedding))
+	return err
+}
+
+// ChunkVectorSpace names the vec_chunks table in embedding_models.
+const ChunkVectorSpace = "chunks"
+
+// EmbeddingModel records which model produced the vectors in a space.
+type EmbeddingModel struct {
+	Space      string `json:"space"`
+	Provider   string `json:"provider"`
+	Model      string `json:"model"`
+	Dim        int    `json:"dim"`
+	RecordedAt string `json:"recorded_at"`
+}
+
+// GetEmbeddingModel returns the model recorded for a vector space, or nil
+// if none has been recorded.
+func (s *Store) GetEmbeddingModel(ctx context.Context, space string) (*EmbeddingModel, error) {
+	m := &EmbeddingModel{Space: space}
+	var provider sql.NullString
+	err := s.db.QueryRowContext(ctx,
+		"SELECT provider, model, dim, recorded_at FROM embedding_models WHERE space = ?", space,
+	).Scan(&provider, &m.Model, &m.Dim, &m.RecordedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	m.Provider = provider.String
+	return m, nil
+}
+
+// SetEmbeddingModel records the model that produced a vector space.
+func (s *Store) SetEmbeddingModel(ctx context.Context, m EmbeddingModel) error {
+	_, err := s.exec(ctx, `
+		INSERT INTO embedding_models (space, provider, model, dim, recorded_at)
+		VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(space) DO UPDATE SET
+			provider = excluded.provider, model = excluded.model,
+			dim = excluded.dim, recorded_at = excluded.recorded_at
+	`, m.Space, m.Provider, m.Model, m.Dim)
+	return err
+}
+
+// HasEmbeddings reports whether any chunk vectors are stored.
+func (s *Store) HasEmbeddings(ctx context.Context) (bool, error) {
+	var exists int
+	err := s.db.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM vec_chunks LIMIT 1)").Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+	return exists == 1, nil
+}
+
+// ResetChunkVectors drops all chunk and entity vectors and recreates
+// vec_chunks and vec_entities with the given dimension, ahead of
+// re-embedding the corpus with a new model.
+func (s *Store) ResetChunkVectors(ctx context.Context, dim int) error {
+	if dim <= 0 {
+		return fmt.Errorf("embedding dimension must be positive, got %d", dim)
+	}
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DROP TABLE IF EXISTS vec_chunks"); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "DROP TABLE IF EXISTS vec_entities"); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`CREATE VIRTUAL TABLE vec_chunks USING vec0(
+			chunk_id INTEGER PRIMARY KEY,
+			embedding float[%d]
+		)`, dim)); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, fmt.Sprintf(`CREATE VIRTUAL TABLE vec_entities USING vec0(
+			entity_id INTEGER PRIMARY KEY,
+			embedding float[%d] distance_metric=cosine
+		)`, dim))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("resetting chunk vectors: %w", err)
+	}
+	s.resetStmts()
+	s.embeddingDim = dim
+	return nil
+}
+
+// StageChunkVectors creates an empty vec_chunks_staged table with the
+// given dimension, replacing any left by an interrupted re-embed. Re-embedding
+// writes there (InsertStagedEmbedding) while searches keep using vec_chunks,
+// until SwapChunkVectors moves the staged vectors in.
+func (s *Store) StageChunkVectors(ctx context.Context, dim int) error {
+	if dim <= 0 {
+		return fmt.Errorf("embedding dimension must be positive, got %d", dim)
+	}
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DROP TABLE IF EXISTS vec_chunks_staged"); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, fmt.Sprintf(`CREATE VIRTUAL TABLE vec_chunks_staged USING vec0(
+			chunk_id INTEGER PRIMARY KEY,
+			embedding float[%d]
+		)`, dim))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("staging chunk vectors: %w", err)
+	}
+	return nil
+}
+
+// InsertStagedEmbedding stores a chunk vector in vec_chunks_staged.
+func (s *Store) InsertStagedEmbedding(ctx context.Context, chunkID int64, embedding []float32) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT OR REPLACE INTO vec_chunks_staged (chunk_id, embedding) VALUES (?, ?)",
+		chunkID, serializeFloat32(embedding))
+	return err
+}
+
+// SwapChunkVectors replaces vec_chunks with the staged vectors in one
+// transaction, so searches see either the old vectors or the new ones.
+// vec_entities is recreated empty with the same dimension, as its vectors
+// came from the old model.
+func (s *Store) SwapChunkVectors(ctx context.Context, dim int) error {
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		stmts := []string{
+			"DROP TABLE IF EXISTS vec_chunks",
+			"DROP TABLE IF EXISTS vec_entities",
+			fmt.Sprintf(`CREATE VIRTUAL TABLE vec_chunks USING vec0(
+			chunk_id INTEGER PRIMARY KEY,
+			embedding float[%d]
+		)`, dim),
+			fmt.Sprintf(`CREATE VIRTUAL TABLE vec_entities USING vec0(
+			entity_id INTEGER PRIMARY KEY,
+			embedding float[%d] distance_metric=cosine
+		)`, dim),
+			"INSERT INTO vec_chunks (chunk_id, embedding) SELECT chunk_id, embedding FROM vec_chunks_staged",
+			"DROP TABLE vec_chunks_staged",
+		}
+		for _, stmt := range stmts {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("swapping in re-embedded chunk vectors: %w", err)
+	}
+	s.resetStmts()
+	s.embeddingDim = dim
+	return nil
+}
+
+// DiscardStagedChunkVectors drops vec_chunks_staged, if any.
+func (s *Store) DiscardStagedChunkVectors(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, "DROP TABLE IF EXISTS vec_chunks_staged")
+	return err
+}
+
+// maxKNN is the largest k sqlite-vec accepts in a KNN query.
+const maxKNN = 4096
+
+// SetSearchReadyOnly restricts the search methods (vector, FTS, graph,
+// sparse, image, and numeric) and DocumentSummaries to documents that
+// have been "ready" once, leaving out documents whose first ingest is
+// still running or failed. A document being re-ingested, or whose
+// re-ingest failed, stays searchable. Call it before searching.
+func (s *Store) SetSearchReadyOnly(on bool) {
+	s.searchReady = on
+	s.summariesChanged()
+}
+
+// documentsJoin joins the chunks c of a search query to their documents d,
+// only those with a ready version under SetSearchReadyOnly.
+func (s *Store) documentsJoin() string {
+	if s.searchReady {
+		return "JOIN documents d ON d.id = c.document_id AND d.has_ready_version = 1"
+	}
+	return "JOIN documents d ON d.id = c.document_id"
+}
+
+// knnWindow returns how many neighbours a KNN search for k results over
+// the rows of table (chunks or chunk_images) fetches. Under
+// SetSearchReadyOnly it adds the rows of documents that are not ready,
+// which the documents join drops after the KNN, so k ready results remain
+// when there are that many, up to sqlite-vec's limit.
+func (s *Store) knnWindow(ctx context.Context, table string, k int) (int, error) {
+	if !s.searchReady {
+		return k, nil
+	}
+	var unready int
+	if err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM `+table+`
+		WHERE document_id IN (SELECT id FROM documents WHERE has_ready_version = 0)`).Scan(&unready); err != nil {
+		return 0, fmt.Errorf("counting unready %s: %w", table, err)
+	}
+	return min(k+unready, max(k, maxKNN)), nil
+}
+
+// VectorSearch performs a KNN search returning the top-k nearest chunks.
+func (s *Store) VectorSearch(ctx context.Context, queryEmbedding []float32, k int) ([]RetrievalResult, error) {
+	return s.vectorSearch(ctx, "vec_chunks", queryEmbedding, k)
+}
+
+// vectorSearch runs a KNN search over table, vec_chunks or
+// vec_chunks_secondary.
+func (s *Store) vectorSearch(ctx context.Context, table string, queryEmbedding []float32, k int) ([]RetrievalResult, error) {
+	window, err := s.knnWindow(ctx, "chunks", k)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.cachedQuery(ctx, `
+		SELECT v.chunk_id, v.distance,
+			c.content, c.heading, c.chunk_type, c.page_number, c.position_in_doc,
+			COALESCE(c.start_offset, 0), COALESCE(c.end_offset, 0),
+			c.metadata, c.document_id,
+			d.filename, d.path, d.metadata
+		FROM `+table+` v
+		JOIN chunks c ON c.id = v.chunk_id
+		`+s.documentsJoin()+`
+		WHERE v.embedding MATCH ? AND k = ?
+		ORDER BY v.distance
+		LIMIT ?
+	`, serializeFloat32(queryEmbedding), window, k)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []RetrievalResult
+	for rows.Next() {
+		var r RetrievalResult
+		var distance float64
+		var chunkMeta, docMeta sql.NullString
+		if err := rows.Scan(&r.ChunkID, &distance,
+			&r.Content, &r.Heading, &r.ChunkType, &r.PageNumber, &r.PositionInDoc,
+			&r.StartOffset, &r.EndOffset,
+			&chunkMeta, &r.DocumentID,
+			&r.Filename, &r.Path, &docMeta); err != nil {
+			return nil, err
+		}
+		// Convert distance to similarity score (1 - distance for cosine)
+		r.Score = 1.0 - distance
+		r.ChunkMeta = chunkMeta.String
+		r.DocMeta = docMeta.String
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return s.openResults(results)
+}
+
+// --- Secondary embedding space ---
+
+// SecondaryVectorSpace names vec_chunks_secondary in embedding_models.
+const SecondaryVectorSpace = "chunks_secondary"
+
+// EnableSecondaryVectors creates vec_chunks_secondary, a second dense
+// embedding space for chunks (e.g. a multilingual model alongside an
+// English one), with the given dimension. Safe to call on every start.
+func (s *Store) EnableSecondaryVectors(ctx context.Context, dim int) error {
+	if dim <= 0 {
+		return fmt.Errorf("secondary embedding dimension must be positive, got %d", dim)
+	}
+	if _, err := s.exec(ctx, fmt.Sprintf(`
+		CREATE VIRTUAL TABLE IF NOT EXISTS vec_chunks_secondary USING vec0(
+			chunk_id INTEGER PRIMARY KEY,
+			embedding float[%d]
+		)`, dim)); err != nil {
+		return fmt.Errorf("creating vec_chunks_secondary: %w", err)
+	}
+	s.secondaryVec = true
+	return nil
+}
+
+// ResetSecondaryVectors drops all secondary chunk vectors and recreates
+// the table with the given dimension, e.g. after the secondary model
+// changed.
+func (s *Store) ResetSecondaryVectors(ctx context.Context, dim int) error {
+	if _, err := s.exec(ctx, "DROP TABLE IF EXISTS vec_chunks_secondary"); err != nil {
+		return fmt.Errorf("resetting secondary vectors: %w", err)
+	}
+	s.resetStmts()
+	return s.EnableSecondaryVectors(ctx, dim)
+}
+
+// InsertSecondaryEmbedding stores a chunk's vector in the secondary space.
+func (s *Store) InsertSecondaryEmbedding(ctx context.Context, chunkID int64, embedding []float32) error {
+	_, err := s.cachedExec(ctx,
+		"INSERT OR REPLACE INTO vec_chunks_secondary (chunk_id, embedding) VALUES (?, ?)",
+		chunkID, serializeFloat32(embedding))
+	return err
+}
+
+// SecondaryVectorSearch performs a KNN search over the secondary space. It
+// returns nothing when the space is not enabled.
+func (s *Store) SecondaryVectorSearch(ctx context.Context, queryEmbedding []float32, k int) ([]RetrievalResult, error) {
+	if !s.secondaryVec {
+		return nil, nil
+	}
+	return s.vectorSearch(ctx, "vec_chunks_secondary", queryEmbedding, k)
+}
+
+// ChunksWithoutSecondaryEmbedding returns up to limit chunks with an ID
+// above afterID that have no vector in the secondary space yet, in ID
+// order, for backfilling.
+func (s *Store) ChunksWithoutSecondaryEmbedding(ctx context.Context, afterID int64, limit int) ([]Chunk, error) {
+	if !s.secondaryVec {
+		return nil, fmt.Errorf("secondary vectors not enabled")
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, document_id, content, heading FROM chunks
+		WHERE id > ? AND id NOT IN (SELECT chunk_id FROM vec_chunks_secondary)
+		ORDER BY id LIMIT ?`, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chunks []Chunk
+	for rows.Next() {
+		var c Chunk
+		if err := rows.Scan(&c.ID, &c.DocumentID, &c.Content, &c.Heading); err != nil {
+			return nil, err
+		}
+		if c.Content, err = s.openText(c.Content); err != nil {
+			return nil, fmt.Errorf("chunk %d: %w", c.ID, err)
+		}
+		chunks = append(chunks, c)
+	}
+	return chunks, rows.Err()
+}
+
+// --- Entity vectors ---
+
+// EntityMatch is an entity found by EntityVectorSearch.
+type EntityMatch struct {
+	Entity
+	// Similarity is the cosine similarity between the query and the
+	// entity's description embedding.
+	Similarity float64 `json:"similarity"`
+}
+
+// InsertEntityEmbedding stores the embedding of an entity's name and
+// description.
+func (s *Store) InsertEntityEmbedding(ctx context.Context, entityID int64, embedding []float32) error {
+	_, err := s.cachedExec(ctx,
+		"INSERT OR REPLACE INTO vec_entities (entity_id, embedding) VALUES (?, ?)",
+		entityID, serializeFloat32(embedding))
+	return err
+}
+
+// EntityVectorSearch performs a KNN search over entity embeddings,
+// returning the top-k entities nearest the query embedding, most similar
+// first.
+func (s *Store) EntityVectorSearch(ctx context.Context, queryEmbedding []float32, k int) ([]EntityMatch, error) {
+	rows, err := s.cachedQuery(ctx, `
+		SELECT v.distance, e.id, e.name, e.entity_type, COALESCE(e.description, ''),
+			COALESCE(e.name_en, ''), COALESCE(e.metadata, '')
+		FROM vec_entities v
+		JOIN entities e ON e.id = v.entity_id
+		WHERE v.embedding MATCH ? AND k = ?
+		ORDER BY v.distance
+	`, serializeFloat32(queryEmbedding), k)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []EntityMatch
+	for rows.Next() {
+		var m EntityMatch
+		var distance float64
+		if err := rows.Scan(&distance, &m.ID, &m.Name, &m.EntityType, &m.Description,
+			&m.NameEN, &m.Metadata); err != nil {
+			return nil, err
+		}
+		m.Similarity = 1.0 - distance
+		matches = append(matches, m)
+	}
+	return matches, rows.Err()
+}
+
+// EntitiesWithoutEmbedding returns up to limit entities with an ID above
+// afterID that have no description embedding yet, in ID order, for
+// backfilling.
+func (s *Store) EntitiesWithoutEmbedding(ctx context.Context, afterID int64, limit int) ([]Entity, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, name, entity_type, COALESCE(description, '') FROM entities
+		WHERE id > ? AND id NOT IN (SELECT entity_id FROM vec_entities)
+		ORDER BY id LIMIT ?`, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entities []Entity
+	for rows.Next() {
+		var e Entity
+		if err := rows.Scan(&e.ID, &e.Name, &e.EntityType, &e.Description); err != nil {
+			return nil, err
+		}
+		entities = append(entities, e)
+	}
+	return entities, rows.Err()
+}
+
+// --- Entity statistics ---
+
+// EntityStat is how widely an entity is linked across the corpus.
+type EntityStat struct {
+	ID            int64  `json:"id"`
+	Name          string `json:"name"`
+	EntityType    string `json:"entity_type"`
+	Documents     int    `json:"documents"`
+	Chunks        int    `json:"chunks"`
+	Relationships int    `json:"relationships"`
+	// Stop marks a stop entity, kept out of graph search.
+	Stop bool `json:"stop"`
+}
+
+// EntityStats returns the limit entities linked to the most documents
+// (then chunks), with their stop flags.
+func (s *Store) EntityStats(ctx context.Context, limit int) ([]EntityStat, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT e.id, e.name, e.entity_type, e.stop,
+			COUNT(DISTINCT c.document_id), COUNT(DISTINCT ec.chunk_id),
+			(SELECT COUNT(*) FROM relationships r
+				WHERE r.source_entity_id = e.id OR r.target_entity_id = e.id)
+		FROM entities e
+		JOIN entity_chunks ec ON ec.entity_id = e.id
+		JOIN chunks c ON c.id = ec.chunk_id
+		GROUP BY e.id
+		ORDER BY COUNT(DISTINCT c.document_id) DESC, COUNT(DISTINCT ec.chunk_id) DESC, e.id
+		LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []EntityStat
+	for rows.Next() {
+		var st EntityStat
+		if err := rows.Scan(&st.ID, &st.Name, &st.EntityType, &st.Stop,
+			&st.Documents, &st.Chunks, &st.Relationships); err != nil {
+			return nil, err
+		}
+		stats = append(stats, st)
+	}
+	return stats, rows.Err()
+}
+
+// StopEntityRule decides which entities are stop entities: generic ones
+// such as "system" or "document" that extraction links to much of the
+// corpus and that would flood graph search.
+type StopEntityRule struct {
+	// Fraction of the corpus's documents an entity may be linked to
+	// before it is a stop entity; 0 disables the automatic rule.
+	Threshold float64
+	// MinDocuments is the corpus size below which the automatic rule is
+	// not applied, since in a small corpus any entity spans a large
+	// fraction of it.
+	MinDocuments int
+	// Stop names are always stop entities; Keep names never are.
+	Stop, Keep []string
+}
+
+// UpdateStopEntities recomputes every entity's stop flag from rule and
+// returns the number of stop entities.
+func (s *Store) UpdateStopEntities(ctx context.Context, rule StopEntityRule) (int, error) {
+	var n int
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "UPDATE entities SET stop = 0 WHERE stop = 1"); err != nil {
+			return err
+		}
+
+		var docs int
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM documents").Scan(&docs); err != nil {
+			return err
+		}
+		if rule.Threshold > 0 && docs >= rule.MinDocuments {
+			if _, err := tx.ExecContext(ctx, `
+				UPDATE entities SET stop = 1 WHERE id IN (
+					SELECT ec.entity_id FROM entity_chunks ec
+					JOIN chunks c ON c.id = ec.chunk_id
+					GROUP BY ec.entity_id
+					HAVING COUNT(DISTINCT c.document_id) > ?
+				)`, rule.Threshold*float64(docs)); err != nil {
+				return err
+			}
+		}
+
+		for _, names := range []struct {
+			list []string
+			stop int
+		}{{rule.Stop, 1}, {rule.Keep, 0}} {
+			if len(names.list) == 0 {
+				continue
+			}
+			args := []interface{}{names.stop}
+			for _, name := range names.list {
+				args = append(args, strings.ToLower(strings.TrimSpace(name)))
+			}
+			if _, err := tx.ExecContext(ctx, "UPDATE entities SET stop = ? WHERE lower(name) IN (?"+
+				repeatPlaceholders(len(names.list)-1)+")", args...); err != nil {
+				return err
+			}
+		}
+
+		return tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM entities WHERE stop = 1").Scan(&n)
+	})
+	return n, err
+}
+
+// StopEntityIDs returns the IDs of the stop entities.
+func (s *Store) StopEntityIDs(ctx context.Context) (map[int64]bool, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id FROM entities WHERE stop = 1")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[int64]bool)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids[id] = true
+	}
+	return ids, rows.Err()
+}
+
+// --- Ingest checkpoints ---
+
+// Ingest checkpoint stages, in pipeline order. The After stages are those
+// of the custom pipeline stages that run after embedding and after the
+// graph.
+const (
+	CheckpointEmbedding      = "embedding"
+	CheckpointAfterEmbedding = "after_embedding"
+	CheckpointGraph          = "graph"
+	CheckpointAfterGraph     = "after_graph"
+)
+
+// IngestCheckpoint records how far an unfinished ingest of a document got,
+// so it can be resumed instead of restarted. Chunk IDs grow in document
+// order, so the chunks an ingest added are those from FirstChunkID on.
+type IngestCheckpoint struct {
+	DocumentID int64  `json:"document_id"`
+	Stage      string `json:"stage"`
+	// FirstChunkID is the lowest chunk ID the ingest added.
+	FirstChunkID int64 `json:"first_chunk_id"`
+	// LastChunkID is the last chunk the stage completed; 0 if none.
+	LastChunkID int64 `json:"last_chunk_id"`
+	// GraphReport is the JSON graph build report of the chunks the graph
+	// stage completed; empty before the graph stage.
+	GraphReport string `json:"graph_report,omitempty"`
+	// Pipeline lists the stages of the ingest's pipeline (see
+	// pipeline.Pipeline.String), which a resumed ingest must run again.
+	Pipeline  string `json:"pipeline,omitempty"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// SetIngestCheckpoint creates or replaces a document's checkpoint.
+func (s *Store) SetIngestCheckpoint(ctx context.Context, cp IngestCheckpoint) error {
+	_, err := s.exec(ctx, `
+		INSERT INTO ingest_checkpoints (document_id, stage, first_chunk_id, last_chunk_id, graph_report, pipeline, updated_at)
+		VALUES (?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), CURRENT_TIMESTAMP)
+		ON CONFLICT(document_id) DO UPDATE SET
+			stage = excluded.stage, first_chunk_id = excluded.first_chunk_id,
+			last_chunk_id = excluded.last_chunk_id, graph_report = excluded.graph_report,
+			pipeline = excluded.pipeline, updated_at = excluded.updated_at
+	`, cp.DocumentID, cp.Stage, cp.FirstChunkID, cp.LastChunkID, cp.GraphReport, cp.Pipeline)
+	return err
+}
+
+// GetIngestCheckpoint returns a document's checkpoint, or sql.ErrNoRows if
+// its last ingest finished.
+func (s *Store) GetIngestCheckpoint(ctx context.Context, docID int64) (*IngestCheckpoint, error) {
+	cp := &IngestCheckpoint{DocumentID: docID}
+	var report, pipeline sql.NullString
+	err := s.db.QueryRowContext(ctx, `
+		SELECT stage, first_chunk_id, last_chunk_id, graph_report, pipeline, updated_at
+		FROM ingest_checkpoints WHERE document_id = ?`, docID,
+	).Scan(&cp.Stage, &cp.FirstChunkID, &cp.LastChunkID, &report, &pipeline, &cp.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	cp.GraphReport, cp.Pipeline = report.String, pipeline.String
+	return cp, nil
+}
+
+// DeleteIngestCheckpoint removes a document's checkpoint once its ingest
+// has finished.
+func (s *Store) DeleteIngestCheckpoint(ctx context.Context, docID int64) error {
+	_, err := s.exec(ctx, "DELETE FROM ingest_checkpoints WHERE document_id = ?", docID)
+	return err
+}
+
+// --- Glossary operations ---
+
+// GlossaryEntry is an abbreviation defined in a document.
+type GlossaryEntry struct {
+	ID           int64  `json:"id"`
+	DocumentID   int64  `json:"document_id"`
+	ChunkID      int64  `json:"chunk_id,omitempty"` // chunk the definition was found in; 0 if unknown
+	Abbreviation string `json:"abbreviation"`
+	Definition   string `json:"definition"`
+	Filename     string `json:"filename,omitempty"`
+}
+
+// InsertGlossary stores glossary entries, ignoring duplicates.
+func (s *Store) InsertGlossary(ctx context.Context, entries []GlossaryEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, `
+			INSERT OR IGNORE INTO glossary (document_id, chunk_id, abbreviation, definition)
+			VALUES (?, ?, ?, ?)`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for _, e := range entries {
+			var chunkID interface{}
+			if e.ChunkID != 0 {
+				chunkID = e.ChunkID
+			}
+			if _, err := stmt.ExecContext(ctx, e.DocumentID, chunkID, e.Abbreviation, e.Definition); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ListGlossary returns glossary entries ordered by abbreviation, limited to
+// one document when docID is non-zero.
+func (s *Store) ListGlossary(ctx context.Context, docID int64) ([]GlossaryEntry, error) {
+	query := `SELECT g.id, g.document_id, COALESCE(g.chunk_id, 0), g.abbreviation, g.definition, d.filename
+		FROM glossary g JOIN documents d ON d.id = g.document_id`
+	var args []interface{}
+	if docID != 0 {
+		query += " WHERE g.document_id = ?"
+		args = append(args, docID)
+	}
+	query += " ORDER BY g.abbreviation COLLATE NOCASE, g.document_id"
+	return s.queryGlossary(ctx, query, args...)
+}
+
+// GlossaryByAbbreviations returns the entries for the given abbreviations,
+// matched exactly.
+func (s *Store) GlossaryByAbbreviations(ctx context.Context, abbrevs []string) ([]GlossaryEntry, error) {
+	if len(abbrevs) == 0 {
+		return nil, nil
+	}
+	query := `SELECT g.id, g.document_id, COALESCE(g.chunk_id, 0), g.abbreviation, g.definition, d.filename
+		FROM glossary g JOIN documents d ON d.id = g.document_id
+		WHERE g.abbreviation IN (?` + strings.Repeat(",?", len(abbrevs)-1) + `)
+		ORDER BY g.abbreviation, g.document_id`
+	args := make([]interface{}, len(abbrevs))
+	for i, a := range abbrevs {
+		args[i] = a
+	}
+	return s.queryGlossary(ctx, query, args...)
+}
+
+func (s *Store) queryGlossary(ctx context.Context, query string, args ...interface{}) ([]GlossaryEntry, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []GlossaryEntry
+	for rows.Next() {
+		var e GlossaryEntry
+		if err := rows.Scan(&e.ID, &e.DocumentID, &e.ChunkID, &e.Abbreviation, &e.Definition, &e.Filename); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// --- Chunk annotation operations ---
+
+// Chunk annotation kinds.
+const (
+	// AnnotationCorrection is corrected text shown to the model next to
+	// the chunk, taking precedence over the chunk's own wording.
+	AnnotationCorrection = "correction"
+	// AnnotationExclude keeps the chunk out of retrieval results.
+	AnnotationExclude = "exclude"
+	// AnnotationBoost multiplies the chunk's fused retrieval score by Boost.
+	AnnotationBoost = "boost"
+	// AnnotationCanonical is an approved answer the model should prefer
+	// when the chunk is in context.
+	AnnotationCanonical = "canonical_answer"
+)
+
+// ChunkAnnotation is a curator's note on a chunk. A chunk has at most one
+// annotation of each kind. Annotations are deleted with their chunk, so
+// they survive updates that leave the chunk's content unchanged.
+type ChunkAnnotation struct {
+	ID        int64     `json:"id"`
+	ChunkID   int64     `json:"chunk_id"`
+	Kind      string    `json:"kind"`
+	Text      string    `json:"text,omitempty"`
+	Boost     float64   `json:"boost,omitempty"`
+	Author    string    `json:"author,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UpsertChunkAnnotation stores an annotation, replacing the chunk's
+// annotation of the same kind. Returns the annotation ID.
+func (s *Store) UpsertChunkAnnotation(ctx context.Context, a ChunkAnnotation) (int64, error) {
+	var id int64
+	err := s.write(ctx, func(ctx context.Context) error {
+		return s.retryBusy(ctx, func() error {
+			return s.db.QueryRowContext(ctx, `
+		INSERT INTO chunk_annotations (chunk_id, kind, text, boost, author)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(chunk_id, kind) DO UPDATE SET
+			text = excluded.text, boost = excluded.boost, author = excluded.author,
+			created_at = CURRENT_TIMESTAMP
+		RETURNING id`,
+				a.ChunkID, a.Kind, a.Text, a.Boost, a.Author).Scan(&id)
+		})
+	})
+	return id, err
+}
+
+// DeleteChunkAnnotation removes an annotation. It returns sql.ErrNoRows
+// when the annotation does not exist.
+func (s *Store) DeleteChunkAnnotation(ctx context.Context, id int64) error {
+	res, err := s.exec(ctx, "DELETE FROM chunk_annotations WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ListChunkAnnotations returns annotations, newest first, limited to one
+// chunk when chunkID is non-zero.
+func (s *Store) ListChunkAnnotations(ctx context.Context, chunkID int64) ([]ChunkAnnotation, error) {
+	query := `SELECT id, chunk_id, kind, COALESCE(text, ''), COALESCE(boost, 0), COALESCE(author, ''), created_at
+		FROM chunk_annotations`
+	var args []interface{}
+	if chunkID != 0 {
+		query += " WHERE chunk_id = ?"
+		args = append(args, chunkID)
+	}
+	query += " ORDER BY created_at DESC, id DESC"
+	return s.queryAnnotations(ctx, query, args...)
+}
+
+// AnnotationsForChunks returns the annotations of the given chunks, keyed
+// by chunk ID.
+func (s *Store) AnnotationsForChunks(ctx context.Context, chunkIDs []int64) (map[int64][]ChunkAnnotation, error) {
+	if len(chunkIDs) == 0 {
+		return nil, nil
+	}
+	query := `SELECT id, chunk_id, kind, COALESCE(text, ''), COALESCE(boost, 0), COALESCE(author, ''), created_at
+		FROM chunk_annotations
+		WHERE chunk_id IN (?` + strings.Repeat(",?", len(chunkIDs)-1) + `)
+		ORDER BY chunk_id, kind`
+	args := make([]interface{}, len(chunkIDs))
+	for i, id := range chunkIDs {
+		args[i] = id
+	}
+	list, err := s.queryAnnotations(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[int64][]ChunkAnnotation)
+	for _, a := range list {
+		out[a.ChunkID] = append(out[a.ChunkID], a)
+	}
+	return out, nil
+}
+
+// ChunkContentHashes returns the content hash of each of chunkIDs that
+// exists.
+func (s *Store) ChunkContentHashes(ctx context.Context, chunkIDs []int64) (map[int64]string, error) {
+	if len(chunkIDs) == 0 {
+		return nil, nil
+	}
+	args := make([]interface{}, len(chunkIDs))
+	for i, id := range chunkIDs {
+		args[i] = id
+	}
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT id, content_hash FROM chunks WHERE id IN (?"+strings.Repeat(",?", len(chunkIDs)-1)+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[int64]string, len(chunkIDs))
+	for rows.Next() {
+		var id int64
+		var hash string
+		if err := rows.Scan(&id, &hash); err != nil {
+			return nil, err
+		}
+		out[id] = hash
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) queryAnnotations(ctx context.Context, query string, args ...interface{}) ([]ChunkAnnotation, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var chunks []Chunk
+	var out []ChunkAnnotation
 	for rows.Next() {
-		var c Chunk
-		var metadata sql.NullString
-		if err := rows.Scan(&c.ID, &c.DocumentID, &c.ParentChunkID, &c.Content,
-			&c.ChunkType, &c.Heading, &c.PageNumber, &c.PositionInDoc,
-			&c.TokenCount, &metadata, &c.ContentHash); err != nil {
+		var a ChunkAnnotation
+		if err := rows.Scan(&a.ID, &a.ChunkID, &a.Kind, &a.Text, &a.Boost, &a.Author, &a.CreatedAt); err != nil {
 			return nil, err
 		}
-		c.Metadata = metadata.String
-		chunks = append(chunks, c)
+		out = append(out, a)
 	}
-	return chunks, rows.Err()
+	return out, rows.Err()
 }
 
-// --- Chunk image operations ---
+// --- Sparse embedding operations ---
 
-// InsertChunkImages batch-inserts images associated with chunks.
-func (s *Store) InsertChunkImages(ctx context.Context, images []ChunkImage) error {
-	if len(images) == 0 {
-		return nil
+// InsertSparseEmbedding stores a learned sparse embedding for a chunk,
+// replacing any previous terms. indices and weights correspond by position.
+func (s *Store) InsertSparseEmbedding(ctx context.Context, chunkID int64, indices []int, weights []float32) error {
+	if len(indices) != len(weights) {
+		return fmt.Errorf("sparse embedding: %d indices but %d weights", len(indices), len(weights))
 	}
 	return s.inTx(ctx, func(tx *sql.Tx) error {
-		stmt, err := tx.PrepareContext(ctx, `
-			INSERT INTO chunk_images (chunk_id, document_id, caption, mime_type, width, height, page_number, data)
-			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-		`)
+		if _, err := tx.ExecContext(ctx,
+			"DELETE FROM sparse_chunks WHERE chunk_id = ?", chunkID); err != nil {
+			return err
+		}
+		stmt, err := tx.PrepareContext(ctx,
+			"INSERT OR REPLACE INTO sparse_chunks (chunk_id, term_id, weight) VALUES (?, ?, ?)")
 		if err != nil {
 			return err
 		}
 		defer stmt.Close()
 
-		for _, img := range images {
-			if _, err := stmt.ExecContext(ctx,
-				img.ChunkID, img.DocumentID, img.Caption, img.MIMEType,
-				img.Width, img.Height, img.PageNumber, img.Data); err != nil {
+		for i, idx := range indices {
+			if _, err := stmt.ExecContext(ctx, chunkID, idx, weights[i]); err != nil {
 				return err
 			}
 		}
@@ -496,70 +2404,44 @@ func (s *Store) InsertChunkImages(ctx context.Context, images []ChunkImage) erro
 	})
 }
 
-// GetImagesByChunkIDs returns images grouped by chunk ID.
-// When includeData is false, the Data field is left empty to avoid loading BLOBs.
-func (s *Store) GetImagesByChunkIDs(ctx context.Context, chunkIDs []int64, includeData bool) (map[int64][]ChunkImage, error) {
-	if len(chunkIDs) == 0 {
+// SparseSearch ranks chunks by the dot product between the query's sparse
+// vector and each chunk's stored sparse vector, returning the top k.
+func (s *Store) SparseSearch(ctx context.Context, indices []int, weights []float32, k int) ([]RetrievalResult, error) {
+	if len(indices) == 0 || len(indices) != len(weights) {
 		return nil, nil
 	}
 
-	dataCol := "NULL"
-	if includeData {
-		dataCol = "data"
-	}
-
-	query := fmt.Sprintf(`SELECT id, chunk_id, document_id, caption, mime_type, width, height, page_number, %s
-		FROM chunk_images WHERE chunk_id IN (?%s) ORDER BY id`,
-		dataCol, repeatPlaceholders(len(chunkIDs)-1))
-
-	args := make([]interface{}, len(chunkIDs))
-	for i, id := range chunkIDs {
-		args[i] = id
-	}
-
-	rows, err := s.db.QueryContext(ctx, query, args...)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	result := make(map[int64][]ChunkImage)
-	for rows.Next() {
-		var img ChunkImage
-		var caption sql.NullString
-		if err := rows.Scan(&img.ID, &img.ChunkID, &img.DocumentID, &caption,
-			&img.MIMEType, &img.Width, &img.Height, &img.PageNumber, &img.Data); err != nil {
-			return nil, err
-		}
-		img.Caption = caption.String
-		result[img.ChunkID] = append(result[img.ChunkID], img)
+	values := make([]string, len(indices))
+	args := make([]interface{}, 0, len(indices)*2+1)
+	for i, idx := range indices {
+		values[i] = "(?, ?)"
+		args = append(args, idx, weights[i])
 	}
-	return result, rows.Err()
-}
-
-// --- Embedding operations ---
-
-// InsertEmbedding stores a vector embedding for a chunk.
-func (s *Store) InsertEmbedding(ctx context.Context, chunkID int64, embedding []float32) error {
-	_, err := s.db.ExecContext(ctx,
-		"INSERT OR REPLACE INTO vec_chunks (chunk_id, embedding) VALUES (?, ?)",
-		chunkID, serializeFloat32(embedding))
-	return err
-}
+	args = append(args, k)
 
-// VectorSearch performs a KNN search returning the top-k nearest chunks.
-func (s *Store) VectorSearch(ctx context.Context, queryEmbedding []float32, k int) ([]RetrievalResult, error) {
-	rows, err := s.db.QueryContext(ctx, `
-		SELECT v.chunk_id, v.distance,
+	query := `
+		WITH q(term_id, weight) AS (VALUES ` + strings.Join(values, ", ") + `),
+		scored AS (
+			SELECT sc.chunk_id, SUM(sc.weight * q.weight) AS score
+			FROM sparse_chunks sc
+			JOIN q ON q.term_id = sc.term_id
+			JOIN chunks c ON c.id = sc.chunk_id
+			` + s.documentsJoin() + `
+			GROUP BY sc.chunk_id
+			ORDER BY score DESC
+			LIMIT ?
+		)
+		SELECT sc.chunk_id, sc.score,
 			c.content, c.heading, c.chunk_type, c.page_number, c.position_in_doc,
+			COALESCE(c.start_offset, 0), COALESCE(c.end_offset, 0),
 			c.metadata, c.document_id,
 			d.filename, d.path, d.metadata
-		FROM vec_chunks v
-		JOIN chunks c ON c.id = v.chunk_id
+		FROM scored sc
+		JOIN chunks c ON c.id = sc.chunk_id
 		JOIN documents d ON d.id = c.document_id
-		WHERE v.embedding MATCH ? AND k = ?
-		ORDER BY v.distance
-	`, serializeFloat32(queryEmbedding), k)
+		ORDER BY sc.score DESC`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -568,37 +2450,56 @@ func (s *Store) VectorSearch(ctx context.Context, queryEmbedding []float32, k in
 	var results []RetrievalResult
 	for rows.Next() {
 		var r RetrievalResult
-		var distance float64
 		var chunkMeta, docMeta sql.NullString
-		if err := rows.Scan(&r.ChunkID, &distance,
+		if err := rows.Scan(&r.ChunkID, &r.Score,
 			&r.Content, &r.Heading, &r.ChunkType, &r.PageNumber, &r.PositionInDoc,
+			&r.StartOffset, &r.EndOffset,
 			&chunkMeta, &r.DocumentID,
 			&r.Filename, &r.Path, &docMeta); err != nil {
 			return nil, err
 		}
-		// Convert distance to similarity score (1 - distance for cosine)
-		r.Score = 1.0 - distance
 		r.ChunkMeta = chunkMeta.String
 		r.DocMeta = docMeta.String
 		results = append(results, r)
 	}
-	return results, rows.Err()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return s.openResults(results)
 }
 
-// FTSSearch performs a full-text search using FTS5 BM25 ranking.
+// FTSSearch performs a full-text search using FTS5 BM25 ranking, with
+// headings and content weighted alike.
 func (s *Store) FTSSearch(ctx context.Context, query string, limit int) ([]RetrievalResult, error) {
-	rows, err := s.db.QueryContext(ctx, `
+	return s.FTSSearchWeighted(ctx, query, limit, 1)
+}
+
+// FTSSearchWeighted is FTSSearch with the BM25 weight of the heading
+// column set relative to the content column (weight 1), so a term in a
+// chunk's heading can count for more than the same term in its body.
+// Weights of 0 or below are treated as 1.
+func (s *Store) FTSSearchWeighted(ctx context.Context, query string, limit int, headingWeight float64) ([]RetrievalResult, error) {
+	if headingWeight <= 0 {
+		headingWeight = 1
+	}
+	// Encrypted chunks are matched in chunks_fts_sealed (see indexSealed).
+	bm25 := fmt.Sprintf("bm25(1.0, %g)", headingWeight)
+	rows, err := s.cachedQuery(ctx, `
 		SELECT f.rowid, f.rank,
 			c.content, c.heading, c.chunk_type, c.page_number, c.position_in_doc,
+			COALESCE(c.start_offset, 0), COALESCE(c.end_offset, 0),
 			c.metadata, c.document_id,
 			d.filename, d.path, d.metadata
-		FROM chunks_fts f
+		FROM (
+			SELECT rowid, rank FROM chunks_fts WHERE chunks_fts MATCH ? AND rank MATCH ?
+			UNION ALL
+			SELECT rowid, rank FROM chunks_fts_sealed WHERE chunks_fts_sealed MATCH ? AND rank MATCH ?
+		) f
 		JOIN chunks c ON c.id = f.rowid
-		JOIN documents d ON d.id = c.document_id
-		WHERE chunks_fts MATCH ?
+		`+s.documentsJoin()+`
 		ORDER BY f.rank
 		LIMIT ?
-	`, query, limit)
+	`, query, bm25, query, bm25, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -611,6 +2512,7 @@ func (s *Store) FTSSearch(ctx context.Context, query string, limit int) ([]Retri
 		var chunkMeta, docMeta sql.NullString
 		if err := rows.Scan(&r.ChunkID, &rank,
 			&r.Content, &r.Heading, &r.ChunkType, &r.PageNumber, &r.PositionInDoc,
+			&r.StartOffset, &r.EndOffset,
 			&chunkMeta, &r.DocumentID,
 			&r.Filename, &r.Path, &docMeta); err != nil {
 			return nil, err
@@ -621,18 +2523,23 @@ func (s *Store) FTSSearch(ctx context.Context, query string, limit int) ([]Retri
 		r.DocMeta = docMeta.String
 		results = append(results, r)
 	}
-	return results, rows.Err()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return s.openResults(results)
 }
 
 // --- Entity operations ---
 
-// UpsertEntity inserts or updates an entity. Returns the entity ID.
+// UpsertEntity inserts or updates an entity. Returns the entity ID. An
+// imported entity keeps its description (see ImportGraph).
 func (s *Store) UpsertEntity(ctx context.Context, e Entity) (int64, error) {
-	res, err := s.db.ExecContext(ctx, `
+	res, err := s.exec(ctx, `
 		INSERT INTO entities (name, entity_type, description, name_en, metadata)
 		VALUES (?, ?, ?, ?, ?)
 		ON CONFLICT(name, entity_type) DO UPDATE SET
-			description = COALESCE(excluded.description, entities.description),
+			description = CASE WHEN entities.import_source IS NOT NULL AND COALESCE(entities.description, '') != ''
+				THEN entities.description ELSE COALESCE(excluded.description, entities.description) END,
 			name_en = COALESCE(excluded.name_en, entities.name_en),
 			metadata = excluded.metadata
 	`, e.Name, e.EntityType, e.Description, e.NameEN, e.Metadata)
@@ -657,6 +2564,7 @@ func (s *Store) UpsertEntity(ctx context.Context, e Entity) (int64, error) {
 
 // UpsertEntityAndLink atomically upserts an entity and links it to a chunk
 // in a single transaction, preventing FOREIGN KEY failures from concurrent access.
+// An imported entity keeps its description (see ImportGraph).
 func (s *Store) UpsertEntityAndLink(ctx context.Context, e Entity, chunkID int64) (int64, error) {
 	var id int64
 	err := s.inTx(ctx, func(tx *sql.Tx) error {
@@ -664,7 +2572,8 @@ func (s *Store) UpsertEntityAndLink(ctx context.Context, e Entity, chunkID int64
 			INSERT INTO entities (name, entity_type, description, name_en, metadata)
 			VALUES (?, ?, ?, ?, ?)
 			ON CONFLICT(name, entity_type) DO UPDATE SET
-				description = COALESCE(excluded.description, entities.description),
+				description = CASE WHEN entities.import_source IS NOT NULL AND COALESCE(entities.description, '') != ''
+					THEN entities.description ELSE COALESCE(excluded.description, entities.description) END,
 				name_en = COALESCE(excluded.name_en, entities.name_en),
 				metadata = excluded.metadata
 		`, e.Name, e.EntityType, e.Description, e.NameEN, e.Metadata)
@@ -695,7 +2604,7 @@ func (s *Store) UpsertEntityAndLink(ctx context.Context, e Entity, chunkID int64
 
 // LinkEntityChunk creates a provenance link between an entity and a chunk.
 func (s *Store) LinkEntityChunk(ctx context.Context, entityID, chunkID int64) error {
-	_, err := s.db.ExecContext(ctx,
+	_, err := s.exec(ctx,
 		"INSERT OR IGNORE INTO entity_chunks (entity_id, chunk_id) VALUES (?, ?)",
 		entityID, chunkID)
 	return err
@@ -703,7 +2612,7 @@ func (s *Store) LinkEntityChunk(ctx context.Context, entityID, chunkID int64) er
 
 // InsertRelationship creates a relationship between two entities.
 func (s *Store) InsertRelationship(ctx context.Context, r Relationship) (int64, error) {
-	res, err := s.db.ExecContext(ctx, `
+	res, err := s.exec(ctx, `
 		INSERT INTO relationships (source_entity_id, target_entity_id, relation_type,
 			weight, description, source_chunk_id, metadata)
 		VALUES (?, ?, ?, ?, ?, ?, ?)
@@ -735,93 +2644,393 @@ func (s *Store) GetEntitiesByNames(ctx context.Context, names []string) ([]Entit
 	}
 	defer rows.Close()
 
-	var entities []Entity
+	var entities []Entity
+	for rows.Next() {
+		var e Entity
+		var metadata sql.NullString
+		if err := rows.Scan(&e.ID, &e.Name, &e.EntityType, &e.Description, &e.NameEN, &metadata); err != nil {
+			return nil, err
+		}
+		e.Metadata = metadata.String
+		entities = append(entities, e)
+	}
+	return entities, rows.Err()
+}
+
+// SearchEntitiesByTerms finds entities whose names contain any of the given
+// terms as substrings. This enables graph search to work when query terms are
+// single words (e.g. "rejected") and entity names are multi-word phrases
+// (e.g. "rechazador de envases"). Results are limited to avoid noise from
+// very short or generic terms.
+func (s *Store) SearchEntitiesByTerms(ctx context.Context, terms []string, limit int) ([]Entity, error) {
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	if limit == 0 {
+		limit = 50
+	}
+
+	// Build OR conditions: name LIKE '%term1%' OR name LIKE '%term2%' ...
+	// Only use terms with length >= 4 to avoid noise from short words.
+	var conditions []string
+	var args []interface{}
+	for _, t := range terms {
+		if len(t) < 4 {
+			continue
+		}
+		conditions = append(conditions, "name LIKE ?")
+		args = append(args, "%"+t+"%")
+	}
+	if len(conditions) == 0 {
+		return nil, nil
+	}
+
+	query := "SELECT id, name, entity_type, description, COALESCE(name_en, ''), metadata FROM entities WHERE " +
+		strings.Join(conditions, " OR ") +
+		" LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entities []Entity
+	for rows.Next() {
+		var e Entity
+		var metadata sql.NullString
+		if err := rows.Scan(&e.ID, &e.Name, &e.EntityType, &e.Description, &e.NameEN, &metadata); err != nil {
+			return nil, err
+		}
+		e.Metadata = metadata.String
+		entities = append(entities, e)
+	}
+	return entities, rows.Err()
+}
+
+// GraphSearch finds chunks reachable via entity relationships.
+func (s *Store) GraphSearch(ctx context.Context, entityIDs []int64, limit int) ([]RetrievalResult, error) {
+	if len(entityIDs) == 0 {
+		return nil, nil
+	}
+
+	query := `
+		SELECT DISTINCT ec.chunk_id, COALESCE(MAX(r.weight), 0.5),
+			c.content, c.heading, c.chunk_type, c.page_number, c.position_in_doc,
+			COALESCE(c.start_offset, 0), COALESCE(c.end_offset, 0),
+			c.metadata, c.document_id,
+			d.filename, d.path, d.metadata
+		FROM entity_chunks ec
+		LEFT JOIN relationships r ON r.source_entity_id = ec.entity_id OR r.target_entity_id = ec.entity_id
+		JOIN chunks c ON c.id = ec.chunk_id
+		` + s.documentsJoin() + `
+		WHERE ec.entity_id IN (?` + repeatPlaceholders(len(entityIDs)-1) + `)
+		GROUP BY ec.chunk_id
+		ORDER BY COALESCE(MAX(r.weight), 0.5) DESC
+		LIMIT ?`
+
+	args := make([]interface{}, 0, len(entityIDs)+1)
+	for _, id := range entityIDs {
+		args = append(args, id)
+	}
+	args = append(args, limit)
+
+	rows, err := s.cachedQuery(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []RetrievalResult
+	for rows.Next() {
+		var r RetrievalResult
+		var chunkMeta, docMeta sql.NullString
+		if err := rows.Scan(&r.ChunkID, &r.Score,
+			&r.Content, &r.Heading, &r.ChunkType, &r.PageNumber, &r.PositionInDoc,
+			&r.StartOffset, &r.EndOffset,
+			&chunkMeta, &r.DocumentID,
+			&r.Filename, &r.Path, &docMeta); err != nil {
+			return nil, err
+		}
+		r.ChunkMeta = chunkMeta.String
+		r.DocMeta = docMeta.String
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return s.openResults(results)
+}
+
+// EntityPath is the best-scoring relationship path from a seed entity.
+type EntityPath struct {
+	EntityID int64
+	Name     string
+	Score    float64 // product of relationship weights along the path; 1 for seeds
+	Hops     int
+	Path     string // e.g. "av-fm -[references]-> en 1366-2 <-[defines]- e1375"
+}
+
+// ExpandEntityPaths runs a bounded-depth BFS over relationships from the
+// seed entities, treating edges as undirected. Each entity keeps its
+// best-scoring path, where a path scores the product of its relationship
+// weights (clamped to (0, 1], so longer paths decay). Expansion stops after
+// maxDepth hops or once maxEntities entities are reached. Stop entities
+// (see UpdateStopEntities) are neither reached nor passed through.
+func (s *Store) ExpandEntityPaths(ctx context.Context, seeds []Entity, maxDepth, maxEntities int) (map[int64]EntityPath, error) {
+	paths := make(map[int64]EntityPath, len(seeds))
+	frontier := make([]int64, 0, len(seeds))
+	for _, e := range seeds {
+		if _, ok := paths[e.ID]; ok {
+			continue
+		}
+		paths[e.ID] = EntityPath{EntityID: e.ID, Name: e.Name, Score: 1, Path: e.Name}
+		frontier = append(frontier, e.ID)
+	}
+
+	for depth := 1; depth <= maxDepth && len(frontier) > 0; depth++ {
+		if maxEntities > 0 && len(paths) >= maxEntities {
+			break
+		}
+		ph := "?" + repeatPlaceholders(len(frontier)-1)
+		query := `
+			SELECT r.source_entity_id, r.target_entity_id, r.relation_type, COALESCE(r.weight, 1.0),
+				es.name, et.name
+			FROM relationships r
+			JOIN entities es ON es.id = r.source_entity_id
+			JOIN entities et ON et.id = r.target_entity_id
+			WHERE (r.source_entity_id IN (` + ph + `) OR r.target_entity_id IN (` + ph + `))
+				AND es.stop = 0 AND et.stop = 0`
+		args := make([]interface{}, 0, len(frontier)*2)
+		for _, id := range frontier {
+			args = append(args, id)
+		}
+		for _, id := range frontier {
+			args = append(args, id)
+		}
+
+		rows, err := s.db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, err
+		}
+
+		inFrontier := make(map[int64]bool, len(frontier))
+		for _, id := range frontier {
+			inFrontier[id] = true
+		}
+		improved := make(map[int64]bool)
+		for rows.Next() {
+			var src, tgt int64
+			var relType, srcName, tgtName string
+			var weight float64
+			if err := rows.Scan(&src, &tgt, &relType, &weight, &srcName, &tgtName); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if weight <= 0 || weight > 1 {
+				weight = 1
+			}
+
+			// An edge between two frontier entities can extend either end.
+			for _, dir := range [2]bool{true, false} {
+				from, to, name, step := src, tgt, tgtName, " -["+relType+"]-> "+tgtName
+				if !dir {
+					from, to, name, step = tgt, src, srcName, " <-["+relType+"]- "+srcName
+				}
+				if !inFrontier[from] {
+					continue
+				}
+				base := paths[from]
+				score := base.Score * weight
+				if cur, ok := paths[to]; ok && cur.Score >= score {
+					continue
+				}
+				if _, ok := paths[to]; !ok && maxEntities > 0 && len(paths) >= maxEntities {
+					continue
+				}
+				paths[to] = EntityPath{EntityID: to, Name: name, Score: score, Hops: base.Hops + 1, Path: base.Path + step}
+				improved[to] = true
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		frontier = frontier[:0]
+		for id := range improved {
+			frontier = append(frontier, id)
+		}
+		sort.Slice(frontier, func(i, j int) bool { return frontier[i] < frontier[j] })
+	}
+	return paths, nil
+}
+
+// MultiHopGraphSearch returns chunks linked to the entities in paths. A
+// chunk scores the best path score among its entities, with ties broken by
+// how many of the entities it mentions, and carries that path in GraphPath.
+func (s *Store) MultiHopGraphSearch(ctx context.Context, paths map[int64]EntityPath, limit int) ([]RetrievalResult, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	args := make([]interface{}, 0, len(paths))
+	for id := range paths {
+		args = append(args, id)
+	}
+	query := `
+		SELECT ec.entity_id, ec.chunk_id,
+			c.content, c.heading, c.chunk_type, c.page_number, c.position_in_doc,
+			COALESCE(c.start_offset, 0), COALESCE(c.end_offset, 0),
+			c.metadata, c.document_id,
+			d.filename, d.path, d.metadata
+		FROM entity_chunks ec
+		JOIN chunks c ON c.id = ec.chunk_id
+		` + s.documentsJoin() + `
+		WHERE ec.entity_id IN (?` + repeatPlaceholders(len(paths)-1) + `)`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type hit struct {
+		result   RetrievalResult
+		entities int
+	}
+	hits := make(map[int64]*hit)
 	for rows.Next() {
-		var e Entity
-		var metadata sql.NullString
-		if err := rows.Scan(&e.ID, &e.Name, &e.EntityType, &e.Description, &e.NameEN, &metadata); err != nil {
+		var entityID int64
+		var r RetrievalResult
+		var chunkMeta, docMeta sql.NullString
+		if err := rows.Scan(&entityID, &r.ChunkID,
+			&r.Content, &r.Heading, &r.ChunkType, &r.PageNumber, &r.PositionInDoc,
+			&r.StartOffset, &r.EndOffset,
+			&chunkMeta, &r.DocumentID,
+			&r.Filename, &r.Path, &docMeta); err != nil {
 			return nil, err
 		}
-		e.Metadata = metadata.String
-		entities = append(entities, e)
+		p := paths[entityID]
+		h, ok := hits[r.ChunkID]
+		if !ok {
+			r.ChunkMeta = chunkMeta.String
+			r.DocMeta = docMeta.String
+			h = &hit{result: r}
+			hits[r.ChunkID] = h
+		}
+		h.entities++
+		if p.Score > h.result.Score || h.result.GraphPath == "" {
+			h.result.Score = p.Score
+			h.result.GraphPath = p.Path
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
-	return entities, rows.Err()
-}
 
-// SearchEntitiesByTerms finds entities whose names contain any of the given
-// terms as substrings. This enables graph search to work when query terms are
-// single words (e.g. "rejected") and entity names are multi-word phrases
-// (e.g. "rechazador de envases"). Results are limited to avoid noise from
-// very short or generic terms.
-func (s *Store) SearchEntitiesByTerms(ctx context.Context, terms []string, limit int) ([]Entity, error) {
-	if len(terms) == 0 {
-		return nil, nil
+	ranked := make([]*hit, 0, len(hits))
+	for _, h := range hits {
+		ranked = append(ranked, h)
 	}
-	if limit == 0 {
-		limit = 50
+	sort.Slice(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if a.result.Score != b.result.Score {
+			return a.result.Score > b.result.Score
+		}
+		if a.entities != b.entities {
+			return a.entities > b.entities
+		}
+		return a.result.ChunkID < b.result.ChunkID
+	})
+	if limit > 0 && len(ranked) > limit {
+		ranked = ranked[:limit]
 	}
 
-	// Build OR conditions: name LIKE '%term1%' OR name LIKE '%term2%' ...
-	// Only use terms with length >= 4 to avoid noise from short words.
-	var conditions []string
-	var args []interface{}
-	for _, t := range terms {
-		if len(t) < 4 {
-			continue
-		}
-		conditions = append(conditions, "name LIKE ?")
-		args = append(args, "%"+t+"%")
+	results := make([]RetrievalResult, len(ranked))
+	for i, h := range ranked {
+		results[i] = h.result
 	}
-	if len(conditions) == 0 {
+	return s.openResults(results)
+}
+
+// ChunkIDsForEntities returns the IDs of the chunks linked to any of the
+// given entities. It queries in batches to avoid overly large IN clauses.
+func (s *Store) ChunkIDsForEntities(ctx context.Context, entityIDs []int64) ([]int64, error) {
+	if len(entityIDs) == 0 {
 		return nil, nil
 	}
 
-	query := "SELECT id, name, entity_type, description, COALESCE(name_en, ''), metadata FROM entities WHERE " +
-		strings.Join(conditions, " OR ") +
-		" LIMIT ?"
-	args = append(args, limit)
+	const batchSize = 200
+	seen := make(map[int64]bool)
+	var result []int64
 
-	rows, err := s.db.QueryContext(ctx, query, args...)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+	for start := 0; start < len(entityIDs); start += batchSize {
+		end := start + batchSize
+		if end > len(entityIDs) {
+			end = len(entityIDs)
+		}
+		batch := entityIDs[start:end]
 
-	var entities []Entity
-	for rows.Next() {
-		var e Entity
-		var metadata sql.NullString
-		if err := rows.Scan(&e.ID, &e.Name, &e.EntityType, &e.Description, &e.NameEN, &metadata); err != nil {
+		placeholders := "?"
+		for i := 1; i < len(batch); i++ {
+			placeholders += ", ?"
+		}
+
+		query := "SELECT DISTINCT chunk_id FROM entity_chunks WHERE entity_id IN (" + placeholders + ")"
+		args := make([]interface{}, len(batch))
+		for i, id := range batch {
+			args[i] = id
+		}
+
+		rows, err := s.db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("querying entity_chunks: %w", err)
+		}
+
+		for rows.Next() {
+			var cid int64
+			if err := rows.Scan(&cid); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if !seen[cid] {
+				seen[cid] = true
+				result = append(result, cid)
+			}
+		}
+		if err := rows.Err(); err != nil {
+			rows.Close()
 			return nil, err
 		}
-		e.Metadata = metadata.String
-		entities = append(entities, e)
+		rows.Close()
 	}
-	return entities, rows.Err()
+
+	return result, nil
 }
 
-// GraphSearch finds chunks reachable via entity relationships.
-func (s *Store) GraphSearch(ctx context.Context, entityIDs []int64, limit int) ([]RetrievalResult, error) {
-	if len(entityIDs) == 0 {
+// GetEntitiesByChunkIDs returns the entities linked to any of the given
+// chunks, most frequently linked first.
+func (s *Store) GetEntitiesByChunkIDs(ctx context.Context, chunkIDs []int64, limit int) ([]Entity, error) {
+	if len(chunkIDs) == 0 {
 		return nil, nil
 	}
+	if limit == 0 {
+		limit = 100
+	}
 
 	query := `
-		SELECT DISTINCT ec.chunk_id, COALESCE(MAX(r.weight), 0.5),
-			c.content, c.heading, c.chunk_type, c.page_number, c.position_in_doc,
-			c.metadata, c.document_id,
-			d.filename, d.path, d.metadata
-		FROM entity_chunks ec
-		LEFT JOIN relationships r ON r.source_entity_id = ec.entity_id OR r.target_entity_id = ec.entity_id
-		JOIN chunks c ON c.id = ec.chunk_id
-		JOIN documents d ON d.id = c.document_id
-		WHERE ec.entity_id IN (?` + repeatPlaceholders(len(entityIDs)-1) + `)
-		GROUP BY ec.chunk_id
-		ORDER BY COALESCE(MAX(r.weight), 0.5) DESC
+		SELECT e.id, e.name, e.entity_type, e.description, COALESCE(e.name_en, ''), e.metadata
+		FROM entities e
+		JOIN entity_chunks ec ON ec.entity_id = e.id
+		WHERE ec.chunk_id IN (?` + repeatPlaceholders(len(chunkIDs)-1) + `)
+		GROUP BY e.id
+		ORDER BY COUNT(*) DESC, e.id
 		LIMIT ?`
 
-	args := make([]interface{}, 0, len(entityIDs)+1)
-	for _, id := range entityIDs {
+	args := make([]interface{}, 0, len(chunkIDs)+1)
+	for _, id := range chunkIDs {
 		args = append(args, id)
 	}
 	args = append(args, limit)
@@ -832,21 +3041,17 @@ func (s *Store) GraphSearch(ctx context.Context, entityIDs []int64, limit int) (
 	}
 	defer rows.Close()
 
-	var results []RetrievalResult
+	var entities []Entity
 	for rows.Next() {
-		var r RetrievalResult
-		var chunkMeta, docMeta sql.NullString
-		if err := rows.Scan(&r.ChunkID, &r.Score,
-			&r.Content, &r.Heading, &r.ChunkType, &r.PageNumber, &r.PositionInDoc,
-			&chunkMeta, &r.DocumentID,
-			&r.Filename, &r.Path, &docMeta); err != nil {
+		var e Entity
+		var metadata sql.NullString
+		if err := rows.Scan(&e.ID, &e.Name, &e.EntityType, &e.Description, &e.NameEN, &metadata); err != nil {
 			return nil, err
 		}
-		r.ChunkMeta = chunkMeta.String
-		r.DocMeta = docMeta.String
-		results = append(results, r)
+		e.Metadata = metadata.String
+		entities = append(entities, e)
 	}
-	return results, rows.Err()
+	return entities, rows.Err()
 }
 
 // GetRelatedEntities performs a 1-hop expansion from the given seed entity IDs
@@ -908,9 +3113,9 @@ func (s *Store) GetRelatedEntities(ctx context.Context, entityIDs []int64, limit
 
 // InsertCommunity stores a community detection result.
 func (s *Store) InsertCommunity(ctx context.Context, c Community) (int64, error) {
-	res, err := s.db.ExecContext(ctx,
-		"INSERT INTO communities (level, summary, entity_ids) VALUES (?, ?, ?)",
-		c.Level, c.Summary, c.EntityIDs)
+	res, err := s.exec(ctx,
+		"INSERT INTO communities (level, summary, entity_ids, signature, summary_signature) VALUES (?, ?, ?, ?, ?)",
+		c.Level, c.Summary, c.EntityIDs, c.Signature, c.SummarySignature)
 	if err != nil {
 		return 0, err
 	}
@@ -919,8 +3124,18 @@ func (s *Store) InsertCommunity(ctx context.Context, c Community) (int64, error)
 
 // GetCommunities returns all communities at a given level.
 func (s *Store) GetCommunities(ctx context.Context, level int) ([]Community, error) {
+	return s.queryCommunities(ctx, "WHERE level = ?", level)
+}
+
+// StaleCommunities returns the communities whose summary is missing or
+// was written for an earlier set of members (see Community.Stale).
+func (s *Store) StaleCommunities(ctx context.Context) ([]Community, error) {
+	return s.queryCommunities(ctx, "WHERE COALESCE(summary, '') = '' OR summary_signature != signature ORDER BY id")
+}
+
+func (s *Store) queryCommunities(ctx context.Context, where string, args ...interface{}) ([]Community, error) {
 	rows, err := s.db.QueryContext(ctx,
-		"SELECT id, level, summary, entity_ids FROM communities WHERE level = ?", level)
+		"SELECT id, level, COALESCE(summary, ''), entity_ids, signature, summary_signature FROM communities "+where, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -929,7 +3144,7 @@ func (s *Store) GetCommunities(ctx context.Context, level int) ([]Community, err
 	var communities []Community
 	for rows.Next() {
 		var c Community
-		if err := rows.Scan(&c.ID, &c.Level, &c.Summary, &c.EntityIDs); err != nil {
+		if err := rows.Scan(&c.ID, &c.Level, &c.Summary, &c.EntityIDs, &c.Signature, &c.SummarySignature); err != nil {
 			return nil, err
 		}
 		communities = append(communities, c)
@@ -939,7 +3154,15 @@ func (s *Store) GetCommunities(ctx context.Context, level int) ([]Community, err
 
 // ClearCommunities removes all community data.
 func (s *Store) ClearCommunities(ctx context.Context) error {
-	_, err := s.db.ExecContext(ctx, "DELETE FROM communities")
+	_, err := s.exec(ctx, "DELETE FROM communities")
+	return err
+}
+
+// SetCommunitySummary stores the summary of a community, marking it
+// current for the community's members.
+func (s *Store) SetCommunitySummary(ctx context.Context, id int64, summary string) error {
+	_, err := s.exec(ctx,
+		"UPDATE communities SET summary = ?, summary_signature = signature WHERE id = ?", summary, id)
 	return err
 }
 
@@ -947,15 +3170,229 @@ func (s *Store) ClearCommunities(ctx context.Context) error {
 
 // LogQuery writes an entry to the query audit log.
 func (s *Store) LogQuery(ctx context.Context, q QueryLog) error {
+	_, err := s.InsertQueryLog(ctx, q)
+	return err
+}
+
+// InsertQueryLog records a query and returns its query log ID.
+func (s *Store) InsertQueryLog(ctx context.Context, q QueryLog) (int64, error) {
 	sourcesJSON, _ := json.Marshal(q.Sources)
-	_, err := s.db.ExecContext(ctx, `
-		INSERT INTO query_log (query, answer, confidence, sources, retrieval_method, model_used, rounds, prompt_tokens, completion_tokens, total_tokens)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, q.Query, q.Answer, q.Confidence, string(sourcesJSON), q.RetrievalMethod, q.ModelUsed, q.Rounds,
-		q.PromptTokens, q.CompletionTokens, q.TotalTokens)
+	var traceJSON sql.NullString
+	if q.Trace != nil {
+		data, _ := json.Marshal(q.Trace)
+		traceJSON = sql.NullString{String: string(data), Valid: true}
+	}
+	var id int64
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO query_log (query, answer, confidence, sources, retrieval_method, model_used, rounds,
+				prompt_tokens, completion_tokens, total_tokens, elapsed_ms, experiment, arm, request_id, refusal_reason, trace)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''), ?)
+		`, q.Query, q.Answer, q.Confidence, string(sourcesJSON), q.RetrievalMethod, q.ModelUsed, q.Rounds,
+			q.PromptTokens, q.CompletionTokens, q.TotalTokens, q.ElapsedMs, q.Experiment, q.Arm, q.RequestID, q.RefusalReason,
+			traceJSON)
+		if err != nil {
+			return err
+		}
+		if id, err = res.LastInsertId(); err != nil {
+			return err
+		}
+		// Link the cited chunks, so StaleQueries finds the answers whose
+		// sources a later re-ingest removed.
+		_, err = tx.ExecContext(ctx, `
+			INSERT OR IGNORE INTO query_sources (query_id, chunk_id, document_id, live_chunk_id)
+			SELECT ?1, json_extract(?2, j.fullkey || '.chunk_id'), json_extract(?2, j.fullkey || '.document_id'), c.id
+			FROM json_each(CASE WHEN json_valid(?2) AND json_type(?2) = 'array' THEN ?2 ELSE '[]' END) j
+			LEFT JOIN chunks c ON c.id = json_extract(?2, j.fullkey || '.chunk_id')
+			WHERE j.type = 'object'
+			  AND json_extract(?2, j.fullkey || '.chunk_id') IS NOT NULL
+			  AND json_extract(?2, j.fullkey || '.document_id') IS NOT NULL`, id, string(sourcesJSON))
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// GetQueryLog returns a logged query. It returns sql.ErrNoRows when the
+// query does not exist.
+func (s *Store) GetQueryLog(ctx context.Context, id int64) (*QueryLogEntry, error) {
+	var q QueryLogEntry
+	var sources, trace sql.NullString
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, query, COALESCE(answer, ''), COALESCE(confidence, 0), sources, COALESCE(retrieval_method, ''),
+			COALESCE(model_used, ''), COALESCE(rounds, 0), COALESCE(prompt_tokens, 0), COALESCE(completion_tokens, 0),
+			COALESCE(total_tokens, 0), COALESCE(elapsed_ms, 0), COALESCE(experiment, ''), COALESCE(arm, ''),
+			COALESCE(request_id, ''), COALESCE(refusal_reason, ''), trace, created_at
+		FROM query_log WHERE id = ?`, id).Scan(
+		&q.ID, &q.Query, &q.Answer, &q.Confidence, &sources, &q.RetrievalMethod,
+		&q.ModelUsed, &q.Rounds, &q.PromptTokens, &q.CompletionTokens,
+		&q.TotalTokens, &q.ElapsedMs, &q.Experiment, &q.Arm,
+		&q.RequestID, &q.RefusalReason, &trace, &q.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if sources.Valid {
+		q.Sources = json.RawMessage(sources.String)
+	}
+	if trace.Valid {
+		q.Trace = json.RawMessage(trace.String)
+	}
+	return &q, nil
+}
+
+// SetQueryFeedback records a caller's rating of a logged answer, replacing
+// any earlier rating. It returns sql.ErrNoRows when the query does not
+// exist.
+func (s *Store) SetQueryFeedback(ctx context.Context, queryID int64, rating int, comment string) error {
+	res, err := s.exec(ctx, `
+		UPDATE query_log SET feedback_rating = ?, feedback_comment = ?, feedback_at = CURRENT_TIMESTAMP
+		WHERE id = ?`, rating, comment, queryID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ArmStats aggregates the logged queries of one experiment arm.
+type ArmStats struct {
+	Arm           string  `json:"arm"`
+	Queries       int     `json:"queries"`
+	AvgConfidence float64 `json:"avg_confidence"`
+	AvgElapsedMs  float64 `json:"avg_elapsed_ms"`
+	AvgTokens     float64 `json:"avg_tokens"`
+	Feedback      int     `json:"feedback"`      // queries with a rating
+	AvgRating     float64 `json:"avg_rating"`    // mean rating, -1 to 1
+	PositiveRate  float64 `json:"positive_rate"` // share of ratings above 0
+	FeedbackRate  float64 `json:"feedback_rate"` // share of queries rated
+}
+
+// ExperimentStats returns per-arm aggregates of an experiment's logged
+// queries, ordered by arm name.
+func (s *Store) ExperimentStats(ctx context.Context, experiment string) ([]ArmStats, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT arm, COUNT(*), COALESCE(AVG(confidence), 0), COALESCE(AVG(elapsed_ms), 0),
+			COALESCE(AVG(total_tokens), 0), COUNT(feedback_rating),
+			COALESCE(AVG(feedback_rating), 0),
+			COALESCE(SUM(CASE WHEN feedback_rating > 0 THEN 1 ELSE 0 END), 0)
+		FROM query_log WHERE experiment = ?
+		GROUP BY arm ORDER BY arm`, experiment)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []ArmStats
+	for rows.Next() {
+		var a ArmStats
+		var positive int
+		if err := rows.Scan(&a.Arm, &a.Queries, &a.AvgConfidence, &a.AvgElapsedMs, &a.AvgTokens,
+			&a.Feedback, &a.AvgRating, &positive); err != nil {
+			return nil, err
+		}
+		if a.Feedback > 0 {
+			a.PositiveRate = float64(positive) / float64(a.Feedback)
+		}
+		if a.Queries > 0 {
+			a.FeedbackRate = float64(a.Feedback) / float64(a.Queries)
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// --- Audit log ---
+
+// AuditEntry represents a row in the audit_log table.
+type AuditEntry struct {
+	ID         int64  `json:"id"`
+	Operation  string `json:"operation"`
+	Actor      string `json:"actor,omitempty"`
+	Params     string `json:"params,omitempty"` // JSON object
+	Outcome    string `json:"outcome"`
+	Error      string `json:"error,omitempty"`
+	DocumentID int64  `json:"document_id,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	CreatedAt  string `json:"created_at"`
+}
+
+// AuditFilter narrows ListAuditEntries. Zero fields match everything.
+type AuditFilter struct {
+	Operation  string
+	Actor      string
+	DocumentID int64
+	Since      time.Time
+	Limit      int // default 100
+}
+
+// InsertAuditEntry appends an entry to the audit log.
+func (s *Store) InsertAuditEntry(ctx context.Context, a AuditEntry) error {
+	var docID interface{}
+	if a.DocumentID != 0 {
+		docID = a.DocumentID
+	}
+	_, err := s.exec(ctx, `
+		INSERT INTO audit_log (operation, actor, params, outcome, error, document_id, duration_ms)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, a.Operation, a.Actor, a.Params, a.Outcome, a.Error, docID, a.DurationMs)
 	return err
 }
 
+// ListAuditEntries returns audit entries matching f, newest first.
+func (s *Store) ListAuditEntries(ctx context.Context, f AuditFilter) ([]AuditEntry, error) {
+	var where []string
+	var args []interface{}
+	if f.Operation != "" {
+		where = append(where, "operation = ?")
+		args = append(args, f.Operation)
+	}
+	if f.Actor != "" {
+		where = append(where, "actor = ?")
+		args = append(args, f.Actor)
+	}
+	if f.DocumentID != 0 {
+		where = append(where, "document_id = ?")
+		args = append(args, f.DocumentID)
+	}
+	if !f.Since.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, f.Since.UTC().Format("2006-01-02 15:04:05"))
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+
+	query := `SELECT id, operation, COALESCE(actor, ''), COALESCE(params, ''), outcome,
+		COALESCE(error, ''), COALESCE(document_id, 0), duration_ms, created_at FROM audit_log`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []AuditEntry
+	for rows.Next() {
+		var a AuditEntry
+		if err := rows.Scan(&a.ID, &a.Operation, &a.Actor, &a.Params, &a.Outcome,
+			&a.Error, &a.DocumentID, &a.DurationMs, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
 // --- Graph data for community detection ---
 
 // AllEntities returns every entity in the database.
@@ -1008,7 +3445,7 @@ func (s *Store) AllRelationships(ctx context.Context) ([]Relationship, error) {
 
 // UpdateDocumentLanguage sets the detected language for a document.
 func (s *Store) UpdateDocumentLanguage(ctx context.Context, docID int64, language string) error {
-	_, err := s.db.ExecContext(ctx,
+	_, err := s.exec(ctx,
 		"UPDATE documents SET language = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
 		language, docID)
 	return err
@@ -1082,6 +3519,75 @@ func (s *Store) SearchEntitiesByNameEN(ctx context.Context, terms []string, limi
 	return entities, rows.Err()
 }
 
+// --- Query translation cache ---
+
+// QueryTranslation is the cached translation of a question's search terms
+// into one language. PromptTokens and CompletionTokens are this language's
+// share of the LLM call that produced it, so a cache hit reports what it
+// saved.
+type QueryTranslation struct {
+	QuestionHash     string   `json:"question_hash"`
+	Language         string   `json:"language"`
+	Terms            []string `json:"terms"`
+	PromptTokens     int      `json:"prompt_tokens"`
+	CompletionTokens int      `json:"completion_tokens"`
+}
+
+// PutQueryTranslation stores a query translation, replacing an earlier one
+// for the same question and language. A read-only store keeps nothing.
+func (s *Store) PutQueryTranslation(ctx context.Context, t QueryTranslation) error {
+	if s.readOnly {
+		return nil
+	}
+	terms, err := json.Marshal(t.Terms)
+	if err != nil {
+		return err
+	}
+	_, err = s.exec(ctx, `
+		INSERT INTO query_translations (question_hash, language, terms, prompt_tokens, completion_tokens, created_at)
+		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(question_hash, language) DO UPDATE SET
+			terms = excluded.terms, prompt_tokens = excluded.prompt_tokens,
+			completion_tokens = excluded.completion_tokens, created_at = excluded.created_at
+	`, t.QuestionHash, t.Language, string(terms), t.PromptTokens, t.CompletionTokens)
+	return err
+}
+
+// GetQueryTranslations returns the cached translations of a question into
+// the given languages, keyed by language. Languages without one are absent.
+func (s *Store) GetQueryTranslations(ctx context.Context, questionHash string, languages []string) (map[string]QueryTranslation, error) {
+	if len(languages) == 0 {
+		return nil, nil
+	}
+	args := make([]interface{}, 0, len(languages)+1)
+	args = append(args, questionHash)
+	for _, l := range languages {
+		args = append(args, l)
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT language, terms, prompt_tokens, completion_tokens
+		FROM query_translations
+		WHERE question_hash = ? AND language IN (?`+strings.Repeat(",?", len(languages)-1)+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[string]QueryTranslation, len(languages))
+	for rows.Next() {
+		t := QueryTranslation{QuestionHash: questionHash}
+		var terms string
+		if err := rows.Scan(&t.Language, &terms, &t.PromptTokens, &t.CompletionTokens); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(terms), &t.Terms); err != nil {
+			return nil, fmt.Errorf("decoding cached translation terms: %w", err)
+		}
+		out[t.Language] = t
+	}
+	return out, rows.Err()
+}
+
 // --- Diagnostic helpers (used by eval ground-truth checks) ---
 
 // ChunkMatch holds the result of a content substring search.
@@ -1124,6 +3630,19 @@ func (s *Store) ChunkHasEmbedding(ctx context.Context, chunkID int64) (bool, err
 	return count > 0, nil
 }
 
+// HasGraph reports whether any entity-chunk links exist, i.e. whether graph
+// retrieval can return anything. It is false for corpora ingested with
+// SkipGraph or whose graph extraction produced nothing.
+func (s *Store) HasGraph(ctx context.Context) (bool, error) {
+	var exists int
+	err := s.db.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM entity_chunks LIMIT 1)").Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+	return exists == 1, nil
+}
+
 // DBStats holds counts of key database objects.
 type DBStats struct {
 	Chunks        int `json:"chunks"`
@@ -1161,7 +3680,8 @@ func (s *Store) DBStats(ctx context.Context) (*DBStats, error) {
 func (s *Store) SampleChunks(ctx context.Context, n int) ([]Chunk, error) {
 	rows, err := s.db.QueryContext(ctx, `
 		SELECT id, document_id, parent_chunk_id, content, chunk_type, heading,
-			page_number, position_in_doc, token_count, metadata, content_hash
+			page_number, position_in_doc, token_count, metadata, content_hash,
+			COALESCE(start_offset, 0), COALESCE(end_offset, 0)
 		FROM chunks ORDER BY RANDOM() LIMIT ?
 	`, n)
 	if err != nil {
@@ -1175,9 +3695,18 @@ func (s *Store) SampleChunks(ctx context.Context, n int) ([]Chunk, error) {
 		var metadata sql.NullString
 		if err := rows.Scan(&c.ID, &c.DocumentID, &c.ParentChunkID, &c.Content,
 			&c.ChunkType, &c.Heading, &c.PageNumber, &c.PositionInDoc,
-			&c.TokenCount, &metadata, &c.ContentHash); err != nil {
+			&c.TokenCount, &metadata, &c.ContentHash,
+			&c.StartOffset, &c.EndOffset); err != nil {
 			return nil, err
 		}
+		content, err := s.openText(c.Content)
+		if errors.Is(err, ErrNoContentKey) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("chunk %d: %w", c.ID, err)
+		}
+		c.Content = content
 		c.Metadata = metadata.String
 		chunks = append(chunks, c)
 	}
@@ -1186,16 +3715,24 @@ func (s *Store) SampleChunks(ctx context.Context, n int) ([]Chunk, error) {
 
 // --- helpers ---
 
+// inTx runs fn in a transaction through the writer.
 func (s *Store) inTx(ctx context.Context, fn func(*sql.Tx) error) error {
-	tx, err := s.db.BeginTx(ctx, nil)
-	if err != nil {
-		return err
-	}
-	if err := fn(tx); err != nil {
-		tx.Rollback()
-		return err
-	}
-	return tx.Commit()
+	return s.write(ctx, func(ctx context.Context) error {
+		var tx *sql.Tx
+		err := s.retryBusy(ctx, func() error {
+			var err error
+			tx, err = s.db.BeginTx(ctx, nil)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if err := fn(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+		return tx.Commit()
+	})
 }
 
 func repeatPlaceholders(n int) string {
@@ -3,13 +3,22 @@
 package store
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
 )
 
-func newTestStore(t *testing.T) *Store {
+func newTestStore(t testing.TB) *Store {
 	t.Helper()
 	dbPath := filepath.Join(t.TempDir(), "test.db")
 	s, err := New(dbPath, 4) // dim=4 for test vectors
@@ -44,6 +53,79 @@ func TestNewCreatesParentDir(t *testing.T) {
 	s.Close()
 }
 
+func TestOpenReader(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	s, err := New(dbPath, 4)
+	if err != nil {
+		t.Fatalf("creating store: %v", err)
+	}
+	defer s.Close()
+	seedSearchCorpus(t, s, 5)
+	if err := s.EnableSecondaryVectors(context.Background(), 4); err != nil {
+		t.Fatalf("EnableSecondaryVectors: %v", err)
+	}
+
+	r, err := OpenReader(dbPath, 4)
+	if err != nil {
+		t.Fatalf("OpenReader: %v", err)
+	}
+	defer r.Close()
+	ctx := context.Background()
+	if res, err := r.FTSSearch(ctx, "torque", 3); err != nil || len(res) != 3 {
+		t.Errorf("FTSSearch through reader: %d results, %v", len(res), err)
+	}
+	if !r.secondaryVec {
+		t.Error("reader did not detect the secondary vector table")
+	}
+	if _, err := r.UpsertDocument(ctx, sampleDoc("/tmp/other.pdf")); err == nil {
+		t.Error("reader accepted a write")
+	}
+
+	// Writes through the primary store are visible to the reader.
+	if _, err := s.UpsertDocument(ctx, sampleDoc("/tmp/other.pdf")); err != nil {
+		t.Fatalf("UpsertDocument: %v", err)
+	}
+	if docs, err := r.ListDocuments(ctx); err != nil || len(docs) != 2 {
+		t.Errorf("ListDocuments through reader: %d documents, %v", len(docs), err)
+	}
+}
+
+func TestOpenReaderImmutable(t *testing.T) {
+	// The immutable reader opens a URI filename, where "#" and "%" mean
+	// something unless escaped.
+	dbPath := filepath.Join(t.TempDir(), "test #1 %41.db")
+	s, err := New(dbPath, 4)
+	if err != nil {
+		t.Fatalf("creating store: %v", err)
+	}
+	seedSearchCorpus(t, s, 5)
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := os.Stat(dbPath + "-wal"); !os.IsNotExist(err) {
+		t.Fatalf("WAL left after close: %v", err)
+	}
+
+	r, err := OpenReaderWithOptions(dbPath, 4, ReaderOptions{Immutable: true})
+	if err != nil {
+		t.Fatalf("OpenReaderWithOptions: %v", err)
+	}
+	defer r.Close()
+	ctx := context.Background()
+	if res, err := r.FTSSearch(ctx, "torque", 3); err != nil || len(res) != 3 {
+		t.Errorf("FTSSearch through immutable reader: %d results, %v", len(res), err)
+	}
+	if _, err := r.UpsertDocument(ctx, sampleDoc("/tmp/other.pdf")); err == nil {
+		t.Error("immutable reader accepted a write")
+	}
+	// Nothing is created next to the database file.
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if _, err := os.Stat(dbPath + suffix); !os.IsNotExist(err) {
+			t.Errorf("%s file exists: %v", suffix, err)
+		}
+	}
+}
+
 // ---------------------------------------------------------------------------
 // Document CRUD
 // ---------------------------------------------------------------------------
@@ -151,6 +233,50 @@ func TestUpsertDocumentUpdate(t *testing.T) {
 	}
 }
 
+func TestDocumentCollections(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	doc := sampleDoc("/contracts/a.pdf")
+	doc.Collection = "contracts"
+	idA, err := s.UpsertDocument(ctx, doc)
+	if err != nil {
+		t.Fatalf("upsert a: %v", err)
+	}
+	idB, err := s.UpsertDocument(ctx, sampleDoc("/manuals/b.pdf"))
+	if err != nil {
+		t.Fatalf("upsert b: %v", err)
+	}
+
+	// Re-ingest without a collection keeps the existing one.
+	doc.Collection = ""
+	doc.ContentHash = "changed"
+	if _, err := s.UpsertDocument(ctx, doc); err != nil {
+		t.Fatalf("re-upsert a: %v", err)
+	}
+	got, err := s.GetDocument(ctx, idA)
+	if err != nil {
+		t.Fatalf("get a: %v", err)
+	}
+	if got.Collection != "contracts" {
+		t.Errorf("collection = %q, want contracts", got.Collection)
+	}
+
+	if err := s.SetDocumentCollection(ctx, idB, "contracts"); err != nil {
+		t.Fatalf("SetDocumentCollection: %v", err)
+	}
+	ids, err := s.DocumentIDsInCollection(ctx, "contracts")
+	if err != nil {
+		t.Fatalf("DocumentIDsInCollection: %v", err)
+	}
+	if len(ids) != 2 || ids[0] != idA || ids[1] != idB {
+		t.Errorf("ids = %v, want [%d %d]", ids, idA, idB)
+	}
+	if ids, _ := s.DocumentIDsInCollection(ctx, "missing"); len(ids) != 0 {
+		t.Errorf("unknown collection returned %v", ids)
+	}
+}
+
 func TestListDocuments(t *testing.T) {
 	s := newTestStore(t)
 	ctx := context.Background()
@@ -240,6 +366,60 @@ func TestDeleteDocument(t *testing.T) {
 	}
 }
 
+func TestCompact(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	var chunkIDs []int64
+	for i := 0; i < 2; i++ {
+		docID, _ := s.UpsertDocument(ctx, sampleDoc(fmt.Sprintf("/compact%d.pdf", i)))
+		ids, err := s.InsertChunks(ctx, []Chunk{
+			{DocumentID: docID, Content: "damper housing", ChunkType: "p", PositionInDoc: 0, TokenCount: 2},
+		})
+		if err != nil {
+			t.Fatalf("insert chunks: %v", err)
+		}
+		chunkIDs = append(chunkIDs, ids[0])
+	}
+	shared, _ := s.UpsertEntityAndLink(ctx, Entity{Name: "damper", EntityType: "component"}, chunkIDs[0])
+	s.LinkEntityChunk(ctx, shared, chunkIDs[1])
+	gone, _ := s.UpsertEntityAndLink(ctx, Entity{Name: "housing", EntityType: "component"}, chunkIDs[0])
+	s.InsertEntityEmbedding(ctx, gone, []float32{0, 1, 0, 0})
+	// Sourced from the surviving document, but one endpoint loses its chunks.
+	s.InsertRelationship(ctx, Relationship{
+		SourceEntityID: gone, TargetEntityID: shared, RelationType: "part_of", Weight: 1, SourceChunkID: &chunkIDs[1],
+	})
+	// A vector row whose chunk never existed.
+	s.InsertEmbedding(ctx, chunkIDs[1]+100, []float32{1, 0, 0, 0})
+
+	docs, _ := s.ListDocuments(ctx)
+	if err := s.DeleteDocument(ctx, docs[0].ID); err != nil {
+		t.Fatalf("delete document: %v", err)
+	}
+
+	stats, err := s.Compact(ctx)
+	if err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+	if stats.Entities != 1 || stats.Relationships != 1 || stats.Vectors != 2 {
+		t.Errorf("Compact = %+v, want 1 entity, 1 relationship, 2 vectors", stats)
+	}
+	if stats.BytesBefore == 0 || stats.BytesAfter == 0 || stats.BytesAfter > stats.BytesBefore {
+		t.Errorf("sizes = %d -> %d", stats.BytesBefore, stats.BytesAfter)
+	}
+
+	entities, err := s.GetEntitiesByNames(ctx, []string{"damper", "housing"})
+	if err != nil || len(entities) != 1 || entities[0].ID != shared {
+		t.Errorf("entities after compaction = %+v, %v; want only damper", entities, err)
+	}
+
+	// A second pass finds nothing left to remove.
+	stats, err = s.Compact(ctx)
+	if err != nil || stats.Entities+stats.Relationships+stats.Vectors != 0 {
+		t.Errorf("second Compact = %+v, %v", stats, err)
+	}
+}
+
 // ---------------------------------------------------------------------------
 // Chunk operations
 // ---------------------------------------------------------------------------
@@ -286,6 +466,17 @@ func TestInsertAndGetChunks(t *testing.T) {
 	if got[0].ContentHash == "" {
 		t.Error("expected non-empty content_hash")
 	}
+
+	one, err := s.GetChunk(ctx, ids[1])
+	if err != nil {
+		t.Fatalf("getting chunk: %v", err)
+	}
+	if one.Content != "second chunk" || one.Heading != "Body" || one.DocumentID != docID {
+		t.Errorf("GetChunk = %+v", one)
+	}
+	if _, err := s.GetChunk(ctx, 99999); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetChunk(missing) error = %v, want sql.ErrNoRows", err)
+	}
 }
 
 // ---------------------------------------------------------------------------
@@ -370,6 +561,98 @@ func TestVectorSearchTopK(t *testing.T) {
 	}
 }
 
+func TestSearchReadyOnly(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	query := []float32{0, 1, 0, 0}
+	var ids []int64
+	for _, status := range []string{"ready", "processing", "error"} {
+		doc := sampleDoc(fmt.Sprintf("/%s.pdf", status))
+		doc.Status = status
+		docID, _ := s.UpsertDocument(ctx, doc)
+		chunkIDs, err := s.InsertChunks(ctx, []Chunk{
+			{DocumentID: docID, Content: "relief valve set pressure, " + status, ChunkType: "paragraph", TokenCount: 4},
+		})
+		if err != nil {
+			t.Fatalf("insert chunks: %v", err)
+		}
+		// The unready documents' chunks are the nearest.
+		emb := query
+		if status == "ready" {
+			emb = []float32{1, 0, 0, 0}
+		}
+		if err := s.InsertEmbedding(ctx, chunkIDs[0], emb); err != nil {
+			t.Fatalf("embedding: %v", err)
+		}
+		ids = append(ids, chunkIDs[0])
+	}
+
+	s.SetSearchReadyOnly(true)
+	vec, err := s.VectorSearch(ctx, query, 1)
+	if err != nil {
+		t.Fatalf("vector search: %v", err)
+	}
+	if len(vec) != 1 || vec[0].ChunkID != ids[0] {
+		t.Errorf("ready-only vector search = %+v, want the ready chunk", vec)
+	}
+	fts, err := s.FTSSearch(ctx, "relief", 10)
+	if err != nil {
+		t.Fatalf("fts search: %v", err)
+	}
+	if len(fts) != 1 || fts[0].ChunkID != ids[0] {
+		t.Errorf("ready-only FTS search = %+v, want the ready chunk", fts)
+	}
+
+	s.SetSearchReadyOnly(false)
+	if vec, _ := s.VectorSearch(ctx, query, 1); len(vec) != 1 || vec[0].ChunkID == ids[0] {
+		t.Errorf("vector search = %+v, want an unready chunk", vec)
+	}
+	if fts, _ := s.FTSSearch(ctx, "relief", 10); len(fts) != 3 {
+		t.Errorf("FTS search returned %d chunks, want all 3", len(fts))
+	}
+}
+
+func TestSearchReadyOnlyReingest(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	s.SetSearchReadyOnly(true)
+
+	doc := sampleDoc("/manual.pdf")
+	doc.Status = "processing"
+	docID, _ := s.UpsertDocument(ctx, doc)
+	if _, err := s.InsertChunks(ctx, []Chunk{{DocumentID: docID, Content: "relief valve", ChunkType: "paragraph"}}); err != nil {
+		t.Fatal(err)
+	}
+	s.UpdateDocumentSummary(ctx, docID, "A pump manual.", `["pump"]`)
+	found := func() (int, int) {
+		t.Helper()
+		fts, err := s.FTSSearch(ctx, "relief", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		summaries, err := s.DocumentSummaries(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(fts), len(summaries)
+	}
+	if fts, summaries := found(); fts != 0 || summaries != 0 {
+		t.Errorf("first ingest running: %d chunks, %d summaries, want none", fts, summaries)
+	}
+
+	// Once ready, the document stays searchable while it is re-ingested
+	// and after a re-ingest fails.
+	s.UpdateDocumentStatus(ctx, docID, "ready")
+	for _, status := range []string{"processing", "error"} {
+		doc.Status = status
+		s.UpsertDocument(ctx, doc)
+		if fts, summaries := found(); fts != 1 || summaries != 1 {
+			t.Errorf("re-ingest %s: %d chunks, %d summaries, want 1 each", status, fts, summaries)
+		}
+	}
+}
+
 // ---------------------------------------------------------------------------
 // FTS search
 // ---------------------------------------------------------------------------
@@ -407,6 +690,36 @@ func TestFTSSearch(t *testing.T) {
 	}
 }
 
+func TestFTSSearchHeadingWeight(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	docID, _ := s.UpsertDocument(ctx, sampleDoc("/weights.pdf"))
+	ids, err := s.InsertChunks(ctx, []Chunk{
+		{DocumentID: docID, Content: "Temperatura de operación entre 5 y 40 grados, humedad relativa máxima del 80 por ciento sin condensación.", ChunkType: "paragraph", Heading: "Condiciones ambientales", PositionInDoc: 0, TokenCount: 20},
+		{DocumentID: docID, Content: "Revise las condiciones de la garantía. Las condiciones ambientales extremas anulan la garantía; las condiciones ambientales se indican aparte.", ChunkType: "paragraph", Heading: "Garantía", PositionInDoc: 1, TokenCount: 20},
+	})
+	if err != nil {
+		t.Fatalf("insert chunks: %v", err)
+	}
+
+	res, err := s.FTSSearch(ctx, "condiciones ambientales", 10)
+	if err != nil || len(res) != 2 {
+		t.Fatalf("FTSSearch = %d results, %v", len(res), err)
+	}
+	if res[0].ChunkID != ids[1] {
+		t.Fatalf("with equal weights the repeated body terms should win, got chunk %d first", res[0].ChunkID)
+	}
+
+	res, err = s.FTSSearchWeighted(ctx, "condiciones ambientales", 10, 5)
+	if err != nil || len(res) != 2 {
+		t.Fatalf("FTSSearchWeighted = %d results, %v", len(res), err)
+	}
+	if res[0].ChunkID != ids[0] {
+		t.Errorf("heading match should rank first with heading weight 5, got chunk %d", res[0].ChunkID)
+	}
+}
+
 func TestFTSSearchNoMatch(t *testing.T) {
 	s := newTestStore(t)
 	ctx := context.Background()
@@ -472,6 +785,130 @@ func TestUpsertEntityAndGetByNames(t *testing.T) {
 	}
 }
 
+func TestEntityVectorSearch(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	valve, _ := s.UpsertEntity(ctx, Entity{Name: "relief valve", EntityType: "component", Description: "Opens at 10 bar"})
+	pump, _ := s.UpsertEntity(ctx, Entity{Name: "pump", EntityType: "component"})
+
+	pending, err := s.EntitiesWithoutEmbedding(ctx, 0, 10)
+	if err != nil || len(pending) != 2 {
+		t.Fatalf("EntitiesWithoutEmbedding = %d, %v; want 2", len(pending), err)
+	}
+	if err := s.InsertEntityEmbedding(ctx, valve, []float32{1, 0, 0, 0}); err != nil {
+		t.Fatalf("InsertEntityEmbedding: %v", err)
+	}
+	if err := s.InsertEntityEmbedding(ctx, pump, []float32{0, 1, 0, 0}); err != nil {
+		t.Fatalf("InsertEntityEmbedding: %v", err)
+	}
+	if pending, _ := s.EntitiesWithoutEmbedding(ctx, 0, 10); len(pending) != 0 {
+		t.Errorf("expected no entities left to embed, got %d", len(pending))
+	}
+
+	matches, err := s.EntityVectorSearch(ctx, []float32{0.9, 0.1, 0, 0}, 2)
+	if err != nil {
+		t.Fatalf("EntityVectorSearch: %v", err)
+	}
+	if len(matches) != 2 || matches[0].ID != valve || matches[0].Description != "Opens at 10 bar" {
+		t.Fatalf("expected relief valve first, got %+v", matches)
+	}
+	if matches[0].Similarity <= matches[1].Similarity || matches[0].Similarity < 0.9 {
+		t.Errorf("similarities = %.3f, %.3f", matches[0].Similarity, matches[1].Similarity)
+	}
+
+	// Resetting the vector space drops entity vectors with the chunk ones.
+	if err := s.ResetChunkVectors(ctx, 4); err != nil {
+		t.Fatalf("ResetChunkVectors: %v", err)
+	}
+	if pending, _ := s.EntitiesWithoutEmbedding(ctx, 0, 10); len(pending) != 2 {
+		t.Errorf("expected both entities to need re-embedding, got %d", len(pending))
+	}
+}
+
+func TestSwapChunkVectors(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	docID, _ := s.UpsertDocument(ctx, sampleDoc("/a.pdf"))
+	ids, err := s.InsertChunks(ctx, []Chunk{{DocumentID: docID, Content: "relief valve", ChunkType: "p"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.InsertEmbedding(ctx, ids[0], []float32{1, 0, 0, 0}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Staged vectors have the new dimension and leave search untouched.
+	if err := s.StageChunkVectors(ctx, 2); err != nil {
+		t.Fatalf("StageChunkVectors: %v", err)
+	}
+	if err := s.InsertStagedEmbedding(ctx, ids[0], []float32{0, 1}); err != nil {
+		t.Fatalf("InsertStagedEmbedding: %v", err)
+	}
+	if got, err := s.VectorSearch(ctx, []float32{1, 0, 0, 0}, 5); err != nil || len(got) != 1 {
+		t.Fatalf("VectorSearch while staging = %+v, %v", got, err)
+	}
+
+	// A discarded staging leaves the old vectors, and a new one starts empty.
+	if err := s.DiscardStagedChunkVectors(ctx); err != nil {
+		t.Fatalf("DiscardStagedChunkVectors: %v", err)
+	}
+	if err := s.StageChunkVectors(ctx, 2); err != nil {
+		t.Fatalf("StageChunkVectors: %v", err)
+	}
+	if err := s.InsertStagedEmbedding(ctx, ids[0], []float32{0, 1}); err != nil {
+		t.Fatalf("InsertStagedEmbedding: %v", err)
+	}
+	if err := s.SwapChunkVectors(ctx, 2); err != nil {
+		t.Fatalf("SwapChunkVectors: %v", err)
+	}
+	got, err := s.VectorSearch(ctx, []float32{0, 1}, 5)
+	if err != nil || len(got) != 1 || got[0].ChunkID != ids[0] {
+		t.Fatalf("VectorSearch after swap = %+v, %v", got, err)
+	}
+	if err := s.InsertEntityEmbedding(ctx, 1, []float32{1, 0}); err != nil {
+		t.Errorf("vec_entities not recreated with the new dimension: %v", err)
+	}
+	if err := s.DiscardStagedChunkVectors(ctx); err != nil {
+		t.Errorf("DiscardStagedChunkVectors after swap: %v", err)
+	}
+}
+
+func TestIngestCheckpoints(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	docID, err := s.UpsertDocument(ctx, sampleDoc("/a.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.GetIngestCheckpoint(ctx, docID); err != sql.ErrNoRows {
+		t.Fatalf("GetIngestCheckpoint before any ingest = %v, want sql.ErrNoRows", err)
+	}
+	cp := IngestCheckpoint{DocumentID: docID, Stage: CheckpointEmbedding, FirstChunkID: 5}
+	if err := s.SetIngestCheckpoint(ctx, cp); err != nil {
+		t.Fatalf("SetIngestCheckpoint: %v", err)
+	}
+	cp.Stage, cp.LastChunkID, cp.GraphReport = CheckpointGraph, 9, `{"chunks":5}`
+	if err := s.SetIngestCheckpoint(ctx, cp); err != nil {
+		t.Fatalf("SetIngestCheckpoint: %v", err)
+	}
+	got, err := s.GetIngestCheckpoint(ctx, docID)
+	if err != nil {
+		t.Fatalf("GetIngestCheckpoint: %v", err)
+	}
+	if got.Stage != CheckpointGraph || got.FirstChunkID != 5 || got.LastChunkID != 9 || got.GraphReport != `{"chunks":5}` {
+		t.Errorf("checkpoint = %+v, want graph stage after chunk 9", got)
+	}
+
+	if err := s.DeleteIngestCheckpoint(ctx, docID); err != nil {
+		t.Fatalf("DeleteIngestCheckpoint: %v", err)
+	}
+	if _, err := s.GetIngestCheckpoint(ctx, docID); err != sql.ErrNoRows {
+		t.Errorf("GetIngestCheckpoint after delete = %v, want sql.ErrNoRows", err)
+	}
+}
+
 func TestUpsertEntityUpdate(t *testing.T) {
 	s := newTestStore(t)
 	ctx := context.Background()
@@ -585,100 +1022,423 @@ func TestGraphSearchEmpty(t *testing.T) {
 	}
 }
 
-// ---------------------------------------------------------------------------
-// LinkEntityChunk
-// ---------------------------------------------------------------------------
-
-func TestLinkEntityChunkIdempotent(t *testing.T) {
+func TestHasGraph(t *testing.T) {
 	s := newTestStore(t)
 	ctx := context.Background()
 
-	docID, _ := s.UpsertDocument(ctx, sampleDoc("/link.pdf"))
+	has, err := s.HasGraph(ctx)
+	if err != nil {
+		t.Fatalf("HasGraph: %v", err)
+	}
+	if has {
+		t.Fatal("expected empty graph on fresh store")
+	}
+
+	docID, _ := s.UpsertDocument(ctx, sampleDoc("/graph.pdf"))
 	chunkIDs, _ := s.InsertChunks(ctx, []Chunk{
 		{DocumentID: docID, Content: "data", ChunkType: "p", PositionInDoc: 0, TokenCount: 1},
 	})
-	entityID, _ := s.UpsertEntity(ctx, Entity{Name: "Test", EntityType: "thing", Description: "d"})
-
-	// First link.
-	if err := s.LinkEntityChunk(ctx, entityID, chunkIDs[0]); err != nil {
-		t.Fatalf("first link: %v", err)
+	if _, err := s.UpsertEntityAndLink(ctx, Entity{Name: "pump", EntityType: "component"}, chunkIDs[0]); err != nil {
+		t.Fatalf("UpsertEntityAndLink: %v", err)
 	}
-	// Second link (INSERT OR IGNORE) should not fail.
-	if err := s.LinkEntityChunk(ctx, entityID, chunkIDs[0]); err != nil {
-		t.Fatalf("duplicate link should not error: %v", err)
+	if has, _ = s.HasGraph(ctx); !has {
+		t.Error("expected HasGraph after linking an entity")
 	}
 }
 
-// ---------------------------------------------------------------------------
-// Community operations
-// ---------------------------------------------------------------------------
-
-func TestInsertAndGetCommunities(t *testing.T) {
+func TestMultiHopGraphSearch(t *testing.T) {
 	s := newTestStore(t)
 	ctx := context.Background()
 
-	c1 := Community{Level: 0, Summary: "Community A", EntityIDs: "[1,2]"}
-	c2 := Community{Level: 0, Summary: "Community B", EntityIDs: "[3,4]"}
-	c3 := Community{Level: 1, Summary: "Super community", EntityIDs: "[1,2,3,4]"}
-
-	id1, err := s.InsertCommunity(ctx, c1)
+	docID, _ := s.UpsertDocument(ctx, sampleDoc("/hops.pdf"))
+	chunkIDs, _ := s.InsertChunks(ctx, []Chunk{
+		{DocumentID: docID, Content: "damper", ChunkType: "p", PositionInDoc: 0, TokenCount: 1},
+		{DocumentID: docID, Content: "standard", ChunkType: "p", PositionInDoc: 1, TokenCount: 1},
+		{DocumentID: docID, Content: "test", ChunkType: "p", PositionInDoc: 2, TokenCount: 1},
+		{DocumentID: docID, Content: "far", ChunkType: "p", PositionInDoc: 3, TokenCount: 1},
+	})
+	damper, _ := s.UpsertEntityAndLink(ctx, Entity{Name: "av-fm", EntityType: "component"}, chunkIDs[0])
+	standard, _ := s.UpsertEntityAndLink(ctx, Entity{Name: "en 1366-2", EntityType: "standard"}, chunkIDs[1])
+	test, _ := s.UpsertEntityAndLink(ctx, Entity{Name: "fire test", EntityType: "procedure"}, chunkIDs[2])
+	far, _ := s.UpsertEntityAndLink(ctx, Entity{Name: "furnace", EntityType: "equipment"}, chunkIDs[3])
+	s.InsertRelationship(ctx, Relationship{SourceEntityID: damper, TargetEntityID: standard, RelationType: "complies_with", Weight: 0.9})
+	s.InsertRelationship(ctx, Relationship{SourceEntityID: test, TargetEntityID: standard, RelationType: "defined_by", Weight: 0.5})
+	s.InsertRelationship(ctx, Relationship{SourceEntityID: test, TargetEntityID: far, RelationType: "uses", Weight: 1})
+
+	paths, err := s.ExpandEntityPaths(ctx, []Entity{{ID: damper, Name: "av-fm"}}, 2, 0)
 	if err != nil {
-		t.Fatalf("insert c1: %v", err)
+		t.Fatalf("ExpandEntityPaths: %v", err)
 	}
-	if id1 == 0 {
-		t.Fatal("expected non-zero community id")
+	if _, ok := paths[far]; ok {
+		t.Error("furnace is 3 hops away and must not be reached at depth 2")
 	}
-	if _, err := s.InsertCommunity(ctx, c2); err != nil {
-		t.Fatalf("insert c2: %v", err)
+	p := paths[test]
+	if p.Hops != 2 || math.Abs(p.Score-0.45) > 1e-9 {
+		t.Errorf("path to fire test = %+v, want 2 hops scoring 0.45", p)
 	}
-	if _, err := s.InsertCommunity(ctx, c3); err != nil {
-		t.Fatalf("insert c3: %v", err)
+	if want := "av-fm -[complies_with]-> en 1366-2 <-[defined_by]- fire test"; p.Path != want {
+		t.Errorf("path = %q, want %q", p.Path, want)
 	}
 
-	// Get level 0.
-	got, err := s.GetCommunities(ctx, 0)
+	results, err := s.MultiHopGraphSearch(ctx, paths, 10)
 	if err != nil {
-		t.Fatalf("get communities level 0: %v", err)
-	}
-	if len(got) != 2 {
-		t.Fatalf("expected 2 level-0 communities, got %d", len(got))
+		t.Fatalf("MultiHopGraphSearch: %v", err)
 	}
-
-	// Get level 1.
-	got1, err := s.GetCommunities(ctx, 1)
-	if err != nil {
-		t.Fatalf("get communities level 1: %v", err)
+	if len(results) != 3 {
+		t.Fatalf("expected 3 chunks, got %d", len(results))
 	}
-	if len(got1) != 1 {
-		t.Fatalf("expected 1 level-1 community, got %d", len(got1))
+	for i, want := range []int64{chunkIDs[0], chunkIDs[1], chunkIDs[2]} {
+		if results[i].ChunkID != want {
+			t.Errorf("results[%d] = chunk %d, want %d", i, results[i].ChunkID, want)
+		}
 	}
-	if got1[0].Summary != "Super community" {
-		t.Errorf("summary: got %q", got1[0].Summary)
+	if results[2].GraphPath != p.Path {
+		t.Errorf("GraphPath = %q", results[2].GraphPath)
 	}
 }
 
-func TestClearCommunities(t *testing.T) {
+func TestStopEntities(t *testing.T) {
 	s := newTestStore(t)
 	ctx := context.Background()
 
-	s.InsertCommunity(ctx, Community{Level: 0, Summary: "x", EntityIDs: "[1]"})
+	// "system" appears in all four documents, "damper" in one.
+	var system, damper, standard int64
+	for i := 0; i < 4; i++ {
+		docID, _ := s.UpsertDocument(ctx, sampleDoc(fmt.Sprintf("/doc%d.pdf", i)))
+		chunkIDs, _ := s.InsertChunks(ctx, []Chunk{
+			{DocumentID: docID, Content: "system", ChunkType: "p", PositionInDoc: 0, TokenCount: 1},
+		})
+		if i > 0 {
+			s.LinkEntityChunk(ctx, system, chunkIDs[0])
+		} else {
+			system, _ = s.UpsertEntityAndLink(ctx, Entity{Name: "system", EntityType: "concept"}, chunkIDs[0])
+			damper, _ = s.UpsertEntityAndLink(ctx, Entity{Name: "AV-FM", EntityType: "component"}, chunkIDs[0])
+			standard, _ = s.UpsertEntityAndLink(ctx, Entity{Name: "en 1366-2", EntityType: "standard"}, chunkIDs[0])
+		}
+	}
+	s.InsertRelationship(ctx, Relationship{SourceEntityID: damper, TargetEntityID: system, RelationType: "part_of", Weight: 1})
+	s.InsertRelationship(ctx, Relationship{SourceEntityID: system, TargetEntityID: standard, RelationType: "complies_with", Weight: 1})
 
-	if err := s.ClearCommunities(ctx); err != nil {
-		t.Fatalf("clear: %v", err)
+	// Below MinDocuments the threshold is not applied.
+	n, err := s.UpdateStopEntities(ctx, StopEntityRule{Threshold: 0.5, MinDocuments: 5})
+	if err != nil || n != 0 {
+		t.Fatalf("UpdateStopEntities(small corpus) = %d, %v; want 0", n, err)
+	}
+	n, err = s.UpdateStopEntities(ctx, StopEntityRule{Threshold: 0.5, MinDocuments: 4})
+	if err != nil || n != 1 {
+		t.Fatalf("UpdateStopEntities = %d, %v; want 1", n, err)
+	}
+	ids, err := s.StopEntityIDs(ctx)
+	if err != nil || len(ids) != 1 || !ids[system] {
+		t.Fatalf("StopEntityIDs = %v, %v; want only system", ids, err)
 	}
 
-	got, err := s.GetCommunities(ctx, 0)
+	stats, err := s.EntityStats(ctx, 10)
 	if err != nil {
-		t.Fatalf("get after clear: %v", err)
+		t.Fatalf("EntityStats: %v", err)
 	}
-	if len(got) != 0 {
-		t.Fatalf("expected 0 communities after clear, got %d", len(got))
+	if len(stats) != 3 || stats[0].ID != system || stats[0].Documents != 4 || !stats[0].Stop {
+		t.Fatalf("EntityStats = %+v, want system first with 4 documents", stats)
+	}
+	if stats[0].Relationships != 2 || stats[1].Stop {
+		t.Errorf("EntityStats = %+v", stats)
 	}
-}
 
-// ---------------------------------------------------------------------------
-// Query log
-// ---------------------------------------------------------------------------
+	// Paths do not pass through a stop entity.
+	paths, err := s.ExpandEntityPaths(ctx, []Entity{{ID: damper, Name: "av-fm"}}, 2, 0)
+	if err != nil {
+		t.Fatalf("ExpandEntityPaths: %v", err)
+	}
+	if _, ok := paths[standard]; ok {
+		t.Error("path reached en 1366-2 through the stop entity")
+	}
+
+	// The override lists win over the threshold, matched case-insensitively.
+	n, err = s.UpdateStopEntities(ctx, StopEntityRule{
+		Threshold: 0.5, MinDocuments: 4, Stop: []string{"av-fm"}, Keep: []string{"System"},
+	})
+	if err != nil || n != 1 {
+		t.Fatalf("UpdateStopEntities(overrides) = %d, %v; want 1", n, err)
+	}
+	if ids, _ := s.StopEntityIDs(ctx); !ids[damper] || ids[system] {
+		t.Errorf("StopEntityIDs = %v, want only av-fm", ids)
+	}
+}
+
+func TestGetEntitiesByChunkIDs(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	docID, _ := s.UpsertDocument(ctx, sampleDoc("/ents.pdf"))
+	chunkIDs, _ := s.InsertChunks(ctx, []Chunk{
+		{DocumentID: docID, Content: "a", ChunkType: "p", PositionInDoc: 0, TokenCount: 1},
+		{DocumentID: docID, Content: "b", ChunkType: "p", PositionInDoc: 1, TokenCount: 1},
+		{DocumentID: docID, Content: "c", ChunkType: "p", PositionInDoc: 2, TokenCount: 1},
+	})
+	pump, _ := s.UpsertEntityAndLink(ctx, Entity{Name: "pump", EntityType: "component"}, chunkIDs[0])
+	s.LinkEntityChunk(ctx, pump, chunkIDs[1])
+	s.UpsertEntityAndLink(ctx, Entity{Name: "valve", EntityType: "component"}, chunkIDs[1])
+	s.UpsertEntityAndLink(ctx, Entity{Name: "motor", EntityType: "component"}, chunkIDs[2])
+
+	got, err := s.GetEntitiesByChunkIDs(ctx, chunkIDs[:2], 10)
+	if err != nil {
+		t.Fatalf("GetEntitiesByChunkIDs: %v", err)
+	}
+	if len(got) != 2 || got[0].Name != "pump" || got[1].Name != "valve" {
+		t.Errorf("expected [pump valve] ordered by link count, got %+v", got)
+	}
+
+	if got, _ := s.GetEntitiesByChunkIDs(ctx, nil, 10); got != nil {
+		t.Errorf("expected nil for no chunk ids, got %v", got)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// LinkEntityChunk
+// ---------------------------------------------------------------------------
+
+func TestLinkEntityChunkIdempotent(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	docID, _ := s.UpsertDocument(ctx, sampleDoc("/link.pdf"))
+	chunkIDs, _ := s.InsertChunks(ctx, []Chunk{
+		{DocumentID: docID, Content: "data", ChunkType: "p", PositionInDoc: 0, TokenCount: 1},
+	})
+	entityID, _ := s.UpsertEntity(ctx, Entity{Name: "Test", EntityType: "thing", Description: "d"})
+
+	// First link.
+	if err := s.LinkEntityChunk(ctx, entityID, chunkIDs[0]); err != nil {
+		t.Fatalf("first link: %v", err)
+	}
+	// Second link (INSERT OR IGNORE) should not fail.
+	if err := s.LinkEntityChunk(ctx, entityID, chunkIDs[0]); err != nil {
+		t.Fatalf("duplicate link should not error: %v", err)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Community operations
+// ---------------------------------------------------------------------------
+
+func TestImportGraph(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	docID, _ := s.UpsertDocument(ctx, sampleDoc("/import.pdf"))
+	ids, _ := s.InsertChunks(ctx, []Chunk{
+		{DocumentID: docID, Content: "pump model P-100", ChunkType: "p", PositionInDoc: 0, TokenCount: 4},
+	})
+	pump, _ := s.UpsertEntityAndLink(ctx, Entity{Name: "p-100", EntityType: "product", Description: "A pump"}, ids[0])
+	s.InsertEntityEmbedding(ctx, pump, []float32{1, 0, 0, 0})
+
+	stats, err := s.ImportGraph(ctx, GraphImport{
+		Source: "ontology.csv",
+		Entities: []Entity{
+			{Name: "p-100", EntityType: "equipment", Description: "Centrifugal pump, 10 bar"},
+			{Name: "centrifugal pumps", EntityType: "category", Description: "Pumps using an impeller"},
+		},
+		Relationships: []ImportedRelationship{
+			{Source: "p-100", Target: "centrifugal pumps", RelationType: "is_a", Weight: 1},
+			{Source: "p-100", Target: "unknown", RelationType: "replaces", Weight: 1},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ImportGraph: %v", err)
+	}
+	if stats.EntitiesCreated != 1 || stats.EntitiesMerged != 1 || stats.RelationshipsCreated != 1 ||
+		len(stats.Unresolved) != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+	// Matched by name despite the type; the stored description is kept.
+	ents, _ := s.GetEntitiesByNames(ctx, []string{"p-100"})
+	if len(ents) != 1 || ents[0].ID != pump || ents[0].Description != "A pump" {
+		t.Errorf("p-100 = %+v", ents)
+	}
+
+	// Merging appends the description and drops the stale vector.
+	stats, err = s.ImportGraph(ctx, GraphImport{
+		Source:     "ontology.csv",
+		OnConflict: ConflictMerge,
+		Entities:   []Entity{{Name: "p-100", EntityType: "product", Description: "Centrifugal pump, 10 bar"}},
+		Relationships: []ImportedRelationship{
+			{Source: "p-100", Target: "centrifugal pumps", RelationType: "is_a", Weight: 2},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ImportGraph(merge): %v", err)
+	}
+	if stats.EntitiesMerged != 1 || stats.RelationshipsMerged != 1 || stats.RelationshipsCreated != 0 {
+		t.Errorf("merge stats = %+v", stats)
+	}
+	ents, _ = s.GetEntitiesByNames(ctx, []string{"p-100"})
+	if ents[0].Description != "A pump\nCentrifugal pump, 10 bar" {
+		t.Errorf("merged description = %q", ents[0].Description)
+	}
+	missing, _ := s.EntitiesWithoutEmbedding(ctx, 0, 10)
+	if len(missing) != 2 {
+		t.Errorf("entities without embedding = %+v, want both", missing)
+	}
+	rels, _ := s.AllRelationships(ctx)
+	if len(rels) != 1 || rels[0].Weight != 2 {
+		t.Errorf("relationships = %+v", rels)
+	}
+
+	// Re-extraction keeps the curated description, and compaction keeps
+	// the imported entity no chunk links to.
+	s.UpsertEntityAndLink(ctx, Entity{Name: "p-100", EntityType: "product", Description: "Pump"}, ids[0])
+	ents, _ = s.GetEntitiesByNames(ctx, []string{"p-100"})
+	if ents[0].Description != "A pump\nCentrifugal pump, 10 bar" {
+		t.Errorf("description after re-extraction = %q", ents[0].Description)
+	}
+	cstats, err := s.Compact(ctx)
+	if err != nil || cstats.Entities != 0 || cstats.Relationships != 0 {
+		t.Errorf("Compact = %+v, %v; want nothing removed", cstats, err)
+	}
+}
+
+func TestContradictions(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	var chunks []int64
+	for i, path := range []string{"/a.pdf", "/b.pdf", "/c.pdf"} {
+		doc := sampleDoc(path)
+		doc.Filename = path[1:]
+		docID, _ := s.UpsertDocument(ctx, doc)
+		ids, _ := s.InsertChunks(ctx, []Chunk{
+			{DocumentID: docID, Content: "tracker settings", ChunkType: "p", PositionInDoc: i, TokenCount: 2},
+		})
+		chunks = append(chunks, ids[0])
+	}
+	entities := map[string]int64{}
+	link := func(name string, chunk int64) int64 {
+		if id, ok := entities[name]; ok {
+			s.LinkEntityChunk(ctx, id, chunk)
+			return id
+		}
+		id, _ := s.UpsertEntityAndLink(ctx, Entity{Name: name, EntityType: "concept"}, chunk)
+		entities[name] = id
+		return id
+	}
+	rel := func(src, tgt int64, relType string, chunk int64) {
+		s.InsertRelationship(ctx, Relationship{SourceEntityID: src, TargetEntityID: tgt, RelationType: relType, Weight: 1, SourceChunkID: &chunk})
+	}
+	tracker := link("tracker", chunks[0])
+	rel(tracker, link("10.0.0.5", chunks[0]), "has_ip_address", chunks[0])
+	rel(tracker, link("10.0.0.9", chunks[1]), "has_ip_address", chunks[1])
+	// Several targets in one document: a multi-valued relation.
+	rel(tracker, link("antenna", chunks[0]), "has_part", chunks[0])
+	rel(tracker, link("gps module", chunks[1]), "has_part", chunks[1])
+	rel(tracker, link("battery", chunks[1]), "has_part", chunks[1])
+	// The same target in another document agrees.
+	rel(tracker, link("10.0.0.5", chunks[2]), "has_ip_address", chunks[2])
+
+	cands, err := s.ContradictionCandidates(ctx)
+	if err != nil {
+		t.Fatalf("ContradictionCandidates: %v", err)
+	}
+	if len(cands) != 2 {
+		t.Fatalf("candidates = %+v, want a-b and b-c", cands)
+	}
+	if c := cands[0]; c.Entity != "tracker" || c.RelationType != "has_ip_address" || c.ChunkA != chunks[0] ||
+		c.TargetA != "10.0.0.5" || c.ChunkB != chunks[1] || c.TargetB != "10.0.0.9" {
+		t.Errorf("candidate = %+v", c)
+	}
+
+	err = s.ReplaceContradictions(ctx, []Contradiction{{
+		EntityID: tracker, Attribute: "ip address", ChunkA: chunks[0], ValueA: "10.0.0.5", ChunkB: chunks[1], ValueB: "10.0.0.9",
+	}})
+	if err != nil {
+		t.Fatalf("ReplaceContradictions: %v", err)
+	}
+	list, err := s.ListContradictions(ctx)
+	if err != nil || len(list) != 1 || list[0].Entity != "tracker" || list[0].FilenameB != "b.pdf" {
+		t.Fatalf("ListContradictions = %+v, %v", list, err)
+	}
+	flagged, err := s.ContradictionsForChunks(ctx, []int64{chunks[1], chunks[2]})
+	if err != nil || len(flagged) != 1 || len(flagged[chunks[1]]) != 1 {
+		t.Fatalf("ContradictionsForChunks = %+v, %v", flagged, err)
+	}
+	if note := flagged[chunks[1]][0].Note(chunks[1]); note != `ip address of tracker is "10.0.0.9" here but "10.0.0.5" in a.pdf` {
+		t.Errorf("note = %q", note)
+	}
+
+	// Contradictions go with their chunks.
+	if err := s.DeleteDocument(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := s.ListContradictions(ctx); len(list) != 0 {
+		t.Errorf("contradictions after delete = %+v", list)
+	}
+}
+
+func TestInsertAndGetCommunities(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	c1 := Community{Level: 0, Summary: "Community A", EntityIDs: "[1,2]"}
+	c2 := Community{Level: 0, Summary: "Community B", EntityIDs: "[3,4]"}
+	c3 := Community{Level: 1, Summary: "Super community", EntityIDs: "[1,2,3,4]"}
+
+	id1, err := s.InsertCommunity(ctx, c1)
+	if err != nil {
+		t.Fatalf("insert c1: %v", err)
+	}
+	if id1 == 0 {
+		t.Fatal("expected non-zero community id")
+	}
+	if _, err := s.InsertCommunity(ctx, c2); err != nil {
+		t.Fatalf("insert c2: %v", err)
+	}
+	if _, err := s.InsertCommunity(ctx, c3); err != nil {
+		t.Fatalf("insert c3: %v", err)
+	}
+
+	// Get level 0.
+	got, err := s.GetCommunities(ctx, 0)
+	if err != nil {
+		t.Fatalf("get communities level 0: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 level-0 communities, got %d", len(got))
+	}
+
+	// Get level 1.
+	got1, err := s.GetCommunities(ctx, 1)
+	if err != nil {
+		t.Fatalf("get communities level 1: %v", err)
+	}
+	if len(got1) != 1 {
+		t.Fatalf("expected 1 level-1 community, got %d", len(got1))
+	}
+	if got1[0].Summary != "Super community" {
+		t.Errorf("summary: got %q", got1[0].Summary)
+	}
+}
+
+func TestClearCommunities(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	s.InsertCommunity(ctx, Community{Level: 0, Summary: "x", EntityIDs: "[1]"})
+
+	if err := s.ClearCommunities(ctx); err != nil {
+		t.Fatalf("clear: %v", err)
+	}
+
+	got, err := s.GetCommunities(ctx, 0)
+	if err != nil {
+		t.Fatalf("get after clear: %v", err)
+	}
+	if len(got) != 0 {
+		t.Fatalf("expected 0 communities after clear, got %d", len(got))
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Query log
+// ---------------------------------------------------------------------------
 
 func TestLogQuery(t *testing.T) {
 	s := newTestStore(t)
@@ -721,6 +1481,114 @@ func TestLogQuery(t *testing.T) {
 	}
 }
 
+// ---------------------------------------------------------------------------
+// SyncChunks (partial re-ingest)
+// ---------------------------------------------------------------------------
+
+func TestStaleQueries(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	docID, err := s.UpsertDocument(ctx, sampleDoc("/stale.pdf"))
+	if err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	ids, err := s.InsertChunks(ctx, []Chunk{
+		{DocumentID: docID, Content: "kept", ChunkType: "paragraph"},
+		{DocumentID: docID, Content: "replaced", ChunkType: "paragraph"},
+	})
+	if err != nil {
+		t.Fatalf("insert chunks: %v", err)
+	}
+	queryID, err := s.InsertQueryLog(ctx, QueryLog{Query: "q", Sources: []map[string]int64{
+		{"chunk_id": ids[0], "document_id": docID},
+		{"chunk_id": ids[1], "document_id": docID},
+	}})
+	if err != nil {
+		t.Fatalf("InsertQueryLog: %v", err)
+	}
+	if stale, err := s.StaleQueries(ctx, docID); err != nil || len(stale) != 0 {
+		t.Fatalf("StaleQueries before the change = %+v, %v", stale, err)
+	}
+
+	// The replacement reuses the deleted chunk's ID; the answer is still
+	// stale.
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM chunks WHERE id = ?", ids[1]); err != nil {
+		t.Fatalf("delete chunk: %v", err)
+	}
+	if _, err := s.InsertChunks(ctx, []Chunk{{DocumentID: docID, Content: "replacement", ChunkType: "paragraph"}}); err != nil {
+		t.Fatalf("insert replacement: %v", err)
+	}
+	stale, err := s.StaleQueries(ctx, docID)
+	if err != nil {
+		t.Fatalf("StaleQueries: %v", err)
+	}
+	if len(stale) != 1 || stale[0].QueryID != queryID || len(stale[0].MissingChunks) != 1 || stale[0].MissingChunks[0] != ids[1] {
+		t.Errorf("StaleQueries = %+v, want query %d missing chunk %d", stale, queryID, ids[1])
+	}
+}
+
+func TestSyncChunks(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	docID, _ := s.UpsertDocument(ctx, sampleDoc("/sync.pdf"))
+	ids, err := s.InsertChunks(ctx, []Chunk{
+		{DocumentID: docID, Content: "Pumps need priming", ChunkType: "p", PositionInDoc: 0},
+		{DocumentID: docID, Content: "Valves open at ten bar", ChunkType: "p", PositionInDoc: 1},
+		{DocumentID: docID, Content: "Filters are replaced yearly", ChunkType: "p", PositionInDoc: 2},
+	})
+	if err != nil {
+		t.Fatalf("InsertChunks: %v", err)
+	}
+	_ = s.InsertEmbedding(ctx, ids[0], []float32{1, 0, 0, 0})
+	_ = s.InsertEmbedding(ctx, ids[1], []float32{0, 1, 0, 0})
+	eID, _ := s.UpsertEntity(ctx, Entity{Name: "valve", EntityType: "component"})
+	_ = s.LinkEntityChunk(ctx, eID, ids[1])
+
+	// The middle chunk changes and a heading is added before the first.
+	sync, err := s.SyncChunks(ctx, docID, []Chunk{
+		{DocumentID: docID, Content: "Maintenance", ChunkType: "heading", PositionInDoc: 0},
+		{DocumentID: docID, Content: "Pumps need priming", ChunkType: "p", PositionInDoc: 1},
+		{DocumentID: docID, Content: "Valves open at twelve bar", ChunkType: "p", PositionInDoc: 2},
+		{DocumentID: docID, Content: "Filters are replaced yearly", ChunkType: "p", PositionInDoc: 3},
+	})
+	if err != nil {
+		t.Fatalf("SyncChunks: %v", err)
+	}
+	if sync.IDs[1] != ids[0] || sync.IDs[3] != ids[2] {
+		t.Errorf("unchanged chunks got new IDs: before %v, after %v", ids, sync.IDs)
+	}
+	if fmt.Sprint(sync.Added) != "[0 2]" || sync.Kept != 2 || sync.Removed != 1 {
+		t.Errorf("sync = %+v", sync)
+	}
+
+	chunks, _ := s.GetChunksByDocument(ctx, docID)
+	if len(chunks) != 4 || chunks[1].ID != ids[0] || chunks[1].PositionInDoc != 1 {
+		t.Fatalf("chunks = %+v", chunks)
+	}
+
+	// The kept chunk's vector survives; the replaced chunk's is gone.
+	results, _ := s.VectorSearch(ctx, []float32{1, 1, 0, 0}, 10)
+	if len(results) != 1 || results[0].ChunkID != ids[0] {
+		t.Errorf("vector results = %+v, want only chunk %d", results, ids[0])
+	}
+	if res, _ := s.FTSSearch(ctx, "ten", 10); len(res) != 0 {
+		t.Errorf("FTS still finds the replaced chunk: %+v", res)
+	}
+	if res, _ := s.FTSSearch(ctx, "twelve", 10); len(res) != 1 {
+		t.Errorf("FTS does not find the new chunk: %+v", res)
+	}
+	if res, _ := s.FTSSearch(ctx, "priming", 10); len(res) != 1 || res[0].ChunkID != ids[0] {
+		t.Errorf("FTS for the kept chunk = %+v", res)
+	}
+	var links int
+	s.DB().QueryRow("SELECT COUNT(*) FROM entity_chunks WHERE chunk_id = ?", ids[1]).Scan(&links)
+	if links != 0 {
+		t.Errorf("replaced chunk still has %d entity links", links)
+	}
+}
+
 // ---------------------------------------------------------------------------
 // DeleteDocumentData (keeps document, removes chunks)
 // ---------------------------------------------------------------------------
@@ -1130,3 +1998,1140 @@ func TestAllEntitiesAndRelationships(t *testing.T) {
 		t.Errorf("relation type: got %q", rels[0].RelationType)
 	}
 }
+
+// ---------------------------------------------------------------------------
+// Sparse embeddings
+// ---------------------------------------------------------------------------
+
+func TestInsertSparseEmbeddingAndSparseSearch(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	docID, err := s.UpsertDocument(ctx, sampleDoc("/sparse.pdf"))
+	if err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+
+	ids, err := s.InsertChunks(ctx, []Chunk{
+		{DocumentID: docID, Content: "part E1375 wiring", ChunkType: "paragraph", PositionInDoc: 0},
+		{DocumentID: docID, Content: "general overview", ChunkType: "paragraph", PositionInDoc: 1},
+	})
+	if err != nil {
+		t.Fatalf("insert chunks: %v", err)
+	}
+
+	if err := s.InsertSparseEmbedding(ctx, ids[0], []int{10, 20}, []float32{2.0, 0.5}); err != nil {
+		t.Fatalf("sparse 0: %v", err)
+	}
+	if err := s.InsertSparseEmbedding(ctx, ids[1], []int{20, 30}, []float32{0.4, 1.0}); err != nil {
+		t.Fatalf("sparse 1: %v", err)
+	}
+
+	results, err := s.SparseSearch(ctx, []int{10, 20}, []float32{1.0, 1.0}, 10)
+	if err != nil {
+		t.Fatalf("sparse search: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if results[0].ChunkID != ids[0] {
+		t.Errorf("expected chunk %d first, got %d", ids[0], results[0].ChunkID)
+	}
+	if results[0].Score != 2.5 {
+		t.Errorf("score: got %f, want 2.5", results[0].Score)
+	}
+	if results[0].Filename != "test.pdf" {
+		t.Errorf("filename: got %q, want %q", results[0].Filename, "test.pdf")
+	}
+
+	// Re-inserting replaces the previous terms.
+	if err := s.InsertSparseEmbedding(ctx, ids[0], []int{99}, []float32{1.0}); err != nil {
+		t.Fatalf("sparse replace: %v", err)
+	}
+	results, err = s.SparseSearch(ctx, []int{10}, []float32{1.0}, 10)
+	if err != nil {
+		t.Fatalf("sparse search after replace: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("expected no results for replaced term, got %d", len(results))
+	}
+
+	// DeleteDocumentData removes sparse rows.
+	if err := s.DeleteDocumentData(ctx, docID); err != nil {
+		t.Fatalf("delete data: %v", err)
+	}
+	var count int
+	if err := s.DB().QueryRow("SELECT COUNT(*) FROM sparse_chunks").Scan(&count); err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("expected sparse_chunks to be empty, got %d rows", count)
+	}
+}
+
+func TestInsertSparseEmbeddingLengthMismatch(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.InsertSparseEmbedding(context.Background(), 1, []int{1, 2}, []float32{1}); err == nil {
+		t.Fatal("expected error for mismatched indices/weights")
+	}
+}
+
+func TestUpdateDocumentSummary(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	id, err := s.UpsertDocument(ctx, sampleDoc("/summary.pdf"))
+	if err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	if _, err := s.UpsertDocument(ctx, sampleDoc("/nosummary.pdf")); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+
+	if err := s.UpdateDocumentSummary(ctx, id, "A manual.", `["manual"]`); err != nil {
+		t.Fatalf("update summary: %v", err)
+	}
+
+	doc, err := s.GetDocument(ctx, id)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if doc.Summary != "A manual." || doc.Keywords != `["manual"]` {
+		t.Errorf("got summary=%q keywords=%q", doc.Summary, doc.Keywords)
+	}
+
+	summaries, err := s.DocumentSummaries(ctx)
+	if err != nil {
+		t.Fatalf("document summaries: %v", err)
+	}
+	if len(summaries) != 1 || summaries[0].DocumentID != id {
+		t.Errorf("expected only document %d, got %+v", id, summaries)
+	}
+
+	// The list is cached: a write behind the store's back is not seen
+	// until the cache expires, one through it is seen at once.
+	if _, err := s.db.ExecContext(ctx, "UPDATE documents SET summary = 'Changed.' WHERE id = ?", id); err != nil {
+		t.Fatal(err)
+	}
+	if summaries, _ := s.DocumentSummaries(ctx); len(summaries) != 1 || summaries[0].Summary != "A manual." {
+		t.Errorf("summaries after an outside write = %+v, want the cached one", summaries)
+	}
+	if err := s.UpdateDocumentSummary(ctx, id, "A pump manual.", `["pump"]`); err != nil {
+		t.Fatal(err)
+	}
+	if summaries, _ := s.DocumentSummaries(ctx); len(summaries) != 1 || summaries[0].Summary != "A pump manual." {
+		t.Errorf("summaries after UpdateDocumentSummary = %+v", summaries)
+	}
+}
+
+func TestChunkOffsets(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	docID, err := s.UpsertDocument(ctx, sampleDoc("/spans.txt"))
+	if err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	if _, err := s.InsertChunks(ctx, []Chunk{
+		{ID: 0, DocumentID: docID, Content: "located", ChunkType: "paragraph", StartOffset: 10, EndOffset: 17},
+		{ID: 1, DocumentID: docID, Content: "unknown", ChunkType: "paragraph"},
+	}); err != nil {
+		t.Fatalf("insert chunks: %v", err)
+	}
+
+	chunks, err := s.GetChunksByDocument(ctx, docID)
+	if err != nil {
+		t.Fatalf("get chunks: %v", err)
+	}
+	if chunks[0].StartOffset != 10 || chunks[0].EndOffset != 17 {
+		t.Errorf("chunk offsets = [%d,%d)", chunks[0].StartOffset, chunks[0].EndOffset)
+	}
+	if chunks[1].StartOffset != 0 || chunks[1].EndOffset != 0 {
+		t.Errorf("unknown chunk offsets = [%d,%d)", chunks[1].StartOffset, chunks[1].EndOffset)
+	}
+}
+
+func TestImageVectorSearch(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	// Disabled by default: search is a no-op.
+	if res, err := s.ImageVectorSearch(ctx, []float32{1, 0, 0}, 5); err != nil || res != nil {
+		t.Fatalf("expected no-op search before enabling, got %v, %v", res, err)
+	}
+	if err := s.EnableImageVectors(ctx, 3); err != nil {
+		t.Fatalf("enable image vectors: %v", err)
+	}
+
+	docID, _ := s.UpsertDocument(ctx, sampleDoc("/images.pdf"))
+	ids, _ := s.InsertChunks(ctx, []Chunk{
+		{DocumentID: docID, Content: "wiring", ChunkType: "p", PositionInDoc: 0, TokenCount: 1},
+		{DocumentID: docID, Content: "photo", ChunkType: "p", PositionInDoc: 1, TokenCount: 1},
+	})
+	if err := s.InsertChunkImages(ctx, []ChunkImage{
+		{ChunkID: ids[0], DocumentID: docID, MIMEType: "image/png", Data: []byte{1}},
+		{ChunkID: ids[0], DocumentID: docID, MIMEType: "image/png", Data: []byte{2}},
+		{ChunkID: ids[1], DocumentID: docID, MIMEType: "image/png", Data: []byte{3}},
+	}); err != nil {
+		t.Fatalf("insert images: %v", err)
+	}
+
+	pending, err := s.ImagesWithoutEmbedding(ctx, docID)
+	if err != nil || len(pending) != 3 {
+		t.Fatalf("expected 3 pending images, got %d (%v)", len(pending), err)
+	}
+	vecs := [][]float32{{1, 0, 0}, {0.9, 0.1, 0}, {0, 0, 1}}
+	for i, img := range pending {
+		if err := s.InsertImageEmbedding(ctx, img.ID, vecs[i]); err != nil {
+			t.Fatalf("insert image embedding: %v", err)
+		}
+	}
+	if pending, _ = s.ImagesWithoutEmbedding(ctx, docID); len(pending) != 0 {
+		t.Errorf("expected no pending images, got %d", len(pending))
+	}
+
+	results, err := s.ImageVectorSearch(ctx, []float32{1, 0, 0}, 3)
+	if err != nil {
+		t.Fatalf("image vector search: %v", err)
+	}
+	// Two images on the first chunk collapse to one result.
+	if len(results) != 2 || results[0].ChunkID != ids[0] {
+		t.Fatalf("expected chunk %d first of 2 results, got %+v", ids[0], results)
+	}
+
+	if err := s.DeleteDocument(ctx, docID); err != nil {
+		t.Fatalf("delete document: %v", err)
+	}
+	var n int
+	s.DB().QueryRow("SELECT COUNT(*) FROM vec_images").Scan(&n)
+	if n != 0 {
+		t.Errorf("expected image vectors deleted with document, %d remain", n)
+	}
+}
+
+func TestSecondaryVectorSearch(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	// Disabled by default: search is a no-op.
+	if res, err := s.SecondaryVectorSearch(ctx, []float32{1, 0}, 5); err != nil || res != nil {
+		t.Fatalf("expected no-op search before enabling, got %v, %v", res, err)
+	}
+	// The secondary space may differ in dimension from the primary one.
+	if err := s.EnableSecondaryVectors(ctx, 2); err != nil {
+		t.Fatalf("enable secondary vectors: %v", err)
+	}
+
+	docID, _ := s.UpsertDocument(ctx, sampleDoc("/manual_es.pdf"))
+	ids, _ := s.InsertChunks(ctx, []Chunk{
+		{DocumentID: docID, Content: "presión máxima", ChunkType: "p", PositionInDoc: 0, TokenCount: 2},
+		{DocumentID: docID, Content: "temperatura", ChunkType: "p", PositionInDoc: 1, TokenCount: 1},
+		{DocumentID: docID, Content: "garantía", ChunkType: "p", PositionInDoc: 2, TokenCount: 1},
+	})
+
+	if err := s.InsertSecondaryEmbedding(ctx, ids[0], []float32{1, 0}); err != nil {
+		t.Fatalf("insert secondary embedding: %v", err)
+	}
+	if err := s.InsertSecondaryEmbedding(ctx, ids[1], []float32{0, 1}); err != nil {
+		t.Fatalf("insert secondary embedding: %v", err)
+	}
+
+	pending, err := s.ChunksWithoutSecondaryEmbedding(ctx, 0, 10)
+	if err != nil || len(pending) != 1 || pending[0].ID != ids[2] {
+		t.Fatalf("expected chunk %d pending, got %+v (%v)", ids[2], pending, err)
+	}
+	if pending, _ = s.ChunksWithoutSecondaryEmbedding(ctx, ids[2], 10); len(pending) != 0 {
+		t.Errorf("expected nothing after chunk %d, got %d", ids[2], len(pending))
+	}
+
+	results, err := s.SecondaryVectorSearch(ctx, []float32{0.9, 0.1}, 2)
+	if err != nil {
+		t.Fatalf("secondary vector search: %v", err)
+	}
+	if len(results) != 2 || results[0].ChunkID != ids[0] || results[0].Filename == "" {
+		t.Fatalf("expected chunk %d first of 2 results, got %+v", ids[0], results)
+	}
+
+	if err := s.DeleteDocument(ctx, docID); err != nil {
+		t.Fatalf("delete document: %v", err)
+	}
+	var n int
+	s.DB().QueryRow("SELECT COUNT(*) FROM vec_chunks_secondary").Scan(&n)
+	if n != 0 {
+		t.Errorf("expected secondary vectors deleted with document, %d remain", n)
+	}
+}
+
+func TestGlossary(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	docID, _ := s.UpsertDocument(ctx, sampleDoc("/manual.pdf"))
+	ids, _ := s.InsertChunks(ctx, []Chunk{
+		{DocumentID: docID, Content: "Total Harmonic Distortion (THD)", ChunkType: "p", TokenCount: 3},
+	})
+	entries := []GlossaryEntry{
+		{DocumentID: docID, ChunkID: ids[0], Abbreviation: "THD", Definition: "Total Harmonic Distortion"},
+		{DocumentID: docID, Abbreviation: "EPP", Definition: "equipo de protección personal"},
+		{DocumentID: docID, ChunkID: ids[0], Abbreviation: "THD", Definition: "Total Harmonic Distortion"},
+	}
+	if err := s.InsertGlossary(ctx, entries); err != nil {
+		t.Fatalf("InsertGlossary: %v", err)
+	}
+
+	all, err := s.ListGlossary(ctx, 0)
+	if err != nil {
+		t.Fatalf("ListGlossary: %v", err)
+	}
+	// Duplicates are ignored; entries are sorted by abbreviation.
+	if len(all) != 2 || all[0].Abbreviation != "EPP" || all[1].ChunkID != ids[0] || all[1].Filename == "" {
+		t.Fatalf("ListGlossary = %+v", all)
+	}
+
+	got, err := s.GlossaryByAbbreviations(ctx, []string{"THD", "XYZ"})
+	if err != nil || len(got) != 1 || got[0].Definition != "Total Harmonic Distortion" {
+		t.Fatalf("GlossaryByAbbreviations = %+v, %v", got, err)
+	}
+
+	if err := s.DeleteDocumentData(ctx, docID); err != nil {
+		t.Fatalf("DeleteDocumentData: %v", err)
+	}
+	if all, _ = s.ListGlossary(ctx, docID); len(all) != 0 {
+		t.Errorf("expected glossary cleared with document data, got %d entries", len(all))
+	}
+}
+
+func TestNumericSearch(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	docID, _ := s.UpsertDocument(ctx, sampleDoc("/valves.pdf"))
+	ids, _ := s.InsertChunks(ctx, []Chunk{
+		{DocumentID: docID, Content: "Set to 75 PSIG at 20 °C", ChunkType: "p", TokenCount: 6, PositionInDoc: 0},
+		{DocumentID: docID, Content: "Set to 150 PSIG", ChunkType: "p", TokenCount: 3, PositionInDoc: 1},
+		{DocumentID: docID, Content: "Range 70–80 PSIG", ChunkType: "p", TokenCount: 3, PositionInDoc: 2},
+	})
+	qs := []ChunkQuantity{
+		{ChunkID: ids[0], DocumentID: docID, Dimension: "pressure", Value: 517.107, Unit: "kPa", Raw: "75 PSIG"},
+		{ChunkID: ids[0], DocumentID: docID, Dimension: "temperature", Value: 20, Unit: "°C", Raw: "20 °C"},
+		{ChunkID: ids[1], DocumentID: docID, Dimension: "pressure", Value: 1034.214, Unit: "kPa", Raw: "150 PSIG"},
+		{ChunkID: ids[2], DocumentID: docID, Dimension: "pressure", Value: 482.633, Unit: "kPa", Raw: "70–80 PSIG"},
+		{ChunkID: ids[2], DocumentID: docID, Dimension: "pressure", Value: 551.581, Unit: "kPa", Raw: "70–80 PSIG"},
+	}
+	if err := s.InsertChunkQuantities(ctx, qs); err != nil {
+		t.Fatalf("InsertChunkQuantities: %v", err)
+	}
+
+	pressure := NumericRange{Dimension: "pressure", Unit: "kPa", Min: 480, Max: 620}
+	got, err := s.NumericSearch(ctx, []NumericRange{pressure}, 10)
+	if err != nil {
+		t.Fatalf("NumericSearch: %v", err)
+	}
+	// The chunk with two values in range ranks first.
+	if len(got) != 2 || got[0].ChunkID != ids[2] || got[0].Score != 2 || got[1].ChunkID != ids[0] {
+		t.Fatalf("NumericSearch = %+v", got)
+	}
+
+	// Every range must match; an open side is unbounded.
+	warm := NumericRange{Dimension: "temperature", Unit: "°C", Min: 15, Max: math.Inf(1)}
+	in, err := s.ChunksInRanges(ctx, ids, []NumericRange{pressure, warm})
+	if err != nil {
+		t.Fatalf("ChunksInRanges: %v", err)
+	}
+	if len(in) != 1 || !in[ids[0]] {
+		t.Errorf("ChunksInRanges = %v, want only chunk %d", in, ids[0])
+	}
+
+	// An exact value allows for conversion rounding.
+	exact := NumericRange{Dimension: "pressure", Unit: "kPa", Min: 1034.2140001, Max: 1034.2140001}
+	if got, _ := s.NumericSearch(ctx, []NumericRange{exact}, 10); len(got) != 1 || got[0].ChunkID != ids[1] {
+		t.Errorf("exact NumericSearch = %+v", got)
+	}
+
+	if err := s.DeleteDocumentData(ctx, docID); err != nil {
+		t.Fatalf("DeleteDocumentData: %v", err)
+	}
+	if left, _ := s.ChunkQuantities(ctx, docID); len(left) != 0 {
+		t.Errorf("expected quantities cleared with document data, got %d", len(left))
+	}
+}
+
+func TestBackfillChunkQuantities(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	c, err := NewContentCipher(map[string][]byte{"": bytes.Repeat([]byte{2}, 32)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetContentCipher(c)
+
+	docID, _ := s.UpsertDocument(ctx, sampleDoc("/valves.pdf"))
+	ids, err := s.InsertChunks(ctx, []Chunk{
+		{DocumentID: docID, Content: "Set to 75 PSIG", ChunkType: "p"},
+		{DocumentID: docID, Content: "No values here", ChunkType: "p"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// As migration 36 marks the chunks of an older database.
+	if _, err := s.db.ExecContext(ctx, "UPDATE chunks SET quantities_pending = 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	var seen []string
+	extract := func(c Chunk) []ChunkQuantity {
+		seen = append(seen, c.Content)
+		if c.ID != ids[0] {
+			return nil
+		}
+		return []ChunkQuantity{{ChunkID: c.ID, DocumentID: c.DocumentID, Dimension: "pressure", Value: 517.107, Unit: "kPa", Raw: "75 PSIG"}}
+	}
+	if n, err := s.BackfillChunkQuantities(ctx, extract); err != nil || n != 2 {
+		t.Fatalf("BackfillChunkQuantities = %d, %v, want 2", n, err)
+	}
+	if len(seen) != 2 || seen[0] != "Set to 75 PSIG" {
+		t.Errorf("extract saw %q, want the decrypted chunks", seen)
+	}
+	if n, err := s.BackfillChunkQuantities(ctx, extract); err != nil || n != 0 {
+		t.Errorf("second BackfillChunkQuantities = %d, %v, want nothing left", n, err)
+	}
+
+	var raw string
+	if err := s.db.QueryRowContext(ctx, "SELECT raw FROM chunk_quantities WHERE chunk_id = ?", ids[0]).Scan(&raw); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(raw, "enc1:") {
+		t.Errorf("stored raw = %q, want it encrypted", raw)
+	}
+	qs, err := s.ChunkQuantities(ctx, docID)
+	if err != nil || len(qs) != 1 || qs[0].Raw != "75 PSIG" {
+		t.Errorf("ChunkQuantities = %+v, %v, want the decrypted value", qs, err)
+	}
+	pressure := NumericRange{Dimension: "pressure", Unit: "kPa", Min: 480, Max: 620}
+	if got, _ := s.NumericSearch(ctx, []NumericRange{pressure}, 10); len(got) != 1 || got[0].ChunkID != ids[0] {
+		t.Errorf("NumericSearch after backfill = %+v", got)
+	}
+}
+
+func TestQueryTranslations(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	tr := QueryTranslation{QuestionHash: "abc", Language: "Spanish", Terms: []string{"bomba"}, PromptTokens: 50}
+	if err := s.PutQueryTranslation(ctx, tr); err != nil {
+		t.Fatalf("PutQueryTranslation: %v", err)
+	}
+	tr.Terms = []string{"bomba", "bombas"}
+	if err := s.PutQueryTranslation(ctx, tr); err != nil {
+		t.Fatalf("PutQueryTranslation (replace): %v", err)
+	}
+
+	got, err := s.GetQueryTranslations(ctx, "abc", []string{"Spanish", "German"})
+	if err != nil {
+		t.Fatalf("GetQueryTranslations: %v", err)
+	}
+	if len(got) != 1 || len(got["Spanish"].Terms) != 2 || got["Spanish"].PromptTokens != 50 {
+		t.Errorf("translations = %+v, want the replaced Spanish entry only", got)
+	}
+}
+
+func TestChunkAnnotations(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	docID, _ := s.UpsertDocument(ctx, sampleDoc("/manual.pdf"))
+	ids, _ := s.InsertChunks(ctx, []Chunk{
+		{DocumentID: docID, Content: "Torque the flange bolts to 40 Nm.", ChunkType: "p"},
+		{DocumentID: docID, Content: "Superseded procedure.", ChunkType: "p", PositionInDoc: 1},
+	})
+
+	first, err := s.UpsertChunkAnnotation(ctx, ChunkAnnotation{ChunkID: ids[0], Kind: AnnotationCorrection, Text: "45 Nm since rev. C", Author: "ana"})
+	if err != nil {
+		t.Fatalf("UpsertChunkAnnotation: %v", err)
+	}
+	// A second correction replaces the first instead of adding a row.
+	again, err := s.UpsertChunkAnnotation(ctx, ChunkAnnotation{ChunkID: ids[0], Kind: AnnotationCorrection, Text: "45 Nm since rev. D"})
+	if err != nil || again != first {
+		t.Fatalf("re-annotating: id %d (want %d), %v", again, first, err)
+	}
+	if _, err := s.UpsertChunkAnnotation(ctx, ChunkAnnotation{ChunkID: ids[1], Kind: AnnotationExclude}); err != nil {
+		t.Fatalf("UpsertChunkAnnotation: %v", err)
+	}
+
+	all, err := s.ListChunkAnnotations(ctx, 0)
+	if err != nil || len(all) != 2 {
+		t.Fatalf("ListChunkAnnotations = %+v, %v", all, err)
+	}
+	byChunk, err := s.AnnotationsForChunks(ctx, ids)
+	if err != nil {
+		t.Fatalf("AnnotationsForChunks: %v", err)
+	}
+	if got := byChunk[ids[0]]; len(got) != 1 || got[0].Text != "45 Nm since rev. D" || got[0].Author != "" || got[0].CreatedAt.IsZero() {
+		t.Errorf("chunk %d annotations = %+v", ids[0], got)
+	}
+
+	if err := s.DeleteChunkAnnotation(ctx, first); err != nil {
+		t.Fatalf("DeleteChunkAnnotation: %v", err)
+	}
+	if err := s.DeleteChunkAnnotation(ctx, first); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("deleting twice = %v, want sql.ErrNoRows", err)
+	}
+
+	if err := s.DeleteDocumentData(ctx, docID); err != nil {
+		t.Fatalf("DeleteDocumentData: %v", err)
+	}
+	if all, _ = s.ListChunkAnnotations(ctx, 0); len(all) != 0 {
+		t.Errorf("expected annotations cleared with document data, got %d", len(all))
+	}
+}
+
+func TestDocumentStatsAndQuality(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	docID, _ := s.UpsertDocument(ctx, sampleDoc("/manual.pdf"))
+	ids, _ := s.InsertChunks(ctx, []Chunk{
+		{DocumentID: docID, Content: "first", ChunkType: "p", TokenCount: 12, PositionInDoc: 0},
+		{DocumentID: docID, Content: "second", ChunkType: "p", TokenCount: 30, PositionInDoc: 1},
+	})
+	if err := s.InsertEmbedding(ctx, ids[0], []float32{1, 0, 0, 0}); err != nil {
+		t.Fatalf("InsertEmbedding: %v", err)
+	}
+	for _, name := range []string{"pump", "valve"} {
+		if _, err := s.UpsertEntityAndLink(ctx, Entity{Name: name, EntityType: "concept"}, ids[0]); err != nil {
+			t.Fatalf("UpsertEntityAndLink: %v", err)
+		}
+	}
+
+	st, err := s.DocumentStats(ctx, docID)
+	if err != nil {
+		t.Fatalf("DocumentStats: %v", err)
+	}
+	if st.Chunks != 2 || len(st.TokenCounts) != 2 || st.TokenCounts[1] != 30 {
+		t.Errorf("chunks = %d, token counts = %v", st.Chunks, st.TokenCounts)
+	}
+	if st.ChunksWithoutEmbedding != 1 || st.Entities != 2 || st.Images != 0 {
+		t.Errorf("stats = %+v", st)
+	}
+
+	if err := s.UpdateDocumentQuality(ctx, docID, `{"chunks":2}`); err != nil {
+		t.Fatalf("UpdateDocumentQuality: %v", err)
+	}
+	doc, err := s.GetDocument(ctx, docID)
+	if err != nil || doc.Quality != `{"chunks":2}` {
+		t.Errorf("quality = %q, %v", doc.Quality, err)
+	}
+}
+
+func TestExperimentStats(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	var ids []int64
+	for _, q := range []QueryLog{
+		{Query: "a", Confidence: 0.8, TotalTokens: 100, ElapsedMs: 200, Experiment: "rrf", Arm: "control"},
+		{Query: "b", Confidence: 0.6, TotalTokens: 300, ElapsedMs: 400, Experiment: "rrf", Arm: "control"},
+		{Query: "c", Confidence: 0.9, Experiment: "rrf", Arm: "fts_heavy"},
+		{Query: "d", Confidence: 0.1},
+	} {
+		id, err := s.InsertQueryLog(ctx, q)
+		if err != nil {
+			t.Fatalf("InsertQueryLog: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := s.SetQueryFeedback(ctx, ids[0], 1, "good"); err != nil {
+		t.Fatalf("SetQueryFeedback: %v", err)
+	}
+	if err := s.SetQueryFeedback(ctx, ids[1], -1, ""); err != nil {
+		t.Fatalf("SetQueryFeedback: %v", err)
+	}
+	if err := s.SetQueryFeedback(ctx, 999, 1, ""); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("feedback on a missing query = %v, want sql.ErrNoRows", err)
+	}
+
+	stats, err := s.ExperimentStats(ctx, "rrf")
+	if err != nil {
+		t.Fatalf("ExperimentStats: %v", err)
+	}
+	if len(stats) != 2 || stats[0].Arm != "control" || stats[1].Arm != "fts_heavy" {
+		t.Fatalf("stats = %+v", stats)
+	}
+	c := stats[0]
+	if c.Queries != 2 || math.Abs(c.AvgConfidence-0.7) > 1e-9 || c.AvgElapsedMs != 300 || c.AvgTokens != 200 {
+		t.Errorf("control = %+v", c)
+	}
+	if c.Feedback != 2 || c.AvgRating != 0 || c.PositiveRate != 0.5 || c.FeedbackRate != 1 {
+		t.Errorf("control feedback = %+v", c)
+	}
+	if stats[1].Queries != 1 || stats[1].Feedback != 0 {
+		t.Errorf("fts_heavy = %+v", stats[1])
+	}
+}
+
+func TestAuditEntries(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	for _, a := range []AuditEntry{
+		{Operation: "ingest", Actor: "alice", Params: `{"path":"/a.pdf"}`, Outcome: "ok", DocumentID: 1, DurationMs: 1200},
+		{Operation: "delete", Actor: "bob", Outcome: "error", Error: "boom", DocumentID: 1},
+		{Operation: "reembed", Outcome: "ok"},
+	} {
+		if err := s.InsertAuditEntry(ctx, a); err != nil {
+			t.Fatalf("InsertAuditEntry: %v", err)
+		}
+	}
+
+	all, err := s.ListAuditEntries(ctx, AuditFilter{})
+	if err != nil {
+		t.Fatalf("ListAuditEntries: %v", err)
+	}
+	if len(all) != 3 || all[0].Operation != "reembed" || all[0].DocumentID != 0 {
+		t.Fatalf("expected newest first, got %+v", all)
+	}
+
+	doc, err := s.ListAuditEntries(ctx, AuditFilter{DocumentID: 1, Limit: 1})
+	if err != nil {
+		t.Fatalf("ListAuditEntries by document: %v", err)
+	}
+	if len(doc) != 1 || doc[0].Operation != "delete" || doc[0].Error != "boom" {
+		t.Errorf("document filter returned %+v", doc)
+	}
+
+	future, err := s.ListAuditEntries(ctx, AuditFilter{Since: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("ListAuditEntries since: %v", err)
+	}
+	if len(future) != 0 {
+		t.Errorf("since filter returned %d entries", len(future))
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Prepared statement cache
+// ---------------------------------------------------------------------------
+
+// seedSearchCorpus inserts n chunks with embeddings for the search tests
+// and benchmarks.
+func seedSearchCorpus(t testing.TB, s *Store, n int) {
+	t.Helper()
+	ctx := context.Background()
+	docID, err := s.UpsertDocument(ctx, sampleDoc("/tmp/corpus.pdf"))
+	if err != nil {
+		t.Fatalf("UpsertDocument: %v", err)
+	}
+	chunks := make([]Chunk, n)
+	for i := range chunks {
+		chunks[i] = Chunk{DocumentID: docID, Content: fmt.Sprintf("damper installation step %d torque", i),
+			ChunkType: "paragraph", PositionInDoc: i, ContentHash: fmt.Sprintf("h%d", i)}
+	}
+	ids, err := s.InsertChunks(ctx, chunks)
+	if err != nil {
+		t.Fatalf("InsertChunks: %v", err)
+	}
+	for i, id := range ids {
+		f := float32(i)
+		if err := s.InsertEmbedding(ctx, id, []float32{1, f, f * f, 1 / (f + 1)}); err != nil {
+			t.Fatalf("InsertEmbedding: %v", err)
+		}
+	}
+}
+
+func TestStatementCache(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	seedSearchCorpus(t, s, 10)
+
+	for i := 0; i < 3; i++ {
+		res, err := s.FTSSearch(ctx, "torque", 5)
+		if err != nil || len(res) != 5 {
+			t.Fatalf("FTSSearch: %d results, %v", len(res), err)
+		}
+		if res, err := s.VectorSearch(ctx, []float32{1, 2, 4, 0.3}, 3); err != nil || len(res) != 3 {
+			t.Fatalf("VectorSearch: %d results, %v", len(res), err)
+		}
+	}
+	// InsertEmbedding, FTSSearch, VectorSearch.
+	if n := len(s.stmts.stmts); n != 3 {
+		t.Errorf("cached statements = %d, want 3", n)
+	}
+
+	// Recreating vec_chunks drops the cached statements that reference it.
+	if err := s.ResetChunkVectors(ctx, 4); err != nil {
+		t.Fatalf("ResetChunkVectors: %v", err)
+	}
+	if len(s.stmts.stmts) != 0 {
+		t.Error("ResetChunkVectors must clear the statement cache")
+	}
+	if res, err := s.VectorSearch(ctx, []float32{1, 2, 4, 0.3}, 3); err != nil || len(res) != 0 {
+		t.Errorf("VectorSearch after reset: %d results, %v", len(res), err)
+	}
+
+	// A bad query still reports its error.
+	if _, err := s.FTSSearch(ctx, `"unterminated`, 5); err == nil {
+		t.Error("expected an FTS syntax error")
+	}
+}
+
+// BenchmarkSearchStatements compares the hot retrieval queries with and
+// without the prepared statement cache:
+//
+//	go test -tags sqlite_fts5 -run '^$' -bench SearchStatements ./store
+func BenchmarkSearchStatements(b *testing.B) {
+	s := newTestStore(b)
+	seedSearchCorpus(b, s, 500)
+	ctx := context.Background()
+	query := []float32{1, 2, 4, 0.3}
+
+	for _, mode := range []struct {
+		name     string
+		disabled bool
+	}{{"prepared", false}, {"unprepared", true}} {
+		s.resetStmts()
+		s.stmts.disabled = mode.disabled
+		b.Run("fts/"+mode.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := s.FTSSearch(ctx, "installation torque", 25); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run("vector/"+mode.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := s.VectorSearch(ctx, query, 25); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestManualCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewWithOptions(filepath.Join(t.TempDir(), "test.db"), 4, Options{ManualCheckpoint: true})
+	if err != nil {
+		t.Fatalf("NewWithOptions: %v", err)
+	}
+	defer s.Close()
+
+	var n int
+	if err := s.DB().QueryRowContext(ctx, "PRAGMA wal_autocheckpoint").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("wal_autocheckpoint = %d, want 0", n)
+	}
+	if _, err := s.UpsertDocument(ctx, sampleDoc("/docs/a.pdf")); err != nil {
+		t.Fatal(err)
+	}
+	if s.WALSize() == 0 {
+		t.Error("WALSize = 0 after a write")
+	}
+	cp, err := s.Checkpoint(ctx, CheckpointTruncate)
+	if err != nil {
+		t.Fatalf("Checkpoint: %v", err)
+	}
+	if cp.Busy || s.WALSize() != 0 {
+		t.Errorf("checkpoint = %+v, WAL %d bytes", cp, s.WALSize())
+	}
+	if _, err := s.Checkpoint(ctx, "NOW"); err == nil {
+		t.Error("Checkpoint accepted an unknown mode")
+	}
+}
+
+func TestContentEncryption(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	acmeKey := bytes.Repeat([]byte{1}, 32)
+	c, err := NewContentCipher(map[string][]byte{"acme": acmeKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetContentCipher(c)
+
+	acme := sampleDoc("/acme.pdf")
+	acme.Collection = "acme"
+	acmeID, _ := s.UpsertDocument(ctx, acme)
+	openID, _ := s.UpsertDocument(ctx, sampleDoc("/public.pdf"))
+	ids, err := s.InsertChunks(ctx, []Chunk{
+		{DocumentID: acmeID, Content: "The relief valve opens at 10 bar.", ChunkType: "paragraph"},
+		{DocumentID: openID, Content: "Public brochure text.", ChunkType: "paragraph"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.InsertChunkImages(ctx, []ChunkImage{
+		{ChunkID: ids[0], DocumentID: acmeID, Caption: "Valve diagram", MIMEType: "image/png", Data: []byte("png-bytes")},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for i, id := range ids {
+		s.InsertEmbedding(ctx, id, []float32{1, float32(i), 0, 0})
+	}
+
+	// At rest: the acme chunk and image are ciphertext, the other chunk is not.
+	var raw, rawOpen, rawCaption string
+	var rawData []byte
+	s.DB().QueryRow("SELECT content FROM chunks WHERE id = ?", ids[0]).Scan(&raw)
+	s.DB().QueryRow("SELECT content FROM chunks WHERE id = ?", ids[1]).Scan(&rawOpen)
+	s.DB().QueryRow("SELECT caption, data FROM chunk_images").Scan(&rawCaption, &rawData)
+	if !strings.HasPrefix(raw, encryptedPrefix) || strings.Contains(raw, "relief") {
+		t.Errorf("acme content stored as %q", raw)
+	}
+	if rawOpen != "Public brochure text." {
+		t.Errorf("plaintext collection content stored as %q", rawOpen)
+	}
+	if strings.Contains(rawCaption, "Valve") || bytes.Contains(rawData, []byte("png-bytes")) {
+		t.Errorf("image stored in plaintext: %q, %q", rawCaption, rawData)
+	}
+
+	// Read back for retrieval: decrypted.
+	got, err := s.GetChunk(ctx, ids[0])
+	if err != nil || got.Content != "The relief valve opens at 10 bar." {
+		t.Fatalf("GetChunk = %+v, %v", got, err)
+	}
+	results, err := s.VectorSearch(ctx, []float32{1, 0, 0, 0}, 5)
+	if err != nil || len(results) != 2 || results[0].Content != "The relief valve opens at 10 bar." {
+		t.Fatalf("VectorSearch = %+v, %v", results, err)
+	}
+	imgs, err := s.GetImagesByChunkIDs(ctx, []int64{ids[0]}, true)
+	if err != nil || imgs[ids[0]][0].Caption != "Valve diagram" || string(imgs[ids[0]][0].Data) != "png-bytes" {
+		t.Fatalf("images = %+v, %v", imgs, err)
+	}
+
+	// Without the key: encrypted chunks are left out of results and fail
+	// to read directly.
+	s.SetContentCipher(nil)
+	results, err = s.VectorSearch(ctx, []float32{1, 0, 0, 0}, 5)
+	if err != nil || len(results) != 1 || results[0].ChunkID != ids[1] {
+		t.Errorf("VectorSearch without key = %+v, %v", results, err)
+	}
+	if _, err := s.GetChunk(ctx, ids[0]); !errors.Is(err, ErrNoContentKey) {
+		t.Errorf("GetChunk without key: err = %v, want ErrNoContentKey", err)
+	}
+
+	// A wrong key under the right name does not decrypt.
+	other, _ := NewContentCipher(map[string][]byte{"acme": bytes.Repeat([]byte{2}, 32)})
+	s.SetContentCipher(other)
+	if _, err := s.GetChunk(ctx, ids[0]); !errors.Is(err, ErrNoContentKey) {
+		t.Errorf("GetChunk with another key: err = %v, want ErrNoContentKey", err)
+	}
+}
+
+func TestEncryptedKeyFacts(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	c, err := NewContentCipher(map[string][]byte{"acme": bytes.Repeat([]byte{1}, 32)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetContentCipher(c)
+	acme := sampleDoc("/acme.pdf")
+	acme.Collection = "acme"
+	acmeID, _ := s.UpsertDocument(ctx, acme)
+	openID, _ := s.UpsertDocument(ctx, sampleDoc("/public.pdf"))
+	if err := s.ReplaceKeyFacts(ctx, acmeID, []KeyFact{
+		{Schema: "contract", Field: "parties", Value: "Acme Corp", Position: 0},
+		{Schema: "contract", Field: "parties", Value: "Globex Ltd", Position: 1},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ReplaceKeyFacts(ctx, openID, []KeyFact{{Schema: "contract", Field: "parties", Value: "Acme Public", Position: 0}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var raw string
+	s.DB().QueryRow("SELECT value FROM key_facts WHERE document_id = ? AND position = 0", acmeID).Scan(&raw)
+	if !strings.HasPrefix(raw, encryptedPrefix) || strings.Contains(raw, "Acme") {
+		t.Errorf("acme key fact stored as %q", raw)
+	}
+
+	// Encrypted values are decrypted and matched by the query filter.
+	facts, err := s.ListKeyFacts(ctx, KeyFactFilter{Query: "acme"})
+	if err != nil || len(facts) != 2 || facts[0].Value != "Acme Corp" || facts[1].Value != "Acme Public" {
+		t.Fatalf("ListKeyFacts(acme) = %+v, %v", facts, err)
+	}
+	if facts, err := s.ListKeyFacts(ctx, KeyFactFilter{Query: "acme", Limit: 1}); err != nil || len(facts) != 1 {
+		t.Errorf("ListKeyFacts(acme, limit 1) = %+v, %v", facts, err)
+	}
+
+	// Without the key the encrypted values are left out.
+	s.SetContentCipher(nil)
+	facts, err = s.ListKeyFacts(ctx, KeyFactFilter{})
+	if err != nil || len(facts) != 1 || facts[0].DocumentID != openID {
+		t.Errorf("ListKeyFacts without key = %+v, %v", facts, err)
+	}
+}
+
+func TestEncryptedKeywordSearch(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	c, err := NewContentCipher(map[string][]byte{"acme": bytes.Repeat([]byte{1}, 32)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetContentCipher(c)
+
+	acme := sampleDoc("/acme.pdf")
+	acme.Collection = "acme"
+	acmeID, _ := s.UpsertDocument(ctx, acme)
+	openID, _ := s.UpsertDocument(ctx, sampleDoc("/public.pdf"))
+	ids, err := s.InsertChunks(ctx, []Chunk{
+		{DocumentID: acmeID, Content: "The relief valve opens at 10 bar.", Heading: "Valves", ChunkType: "paragraph"},
+		{DocumentID: openID, Content: "The brochure valve is decorative.", ChunkType: "paragraph"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := func(query string) map[int64]string {
+		t.Helper()
+		results, err := s.FTSSearch(ctx, query, 10)
+		if err != nil {
+			t.Fatalf("FTSSearch(%q): %v", query, err)
+		}
+		got := make(map[int64]string)
+		for _, r := range results {
+			got[r.ChunkID] = r.Content
+		}
+		return got
+	}
+	if got := found("relief"); got[ids[0]] != "The relief valve opens at 10 bar." || len(got) != 1 {
+		t.Errorf("FTSSearch(relief) = %v, want the decrypted acme chunk", got)
+	}
+	if got := found("valve"); len(got) != 2 {
+		t.Errorf("FTSSearch(valve) = %v, want both chunks", got)
+	}
+	// The ciphertext itself is not indexed.
+	var raw string
+	s.DB().QueryRow("SELECT content FROM chunks WHERE id = ?", ids[0]).Scan(&raw)
+	if got := found(`"` + strings.TrimPrefix(raw, encryptedPrefix)[:8] + `"`); len(got) != 0 {
+		t.Errorf("FTSSearch of the key ID = %v, want no hits", got)
+	}
+
+	// A re-ingest keeping the chunk reindexes its new heading.
+	if _, err := s.SyncChunks(ctx, acmeID, []Chunk{
+		{DocumentID: acmeID, Content: "The relief valve opens at 10 bar.", Heading: "Safety", ChunkType: "paragraph"},
+	}); err != nil {
+		t.Fatalf("SyncChunks: %v", err)
+	}
+	if got := found("safety"); got[ids[0]] == "" {
+		t.Errorf("FTSSearch(safety) = %v, want the kept chunk under its new heading", got)
+	}
+
+	// Without the key the chunk is left out; the index is rebuilt for
+	// chunks encrypted before it existed.
+	s.SetContentCipher(nil)
+	if got := found("relief"); len(got) != 0 {
+		t.Errorf("FTSSearch without key = %v", got)
+	}
+	s.SetContentCipher(c)
+	s.DB().Exec("DELETE FROM chunks_fts_sealed")
+	if n, err := s.IndexSealedChunks(ctx); err != nil || n != 1 {
+		t.Fatalf("IndexSealedChunks = %d, %v, want 1", n, err)
+	}
+	if got := found("relief"); got[ids[0]] == "" {
+		t.Errorf("FTSSearch after IndexSealedChunks = %v", got)
+	}
+
+	// Deleting the document drops its index rows.
+	if err := s.DeleteDocument(ctx, acmeID); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	s.DB().QueryRow("SELECT count(*) FROM chunks_fts_sealed").Scan(&n)
+	if n != 0 {
+		t.Errorf("%d sealed index rows left after delete", n)
+	}
+}
+
+func TestChunkUsage(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	docID, _ := s.UpsertDocument(ctx, sampleDoc("/manual.pdf"))
+	ids, _ := s.InsertChunks(ctx, []Chunk{
+		{DocumentID: docID, Content: "Torque the flange bolts to 40 Nm.", ChunkType: "p"},
+		{DocumentID: docID, Content: "Check the seal monthly.", ChunkType: "p", PositionInDoc: 1},
+	})
+
+	for i := 0; i < 2; i++ {
+		if err := s.RecordChunkUsage(ctx, ids, ids[:1], 0); err != nil {
+			t.Fatalf("RecordChunkUsage: %v", err)
+		}
+	}
+	// A deleted chunk is skipped rather than failing the whole record.
+	if err := s.RecordChunkUsage(ctx, []int64{ids[1], 9999}, nil, 0); err != nil {
+		t.Fatalf("RecordChunkUsage with a missing chunk: %v", err)
+	}
+
+	usage, err := s.ChunkUsages(ctx, append(ids, 9999), 0)
+	if err != nil {
+		t.Fatalf("ChunkUsages: %v", err)
+	}
+	if len(usage) != 2 {
+		t.Fatalf("usage = %+v, want 2 chunks", usage)
+	}
+	if u := usage[ids[0]]; u.Retrieved != 2 || u.Cited != 2 || u.RetrievedScore != 2 || u.CitedScore != 2 || u.LastUsed.IsZero() {
+		t.Errorf("chunk %d usage = %+v", ids[0], u)
+	}
+	if u := usage[ids[1]]; u.Retrieved != 3 || u.Cited != 0 {
+		t.Errorf("chunk %d usage = %+v", ids[1], u)
+	}
+
+	if got := decayUsage(8, 2*time.Hour, time.Hour); got != 2 {
+		t.Errorf("decayUsage over two half-lives = %v, want 2", got)
+	}
+
+	if err := s.DeleteDocumentData(ctx, docID); err != nil {
+		t.Fatalf("DeleteDocumentData: %v", err)
+	}
+	if usage, _ = s.ChunkUsages(ctx, ids, 0); len(usage) != 0 {
+		t.Errorf("expected usage cleared with document data, got %+v", usage)
+	}
+}
+
+func TestSessionDocuments(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	shared, _ := s.UpsertDocument(ctx, sampleDoc("/manual.pdf"))
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	doc := sampleDoc("session://a//upload.pdf")
+	doc.SessionID = "a"
+	doc.ExpiresAt = SessionExpiry(now)
+	upload, err := s.UpsertDocument(ctx, doc)
+	if err != nil {
+		t.Fatalf("UpsertDocument: %v", err)
+	}
+
+	got, err := s.GetDocument(ctx, upload)
+	if err != nil {
+		t.Fatalf("GetDocument: %v", err)
+	}
+	if got.SessionID != "a" || got.ExpiresAt != "2026-03-01T12:00:00Z" {
+		t.Errorf("session document = %q expiring %q", got.SessionID, got.ExpiresAt)
+	}
+	// Stored in the form it is read back in.
+	var stored int
+	if err := s.DB().QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM documents WHERE expires_at = '2026-03-01T12:00:00Z'").Scan(&stored); err != nil || stored != 1 {
+		t.Errorf("documents storing the RFC 3339 expiry = %d, %v", stored, err)
+	}
+	if got, _ := s.GetDocument(ctx, shared); got.SessionID != "" || got.ExpiresAt != "" {
+		t.Errorf("shared document has session %q expiring %q", got.SessionID, got.ExpiresAt)
+	}
+
+	sessions, err := s.SessionDocuments(ctx)
+	if err != nil {
+		t.Fatalf("SessionDocuments: %v", err)
+	}
+	if len(sessions) != 1 || sessions[upload] != "a" {
+		t.Errorf("SessionDocuments = %v, want only %d in session a", sessions, upload)
+	}
+
+	if ids, _ := s.ExpiredSessionDocuments(ctx, now.Add(-time.Minute)); len(ids) != 0 {
+		t.Errorf("expired before expiry: %v", ids)
+	}
+	if err := s.ExtendSession(ctx, "a", now.Add(time.Hour)); err != nil {
+		t.Fatalf("ExtendSession: %v", err)
+	}
+	// Extending never shortens.
+	if err := s.ExtendSession(ctx, "a", now.Add(time.Minute)); err != nil {
+		t.Fatalf("ExtendSession: %v", err)
+	}
+	if ids, _ := s.ExpiredSessionDocuments(ctx, now.Add(30*time.Minute)); len(ids) != 0 {
+		t.Errorf("expired before the extended expiry: %v", ids)
+	}
+	ids, err := s.ExpiredSessionDocuments(ctx, now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("ExpiredSessionDocuments: %v", err)
+	}
+	if len(ids) != 1 || ids[0] != upload {
+		t.Errorf("ExpiredSessionDocuments = %v, want [%d]", ids, upload)
+	}
+}
+
+func TestWriteQueue(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	// Concurrent writers queue for the writer instead of failing busy.
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		go func(i int) {
+			docID, err := s.UpsertDocument(ctx, sampleDoc(fmt.Sprintf("/doc%d.pdf", i)))
+			if err == nil {
+				_, err = s.InsertChunks(ctx, []Chunk{{DocumentID: docID, Content: "Chunk.", ChunkType: "p"}})
+			}
+			errs <- err
+		}(i)
+	}
+	for i := 0; i < 8; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("concurrent write: %v", err)
+		}
+	}
+	stats := s.WriteQueueStats()
+	if stats.Writes < 16 || stats.MaxQueued < 1 || stats.Queued != 0 || stats.BusyErrors != 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	// A write nested in another runs inline rather than waiting for itself.
+	nested, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	err := s.write(nested, func(ctx context.Context) error {
+		_, err := s.exec(ctx, "UPDATE documents SET status = 'ready'")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("nested write: %v", err)
+	}
+
+	if !isBusy(sqlite3.Error{Code: sqlite3.ErrBusy}) || isBusy(errors.New("other")) {
+		t.Error("isBusy misclassified an error")
+	}
+}
+
+func TestEmbedSummary(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	c, err := NewContentCipher(map[string][]byte{"acme": bytes.Repeat([]byte{1}, 32)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetContentCipher(c)
+	doc := sampleDoc("/acme.pdf")
+	doc.Collection = "acme"
+	id, _ := s.UpsertDocument(ctx, doc)
+
+	if got, err := s.EmbedSummary(ctx, id, "h1"); err != nil || got != "" {
+		t.Fatalf("EmbedSummary before Put = %q, %v", got, err)
+	}
+	if err := s.PutEmbedSummary(ctx, id, "h1", "Acme shall indemnify"); err != nil {
+		t.Fatalf("PutEmbedSummary: %v", err)
+	}
+	var raw string
+	s.DB().QueryRow("SELECT summary FROM embed_summaries WHERE document_id = ?", id).Scan(&raw)
+	if !strings.HasPrefix(raw, encryptedPrefix) || strings.Contains(raw, "Acme") {
+		t.Errorf("summary stored as %q", raw)
+	}
+	if got, err := s.EmbedSummary(ctx, id, "h1"); err != nil || got != "Acme shall indemnify" {
+		t.Errorf("EmbedSummary = %q, %v", got, err)
+	}
+
+	// Without the key the summary is a miss; it goes with its document.
+	s.SetContentCipher(nil)
+	if got, err := s.EmbedSummary(ctx, id, "h1"); err != nil || got != "" {
+		t.Errorf("EmbedSummary without the key = %q, %v", got, err)
+	}
+	if err := s.DeleteDocument(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	s.DB().QueryRow("SELECT COUNT(*) FROM embed_summaries").Scan(&n)
+	if n != 0 {
+		t.Errorf("%d summaries left after DeleteDocument", n)
+	}
+}
@@ -0,0 +1,79 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// QueryTemplate is a named question with {parameter} placeholders, such as
+// "What is the {spec} of the {component}?".
+type QueryTemplate struct {
+	Name        string    `json:"name"`
+	Question    string    `json:"question"`
+	Description string    `json:"description,omitempty"`
+	Author      string    `json:"author,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// PutQueryTemplate stores a template, replacing the template of the same
+// name but keeping its creation time.
+func (s *Store) PutQueryTemplate(ctx context.Context, t QueryTemplate) error {
+	_, err := s.exec(ctx, `
+		INSERT INTO query_templates (name, question, description, author)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET
+			question = excluded.question, description = excluded.description,
+			author = excluded.author, updated_at = CURRENT_TIMESTAMP`,
+		t.Name, t.Question, t.Description, t.Author)
+	return err
+}
+
+// GetQueryTemplate returns the template with the given name, or
+// sql.ErrNoRows.
+func (s *Store) GetQueryTemplate(ctx context.Context, name string) (*QueryTemplate, error) {
+	var t QueryTemplate
+	err := s.db.QueryRowContext(ctx, `
+		SELECT name, question, COALESCE(description, ''), COALESCE(author, ''), created_at, updated_at
+		FROM query_templates WHERE name = ?`, name).
+		Scan(&t.Name, &t.Question, &t.Description, &t.Author, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ListQueryTemplates returns all templates in name order.
+func (s *Store) ListQueryTemplates(ctx context.Context) ([]QueryTemplate, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT name, question, COALESCE(description, ''), COALESCE(author, ''), created_at, updated_at
+		FROM query_templates ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []QueryTemplate
+	for rows.Next() {
+		var t QueryTemplate
+		if err := rows.Scan(&t.Name, &t.Question, &t.Description, &t.Author, &t.CreatedAt, &t.UpdatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+	return list, rows.Err()
+}
+
+// DeleteQueryTemplate removes a template. It returns sql.ErrNoRows when
+// the template does not exist.
+func (s *Store) DeleteQueryTemplate(ctx context.Context, name string) error {
+	res, err := s.exec(ctx, "DELETE FROM query_templates WHERE name = ?", name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// ChunkUsage counts how often a chunk was retrieved for an answer and cited
+// by an accepted one. The scores are the same counts with each use decayed
+// by its age, so chunks that stop being useful fade back.
+type ChunkUsage struct {
+	ChunkID        int64     `json:"chunk_id"`
+	Retrieved      int       `json:"retrieved"`
+	Cited          int       `json:"cited"`
+	RetrievedScore float64   `json:"retrieved_score"`
+	CitedScore     float64   `json:"cited_score"`
+	LastUsed       time.Time `json:"last_used"`
+}
+
+// decayUsage decays a usage score that was current elapsed ago, halving it
+// every halfLife. A halfLife of 0 keeps it unchanged.
+func decayUsage(score float64, elapsed, halfLife time.Duration) float64 {
+	if halfLife <= 0 || elapsed <= 0 {
+		return score
+	}
+	return score * math.Exp2(-float64(elapsed)/float64(halfLife))
+}
+
+// RecordChunkUsage adds one retrieval to each chunk of retrieved and one
+// citation to each of cited, decaying the stored scores by halfLife first.
+// Chunks deleted in the meantime are skipped.
+func (s *Store) RecordChunkUsage(ctx context.Context, retrieved, cited []int64, halfLife time.Duration) error {
+	if len(retrieved) == 0 && len(cited) == 0 {
+		return nil
+	}
+	type inc struct{ retrieved, cited int }
+	incs := make(map[int64]*inc)
+	var ids []int64
+	add := func(id int64) *inc {
+		i, ok := incs[id]
+		if !ok {
+			i = &inc{}
+			incs[id] = i
+			ids = append(ids, id)
+		}
+		return i
+	}
+	for _, id := range retrieved {
+		add(id).retrieved = 1
+	}
+	for _, id := range cited {
+		add(id).cited = 1
+	}
+
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		now := time.Now()
+		for _, id := range ids {
+			var retrievedScore, citedScore float64
+			var decayedAt int64
+			err := tx.QueryRowContext(ctx,
+				"SELECT retrieved_score, cited_score, decayed_at FROM chunk_usage WHERE chunk_id = ?", id).
+				Scan(&retrievedScore, &citedScore, &decayedAt)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("reading usage of chunk %d: %w", id, err)
+			}
+			elapsed := now.Sub(time.Unix(decayedAt, 0))
+			i := incs[id]
+			if _, err := tx.ExecContext(ctx, `
+				INSERT INTO chunk_usage (chunk_id, retrieved, cited, retrieved_score, cited_score, decayed_at)
+				SELECT id, ?, ?, ?, ?, ? FROM chunks WHERE id = ?
+				ON CONFLICT(chunk_id) DO UPDATE SET
+					retrieved = retrieved + excluded.retrieved,
+					cited = cited + excluded.cited,
+					retrieved_score = excluded.retrieved_score,
+					cited_score = excluded.cited_score,
+					decayed_at = excluded.decayed_at`,
+				i.retrieved, i.cited,
+				decayUsage(retrievedScore, elapsed, halfLife)+float64(i.retrieved),
+				decayUsage(citedScore, elapsed, halfLife)+float64(i.cited),
+				now.Unix(), id); err != nil {
+				return fmt.Errorf("recording usage of chunk %d: %w", id, err)
+			}
+		}
+		return nil
+	})
+}
+
+// ChunkUsages returns the usage of each of chunkIDs that has been used,
+// with the scores decayed by halfLife to now.
+func (s *Store) ChunkUsages(ctx context.Context, chunkIDs []int64, halfLife time.Duration) (map[int64]ChunkUsage, error) {
+	if len(chunkIDs) == 0 {
+		return nil, nil
+	}
+	args := make([]interface{}, len(chunkIDs))
+	for i, id := range chunkIDs {
+		args[i] = id
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT chunk_id, retrieved, cited, retrieved_score, cited_score, decayed_at
+		FROM chunk_usage WHERE chunk_id IN (?`+strings.Repeat(",?", len(chunkIDs)-1)+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	out := make(map[int64]ChunkUsage)
+	for rows.Next() {
+		var u ChunkUsage
+		var decayedAt int64
+		if err := rows.Scan(&u.ChunkID, &u.Retrieved, &u.Cited, &u.RetrievedScore, &u.CitedScore, &decayedAt); err != nil {
+			return nil, err
+		}
+		u.LastUsed = time.Unix(decayedAt, 0)
+		elapsed := now.Sub(u.LastUsed)
+		u.RetrievedScore = decayUsage(u.RetrievedScore, elapsed, halfLife)
+		u.CitedScore = decayUsage(u.CitedScore, elapsed, halfLife)
+		out[u.ChunkID] = u
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,83 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// manualCheckpointDriver is the sqlite3 driver with automatic WAL
+// checkpoints turned off on every connection (Options.ManualCheckpoint).
+// wal_autocheckpoint is per connection, so it cannot be set once on the
+// pool.
+const manualCheckpointDriver = "sqlite3_manual_checkpoint"
+
+func init() {
+	sql.Register(manualCheckpointDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			_, err := conn.Exec("PRAGMA wal_autocheckpoint = 0", nil)
+			return err
+		},
+	})
+}
+
+// WAL checkpoint modes (see PRAGMA wal_checkpoint).
+const (
+	CheckpointPassive  = "PASSIVE"
+	CheckpointFull     = "FULL"
+	CheckpointRestart  = "RESTART"
+	CheckpointTruncate = "TRUNCATE"
+)
+
+// WALCheckpoint is the result of a WAL checkpoint.
+type WALCheckpoint struct {
+	Mode string `json:"mode"`
+	// Busy is set when a reader or writer kept the checkpoint from
+	// finishing; the frames not yet checkpointed stay in the WAL.
+	Busy               bool `json:"busy"`
+	LogFrames          int  `json:"log_frames"`
+	CheckpointedFrames int  `json:"checkpointed_frames"`
+}
+
+// Checkpoint copies WAL frames back into the database with the given mode.
+func (s *Store) Checkpoint(ctx context.Context, mode string) (*WALCheckpoint, error) {
+	switch mode {
+	case CheckpointPassive, CheckpointFull, CheckpointRestart, CheckpointTruncate:
+	default:
+		return nil, fmt.Errorf("unknown checkpoint mode %q", mode)
+	}
+	var busy int
+	cp := &WALCheckpoint{Mode: mode}
+	err := s.write(ctx, func(ctx context.Context) error {
+		return s.db.QueryRowContext(ctx, "PRAGMA wal_checkpoint("+mode+")").Scan(
+			&busy, &cp.LogFrames, &cp.CheckpointedFrames)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("checkpointing wal: %w", err)
+	}
+	cp.Busy = busy != 0
+	return cp, nil
+}
+
+// WALSize returns the size of the database's WAL file in bytes, or 0 when
+// there is none.
+func (s *Store) WALSize() int64 {
+	fi, err := os.Stat(s.path + "-wal")
+	if err != nil {
+		return 0
+	}
+	return fi.Size()
+}
+
+// Path returns the database file path.
+func (s *Store) Path() string {
+	return s.path
+}
+
+// ReadOnly reports whether the store was opened by OpenReader.
+func (s *Store) ReadOnly() bool {
+	return s.readOnly
+}
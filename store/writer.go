@@ -0,0 +1,202 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// busyRetries is how many times a write that SQLite reports busy is
+// retried, after waiting busyBackoff, then twice as long each time.
+const (
+	busyRetries = 4
+	busyBackoff = 50 * time.Millisecond
+)
+
+// WriteQueueStats reports contention on the store's writer: how many
+// writes ran and how long they queued for it, and how often SQLite found
+// the database locked by another connection (a replicator, a CLI, another
+// process) despite the busy timeout.
+type WriteQueueStats struct {
+	Writes      int64   `json:"writes"`
+	Queued      int     `json:"queued"` // waiting now
+	MaxQueued   int     `json:"max_queued"`
+	AvgWaitMs   float64 `json:"avg_wait_ms"`
+	MaxWaitMs   float64 `json:"max_wait_ms"`
+	BusyRetries int64   `json:"busy_retries"`
+	BusyErrors  int64   `json:"busy_errors"` // writes still busy after every retry
+}
+
+// writer runs the store's mutating operations one at a time on a
+// goroutine of its own, so concurrent ingests, query logging, and
+// maintenance never contend for SQLite's write lock; reads go straight to
+// the connection pool. Callers queue on an unbuffered channel, so a write
+// is running or waiting to be handed over, never dropped.
+type writer struct {
+	cmds    chan writeCmd
+	stop    chan struct{}
+	stopped chan struct{}
+
+	mu        sync.Mutex
+	stats     WriteQueueStats
+	totalWait time.Duration
+}
+
+type writeCmd struct {
+	ctx    context.Context
+	fn     func(context.Context) error
+	queued time.Time
+	done   chan error
+}
+
+// inWriterKey marks the context of a running write, so writes nested in it
+// run inline instead of queuing behind it.
+type inWriterKey struct{}
+
+func startWriter() *writer {
+	w := &writer{
+		cmds:    make(chan writeCmd),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+func (w *writer) run() {
+	defer close(w.stopped)
+	for {
+		select {
+		case <-w.stop:
+			return
+		case cmd := <-w.cmds:
+			wait := time.Since(cmd.queued)
+			w.mu.Lock()
+			w.stats.Queued--
+			w.stats.Writes++
+			w.totalWait += wait
+			if ms := float64(wait) / float64(time.Millisecond); ms > w.stats.MaxWaitMs {
+				w.stats.MaxWaitMs = ms
+			}
+			w.mu.Unlock()
+			cmd.done <- cmd.fn(context.WithValue(cmd.ctx, inWriterKey{}, true))
+		}
+	}
+}
+
+// do runs fn on the writer and returns its error. It gives up waiting for
+// the writer when ctx ends, but once fn has started it waits for it.
+func (w *writer) do(ctx context.Context, fn func(context.Context) error) error {
+	cmd := writeCmd{ctx: ctx, fn: fn, queued: time.Now(), done: make(chan error, 1)}
+	w.mu.Lock()
+	w.stats.Queued++
+	w.stats.MaxQueued = max(w.stats.MaxQueued, w.stats.Queued)
+	w.mu.Unlock()
+	select {
+	case w.cmds <- cmd:
+		return <-cmd.done
+	case <-ctx.Done():
+		w.dequeued()
+		return ctx.Err()
+	case <-w.stopped:
+		// The store is closing; fn fails on the closed database.
+		w.dequeued()
+		return fn(ctx)
+	}
+}
+
+func (w *writer) dequeued() {
+	w.mu.Lock()
+	w.stats.Queued--
+	w.mu.Unlock()
+}
+
+func (w *writer) close() {
+	close(w.stop)
+	<-w.stopped
+}
+
+// write runs fn as one mutating operation through the writer. Writes
+// nested in another, and all writes of a read-only or closed store, run
+// inline.
+func (s *Store) write(ctx context.Context, fn func(context.Context) error) error {
+	if s.writer == nil || ctx.Value(inWriterKey{}) != nil {
+		return fn(ctx)
+	}
+	return s.writer.do(ctx, fn)
+}
+
+// exec is ExecContext for mutating statements.
+func (s *Store) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	var res sql.Result
+	err := s.write(ctx, func(ctx context.Context) error {
+		return s.retryBusy(ctx, func() error {
+			var err error
+			res, err = s.db.ExecContext(ctx, query, args...)
+			return err
+		})
+	})
+	return res, err
+}
+
+// retryBusy runs op, retrying with backoff while SQLite reports the
+// database busy or locked. op must have no effect when it fails that way:
+// a single statement, or the start of an immediate transaction.
+func (s *Store) retryBusy(ctx context.Context, op func() error) error {
+	backoff := busyBackoff
+	for attempt := 0; ; attempt++ {
+		err := op()
+		if !isBusy(err) {
+			return err
+		}
+		if attempt == busyRetries {
+			s.countBusy(false)
+			return err
+		}
+		s.countBusy(true)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (s *Store) countBusy(retried bool) {
+	if s.writer == nil {
+		return
+	}
+	s.writer.mu.Lock()
+	if retried {
+		s.writer.stats.BusyRetries++
+	} else {
+		s.writer.stats.BusyErrors++
+	}
+	s.writer.mu.Unlock()
+}
+
+// isBusy reports whether err is SQLite's SQLITE_BUSY or SQLITE_LOCKED.
+func isBusy(err error) bool {
+	var se sqlite3.Error
+	return errors.As(err, &se) && (se.Code == sqlite3.ErrBusy || se.Code == sqlite3.ErrLocked)
+}
+
+// WriteQueueStats returns a snapshot of the writer's contention counters.
+// A read-only store reports zeros.
+func (s *Store) WriteQueueStats() WriteQueueStats {
+	if s.writer == nil {
+		return WriteQueueStats{}
+	}
+	s.writer.mu.Lock()
+	defer s.writer.mu.Unlock()
+	stats := s.writer.stats
+	if stats.Writes > 0 {
+		stats.AvgWaitMs = float64(s.writer.totalWait) / float64(time.Millisecond) / float64(stats.Writes)
+	}
+	return stats
+}
@@ -0,0 +1,152 @@
+package goreason
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/bbiangul/go-reason/llm"
+	"github.com/bbiangul/go-reason/store"
+)
+
+const (
+	// maxSuggestedQuestions caps the follow-up questions returned per answer.
+	maxSuggestedQuestions = 5
+	// suggestionSourceChars caps how much of each source is shown to the
+	// suggestion prompt; headings and openings are enough to ground it.
+	suggestionSourceChars = 600
+	// suggestionEntities caps the entity neighbourhood listed in the prompt.
+	suggestionEntities = 20
+)
+
+const suggestedQuestionsPrompt = `A user asked a question about a document collection and received an answer.
+Suggest 3-5 natural follow-up questions the user is likely to ask next.
+
+Rules:
+- Every question MUST be answerable from the CONTEXT or RELATED TOPICS below. Do not invent topics.
+- Do not repeat or rephrase the original question.
+- Prefer questions that explore related components, procedures, requirements, or consequences.
+- Keep each question short (under 20 words) and in the same language as the original question.
+
+Return a JSON object: {"questions": ["...", "..."]}
+Do NOT include any text outside the JSON object.
+
+ORIGINAL QUESTION: %s
+
+ANSWER: %s
+
+CONTEXT:
+%s
+RELATED TOPICS: %s`
+
+// suggestedQuestionsResult is the JSON shape returned by the suggestion call.
+type suggestedQuestionsResult struct {
+	Questions []string `json:"questions"`
+}
+
+// suggestQuestions generates follow-up questions grounded in the answer's
+// sources and the entity neighbourhood of those sources. It returns the
+// questions and the prompt/completion tokens spent.
+func (e *engine) suggestQuestions(ctx context.Context, question string, answer *Answer) ([]string, int, int, error) {
+	if len(answer.Sources) == 0 {
+		return nil, 0, 0, nil
+	}
+
+	chunkIDs := make([]int64, len(answer.Sources))
+	for i, s := range answer.Sources {
+		chunkIDs[i] = s.ChunkID
+	}
+	entities, err := e.store.GetEntitiesByChunkIDs(ctx, chunkIDs, suggestionEntities)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("loading source entities: %w", err)
+	}
+	if len(entities) > 0 && len(entities) < suggestionEntities {
+		ids := make([]int64, len(entities))
+		for i, ent := range entities {
+			ids[i] = ent.ID
+		}
+		related, err := e.store.GetRelatedEntities(ctx, ids, suggestionEntities-len(entities))
+		if err == nil {
+			entities = append(entities, related...)
+		}
+	}
+
+	resp, err := e.chatLLM.Chat(ctx, llm.ChatRequest{
+		Messages: []llm.Message{
+			{Role: "user", Content: fmt.Sprintf(suggestedQuestionsPrompt,
+				question, answer.Text, suggestionContext(answer.Sources), entityNames(entities))},
+		},
+		Temperature:    0.3,
+		ResponseFormat: "json_object",
+	})
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("suggestion llm chat: %w", err)
+	}
+
+	questions, err := parseSuggestedQuestions(resp.Content, question)
+	return questions, resp.PromptTokens, resp.CompletionTokens, err
+}
+
+// suggestionContext renders the answer's sources as a compact context block.
+func suggestionContext(sources []Source) string {
+	var b strings.Builder
+	for _, s := range sources {
+		content := s.Content
+		if len(content) > suggestionSourceChars {
+			cut := strings.LastIndex(content[:suggestionSourceChars], " ")
+			if cut <= 0 {
+				cut = suggestionSourceChars
+			}
+			content = content[:cut] + " ..."
+		}
+		if s.Heading != "" {
+			fmt.Fprintf(&b, "[%s] %s\n", s.Heading, content)
+		} else {
+			fmt.Fprintf(&b, "%s\n", content)
+		}
+	}
+	return b.String()
+}
+
+// entityNames lists entity names for the prompt, or "(none)".
+func entityNames(entities []store.Entity) string {
+	if len(entities) == 0 {
+		return "(none)"
+	}
+	names := make([]string, len(entities))
+	for i, ent := range entities {
+		names[i] = ent.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// parseSuggestedQuestions decodes the LLM response, tolerating surrounding
+// prose, and drops blanks, duplicates, and echoes of the original question.
+func parseSuggestedQuestions(raw, question string) ([]string, error) {
+	raw = llm.JSONObject(raw)
+
+	var result suggestedQuestionsResult
+	if err := json.Unmarshal([]byte(raw), &result); err != nil {
+		return nil, fmt.Errorf("unmarshalling suggested questions: %w", err)
+	}
+
+	normalize := func(s string) string {
+		return strings.TrimRight(strings.ToLower(strings.TrimSpace(s)), "?.! ")
+	}
+	seen := map[string]bool{normalize(question): true}
+	out := make([]string, 0, len(result.Questions))
+	for _, q := range result.Questions {
+		q = strings.TrimSpace(q)
+		key := normalize(q)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, q)
+		if len(out) == maxSuggestedQuestions {
+			break
+		}
+	}
+	return out, nil
+}
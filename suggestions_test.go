@@ -0,0 +1,40 @@
+package goreason
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSuggestedQuestions(t *testing.T) {
+	raw := "Sure!\n" + `{"questions": ["What is the torque?", "How is the damper installed?", "  ", "how is the damper installed", "What voltage does it use?", "Q4?", "Q5?", "Q6?"]}`
+	got, err := parseSuggestedQuestions(raw, "What is the torque")
+	if err != nil {
+		t.Fatalf("parseSuggestedQuestions: %v", err)
+	}
+	want := []string{"How is the damper installed?", "What voltage does it use?", "Q4?", "Q5?", "Q6?"}
+	if len(got) != len(want) {
+		t.Fatalf("questions = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("questions[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	if _, err := parseSuggestedQuestions("no json", "q"); err == nil {
+		t.Error("expected error for non-JSON response")
+	}
+}
+
+func TestSuggestionContextTruncates(t *testing.T) {
+	ctx := suggestionContext([]Source{
+		{Heading: "Wiring", Content: strings.Repeat("cable ", suggestionSourceChars)},
+		{Content: "short"},
+	})
+	if !strings.HasPrefix(ctx, "[Wiring] cable") || !strings.Contains(ctx, " ...\nshort\n") {
+		t.Errorf("unexpected context: %q", ctx[len(ctx)-40:])
+	}
+	if entityNames(nil) != "(none)" {
+		t.Error("expected placeholder for empty entity list")
+	}
+}
@@ -0,0 +1,408 @@
+package goreason
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/bbiangul/go-reason/chunker"
+	"github.com/bbiangul/go-reason/llm"
+	"github.com/bbiangul/go-reason/store"
+)
+
+const (
+	// summarizeBatchTokens is the chunk text sent per map call, and the
+	// size below which part summaries are reduced in one call.
+	summarizeBatchTokens = 3000
+	// defaultSummaryKeyPoints is the number of key points asked for.
+	defaultSummaryKeyPoints = 7
+	// maxSummarizeLevels bounds the rounds of collapsing part summaries
+	// that do not fit one reduce call.
+	maxSummarizeLevels = 4
+)
+
+// Summarization is the result of SummarizeDocument.
+type Summarization struct {
+	DocumentID   int64         `json:"document_id"`
+	Filename     string        `json:"filename"`
+	ShortSummary string        `json:"short_summary"`
+	LongSummary  string        `json:"long_summary"`
+	KeyPoints    []string      `json:"key_points"`
+	Parts        []SummaryPart `json:"parts"`
+	// FailedParts counts map calls that failed and were left out.
+	FailedParts int `json:"failed_parts,omitempty"`
+
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// SummaryPart is the summary of a run of consecutive top-level sections
+// (or of part of one long section), from the map step.
+type SummaryPart struct {
+	Headings  []string `json:"headings,omitempty"`
+	Summary   string   `json:"summary"`
+	KeyPoints []string `json:"key_points,omitempty"`
+}
+
+// SummarizeOption configures SummarizeDocument.
+type SummarizeOption func(*summarizeOptions)
+
+type summarizeOptions struct {
+	language  string
+	keyPoints int
+	focus     string
+}
+
+// WithSummaryLanguage sets the language of the summary. By default it is
+// written in the document's language.
+func WithSummaryLanguage(lang string) SummarizeOption {
+	return func(o *summarizeOptions) { o.language = lang }
+}
+
+// WithSummaryKeyPoints sets the number of key points (default 7).
+func WithSummaryKeyPoints(n int) SummarizeOption {
+	return func(o *summarizeOptions) { o.keyPoints = n }
+}
+
+// WithSummaryFocus asks the summary to concentrate on a topic, e.g.
+// "maintenance intervals" or "obligations of the supplier".
+func WithSummaryFocus(focus string) SummarizeOption {
+	return func(o *summarizeOptions) { o.focus = focus }
+}
+
+// instructions renders the options as prompt lines.
+func (o *summarizeOptions) instructions() string {
+	var b strings.Builder
+	if o.focus != "" {
+		fmt.Fprintf(&b, "Focus on: %s\n", o.focus)
+	}
+	if o.language != "" {
+		fmt.Fprintf(&b, "Write in %s.\n", o.language)
+	} else {
+		b.WriteString("Write in the same language as the document.\n")
+	}
+	return b.String()
+}
+
+const summarizeMapPrompt = `You are summarizing one part of a longer document. Headings are marked with #.
+Return a JSON object with exactly these keys:
+  "summary"    : string (2-4 sentences on what this part covers and says)
+  "key_points" : array of strings (at most %d facts, figures, requirements, or conclusions stated in this part)
+%s
+Use only what the text states. Do NOT include any text outside the JSON object.
+
+DOCUMENT: %s
+
+TEXT:
+%s`
+
+const summarizeReducePrompt = `You are summarizing a document. %s
+Return a JSON object with exactly these keys:
+  "short_summary" : string (1-2 sentences: what the document is and what it is for)
+  "long_summary"  : string (2-4 paragraphs following the order and structure of the document)
+  "key_points"    : array of strings (the %d most important facts, figures, requirements, or conclusions)
+%s
+Use only what the input states. Do NOT include any text outside the JSON object.
+
+DOCUMENT: %s
+
+%s`
+
+// summarizeSection is a run of text under one top-level heading.
+type summarizeSection struct {
+	heading string
+	text    string
+	tokens  int
+}
+
+// summarizeBatch is the text of one map call.
+type summarizeBatch struct {
+	headings []string
+	text     strings.Builder
+	tokens   int
+}
+
+// SummarizeDocument summarizes a document from its stored chunks by
+// map-reduce: runs of consecutive top-level sections are summarized in
+// batches, long sections in several parts, and the part summaries are
+// combined into short and long summaries and key points. A failed part is
+// logged and left out. Short documents take a single LLM call.
+func (e *engine) SummarizeDocument(ctx context.Context, documentID int64, opts ...SummarizeOption) (*Summarization, error) {
+	options := &summarizeOptions{keyPoints: defaultSummaryKeyPoints}
+	for _, o := range opts {
+		o(options)
+	}
+	if options.keyPoints <= 0 {
+		options.keyPoints = defaultSummaryKeyPoints
+	}
+
+	doc, err := e.store.GetDocument(ctx, documentID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %d", ErrDocumentNotFound, documentID)
+		}
+		return nil, err
+	}
+	chunks, err := e.store.GetChunksByDocument(ctx, documentID)
+	if err != nil {
+		return nil, fmt.Errorf("loading chunks: %w", err)
+	}
+
+	out := &Summarization{DocumentID: doc.ID, Filename: doc.Filename, KeyPoints: []string{}, Parts: []SummaryPart{}}
+	batches := batchSummarySections(summarySections(chunks), summarizeBatchTokens)
+	if len(batches) == 0 {
+		return out, nil
+	}
+
+	// A document that fits one call is summarized directly.
+	if len(batches) == 1 {
+		if err := e.reduceSummary(ctx, out, doc.Filename, "INPUT is the full text of the document. Headings are marked with #.",
+			"TEXT:\n"+batches[0].text.String(), options); err != nil {
+			return nil, err
+		}
+		return out, nil
+	}
+
+	// Map: one summary per batch of sections.
+	for i, b := range batches {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		part, err := e.summarizePart(ctx, out, doc.Filename, b.text.String(), options)
+		if err != nil {
+			out.FailedParts++
+			slog.WarnContext(ctx, "summarize: part failed (non-fatal)", "doc_id", documentID, "part", i+1, "error", err)
+			continue
+		}
+		part.Headings = b.headings
+		out.Parts = append(out.Parts, *part)
+	}
+	if len(out.Parts) == 0 {
+		return nil, fmt.Errorf("summarization failed: all %d parts failed", out.FailedParts)
+	}
+
+	// Collapse part summaries that do not fit one reduce call.
+	parts := out.Parts
+	for level := 0; level < maxSummarizeLevels; level++ {
+		var sections []summarizeSection
+		total := 0
+		for _, p := range parts {
+			text := renderSummaryPart(p)
+			n := chunker.EstimateTokens(text)
+			sections = append(sections, summarizeSection{heading: strings.Join(p.Headings, "; "), text: text, tokens: n})
+			total += n
+		}
+		if total <= summarizeBatchTokens || len(parts) == 1 {
+			break
+		}
+		var collapsed []SummaryPart
+		for _, b := range batchSummarySections(sections, summarizeBatchTokens) {
+			part, err := e.summarizePart(ctx, out, doc.Filename, b.text.String(), options)
+			if err != nil {
+				return nil, fmt.Errorf("combining part summaries: %w", err)
+			}
+			part.Headings = b.headings
+			collapsed = append(collapsed, *part)
+		}
+		parts = collapsed
+	}
+
+	var input strings.Builder
+	input.WriteString("PART SUMMARIES:\n")
+	for i, p := range parts {
+		fmt.Fprintf(&input, "[%d] %s\n", i+1, renderSummaryPart(p))
+	}
+	if err := e.reduceSummary(ctx, out, doc.Filename,
+		"INPUT is the summaries of consecutive parts of the document, in document order.", input.String(), options); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// summarySections groups a document's leaf chunks under their top-level
+// section, in document order. Parent chunks repeat their children's text
+// and are skipped; a nested section's heading is kept as a sub-heading.
+func summarySections(chunks []store.Chunk) []summarizeSection {
+	byID := make(map[int64]store.Chunk, len(chunks))
+	parents := make(map[int64]bool)
+	for _, c := range chunks {
+		byID[c.ID] = c
+		if c.ParentChunkID != nil {
+			parents[*c.ParentChunkID] = true
+		}
+	}
+	root := func(c store.Chunk) store.Chunk {
+		for c.ParentChunkID != nil {
+			p, ok := byID[*c.ParentChunkID]
+			if !ok {
+				break
+			}
+			c = p
+		}
+		return c
+	}
+
+	var sections []summarizeSection
+	var b strings.Builder
+	rootID, heading := int64(-1), ""
+	flush := func() {
+		if text := strings.TrimSpace(b.String()); text != "" {
+			sections = append(sections, summarizeSection{heading: byID[rootID].Heading, text: text, tokens: chunker.EstimateTokens(text)})
+		}
+		b.Reset()
+	}
+	for _, c := range chunks {
+		if parents[c.ID] || strings.TrimSpace(c.Content) == "" {
+			continue
+		}
+		r := root(c)
+		if r.ID != rootID {
+			flush()
+			rootID, heading = r.ID, ""
+		}
+		if c.Heading != heading {
+			heading = c.Heading
+			if heading != "" {
+				level := "#"
+				if c.Heading != r.Heading {
+					level = "##"
+				}
+				fmt.Fprintf(&b, "%s %s\n", level, heading)
+			}
+		}
+		b.WriteString(c.Content)
+		b.WriteString("\n\n")
+	}
+	flush()
+	return sections
+}
+
+// batchSummarySections packs consecutive sections into batches of about
+// limit tokens. A section longer than limit is split on paragraph
+// boundaries, repeating its heading in each batch.
+func batchSummarySections(sections []summarizeSection, limit int) []*summarizeBatch {
+	var batches []*summarizeBatch
+	cur := &summarizeBatch{}
+	add := func(heading, text string, tokens int, continued bool) {
+		if cur.tokens > 0 && cur.tokens+tokens > limit {
+			batches = append(batches, cur)
+			cur = &summarizeBatch{}
+		}
+		if n := len(cur.headings); heading != "" && (n == 0 || cur.headings[n-1] != heading) {
+			cur.headings = append(cur.headings, heading)
+		}
+		if continued && heading != "" {
+			fmt.Fprintf(&cur.text, "# %s (continued)\n", heading)
+		}
+		cur.text.WriteString(text)
+		cur.text.WriteString("\n\n")
+		cur.tokens += tokens
+	}
+	for _, sec := range sections {
+		if sec.tokens <= limit {
+			add(sec.heading, sec.text, sec.tokens, false)
+			continue
+		}
+		var piece strings.Builder
+		first := true
+		for _, para := range strings.Split(sec.text, "\n\n") {
+			if piece.Len() > 0 && chunker.EstimateTokens(piece.String()+para) > limit {
+				add(sec.heading, piece.String(), chunker.EstimateTokens(piece.String()), !first)
+				piece.Reset()
+				first = false
+			}
+			if piece.Len() > 0 {
+				piece.WriteString("\n\n")
+			}
+			piece.WriteString(para)
+		}
+		if piece.Len() > 0 {
+			add(sec.heading, piece.String(), chunker.EstimateTokens(piece.String()), !first)
+		}
+	}
+	if cur.tokens > 0 {
+		batches = append(batches, cur)
+	}
+	return batches
+}
+
+// renderSummaryPart formats a part summary as reduce input.
+func renderSummaryPart(p SummaryPart) string {
+	var b strings.Builder
+	if len(p.Headings) > 0 {
+		fmt.Fprintf(&b, "(%s) ", strings.Join(p.Headings, "; "))
+	}
+	b.WriteString(p.Summary)
+	for _, k := range p.KeyPoints {
+		b.WriteString("\n- ")
+		b.WriteString(k)
+	}
+	return b.String()
+}
+
+// summarizePart runs one map call.
+func (e *engine) summarizePart(ctx context.Context, out *Summarization, filename, text string, o *summarizeOptions) (*SummaryPart, error) {
+	raw, err := e.summaryChat(ctx, out, fmt.Sprintf(summarizeMapPrompt, o.keyPoints, o.instructions(), filename, text))
+	if err != nil {
+		return nil, err
+	}
+	var part SummaryPart
+	if err := json.Unmarshal([]byte(raw), &part); err != nil {
+		return nil, fmt.Errorf("unmarshalling part summary: %w", err)
+	}
+	part.Summary = strings.TrimSpace(part.Summary)
+	if part.Summary == "" {
+		return nil, fmt.Errorf("empty part summary in response")
+	}
+	return &part, nil
+}
+
+// reduceSummary runs the final call and fills out's summaries.
+func (e *engine) reduceSummary(ctx context.Context, out *Summarization, filename, inputNote, input string, o *summarizeOptions) error {
+	raw, err := e.summaryChat(ctx, out, fmt.Sprintf(summarizeReducePrompt, inputNote, o.keyPoints, o.instructions(), filename, input))
+	if err != nil {
+		return fmt.Errorf("summarizing document: %w", err)
+	}
+	var result struct {
+		ShortSummary string   `json:"short_summary"`
+		LongSummary  string   `json:"long_summary"`
+		KeyPoints    []string `json:"key_points"`
+	}
+	if err := json.Unmarshal([]byte(raw), &result); err != nil {
+		return fmt.Errorf("unmarshalling document summary: %w", err)
+	}
+	out.ShortSummary = strings.TrimSpace(result.ShortSummary)
+	out.LongSummary = strings.TrimSpace(result.LongSummary)
+	if out.ShortSummary == "" && out.LongSummary == "" {
+		return fmt.Errorf("empty document summary in response")
+	}
+	for _, k := range result.KeyPoints {
+		if k = strings.TrimSpace(k); k != "" && len(out.KeyPoints) < o.keyPoints {
+			out.KeyPoints = append(out.KeyPoints, k)
+		}
+	}
+	return nil
+}
+
+// summaryChat sends a summarization prompt, adds its token usage to out,
+// and returns the reply's JSON object.
+func (e *engine) summaryChat(ctx context.Context, out *Summarization, prompt string) (string, error) {
+	resp, err := e.chatLLM.Chat(ctx, llm.ChatRequest{
+		Messages:       []llm.Message{{Role: "user", Content: prompt}},
+		Temperature:    0.0,
+		ResponseFormat: "json_object",
+	})
+	if err != nil {
+		return "", err
+	}
+	out.PromptTokens += resp.PromptTokens
+	out.CompletionTokens += resp.CompletionTokens
+	out.TotalTokens += resp.TotalTokens
+
+	return llm.JSONObject(resp.Content), nil
+}
@@ -0,0 +1,108 @@
+package goreason
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bbiangul/go-reason/store"
+)
+
+func TestSummarySectionsFollowHeadings(t *testing.T) {
+	id := func(n int64) *int64 { return &n }
+	chunks := []store.Chunk{
+		{ID: 1, Heading: "1 Scope", Content: "1 Scope (parent)"},
+		{ID: 2, ParentChunkID: id(1), Heading: "1 Scope", Content: "Covers the AV-FM damper."},
+		{ID: 3, ParentChunkID: id(1), Heading: "1.1 Limits", Content: "1.1 Limits (parent)"},
+		{ID: 4, ParentChunkID: id(3), Heading: "1.1 Limits", Content: "Ducts up to 600 mm."},
+		{ID: 5, Heading: "2 Installation", Content: "2 Installation (parent)"},
+		{ID: 6, ParentChunkID: id(5), Heading: "2 Installation", Content: "Mount in the wall."},
+	}
+	sections := summarySections(chunks)
+	if len(sections) != 2 || sections[0].heading != "1 Scope" || sections[1].heading != "2 Installation" {
+		t.Fatalf("sections = %+v, want one per top-level heading", sections)
+	}
+	want := "# 1 Scope\nCovers the AV-FM damper.\n\n## 1.1 Limits\nDucts up to 600 mm."
+	if sections[0].text != want {
+		t.Errorf("section text = %q, want %q", sections[0].text, want)
+	}
+	if strings.Contains(sections[0].text, "(parent)") {
+		t.Error("parent chunk text was included")
+	}
+
+	// Both sections fit one batch; a tight limit gives one each, and a
+	// section over the limit is split with its heading repeated.
+	if b := batchSummarySections(sections, 1000); len(b) != 1 || len(b[0].headings) != 2 {
+		t.Errorf("batches = %d, want one covering both sections", len(b))
+	}
+	if b := batchSummarySections(sections, 20); len(b) != 2 {
+		t.Errorf("batches = %d, want 2", len(b))
+	}
+	b := batchSummarySections(sections[:1], 6)
+	if len(b) != 2 || !strings.Contains(b[1].text.String(), "# 1 Scope (continued)") {
+		t.Errorf("split section batches = %d, second %q", len(b), b[len(b)-1].text.String())
+	}
+}
+
+func TestSummarizeDocumentMapReduce(t *testing.T) {
+	eng, srv, _ := faultEngine(t, nil)
+	ctx := context.Background()
+
+	// Four sections of ~1200 tokens each need several map calls.
+	var text strings.Builder
+	for i := 1; i <= 4; i++ {
+		fmt.Fprintf(&text, "SECTION %d MAINTENANCE\n\n", i)
+		for p := 0; p < 10; p++ {
+			text.WriteString(strings.Repeat(fmt.Sprintf("The pump unit %d requires inspection every month. ", i), 12))
+			text.WriteString("\n\n")
+		}
+	}
+	path := filepath.Join(t.TempDir(), "manual.txt")
+	if err := os.WriteFile(path, []byte(text.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	docID, err := eng.Ingest(ctx, path)
+	if err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+
+	var maps, reduces atomic.Int32
+	chat := srv.ChatFunc
+	srv.ChatFunc = func(prompt string) string {
+		switch {
+		case strings.Contains(prompt, "one part of a longer document"):
+			maps.Add(1)
+			return `{"summary": "Inspection intervals for one pump unit.", "key_points": ["monthly inspection"]}`
+		case strings.Contains(prompt, "You are summarizing a document."):
+			reduces.Add(1)
+			if !strings.Contains(prompt, "PART SUMMARIES") || !strings.Contains(prompt, "Write in Spanish.") {
+				return `{}`
+			}
+			return `{"short_summary": "Pump maintenance manual.", "long_summary": "Covers four pump units.", "key_points": ["a", "b", "c"]}`
+		}
+		return chat(prompt)
+	}
+
+	sum, err := eng.SummarizeDocument(ctx, docID, WithSummaryLanguage("Spanish"), WithSummaryKeyPoints(2))
+	if err != nil {
+		t.Fatalf("SummarizeDocument: %v", err)
+	}
+	if maps.Load() < 2 || reduces.Load() != 1 {
+		t.Errorf("map calls = %d, reduce calls = %d; want several maps and one reduce", maps.Load(), reduces.Load())
+	}
+	if sum.ShortSummary != "Pump maintenance manual." || len(sum.KeyPoints) != 2 {
+		t.Errorf("summary = %+v, want the reduced summary with 2 key points", sum)
+	}
+	if len(sum.Parts) != int(maps.Load()) || len(sum.Parts[0].Headings) == 0 {
+		t.Errorf("parts = %+v", sum.Parts)
+	}
+
+	if _, err := eng.SummarizeDocument(ctx, docID+100); !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("SummarizeDocument(unknown) = %v, want ErrDocumentNotFound", err)
+	}
+}
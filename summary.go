@@ -0,0 +1,168 @@
+package goreason
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bbiangul/go-reason/llm"
+	"github.com/bbiangul/go-reason/parser"
+)
+
+// summaryInputChars caps how much document text is sent to the LLM when
+// generating a document summary. The opening sections of technical and legal
+// documents (scope, purpose, definitions) carry most of the signal.
+const summaryInputChars = 12000
+
+// maxSummaryKeywords caps the keyword list stored per document.
+const maxSummaryKeywords = 15
+
+// DocumentSummary is the LLM-generated overview of an ingested document.
+type DocumentSummary struct {
+	DocumentID int64    `json:"document_id"`
+	Filename   string   `json:"filename"`
+	Summary    string   `json:"summary"`
+	Keywords   []string `json:"keywords"`
+}
+
+const documentSummaryPrompt = `You are summarizing a document for a search index.
+Read the document excerpt below and return a JSON object with exactly these keys:
+  "summary"  : string (3-5 sentences describing what the document covers, its purpose, and its scope)
+  "keywords" : array of strings (5-15 distinctive terms: product names, standards, part numbers, key concepts)
+
+Write the summary in the same language as the document. Keywords must be lowercase.
+Do NOT include any text outside the JSON object.
+
+DOCUMENT: %s
+
+%s`
+
+// documentSummaryResult is the JSON shape returned by the summary LLM call.
+type documentSummaryResult struct {
+	Summary  string   `json:"summary"`
+	Keywords []string `json:"keywords"`
+}
+
+// summaryInput flattens parsed sections into a single text, truncated to
+// summaryInputChars on a word boundary.
+func summaryInput(sections []parser.Section) string {
+	return sectionText(sections, summaryInputChars)
+}
+
+// sectionText flattens parsed sections into a single text, truncated to
+// limit bytes on a word boundary.
+func sectionText(sections []parser.Section, limit int) string {
+	var b strings.Builder
+	var walk func(secs []parser.Section)
+	walk = func(secs []parser.Section) {
+		for _, sec := range secs {
+			if b.Len() >= limit {
+				return
+			}
+			if sec.Heading != "" {
+				b.WriteString(sec.Heading)
+				b.WriteString("\n")
+			}
+			if sec.Content != "" {
+				b.WriteString(sec.Content)
+				b.WriteString("\n\n")
+			}
+			walk(sec.Children)
+		}
+	}
+	walk(sections)
+
+	text := b.String()
+	if len(text) <= limit {
+		return strings.TrimSpace(text)
+	}
+	cut := strings.LastIndex(text[:limit], " ")
+	if cut <= 0 {
+		cut = limit
+	}
+	return strings.TrimSpace(text[:cut])
+}
+
+// summarizeDocument asks the chat LLM for a summary and keyword list and
+// stores them on the document row.
+func (e *engine) summarizeDocument(ctx context.Context, docID int64, filename string, sections []parser.Section) error {
+	text := summaryInput(sections)
+	if text == "" {
+		return nil
+	}
+
+	resp, err := e.chatLLM.Chat(ctx, llm.ChatRequest{
+		Messages: []llm.Message{
+			{Role: "user", Content: fmt.Sprintf(documentSummaryPrompt, filename, text)},
+		},
+		Temperature:    0.0,
+		ResponseFormat: "json_object",
+	})
+	if err != nil {
+		return fmt.Errorf("summary llm chat: %w", err)
+	}
+
+	result, err := parseDocumentSummary(resp.Content)
+	if err != nil {
+		return err
+	}
+
+	keywordsJSON, _ := json.Marshal(result.Keywords)
+	return e.store.UpdateDocumentSummary(ctx, docID, result.Summary, string(keywordsJSON))
+}
+
+// parseDocumentSummary decodes the LLM response, tolerating surrounding
+// prose, and normalises the keyword list (lowercase, deduplicated, capped).
+func parseDocumentSummary(raw string) (*documentSummaryResult, error) {
+	raw = llm.JSONObject(raw)
+
+	var result documentSummaryResult
+	if err := json.Unmarshal([]byte(raw), &result); err != nil {
+		return nil, fmt.Errorf("unmarshalling summary result: %w", err)
+	}
+	result.Summary = strings.TrimSpace(result.Summary)
+	if result.Summary == "" {
+		return nil, fmt.Errorf("empty summary in response")
+	}
+
+	seen := make(map[string]bool)
+	keywords := make([]string, 0, len(result.Keywords))
+	for _, k := range result.Keywords {
+		k = strings.ToLower(strings.TrimSpace(k))
+		if k == "" || seen[k] {
+			continue
+		}
+		seen[k] = true
+		keywords = append(keywords, k)
+		if len(keywords) == maxSummaryKeywords {
+			break
+		}
+	}
+	result.Keywords = keywords
+	return &result, nil
+}
+
+// DocumentSummary returns the stored summary and keywords for a document.
+func (e *engine) DocumentSummary(ctx context.Context, documentID int64) (*DocumentSummary, error) {
+	doc, err := e.store.GetDocument(ctx, documentID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %d", ErrDocumentNotFound, documentID)
+		}
+		return nil, err
+	}
+
+	out := &DocumentSummary{
+		DocumentID: doc.ID,
+		Filename:   doc.Filename,
+		Summary:    doc.Summary,
+		Keywords:   []string{},
+	}
+	if doc.Keywords != "" {
+		_ = json.Unmarshal([]byte(doc.Keywords), &out.Keywords)
+	}
+	return out, nil
+}
@@ -0,0 +1,52 @@
+package goreason
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bbiangul/go-reason/parser"
+)
+
+func TestParseDocumentSummary(t *testing.T) {
+	raw := "Here you go:\n{\"summary\": \" Installation manual for the AV-FM damper. \", \"keywords\": [\"AV-FM\", \"en 1366-2\", \"av-fm\", \" \"]}"
+	got, err := parseDocumentSummary(raw)
+	if err != nil {
+		t.Fatalf("parseDocumentSummary: %v", err)
+	}
+	if got.Summary != "Installation manual for the AV-FM damper." {
+		t.Errorf("summary = %q", got.Summary)
+	}
+	want := []string{"av-fm", "en 1366-2"}
+	if len(got.Keywords) != len(want) {
+		t.Fatalf("keywords = %v, want %v", got.Keywords, want)
+	}
+	for i := range want {
+		if got.Keywords[i] != want[i] {
+			t.Errorf("keywords[%d] = %q, want %q", i, got.Keywords[i], want[i])
+		}
+	}
+}
+
+func TestParseDocumentSummaryErrors(t *testing.T) {
+	for _, raw := range []string{"no json here", `{"summary": "", "keywords": ["a"]}`} {
+		if _, err := parseDocumentSummary(raw); err == nil {
+			t.Errorf("parseDocumentSummary(%q): expected error", raw)
+		}
+	}
+}
+
+func TestSummaryInputTruncates(t *testing.T) {
+	long := strings.Repeat("word ", summaryInputChars)
+	sections := []parser.Section{
+		{Heading: "Scope", Content: "Short intro.", Children: []parser.Section{
+			{Heading: "Details", Content: long},
+		}},
+	}
+	got := summaryInput(sections)
+	if !strings.HasPrefix(got, "Scope\nShort intro.") {
+		t.Errorf("expected input to start with first section, got %q", got[:30])
+	}
+	if len(got) > summaryInputChars {
+		t.Errorf("input length %d exceeds cap %d", len(got), summaryInputChars)
+	}
+}
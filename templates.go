@@ -0,0 +1,165 @@
+package goreason
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bbiangul/go-reason/store"
+)
+
+// QueryTemplate is a named question with {parameter} placeholders, such as
+// "What is the {spec} of the {component}?", for queries a dashboard or
+// checklist repeats with different values.
+type QueryTemplate struct {
+	Name        string `json:"name"`
+	Question    string `json:"question"`
+	Description string `json:"description,omitempty"`
+	// Parameters are the placeholder names in the order they first
+	// appear in Question.
+	Parameters []string  `json:"parameters"`
+	Author     string    `json:"author,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// templateNamePattern keeps template names usable in URL paths.
+var templateNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,63}$`)
+
+// placeholderPattern matches a template parameter: {name}.
+var placeholderPattern = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// TemplateParameters returns the placeholder names of a template question
+// in the order they first appear.
+func TemplateParameters(question string) []string {
+	params := []string{}
+	seen := make(map[string]bool)
+	for _, m := range placeholderPattern.FindAllStringSubmatch(question, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			params = append(params, m[1])
+		}
+	}
+	return params
+}
+
+// RenderTemplate substitutes params into a template question. Every
+// placeholder needs a non-empty value, and every parameter a placeholder,
+// so a misspelt name fails instead of asking a different question. Values
+// are put on one line and are not expanded themselves.
+func RenderTemplate(question string, params map[string]string) (string, error) {
+	var missing []string
+	for _, name := range TemplateParameters(question) {
+		if strings.TrimSpace(params[name]) == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("%w: missing template parameters: %s", ErrInvalidConfig, strings.Join(missing, ", "))
+	}
+	var unknown []string
+	for name := range params {
+		if !strings.Contains(question, "{"+name+"}") {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return "", fmt.Errorf("%w: unknown template parameters: %s", ErrInvalidConfig, strings.Join(unknown, ", "))
+	}
+	return placeholderPattern.ReplaceAllStringFunc(question, func(p string) string {
+		return strings.Join(strings.Fields(params[p[1:len(p)-1]]), " ")
+	}), nil
+}
+
+// SaveQueryTemplate stores a query template, replacing the one of the same
+// name. The author defaults to the context's actor (WithActor).
+func (e *engine) SaveQueryTemplate(ctx context.Context, t QueryTemplate) error {
+	if err := e.writable(); err != nil {
+		return err
+	}
+	start := time.Now()
+	err := e.saveQueryTemplate(ctx, t)
+	e.audit(ctx, AuditSaveTemplate, start, 0, map[string]string{"name": t.Name}, err)
+	return err
+}
+
+func (e *engine) saveQueryTemplate(ctx context.Context, t QueryTemplate) error {
+	t.Question = strings.TrimSpace(t.Question)
+	if !templateNamePattern.MatchString(t.Name) {
+		return fmt.Errorf("%w: template name %q must be 1-64 letters, digits, '.', '_' or '-'", ErrInvalidConfig, t.Name)
+	}
+	if t.Question == "" {
+		return fmt.Errorf("%w: template question is required", ErrInvalidConfig)
+	}
+	if t.Author == "" {
+		t.Author = ActorFromContext(ctx)
+	}
+	return e.store.PutQueryTemplate(ctx, store.QueryTemplate{
+		Name:        t.Name,
+		Question:    t.Question,
+		Description: strings.TrimSpace(t.Description),
+		Author:      t.Author,
+	})
+}
+
+// QueryTemplates returns the stored query templates in name order.
+func (e *engine) QueryTemplates(ctx context.Context) ([]QueryTemplate, error) {
+	list, err := e.store.ListQueryTemplates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]QueryTemplate, len(list))
+	for i, t := range list {
+		out[i] = toQueryTemplate(t)
+	}
+	return out, nil
+}
+
+// DeleteQueryTemplate removes a query template.
+func (e *engine) DeleteQueryTemplate(ctx context.Context, name string) error {
+	if err := e.writable(); err != nil {
+		return err
+	}
+	start := time.Now()
+	err := e.store.DeleteQueryTemplate(ctx, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+	e.audit(ctx, AuditDeleteTemplate, start, 0, map[string]string{"name": name}, err)
+	return err
+}
+
+// QueryTemplate renders the named template with params (see
+// RenderTemplate) and runs the question through Query.
+func (e *engine) QueryTemplate(ctx context.Context, name string, params map[string]string, opts ...QueryOption) (*Answer, error) {
+	t, err := e.store.GetQueryTemplate(ctx, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	question, err := RenderTemplate(t.Question, params)
+	if err != nil {
+		return nil, err
+	}
+	return e.Query(ctx, question, opts...)
+}
+
+func toQueryTemplate(t store.QueryTemplate) QueryTemplate {
+	return QueryTemplate{
+		Name:        t.Name,
+		Question:    t.Question,
+		Description: t.Description,
+		Parameters:  TemplateParameters(t.Question),
+		Author:      t.Author,
+		CreatedAt:   t.CreatedAt,
+		UpdatedAt:   t.UpdatedAt,
+	}
+}
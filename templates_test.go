@@ -0,0 +1,85 @@
+package goreason
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestQueryTemplates(t *testing.T) {
+	eng, srv, path := faultEngine(t, nil)
+	ctx := WithActor(context.Background(), "dashboard")
+	if _, err := eng.Ingest(ctx, path); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+
+	var (
+		mu      sync.Mutex
+		prompts []string
+	)
+	chat := srv.ChatFunc
+	srv.ChatFunc = func(prompt string) string {
+		mu.Lock()
+		prompts = append(prompts, prompt)
+		mu.Unlock()
+		return chat(prompt)
+	}
+
+	if err := eng.SaveQueryTemplate(ctx, QueryTemplate{Name: "setpoint", Question: "At what {spec} does the {component} open?"}); err != nil {
+		t.Fatalf("SaveQueryTemplate: %v", err)
+	}
+	// Saving again replaces the question.
+	if err := eng.SaveQueryTemplate(ctx, QueryTemplate{Name: "setpoint", Question: "At what {spec} does the {component} open? Check the {component} section."}); err != nil {
+		t.Fatalf("SaveQueryTemplate: %v", err)
+	}
+	list, err := eng.QueryTemplates(ctx)
+	if err != nil || len(list) != 1 || list[0].Author != "dashboard" || strings.Join(list[0].Parameters, ",") != "spec,component" {
+		t.Fatalf("QueryTemplates = %+v, %v", list, err)
+	}
+
+	ans, err := eng.QueryTemplate(ctx, "setpoint", map[string]string{"spec": "pressure", "component": " relief\nvalve "})
+	if err != nil {
+		t.Fatalf("QueryTemplate: %v", err)
+	}
+	if !strings.Contains(ans.Text, "10 bar") {
+		t.Errorf("answer = %q", ans.Text)
+	}
+	if !strings.Contains(strings.Join(prompts, "\n"), "At what pressure does the relief valve open? Check the relief valve section.") {
+		t.Error("rendered question not asked")
+	}
+
+	if _, err := eng.QueryTemplate(ctx, "setpoint", map[string]string{"spec": "pressure"}); !errors.Is(err, ErrInvalidConfig) || !strings.Contains(err.Error(), "component") {
+		t.Errorf("missing parameter = %v, want ErrInvalidConfig", err)
+	}
+	if _, err := eng.QueryTemplate(ctx, "setpoint", map[string]string{"spec": "pressure", "component": "valve", "compnent": "valve"}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("unknown parameter = %v, want ErrInvalidConfig", err)
+	}
+	if _, err := eng.QueryTemplate(ctx, "nope", nil); !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("unknown template = %v, want ErrTemplateNotFound", err)
+	}
+	if err := eng.SaveQueryTemplate(ctx, QueryTemplate{Name: "bad/name", Question: "?"}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("bad name = %v, want ErrInvalidConfig", err)
+	}
+
+	if err := eng.DeleteQueryTemplate(ctx, "setpoint"); err != nil {
+		t.Fatalf("DeleteQueryTemplate: %v", err)
+	}
+	if err := eng.DeleteQueryTemplate(ctx, "setpoint"); !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("second delete = %v, want ErrTemplateNotFound", err)
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	got, err := RenderTemplate("What is the {spec} of the {component}?", map[string]string{"spec": "{component}", "component": "pump"})
+	if err != nil || got != "What is the {component} of the pump?" {
+		t.Errorf("RenderTemplate = %q, %v; values must not be expanded", got, err)
+	}
+	if got, err := RenderTemplate("List all {x} and {y}.", map[string]string{"x": "valves", "y": " "}); err == nil {
+		t.Errorf("RenderTemplate = %q, want an error for the blank value", got)
+	}
+	if got := TemplateParameters("No placeholders, {not one} here"); len(got) != 0 {
+		t.Errorf("TemplateParameters = %q", got)
+	}
+}
@@ -0,0 +1,124 @@
+package goreason
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestUpdateKeepsUnchangedChunks(t *testing.T) {
+	eng, srv, path := faultEngine(t, nil)
+	ctx := context.Background()
+	s := eng.(*engine).store
+
+	docID, err := eng.Ingest(ctx, path)
+	if err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	before, err := s.GetChunksByDocument(ctx, docID)
+	if err != nil || len(before) == 0 {
+		t.Fatalf("chunks = %v, %v", before, err)
+	}
+
+	// Count graph extractions during the update.
+	var extracted atomic.Int32
+	chat := srv.ChatFunc
+	srv.ChatFunc = func(prompt string) string {
+		if strings.Contains(prompt, "entity extraction engine") {
+			extracted.Add(1)
+		}
+		return chat(prompt)
+	}
+
+	data, _ := os.ReadFile(path)
+	data = append(data, []byte("\nDrain the casing before freezing weather and refill it with clean water before the pump "+
+		"is started again, checking that the relief valve seat is free of ice and debris.\n")...)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	changed, err := eng.Update(ctx, path)
+	if err != nil || !changed {
+		t.Fatalf("Update = %v, %v", changed, err)
+	}
+
+	after, err := s.GetChunksByDocument(ctx, docID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byContent := make(map[string]int64)
+	for _, c := range before {
+		byContent[c.Content] = c.ID
+	}
+	kept, added := 0, 0
+	for _, c := range after {
+		id, ok := byContent[c.Content]
+		switch {
+		case ok && id != c.ID:
+			t.Errorf("unchanged chunk %d got new ID %d", id, c.ID)
+		case ok:
+			kept++
+		default:
+			added++
+		}
+	}
+	if kept == 0 || added == 0 {
+		t.Fatalf("kept %d and added %d chunks, want some of each", kept, added)
+	}
+	if n := int(extracted.Load()); n != added {
+		t.Errorf("update extracted entities from %d chunks, want %d (the new ones only)", n, added)
+	}
+}
+
+func TestUpdateAllChangeReport(t *testing.T) {
+	eng, srv, path := faultEngine(t, nil)
+	ctx := context.Background()
+
+	if _, err := eng.Ingest(ctx, path); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	answer, err := eng.Query(ctx, "When does the relief valve open?")
+	if err != nil || answer.QueryID == 0 || len(answer.Sources) == 0 {
+		t.Fatalf("Query = %+v, %v", answer, err)
+	}
+
+	chat := srv.ChatFunc
+	srv.ChatFunc = func(prompt string) string {
+		if strings.Contains(prompt, "entity extraction engine") && strings.Contains(prompt, "pressure switch") {
+			return `{"language": "English", "entities": [{"name": "pressure switch", "type": "concept", "description": "Switch"}]}`
+		}
+		return chat(prompt)
+	}
+	text := "Pump maintenance.\n\n" +
+		"The pressure switch on the discharge line of the pump trips at 12 bar and resets once the " +
+		"pressure drops below 9 bar. Test the pressure switch every 250 operating hours.\n"
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := eng.UpdateAll(ctx)
+	if err != nil || len(results) != 1 || !results[0].Changed {
+		t.Fatalf("UpdateAll = %+v, %v", results, err)
+	}
+	changes := results[0].Changes
+	if changes == nil {
+		t.Fatal("changed document has no change report")
+	}
+	if len(changes.SectionsAdded)+len(changes.SectionsModified) == 0 {
+		t.Errorf("report = %+v, want an added or modified section", changes)
+	}
+	if strings.Join(changes.EntitiesAdded, ",") != "pressure switch" || strings.Join(changes.EntitiesRemoved, ",") != "relief valve" {
+		t.Errorf("entities added %v, removed %v", changes.EntitiesAdded, changes.EntitiesRemoved)
+	}
+	if len(changes.StaleAnswers) != 1 || changes.StaleAnswers[0].QueryID != answer.QueryID {
+		t.Errorf("stale answers = %+v, want query %d", changes.StaleAnswers, answer.QueryID)
+	}
+
+	// Unchanged documents have no report, and answers already reported
+	// stale are not reported again.
+	results, err = eng.UpdateAll(ctx)
+	if err != nil || len(results) != 1 || results[0].Changed || results[0].Changes != nil {
+		t.Errorf("second UpdateAll = %+v, %v", results, err)
+	}
+}
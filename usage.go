@@ -0,0 +1,71 @@
+package goreason
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/bbiangul/go-reason/reasoning"
+	"github.com/bbiangul/go-reason/store"
+)
+
+// usageAcceptConfidence is the confidence from which an answer counts as
+// accepted, so the sources it cites count as cited.
+const usageAcceptConfidence = 0.5
+
+// usageHalfLife returns Config.UsageHalfLifeDays as a duration.
+func (e *engine) usageHalfLife() time.Duration {
+	return time.Duration(e.cfg.UsageHalfLifeDays * float64(24*time.Hour))
+}
+
+// recordUsage counts the sources of answer as retrieved and, when the
+// answer is accepted, the sources its text cites as cited, for
+// Config.UsageBoost. Read-only engines count nothing; failures are logged.
+func (e *engine) recordUsage(ctx context.Context, answer *Answer) {
+	if e.writable() != nil || len(answer.Sources) == 0 {
+		return
+	}
+	retrieved := make([]int64, len(answer.Sources))
+	for i, s := range answer.Sources {
+		retrieved[i] = s.ChunkID
+	}
+	var cited []int64
+	if answerAccepted(answer) {
+		cited = citedChunks(answer)
+	}
+	if err := e.queryLog.RecordChunkUsage(ctx, retrieved, cited, e.usageHalfLife()); err != nil {
+		slog.WarnContext(ctx, "query: recording chunk usage failed (non-fatal)", "error", err)
+	}
+}
+
+// answerAccepted reports whether answer found what was asked with enough
+// confidence for its citations to count.
+func answerAccepted(answer *Answer) bool {
+	if answer.Refusal != "" || answer.Found != nil && !*answer.Found {
+		return false
+	}
+	return answer.Confidence >= usageAcceptConfidence
+}
+
+// citedChunks returns the sources the answer's text cites, by filename,
+// heading, page, or source number.
+func citedChunks(answer *Answer) []int64 {
+	chunks := make([]store.RetrievalResult, len(answer.Sources))
+	for i, s := range answer.Sources {
+		chunks[i] = store.RetrievalResult{
+			ChunkID:    s.ChunkID,
+			Filename:   s.Filename,
+			Heading:    s.Heading,
+			PageNumber: s.PageNumber,
+		}
+	}
+	seen := make(map[int64]bool)
+	var ids []int64
+	for _, c := range reasoning.ExtractCitations(answer.Text, chunks) {
+		if c.Verified && c.ChunkID != 0 && !seen[c.ChunkID] {
+			seen[c.ChunkID] = true
+			ids = append(ids, c.ChunkID)
+		}
+	}
+	return ids
+}
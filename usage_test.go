@@ -0,0 +1,106 @@
+package goreason
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecordUsage(t *testing.T) {
+	eng, srv, pump := faultEngine(t, nil, skipGraph, func(cfg *Config) {
+		cfg.WeightVector = 1
+		cfg.WeightFTS = 1
+		cfg.UsageBoost = 0.5
+		cfg.UsageHalfLifeDays = 30
+	})
+	srv.ChatFunc = func(prompt string) string {
+		return "The relief valve opens at 10 bar [Source 1]."
+	}
+	ctx := context.Background()
+	for name, text := range map[string]string{
+		"pump.txt": "The relief valve opens at 10 bar.\n",
+		"seal.txt": "Replace the relief valve seal every year.\n",
+	} {
+		path := filepath.Join(filepath.Dir(pump), name)
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := eng.Ingest(ctx, path); err != nil {
+			t.Fatalf("Ingest: %v", err)
+		}
+	}
+
+	const question = "At what pressure does the relief valve open?"
+	answer, err := eng.Query(ctx, question)
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if len(answer.Sources) == 0 {
+		t.Fatal("answer has no sources")
+	}
+	chunkID := answer.Sources[0].ChunkID
+	usage, err := eng.(*engine).store.ChunkUsages(ctx, []int64{chunkID}, 0)
+	if err != nil {
+		t.Fatalf("ChunkUsages: %v", err)
+	}
+	u := usage[chunkID]
+	if u.Retrieved != 1 {
+		t.Errorf("usage = %+v, want one retrieval", u)
+	}
+	if answerAccepted(answer) && u.Cited != 1 {
+		t.Errorf("accepted answer cites [Source 1]: usage = %+v", u)
+	}
+
+	// The next search boosts the chunk by its usage.
+	results, trace, err := eng.Retrieve(ctx, question)
+	if err != nil {
+		t.Fatalf("Retrieve: %v", err)
+	}
+	if trace.UsageBoosted == 0 {
+		t.Errorf("trace = %+v, want the used chunk boosted", trace)
+	}
+
+	// Answers citing the lowest ranked chunk lift it ahead of the others.
+	e := eng.(*engine)
+	last := results[len(results)-1].ChunkID
+	for i := 0; i < 10; i++ {
+		if err := e.store.RecordChunkUsage(ctx, nil, []int64{last}, e.usageHalfLife()); err != nil {
+			t.Fatalf("RecordChunkUsage: %v", err)
+		}
+	}
+	results, _, err = eng.Retrieve(ctx, question)
+	if err != nil {
+		t.Fatalf("Retrieve: %v", err)
+	}
+	if len(results) < 2 || results[0].ChunkID != last {
+		t.Errorf("results = %+v, want the cited chunk %d first", results, last)
+	}
+}
+
+func TestCitedChunks(t *testing.T) {
+	yes, no := true, false
+	answer := &Answer{
+		Text:       "Opens at 10 bar [Source 2]; see also manual.pdf.",
+		Confidence: 0.8,
+		Found:      &yes,
+		Sources: []Source{
+			{ChunkID: 11, Filename: "pump.txt"},
+			{ChunkID: 12, Filename: "valve.txt"},
+		},
+	}
+	if got := citedChunks(answer); len(got) != 1 || got[0] != 12 {
+		t.Errorf("citedChunks = %v, want [12]", got)
+	}
+	if !answerAccepted(answer) {
+		t.Error("confident answer not accepted")
+	}
+	answer.Found = &no
+	if answerAccepted(answer) {
+		t.Error("answer that found nothing accepted")
+	}
+	answer.Found, answer.Confidence = nil, 0.3
+	if answerAccepted(answer) {
+		t.Error("low-confidence answer accepted")
+	}
+}
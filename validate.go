@@ -0,0 +1,450 @@
+package goreason
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bbiangul/go-reason/llm"
+	"github.com/bbiangul/go-reason/store"
+)
+
+// Statuses of a ConfigCheck.
+const (
+	CheckOK      = "ok"
+	CheckWarn    = "warn"
+	CheckFail    = "fail"
+	CheckSkipped = "skipped"
+)
+
+// providerCheckTimeout bounds each provider call of ValidateConfig,
+// including the LLM client's retries.
+const providerCheckTimeout = 30 * time.Second
+
+// ConfigCheck is one finding of ValidateConfig. Fix says what to change
+// when the check did not pass.
+type ConfigCheck struct {
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	Detail    string `json:"detail"`
+	Fix       string `json:"fix,omitempty"`
+	ElapsedMs int64  `json:"elapsed_ms,omitempty"`
+}
+
+// ConfigReport is the result of ValidateConfig. OK is false when any
+// check failed; warnings do not stop the engine from starting.
+type ConfigReport struct {
+	OK     bool          `json:"ok"`
+	Checks []ConfigCheck `json:"checks"`
+}
+
+// ValidateConfig checks cfg against the running environment without
+// creating an engine: the settings New validates, the SQLite extensions
+// of this build, the embedding model recorded in an existing database, and
+// one call to each configured provider, which shows whether it is
+// reachable, accepts the API key, and serves the model, and whether the
+// embedding dimensions match the config. Provider calls are real requests
+// and may be billed.
+func ValidateConfig(ctx context.Context, cfg Config) *ConfigReport {
+	if cfg.EmbeddingDim == 0 {
+		cfg.EmbeddingDim = 768
+	}
+	r := &ConfigReport{OK: true}
+	add := func(c ConfigCheck) {
+		if c.Status == CheckFail {
+			r.OK = false
+		}
+		r.Checks = append(r.Checks, c)
+	}
+
+	add(checkSettings(cfg))
+	for _, c := range checkSQLite(ctx) {
+		add(c)
+	}
+	add(checkDatabase(ctx, cfg))
+
+	add(checkProvider(ctx, "chat", cfg.Chat, func(ctx context.Context, p llm.Provider) (string, error) {
+		return "", pingChat(ctx, p)
+	}))
+	add(checkProvider(ctx, "embedding", cfg.Embedding, embeddingProbe(cfg.EmbeddingDim, "embedding_dim")))
+	if cfg.Escalation.Provider != "" {
+		add(checkProvider(ctx, "escalation", cfg.Escalation, func(ctx context.Context, p llm.Provider) (string, error) {
+			return "", pingChat(ctx, p)
+		}))
+	}
+	if cfg.CaptionImages {
+		add(checkProvider(ctx, "vision", cfg.Vision, func(ctx context.Context, p llm.Provider) (string, error) {
+			return "", pingChat(ctx, p)
+		}))
+	} else {
+		add(ConfigCheck{Name: "vision", Status: CheckSkipped, Detail: "caption_images is off"})
+	}
+	if cfg.SecondaryEmbedding.Provider != "" {
+		add(checkProvider(ctx, "secondary_embedding", cfg.SecondaryEmbedding,
+			embeddingProbe(cfg.SecondaryEmbeddingDim, "secondary_embedding_dim")))
+	}
+	if cfg.Sparse.Provider != "" {
+		add(checkSparse(ctx, cfg.Sparse))
+	}
+	if cfg.ImageEmbedding.Provider != "" {
+		add(checkImageEmbedding(ctx, cfg))
+	}
+	return r
+}
+
+// String renders the report for a terminal, one line per check with the
+// fix below each check that did not pass.
+func (r *ConfigReport) String() string {
+	var b strings.Builder
+	for _, c := range r.Checks {
+		fmt.Fprintf(&b, "[%-7s] %-20s %s", strings.ToUpper(c.Status), c.Name, c.Detail)
+		if c.ElapsedMs > 0 {
+			fmt.Fprintf(&b, " (%dms)", c.ElapsedMs)
+		}
+		b.WriteString("\n")
+		if c.Fix != "" {
+			fmt.Fprintf(&b, "%31s%s\n", "fix: ", c.Fix)
+		}
+	}
+	failed, warned := 0, 0
+	for _, c := range r.Checks {
+		switch c.Status {
+		case CheckFail:
+			failed++
+		case CheckWarn:
+			warned++
+		}
+	}
+	switch {
+	case failed > 0:
+		fmt.Fprintf(&b, "\n%d of %d checks failed, %d warnings.\n", failed, len(r.Checks), warned)
+	case warned > 0:
+		fmt.Fprintf(&b, "\nAll checks passed with %d warnings.\n", warned)
+	default:
+		b.WriteString("\nAll checks passed.\n")
+	}
+	return b.String()
+}
+
+// validateConfig checks the settings New validates before opening
+// anything. Chunk types, validation checks, and encryption keys are
+// checked where they are compiled.
+func validateConfig(cfg Config) error {
+	if cfg.CommunityLevels < 0 || cfg.CommunityLevels > 2 {
+		return fmt.Errorf("%w: community_levels must be 1 or 2", ErrInvalidConfig)
+	}
+	if cfg.CommunitySummaryIntervalMinutes < 0 {
+		return fmt.Errorf("%w: community_summary_interval_minutes must not be negative", ErrInvalidConfig)
+	}
+	if cfg.LLMCallEstimateMs < 0 {
+		return fmt.Errorf("%w: llm_call_estimate_ms must not be negative", ErrInvalidConfig)
+	}
+	if cfg.MaxContradictionChecks < 0 {
+		return fmt.Errorf("%w: max_contradiction_checks must not be negative", ErrInvalidConfig)
+	}
+	if cfg.ReasoningSearchBudget < 0 {
+		return fmt.Errorf("%w: reasoning_search_budget must not be negative", ErrInvalidConfig)
+	}
+	for _, t := range cfg.GraphSkipChunkTypes {
+		if slices.Contains(cfg.GraphChunkTypes, t) {
+			return fmt.Errorf("%w: chunk type %q is in both graph_chunk_types and graph_skip_chunk_types", ErrInvalidConfig, t)
+		}
+	}
+	if cfg.Immutable && !cfg.ReadOnly {
+		return fmt.Errorf("%w: immutable requires read_only", ErrInvalidConfig)
+	}
+	switch cfg.Chat.PromptStyle {
+	case "", llm.PromptStyleChat, llm.PromptStyleReasoning:
+	default:
+		return fmt.Errorf("%w: chat prompt_style must be %q or %q, got %q",
+			ErrInvalidConfig, llm.PromptStyleChat, llm.PromptStyleReasoning, cfg.Chat.PromptStyle)
+	}
+	if !validEmbedTruncation(cfg.EmbedTruncation) {
+		return fmt.Errorf("%w: unknown embed_truncation %q", ErrInvalidConfig, cfg.EmbedTruncation)
+	}
+	if cfg.SecondaryEmbedding.Provider != "" && cfg.SecondaryEmbeddingDim <= 0 {
+		return fmt.Errorf("%w: secondary_embedding_dim is required with secondary_embedding", ErrInvalidConfig)
+	}
+	if err := validateExperiments(cfg.Experiments); err != nil {
+		return err
+	}
+	if err := validateKeyFacts(cfg.KeyFacts); err != nil {
+		return err
+	}
+	if err := validateScope(cfg.Scope); err != nil {
+		return err
+	}
+	if err := validateReplication(cfg.Replication); err != nil {
+		return err
+	}
+	if !validInjectionPolicy(cfg.InjectionPolicy) {
+		return fmt.Errorf("%w: unknown injection_policy %q", ErrInvalidConfig, cfg.InjectionPolicy)
+	}
+	if cfg.GraphSeedSimilarity < 0 || cfg.GraphSeedSimilarity > 1 {
+		return fmt.Errorf("%w: graph_seed_similarity must be between 0 and 1", ErrInvalidConfig)
+	}
+	if cfg.FTSHeadingWeight < 0 {
+		return fmt.Errorf("%w: fts_heading_weight must not be negative", ErrInvalidConfig)
+	}
+	if cfg.StopEntityThreshold < 0 || cfg.StopEntityThreshold > 1 {
+		return fmt.Errorf("%w: stop_entity_threshold must be between 0 and 1", ErrInvalidConfig)
+	}
+	if cfg.RetrievalLegTimeoutMs < 0 || cfg.TranslationTimeoutMs < 0 {
+		return fmt.Errorf("%w: retrieval_leg_timeout_ms and translation_timeout_ms must not be negative", ErrInvalidConfig)
+	}
+	if cfg.Chat.Provider == "onnx" || cfg.Vision.Provider == "onnx" || cfg.Escalation.Provider == "onnx" {
+		return fmt.Errorf("%w: the onnx provider serves embeddings only", ErrInvalidConfig)
+	}
+	if cfg.EscalationThreshold < 0 || cfg.EscalationThreshold > 1 {
+		return fmt.Errorf("%w: escalation_threshold must be between 0 and 1", ErrInvalidConfig)
+	}
+	return nil
+}
+
+// checkSettings runs the validation New does before opening anything.
+func checkSettings(cfg Config) ConfigCheck {
+	c := ConfigCheck{Name: "settings"}
+	_, _, _, err := compileChunkTypes(cfg.ChunkTypes)
+	if err == nil {
+		_, err = compileValidationChecks(cfg.ValidationChecks)
+	}
+	if err == nil {
+		err = validateConfig(cfg)
+	}
+	if err == nil {
+		_, err = contentCipher(cfg.EncryptionKeys)
+	}
+	if err != nil {
+		c.Status, c.Detail = CheckFail, err.Error()
+		c.Fix = "correct the setting named above; New refuses this config"
+		return c
+	}
+	c.Status, c.Detail = CheckOK, "all settings valid"
+	return c
+}
+
+// checkSQLite reports whether this build has sqlite-vec and FTS5.
+func checkSQLite(ctx context.Context) []ConfigCheck {
+	f, err := store.Features(ctx)
+	if err != nil {
+		return []ConfigCheck{{Name: "sqlite", Status: CheckFail, Detail: err.Error(),
+			Fix: "build with CGO_ENABLED=1 and a C compiler; the SQLite driver uses cgo"}}
+	}
+	vec := ConfigCheck{Name: "sqlite-vec", Status: CheckOK,
+		Detail: fmt.Sprintf("%s (SQLite %s)", f.VecVersion, f.SQLiteVersion)}
+	if f.VecVersion == "" {
+		vec.Status, vec.Detail = CheckFail, "vec_version() is not available"
+		vec.Fix = "build with CGO_ENABLED=1 so the sqlite-vec bindings are linked"
+	}
+	fts := ConfigCheck{Name: "fts5", Status: CheckOK, Detail: "available"}
+	if !f.FTS5 {
+		fts.Status, fts.Detail = CheckFail, "FTS5 is not compiled into SQLite"
+		fts.Fix = "build with -tags sqlite_fts5 (go build -tags sqlite_fts5 ./...)"
+	}
+	return []ConfigCheck{vec, fts}
+}
+
+// checkDatabase compares the configured embedding model with the one that
+// produced the vectors of an existing database.
+func checkDatabase(ctx context.Context, cfg Config) ConfigCheck {
+	path := cfg.resolveDBPath()
+	c := ConfigCheck{Name: "database"}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		if cfg.ReadOnly {
+			c.Status, c.Detail = CheckFail, path+" does not exist"
+			c.Fix = "read_only needs an existing database; correct db_path or turn read_only off"
+			return c
+		}
+		c.Status, c.Detail = CheckOK, path+" will be created"
+		return c
+	} else if err != nil {
+		c.Status, c.Detail = CheckFail, err.Error()
+		c.Fix = "make db_path readable by the server's user"
+		return c
+	}
+
+	s, err := store.OpenReaderWithOptions(path, cfg.EmbeddingDim, store.ReaderOptions{Immutable: cfg.Immutable})
+	if err != nil {
+		c.Status, c.Detail = CheckFail, err.Error()
+		c.Fix = "check that db_path is a GoReason SQLite database"
+		return c
+	}
+	defer s.Close()
+	var docs int
+	if err := s.DB().QueryRowContext(ctx, "SELECT COUNT(*) FROM documents").Scan(&docs); err != nil {
+		c.Status, c.Detail = CheckFail, fmt.Sprintf("%s: %v", path, err)
+		c.Fix = "check that db_path is a GoReason SQLite database"
+		return c
+	}
+	c.Status, c.Detail = CheckOK, fmt.Sprintf("%s (%d documents)", path, docs)
+
+	want := configuredEmbeddingModel(cfg)
+	have, err := s.GetEmbeddingModel(ctx, store.ChunkVectorSpace)
+	if err != nil {
+		// Databases from before model tracking are migrated at startup.
+		c.Status = CheckWarn
+		c.Detail += fmt.Sprintf("; embedding model not readable: %v", err)
+		return c
+	}
+	if have == nil || (have.Model == want.Model && have.Dim == want.Dim) {
+		return c
+	}
+	hasVectors, err := s.HasEmbeddings(ctx)
+	if err != nil || !hasVectors {
+		return c
+	}
+	c.Detail += fmt.Sprintf("; vectors were produced by %q (%d dims), configured model is %q (%d dims)",
+		have.Model, have.Dim, want.Model, want.Dim)
+	if cfg.EmbeddingDriftPolicy == EmbeddingDriftReembed {
+		c.Status = CheckWarn
+		c.Fix = "the corpus will be re-embedded at startup (embedding_drift_policy: reembed)"
+		return c
+	}
+	c.Status = CheckFail
+	c.Fix = fmt.Sprintf("configure %q again, or re-embed the corpus (POST /reembed, or embedding_drift_policy: reembed); queries fail until then", have.Model)
+	return c
+}
+
+// pingChat sends the provider a minimal chat request.
+func pingChat(ctx context.Context, p llm.Provider) error {
+	_, err := p.Chat(ctx, llm.ChatRequest{
+		Messages:  []llm.Message{{Role: "user", Content: "Reply with OK."}},
+		MaxTokens: 16,
+	})
+	return err
+}
+
+// embeddingProbe embeds one text and compares the vector's length with
+// the configured dimension, named by setting.
+func embeddingProbe(dim int, setting string) func(context.Context, llm.Provider) (string, error) {
+	return func(ctx context.Context, p llm.Provider) (string, error) {
+		vecs, err := p.Embed(ctx, []string{"goreason configuration check"})
+		if err != nil {
+			return "", err
+		}
+		if len(vecs) != 1 {
+			return "", fmt.Errorf("got %d embeddings for 1 text", len(vecs))
+		}
+		if len(vecs[0]) != dim {
+			return "", &dimensionError{setting: setting, got: len(vecs[0]), want: dim}
+		}
+		return fmt.Sprintf("%d dims", dim), nil
+	}
+}
+
+// dimensionError is an embedding model whose vectors do not have the
+// configured dimension.
+type dimensionError struct {
+	setting   string
+	got, want int
+}
+
+func (e *dimensionError) Error() string {
+	return fmt.Sprintf("the model returns %d-dimensional vectors, %s is %d", e.got, e.setting, e.want)
+}
+
+// checkProvider creates the provider of c and runs probe against it.
+func checkProvider(ctx context.Context, name string, c LLMConfig, probe func(context.Context, llm.Provider) (string, error)) ConfigCheck {
+	p, err := llm.NewProvider(llm.Config{
+		Provider:    c.Provider,
+		Model:       c.Model,
+		BaseURL:     c.BaseURL,
+		APIKey:      c.APIKey,
+		ONNXLibrary: c.ONNXLibrary,
+	})
+	if err != nil {
+		return ConfigCheck{Name: name, Status: CheckFail, Detail: err.Error(),
+			Fix: "set provider to ollama, lmstudio, openrouter, openai, groq, xai, gemini, custom, or onnx"}
+	}
+	return runProbe(ctx, name, c, func(ctx context.Context) (string, error) { return probe(ctx, p) })
+}
+
+// checkSparse probes the sparse embedding provider.
+func checkSparse(ctx context.Context, c LLMConfig) ConfigCheck {
+	p, err := llm.NewSparseEmbedder(llm.Config{Provider: c.Provider, Model: c.Model, BaseURL: c.BaseURL, APIKey: c.APIKey})
+	if err != nil {
+		return ConfigCheck{Name: "sparse_embedding", Status: CheckFail, Detail: err.Error(),
+			Fix: "correct the sparse provider settings"}
+	}
+	return runProbe(ctx, "sparse_embedding", c, func(ctx context.Context) (string, error) {
+		_, err := p.EmbedSparse(ctx, []string{"goreason configuration check"})
+		return "", err
+	})
+}
+
+// checkImageEmbedding probes the multimodal embedding provider's text
+// side, which shares the image vectors' dimension.
+func checkImageEmbedding(ctx context.Context, cfg Config) ConfigCheck {
+	c := cfg.ImageEmbedding
+	dim := cfg.ImageEmbeddingDim
+	if dim == 0 {
+		dim = 1024
+	}
+	p, err := llm.NewMultimodalEmbedder(llm.Config{Provider: c.Provider, Model: c.Model, BaseURL: c.BaseURL, APIKey: c.APIKey})
+	if err != nil {
+		return ConfigCheck{Name: "image_embedding", Status: CheckFail, Detail: err.Error(),
+			Fix: "correct the image embedding provider settings"}
+	}
+	return runProbe(ctx, "image_embedding", c, func(ctx context.Context) (string, error) {
+		vecs, err := p.EmbedTexts(ctx, []string{"goreason configuration check"})
+		if err != nil {
+			return "", err
+		}
+		if len(vecs) == 1 && len(vecs[0]) != dim {
+			return "", &dimensionError{setting: "image_embedding_dim", got: len(vecs[0]), want: dim}
+		}
+		return fmt.Sprintf("%d dims", dim), nil
+	})
+}
+
+// runProbe times probe under providerCheckTimeout and turns its error
+// into a failed check with a fix.
+func runProbe(ctx context.Context, name string, c LLMConfig, probe func(context.Context) (string, error)) ConfigCheck {
+	ctx, cancel := context.WithTimeout(ctx, providerCheckTimeout)
+	defer cancel()
+	start := time.Now()
+	detail, err := probe(ctx)
+	check := ConfigCheck{Name: name, ElapsedMs: time.Since(start).Milliseconds()}
+	target := c.Provider + " " + c.Model
+	if err != nil {
+		check.Status, check.Detail = CheckFail, fmt.Sprintf("%s: %v", target, err)
+		check.Fix = providerFix(c, err)
+		return check
+	}
+	check.Status, check.Detail = CheckOK, target
+	if detail != "" {
+		check.Detail += ", " + detail
+	}
+	return check
+}
+
+// providerFix suggests a fix for a failed provider call from its error.
+func providerFix(c LLMConfig, err error) string {
+	var dimErr *dimensionError
+	if errors.As(err, &dimErr) {
+		return fmt.Sprintf("set %s to %d, the model's dimension (existing vectors must then be re-embedded)", dimErr.setting, dimErr.got)
+	}
+	url := c.BaseURL
+	if url == "" {
+		url = "the provider's default URL"
+	}
+	msg := strings.ToLower(err.Error())
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return fmt.Sprintf("no answer from %s in time; check base_url and the network", url)
+	case strings.Contains(msg, "connection refused"), strings.Contains(msg, "no such host"), strings.Contains(msg, "dial tcp"):
+		return fmt.Sprintf("nothing answers at %s; start the %s server or correct base_url", url, c.Provider)
+	case strings.Contains(msg, "error 401"), strings.Contains(msg, "error 403"):
+		return "the provider rejected the API key; set api_key or the provider's API key environment variable"
+	case strings.Contains(msg, "error 404"), strings.Contains(msg, "not found"), strings.Contains(msg, "does not exist"):
+		if c.Provider == "ollama" {
+			return fmt.Sprintf("pull the model first: ollama pull %s", c.Model)
+		}
+		return fmt.Sprintf("check that the provider serves model %q and that base_url is right", c.Model)
+	}
+	return ""
+}
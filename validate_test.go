@@ -0,0 +1,68 @@
+package goreason
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bbiangul/go-reason/llm/llmtest"
+)
+
+func TestValidateConfig(t *testing.T) {
+	srv := llmtest.NewServer(nil)
+	defer srv.Close()
+	cfg := faultConfig(t.TempDir(), srv)
+	ctx := context.Background()
+
+	check := func(r *ConfigReport, name string) ConfigCheck {
+		t.Helper()
+		for _, c := range r.Checks {
+			if c.Name == name {
+				return c
+			}
+		}
+		t.Fatalf("no %q check in\n%s", name, r)
+		return ConfigCheck{}
+	}
+
+	r := ValidateConfig(ctx, cfg)
+	if !r.OK {
+		t.Fatalf("valid config failed:\n%s", r)
+	}
+	if c := check(r, "embedding"); c.Status != CheckOK || !strings.Contains(c.Detail, "4 dims") {
+		t.Errorf("embedding check = %+v", c)
+	}
+	if c := check(r, "vision"); c.Status != CheckSkipped {
+		t.Errorf("vision check = %+v, want skipped", c)
+	}
+
+	wrongDim := cfg
+	wrongDim.EmbeddingDim = 8
+	r = ValidateConfig(ctx, wrongDim)
+	c := check(r, "embedding")
+	if r.OK || c.Status != CheckFail || !strings.Contains(c.Fix, "embedding_dim to 4") {
+		t.Errorf("wrong dimension: ok=%v, check %+v", r.OK, c)
+	}
+
+	badSetting := cfg
+	badSetting.CommunityLevels = 3
+	if c := check(ValidateConfig(ctx, badSetting), "settings"); c.Status != CheckFail {
+		t.Errorf("bad setting: %+v", c)
+	}
+
+	dead := llmtest.NewServer(nil)
+	deadURL := dead.URL
+	dead.Close()
+	unreachable := cfg
+	unreachable.Embedding = LLMConfig{Provider: "custom", Model: "fake", BaseURL: deadURL}
+	shortCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	r = ValidateConfig(shortCtx, unreachable)
+	if c := check(r, "chat"); c.Status != CheckOK {
+		t.Errorf("chat check = %+v, want ok", c)
+	}
+	if c := check(r, "embedding"); r.OK || c.Status != CheckFail || c.Fix == "" {
+		t.Errorf("unreachable embedding: ok=%v, check %+v", r.OK, c)
+	}
+}
@@ -0,0 +1,46 @@
+package goreason
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/bbiangul/go-reason/reasoning"
+)
+
+// WithValidationChecks adds validation criteria for this query, on top of
+// Config.ValidationChecks.
+func WithValidationChecks(checks ...ValidationCheckConfig) QueryOption {
+	return func(o *queryOptions) { o.checks = append(o.checks, checks...) }
+}
+
+// compileValidationChecks validates the configured checks and converts
+// them for the reasoning engine.
+func compileValidationChecks(checks []ValidationCheckConfig) ([]reasoning.ValidationCheck, error) {
+	if len(checks) == 0 {
+		return nil, nil
+	}
+	out := make([]reasoning.ValidationCheck, 0, len(checks))
+	for _, c := range checks {
+		if !reasoning.ValidCheckKind(c.Check) {
+			return nil, fmt.Errorf("%w: unknown validation check %q", ErrInvalidConfig, c.Check)
+		}
+		vc := reasoning.ValidationCheck{Kind: c.Check, Limit: c.Limit, Message: c.Message}
+		switch c.Check {
+		case reasoning.CheckMaxWords:
+			if c.Limit <= 0 {
+				return nil, fmt.Errorf("%w: validation check %q needs a positive limit", ErrInvalidConfig, c.Check)
+			}
+		case reasoning.CheckMustMatch, reasoning.CheckMustNotMatch:
+			if c.Pattern == "" {
+				return nil, fmt.Errorf("%w: validation check %q needs a pattern", ErrInvalidConfig, c.Check)
+			}
+			re, err := regexp.Compile(c.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("%w: validation check %q pattern: %v", ErrInvalidConfig, c.Check, err)
+			}
+			vc.Pattern = re
+		}
+		out = append(out, vc)
+	}
+	return out, nil
+}
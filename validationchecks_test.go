@@ -0,0 +1,31 @@
+package goreason
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCompileValidationChecks(t *testing.T) {
+	checks, err := compileValidationChecks([]ValidationCheckConfig{
+		{Check: "cite_pages"},
+		{Check: "max_words", Limit: 120},
+		{Check: "must_not_match", Pattern: `(?i)as an ai`, Message: "Do not refer to yourself."},
+	})
+	if err != nil {
+		t.Fatalf("compileValidationChecks: %v", err)
+	}
+	if len(checks) != 3 || checks[1].Limit != 120 || checks[2].Pattern == nil {
+		t.Errorf("unexpected checks: %+v", checks)
+	}
+
+	for _, bad := range []ValidationCheckConfig{
+		{Check: "rhymes"},
+		{Check: "max_words"},
+		{Check: "must_match"},
+		{Check: "must_match", Pattern: "("},
+	} {
+		if _, err := compileValidationChecks([]ValidationCheckConfig{bad}); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%+v: error = %v, want ErrInvalidConfig", bad, err)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package goreason
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/bbiangul/go-reason/store"
+)
+
+// queryPool runs queries on worker engines (Config.QueryWorkers). Each
+// worker has its own read-only store and retriever, so concurrent queries
+// no longer queue for the writer's four-connection pool. Jobs are dealt
+// round-robin onto per-worker queues; a worker whose queue is empty takes
+// the newest job from the longest other queue, so one slow query does not
+// hold up the jobs queued behind it.
+type queryPool struct {
+	workers []*engine
+
+	mu        sync.Mutex
+	cond      *sync.Cond
+	queues    [][]*queryJob
+	next      int
+	queued    int
+	busy      int
+	completed int64
+	stolen    int64
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+// queryJob is one query waiting for or running on a worker.
+type queryJob struct {
+	ctx    context.Context
+	run    func(w *engine) (*Answer, error)
+	answer *Answer
+	err    error
+	done   chan struct{}
+}
+
+// QueryWorkerStats is a snapshot of the query worker pool. All fields are
+// zero when queries run on the calling goroutine.
+type QueryWorkerStats struct {
+	Workers   int   `json:"workers"`
+	Busy      int   `json:"busy"`
+	Queued    int   `json:"queued"`
+	Completed int64 `json:"completed"`
+	Stolen    int64 `json:"stolen"` // jobs run by a worker other than the one they were queued on
+}
+
+// startQueryWorkers opens n read-only stores on dbPath and starts a worker
+// engine on each. Workers share the primary engine's providers, reasoner,
+// compiled config, and query translator; only the store and retriever are
+// their own.
+func (e *engine) startQueryWorkers(dbPath string, n int) (*queryPool, error) {
+	p := &queryPool{queues: make([][]*queryJob, n)}
+	p.cond = sync.NewCond(&p.mu)
+	for i := 0; i < n; i++ {
+		r, err := store.OpenReaderWithOptions(dbPath, e.cfg.EmbeddingDim, store.ReaderOptions{Immutable: e.cfg.Immutable})
+		if err != nil {
+			for _, w := range p.workers {
+				w.store.Close()
+			}
+			return nil, fmt.Errorf("opening query worker %d: %w", i, err)
+		}
+		r.SetContentCipher(e.store.ContentCipher())
+		r.SetSearchReadyOnly(!e.cfg.SearchUnreadyDocuments)
+		w := &engine{
+			cfg:          e.cfg,
+			store:        r,
+			chatLLM:      e.chatLLM,
+			embedLLM:     e.embedLLM,
+			visionLLM:    e.visionLLM,
+			sparseLLM:    e.sparseLLM,
+			imageLLM:     e.imageLLM,
+			reasoner:     e.reasoner,
+			escalator:    e.escalator,
+			collChunkers: e.collChunkers,
+			checks:       e.checks,
+			secondaryLLM: e.secondaryLLM,
+			typeBoosts:   e.typeBoosts,
+			scope:        e.scope,
+			querySink:    e.querySink,
+			queryLog:     e.queryLog,
+			primary:      e,
+		}
+		w.retriever = w.newRetriever()
+		// The read-only store cannot persist translations, so workers
+		// use the primary's translator and its cache.
+		w.retriever.SetTranslator(e.retriever.Translator())
+		p.workers = append(p.workers, w)
+	}
+	for i := range p.workers {
+		p.wg.Add(1)
+		go p.work(i)
+	}
+	return p, nil
+}
+
+// do queues run and waits for its result. A job whose context ends while
+// it is still queued is dropped without running.
+func (p *queryPool) do(ctx context.Context, run func(w *engine) (*Answer, error)) (*Answer, error) {
+	job := &queryJob{ctx: ctx, run: run, done: make(chan struct{})}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrStoreClosed
+	}
+	p.queues[p.next] = append(p.queues[p.next], job)
+	p.next = (p.next + 1) % len(p.queues)
+	p.queued++
+	p.cond.Signal()
+	p.mu.Unlock()
+
+	select {
+	case <-job.done:
+		return job.answer, job.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// work runs jobs for worker i until the pool is closed and drained.
+func (p *queryPool) work(i int) {
+	defer p.wg.Done()
+	w := p.workers[i]
+	for {
+		p.mu.Lock()
+		for p.queued == 0 && !p.closed {
+			p.cond.Wait()
+		}
+		if p.queued == 0 {
+			p.mu.Unlock()
+			return
+		}
+		job := p.take(i)
+		p.busy++
+		p.mu.Unlock()
+
+		if err := job.ctx.Err(); err != nil {
+			job.err = err
+		} else {
+			job.answer, job.err = job.run(w)
+		}
+		close(job.done)
+
+		p.mu.Lock()
+		p.busy--
+		p.completed++
+		p.mu.Unlock()
+	}
+}
+
+// take removes the next job for worker i: the oldest on its own queue, or
+// else the newest on the longest other queue. p.mu must be held and
+// p.queued must be positive.
+func (p *queryPool) take(i int) *queryJob {
+	p.queued--
+	if q := p.queues[i]; len(q) > 0 {
+		job := q[0]
+		q[0] = nil
+		p.queues[i] = q[1:]
+		return job
+	}
+	victim := -1
+	for j, q := range p.queues {
+		if len(q) > 0 && (victim < 0 || len(q) > len(p.queues[victim])) {
+			victim = j
+		}
+	}
+	q := p.queues[victim]
+	job := q[len(q)-1]
+	p.queues[victim] = q[:len(q)-1]
+	p.stolen++
+	return job
+}
+
+// stats reports the pool's workers and jobs.
+func (p *queryPool) stats() QueryWorkerStats {
+	if p == nil {
+		return QueryWorkerStats{}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return QueryWorkerStats{
+		Workers:   len(p.workers),
+		Busy:      p.busy,
+		Queued:    p.queued,
+		Completed: p.completed,
+		Stolen:    p.stolen,
+	}
+}
+
+// close stops accepting jobs, waits for queued and running ones, and
+// closes the workers' stores.
+func (p *queryPool) close() {
+	p.mu.Lock()
+	p.closed = true
+	p.cond.Broadcast()
+	p.mu.Unlock()
+	p.wg.Wait()
+	for _, w := range p.workers {
+		if w.store != nil {
+			w.store.Close()
+		}
+	}
+}
+
+// QueryWorkerStats reports the query worker pool's load.
+func (e *engine) QueryWorkerStats() QueryWorkerStats {
+	return e.workers.stats()
+}
+
+// writeStore returns the store that accepts writes. Query workers log
+// queries through the primary engine, as their own store is read-only.
+func (e *engine) writeStore() *store.Store {
+	if e.primary != nil {
+		return e.primary.store
+	}
+	return e.store
+}
+
+// logQuery records a query and returns its log ID, or 0 when the engine is
+// read-only or logging fails. A read-only engine writes the entry to its
+// query log sink, if any.
+func (e *engine) logQuery(ctx context.Context, entry store.QueryLog) int64 {
+	if e.writable() != nil {
+		e.querySink.write(ctx, entry)
+		return 0
+	}
+	id, err := e.queryLog.InsertQueryLog(ctx, entry)
+	if err != nil {
+		slog.WarnContext(ctx, "query: logging failed (non-fatal)", "error", err)
+		return 0
+	}
+	return id
+}
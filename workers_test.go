@@ -0,0 +1,135 @@
+package goreason
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitQueued waits until n jobs are queued on p.
+func waitQueued(t *testing.T, p *queryPool, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for p.stats().Queued != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("queued = %d, want %d", p.stats().Queued, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestQueryPoolStealsFromBusyWorker(t *testing.T) {
+	p := &queryPool{queues: make([][]*queryJob, 2), workers: []*engine{{}, {}}}
+	p.cond = sync.NewCond(&p.mu)
+
+	// Queue four jobs before any worker runs: round-robin puts two on each
+	// queue. Only worker 0 is started, so it must steal worker 1's jobs.
+	var (
+		mu  sync.Mutex
+		ran []int
+	)
+	results := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		go func() {
+			_, err := p.do(context.Background(), func(*engine) (*Answer, error) {
+				mu.Lock()
+				ran = append(ran, i)
+				mu.Unlock()
+				return &Answer{}, nil
+			})
+			results <- err
+		}()
+	}
+	waitQueued(t, p, 4)
+	p.wg.Add(1)
+	go p.work(0)
+	for i := 0; i < 4; i++ {
+		if err := <-results; err != nil {
+			t.Fatalf("job: %v", err)
+		}
+	}
+	p.close()
+
+	if len(ran) != 4 {
+		t.Errorf("ran %v, want all four jobs", ran)
+	}
+	if st := p.stats(); st.Completed != 4 || st.Stolen != 2 || st.Queued != 0 || st.Busy != 0 {
+		t.Errorf("stats = %+v, want 4 completed, 2 stolen", st)
+	}
+	if _, err := p.do(context.Background(), nil); !errors.Is(err, ErrStoreClosed) {
+		t.Errorf("do after close = %v, want ErrStoreClosed", err)
+	}
+}
+
+func TestQueryPoolDropsCancelledJobs(t *testing.T) {
+	p := &queryPool{queues: make([][]*queryJob, 1), workers: []*engine{{}}}
+	p.cond = sync.NewCond(&p.mu)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.do(ctx, func(*engine) (*Answer, error) {
+			t.Error("a job cancelled while queued must not run")
+			return nil, nil
+		})
+		done <- err
+	}()
+	waitQueued(t, p, 1)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+	p.wg.Add(1)
+	go p.work(0)
+	p.close()
+	if st := p.stats(); st.Completed != 1 {
+		t.Errorf("stats = %+v, want the cancelled job drained", st)
+	}
+}
+
+func TestQueryThroughWorkers(t *testing.T) {
+	eng, _, path := faultEngine(t, nil, skipGraph, func(cfg *Config) {
+		cfg.QueryWorkers = 3
+		cfg.QueryCacheSize = 8
+	})
+
+	// Ingest through the primary engine after the workers opened their
+	// read connections; the workers see the new document.
+	ctx := context.Background()
+	if err := os.WriteFile(path, []byte("Pump maintenance.\n\nThe relief valve on the discharge line opens at 10 bar.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := eng.Ingest(ctx, path); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	answers := make([]*Answer, 6)
+	errs := make([]error, 6)
+	for i := range answers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			answers[i], errs[i] = eng.Query(ctx, "When does the relief valve open?")
+		}()
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("query %d: %v", i, err)
+		}
+		if len(answers[i].Sources) == 0 || answers[i].QueryID == 0 {
+			t.Errorf("query %d: answer = %+v, want sources and a logged query ID", i, answers[i])
+		}
+	}
+
+	if st := eng.QueryWorkerStats(); st.Workers != 3 || st.Completed != 6 {
+		t.Errorf("worker stats = %+v", st)
+	}
+	if cs := eng.QueryCacheStats(); cs.Capacity != 4*8 || cs.Hits+cs.Misses == 0 {
+		t.Errorf("query cache stats = %+v, want summed over the engine and its workers", cs)
+	}
+}